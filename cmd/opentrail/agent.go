@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"opentrail/internal/agents"
+)
+
+// managedAgent registers "opentrail ship" with a managing server, applies the configuration the
+// server assigns it and reports its tail positions in heartbeats
+type managedAgent struct {
+	client       *agents.Client
+	registration agents.Registration
+	interval     time.Duration
+	config       agents.ManagedConfig
+
+	mu        sync.Mutex
+	positions []*agents.TailPosition
+	// changed is set once the server assigned another configuration, which applies on restart
+	changed bool
+}
+
+// startAgent registers with the managing server and fetches the configuration assigned to the agent
+func startAgent(ctx context.Context, managerURL string) (*managedAgent, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine hostname: %w", err)
+	}
+	agent := &managedAgent{
+		client:       agents.NewClient(managerURL),
+		registration: agents.Registration{Hostname: hostname, Version: Version},
+	}
+	if err := agent.register(ctx); err != nil {
+		return nil, fmt.Errorf("failed to register with %s: %w", managerURL, err)
+	}
+	config, err := agent.client.Config(ctx, agent.registration.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch agent configuration: %w", err)
+	}
+	agent.config = config
+	return agent, nil
+}
+
+// register registers the agent, keeping the ID it was given before
+func (a *managedAgent) register(ctx context.Context) error {
+	registered, err := a.client.Register(ctx, a.registration)
+	if err != nil {
+		return err
+	}
+	a.registration.ID = registered.ID
+	a.interval = time.Duration(registered.HeartbeatInterval) * time.Second
+	if a.interval <= 0 {
+		a.interval = agents.DefaultHeartbeatInterval
+	}
+	return nil
+}
+
+// pipeline returns how the lines of a file are processed before they are shipped
+func (a *managedAgent) pipeline(file agents.FileConfig) (*agents.Pipeline, error) {
+	return agents.NewPipeline(a.config.Config, file, a.registration.Hostname)
+}
+
+// track starts reporting the tail position of a file
+func (a *managedAgent) track(path string) *agents.TailPosition {
+	position := &agents.TailPosition{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		position.Size = info.Size()
+	}
+	a.mu.Lock()
+	a.positions = append(a.positions, position)
+	a.mu.Unlock()
+	return position
+}
+
+// advance records that n more bytes of a file were shipped
+func (a *managedAgent) advance(position *agents.TailPosition, n int) {
+	a.mu.Lock()
+	position.Offset += int64(n)
+	if position.Offset > position.Size {
+		position.Size = position.Offset
+	}
+	a.mu.Unlock()
+}
+
+// run sends heartbeats until the context is done
+func (a *managedAgent) run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.heartbeat(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Agent heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// heartbeat reports the configuration applied and the tail positions, registering again if the
+// server forgot the agent
+func (a *managedAgent) heartbeat(ctx context.Context) error {
+	a.mu.Lock()
+	heartbeat := agents.Heartbeat{ID: a.registration.ID, ConfigHash: a.config.Hash}
+	for _, position := range a.positions {
+		if info, err := os.Stat(position.Path); err == nil && info.Size() > position.Size {
+			position.Size = info.Size()
+		}
+		heartbeat.Positions = append(heartbeat.Positions, *position)
+	}
+	a.mu.Unlock()
+
+	response, err := a.client.Heartbeat(ctx, heartbeat)
+	if errors.Is(err, agents.ErrUnknownAgent) {
+		if err := a.register(ctx); err != nil {
+			return err
+		}
+		response, err = a.client.Heartbeat(ctx, heartbeat)
+	}
+	if err != nil {
+		return err
+	}
+	if response.ConfigChanged && !a.changed {
+		a.changed = true
+		log.Printf("The agent configuration changed on the server; restart the shipper to apply it")
+	}
+	return nil
+}
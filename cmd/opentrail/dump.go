@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"opentrail/internal/dump"
+	"opentrail/internal/lifecycle"
+	"opentrail/internal/parquetlog"
+	"opentrail/internal/storage"
+)
+
+// runDump implements the "opentrail dump" subcommand, which writes every entry of the database,
+// as of a single point in time, to partitioned NDJSON or Parquet files for migrating to other systems
+func runDump(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: opentrail dump [flags] -output DIR\n\n")
+		fmt.Fprintf(fs.Output(), "Writes the entries of a consistent snapshot of the database to NDJSON or Parquet files, one per UTC day or hour.\n")
+		fmt.Fprintf(fs.Output(), "The database may be in use by a running server; entries stored meanwhile are not included.\n\n")
+		fs.PrintDefaults()
+	}
+
+	defaultDatabase := os.Getenv("OPENTRAIL_DATABASE_PATH")
+	if defaultDatabase == "" {
+		defaultDatabase = "logs.db"
+	}
+
+	databasePath := fs.String("database-path", defaultDatabase, "Path to SQLite database file")
+	output := fs.String("output", "", "Directory to write the files and manifest to; must be empty or not exist")
+	format := fs.String("format", string(dump.FormatNDJSON), "File format: ndjson or parquet")
+	partition := fs.String("partition", string(dump.PartitionDay), "File partitioning: day or hour (UTC)")
+	compress := fs.Bool("gzip", false, "Compress every NDJSON file with gzip")
+	parquetColumns := fs.Int("parquet-columns", parquetlog.DefaultStructuredColumns, "Number of the most common structured data keys flattened into Parquet columns")
+	startTime := fs.String("start-time", "", "Only dump entries at or after this time (RFC3339)")
+	endTime := fs.String("end-time", "", "Only dump entries at or before this time (RFC3339)")
+	progressEvery := fs.Int("progress-every", dump.DefaultProgressInterval, "Number of entries between progress reports")
+	webhook := fs.String("lifecycle-webhook", os.Getenv("OPENTRAIL_LIFECYCLE_WEBHOOK"), "URL the finished dump is announced to as a backup_completed event")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	notifier, err := lifecycle.NewNotifier(*webhook)
+	if err != nil {
+		log.Printf("Invalid -lifecycle-webhook: %v", err)
+		return 2
+	}
+
+	opts := dump.Options{
+		Format:           dump.Format(*format),
+		Partition:        dump.Partition(*partition),
+		Compress:         *compress,
+		ProgressInterval: *progressEvery,
+		Progress: func(p dump.Progress) {
+			log.Printf("Dumped %d of %d entries to %d files in %v", p.Entries, p.Total, p.Files, p.Elapsed.Round(time.Millisecond))
+		},
+	}
+	for _, bound := range []struct {
+		name  string
+		value string
+		dest  **time.Time
+	}{{"start-time", *startTime, &opts.StartTime}, {"end-time", *endTime, &opts.EndTime}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			log.Printf("Invalid -%s: %v", bound.name, err)
+			return 2
+		}
+		*bound.dest = &t
+	}
+
+	started := time.Now()
+	snapshot, err := storage.OpenSnapshot(*databasePath)
+	if err != nil {
+		log.Printf("Failed to open snapshot: %v", err)
+		return 1
+	}
+	defer snapshot.Close()
+	if opts.Format == dump.FormatParquet && *parquetColumns > 0 {
+		if opts.StructuredKeys, err = snapshot.StructuredKeys(*parquetColumns); err != nil {
+			log.Printf("Failed to list structured data keys: %v", err)
+			return 1
+		}
+	}
+	log.Printf("Snapshot of %s taken with %d entries up to ID %d", *databasePath, snapshot.TotalEntries, snapshot.MaxID)
+
+	manifest, err := dump.Write(snapshot, *output, opts)
+	if err != nil {
+		log.Printf("Dump failed: %v", err)
+		return 1
+	}
+	log.Printf("Dump of %d entries written to %s", manifest.Entries, *output)
+
+	// The dump is complete even if the webhook cannot be reached
+	err = notifier.Send(context.Background(), lifecycle.Event{
+		Type:    lifecycle.BackupCompleted,
+		Entries: manifest.Entries,
+		Details: map[string]string{
+			"database":    *databasePath,
+			"output":      *output,
+			"format":      string(manifest.Format),
+			"files":       strconv.Itoa(len(manifest.Files)),
+			"duration_ms": strconv.FormatInt(time.Since(started).Milliseconds(), 10),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to announce the dump to the lifecycle webhook: %v", err)
+	}
+	return 0
+}
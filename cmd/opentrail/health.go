@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// runHealth implements the "opentrail health" subcommand, which asks a running server whether it
+// is ready and exits nonzero if not. It serves as a container probe in images without curl.
+func runHealth(args []string) int {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: opentrail health [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Exits 0 if the server answers its readiness check, 1 if it is not ready or unreachable.\n")
+		fmt.Fprintf(fs.Output(), "Without -url, the server configured by the OPENTRAIL_HTTP_* and OPENTRAIL_ACME_DOMAINS environment variables is checked.\n\n")
+		fs.PrintDefaults()
+	}
+
+	serverURL := fs.String("url", "", "Base URL of the server, e.g. http://localhost:8080 or https://logs.example.com/logs")
+	liveness := fs.Bool("live", false, "Only check that the process answers (/api/health) instead of readiness (/api/ready)")
+	timeout := fs.Duration("timeout", 5*time.Second, "How long to wait for the answer")
+	insecure := fs.Bool("insecure", false, "Do not verify the server's TLS certificate")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	base, serverName := *serverURL, ""
+	if base == "" {
+		base, serverName = defaultHealthURL()
+	}
+	endpoint := "/api/ready"
+	if *liveness {
+		endpoint = "/api/health"
+	}
+	target, err := url.Parse(strings.TrimRight(base, "/") + endpoint)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		fmt.Fprintf(os.Stderr, "Invalid -url %q: must be an http or https URL\n", base)
+		return 2
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: serverName, InsecureSkipVerify: *insecure},
+		},
+		// A redirect, e.g. to HTTPS, means the probe is pointed at the wrong listener
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(target.String())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Health check failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Health check failed: %s returned %s: %s\n", target, resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	fmt.Printf("%s returned %s\n", target, resp.Status)
+	return 0
+}
+
+// defaultHealthURL returns the URL of the web interface configured by the environment, on the
+// loopback address unless the listener is bound to another one, and the TLS server name to verify
+// when it is served over HTTPS for ACME domains
+func defaultHealthURL() (string, string) {
+	host := strings.Trim(os.Getenv("OPENTRAIL_HTTP_BIND"), "[]")
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	port := os.Getenv("OPENTRAIL_HTTP_PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	scheme, serverName := "http", ""
+	if domains := os.Getenv("OPENTRAIL_ACME_DOMAINS"); domains != "" {
+		scheme = "https"
+		serverName = strings.TrimSpace(strings.Split(domains, ",")[0])
+	}
+	basePath := "/" + strings.Trim(os.Getenv("OPENTRAIL_HTTP_BASE_PATH"), "/ ")
+	return scheme + "://" + net.JoinHostPort(host, port) + strings.TrimRight(basePath, "/"), serverName
+}
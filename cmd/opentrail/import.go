@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/entryid"
+	"opentrail/internal/importer"
+	"opentrail/internal/interfaces"
+	"opentrail/internal/parser"
+	"opentrail/internal/storage"
+)
+
+// runImport implements the "opentrail import" subcommand, which bulk-loads
+// existing log files straight into the database without going over the network
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: opentrail import [flags] FILE...\n\n")
+		fmt.Fprintf(fs.Output(), "Imports plain text, gzip, NDJSON or exported JSON archives into the database.\n")
+		fmt.Fprintf(fs.Output(), "Existing rsyslog files, journalctl -o json exports and Loki exports can be migrated with -format.\n\n")
+		fs.PrintDefaults()
+	}
+
+	defaultDatabase := os.Getenv("OPENTRAIL_DATABASE_PATH")
+	if defaultDatabase == "" {
+		defaultDatabase = "logs.db"
+	}
+
+	databasePath := fs.String("database-path", defaultDatabase, "Path to SQLite database file")
+	format := fs.String("format", string(importer.FormatAuto), "Input format: auto, text, ndjson, json, rsyslog, journald or loki")
+	parserName := fs.String("parser", "rfc5424", "Parser for text input: rfc5424 (falls back to raw messages) or rfc5424-strict")
+	severityRules := fs.String("severity-rules", "", "JSON file of keyword rules inferring the severity of raw messages (replaces the defaults)")
+	timestampRules := fs.String("timestamp-rules", "", "JSON file of timestamp layouts and time zones for text input whose timestamps are not RFC3339")
+	transformRules := fs.String("transform-rules", "", "JSON file of rewrites applied to text input before parsing, such as stripping ANSI codes or container runtime prefixes")
+	defaultKeepANSIColors, _ := strconv.ParseBool(os.Getenv("OPENTRAIL_KEEP_ANSI_COLORS"))
+	keepANSIColors := fs.Bool("keep-ansi-colors", defaultKeepANSIColors, "Keep the ANSI color sequences of messages instead of escaping them, as the server's -keep-ansi-colors")
+	timestamps := fs.String("timestamps", string(importer.TimestampParsed), "Timestamp handling: parsed (keep source timestamps) or import (use import time)")
+	stateFile := fs.String("state-file", "", "File used to resume interrupted imports (default <database-path>.import-state)")
+	noResume := fs.Bool("no-resume", false, "Ignore and do not record resume state")
+	defaultEntryIDs := os.Getenv("OPENTRAIL_ENTRY_IDS")
+	if defaultEntryIDs == "" {
+		defaultEntryIDs = entryid.SchemeAutoIncrement
+	}
+	entryIDs := fs.String("entry-ids", defaultEntryIDs, "uid given to imported entries that have none: autoincrement (none), ulid or ksuid")
+	progressEvery := fs.Int("progress-every", importer.DefaultProgressInterval, "Number of records between progress reports")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var logParser interfaces.LogParser
+	switch *parserName {
+	case "rfc5424":
+		logParser = parser.NewRFC5424Parser(false)
+	case "rfc5424-strict":
+		logParser = parser.NewRFC5424Parser(true)
+	default:
+		log.Printf("Unknown parser %q", *parserName)
+		return 2
+	}
+	if *severityRules != "" {
+		table, err := parser.LoadSeverityTable(*severityRules)
+		if err != nil {
+			log.Printf("Failed to load severity rules: %v", err)
+			return 2
+		}
+		if rfc5424, ok := logParser.(*parser.RFC5424Parser); ok {
+			rfc5424.SetSeverityTable(table)
+		}
+	}
+	if *timestampRules != "" {
+		rules, err := parser.LoadTimestampRules(*timestampRules)
+		if err != nil {
+			log.Printf("Failed to load timestamp rules: %v", err)
+			return 2
+		}
+		if rfc5424, ok := logParser.(*parser.RFC5424Parser); ok {
+			rfc5424.SetTimestampRules(rules)
+		}
+	}
+	if *transformRules != "" {
+		rules, err := parser.LoadTransformRules(*transformRules)
+		if err != nil {
+			log.Printf("Failed to load transform rules: %v", err)
+			return 2
+		}
+		if rfc5424, ok := logParser.(*parser.RFC5424Parser); ok {
+			rfc5424.SetTransformRules(rules)
+		}
+	}
+
+	ids, err := entryid.New(strings.ToLower(*entryIDs))
+	if err != nil {
+		log.Printf("Invalid -entry-ids: %v", err)
+		return 2
+	}
+
+	if *stateFile == "" {
+		*stateFile = *databasePath + ".import-state"
+	}
+	if *noResume {
+		*stateFile = ""
+	}
+
+	logStorage, err := storage.NewSQLiteStorage(*databasePath)
+	if err != nil {
+		log.Printf("Failed to open storage: %v", err)
+		return 1
+	}
+	defer logStorage.Close()
+	if assigner, ok := logStorage.(interfaces.EntryIDAssigner); ok {
+		assigner.SetEntryIDs(ids)
+	}
+
+	imp, err := importer.New(logStorage, logParser, importer.Options{
+		Format:           importer.Format(*format),
+		TimestampMode:    importer.TimestampMode(*timestamps),
+		KeepANSIColors:   *keepANSIColors,
+		StateFile:        *stateFile,
+		ProgressInterval: *progressEvery,
+		Progress: func(p importer.Progress) {
+			log.Printf("%s: %d records (%d imported, %d skipped, %d duplicates, %d failed, %d sanitized) in %v",
+				p.File, p.Records, p.Imported, p.Skipped, p.Duplicates, p.Failed, p.Sanitized, p.Elapsed.Round(time.Millisecond))
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create importer: %v", err)
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range fs.Args() {
+		if _, err := imp.ImportFile(path); err != nil {
+			log.Printf("Import of %s failed: %v", path, err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
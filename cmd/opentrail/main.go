@@ -4,19 +4,39 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
+	// Embed the time zone database so tz query parameters work on hosts without zoneinfo
+	_ "time/tzdata"
 
+	"opentrail/internal/agents"
+	"opentrail/internal/archive"
+	"opentrail/internal/cloudmeta"
+	"opentrail/internal/cluster"
 	"opentrail/internal/config"
+	"opentrail/internal/demo"
+	"opentrail/internal/entryid"
+	"opentrail/internal/fieldcrypt"
 	"opentrail/internal/interfaces"
+	"opentrail/internal/lifecycle"
+	"opentrail/internal/logformat"
+	"opentrail/internal/notify"
 	"opentrail/internal/parser"
+	"opentrail/internal/resilience"
+	"opentrail/internal/sanitize"
 	"opentrail/internal/server"
 	"opentrail/internal/service"
+	"opentrail/internal/siem"
 	"opentrail/internal/storage"
 	"opentrail/internal/types"
+	"opentrail/internal/upgrade"
+	"opentrail/internal/vault"
 	"opentrail/web"
 )
 
@@ -27,6 +47,13 @@ var (
 	GitCommit = "unknown"
 )
 
+const (
+	// UpgradeReadyTimeout is how long to wait for a new process to take over the listeners
+	UpgradeReadyTimeout = 30 * time.Second
+	// UpgradeDrainTimeout is how long to wait for TCP senders to disconnect after an upgrade
+	UpgradeDrainTimeout = 30 * time.Second
+)
+
 // Application represents the main application
 type Application struct {
 	config          *types.Config
@@ -34,8 +61,19 @@ type Application struct {
 	parser          interfaces.LogParser
 	logService      interfaces.LogService
 	tcpServer       *server.TCPServer
+	udpServer       *server.UDPServer
 	httpServer      *server.HTTPServer
 	webSocketServer *server.WebSocketServer
+	upgrader        *upgrade.Upgrader
+	siemForwarder   *siem.Forwarder
+	clusterStream   *cluster.Stream
+	fallbackRule    *notify.FallbackRule
+	lifecycle       *lifecycle.Notifier
+	encryptor       *fieldcrypt.Encryptor
+	vault           *vault.Client
+
+	// startupServer answers probes on the HTTP address until the HTTP server starts
+	startupServer *server.StartupServer
 
 	// Lifecycle management
 	ctx    context.Context
@@ -48,6 +86,22 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.SetPrefix("[OpenTrail] ")
 
+	// Dispatch subcommands before loading server configuration
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "ship":
+			os.Exit(runShip(os.Args[2:]))
+		case "dump":
+			os.Exit(runDump(os.Args[2:]))
+		case "health":
+			os.Exit(runHealth(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		}
+	}
+
 	// Display version information
 	log.Printf("OpenTrail v%s (built %s, commit %s)", Version, BuildTime, GitCommit)
 
@@ -57,26 +111,54 @@ func main() {
 		log.Fatalf("Failed to create application: %v", err)
 	}
 
-	// Set up signal handling for graceful shutdown
+	// Set up signal handling for graceful shutdown and binary upgrades
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if upgrade.Supported {
+		signal.Notify(sigChan, upgrade.Signal)
+	}
 
 	// Start the application
 	if err := app.Start(); err != nil {
 		log.Fatalf("Failed to start application: %v", err)
 	}
 
+	// Let a parent process that handed over its listeners know we have taken over
+	if err := app.upgrader.Ready(); err != nil {
+		log.Printf("Failed to signal upgrade readiness: %v", err)
+	}
+
 	log.Printf("OpenTrail started successfully")
-	log.Printf("TCP server listening on port %d", app.config.TCPPort)
-	log.Printf("Web interface available at http://localhost:%d", app.config.HTTPPort)
-	log.Printf("WebSocket server listening on port %d", app.config.WebSocketPort)
+	log.Printf("TCP server listening on %s", displayAddress(app.config.TCPBindAddress, app.config.TCPPort))
+	if app.udpServer != nil {
+		log.Printf("UDP server listening on %s", displayAddress(app.config.UDPBindAddress, app.config.UDPPort))
+	}
+	if len(app.config.ACMEDomains) > 0 {
+		log.Printf("Web interface available at https://%s", displayAddress(app.config.ACMEDomains[0], app.config.HTTPPort))
+	} else {
+		log.Printf("Web interface available at http://%s", displayAddress(app.config.HTTPBindAddress, app.config.HTTPPort))
+	}
+	log.Printf("WebSocket server listening on %s", displayAddress(app.config.WebSocketBindAddress, app.config.WebSocketPort))
 	if app.config.AuthEnabled {
 		log.Printf("Authentication enabled for web interface")
 	}
 
 	// Wait for shutdown signal
-	<-sigChan
-	log.Printf("Shutdown signal received, stopping application...")
+	for sig := range sigChan {
+		if upgrade.Supported && sig == upgrade.Signal {
+			log.Printf("Upgrade signal received, starting new process...")
+			if err := app.upgrader.Upgrade(UpgradeReadyTimeout); err != nil {
+				log.Printf("Upgrade failed, continuing to serve: %v", err)
+				continue
+			}
+			log.Printf("New process is ready, draining connections...")
+			app.tcpServer.Drain(UpgradeDrainTimeout)
+			break
+		}
+
+		log.Printf("Shutdown signal received, stopping application...")
+		break
+	}
 
 	// Graceful shutdown
 	if err := app.Stop(); err != nil {
@@ -87,6 +169,65 @@ func main() {
 	log.Printf("OpenTrail stopped successfully")
 }
 
+// displayAddress formats a listen address for log output, showing localhost when all interfaces are bound
+func displayAddress(host string, port int) string {
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// runSetup serves the setup wizard until an admin account has been created, then applies the
+// settings chosen in it to cfg
+func runSetup(cfg *types.Config) error {
+	wizard := server.NewSetupServer(cfg, config.NewSetupStore(cfg))
+	if err := wizard.Start(); err != nil {
+		return fmt.Errorf("failed to start setup wizard: %w", err)
+	}
+	log.Printf("No admin account is configured; complete the setup at http://%s%s/ with setup token %s",
+		displayAddress(cfg.HTTPBindAddress, cfg.HTTPPort), cfg.HTTPBasePath, wizard.Token())
+	log.Printf("Start with -no-auth to run without authentication instead")
+
+	settings := <-wizard.Done()
+	wizard.Stop()
+	if err := config.ApplySetup(cfg, settings); err != nil {
+		return fmt.Errorf("failed to apply setup: %w", err)
+	}
+	log.Printf("Setup complete, admin account %q created", settings.AdminUsername)
+	return nil
+}
+
+// nodeName returns the name identifying this node: the configured one, or the host name
+func nodeName(cfg *types.Config) string {
+	if cfg.NodeName != "" {
+		return cfg.NodeName
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("Failed to read the host name to name this node: %v", err)
+	}
+	return hostname
+}
+
+// deploymentStamp returns the deployment metadata to record in every entry: the node receiving it
+// and what the instance metadata service reports, overridden by the configured values. Entries are
+// still received when the metadata service cannot be reached.
+func deploymentStamp(ctx context.Context, cfg *types.Config) map[string]string {
+	stamp := make(map[string]string)
+	if node := nodeName(cfg); node != "" {
+		stamp[types.NodeParam] = node
+	}
+	if cfg.StampCloud != "" {
+		discovered, err := cloudmeta.Discover(ctx, cfg.StampCloud)
+		if err != nil {
+			log.Printf("Not stamping cloud metadata: %v", err)
+		}
+		maps.Copy(stamp, discovered)
+	}
+	maps.Copy(stamp, cfg.Stamp)
+	return stamp
+}
+
 // NewApplication creates a new application instance
 func NewApplication() (*Application, error) {
 	// Load configuration
@@ -95,6 +236,13 @@ func NewApplication() (*Application, error) {
 		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	// Without an admin account, serve the setup wizard rather than run without authentication
+	if config.NeedsSetup(cfg) {
+		if err := runSetup(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create context for application lifecycle
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -104,6 +252,12 @@ func NewApplication() (*Application, error) {
 		cancel: cancel,
 	}
 
+	// Secrets kept in Vault are needed by the components
+	if err := app.connectVault(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to connect to vault: %w", err)
+	}
+
 	// Initialize components
 	if err := app.initializeComponents(); err != nil {
 		cancel()
@@ -115,17 +269,51 @@ func NewApplication() (*Application, error) {
 
 // initializeComponents initializes all application components
 func (app *Application) initializeComponents() error {
+	// Listeners are obtained through the upgrader so they can be handed to a new binary
+	app.upgrader = upgrade.New(app.config.ReusePort)
+
 	// Initialize storage with batching optimization
 	batchConfig := storage.DefaultBatchConfig()
 	// Optimize for production use
 	batchConfig.BatchSize = 100
 	batchConfig.BatchTimeout = 50 * time.Millisecond
 	batchConfig.QueueSize = 10000
+	batchConfig.CheckpointMode = app.config.WALCheckpointMode
+	batchConfig.CheckpointPages = &app.config.WALCheckpointPages
+	batchConfig.TruncateInterval = app.config.WALTruncateInterval
+	batchConfig.Recovery = storage.NewRecoveryTracker()
+
+	// Opening storage after a crash can take minutes; report its progress to probes meanwhile. During
+	// an upgrade the parent process keeps serving them.
+	if !app.upgrader.HasParent() {
+		startupServer := server.NewStartupServer(app.config, batchConfig.Recovery.Status)
+		if err := startupServer.Start(); err != nil {
+			log.Printf("Not answering probes during startup: %v", err)
+		} else {
+			app.startupServer = startupServer
+		}
+	}
 
 	sqliteStorage, err := storage.NewBatchedSQLiteStorage(app.config.DatabasePath, batchConfig)
 	if err != nil {
 		return fmt.Errorf("failed to initialize batched storage: %w", err)
 	}
+	if compressor, ok := sqliteStorage.(interfaces.RawMessageCompressor); ok {
+		compressor.SetRawCompression(app.config.RawMessages == types.RawMessagesCompressed)
+	}
+	if chainer, ok := sqliteStorage.(interfaces.HashChainer); ok {
+		chainer.SetHashChain(app.config.HashChain)
+	}
+	if idempotent, ok := sqliteStorage.(interfaces.IdempotentStore); ok {
+		idempotent.SetIdempotencyWindow(app.config.IdempotencyWindow)
+	}
+	if assigner, ok := sqliteStorage.(interfaces.EntryIDAssigner); ok {
+		ids, err := entryid.New(app.config.EntryIDs)
+		if err != nil {
+			return err
+		}
+		assigner.SetEntryIDs(ids)
+	}
 	app.storage = sqliteStorage
 
 	// Initialize parser
@@ -133,22 +321,208 @@ func (app *Application) initializeComponents() error {
 	if err := logParser.SetFormat(app.config.LogFormat); err != nil {
 		return fmt.Errorf("failed to set log format: %w", err)
 	}
+	if app.config.TimestampRules != "" {
+		rules, err := parser.LoadTimestampRules(app.config.TimestampRules)
+		if err != nil {
+			return err
+		}
+		logParser.(*parser.RFC5424Parser).SetTimestampRules(rules)
+	}
+	if app.config.TransformRules != "" {
+		rules, err := parser.LoadTransformRules(app.config.TransformRules)
+		if err != nil {
+			return err
+		}
+		logParser.(*parser.RFC5424Parser).SetTransformRules(rules)
+	}
 	app.parser = logParser
 
 	// Initialize log service
 	logService := service.NewLogService(logParser, sqliteStorage)
+	logService.SetIntegrityCheckInterval(app.config.IntegrityCheckInterval)
+	logService.SetParseWorkers(app.config.ParseWorkers)
+	logService.SetMemoryLimit(int64(app.config.MemoryLimitMB) << 20)
+	logService.SetStoragePolicy(resilience.Config{
+		Attempts:   app.config.StorageRetryAttempts,
+		Backoff:    app.config.StorageRetryBackoff,
+		MaxBackoff: resilience.DefaultConfig.MaxBackoff,
+		Threshold:  app.config.StorageBreakerThreshold,
+		OpenFor:    app.config.StorageBreakerOpen,
+	})
+	logService.SetDegradeAfter(app.config.DegradeAfter)
+	if app.config.DegradedSpool != "" {
+		if err := logService.SetDegradedSpool(app.config.DegradedSpool, int64(app.config.DegradedSpoolLimitMB)<<20); err != nil {
+			return err
+		}
+	}
+	logService.SetSearchConcurrency(app.config.MaxConcurrentSearches, app.config.SearchQueueTimeout)
+	logService.SetSearchCache(app.config.SearchCacheTTL, app.config.SearchCacheSize)
+	logService.SetTailBuffer(app.config.TailBufferWindow, app.config.TailBufferSize)
+	logService.SetRawRetention(app.config.RawMessages != types.RawMessagesOff)
+	logService.SetKeepANSIColors(app.config.KeepANSIColors)
+	logService.SetStructuredDataLimits(sanitize.Limits{
+		MaxBytes:      app.config.SDMaxBytes,
+		MaxParams:     app.config.SDMaxParams,
+		MaxKeysPerApp: app.config.SDMaxKeysPerApp,
+	})
+	notifier, err := lifecycle.NewNotifier(app.config.LifecycleWebhook)
+	if err != nil {
+		return err
+	}
+	logService.SetRetention(app.config.RetentionDays)
+	logService.SetLifecycleNotifier(notifier)
+	app.lifecycle = notifier
+	if stamp := deploymentStamp(app.ctx, app.config); len(stamp) > 0 {
+		logService.SetStamp(stamp)
+		log.Printf("Stamping entries with %v", stamp)
+	}
+	if len(app.config.EncryptFields) > 0 {
+		keys, err := fieldcrypt.LoadKeyring(app.config.EncryptionKeys, app.readFile)
+		if err != nil {
+			return err
+		}
+		app.encryptor = fieldcrypt.New(app.config.EncryptFields, keys)
+		logService.SetFieldEncryption(app.encryptor)
+		log.Printf("Encrypting fields %v", app.config.EncryptFields)
+	}
 	app.logService = logService
 
+	// A demo serves sample logs and accepts no others
+	if app.config.Demo {
+		stored, err := demo.Seed(logParser, sqliteStorage, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to seed demo data: %w", err)
+		}
+		if stored > 0 {
+			log.Printf("Seeded the database with %d sample entries", stored)
+		}
+		if _, err := logService.SetMode(types.ModeReadOnly, "Demo: sample logs only, ingestion is disabled"); err != nil {
+			return err
+		}
+	}
+
+	// Output formats render entries for exports and forwarding through templates
+	outputFormats, err := logformat.NewRegistry()
+	if err != nil {
+		return err
+	}
+	if app.config.OutputFormats != "" {
+		if outputFormats, err = logformat.LoadFormats(app.config.OutputFormats); err != nil {
+			return err
+		}
+		log.Printf("Loaded output formats: %v", outputFormats.Names())
+	}
+
+	// Initialize forwarding of security-relevant entries to a SIEM
+	siem.ProductVersion = Version
+	if app.config.SIEMForward != "" {
+		var forwarder *siem.Forwarder
+		var err error
+		if format, ok := outputFormats.Format(app.config.SIEMFormat); ok {
+			forwarder, err = siem.NewForwarderWithEncoder(app.config.SIEMForward, format.Encode, app.config.SIEMMinSeverity, app.config.SIEMFacilities)
+		} else {
+			forwarder, err = siem.NewForwarder(app.config.SIEMForward, app.config.SIEMFormat, app.config.SIEMMinSeverity, app.config.SIEMFacilities)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initialize SIEM forwarding: %w", err)
+		}
+		app.siemForwarder = forwarder
+	}
+
 	// Initialize TCP server
 	tcpServer := server.NewTCPServer(app.config, logService)
+	tcpServer.SetListenFunc(app.upgrader.ListenFunc("tcp"))
+	tcpServer.SetFileReader(app.readFile)
 	app.tcpServer = tcpServer
 
+	// Initialize UDP server if enabled
+	if app.config.UDPPort > 0 {
+		udpServer := server.NewUDPServer(app.config, logService)
+		udpServer.SetListenFunc(app.upgrader.PacketListenFunc("udp"))
+		app.udpServer = udpServer
+	}
+
 	// Initialize HTTP server with embedded static files
 	httpServer := server.NewHTTPServerWithStaticFiles(app.config, logService, web.GetStaticFS())
+	httpServer.SetListenFunc(app.upgrader.ListenFunc("http"))
+	httpServer.SetChallengeListenFunc(app.upgrader.ListenFunc("acme-http"))
+	httpServer.SetConnectionAdmin(tcpServer)
+	if app.udpServer != nil {
+		httpServer.SetUDPServer(app.udpServer)
+	}
+	httpServer.SetOutputFormats(outputFormats)
+	httpServer.SetLifecycleNotifier(app.lifecycle)
+	if app.encryptor != nil {
+		httpServer.SetFieldEncryption(app.encryptor)
+	}
+	var channels *notify.Registry
+	if app.config.NotificationChannels != "" {
+		channels, err = notify.LoadChannels(app.config.NotificationChannels)
+		if err != nil {
+			return fmt.Errorf("failed to load notification channels: %w", err)
+		}
+		httpServer.SetNotificationChannels(channels)
+		log.Printf("Loaded notification channels: %v", channels.Names())
+	}
+	if app.config.FallbackAlertPercent > 0 {
+		var fallbackChannels []notify.Channel
+		for _, name := range app.config.FallbackAlertChannels {
+			channel, ok := channels.Channel(name)
+			if !ok {
+				return fmt.Errorf("fallback alert channel %q is not a configured notification channel", name)
+			}
+			fallbackChannels = append(fallbackChannels, channel)
+		}
+		app.fallbackRule = notify.NewFallbackRule(app.config.FallbackAlertPercent, int64(app.config.FallbackAlertMinEntries),
+			app.config.FallbackAlertWindow, fallbackChannels)
+	}
+	var agentConfigs []agents.Config
+	if app.config.AgentConfig != "" {
+		configs, err := agents.LoadConfigs(app.config.AgentConfig)
+		if err != nil {
+			return err
+		}
+		agentConfigs = configs
+	}
+	agentRegistry, err := agents.NewRegistry(agentConfigs...)
+	if err != nil {
+		return fmt.Errorf("invalid agent configuration: %w", err)
+	}
+	httpServer.SetAgentRegistry(agentRegistry)
+	if len(agentConfigs) > 0 {
+		log.Printf("Loaded agent configurations: %v", agentRegistry.Names())
+	}
+	if len(app.config.ClusterPeers) > 0 {
+		peers, err := cluster.ParsePeers(app.config.ClusterPeers)
+		if err != nil {
+			return fmt.Errorf("invalid cluster peers: %w", err)
+		}
+		app.clusterStream = cluster.NewStream(nodeName(app.config), peers, app.config.ClusterMergeWindow)
+		httpServer.SetClusterStream(app.clusterStream)
+	}
+	if len(app.config.Archive) > 0 {
+		locations, err := archive.ParseLocations(app.config.Archive)
+		if err != nil {
+			return err
+		}
+		searcher, err := archive.New(archive.Options{
+			Locations:     locations,
+			Endpoint:      app.config.ArchiveEndpoint,
+			Region:        app.config.ArchiveRegion,
+			MaxPartitions: app.config.ArchiveMaxPartitions,
+			Credentials:   app.archiveCredentials(),
+		})
+		if err != nil {
+			return err
+		}
+		httpServer.SetArchive(searcher)
+		log.Printf("Searching archived dumps %v past retention", locations)
+	}
 	app.httpServer = httpServer
 
 	// Initialize WebSocket server
 	webSocketServer := server.NewWebSocketServer(app.config, logService)
+	webSocketServer.SetListenFunc(app.upgrader.ListenFunc("websocket"))
 	app.webSocketServer = webSocketServer
 
 	return nil
@@ -169,9 +543,24 @@ func (app *Application) Start() error {
 		return fmt.Errorf("failed to start TCP server: %w", err)
 	}
 
+	// Start UDP server
+	if app.udpServer != nil {
+		if err := app.udpServer.Start(); err != nil {
+			app.tcpServer.Stop()
+			app.logService.Stop()
+			return fmt.Errorf("failed to start UDP server: %w", err)
+		}
+	}
+
+	// Hand the HTTP address over from the startup server
+	if app.startupServer != nil {
+		app.startupServer.Stop()
+		app.startupServer = nil
+	}
+
 	// Start HTTP server
 	if err := app.httpServer.Start(); err != nil {
-		app.tcpServer.Stop()
+		app.stopIngestion()
 		app.logService.Stop()
 		return fmt.Errorf("failed to start HTTP server: %w", err)
 	}
@@ -179,14 +568,74 @@ func (app *Application) Start() error {
 	// Start WebSocket server
 	if err := app.webSocketServer.Start(); err != nil {
 		app.httpServer.Stop()
-		app.tcpServer.Stop()
+		app.stopIngestion()
 		app.logService.Stop()
 		return fmt.Errorf("failed to start WebSocket server: %w", err)
 	}
 
+	// Announce lifecycle events to the webhook until shutdown
+	if app.config.LifecycleWebhook != "" {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.lifecycle.Run(app.ctx)
+		}()
+		log.Printf("Announcing lifecycle events to %s", app.config.LifecycleWebhook)
+	}
+
+	// Forward new entries to the SIEM collector until shutdown
+	if app.siemForwarder != nil {
+		subscription := app.logService.Subscribe()
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			defer app.logService.Unsubscribe(subscription)
+			app.siemForwarder.Run(app.ctx, subscription)
+		}()
+		log.Printf("Forwarding %s events to SIEM collector %s", app.config.SIEMFormat, app.config.SIEMForward)
+	}
+
+	// Keep the Vault token valid until shutdown
+	if app.vault != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.vault.Run(app.ctx)
+		}()
+	}
+
+	// Watch for senders whose messages stop parsing until shutdown
+	if app.fallbackRule != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.fallbackRule.Run(app.ctx)
+		}()
+	}
+
+	// Merge the live streams of the cluster peers with that of this node until shutdown
+	if app.clusterStream != nil {
+		subscription := app.logService.Subscribe()
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			defer app.logService.Unsubscribe(subscription)
+			app.clusterStream.Run(app.ctx, subscription)
+		}()
+		log.Printf("Following the live streams of %d cluster peers as node %s", len(app.config.ClusterPeers), app.clusterStream.Status().Node)
+	}
+
 	return nil
 }
 
+// stopIngestion stops the TCP and UDP servers when a later component fails to start
+func (app *Application) stopIngestion() {
+	app.tcpServer.Stop()
+	if app.udpServer != nil {
+		app.udpServer.Stop()
+	}
+}
+
 // Stop gracefully stops all application components
 func (app *Application) Stop() error {
 	log.Printf("Stopping OpenTrail components...")
@@ -222,6 +671,13 @@ func (app *Application) Stop() error {
 		}
 	}
 
+	// Stop UDP server
+	if app.udpServer != nil {
+		if err := app.udpServer.Stop(); err != nil {
+			errors = append(errors, fmt.Errorf("UDP server stop error: %w", err))
+		}
+	}
+
 	// Stop log service
 	if app.logService != nil {
 		if err := app.logService.Stop(); err != nil {
@@ -270,6 +726,10 @@ func (app *Application) GetStats() map[string]interface{} {
 		stats["tcp_server"] = app.tcpServer.GetStats()
 	}
 
+	if app.udpServer != nil {
+		stats["udp_server"] = app.udpServer.GetStats()
+	}
+
 	if app.httpServer != nil {
 		stats["http_server"] = app.httpServer.GetStats()
 	}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"opentrail/internal/storage"
+)
+
+// runMigrate implements the "opentrail migrate" subcommand, which shows the schema version of a
+// database and migrates it up or, for development, down to a given version
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: opentrail migrate [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Migrates the database schema to the latest or the given version. The server migrates on startup,\n")
+		fmt.Fprintf(fs.Output(), "so this is only needed to preview migrations, check the version or revert migrations in development.\n\n")
+		fs.PrintDefaults()
+	}
+
+	defaultDatabase := os.Getenv("OPENTRAIL_DATABASE_PATH")
+	if defaultDatabase == "" {
+		defaultDatabase = "logs.db"
+	}
+
+	databasePath := fs.String("database-path", defaultDatabase, "Path to SQLite database file")
+	target := fs.Int("to", storage.LatestSchemaVersion, "Schema version to migrate to")
+	dryRun := fs.Bool("dry-run", false, "Print the migrations and their SQL without running them")
+	status := fs.Bool("status", false, "List the migrations and when they were applied")
+	down := fs.Bool("down", false, "Allow reverting migrations when -to is below the current version; this drops their tables and data")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	current, applied, err := storage.SchemaStatus(*databasePath)
+	if err != nil {
+		log.Printf("Failed to read schema version: %v", err)
+		return 1
+	}
+	if *status {
+		fmt.Printf("Schema version %d of %d\n", current, storage.LatestSchemaVersion)
+		for _, step := range applied {
+			state := "pending"
+			if step.AppliedAt != nil {
+				state = "applied " + step.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-20s %s\n", step.Version, step.Name, state)
+		}
+		return 0
+	}
+	if *target < current && !*down && !*dryRun {
+		log.Printf("Migrating from version %d down to %d drops data; pass -down to confirm or -dry-run to preview", current, *target)
+		return 2
+	}
+
+	steps, err := storage.MigrateSchema(*databasePath, *target, *dryRun)
+	for _, step := range steps {
+		direction := "up"
+		if step.Down {
+			direction = "down"
+		}
+		if *dryRun {
+			fmt.Printf("-- Migration %d (%s) %s\n", step.Version, step.Name, direction)
+			if step.SQL == "" {
+				fmt.Printf("-- Data migration implemented in Go\n\n")
+				continue
+			}
+			fmt.Println(step.SQL)
+			continue
+		}
+		log.Printf("Migrated %s: %d (%s)", direction, step.Version, step.Name)
+	}
+	if err != nil {
+		log.Printf("Migration failed: %v", err)
+		return 1
+	}
+	if len(steps) == 0 {
+		log.Printf("Database schema is at version %d, nothing to migrate", current)
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"opentrail/internal/archive"
+	"opentrail/internal/vault"
+)
+
+// vaultLoginTimeout bounds logging in to Vault and reading the secrets needed at startup
+const vaultLoginTimeout = 30 * time.Second
+
+// connectVault logs in to Vault if configured and replaces the passwords referring to Vault
+// secrets with the secrets themselves. Files and archive credentials are read when they are used.
+func (app *Application) connectVault() error {
+	if app.config.VaultAddress == "" {
+		return nil
+	}
+	client, err := vault.New(vault.Options{
+		Address:  app.config.VaultAddress,
+		Auth:     app.config.VaultAuth,
+		Token:    app.config.VaultToken,
+		RoleID:   app.config.VaultRoleID,
+		SecretID: app.config.VaultSecretID,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(app.ctx, vaultLoginTimeout)
+	defer cancel()
+	if err := client.Login(ctx); err != nil {
+		return err
+	}
+	for _, password := range []*string{&app.config.AuthPassword, &app.config.ReaderPassword} {
+		if *password, err = client.Resolve(ctx, *password); err != nil {
+			return err
+		}
+	}
+	app.vault = client
+	log.Printf("Reading secrets from Vault at %s", app.config.VaultAddress)
+	return nil
+}
+
+// readFile reads the certificate, key and tenant files named in the configuration, through Vault
+// when it is configured
+func (app *Application) readFile(name string) ([]byte, error) {
+	if app.vault != nil {
+		return app.vault.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// archiveCredentials returns the provider of the credentials signing archive requests, or nil to
+// use the AWS environment variables. Secrets in Vault are read again as they are rotated.
+func (app *Application) archiveCredentials() func(context.Context) (archive.Credentials, error) {
+	if app.config.ArchiveAccessKey == "" {
+		return nil
+	}
+	return func(ctx context.Context) (archive.Credentials, error) {
+		credentials := archive.Credentials{AccessKey: app.config.ArchiveAccessKey, SecretKey: app.config.ArchiveSecretKey}
+		if app.vault == nil {
+			return credentials, nil
+		}
+		var err error
+		if credentials.AccessKey, err = app.vault.Resolve(ctx, credentials.AccessKey); err != nil {
+			return archive.Credentials{}, fmt.Errorf("failed to read archive access key: %w", err)
+		}
+		if credentials.SecretKey, err = app.vault.Resolve(ctx, credentials.SecretKey); err != nil {
+			return archive.Credentials{}, fmt.Errorf("failed to read archive secret key: %w", err)
+		}
+		return credentials, nil
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"opentrail/internal/agents"
+	"opentrail/internal/shipper"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runShip implements the "opentrail ship" subcommand, which forwards log lines from files or
+// standard input to a TCP ingestion listener, reconnecting when the server drains or restarts
+func runShip(args []string) int {
+	fs := flag.NewFlagSet("ship", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: opentrail ship [flags] [FILE...]\n\n")
+		fmt.Fprintf(fs.Output(), "Forwards one log line per input line to an OpenTrail TCP listener, reading standard input when no file is given.\n")
+		fmt.Fprintf(fs.Output(), "Lines are buffered while the server is unreachable and none are lost when it drains connections.\n\n")
+		fs.PrintDefaults()
+	}
+
+	server := fs.String("server", "localhost:2253", "Address (host:port) of the TCP ingestion listener")
+	queueSize := fs.Int("queue-size", shipper.DefaultQueueSize, "Number of lines buffered while the server is unreachable")
+	flushTimeout := fs.Duration("flush-timeout", time.Minute, "How long to keep forwarding buffered lines once the input ends")
+	numberLines := fs.Bool("sequence", false, "Number RFC5424 lines with a meta sequenceId so the server can detect lost lines")
+	keyLines := fs.Bool("idempotency-keys", false, "Give RFC5424 lines an idempotency key so the server does not store lines sent again twice")
+	spoolDir := fs.String("spool", "", "Directory keeping lines on disk until the server acknowledges storing them, instead of a memory buffer")
+	spoolLimitMB := fs.Int("spool-limit-mb", 1024, "Disk space in MiB the spool may use before the oldest lines are dropped (0 for no limit)")
+	metricsAddr := fs.String("metrics-addr", "", "Address (host:port) to serve Prometheus metrics of the shipper on")
+	manager := fs.String("manager", "", "URL of an OpenTrail server whose agent configuration decides the files to ship, their parsers and redaction")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *spoolLimitMB < 0 {
+		fmt.Fprintf(fs.Output(), "-spool-limit-mb cannot be negative\n")
+		return 2
+	}
+
+	var client *shipper.Client
+	if *spoolDir != "" {
+		var err error
+		client, err = shipper.NewSpoolingClient(*server, *spoolDir, int64(*spoolLimitMB)<<20)
+		if err != nil {
+			log.Printf("Failed to open spool: %v", err)
+			return 1
+		}
+		if stats, _ := client.SpoolStats(); stats.Pending > 0 {
+			log.Printf("Resuming with %d unacknowledged line(s) from the spool", stats.Pending)
+		}
+	} else {
+		client = shipper.NewClient(*server, *queueSize)
+	}
+	if *metricsAddr != "" {
+		serveShipMetrics(*metricsAddr, client)
+	}
+	if *numberLines {
+		client.NumberLines()
+	}
+	if *keyLines {
+		client.KeyLines()
+	}
+	exitCode := 0
+
+	// A managed agent ships the files of its configuration unless files are given
+	files := make([]agents.FileConfig, 0, len(fs.Args()))
+	for _, path := range fs.Args() {
+		files = append(files, agents.FileConfig{Path: path})
+	}
+	var agent *managedAgent
+	if *manager != "" {
+		var err error
+		agent, err = startAgent(ctx, *manager)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		log.Printf("Registered with %s as agent %s", *manager, agent.registration.ID)
+		if len(files) == 0 {
+			files = agent.config.Config.Files
+			if len(files) == 0 {
+				log.Printf("The agent configuration lists no files to ship")
+			}
+		}
+	} else if len(files) == 0 {
+		files = []agents.FileConfig{{Path: "-"}}
+	}
+
+	heartbeatCtx, stopHeartbeats := context.WithCancel(ctx)
+	defer stopHeartbeats()
+	var heartbeats sync.WaitGroup
+	var pipelines []*agents.Pipeline
+	if agent != nil {
+		for _, file := range files {
+			pipeline, err := agent.pipeline(file)
+			if err != nil {
+				log.Printf("Invalid agent configuration for %s: %v", file.Path, err)
+				return 1
+			}
+			pipelines = append(pipelines, pipeline)
+		}
+		heartbeats.Add(1)
+		go func() {
+			defer heartbeats.Done()
+			agent.run(heartbeatCtx)
+		}()
+	}
+
+	for i, file := range files {
+		input := shipTarget{path: file.Path}
+		if agent != nil {
+			input.pipeline = pipelines[i]
+			input.position = agent.track(file.Path)
+			input.advance = agent.advance
+		}
+		if err := shipInput(ctx, client, input); err != nil {
+			log.Printf("Shipping %s failed: %v", file.Path, err)
+			exitCode = 1
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), *flushTimeout)
+	defer cancel()
+	if err := client.Close(closeCtx); err != nil {
+		log.Printf("%v", err)
+		exitCode = 1
+	}
+	log.Printf("Shipped %d lines to %s", client.Sent(), *server)
+	if agent != nil {
+		stopHeartbeats()
+		heartbeats.Wait()
+		// A last heartbeat reports how far the files were shipped
+		heartbeatCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := agent.heartbeat(heartbeatCtx); err != nil {
+			log.Printf("Agent heartbeat failed: %v", err)
+		}
+	}
+	if stats, ok := client.SpoolStats(); ok && stats.Pending > 0 {
+		log.Printf("%d unacknowledged line(s) remain in the spool and will be sent next time", stats.Pending)
+	}
+
+	return exitCode
+}
+
+// serveShipMetrics serves Prometheus metrics of the lines shipped and the spool in the background
+func serveShipMetrics(addr string, client *shipper.Client) {
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "opentrail_ship_sent_lines_total",
+		Help: "Total number of lines written to the server, including lines sent again",
+	}, func() float64 { return float64(client.Sent()) }))
+
+	if _, ok := client.SpoolStats(); ok {
+		spoolMetric := func(name, help string, value func(shipper.SpoolStats) float64) prometheus.Collector {
+			return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+				stats, _ := client.SpoolStats()
+				return value(stats)
+			})
+		}
+		prometheus.MustRegister(
+			spoolMetric("opentrail_ship_spool_pending_lines", "Lines in the spool not acknowledged by the server",
+				func(stats shipper.SpoolStats) float64 { return float64(stats.Pending) }),
+			spoolMetric("opentrail_ship_spool_bytes", "Size of the spool's segment files",
+				func(stats shipper.SpoolStats) float64 { return float64(stats.Bytes) }),
+			spoolMetric("opentrail_ship_spool_segments", "Number of segment files in the spool",
+				func(stats shipper.SpoolStats) float64 { return float64(stats.Segments) }),
+			spoolMetric("opentrail_ship_spool_acked_lines", "Lines acknowledged by the server since the spool was created",
+				func(stats shipper.SpoolStats) float64 { return float64(stats.Acked) }),
+		)
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "opentrail_ship_spool_dropped_lines_total",
+			Help: "Total number of unacknowledged lines dropped to keep the spool within its limit",
+		}, func() float64 {
+			stats, _ := client.SpoolStats()
+			return float64(stats.Dropped)
+		}))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+}
+
+// shipTarget is an input of "opentrail ship" with, for a managed agent, the processing of its
+// lines and the position reported for it
+type shipTarget struct {
+	path     string
+	pipeline *agents.Pipeline
+	position *agents.TailPosition
+	advance  func(position *agents.TailPosition, n int)
+}
+
+// shipInput forwards every line of a file, or of standard input when path is "-"
+func shipInput(ctx context.Context, client *shipper.Client, target shipTarget) error {
+	var input io.Reader = os.Stdin
+	if target.path != "-" {
+		file, err := os.Open(target.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader := bufio.NewReader(input)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			read := len(line)
+			if target.pipeline != nil {
+				line = target.pipeline.Apply(line, time.Now())
+			}
+			if sendErr := client.Send(ctx, line); sendErr != nil {
+				return sendErr
+			}
+			if target.advance != nil {
+				target.advance(target.position, read)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
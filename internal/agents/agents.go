@@ -0,0 +1,391 @@
+// Package agents keeps track of the shippers running as agents on other hosts: the configuration
+// centrally managed for them, and the registrations and heartbeats reporting how far they got
+package agents
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"opentrail/internal/parser"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often agents are asked to send a heartbeat
+	DefaultHeartbeatInterval = 30 * time.Second
+	// missedHeartbeats is how many heartbeats an agent may miss before it is listed as disconnected
+	missedHeartbeats = 3
+	// maxAgents bounds the agents remembered; the one seen least recently is forgotten
+	maxAgents = 10000
+	// maxPositions bounds the tail positions kept per agent
+	maxPositions = 1000
+)
+
+// ErrUnknownAgent is returned for an agent ID that is not registered, e.g. after a server
+// restart; the agent registers again
+var ErrUnknownAgent = errors.New("unknown agent")
+
+// UnknownAgentCode is the error code of the server's responses to heartbeats of unknown agents
+const UnknownAgentCode = "unknown_agent"
+
+// Parsers an agent applies to the lines of a file
+const (
+	// ParserSyslog ships lines as they are, for files already holding syslog messages
+	ParserSyslog = "syslog"
+	// ParserRaw wraps every line in an RFC5424 message with the agent's hostname and the file's
+	// app name
+	ParserRaw = "raw"
+)
+
+// Config is the configuration centrally managed for the agents whose hostname matches it
+type Config struct {
+	Name string `json:"name"`
+	// Hostnames are path.Match patterns of the agent hostnames the configuration applies to; an
+	// empty list applies it to every agent not matched by an earlier configuration
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Files are the files the agents tail
+	Files []FileConfig `json:"files"`
+	// Redact are applied by the agents to every line before it is shipped
+	Redact []RedactRule `json:"redact,omitempty"`
+	// Severities infer the severity of the lines ParserRaw wraps from their content, replacing
+	// parser.DefaultSeverityRules
+	Severities []parser.SeverityRule `json:"severities,omitempty"`
+}
+
+// FileConfig is a file an agent tails
+type FileConfig struct {
+	Path string `json:"path"`
+	// Parser is ParserSyslog (the default) or ParserRaw
+	Parser string `json:"parser,omitempty"`
+	// AppName is the app name of the messages ParserRaw wraps lines in, the file's base name by default
+	AppName string `json:"app_name,omitempty"`
+}
+
+// RedactRule replaces the matches of a regular expression before a line leaves the agent's host
+type RedactRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Registration is sent by an agent when it starts
+type Registration struct {
+	// ID is the agent's previous ID, so it keeps it when registering again; empty assigns a new one
+	ID       string `json:"id,omitempty"`
+	Hostname string `json:"hostname"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Registered is the server's answer to a registration
+type Registered struct {
+	ID         string `json:"id"`
+	ConfigHash string `json:"config_hash"`
+	// HeartbeatInterval is how often, in seconds, the agent should send a heartbeat
+	HeartbeatInterval int `json:"heartbeat_interval"`
+}
+
+// Heartbeat is sent periodically by a registered agent
+type Heartbeat struct {
+	ID string `json:"id"`
+	// ConfigHash is the hash of the configuration the agent applies
+	ConfigHash string         `json:"config_hash"`
+	Positions  []TailPosition `json:"positions,omitempty"`
+}
+
+// TailPosition is how far an agent has shipped a file
+type TailPosition struct {
+	Path string `json:"path"`
+	// Offset is the number of bytes of the file shipped
+	Offset int64 `json:"offset"`
+	// Size is the size of the file when it was last looked at
+	Size int64 `json:"size"`
+}
+
+// HeartbeatResponse tells an agent which configuration it should apply
+type HeartbeatResponse struct {
+	ConfigHash string `json:"config_hash"`
+	// ConfigChanged is set when the agent applies another configuration and should fetch it
+	ConfigChanged bool `json:"config_changed"`
+}
+
+// ManagedConfig is the configuration served to an agent with its hash
+type ManagedConfig struct {
+	Hash   string `json:"hash"`
+	Config Config `json:"config"`
+}
+
+// Status describes a registered agent
+type Status struct {
+	ID           string    `json:"id"`
+	Hostname     string    `json:"hostname"`
+	Version      string    `json:"version,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	// Connected is set while the agent sends its heartbeats on time
+	Connected bool `json:"connected"`
+	// Config is the name of the configuration assigned to the agent, empty if none applies
+	Config string `json:"config,omitempty"`
+	// ConfigHash is the hash of the configuration the agent last reported applying
+	ConfigHash string `json:"config_hash,omitempty"`
+	// ConfigCurrent is set when the agent applies the configuration assigned to it
+	ConfigCurrent bool           `json:"config_current"`
+	Positions     []TailPosition `json:"positions"`
+	// LagBytes is the number of bytes of the tailed files not shipped yet
+	LagBytes int64 `json:"lag_bytes"`
+}
+
+// LoadConfigs reads a JSON array of agent configurations
+func LoadConfigs(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent configuration: %w", err)
+	}
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse agent configuration %s: %w", path, err)
+	}
+	return configs, nil
+}
+
+// Validate checks the hostname patterns, parsers, redaction patterns and severity rules of a configuration
+func (c Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("agent configurations need a name")
+	}
+	for _, pattern := range c.Hostnames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("agent configuration %s has an invalid hostname pattern %q", c.Name, pattern)
+		}
+	}
+	for _, file := range c.Files {
+		if file.Path == "" {
+			return fmt.Errorf("agent configuration %s has a file without a path", c.Name)
+		}
+		if file.Parser != "" && file.Parser != ParserSyslog && file.Parser != ParserRaw {
+			return fmt.Errorf("agent configuration %s has an unknown parser %q for %s", c.Name, file.Parser, file.Path)
+		}
+	}
+	for _, rule := range c.Redact {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("agent configuration %s has an invalid redaction pattern: %w", c.Name, err)
+		}
+	}
+	if _, err := parser.NewSeverityTable(c.Severities); err != nil {
+		return fmt.Errorf("agent configuration %s has an invalid severity rule: %w", c.Name, err)
+	}
+	return nil
+}
+
+// Hash identifies the content of a configuration, so agents can tell when theirs changed
+func (c Config) Hash() string {
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// matches reports whether the configuration applies to an agent hostname
+func (c Config) matches(hostname string) bool {
+	if len(c.Hostnames) == 0 {
+		return true
+	}
+	for _, pattern := range c.Hostnames {
+		if ok, _ := path.Match(pattern, hostname); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds the managed configurations and the registered agents. Registrations are kept in
+// memory; agents register again when the server restarts.
+type Registry struct {
+	configs  []Config
+	hashes   []string
+	interval time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	agents map[string]*agent
+}
+
+// agent is the state of a registered agent
+type agent struct {
+	hostname     string
+	version      string
+	registeredAt time.Time
+	lastSeen     time.Time
+	configHash   string
+	positions    []TailPosition
+}
+
+// NewRegistry creates a registry serving the configurations, rejecting invalid ones and
+// duplicate names
+func NewRegistry(configs ...Config) (*Registry, error) {
+	registry := &Registry{
+		interval: DefaultHeartbeatInterval,
+		now:      time.Now,
+		agents:   make(map[string]*agent),
+	}
+	names := make(map[string]bool)
+	for _, config := range configs {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate agent configuration %s", config.Name)
+		}
+		names[config.Name] = true
+		registry.configs = append(registry.configs, config)
+		registry.hashes = append(registry.hashes, config.Hash())
+	}
+	return registry, nil
+}
+
+// Names lists the configuration names in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.configs))
+	for _, config := range r.configs {
+		names = append(names, config.Name)
+	}
+	return names
+}
+
+// assigned returns the first configuration matching a hostname and its hash, or an empty
+// configuration if none does
+func (r *Registry) assigned(hostname string) (Config, string) {
+	for i, config := range r.configs {
+		if config.matches(hostname) {
+			return config, r.hashes[i]
+		}
+	}
+	empty := Config{}
+	return empty, empty.Hash()
+}
+
+// Register records an agent, keeping the ID it asked for if it had one
+func (r *Registry) Register(registration Registration) (Registered, error) {
+	if registration.Hostname == "" {
+		return Registered{}, fmt.Errorf("hostname is required")
+	}
+	if len(registration.Hostname) > 255 || len(registration.ID) > 64 {
+		return Registered{}, fmt.Errorf("hostname or ID is too long")
+	}
+	id := registration.ID
+	if id == "" {
+		var random [8]byte
+		rand.Read(random[:])
+		id = hex.EncodeToString(random[:])
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.agents[id]
+	if !ok {
+		r.makeRoom()
+		existing = &agent{registeredAt: now}
+		r.agents[id] = existing
+	}
+	existing.hostname = registration.Hostname
+	existing.version = registration.Version
+	existing.lastSeen = now
+
+	_, hash := r.assigned(registration.Hostname)
+	return Registered{ID: id, ConfigHash: hash, HeartbeatInterval: int(r.interval / time.Second)}, nil
+}
+
+// makeRoom forgets the agent seen least recently once the registry is full
+func (r *Registry) makeRoom() {
+	if len(r.agents) < maxAgents {
+		return
+	}
+	var oldest string
+	var oldestSeen time.Time
+	for id, agent := range r.agents {
+		if oldestSeen.IsZero() || agent.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = id, agent.lastSeen
+		}
+	}
+	delete(r.agents, oldest)
+}
+
+// Heartbeat records the configuration and tail positions an agent reports
+func (r *Registry) Heartbeat(heartbeat Heartbeat) (HeartbeatResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	agent, ok := r.agents[heartbeat.ID]
+	if !ok {
+		return HeartbeatResponse{}, ErrUnknownAgent
+	}
+	agent.lastSeen = r.now()
+	agent.configHash = heartbeat.ConfigHash
+	positions := heartbeat.Positions
+	if len(positions) > maxPositions {
+		positions = positions[:maxPositions]
+	}
+	agent.positions = append([]TailPosition{}, positions...)
+
+	_, hash := r.assigned(agent.hostname)
+	return HeartbeatResponse{ConfigHash: hash, ConfigChanged: hash != heartbeat.ConfigHash}, nil
+}
+
+// Config returns the configuration assigned to an agent
+func (r *Registry) Config(id string) (ManagedConfig, error) {
+	r.mu.Lock()
+	agent, ok := r.agents[id]
+	var hostname string
+	if ok {
+		hostname = agent.hostname
+	}
+	r.mu.Unlock()
+	if !ok {
+		return ManagedConfig{}, ErrUnknownAgent
+	}
+	config, hash := r.assigned(hostname)
+	return ManagedConfig{Hash: hash, Config: config}, nil
+}
+
+// Agents lists the registered agents by hostname
+func (r *Registry) Agents() []Status {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]Status, 0, len(r.agents))
+	for id, agent := range r.agents {
+		config, hash := r.assigned(agent.hostname)
+		status := Status{
+			ID:            id,
+			Hostname:      agent.hostname,
+			Version:       agent.version,
+			RegisteredAt:  agent.registeredAt,
+			LastSeen:      agent.lastSeen,
+			Connected:     now.Sub(agent.lastSeen) <= missedHeartbeats*r.interval,
+			Config:        config.Name,
+			ConfigHash:    agent.configHash,
+			ConfigCurrent: agent.configHash == hash,
+			Positions:     append([]TailPosition{}, agent.positions...),
+		}
+		for _, position := range agent.positions {
+			if position.Size > position.Offset {
+				status.LagBytes += position.Size - position.Offset
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Hostname != statuses[j].Hostname {
+			return statuses[i].Hostname < statuses[j].Hostname
+		}
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
@@ -0,0 +1,164 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/parser"
+)
+
+func testRegistry(t *testing.T, configs ...Config) (*Registry, *time.Time) {
+	t.Helper()
+	registry, err := NewRegistry(configs...)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+	return registry, &now
+}
+
+func TestRegistry_AssignsConfigByHostname(t *testing.T) {
+	web := Config{Name: "web", Hostnames: []string{"web-*"}, Files: []FileConfig{{Path: "/var/log/nginx/access.log", Parser: ParserRaw}}}
+	fallback := Config{Name: "default", Files: []FileConfig{{Path: "/var/log/syslog"}}}
+	registry, _ := testRegistry(t, web, fallback)
+
+	registered, err := registry.Register(Registration{Hostname: "web-01", Version: "1.2.0"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registered.ID == "" || registered.ConfigHash != web.Hash() || registered.HeartbeatInterval != 30 {
+		t.Errorf("Unexpected registration: %+v", registered)
+	}
+	config, err := registry.Config(registered.ID)
+	if err != nil || config.Config.Name != "web" || config.Hash != web.Hash() {
+		t.Errorf("Expected the web configuration, got %+v, %v", config, err)
+	}
+
+	other, _ := registry.Register(Registration{Hostname: "db-01"})
+	if config, _ := registry.Config(other.ID); config.Config.Name != "default" {
+		t.Errorf("Expected the default configuration, got %q", config.Config.Name)
+	}
+
+	again, _ := registry.Register(Registration{ID: registered.ID, Hostname: "web-01"})
+	if again.ID != registered.ID || len(registry.Agents()) != 2 {
+		t.Errorf("Expected registering again to keep the agent, got %+v and %d agents", again, len(registry.Agents()))
+	}
+
+	if _, err := registry.Register(Registration{}); err == nil {
+		t.Error("Expected a registration without hostname to be rejected")
+	}
+	if _, err := registry.Config("unknown"); err != ErrUnknownAgent {
+		t.Errorf("Expected ErrUnknownAgent, got %v", err)
+	}
+}
+
+func TestRegistry_Heartbeat(t *testing.T) {
+	config := Config{Name: "web", Files: []FileConfig{{Path: "/var/log/app.log"}}}
+	registry, now := testRegistry(t, config)
+	registered, _ := registry.Register(Registration{Hostname: "web-01"})
+
+	response, err := registry.Heartbeat(Heartbeat{ID: registered.ID, ConfigHash: "outdated", Positions: []TailPosition{
+		{Path: "/var/log/app.log", Offset: 600, Size: 1000},
+		{Path: "/var/log/other.log", Offset: 50, Size: 50},
+	}})
+	if err != nil || !response.ConfigChanged || response.ConfigHash != config.Hash() {
+		t.Errorf("Expected an outdated configuration to be reported, got %+v, %v", response, err)
+	}
+
+	statuses := registry.Agents()
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 agent, got %d", len(statuses))
+	}
+	status := statuses[0]
+	if !status.Connected || status.ConfigCurrent || status.LagBytes != 400 || len(status.Positions) != 2 || status.Config != "web" {
+		t.Errorf("Unexpected agent status: %+v", status)
+	}
+
+	if response, _ := registry.Heartbeat(Heartbeat{ID: registered.ID, ConfigHash: config.Hash()}); response.ConfigChanged {
+		t.Error("Expected the current configuration not to be reported as changed")
+	}
+	*now = now.Add(missedHeartbeats*DefaultHeartbeatInterval + time.Second)
+	if status := registry.Agents()[0]; status.Connected || !status.ConfigCurrent {
+		t.Errorf("Expected an agent missing its heartbeats to be disconnected, got %+v", status)
+	}
+
+	if _, err := registry.Heartbeat(Heartbeat{ID: "unknown"}); err != ErrUnknownAgent {
+		t.Errorf("Expected ErrUnknownAgent, got %v", err)
+	}
+}
+
+func TestNewRegistry_RejectsInvalidConfigs(t *testing.T) {
+	invalid := map[string]Config{
+		"no name":        {Files: []FileConfig{{Path: "/var/log/app.log"}}},
+		"hostname":       {Name: "a", Hostnames: []string{"web-["}},
+		"file path":      {Name: "a", Files: []FileConfig{{Parser: ParserRaw}}},
+		"parser":         {Name: "a", Files: []FileConfig{{Path: "/var/log/app.log", Parser: "json"}}},
+		"redact pattern": {Name: "a", Redact: []RedactRule{{Pattern: "("}}},
+		"severity":       {Name: "a", Severities: []parser.SeverityRule{{Keyword: "oops", Severity: 8}}},
+	}
+	for name, config := range invalid {
+		if _, err := NewRegistry(config); err == nil {
+			t.Errorf("Expected an invalid %s to be rejected", name)
+		}
+	}
+	if _, err := NewRegistry(Config{Name: "a"}, Config{Name: "a"}); err == nil {
+		t.Error("Expected duplicate names to be rejected")
+	}
+}
+
+func TestLoadConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+	os.WriteFile(path, []byte(`[{"name": "web", "hostnames": ["web-*"], "files": [{"path": "/var/log/app.log", "parser": "raw", "app_name": "app"}],
+		"redact": [{"pattern": "password=\\S+", "replacement": "password=***"}]}]`), 0644)
+
+	configs, err := LoadConfigs(path)
+	if err != nil {
+		t.Fatalf("LoadConfigs failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Files[0].AppName != "app" || configs[0].Redact[0].Replacement != "password=***" {
+		t.Errorf("Unexpected configurations: %+v", configs)
+	}
+
+	os.WriteFile(path, []byte(`{`), 0644)
+	if _, err := LoadConfigs(path); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	config := Config{Name: "web", Redact: []RedactRule{{Pattern: `token=\w+`}, {Pattern: `\d{4}-\d{4}`, Replacement: "####"}}}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	pipeline, err := NewPipeline(config, FileConfig{Path: "/var/log/my app.log", Parser: ParserRaw}, "web-01")
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	got := pipeline.Apply("login token=abc card 1234-5678\n", now)
+	want := "<13>1 2026-03-01T12:00:00Z web-01 my_app.log - - - login [REDACTED] card ####\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// The severity of raw lines is inferred from their content
+	if got := pipeline.Apply("upstream ERROR: connection reset", now); !strings.HasPrefix(got, "<11>1 ") {
+		t.Errorf("Expected user.err priority, got %q", got)
+	}
+	config.Severities = []parser.SeverityRule{{Keyword: "oomkilled", Severity: 2}}
+	pipeline, _ = NewPipeline(config, FileConfig{Path: "/var/log/pods.log", Parser: ParserRaw}, "web-01")
+	if got := pipeline.Apply("container OOMKilled", now); !strings.HasPrefix(got, "<10>1 ") {
+		t.Errorf("Expected configured rule to apply, got %q", got)
+	}
+	if got := pipeline.Apply("request error", now); !strings.HasPrefix(got, "<13>1 ") {
+		t.Errorf("Expected configured rules to replace the defaults, got %q", got)
+	}
+
+	pipeline, _ = NewPipeline(config, FileConfig{Path: "/var/log/syslog"}, "web-01")
+	line := "<34>1 2026-03-01T12:00:00Z web-01 app - - - token=abc"
+	if got := pipeline.Apply(line, now); !strings.HasSuffix(got, "- - - [REDACTED]") || !strings.HasPrefix(got, "<34>1") {
+		t.Errorf("Expected a syslog line to be redacted only, got %q", got)
+	}
+}
@@ -0,0 +1,319 @@
+// Package archive searches dumps of the database stored in S3, so that searches can reach back past
+// retention. Archives are complete NDJSON dumps written by "opentrail dump" and copied to a bucket,
+// such as one per month. A search downloads the partitions of the dumps covering its time range,
+// loads their entries into a temporary database and runs the query there; nothing is kept between
+// searches, so every search of the archive takes as long as downloading and loading its partitions.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/dump"
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+	"opentrail/internal/storage"
+	"opentrail/internal/types"
+)
+
+const (
+	// DefaultMaxPartitions is the default number of partitions a search may download
+	DefaultMaxPartitions = 7
+	// manifestTTL is how long manifests are reused before being fetched again, so that new dumps
+	// are found
+	manifestTTL = 5 * time.Minute
+	// maxLineBytes bounds an NDJSON line of a dump
+	maxLineBytes = 16 << 20
+	// loadBatch is how many entries are inserted into the staging database at once
+	loadBatch = 10000
+)
+
+// Location is a dump in a bucket: the prefix its manifest and partitions are stored under
+type Location struct {
+	Bucket string
+	Prefix string
+}
+
+// String returns the location as an s3:// URL
+func (l Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Prefix
+}
+
+// key returns the object key of a file of the dump
+func (l Location) key(name string) string {
+	if l.Prefix == "" {
+		return name
+	}
+	return l.Prefix + "/" + name
+}
+
+// ParseLocations parses s3://bucket/prefix URLs of dumps
+func ParseLocations(urls []string) ([]Location, error) {
+	locations := make([]Location, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "s3" || u.Host == "" || u.User != nil || u.RawQuery != "" {
+			return nil, fmt.Errorf("archive %q must be an s3://bucket/prefix URL", raw)
+		}
+		locations = append(locations, Location{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/")})
+	}
+	return locations, nil
+}
+
+// Options configure an archive
+type Options struct {
+	// Locations are the dumps searched
+	Locations []Location
+	// Endpoint is the URL of an S3-compatible store, which addresses buckets by path; empty uses AWS
+	Endpoint string
+	// Region is the region of the buckets and of request signatures
+	Region string
+	// MaxPartitions is the most partitions a search downloads, DefaultMaxPartitions unless set
+	MaxPartitions int
+	// Credentials returns the credentials of each request, which may change over time; nil uses
+	// the standard AWS environment variables
+	Credentials func(context.Context) (Credentials, error)
+}
+
+// Archive searches the dumps at a set of locations
+type Archive struct {
+	locations     []Location
+	s3            *s3Client
+	maxPartitions int
+
+	mu        sync.Mutex
+	manifests map[Location]*dump.Manifest
+	fetchedAt time.Time
+}
+
+// New creates an archive reading objects with the configured credentials, else those of the standard
+// AWS environment variables, or anonymously without either
+func New(opts Options) (*Archive, error) {
+	client := &s3Client{
+		region:      opts.Region,
+		credentials: opts.Credentials,
+		client:      &http.Client{Timeout: 10 * time.Minute},
+	}
+	if client.credentials == nil {
+		client.credentials = credentialsFromEnv
+	}
+	if client.region == "" {
+		client.region = "us-east-1"
+	}
+	if opts.Endpoint != "" {
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("archive endpoint %q must be an http or https URL", opts.Endpoint)
+		}
+		client.endpoint = endpoint
+	}
+	maxPartitions := opts.MaxPartitions
+	if maxPartitions <= 0 {
+		maxPartitions = DefaultMaxPartitions
+	}
+	return &Archive{locations: opts.Locations, s3: client, maxPartitions: maxPartitions}, nil
+}
+
+// partition is a file of a dump
+type partition struct {
+	location Location
+	file     dump.FileInfo
+}
+
+// Search runs a query against the archived entries in its time range, which must have a start.
+// Entries keep the ID they were dumped with and are marked as archived.
+func (a *Archive) Search(ctx context.Context, query types.SearchQuery) (*types.ArchiveResult, error) {
+	if query.StartTime == nil {
+		return nil, errors.New("searches of the archive need a start time")
+	}
+	started := time.Now()
+	partitions, err := a.partitions(ctx, *query.StartTime, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if len(partitions) > a.maxPartitions {
+		return nil, fmt.Errorf("%w: %d partitions cover it and at most %d are searched, narrow the time range",
+			interfaces.ErrArchiveRangeTooLarge, len(partitions), a.maxPartitions)
+	}
+	result := &types.ArchiveResult{Partitions: len(partitions)}
+	if len(partitions) == 0 {
+		return result, nil
+	}
+
+	dir, err := os.MkdirTemp("", "opentrail-archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the archive search database: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	staging, err := storage.NewSQLiteStorage(filepath.Join(dir, "archive.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the archive search database: %w", err)
+	}
+	defer staging.Close()
+	loader, ok := staging.(interfaces.BulkLoader)
+	if !ok {
+		return nil, errors.New("the archive search database cannot load entries")
+	}
+
+	// Row IDs of the staging database map back to the IDs the entries were dumped with
+	ids := make(map[int64]int64)
+	for _, p := range partitions {
+		if err := a.load(ctx, p, query, loader, ids, result); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := staging.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search archived entries: %w", err)
+	}
+	for _, entry := range entries {
+		entry.ID = ids[entry.ID]
+		entry.Archived = true
+	}
+	result.Entries = entries
+	result.Elapsed = time.Since(started)
+
+	archiveMetrics := metrics.GetArchiveMetrics()
+	archiveMetrics.Searches.Inc()
+	archiveMetrics.Partitions.Add(float64(result.Partitions))
+	archiveMetrics.Bytes.Add(float64(result.Bytes))
+	archiveMetrics.Duration.Observe(result.Elapsed.Seconds())
+	return result, nil
+}
+
+// partitions returns the partitions of all dumps with entries in a time range
+func (a *Archive) partitions(ctx context.Context, start time.Time, end *time.Time) ([]partition, error) {
+	manifests, err := a.loadManifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var partitions []partition
+	for _, location := range a.locations {
+		for _, file := range manifests[location].Files {
+			if file.Last.Before(start) || (end != nil && file.First.After(*end)) {
+				continue
+			}
+			partitions = append(partitions, partition{location: location, file: file})
+		}
+	}
+	return partitions, nil
+}
+
+// loadManifests returns the manifests of the dumps, fetching them again once they are old
+func (a *Archive) loadManifests(ctx context.Context) (map[Location]*dump.Manifest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.manifests != nil && time.Since(a.fetchedAt) < manifestTTL {
+		return a.manifests, nil
+	}
+
+	manifests := make(map[Location]*dump.Manifest, len(a.locations))
+	for _, location := range a.locations {
+		body, err := a.s3.get(ctx, location.Bucket, location.key(dump.ManifestFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the manifest of archive %s: %w", location, err)
+		}
+		var manifest dump.Manifest
+		err = json.NewDecoder(body).Decode(&manifest)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest of archive %s: %w", location, err)
+		}
+		if manifest.Format != "" && manifest.Format != dump.FormatNDJSON {
+			return nil, fmt.Errorf("archive %s is a %s dump, only NDJSON dumps can be searched", location, manifest.Format)
+		}
+		manifests[location] = &manifest
+	}
+	a.manifests = manifests
+	a.fetchedAt = time.Now()
+	return manifests, nil
+}
+
+// load downloads a partition and loads its entries in the time range of query
+func (a *Archive) load(ctx context.Context, p partition, query types.SearchQuery, loader interfaces.BulkLoader,
+	ids map[int64]int64, result *types.ArchiveResult) error {
+	body, err := a.s3.get(ctx, p.location.Bucket, p.location.key(p.file.Name))
+	if err != nil {
+		return fmt.Errorf("failed to download archive partition: %w", err)
+	}
+	defer body.Close()
+	counted := &countingReader{reader: body}
+	var reader io.Reader = counted
+	if path.Ext(p.file.Name) == ".gz" {
+		gz, err := gzip.NewReader(counted)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive partition %s: %w", p.file.Name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var batch []*types.LogEntry
+	var dumpedIDs []int64
+	flush := func() error {
+		if err := loader.Load(batch); err != nil {
+			return fmt.Errorf("failed to load archive partition %s: %w", p.file.Name, err)
+		}
+		for i, entry := range batch {
+			if entry.ID != 0 {
+				ids[entry.ID] = dumpedIDs[i]
+				result.Loaded++
+			}
+		}
+		batch, dumpedIDs = batch[:0], dumpedIDs[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &types.LogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("invalid entry in archive partition %s: %w", p.file.Name, err)
+		}
+		if entry.Timestamp.Before(*query.StartTime) || (query.EndTime != nil && entry.Timestamp.After(*query.EndTime)) {
+			continue
+		}
+		batch = append(batch, entry)
+		dumpedIDs = append(dumpedIDs, entry.ID)
+		if len(batch) == loadBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	result.Bytes += counted.n
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read archive partition %s: %w", p.file.Name, err)
+	}
+	return flush()
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
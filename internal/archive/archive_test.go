@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/dump"
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestParseLocations(t *testing.T) {
+	locations, err := ParseLocations([]string{"s3://logs/opentrail/2024-03/", "s3://archive"})
+	if err != nil {
+		t.Fatalf("ParseLocations failed: %v", err)
+	}
+	if locations[0] != (Location{Bucket: "logs", Prefix: "opentrail/2024-03"}) || locations[1] != (Location{Bucket: "archive"}) {
+		t.Errorf("Unexpected locations: %+v", locations)
+	}
+	if locations[0].key(dump.ManifestFile) != "opentrail/2024-03/manifest.json" || locations[1].key("2024-03-01.ndjson") != "2024-03-01.ndjson" {
+		t.Errorf("Unexpected object keys")
+	}
+
+	for _, invalid := range []string{"https://logs.s3.amazonaws.com/opentrail", "s3:///opentrail", "logs/opentrail", "s3://key:secret@logs/opentrail"} {
+		if _, err := ParseLocations([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// fakeS3 serves objects by bucket and key, path-style
+type fakeS3 struct {
+	objects  map[string][]byte
+	requests []*http.Request
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r)
+	object, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.Error(w, "NoSuchKey", http.StatusNotFound)
+		return
+	}
+	w.Write(object)
+}
+
+func ndjson(t *testing.T, entries []types.LogEntry, compress bool) []byte {
+	var buf bytes.Buffer
+	var encoder *json.Encoder
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		encoder = json.NewEncoder(gz)
+	} else {
+		encoder = json.NewEncoder(&buf)
+	}
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			t.Fatalf("Failed to encode entry: %v", err)
+		}
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestArchive_Search(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	entry := func(id int64, at time.Time, message string) types.LogEntry {
+		return types.LogEntry{ID: id, Priority: 14, Facility: 1, Severity: 6, Version: 1, Timestamp: at,
+			Hostname: "web-1", AppName: "api", Message: message}
+	}
+	first := []types.LogEntry{entry(10, day1.Add(time.Hour), "login failed for alice"), entry(11, day1.Add(2*time.Hour), "request served")}
+	second := []types.LogEntry{entry(12, day2.Add(time.Hour), "login failed for bob"), entry(13, day2.Add(20*time.Hour), "login failed for carol")}
+	manifest, _ := json.Marshal(dump.Manifest{Format: dump.FormatNDJSON, Partition: dump.PartitionDay, Files: []dump.FileInfo{
+		{Name: "2024-03-01.ndjson.gz", Entries: 2, First: first[0].Timestamp, Last: first[1].Timestamp},
+		{Name: "2024-03-02.ndjson", Entries: 2, First: second[0].Timestamp, Last: second[1].Timestamp},
+	}})
+	store := &fakeS3{objects: map[string][]byte{
+		"logs/2024-03/manifest.json":        manifest,
+		"logs/2024-03/2024-03-01.ndjson.gz": ndjson(t, first, true),
+		"logs/2024-03/2024-03-02.ndjson":    ndjson(t, second, false),
+	}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	locations, _ := ParseLocations([]string{"s3://logs/2024-03"})
+	archive, err := New(Options{Locations: locations, Endpoint: server.URL, Region: "eu-west-1", MaxPartitions: 1})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// Only the second day is downloaded, and only entries in the range are returned
+	start, end := day2, day2.Add(12*time.Hour)
+	result, err := archive.Search(context.Background(), types.SearchQuery{Text: "login", StartTime: &start, EndTime: &end, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if result.Partitions != 1 || result.Loaded != 1 || len(result.Entries) != 1 {
+		t.Fatalf("Expected one entry from one partition, got %+v", result)
+	}
+	if got := result.Entries[0]; got.ID != 12 || !got.Archived || got.Message != "login failed for bob" {
+		t.Errorf("Expected archived entry 12, got %+v", got)
+	}
+	authorization := store.requests[len(store.requests)-1].Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(authorization, "/eu-west-1/s3/aws4_request") {
+		t.Errorf("Expected requests to be signed, got %q", authorization)
+	}
+
+	// Ranges covering more partitions than allowed are rejected before downloading any
+	start = day1
+	requests := len(store.requests)
+	if _, err := archive.Search(context.Background(), types.SearchQuery{StartTime: &start, Limit: 10}); !errors.Is(err, interfaces.ErrArchiveRangeTooLarge) {
+		t.Errorf("Expected the range to be too large, got %v", err)
+	}
+	if len(store.requests) != requests {
+		t.Errorf("Expected no partitions to be downloaded, got %d requests", len(store.requests)-requests)
+	}
+
+	// Both days, newest first, with the manifest cached
+	archive.maxPartitions = 2
+	result, err = archive.Search(context.Background(), types.SearchQuery{Text: "login", StartTime: &start, Limit: 10, Offset: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if result.Partitions != 2 || len(result.Entries) != 2 || result.Entries[0].ID != 12 || result.Entries[1].ID != 10 {
+		t.Errorf("Expected entries 12 and 10, got %+v", result.Entries)
+	}
+	if result.Bytes == 0 {
+		t.Error("Expected the downloaded bytes to be counted")
+	}
+	for _, r := range store.requests[requests:] {
+		if strings.HasSuffix(r.URL.Path, dump.ManifestFile) {
+			t.Error("Expected the manifest to be reused")
+		}
+	}
+
+	if _, err := archive.Search(context.Background(), types.SearchQuery{Limit: 10}); err == nil {
+		t.Error("Expected a search without a start time to be rejected")
+	}
+}
+
+func TestArchive_ParquetDumpsAreRejected(t *testing.T) {
+	manifest, _ := json.Marshal(dump.Manifest{Format: dump.FormatParquet})
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{"logs/manifest.json": manifest}})
+	defer server.Close()
+
+	locations, _ := ParseLocations([]string{"s3://logs"})
+	archive, _ := New(Options{Locations: locations, Endpoint: server.URL})
+	start := time.Now().AddDate(0, -1, 0)
+	if _, err := archive.Search(context.Background(), types.SearchQuery{StartTime: &start, Limit: 10}); err == nil || !strings.Contains(err.Error(), "NDJSON") {
+		t.Errorf("Expected a Parquet dump to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package archive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of the empty body of GET requests
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Credentials sign requests to S3; without an access key, requests are sent unsigned for public
+// buckets
+type Credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// credentialsFromEnv reads the standard AWS environment variables
+func credentialsFromEnv(context.Context) (Credentials, error) {
+	return Credentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// s3Client reads objects from S3 or an S3-compatible store
+type s3Client struct {
+	// endpoint addresses buckets by path, as S3-compatible stores expect; nil uses the
+	// virtual-hosted AWS endpoint of the region
+	endpoint    *url.URL
+	region      string
+	credentials func(context.Context) (Credentials, error)
+	client      *http.Client
+}
+
+// objectURL returns the URL of an object
+func (c *s3Client) objectURL(bucket, key string) *url.URL {
+	if c.endpoint != nil {
+		u := *c.endpoint
+		base := strings.TrimSuffix(u.EscapedPath(), "/")
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+		u.RawPath = base + "/" + encodePath(bucket) + "/" + encodePath(key)
+		return &u
+	}
+	return &url.URL{
+		Scheme:  "https",
+		Host:    bucket + ".s3." + c.region + ".amazonaws.com",
+		Path:    "/" + key,
+		RawPath: "/" + encodePath(key),
+	}
+}
+
+// get opens an object for reading
+func (c *s3Client) get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(bucket, key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := c.credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get S3 credentials: %w", err)
+	}
+	c.sign(request, credentials, time.Now())
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("s3://%s/%s: %s", bucket, key, response.Status)
+	}
+	return response.Body, nil
+}
+
+// sign adds an AWS Signature Version 4 to a request without a body
+func (c *s3Client) sign(request *http.Request, credentials Credentials, now time.Time) {
+	if credentials.AccessKey == "" {
+		return
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + request.URL.Host + "\n" +
+		"x-amz-content-sha256:" + emptyPayloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if token := credentials.SessionToken; token != "" {
+		request.Header.Set("X-Amz-Security-Token", token)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + token + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretKey), date)
+	for _, part := range []string{c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodePath percent-encodes an object key as S3 signatures expect: everything but unreserved
+// characters and the slashes separating segments
+func encodePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
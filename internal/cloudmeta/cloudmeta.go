@@ -0,0 +1,202 @@
+// Package cloudmeta reads where the process runs from the instance metadata service of its cloud
+// provider, for stamping entries with their region and instance
+package cloudmeta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Supported providers
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderAzure = "azure"
+)
+
+// Names of the discovered values
+const (
+	Cloud      = "cloud"
+	Region     = "region"
+	Zone       = "zone"
+	InstanceID = "instance_id"
+	// Account is the AWS account, GCP project or Azure subscription
+	Account = "account"
+)
+
+// DefaultTimeout bounds the requests to the metadata service, which answers within milliseconds
+// where it exists
+const DefaultTimeout = 2 * time.Second
+
+// maxResponseSize bounds the metadata responses read
+const maxResponseSize = 64 * 1024
+
+// Base URLs of the metadata services; tests point them at local servers
+var (
+	awsBaseURL   = "http://169.254.169.254"
+	gcpBaseURL   = "http://metadata.google.internal"
+	azureBaseURL = "http://169.254.169.254"
+)
+
+// Providers lists the supported providers
+func Providers() []string {
+	return []string{ProviderAWS, ProviderGCP, ProviderAzure}
+}
+
+// Discover reads the cloud, region, zone, instance ID and account of the instance from the
+// metadata service of provider
+func Discover(ctx context.Context, provider string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	var values map[string]string
+	var err error
+	switch provider {
+	case ProviderAWS:
+		values, err = discoverAWS(ctx)
+	case ProviderGCP:
+		values, err = discoverGCP(ctx)
+	case ProviderAzure:
+		values, err = discoverAzure(ctx)
+	default:
+		return nil, fmt.Errorf("unknown cloud provider %q, must be one of %s", provider, strings.Join(Providers(), ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s instance metadata: %w", provider, err)
+	}
+
+	// Leave out what the provider did not report, e.g. the zone of a regional Azure VM
+	for name, value := range values {
+		if value == "" {
+			delete(values, name)
+		}
+	}
+	values[Cloud] = provider
+	return values, nil
+}
+
+// discoverAWS reads the instance identity document, with an IMDSv2 session token
+func discoverAWS(ctx context.Context) (map[string]string, error) {
+	token, err := fetch(ctx, http.MethodPut, awsBaseURL+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := fetch(ctx, http.MethodGet, awsBaseURL+"/latest/dynamic/instance-identity/document", map[string]string{
+		"X-aws-ec2-metadata-token": string(token),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceID       string `json:"instanceId"`
+		AccountID        string `json:"accountId"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("invalid identity document: %w", err)
+	}
+	return map[string]string{
+		Region:     document.Region,
+		Zone:       document.AvailabilityZone,
+		InstanceID: document.InstanceID,
+		Account:    document.AccountID,
+	}, nil
+}
+
+// discoverGCP reads the zone, instance ID and project; the region is the zone without its suffix
+func discoverGCP(ctx context.Context) (map[string]string, error) {
+	header := map[string]string{"Metadata-Flavor": "Google"}
+	read := func(path string) (string, error) {
+		body, err := fetch(ctx, http.MethodGet, gcpBaseURL+"/computeMetadata/v1/"+path, header)
+		return strings.TrimSpace(string(body)), err
+	}
+
+	zone, err := read("instance/zone")
+	if err != nil {
+		return nil, err
+	}
+	instanceID, err := read("instance/id")
+	if err != nil {
+		return nil, err
+	}
+	project, err := read("project/project-id")
+	if err != nil {
+		return nil, err
+	}
+
+	// The zone is reported as projects/123456/zones/europe-west1-b
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return map[string]string{
+		Region:     region,
+		Zone:       zone,
+		InstanceID: instanceID,
+		Account:    project,
+	}, nil
+}
+
+// discoverAzure reads the compute metadata of the VM
+func discoverAzure(ctx context.Context) (map[string]string, error) {
+	body, err := fetch(ctx, http.MethodGet, azureBaseURL+"/metadata/instance/compute?api-version=2021-02-01", map[string]string{
+		"Metadata": "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var compute struct {
+		Location       string `json:"location"`
+		Zone           string `json:"zone"`
+		VMID           string `json:"vmId"`
+		SubscriptionID string `json:"subscriptionId"`
+	}
+	if err := json.Unmarshal(body, &compute); err != nil {
+		return nil, fmt.Errorf("invalid compute metadata: %w", err)
+	}
+	return map[string]string{
+		Region:     compute.Location,
+		Zone:       compute.Zone,
+		InstanceID: compute.VMID,
+		Account:    compute.SubscriptionID,
+	}, nil
+}
+
+// fetch requests url from the metadata service and returns the body of a 200 response
+func fetch(ctx context.Context, method, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	// Metadata services are link-local and must not be reached through a proxy
+	client := &http.Client{Transport: &http.Transport{Proxy: nil}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s returned %s", method, req.URL.Path, resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,95 @@
+package cloudmeta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveMetadata points every provider at a local metadata service answering from responses,
+// keyed by method and path, when the request carries the header; the AWS session token is
+// requested without it
+func serveMetadata(t *testing.T, header, value string, responses map[string]string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok || (r.Header.Get(header) != value && r.URL.Path != "/latest/api/token") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := []string{awsBaseURL, gcpBaseURL, azureBaseURL}
+	awsBaseURL, gcpBaseURL, azureBaseURL = server.URL, server.URL, server.URL
+	t.Cleanup(func() {
+		awsBaseURL, gcpBaseURL, azureBaseURL = previous[0], previous[1], previous[2]
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		provider      string
+		header, value string
+		responses     map[string]string
+		expected      map[string]string
+	}{
+		{
+			provider: ProviderAWS, header: "X-aws-ec2-metadata-token", value: "token",
+			responses: map[string]string{
+				"PUT /latest/api/token": "token",
+				"GET /latest/dynamic/instance-identity/document": `{"region": "eu-west-1", "availabilityZone": "eu-west-1a",
+					"instanceId": "i-0abc", "accountId": "123456789012"}`,
+			},
+			expected: map[string]string{Cloud: "aws", Region: "eu-west-1", Zone: "eu-west-1a", InstanceID: "i-0abc", Account: "123456789012"},
+		},
+		{
+			provider: ProviderGCP, header: "Metadata-Flavor", value: "Google",
+			responses: map[string]string{
+				"GET /computeMetadata/v1/instance/zone":      "projects/42/zones/europe-west1-b",
+				"GET /computeMetadata/v1/instance/id":        "4711",
+				"GET /computeMetadata/v1/project/project-id": "shop-prod",
+			},
+			expected: map[string]string{Cloud: "gcp", Region: "europe-west1", Zone: "europe-west1-b", InstanceID: "4711", Account: "shop-prod"},
+		},
+		{
+			provider: ProviderAzure, header: "Metadata", value: "true",
+			responses: map[string]string{
+				"GET /metadata/instance/compute": `{"location": "westeurope", "zone": "", "vmId": "02aab8a4", "subscriptionId": "8d10da13"}`,
+			},
+			expected: map[string]string{Cloud: "azure", Region: "westeurope", InstanceID: "02aab8a4", Account: "8d10da13"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.provider, func(t *testing.T) {
+			serveMetadata(t, tc.header, tc.value, tc.responses)
+			values, err := Discover(context.Background(), tc.provider)
+			if err != nil {
+				t.Fatalf("Discover() failed: %v", err)
+			}
+			if len(values) != len(tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, values)
+			}
+			for name, value := range tc.expected {
+				if values[name] != value {
+					t.Errorf("Expected %s %q, got %q", name, value, values[name])
+				}
+			}
+		})
+	}
+}
+
+func TestDiscover_Errors(t *testing.T) {
+	if _, err := Discover(context.Background(), "openstack"); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+
+	// A metadata service answering without the expected header is not the provider's
+	serveMetadata(t, "Metadata-Flavor", "Google", map[string]string{})
+	if _, err := Discover(context.Background(), ProviderGCP); err == nil {
+		t.Error("Expected an error when the metadata service does not answer")
+	}
+}
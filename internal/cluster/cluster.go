@@ -0,0 +1,227 @@
+// Package cluster merges the live streams of the nodes of a deployment, so that a live tail client
+// connected to any node sees the entries ingested on all of them. Each node follows the local
+// stream of every peer over its WebSocket API, tags entries with the node that received them and
+// holds them for a short merge window to pass them on in approximate timestamp order. A cursor per
+// peer, the last row ID received from it, lets following resume without gaps after a reconnect.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+const (
+	// DefaultMergeWindow is how long entries are held to be put in timestamp order
+	DefaultMergeWindow = 500 * time.Millisecond
+	// subscriberBuffer is how many merged entries wait for a slow live tail client before its
+	// entries are dropped
+	subscriberBuffer = 100
+)
+
+// Peer is another node whose live stream is followed
+type Peer struct {
+	Name string
+	// URL is the base URL of the peer's HTTP API, with the credentials to use in its user info
+	URL *url.URL
+}
+
+// ParsePeers parses peers given as name=url or as url, which names the peer after its host
+func ParsePeers(specs []string) ([]Peer, error) {
+	peers := make([]Peer, 0, len(specs))
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		name, rawURL, named := strings.Cut(spec, "=")
+		if !named {
+			name, rawURL = "", spec
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("cluster peer %q must be an http or https URL, optionally preceded by name=", spec)
+		}
+		if name == "" {
+			name = u.Host
+		}
+		if names[name] {
+			return nil, fmt.Errorf("cluster peer %q is listed twice", name)
+		}
+		names[name] = true
+		peers = append(peers, Peer{Name: name, URL: u})
+	}
+	return peers, nil
+}
+
+// Stream merges the live stream of this node with those of its peers
+type Stream struct {
+	node   string
+	window time.Duration
+	peers  []*follower
+	// incoming receives the entries of all nodes, tagged with their node
+	incoming chan *types.LogEntry
+
+	mu          sync.Mutex
+	subscribers map[chan *types.LogEntry]bool
+	local       types.ClusterNode
+}
+
+// NewStream creates the stream of node, following peers and holding entries for window; a window
+// of zero passes entries on as they arrive
+func NewStream(node string, peers []Peer, window time.Duration) *Stream {
+	s := &Stream{
+		node:        node,
+		window:      window,
+		incoming:    make(chan *types.LogEntry, 1000),
+		subscribers: make(map[chan *types.LogEntry]bool),
+		local:       types.ClusterNode{Name: node, Local: true, Connected: true},
+	}
+	for _, peer := range peers {
+		s.peers = append(s.peers, newFollower(peer, s.incoming))
+	}
+	return s
+}
+
+// Run follows the peers and merges their entries with those of the local stream until ctx is done
+// or local is closed
+func (s *Stream) Run(ctx context.Context, local <-chan *types.LogEntry) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, f := range s.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.run(ctx)
+		}()
+	}
+	defer wg.Wait()
+
+	interval := max(s.window/5, 10*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending []pendingEntry
+	for {
+		select {
+		case entry, ok := <-local:
+			if !ok {
+				return
+			}
+			s.recordLocal(entry)
+			tagged := *entry
+			tagged.Node = s.node
+			pending = s.hold(pending, &tagged)
+		case entry := <-s.incoming:
+			pending = s.hold(pending, entry)
+		case now := <-ticker.C:
+			pending = s.release(pending, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pendingEntry is an entry held in the merge window since it arrived
+type pendingEntry struct {
+	entry   *types.LogEntry
+	arrived time.Time
+}
+
+// hold adds an entry to those held, or passes it on at once without a merge window
+func (s *Stream) hold(pending []pendingEntry, entry *types.LogEntry) []pendingEntry {
+	if s.window <= 0 {
+		s.broadcast(entry)
+		return pending
+	}
+	return append(pending, pendingEntry{entry: entry, arrived: time.Now()})
+}
+
+// release passes on the entries held for the whole merge window, oldest timestamp first, and
+// returns those still held
+func (s *Stream) release(pending []pendingEntry, now time.Time) []pendingEntry {
+	cutoff := now.Add(-s.window)
+	var due, held []pendingEntry
+	for _, p := range pending {
+		if p.arrived.After(cutoff) {
+			held = append(held, p)
+		} else {
+			due = append(due, p)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].entry.Timestamp.Before(due[j].entry.Timestamp)
+	})
+	for _, p := range due {
+		s.broadcast(p.entry)
+	}
+	return held
+}
+
+// recordLocal advances the cursor of this node
+func (s *Stream) recordLocal(entry *types.LogEntry) {
+	metrics.GetClusterMetrics().Entries.WithLabelValues(s.node).Inc()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.local.Received++
+	s.local.CursorID = entry.ID
+	timestamp := entry.Timestamp
+	s.local.CursorTimestamp = &timestamp
+}
+
+// broadcast sends a merged entry to every subscriber, skipping those whose buffer is full
+func (s *Stream) broadcast(entry *types.LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			metrics.GetClusterMetrics().Dropped.Inc()
+		}
+	}
+}
+
+// Subscribe creates a subscription to the merged entries
+func (s *Stream) Subscribe() <-chan *types.LogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan *types.LogEntry, subscriberBuffer)
+	s.subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe removes a subscription
+func (s *Stream) Unsubscribe(subscription <-chan *types.LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		if ch == subscription {
+			delete(s.subscribers, ch)
+			close(ch)
+			break
+		}
+	}
+}
+
+// Status reports the state of the stream of every node
+func (s *Stream) Status() types.ClusterStatus {
+	s.mu.Lock()
+	status := types.ClusterStatus{
+		Node:               s.node,
+		MergeWindowSeconds: s.window.Seconds(),
+		Subscribers:        len(s.subscribers),
+		Nodes:              []types.ClusterNode{s.local},
+	}
+	s.mu.Unlock()
+	for _, f := range s.peers {
+		status.Nodes = append(status.Nodes, f.status())
+	}
+	return status
+}
@@ -0,0 +1,254 @@
+package cluster
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// minReconnectDelay and maxReconnectDelay bound the delay before connecting to a peer again,
+	// doubled after each failed attempt
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+	// readTimeout is how long a peer's stream may stay silent; peers ping every 30 seconds
+	readTimeout = 90 * time.Second
+	// backfillLimit is the most entries fetched from a peer after a reconnect
+	backfillLimit = 1000
+	// requestTimeout bounds connecting to a peer and fetching its missed entries
+	requestTimeout = 10 * time.Second
+)
+
+// follower follows the local stream of one peer
+type follower struct {
+	peer Peer
+	out  chan<- *types.LogEntry
+
+	dialer *websocket.Dialer
+	client *http.Client
+
+	mu    sync.Mutex
+	state types.ClusterNode
+}
+
+func newFollower(peer Peer, out chan<- *types.LogEntry) *follower {
+	redacted := *peer.URL
+	redacted.User = nil
+	return &follower{
+		peer:   peer,
+		out:    out,
+		dialer: &websocket.Dialer{HandshakeTimeout: requestTimeout, Proxy: http.ProxyFromEnvironment},
+		client: &http.Client{Timeout: requestTimeout},
+		state:  types.ClusterNode{Name: peer.Name, URL: redacted.String()},
+	}
+}
+
+// run follows the peer, reconnecting with backoff, until ctx is done
+func (f *follower) run(ctx context.Context) {
+	delay := minReconnectDelay
+	for {
+		connectedAt := time.Now()
+		err := f.follow(ctx)
+		f.disconnected(err)
+		if ctx.Err() != nil {
+			return
+		}
+		// A stream that stayed up for a while starts the backoff over
+		if time.Since(connectedAt) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// follow connects to the peer's stream, fetches the entries missed since the cursor and passes
+// on the entries of the stream until it fails
+func (f *follower) follow(ctx context.Context) error {
+	conn, _, err := f.dialer.DialContext(ctx, f.endpoint("/api/logs/stream", url.Values{"scope": {"local"}}, true), f.header())
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	cursor := f.connected()
+
+	// The stream is connected first so that no entry is missed; entries it repeats from the
+	// backfill are skipped
+	backfilled, err := f.backfill(ctx, cursor)
+	if err != nil {
+		log.Printf("Failed to fetch the entries cluster peer %s stored while disconnected: %v", f.peer.Name, err)
+	}
+	for _, entry := range backfilled {
+		f.pass(ctx, entry, true)
+	}
+	seen := make(map[int64]bool, len(backfilled))
+	for _, entry := range backfilled {
+		seen[entry.ID] = true
+	}
+
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	for {
+		var entry types.LogEntry
+		if err := conn.ReadJSON(&entry); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if seen[entry.ID] {
+			continue
+		}
+		f.pass(ctx, &entry, false)
+	}
+}
+
+// backfill fetches the entries the peer stored after the cursor, oldest first. Only entries with a
+// timestamp from that of the cursor on are found, and at most backfillLimit of them.
+func (f *follower) backfill(ctx context.Context, cursor types.ClusterNode) ([]*types.LogEntry, error) {
+	if cursor.CursorTimestamp == nil {
+		return nil, nil
+	}
+	query := url.Values{
+		"start_time": {cursor.CursorTimestamp.Format(time.RFC3339Nano)},
+		"limit":      {strconv.Itoa(backfillLimit)},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, f.endpoint("/api/logs", query, false), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range f.header() {
+		request.Header[name] = values
+	}
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search answered %s", response.Status)
+	}
+	var body struct {
+		Data []*types.LogEntry `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid search response: %w", err)
+	}
+
+	// Results are newest first
+	var missed []*types.LogEntry
+	for i := len(body.Data) - 1; i >= 0; i-- {
+		if body.Data[i].ID > cursor.CursorID {
+			missed = append(missed, body.Data[i])
+		}
+	}
+	return missed, nil
+}
+
+// pass tags an entry of the peer with the node that recorded receiving it, or else the peer's
+// name, advances the cursor and hands the entry to the merge
+func (f *follower) pass(ctx context.Context, entry *types.LogEntry, backfilled bool) {
+	entry.Node = entry.Metadata(types.NodeParam)
+	if entry.Node == "" {
+		entry.Node = f.peer.Name
+	}
+	metrics.GetClusterMetrics().Entries.WithLabelValues(f.peer.Name).Inc()
+	f.mu.Lock()
+	f.state.Received++
+	if backfilled {
+		f.state.Backfilled++
+	}
+	if entry.ID > f.state.CursorID {
+		f.state.CursorID = entry.ID
+		timestamp := entry.Timestamp
+		f.state.CursorTimestamp = &timestamp
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.out <- entry:
+	case <-ctx.Done():
+	}
+}
+
+// connected records a connection and returns the state with the cursor to resume from
+func (f *follower) connected() types.ClusterNode {
+	metrics.GetClusterMetrics().Connected.WithLabelValues(f.peer.Name).Set(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.state.ConnectedAt != nil {
+		f.state.Reconnects++
+		metrics.GetClusterMetrics().Reconnects.WithLabelValues(f.peer.Name).Inc()
+		log.Printf("Following the live stream of cluster peer %s again", f.peer.Name)
+	}
+	f.state.Connected = true
+	f.state.ConnectedAt = &now
+	f.state.LastError = ""
+	return f.state
+}
+
+// disconnected records that the stream ended with err
+func (f *follower) disconnected(err error) {
+	metrics.GetClusterMetrics().Connected.WithLabelValues(f.peer.Name).Set(0)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state.Connected && err != nil {
+		log.Printf("Lost the live stream of cluster peer %s: %v", f.peer.Name, err)
+	}
+	f.state.Connected = false
+	if err != nil {
+		f.state.LastError = err.Error()
+	}
+}
+
+// status returns the state of the peer's stream
+func (f *follower) status() types.ClusterNode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// endpoint returns the URL of an API path of the peer, as a WebSocket URL if ws is set
+func (f *follower) endpoint(path string, query url.Values, ws bool) string {
+	u := *f.peer.URL
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	if ws {
+		u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	}
+	return u.String()
+}
+
+// header returns the headers authenticating requests to the peer with the credentials of its URL
+func (f *follower) header() http.Header {
+	header := http.Header{}
+	if user := f.peer.URL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+	return header
+}
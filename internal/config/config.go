@@ -3,11 +3,25 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"opentrail/internal/archive"
+	"opentrail/internal/cloudmeta"
+	"opentrail/internal/cluster"
+	"opentrail/internal/entryid"
+	"opentrail/internal/logformat"
+	"opentrail/internal/notify"
+	"opentrail/internal/siem"
 	"opentrail/internal/types"
+	"opentrail/internal/vault"
 )
 
 // LoadConfig loads configuration from command-line flags and environment variables
@@ -25,13 +39,119 @@ func LoadConfigWithFlagSet(fs *flag.FlagSet) (*types.Config, error) {
 	tcpPort := fs.Int("tcp-port", 2253, "TCP port for log ingestion")
 	httpPort := fs.Int("http-port", 8080, "HTTP port for web interface")
 	webSocketPort := fs.Int("websocket-port", 8081, "WebSocket port for log ingestion")
+	tcpBind := fs.String("tcp-bind", "", "Address to bind the TCP listener to (empty binds all interfaces)")
+	httpBind := fs.String("http-bind", "", "Address to bind the HTTP listener to (empty binds all interfaces)")
+	webSocketBind := fs.String("websocket-bind", "", "Address to bind the WebSocket listener to (empty binds all interfaces)")
+	tcpProxyProtocol := fs.Bool("tcp-proxy-protocol", false, "Expect a PROXY protocol v1/v2 header on TCP ingestion connections")
+	tcpProxyTrusted := fs.String("tcp-proxy-trusted", "", "Comma-separated CIDRs of proxies allowed to send a PROXY protocol header")
+	tcpIdleTimeout := fs.Duration("tcp-idle-timeout", 30*time.Second, "Close TCP ingestion connections that send nothing for this long (0 uses the default)")
+	tcpMaxLifetime := fs.Duration("tcp-max-connection-lifetime", 0, "Close TCP ingestion connections this long after they were accepted (0 disables)")
+	tcpTLSCert := fs.String("tcp-tls-cert", "", "PEM certificate file for TLS on the TCP ingestion listener")
+	tcpTLSKey := fs.String("tcp-tls-key", "", "PEM private key file for TLS on the TCP ingestion listener")
+	tcpTLSClientCA := fs.String("tcp-tls-client-ca", "", "PEM file of CAs that must sign TLS ingestion client certificates (mTLS)")
+	tcpTLSTenants := fs.String("tcp-tls-tenants", "", "JSON file of tenants routed by the TLS server name (SNI) of ingestion connections")
+	udpPort := fs.Int("udp-port", 0, "UDP port for syslog ingestion, one message per datagram (0 disables)")
+	udpBind := fs.String("udp-bind", "", "Address to bind the UDP listener to (empty binds all interfaces)")
+	udpMaxMessageSize := fs.Int("udp-max-message-size", 8192, "Size in bytes longer UDP datagrams are truncated to (0 uses the default)")
+	httpH2C := fs.Bool("http-h2c", false, "Accept cleartext HTTP/2 (h2c) on the HTTP listener, only from trusted proxies when any are configured")
+	http2MaxStreams := fs.Int("http2-max-concurrent-streams", 250, "Maximum concurrent requests multiplexed on one HTTP/2 connection (0 uses the default)")
+	httpIdleTimeout := fs.Duration("http-idle-timeout", 120*time.Second, "Close idle keep-alive HTTP connections after this long (0 uses the default)")
+	httpAPITimeout := fs.Duration("http-api-timeout", 30*time.Second, "Read and write deadline of API requests (0 disables)")
+	httpExportTimeout := fs.Duration("http-export-timeout", 30*time.Minute, "Read and write deadline of log exports (0 disables)")
+	httpStreamTimeout := fs.Duration("http-stream-timeout", 0, "Read and write deadline of WebSocket log streams (0 disables)")
+	accessLog := fs.Bool("access-log", false, "Write an access log line per HTTP request to the process log")
+	accessLogIngest := fs.Bool("access-log-ingest", false, "Store an access log entry per HTTP request in the log store")
+	acmeDomains := fs.String("acme-domains", "", "Comma-separated domains to serve the web interface over HTTPS for, with certificates obtained through ACME (empty serves plain HTTP)")
+	acmeEmail := fs.String("acme-email", "", "Contact email of the ACME account, notified about expiring certificates")
+	acmeDirectory := fs.String("acme-directory", "", "Directory URL of the ACME certificate authority (empty uses Let's Encrypt)")
+	acmeCacheDir := fs.String("acme-cache-dir", "opentrail-acme", "Directory storing the ACME account key and certificates")
+	acmeChallenge := fs.String("acme-challenge", types.ACMETLSALPN, "ACME challenge type: tls-alpn-01 on the HTTPS listener or http-01 on acme-http-port")
+	acmeHTTPPort := fs.Int("acme-http-port", 80, "Port answering http-01 challenges and redirecting other requests to HTTPS")
+	httpBasePath := fs.String("http-base-path", "", "Path prefix to serve the web interface and API under, e.g. /logs")
+	trustedProxies := fs.String("trusted-proxies", "", "Comma-separated CIDRs of reverse proxies whose forwarding headers are trusted")
+	allowedOrigins := fs.String("allowed-origins", "", "Comma-separated additional origins allowed to open WebSocket connections (* allows any)")
+	searchRateLimit := fs.Int("search-rate-limit", 600, "Search requests per minute allowed per client (0 disables)")
+	ingestRateLimit := fs.Int("ingest-rate-limit", 6000, "HTTP ingestion requests per minute allowed per client (0 disables)")
+	adminRateLimit := fs.Int("admin-rate-limit", 30, "Admin requests per minute allowed per client (0 disables)")
+	searchMaxBody := fs.Int("search-max-body", 64*1024, "Maximum search request body size in bytes (0 disables)")
+	ingestMaxBody := fs.Int("ingest-max-body", 10*1024*1024, "Maximum HTTP ingestion request body size in bytes (0 disables)")
+	adminMaxBody := fs.Int("admin-max-body", 1024*1024, "Maximum admin request body size in bytes (0 disables)")
+	maxConcurrentSearches := fs.Int("max-concurrent-searches", 4, "Maximum number of searches running against storage at once (0 uses the default)")
+	searchQueueTimeout := fs.Duration("search-queue-timeout", 5*time.Second, "How long a search waits for a free slot before being rejected (0 rejects immediately)")
+	searchCacheTTL := fs.Duration("search-cache-ttl", 5*time.Second, "How long the results of identical searches are reused (0 disables)")
+	searchCacheSize := fs.Int("search-cache-size", 256, "Maximum number of search results cached (0 uses the default)")
+	tailBufferWindow := fs.Duration("tail-buffer-window", 10*time.Minute, "How long received entries are held in memory for searching the live tail (0 disables)")
+	tailBufferSize := fs.Int("tail-buffer-size", 10000, "Maximum number of entries held for searching the live tail (0 uses the default)")
 	databasePath := fs.String("database-path", "logs.db", "Path to SQLite database file")
 	logFormat := fs.String("log-format", "{{timestamp}}|{{level}}|{{tracking_id}}|{{message}}", "Log parsing format")
+	parseWorkers := fs.Int("parse-workers", 0, "Number of goroutines parsing incoming messages (0 uses one per CPU)")
+	degradeAfter := fs.Duration("degrade-after", 10*time.Second, "How long storage writes must keep failing before entering the degraded mode, which forwards and spools entries and rejects searches (0 disables)")
+	degradedSpool := fs.String("degraded-spool", "", "File keeping the messages received in the degraded mode until storage recovers (empty only forwards them)")
+	degradedSpoolLimitMB := fs.Int("degraded-spool-limit-mb", 1024, "Disk space in MiB the degraded spool may use (0 for no limit)")
+	storageRetryAttempts := fs.Int("storage-retry-attempts", 3, "How often a storage operation failing because the database is busy is run (0 or 1 disables retries)")
+	storageRetryBackoff := fs.Duration("storage-retry-backoff", 10*time.Millisecond, "Delay before retrying a storage operation, doubled before each further retry")
+	storageBreakerThreshold := fs.Int("storage-breaker-threshold", 20, "Storage operations in a row failing because the database is busy that open the circuit breaker (0 disables)")
+	storageBreakerOpen := fs.Duration("storage-breaker-open", 5*time.Second, "How long the storage circuit breaker fails operations fast before probing storage again")
+	memoryLimitMB := fs.Int("memory-limit-mb", 0, "Approximate memory in MiB messages not yet stored may hold before senders are held back (0 disables)")
+	timestampRules := fs.String("timestamp-rules", "", "JSON file of timestamp layouts and time zones for senders whose timestamps are not RFC3339")
+	transformRules := fs.String("transform-rules", "", "JSON file of rewrites per sender applied before parsing: strip ANSI codes, trim prefixes, collapse whitespace")
+	stamp := fs.String("stamp", "", "Comma-separated name=value deployment metadata recorded in every entry, e.g. environment=prod,cluster=blue")
+	stampCloud := fs.String("stamp-cloud", "", "Also stamp the region, zone, instance and account read from the instance metadata service of this cloud: aws, gcp or azure")
 	retentionDays := fs.Int("retention-days", 30, "Number of days to retain logs")
 	maxConnections := fs.Int("max-connections", 100, "Maximum number of concurrent TCP connections")
 	authUsername := fs.String("auth-username", "", "Username for HTTP Basic Auth (empty disables auth)")
 	authPassword := fs.String("auth-password", "", "Password for HTTP Basic Auth")
 	authEnabled := fs.Bool("auth-enabled", false, "Enable HTTP Basic Authentication")
+	setupFile := fs.String("setup-file", "opentrail-setup.json", "File the first-run setup wizard stores its settings in (empty disables the wizard)")
+	noAuth := fs.Bool("no-auth", false, "Run without authentication instead of starting the setup wizard when no admin account is configured")
+	demo := fs.Bool("demo", false, "Seed an empty database with sample logs and serve it read-only to anyone, for showcasing the web interface and APIs")
+	readerUsername := fs.String("reader-username", "", "Username of a reader-role account that sees redacted content and cannot use admin endpoints")
+	readerPassword := fs.String("reader-password", "", "Password of the reader-role account")
+	redactFields := fs.String("redact-fields", "", "Comma-separated structured data keys (sdid.param) masked for reader-role users")
+	redactPattern := fs.String("redact-pattern", "", "Regular expression whose matches in messages and structured data values are masked for reader-role users")
+	encryptFields := fs.String("encrypt-fields", "", "Comma-separated structured data keys (sdid.param) encrypted before storage and only shown decrypted to admins")
+	encryptionKeys := fs.String("encryption-keys", "", "JSON file of the keys encrypting -encrypt-fields, per tenant and app")
+	reusePort := fs.Bool("reuse-port", false, "Bind listeners with SO_REUSEPORT so a new instance can share the ports during upgrades")
+	storageLimitMB := fs.Int("storage-limit-mb", 0, "Disk space in MiB the database may use, for storage projections (0 uses the free disk space)")
+	integrityCheckInterval := fs.Duration("integrity-check-interval", 24*time.Hour, "Interval between background database integrity checks (0 disables)")
+	walCheckpointMode := fs.String("wal-checkpoint-mode", types.CheckpointPassive, "How the write-ahead log is checkpointed at -wal-checkpoint-pages: passive, restart or truncate")
+	walCheckpointPages := fs.Int("wal-checkpoint-pages", 1000, "Size in pages the write-ahead log is checkpointed at (0 disables)")
+	walTruncateInterval := fs.Duration("wal-truncate-interval", 0, "Interval between checkpoints truncating the write-ahead log regardless of its size (0 disables)")
+	keepANSIColors := fs.Bool("keep-ansi-colors", false, "Store the ANSI color sequences of messages for the web UI to render instead of escaping them")
+	rawMessages := fs.String("raw-messages", types.RawMessagesPlain, "How the message as received is stored with each entry: plain, compressed or off")
+	sdMaxBytes := fs.Int("sd-max-bytes", 64*1024, "Maximum total size in bytes of the structured data of an entry (0 disables)")
+	sdMaxParams := fs.Int("sd-max-params", 256, "Maximum number of structured data parameters of an entry (0 disables)")
+	sdMaxKeysPerApp := fs.Int("sd-max-keys-per-app", 1000, "Maximum number of distinct structured data keys per application (0 disables)")
+	hashChain := fs.Bool("hash-chain", false, "Link stored entries in a per-day SHA-256 hash chain so later alterations can be detected")
+	idempotencyWindow := fs.Duration("idempotency-window", 24*time.Hour, "How long the idempotency keys of stored entries are remembered to drop entries sent again (0 disables)")
+	entryIDs := fs.String("entry-ids", entryid.SchemeAutoIncrement, "Globally unique, time-sortable uid given to new entries besides their row ID: autoincrement (none), ulid or ksuid")
+	siemForward := fs.String("siem-forward", "", "SIEM collector (tcp://host:port or udp://host:port) that security-relevant entries are forwarded to")
+	siemFormat := fs.String("siem-format", siem.FormatCEF, "Format of forwarded SIEM events: cef, ocsf or an output format such as rfc3164")
+	siemMinSeverity := fs.Int("siem-min-severity", 4, "Forward entries at least this severe (syslog severity 0-7, 4 is warning)")
+	outputFormats := fs.String("output-formats", "", "JSON file of Go-template output formats for exports and forwarding")
+	notificationChannels := fs.String("notification-channels", "", "JSON file defining Slack, Discord and Teams notification channels")
+	fallbackAlertPercent := fs.Int("fallback-alert-percent", notify.DefaultFallbackPercent, "Alert when at least this percentage of a source's messages cannot be parsed (0 disables)")
+	fallbackAlertMinEntries := fs.Int("fallback-alert-min-entries", notify.DefaultFallbackMinEntries, "Messages a source must send in a window for the fallback alert to judge it")
+	fallbackAlertWindow := fs.Duration("fallback-alert-window", notify.DefaultFallbackWindow, "Period the messages of each source are counted over by the fallback alert")
+	fallbackAlertChannels := fs.String("fallback-alert-channels", "", "Comma-separated notification channels the fallback alert is sent to (empty only logs it)")
+	lifecycleWebhook := fs.String("lifecycle-webhook", "", "URL that retention runs, deletions and backups are announced to as JSON events")
+	agentConfig := fs.String("agent-config", "", "JSON file of the files, parsers and redaction rules centrally managed for shipper agents")
+	nodeName := fs.String("node-name", "", "Name of this node, recorded in every entry it receives and tagging its live entries in a cluster (default the host name)")
+	clusterPeers := fs.String("cluster-peers", "", "Comma-separated other nodes whose live streams are followed, as name=url or url of their HTTP API")
+	archiveURLs := fs.String("archive", "", "Comma-separated s3://bucket/prefix URLs of NDJSON dumps searched for entries past retention")
+	archiveEndpoint := fs.String("archive-endpoint", "", "URL of an S3-compatible store holding the archive (default AWS)")
+	archiveRegion := fs.String("archive-region", "", "Region of the archive buckets (default AWS_REGION or us-east-1)")
+	archiveAuto := fs.Bool("archive-auto", false, "Search the archive whenever a search starts before the retention cutoff, not only with archive=true")
+	archiveMaxPartitions := fs.Int("archive-max-partitions", archive.DefaultMaxPartitions, "Most archive partitions a search downloads")
+	archiveAccessKey := fs.String("archive-access-key", "", "Access key ID signing archive requests (default AWS_ACCESS_KEY_ID)")
+	archiveSecretKey := fs.String("archive-secret-key", "", "Secret access key signing archive requests (default AWS_SECRET_ACCESS_KEY)")
+	vaultAddress := fs.String("vault-addr", "", "URL of the Vault server that secrets given as vault:path#key are read from")
+	vaultAuth := fs.String("vault-auth", vault.AuthToken, "How to log in to Vault: token or approle")
+	vaultToken := fs.String("vault-token", "", "Vault token, with -vault-auth token")
+	vaultRoleID := fs.String("vault-role-id", "", "AppRole role ID, with -vault-auth approle")
+	vaultSecretID := fs.String("vault-secret-id", "", "AppRole secret ID, with -vault-auth approle")
+	clusterMergeWindow := fs.Duration("cluster-merge-window", cluster.DefaultMergeWindow, "How long cluster live stream entries are held to be put in timestamp order (0 disables)")
+	siemFacilities := fs.String("siem-facilities", "", "Comma-separated syslog facility codes to forward (empty forwards all)")
 
 	// Only parse if this is the global command line
 	if fs == flag.CommandLine {
@@ -42,13 +162,138 @@ func LoadConfigWithFlagSet(fs *flag.FlagSet) (*types.Config, error) {
 	config.TCPPort = getIntFromEnv("OPENTRAIL_TCP_PORT", *tcpPort)
 	config.HTTPPort = getIntFromEnv("OPENTRAIL_HTTP_PORT", *httpPort)
 	config.WebSocketPort = getIntFromEnv("OPENTRAIL_WEBSOCKET_PORT", *webSocketPort)
+	config.TCPBindAddress = trimBrackets(getStringFromEnv("OPENTRAIL_TCP_BIND", *tcpBind))
+	config.HTTPBindAddress = trimBrackets(getStringFromEnv("OPENTRAIL_HTTP_BIND", *httpBind))
+	config.WebSocketBindAddress = trimBrackets(getStringFromEnv("OPENTRAIL_WEBSOCKET_BIND", *webSocketBind))
+	config.TCPProxyProtocol = getBoolFromEnv("OPENTRAIL_TCP_PROXY_PROTOCOL", *tcpProxyProtocol)
+	config.TCPProxyTrusted = splitList(getStringFromEnv("OPENTRAIL_TCP_PROXY_TRUSTED", *tcpProxyTrusted))
+	config.TCPIdleTimeout = getDurationFromEnv("OPENTRAIL_TCP_IDLE_TIMEOUT", *tcpIdleTimeout)
+	config.TCPMaxConnectionLifetime = getDurationFromEnv("OPENTRAIL_TCP_MAX_CONNECTION_LIFETIME", *tcpMaxLifetime)
+	config.TCPTLSCert = getStringFromEnv("OPENTRAIL_TCP_TLS_CERT", *tcpTLSCert)
+	config.TCPTLSKey = getStringFromEnv("OPENTRAIL_TCP_TLS_KEY", *tcpTLSKey)
+	config.TCPTLSClientCA = getStringFromEnv("OPENTRAIL_TCP_TLS_CLIENT_CA", *tcpTLSClientCA)
+	config.TCPTLSTenants = getStringFromEnv("OPENTRAIL_TCP_TLS_TENANTS", *tcpTLSTenants)
+	config.UDPPort = getIntFromEnv("OPENTRAIL_UDP_PORT", *udpPort)
+	config.UDPBindAddress = trimBrackets(getStringFromEnv("OPENTRAIL_UDP_BIND", *udpBind))
+	config.UDPMaxMessageSize = getIntFromEnv("OPENTRAIL_UDP_MAX_MESSAGE_SIZE", *udpMaxMessageSize)
+	config.HTTPH2C = getBoolFromEnv("OPENTRAIL_HTTP_H2C", *httpH2C)
+	config.HTTP2MaxConcurrentStreams = getIntFromEnv("OPENTRAIL_HTTP2_MAX_CONCURRENT_STREAMS", *http2MaxStreams)
+	config.HTTPIdleTimeout = getDurationFromEnv("OPENTRAIL_HTTP_IDLE_TIMEOUT", *httpIdleTimeout)
+	config.HTTPAPITimeout = getDurationFromEnv("OPENTRAIL_HTTP_API_TIMEOUT", *httpAPITimeout)
+	config.HTTPExportTimeout = getDurationFromEnv("OPENTRAIL_HTTP_EXPORT_TIMEOUT", *httpExportTimeout)
+	config.HTTPStreamTimeout = getDurationFromEnv("OPENTRAIL_HTTP_STREAM_TIMEOUT", *httpStreamTimeout)
+	config.AccessLog = getBoolFromEnv("OPENTRAIL_ACCESS_LOG", *accessLog)
+	config.AccessLogIngest = getBoolFromEnv("OPENTRAIL_ACCESS_LOG_INGEST", *accessLogIngest)
+	config.ACMEDomains = splitList(getStringFromEnv("OPENTRAIL_ACME_DOMAINS", *acmeDomains))
+	config.ACMEEmail = getStringFromEnv("OPENTRAIL_ACME_EMAIL", *acmeEmail)
+	config.ACMEDirectory = getStringFromEnv("OPENTRAIL_ACME_DIRECTORY", *acmeDirectory)
+	config.ACMECacheDir = getStringFromEnv("OPENTRAIL_ACME_CACHE_DIR", *acmeCacheDir)
+	config.ACMEChallenge = strings.ToLower(getStringFromEnv("OPENTRAIL_ACME_CHALLENGE", *acmeChallenge))
+	config.ACMEHTTPPort = getIntFromEnv("OPENTRAIL_ACME_HTTP_PORT", *acmeHTTPPort)
+	config.HTTPBasePath = normalizeBasePath(getStringFromEnv("OPENTRAIL_HTTP_BASE_PATH", *httpBasePath))
+	config.TrustedProxies = splitList(getStringFromEnv("OPENTRAIL_TRUSTED_PROXIES", *trustedProxies))
+	config.AllowedOrigins = splitList(getStringFromEnv("OPENTRAIL_ALLOWED_ORIGINS", *allowedOrigins))
+	config.SearchRateLimit = getIntFromEnv("OPENTRAIL_SEARCH_RATE_LIMIT", *searchRateLimit)
+	config.IngestRateLimit = getIntFromEnv("OPENTRAIL_INGEST_RATE_LIMIT", *ingestRateLimit)
+	config.AdminRateLimit = getIntFromEnv("OPENTRAIL_ADMIN_RATE_LIMIT", *adminRateLimit)
+	config.SearchMaxBodyBytes = getIntFromEnv("OPENTRAIL_SEARCH_MAX_BODY", *searchMaxBody)
+	config.IngestMaxBodyBytes = getIntFromEnv("OPENTRAIL_INGEST_MAX_BODY", *ingestMaxBody)
+	config.AdminMaxBodyBytes = getIntFromEnv("OPENTRAIL_ADMIN_MAX_BODY", *adminMaxBody)
+	config.MaxConcurrentSearches = getIntFromEnv("OPENTRAIL_MAX_CONCURRENT_SEARCHES", *maxConcurrentSearches)
+	config.SearchQueueTimeout = getDurationFromEnv("OPENTRAIL_SEARCH_QUEUE_TIMEOUT", *searchQueueTimeout)
+	config.SearchCacheTTL = getDurationFromEnv("OPENTRAIL_SEARCH_CACHE_TTL", *searchCacheTTL)
+	config.SearchCacheSize = getIntFromEnv("OPENTRAIL_SEARCH_CACHE_SIZE", *searchCacheSize)
+	config.TailBufferWindow = getDurationFromEnv("OPENTRAIL_TAIL_BUFFER_WINDOW", *tailBufferWindow)
+	config.TailBufferSize = getIntFromEnv("OPENTRAIL_TAIL_BUFFER_SIZE", *tailBufferSize)
 	config.DatabasePath = getStringFromEnv("OPENTRAIL_DATABASE_PATH", *databasePath)
 	config.LogFormat = getStringFromEnv("OPENTRAIL_LOG_FORMAT", *logFormat)
+	config.ParseWorkers = getIntFromEnv("OPENTRAIL_PARSE_WORKERS", *parseWorkers)
+	config.MemoryLimitMB = getIntFromEnv("OPENTRAIL_MEMORY_LIMIT_MB", *memoryLimitMB)
+	config.DegradeAfter = getDurationFromEnv("OPENTRAIL_DEGRADE_AFTER", *degradeAfter)
+	config.DegradedSpool = getStringFromEnv("OPENTRAIL_DEGRADED_SPOOL", *degradedSpool)
+	config.DegradedSpoolLimitMB = getIntFromEnv("OPENTRAIL_DEGRADED_SPOOL_LIMIT_MB", *degradedSpoolLimitMB)
+	config.StorageRetryAttempts = getIntFromEnv("OPENTRAIL_STORAGE_RETRY_ATTEMPTS", *storageRetryAttempts)
+	config.StorageRetryBackoff = getDurationFromEnv("OPENTRAIL_STORAGE_RETRY_BACKOFF", *storageRetryBackoff)
+	config.StorageBreakerThreshold = getIntFromEnv("OPENTRAIL_STORAGE_BREAKER_THRESHOLD", *storageBreakerThreshold)
+	config.StorageBreakerOpen = getDurationFromEnv("OPENTRAIL_STORAGE_BREAKER_OPEN", *storageBreakerOpen)
+	config.TimestampRules = getStringFromEnv("OPENTRAIL_TIMESTAMP_RULES", *timestampRules)
+	config.TransformRules = getStringFromEnv("OPENTRAIL_TRANSFORM_RULES", *transformRules)
 	config.RetentionDays = getIntFromEnv("OPENTRAIL_RETENTION_DAYS", *retentionDays)
 	config.MaxConnections = getIntFromEnv("OPENTRAIL_MAX_CONNECTIONS", *maxConnections)
 	config.AuthUsername = getStringFromEnv("OPENTRAIL_AUTH_USERNAME", *authUsername)
 	config.AuthPassword = getStringFromEnv("OPENTRAIL_AUTH_PASSWORD", *authPassword)
 	config.AuthEnabled = getBoolFromEnv("OPENTRAIL_AUTH_ENABLED", *authEnabled)
+	config.SetupFile = getStringFromEnv("OPENTRAIL_SETUP_FILE", *setupFile)
+	config.NoAuth = getBoolFromEnv("OPENTRAIL_NO_AUTH", *noAuth)
+	config.Demo = getBoolFromEnv("OPENTRAIL_DEMO", *demo)
+	config.ReaderUsername = getStringFromEnv("OPENTRAIL_READER_USERNAME", *readerUsername)
+	config.ReaderPassword = getStringFromEnv("OPENTRAIL_READER_PASSWORD", *readerPassword)
+	config.RedactFields = splitList(getStringFromEnv("OPENTRAIL_REDACT_FIELDS", *redactFields))
+	config.RedactPattern = getStringFromEnv("OPENTRAIL_REDACT_PATTERN", *redactPattern)
+	config.EncryptFields = splitList(getStringFromEnv("OPENTRAIL_ENCRYPT_FIELDS", *encryptFields))
+	config.EncryptionKeys = getStringFromEnv("OPENTRAIL_ENCRYPTION_KEYS", *encryptionKeys)
+	config.ReusePort = getBoolFromEnv("OPENTRAIL_REUSE_PORT", *reusePort)
+	config.StorageLimitMB = getIntFromEnv("OPENTRAIL_STORAGE_LIMIT_MB", *storageLimitMB)
+	config.IntegrityCheckInterval = getDurationFromEnv("OPENTRAIL_INTEGRITY_CHECK_INTERVAL", *integrityCheckInterval)
+	config.WALCheckpointMode = strings.ToLower(getStringFromEnv("OPENTRAIL_WAL_CHECKPOINT_MODE", *walCheckpointMode))
+	config.WALCheckpointPages = getIntFromEnv("OPENTRAIL_WAL_CHECKPOINT_PAGES", *walCheckpointPages)
+	config.WALTruncateInterval = getDurationFromEnv("OPENTRAIL_WAL_TRUNCATE_INTERVAL", *walTruncateInterval)
+	config.RawMessages = strings.ToLower(getStringFromEnv("OPENTRAIL_RAW_MESSAGES", *rawMessages))
+	config.KeepANSIColors = getBoolFromEnv("OPENTRAIL_KEEP_ANSI_COLORS", *keepANSIColors)
+	config.SDMaxBytes = getIntFromEnv("OPENTRAIL_SD_MAX_BYTES", *sdMaxBytes)
+	config.SDMaxParams = getIntFromEnv("OPENTRAIL_SD_MAX_PARAMS", *sdMaxParams)
+	config.SDMaxKeysPerApp = getIntFromEnv("OPENTRAIL_SD_MAX_KEYS_PER_APP", *sdMaxKeysPerApp)
+	config.HashChain = getBoolFromEnv("OPENTRAIL_HASH_CHAIN", *hashChain)
+	config.IdempotencyWindow = getDurationFromEnv("OPENTRAIL_IDEMPOTENCY_WINDOW", *idempotencyWindow)
+	config.EntryIDs = strings.ToLower(getStringFromEnv("OPENTRAIL_ENTRY_IDS", *entryIDs))
+	config.SIEMForward = getStringFromEnv("OPENTRAIL_SIEM_FORWARD", *siemForward)
+	config.SIEMFormat = strings.ToLower(getStringFromEnv("OPENTRAIL_SIEM_FORMAT", *siemFormat))
+	config.SIEMMinSeverity = getIntFromEnv("OPENTRAIL_SIEM_MIN_SEVERITY", *siemMinSeverity)
+	config.OutputFormats = getStringFromEnv("OPENTRAIL_OUTPUT_FORMATS", *outputFormats)
+	config.NotificationChannels = getStringFromEnv("OPENTRAIL_NOTIFICATION_CHANNELS", *notificationChannels)
+	config.FallbackAlertPercent = getIntFromEnv("OPENTRAIL_FALLBACK_ALERT_PERCENT", *fallbackAlertPercent)
+	config.FallbackAlertMinEntries = getIntFromEnv("OPENTRAIL_FALLBACK_ALERT_MIN_ENTRIES", *fallbackAlertMinEntries)
+	config.FallbackAlertWindow = getDurationFromEnv("OPENTRAIL_FALLBACK_ALERT_WINDOW", *fallbackAlertWindow)
+	config.FallbackAlertChannels = splitList(getStringFromEnv("OPENTRAIL_FALLBACK_ALERT_CHANNELS", *fallbackAlertChannels))
+	config.LifecycleWebhook = getStringFromEnv("OPENTRAIL_LIFECYCLE_WEBHOOK", *lifecycleWebhook)
+	config.AgentConfig = getStringFromEnv("OPENTRAIL_AGENT_CONFIG", *agentConfig)
+	config.NodeName = strings.TrimSpace(getStringFromEnv("OPENTRAIL_NODE_NAME", *nodeName))
+	config.ClusterPeers = splitList(getStringFromEnv("OPENTRAIL_CLUSTER_PEERS", *clusterPeers))
+	config.ClusterMergeWindow = getDurationFromEnv("OPENTRAIL_CLUSTER_MERGE_WINDOW", *clusterMergeWindow)
+	config.Archive = splitList(getStringFromEnv("OPENTRAIL_ARCHIVE", *archiveURLs))
+	config.ArchiveEndpoint = getStringFromEnv("OPENTRAIL_ARCHIVE_ENDPOINT", *archiveEndpoint)
+	config.ArchiveRegion = getStringFromEnv("OPENTRAIL_ARCHIVE_REGION", *archiveRegion)
+	config.ArchiveAuto = getBoolFromEnv("OPENTRAIL_ARCHIVE_AUTO", *archiveAuto)
+	config.ArchiveMaxPartitions = getIntFromEnv("OPENTRAIL_ARCHIVE_MAX_PARTITIONS", *archiveMaxPartitions)
+	config.ArchiveAccessKey = getStringFromEnv("OPENTRAIL_ARCHIVE_ACCESS_KEY", *archiveAccessKey)
+	config.ArchiveSecretKey = getStringFromEnv("OPENTRAIL_ARCHIVE_SECRET_KEY", *archiveSecretKey)
+	config.VaultAddress = getStringFromEnv("OPENTRAIL_VAULT_ADDR", *vaultAddress)
+	config.VaultAuth = strings.ToLower(getStringFromEnv("OPENTRAIL_VAULT_AUTH", *vaultAuth))
+	config.VaultToken = getStringFromEnv("OPENTRAIL_VAULT_TOKEN", *vaultToken)
+	config.VaultRoleID = getStringFromEnv("OPENTRAIL_VAULT_ROLE_ID", *vaultRoleID)
+	config.VaultSecretID = getStringFromEnv("OPENTRAIL_VAULT_SECRET_ID", *vaultSecretID)
+	facilities, err := parseIntList(splitList(getStringFromEnv("OPENTRAIL_SIEM_FACILITIES", *siemFacilities)))
+	if err != nil {
+		return nil, fmt.Errorf("configuration validation failed: siem-facilities %w", err)
+	}
+	config.SIEMFacilities = facilities
+	stampFields, err := parseStamp(splitList(getStringFromEnv("OPENTRAIL_STAMP", *stamp)))
+	if err != nil {
+		return nil, fmt.Errorf("configuration validation failed: stamp %w", err)
+	}
+	config.Stamp = stampFields
+	config.StampCloud = strings.ToLower(getStringFromEnv("OPENTRAIL_STAMP_CLOUD", *stampCloud))
+
+	// Settings from the setup wizard fill in what flags and environment variables leave unset
+	if config.SetupFile != "" {
+		settings, err := LoadSetupFile(config.SetupFile)
+		if err != nil {
+			return nil, err
+		}
+		if settings != nil {
+			applySetup(config, settings, explicitlySet(fs))
+		}
+	}
 
 	// Validate configuration
 	if err := validateConfig(config); err != nil {
@@ -82,6 +327,90 @@ func validateConfig(config *types.Config) error {
 		return fmt.Errorf("http-port and websocket-port cannot be the same (%d)", config.HTTPPort)
 	}
 
+	// Validate bind addresses
+	if err := validateBindAddress("tcp-bind", config.TCPBindAddress); err != nil {
+		return err
+	}
+	if err := validateBindAddress("http-bind", config.HTTPBindAddress); err != nil {
+		return err
+	}
+	if err := validateBindAddress("websocket-bind", config.WebSocketBindAddress); err != nil {
+		return err
+	}
+	if err := validateBindAddress("udp-bind", config.UDPBindAddress); err != nil {
+		return err
+	}
+
+	// Validate reverse proxy settings
+	if config.HTTPBasePath != "" && (!strings.HasPrefix(config.HTTPBasePath, "/") || strings.ContainsAny(config.HTTPBasePath, "?# ")) {
+		return fmt.Errorf("http-base-path must be an absolute URL path such as /logs, got %q", config.HTTPBasePath)
+	}
+	for _, proxy := range config.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return fmt.Errorf("trusted-proxies entry must be a CIDR or IP address, got %q", proxy)
+			}
+		}
+	}
+	for _, proxy := range config.TCPProxyTrusted {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return fmt.Errorf("tcp-proxy-trusted entry must be a CIDR or IP address, got %q", proxy)
+			}
+		}
+	}
+	if config.TCPProxyProtocol && len(config.TCPProxyTrusted) == 0 {
+		return fmt.Errorf("tcp-proxy-protocol requires tcp-proxy-trusted to list the proxies allowed to send the header")
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if parsed, err := url.Parse(origin); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("allowed-origins entry must be * or an origin such as https://logs.example.com, got %q", origin)
+		}
+	}
+
+	// Validate HTTP request limits
+	limits := map[string]int{
+		"search-rate-limit": config.SearchRateLimit,
+		"ingest-rate-limit": config.IngestRateLimit,
+		"admin-rate-limit":  config.AdminRateLimit,
+		"search-max-body":   config.SearchMaxBodyBytes,
+		"ingest-max-body":   config.IngestMaxBodyBytes,
+		"admin-max-body":    config.AdminMaxBodyBytes,
+	}
+	for name, value := range limits {
+		if value < 0 {
+			return fmt.Errorf("%s cannot be negative, got %d", name, value)
+		}
+	}
+
+	// Validate TLS ingestion; the files themselves are loaded when the TCP server starts
+	if (config.TCPTLSCert == "") != (config.TCPTLSKey == "") {
+		return fmt.Errorf("tcp-tls-cert and tcp-tls-key must be set together")
+	}
+	if config.TCPTLSClientCA != "" && config.TCPTLSCert == "" && config.TCPTLSTenants == "" {
+		return fmt.Errorf("tcp-tls-client-ca requires tcp-tls-cert or tcp-tls-tenants")
+	}
+
+	// Validate HTTPS through ACME; certificates are obtained on the first TLS handshake
+	if len(config.ACMEDomains) > 0 {
+		if err := validateACME(config); err != nil {
+			return err
+		}
+	}
+
+	// Validate deployment metadata; reserved names would overwrite what the receiver records
+	for name := range config.Stamp {
+		if err := validateStampName(name); err != nil {
+			return err
+		}
+	}
+	if config.StampCloud != "" && !slices.Contains(cloudmeta.Providers(), config.StampCloud) {
+		return fmt.Errorf("stamp-cloud must be one of %s, got %q", strings.Join(cloudmeta.Providers(), ", "), config.StampCloud)
+	}
+
 	// Validate database path is not empty
 	if strings.TrimSpace(config.DatabasePath) == "" {
 		return fmt.Errorf("database-path cannot be empty")
@@ -102,6 +431,145 @@ func validateConfig(config *types.Config) error {
 		return fmt.Errorf("max-connections must be at least 1, got %d", config.MaxConnections)
 	}
 
+	// Validate TCP connection timeouts
+	if config.TCPIdleTimeout < 0 {
+		return fmt.Errorf("tcp-idle-timeout cannot be negative, got %v", config.TCPIdleTimeout)
+	}
+	if config.TCPMaxConnectionLifetime < 0 {
+		return fmt.Errorf("tcp-max-connection-lifetime cannot be negative, got %v", config.TCPMaxConnectionLifetime)
+	}
+
+	// Validate UDP ingestion; it may share its port number with the TCP listener, as syslog's 514 does
+	if config.UDPPort < 0 || config.UDPPort > 65535 {
+		return fmt.Errorf("udp-port must be between 0 and 65535, got %d", config.UDPPort)
+	}
+	if config.UDPMaxMessageSize != 0 && (config.UDPMaxMessageSize < 480 || config.UDPMaxMessageSize > 65535) {
+		return fmt.Errorf("udp-max-message-size must be 0 or between 480 and 65535, got %d", config.UDPMaxMessageSize)
+	}
+
+	// Validate HTTP connection settings
+	if config.HTTP2MaxConcurrentStreams < 0 {
+		return fmt.Errorf("http2-max-concurrent-streams cannot be negative, got %d", config.HTTP2MaxConcurrentStreams)
+	}
+	if config.HTTPIdleTimeout < 0 {
+		return fmt.Errorf("http-idle-timeout cannot be negative, got %v", config.HTTPIdleTimeout)
+	}
+	for name, timeout := range map[string]time.Duration{
+		"http-api-timeout":    config.HTTPAPITimeout,
+		"http-export-timeout": config.HTTPExportTimeout,
+		"http-stream-timeout": config.HTTPStreamTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("%s cannot be negative, got %v", name, timeout)
+		}
+	}
+
+	// Validate parse workers
+	if config.ParseWorkers < 0 {
+		return fmt.Errorf("parse-workers cannot be negative, got %d", config.ParseWorkers)
+	}
+
+	// Validate memory limit
+	if config.MemoryLimitMB < 0 {
+		return fmt.Errorf("memory-limit-mb cannot be negative, got %d", config.MemoryLimitMB)
+	}
+
+	// Validate degraded mode
+	if config.DegradeAfter < 0 {
+		return fmt.Errorf("degrade-after cannot be negative, got %v", config.DegradeAfter)
+	}
+	if config.DegradedSpoolLimitMB < 0 {
+		return fmt.Errorf("degraded-spool-limit-mb cannot be negative, got %d", config.DegradedSpoolLimitMB)
+	}
+
+	// Validate storage retries and circuit breaker
+	if config.StorageRetryAttempts < 0 {
+		return fmt.Errorf("storage-retry-attempts cannot be negative, got %d", config.StorageRetryAttempts)
+	}
+	if config.StorageRetryBackoff < 0 {
+		return fmt.Errorf("storage-retry-backoff cannot be negative, got %v", config.StorageRetryBackoff)
+	}
+	if config.StorageBreakerThreshold < 0 {
+		return fmt.Errorf("storage-breaker-threshold cannot be negative, got %d", config.StorageBreakerThreshold)
+	}
+	if config.StorageBreakerOpen < 0 {
+		return fmt.Errorf("storage-breaker-open cannot be negative, got %v", config.StorageBreakerOpen)
+	}
+
+	// Validate search concurrency
+	if config.MaxConcurrentSearches < 0 {
+		return fmt.Errorf("max-concurrent-searches cannot be negative, got %d", config.MaxConcurrentSearches)
+	}
+	if config.SearchQueueTimeout < 0 {
+		return fmt.Errorf("search-queue-timeout cannot be negative, got %v", config.SearchQueueTimeout)
+	}
+	if config.SearchCacheTTL < 0 {
+		return fmt.Errorf("search-cache-ttl cannot be negative, got %v", config.SearchCacheTTL)
+	}
+	if config.SearchCacheSize < 0 {
+		return fmt.Errorf("search-cache-size cannot be negative, got %d", config.SearchCacheSize)
+	}
+	if config.TailBufferWindow < 0 {
+		return fmt.Errorf("tail-buffer-window cannot be negative, got %v", config.TailBufferWindow)
+	}
+	if config.TailBufferSize < 0 {
+		return fmt.Errorf("tail-buffer-size cannot be negative, got %d", config.TailBufferSize)
+	}
+	if config.IdempotencyWindow < 0 {
+		return fmt.Errorf("idempotency-window cannot be negative, got %v", config.IdempotencyWindow)
+	}
+	if config.EntryIDs == "" {
+		config.EntryIDs = entryid.SchemeAutoIncrement
+	}
+	if _, err := entryid.New(config.EntryIDs); err != nil {
+		return fmt.Errorf("entry-ids must be autoincrement, ulid or ksuid, got %q", config.EntryIDs)
+	}
+
+	// Validate storage limit
+	if config.StorageLimitMB < 0 {
+		return fmt.Errorf("storage-limit-mb cannot be negative, got %d", config.StorageLimitMB)
+	}
+
+	// Validate structured data limits
+	if config.SDMaxBytes < 0 {
+		return fmt.Errorf("sd-max-bytes cannot be negative, got %d", config.SDMaxBytes)
+	}
+	if config.SDMaxParams < 0 {
+		return fmt.Errorf("sd-max-params cannot be negative, got %d", config.SDMaxParams)
+	}
+	if config.SDMaxKeysPerApp < 0 {
+		return fmt.Errorf("sd-max-keys-per-app cannot be negative, got %d", config.SDMaxKeysPerApp)
+	}
+
+	// Validate integrity check interval
+	if config.IntegrityCheckInterval < 0 {
+		return fmt.Errorf("integrity-check-interval cannot be negative, got %v", config.IntegrityCheckInterval)
+	}
+
+	// Validate the checkpoint policy of the write-ahead log, checkpointing passively by default
+	if config.WALCheckpointMode == "" {
+		config.WALCheckpointMode = types.CheckpointPassive
+	}
+	if !slices.Contains(types.CheckpointModes, config.WALCheckpointMode) {
+		return fmt.Errorf("wal-checkpoint-mode must be one of %s, got %q", strings.Join(types.CheckpointModes, ", "), config.WALCheckpointMode)
+	}
+	if config.WALCheckpointPages < 0 {
+		return fmt.Errorf("wal-checkpoint-pages cannot be negative, got %d", config.WALCheckpointPages)
+	}
+	if config.WALTruncateInterval != 0 && config.WALTruncateInterval < time.Minute {
+		return fmt.Errorf("wal-truncate-interval must be at least 1m or 0, got %v", config.WALTruncateInterval)
+	}
+
+	// Validate raw message mode, storing plain messages by default
+	if config.RawMessages == "" {
+		config.RawMessages = types.RawMessagesPlain
+	}
+	switch config.RawMessages {
+	case types.RawMessagesPlain, types.RawMessagesCompressed, types.RawMessagesOff:
+	default:
+		return fmt.Errorf("raw-messages must be plain, compressed or off, got %q", config.RawMessages)
+	}
+
 	// Validate authentication settings
 	if config.AuthEnabled {
 		if strings.TrimSpace(config.AuthUsername) == "" {
@@ -117,9 +585,285 @@ func validateConfig(config *types.Config) error {
 		config.AuthEnabled = true
 	}
 
+	// Validate the reader role and what it sees redacted
+	if config.ReaderUsername != "" {
+		if !config.AuthEnabled {
+			return fmt.Errorf("reader-username requires authentication to be enabled")
+		}
+		if strings.TrimSpace(config.ReaderPassword) == "" {
+			return fmt.Errorf("reader-password cannot be empty when reader-username is set")
+		}
+		if config.ReaderUsername == config.AuthUsername {
+			return fmt.Errorf("reader-username must differ from auth-username")
+		}
+	}
+	for _, field := range config.RedactFields {
+		if sdid, param, ok := strings.Cut(field, "."); !ok || sdid == "" || param == "" {
+			return fmt.Errorf("redact-fields entry must be a structured data key such as sdid.param, got %q", field)
+		}
+	}
+	if config.RedactPattern != "" {
+		if _, err := regexp.Compile(config.RedactPattern); err != nil {
+			return fmt.Errorf("redact-pattern is not a valid regular expression: %w", err)
+		}
+	}
+
+	// Validate field encryption; the keys themselves are loaded at startup
+	for _, field := range config.EncryptFields {
+		sdid, param, ok := strings.Cut(field, ".")
+		if !ok || sdid == "" || param == "" {
+			return fmt.Errorf("encrypt-fields entry must be a structured data key such as sdid.param, got %q", field)
+		}
+		if sdid == types.MetadataSDID {
+			return fmt.Errorf("encrypt-fields cannot include the receiver metadata %s", field)
+		}
+	}
+	if len(config.EncryptFields) > 0 && config.EncryptionKeys == "" {
+		return fmt.Errorf("encrypt-fields requires encryption-keys")
+	}
+	if config.EncryptionKeys != "" && len(config.EncryptFields) == 0 {
+		return fmt.Errorf("encryption-keys requires encrypt-fields")
+	}
+
+	// Validate SIEM forwarding
+	if config.SIEMForward != "" {
+		if _, _, err := siem.ParseTarget(config.SIEMForward); err != nil {
+			return fmt.Errorf("siem-forward: %w", err)
+		}
+	}
+	if config.LifecycleWebhook != "" {
+		if parsed, err := url.Parse(config.LifecycleWebhook); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("lifecycle-webhook must be an http or https URL, got %q", config.LifecycleWebhook)
+		}
+	}
+	if config.SIEMFormat == "" {
+		config.SIEMFormat = siem.FormatCEF
+	}
+	// Formats defined in the output formats file are checked when it is loaded
+	if !slices.Contains(siem.Formats, config.SIEMFormat) && !logformat.IsBuiltin(config.SIEMFormat) && config.OutputFormats == "" {
+		return fmt.Errorf("siem-format must be cef, ocsf or an output format, got %q", config.SIEMFormat)
+	}
+	if config.SIEMMinSeverity < 0 || config.SIEMMinSeverity > 7 {
+		return fmt.Errorf("siem-min-severity must be between 0 and 7, got %d", config.SIEMMinSeverity)
+	}
+	for _, facility := range config.SIEMFacilities {
+		if facility < 0 || facility > 23 {
+			return fmt.Errorf("siem-facilities entries must be between 0 and 23, got %d", facility)
+		}
+	}
+
+	// Validate cluster peers
+	if _, err := cluster.ParsePeers(config.ClusterPeers); err != nil {
+		return fmt.Errorf("cluster-peers: %w", err)
+	}
+	if config.FallbackAlertPercent < 0 || config.FallbackAlertPercent > 100 {
+		return fmt.Errorf("fallback-alert-percent must be between 0 and 100, got %d", config.FallbackAlertPercent)
+	}
+	if config.FallbackAlertPercent > 0 {
+		if config.FallbackAlertMinEntries < 1 {
+			return fmt.Errorf("fallback-alert-min-entries must be at least 1, got %d", config.FallbackAlertMinEntries)
+		}
+		if config.FallbackAlertWindow < time.Minute {
+			return fmt.Errorf("fallback-alert-window must be at least 1m, got %v", config.FallbackAlertWindow)
+		}
+	}
+	if len(config.FallbackAlertChannels) > 0 && config.NotificationChannels == "" {
+		return fmt.Errorf("fallback-alert-channels requires notification-channels")
+	}
+	if config.ClusterMergeWindow < 0 {
+		return fmt.Errorf("cluster-merge-window cannot be negative, got %v", config.ClusterMergeWindow)
+	}
+
+	// Validate archive
+	if _, err := archive.ParseLocations(config.Archive); err != nil {
+		return err
+	}
+	if config.ArchiveEndpoint != "" {
+		if u, err := url.Parse(config.ArchiveEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("archive-endpoint must be an http or https URL, got %q", config.ArchiveEndpoint)
+		}
+	}
+	if len(config.Archive) > 0 && config.ArchiveMaxPartitions < 1 {
+		return fmt.Errorf("archive-max-partitions must be at least 1, got %d", config.ArchiveMaxPartitions)
+	}
+	if (config.ArchiveAccessKey == "") != (config.ArchiveSecretKey == "") {
+		return fmt.Errorf("archive-access-key and archive-secret-key must be set together")
+	}
+
+	return validateVault(config)
+}
+
+// validateVault checks the Vault login and the options referring to Vault secrets
+func validateVault(config *types.Config) error {
+	if config.VaultAddress != "" {
+		if u, err := url.Parse(config.VaultAddress); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("vault-addr must be an http or https URL, got %q", config.VaultAddress)
+		}
+		switch config.VaultAuth {
+		case vault.AuthToken:
+			if config.VaultToken == "" {
+				return fmt.Errorf("vault-auth token requires vault-token")
+			}
+		case vault.AuthAppRole:
+			if config.VaultRoleID == "" || config.VaultSecretID == "" {
+				return fmt.Errorf("vault-auth approle requires vault-role-id and vault-secret-id")
+			}
+		default:
+			return fmt.Errorf("vault-auth must be token or approle, got %q", config.VaultAuth)
+		}
+	}
+
+	// Secrets are read once Vault can be logged in to, at startup
+	secrets := []struct {
+		name  string
+		value string
+	}{
+		{"auth-password", config.AuthPassword},
+		{"reader-password", config.ReaderPassword},
+		{"tcp-tls-cert", config.TCPTLSCert},
+		{"tcp-tls-key", config.TCPTLSKey},
+		{"tcp-tls-client-ca", config.TCPTLSClientCA},
+		{"tcp-tls-tenants", config.TCPTLSTenants},
+		{"encryption-keys", config.EncryptionKeys},
+		{"archive-access-key", config.ArchiveAccessKey},
+		{"archive-secret-key", config.ArchiveSecretKey},
+	}
+	for _, secret := range secrets {
+		if !vault.IsRef(secret.value) {
+			continue
+		}
+		if config.VaultAddress == "" {
+			return fmt.Errorf("%s refers to a Vault secret but vault-addr is not set", secret.name)
+		}
+		if _, _, err := vault.ParseRef(secret.value); err != nil {
+			return fmt.Errorf("%s: %w", secret.name, err)
+		}
+	}
+	return nil
+}
+
+// validateBindAddress checks that a bind address is empty, an IP address or a host name without a port
+func validateBindAddress(name, address string) error {
+	if address == "" || net.ParseIP(address) != nil {
+		return nil
+	}
+	if strings.ContainsAny(address, ":/ ") {
+		return fmt.Errorf("%s must be an IP address or host name without a port, got %q", name, address)
+	}
+	return nil
+}
+
+// validateACME checks the domains, directory, cache and challenge of HTTPS through ACME
+func validateACME(config *types.Config) error {
+	for _, domain := range config.ACMEDomains {
+		if net.ParseIP(domain) != nil || strings.ContainsAny(domain, ":/* ") || !strings.Contains(domain, ".") {
+			return fmt.Errorf("acme-domains entry must be a fully qualified domain name, got %q", domain)
+		}
+	}
+	if config.ACMEDirectory != "" {
+		if parsed, err := url.Parse(config.ACMEDirectory); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("acme-directory must be an https URL, got %q", config.ACMEDirectory)
+		}
+	}
+	if strings.TrimSpace(config.ACMECacheDir) == "" {
+		return fmt.Errorf("acme-cache-dir cannot be empty when acme-domains is set")
+	}
+	if config.ACMEChallenge == "" {
+		config.ACMEChallenge = types.ACMETLSALPN
+	}
+	switch config.ACMEChallenge {
+	case types.ACMETLSALPN:
+	case types.ACMEHTTP:
+		if config.ACMEHTTPPort < 1 || config.ACMEHTTPPort > 65535 {
+			return fmt.Errorf("acme-http-port must be between 1 and 65535, got %d", config.ACMEHTTPPort)
+		}
+		for name, port := range map[string]int{"tcp-port": config.TCPPort, "http-port": config.HTTPPort, "websocket-port": config.WebSocketPort} {
+			if port == config.ACMEHTTPPort {
+				return fmt.Errorf("acme-http-port and %s cannot be the same (%d)", name, port)
+			}
+		}
+	default:
+		return fmt.Errorf("acme-challenge must be %s or %s, got %q", types.ACMETLSALPN, types.ACMEHTTP, config.ACMEChallenge)
+	}
+	return nil
+}
+
+// trimBrackets accepts IPv6 bind addresses written in URL form, e.g. "[::1]"
+func trimBrackets(address string) string {
+	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
+		return address[1 : len(address)-1]
+	}
+	return address
+}
+
+// normalizeBasePath turns "logs/", "/logs/" or "/" into "/logs" or "" respectively
+func normalizeBasePath(path string) string {
+	path = strings.TrimRight(strings.TrimSpace(path), "/")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// parseIntList parses the integers of a split list
+func parseIntList(items []string) ([]int, error) {
+	var values []int
+	for _, item := range items {
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("must be a list of integers, got %q", item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseStamp parses name=value items into deployment metadata
+func parseStamp(items []string) (map[string]string, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	stamp := make(map[string]string, len(items))
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("must be a list of name=value items, got %q", item)
+		}
+		stamp[name] = value
+	}
+	return stamp, nil
+}
+
+// validateStampName checks that a deployment metadata name is an RFC5424 parameter name that the
+// receiver does not record itself
+func validateStampName(name string) error {
+	switch name {
+	case types.SourceIPParam, types.TenantParam, types.SequenceGapParam, types.IdempotencyKeyParam, types.SampleRateParam, types.NodeParam:
+		return fmt.Errorf("stamp name %q is reserved", name)
+	}
+	if len(name) > 32 {
+		return fmt.Errorf("stamp name %q exceeds 32 characters", name)
+	}
+	for _, c := range name {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			return fmt.Errorf("stamp name %q must be printable ASCII without spaces, '=', ']' or '\"'", name)
+		}
+	}
 	return nil
 }
 
+// splitList splits a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Helper functions for environment variable parsing
 
 func getStringFromEnv(key, defaultValue string) string {
@@ -145,4 +889,13 @@ func getBoolFromEnv(key string, defaultValue bool) bool {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getDurationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
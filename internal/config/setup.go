@@ -0,0 +1,141 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// minSetupPasswordLength is the shortest admin password the setup wizard accepts
+const minSetupPasswordLength = 8
+
+// NeedsSetup reports whether the server has no admin account and should serve the setup wizard
+// rather than run without authentication. A demo has no admin, so it is not set up.
+func NeedsSetup(config *types.Config) bool {
+	return !config.AuthEnabled && !config.NoAuth && !config.Demo && config.SetupFile != ""
+}
+
+// LoadSetupFile reads the settings stored by the setup wizard, nil if it has not run yet
+func LoadSetupFile(path string) (*types.SetupSettings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup file: %w", err)
+	}
+	var settings types.SetupSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse setup file %s: %w", path, err)
+	}
+	return &settings, nil
+}
+
+// SaveSetupFile writes the settings chosen in the setup wizard. The file holds the admin password,
+// so only its owner may read it.
+func SaveSetupFile(path string, settings types.SetupSettings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write setup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write setup file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write setup file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write setup file: %w", err)
+	}
+	return nil
+}
+
+// ApplySetup applies the settings chosen in the setup wizard to a loaded configuration and
+// validates the result
+func ApplySetup(config *types.Config, settings types.SetupSettings) error {
+	if strings.TrimSpace(settings.AdminUsername) == "" {
+		return fmt.Errorf("admin username cannot be empty")
+	}
+	if len(settings.AdminPassword) < minSetupPasswordLength {
+		return fmt.Errorf("admin password must be at least %d characters", minSetupPasswordLength)
+	}
+	if config.ReaderUsername != "" && settings.AdminUsername == config.ReaderUsername {
+		return fmt.Errorf("admin username must differ from reader-username")
+	}
+
+	applySetup(config, &settings, func(name, env string) bool { return false })
+	return validateConfig(config)
+}
+
+// applySetup fills in the options of the setup settings that isSet does not report as given
+func applySetup(config *types.Config, settings *types.SetupSettings, isSet func(name, env string) bool) {
+	if !isSet("auth-username", "OPENTRAIL_AUTH_USERNAME") && !isSet("auth-password", "OPENTRAIL_AUTH_PASSWORD") {
+		config.AuthUsername = settings.AdminUsername
+		config.AuthPassword = settings.AdminPassword
+	}
+	if settings.RetentionDays > 0 && !isSet("retention-days", "OPENTRAIL_RETENTION_DAYS") {
+		config.RetentionDays = settings.RetentionDays
+	}
+	if !isSet("tcp-bind", "OPENTRAIL_TCP_BIND") {
+		config.TCPBindAddress = trimBrackets(settings.TCPBindAddress)
+	}
+	if settings.TCPPort != 0 && !isSet("tcp-port", "OPENTRAIL_TCP_PORT") {
+		config.TCPPort = settings.TCPPort
+	}
+	if !isSet("websocket-bind", "OPENTRAIL_WEBSOCKET_BIND") {
+		config.WebSocketBindAddress = trimBrackets(settings.WebSocketBindAddress)
+	}
+	if settings.WebSocketPort != 0 && !isSet("websocket-port", "OPENTRAIL_WEBSOCKET_PORT") {
+		config.WebSocketPort = settings.WebSocketPort
+	}
+	if settings.TLSCert != "" && !isSet("tcp-tls-cert", "OPENTRAIL_TCP_TLS_CERT") && !isSet("tcp-tls-key", "OPENTRAIL_TCP_TLS_KEY") {
+		config.TCPTLSCert = settings.TLSCert
+		config.TCPTLSKey = settings.TLSKey
+	}
+}
+
+// explicitlySet reports whether an option was given as a flag of fs or an environment variable,
+// either of which takes precedence over the setup file
+func explicitlySet(fs *flag.FlagSet) func(name, env string) bool {
+	flags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = true
+	})
+	return func(name, env string) bool {
+		return flags[name] || os.Getenv(env) != ""
+	}
+}
+
+// SetupStore validates the settings chosen in the setup wizard against the loaded configuration
+// and stores them in its setup file
+type SetupStore struct {
+	config *types.Config
+}
+
+// NewSetupStore creates a setup store for a loaded configuration
+func NewSetupStore(config *types.Config) *SetupStore {
+	return &SetupStore{config: config}
+}
+
+// Validate checks the settings without changing the configuration
+func (s *SetupStore) Validate(settings types.SetupSettings) error {
+	candidate := *s.config
+	return ApplySetup(&candidate, settings)
+}
+
+// Save writes the settings to the setup file
+func (s *SetupStore) Save(settings types.SetupSettings) error {
+	return SaveSetupFile(s.config.SetupFile, settings)
+}
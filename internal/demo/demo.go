@@ -0,0 +1,297 @@
+// Package demo generates realistic sample logs for showcasing the web interface and APIs without
+// wiring real senders
+package demo
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+)
+
+const (
+	// Entries is how many entries Seed stores
+	Entries = 2000
+	// Period is how far back the seeded entries reach
+	Period = 24 * time.Hour
+	// incidentShare is the share of entries that belong to the incident, an outage of the
+	// inventory service a few hours ago that gives the volume chart and alerts something to show
+	incidentShare = 0.08
+)
+
+// Syslog facilities and severities used by the sample senders
+const (
+	facilityAuthpriv = 10
+	facilityCron     = 9
+	facilityDaemon   = 3
+	facilityLocal0   = 16
+
+	severityError   = 3
+	severityWarning = 4
+	severityNotice  = 5
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// line is a generated message before it is dated
+type line struct {
+	facility, severity       int
+	host, app, procID, msgID string
+	sd                       string
+	message                  string
+}
+
+// sender generates the messages of one application
+type sender struct {
+	weight int
+	next   func(rng *rand.Rand) line
+}
+
+var (
+	paths     = []string{"/api/orders", "/api/orders/%d", "/api/customers/%d", "/api/cart", "/api/checkout", "/healthz", "/static/app.js"}
+	customers = []string{"acme", "globex", "initech", "umbrella", "hooli"}
+	jobs      = []string{"send-invoice", "resize-image", "sync-inventory", "export-report"}
+)
+
+// pick returns one of items
+func pick[T any](rng *rand.Rand, items []T) T {
+	return items[rng.Intn(len(items))]
+}
+
+func requestID(rng *rand.Rand) string {
+	return fmt.Sprintf("%08x", rng.Uint32())
+}
+
+var senders = []sender{
+	{weight: 40, next: func(rng *rand.Rand) line {
+		path := pick(rng, paths)
+		if strings.Contains(path, "%d") {
+			path = fmt.Sprintf(path, 1000+rng.Intn(9000))
+		}
+		method := pick(rng, []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"})
+		status, severity := 200, severityInfo
+		switch n := rng.Intn(100); {
+		case n < 3:
+			status, severity = 502, severityError
+		case n < 10:
+			status, severity = pick(rng, []int{400, 401, 404, 429}), severityWarning
+		case method == "POST":
+			status = 201
+		}
+		duration := 5 + rng.Intn(120)
+		client := fmt.Sprintf("203.0.113.%d", 1+rng.Intn(254))
+		return line{
+			facility: facilityLocal0, severity: severity,
+			host: pick(rng, []string{"web-01", "web-02"}), app: "nginx", procID: "1187", msgID: "access",
+			sd: fmt.Sprintf(`[http@32473 method="%s" path="%s" status="%d" duration_ms="%d" client_ip="%s"]`,
+				method, path, status, duration, client),
+			message: fmt.Sprintf(`%s - - "%s %s HTTP/1.1" %d %d "-" "Mozilla/5.0"`, client, method, path, status, 200+rng.Intn(20000)),
+		}
+	}},
+	{weight: 25, next: func(rng *rand.Rand) line {
+		order := 10000 + rng.Intn(90000)
+		customer := pick(rng, customers)
+		l := line{
+			facility: facilityLocal0, host: "api-01", app: "api", procID: "2214",
+			sd: fmt.Sprintf(`[trace@32473 request_id="%s" customer="%s"]`, requestID(rng), customer),
+		}
+		switch n := rng.Intn(100); {
+		case n < 4:
+			l.severity, l.msgID = severityError, "payment"
+			l.message = fmt.Sprintf("Payment provider returned an error for order %d: card_declined", order)
+		case n < 12:
+			l.severity, l.msgID = severityWarning, "cart"
+			l.message = fmt.Sprintf("Cart of customer %s holds %d items that are out of stock", customer, 1+rng.Intn(3))
+		case n < 25:
+			l.severity, l.msgID = severityDebug, "cache"
+			l.message = fmt.Sprintf("Cache miss for product %d, loaded in %dms", rng.Intn(500), 2+rng.Intn(40))
+		default:
+			l.severity, l.msgID = severityInfo, "order"
+			l.message = fmt.Sprintf("Order %d created for customer %s, total %d.%02d EUR", order, customer, 5+rng.Intn(400), rng.Intn(100))
+		}
+		return l
+	}},
+	{weight: 10, next: func(rng *rand.Rand) line {
+		l := line{facility: facilityDaemon, host: "db-01", app: "postgres", procID: "812"}
+		switch n := rng.Intn(100); {
+		case n < 2:
+			l.severity, l.msgID = severityError, "deadlock"
+			l.message = "ERROR: deadlock detected; process waits for ShareLock on transaction"
+		case n < 30:
+			l.severity, l.msgID = severityWarning, "slow"
+			l.sd = fmt.Sprintf(`[db@32473 duration_ms="%d" table="orders"]`, 500+rng.Intn(4000))
+			l.message = "LOG: duration exceeded log_min_duration_statement: SELECT * FROM orders WHERE customer_id = $1"
+		default:
+			l.severity, l.msgID = severityInfo, "checkpoint"
+			l.message = fmt.Sprintf("LOG: checkpoint complete: wrote %d buffers (%.1f%%)", 100+rng.Intn(4000), rng.Float64()*10)
+		}
+		return l
+	}},
+	{weight: 15, next: func(rng *rand.Rand) line {
+		job := pick(rng, jobs)
+		id := requestID(rng)
+		l := line{
+			facility: facilityLocal0, host: "worker-01", app: "worker", procID: "3301",
+			sd: fmt.Sprintf(`[job@32473 name="%s" id="%s"]`, job, id),
+		}
+		switch n := rng.Intn(100); {
+		case n < 3:
+			l.severity, l.msgID = severityError, "failed"
+			l.message = fmt.Sprintf("Job %s %s failed permanently after 5 attempts", job, id)
+		case n < 10:
+			l.severity, l.msgID = severityWarning, "retry"
+			l.message = fmt.Sprintf("Retrying job %s %s (attempt %d/5)", job, id, 2+rng.Intn(3))
+		default:
+			l.severity, l.msgID = severityInfo, "done"
+			l.message = fmt.Sprintf("Processed job %s %s in %dms", job, id, 20+rng.Intn(3000))
+		}
+		return l
+	}},
+	{weight: 6, next: func(rng *rand.Rand) line {
+		port := 30000 + rng.Intn(30000)
+		if rng.Intn(3) == 0 {
+			return line{
+				facility: facilityAuthpriv, severity: severityNotice, host: "bastion", app: "sshd", procID: "602", msgID: "login",
+				message: fmt.Sprintf("Accepted publickey for deploy from 192.0.2.%d port %d ssh2", 10+rng.Intn(5), port),
+			}
+		}
+		return line{
+			facility: facilityAuthpriv, severity: severityWarning, host: "bastion", app: "sshd", procID: "602", msgID: "login",
+			message: fmt.Sprintf("Failed password for invalid user %s from 198.51.100.%d port %d ssh2",
+				pick(rng, []string{"admin", "root", "test", "oracle"}), 1+rng.Intn(254), port),
+		}
+	}},
+	{weight: 4, next: func(rng *rand.Rand) line {
+		return line{
+			facility: facilityCron, severity: severityInfo, host: pick(rng, []string{"web-01", "web-02", "db-01"}),
+			app: "CRON", procID: "-", msgID: "-",
+			message: pick(rng, []string{"(root) CMD (/usr/local/bin/backup.sh)", "(root) CMD (logrotate /etc/logrotate.conf)", "(www-data) CMD (php /srv/app/artisan schedule:run)"}),
+		}
+	}},
+}
+
+// incident generates the messages of the inventory service outage
+func incident(rng *rand.Rand) line {
+	if rng.Intn(3) == 0 {
+		return line{
+			facility: facilityLocal0, severity: severityError, host: pick(rng, []string{"web-01", "web-02"}),
+			app: "nginx", procID: "1187", msgID: "access",
+			sd:      fmt.Sprintf(`[http@32473 method="POST" path="/api/checkout" status="504" duration_ms="%d"]`, 30000+rng.Intn(100)),
+			message: `upstream timed out (110: Connection timed out) while reading response header from upstream, request: "POST /api/checkout HTTP/1.1"`,
+		}
+	}
+	return line{
+		facility: facilityLocal0, severity: severityError, host: "api-01", app: "api", procID: "2214", msgID: "inventory",
+		sd:      fmt.Sprintf(`[trace@32473 request_id="%s" upstream="inventory"]`, requestID(rng)),
+		message: fmt.Sprintf("Timeout calling inventory service after 5000ms (attempt %d/3)", 1+rng.Intn(3)),
+	}
+}
+
+// Lines generates count RFC5424 messages spread over period before now, oldest first. The same rng
+// seed generates the same messages.
+func Lines(rng *rand.Rand, now time.Time, period time.Duration, count int) []string {
+	totalWeight := 0
+	for _, s := range senders {
+		totalWeight += s.weight
+	}
+
+	// The outage lasts 20 minutes and started at a quarter of the period before now
+	incidentStart := now.Add(-period / 4)
+	incidentLength := 20 * time.Minute
+
+	type dated struct {
+		at   time.Time
+		line line
+	}
+	entries := make([]dated, 0, count)
+	for i := 0; i < count; i++ {
+		if rng.Float64() < incidentShare {
+			at := incidentStart.Add(time.Duration(rng.Int63n(int64(incidentLength))))
+			entries = append(entries, dated{at, incident(rng)})
+			continue
+		}
+		at := now.Add(-time.Duration(rng.Int63n(int64(period))))
+		n := rng.Intn(totalWeight)
+		for _, s := range senders {
+			if n < s.weight {
+				entries = append(entries, dated{at, s.next(rng)})
+				break
+			}
+			n -= s.weight
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		sd := e.line.sd
+		if sd == "" {
+			sd = "-"
+		}
+		lines[i] = fmt.Sprintf("<%d>1 %s %s %s %s %s %s %s",
+			e.line.facility*8+e.line.severity, e.at.UTC().Format(time.RFC3339Nano),
+			e.line.host, e.line.app, e.line.procID, e.line.msgID, sd, e.line.message)
+	}
+	return lines
+}
+
+// Seed stores sample entries covering the Period before now, unless storage already holds entries
+// so that real logs are never mixed with generated ones. It returns how many entries were stored,
+// waiting until they are written when storage reports commits.
+func Seed(parser interfaces.LogParser, storage interfaces.LogStorage, now time.Time) (int, error) {
+	existing, err := storage.GetRecent(1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for existing entries: %w", err)
+	}
+	if len(existing) > 0 {
+		return 0, nil
+	}
+
+	notifier, notifies := storage.(interfaces.CommitNotifier)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var writeErr error
+
+	rng := rand.New(rand.NewSource(now.UnixNano()))
+	stored := 0
+	for _, raw := range Lines(rng, now, Period, Entries) {
+		entry, err := parser.Parse(raw)
+		if err != nil {
+			return stored, fmt.Errorf("failed to parse sample entry %q: %w", raw, err)
+		}
+		entry.Raw = raw
+		entry.ReceivedAt = entry.Timestamp
+
+		if !notifies {
+			err = storage.Store(entry)
+		} else {
+			wg.Add(1)
+			err = notifier.StoreNotify(entry, func(err error) {
+				defer wg.Done()
+				if err != nil {
+					mu.Lock()
+					writeErr = err
+					mu.Unlock()
+				}
+			})
+			if err != nil {
+				wg.Done()
+			}
+		}
+		if err != nil {
+			wg.Wait()
+			return stored, fmt.Errorf("failed to store sample entry: %w", err)
+		}
+		stored++
+	}
+
+	wg.Wait()
+	if writeErr != nil {
+		return stored, fmt.Errorf("failed to store sample entries: %w", writeErr)
+	}
+	return stored, nil
+}
@@ -0,0 +1,70 @@
+package demo
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"opentrail/internal/parser"
+	"opentrail/internal/storage"
+	"opentrail/internal/types"
+)
+
+func TestLines(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lines := Lines(rand.New(rand.NewSource(1)), now, Period, 500)
+	if len(lines) != 500 {
+		t.Fatalf("Expected 500 lines, got %d", len(lines))
+	}
+
+	logParser := parser.NewRFC5424Parser(true)
+	hosts := make(map[string]bool)
+	var previous time.Time
+	for _, line := range lines {
+		entry, err := logParser.Parse(line)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", line, err)
+		}
+		if entry.Timestamp.Before(previous) || entry.Timestamp.After(now) || entry.Timestamp.Before(now.Add(-Period)) {
+			t.Fatalf("Expected ordered timestamps within the period, got %v after %v", entry.Timestamp, previous)
+		}
+		previous = entry.Timestamp
+		hosts[entry.Hostname] = true
+	}
+	if len(hosts) < 4 {
+		t.Errorf("Expected entries of several hosts, got %v", hosts)
+	}
+
+	again := Lines(rand.New(rand.NewSource(1)), now, Period, 500)
+	if again[0] != lines[0] || again[499] != lines[499] {
+		t.Error("Expected the same seed to generate the same lines")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	store, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "demo.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	logParser := parser.NewRFC5424Parser(true)
+	stored, err := Seed(logParser, store, time.Now())
+	if err != nil {
+		t.Fatalf("Seed() failed: %v", err)
+	}
+	if stored != Entries {
+		t.Errorf("Expected %d entries to be stored, got %d", Entries, stored)
+	}
+	entries, err := store.Search(types.SearchQuery{Limit: 10})
+	if err != nil || len(entries) != 10 {
+		t.Fatalf("Expected stored entries, got %d: %v", len(entries), err)
+	}
+
+	// A database that already holds entries is left alone
+	stored, err = Seed(logParser, store, time.Now())
+	if err != nil || stored != 0 {
+		t.Errorf("Expected no entries to be added to a non-empty database, got %d: %v", stored, err)
+	}
+}
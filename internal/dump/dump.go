@@ -0,0 +1,337 @@
+// Package dump writes every entry of a database snapshot to NDJSON or Parquet files partitioned by
+// time, for migrating to other systems. It backs the "opentrail dump" subcommand.
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"opentrail/internal/parquetlog"
+	"opentrail/internal/types"
+)
+
+// ManifestFile is the name of the file describing a dump, written once all partitions are complete
+const ManifestFile = "manifest.json"
+
+// DefaultProgressInterval is the default number of entries between progress reports
+const DefaultProgressInterval = 100000
+
+// Format selects the file format of a dump
+type Format string
+
+const (
+	// FormatNDJSON writes one JSON encoded log entry per line
+	FormatNDJSON Format = "ndjson"
+	// FormatParquet writes Parquet files with the columns of package parquetlog
+	FormatParquet Format = "parquet"
+)
+
+// Partition selects how entries are split into files
+type Partition string
+
+const (
+	// PartitionDay writes one file per UTC day
+	PartitionDay Partition = "day"
+	// PartitionHour writes one file per UTC hour
+	PartitionHour Partition = "hour"
+)
+
+// layout returns the time layout naming the files of a partition
+func (p Partition) layout() string {
+	if p == PartitionHour {
+		return "2006-01-02T15"
+	}
+	return "2006-01-02"
+}
+
+// Source is a consistent view of the entries to dump, such as a storage.Snapshot
+type Source interface {
+	// Count returns the number of entries with timestamps in the given bounds
+	Count(startTime, endTime *time.Time) (int64, error)
+	// Entries calls fn for every entry with a timestamp in the given bounds, in timestamp order
+	Entries(startTime, endTime *time.Time, fn func(entry *types.LogEntry) error) error
+}
+
+// Options configure a dump
+type Options struct {
+	// StartTime and EndTime bound the timestamps of the entries dumped; nil leaves a side open
+	StartTime *time.Time
+	EndTime   *time.Time
+	// Format is the file format, NDJSON unless set
+	Format Format
+	// Partition splits entries into files, by day unless set
+	Partition Partition
+	// Compress gzips every NDJSON file; Parquet files are always compressed
+	Compress bool
+	// StructuredKeys are the structured data keys flattened into columns of Parquet files
+	StructuredKeys []string
+	// ProgressInterval is the number of entries between calls to Progress
+	ProgressInterval int
+	// Progress, if set, is called periodically and when the dump is complete
+	Progress func(Progress)
+}
+
+// Progress reports how far a dump has come
+type Progress struct {
+	Entries int64
+	Total   int64
+	Files   int
+	Elapsed time.Duration
+}
+
+// FileInfo describes one file of a dump
+type FileInfo struct {
+	// Name is the file name relative to the output directory
+	Name    string    `json:"name"`
+	Entries int64     `json:"entries"`
+	First   time.Time `json:"first_timestamp"`
+	Last    time.Time `json:"last_timestamp"`
+}
+
+// Manifest describes a complete dump. Its presence marks the dump as complete.
+type Manifest struct {
+	Format    Format     `json:"format"`
+	Partition Partition  `json:"partition"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Entries   int64      `json:"entries"`
+	Files     []FileInfo `json:"files"`
+	// StructuredColumns maps the flattened structured data keys of Parquet files to their columns
+	StructuredColumns []parquetlog.Column `json:"structured_columns,omitempty"`
+}
+
+// Write dumps the entries of source to dir, one file per partition followed by the manifest. dir is
+// created if needed and must not contain files of an earlier dump.
+func Write(source Source, dir string, opts Options) (*Manifest, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = FormatNDJSON
+	case FormatNDJSON:
+	case FormatParquet:
+		if opts.Compress {
+			return nil, fmt.Errorf("parquet files are compressed internally and cannot be gzipped")
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.Format)
+	}
+	switch opts.Partition {
+	case "":
+		opts.Partition = PartitionDay
+	case PartitionDay, PartitionHour:
+	default:
+		return nil, fmt.Errorf("unknown partition %q", opts.Partition)
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = DefaultProgressInterval
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	existing, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("output directory %s is not empty", dir)
+	}
+
+	total, err := source.Count(opts.StartTime, opts.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	manifest := &Manifest{
+		Format:    opts.Format,
+		Partition: opts.Partition,
+		StartTime: opts.StartTime,
+		EndTime:   opts.EndTime,
+		CreatedAt: started,
+		Files:     []FileInfo{},
+	}
+	if opts.Format == FormatParquet {
+		manifest.StructuredColumns = parquetlog.Columns(opts.StructuredKeys)
+	}
+	ext := "." + string(opts.Format)
+	if opts.Compress {
+		ext += ".gz"
+	}
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(Progress{Entries: manifest.Entries, Total: total, Files: len(manifest.Files), Elapsed: time.Since(started)})
+		}
+	}
+
+	// Timestamps are ordered as stored, so entries with other UTC offsets may return to a partition
+	// already written. NDJSON files are then appended to; as a complete Parquet file cannot be, the
+	// partition gets another one, numbered from 2 (2024-03-01-2.parquet).
+	var current *partitionWriter
+	written := make(map[string]int)
+	parts := make(map[string]int)
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		err := current.close()
+		if i, ok := written[current.info.Name]; ok {
+			manifest.Files[i] = current.info
+		} else {
+			written[current.info.Name] = len(manifest.Files)
+			manifest.Files = append(manifest.Files, current.info)
+		}
+		current = nil
+		return err
+	}
+
+	err = source.Entries(opts.StartTime, opts.EndTime, func(entry *types.LogEntry) error {
+		partition := entry.Timestamp.UTC().Format(opts.Partition.layout())
+		if current == nil || current.partition != partition {
+			if err := finish(); err != nil {
+				return err
+			}
+			info := FileInfo{Name: partition + ext}
+			if i, ok := written[info.Name]; ok {
+				if opts.Format == FormatParquet {
+					info.Name = partition + "-" + strconv.Itoa(parts[partition]+1) + ext
+				} else {
+					info = manifest.Files[i]
+				}
+			}
+			var err error
+			if current, err = openPartition(dir, partition, info, opts); err != nil {
+				return err
+			}
+			if info.Entries == 0 {
+				parts[partition]++
+			}
+		}
+
+		if err := current.write(entry); err != nil {
+			return err
+		}
+		manifest.Entries++
+		if manifest.Entries%int64(opts.ProgressInterval) == 0 {
+			report()
+		}
+		return nil
+	})
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	report()
+	return manifest, nil
+}
+
+// partitionWriter writes the entries of one partition
+type partitionWriter struct {
+	partition string
+	info      FileInfo
+	file      *os.File
+	gz        *gzip.Writer
+	buffered  *bufio.Writer
+	encoder   *json.Encoder
+	parquet   *parquetlog.Writer
+}
+
+// openPartition opens a file of a partition, appending when it was written before. Appended gzip
+// files hold several members, which gzip readers decompress as one stream.
+func openPartition(dir, partition string, info FileInfo, opts Options) (*partitionWriter, error) {
+	file, err := os.OpenFile(filepath.Join(dir, info.Name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", info.Name, err)
+	}
+
+	w := &partitionWriter{partition: partition, info: info, file: file}
+	var out io.Writer = file
+	if opts.Compress {
+		w.gz = gzip.NewWriter(file)
+		out = w.gz
+	}
+	w.buffered = bufio.NewWriterSize(out, 256*1024)
+	if opts.Format == FormatParquet {
+		if w.parquet, err = parquetlog.NewWriter(w.buffered, opts.StructuredKeys); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return w, nil
+	}
+	w.encoder = json.NewEncoder(w.buffered)
+	w.encoder.SetEscapeHTML(false)
+	return w, nil
+}
+
+func (w *partitionWriter) write(entry *types.LogEntry) error {
+	if w.parquet != nil {
+		if err := w.parquet.Write(entry); err != nil {
+			return fmt.Errorf("failed to write %s: %w", w.info.Name, err)
+		}
+	} else if err := w.encoder.Encode(entry); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.info.Name, err)
+	}
+	if w.info.Entries == 0 || entry.Timestamp.Before(w.info.First) {
+		w.info.First = entry.Timestamp
+	}
+	if w.info.Entries == 0 || entry.Timestamp.After(w.info.Last) {
+		w.info.Last = entry.Timestamp
+	}
+	w.info.Entries++
+	return nil
+}
+
+// close flushes and syncs the file, so the manifest only lists complete files
+func (w *partitionWriter) close() error {
+	var err error
+	if w.parquet != nil {
+		err = w.parquet.Close()
+	}
+	if flushErr := w.buffered.Flush(); err == nil {
+		err = flushErr
+	}
+	if w.gz != nil {
+		if closeErr := w.gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.info.Name, err)
+	}
+	return nil
+}
+
+// writeManifest writes the manifest through a temporary file, so it never appears half written
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, ManifestFile)); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
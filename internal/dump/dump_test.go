@@ -0,0 +1,267 @@
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"opentrail/internal/storage"
+	"opentrail/internal/types"
+)
+
+func createDatabase(t *testing.T, timestamps ...time.Time) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs.db")
+	logStorage, err := storage.NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer logStorage.Close()
+
+	for i, ts := range timestamps {
+		entry := &types.LogEntry{
+			Severity:       6,
+			Version:        1,
+			Timestamp:      ts,
+			Hostname:       "web-1",
+			AppName:        "app",
+			Message:        "entry " + string(rune('a'+i)),
+			StructuredData: map[string]interface{}{"req": map[string]string{"id": "42"}},
+		}
+		if err := logStorage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+	return path
+}
+
+// storeLegacyTimestamps rewrites the timestamps of the entries of createDatabase the way databases
+// written before timestamps were stored in UTC hold them, in the zone they arrived with, until the
+// storage migrates them on startup
+func storeLegacyTimestamps(t *testing.T, dbPath string, timestamps ...time.Time) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	for i, ts := range timestamps {
+		if _, err := db.Exec("UPDATE logs SET timestamp = ? WHERE message = ?", ts, "entry "+string(rune('a'+i))); err != nil {
+			t.Fatalf("Failed to store legacy timestamp: %v", err)
+		}
+	}
+}
+
+func readPartition(t *testing.T, path string, compressed bool) []types.LogEntry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		scanner = bufio.NewScanner(gz)
+	}
+	var entries []types.LogEntry
+	for scanner.Scan() {
+		var entry types.LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid line in %s: %v", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWrite_PartitionsByDay(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 2, 9, 30, 0, 0, time.UTC)
+	dbPath := createDatabase(t, day2, day1, day1.Add(time.Hour), day2.Add(time.Hour))
+
+	snapshot, err := storage.OpenSnapshot(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	var progress []Progress
+	dir := filepath.Join(t.TempDir(), "dump")
+	manifest, err := Write(snapshot, dir, Options{
+		Compress:         true,
+		ProgressInterval: 2,
+		Progress:         func(p Progress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if manifest.Entries != 4 || len(manifest.Files) != 2 {
+		t.Fatalf("Expected 4 entries in 2 files, got %d in %d", manifest.Entries, len(manifest.Files))
+	}
+	if manifest.Files[0].Name != "2024-03-01.ndjson.gz" || manifest.Files[1].Name != "2024-03-02.ndjson.gz" {
+		t.Errorf("Unexpected file names %q and %q", manifest.Files[0].Name, manifest.Files[1].Name)
+	}
+	if !manifest.Files[0].First.Equal(day1) || !manifest.Files[0].Last.Equal(day1.Add(time.Hour)) {
+		t.Errorf("Unexpected bounds of the first file: %v to %v", manifest.Files[0].First, manifest.Files[0].Last)
+	}
+
+	entries := readPartition(t, filepath.Join(dir, manifest.Files[0].Name), true)
+	if len(entries) != 2 || entries[0].Message != "entry b" || entries[1].Message != "entry c" {
+		t.Fatalf("Expected entries b and c in timestamp order, got %+v", entries)
+	}
+	if req, ok := entries[0].StructuredData["req"].(map[string]interface{}); !ok || req["id"] != "42" {
+		t.Errorf("Expected structured data to be kept, got %v", entries[0].StructuredData)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	var written Manifest
+	if err := json.Unmarshal(data, &written); err != nil || written.Entries != 4 {
+		t.Errorf("Unexpected manifest %s: %v", data, err)
+	}
+
+	if len(progress) != 3 || progress[len(progress)-1].Entries != 4 || progress[0].Total != 4 {
+		t.Errorf("Expected progress after 2 and 4 entries and on completion, got %+v", progress)
+	}
+}
+
+func TestWrite_TimeBoundsAndHourlyPartitions(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	dbPath := createDatabase(t, base, base.Add(30*time.Minute), base.Add(time.Hour), base.Add(3*time.Hour))
+
+	snapshot, err := storage.OpenSnapshot(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	start, end := base.Add(time.Minute), base.Add(2*time.Hour)
+	dir := t.TempDir()
+	manifest, err := Write(snapshot, dir, Options{StartTime: &start, EndTime: &end, Partition: PartitionHour})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if manifest.Entries != 2 || len(manifest.Files) != 2 {
+		t.Fatalf("Expected 2 entries in 2 files, got %d in %d", manifest.Entries, len(manifest.Files))
+	}
+	if manifest.Files[0].Name != "2024-03-01T10.ndjson" || manifest.Files[1].Name != "2024-03-01T11.ndjson" {
+		t.Errorf("Unexpected file names %q and %q", manifest.Files[0].Name, manifest.Files[1].Name)
+	}
+	if entries := readPartition(t, filepath.Join(dir, "2024-03-01T10.ndjson"), false); len(entries) != 1 || entries[0].Message != "entry b" {
+		t.Errorf("Expected only entry b in the first hour, got %+v", entries)
+	}
+}
+
+func TestWrite_RefusesNonEmptyDirectory(t *testing.T) {
+	dbPath := createDatabase(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	snapshot, err := storage.OpenSnapshot(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(snapshot, dir, Options{}); err == nil {
+		t.Error("Expected an error for a directory holding an earlier dump")
+	}
+	if _, err := Write(snapshot, t.TempDir(), Options{Partition: "week"}); err == nil {
+		t.Error("Expected an error for an unknown partition")
+	}
+}
+
+func TestWrite_AppendsToRevisitedPartition(t *testing.T) {
+	// Stored with another offset by an older version, the last entry sorts after the second but belongs
+	// to the first day
+	first := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 3, 2, 0, 30, 0, 0, time.UTC)
+	third := time.Date(2024, 3, 2, 2, 0, 0, 0, time.FixedZone("PKT", 5*3600))
+	dbPath := createDatabase(t, first, second, third)
+	storeLegacyTimestamps(t, dbPath, first, second, third)
+
+	snapshot, err := storage.OpenSnapshot(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	dir := t.TempDir()
+	manifest, err := Write(snapshot, dir, Options{Compress: true})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(manifest.Files) != 2 || manifest.Files[0].Entries != 2 || manifest.Files[1].Entries != 1 {
+		t.Fatalf("Expected 2 entries on the first day and 1 on the second, got %+v", manifest.Files)
+	}
+	if !manifest.Files[0].First.Equal(third) {
+		t.Errorf("Expected the first day to start at %v, got %v", third, manifest.Files[0].First)
+	}
+	if entries := readPartition(t, filepath.Join(dir, manifest.Files[0].Name), true); len(entries) != 2 {
+		t.Errorf("Expected both entries of the first day to be readable, got %d", len(entries))
+	}
+}
+
+func TestWrite_Parquet(t *testing.T) {
+	first := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 3, 2, 0, 30, 0, 0, time.UTC)
+	third := time.Date(2024, 3, 2, 2, 0, 0, 0, time.FixedZone("PKT", 5*3600))
+	dbPath := createDatabase(t, first, second, third)
+	storeLegacyTimestamps(t, dbPath, first, second, third)
+
+	snapshot, err := storage.OpenSnapshot(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	dir := t.TempDir()
+	manifest, err := Write(snapshot, dir, Options{Format: FormatParquet, StructuredKeys: []string{"req.id"}})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// The first day is revisited after the second, and a Parquet file cannot be appended to
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	if len(names) != 3 || names[0] != "2024-03-01.parquet" || names[1] != "2024-03-02.parquet" || names[2] != "2024-03-01-2.parquet" {
+		t.Fatalf("Unexpected files %v", names)
+	}
+	if len(manifest.StructuredColumns) != 1 || manifest.StructuredColumns[0].Name != "sd_req_id" {
+		t.Errorf("Expected the column mapping in the manifest, got %+v", manifest.StructuredColumns)
+	}
+
+	type row struct {
+		Message   string  `parquet:"message"`
+		RequestID *string `parquet:"sd_req_id,optional"`
+	}
+	rows, err := parquet.ReadFile[row](filepath.Join(dir, names[2]))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", names[2], err)
+	}
+	if len(rows) != 1 || rows[0].Message != "entry c" || rows[0].RequestID == nil || *rows[0].RequestID != "42" {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+
+	if _, err := Write(snapshot, t.TempDir(), Options{Format: FormatParquet, Compress: true}); err == nil {
+		t.Error("Expected an error for gzipped parquet files")
+	}
+}
@@ -0,0 +1,139 @@
+// Package entryid generates globally unique, time-sortable identifiers for log entries. SQLite
+// numbers entries by row, which only orders and identifies them within one database; the
+// identifiers generated here stay unique when entries of several nodes or imported archives are
+// merged, and sort by the time of the entry.
+package entryid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schemes of entry identifiers, the values of the entry-ids option
+const (
+	// SchemeAutoIncrement leaves entries identified by their row ID alone
+	SchemeAutoIncrement = "autoincrement"
+	// SchemeULID identifies entries by a ULID: a millisecond timestamp and 80 random bits in 26
+	// Crockford base32 characters
+	SchemeULID = "ulid"
+	// SchemeKSUID identifies entries by a KSUID: a second timestamp and 128 random bits in 27
+	// base62 characters
+	SchemeKSUID = "ksuid"
+)
+
+// Schemes lists the valid schemes
+var Schemes = []string{SchemeAutoIncrement, SchemeULID, SchemeKSUID}
+
+const (
+	// crockford is the ULID alphabet, in ascending byte order
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// base62 is the KSUID alphabet, in ascending byte order
+	base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// ksuidEpoch is the start of the KSUID timestamp, 2014-05-13 16:53:20 UTC
+	ksuidEpoch = 1400000000
+	// maxULIDTime is the last millisecond a ULID can hold
+	maxULIDTime = 1<<48 - 1
+)
+
+// Generator creates the identifiers of one scheme. It is safe for concurrent use.
+type Generator struct {
+	scheme string
+
+	mu sync.Mutex
+	// lastTime and lastRandom are those of the previous ULID, incremented for a ULID of the same
+	// millisecond so that identifiers generated in a row sort in that order
+	lastTime   uint64
+	lastRandom [10]byte
+}
+
+// New returns the generator of a scheme, or nil for SchemeAutoIncrement and the empty scheme
+func New(scheme string) (*Generator, error) {
+	switch scheme {
+	case "", SchemeAutoIncrement:
+		return nil, nil
+	case SchemeULID, SchemeKSUID:
+		return &Generator{scheme: scheme}, nil
+	}
+	return nil, fmt.Errorf("unknown entry ID scheme %q, expected one of %s", scheme, strings.Join(Schemes, ", "))
+}
+
+// Scheme returns the scheme of the generator
+func (g *Generator) Scheme() string {
+	return g.scheme
+}
+
+// Next returns a new identifier for an entry of time t. Times the scheme cannot represent are
+// clamped, so an entry dated before 2014 gets a KSUID of the KSUID epoch.
+func (g *Generator) Next(t time.Time) string {
+	if g.scheme == SchemeKSUID {
+		return ksuid(t)
+	}
+	return g.ulid(t)
+}
+
+// ulid returns a ULID of time t, monotonic within a millisecond
+func (g *Generator) ulid(t time.Time) string {
+	ms := uint64(min(max(t.UnixMilli(), 0), maxULIDTime))
+
+	g.mu.Lock()
+	if ms != g.lastTime || !increment(g.lastRandom[:]) {
+		g.lastTime = ms
+		rand.Read(g.lastRandom[:])
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], g.lastRandom[:])
+	g.mu.Unlock()
+
+	// 128 bits in 26 characters of 5 bits, the first holding the 3 most significant bits
+	var text [26]byte
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	for i := len(text) - 1; i >= 0; i-- {
+		text[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(text[:])
+}
+
+// increment adds one to a big-endian number, reporting false when it overflows
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ksuid returns a KSUID of time t
+func ksuid(t time.Time) string {
+	var id [20]byte
+	seconds := min(max(t.Unix()-ksuidEpoch, 0), 1<<32-1)
+	binary.BigEndian.PutUint32(id[:4], uint32(seconds))
+	rand.Read(id[4:])
+
+	// Divide the 160-bit number by 62 repeatedly, as 5 big-endian 32-bit words
+	var words [5]uint32
+	for i := range words {
+		words[i] = binary.BigEndian.Uint32(id[i*4:])
+	}
+	var text [27]byte
+	for i := len(text) - 1; i >= 0; i-- {
+		var remainder uint64
+		for j := range words {
+			value := remainder<<32 | uint64(words[j])
+			words[j] = uint32(value / 62)
+			remainder = value % 62
+		}
+		text[i] = base62[remainder]
+	}
+	return string(text[:])
+}
@@ -0,0 +1,74 @@
+package entryid
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	for _, scheme := range []string{"", SchemeAutoIncrement} {
+		if generator, err := New(scheme); generator != nil || err != nil {
+			t.Errorf("New(%q) = %v, %v, want no generator", scheme, generator, err)
+		}
+	}
+	if _, err := New("uuid"); err == nil {
+		t.Error("Expected an unknown scheme to be rejected")
+	}
+}
+
+func TestGenerator_ULID(t *testing.T) {
+	generator, _ := New(SchemeULID)
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	id := generator.Next(at)
+	if len(id) != 26 || strings.Trim(id, crockford) != "" {
+		t.Fatalf("Expected 26 Crockford base32 characters, got %q", id)
+	}
+	// The first 10 characters encode the millisecond timestamp
+	if id[:10] != "01HQWY5CG0" {
+		t.Errorf("Expected the timestamp prefix 01HQWY5CG0, got %q", id[:10])
+	}
+
+	// Identifiers of the same millisecond sort in the order they were generated
+	ids := []string{id}
+	for i := 0; i < 100; i++ {
+		ids = append(ids, generator.Next(at))
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("Expected identifiers of the same millisecond to increase")
+	}
+
+	// Later entries sort after earlier ones, whatever order they arrive in
+	later := generator.Next(at.Add(time.Millisecond))
+	earlier := generator.Next(at.Add(-time.Hour))
+	if !(earlier < id && id < later) {
+		t.Errorf("Expected %s < %s < %s", earlier, id, later)
+	}
+}
+
+func TestGenerator_KSUID(t *testing.T) {
+	generator, _ := New(SchemeKSUID)
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := generator.Next(at)
+		if len(id) != 27 || strings.Trim(id, base62) != "" {
+			t.Fatalf("Expected 27 base62 characters, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate identifier %s", id)
+		}
+		seen[id] = true
+	}
+
+	if earlier, later := generator.Next(at), generator.Next(at.Add(time.Second)); earlier >= later {
+		t.Errorf("Expected %s to sort before %s", earlier, later)
+	}
+	// Times before the KSUID epoch are clamped rather than wrapping around
+	if old, id := generator.Next(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)), generator.Next(at); old >= id {
+		t.Errorf("Expected an entry of 2001 to sort before one of 2024, got %s and %s", old, id)
+	}
+}
@@ -0,0 +1,247 @@
+// Package fieldcrypt encrypts selected structured data values of log entries before they are
+// stored, with keys chosen by the tenant and app of each entry, so that the values are only readable
+// to those holding the keys.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+const (
+	// sealedPrefix starts every encrypted value, followed by the key ID and the base64 of the nonce
+	// and ciphertext
+	sealedPrefix = "enc:v1:"
+	// keySize is the length of the AES-256 keys in bytes
+	keySize = 32
+)
+
+// Unkeyed replaces the values of entries no key applies to; storing them unencrypted would defeat
+// the point of configuring the field
+const Unkeyed = "[NO ENCRYPTION KEY]"
+
+// Key is a data key of the keyring file. Tenant and AppName select the entries it applies to,
+// either left empty to apply to all.
+type Key struct {
+	ID      string `json:"id"`
+	Tenant  string `json:"tenant,omitempty"`
+	AppName string `json:"app_name,omitempty"`
+	// Key is the base64 of 32 random bytes
+	Key string `json:"key"`
+}
+
+// scope is the tenant and app a key applies to
+type scope struct {
+	tenant  string
+	appName string
+}
+
+// Keyring holds the data keys. The last key listed for a scope encrypts its entries; earlier ones
+// are kept to decrypt what they encrypted until a re-encryption job has moved it to the current key.
+type Keyring struct {
+	ciphers map[string]cipher.AEAD
+	current map[scope]string
+}
+
+// NewKeyring checks and loads data keys
+func NewKeyring(keys []Key) (*Keyring, error) {
+	ring := &Keyring{ciphers: make(map[string]cipher.AEAD), current: make(map[scope]string)}
+	for i, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("key %d: id is required and cannot contain a colon", i+1)
+		}
+		if _, ok := ring.ciphers[key.ID]; ok {
+			return nil, fmt.Errorf("key %d: duplicate id %q", i+1, key.ID)
+		}
+		secret, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil || len(secret) != keySize {
+			return nil, fmt.Errorf("key %q: key must be the base64 of %d bytes", key.ID, keySize)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		ring.ciphers[key.ID] = aead
+		ring.current[scope{tenant: key.Tenant, appName: key.AppName}] = key.ID
+	}
+	if len(ring.ciphers) == 0 {
+		return nil, fmt.Errorf("no keys defined")
+	}
+	return ring, nil
+}
+
+// LoadKeyring reads a JSON array of data keys with readFile
+func LoadKeyring(path string, readFile func(string) ([]byte, error)) (*Keyring, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption keys: %w", err)
+	}
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption keys %s: %w", path, err)
+	}
+	return NewKeyring(keys)
+}
+
+// currentKey returns the ID of the key encrypting the entries of a tenant and app: the most specific
+// scope defined wins, and tenant keys take precedence over app keys
+func (k *Keyring) currentKey(tenant, appName string) (string, bool) {
+	for _, candidate := range []scope{{tenant, appName}, {tenant, ""}, {"", appName}, {"", ""}} {
+		if id, ok := k.current[candidate]; ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Encryptor encrypts the configured fields of entries and decrypts them again
+type Encryptor struct {
+	// fields holds the encrypted "sdid.param" keys
+	fields map[string]bool
+	keys   *Keyring
+}
+
+// New creates the encryptor of the "sdid.param" fields with the keys of a keyring
+func New(fields []string, keys *Keyring) *Encryptor {
+	e := &Encryptor{fields: make(map[string]bool), keys: keys}
+	for _, field := range fields {
+		e.fields[field] = true
+	}
+	return e
+}
+
+// Sealed reports whether a value was encrypted by an encryptor
+func Sealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Seal encrypts the configured fields of an entry in place with the current key of its tenant and
+// app, and reports whether any value changed. Values already encrypted with another key are
+// re-encrypted, so the same call serves new entries and key rotation.
+func (e *Encryptor) Seal(entry *types.LogEntry) bool {
+	if entry == nil {
+		return false
+	}
+	keyID, keyed := e.keys.currentKey(entry.Metadata(types.TenantParam), entry.AppName)
+	encryptionMetrics := metrics.GetEncryptionMetrics()
+
+	changed := false
+	for sdid, element := range entry.StructuredData {
+		if sdid == types.MetadataSDID {
+			// The receiver's metadata selects the key
+			continue
+		}
+		e.forEachField(sdid, element, func(name, value string) (string, bool) {
+			if Sealed(value) {
+				sealedKey, plain, ok := e.open(name, value)
+				if !ok || !keyed || sealedKey == keyID {
+					// Values of removed keys are lost either way, and a value under a stale key is
+					// better kept than dropped when its scope has no key left
+					return value, false
+				}
+				value = plain
+			}
+			changed = true
+			if !keyed {
+				encryptionMetrics.Unkeyed.Inc()
+				return Unkeyed, true
+			}
+			encryptionMetrics.Encrypted.Inc()
+			return e.seal(keyID, name, value), true
+		})
+	}
+	return changed
+}
+
+// Decrypt returns the plaintext of a value of a field, or the value itself if it is not encrypted or
+// no key for it is left
+func (e *Encryptor) Decrypt(field, value string) string {
+	if !Sealed(value) {
+		return value
+	}
+	if _, plain, ok := e.open(field, value); ok {
+		return plain
+	}
+	metrics.GetEncryptionMetrics().DecryptFailures.Inc()
+	return value
+}
+
+// Fields returns the encrypted "sdid.param" keys
+func (e *Encryptor) Fields() []string {
+	fields := make([]string, 0, len(e.fields))
+	for field := range e.fields {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// forEachField replaces the string values of the configured parameters of an element with what
+// replace returns for them
+func (e *Encryptor) forEachField(sdid string, element interface{}, replace func(field, value string) (string, bool)) {
+	switch params := element.(type) {
+	case map[string]string:
+		for param, value := range params {
+			if field := sdid + "." + param; e.fields[field] {
+				if replaced, ok := replace(field, value); ok {
+					params[param] = replaced
+				}
+			}
+		}
+	case map[string]interface{}:
+		for param, value := range params {
+			s, isString := value.(string)
+			if field := sdid + "." + param; isString && e.fields[field] {
+				if replaced, ok := replace(field, s); ok {
+					params[param] = replaced
+				}
+			}
+		}
+	}
+}
+
+// seal encrypts a value of a field with a key. The field name is authenticated along with it, so a
+// value cannot be moved to another field unnoticed.
+func (e *Encryptor) seal(keyID, field, value string) string {
+	aead := e.keys.ciphers[keyID]
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return sealedPrefix + keyID + ":" + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// open decrypts a sealed value of a field, returning the ID of the key it was sealed with
+func (e *Encryptor) open(field, value string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", "", false
+	}
+	keyID, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", "", false
+	}
+	aead, ok := e.keys.ciphers[keyID]
+	if !ok {
+		return "", "", false
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", "", false
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", "", false
+	}
+	return keyID, string(plain), true
+}
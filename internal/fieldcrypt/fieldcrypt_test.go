@@ -0,0 +1,142 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+// testKey returns a base64 key of 32 copies of b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func testEntry(tenant, appName, token string) *types.LogEntry {
+	entry := &types.LogEntry{AppName: appName, StructuredData: map[string]interface{}{
+		"auth": map[string]interface{}{"token": token, "user": "alice"},
+	}}
+	if tenant != "" {
+		entry.SetMetadata(types.TenantParam, tenant)
+	}
+	return entry
+}
+
+func param(entry *types.LogEntry, sdid, name string) string {
+	value, _ := entry.StructuredData[sdid].(map[string]interface{})[name].(string)
+	return value
+}
+
+func TestNewKeyring(t *testing.T) {
+	for name, keys := range map[string][]Key{
+		"empty":        nil,
+		"missing id":   {{Key: testKey('a')}},
+		"colon in id":  {{ID: "a:b", Key: testKey('a')}},
+		"duplicate id": {{ID: "a", Key: testKey('a')}, {ID: "a", Tenant: "acme", Key: testKey('b')}},
+		"short key":    {{ID: "a", Key: base64.StdEncoding.EncodeToString([]byte("short"))}},
+		"not base64":   {{ID: "a", Key: "not base64!"}},
+	} {
+		if _, err := NewKeyring(keys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`[{"id": "default", "key": "`+testKey('a')+`"}]`), 0600)
+	if _, err := LoadKeyring(path, os.ReadFile); err != nil {
+		t.Errorf("LoadKeyring failed: %v", err)
+	}
+}
+
+func TestEncryptor_SealAndDecrypt(t *testing.T) {
+	keys, err := NewKeyring([]Key{
+		{ID: "default", Key: testKey('a')},
+		{ID: "acme", Tenant: "acme", Key: testKey('b')},
+		{ID: "acme-billing", Tenant: "acme", AppName: "billing", Key: testKey('c')},
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	encryptor := New([]string{"auth.token"}, keys)
+
+	for _, tc := range []struct {
+		tenant, appName, keyID string
+	}{
+		{"", "api", "default"},
+		{"acme", "api", "acme"},
+		{"acme", "billing", "acme-billing"},
+		{"other", "billing", "default"},
+	} {
+		entry := testEntry(tc.tenant, tc.appName, "s3cret")
+		if !encryptor.Seal(entry) {
+			t.Fatalf("Expected the token of %s/%s to be encrypted", tc.tenant, tc.appName)
+		}
+		sealed := param(entry, "auth", "token")
+		if !Sealed(sealed) || !strings.HasPrefix(sealed, sealedPrefix+tc.keyID+":") || strings.Contains(sealed, "s3cret") {
+			t.Errorf("Expected the token of %s/%s sealed with %s, got %q", tc.tenant, tc.appName, tc.keyID, sealed)
+		}
+		if param(entry, "auth", "user") != "alice" {
+			t.Errorf("Expected other parameters to stay as they are")
+		}
+		if plain := encryptor.Decrypt("auth.token", sealed); plain != "s3cret" {
+			t.Errorf("Expected the token decrypted, got %q", plain)
+		}
+		if encryptor.Seal(entry) {
+			t.Errorf("Expected a value sealed with the current key to be left alone")
+		}
+	}
+
+	// The field name is authenticated with the value
+	entry := testEntry("", "api", "s3cret")
+	encryptor.Seal(entry)
+	if moved := encryptor.Decrypt("auth.user", param(entry, "auth", "token")); moved == "s3cret" {
+		t.Errorf("Expected a value moved to another field not to decrypt")
+	}
+	if plain := encryptor.Decrypt("auth.token", "plain"); plain != "plain" {
+		t.Errorf("Expected plaintext to be returned as is, got %q", plain)
+	}
+}
+
+func TestEncryptor_Rotation(t *testing.T) {
+	old, _ := NewKeyring([]Key{{ID: "2024", Key: testKey('a')}})
+	entry := testEntry("", "api", "s3cret")
+	New([]string{"auth.token"}, old).Seal(entry)
+	stale := param(entry, "auth", "token")
+
+	rotated, _ := NewKeyring([]Key{{ID: "2024", Key: testKey('a')}, {ID: "2025", Key: testKey('b')}})
+	encryptor := New([]string{"auth.token"}, rotated)
+	if plain := encryptor.Decrypt("auth.token", stale); plain != "s3cret" {
+		t.Errorf("Expected previous keys to keep decrypting, got %q", plain)
+	}
+	if !encryptor.Seal(entry) {
+		t.Fatalf("Expected a value under a previous key to be re-encrypted")
+	}
+	if current := param(entry, "auth", "token"); !strings.HasPrefix(current, sealedPrefix+"2025:") || encryptor.Decrypt("auth.token", current) != "s3cret" {
+		t.Errorf("Expected the value re-encrypted with the current key, got %q", current)
+	}
+
+	// Without its key, a value stays as stored
+	retired, _ := NewKeyring([]Key{{ID: "2026", Key: testKey('c')}})
+	encryptor = New([]string{"auth.token"}, retired)
+	current := param(entry, "auth", "token")
+	if encryptor.Seal(entry) || param(entry, "auth", "token") != current || encryptor.Decrypt("auth.token", current) != current {
+		t.Errorf("Expected a value of a removed key to be left alone")
+	}
+}
+
+func TestEncryptor_Unkeyed(t *testing.T) {
+	keys, _ := NewKeyring([]Key{{ID: "acme", Tenant: "acme", Key: testKey('a')}})
+	encryptor := New([]string{"auth.token"}, keys)
+
+	entry := testEntry("other", "api", "s3cret")
+	entry.StructuredData["payment"] = map[string]string{"card": "1234"}
+	if !encryptor.Seal(entry) || param(entry, "auth", "token") != Unkeyed {
+		t.Errorf("Expected the token of a tenant without a key to be dropped, got %q", param(entry, "auth", "token"))
+	}
+	if entry.Metadata(types.TenantParam) != "other" {
+		t.Errorf("Expected the receiver metadata to be left alone")
+	}
+}
@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// formatRsyslogLine formats an entry as RSYSLOG_FileFormat writes it. The tag is always written,
+// even if empty, so that a colon in the message is not taken for the end of a tag.
+func formatRsyslogLine(entry *types.LogEntry) string {
+	line := entry.Timestamp.Format(time.RFC3339Nano)
+	if entry.Hostname == "" {
+		// Only a line without anything after the timestamp has no hostname
+		return line
+	}
+	line += " " + entry.Hostname + " " + entry.AppName
+	if entry.ProcID != "" {
+		line += "[" + entry.ProcID + "]"
+	}
+	line += ":"
+	if entry.Message != "" {
+		line += " " + entry.Message
+	}
+	return line
+}
+
+func FuzzParseRsyslogLine(f *testing.F) {
+	for _, seed := range []string{
+		"Mar 14 09:30:01 web01 sshd[4321]: Accepted publickey for deploy",
+		"Dec 31 23:59:59 web01 kernel: shutting down",
+		"2024-03-14T09:30:01.123456+02:00 db1 postgres[77]: checkpoint starting",
+		"2024-03-14T09:30:01Z host tag[]: empty pid",
+		"2024-03-14T09:30:01Z host [1]: no name",
+		"Feb 30 25:61:61 bad date",
+		"Mar 14 09:30:01",
+		"9", "", ":", "[]:",
+	} {
+		f.Add(seed)
+	}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	f.Fuzz(func(t *testing.T, line string) {
+		first, err := parseRsyslogLine(line, now)
+		if err != nil {
+			return
+		}
+		if first == nil {
+			t.Fatalf("parseRsyslogLine(%q) returned neither an entry nor an error", line)
+		}
+		if first.Priority != defaultPriority || first.Version != 1 {
+			t.Errorf("parseRsyslogLine(%q) set priority %d and version %d", line, first.Priority, first.Version)
+		}
+
+		// Years outside 0-9999 have no RFC3339 form
+		if year := first.Timestamp.Year(); year < 0 || year > 9999 {
+			return
+		}
+		formatted := formatRsyslogLine(first)
+		second, err := parseRsyslogLine(formatted, now)
+		if err != nil {
+			t.Fatalf("parseRsyslogLine(%q) failed on the formatted entry %q: %v", line, formatted, err)
+		}
+		if !second.Timestamp.Equal(first.Timestamp) || second.Hostname != first.Hostname ||
+			second.AppName != first.AppName || second.ProcID != first.ProcID || second.Message != first.Message {
+			t.Fatalf("Round trip of %q through %q changed the entry:\n first: %+v\nsecond: %+v", line, formatted, first, second)
+		}
+	})
+}
+
+func FuzzDecodeEntry(f *testing.F) {
+	for _, seed := range []string{
+		`{"priority":14,"timestamp":"2024-03-14T09:30:01Z","hostname":"web01","message":"hello"}`,
+		`{"priority":999,"version":0,"structured_data":{"meta":{"k":"v"}},"message":"\u0000\ud800"}`,
+		`{"id":42,"priority":-1,"timestamp":"2024-03-14T09:30:01.123456789+05:30"}`,
+		`{"structured_data":{"a":[1,2,{"b":null}]}}`,
+		`[]`, `null`, `{`, `{"timestamp":"yesterday"}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		first, err := decodeEntry(data)
+		if err != nil {
+			return
+		}
+		if first.ID != 0 || first.Version == 0 {
+			t.Errorf("decodeEntry(%q) kept ID %d and version %d", data, first.ID, first.Version)
+		}
+		if first.Facility != first.Priority>>3 || first.Severity != first.Priority&7 {
+			t.Errorf("decodeEntry(%q) facility %d and severity %d do not match priority %d", data, first.Facility,
+				first.Severity, first.Priority)
+		}
+
+		encoded, err := json.Marshal(first)
+		if err != nil {
+			// Timestamps outside 0-9999 decode but cannot be encoded again
+			return
+		}
+		second, err := decodeEntry(encoded)
+		if err != nil {
+			t.Fatalf("decodeEntry(%q) failed on the encoded entry %q: %v", data, encoded, err)
+		}
+		reencoded, err := json.Marshal(second)
+		if err != nil {
+			t.Fatalf("Failed to encode decoded entry %q: %v", encoded, err)
+		}
+		if string(reencoded) != string(encoded) {
+			t.Fatalf("Round trip of %q changed the entry from %s to %s", data, encoded, reencoded)
+		}
+	})
+}
+
+func FuzzParseJournaldRecord(f *testing.F) {
+	for _, seed := range []string{
+		`{"__REALTIME_TIMESTAMP":"1710408601123456","PRIORITY":"3","SYSLOG_FACILITY":"4","_HOSTNAME":"web01","SYSLOG_IDENTIFIER":"sshd","_PID":"4321","MESSAGE":[104,105]}`,
+		`{"__REALTIME_TIMESTAMP":"-1","PRIORITY":"99","SYSLOG_FACILITY":"-3","MESSAGE":"x"}`,
+		`{"__REALTIME_TIMESTAMP":"99999999999999999999","MESSAGE":[300,-1]}`,
+		`{"MESSAGE":null,"PRIORITY":3}`,
+		`[]`, `{`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		entry, err := parseJournaldRecord(data)
+		if err != nil {
+			return
+		}
+		if entry == nil {
+			t.Fatalf("parseJournaldRecord(%q) returned neither an entry nor an error", data)
+		}
+		if entry.Priority < 0 || entry.Priority > 191 {
+			t.Errorf("parseJournaldRecord(%q) priority %d out of range", data, entry.Priority)
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package importer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/sanitize"
+	"opentrail/internal/types"
+)
+
+const (
+	// DefaultProgressInterval is the default number of records between progress reports
+	DefaultProgressInterval = 10000
+	// MaxLineSize is the maximum size of a single line in a text or NDJSON source
+	MaxLineSize = 10 * 1024 * 1024
+)
+
+// Format identifies the layout of an import source
+type Format string
+
+const (
+	// FormatAuto detects the format from the file extension
+	FormatAuto Format = "auto"
+	// FormatText treats every line as a raw message for the configured parser
+	FormatText Format = "text"
+	// FormatNDJSON treats every line as a JSON encoded LogEntry
+	FormatNDJSON Format = "ndjson"
+	// FormatJSON reads an exported API response or a JSON array of LogEntry values
+	FormatJSON Format = "json"
+)
+
+// TimestampMode controls how timestamps are assigned to imported entries
+type TimestampMode string
+
+const (
+	// TimestampParsed keeps the timestamp found in the source, falling back to the import time
+	TimestampParsed TimestampMode = "parsed"
+	// TimestampImport overrides every timestamp with the import time
+	TimestampImport TimestampMode = "import"
+)
+
+// Options configures an Importer
+type Options struct {
+	// Format of the source files (FormatAuto detects it per file)
+	Format Format
+
+	// TimestampMode controls timestamp handling
+	TimestampMode TimestampMode
+
+	// KeepANSIColors keeps the ANSI color sequences of messages instead of escaping them, as the
+	// server's -keep-ansi-colors does
+	KeepANSIColors bool
+
+	// StateFile records progress so interrupted imports can resume (empty disables)
+	StateFile string
+
+	// ProgressInterval is the number of records between progress reports
+	ProgressInterval int
+
+	// Progress is called periodically and once more when a file is finished
+	Progress func(Progress)
+}
+
+// Progress reports how far an import of a single file has advanced
+type Progress struct {
+	File     string `json:"file"`
+	Records  int64  `json:"records"`
+	Imported int64  `json:"imported"`
+	Skipped  int64  `json:"skipped"`
+	Failed   int64  `json:"failed"`
+	// Duplicates counts entries not stored because their uid or idempotency key is already stored,
+	// such as those of an archive imported before
+	Duplicates int64 `json:"duplicates"`
+	// Sanitized counts imported entries whose text had invalid UTF-8 or control characters
+	// replaced or was normalized to NFC
+	Sanitized int64         `json:"sanitized"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Done      bool          `json:"done"`
+}
+
+// Importer bulk-loads log files directly into a storage backend
+type Importer struct {
+	storage interfaces.LogStorage
+	parser  interfaces.LogParser
+	options Options
+	state   *State
+}
+
+// New creates a new Importer, loading any existing resume state
+func New(storage interfaces.LogStorage, parser interfaces.LogParser, options Options) (*Importer, error) {
+	if options.Format == "" {
+		options.Format = FormatAuto
+	}
+	switch options.Format {
+	case FormatAuto, FormatText, FormatNDJSON, FormatJSON, FormatRsyslog, FormatJournald, FormatLoki:
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", options.Format)
+	}
+
+	if options.TimestampMode == "" {
+		options.TimestampMode = TimestampParsed
+	}
+	switch options.TimestampMode {
+	case TimestampParsed, TimestampImport:
+	default:
+		return nil, fmt.Errorf("unsupported timestamp mode: %s", options.TimestampMode)
+	}
+
+	if options.ProgressInterval <= 0 {
+		options.ProgressInterval = DefaultProgressInterval
+	}
+
+	state, err := LoadState(options.StateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load import state: %w", err)
+	}
+
+	return &Importer{
+		storage: storage,
+		parser:  parser,
+		options: options,
+		state:   state,
+	}, nil
+}
+
+// ImportFile imports a single file, resuming from the recorded position if possible
+func (im *Importer) ImportFile(path string) (*Progress, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+
+	progress := &Progress{File: absPath}
+	start := time.Now()
+
+	fileState := im.state.Get(absPath)
+	if fileState.Completed {
+		progress.Done = true
+		im.report(progress, start)
+		return progress, nil
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader, err := decompress(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	format := im.options.Format
+	if format == FormatAuto {
+		format = DetectFormat(absPath)
+	}
+
+	// handled counts the records that were stored, skipped or failed to parse, which a run resuming
+	// after an error must not import again
+	handled := int64(0)
+	process := func(entry *types.LogEntry, err error) error {
+		progress.Records++
+
+		// Skip records that were imported by a previous run
+		if progress.Records <= fileState.Records {
+			progress.Skipped++
+			handled = progress.Records
+			return nil
+		}
+
+		if err != nil {
+			progress.Failed++
+		} else if entry != nil {
+			im.applyTimestampMode(entry)
+			if sanitize.Entry(entry, im.options.KeepANSIColors).Any() {
+				progress.Sanitized++
+			}
+			err := im.storage.Store(entry)
+			switch {
+			case errors.Is(err, interfaces.ErrDuplicateEntry):
+				progress.Duplicates++
+			case err != nil:
+				return fmt.Errorf("failed to store record %d: %w", progress.Records, err)
+			default:
+				progress.Imported++
+			}
+		}
+		handled = progress.Records
+
+		if progress.Records%int64(im.options.ProgressInterval) == 0 {
+			if err := im.checkpoint(absPath, progress.Records, false); err != nil {
+				return err
+			}
+			im.report(progress, start)
+		}
+		return nil
+	}
+
+	switch format {
+	case FormatText:
+		err = im.readLines(reader, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+			return process(im.parser.Parse(line))
+		})
+	case FormatNDJSON:
+		err = im.readLines(reader, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+			return process(decodeEntry([]byte(line)))
+		})
+	case FormatJSON:
+		err = im.readJSON(reader, process)
+	case FormatRsyslog:
+		now := time.Now()
+		err = im.readLines(reader, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+			return process(parseRsyslogLine(line, now))
+		})
+	case FormatJournald:
+		err = im.readLines(reader, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+			return process(parseJournaldRecord([]byte(line)))
+		})
+	case FormatLoki:
+		err = im.readLoki(reader, process)
+	}
+	if err != nil {
+		// Record how far we got so the next run can resume; a failed store or an unreadable record
+		// is tried again
+		if checkpointErr := im.checkpoint(absPath, handled, false); checkpointErr != nil {
+			return progress, fmt.Errorf("%w (and failed to save state: %v)", err, checkpointErr)
+		}
+		return progress, err
+	}
+
+	if err := im.checkpoint(absPath, progress.Records, true); err != nil {
+		return progress, err
+	}
+
+	progress.Done = true
+	im.report(progress, start)
+	return progress, nil
+}
+
+// checkpoint persists the number of records handled for a file
+func (im *Importer) checkpoint(path string, records int64, completed bool) error {
+	im.state.Set(path, FileState{Records: records, Completed: completed})
+	if err := im.state.Save(); err != nil {
+		return fmt.Errorf("failed to save import state: %w", err)
+	}
+	return nil
+}
+
+// report invokes the progress callback if configured
+func (im *Importer) report(progress *Progress, start time.Time) {
+	if im.options.Progress == nil {
+		return
+	}
+	progress.Elapsed = time.Since(start)
+	im.options.Progress(*progress)
+}
+
+// applyTimestampMode adjusts entry timestamps according to the configured mode
+func (im *Importer) applyTimestampMode(entry *types.LogEntry) {
+	now := time.Now()
+	if im.options.TimestampMode == TimestampImport || entry.Timestamp.IsZero() {
+		entry.Timestamp = now
+	}
+	entry.CreatedAt = now
+}
+
+// readLines calls fn for every line in r
+func (im *Importer) readLines(r io.Reader, fn func(string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxLineSize)
+
+	for scanner.Scan() {
+		if err := fn(strings.TrimRight(scanner.Text(), "\r")); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	return nil
+}
+
+// readJSON decodes an exported API response ({"data": [...]}) or a bare JSON array. The records
+// are decoded one at a time, so an export does not have to fit in memory.
+func (im *Importer) readJSON(r io.Reader, fn func(*types.LogEntry, error) error) error {
+	dec := json.NewDecoder(r)
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON export: %w", err)
+	}
+	if token != json.Delim('[') {
+		if token != json.Delim('{') {
+			return fmt.Errorf("failed to decode JSON export: expected an array or an object, got %v", token)
+		}
+		if err := seekArray(dec, "data"); err != nil {
+			return fmt.Errorf("failed to decode JSON export: %w", err)
+		}
+	}
+
+	return decodeArray(dec, func(record json.RawMessage) error {
+		return fn(decodeEntry(record))
+	})
+}
+
+// seekArray advances dec, positioned inside an object, past the start of the array at path: a key
+// of that object, then of the objects nested in its value. The values of other keys are skipped.
+func seekArray(dec *json.Decoder, path ...string) error {
+	for i, key := range path {
+		if i > 0 {
+			if err := expectDelim(dec, '{'); err != nil {
+				return err
+			}
+		}
+		for {
+			if !dec.More() {
+				return fmt.Errorf("no %s key", key)
+			}
+			token, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if token == key {
+				break
+			}
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	return expectDelim(dec, '[')
+}
+
+// expectDelim reads the next token of dec, failing unless it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// decodeArray calls fn with each element of the array dec is positioned in, decoding them one at a
+// time, and reads the end of the array
+func decodeArray[T any](dec *json.Decoder, fn func(T) error) error {
+	for index := 0; dec.More(); index++ {
+		var element T
+		if err := dec.Decode(&element); err != nil {
+			return fmt.Errorf("failed to decode element %d: %w", index, err)
+		}
+		if err := fn(element); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return fmt.Errorf("failed to decode the end of the array: %w", err)
+	}
+	return nil
+}
+
+// decodeEntry decodes a single JSON encoded LogEntry
+func decodeEntry(data []byte) (*types.LogEntry, error) {
+	var entry types.LogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid JSON record: %w", err)
+	}
+
+	// Database IDs are assigned by the destination storage
+	entry.ID = 0
+	if entry.Version == 0 {
+		entry.Version = 1
+	}
+	entry.SetPriority(entry.Priority)
+
+	return &entry, nil
+}
+
+// decompress transparently wraps gzip compressed input
+func decompress(file *os.File) (io.Reader, error) {
+	buffered := bufio.NewReader(file)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// DetectFormat guesses the source format from the file name
+func DetectFormat(path string) Format {
+	name := strings.TrimSuffix(strings.ToLower(path), ".gz")
+	switch filepath.Ext(name) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatText
+	}
+}
@@ -0,0 +1,333 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/parser"
+	"opentrail/internal/types"
+)
+
+// MockStorage records stored entries in memory
+type MockStorage struct {
+	entries   []*types.LogEntry
+	failAfter int
+	mutex     sync.Mutex
+}
+
+func (m *MockStorage) Store(entry *types.LogEntry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.failAfter > 0 && len(m.entries) >= m.failAfter {
+		return fmt.Errorf("storage unavailable")
+	}
+	for _, stored := range m.entries {
+		if entry.UID != "" && stored.UID == entry.UID {
+			return interfaces.ErrDuplicateEntry
+		}
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *MockStorage) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	return m.entries, nil
+}
+
+func (m *MockStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
+	return m.entries, nil
+}
+
+func (m *MockStorage) Cleanup(retentionDays int) error {
+	return nil
+}
+
+func (m *MockStorage) Close() error {
+	return nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestImporter_TextFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "app.log", strings.Join([]string{
+		"<134>1 2024-01-01T10:00:00Z host1 app1 123 ID1 - First message",
+		"",
+		"<131>1 2024-01-01T10:01:00Z host1 app1 123 ID2 - Second message",
+		"plain line without syslog header",
+	}, "\n"))
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+
+	progress, err := imp.ImportFile(path)
+	if err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+
+	if progress.Imported != 3 || !progress.Done {
+		t.Errorf("Expected 3 imported records and done, got %+v", progress)
+	}
+
+	expected := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if !storage.entries[0].Timestamp.Equal(expected) {
+		t.Errorf("Expected parsed timestamp %v, got %v", expected, storage.entries[0].Timestamp)
+	}
+	if storage.entries[2].Message != "plain line without syslog header" {
+		t.Errorf("Expected fallback message, got %q", storage.entries[2].Message)
+	}
+}
+
+func TestImporter_GzipNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.ndjson.gz")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte(`{"id":42,"priority":11,"timestamp":"2024-02-01T08:00:00Z","hostname":"db1","app_name":"postgres","message":"checkpoint complete"}` + "\n"))
+	gz.Write([]byte("not json\n"))
+	gz.Close()
+	file.Close()
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+
+	progress, err := imp.ImportFile(path)
+	if err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+
+	if progress.Imported != 1 || progress.Failed != 1 {
+		t.Errorf("Expected 1 imported and 1 failed record, got %+v", progress)
+	}
+
+	entry := storage.entries[0]
+	if entry.ID != 0 {
+		t.Errorf("Expected source ID to be cleared, got %d", entry.ID)
+	}
+	if entry.Facility != 1 || entry.Severity != 3 {
+		t.Errorf("Expected facility/severity derived from priority, got %d/%d", entry.Facility, entry.Severity)
+	}
+	if entry.Version != 1 {
+		t.Errorf("Expected default version 1, got %d", entry.Version)
+	}
+}
+
+func TestImporter_DuplicateUIDs(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "dump.ndjson", strings.Join([]string{
+		`{"id":1,"uid":"01HQWY5CG0A3N9V4Z6C8W7X2JK","priority":14,"timestamp":"2024-03-01T12:00:00Z","message":"first"}`,
+		`{"id":2,"uid":"01HQWY5CG1F5T2R8M4P6Q9S3BD","priority":14,"timestamp":"2024-03-01T12:00:00.001Z","message":"second"}`,
+	}, "\n"))
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	if _, err := imp.ImportFile(path); err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+	if len(storage.entries) != 2 || storage.entries[0].UID != "01HQWY5CG0A3N9V4Z6C8W7X2JK" {
+		t.Fatalf("Expected the entries to keep their uid, got %+v", storage.entries)
+	}
+
+	// Importing the dump again stores nothing new
+	again, err := New(storage, parser.NewRFC5424Parser(false), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	progress, err := again.ImportFile(path)
+	if err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+	if progress.Imported != 0 || progress.Duplicates != 2 || len(storage.entries) != 2 {
+		t.Errorf("Expected 2 duplicates and nothing imported, got %+v", progress)
+	}
+}
+
+func TestImporter_JSONExport(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "export.json",
+		`{"success":true,"data":[{"priority":134,"timestamp":"2024-03-01T00:00:00Z","message":"a"},{"priority":134,"timestamp":"2024-03-01T00:00:01Z","message":"b"}]}`)
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{TimestampMode: TimestampImport})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+
+	before := time.Now()
+	if _, err := imp.ImportFile(path); err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+
+	if len(storage.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(storage.entries))
+	}
+	if storage.entries[0].Timestamp.Before(before) {
+		t.Errorf("Expected import timestamp, got %v", storage.entries[0].Timestamp)
+	}
+
+	// A bare array, and an export whose data follows other keys
+	for name, content := range map[string]string{
+		"array.json":  `[{"priority":134,"timestamp":"2024-03-01T00:00:02Z","message":"c"}]`,
+		"nested.json": `{"success":true,"meta":{"data":[1]},"data":[{"priority":134,"timestamp":"2024-03-01T00:00:03Z","message":"d"}]}`,
+	} {
+		storage.entries = nil
+		if _, err := imp.ImportFile(writeFile(t, dir, name, content)); err != nil || len(storage.entries) != 1 {
+			t.Errorf("Expected 1 entry from %s, got %d (%v)", name, len(storage.entries), err)
+		}
+	}
+	if _, err := imp.ImportFile(writeFile(t, dir, "truncated.json", `{"data":[{"message":"e"},{"mess`)); err == nil {
+		t.Error("Expected a truncated export to fail")
+	}
+}
+
+func TestImporter_Resume(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fmt.Sprintf("<134>1 2024-01-01T10:00:%02dZ host app - - - message %d", i, i))
+	}
+	path := writeFile(t, dir, "resume.log", strings.Join(lines, "\n"))
+	stateFile := filepath.Join(dir, "state.json")
+
+	// First run fails part-way through
+	storage := &MockStorage{failAfter: 4}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{StateFile: stateFile, ProgressInterval: 2})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	if _, err := imp.ImportFile(path); err == nil {
+		t.Fatal("Expected import to fail")
+	}
+
+	// Second run resumes after the records already stored
+	storage.failAfter = 0
+	imp, err = New(storage, parser.NewRFC5424Parser(false), Options{StateFile: stateFile, ProgressInterval: 2})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	progress, err := imp.ImportFile(path)
+	if err != nil {
+		t.Fatalf("Resumed import failed: %v", err)
+	}
+
+	if progress.Skipped != 4 || progress.Imported != 6 {
+		t.Errorf("Expected 4 skipped and 6 imported, got %+v", progress)
+	}
+	if len(storage.entries) != 10 {
+		t.Errorf("Expected 10 entries without duplicates, got %d", len(storage.entries))
+	}
+
+	// Third run sees the file as completed
+	progress, err = imp.ImportFile(path)
+	if err != nil {
+		t.Fatalf("Repeated import failed: %v", err)
+	}
+	if progress.Imported != 0 || !progress.Done {
+		t.Errorf("Expected completed file to be skipped, got %+v", progress)
+	}
+}
+
+func TestImporter_ResumeAfterReadError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cut.log.gz")
+	stateFile := filepath.Join(dir, "state.json")
+
+	// The first half of the archive is flushed on its own, so a copy cut after it reads 100
+	// records before failing
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(gz, "<134>1 2024-01-01T10:%02d:%02dZ host app - - - message %d\n", i/60, i%60, i)
+		if i == 99 {
+			gz.Flush()
+		}
+	}
+	flushed := compressed.Len()
+	gz.Close()
+	full := compressed.Bytes()
+
+	if err := os.WriteFile(path, full[:flushed], 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{StateFile: stateFile, ProgressInterval: 1000})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	if _, err := imp.ImportFile(path); err == nil {
+		t.Fatal("Expected reading the cut archive to fail")
+	}
+	if len(storage.entries) != 100 {
+		t.Fatalf("Expected 100 entries before the read error, got %d", len(storage.entries))
+	}
+
+	// Resuming with the whole archive skips every record stored before the error
+	if err := os.WriteFile(path, full, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	imp, err = New(storage, parser.NewRFC5424Parser(false), Options{StateFile: stateFile, ProgressInterval: 1000})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	progress, err := imp.ImportFile(path)
+	if err != nil {
+		t.Fatalf("Resumed import failed: %v", err)
+	}
+	if progress.Skipped != 100 || progress.Imported != 100 || len(storage.entries) != 200 {
+		t.Errorf("Expected 100 skipped, 100 imported and 200 entries, got %+v with %d entries", progress, len(storage.entries))
+	}
+}
+
+func TestImporter_InvalidOptions(t *testing.T) {
+	if _, err := New(&MockStorage{}, nil, Options{Format: "xml"}); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if _, err := New(&MockStorage{}, nil, Options{TimestampMode: "guess"}); err == nil {
+		t.Error("Expected error for unsupported timestamp mode")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]Format{
+		"app.log":           FormatText,
+		"app.log.gz":        FormatText,
+		"export.ndjson":     FormatNDJSON,
+		"export.JSONL.gz":   FormatNDJSON,
+		"export.json":       FormatJSON,
+		"/var/log/messages": FormatText,
+	}
+
+	for path, expected := range tests {
+		if got := DetectFormat(path); got != expected {
+			t.Errorf("DetectFormat(%q) = %s, expected %s", path, got, expected)
+		}
+	}
+}
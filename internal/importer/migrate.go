@@ -0,0 +1,302 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// FormatRsyslog reads files written by rsyslog's traditional or high-precision file templates
+	FormatRsyslog Format = "rsyslog"
+	// FormatJournald reads `journalctl -o json` exports
+	FormatJournald Format = "journald"
+	// FormatLoki reads Loki query_range responses or `logcli --output=jsonl` exports
+	FormatLoki Format = "loki"
+)
+
+// defaultPriority is used for sources that carry no PRI value (facility 16 = local0, severity 6 = info)
+const defaultPriority = 134
+
+// parseRsyslogLine parses a line in RSYSLOG_TraditionalFileFormat
+// ("Jan  2 15:04:05 host tag[pid]: msg") or RSYSLOG_FileFormat
+// ("2006-01-02T15:04:05.000000+00:00 host tag[pid]: msg")
+func parseRsyslogLine(line string, now time.Time) (*types.LogEntry, error) {
+	var timestamp time.Time
+	var rest string
+
+	if len(line) > 0 && line[0] >= '0' && line[0] <= '9' {
+		parts := strings.SplitN(line, " ", 2)
+		ts, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsyslog timestamp: %s", parts[0])
+		}
+		timestamp = ts
+		if len(parts) == 2 {
+			rest = parts[1]
+		}
+	} else {
+		if len(line) < len(time.Stamp) {
+			return nil, fmt.Errorf("rsyslog line too short")
+		}
+		ts, err := time.ParseInLocation(time.Stamp, line[:len(time.Stamp)], now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsyslog timestamp: %s", line[:len(time.Stamp)])
+		}
+		// The traditional format has no year; assume the most recent matching date, which for
+		// February 29 is in a leap year rather than March 1 of a common one
+		limit := now.Add(24 * time.Hour)
+		for year := now.Year(); ; year-- {
+			timestamp = time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), now.Location())
+			if timestamp.Day() == ts.Day() && !timestamp.After(limit) {
+				break
+			}
+		}
+		rest = strings.TrimSpace(line[len(time.Stamp):])
+	}
+
+	entry := &types.LogEntry{
+		Version:   1,
+		Timestamp: timestamp,
+	}
+	entry.SetPriority(defaultPriority)
+
+	hostname, rest := splitField(rest)
+	entry.Hostname = hostname
+
+	// The tag ends at the first colon, e.g. "sshd[1234]:" or "kernel:"
+	if colon := strings.Index(rest, ":"); colon != -1 && !strings.Contains(rest[:colon], " ") {
+		tag := rest[:colon]
+		rest = strings.TrimSpace(rest[colon+1:])
+
+		if open := strings.Index(tag, "["); open != -1 && strings.HasSuffix(tag, "]") {
+			entry.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		entry.AppName = tag
+	}
+
+	entry.Message = rest
+	return entry, nil
+}
+
+// parseJournaldRecord converts a single `journalctl -o json` record into a LogEntry
+func parseJournaldRecord(data []byte) (*types.LogEntry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid journald record: %w", err)
+	}
+
+	entry := &types.LogEntry{Version: 1}
+
+	facility := defaultPriority >> 3
+	severity := defaultPriority & 7
+	if value, ok := journaldInt(fields["SYSLOG_FACILITY"]); ok {
+		facility = value
+	}
+	if value, ok := journaldInt(fields["PRIORITY"]); ok {
+		severity = value
+	}
+	if facility < 0 || facility > 23 || severity < 0 || severity > 7 {
+		return nil, fmt.Errorf("journald priority out of range: facility %d, severity %d", facility, severity)
+	}
+	entry.SetPriority(facility*8 + severity)
+
+	if micros, ok := journaldInt(fields["__REALTIME_TIMESTAMP"]); ok {
+		entry.Timestamp = time.UnixMicro(int64(micros)).UTC()
+	}
+
+	entry.Hostname = journaldString(fields["_HOSTNAME"])
+	entry.AppName = journaldString(fields["SYSLOG_IDENTIFIER"])
+	if entry.AppName == "" {
+		entry.AppName = journaldString(fields["_COMM"])
+	}
+	entry.ProcID = journaldString(fields["_PID"])
+	if entry.ProcID == "" {
+		entry.ProcID = journaldString(fields["SYSLOG_PID"])
+	}
+	entry.Message = journaldString(fields["MESSAGE"])
+
+	// Preserve the remaining trusted fields (unit, transport, boot ID, ...) as structured data
+	params := make(map[string]interface{})
+	for key, value := range fields {
+		switch key {
+		case "MESSAGE", "PRIORITY", "SYSLOG_FACILITY", "SYSLOG_IDENTIFIER", "SYSLOG_PID", "_HOSTNAME", "_PID":
+			continue
+		}
+		if strings.HasPrefix(key, "__") {
+			continue
+		}
+		if str := journaldString(value); str != "" {
+			params[key] = str
+		}
+	}
+	if len(params) > 0 {
+		entry.StructuredData = map[string]interface{}{"journald": params}
+	}
+
+	return entry, nil
+}
+
+// journaldString decodes a journald field, which is a string or a byte array for binary data
+func journaldString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		bytes := make([]byte, 0, len(v))
+		for _, b := range v {
+			if n, ok := b.(float64); ok {
+				bytes = append(bytes, byte(n))
+			}
+		}
+		return string(bytes)
+	default:
+		return ""
+	}
+}
+
+// journaldInt decodes a numeric journald field, which journalctl encodes as a string
+func journaldInt(value interface{}) (int, bool) {
+	str := journaldString(value)
+	if str == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// readLoki decodes a Loki query_range response or a logcli JSONL export. A response is decoded a
+// stream at a time, so an export does not have to fit in memory.
+func (im *Importer) readLoki(r io.Reader, fn func(*types.LogEntry, error) error) error {
+	// Only what the check read is kept to be read again
+	var checked bytes.Buffer
+	queryRange := isLokiResponse(io.TeeReader(r, &checked))
+	r = io.MultiReader(&checked, r)
+
+	if !queryRange {
+		// One logcli record per line
+		return im.readLines(r, func(line string) error {
+			if strings.TrimSpace(line) == "" {
+				return nil
+			}
+
+			var record struct {
+				Labels    map[string]string `json:"labels"`
+				Line      string            `json:"line"`
+				Timestamp time.Time         `json:"timestamp"`
+			}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return fn(nil, fmt.Errorf("invalid Loki record: %w", err))
+			}
+			return fn(newLokiEntry(record.Labels, record.Timestamp, record.Line), nil)
+		})
+	}
+
+	dec := json.NewDecoder(r)
+	err := expectDelim(dec, '{')
+	if err == nil {
+		err = seekArray(dec, "data", "result")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode Loki response: %w", err)
+	}
+
+	type lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	return decodeArray(dec, func(stream lokiStream) error {
+		for _, value := range stream.Values {
+			nanos, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				if err := fn(nil, fmt.Errorf("invalid Loki timestamp: %s", value[0])); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := fn(newLokiEntry(stream.Stream, time.Unix(0, nanos).UTC(), value[1]), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// isLokiResponse reports whether the first JSON value of r is an object with a data key, which a
+// query_range response has and a logcli record does not. It reads no further than that key.
+func isLokiResponse(r io.Reader) bool {
+	dec := json.NewDecoder(r)
+	if expectDelim(dec, '{') != nil {
+		return false
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if token == "data" {
+			return true
+		}
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// newLokiEntry builds a LogEntry from a Loki stream, mapping well-known labels to RFC5424 fields
+func newLokiEntry(labels map[string]string, timestamp time.Time, line string) *types.LogEntry {
+	entry := &types.LogEntry{
+		Version:   1,
+		Timestamp: timestamp,
+		Message:   line,
+		Hostname:  firstLabel(labels, "host", "hostname", "instance", "node"),
+		AppName:   firstLabel(labels, "app", "service_name", "job", "container"),
+	}
+
+	priority := defaultPriority
+	if severity, ok := types.SeverityForLevel(firstLabel(labels, "level", "detected_level", "severity")); ok {
+		priority = (defaultPriority &^ 7) | severity
+	}
+	entry.SetPriority(priority)
+
+	if len(labels) > 0 {
+		params := make(map[string]interface{}, len(labels))
+		for key, value := range labels {
+			params[key] = value
+		}
+		entry.StructuredData = map[string]interface{}{"loki": params}
+	}
+
+	return entry
+}
+
+// firstLabel returns the value of the first label present in labels
+func firstLabel(labels map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := labels[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitField splits off the first space separated field
+func splitField(s string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(s), " ", 2)
+	if len(parts) == 2 {
+		return parts[0], strings.TrimSpace(parts[1])
+	}
+	return parts[0], ""
+}
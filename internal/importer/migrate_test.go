@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"opentrail/internal/parser"
+)
+
+func TestParseRsyslogLine_TraditionalFormat(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	entry, err := parseRsyslogLine("Mar 14 09:30:01 web01 sshd[4321]: Accepted publickey for deploy", now)
+	if err != nil {
+		t.Fatalf("parseRsyslogLine failed: %v", err)
+	}
+
+	expected := time.Date(2024, 3, 14, 9, 30, 1, 0, time.UTC)
+	if !entry.Timestamp.Equal(expected) {
+		t.Errorf("Expected timestamp %v, got %v", expected, entry.Timestamp)
+	}
+	if entry.Hostname != "web01" || entry.AppName != "sshd" || entry.ProcID != "4321" {
+		t.Errorf("Unexpected header fields: %+v", entry)
+	}
+	if entry.Message != "Accepted publickey for deploy" {
+		t.Errorf("Unexpected message: %q", entry.Message)
+	}
+}
+
+func TestParseRsyslogLine_YearRollover(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	entry, err := parseRsyslogLine("Dec 31 23:59:59 web01 kernel: shutting down", now)
+	if err != nil {
+		t.Fatalf("parseRsyslogLine failed: %v", err)
+	}
+	if entry.Timestamp.Year() != 2023 {
+		t.Errorf("Expected December entry to belong to the previous year, got %v", entry.Timestamp)
+	}
+	if entry.AppName != "kernel" || entry.ProcID != "" {
+		t.Errorf("Unexpected tag parsing: %+v", entry)
+	}
+}
+
+func TestParseRsyslogLine_LeapDay(t *testing.T) {
+	for _, test := range []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		// In a common year the leap day belongs to the last leap year
+		{time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC)},
+		{time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 29, 8, 0, 0, 0, time.UTC)},
+		// In a leap year it is the current year once reached, the previous leap year before
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 29, 8, 0, 0, 0, time.UTC)},
+	} {
+		entry, err := parseRsyslogLine("Feb 29 08:00:00 web01 cron[1]: leap day job", test.now)
+		if err != nil {
+			t.Fatalf("parseRsyslogLine failed: %v", err)
+		}
+		if !entry.Timestamp.Equal(test.expected) {
+			t.Errorf("At %v, expected %v, got %v", test.now, test.expected, entry.Timestamp)
+		}
+	}
+}
+
+func TestParseRsyslogLine_HighPrecisionFormat(t *testing.T) {
+	entry, err := parseRsyslogLine("2024-03-14T09:30:01.123456+02:00 db1 postgres[77]: checkpoint starting", time.Now())
+	if err != nil {
+		t.Fatalf("parseRsyslogLine failed: %v", err)
+	}
+	if entry.Timestamp.Nanosecond() != 123456000 {
+		t.Errorf("Expected sub-second precision to be preserved, got %v", entry.Timestamp)
+	}
+	if entry.AppName != "postgres" || entry.ProcID != "77" {
+		t.Errorf("Unexpected tag parsing: %+v", entry)
+	}
+}
+
+func TestParseJournaldRecord(t *testing.T) {
+	record := `{"__REALTIME_TIMESTAMP":"1710408601123456","__CURSOR":"s=abc","PRIORITY":"3","SYSLOG_FACILITY":"4","_HOSTNAME":"web01","SYSLOG_IDENTIFIER":"sshd","_PID":"4321","_SYSTEMD_UNIT":"ssh.service","MESSAGE":[104,105]}`
+
+	entry, err := parseJournaldRecord([]byte(record))
+	if err != nil {
+		t.Fatalf("parseJournaldRecord failed: %v", err)
+	}
+
+	if entry.Facility != 4 || entry.Severity != 3 {
+		t.Errorf("Expected facility 4 / severity 3, got %d/%d", entry.Facility, entry.Severity)
+	}
+	if entry.Timestamp.UnixMicro() != 1710408601123456 {
+		t.Errorf("Unexpected timestamp: %v", entry.Timestamp)
+	}
+	if entry.Hostname != "web01" || entry.AppName != "sshd" || entry.ProcID != "4321" {
+		t.Errorf("Unexpected header fields: %+v", entry)
+	}
+	if entry.Message != "hi" {
+		t.Errorf("Expected binary MESSAGE to be decoded, got %q", entry.Message)
+	}
+
+	params, ok := entry.StructuredData["journald"].(map[string]interface{})
+	if !ok || params["_SYSTEMD_UNIT"] != "ssh.service" {
+		t.Errorf("Expected _SYSTEMD_UNIT in structured data, got %v", entry.StructuredData)
+	}
+	if _, ok := params["__CURSOR"]; ok {
+		t.Error("Expected address fields to be dropped")
+	}
+}
+
+func TestImporter_LokiQueryRange(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "loki.json", `{"status":"success","data":{"resultType":"streams","result":[
+		{"stream":{"app":"api","host":"node1","level":"error"},"values":[["1710408601000000001","boom"],["1710408602000000000","again"]]}
+	]}}`)
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{Format: FormatLoki})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	if _, err := imp.ImportFile(path); err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+
+	if len(storage.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(storage.entries))
+	}
+	entry := storage.entries[0]
+	if entry.Timestamp.UnixNano() != 1710408601000000001 {
+		t.Errorf("Expected nanosecond timestamp to be preserved, got %v", entry.Timestamp)
+	}
+	if entry.AppName != "api" || entry.Hostname != "node1" || entry.Severity != 3 {
+		t.Errorf("Unexpected label mapping: %+v", entry)
+	}
+	labels, ok := entry.StructuredData["loki"].(map[string]interface{})
+	if !ok || labels["level"] != "error" {
+		t.Errorf("Expected labels in structured data, got %v", entry.StructuredData)
+	}
+}
+
+func TestImporter_LokiJSONL(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "loki.jsonl", strings.Join([]string{
+		`{"labels":{"job":"nginx"},"line":"GET / 200","timestamp":"2024-03-14T09:30:01.5Z"}`,
+		`{"labels":{"job":"nginx"},"line":"GET /health 200","timestamp":"2024-03-14T09:30:02Z"}`,
+	}, "\n"))
+
+	storage := &MockStorage{}
+	imp, err := New(storage, parser.NewRFC5424Parser(false), Options{Format: FormatLoki})
+	if err != nil {
+		t.Fatalf("Failed to create importer: %v", err)
+	}
+	if _, err := imp.ImportFile(path); err != nil {
+		t.Fatalf("ImportFile failed: %v", err)
+	}
+
+	if len(storage.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(storage.entries))
+	}
+	if storage.entries[0].AppName != "nginx" || storage.entries[0].Severity != 6 {
+		t.Errorf("Unexpected entry: %+v", storage.entries[0])
+	}
+}
+
+func TestIsLokiResponse(t *testing.T) {
+	// The check stops at the data key, without reading the streams after it
+	response := io.MultiReader(strings.NewReader(`{"status":"success","data":`), iotest.ErrReader(errors.New("read past the data key")))
+	if !isLokiResponse(response) {
+		t.Error("Expected a query_range response")
+	}
+
+	for _, input := range []string{
+		`{"labels":{"job":"nginx"},"line":"data","timestamp":"2024-03-14T09:30:01Z"}`,
+		`not JSON`,
+		``,
+	} {
+		if isLokiResponse(strings.NewReader(input)) {
+			t.Errorf("Expected %q not to be a query_range response", input)
+		}
+	}
+}
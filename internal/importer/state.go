@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileState records how far a single file has been imported
+type FileState struct {
+	Records   int64 `json:"records"`
+	Completed bool  `json:"completed"`
+}
+
+// State tracks import progress across runs so interrupted imports can resume
+type State struct {
+	path  string
+	Files map[string]FileState `json:"files"`
+	mutex sync.Mutex
+}
+
+// LoadState reads the state file at path; an empty path gives an in-memory state
+func LoadState(path string) (*State, error) {
+	state := &State{
+		path:  path,
+		Files: make(map[string]FileState),
+	}
+
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to decode state file: %w", err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]FileState)
+	}
+
+	return state, nil
+}
+
+// Get returns the recorded state for a file
+func (s *State) Get(file string) FileState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Files[file]
+}
+
+// Set records the state for a file
+func (s *State) Set(file string, fileState FileState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Files[file] = fileState
+}
+
+// Save writes the state to disk atomically (no-op for in-memory state)
+func (s *State) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
@@ -9,4 +9,11 @@ type LogParser interface {
 	
 	// SetFormat configures the parser to use a specific log format
 	SetFormat(format string) error
-}
\ No newline at end of file
+}
+
+// SourceParser is implemented by parsers whose parsing depends on where a message came from
+type SourceParser interface {
+	// ParseFrom converts a raw log message string from sourceIP, received on tenant's connection,
+	// into a LogEntry; either may be empty
+	ParseFrom(rawMessage, sourceIP, tenant string) (*types.LogEntry, error)
+}
@@ -1,6 +1,92 @@
 package interfaces
 
-import "opentrail/internal/types"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// ErrSearchBusy is returned when too many searches are already running and the query could not be admitted in time
+var ErrSearchBusy = errors.New("too many concurrent searches, please try again later")
+
+// ErrArchiveRangeTooLarge is returned when a search of the archive would download more partitions
+// than allowed
+var ErrArchiveRangeTooLarge = errors.New("time range covers too many archive partitions")
+
+// ErrStorageBusy is returned for storage operations not run because the circuit breaker opened
+// after storage kept failing transiently
+var ErrStorageBusy = errors.New("storage is busy, please try again later")
+
+// ErrReprocessRunning is returned when a reprocessing job is started while another is still running
+var ErrReprocessRunning = errors.New("a reprocessing job is already running")
+
+// ReprocessManager runs background jobs that re-parse stored raw messages with the current parser
+type ReprocessManager interface {
+	// StartReprocess starts re-parsing the entries selected by query
+	StartReprocess(query types.ReprocessQuery) (*types.ReprocessStatus, error)
+
+	// ReprocessStatus reports the progress of the most recent job
+	ReprocessStatus() types.ReprocessStatus
+}
+
+// ErrReencryptRunning is returned when a re-encryption job is started while another is still running
+var ErrReencryptRunning = errors.New("a re-encryption job is already running")
+
+// ErrEncryptionDisabled is returned when a re-encryption job is started without encrypted fields
+var ErrEncryptionDisabled = errors.New("field encryption is not configured")
+
+// ReencryptManager runs background jobs that encrypt the configured fields of stored entries with the
+// current keys
+type ReencryptManager interface {
+	// StartReencrypt starts re-encrypting every stored entry
+	StartReencrypt() (*types.ReencryptStatus, error)
+
+	// ReencryptStatus reports the progress of the most recent job
+	ReencryptStatus() types.ReencryptStatus
+}
+
+// IntegrityReporter is implemented by services that keep the report of their last integrity check
+type IntegrityReporter interface {
+	// LastIntegrityReport returns the report of the most recent check, nil if none has run
+	LastIntegrityReport() *IntegrityReport
+}
+
+// AdhocScanner runs scans matching every stored entry, for searches the indexes cannot answer
+type AdhocScanner interface {
+	// Scan reads the stored entries from the newest down and calls match for each entry matching
+	// query and progress after each page read. It stops at the end of the entries, at the limit of
+	// matches, when ctx is done or when a callback fails.
+	Scan(ctx context.Context, query types.ScanQuery, match func(*types.LogEntry) error, progress func(types.ScanProgress) error) (types.ScanProgress, error)
+}
+
+// ErrInvalidReport is returned when a scheduled report's definition is rejected
+var ErrInvalidReport = errors.New("invalid report")
+
+// ReportManager schedules saved searches and keeps the summaries of their runs
+type ReportManager interface {
+	// CreateReport validates and saves a new report, which first runs on the next scheduler pass
+	CreateReport(report *types.Report) error
+
+	// Reports lists the saved reports
+	Reports() ([]types.Report, error)
+
+	// UpdateReport validates and replaces the definition of the report with its ID, keeping its
+	// results
+	UpdateReport(report *types.Report) error
+
+	// DeleteReport removes a report and its results
+	DeleteReport(id int64) error
+
+	// ReportResults returns the results of a report's runs ending after since, oldest first
+	ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error)
+
+	// AlertHistory returns the alert events after since, oldest first, of one report or of all
+	// reports when reportID is zero
+	AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error)
+}
 
 // LogService defines the interface for the central log processing service
 type LogService interface {
@@ -32,6 +118,230 @@ type LogService interface {
 	GetStats() ServiceStats
 }
 
+// SourceLogProcessor is implemented by log services that can record the address a message was received from
+type SourceLogProcessor interface {
+	// ProcessLogFrom processes a raw log message received from the given (normalized) source IP
+	ProcessLogFrom(rawMessage, sourceIP string) error
+}
+
+// TrackedLogProcessor is implemented by log services that report parse failures of queued messages
+// back to whoever submitted them
+type TrackedLogProcessor interface {
+	// ProcessLogTracked processes a raw log message like ProcessLogFrom, calling parseFailed from the
+	// processing goroutine if the message cannot be parsed; sourceIP may be empty
+	ProcessLogTracked(rawMessage, sourceIP string, parseFailed func()) error
+}
+
+// TenantLogProcessor is implemented by log services that can record which tenant a message was
+// received for
+type TenantLogProcessor interface {
+	// ProcessLogForTenant processes a raw log message like ProcessLogTracked, recording the tenant
+	// the sender's connection was routed to; sender-supplied tenants are discarded
+	ProcessLogForTenant(rawMessage, sourceIP, tenant string, parseFailed func()) error
+}
+
+// AckingLogProcessor is implemented by log services that can tell senders when their messages
+// are stored
+type AckingLogProcessor interface {
+	// ProcessLogAcked processes a raw log message like ProcessLogForTenant and calls done once it
+	// is stored, or cannot be parsed, with nil, or with the error if storing it failed. done is not
+	// called if ProcessLogAcked returns an error, nor if the service stops before processing it.
+	ProcessLogAcked(rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error
+}
+
+// OrderedLogProcessor is implemented by log services that keep the entries of one connection in the
+// order its messages arrived
+type OrderedLogProcessor interface {
+	// ProcessLogInStream processes a raw log message like ProcessLogAcked, with done optional, as the
+	// next message of stream, which identifies the connection. The entries of a stream are stored and
+	// sent to subscribers in the order their messages were handed over, whatever the parse workers,
+	// batching and commit notifications do.
+	ProcessLogInStream(stream int64, rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error
+}
+
+// IngestLatencyReporter is implemented by log services that measure how late entries arrive and
+// how long they take to be committed
+type IngestLatencyReporter interface {
+	// IngestLatency returns the latencies per source since startup or the last reset
+	IngestLatency() types.IngestLatency
+
+	// ResetIngestLatency forgets the per-source latencies
+	ResetIngestLatency()
+}
+
+// EntrySizeReporter is implemented by log services that measure the size of the messages and
+// structured data of the entries of each app
+type EntrySizeReporter interface {
+	// EntrySizes returns the sizes per app since startup or the last reset
+	EntrySizes() types.EntrySizes
+
+	// ResetEntrySizes forgets the per-app sizes
+	ResetEntrySizes()
+}
+
+// SequenceGapReporter is implemented by log services that follow the sequence numbers senders give
+// their messages
+type SequenceGapReporter interface {
+	// SequenceGaps returns the numbered sources and their gaps since startup or the last reset
+	SequenceGaps() types.SequenceReport
+
+	// ResetSequenceGaps forgets the sequence numbering of every source
+	ResetSequenceGaps()
+}
+
+// SearchComparer is implemented by log services that compare the results of a search over two
+// time ranges
+type SearchComparer interface {
+	// Compare returns the changes in entry counts per severity and message pattern between the
+	// baseline and the current range
+	Compare(query types.CompareQuery) (*types.CompareResult, error)
+}
+
+// ErrInvalidEvent is returned when an event marker is rejected
+var ErrInvalidEvent = errors.New("invalid event")
+
+// EventRecorder is implemented by log services that keep deploy and other event markers to show
+// alongside log volume
+type EventRecorder interface {
+	// RecordEvent validates and saves a new event
+	RecordEvent(event *types.Event) error
+
+	// Events returns the events matching query, oldest first
+	Events(query types.EventQuery) ([]types.Event, error)
+
+	// DeleteEvent removes an event
+	DeleteEvent(id int64) error
+}
+
+// ErrInvalidShare is returned when a share is rejected
+var ErrInvalidShare = errors.New("invalid share")
+
+// ShareManager is implemented by log services that freeze log entries into shares opened by token
+type ShareManager interface {
+	// CreateShare validates and saves a share of entries, opened until ttl from now or for
+	// types.DefaultShareTTL when ttl is zero, setting its token and times
+	CreateShare(share *types.Share, ttl time.Duration) error
+
+	// Share returns a share with its entries unless it expired
+	Share(token string) (*types.Share, error)
+
+	// Shares lists the shares that did not expire, without their entries
+	Shares() ([]types.Share, error)
+
+	// DeleteShare removes a share before it expires
+	DeleteShare(token string) error
+}
+
+// ErrInvalidPreferences is returned when the preferences saved for a user are rejected
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+// PreferencesManager is implemented by log services that keep the web interface preferences of each
+// user, so they follow the user across browsers
+type PreferencesManager interface {
+	// UserPreferences returns the preferences of a user, empty if none were saved
+	UserPreferences(user string) (*types.UserPreferences, error)
+
+	// SaveUserPreferences validates and replaces the preferences of a user
+	SaveUserPreferences(user string, preferences *types.UserPreferences) error
+
+	// AddRecentQuery records the filters of a search as the most recent query of a user and returns
+	// the preferences with it
+	AddRecentQuery(user string, query json.RawMessage) (*types.UserPreferences, error)
+
+	// DeleteUserPreferences removes the preferences of a user
+	DeleteUserPreferences(user string) error
+}
+
+// ErrInvalidAccount is returned when a user or API token is rejected
+var ErrInvalidAccount = errors.New("invalid account")
+
+// AccountManager is implemented by log services that keep users and API tokens, which authenticate
+// requests alongside the accounts of the server's configuration
+type AccountManager interface {
+	// CreateUser validates and saves a new user with the password and role
+	CreateUser(username, password, role string) (*types.User, error)
+
+	// Users lists the users by name
+	Users() ([]types.User, error)
+
+	// UpdateUser changes the password of a user unless it is empty, and its role unless it is empty
+	UpdateUser(username, password, role string) (*types.User, error)
+
+	// DeleteUser removes a user
+	DeleteUser(username string) error
+
+	// Authenticate returns the user with the password, ErrUserNotFound when there is none
+	Authenticate(username, password string) (*types.User, error)
+
+	// CreateAPIToken validates and saves a new API token valid until ttl from now, or without
+	// expiry when ttl is zero, setting its token, ID and times
+	CreateAPIToken(token *types.APIToken, ttl time.Duration) error
+
+	// APITokens lists the API tokens, newest first
+	APITokens() ([]types.APIToken, error)
+
+	// DeleteAPIToken removes an API token
+	DeleteAPIToken(id int64) error
+
+	// AuthenticateToken returns the API token a bearer token belongs to, ErrAPITokenNotFound when it
+	// is unknown or expired
+	AuthenticateToken(token string) (*types.APIToken, error)
+}
+
+// ErrInvalidRetention is returned for retention periods out of range
+var ErrInvalidRetention = errors.New("invalid retention")
+
+// RetentionManager is implemented by log services whose retention period can be changed at runtime,
+// persisting across restarts
+type RetentionManager interface {
+	// Retention returns the retention period in effect
+	Retention() types.RetentionStatus
+
+	// UpdateRetention sets and stores the retention period, overriding the configured one
+	UpdateRetention(days int) (types.RetentionStatus, error)
+
+	// ResetRetention removes the stored retention period, going back to the configured one
+	ResetRetention() (types.RetentionStatus, error)
+}
+
+// ErrTailBufferDisabled is returned when searching the tail buffer while it is disabled
+var ErrTailBufferDisabled = errors.New("tail buffer is disabled")
+
+// ErrInvalidBufferQuery is returned when a search of the tail buffer cannot be evaluated in memory
+var ErrInvalidBufferQuery = errors.New("invalid tail buffer query")
+
+// TailBufferReader is implemented by log services that hold the entries of the last minutes in
+// memory, so the live tail can be searched without hitting storage
+type TailBufferReader interface {
+	// RecentBuffer returns the buffered entries matching query, the last received first
+	RecentBuffer(query types.SearchQuery) (*types.RecentBuffer, error)
+}
+
+// ErrReadOnly is returned for messages received while ingestion is paused by the read-only or
+// maintenance mode
+var ErrReadOnly = errors.New("ingestion is paused")
+
+// ErrMemoryLimit is returned for messages that waited too long for pending messages to release
+// memory while they held the configured memory limit
+var ErrMemoryLimit = errors.New("memory limit reached, please try again later")
+
+// ErrStorageUnavailable is returned to senders waiting for an acknowledgement of messages received
+// in the degraded mode that could not be spooled either
+var ErrStorageUnavailable = errors.New("storage is unavailable")
+
+// ErrInvalidMode is returned for unknown operating modes
+var ErrInvalidMode = errors.New("invalid mode")
+
+// ModeController is implemented by log services that can be put into the read-only or
+// maintenance mode, e.g. during migrations, restores and disk-pressure incidents
+type ModeController interface {
+	// Mode returns the current operating mode
+	Mode() types.ModeStatus
+
+	// SetMode switches to a mode, with an optional notice for rejected clients
+	SetMode(mode, message string) (types.ModeStatus, error)
+}
+
 // ServiceStats represents statistics about the log service
 type ServiceStats struct {
 	ProcessedLogs     int64 `json:"processed_logs"`
@@ -39,4 +349,30 @@ type ServiceStats struct {
 	ActiveSubscribers int   `json:"active_subscribers"`
 	QueueSize         int   `json:"queue_size"`
 	IsRunning         bool  `json:"is_running"`
+	RejectedSearches  int64 `json:"rejected_searches"`
+	// SanitizedLogs counts entries stored with invalid UTF-8 replaced, control characters escaped
+	// or text normalized to NFC
+	SanitizedLogs int64 `json:"sanitized_logs"`
+	// LimitedLogs counts entries stored with structured data truncated or moved into the overflow
+	// parameter for exceeding its limits
+	LimitedLogs int64 `json:"limited_logs"`
+	// RejectedLogs counts messages rejected while ingestion was paused
+	RejectedLogs int64 `json:"rejected_logs"`
+	// CachedSearches counts searches answered from the search cache
+	CachedSearches int64 `json:"cached_searches"`
+	// DuplicateLogs counts entries not stored again because their idempotency key or uid was already stored
+	DuplicateLogs int64 `json:"duplicate_logs"`
+	// PendingMemoryBytes approximates the memory held by messages not yet written to storage, and
+	// MemoryLimitBytes is the limit on it (0 when unlimited)
+	PendingMemoryBytes int64 `json:"pending_memory_bytes"`
+	MemoryLimitBytes   int64 `json:"memory_limit_bytes"`
+	// SubscriberMemoryBytes approximates the memory held by entries buffered for live tail subscribers
+	SubscriberMemoryBytes int64 `json:"subscriber_memory_bytes"`
+	// MemoryRejectedLogs counts messages rejected because the memory limit was held too long
+	MemoryRejectedLogs int64 `json:"memory_rejected_logs"`
+	// SpooledLogs counts entries spooled in the degraded mode, ReplayedLogs those stored from the
+	// spool once storage recovered, and UnstoredLogs those only sent to subscribers
+	SpooledLogs  int64 `json:"spooled_logs"`
+	ReplayedLogs int64 `json:"replayed_logs"`
+	UnstoredLogs int64 `json:"unstored_logs"`
 }
\ No newline at end of file
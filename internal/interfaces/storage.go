@@ -1,6 +1,12 @@
 package interfaces
 
-import "opentrail/internal/types"
+import (
+	"errors"
+	"time"
+
+	"opentrail/internal/entryid"
+	"opentrail/internal/types"
+)
 
 // LogStorage defines the interface for log storage operations
 type LogStorage interface {
@@ -18,4 +24,337 @@ type LogStorage interface {
 	
 	// Close closes the storage connection
 	Close() error
-}
\ No newline at end of file
+}
+
+// CommitNotifier is implemented by storage backends whose Store returns before the entry is
+// written, so callers can learn when it is
+type CommitNotifier interface {
+	// StoreNotify queues a log entry like Store and calls committed once it is written, with the
+	// error if writing it failed. committed is not called if StoreNotify returns an error.
+	StoreNotify(entry *types.LogEntry, committed func(error)) error
+}
+
+// IntegrityChecker is implemented by components that can verify on-disk consistency
+type IntegrityChecker interface {
+	// CheckIntegrity runs a consistency check; quick selects the cheaper variant
+	CheckIntegrity(quick bool) (*IntegrityReport, error)
+}
+
+// HistogramProvider is implemented by storage backends that maintain per-minute rollups
+type HistogramProvider interface {
+	// Histogram returns entry counts per interval, served from pre-aggregated rollups
+	Histogram(query types.HistogramQuery) (*types.Histogram, error)
+}
+
+// FacetProvider is implemented by storage backends that maintain per-field value rollups
+type FacetProvider interface {
+	// Facets returns the top values and distinct value counts of the requested fields
+	Facets(query types.FacetQuery) (*types.FacetResult, error)
+}
+
+// FieldCatalog is implemented by storage backends that record field names and values for autocompletion
+type FieldCatalog interface {
+	// Fields lists known field names starting with prefix
+	Fields(prefix string, limit int) ([]types.FieldInfo, error)
+
+	// FieldValues lists the most recently seen values of a field
+	FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error)
+}
+
+// ErrInvalidPromotion is returned when a field cannot be promoted to a column
+var ErrInvalidPromotion = errors.New("field cannot be promoted")
+
+// ErrFieldNotPromoted is returned when demoting a field that has no promoted column
+var ErrFieldNotPromoted = errors.New("field is not promoted")
+
+// FieldPromoter is implemented by storage backends that can materialize structured data keys as indexed columns
+type FieldPromoter interface {
+	// PromoteField adds an indexed column for a "sdid.param" field; the index is built in the background
+	PromoteField(field string) (*types.PromotedField, error)
+
+	// DemoteField drops the column and index of a promoted field
+	DemoteField(field string) error
+
+	// PromotedFields lists the promoted fields and their index status
+	PromotedFields() ([]types.PromotedField, error)
+}
+
+// Reprocessor is implemented by storage backends that keep raw messages and can re-parse them
+type Reprocessor interface {
+	// ReprocessBatch re-parses up to limit entries with IDs above afterID and updates their fields
+	ReprocessBatch(query types.ReprocessQuery, afterID int64, limit int, parse func(raw string) (*types.LogEntry, error)) (*types.ReprocessBatch, error)
+}
+
+// Reencrypter is implemented by storage backends that can rewrite the encrypted fields of stored
+// entries, to move them to a new key or encrypt fields configured after they were stored
+type Reencrypter interface {
+	// ReencryptBatch passes up to limit entries with IDs above afterID to seal and stores the
+	// structured data of those it changed, dropping their raw messages
+	ReencryptBatch(afterID int64, limit int, seal func(*types.LogEntry) bool) (*types.ReencryptBatch, error)
+}
+
+// EntryScanner is implemented by storage backends that can read every stored entry page by page
+type EntryScanner interface {
+	// ScanPage returns up to limit entries with IDs below beforeID, or the newest entries when it is
+	// zero, by descending ID; withRaw also reads the raw messages
+	ScanPage(beforeID int64, limit int, withRaw bool) ([]*types.LogEntry, error)
+}
+
+// ErrEntryNotFound is returned when no log entry has the requested ID
+var ErrEntryNotFound = errors.New("log entry not found")
+
+// RawMessageReader is implemented by storage backends that retain the message of each entry as received
+type RawMessageReader interface {
+	// RawMessage returns the raw message of an entry, empty if none was retained
+	RawMessage(id int64) (string, error)
+}
+
+// ErrWALDisabled is returned when checkpointing a database that does not use a write-ahead log
+var ErrWALDisabled = errors.New("write-ahead log is disabled")
+
+// WALCheckpointer is implemented by storage backends, and the log services over them, that can
+// checkpoint their write-ahead log on demand
+type WALCheckpointer interface {
+	// Checkpoint writes the frames of the write-ahead log to the database in one of the
+	// types.CheckpointModes
+	Checkpoint(mode string) (*types.CheckpointResult, error)
+}
+
+// StorageUsageReader is implemented by storage backends that can measure their disk usage
+type StorageUsageReader interface {
+	// StorageUsage returns the file sizes and per-day entry counts; the capacity fields are left unset
+	StorageUsage() (*types.StorageUsage, error)
+}
+
+// RetentionCleaner is implemented by storage backends that report how many entries retention removed
+type RetentionCleaner interface {
+	// CleanupExpired removes the entries older than retentionDays and returns how many were removed
+	CleanupExpired(retentionDays int) (int64, error)
+}
+
+// VolumeAttributor is implemented by storage backends, and the log services over them, that
+// attribute the volume ingested and stored to apps, hosts and tenants
+type VolumeAttributor interface {
+	// MeasureVolume records what each app, host and tenant occupies in storage on the day of now
+	MeasureVolume(now time.Time) error
+	// Volume reports the volume per day and in total of each value of the query's field
+	Volume(query types.VolumeQuery) (*types.VolumeReport, error)
+}
+
+// RecoveryReporter is implemented by storages, and the log services over them, that report the
+// progress of the work done on startup, such as applying the write-ahead log after a crash
+type RecoveryReporter interface {
+	RecoveryStatus() types.RecoveryStatus
+}
+
+// LogDeleter is implemented by storage backends that can delete the entries matching a search
+type LogDeleter interface {
+	// DeleteMatching deletes the entries a search query matches, returning how many were deleted;
+	// with dryRun it only counts them. Fields, Limit, Offset and Collapse of the query are ignored.
+	DeleteMatching(query types.SearchQuery, dryRun bool) (int64, error)
+}
+
+// EntryDetailReader is implemented by storage backends that can read a single entry by ID
+type EntryDetailReader interface {
+	// EntryDetail returns an entry with its raw message and hash chain link; the server fills in
+	// the metadata held in its structured data
+	EntryDetail(id int64) (*types.EntryDetail, error)
+}
+
+// RawMessageCompressor is implemented by storage backends that can compress retained raw messages
+type RawMessageCompressor interface {
+	// SetRawCompression selects whether raw messages are stored compressed
+	SetRawCompression(enabled bool)
+}
+
+// HashChainer is implemented by storage backends that can link stored entries in a hash chain
+type HashChainer interface {
+	// SetHashChain selects whether new entries are hashed and linked to the previous entry of their partition
+	SetHashChain(enabled bool)
+}
+
+// ErrDuplicateEntry is returned when an entry carries an idempotency key already stored within the
+// idempotency window, or a uid already stored; the entry is not stored again
+var ErrDuplicateEntry = errors.New("entry with this idempotency key already stored")
+
+// BulkLoader is implemented by storage backends that can insert many entries at once, bypassing
+// what Store records besides the entries themselves
+type BulkLoader interface {
+	// Load inserts entries in a single transaction, setting their IDs
+	Load(entries []*types.LogEntry) error
+}
+
+// EntryIDAssigner is implemented by storage backends that can give entries a globally unique,
+// time-sortable uid besides their row ID
+type EntryIDAssigner interface {
+	// SetEntryIDs sets the generator of the uid of new entries that have none; nil leaves them
+	// without one
+	SetEntryIDs(generator *entryid.Generator)
+}
+
+// IdempotentStore is implemented by storage backends that recognize entries sent again by their
+// idempotency key
+type IdempotentStore interface {
+	// SetIdempotencyWindow sets how long idempotency keys are remembered; zero stores every entry
+	SetIdempotencyWindow(window time.Duration)
+}
+
+// ChainVerifier is implemented by storage backends that can verify the hash chains of stored entries
+type ChainVerifier interface {
+	// VerifyChain recomputes the hash chain of one partition, or of all partitions when empty
+	VerifyChain(partition string) (*types.ChainReport, error)
+}
+
+// ErrReportNotFound is returned when no scheduled report has the requested ID
+var ErrReportNotFound = errors.New("report not found")
+
+// ErrReportExists is returned when creating a report with the name of an existing one
+var ErrReportExists = errors.New("a report with this name already exists")
+
+// ReportStore is implemented by storage backends that keep scheduled reports and the summaries of
+// their runs apart from log entries, so retention does not remove them
+type ReportStore interface {
+	// CreateReport saves a new report, setting its ID and creation time
+	CreateReport(report *types.Report) error
+
+	// Reports lists the saved reports
+	Reports() ([]types.Report, error)
+
+	// UpdateReport replaces the definition of the report with its ID, keeping its results, and
+	// fills in its state; an alert that is switched off stops firing
+	UpdateReport(report *types.Report) error
+
+	// DeleteReport removes a report and its results
+	DeleteReport(id int64) error
+
+	// RunReport summarizes the entries matching query, a compiled report query covering the
+	// run's time window, stores the result and records the run on the report
+	RunReport(report types.Report, query types.SearchQuery) (*types.ReportResult, error)
+
+	// ReportResults returns the results of a report's runs ending after since, oldest first
+	ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error)
+
+	// AlertHistory returns the alert events after since, oldest first, of one report or of all
+	// reports when reportID is zero
+	AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error)
+}
+
+// ErrEventNotFound is returned when no event marker has the requested ID
+var ErrEventNotFound = errors.New("event not found")
+
+// EventStore is implemented by storage backends that keep deploy and other event markers apart
+// from log entries, so retention does not remove them
+type EventStore interface {
+	// CreateEvent saves a new event, setting its ID and creation time
+	CreateEvent(event *types.Event) error
+
+	// Events returns the events matching query, oldest first
+	Events(query types.EventQuery) ([]types.Event, error)
+
+	// DeleteEvent removes an event
+	DeleteEvent(id int64) error
+}
+
+// PreferencesStore is implemented by storage backends that keep the web interface preferences of
+// each user
+type PreferencesStore interface {
+	// UserPreferences returns the preferences of a user, empty if none were saved
+	UserPreferences(user string) (*types.UserPreferences, error)
+
+	// SaveUserPreferences replaces the preferences of a user, setting their update time
+	SaveUserPreferences(user string, preferences *types.UserPreferences) error
+
+	// DeleteUserPreferences removes the preferences of a user
+	DeleteUserPreferences(user string) error
+}
+
+// ErrShareNotFound is returned when no share has the requested token or it expired
+var ErrShareNotFound = errors.New("share not found")
+
+// ShareStore is implemented by storage backends that keep frozen copies of log entries shared by
+// token apart from log entries, so retention does not remove them
+type ShareStore interface {
+	// CreateShare saves a share with its entries, setting its entry count
+	CreateShare(share *types.Share) error
+
+	// Share returns a share with its entries unless it expired by now
+	Share(token string, now time.Time) (*types.Share, error)
+
+	// Shares lists the shares not expired by now without their entries, newest first
+	Shares(now time.Time) ([]types.Share, error)
+
+	// DeleteShare removes a share
+	DeleteShare(token string) error
+
+	// DeleteExpiredShares removes the shares expired by now, returning how many
+	DeleteExpiredShares(now time.Time) (int64, error)
+}
+
+// ErrUserNotFound is returned when no user has the requested name, or the password does not match
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when creating a user with the name of an existing one
+var ErrUserExists = errors.New("a user with this name already exists")
+
+// ErrAPITokenNotFound is returned when no API token has the requested ID or hash, or it expired
+var ErrAPITokenNotFound = errors.New("API token not found")
+
+// AccountStore is implemented by storage backends that keep the users and API tokens managed
+// through the admin API
+type AccountStore interface {
+	// CreateUser saves a new user with the hash of its password, setting its times
+	CreateUser(user *types.User, passwordHash string) error
+
+	// Users lists the users by name
+	Users() ([]types.User, error)
+
+	// UserCredentials returns a user with the hash of its password
+	UserCredentials(username string) (*types.User, string, error)
+
+	// UpdateUser changes the role of a user unless role is empty, and the hash of its password
+	// unless passwordHash is empty
+	UpdateUser(username, role, passwordHash string) (*types.User, error)
+
+	// DeleteUser removes a user
+	DeleteUser(username string) error
+
+	// CreateAPIToken saves a new API token by the hash of its token, setting its ID and creation time
+	CreateAPIToken(token *types.APIToken, tokenHash string) error
+
+	// APITokens lists the API tokens, newest first
+	APITokens() ([]types.APIToken, error)
+
+	// APIToken returns the API token with the hash unless it expired by now
+	APIToken(tokenHash string, now time.Time) (*types.APIToken, error)
+
+	// TouchAPIToken records now as the last use of an API token
+	TouchAPIToken(id int64, now time.Time) error
+
+	// DeleteAPIToken removes an API token
+	DeleteAPIToken(id int64) error
+}
+
+// ErrSettingNotFound is returned when no value is stored for a setting
+var ErrSettingNotFound = errors.New("setting not found")
+
+// SettingStore is implemented by storage backends that keep the settings changed at runtime through
+// the admin API, which take precedence over the server's configuration
+type SettingStore interface {
+	// Setting returns the value stored for a setting, ErrSettingNotFound if there is none
+	Setting(key string) (string, error)
+
+	// SaveSetting stores the value of a setting, replacing the previous one
+	SaveSetting(key, value string) error
+
+	// DeleteSetting removes the value of a setting, if any
+	DeleteSetting(key string) error
+}
+
+// IntegrityReport describes the outcome of a storage integrity check
+type IntegrityReport struct {
+	OK         bool      `json:"ok"`
+	Mode       string    `json:"mode"`
+	Problems   []string  `json:"problems,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
@@ -0,0 +1,205 @@
+// Package lifecycle announces changes to what storage holds, such as retention removing expired
+// entries or a backup being written, in the server log and to an optional webhook, so that
+// external automation can react to them
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Event types
+const (
+	// RetentionCompleted is emitted after a retention run removed the entries past the retention period
+	RetentionCompleted = "retention_completed"
+	// LogsDeleted is emitted after an admin deleted the entries matching a search
+	LogsDeleted = "logs_deleted"
+	// BackupCompleted is emitted by "opentrail dump" after it wrote a backup of the database
+	BackupCompleted = "backup_completed"
+)
+
+const (
+	// queueSize bounds the events waiting for delivery; further events are dropped
+	queueSize = 100
+	// sendTimeout bounds one webhook delivery
+	sendTimeout = 10 * time.Second
+	// sendAttempts is how often a delivery is tried before the event is dropped
+	sendAttempts = 3
+	// maxErrorBody bounds how much of a rejected delivery's response is reported
+	maxErrorBody = 512
+)
+
+// retryDelay is the wait before the second attempt, doubling for each further one; tests shorten it
+var retryDelay = time.Second
+
+// Event describes one change to what storage holds
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Host is the host name of the process that made the change
+	Host string `json:"host,omitempty"`
+	// Entries is the number of entries removed or written
+	Entries int64 `json:"entries"`
+	// Details are type-specific values, such as the retention cutoff or the user who deleted entries
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// String formats the event for the server log
+func (e Event) String() string {
+	names := make([]string, 0, len(e.Details))
+	for name := range e.Details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s entries=%d", e.Type, e.Entries)
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=%q", name, e.Details[name])
+	}
+	return b.String()
+}
+
+// Notifier logs events and posts them as JSON to a webhook in the background. A nil Notifier
+// only logs.
+type Notifier struct {
+	url     string
+	client  *http.Client
+	queue   chan Event
+	dropped atomic.Int64
+}
+
+// NewNotifier creates a notifier posting to url, which must be an http(s) URL; an empty url only logs
+func NewNotifier(url string) (*Notifier, error) {
+	if url != "" && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("lifecycle webhook must be an http(s) URL, got %q", url)
+	}
+	return &Notifier{
+		url:    url,
+		client: &http.Client{Timeout: sendTimeout},
+		queue:  make(chan Event, queueSize),
+	}, nil
+}
+
+// Emit logs an event and queues it for the webhook without blocking, filling in its time and host
+func (n *Notifier) Emit(event Event) {
+	event = stamp(event)
+	log.Printf("Lifecycle event %s", event)
+	if n == nil || n.url == "" {
+		return
+	}
+	select {
+	case n.queue <- event:
+	default:
+		n.dropped.Add(1)
+		log.Printf("Lifecycle webhook queue is full, dropped %s event", event.Type)
+	}
+}
+
+// Dropped returns the number of events that could not be delivered
+func (n *Notifier) Dropped() int64 {
+	if n == nil {
+		return 0
+	}
+	return n.dropped.Load()
+}
+
+// Run delivers queued events until ctx is done, then tries once more to deliver those still queued
+func (n *Notifier) Run(ctx context.Context) {
+	if n == nil || n.url == "" {
+		return
+	}
+	for {
+		select {
+		case event := <-n.queue:
+			n.deliver(ctx, event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-n.queue:
+					n.deliver(context.Background(), event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Send logs an event and posts it to the webhook right away, for short-lived commands
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	event = stamp(event)
+	log.Printf("Lifecycle event %s", event)
+	if n == nil || n.url == "" {
+		return nil
+	}
+	return n.post(ctx, event)
+}
+
+// deliver posts an event, retrying failed attempts while ctx is not done
+func (n *Notifier) deliver(ctx context.Context, event Event) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := n.post(ctx, event)
+		if err == nil {
+			return
+		}
+		if attempt == sendAttempts || ctx.Err() != nil {
+			n.dropped.Add(1)
+			log.Printf("Failed to deliver %s event to the lifecycle webhook: %v", event.Type, err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+		}
+	}
+}
+
+// post sends one event to the webhook
+func (n *Notifier) post(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// stamp fills in the time and host of an event that leaves them unset
+func stamp(event Event) Event {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Host == "" {
+		event.Host, _ = os.Hostname()
+	}
+	return event
+}
@@ -0,0 +1,129 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver records the events posted to it, rejecting the first failures requests
+func receiver(t *testing.T, failures int) (string, func() []Event) {
+	t.Helper()
+	var mu sync.Mutex
+	var events []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+		}
+		events = append(events, event)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Event(nil), events...)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	retryDelay = time.Millisecond
+	url, received := receiver(t, 1)
+	notifier, err := NewNotifier(url)
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(done)
+	}()
+
+	// The first delivery is rejected and retried
+	notifier.Emit(Event{Type: RetentionCompleted, Entries: 42, Details: map[string]string{"retention_days": "30"}})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	events := received()
+	if len(events) != 1 {
+		t.Fatalf("Expected one event, got %+v", events)
+	}
+	event := events[0]
+	if event.Type != RetentionCompleted || event.Entries != 42 || event.Details["retention_days"] != "30" ||
+		event.Time.IsZero() || event.Host == "" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if notifier.Dropped() != 0 {
+		t.Errorf("Expected no dropped events, got %d", notifier.Dropped())
+	}
+}
+
+func TestNotifier_DrainsOnShutdown(t *testing.T) {
+	url, received := receiver(t, 0)
+	notifier, err := NewNotifier(url)
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+
+	notifier.Emit(Event{Type: LogsDeleted, Entries: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	notifier.Run(ctx)
+	if events := received(); len(events) != 1 || events[0].Type != LogsDeleted {
+		t.Errorf("Expected the queued event to be delivered on shutdown, got %+v", events)
+	}
+}
+
+func TestNotifier_Send(t *testing.T) {
+	retryDelay = time.Millisecond
+	url, received := receiver(t, 1)
+	notifier, err := NewNotifier(url)
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+
+	if err := notifier.Send(context.Background(), Event{Type: BackupCompleted}); err == nil {
+		t.Error("Expected a rejected delivery to be reported")
+	}
+	if err := notifier.Send(context.Background(), Event{Type: BackupCompleted, Entries: 7}); err != nil {
+		t.Errorf("Send failed: %v", err)
+	}
+	if events := received(); len(events) != 1 || events[0].Entries != 7 {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	// Without a webhook, events are only logged
+	var none *Notifier
+	none.Emit(Event{Type: LogsDeleted})
+	if err := none.Send(context.Background(), Event{Type: BackupCompleted}); err != nil {
+		t.Errorf("Expected a nil notifier to only log, got %v", err)
+	}
+
+	if _, err := NewNotifier("ftp://example.com/hook"); err == nil {
+		t.Error("Expected a non-HTTP URL to be rejected")
+	}
+}
+
+func TestEvent_String(t *testing.T) {
+	event := Event{Type: LogsDeleted, Entries: 5, Details: map[string]string{"user": "admin", "filters": "hostname=web01"}}
+	if got, want := event.String(), `logs_deleted entries=5 filters="hostname=web01" user="admin"`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,272 @@
+// Package logformat renders log entries as text lines through Go templates, so exports and
+// forwarders can produce the layouts downstream consumers expect, such as classic syslog lines or
+// Apache's combined log format. Formats are defined once and referred to by name.
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// Built-in formats, available without being defined
+const (
+	// FormatRFC3164 is the classic BSD syslog line, e.g. "<34>Oct 15 10:30:00 web01 sshd[42]: message"
+	FormatRFC3164 = "rfc3164"
+	// FormatRFC5424 is the entry as an RFC5424 message, with its structured data
+	FormatRFC5424 = "rfc5424"
+)
+
+// builtinTemplates are the templates of the built-in formats
+var builtinTemplates = map[string]string{
+	FormatRFC3164: `<{{.Priority}}>{{.Timestamp.Format "Jan _2 15:04:05"}} {{nilvalue .Hostname}} {{nilvalue .AppName}}{{with .ProcID}}[{{.}}]{{end}}: {{.Message}}`,
+	FormatRFC5424: `<{{.Priority}}>1 {{rfc3339 .Timestamp}} {{nilvalue .Hostname}} {{nilvalue .AppName}} {{nilvalue .ProcID}} {{nilvalue .MsgID}} {{sdata .}}{{with .Message}} {{.}}{{end}}`,
+}
+
+// Definition is one entry of an output format file
+type Definition struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+	// ContentType is the media type of exports in the format, text/plain by default
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// Format renders entries through a template, one line per entry
+type Format struct {
+	name        string
+	tmpl        *template.Template
+	contentType string
+}
+
+// severityNames are the syslog severity keywords, emergency first
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// facilityNames are the syslog facility keywords by code
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5",
+	"local6", "local7",
+}
+
+// New compiles a format's template
+func New(name, text, contentType string) (*Format, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid output format name %q, expected lower case letters, digits, - and _", name)
+	}
+	funcs := template.FuncMap{
+		"severity": func(severity int) string { return keyword(severityNames, "severity", severity) },
+		"facility": func(facility int) string { return keyword(facilityNames, "facility", facility) },
+		"sd":       structuredDataValue,
+		"sdata":    structuredData,
+		"nilvalue": func(value string) string {
+			if value == "" {
+				return "-"
+			}
+			return value
+		},
+		"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+		"unix":    func(t time.Time) int64 { return t.Unix() },
+		"utc":     func(t time.Time) time.Time { return t.UTC() },
+		"quote":   func(value string) string { data, _ := json.Marshal(value); return string(data) },
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"default": func(fallback, value string) string {
+			if value == "" {
+				return fallback
+			}
+			return value
+		},
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for output format %s: %w", name, err)
+	}
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return &Format{name: name, tmpl: tmpl, contentType: contentType}, nil
+}
+
+// Name returns the name the format is referred to by
+func (f *Format) Name() string {
+	return f.name
+}
+
+// ContentType returns the media type of a stream of entries in the format
+func (f *Format) ContentType() string {
+	return f.contentType
+}
+
+// Encode renders an entry, without a trailing newline. Line breaks in the output, e.g. from a
+// multi-line message, are replaced by spaces so every entry stays on one line.
+func (f *Format) Encode(entry *types.LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, entry); err != nil {
+		return nil, fmt.Errorf("failed to render entry in format %s: %w", f.name, err)
+	}
+	line := bytes.TrimRight(buf.Bytes(), "\r\n")
+	for i, c := range line {
+		if c == '\n' || c == '\r' {
+			line[i] = ' '
+		}
+	}
+	return line, nil
+}
+
+// Registry holds the output formats by name, the built-in ones included
+type Registry struct {
+	formats map[string]*Format
+}
+
+// NewRegistry creates a registry of the built-in formats and the given ones, rejecting duplicate
+// names
+func NewRegistry(formats ...*Format) (*Registry, error) {
+	registry := &Registry{formats: make(map[string]*Format, len(builtinTemplates)+len(formats))}
+	for name, text := range builtinTemplates {
+		format, err := New(name, text, "")
+		if err != nil {
+			return nil, err
+		}
+		registry.formats[name] = format
+	}
+	for _, format := range formats {
+		if _, ok := registry.formats[format.Name()]; ok {
+			return nil, fmt.Errorf("duplicate output format %s", format.Name())
+		}
+		registry.formats[format.Name()] = format
+	}
+	return registry, nil
+}
+
+// LoadFormats reads a JSON array of format definitions and creates a registry of them
+func LoadFormats(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output formats: %w", err)
+	}
+	var definitions []Definition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to parse output formats %s: %w", path, err)
+	}
+
+	formats := make([]*Format, 0, len(definitions))
+	for _, definition := range definitions {
+		format, err := New(definition.Name, definition.Template, definition.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, format)
+	}
+	return NewRegistry(formats...)
+}
+
+// IsBuiltin reports whether a name is that of a built-in format
+func IsBuiltin(name string) bool {
+	_, ok := builtinTemplates[name]
+	return ok
+}
+
+// Format returns the format with the given name
+func (r *Registry) Format(name string) (*Format, bool) {
+	if r == nil {
+		return nil, false
+	}
+	format, ok := r.formats[name]
+	return format, ok
+}
+
+// Names lists the format names in order
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validName reports whether a format name is non-empty and made of lower case letters, digits, -
+// and _, so it can be passed as a flag value or query parameter as is
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// keyword names a syslog code, falling back to the prefix and the number for unknown ones
+func keyword(names []string, prefix string, code int) string {
+	if code < 0 || code >= len(names) {
+		return fmt.Sprintf("%s%d", prefix, code)
+	}
+	return names[code]
+}
+
+// structuredDataValue returns a structured data parameter of an entry ("sdid.param"), empty if absent
+func structuredDataValue(entry *types.LogEntry, field string) string {
+	sdID, param, ok := strings.Cut(field, ".")
+	if !ok || entry == nil {
+		return ""
+	}
+	return params(entry.StructuredData[sdID])[param]
+}
+
+// structuredData formats the structured data of an entry as RFC5424 SD-ELEMENTs, sorted by ID and
+// parameter name, or "-" if it has none
+func structuredData(entry *types.LogEntry) string {
+	if entry == nil || len(entry.StructuredData) == 0 {
+		return "-"
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	var b strings.Builder
+	for _, id := range sortedKeys(entry.StructuredData) {
+		element := params(entry.StructuredData[id])
+		b.WriteString("[" + id)
+		for _, key := range sortedKeys(element) {
+			fmt.Fprintf(&b, ` %s="%s"`, key, escaper.Replace(element[key]))
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
+
+// params returns the parameters of a structured data element, which are strings when parsed and
+// arbitrary JSON values when loaded from storage
+func params(element interface{}) map[string]string {
+	switch element := element.(type) {
+	case map[string]string:
+		return element
+	case map[string]interface{}:
+		values := make(map[string]string, len(element))
+		for key, value := range element {
+			values[key] = fmt.Sprint(value)
+		}
+		return values
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package logformat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func testEntry() *types.LogEntry {
+	return &types.LogEntry{
+		Priority:  34,
+		Facility:  4,
+		Severity:  2,
+		Timestamp: time.Date(2026, 10, 5, 9, 3, 7, 250000000, time.UTC),
+		Hostname:  "web01",
+		AppName:   "sshd",
+		ProcID:    "42",
+		Message:   "failed password",
+		StructuredData: map[string]interface{}{
+			"http":   map[string]interface{}{"method": "GET", "status": float64(404), "path": `/a"b]`},
+			"origin": map[string]string{"ip": "192.0.2.1"},
+		},
+	}
+}
+
+func TestBuiltinFormats(t *testing.T) {
+	registry, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+
+	tests := map[string]string{
+		FormatRFC3164: "<34>Oct  5 09:03:07 web01 sshd[42]: failed password",
+		FormatRFC5424: `<34>1 2026-10-05T09:03:07.25Z web01 sshd 42 - [http method="GET" path="/a\"b\]" status="404"][origin ip="192.0.2.1"] failed password`,
+	}
+	for name, want := range tests {
+		format, ok := registry.Format(name)
+		if !ok {
+			t.Fatalf("Expected built-in format %s", name)
+		}
+		got, err := format.Encode(testEntry())
+		if err != nil {
+			t.Fatalf("Encode %s failed: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+
+	format, _ := registry.Format(FormatRFC5424)
+	got, _ := format.Encode(&types.LogEntry{Priority: 14, Timestamp: time.Date(2026, 10, 5, 9, 3, 7, 0, time.UTC)})
+	if string(got) != "<14>1 2026-10-05T09:03:07Z - - - - -" {
+		t.Errorf("Expected nil values for empty fields, got %q", got)
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formats.json")
+	os.WriteFile(path, []byte(`[
+		{"name": "apache-combined", "template": "{{sd . \"origin.ip\"}} - - [{{.Timestamp.Format \"02/Jan/2006:15:04:05 -0700\"}}] \"{{sd . \"http.method\"}} {{sd . \"http.path\"}} HTTP/1.1\" {{sd . \"http.status\"}} {{default \"-\" (sd . \"http.bytes\")}}"},
+		{"name": "json-lines", "template": "{\"host\":{{quote .Hostname}},\"level\":{{quote (severity .Severity)}},\"facility\":{{quote (facility .Facility)}},\"msg\":{{quote .Message}}}", "content_type": "application/x-ndjson"}
+	]`), 0644)
+
+	registry, err := LoadFormats(path)
+	if err != nil {
+		t.Fatalf("LoadFormats failed: %v", err)
+	}
+	if names := registry.Names(); strings.Join(names, ",") != "apache-combined,json-lines,rfc3164,rfc5424" {
+		t.Errorf("Unexpected format names: %v", names)
+	}
+
+	apache, _ := registry.Format("apache-combined")
+	got, _ := apache.Encode(testEntry())
+	if want := `192.0.2.1 - - [05/Oct/2026:09:03:07 +0000] "GET /a"b] HTTP/1.1" 404 -`; string(got) != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	jsonLines, _ := registry.Format("json-lines")
+	entry := testEntry()
+	entry.Message = "first line\nsecond line\n"
+	got, _ = jsonLines.Encode(entry)
+	if want := `{"host":"web01","level":"crit","facility":"auth","msg":"first line\nsecond line\n"}`; string(got) != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if jsonLines.ContentType() != "application/x-ndjson" || apache.ContentType() != "text/plain; charset=utf-8" {
+		t.Errorf("Unexpected content types %q and %q", jsonLines.ContentType(), apache.ContentType())
+	}
+}
+
+func TestFormat_KeepsEntriesOnOneLine(t *testing.T) {
+	format, err := New("plain", "{{.Hostname}}: {{.Message}}\n", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	got, _ := format.Encode(&types.LogEntry{Hostname: "web01", Message: "panic: boom\n\tgoroutine 1\r\n"})
+	if string(got) != "web01: panic: boom \tgoroutine 1" {
+		t.Errorf("Expected a single line, got %q", got)
+	}
+}
+
+func TestFormat_Invalid(t *testing.T) {
+	for _, name := range []string{"", "Apache", "with space"} {
+		if _, err := New(name, "{{.Message}}", ""); err == nil {
+			t.Errorf("Expected name %q to be rejected", name)
+		}
+	}
+	if _, err := New("broken", "{{.Message", ""); err == nil {
+		t.Error("Expected an invalid template to be rejected")
+	}
+	format, _ := New("rfc3164", "{{.Message}}", "")
+	if _, err := NewRegistry(format); err == nil {
+		t.Error("Expected a format named like a built-in one to be rejected")
+	}
+}
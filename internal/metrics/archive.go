@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ArchiveMetrics reports searches of the archived dumps of entries past retention
+type ArchiveMetrics struct {
+	Searches   prometheus.Counter
+	Partitions prometheus.Counter
+	Bytes      prometheus.Counter
+	// Duration is how long downloading and searching the partitions of a search took
+	Duration prometheus.Histogram
+}
+
+var (
+	archiveMetricsInstance *ArchiveMetrics
+	archiveMetricsOnce     sync.Once
+)
+
+// GetArchiveMetrics returns the singleton archive search metrics
+func GetArchiveMetrics() *ArchiveMetrics {
+	archiveMetricsOnce.Do(func() {
+		archiveMetricsInstance = &ArchiveMetrics{
+			Searches: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_archive_searches_total",
+				Help: "Total number of searches that read archived entries",
+			}),
+			Partitions: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_archive_partitions_total",
+				Help: "Total number of archive partitions downloaded by searches",
+			}),
+			Bytes: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_archive_bytes_total",
+				Help: "Total number of bytes downloaded from the archive by searches",
+			}),
+			Duration: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_archive_search_duration_seconds",
+				Help:    "Time taken to download and search the archive partitions of a search",
+				Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+			}),
+		}
+	})
+	return archiveMetricsInstance
+}
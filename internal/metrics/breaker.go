@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// BreakerMetrics reports the retries and circuit breaker states of the storage operation policies,
+// labelled by operation (read or write)
+type BreakerMetrics struct {
+	// State is 0 while the breaker is closed, 1 while half-open and 2 while open
+	State       *prometheus.GaugeVec
+	Transitions *prometheus.CounterVec
+	Retries     *prometheus.CounterVec
+	Rejected    *prometheus.CounterVec
+}
+
+var (
+	breakerMetricsInstance *BreakerMetrics
+	breakerMetricsOnce     sync.Once
+)
+
+// GetBreakerMetrics returns the singleton storage breaker metrics
+func GetBreakerMetrics() *BreakerMetrics {
+	breakerMetricsOnce.Do(func() {
+		breakerMetricsInstance = &BreakerMetrics{
+			State: promauto.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "opentrail_storage_breaker_state",
+				Help: "State of the storage circuit breaker: 0 closed, 1 half-open, 2 open",
+			}, []string{"operation"}),
+			Transitions: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_storage_breaker_transitions_total",
+				Help: "Total number of times the storage circuit breaker entered a state",
+			}, []string{"operation", "state"}),
+			Retries: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_storage_retries_total",
+				Help: "Total number of storage operations retried after failing transiently",
+			}, []string{"operation"}),
+			Rejected: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_storage_breaker_rejected_total",
+				Help: "Total number of storage operations failed without being run while the circuit breaker was open",
+			}, []string{"operation"}),
+		}
+	})
+	return breakerMetricsInstance
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// CheckpointMetrics follows the checkpoints of the write-ahead log run by the checkpoint policy
+// and the admin API
+type CheckpointMetrics struct {
+	// Checkpoints counts checkpoints by mode and by trigger: pages, schedule or manual
+	Checkpoints *prometheus.CounterVec
+	// Busy counts checkpoints that readers or writers kept from completing, by mode
+	Busy     *prometheus.CounterVec
+	Duration prometheus.Histogram
+	// WALFrames is the number of frames in the log when last measured
+	WALFrames prometheus.Gauge
+}
+
+var (
+	checkpointMetricsInstance *CheckpointMetrics
+	checkpointMetricsOnce     sync.Once
+)
+
+// GetCheckpointMetrics returns the singleton checkpoint metrics
+func GetCheckpointMetrics() *CheckpointMetrics {
+	checkpointMetricsOnce.Do(func() {
+		checkpointMetricsInstance = &CheckpointMetrics{
+			Checkpoints: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_wal_checkpoints_total",
+				Help: "Total number of write-ahead log checkpoints run by OpenTrail, by mode and trigger",
+			}, []string{"mode", "trigger"}),
+			Busy: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_wal_checkpoints_busy_total",
+				Help: "Total number of write-ahead log checkpoints that readers or writers kept from completing, by mode",
+			}, []string{"mode"}),
+			Duration: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_wal_checkpoint_duration_seconds",
+				Help:    "Time taken by write-ahead log checkpoints",
+				Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+			}),
+			WALFrames: promauto.NewGauge(prometheus.GaugeOpts{
+				Name: "opentrail_wal_frames",
+				Help: "Number of frames in the write-ahead log when last measured by the checkpoint policy",
+			}),
+		}
+	})
+	return checkpointMetricsInstance
+}
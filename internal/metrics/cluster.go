@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ClusterMetrics reports the live streams followed from the peers of a cluster, labelled by node
+type ClusterMetrics struct {
+	// Connected is 1 while the stream of a peer is connected
+	Connected  *prometheus.GaugeVec
+	Entries    *prometheus.CounterVec
+	Reconnects *prometheus.CounterVec
+	// Dropped counts merged entries a live tail client was too slow to take
+	Dropped prometheus.Counter
+}
+
+var (
+	clusterMetricsInstance *ClusterMetrics
+	clusterMetricsOnce     sync.Once
+)
+
+// GetClusterMetrics returns the singleton cluster stream metrics
+func GetClusterMetrics() *ClusterMetrics {
+	clusterMetricsOnce.Do(func() {
+		clusterMetricsInstance = &ClusterMetrics{
+			Connected: promauto.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "opentrail_cluster_stream_connected",
+				Help: "Whether the live stream of a cluster peer is connected",
+			}, []string{"node"}),
+			Entries: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_cluster_stream_entries_total",
+				Help: "Total number of entries received from the live stream of a cluster node",
+			}, []string{"node"}),
+			Reconnects: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_cluster_stream_reconnects_total",
+				Help: "Total number of times the live stream of a cluster peer was connected again",
+			}, []string{"node"}),
+			Dropped: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_cluster_stream_dropped_total",
+				Help: "Total number of merged cluster entries dropped because a live tail client was too slow",
+			}),
+		}
+	})
+	return clusterMetricsInstance
+}
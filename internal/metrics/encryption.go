@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// EncryptionMetrics counts the structured data values encrypted before storage
+type EncryptionMetrics struct {
+	Encrypted prometheus.Counter
+	// Unkeyed counts values dropped because no key applied to their entry's tenant and app
+	Unkeyed prometheus.Counter
+	// DecryptFailures counts encrypted values served as stored because their key is gone
+	DecryptFailures prometheus.Counter
+}
+
+var (
+	encryptionMetricsInstance *EncryptionMetrics
+	encryptionMetricsOnce     sync.Once
+)
+
+// GetEncryptionMetrics returns the singleton field encryption metrics
+func GetEncryptionMetrics() *EncryptionMetrics {
+	encryptionMetricsOnce.Do(func() {
+		encryptionMetricsInstance = &EncryptionMetrics{
+			Encrypted: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_field_encryptions_total",
+				Help: "Total number of structured data values encrypted, including re-encryptions under a new key",
+			}),
+			Unkeyed: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_field_encryption_unkeyed_total",
+				Help: "Total number of structured data values dropped because no encryption key applied to their entry",
+			}),
+			DecryptFailures: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_field_decryption_failures_total",
+				Help: "Total number of encrypted values that could not be decrypted for a response",
+			}),
+		}
+	})
+	return encryptionMetricsInstance
+}
@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// sizeBuckets are the bucket bounds in bytes, from one-line messages to JSON documents
+var sizeBuckets = [...]int64{64, 128, 256, 512, 1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 32 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// maxSizeApps bounds the apps tracked; the least recently seen one is dropped
+const maxSizeApps = 200
+
+// EntrySizes tracks the size of the messages and structured data of entries. Prometheus gets
+// histograms over all apps; the per-app breakdown is kept in memory.
+type EntrySizes struct {
+	MessageBytes        prometheus.Histogram
+	StructuredDataBytes prometheus.Histogram
+
+	mu   sync.Mutex
+	apps map[string]*appSizes
+}
+
+// appSizes accumulates the sizes of the entries of one app
+type appSizes struct {
+	entries        int64
+	lastSeen       time.Time
+	message        sizeHistogram
+	structuredData sizeHistogram
+}
+
+// sizeHistogram counts sizes into sizeBuckets plus an unbounded bucket
+type sizeHistogram struct {
+	counts [len(sizeBuckets) + 1]int64
+	sum    int64
+	max    int64
+}
+
+var (
+	entrySizesInstance *EntrySizes
+	entrySizesOnce     sync.Once
+)
+
+// GetEntrySizes returns the singleton entry size tracker
+func GetEntrySizes() *EntrySizes {
+	entrySizesOnce.Do(func() {
+		buckets := make([]float64, len(sizeBuckets))
+		for i, bound := range sizeBuckets {
+			buckets[i] = float64(bound)
+		}
+		entrySizesInstance = &EntrySizes{
+			MessageBytes: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_entry_message_bytes",
+				Help:    "Size of the messages received, in bytes",
+				Buckets: buckets,
+			}),
+			StructuredDataBytes: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_entry_structured_data_bytes",
+				Help:    "Size of the structured data senders attached to entries, in bytes",
+				Buckets: buckets,
+			}),
+			apps: make(map[string]*appSizes),
+		}
+	})
+	return entrySizesInstance
+}
+
+// Record counts the size of an entry received at the given time, its message as received and
+// the structured data attached by its sender
+func (s *EntrySizes) Record(entry *types.LogEntry, received time.Time) {
+	message := int64(entry.ReceivedBytes)
+	if message == 0 {
+		message = int64(len(entry.Message))
+	}
+	structuredData := structuredDataSize(entry)
+	s.MessageBytes.Observe(float64(message))
+	s.StructuredDataBytes.Observe(float64(structuredData))
+
+	name := entry.AppName
+	if name == "" {
+		name = "-"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.app(name, received)
+	app.entries++
+	app.lastSeen = received
+	app.message.observe(message)
+	app.structuredData.observe(structuredData)
+}
+
+// app returns the accumulator of an app, making room for it if needed
+func (s *EntrySizes) app(name string, now time.Time) *appSizes {
+	if app, ok := s.apps[name]; ok {
+		return app
+	}
+	if len(s.apps) >= maxSizeApps {
+		var oldest string
+		for candidate, app := range s.apps {
+			if oldest == "" || app.lastSeen.Before(s.apps[oldest].lastSeen) {
+				oldest = candidate
+			}
+		}
+		delete(s.apps, oldest)
+	}
+	app := &appSizes{lastSeen: now}
+	s.apps[name] = app
+	return app
+}
+
+// Snapshot returns the sizes per app, largest 95th percentile message first
+func (s *EntrySizes) Snapshot() types.EntrySizes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := types.EntrySizes{
+		Buckets: append([]int64(nil), sizeBuckets[:]...),
+		Apps:    make([]types.AppEntrySizes, 0, len(s.apps)),
+	}
+	for name, app := range s.apps {
+		snapshot.Apps = append(snapshot.Apps, types.AppEntrySizes{
+			AppName:        name,
+			Entries:        app.entries,
+			LastSeen:       app.lastSeen,
+			Message:        app.message.export(),
+			StructuredData: app.structuredData.export(),
+		})
+	}
+	sort.Slice(snapshot.Apps, func(i, j int) bool {
+		a, b := snapshot.Apps[i], snapshot.Apps[j]
+		if a.Message.P95Bytes != b.Message.P95Bytes {
+			return a.Message.P95Bytes > b.Message.P95Bytes
+		}
+		if a.Message.AvgBytes != b.Message.AvgBytes {
+			return a.Message.AvgBytes > b.Message.AvgBytes
+		}
+		return a.AppName < b.AppName
+	})
+	return snapshot
+}
+
+// Reset forgets the per-app sizes; the Prometheus histograms are cumulative and kept
+func (s *EntrySizes) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apps = make(map[string]*appSizes)
+}
+
+// structuredDataSize returns the size of the structured data elements of an entry as written in
+// RFC5424, [id name="value"], leaving out the metadata element the server records
+func structuredDataSize(entry *types.LogEntry) int64 {
+	var size int
+	for id, element := range entry.StructuredData {
+		if id == types.MetadataSDID {
+			continue
+		}
+		size += len(id) + 2
+		switch params := element.(type) {
+		case map[string]string:
+			for name, value := range params {
+				size += len(name) + len(value) + 4
+			}
+		case map[string]interface{}:
+			for name, value := range params {
+				if value, ok := value.(string); ok {
+					size += len(name) + len(value) + 4
+				}
+			}
+		}
+	}
+	return int64(size)
+}
+
+// observe counts one size in bytes
+func (h *sizeHistogram) observe(size int64) {
+	bucket := sort.Search(len(sizeBuckets), func(i int) bool { return sizeBuckets[i] >= size })
+	h.counts[bucket]++
+	h.sum += size
+	if size > h.max {
+		h.max = size
+	}
+}
+
+// export converts the histogram for the API, estimating percentiles from the buckets
+func (h *sizeHistogram) export() types.SizeHistogram {
+	exported := types.SizeHistogram{
+		Counts:   append([]int64(nil), h.counts[:]...),
+		SumBytes: h.sum,
+		MaxBytes: h.max,
+	}
+	var total int64
+	for _, count := range h.counts {
+		total += count
+	}
+	if total > 0 {
+		exported.AvgBytes = h.sum / total
+	}
+	exported.P50Bytes = h.percentile(total, 0.50)
+	exported.P95Bytes = h.percentile(total, 0.95)
+	exported.P99Bytes = h.percentile(total, 0.99)
+	return exported
+}
+
+// percentile returns the upper bound of the bucket holding the p-th size
+func (h *sizeHistogram) percentile(total int64, p float64) int64 {
+	if total == 0 {
+		return 0
+	}
+	rank := max(int64(float64(total)*p+0.5), 1)
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i < len(sizeBuckets) && sizeBuckets[i] < h.max {
+				return sizeBuckets[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestEntrySizes_Record(t *testing.T) {
+	sizes := GetEntrySizes()
+	sizes.Reset()
+	defer sizes.Reset()
+
+	received := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 19; i++ {
+		sizes.Record(&types.LogEntry{AppName: "api", Message: "request served", ReceivedBytes: 100}, received)
+	}
+	// One oversized entry with a JSON blob as its message and large structured data
+	blob := &types.LogEntry{
+		AppName:       "api",
+		ReceivedBytes: 50 << 10,
+		StructuredData: map[string]interface{}{
+			"request@1": map[string]string{"body": string(make([]byte, 2000))},
+		},
+	}
+	blob.SetMetadata(types.SourceIPParam, "10.0.0.5")
+	sizes.Record(blob, received)
+	sizes.Record(&types.LogEntry{Message: "no app"}, received.Add(time.Second))
+
+	snapshot := sizes.Snapshot()
+	if len(snapshot.Apps) != 2 || snapshot.Apps[0].AppName != "api" || snapshot.Apps[1].AppName != "-" {
+		t.Fatalf("Expected api before the entries without an app, got %+v", snapshot.Apps)
+	}
+	api := snapshot.Apps[0]
+	if api.Entries != 20 || api.Message.MaxBytes != 50<<10 || api.Message.AvgBytes != (19*100+50<<10)/20 {
+		t.Errorf("Unexpected message sizes %+v", api.Message)
+	}
+	if api.Message.P50Bytes != 128 || api.Message.P95Bytes != 128 || api.Message.P99Bytes != 50<<10 {
+		t.Errorf("Expected the blob to show in the 99th percentile only, got %+v", api.Message)
+	}
+	// [request@1 body="..."] without the metadata element recorded by the server
+	if want := int64(len("request@1") + 2 + len("body") + 2000 + 4); api.StructuredData.MaxBytes != want {
+		t.Errorf("Expected structured data of %d bytes, got %+v", want, api.StructuredData)
+	}
+	if len(snapshot.Buckets)+1 != len(api.Message.Counts) {
+		t.Errorf("Expected a count per bucket plus the unbounded one, got %d buckets and %d counts",
+			len(snapshot.Buckets), len(api.Message.Counts))
+	}
+	if noApp := snapshot.Apps[1]; noApp.Message.MaxBytes != int64(len("no app")) {
+		t.Errorf("Expected the message length without a received size, got %+v", noApp.Message)
+	}
+}
+
+func TestEntrySizes_AppLimit(t *testing.T) {
+	sizes := GetEntrySizes()
+	sizes.Reset()
+	defer sizes.Reset()
+
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i <= maxSizeApps; i++ {
+		sizes.Record(&types.LogEntry{AppName: fmt.Sprintf("app%d", i), ReceivedBytes: 10}, start.Add(time.Duration(i)*time.Second))
+	}
+	snapshot := sizes.Snapshot()
+	if len(snapshot.Apps) != maxSizeApps {
+		t.Fatalf("Expected %d apps, got %d", maxSizeApps, len(snapshot.Apps))
+	}
+	for _, app := range snapshot.Apps {
+		if app.AppName == "app0" {
+			t.Error("Expected the least recently seen app to be dropped")
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"sync"
+
+	"opentrail/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// maxFallbackSources bounds the sources counted per window; entries of further sources are
+	// only counted in the totals
+	maxFallbackSources = 1000
+	// maxFallbackSamples is the number of unparsed messages kept per source and window
+	maxFallbackSamples = 5
+)
+
+// FallbackTracker counts per source the messages the parser could not parse, stored whole by its
+// fallback path or rejected in strict mode, so that a sender changing its format is noticed
+type FallbackTracker struct {
+	Fallbacks prometheus.Counter
+	// Firing is the number of sources the fallback alert currently fires for
+	Firing prometheus.Gauge
+
+	mu      sync.Mutex
+	sources map[FallbackSource]*FallbackWindow
+}
+
+// FallbackSource is a sender address, per tenant
+type FallbackSource struct {
+	Tenant   string
+	SourceIP string
+}
+
+// FallbackWindow counts the messages of a source since the window started
+type FallbackWindow struct {
+	Source    FallbackSource
+	Entries   int64
+	Fallbacks int64
+	// Samples are the first unparsed messages of the window
+	Samples []*types.LogEntry
+}
+
+var (
+	fallbackTrackerInstance *FallbackTracker
+	fallbackTrackerOnce     sync.Once
+)
+
+// GetFallbackTracker returns the singleton parser fallback tracker
+func GetFallbackTracker() *FallbackTracker {
+	fallbackTrackerOnce.Do(func() {
+		fallbackTrackerInstance = &FallbackTracker{
+			Fallbacks: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_parse_fallbacks_total",
+				Help: "Total number of messages the parser could not parse, stored whole or rejected in strict mode",
+			}),
+			Firing: promauto.NewGauge(prometheus.GaugeOpts{
+				Name: "opentrail_parse_fallback_alerts_firing",
+				Help: "Number of sources whose share of unparsed messages is above the fallback alert threshold",
+			}),
+			sources: make(map[FallbackSource]*FallbackWindow),
+		}
+	})
+	return fallbackTrackerInstance
+}
+
+// Observe counts a message received from a source; fallback is set when it could not be parsed,
+// with entry holding it as stored, or as received if it was rejected
+func (t *FallbackTracker) Observe(source FallbackSource, entry *types.LogEntry, fallback bool) {
+	if fallback {
+		t.Fallbacks.Inc()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	window, ok := t.sources[source]
+	if !ok {
+		if len(t.sources) >= maxFallbackSources {
+			return
+		}
+		window = &FallbackWindow{Source: source}
+		t.sources[source] = window
+	}
+	window.Entries++
+	if fallback {
+		window.Fallbacks++
+		if len(window.Samples) < maxFallbackSamples {
+			window.Samples = append(window.Samples, entry)
+		}
+	}
+}
+
+// Rotate returns the windows of the sources seen since the previous call and starts new ones
+func (t *FallbackTracker) Rotate() []FallbackWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	windows := make([]FallbackWindow, 0, len(t.sources))
+	for _, window := range t.sources {
+		windows = append(windows, *window)
+	}
+	t.sources = make(map[FallbackSource]*FallbackWindow)
+	return windows
+}
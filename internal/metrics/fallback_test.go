@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func TestFallbackTracker_Rotate(t *testing.T) {
+	tracker := GetFallbackTracker()
+	tracker.Rotate()
+	defer tracker.Rotate()
+
+	source := FallbackSource{Tenant: "acme", SourceIP: "10.0.0.5"}
+	for i := 0; i < 10; i++ {
+		tracker.Observe(source, &types.LogEntry{Message: "unparsed"}, i%2 == 0)
+	}
+	tracker.Observe(FallbackSource{SourceIP: "10.0.0.6"}, &types.LogEntry{Message: "parsed"}, false)
+
+	windows := tracker.Rotate()
+	if len(windows) != 2 {
+		t.Fatalf("Expected windows of 2 sources, got %+v", windows)
+	}
+	for _, window := range windows {
+		if window.Source == source && (window.Entries != 10 || window.Fallbacks != 5 || len(window.Samples) != maxFallbackSamples) {
+			t.Errorf("Unexpected window %+v", window)
+		}
+	}
+	if windows := tracker.Rotate(); len(windows) != 0 {
+		t.Errorf("Expected rotation to start new windows, got %+v", windows)
+	}
+}
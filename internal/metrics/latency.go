@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// latencyBuckets are the bucket bounds in seconds, from server-side batching delays to senders
+// that were offline for hours
+var latencyBuckets = [...]float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600, 14400}
+
+// maxLatencySources bounds the sources tracked; the least recently seen one is dropped
+const maxLatencySources = 200
+
+// IngestLatency tracks how late entries are received and how long they take to be committed.
+// Prometheus gets histograms over all sources; the per-source breakdown is kept in memory.
+type IngestLatency struct {
+	SenderLag        prometheus.Histogram
+	ServerLag        prometheus.Histogram
+	FutureTimestamps prometheus.Counter
+
+	mu      sync.Mutex
+	sources map[string]*sourceLatency
+}
+
+// sourceLatency accumulates the latencies of one source
+type sourceLatency struct {
+	entries   int64
+	lastSeen  time.Time
+	senderLag latencyHistogram
+	serverLag latencyHistogram
+	future    int64
+}
+
+// latencyHistogram counts latencies into latencyBuckets plus an unbounded bucket
+type latencyHistogram struct {
+	counts [len(latencyBuckets) + 1]int64
+	sum    float64
+	max    float64
+}
+
+var (
+	ingestLatencyInstance *IngestLatency
+	ingestLatencyOnce     sync.Once
+)
+
+// GetIngestLatency returns the singleton ingestion latency tracker
+func GetIngestLatency() *IngestLatency {
+	ingestLatencyOnce.Do(func() {
+		ingestLatencyInstance = &IngestLatency{
+			SenderLag: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_ingest_sender_lag_seconds",
+				Help:    "Time from an entry's timestamp until it was received",
+				Buckets: latencyBuckets[:],
+			}),
+			ServerLag: promauto.NewHistogram(prometheus.HistogramOpts{
+				Name:    "opentrail_ingest_server_lag_seconds",
+				Help:    "Time from receiving an entry until it was committed to storage",
+				Buckets: latencyBuckets[:],
+			}),
+			FutureTimestamps: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_future_timestamps_total",
+				Help: "Total number of entries timestamped after they were received",
+			}),
+			sources: make(map[string]*sourceLatency),
+		}
+	})
+	return ingestLatencyInstance
+}
+
+// RecordCommitted records the latencies of entries committed together at the given time. Entries
+// without a receive time, such as imported or reprocessed ones, are skipped.
+func (l *IngestLatency) RecordCommitted(entries []*types.LogEntry, committed time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.ReceivedAt.IsZero() {
+			continue
+		}
+		senderLag := entry.ReceivedAt.Sub(entry.Timestamp).Seconds()
+		serverLag := committed.Sub(entry.ReceivedAt).Seconds()
+		future := senderLag < 0
+		if future {
+			senderLag = 0
+			l.FutureTimestamps.Inc()
+		}
+		l.SenderLag.Observe(senderLag)
+		l.ServerLag.Observe(serverLag)
+
+		source := l.source(latencySource(entry), committed)
+		source.entries++
+		source.lastSeen = committed
+		source.senderLag.observe(senderLag)
+		source.serverLag.observe(serverLag)
+		if future {
+			source.future++
+		}
+	}
+}
+
+// source returns the accumulator of a source, making room for it if needed
+func (l *IngestLatency) source(name string, now time.Time) *sourceLatency {
+	if source, ok := l.sources[name]; ok {
+		return source
+	}
+	if len(l.sources) >= maxLatencySources {
+		var oldest string
+		for candidate, source := range l.sources {
+			if oldest == "" || source.lastSeen.Before(l.sources[oldest].lastSeen) {
+				oldest = candidate
+			}
+		}
+		delete(l.sources, oldest)
+	}
+	source := &sourceLatency{lastSeen: now}
+	l.sources[name] = source
+	return source
+}
+
+// Snapshot returns the latencies per source, most recently seen first
+func (l *IngestLatency) Snapshot() types.IngestLatency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := types.IngestLatency{
+		Buckets: append([]float64(nil), latencyBuckets[:]...),
+		Sources: make([]types.SourceLatency, 0, len(l.sources)),
+	}
+	for name, source := range l.sources {
+		snapshot.Sources = append(snapshot.Sources, types.SourceLatency{
+			Source:           name,
+			Entries:          source.entries,
+			LastSeen:         source.lastSeen,
+			SenderLag:        source.senderLag.export(),
+			ServerLag:        source.serverLag.export(),
+			FutureTimestamps: source.future,
+		})
+	}
+	sort.Slice(snapshot.Sources, func(i, j int) bool {
+		return snapshot.Sources[i].LastSeen.After(snapshot.Sources[j].LastSeen)
+	})
+	return snapshot
+}
+
+// Reset forgets the per-source latencies; the Prometheus histograms are cumulative and kept
+func (l *IngestLatency) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sources = make(map[string]*sourceLatency)
+}
+
+// latencySource names the source of an entry: the recorded source IP, or else its hostname
+func latencySource(entry *types.LogEntry) string {
+	switch params := entry.StructuredData[types.MetadataSDID].(type) {
+	case map[string]interface{}:
+		if ip, ok := params[types.SourceIPParam].(string); ok && ip != "" {
+			return ip
+		}
+	case map[string]string:
+		if ip := params[types.SourceIPParam]; ip != "" {
+			return ip
+		}
+	}
+	if entry.Hostname != "" {
+		return entry.Hostname
+	}
+	return "-"
+}
+
+// observe counts one latency in seconds
+func (h *latencyHistogram) observe(seconds float64) {
+	bucket := sort.SearchFloat64s(latencyBuckets[:], seconds)
+	h.counts[bucket]++
+	h.sum += seconds
+	if seconds > h.max {
+		h.max = seconds
+	}
+}
+
+// export converts the histogram for the API, estimating percentiles from the buckets
+func (h *latencyHistogram) export() types.LatencyHistogram {
+	exported := types.LatencyHistogram{
+		Counts:     append([]int64(nil), h.counts[:]...),
+		SumSeconds: h.sum,
+		MaxSeconds: h.max,
+	}
+	var total int64
+	for _, count := range h.counts {
+		total += count
+	}
+	exported.P50Seconds = h.percentile(total, 0.50)
+	exported.P95Seconds = h.percentile(total, 0.95)
+	return exported
+}
+
+// percentile returns the upper bound of the bucket holding the p-th latency
+func (h *latencyHistogram) percentile(total int64, p float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rank := int64(float64(total)*p + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i < len(latencyBuckets) && latencyBuckets[i] < h.max {
+				return latencyBuckets[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestIngestLatency_RecordCommitted(t *testing.T) {
+	latency := GetIngestLatency()
+	latency.Reset()
+	defer latency.Reset()
+
+	received := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	committed := received.Add(200 * time.Millisecond)
+
+	lagging := &types.LogEntry{Hostname: "web01", Timestamp: received.Add(-2 * time.Minute), ReceivedAt: received}
+	lagging.SetMetadata(types.SourceIPParam, "10.0.0.5")
+	ahead := &types.LogEntry{Hostname: "db01", Timestamp: received.Add(time.Minute), ReceivedAt: received}
+	imported := &types.LogEntry{Hostname: "old01", Timestamp: received.Add(-time.Hour)}
+	latency.RecordCommitted([]*types.LogEntry{lagging, ahead, imported}, committed)
+
+	snapshot := latency.Snapshot()
+	if len(snapshot.Sources) != 2 {
+		t.Fatalf("Expected 2 sources without the entry lacking a receive time, got %+v", snapshot.Sources)
+	}
+	sources := make(map[string]types.SourceLatency)
+	for _, source := range snapshot.Sources {
+		sources[source.Source] = source
+	}
+
+	byIP, ok := sources["10.0.0.5"]
+	if !ok || byIP.Entries != 1 || byIP.FutureTimestamps != 0 {
+		t.Fatalf("Expected the entry to be attributed to its source IP, got %+v", snapshot.Sources)
+	}
+	if byIP.SenderLag.MaxSeconds != 120 || byIP.SenderLag.P95Seconds != 120 {
+		t.Errorf("Expected a sender lag of 2 minutes, got %+v", byIP.SenderLag)
+	}
+	if byIP.ServerLag.P50Seconds != 0.2 || byIP.ServerLag.Counts[3] != 1 {
+		t.Errorf("Expected a server lag of 200ms in the 500ms bucket, got %+v", byIP.ServerLag)
+	}
+
+	byHost, ok := sources["db01"]
+	if !ok || byHost.FutureTimestamps != 1 || byHost.SenderLag.MaxSeconds != 0 {
+		t.Errorf("Expected a future timestamp counted as zero lag for db01, got %+v", byHost)
+	}
+	if len(snapshot.Buckets)+1 != len(byHost.SenderLag.Counts) {
+		t.Errorf("Expected a count per bucket plus the unbounded one, got %d buckets and %d counts",
+			len(snapshot.Buckets), len(byHost.SenderLag.Counts))
+	}
+}
+
+func TestIngestLatency_SourceLimit(t *testing.T) {
+	latency := GetIngestLatency()
+	latency.Reset()
+	defer latency.Reset()
+
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i <= maxLatencySources; i++ {
+		entry := &types.LogEntry{
+			Hostname:   fmt.Sprintf("host%d", i),
+			Timestamp:  start,
+			ReceivedAt: start,
+		}
+		latency.RecordCommitted([]*types.LogEntry{entry}, start.Add(time.Duration(i)*time.Second))
+	}
+
+	snapshot := latency.Snapshot()
+	if len(snapshot.Sources) != maxLatencySources {
+		t.Fatalf("Expected %d sources, got %d", maxLatencySources, len(snapshot.Sources))
+	}
+	for _, source := range snapshot.Sources {
+		if source.Source == "host0" {
+			t.Error("Expected the least recently seen source to be dropped")
+		}
+	}
+	if snapshot.Sources[0].Source != "host200" {
+		t.Errorf("Expected the most recently seen source first, got %s", snapshot.Sources[0].Source)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// How structured data exceeding its limits was handled, the values of the action label
+const (
+	LimitTruncated = "truncated"
+	LimitBucketed  = "bucketed"
+)
+
+// LimitMetrics counts the incoming entries whose structured data exceeded its limits
+type LimitMetrics struct {
+	Entries prometheus.Counter
+	Params  *prometheus.CounterVec
+}
+
+var (
+	limitMetricsInstance *LimitMetrics
+	limitMetricsOnce     sync.Once
+)
+
+// GetLimitMetrics returns the singleton structured data limit metrics
+func GetLimitMetrics() *LimitMetrics {
+	limitMetricsOnce.Do(func() {
+		limitMetricsInstance = &LimitMetrics{
+			Entries: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sd_limited_total",
+				Help: "Total number of incoming entries whose structured data exceeded its limits",
+			}),
+			Params: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sd_limited_params_total",
+				Help: "Total number of structured data parameters dropped or moved into the overflow parameter",
+			}, []string{"action"}),
+		}
+	})
+	return limitMetricsInstance
+}
+
+// Observe counts an entry whose structured data exceeded its limits
+func (m *LimitMetrics) Observe(truncated, bucketed int) {
+	m.Entries.Inc()
+	if truncated > 0 {
+		m.Params.WithLabelValues(LimitTruncated).Add(float64(truncated))
+	}
+	if bucketed > 0 {
+		m.Params.WithLabelValues(LimitBucketed).Add(float64(bucketed))
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Where accounted memory is held, the values of the area label
+const (
+	// MemoryPending is held by messages between being received and written to storage: the
+	// processing queue, the batch being parsed and the storage write buffers
+	MemoryPending = "pending"
+	// MemorySubscribers is held by entries waiting in the buffers of live tail subscribers
+	MemorySubscribers = "subscribers"
+	// MemoryTailBuffer is held by the entries of the last minutes kept for searching the live tail
+	MemoryTailBuffer = "tail_buffer"
+)
+
+// MemoryMetrics reports the approximate memory held by incoming messages and the memory limit
+// applying backpressure to senders
+type MemoryMetrics struct {
+	Held       *prometheus.GaugeVec
+	Limit      prometheus.Gauge
+	Waits      prometheus.Counter
+	Rejections prometheus.Counter
+}
+
+var (
+	memoryMetricsInstance *MemoryMetrics
+	memoryMetricsOnce     sync.Once
+)
+
+// GetMemoryMetrics returns the singleton memory metrics
+func GetMemoryMetrics() *MemoryMetrics {
+	memoryMetricsOnce.Do(func() {
+		memoryMetricsInstance = &MemoryMetrics{
+			Held: promauto.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "opentrail_memory_held_bytes",
+				Help: "Approximate memory in bytes held by incoming messages and entries, by area",
+			}, []string{"area"}),
+			Limit: promauto.NewGauge(prometheus.GaugeOpts{
+				Name: "opentrail_memory_limit_bytes",
+				Help: "Memory in bytes pending messages may hold before senders are held back (0 when unlimited)",
+			}),
+			Waits: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_memory_limit_waits_total",
+				Help: "Total number of messages held back until pending messages released memory",
+			}),
+			Rejections: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_memory_limit_rejections_total",
+				Help: "Total number of messages rejected because pending messages held the memory limit for too long",
+			}),
+		}
+	})
+	return memoryMetricsInstance
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons an incoming entry was sanitized, the values of the reason label
+const (
+	SanitizeInvalidUTF8  = "invalid_utf8"
+	SanitizeControlChars = "control_chars"
+	SanitizeNormalized   = "normalized"
+)
+
+// SanitizeMetrics counts the incoming entries whose text had to be sanitized before storing
+type SanitizeMetrics struct {
+	Entries prometheus.Counter
+	Reasons *prometheus.CounterVec
+}
+
+var (
+	sanitizeMetricsInstance *SanitizeMetrics
+	sanitizeMetricsOnce     sync.Once
+)
+
+// GetSanitizeMetrics returns the singleton sanitization metrics
+func GetSanitizeMetrics() *SanitizeMetrics {
+	sanitizeMetricsOnce.Do(func() {
+		sanitizeMetricsInstance = &SanitizeMetrics{
+			Entries: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sanitized_total",
+				Help: "Total number of incoming entries whose text was sanitized",
+			}),
+			Reasons: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sanitized_reasons_total",
+				Help: "Total number of sanitized incoming entries by what was changed",
+			}, []string{"reason"}),
+		}
+	})
+	return sanitizeMetricsInstance
+}
+
+// Observe counts a sanitized entry with what was changed in it
+func (m *SanitizeMetrics) Observe(invalidUTF8, controlChars, normalized bool) {
+	m.Entries.Inc()
+	if invalidUTF8 {
+		m.Reasons.WithLabelValues(SanitizeInvalidUTF8).Inc()
+	}
+	if controlChars {
+		m.Reasons.WithLabelValues(SanitizeControlChars).Inc()
+	}
+	if normalized {
+		m.Reasons.WithLabelValues(SanitizeNormalized).Inc()
+	}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Scan outcome labels besides the outcomes of types.ScanProgress
+const (
+	ScanCancelled = "cancelled"
+	ScanFailed    = "failed"
+)
+
+// ScanMetrics reports adhoc scans reading every stored entry
+type ScanMetrics struct {
+	// Scans counts finished scans by outcome
+	Scans *prometheus.CounterVec
+	// Rows counts the entries read by scans
+	Rows prometheus.Counter
+}
+
+var (
+	scanMetricsInstance *ScanMetrics
+	scanMetricsOnce     sync.Once
+)
+
+// GetScanMetrics returns the singleton adhoc scan metrics
+func GetScanMetrics() *ScanMetrics {
+	scanMetricsOnce.Do(func() {
+		scanMetricsInstance = &ScanMetrics{
+			Scans: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_scans_total",
+				Help: "Total number of adhoc scans by outcome (complete, limit, timeout, cancelled, failed)",
+			}, []string{"outcome"}),
+			Rows: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_scan_rows_total",
+				Help: "Total number of stored entries read by adhoc scans",
+			}),
+		}
+	})
+	return scanMetricsInstance
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Whether a search was answered from the search cache, the values of the result label
+const (
+	SearchCacheHit  = "hit"
+	SearchCacheMiss = "miss"
+)
+
+// SearchCacheMetrics counts the lookups of the search cache and the results new writes dropped
+type SearchCacheMetrics struct {
+	Requests      *prometheus.CounterVec
+	Invalidations prometheus.Counter
+}
+
+var (
+	searchCacheMetricsInstance *SearchCacheMetrics
+	searchCacheMetricsOnce     sync.Once
+)
+
+// GetSearchCacheMetrics returns the singleton search cache metrics
+func GetSearchCacheMetrics() *SearchCacheMetrics {
+	searchCacheMetricsOnce.Do(func() {
+		searchCacheMetricsInstance = &SearchCacheMetrics{
+			Requests: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "opentrail_search_cache_requests_total",
+				Help: "Total number of searches looked up in the search cache",
+			}, []string{"result"}),
+			Invalidations: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_search_cache_invalidations_total",
+				Help: "Total number of cached search results dropped because new entries fell in their time range",
+			}),
+		}
+	})
+	return searchCacheMetricsInstance
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// MaxSequenceID is the highest RFC5424 sequenceId, after which senders start again at 1
+	MaxSequenceID = 2147483647
+	// maxSequenceSources bounds the sources tracked; the least recently seen one is dropped
+	maxSequenceSources = 1000
+	// maxSequenceGaps bounds the gaps kept per source; the oldest one is dropped
+	maxSequenceGaps = 100
+	// sequenceReorderWindow is how far back a sequence number may go and still count as late
+	// rather than as the sender starting over
+	sequenceReorderWindow = 1000
+)
+
+// SequenceTracker follows the sequence numbers senders give their messages and records the gaps,
+// so dropped messages can be told apart from quiet senders
+type SequenceTracker struct {
+	Gaps    prometheus.Counter
+	Skipped prometheus.Counter
+	Late    prometheus.Counter
+
+	mu      sync.Mutex
+	sources map[sequenceKey]*sourceSequence
+}
+
+// sequenceKey identifies a numbered source
+type sequenceKey struct {
+	tenant   string
+	hostname string
+	appName  string
+}
+
+// sourceSequence is the sequence state of one source
+type sourceSequence struct {
+	entries  int64
+	last     int64
+	lastSeen time.Time
+	missing  int64
+	late     int64
+	resets   int64
+	gaps     []types.SequenceGap
+}
+
+var (
+	sequenceTrackerInstance *SequenceTracker
+	sequenceTrackerOnce     sync.Once
+)
+
+// GetSequenceTracker returns the singleton sequence tracker
+func GetSequenceTracker() *SequenceTracker {
+	sequenceTrackerOnce.Do(func() {
+		sequenceTrackerInstance = &SequenceTracker{
+			Gaps: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sequence_gaps_total",
+				Help: "Total number of gaps found in sender sequence numbers",
+			}),
+			Skipped: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sequence_skipped_total",
+				Help: "Total number of sequence numbers skipped by senders",
+			}),
+			Late: promauto.NewCounter(prometheus.CounterOpts{
+				Name: "opentrail_ingest_sequence_late_total",
+				Help: "Total number of entries received after a higher sequence number",
+			}),
+			sources: make(map[sequenceKey]*sourceSequence),
+		}
+	})
+	return sequenceTrackerInstance
+}
+
+// Observe records the sequence number of an entry received from a source and returns how many
+// sequence numbers were skipped just before it, 0 if none
+func (t *SequenceTracker) Observe(tenant, hostname, appName string, sequence int64, now time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	source := t.source(sequenceKey{tenant: tenant, hostname: hostname, appName: appName}, now)
+	source.entries++
+	source.lastSeen = now
+
+	switch {
+	case source.last == 0 || sequence == source.last+1 || (source.last == MaxSequenceID && sequence == 1):
+		source.last = sequence
+	case sequence > source.last+1:
+		gap := types.SequenceGap{First: source.last + 1, Last: sequence - 1, DetectedAt: now}
+		gap.Missing = gap.Last - gap.First + 1
+		if len(source.gaps) >= maxSequenceGaps {
+			source.gaps = source.gaps[1:]
+		}
+		source.gaps = append(source.gaps, gap)
+		source.missing += gap.Missing
+		source.last = sequence
+		t.Gaps.Inc()
+		t.Skipped.Add(float64(gap.Missing))
+		return gap.Missing
+	default:
+		if source.fill(sequence) {
+			source.late++
+			t.Late.Inc()
+		} else if sequence == 1 || source.last-sequence > sequenceReorderWindow {
+			source.resets++
+			source.last = sequence
+		} else {
+			// A duplicate, or an entry behind another one with a higher number
+			source.late++
+			t.Late.Inc()
+		}
+	}
+	return 0
+}
+
+// fill accounts for a late entry whose sequence number lies in a gap, dropping the gap once all
+// its entries arrived, and reports whether it did
+func (s *sourceSequence) fill(sequence int64) bool {
+	for i := range s.gaps {
+		gap := &s.gaps[i]
+		if sequence < gap.First || sequence > gap.Last || gap.Missing == 0 {
+			continue
+		}
+		gap.Missing--
+		s.missing--
+		if gap.Missing == 0 {
+			s.gaps = append(s.gaps[:i], s.gaps[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// source returns the state of a source, making room for it if needed
+func (t *SequenceTracker) source(key sequenceKey, now time.Time) *sourceSequence {
+	if source, ok := t.sources[key]; ok {
+		return source
+	}
+	if len(t.sources) >= maxSequenceSources {
+		var oldest sequenceKey
+		var oldestSeen time.Time
+		for candidate, source := range t.sources {
+			if oldestSeen.IsZero() || source.lastSeen.Before(oldestSeen) {
+				oldest, oldestSeen = candidate, source.lastSeen
+			}
+		}
+		delete(t.sources, oldest)
+	}
+	source := &sourceSequence{lastSeen: now}
+	t.sources[key] = source
+	return source
+}
+
+// Snapshot returns the numbered sources, those missing the most entries first
+func (t *SequenceTracker) Snapshot() types.SequenceReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := types.SequenceReport{Sources: make([]types.SourceSequence, 0, len(t.sources))}
+	for key, source := range t.sources {
+		report.Sources = append(report.Sources, types.SourceSequence{
+			Tenant:       key.tenant,
+			Hostname:     key.hostname,
+			AppName:      key.appName,
+			Entries:      source.entries,
+			LastSequence: source.last,
+			LastSeen:     source.lastSeen,
+			Missing:      source.missing,
+			Late:         source.late,
+			Resets:       source.resets,
+			Gaps:         append([]types.SequenceGap{}, source.gaps...),
+		})
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		a, b := report.Sources[i], report.Sources[j]
+		if a.Missing != b.Missing {
+			return a.Missing > b.Missing
+		}
+		return a.LastSeen.After(b.LastSeen)
+	})
+	return report
+}
+
+// Reset forgets the sequence state of every source; the Prometheus counters are cumulative and kept
+func (t *SequenceTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sources = make(map[sequenceKey]*sourceSequence)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSequenceTracker_Observe(t *testing.T) {
+	tracker := GetSequenceTracker()
+	tracker.Reset()
+	defer tracker.Reset()
+
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	observe := func(sequence int64) int64 {
+		now = now.Add(time.Second)
+		return tracker.Observe("", "web01", "nginx", sequence, now)
+	}
+
+	for _, sequence := range []int64{1, 2, 3} {
+		if skipped := observe(sequence); skipped != 0 {
+			t.Fatalf("Expected no gap before %d, got %d", sequence, skipped)
+		}
+	}
+	if skipped := observe(7); skipped != 3 {
+		t.Fatalf("Expected 3 skipped before 7, got %d", skipped)
+	}
+	observe(5) // late, fills part of the gap
+	observe(7) // duplicate
+	observe(8)
+	// Another source with the same numbers is tracked separately
+	tracker.Observe("tenant-a", "web01", "nginx", 1, now)
+
+	report := tracker.Snapshot()
+	if len(report.Sources) != 2 {
+		t.Fatalf("Expected 2 sources, got %+v", report.Sources)
+	}
+	source := report.Sources[0]
+	if source.Tenant != "" || source.Entries != 7 || source.LastSequence != 8 || source.Missing != 2 || source.Late != 2 {
+		t.Fatalf("Unexpected source state: %+v", source)
+	}
+	if len(source.Gaps) != 1 || source.Gaps[0].First != 4 || source.Gaps[0].Last != 6 || source.Gaps[0].Missing != 2 {
+		t.Fatalf("Expected one gap 4-6 still missing 2 entries, got %+v", source.Gaps)
+	}
+
+	// Filling the rest of the gap drops it
+	observe(4)
+	observe(6)
+	if source := tracker.Snapshot().Sources[0]; source.Missing != 0 || len(source.Gaps) != 0 || source.Late != 4 {
+		t.Errorf("Expected the gap to be filled, got %+v", source)
+	}
+
+	// Starting over is a reset rather than a gap
+	if skipped := observe(1); skipped != 0 {
+		t.Errorf("Expected no gap when the sender starts over, got %d", skipped)
+	}
+	if skipped := observe(2); skipped != 0 {
+		t.Errorf("Expected no gap after a reset, got %d", skipped)
+	}
+	for _, source := range tracker.Snapshot().Sources {
+		if source.Tenant == "" && (source.Resets != 1 || source.LastSequence != 2) {
+			t.Errorf("Expected one reset, got %+v", source)
+		}
+	}
+}
+
+func TestSequenceTracker_Wraparound(t *testing.T) {
+	tracker := GetSequenceTracker()
+	tracker.Reset()
+	defer tracker.Reset()
+
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	tracker.Observe("", "host", "app", MaxSequenceID-1, now)
+	tracker.Observe("", "host", "app", MaxSequenceID, now)
+	if skipped := tracker.Observe("", "host", "app", 1, now); skipped != 0 {
+		t.Errorf("Expected the sequence to wrap around without a gap, got %d", skipped)
+	}
+	if source := tracker.Snapshot().Sources[0]; source.Resets != 0 || source.Missing != 0 {
+		t.Errorf("Expected a wraparound to be neither a reset nor a gap, got %+v", source)
+	}
+}
+
+func TestSequenceTracker_GapLimit(t *testing.T) {
+	tracker := GetSequenceTracker()
+	tracker.Reset()
+	defer tracker.Reset()
+
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := int64(0); i <= maxSequenceGaps+1; i++ {
+		tracker.Observe("", "host", "app", 1+i*2, now)
+	}
+	source := tracker.Snapshot().Sources[0]
+	if len(source.Gaps) != maxSequenceGaps || source.Gaps[0].First != 4 {
+		t.Errorf("Expected the oldest gap to be dropped, got %d gaps starting with %+v", len(source.Gaps), source.Gaps[0])
+	}
+	if source.Missing != maxSequenceGaps+1 {
+		t.Errorf("Expected dropped gaps to remain counted as missing, got %d", source.Missing)
+	}
+}
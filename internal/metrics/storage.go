@@ -43,6 +43,11 @@ type StorageMetrics struct {
 	ThroughputTPS prometheus.Gauge
 	LatencyP95    prometheus.Gauge
 	LatencyP99    prometheus.Gauge
+
+	// Integrity checks
+	IntegrityChecksTotal   prometheus.Counter
+	IntegrityFailuresTotal prometheus.Counter
+	IntegrityStatus        prometheus.Gauge
 }
 
 var (
@@ -167,6 +172,20 @@ func newStorageMetrics() *StorageMetrics {
 			Name: "opentrail_storage_latency_p99_seconds",
 			Help: "99th percentile latency",
 		}),
+
+		// Integrity checks
+		IntegrityChecksTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "opentrail_storage_integrity_checks_total",
+			Help: "Total number of database integrity checks run",
+		}),
+		IntegrityFailuresTotal: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "opentrail_storage_integrity_failures_total",
+			Help: "Total number of database integrity checks that reported problems",
+		}),
+		IntegrityStatus: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "opentrail_storage_integrity_ok",
+			Help: "Result of the last integrity check (1 = ok, 0 = problems found)",
+		}),
 	}
 }
 
@@ -250,6 +269,17 @@ func (m *StorageMetrics) UpdateDatabaseConnections(count int) {
 	m.DatabaseConnectionsActive.Set(float64(count))
 }
 
+// RecordIntegrityCheck records the outcome of a database integrity check
+func (m *StorageMetrics) RecordIntegrityCheck(ok bool) {
+	m.IntegrityChecksTotal.Inc()
+	if ok {
+		m.IntegrityStatus.Set(1)
+	} else {
+		m.IntegrityFailuresTotal.Inc()
+		m.IntegrityStatus.Set(0)
+	}
+}
+
 // Helper function to check if error is a timeout
 func isTimeoutError(err error) bool {
 	if err == nil {
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ChannelConfig is one entry of a channel configuration file
+type ChannelConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Template string `json:"template,omitempty"`
+}
+
+// Registry holds the configured channels by name
+type Registry struct {
+	channels map[string]Channel
+}
+
+// NewRegistry creates a registry of channels, rejecting duplicate names
+func NewRegistry(channels ...Channel) (*Registry, error) {
+	registry := &Registry{channels: make(map[string]Channel, len(channels))}
+	for _, channel := range channels {
+		if channel.Name() == "" {
+			return nil, fmt.Errorf("notification channels need a name")
+		}
+		if _, ok := registry.channels[channel.Name()]; ok {
+			return nil, fmt.Errorf("duplicate notification channel %s", channel.Name())
+		}
+		registry.channels[channel.Name()] = channel
+	}
+	return registry, nil
+}
+
+// LoadChannels reads a JSON array of channel configurations and creates their channels
+func LoadChannels(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification channels: %w", err)
+	}
+	var configs []ChannelConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse notification channels %s: %w", path, err)
+	}
+
+	channels := make([]Channel, 0, len(configs))
+	for _, config := range configs {
+		channel, err := NewWebhookChannel(config.Name, config.Type, config.URL, config.Template)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return NewRegistry(channels...)
+}
+
+// Channel returns the channel with the given name
+func (r *Registry) Channel(name string) (Channel, bool) {
+	if r == nil {
+		return nil, false
+	}
+	channel, ok := r.channels[name]
+	return channel, ok
+}
+
+// Names lists the configured channel names in order
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
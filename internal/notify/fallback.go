@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"opentrail/internal/metrics"
+)
+
+const (
+	// DefaultFallbackPercent is the default share of unparsed messages a source alerts at
+	DefaultFallbackPercent = 20
+	// DefaultFallbackMinEntries is the default number of messages a source must send in a window
+	// for its share of unparsed messages to count
+	DefaultFallbackMinEntries = 100
+	// DefaultFallbackWindow is the default period the messages of a source are counted over
+	DefaultFallbackWindow = 5 * time.Minute
+	// fallbackSendTimeout bounds the delivery of a fallback notification through one channel
+	fallbackSendTimeout = 15 * time.Second
+)
+
+// Fallback alert states
+const (
+	FallbackFiring   = "firing"
+	FallbackResolved = "resolved"
+)
+
+// FallbackRule is the built-in rule notifying when too many of the messages a source sends cannot
+// be parsed and end up on the parser's fallback path, stored whole as the message. That almost
+// always means the sender changed its format, after which searches on its fields silently miss
+// its entries. The rule fires once per source and resolves when a window of the source's messages
+// is back under the threshold.
+type FallbackRule struct {
+	percent    int
+	minEntries int64
+	window     time.Duration
+	channels   []Channel
+
+	firing map[metrics.FallbackSource]bool
+}
+
+// NewFallbackRule creates the rule firing when at least percent of at least minEntries messages of
+// a source in a window could not be parsed; notifications are sent to channels, and only logged
+// without any
+func NewFallbackRule(percent int, minEntries int64, window time.Duration, channels []Channel) *FallbackRule {
+	return &FallbackRule{
+		percent:    percent,
+		minEntries: minEntries,
+		window:     window,
+		channels:   channels,
+		firing:     make(map[metrics.FallbackSource]bool),
+	}
+}
+
+// Run evaluates the rule at the end of every window until ctx is done
+func (r *FallbackRule) Run(ctx context.Context) {
+	tracker := metrics.GetFallbackTracker()
+	// Counts from before the first window are partial
+	tracker.Rotate()
+	ticker := time.NewTicker(r.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, notification := range r.Evaluate(tracker.Rotate(), now) {
+				r.send(ctx, notification)
+			}
+		}
+	}
+}
+
+// Evaluate returns the notifications of the sources whose alert changed state in a window
+func (r *FallbackRule) Evaluate(windows []metrics.FallbackWindow, now time.Time) []*Notification {
+	slices.SortFunc(windows, func(a, b metrics.FallbackWindow) int {
+		if c := strings.Compare(a.Source.Tenant, b.Source.Tenant); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Source.SourceIP, b.Source.SourceIP)
+	})
+
+	var notifications []*Notification
+	for _, window := range windows {
+		if window.Entries < r.minEntries {
+			// Too few messages to tell; a firing alert keeps firing
+			continue
+		}
+		percent := int(window.Fallbacks * 100 / window.Entries)
+		spiking := percent >= r.percent
+		if spiking == r.firing[window.Source] {
+			continue
+		}
+
+		// Default templates show the rule name but not its description
+		state := FallbackResolved
+		rule := Rule{Name: "Parser fallback resolved for " + describeSource(window.Source)}
+		if spiking {
+			state = FallbackFiring
+			rule.Name = "Parser fallback spike from " + describeSource(window.Source)
+			r.firing[window.Source] = true
+		} else {
+			delete(r.firing, window.Source)
+		}
+		rule.Description = fmt.Sprintf("%d%% of %d messages from %s in the last %s could not be parsed (threshold %d%%)",
+			percent, window.Entries, describeSource(window.Source), r.window, r.percent)
+		rule.Labels = map[string]string{"state": state, "source_ip": window.Source.SourceIP}
+		if window.Source.Tenant != "" {
+			rule.Labels["tenant"] = window.Source.Tenant
+		}
+
+		notification := &Notification{Rule: rule, Count: int(window.Fallbacks), Time: now}
+		if spiking {
+			// Newest first, as channels expect
+			notification.Entries = slices.Clone(window.Samples)
+			slices.Reverse(notification.Entries)
+		}
+		notifications = append(notifications, notification)
+	}
+	metrics.GetFallbackTracker().Firing.Set(float64(len(r.firing)))
+	return notifications
+}
+
+// send logs a notification and delivers it through every channel
+func (r *FallbackRule) send(ctx context.Context, notification *Notification) {
+	log.Printf("%s: %s", notification.Rule.Name, notification.Rule.Description)
+	for _, channel := range r.channels {
+		sendCtx, cancel := context.WithTimeout(ctx, fallbackSendTimeout)
+		if err := channel.Send(sendCtx, notification); err != nil {
+			log.Printf("Failed to send fallback notification to channel %s: %v", channel.Name(), err)
+		}
+		cancel()
+	}
+}
+
+// describeSource names a source in notifications
+func describeSource(source metrics.FallbackSource) string {
+	name := source.SourceIP
+	if name == "" {
+		name = "an unknown address"
+	}
+	if source.Tenant != "" {
+		name += " (tenant " + source.Tenant + ")"
+	}
+	return name
+}
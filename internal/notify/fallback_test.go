@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+// recordingChannel keeps the notifications sent through it
+type recordingChannel struct {
+	sent []*Notification
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, notification *Notification) error {
+	c.sent = append(c.sent, notification)
+	return nil
+}
+
+func TestFallbackRule_Evaluate(t *testing.T) {
+	channel := &recordingChannel{}
+	rule := NewFallbackRule(20, 100, 5*time.Minute, []Channel{channel})
+	web := metrics.FallbackSource{SourceIP: "10.0.0.5"}
+	db := metrics.FallbackSource{Tenant: "acme", SourceIP: "10.0.0.9"}
+	samples := []*types.LogEntry{{Message: "first unparsed"}, {Message: "second unparsed"}}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// A source over the threshold fires, one under it or with too few messages does not
+	notifications := rule.Evaluate([]metrics.FallbackWindow{
+		{Source: db, Entries: 50, Fallbacks: 50},
+		{Source: web, Entries: 200, Fallbacks: 60, Samples: samples},
+		{Source: metrics.FallbackSource{SourceIP: "10.0.0.7"}, Entries: 1000, Fallbacks: 10},
+	}, now)
+	if len(notifications) != 1 {
+		t.Fatalf("Expected one notification, got %d", len(notifications))
+	}
+	firing := notifications[0]
+	if firing.Rule.Name != "Parser fallback spike from 10.0.0.5" || firing.Rule.Labels["state"] != FallbackFiring || firing.Count != 60 {
+		t.Errorf("Unexpected firing notification %+v", firing)
+	}
+	if firing.Rule.Description != "30% of 200 messages from 10.0.0.5 in the last 5m0s could not be parsed (threshold 20%)" {
+		t.Errorf("Unexpected description %q", firing.Rule.Description)
+	}
+	if len(firing.Entries) != 2 || firing.Entries[0].Message != "second unparsed" {
+		t.Errorf("Expected the samples newest first, got %+v", firing.Entries)
+	}
+
+	// It fires once, keeps firing while there is too little to tell, then resolves
+	if notifications := rule.Evaluate([]metrics.FallbackWindow{{Source: web, Entries: 300, Fallbacks: 90}}, now); len(notifications) != 0 {
+		t.Errorf("Expected no repeated notification, got %+v", notifications)
+	}
+	if notifications := rule.Evaluate([]metrics.FallbackWindow{{Source: web, Entries: 10, Fallbacks: 0}}, now); len(notifications) != 0 {
+		t.Errorf("Expected a firing alert to keep firing on few messages, got %+v", notifications)
+	}
+	notifications = rule.Evaluate([]metrics.FallbackWindow{
+		{Source: web, Entries: 500, Fallbacks: 5},
+		{Source: db, Entries: 100, Fallbacks: 40},
+	}, now)
+	if len(notifications) != 2 || notifications[0].Rule.Labels["state"] != FallbackResolved ||
+		notifications[1].Rule.Name != "Parser fallback spike from 10.0.0.9 (tenant acme)" || notifications[1].Rule.Labels["tenant"] != "acme" {
+		t.Fatalf("Expected 10.0.0.5 to resolve and tenant acme to fire, got %+v", notifications)
+	}
+
+	rule.send(context.Background(), notifications[0])
+	if len(channel.sent) != 1 || channel.sent[0].Rule.Name != "Parser fallback resolved for 10.0.0.5" {
+		t.Errorf("Expected the resolution to be sent, got %+v", channel.sent)
+	}
+}
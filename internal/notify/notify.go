@@ -0,0 +1,119 @@
+// Package notify delivers notifications about log entries to chat services. A channel renders a
+// Go template over the triggering entries and the metadata of the rule that fired, then posts the
+// result to a Slack, Discord or Microsoft Teams incoming webhook. Channels are configured once
+// and shared by everything that notifies, such as alert rules and digests.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// Rule describes what triggered a notification
+type Rule struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Query       string            `json:"query,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// Notification is the data a channel template is rendered with
+type Notification struct {
+	Rule Rule
+	// Entries are the triggering entries, newest first; there may be fewer than Count
+	Entries []*types.LogEntry
+	// Count is the total number of triggering entries
+	Count int
+	Time  time.Time
+}
+
+// Channel delivers rendered notifications to one destination
+type Channel interface {
+	// Name identifies the channel in rule configurations
+	Name() string
+
+	// Send renders a notification and delivers it
+	Send(ctx context.Context, notification *Notification) error
+}
+
+// DefaultTemplate lists the rule and up to the first ten triggering entries
+const DefaultTemplate = `{{escape .Rule.Name}}: {{.Count}} matching {{if eq .Count 1}}entry{{else}}entries{{end}}
+{{- range $i, $e := .Entries}}{{if lt $i 10}}
+[{{severity $e.Severity}}] {{$e.Timestamp.Format "2006-01-02 15:04:05Z07:00"}} {{escape $e.Hostname}} {{escape $e.AppName}}: {{escape (truncate 200 $e.Message)}}
+{{- end}}{{end}}
+{{- with remaining .Count .Entries 10}}
+...and {{.}} more{{end}}`
+
+// severityNames are the syslog severity keywords, emergency first
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// parseTemplate compiles a channel template; escape quotes text for the channel's markup
+func parseTemplate(name, text string, escape func(string) string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	funcs := template.FuncMap{
+		"escape": escape,
+		"severity": func(severity int) string {
+			if severity < 0 || severity >= len(severityNames) {
+				return fmt.Sprintf("severity%d", severity)
+			}
+			return severityNames[severity]
+		},
+		"truncate": truncate,
+		"remaining": func(count int, entries []*types.LogEntry, shown int) int {
+			return max(count-min(len(entries), shown), 0)
+		},
+		"upper": strings.ToUpper,
+		"sd":    structuredDataValue,
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for channel %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// render executes a template and bounds the result to limit characters
+func render(tmpl *template.Template, notification *Notification, limit int) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notification); err != nil {
+		return "", fmt.Errorf("failed to render notification: %w", err)
+	}
+	return truncate(limit, strings.TrimSpace(buf.String())), nil
+}
+
+// truncate shortens text to at most limit characters, marking the cut with an ellipsis
+func truncate(limit int, text string) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+	if limit == 1 {
+		return "…"
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// structuredDataValue returns a structured data parameter of an entry ("sdid.param"), empty if absent
+func structuredDataValue(entry *types.LogEntry, field string) string {
+	sdID, param, ok := strings.Cut(field, ".")
+	if !ok || entry == nil {
+		return ""
+	}
+	switch element := entry.StructuredData[sdID].(type) {
+	case map[string]interface{}:
+		if value, ok := element[param]; ok {
+			return fmt.Sprint(value)
+		}
+	case map[string]string:
+		return element[param]
+	}
+	return ""
+}
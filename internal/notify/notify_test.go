@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func testNotification(count int) *Notification {
+	var entries []*types.LogEntry
+	for i := 0; i < count && i < 12; i++ {
+		entries = append(entries, &types.LogEntry{
+			Severity: 3, Hostname: "web01", AppName: "api",
+			Timestamp: time.Date(2024, 5, 1, 12, 0, i, 0, time.UTC),
+			Message:   fmt.Sprintf("request %d failed for <@here> & co", i),
+			StructuredData: map[string]interface{}{
+				"request": map[string]interface{}{"path": "/login"},
+			},
+		})
+	}
+	return &Notification{Rule: Rule{Name: "API errors"}, Entries: entries, Count: count, Time: time.Now()}
+}
+
+// captureWebhook records the JSON bodies posted to it
+func captureWebhook(t *testing.T, status int) (*httptest.Server, *[]map[string]interface{}) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Webhook received invalid JSON: %s", data)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+		io.WriteString(w, "invalid_token")
+	}))
+	return server, &bodies
+}
+
+func TestWebhookChannels(t *testing.T) {
+	server, bodies := captureWebhook(t, http.StatusOK)
+	defer server.Close()
+
+	for _, channelType := range Types {
+		channel, err := NewWebhookChannel(channelType, channelType, server.URL, "")
+		if err != nil {
+			t.Fatalf("NewWebhookChannel(%s) failed: %v", channelType, err)
+		}
+		if err := channel.Send(context.Background(), testNotification(15)); err != nil {
+			t.Fatalf("Send(%s) failed: %v", channelType, err)
+		}
+	}
+	if len(*bodies) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %d", len(*bodies))
+	}
+
+	slack := (*bodies)[0]["text"].(string)
+	if !strings.HasPrefix(slack, "API errors: 15 matching entries\n[err] 2024-05-01 12:00:00Z web01 api: request 0 failed for &lt;@here&gt; &amp; co") {
+		t.Errorf("Unexpected Slack text: %s", slack)
+	}
+	if strings.Count(slack, "\n[err]") != 10 || !strings.HasSuffix(slack, "...and 5 more") {
+		t.Errorf("Expected ten entries and a remainder line, got: %s", slack)
+	}
+
+	discord := (*bodies)[1]
+	if !strings.Contains(discord["content"].(string), "<@here>") || discord["allowed_mentions"] == nil {
+		t.Errorf("Expected raw content with mentions disabled, got %v", discord)
+	}
+
+	teams := (*bodies)[2]
+	attachment := teams["attachments"].([]interface{})[0].(map[string]interface{})
+	card := attachment["content"].(map[string]interface{})
+	block := card["body"].([]interface{})[0].(map[string]interface{})
+	if teams["type"] != "message" || card["type"] != "AdaptiveCard" || !strings.HasPrefix(block["text"].(string), "API errors: 15") {
+		t.Errorf("Unexpected Teams payload: %v", teams)
+	}
+}
+
+func TestWebhookChannel_Template(t *testing.T) {
+	server, bodies := captureWebhook(t, http.StatusOK)
+	defer server.Close()
+
+	channel, err := NewWebhookChannel("custom", TypeDiscord, server.URL,
+		`{{upper .Rule.Name}}{{range .Entries}} {{sd . "request.path"}}{{end}} {{truncate 8 "abcdefghijk"}}`)
+	if err != nil {
+		t.Fatalf("NewWebhookChannel failed: %v", err)
+	}
+	if err := channel.Send(context.Background(), testNotification(2)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if text := (*bodies)[0]["content"]; text != "API ERRORS /login /login abcdefg…" {
+		t.Errorf("Unexpected rendered template: %q", text)
+	}
+
+	// Discord rejects content over 2000 characters, so long renderings are cut
+	long, err := NewWebhookChannel("long", TypeDiscord, server.URL, `{{range .Entries}}{{.Message}} {{.Message}} {{.Message}} {{.Message}} {{.Message}} {{.Message}}{{end}}`)
+	if err != nil {
+		t.Fatalf("NewWebhookChannel failed: %v", err)
+	}
+	if err := long.Send(context.Background(), testNotification(12)); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if text := (*bodies)[1]["content"].(string); len([]rune(text)) != discordContentLimit {
+		t.Errorf("Expected content cut to %d characters, got %d", discordContentLimit, len([]rune(text)))
+	}
+
+	if _, err := NewWebhookChannel("broken", TypeSlack, server.URL, "{{.Rule.Name"); err == nil {
+		t.Error("Expected an invalid template to be rejected")
+	}
+	if _, err := NewWebhookChannel("irc", "irc", server.URL, ""); err == nil {
+		t.Error("Expected an unsupported type to be rejected")
+	}
+	if _, err := NewWebhookChannel("local", TypeSlack, "file:///etc/passwd", ""); err == nil {
+		t.Error("Expected a non-HTTP URL to be rejected")
+	}
+}
+
+func TestWebhookChannel_Rejected(t *testing.T) {
+	server, _ := captureWebhook(t, http.StatusForbidden)
+	defer server.Close()
+
+	channel, err := NewWebhookChannel("ops", TypeSlack, server.URL, "")
+	if err != nil {
+		t.Fatalf("NewWebhookChannel failed: %v", err)
+	}
+	err = channel.Send(context.Background(), testNotification(1))
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("Expected the rejection to be reported, got %v", err)
+	}
+}
+
+func TestLoadChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channels.json")
+	os.WriteFile(path, []byte(`[
+		{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/x"},
+		{"name": "security", "type": "teams", "url": "https://example.webhook.office.com/x", "template": "{{.Count}}"}
+	]`), 0o600)
+
+	registry, err := LoadChannels(path)
+	if err != nil {
+		t.Fatalf("LoadChannels failed: %v", err)
+	}
+	if names := registry.Names(); len(names) != 2 || names[0] != "ops" || names[1] != "security" {
+		t.Errorf("Unexpected channel names: %v", names)
+	}
+	if _, ok := registry.Channel("security"); !ok {
+		t.Error("Expected channel security to be registered")
+	}
+
+	os.WriteFile(path, []byte(`[
+		{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/x"},
+		{"name": "ops", "type": "discord", "url": "https://discord.com/api/webhooks/x"}
+	]`), 0o600)
+	if _, err := LoadChannels(path); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected duplicate channel names to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Supported channel types
+const (
+	TypeSlack   = "slack"
+	TypeDiscord = "discord"
+	TypeTeams   = "teams"
+)
+
+// Types lists every supported channel type
+var Types = []string{TypeSlack, TypeDiscord, TypeTeams}
+
+const (
+	// sendTimeout bounds one webhook delivery
+	sendTimeout = 10 * time.Second
+	// maxErrorBody bounds how much of a rejected delivery's response is reported
+	maxErrorBody = 512
+)
+
+// Message length limits of the chat services
+const (
+	slackTextLimit      = 40000
+	discordContentLimit = 2000
+	teamsTextLimit      = 20000
+)
+
+// webhookFlavor adapts rendered text to one chat service
+type webhookFlavor struct {
+	limit   int
+	escape  func(string) string
+	payload func(text string) interface{}
+}
+
+var flavors = map[string]webhookFlavor{
+	TypeSlack: {
+		limit: slackTextLimit,
+		// Slack treats <...> as links and mentions, so log content must not contain them
+		escape: strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+		payload: func(text string) interface{} {
+			return map[string]interface{}{"text": text}
+		},
+	},
+	TypeDiscord: {
+		limit:  discordContentLimit,
+		escape: func(text string) string { return text },
+		payload: func(text string) interface{} {
+			// Mentions in log content must not ping anyone
+			return map[string]interface{}{
+				"content":          text,
+				"allowed_mentions": map[string]interface{}{"parse": []string{}},
+			}
+		},
+	},
+	TypeTeams: {
+		limit:  teamsTextLimit,
+		escape: func(text string) string { return text },
+		payload: func(text string) interface{} {
+			// An Adaptive Card, accepted by both Office 365 connectors and Workflows webhooks
+			return map[string]interface{}{
+				"type": "message",
+				"attachments": []interface{}{map[string]interface{}{
+					"contentType": "application/vnd.microsoft.card.adaptive",
+					"content": map[string]interface{}{
+						"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+						"type":    "AdaptiveCard",
+						"version": "1.4",
+						"body": []interface{}{map[string]interface{}{
+							"type": "TextBlock",
+							"text": text,
+							"wrap": true,
+						}},
+					},
+				}},
+			}
+		},
+	},
+}
+
+// webhookChannel posts rendered notifications to a chat service's incoming webhook
+type webhookChannel struct {
+	name     string
+	url      string
+	flavor   webhookFlavor
+	template *template.Template
+	client   *http.Client
+}
+
+// NewWebhookChannel creates a channel of the given type posting to url; an empty template uses DefaultTemplate
+func NewWebhookChannel(name, channelType, url, templateText string) (Channel, error) {
+	flavor, ok := flavors[channelType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported channel type %q for channel %s, expected slack, discord or teams", channelType, name)
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("webhook URL of channel %s must be an http(s) URL", name)
+	}
+	tmpl, err := parseTemplate(name, templateText, flavor.escape)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookChannel{
+		name:     name,
+		url:      url,
+		flavor:   flavor,
+		template: tmpl,
+		client:   &http.Client{Timeout: sendTimeout},
+	}, nil
+}
+
+// Name identifies the channel
+func (c *webhookChannel) Name() string {
+	return c.name
+}
+
+// Send renders a notification and posts it to the webhook
+func (c *webhookChannel) Send(ctx context.Context, notification *Notification) error {
+	text, err := render(c.template, notification, c.flavor.limit)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(c.flavor.payload(text))
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver notification to %s: %w", c.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("channel %s rejected notification with status %d: %s", c.name, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
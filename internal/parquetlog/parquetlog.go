@@ -0,0 +1,252 @@
+// Package parquetlog writes log entries as Apache Parquet files, for querying archived logs directly
+// from DuckDB, Spark and other columnar engines. Every RFC5424 field gets a column, the structured
+// data is kept whole as JSON, and the most common structured data keys are flattened into columns
+// of their own so they can be filtered without parsing JSON.
+package parquetlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// ContentType is the media type of Parquet files
+	ContentType = "application/vnd.apache.parquet"
+
+	// DefaultStructuredColumns is the default number of structured data keys flattened into columns
+	DefaultStructuredColumns = 32
+
+	// ColumnsMetadataKey is the file metadata key holding the structured data key of every flattened
+	// column, as a JSON array of {"key", "column"} objects
+	ColumnsMetadataKey = "opentrail.structured_columns"
+
+	// rowGroupSize bounds the rows buffered in memory before a row group is written
+	rowGroupSize = 100000
+)
+
+// Column maps a structured data key ("sdid.param") to the column holding its values
+type Column struct {
+	Key  string `json:"key"`
+	Name string `json:"column"`
+}
+
+// Columns names the columns of the given structured data keys: "sd_" followed by the key with every
+// character other than letters, digits and underscores replaced by an underscore
+func Columns(keys []string) []Column {
+	columns := make([]Column, 0, len(keys))
+	used := make(map[string]bool)
+	for _, key := range keys {
+		base := "sd_" + strings.Map(func(r rune) rune {
+			if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return '_'
+		}, key)
+		name := base
+		for n := 2; used[name]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
+		columns = append(columns, Column{Key: key, Name: name})
+	}
+	return columns
+}
+
+// CommonKeys returns up to limit structured data keys carried by the most entries, most common first
+func CommonKeys(entries []*types.LogEntry, limit int) []string {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		forEachParam(entry, func(key string, _ interface{}) {
+			counts[key]++
+		})
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// forEachParam calls fn with the key and value of every structured data parameter of an entry
+func forEachParam(entry *types.LogEntry, fn func(key string, value interface{})) {
+	for sdID, element := range entry.StructuredData {
+		switch params := element.(type) {
+		case map[string]string:
+			for name, value := range params {
+				fn(sdID+"."+name, value)
+			}
+		case map[string]interface{}:
+			for name, value := range params {
+				fn(sdID+"."+name, value)
+			}
+		}
+	}
+}
+
+// Writer writes entries to a Parquet file
+type Writer struct {
+	writer  *parquet.Writer
+	columns []Column
+
+	// index maps column names to their position in a row
+	index map[string]int
+	row   parquet.Row
+}
+
+// NewWriter creates a writer flattening the given structured data keys into columns. Close must be
+// called to write the file footer.
+func NewWriter(output io.Writer, structuredKeys []string) (*Writer, error) {
+	columns := Columns(structuredKeys)
+	timestamp := parquet.Timestamp(parquet.Microsecond)
+	group := parquet.Group{
+		"id":              parquet.Int(64),
+		"timestamp":       timestamp,
+		"priority":        parquet.Int(32),
+		"facility":        parquet.Int(32),
+		"severity":        parquet.Int(32),
+		"version":         parquet.Int(32),
+		"hostname":        parquet.String(),
+		"app_name":        parquet.String(),
+		"proc_id":         parquet.String(),
+		"msg_id":          parquet.String(),
+		"message":         parquet.String(),
+		"structured_data": parquet.Optional(parquet.JSON()),
+		"created_at":      parquet.Optional(timestamp),
+	}
+	for _, column := range columns {
+		group[column.Name] = parquet.Optional(parquet.String())
+	}
+	schema := parquet.NewSchema("log_entry", group)
+
+	mapping, err := json.Marshal(columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode column mapping: %w", err)
+	}
+
+	w := &Writer{
+		writer: parquet.NewWriter(output, schema,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(rowGroupSize),
+			parquet.KeyValueMetadata(ColumnsMetadataKey, string(mapping))),
+		columns: columns,
+		index:   make(map[string]int),
+		row:     make(parquet.Row, len(schema.Columns())),
+	}
+	for _, path := range schema.Columns() {
+		leaf, _ := schema.Lookup(path...)
+		w.index[path[0]] = leaf.ColumnIndex
+	}
+	return w, nil
+}
+
+// Columns returns the structured data keys flattened into columns
+func (w *Writer) Columns() []Column {
+	return w.columns
+}
+
+// Write appends an entry
+func (w *Writer) Write(entry *types.LogEntry) error {
+	w.set("id", parquet.Int64Value(entry.ID))
+	w.set("timestamp", parquet.Int64Value(entry.Timestamp.UnixMicro()))
+	w.set("priority", parquet.Int32Value(int32(entry.Priority)))
+	w.set("facility", parquet.Int32Value(int32(entry.Facility)))
+	w.set("severity", parquet.Int32Value(int32(entry.Severity)))
+	w.set("version", parquet.Int32Value(int32(entry.Version)))
+	w.set("hostname", parquet.ByteArrayValue([]byte(entry.Hostname)))
+	w.set("app_name", parquet.ByteArrayValue([]byte(entry.AppName)))
+	w.set("proc_id", parquet.ByteArrayValue([]byte(entry.ProcID)))
+	w.set("msg_id", parquet.ByteArrayValue([]byte(entry.MsgID)))
+	w.set("message", parquet.ByteArrayValue([]byte(entry.Message)))
+
+	w.setOptional("structured_data", nil)
+	if len(entry.StructuredData) > 0 {
+		data, err := json.Marshal(entry.StructuredData)
+		if err != nil {
+			return fmt.Errorf("failed to encode structured data of entry %d: %w", entry.ID, err)
+		}
+		w.setOptional("structured_data", data)
+	}
+	w.setOptional("created_at", nil)
+	if !entry.CreatedAt.IsZero() {
+		w.setNullable("created_at", parquet.Int64Value(entry.CreatedAt.UnixMicro()))
+	}
+
+	for _, column := range w.columns {
+		w.setOptional(column.Name, nil)
+	}
+	if len(w.columns) > 0 {
+		values := make(map[string]interface{})
+		forEachParam(entry, func(key string, value interface{}) {
+			values[key] = value
+		})
+		for _, column := range w.columns {
+			if value, ok := values[column.Key]; ok {
+				w.setOptional(column.Name, []byte(stringValue(value)))
+			}
+		}
+	}
+
+	if _, err := w.writer.WriteRows([]parquet.Row{w.row}); err != nil {
+		return fmt.Errorf("failed to write entry %d: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// Close flushes the buffered rows and writes the file footer. It does not close the output.
+func (w *Writer) Close() error {
+	if err := w.writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish parquet file: %w", err)
+	}
+	return nil
+}
+
+// set stores the value of a required column
+func (w *Writer) set(name string, value parquet.Value) {
+	i := w.index[name]
+	w.row[i] = value.Level(0, 0, i)
+}
+
+// setNullable stores the value of an optional column
+func (w *Writer) setNullable(name string, value parquet.Value) {
+	i := w.index[name]
+	w.row[i] = value.Level(0, 1, i)
+}
+
+// setOptional stores a byte array in an optional column, or null when data is nil
+func (w *Writer) setOptional(name string, data []byte) {
+	if data == nil {
+		i := w.index[name]
+		w.row[i] = parquet.NullValue().Level(0, 0, i)
+		return
+	}
+	w.setNullable(name, parquet.ByteArrayValue(data))
+}
+
+// stringValue formats a structured data value: strings as they are, anything else as JSON
+func stringValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,103 @@
+package parquetlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"opentrail/internal/types"
+)
+
+// exportedRow reads back the columns written for the test entries
+type exportedRow struct {
+	ID             int64     `parquet:"id"`
+	Timestamp      time.Time `parquet:"timestamp,timestamp(microsecond)"`
+	Severity       int32     `parquet:"severity"`
+	Hostname       string    `parquet:"hostname"`
+	Message        string    `parquet:"message"`
+	StructuredData *string   `parquet:"structured_data,optional"`
+	RequestID      *string   `parquet:"sd_req_id,optional"`
+	Status         *string   `parquet:"sd_http_status,optional"`
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 10, 0, 0, 123456000, time.UTC)
+	entries := []*types.LogEntry{
+		{ID: 1, Severity: 3, Version: 1, Timestamp: ts, Hostname: "web-1", AppName: "api", Message: "failed",
+			StructuredData: map[string]interface{}{
+				"req":  map[string]string{"id": "r-1"},
+				"http": map[string]interface{}{"status": float64(500)},
+			}},
+		{ID: 2, Severity: 6, Version: 1, Timestamp: ts.Add(time.Second), Hostname: "web-2", Message: "ok",
+			StructuredData: map[string]interface{}{"req": map[string]string{"id": "r-2"}}},
+		{ID: 3, Severity: 6, Version: 1, Timestamp: ts.Add(2 * time.Second), Hostname: "web-2", Message: "plain"},
+	}
+
+	keys := CommonKeys(entries, DefaultStructuredColumns)
+	if !reflect.DeepEqual(keys, []string{"req.id", "http.status"}) {
+		t.Fatalf("Expected keys by frequency, got %v", keys)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, keys)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	mapping, ok := file.Lookup(ColumnsMetadataKey)
+	if !ok {
+		t.Fatal("Expected the column mapping in the file metadata")
+	}
+	var columns []Column
+	if err := json.Unmarshal([]byte(mapping), &columns); err != nil || len(columns) != 2 || columns[0].Name != "sd_req_id" {
+		t.Errorf("Unexpected column mapping %s: %v", mapping, err)
+	}
+
+	reader := parquet.NewGenericReader[exportedRow](file)
+	rows := make([]exportedRow, 3)
+	if n, _ := reader.Read(rows); n != 3 {
+		t.Fatalf("Expected 3 rows, got %d", n)
+	}
+
+	if rows[0].ID != 1 || rows[0].Severity != 3 || rows[0].Hostname != "web-1" || !rows[0].Timestamp.Equal(ts) {
+		t.Errorf("Unexpected first row %+v", rows[0])
+	}
+	if rows[0].RequestID == nil || *rows[0].RequestID != "r-1" || rows[0].Status == nil || *rows[0].Status != "500" {
+		t.Errorf("Expected flattened structured data in the first row, got %+v", rows[0])
+	}
+	if rows[1].Status != nil || rows[1].RequestID == nil || *rows[1].RequestID != "r-2" {
+		t.Errorf("Expected only the request ID in the second row, got %+v", rows[1])
+	}
+	if rows[2].StructuredData != nil || rows[2].RequestID != nil {
+		t.Errorf("Expected nulls for an entry without structured data, got %+v", rows[2])
+	}
+	if rows[0].StructuredData == nil || !json.Valid([]byte(*rows[0].StructuredData)) {
+		t.Errorf("Expected the structured data as JSON, got %v", rows[0].StructuredData)
+	}
+}
+
+func TestColumns_Names(t *testing.T) {
+	columns := Columns([]string{"origin.ip", "origin-ip", "meta.sequenceId"})
+	want := []string{"sd_origin_ip", "sd_origin_ip_2", "sd_meta_sequenceId"}
+	for i, column := range columns {
+		if column.Name != want[i] {
+			t.Errorf("Expected column %q for %q, got %q", want[i], column.Key, column.Name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// conformanceCase is a message captured from a syslog sender and the fields it must parse into
+type conformanceCase struct {
+	name string
+	raw  string
+	// legacy marks BSD syslog (RFC3164) messages, which are not read as RFC5424: lenient mode keeps
+	// them whole as local0 entries stamped with the time they were parsed, and strict mode rejects
+	// them. Only the severity inferred from their content is compared.
+	legacy bool
+	want   types.LogEntry
+}
+
+// senderCorpora are messages captured from common senders in their default and RFC5424 formats.
+// Add a capture here when a sender's format breaks parsing, before changing the parser.
+var senderCorpora = map[string][]conformanceCase{
+	"rsyslog": {
+		{
+			name: "RSYSLOG_SyslogProtocol23Format",
+			raw:  "<13>1 2023-10-15T14:30:45.123456+02:00 web01 app 1234 - - started worker",
+			want: types.LogEntry{Facility: 1, Severity: 5, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123456+02:00"),
+				Hostname: "web01", AppName: "app", ProcID: "1234", Message: "started worker"},
+		},
+		{
+			name: "kernel message with parentheses",
+			raw:  "<3>1 2023-10-15T14:30:45.000001+00:00 web01 kernel - - - EXT4-fs error (device sda1): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0",
+			want: types.LogEntry{Facility: 0, Severity: 3, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.000001Z"),
+				Hostname: "web01", AppName: "kernel", Message: "EXT4-fs error (device sda1): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0"},
+		},
+		{
+			name:   "RSYSLOG_TraditionalForwardFormat",
+			raw:    "<86>Oct 15 14:30:45 web01 sshd[2231]: Accepted publickey for deploy from 10.0.0.5 port 52114 ssh2",
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+	},
+	"syslog-ng": {
+		{
+			name: "IETF syslog with BOM and sequence ID",
+			raw:  "<38>1 2023-10-15T14:30:45+00:00 web01 sshd 2231 - [meta sequenceId=\"12\"] \ufeffAccepted publickey for deploy from 10.0.0.5 port 52114 ssh2",
+			want: types.LogEntry{Facility: 4, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45Z"),
+				Hostname: "web01", AppName: "sshd", ProcID: "2231",
+				StructuredData: map[string]interface{}{"meta": map[string]string{"sequenceId": "12"}},
+				Message:        "Accepted publickey for deploy from 10.0.0.5 port 52114 ssh2"},
+		},
+		{
+			name: "several structured data elements",
+			raw:  `<165>1 2023-10-15T14:30:45.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] An application event log entry`,
+			want: types.LogEntry{Facility: 20, Severity: 5, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.003Z"),
+				Hostname: "mymachine.example.com", AppName: "evntslog", MsgID: "ID47",
+				StructuredData: map[string]interface{}{
+					"exampleSDID@32473":     map[string]string{"iut": "3", "eventSource": "Application", "eventID": "1011"},
+					"examplePriority@32473": map[string]string{"class": "high"},
+				},
+				Message: "An application event log entry"},
+		},
+		{
+			name: "internal message with quotes",
+			raw:  `<46>1 2023-10-15T14:30:45+02:00 web01 syslog-ng 1202 - [meta sequenceId="1"] syslog-ng starting up; version='3.38.1'`,
+			want: types.LogEntry{Facility: 5, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45+02:00"),
+				Hostname: "web01", AppName: "syslog-ng", ProcID: "1202",
+				StructuredData: map[string]interface{}{"meta": map[string]string{"sequenceId": "1"}},
+				Message:        "syslog-ng starting up; version='3.38.1'"},
+		},
+	},
+	"nginx": {
+		{
+			name:   "access log",
+			raw:    `<190>Oct 15 14:30:45 web01 nginx: 10.0.0.1 - - [15/Oct/2023:14:30:45 +0000] "GET /api/users HTTP/1.1" 200 1532 "-" "curl/8.4.0"`,
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+		{
+			name:   "access log with a 5xx status",
+			raw:    `<190>Oct 15 14:30:45 web01 nginx: 10.0.0.1 - - [15/Oct/2023:14:30:45 +0000] "GET /api/users HTTP/1.1" 502 157 "-" "curl/8.4.0"`,
+			legacy: true,
+			want:   types.LogEntry{Severity: 3},
+		},
+		{
+			name:   "error log",
+			raw:    `<187>Oct 15 14:30:45 web01 nginx: 2023/10/15 14:30:45 [error] 1234#1234: *5 connect() failed (111: Connection refused) while connecting to upstream, client: 10.0.0.1, server: _, request: "GET / HTTP/1.1", upstream: "http://127.0.0.1:8080/"`,
+			legacy: true,
+			want:   types.LogEntry{Severity: 3},
+		},
+	},
+	"haproxy": {
+		{
+			name:   "default HTTP log",
+			raw:    `<134>Oct 15 14:30:45 haproxy[1234]: 10.0.0.1:51234 [15/Oct/2023:14:30:45.123] http-in backend/srv1 0/0/1/2/3 200 512 - - ---- 1/1/0/0/0 0/0 "GET / HTTP/1.1"`,
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+		{
+			name: "log-format rfc5424",
+			raw:  `<134>1 2023-10-15T14:30:45.123456+00:00 lb01 haproxy 1234 - - 10.0.0.1:51234 [15/Oct/2023:14:30:45.123] http-in backend/<NOSRV> 0/-1/-1/-1/0 503 217 - - SC-- 1/1/0/0/0 0/0 "GET / HTTP/1.1"`,
+			want: types.LogEntry{Facility: 16, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123456Z"),
+				Hostname: "lb01", AppName: "haproxy", ProcID: "1234",
+				Message: `10.0.0.1:51234 [15/Oct/2023:14:30:45.123] http-in backend/<NOSRV> 0/-1/-1/-1/0 503 217 - - SC-- 1/1/0/0/0 0/0 "GET / HTTP/1.1"`},
+		},
+		{
+			name: "log-format-sd",
+			raw:  `<134>1 2023-10-15T14:30:45.123456+00:00 lb01 haproxy 1234 - [exampleSDID@1234 bytes_read="512" status="200"] 10.0.0.1:51234 http-in backend/srv1`,
+			want: types.LogEntry{Facility: 16, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123456Z"),
+				Hostname: "lb01", AppName: "haproxy", ProcID: "1234",
+				StructuredData: map[string]interface{}{"exampleSDID@1234": map[string]string{"bytes_read": "512", "status": "200"}},
+				Message:        "10.0.0.1:51234 http-in backend/srv1"},
+		},
+	},
+	"cisco": {
+		{
+			name:   "IOS with sequence number and uptime",
+			raw:    "<189>52: *Mar  1 00:01:02.123: %LINK-3-UPDOWN: Interface GigabitEthernet0/1, changed state to down",
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+		{
+			name:   "ASA",
+			raw:    "<166>%ASA-6-302013: Built inbound TCP connection 12345 for outside:10.0.0.1/51234 (10.0.0.1/51234) to inside:192.168.1.10/443 (192.168.1.10/443)",
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+		{
+			name: "NX-OS logging rfc-strict 5424",
+			raw:  "<189>1 2023-10-15T14:30:45.123Z nexus1 - - - - %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access",
+			want: types.LogEntry{Facility: 23, Severity: 5, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123Z"),
+				Hostname: "nexus1", Message: "%ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access"},
+		},
+	},
+	"juniper": {
+		{
+			name: "structured-data format",
+			raw:  `<165>1 2023-10-15T14:30:45.123Z mx960 mgd 3046 UI_DBASE_LOGOUT_EVENT [junos@2636.1.1.1.2.18 username="regress"] User 'regress' exiting configuration mode`,
+			want: types.LogEntry{Facility: 20, Severity: 5, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123Z"),
+				Hostname: "mx960", AppName: "mgd", ProcID: "3046", MsgID: "UI_DBASE_LOGOUT_EVENT",
+				StructuredData: map[string]interface{}{"junos@2636.1.1.1.2.18": map[string]string{"username": "regress"}},
+				Message:        "User 'regress' exiting configuration mode"},
+		},
+		{
+			name: "SRX session log with hyphenated parameters",
+			raw:  `<14>1 2023-10-15T14:30:45.456+02:00 srx1 RT_FLOW - RT_FLOW_SESSION_CREATE [junos@2636.1.1.1.2.129 source-address="10.0.0.1" source-port="51234" destination-address="8.8.8.8" destination-port="53" service-name="junos-dns-udp"] session created 10.0.0.1/51234->8.8.8.8/53`,
+			want: types.LogEntry{Facility: 1, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.456+02:00"),
+				Hostname: "srx1", AppName: "RT_FLOW", MsgID: "RT_FLOW_SESSION_CREATE",
+				StructuredData: map[string]interface{}{"junos@2636.1.1.1.2.129": map[string]string{
+					"source-address": "10.0.0.1", "source-port": "51234", "destination-address": "8.8.8.8",
+					"destination-port": "53", "service-name": "junos-dns-udp",
+				}},
+				Message: "session created 10.0.0.1/51234->8.8.8.8/53"},
+		},
+		{
+			name:   "default format",
+			raw:    "<28>Oct 15 14:30:45 srx1 RT_FLOW: RT_FLOW_SESSION_DENY: session denied 10.0.0.1/51234->10.0.0.2/22 junos-ssh 6(0)",
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+	},
+	"docker": {
+		{
+			name:   "syslog driver default format",
+			raw:    "<30>Oct 15 14:30:45 myapp[1234]: GET /health 200 0.4ms",
+			legacy: true,
+			want:   types.LogEntry{Severity: 6},
+		},
+		{
+			name: "syslog-format rfc5424",
+			raw:  "<30>1 2023-10-15T14:30:45Z dockerhost 3f2a9c8d1e0b 1234 3f2a9c8d1e0b - listening on :8080",
+			want: types.LogEntry{Facility: 3, Severity: 6, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45Z"),
+				Hostname: "dockerhost", AppName: "3f2a9c8d1e0b", ProcID: "1234", MsgID: "3f2a9c8d1e0b", Message: "listening on :8080"},
+		},
+		{
+			name: "syslog-format rfc5424micro",
+			raw:  "<27>1 2023-10-15T14:30:45.123456Z dockerhost web 1234 web - panic: runtime error: invalid memory address or nil pointer dereference",
+			want: types.LogEntry{Facility: 3, Severity: 3, Version: 1, Timestamp: timestamp("2023-10-15T14:30:45.123456Z"),
+				Hostname: "dockerhost", AppName: "web", ProcID: "1234", MsgID: "web",
+				Message: "panic: runtime error: invalid memory address or nil pointer dereference"},
+		},
+	},
+}
+
+// timestamp parses an RFC3339 timestamp of an expected entry
+func timestamp(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestConformance_Senders(t *testing.T) {
+	lenient := NewRFC5424Parser(false)
+	strict := NewRFC5424Parser(true)
+
+	for sender, cases := range senderCorpora {
+		for _, tt := range cases {
+			t.Run(sender+"/"+tt.name, func(t *testing.T) {
+				entry, err := lenient.Parse(tt.raw)
+				if err != nil {
+					t.Fatalf("Parse failed: %v", err)
+				}
+
+				want := tt.want
+				if tt.legacy {
+					want = types.LogEntry{Facility: 16, Severity: tt.want.Severity, Version: 1, Message: tt.raw}
+				}
+				if entry.Facility != want.Facility || entry.Severity != want.Severity || entry.Priority != want.Facility*8+want.Severity {
+					t.Errorf("Expected facility %d and severity %d, got %d and %d (priority %d)",
+						want.Facility, want.Severity, entry.Facility, entry.Severity, entry.Priority)
+				}
+				if !want.Timestamp.IsZero() && !entry.Timestamp.Equal(want.Timestamp) {
+					t.Errorf("Expected timestamp %v, got %v", want.Timestamp, entry.Timestamp)
+				}
+				got := [...]string{entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID, entry.Message}
+				expected := [...]string{want.Hostname, want.AppName, want.ProcID, want.MsgID, want.Message}
+				if entry.Version != want.Version || got != expected {
+					t.Errorf("Expected version %d and fields %q, got %d and %q", want.Version, expected, entry.Version, got)
+				}
+				if len(entry.StructuredData) != 0 || len(want.StructuredData) != 0 {
+					if !reflect.DeepEqual(entry.StructuredData, want.StructuredData) {
+						t.Errorf("Expected structured data %v, got %v", want.StructuredData, entry.StructuredData)
+					}
+				}
+
+				// Strict mode accepts exactly the RFC5424 messages
+				if _, err := strict.Parse(tt.raw); (err != nil) != tt.legacy {
+					t.Errorf("Expected strict mode to reject only legacy messages, got %v", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// formatPlaceholders are the fields a log format can contain and the patterns they match. The
+// timestamp is matched lazily and then read like an RFC5424 timestamp, so layouts from the timestamp
+// rules that contain spaces work too.
+var formatPlaceholders = map[string]string{
+	"timestamp":   `.+?`,
+	"level":       `[A-Za-z]+|[0-7]`,
+	"tracking_id": `\S*?`,
+	"message":     `(?s:.*)`,
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// LineFormat is a compiled log format such as "{{timestamp}}|{{level}}|{{tracking_id}}|{{message}}",
+// which reads application log lines that are not syslog messages into the RFC5424 fields
+type LineFormat struct {
+	pattern *regexp.Regexp
+	fields  []string
+}
+
+// NewLineFormat compiles a log format. It must contain {{message}}, and each placeholder at most once.
+func NewLineFormat(format string) (*LineFormat, error) {
+	var pattern strings.Builder
+	var fields []string
+	seen := make(map[string]bool)
+
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(format, -1) {
+		name := format[match[2]:match[3]]
+		expression, ok := formatPlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {{%s}} in log format", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("placeholder {{%s}} appears more than once in log format", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+
+		pattern.WriteString(regexp.QuoteMeta(format[last:match[0]]))
+		pattern.WriteString("(" + expression + ")")
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(format[last:]))
+	pattern.WriteString("$")
+
+	if !seen["message"] {
+		return nil, fmt.Errorf("log format must contain the {{message}} placeholder")
+	}
+	return &LineFormat{pattern: regexp.MustCompile(pattern.String()), fields: fields}, nil
+}
+
+// Parse reads a line in the format into a LogEntry. The level becomes the severity and the tracking
+// ID the MSGID, so entries can be filtered the same way as syslog messages. It reports false when the
+// line does not match, including when the timestamp or level cannot be read.
+func (f *LineFormat) Parse(line string, timestamps *TimestampFormat) (*types.LogEntry, bool) {
+	if f == nil {
+		return nil, false
+	}
+	match := f.pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil, false
+	}
+
+	entry := &types.LogEntry{
+		Version:   1,
+		Timestamp: time.Now(),
+		CreatedAt: time.Now(),
+	}
+	severity := 6 // Informational when the format has no level
+	for i, name := range f.fields {
+		value := match[i+1]
+		switch name {
+		case "timestamp":
+			timestamp, ok := parseLineTimestamp(value, timestamps)
+			if !ok {
+				return nil, false
+			}
+			entry.Timestamp = timestamp
+		case "level":
+			level, ok := types.SeverityForLevel(value)
+			if !ok {
+				return nil, false
+			}
+			severity = level
+		case "tracking_id":
+			if value != "-" {
+				entry.MsgID = value
+			}
+		case "message":
+			entry.Message = strings.TrimSpace(value)
+		}
+	}
+
+	// Facility 16 = local0, as for other messages without a PRI
+	entry.SetPriority(16*8 + severity)
+	return entry, true
+}
+
+// parseLineTimestamp reads the whole of value as an RFC3339 timestamp or, failing that, with the
+// sender's timestamp rule
+func parseLineTimestamp(value string, timestamps *TimestampFormat) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return timestamp, true
+	}
+	if timestamp, ok := parseLeapSecond(value); ok {
+		return timestamp, true
+	}
+	if timestamps != nil {
+		if timestamp, rest, ok := timestamps.Parse(value, time.Now()); ok && rest == "" {
+			return timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
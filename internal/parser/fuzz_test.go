@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// fuzzSeeds are well-formed and malformed messages the fuzz targets start from
+var fuzzSeeds = []string{
+	"<165>1 2023-10-15T14:30:45.123Z web01 nginx 1234 access - User login successful",
+	"<134>1 2023-10-15T14:30:45Z - - - - - System startup complete",
+	`<165>1 2023-10-15T14:30:45Z web01 nginx 1234 access [exampleSDID@32473 iut="3" eventSource="Application"] User login`,
+	`<165>1 2023-10-15T14:30:45+02:00 web01 app - - [a x="1"][b y="\"q\" \\ \]"] two elements`,
+	"<165>1 - web01 app - - - nil timestamp",
+	"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+	"<999>1 2023-10-15T14:30:45Z host app - - - out of range",
+	"<>1", "<", "<1", "<13>", "<13>1", "<13>2 x", "[", "[[[]", "- - -",
+	"<13>1 2023-10-15T14:30:45Z h a p m [unterminated x=\"y\" msg",
+	"<13>1 2023-10-15T14:30:45Z h a p m [] empty element",
+	"plain text without any header",
+}
+
+// formatRFC5424 formats an entry as an RFC5424 message, writing empty fields as nil values and
+// escaping structured data parameter values
+func formatRFC5424(entry *types.LogEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>%d %s", entry.Priority, entry.Version, entry.Timestamp.Format(time.RFC3339Nano))
+	for _, field := range []string{entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID} {
+		if field == "" {
+			field = "-"
+		}
+		b.WriteString(" " + field)
+	}
+
+	if len(entry.StructuredData) == 0 {
+		b.WriteString(" -")
+	} else {
+		b.WriteString(" ")
+		escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+		for _, id := range sortedKeys(entry.StructuredData) {
+			params := entry.StructuredData[id].(map[string]string)
+			b.WriteString("[" + id)
+			for _, key := range sortedKeys(params) {
+				fmt.Fprintf(&b, ` %s="%s"`, key, escaper.Replace(params[key]))
+			}
+			b.WriteString("]")
+		}
+	}
+
+	if entry.Message != "" {
+		b.WriteString(" " + entry.Message)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkEntry checks the invariants every parsed entry must satisfy
+func checkEntry(t *testing.T, raw string, entry *types.LogEntry) {
+	t.Helper()
+	if entry == nil {
+		t.Fatalf("Parse(%q) returned neither an entry nor an error", raw)
+	}
+	if entry.Priority < 0 || entry.Priority > 191 {
+		t.Errorf("Parse(%q) priority %d out of range", raw, entry.Priority)
+	}
+	if entry.Facility != entry.Priority>>3 || entry.Severity != entry.Priority&7 {
+		t.Errorf("Parse(%q) facility %d and severity %d do not match priority %d", raw, entry.Facility, entry.Severity, entry.Priority)
+	}
+	if entry.Version != 1 {
+		t.Errorf("Parse(%q) version %d, want 1", raw, entry.Version)
+	}
+}
+
+func FuzzRFC5424Parser_Strict(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	parser := &RFC5424Parser{strictMode: true}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		entry, err := parser.Parse(raw)
+		if err != nil {
+			if entry != nil {
+				t.Errorf("Parse(%q) returned an entry with error %v", raw, err)
+			}
+			return
+		}
+		checkEntry(t, raw, entry)
+	})
+}
+
+func FuzzRFC5424Parser_Lenient(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	parser := &RFC5424Parser{strictMode: false}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		entry, err := parser.Parse(raw)
+		if raw == "" {
+			if err == nil {
+				t.Error("Parse accepted an empty message")
+			}
+			return
+		}
+		// Lenient parsing must keep every message, falling back to storing it as is
+		if err != nil {
+			t.Fatalf("Parse(%q) failed in lenient mode: %v", raw, err)
+		}
+		checkEntry(t, raw, entry)
+	})
+}
+
+// FuzzRFC5424Parser_RoundTrip checks that formatting a parsed entry and parsing it again, in strict
+// mode, yields the same entry
+func FuzzRFC5424Parser_RoundTrip(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	lenient := &RFC5424Parser{strictMode: false}
+	strict := &RFC5424Parser{strictMode: true}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		first, err := lenient.Parse(raw)
+		if err != nil {
+			return
+		}
+		formatted := formatRFC5424(first)
+		second, err := strict.Parse(formatted)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed on the formatted entry %q: %v", raw, formatted, err)
+		}
+
+		// The fallback keeps a malformed message as it was received, while a formatted message
+		// goes through the RFC5424 parser, which trims it and reads "-" as no message
+		wantMessage := strings.TrimSpace(first.Message)
+		if wantMessage == "-" {
+			wantMessage = ""
+		}
+
+		if second.Priority != first.Priority || second.Version != first.Version || !second.Timestamp.Equal(first.Timestamp) ||
+			second.Hostname != first.Hostname || second.AppName != first.AppName || second.ProcID != first.ProcID ||
+			second.MsgID != first.MsgID || second.Message != wantMessage {
+			t.Fatalf("Round trip of %q through %q changed the entry:\n first: %+v\nsecond: %+v", raw, formatted, first, second)
+		}
+		if !reflect.DeepEqual(second.StructuredData, first.StructuredData) {
+			t.Fatalf("Round trip of %q through %q changed structured data from %v to %v", raw, formatted,
+				first.StructuredData, second.StructuredData)
+		}
+	})
+}
+
+func TestRFC5424Parser_LargeInputs(t *testing.T) {
+	parser := NewRFC5424Parser(false)
+	header := "<13>1 2023-10-15T14:30:45Z host app - - "
+
+	inputs := map[string]string{
+		"unterminated brackets": header + strings.Repeat("[", 1<<20),
+		"many elements":         header + strings.Repeat(`[id k="v"]`, 1<<16) + " msg",
+		"nested elements":       header + "[" + strings.Repeat("[", 1<<16) + strings.Repeat("]", 1<<16) + "] msg",
+		"many parameters":       header + "[id" + strings.Repeat(` k="v"`, 1<<16) + "] msg",
+		"long message":          header + "- " + strings.Repeat("x", 1<<22),
+		"long PRI":              "<" + strings.Repeat("9", 1<<20) + ">1 msg",
+	}
+	for name, raw := range inputs {
+		t.Run(name, func(t *testing.T) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if _, err := parser.Parse(raw); err != nil {
+					t.Errorf("Parse failed: %v", err)
+				}
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("Parse did not return")
+			}
+		})
+	}
+}
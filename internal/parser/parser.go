@@ -12,38 +12,76 @@ import (
 
 // RFC5424Parser implements RFC5424 syslog protocol parsing
 type RFC5424Parser struct {
-	strictMode bool // Whether to reject malformed messages
+	strictMode bool            // Whether to reject malformed messages
+	severities *SeverityTable  // Infers the severity of malformed messages kept in lenient mode
+	timestamps *TimestampRules // Read the timestamps of senders not using RFC3339
+	format     *LineFormat     // Reads application log lines without a PRI
+	transforms *TransformRules // Rewrite the messages of senders before they are parsed
 }
 
 // NewRFC5424Parser creates a new RFC5424 parser
 func NewRFC5424Parser(strictMode bool) interfaces.LogParser {
 	return &RFC5424Parser{
 		strictMode: strictMode,
+		severities: DefaultSeverityTable(),
 	}
 }
 
-// SetFormat is a no-op for RFC5424 parser as it has a fixed format
+// SetSeverityTable replaces the rules inferring the severity of malformed messages from their content
+func (p *RFC5424Parser) SetSeverityTable(table *SeverityTable) {
+	p.severities = table
+}
+
+// SetTimestampRules sets the layouts and time zones used for timestamps that are not RFC3339, by sender
+func (p *RFC5424Parser) SetTimestampRules(rules *TimestampRules) {
+	p.timestamps = rules
+}
+
+// SetTransformRules sets the rewrites applied to messages before they are parsed, by sender
+func (p *RFC5424Parser) SetTransformRules(rules *TransformRules) {
+	p.transforms = rules
+}
+
+// SetFormat sets the format of application log lines, which are read when a message has no PRI.
+// Syslog messages are always read as RFC5424.
 func (p *RFC5424Parser) SetFormat(format string) error {
-	// RFC5424 has a fixed format, so this is a no-op
+	lineFormat, err := NewLineFormat(format)
+	if err != nil {
+		return err
+	}
+	p.format = lineFormat
 	return nil
 }
 
 // Parse converts a raw RFC5424 log message string into a LogEntry
 func (p *RFC5424Parser) Parse(rawMessage string) (*types.LogEntry, error) {
+	return p.ParseFrom(rawMessage, "", "")
+}
+
+// ParseFrom converts a raw RFC5424 log message string into a LogEntry, rewriting it with the
+// transform rule and reading a timestamp that is not RFC3339 with the timestamp rule matching the
+// sender's address and tenant
+func (p *RFC5424Parser) ParseFrom(rawMessage, sourceIP, tenant string) (*types.LogEntry, error) {
 	if rawMessage == "" {
 		return nil, fmt.Errorf("raw message cannot be empty")
 	}
+	if rawMessage = p.transforms.Apply(rawMessage, sourceIP, tenant); rawMessage == "" {
+		return nil, fmt.Errorf("raw message is empty once transformed")
+	}
 
-	return p.parseRFC5424(rawMessage)
+	return p.parseRFC5424(rawMessage, p.timestamps.Match(sourceIP, tenant))
 }
 
 // parseRFC5424 parses messages according to RFC5424 specification
-func (p *RFC5424Parser) parseRFC5424(rawMessage string) (*types.LogEntry, error) {
+func (p *RFC5424Parser) parseRFC5424(rawMessage string, format *TimestampFormat) (*types.LogEntry, error) {
 	// RFC5424 format: <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [STRUCTURED-DATA] MSG
 
 	// Parse PRI (priority) part
 	pri, remaining, err := p.parsePRI(rawMessage)
 	if err != nil {
+		if entry, ok := p.format.Parse(rawMessage, format); ok {
+			return entry, nil
+		}
 		if p.strictMode {
 			return nil, fmt.Errorf("RFC5424 parse error: %w", err)
 		}
@@ -60,7 +98,7 @@ func (p *RFC5424Parser) parseRFC5424(rawMessage string) (*types.LogEntry, error)
 	}
 
 	// Parse TIMESTAMP
-	timestamp, remaining, err := p.parseTimestamp(remaining)
+	timestamp, remaining, err := p.parseTimestamp(remaining, format)
 	if err != nil {
 		fmt.Println("Error parsing timestamp:", err)
 		if p.strictMode {
@@ -87,8 +125,9 @@ func (p *RFC5424Parser) parseRFC5424(rawMessage string) (*types.LogEntry, error)
 		return nil, fmt.Errorf("RFC5424 structured data parse error: %w", err)
 	}
 
-	// Remaining is the MSG part
+	// Remaining is the MSG part, which senders such as syslog-ng start with a UTF-8 BOM
 	message := strings.TrimSpace(remaining)
+	message = strings.TrimPrefix(message, "\ufeff")
 	if message == "-" {
 		message = "" // Nil value for message
 	}
@@ -158,8 +197,9 @@ func (p *RFC5424Parser) parseVersion(remaining string) (int, string, error) {
 	return version, "", nil
 }
 
-// parseTimestamp extracts and parses the timestamp field
-func (p *RFC5424Parser) parseTimestamp(remaining string) (time.Time, string, error) {
+// parseTimestamp extracts and parses the timestamp field, falling back to the layouts of format, if
+// any, for timestamps that are not RFC3339
+func (p *RFC5424Parser) parseTimestamp(remaining string, format *TimestampFormat) (time.Time, string, error) {
 	parts := strings.SplitN(remaining, " ", 2)
 	if len(parts) < 1 {
 		fmt.Println("Error parsing timestamp:", remaining)
@@ -182,6 +222,16 @@ func (p *RFC5424Parser) parseTimestamp(remaining string) (time.Time, string, err
 		// Try RFC3339 without nanoseconds
 		timestamp, err = time.Parse(time.RFC3339, timestampStr)
 		if err != nil {
+			if leap, ok := parseLeapSecond(timestampStr); ok {
+				timestamp, err = leap, nil
+			}
+		}
+		if err != nil {
+			if format != nil {
+				if timestamp, rest, ok := format.Parse(remaining, time.Now()); ok {
+					return timestamp, rest, nil
+				}
+			}
 			return time.Time{}, remaining, fmt.Errorf("invalid TIMESTAMP format: %s", timestampStr)
 		}
 	}
@@ -228,30 +278,9 @@ func (p *RFC5424Parser) parseStructuredData(remaining string) (map[string]interf
 
 	structuredData := make(map[string]interface{})
 
-	// Find all structured data elements
+	// Parse all structured data elements
 	for strings.HasPrefix(remaining, "[") {
-		// Find the end of this structured data element
-		depth := 0
-		end := -1
-		for i, r := range remaining {
-			if r == '[' {
-				depth++
-			} else if r == ']' {
-				depth--
-				if depth == 0 {
-					end = i
-					break
-				}
-			}
-		}
-
-		if end == -1 {
-			return nil, remaining, fmt.Errorf("malformed structured data: missing closing bracket")
-		}
-
-		// Parse this structured data element
-		element := remaining[1:end] // Remove [ and ]
-		sdID, params, err := p.parseStructuredDataElement(element)
+		sdID, params, rest, err := p.parseStructuredDataElement(remaining)
 		if err != nil {
 			return nil, remaining, fmt.Errorf("malformed structured data element: %w", err)
 		}
@@ -259,49 +288,88 @@ func (p *RFC5424Parser) parseStructuredData(remaining string) (map[string]interf
 		structuredData[sdID] = params
 
 		// Move to next element or end
-		remaining = strings.TrimSpace(remaining[end+1:])
+		remaining = strings.TrimSpace(rest)
 	}
 
 	return structuredData, remaining, nil
 }
 
-// parseStructuredDataElement parses a single structured data element
-func (p *RFC5424Parser) parseStructuredDataElement(element string) (string, map[string]string, error) {
-	// Format: SD-ID param1="value1" param2="value2"
-	parts := strings.Fields(element)
-	if len(parts) == 0 {
-		return "", nil, fmt.Errorf("empty structured data element")
+// parseStructuredDataElement parses the structured data element at the start of remaining and
+// returns the text after it
+func (p *RFC5424Parser) parseStructuredDataElement(remaining string) (string, map[string]string, string, error) {
+	// Format: [SD-ID param1="value1" param2="value2"], with ", \ and ] escaped in values
+	i := 1
+	sdID := readSDName(remaining[i:])
+	if sdID == "" {
+		return "", nil, remaining, fmt.Errorf("missing or invalid SD-ID")
 	}
+	i += len(sdID)
 
-	sdID := parts[0]
 	params := make(map[string]string)
-
-	// Parse parameters
-	for i := 1; i < len(parts); i++ {
-		param := parts[i]
-
-		// Find the = separator
-		eqIndex := strings.Index(param, "=")
-		if eqIndex == -1 {
-			continue // Skip malformed parameters
+	for {
+		if i == len(remaining) {
+			return "", nil, remaining, fmt.Errorf("missing closing bracket")
+		}
+		if remaining[i] == ']' {
+			return sdID, params, remaining[i+1:], nil
+		}
+		if remaining[i] != ' ' {
+			return "", nil, remaining, fmt.Errorf("unexpected %q after %s", remaining[i], sdID)
+		}
+		for i < len(remaining) && remaining[i] == ' ' {
+			i++
+		}
+		if i < len(remaining) && remaining[i] == ']' {
+			continue
 		}
 
-		key := param[:eqIndex]
-		value := param[eqIndex+1:]
+		name := readSDName(remaining[i:])
+		i += len(name)
+		if name == "" || !strings.HasPrefix(remaining[i:], `="`) {
+			return "", nil, remaining, fmt.Errorf("malformed parameter in %s, expected name=\"value\"", sdID)
+		}
+		i += 2
 
-		// Remove quotes from value
-		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
-			value = value[1 : len(value)-1]
-			// Unescape quotes and backslashes
-			value = strings.ReplaceAll(value, `\"`, `"`)
-			value = strings.ReplaceAll(value, `\\`, `\`)
-			value = strings.ReplaceAll(value, `\]`, `]`)
+		value, n, ok := readParamValue(remaining[i:])
+		if !ok {
+			return "", nil, remaining, fmt.Errorf("unterminated value of parameter %s", name)
 		}
+		params[name] = value
+		i += n
+	}
+}
 
-		params[key] = value
+// readSDName returns the SD-ID or parameter name at the start of s: printable US-ASCII characters
+// other than '=', ' ', ']' and '"'
+func readSDName(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			return s[:i]
+		}
 	}
+	return s
+}
 
-	return sdID, params, nil
+// readParamValue reads a parameter value up to its closing quote, unescaping \", \\ and \]. A
+// backslash before any other character is kept. It returns the value and the length of the
+// escaped value including the closing quote.
+func readParamValue(s string) (string, int, bool) {
+	var value strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return value.String(), i + 1, true
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+				c = s[i]
+			}
+			value.WriteByte(c)
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, false
 }
 
 // fallbackParse creates a LogEntry for malformed messages
@@ -316,10 +384,11 @@ func (p *RFC5424Parser) fallbackParse(rawMessage string) *types.LogEntry {
 		StructuredData: nil,
 		Message:        rawMessage,
 		CreatedAt:      time.Now(),
+		Fallback:       true,
 	}
 
-	// Set default priority (facility 16 = local0, severity 6 = info)
-	entry.SetPriority(134) // 16 * 8 + 6
+	// Facility 16 = local0; the severity is inferred from the content, info when nothing matches
+	entry.SetPriority(16*8 + p.severities.Detect(rawMessage, 6))
 
 	return entry
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestNewRFC5424Parser(t *testing.T) {
@@ -15,11 +16,79 @@ func TestNewRFC5424Parser(t *testing.T) {
 func TestRFC5424Parser_SetFormat(t *testing.T) {
 	parser := NewRFC5424Parser(true)
 	
-	// RFC5424 parser should ignore SetFormat calls
 	err := parser.SetFormat("{{timestamp}} {{level}} {{message}}")
 	if err != nil {
 		t.Errorf("SetFormat() returned error: %v", err)
 	}
+
+	invalid := []string{
+		"{{timestamp}} {{level}}",
+		"{{timestamp}} {{host}} {{message}}",
+		"{{level}} {{level}} {{message}}",
+	}
+	for _, format := range invalid {
+		if err := parser.SetFormat(format); err == nil {
+			t.Errorf("Expected format %q to be rejected", format)
+		}
+	}
+}
+
+func TestRFC5424Parser_LineFormat(t *testing.T) {
+	parser := NewRFC5424Parser(true)
+	if err := parser.SetFormat("{{timestamp}}|{{level}}|{{tracking_id}}|{{message}}"); err != nil {
+		t.Fatalf("SetFormat failed: %v", err)
+	}
+
+	tests := []struct {
+		line     string
+		severity int
+		msgID    string
+		message  string
+	}{
+		{"2024-03-01T10:00:00Z|ERROR|req-42|payment failed", 3, "req-42", "payment failed"},
+		{"2024-03-01T10:00:00Z|warn|-|disk almost full", 4, "", "disk almost full"},
+		{"2024-03-01T10:00:00Z|TRACE||entering handler", 7, "", "entering handler"},
+		{"2024-03-01T10:00:00Z|5|job-7|a|message|with|bars", 5, "job-7", "a|message|with|bars"},
+	}
+	for _, tt := range tests {
+		entry, err := parser.Parse(tt.line)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if entry.Severity != tt.severity || entry.Facility != 16 || entry.MsgID != tt.msgID || entry.Message != tt.message {
+			t.Errorf("Parse(%q) = severity %d, facility %d, msg_id %q, message %q", tt.line, entry.Severity, entry.Facility, entry.MsgID, entry.Message)
+		}
+		if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !entry.Timestamp.Equal(want) {
+			t.Errorf("Parse(%q) timestamp = %v, want %v", tt.line, entry.Timestamp, want)
+		}
+	}
+
+	// Lines with an unknown level or an unreadable timestamp are malformed, and syslog messages are
+	// still read as RFC5424
+	for _, line := range []string{"2024-03-01T10:00:00Z|LOUD|req-1|hello", "yesterday|ERROR|req-1|hello", "just text"} {
+		if _, err := parser.Parse(line); err == nil {
+			t.Errorf("Expected %q to be rejected in strict mode", line)
+		}
+	}
+	entry, err := parser.Parse("<34>1 2024-03-01T10:00:00Z host app - ID47 - syslog message")
+	if err != nil || entry.MsgID != "ID47" || entry.Severity != 2 {
+		t.Errorf("Expected an RFC5424 message to be parsed as before, got %+v, %v", entry, err)
+	}
+
+	// The timestamp rules of the sender apply to lines too
+	rules, err := NewTimestampRules([]TimestampRule{{Layouts: []string{"2006-01-02 15:04:05"}, TimeZone: "Europe/Berlin"}})
+	if err != nil {
+		t.Fatalf("NewTimestampRules failed: %v", err)
+	}
+	parser.(*RFC5424Parser).SetTimestampRules(rules)
+	entry, err = parser.Parse("2024-03-01 11:00:00|info|req-9|local time")
+	if err != nil {
+		t.Fatalf("Parse with a timestamp rule failed: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !entry.Timestamp.Equal(want) || entry.Severity != 6 {
+		t.Errorf("Expected info at %v, got %+v", want, entry)
+	}
 }
 
 func TestRFC5424Parser_Parse_ValidMessages(t *testing.T) {
@@ -425,4 +494,251 @@ func TestRFC5424Parser_FallbackParse(t *testing.T) {
 	if entry.Priority != 134 { // default priority
 		t.Errorf("Expected fallback priority to be 134, got: %d", entry.Priority)
 	}
-}
\ No newline at end of file
+
+	if !entry.Fallback {
+		t.Error("Expected the entry to be marked as parsed by the fallback")
+	}
+}
+
+func TestRFC5424Parser_Parse_StructuredDataValues(t *testing.T) {
+	strictParser := NewRFC5424Parser(true)
+	lenientParser := NewRFC5424Parser(false)
+
+	raw := `<165>1 2023-10-15T14:30:45Z web01 app - - [meta@1 path="/a b/c" quote="say \"hi\"" bracket="x\]y" backslash="C:\\dir\n"] done`
+	entry, err := strictParser.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	params, ok := entry.StructuredData["meta@1"].(map[string]string)
+	if !ok {
+		t.Fatalf("Structured data element missing: %v", entry.StructuredData)
+	}
+	want := map[string]string{"path": "/a b/c", "quote": `say "hi"`, "bracket": "x]y", "backslash": `C:\dir\n`}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("Param %s = %q, want %q", key, params[key], value)
+		}
+	}
+	if entry.Message != "done" {
+		t.Errorf("Message = %q, want %q", entry.Message, "done")
+	}
+
+	// Parameters must be name="value"; lenient parsing keeps the malformed element in the message
+	malformed := `<165>1 2023-10-15T14:30:45Z web01 app - - [meta@1 unquoted=value] done`
+	if _, err := strictParser.Parse(malformed); err == nil {
+		t.Error("Expected an unquoted parameter value to be rejected in strict mode")
+	}
+	entry, err = lenientParser.Parse(malformed)
+	if err != nil {
+		t.Fatalf("Parse() error in lenient mode = %v", err)
+	}
+	if entry.StructuredData != nil || entry.Message != "[meta@1 unquoted=value] done" {
+		t.Errorf("Expected the malformed element to stay in the message, got %v and %q", entry.StructuredData, entry.Message)
+	}
+}
+
+func TestSeverityTable_Detect(t *testing.T) {
+	table := DefaultSeverityTable()
+	tests := map[string]int{
+		"panic: runtime error: index out of range":                           2,
+		"2024-01-01 12:00:00 ERROR failed to connect":                        3,
+		"level=warn msg=\"disk almost full\"":                                4,
+		"Traceback (most recent call last):":                                 3,
+		`10.0.0.1 - - [01/Jan/2024:12:00:00 +0000] "GET / HTTP/1.1" 503 0`:   3,
+		`10.0.0.1 - - [01/Jan/2024:12:00:00 +0000] "GET / HTTP/1.1" 200 512`: 6,
+		"request done status=502":                                            3,
+		"DEBUG cache miss":                                                   7,
+		"the child panicked":                                                 6,
+		"user logged in":                                                     6,
+		// The most severe match wins
+		"WARN retrying after error": 3,
+	}
+	for message, want := range tests {
+		if got := table.Detect(message, 6); got != want {
+			t.Errorf("Detect(%q) = %d, want %d", message, got, want)
+		}
+	}
+
+	custom, err := NewSeverityTable([]SeverityRule{{Keyword: "OOMKilled", Severity: 2}, {Pattern: `took \d{4,}ms`, Severity: 4}})
+	if err != nil {
+		t.Fatalf("NewSeverityTable failed: %v", err)
+	}
+	if got := custom.Detect("pod oomkilled", 6); got != 2 {
+		t.Errorf("Expected keyword to match ignoring case, got %d", got)
+	}
+	if got := custom.Detect("query took 12000ms", 6); got != 4 {
+		t.Errorf("Expected pattern to match, got %d", got)
+	}
+	if got := custom.Detect("ERROR", 6); got != 6 {
+		t.Errorf("Expected custom rules to replace the defaults, got %d", got)
+	}
+
+	invalid := [][]SeverityRule{
+		{{Keyword: "oops", Severity: 8}},
+		{{Severity: 3}},
+		{{Keyword: "oops", Pattern: "oops", Severity: 3}},
+		{{Pattern: "(", Severity: 3}},
+	}
+	for _, rules := range invalid {
+		if _, err := NewSeverityTable(rules); err == nil {
+			t.Errorf("Expected rules %+v to be rejected", rules)
+		}
+	}
+}
+
+func TestRFC5424Parser_FallbackSeverity(t *testing.T) {
+	parser := NewRFC5424Parser(false)
+	entry, err := parser.Parse("Exception in thread \"main\" java.lang.NullPointerException")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if entry.Severity != 3 || entry.Facility != 16 {
+		t.Errorf("Expected local0.err, got facility %d severity %d", entry.Facility, entry.Severity)
+	}
+
+	table, _ := NewSeverityTable([]SeverityRule{{Keyword: "java", Severity: 7}})
+	parser.(*RFC5424Parser).SetSeverityTable(table)
+	entry, _ = parser.Parse("Exception in thread \"main\" java.lang.NullPointerException")
+	if entry.Severity != 7 {
+		t.Errorf("Expected severity from the configured table, got %d", entry.Severity)
+	}
+}
+
+func TestRFC5424Parser_TimestampRules(t *testing.T) {
+	rules, err := NewTimestampRules([]TimestampRule{
+		{Sources: []string{"10.1.0.0/16", "192.0.2.7"}, Layouts: []string{"02/01/2006:15:04:05"}, TimeZone: "Europe/Berlin"},
+		{Tenants: []string{"tenant-a"}, TimeZone: "America/New_York"},
+		{Sources: []string{"10.2.0.1"}, Layouts: []string{"Jan 2 15:04:05"}},
+	})
+	if err != nil {
+		t.Fatalf("NewTimestampRules failed: %v", err)
+	}
+	parser := NewRFC5424Parser(true).(*RFC5424Parser)
+	parser.SetTimestampRules(rules)
+
+	// Timestamps without a year fall in the last year in which they are not ahead
+	yearless := time.Date(time.Now().Year(), 3, 15, 10, 0, 0, 0, time.UTC)
+	if yearless.After(time.Now().Add(24 * time.Hour)) {
+		yearless = yearless.AddDate(-1, 0, 0)
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		sourceIP string
+		tenant   string
+		want     time.Time
+	}{
+		{"custom layout in the zone of the source", "<34>1 01/03/2024:10:00:00 fw01 - - - - denied", "10.1.4.2", "", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+		{"single address", "<34>1 01/03/2024:10:00:00 fw01 - - - - denied", "192.0.2.7", "", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+		{"naive ISO timestamp of a tenant", "<34>1 2024-07-01T08:30:00.5 app01 - - - - started", "203.0.113.9", "tenant-a", time.Date(2024, 7, 1, 12, 30, 0, 500000000, time.UTC)},
+		{"RFC3339 keeps its offset", "<34>1 2024-07-01T08:30:00+02:00 app01 - - - - started", "203.0.113.9", "tenant-a", time.Date(2024, 7, 1, 6, 30, 0, 0, time.UTC)},
+		{"layout spanning fields", "<34>1 Mar 15 10:00:00 sw01 - - - - link up", "10.2.0.1", "", yearless},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parser.ParseFrom(tt.raw, tt.sourceIP, tt.tenant)
+			if err != nil {
+				t.Fatalf("ParseFrom failed: %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.want) {
+				t.Errorf("Expected timestamp %v, got %v", tt.want, entry.Timestamp)
+			}
+			if entry.Hostname == "" || entry.Message == "" {
+				t.Errorf("Expected the fields after the timestamp to be parsed, got %+v", entry)
+			}
+		})
+	}
+
+	// Messages from other senders still need RFC3339 timestamps
+	if _, err := parser.ParseFrom("<34>1 01/03/2024:10:00:00 fw01 - - - - denied", "10.3.0.1", ""); err == nil {
+		t.Error("Expected a custom timestamp from an unmatched source to be rejected")
+	}
+	if _, err := parser.Parse("<34>1 2024-07-01T08:30:00 app01 - - - - started"); err == nil {
+		t.Error("Expected a naive timestamp without a matching rule to be rejected")
+	}
+
+	// A leap second sorts between the seconds around it
+	leap, err := parser.Parse("<34>1 2016-12-31T23:59:60.5Z ntp01 - - - - leap second")
+	if err != nil {
+		t.Fatalf("Parse of a leap second failed: %v", err)
+	}
+	if want := time.Date(2016, 12, 31, 23, 59, 59, 999999999, time.UTC); !leap.Timestamp.Equal(want) {
+		t.Errorf("Expected leap second at %v, got %v", want, leap.Timestamp)
+	}
+
+	invalid := [][]TimestampRule{
+		{{TimeZone: "Mars/Olympus"}},
+		{{TimeZone: "Local"}},
+		{{Sources: []string{"10.0.0.0/33"}}},
+		{{Layouts: []string{" "}}},
+	}
+	for _, rules := range invalid {
+		if _, err := NewTimestampRules(rules); err == nil {
+			t.Errorf("Expected rules %+v to be rejected", rules)
+		}
+	}
+}
+
+func TestRFC5424Parser_TransformRules(t *testing.T) {
+	rules, err := NewTransformRules([]TransformRule{
+		{Sources: []string{"10.42.0.0/16"}, StripANSI: true, TrimPrefixes: []string{"cri"}, CollapseWhitespace: true},
+		{Tenants: []string{"tenant-a"}, TrimPrefixes: []string{"docker", `\[app\] `}},
+		{Sources: []string{"10.43.0.1"}, TrimPrefixes: []string{"kubectl"}},
+	})
+	if err != nil {
+		t.Fatalf("NewTransformRules failed: %v", err)
+	}
+	parser := NewRFC5424Parser(false).(*RFC5424Parser)
+	parser.SetTransformRules(rules)
+
+	tests := []struct {
+		name        string
+		raw         string
+		sourceIP    string
+		tenant      string
+		wantApp     string
+		wantMessage string
+	}{
+		{"container runtime prefix", "2024-05-01T12:00:00.123456789Z stdout F <14>1 2024-05-01T12:00:00Z node1 api - - - started", "10.42.1.5", "", "api", "started"},
+		{"ANSI colors and whitespace", "2024-05-01T12:00:00.1+02:00 stderr P \x1b[31mERROR\x1b[0m  failed\tto   connect", "10.42.1.5", "", "", "ERROR failed to connect"},
+		{"docker and custom prefix of a tenant", "2024-05-01T12:00:00.5Z [app] <14>1 2024-05-01T12:00:00Z node1 worker - - - done", "203.0.113.9", "tenant-a", "worker", "done"},
+		{"kubectl prefix", "[pod/api-7d9f/app] <14>1 2024-05-01T12:00:00Z node1 api - - - ready", "10.43.0.1", "", "api", "ready"},
+		{"unmatched sender", "\x1b[1mbold\x1b[0m", "10.44.0.1", "", "", "\x1b[1mbold\x1b[0m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parser.ParseFrom(tt.raw, tt.sourceIP, tt.tenant)
+			if err != nil {
+				t.Fatalf("ParseFrom failed: %v", err)
+			}
+			if entry.AppName != tt.wantApp || entry.Message != tt.wantMessage {
+				t.Errorf("Expected app %q and message %q, got %q and %q", tt.wantApp, tt.wantMessage, entry.AppName, entry.Message)
+			}
+		})
+	}
+
+	// Prefixes are only trimmed at the start of a message, and line breaks survive collapsing
+	if got := rules.Apply("panic:  boom\n\tat  main.go:12 stdout F x", "10.42.0.9", ""); got != "panic: boom\n at main.go:12 stdout F x" {
+		t.Errorf("Unexpected rewrite %q", got)
+	}
+	// Without a sender only rules without sources or tenants apply
+	if got := rules.Apply("2024-05-01T12:00:00Z stdout F hello", "", ""); got != "2024-05-01T12:00:00Z stdout F hello" {
+		t.Errorf("Expected no rule to apply without a sender, got %q", got)
+	}
+	if _, err := parser.ParseFrom("2024-05-01T12:00:00Z stdout F ", "10.42.1.5", ""); err == nil {
+		t.Error("Expected a message left empty to be rejected")
+	}
+
+	invalid := [][]TransformRule{
+		{{Sources: []string{"10.42.0.1"}}},
+		{{Sources: []string{"not-an-address"}, StripANSI: true}},
+		{{TrimPrefixes: []string{"("}}},
+		{{TrimPrefixes: []string{""}}},
+	}
+	for _, rules := range invalid {
+		if _, err := NewTransformRules(rules); err == nil {
+			t.Errorf("Expected rules %+v to be rejected", rules)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// SeverityRule assigns a severity to messages containing a keyword or matching a pattern
+type SeverityRule struct {
+	// Keyword matches a whole word, ignoring case, e.g. "panic" matches "PANIC:" but not "panicked"
+	Keyword string `json:"keyword,omitempty"`
+	// Pattern is a regular expression, for content that is not a single word
+	Pattern string `json:"pattern,omitempty"`
+	// Severity is the RFC5424 severity of matching messages, 0 (emergency) to 7 (debug)
+	Severity int `json:"severity"`
+}
+
+// DefaultSeverityRules infer the level of messages that carry none from common level names,
+// crash keywords and HTTP 5xx responses
+var DefaultSeverityRules = []SeverityRule{
+	{Keyword: "emerg", Severity: 0},
+	{Keyword: "emergency", Severity: 0},
+	{Keyword: "panic", Severity: 2},
+	{Keyword: "fatal", Severity: 2},
+	{Keyword: "crit", Severity: 2},
+	{Keyword: "critical", Severity: 2},
+	{Keyword: "error", Severity: 3},
+	{Keyword: "err", Severity: 3},
+	{Keyword: "exception", Severity: 3},
+	{Keyword: "traceback", Severity: 3},
+	{Keyword: "segfault", Severity: 3},
+	// Access log status codes, e.g. "GET / HTTP/1.1" 503 or status=502
+	{Pattern: `HTTP/[\d.]+" 5\d\d\b|\bstatus[=:]\s*"?5\d\d\b`, Severity: 3},
+	{Keyword: "warn", Severity: 4},
+	{Keyword: "warning", Severity: 4},
+	{Keyword: "deprecated", Severity: 4},
+	{Keyword: "notice", Severity: 5},
+	{Keyword: "debug", Severity: 7},
+	{Keyword: "trace", Severity: 7},
+}
+
+// SeverityTable infers the severity of a message from its content. When several rules match, the
+// most severe one wins.
+type SeverityTable struct {
+	// bySeverity holds one combined expression per severity, most severe first
+	bySeverity []severityMatcher
+}
+
+type severityMatcher struct {
+	severity int
+	pattern  *regexp.Regexp
+}
+
+// NewSeverityTable compiles severity rules
+func NewSeverityTable(rules []SeverityRule) (*SeverityTable, error) {
+	var alternatives [8][]string
+	for _, rule := range rules {
+		if rule.Severity < 0 || rule.Severity > 7 {
+			return nil, fmt.Errorf("invalid severity %d, must be between 0 and 7", rule.Severity)
+		}
+		switch {
+		case rule.Keyword != "" && rule.Pattern != "":
+			return nil, fmt.Errorf("severity rules take either a keyword or a pattern, not both")
+		case rule.Keyword != "":
+			alternatives[rule.Severity] = append(alternatives[rule.Severity], `(?i:\b`+regexp.QuoteMeta(rule.Keyword)+`\b)`)
+		case rule.Pattern != "":
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid severity pattern: %w", err)
+			}
+			alternatives[rule.Severity] = append(alternatives[rule.Severity], `(?:`+rule.Pattern+`)`)
+		default:
+			return nil, fmt.Errorf("severity rules need a keyword or a pattern")
+		}
+	}
+
+	table := &SeverityTable{}
+	for severity, patterns := range alternatives {
+		if len(patterns) == 0 {
+			continue
+		}
+		combined := regexp.MustCompile(strings.Join(patterns, "|"))
+		table.bySeverity = append(table.bySeverity, severityMatcher{severity: severity, pattern: combined})
+	}
+	return table, nil
+}
+
+// defaultSeverityTable is compiled once and shared, as tables are not modified after compiling
+var defaultSeverityTable = func() *SeverityTable {
+	table, err := NewSeverityTable(DefaultSeverityRules)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}()
+
+// DefaultSeverityTable returns the table of DefaultSeverityRules
+func DefaultSeverityTable() *SeverityTable {
+	return defaultSeverityTable
+}
+
+// LoadSeverityTable reads a JSON array of severity rules, which replace the default rules
+func LoadSeverityTable(path string) (*SeverityTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read severity rules: %w", err)
+	}
+	var rules []SeverityRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse severity rules %s: %w", path, err)
+	}
+	return NewSeverityTable(rules)
+}
+
+// Detect returns the severity of the most severe rule matching a message, or fallback when no
+// rule matches
+func (t *SeverityTable) Detect(message string, fallback int) int {
+	if t == nil {
+		return fallback
+	}
+	for _, matcher := range t.bySeverity {
+		if matcher.pattern.MatchString(message) {
+			return matcher.severity
+		}
+	}
+	return fallback
+}
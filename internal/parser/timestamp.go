@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// naiveLayouts are tried after a rule's own layouts, so a rule giving only a time zone accepts ISO
+// 8601 timestamps without an offset, with or without fractional seconds
+var naiveLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseLeapSecond reads an RFC3339 timestamp in a leap second, such as "2016-12-31T23:59:60.5Z",
+// which time.Parse rejects. It is placed at the last nanosecond before the following second, so it
+// sorts after the second before it and before the second after it.
+func parseLeapSecond(s string) (time.Time, bool) {
+	if len(s) < 20 || s[16] != ':' || s[17:19] != "60" {
+		return time.Time{}, false
+	}
+	end := 19
+	if s[end] == '.' {
+		end++
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+	}
+	timestamp, err := time.Parse(time.RFC3339, s[:17]+"59"+s[end:])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp.Add(time.Second - time.Nanosecond), true
+}
+
+// TimestampRule configures how the timestamps of some senders are read when they are not RFC3339
+type TimestampRule struct {
+	// Sources are the sender addresses or CIDR ranges the rule applies to (empty matches any)
+	Sources []string `json:"sources,omitempty"`
+	// Tenants are the TLS tenants the rule applies to (empty matches any)
+	Tenants []string `json:"tenants,omitempty"`
+	// Layouts are Go time layouts tried in order, e.g. "02/01/2006:15:04:05". A layout containing
+	// spaces reads as many space-separated fields of the header.
+	Layouts []string `json:"layouts,omitempty"`
+	// TimeZone is the IANA time zone of timestamps without an offset, e.g. "Europe/Berlin" (UTC if empty)
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// TimestampFormat is the compiled form of a rule: the layouts to try and the zone of naive timestamps
+type TimestampFormat struct {
+	layouts  []string
+	location *time.Location
+}
+
+// Parse reads a timestamp from the start of s with the first matching layout and returns it with
+// the text after it. Timestamps without a year, such as "Jan 2 15:04:05", are placed in the last
+// year, counting from now, in which they are not more than a day ahead.
+func (f *TimestampFormat) Parse(s string, now time.Time) (time.Time, string, bool) {
+	for _, layout := range f.layouts {
+		fields := strings.Count(layout, " ") + 1
+		parts := strings.SplitN(s, " ", fields+1)
+		if len(parts) < fields {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(layout, strings.Join(parts[:fields], " "), f.location)
+		if err != nil {
+			continue
+		}
+		if timestamp.Year() == 0 {
+			timestamp = timestamp.AddDate(now.In(f.location).Year(), 0, 0)
+			if timestamp.After(now.Add(24 * time.Hour)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+		}
+		var rest string
+		if len(parts) > fields {
+			rest = strings.TrimSpace(parts[fields])
+		}
+		return timestamp, rest, true
+	}
+	return time.Time{}, s, false
+}
+
+// sourceMatcher selects the messages a rule applies to by the sender's address and tenant
+type sourceMatcher struct {
+	addresses []net.IP
+	networks  []*net.IPNet
+	tenants   []string
+}
+
+// newSourceMatcher compiles the sources, addresses or CIDR ranges, and tenants of a rule
+func newSourceMatcher(sources, tenants []string) (sourceMatcher, error) {
+	matcher := sourceMatcher{tenants: tenants}
+	for _, source := range sources {
+		if _, network, err := net.ParseCIDR(source); err == nil {
+			matcher.networks = append(matcher.networks, network)
+		} else if ip := net.ParseIP(source); ip != nil {
+			matcher.addresses = append(matcher.addresses, ip)
+		} else {
+			return matcher, fmt.Errorf("invalid source %q, expected an IP address or CIDR range", source)
+		}
+	}
+	return matcher, nil
+}
+
+type timestampMatcher struct {
+	sourceMatcher
+	format *TimestampFormat
+}
+
+// matches reports whether a message from sourceIP on tenant's connection falls under the rule
+func (m *sourceMatcher) matches(sourceIP, tenant string) bool {
+	if len(m.tenants) > 0 && !slices.Contains(m.tenants, tenant) {
+		return false
+	}
+	if len(m.addresses) == 0 && len(m.networks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(sourceIP)
+	if ip == nil {
+		return false
+	}
+	for _, address := range m.addresses {
+		if address.Equal(ip) {
+			return true
+		}
+	}
+	for _, network := range m.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimestampRules selects the timestamp format of a message by where it came from. The first
+// matching rule applies.
+type TimestampRules struct {
+	matchers []timestampMatcher
+}
+
+// NewTimestampRules compiles timestamp rules
+func NewTimestampRules(rules []TimestampRule) (*TimestampRules, error) {
+	compiled := &TimestampRules{}
+	for i, rule := range rules {
+		location := time.UTC
+		if rule.TimeZone != "" {
+			loc, err := time.LoadLocation(rule.TimeZone)
+			if err != nil || rule.TimeZone == "Local" {
+				return nil, fmt.Errorf("rule %d: invalid time_zone %q, expected an IANA time zone name", i+1, rule.TimeZone)
+			}
+			location = loc
+		}
+		for _, layout := range rule.Layouts {
+			if strings.TrimSpace(layout) == "" {
+				return nil, fmt.Errorf("rule %d: empty layout", i+1)
+			}
+		}
+
+		sources, err := newSourceMatcher(rule.Sources, rule.Tenants)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		compiled.matchers = append(compiled.matchers, timestampMatcher{
+			sourceMatcher: sources,
+			format: &TimestampFormat{
+				layouts:  append(slices.Clone(rule.Layouts), naiveLayouts...),
+				location: location,
+			},
+		})
+	}
+	return compiled, nil
+}
+
+// LoadTimestampRules reads a JSON array of timestamp rules
+func LoadTimestampRules(path string) (*TimestampRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp rules: %w", err)
+	}
+	var rules []TimestampRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp rules %s: %w", path, err)
+	}
+	return NewTimestampRules(rules)
+}
+
+// Match returns the format of the first rule matching a message from sourceIP on tenant's
+// connection, either of which may be empty, or nil if none does
+func (r *TimestampRules) Match(sourceIP, tenant string) *TimestampFormat {
+	if r == nil {
+		return nil
+	}
+	for i := range r.matchers {
+		if r.matchers[i].matches(sourceIP, tenant) {
+			return r.matchers[i].format
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// KnownPrefixes are the wrappers that log collectors put in front of lines, which a transform rule
+// can trim by name instead of by a regular expression
+var KnownPrefixes = map[string]string{
+	// cri is the prefix of the container log files of containerd and CRI-O: the time, the stream
+	// and whether the line is full or partial
+	"cri": `^\d{4}-\d{2}-\d{2}T[0-9:.]+(Z|[+-]\d{2}:\d{2}) (stdout|stderr) [FP] `,
+	// docker is the time `docker logs --timestamps` puts in front of every line
+	"docker": `^\d{4}-\d{2}-\d{2}T[0-9:.]+Z `,
+	// kubectl is the pod and container `kubectl logs --prefix` puts in front of every line
+	"kubectl": `^\[pod/[^/\]]+/[^\]]+\] `,
+}
+
+// ansiSequence matches ANSI escape sequences: CSI sequences such as colors and cursor movements,
+// OSC sequences such as hyperlinks and window titles, and two-character escapes
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(\x07|\x1b\\\\)|\x1b[@-Z\\\\-_]")
+
+// horizontalSpace matches runs of whitespace within a line
+var horizontalSpace = regexp.MustCompile(`[^\S\r\n]{2,}|[^\S\r\n ]`)
+
+// TransformRule rewrites the messages of some senders before they are parsed, so wrappers and
+// decorations added on the way do not break parsing or searching
+type TransformRule struct {
+	// Sources are the sender addresses or CIDR ranges the rule applies to (empty matches any)
+	Sources []string `json:"sources,omitempty"`
+	// Tenants are the TLS tenants the rule applies to (empty matches any)
+	Tenants []string `json:"tenants,omitempty"`
+	// StripANSI removes ANSI escape sequences such as color codes
+	StripANSI bool `json:"strip_ansi,omitempty"`
+	// TrimPrefixes are removed in order from the start of messages: regular expressions, anchored
+	// at the start, or the names of KnownPrefixes
+	TrimPrefixes []string `json:"trim_prefixes,omitempty"`
+	// CollapseWhitespace replaces runs of spaces and tabs with a single space, keeping line breaks
+	CollapseWhitespace bool `json:"collapse_whitespace,omitempty"`
+}
+
+type transformMatcher struct {
+	sourceMatcher
+	stripANSI          bool
+	prefixes           []*regexp.Regexp
+	collapseWhitespace bool
+}
+
+// apply rewrites a message with the rule
+func (m *transformMatcher) apply(message string) string {
+	if m.stripANSI && strings.IndexByte(message, '\x1b') >= 0 {
+		message = ansiSequence.ReplaceAllString(message, "")
+	}
+	for _, prefix := range m.prefixes {
+		if loc := prefix.FindStringIndex(message); loc != nil {
+			message = message[loc[1]:]
+		}
+	}
+	if m.collapseWhitespace {
+		message = strings.TrimSpace(horizontalSpace.ReplaceAllString(message, " "))
+	}
+	return message
+}
+
+// TransformRules selects how messages are rewritten before parsing by where they came from. The
+// first matching rule applies.
+type TransformRules struct {
+	matchers []transformMatcher
+}
+
+// NewTransformRules compiles transform rules
+func NewTransformRules(rules []TransformRule) (*TransformRules, error) {
+	compiled := &TransformRules{}
+	for i, rule := range rules {
+		if !rule.StripANSI && len(rule.TrimPrefixes) == 0 && !rule.CollapseWhitespace {
+			return nil, fmt.Errorf("rule %d: no transformation, expected strip_ansi, trim_prefixes or collapse_whitespace", i+1)
+		}
+		sources, err := newSourceMatcher(rule.Sources, rule.Tenants)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		matcher := transformMatcher{
+			sourceMatcher:      sources,
+			stripANSI:          rule.StripANSI,
+			collapseWhitespace: rule.CollapseWhitespace,
+		}
+		for _, prefix := range rule.TrimPrefixes {
+			pattern, known := KnownPrefixes[prefix]
+			if !known {
+				pattern = prefix
+				if !strings.HasPrefix(pattern, "^") {
+					pattern = "^(?:" + pattern + ")"
+				}
+			}
+			compiledPrefix, err := regexp.Compile(pattern)
+			if err != nil || prefix == "" {
+				return nil, fmt.Errorf("rule %d: invalid trim prefix %q, expected a regular expression or one of cri, docker, kubectl", i+1, prefix)
+			}
+			matcher.prefixes = append(matcher.prefixes, compiledPrefix)
+		}
+		compiled.matchers = append(compiled.matchers, matcher)
+	}
+	return compiled, nil
+}
+
+// LoadTransformRules reads a JSON array of transform rules
+func LoadTransformRules(path string) (*TransformRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform rules: %w", err)
+	}
+	var rules []TransformRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse transform rules %s: %w", path, err)
+	}
+	return NewTransformRules(rules)
+}
+
+// Apply rewrites a message from sourceIP on tenant's connection, either of which may be empty,
+// with the first rule matching it; messages no rule matches are returned as they are
+func (r *TransformRules) Apply(message, sourceIP, tenant string) string {
+	if r == nil {
+		return message
+	}
+	for i := range r.matchers {
+		if r.matchers[i].matches(sourceIP, tenant) {
+			return r.matchers[i].apply(message)
+		}
+	}
+	return message
+}
@@ -0,0 +1,274 @@
+// Package querylang compiles the compact search expressions accepted by the
+// q query parameter into a SearchQuery.
+//
+// An expression is a whitespace separated list of terms that must all match:
+//
+//	app:nginx severity<=err "connection refused" user_id=42 -host:web03
+//
+// Bare words and quoted phrases search the message text. field:value and
+// field=value match a field exactly, field!=value or a leading "-" negates a
+// term. severity accepts <, <=, >, >= with numbers or names (err, warning, ...).
+// Fields other than the built-in ones match structured data parameters, either
+// in any element (user_id=42) or in a specific one (request@32473.user_id=42).
+package querylang
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"opentrail/internal/types"
+)
+
+// fieldAliases maps the accepted field names to their canonical names
+var fieldAliases = map[string]string{
+	"host":        "hostname",
+	"hostname":    "hostname",
+	"app":         "app_name",
+	"app_name":    "app_name",
+	"proc":        "proc_id",
+	"proc_id":     "proc_id",
+	"pid":         "proc_id",
+	"msgid":       "msg_id",
+	"msg_id":      "msg_id",
+	"tracking_id": types.TrackingIDField,
+	"ip":          types.SourceIPParam,
+	"source_ip":   types.SourceIPParam,
+	"severity":    "severity",
+	"sev":         "severity",
+	"level":       "severity",
+	"facility":    "facility",
+}
+
+// facilityNames maps facility keywords to syslog facility codes
+var facilityNames = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "security": 13, "console": 14, "clock": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// operators are matched longest first
+var operators = []string{"<=", ">=", "!=", ":", "=", "<", ">"}
+
+// Compile parses expr and adds its terms to query. Text terms are combined
+// with any text search already present in the query.
+func Compile(expr string, query *types.SearchQuery) error {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return err
+	}
+
+	var include, exclude []string
+	for _, token := range tokens {
+		negate := false
+		if len(token) > 1 && token[0] == '-' {
+			negate = true
+			token = token[1:]
+		}
+
+		field, op, value, ok := splitComparison(token)
+		if !ok {
+			text, err := unquote(token)
+			if err != nil {
+				return err
+			}
+			if negate {
+				exclude = append(exclude, ftsPhrase(text))
+			} else {
+				include = append(include, ftsPhrase(text))
+			}
+			continue
+		}
+
+		if err := applyComparison(query, field, op, value, negate); err != nil {
+			return err
+		}
+	}
+
+	if len(exclude) > 0 && len(include) == 0 && query.Text == "" {
+		return fmt.Errorf("negated text terms require at least one text term to match")
+	}
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	text := strings.Join(include, " ")
+	for _, term := range exclude {
+		text += " NOT " + term
+	}
+	text = strings.TrimSpace(text)
+	if query.Text != "" {
+		if strings.HasPrefix(text, "NOT ") {
+			text = "(" + query.Text + ") " + text
+		} else {
+			text = "(" + query.Text + ") AND (" + text + ")"
+		}
+	}
+	query.Text = text
+	return nil
+}
+
+// tokenize splits expr on whitespace outside double quotes
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// splitComparison splits a field:value style term; ok is false for plain text terms
+func splitComparison(token string) (field, op, value string, ok bool) {
+	idx := strings.IndexAny(token, ":=<>!")
+	if idx <= 0 || !validFieldName(token[:idx]) {
+		return "", "", "", false
+	}
+
+	rest := token[idx:]
+	for _, candidate := range operators {
+		if strings.HasPrefix(rest, candidate) {
+			return token[:idx], candidate, rest[len(candidate):], true
+		}
+	}
+	return "", "", "", false
+}
+
+// validFieldName reports whether name can be a built-in field or a structured data parameter
+func validFieldName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_.@-", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyComparison adds a single field comparison to query
+func applyComparison(query *types.SearchQuery, field, op, rawValue string, negate bool) error {
+	value, err := unquote(rawValue)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("missing value for %s", field)
+	}
+
+	canonical, builtin := fieldAliases[strings.ToLower(field)]
+	if !builtin {
+		canonical = field
+	}
+
+	switch canonical {
+	case "severity":
+		return applySeverity(query, op, value, negate)
+	case "facility":
+		if negate || (op != ":" && op != "=") {
+			return fmt.Errorf("facility only supports exact matches")
+		}
+		facility, err := lookupCode(value, facilityNames, 23)
+		if err != nil {
+			return fmt.Errorf("invalid facility %q", value)
+		}
+		query.Facility = &facility
+		return nil
+	}
+
+	switch op {
+	case ":", "=":
+	case "!=":
+		negate = !negate
+	default:
+		return fmt.Errorf("%s does not support the %s operator", field, op)
+	}
+
+	if canonical == types.SourceIPParam {
+		addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", field, value)
+		}
+		value = addr.Unmap().WithZone("").String()
+	}
+
+	query.Filters = append(query.Filters, types.FieldFilter{Field: canonical, Value: value, Negate: negate})
+	return nil
+}
+
+// applySeverity adds a severity comparison. Lower severities are more severe, so
+// severity<=err matches err, crit, alert and emerg.
+func applySeverity(query *types.SearchQuery, op, value string, negate bool) error {
+	severity, err := lookupCode(value, types.LevelSeverities, 7)
+	if err != nil {
+		return fmt.Errorf("invalid severity %q", value)
+	}
+	if negate || op == "!=" {
+		return fmt.Errorf("negated severity is not supported, use a comparison such as severity<=warning")
+	}
+
+	switch op {
+	case ":", "=":
+		query.Severity = &severity
+	case "<=":
+		query.MinSeverity = &severity
+	case "<":
+		severity--
+		query.MinSeverity = &severity
+	case ">=":
+		query.MaxSeverity = &severity
+	case ">":
+		severity++
+		query.MaxSeverity = &severity
+	}
+	return nil
+}
+
+// lookupCode resolves a numeric code or a keyword
+func lookupCode(value string, names map[string]int, max int) (int, error) {
+	if code, ok := names[strings.ToLower(value)]; ok {
+		return code, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 0 || code > max {
+		return 0, fmt.Errorf("unknown value %q", value)
+	}
+	return code, nil
+}
+
+// unquote removes surrounding double quotes from a term
+func unquote(term string) (string, error) {
+	if !strings.Contains(term, `"`) {
+		return term, nil
+	}
+	if len(term) < 2 || term[0] != '"' || term[len(term)-1] != '"' || strings.Contains(term[1:len(term)-1], `"`) {
+		return "", fmt.Errorf("misplaced quote in %s", term)
+	}
+	return term[1 : len(term)-1], nil
+}
+
+// ftsPhrase quotes text as an FTS5 phrase so punctuation is not read as query syntax
+func ftsPhrase(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
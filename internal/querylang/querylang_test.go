@@ -0,0 +1,124 @@
+package querylang
+
+import (
+	"reflect"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want types.SearchQuery
+	}{
+		{
+			name: "example from the docs",
+			expr: `app:nginx severity<=err "connection refused" user_id=42 -host:web03`,
+			want: types.SearchQuery{
+				Text:        `"connection refused"`,
+				MinSeverity: intPtr(3),
+				Filters: []types.FieldFilter{
+					{Field: "app_name", Value: "nginx"},
+					{Field: "user_id", Value: "42"},
+					{Field: "hostname", Value: "web03", Negate: true},
+				},
+			},
+		},
+		{
+			name: "text terms and negation",
+			expr: `timeout -retry "gave up"`,
+			want: types.SearchQuery{Text: `"timeout" "gave up" NOT "retry"`},
+		},
+		{
+			name: "severity comparisons",
+			expr: `severity>warning sev<7`,
+			want: types.SearchQuery{MaxSeverity: intPtr(5), MinSeverity: intPtr(6)},
+		},
+		{
+			name: "exact severity and facility",
+			expr: `level=info facility:local3`,
+			want: types.SearchQuery{Severity: intPtr(6), Facility: intPtr(19)},
+		},
+		{
+			name: "not equal and quoted value",
+			expr: `msgid!=AUDIT request@32473.path:"/api/v1 users"`,
+			want: types.SearchQuery{Filters: []types.FieldFilter{
+				{Field: "msg_id", Value: "AUDIT", Negate: true},
+				{Field: "request@32473.path", Value: "/api/v1 users"},
+			}},
+		},
+		{
+			name: "application log levels and tracking IDs",
+			expr: `level=FATAL tracking_id:req-42`,
+			want: types.SearchQuery{Severity: intPtr(2), Filters: []types.FieldFilter{{Field: "msg_id", Value: "req-42"}}},
+		},
+		{
+			name: "source ip is normalized",
+			expr: `ip:::ffff:10.0.0.1`,
+			want: types.SearchQuery{Filters: []types.FieldFilter{{Field: types.SourceIPParam, Value: "10.0.0.1"}}},
+		},
+		{
+			name: "quoted field-like text",
+			expr: `"user:42"`,
+			want: types.SearchQuery{Text: `"user:42"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got types.SearchQuery
+			if err := Compile(tt.expr, &got); err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compile(%q)\n got: %+v\nwant: %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_CombinesWithExistingText(t *testing.T) {
+	query := types.SearchQuery{Text: "error OR warning"}
+	if err := Compile("disk", &query); err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if want := `(error OR warning) AND ("disk")`; query.Text != want {
+		t.Errorf("Expected %q, got %q", want, query.Text)
+	}
+
+	query = types.SearchQuery{Text: "error"}
+	if err := Compile("-disk", &query); err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if want := `(error) NOT "disk"`; query.Text != want {
+		t.Errorf("Expected %q, got %q", want, query.Text)
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	invalid := []string{
+		`"unterminated`,
+		`-timeout`,
+		`severity<=loud`,
+		`-severity:debug`,
+		`severity!=info`,
+		`facility>local0`,
+		`facility:local9`,
+		`host<web01`,
+		`host:`,
+		`ip:not-an-ip`,
+		`app:ng"inx`,
+	}
+	for _, expr := range invalid {
+		var query types.SearchQuery
+		if err := Compile(expr, &query); err == nil {
+			t.Errorf("Compile(%q): expected error, got %+v", expr, query)
+		}
+	}
+}
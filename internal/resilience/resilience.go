@@ -0,0 +1,180 @@
+// Package resilience retries storage operations that fail transiently, such as SQLite reporting
+// SQLITE_BUSY, and trips a circuit breaker when they keep failing: while it is open, operations
+// fail at once with interfaces.ErrStorageBusy instead of piling up retries and error logs, and
+// after a while a single operation probes whether storage recovered.
+package resilience
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+)
+
+// Breaker states, the values of the state label
+const (
+	StateClosed   = "closed"
+	StateHalfOpen = "half_open"
+	StateOpen     = "open"
+)
+
+// stateValues are the values of the state gauge
+var stateValues = map[string]float64{StateClosed: 0, StateHalfOpen: 1, StateOpen: 2}
+
+// Config is a retry and circuit breaker policy
+type Config struct {
+	// Attempts is how often an operation failing transiently is run, 0 or 1 disables retries
+	Attempts int
+	// Backoff is the delay before the first retry, doubled before each further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Threshold is how many operations in a row must fail transiently, after their retries, for
+	// the breaker to open; 0 disables the breaker
+	Threshold int
+	// OpenFor is how long the breaker stays open before an operation is let through as a probe
+	OpenFor time.Duration
+}
+
+// DefaultConfig retries an operation twice within 30ms and opens the breaker after 20 operations
+// failed in a row, for 5 seconds
+var DefaultConfig = Config{
+	Attempts:   3,
+	Backoff:    10 * time.Millisecond,
+	MaxBackoff: 200 * time.Millisecond,
+	Threshold:  20,
+	OpenFor:    5 * time.Second,
+}
+
+// IsTransient reports whether an error is SQLite's transient SQLITE_BUSY or SQLITE_LOCKED, which
+// persist past busy_timeout when another connection holds the write lock for long
+func IsTransient(err error) bool {
+	errStr := strings.ToLower(err.Error())
+	return strings.Contains(errStr, "database is locked") || strings.Contains(errStr, "sqlite_busy") ||
+		strings.Contains(errStr, "database table is locked")
+}
+
+// Policy applies a Config to the operations of one kind, named by operation in logs and metrics
+type Policy struct {
+	operation string
+	config    Config
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// probing is set while the operation let through in the half-open state runs
+	probing bool
+}
+
+// NewPolicy creates a policy for operations of a kind, such as "read" or "write"
+func NewPolicy(operation string, config Config) *Policy {
+	config.Attempts = max(config.Attempts, 1)
+	config.MaxBackoff = max(config.MaxBackoff, config.Backoff)
+	p := &Policy{operation: operation, config: config, state: StateClosed}
+	metrics.GetBreakerMetrics().State.WithLabelValues(operation).Set(stateValues[StateClosed])
+	return p
+}
+
+// State returns the state of the breaker
+func (p *Policy) State() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Do runs op, retrying it with backoff while it fails transiently, and records the outcome. While
+// the breaker is open it fails with interfaces.ErrStorageBusy without running op.
+func (p *Policy) Do(op func() error) error {
+	err := p.Submit(op)
+	if err == nil {
+		p.Record(nil)
+	}
+	return err
+}
+
+// Submit runs op like Do but leaves its outcome to a later Record when it succeeds, for operations
+// such as batched writes whose result is only known once committed
+func (p *Policy) Submit(op func() error) error {
+	if err := p.admit(time.Now()); err != nil {
+		return err
+	}
+
+	var err error
+	backoff := p.config.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || !IsTransient(err) || attempt >= p.config.Attempts {
+			break
+		}
+		metrics.GetBreakerMetrics().Retries.WithLabelValues(p.operation).Inc()
+		time.Sleep(backoff)
+		backoff = min(backoff*2, p.config.MaxBackoff)
+	}
+	if err != nil {
+		p.Record(err)
+	}
+	return err
+}
+
+// Record feeds the outcome of an operation to the breaker. Transient failures in a row open it,
+// and any other outcome shows that storage answers again and closes it.
+func (p *Policy) Record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probing = false
+	if err == nil || !IsTransient(err) {
+		p.failures = 0
+		if p.state != StateClosed {
+			log.Printf("Storage %ss succeed again, closing the circuit breaker", p.operation)
+			p.transition(StateClosed)
+		}
+		return
+	}
+
+	p.failures++
+	switch {
+	case p.state == StateHalfOpen:
+		p.openedAt = time.Now()
+		p.transition(StateOpen)
+	case p.state == StateClosed && p.config.Threshold > 0 && p.failures >= p.config.Threshold:
+		log.Printf("Opening the storage %s circuit breaker for %v after %d failures in a row: %v",
+			p.operation, p.config.OpenFor, p.failures, err)
+		p.openedAt = time.Now()
+		p.transition(StateOpen)
+	}
+}
+
+// admit lets an operation through unless the breaker is open, or half-open with a probe running
+func (p *Policy) admit(now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case StateOpen:
+		if now.Sub(p.openedAt) < p.config.OpenFor {
+			break
+		}
+		p.transition(StateHalfOpen)
+		p.probing = true
+		return nil
+	case StateHalfOpen:
+		if p.probing {
+			break
+		}
+		p.probing = true
+		return nil
+	default:
+		return nil
+	}
+	metrics.GetBreakerMetrics().Rejected.WithLabelValues(p.operation).Inc()
+	return interfaces.ErrStorageBusy
+}
+
+// transition enters a state; p.mu must be held
+func (p *Policy) transition(state string) {
+	p.state = state
+	breakerMetrics := metrics.GetBreakerMetrics()
+	breakerMetrics.State.WithLabelValues(p.operation).Set(stateValues[state])
+	breakerMetrics.Transitions.WithLabelValues(p.operation, state).Inc()
+}
@@ -0,0 +1,127 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+)
+
+var errBusy = errors.New("database is locked (5) (SQLITE_BUSY)")
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errBusy, true},
+		{errors.New("database table is locked"), true},
+		{errors.New("SQLITE_BUSY: cannot commit"), true},
+		{errors.New("disk I/O error"), false},
+		{errors.New("UNIQUE constraint failed"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Retries(t *testing.T) {
+	policy := NewPolicy("test", Config{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Threshold: 10, OpenFor: time.Second})
+
+	calls := 0
+	err := policy.Do(func() error {
+		calls++
+		if calls < 3 {
+			return errBusy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success after 3 attempts, got %v after %d", err, calls)
+	}
+
+	// Other errors are not retried
+	calls = 0
+	err = policy.Do(func() error {
+		calls++
+		return errors.New("disk I/O error")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected a permanent error to fail at once, got %v after %d attempts", err, calls)
+	}
+
+	// Attempts are bounded
+	calls = 0
+	if err := policy.Do(func() error { calls++; return errBusy }); err != errBusy || calls != 3 {
+		t.Errorf("Expected the busy error after 3 attempts, got %v after %d", err, calls)
+	}
+}
+
+func TestPolicy_Breaker(t *testing.T) {
+	policy := NewPolicy("test", Config{Attempts: 1, Threshold: 3, OpenFor: 50 * time.Millisecond})
+	busy := func() error { return errBusy }
+
+	for i := 0; i < 3; i++ {
+		if err := policy.Do(busy); err != errBusy {
+			t.Fatalf("Expected the busy error, got %v", err)
+		}
+	}
+	if state := policy.State(); state != StateOpen {
+		t.Fatalf("Expected the breaker to open after 3 failures, got %s", state)
+	}
+
+	// While open, operations fail fast without running
+	ran := false
+	if err := policy.Do(func() error { ran = true; return nil }); !errors.Is(err, interfaces.ErrStorageBusy) || ran {
+		t.Errorf("Expected ErrStorageBusy without running the operation, got %v (ran %v)", err, ran)
+	}
+
+	// A failing probe reopens the breaker
+	time.Sleep(60 * time.Millisecond)
+	if err := policy.Do(busy); err != errBusy {
+		t.Errorf("Expected the probe to run and fail, got %v", err)
+	}
+	if state := policy.State(); state != StateOpen {
+		t.Errorf("Expected a failed probe to reopen the breaker, got %s", state)
+	}
+
+	// A succeeding probe closes it
+	time.Sleep(60 * time.Millisecond)
+	if err := policy.Do(func() error { return nil }); err != nil {
+		t.Errorf("Expected the probe to succeed, got %v", err)
+	}
+	if state := policy.State(); state != StateClosed {
+		t.Errorf("Expected a succeeding probe to close the breaker, got %s", state)
+	}
+}
+
+func TestPolicy_HalfOpenAdmitsOneProbe(t *testing.T) {
+	policy := NewPolicy("test", Config{Attempts: 1, Threshold: 1, OpenFor: time.Millisecond})
+	policy.Do(func() error { return errBusy })
+	time.Sleep(5 * time.Millisecond)
+
+	// Submit leaves the probe running until its outcome is recorded
+	if err := policy.Submit(func() error { return nil }); err != nil {
+		t.Fatalf("Expected the probe to be admitted, got %v", err)
+	}
+	if err := policy.Submit(func() error { return nil }); !errors.Is(err, interfaces.ErrStorageBusy) {
+		t.Errorf("Expected a second operation to be rejected while probing, got %v", err)
+	}
+	policy.Record(nil)
+	if state := policy.State(); state != StateClosed {
+		t.Errorf("Expected the recorded success to close the breaker, got %s", state)
+	}
+}
+
+func TestPolicy_DisabledBreaker(t *testing.T) {
+	policy := NewPolicy("test", Config{Attempts: 1, Threshold: 0})
+	for i := 0; i < 100; i++ {
+		policy.Do(func() error { return errBusy })
+	}
+	if state := policy.State(); state != StateClosed {
+		t.Errorf("Expected a zero threshold to keep the breaker closed, got %s", state)
+	}
+}
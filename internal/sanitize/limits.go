@@ -0,0 +1,232 @@
+package sanitize
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// TruncatedParam is the metadata parameter holding how many structured data parameters were
+	// dropped because the entry exceeded the size or parameter limit
+	TruncatedParam = "sd_truncated"
+	// OverflowParam is the metadata parameter collecting, as "sdid.param=value" pairs, the
+	// parameters whose keys exceeded the key limit of their application
+	OverflowParam = "sd_overflow"
+
+	// maxTrackedApps bounds the applications whose keys are tracked; the keys of any further
+	// applications share one set
+	maxTrackedApps = 10000
+)
+
+// DefaultLimits are the structured data limits applied unless configured otherwise
+var DefaultLimits = Limits{
+	MaxBytes:      64 * 1024,
+	MaxParams:     256,
+	MaxKeysPerApp: 1000,
+}
+
+// Limits bound the structured data of incoming entries, protecting the field catalog and indexes
+// from senders putting request IDs or other unbounded values into parameter names. Zero disables
+// a limit.
+type Limits struct {
+	// MaxBytes is the total size of the element IDs, parameter names and values of an entry
+	MaxBytes int
+	// MaxParams is the number of parameters an entry may carry
+	MaxParams int
+	// MaxKeysPerApp is the number of distinct keys ("sdid.param") an application may use
+	MaxKeysPerApp int
+}
+
+// LimitChanges records what enforcing the limits changed in an entry
+type LimitChanges struct {
+	// Truncated is the number of parameters dropped for exceeding MaxBytes or MaxParams
+	Truncated int
+	// Bucketed is the number of parameters moved into OverflowParam for exceeding MaxKeysPerApp
+	Bucketed int
+}
+
+// Any reports whether anything was changed
+func (c LimitChanges) Any() bool {
+	return c.Truncated > 0 || c.Bucketed > 0
+}
+
+// Guard enforces Limits, remembering the keys every application has used. Keys are only
+// remembered in memory, so an application may use new keys again after a restart until it
+// reaches its limit. It is safe for concurrent use.
+type Guard struct {
+	limits Limits
+
+	mu      sync.Mutex
+	appKeys map[string]map[string]struct{}
+}
+
+// NewGuard creates a guard enforcing limits
+func NewGuard(limits Limits) *Guard {
+	return &Guard{
+		limits:  limits,
+		appKeys: make(map[string]map[string]struct{}),
+	}
+}
+
+// sdParam is one structured data parameter of an entry
+type sdParam struct {
+	sdID  string
+	name  string
+	value interface{}
+}
+
+func (p sdParam) key() string {
+	return p.sdID + "." + p.name
+}
+
+func (p sdParam) size() int {
+	size := len(p.sdID) + len(p.name)
+	if s, ok := p.value.(string); ok {
+		size += len(s)
+	}
+	return size
+}
+
+// Apply enforces the limits on an entry in place. Parameters with keys beyond the key limit of the
+// entry's application are moved into OverflowParam, so their content stays searchable without
+// adding keys to the field catalog. Parameters beyond the size or parameter limit are then dropped,
+// their number recorded in TruncatedParam. Parameters are considered in key order, so the same
+// ones are kept for the same entry. Call it before receiver metadata is added, which is exempt.
+func (g *Guard) Apply(entry *types.LogEntry) LimitChanges {
+	var changes LimitChanges
+	if g == nil || len(entry.StructuredData) == 0 {
+		return changes
+	}
+
+	// Only the guard sets its markers
+	entry.ClearMetadata(TruncatedParam)
+	entry.ClearMetadata(OverflowParam)
+
+	params := structuredParams(entry.StructuredData)
+	if len(params) == 0 {
+		return changes
+	}
+
+	var kept, overflow []sdParam
+	if g.limits.MaxKeysPerApp > 0 {
+		kept, overflow = g.admitKeys(entry.AppName, params)
+	} else {
+		kept = params
+	}
+
+	// Every overflowing parameter leaves the entry, bucketed or not
+	removed := overflow
+	budget := g.limits.MaxBytes
+	retained := 0
+	for _, param := range kept {
+		if (g.limits.MaxParams > 0 && retained >= g.limits.MaxParams) ||
+			(g.limits.MaxBytes > 0 && param.size() > budget) {
+			removed = append(removed, param)
+			changes.Truncated++
+			continue
+		}
+		budget -= param.size()
+		retained++
+	}
+
+	var bucket []string
+	for _, param := range overflow {
+		s, _ := param.value.(string)
+		pair := param.key() + "=" + s
+		if g.limits.MaxBytes > 0 {
+			if len(pair)+1 > budget {
+				changes.Truncated++
+				continue
+			}
+			budget -= len(pair) + 1
+		}
+		bucket = append(bucket, pair)
+		changes.Bucketed++
+	}
+
+	if !changes.Any() {
+		return changes
+	}
+
+	for _, param := range removed {
+		switch element := entry.StructuredData[param.sdID].(type) {
+		case map[string]string:
+			delete(element, param.name)
+			if len(element) == 0 {
+				delete(entry.StructuredData, param.sdID)
+			}
+		case map[string]interface{}:
+			delete(element, param.name)
+			if len(element) == 0 {
+				delete(entry.StructuredData, param.sdID)
+			}
+		}
+	}
+	if len(bucket) > 0 {
+		entry.SetMetadata(OverflowParam, strings.Join(bucket, " "))
+	}
+	if changes.Truncated > 0 {
+		entry.SetMetadata(TruncatedParam, strconv.Itoa(changes.Truncated))
+	}
+	return changes
+}
+
+// admitKeys splits parameters into those whose keys the application has used before or may still
+// add, remembering the added keys, and those beyond its key limit
+func (g *Guard) admitKeys(appName string, params []sdParam) (admitted, overflow []sdParam) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	keys, ok := g.appKeys[appName]
+	if !ok {
+		if len(g.appKeys) >= maxTrackedApps {
+			appName = ""
+			keys = g.appKeys[appName]
+		}
+		if keys == nil {
+			keys = make(map[string]struct{})
+			g.appKeys[appName] = keys
+		}
+	}
+
+	for _, param := range params {
+		key := param.key()
+		if _, known := keys[key]; !known {
+			if len(keys) >= g.limits.MaxKeysPerApp {
+				overflow = append(overflow, param)
+				continue
+			}
+			keys[key] = struct{}{}
+		}
+		admitted = append(admitted, param)
+	}
+	return admitted, overflow
+}
+
+// structuredParams flattens the parameter maps of structured data into parameters sorted by key
+func structuredParams(data map[string]interface{}) []sdParam {
+	var params []sdParam
+	for sdID, element := range data {
+		switch values := element.(type) {
+		case map[string]string:
+			for name, value := range values {
+				params = append(params, sdParam{sdID: sdID, name: name, value: value})
+			}
+		case map[string]interface{}:
+			for name, value := range values {
+				params = append(params, sdParam{sdID: sdID, name: name, value: value})
+			}
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].sdID != params[j].sdID {
+			return params[i].sdID < params[j].sdID
+		}
+		return params[i].name < params[j].name
+	})
+	return params
+}
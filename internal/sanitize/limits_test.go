@@ -0,0 +1,93 @@
+package sanitize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func TestGuard_KeysPerApp(t *testing.T) {
+	guard := NewGuard(Limits{MaxKeysPerApp: 2})
+
+	first := &types.LogEntry{AppName: "api", StructuredData: map[string]interface{}{
+		"req": map[string]string{"id": "1", "path": "/"},
+	}}
+	if changes := guard.Apply(first); changes.Any() {
+		t.Fatalf("Expected the first keys to be admitted, got %+v", changes)
+	}
+
+	second := &types.LogEntry{AppName: "api", StructuredData: map[string]interface{}{
+		"req":  map[string]string{"id": "2"},
+		"user": map[string]interface{}{"u-123": "alice"},
+	}}
+	changes := guard.Apply(second)
+	if changes.Bucketed != 1 || changes.Truncated != 0 {
+		t.Fatalf("Expected 1 bucketed parameter, got %+v", changes)
+	}
+	if _, ok := second.StructuredData["user"]; ok {
+		t.Error("Expected the overflowing element to be removed")
+	}
+	meta := second.StructuredData[types.MetadataSDID].(map[string]interface{})
+	if meta[OverflowParam] != "user.u-123=alice" {
+		t.Errorf("Expected the overflow parameter to hold the pair, got %v", meta[OverflowParam])
+	}
+	if second.StructuredData["req"].(map[string]string)["id"] != "2" {
+		t.Error("Expected known keys to be kept")
+	}
+
+	// Other applications have their own keys
+	other := &types.LogEntry{AppName: "worker", StructuredData: map[string]interface{}{
+		"user": map[string]string{"u-123": "alice"},
+	}}
+	if changes := guard.Apply(other); changes.Any() {
+		t.Errorf("Expected another application's keys to be admitted, got %+v", changes)
+	}
+}
+
+func TestGuard_SizeAndParams(t *testing.T) {
+	params := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		params[fmt.Sprintf("p%d", i)] = "v"
+	}
+	entry := &types.LogEntry{StructuredData: map[string]interface{}{"x": params}}
+	changes := NewGuard(Limits{MaxParams: 4}).Apply(entry)
+	if changes.Truncated != 6 {
+		t.Fatalf("Expected 6 truncated parameters, got %+v", changes)
+	}
+	kept := entry.StructuredData["x"].(map[string]string)
+	for _, name := range []string{"p0", "p1", "p2", "p3"} {
+		if _, ok := kept[name]; !ok {
+			t.Errorf("Expected %s to be kept in key order, got %v", name, kept)
+		}
+	}
+	meta := entry.StructuredData[types.MetadataSDID].(map[string]interface{})
+	if meta[TruncatedParam] != "6" {
+		t.Errorf("Expected the truncation marker 6, got %v", meta[TruncatedParam])
+	}
+
+	entry = &types.LogEntry{StructuredData: map[string]interface{}{
+		"a": map[string]string{"big": strings.Repeat("x", 100), "small": "y"},
+	}}
+	changes = NewGuard(Limits{MaxBytes: 50}).Apply(entry)
+	if changes.Truncated != 1 {
+		t.Fatalf("Expected 1 truncated parameter, got %+v", changes)
+	}
+	if kept := entry.StructuredData["a"].(map[string]string); len(kept) != 1 || kept["small"] != "y" {
+		t.Errorf("Expected only the small parameter to fit, got %v", kept)
+	}
+}
+
+func TestGuard_ClearsSenderMarkers(t *testing.T) {
+	entry := &types.LogEntry{StructuredData: map[string]interface{}{
+		types.MetadataSDID: map[string]string{TruncatedParam: "99"},
+		"a":                map[string]string{"b": "c"},
+	}}
+	if changes := NewGuard(DefaultLimits).Apply(entry); changes.Any() {
+		t.Fatalf("Expected no changes, got %+v", changes)
+	}
+	if _, ok := entry.StructuredData[types.MetadataSDID]; ok {
+		t.Errorf("Expected the spoofed marker to be removed, got %v", entry.StructuredData)
+	}
+}
@@ -0,0 +1,141 @@
+// Package sanitize makes the text of incoming log entries safe to store, encode as JSON and show in
+// the web UI: invalid UTF-8 is replaced, control characters are escaped and text is normalized to
+// Unicode NFC, so the same message always looks and matches the same.
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"opentrail/internal/types"
+)
+
+// colorSequence matches an ANSI Select Graphic Rendition sequence at the start of text, which sets
+// colors and text styles and moves nothing on screen
+var colorSequence = regexp.MustCompile(`^\x1b\[[0-9;:]*m`)
+
+// Changes records what sanitizing an entry changed
+type Changes struct {
+	// InvalidUTF8 is set when invalid UTF-8 sequences were replaced by U+FFFD
+	InvalidUTF8 bool
+	// ControlChars is set when control characters were escaped
+	ControlChars bool
+	// Normalized is set when text was normalized to NFC
+	Normalized bool
+}
+
+// Any reports whether anything was changed
+func (c Changes) Any() bool {
+	return c.InvalidUTF8 || c.ControlChars || c.Normalized
+}
+
+// Entry sanitizes the header fields, message and structured data of an entry in place. Line
+// breaks and tabs are kept in the message, where they belong to multi-line messages such as
+// stack traces, and escaped everywhere else. With keepColors the ANSI color and style sequences of
+// the message are kept for the web UI to render; other escape sequences are still escaped. The raw
+// message is left as received.
+func Entry(entry *types.LogEntry, keepColors bool) Changes {
+	var changes Changes
+	entry.Hostname = changes.text(entry.Hostname, false, false)
+	entry.AppName = changes.text(entry.AppName, false, false)
+	entry.ProcID = changes.text(entry.ProcID, false, false)
+	entry.MsgID = changes.text(entry.MsgID, false, false)
+	entry.Message = changes.text(entry.Message, true, keepColors)
+
+	if len(entry.StructuredData) > 0 {
+		sanitized := make(map[string]interface{}, len(entry.StructuredData))
+		for sdid, element := range entry.StructuredData {
+			sanitized[changes.text(sdid, false, false)] = changes.element(element)
+		}
+		entry.StructuredData = sanitized
+	}
+	return changes
+}
+
+// element sanitizes the parameter names and values of one structured data element
+func (c *Changes) element(element interface{}) interface{} {
+	switch params := element.(type) {
+	case map[string]string:
+		sanitized := make(map[string]string, len(params))
+		for name, value := range params {
+			sanitized[c.text(name, false, false)] = c.text(value, false, false)
+		}
+		return sanitized
+	case map[string]interface{}:
+		sanitized := make(map[string]interface{}, len(params))
+		for name, value := range params {
+			if s, ok := value.(string); ok {
+				value = c.text(s, false, false)
+			}
+			sanitized[c.text(name, false, false)] = value
+		}
+		return sanitized
+	case string:
+		return c.text(params, false, false)
+	}
+	return element
+}
+
+// String sanitizes a single value; multiline keeps line breaks and tabs
+func String(s string, multiline bool) (string, Changes) {
+	var changes Changes
+	s = changes.text(s, multiline, false)
+	return s, changes
+}
+
+// text sanitizes one value, recording what changed; keepColors keeps ANSI color sequences
+func (c *Changes) text(s string, multiline, keepColors bool) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "\ufffd")
+		c.InvalidUTF8 = true
+	}
+
+	if strings.IndexFunc(s, func(r rune) bool { return isControl(r, multiline) }) >= 0 {
+		var b strings.Builder
+		b.Grow(len(s) + 8)
+		escaped := false
+		for i := 0; i < len(s); {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			var color string
+			if keepColors && r == 0x1b {
+				color = colorSequence.FindString(s[i:])
+			}
+			switch {
+			case !isControl(r, multiline):
+				b.WriteRune(r)
+			case color != "":
+				b.WriteString(color)
+				size = len(color)
+			case r < 0x80:
+				fmt.Fprintf(&b, `\x%02x`, r)
+				escaped = true
+			default:
+				fmt.Fprintf(&b, `\u%04x`, r)
+				escaped = true
+			}
+			i += size
+		}
+		if escaped {
+			s = b.String()
+			c.ControlChars = true
+		}
+	}
+
+	if !norm.NFC.IsNormalString(s) {
+		s = norm.NFC.String(s)
+		c.Normalized = true
+	}
+	return s
+}
+
+// isControl reports whether a rune is a C0 or C1 control character or DEL that has to be escaped
+func isControl(r rune, multiline bool) bool {
+	if multiline && (r == '\n' || r == '\t') {
+		return false
+	}
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f)
+}
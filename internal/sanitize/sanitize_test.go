@@ -0,0 +1,96 @@
+package sanitize
+
+import (
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		multiline bool
+		want      string
+		changes   Changes
+	}{
+		{"clean", "user logged in", false, "user logged in", Changes{}},
+		{"invalid utf8", "bad \xff\xfe byte", false, "bad \ufffd byte", Changes{InvalidUTF8: true}},
+		{"escape sequence", "\x1b[31mred\x1b[0m", false, `\x1b[31mred\x1b[0m`, Changes{ControlChars: true}},
+		{"nul and del", "a\x00b\x7f", false, `a\x00b\x7f`, Changes{ControlChars: true}},
+		{"c1 control", "next\u0085line", false, `next\u0085line`, Changes{ControlChars: true}},
+		{"line breaks kept in messages", "panic:\n\tmain.go:12", true, "panic:\n\tmain.go:12", Changes{}},
+		{"line breaks escaped in fields", "web\n01", false, `web\x0a01`, Changes{ControlChars: true}},
+		{"carriage return", "line\r\n", true, "line\\x0d\n", Changes{ControlChars: true}},
+		{"nfc", "cafe\u0301", false, "caf\u00e9", Changes{Normalized: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes := String(tt.input, tt.multiline)
+			if got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if changes != tt.changes {
+				t.Errorf("String(%q) changes = %+v, want %+v", tt.input, changes, tt.changes)
+			}
+		})
+	}
+}
+
+func TestEntry(t *testing.T) {
+	entry := &types.LogEntry{
+		Hostname: "web\x0001",
+		AppName:  "api",
+		Message:  "first line\nsecond \xc3\x28 line",
+		Raw:      "<14>1 - web\x0001 api - - - first line\nsecond \xc3\x28 line",
+		StructuredData: map[string]interface{}{
+			"request": map[string]interface{}{"path": "/cafe\u0301", "status": 200},
+			"meta":    map[string]string{"note": "bell\x07"},
+		},
+	}
+
+	changes := Entry(entry, false)
+	if !changes.InvalidUTF8 || !changes.ControlChars || !changes.Normalized {
+		t.Errorf("Expected every kind of change, got %+v", changes)
+	}
+	if entry.Hostname != `web\x0001` || entry.Message != "first line\nsecond \ufffd( line" {
+		t.Errorf("Unexpected sanitized fields %q, %q", entry.Hostname, entry.Message)
+	}
+	request := entry.StructuredData["request"].(map[string]interface{})
+	if request["path"] != "/caf\u00e9" || request["status"] != 200 {
+		t.Errorf("Unexpected sanitized structured data %+v", request)
+	}
+	if note := entry.StructuredData["meta"].(map[string]string)["note"]; note != `bell\x07` {
+		t.Errorf("Expected control character to be escaped, got %q", note)
+	}
+	// The raw message stays as received, so the entry can be re-parsed
+	if entry.Raw != "<14>1 - web\x0001 api - - - first line\nsecond \xc3\x28 line" {
+		t.Errorf("Expected raw message to be kept, got %q", entry.Raw)
+	}
+
+	if changes := Entry(&types.LogEntry{Hostname: "web01", Message: "fine"}, false); changes.Any() {
+		t.Errorf("Expected no changes for clean entry, got %+v", changes)
+	}
+}
+
+func TestEntry_KeepColors(t *testing.T) {
+	entry := &types.LogEntry{
+		AppName: "\x1b[31mapi",
+		Message: "\x1b[1;31mERROR\x1b[0m failed\n\x1b[38;2;255;0;0mtrace\x1b[m",
+	}
+	if changes := Entry(entry, true); !changes.ControlChars || entry.Message != "\x1b[1;31mERROR\x1b[0m failed\n\x1b[38;2;255;0;0mtrace\x1b[m" {
+		t.Errorf("Expected colors kept in the message only, got %q (%+v)", entry.Message, changes)
+	}
+	if entry.AppName != `\x1b[31mapi` {
+		t.Errorf("Expected colors escaped outside the message, got %q", entry.AppName)
+	}
+
+	// Sequences that move the cursor or set the window title are escaped all the same
+	moving := &types.LogEntry{Message: "\x1b[2J\x1b]0;title\x07\x1b[32mok\x1b[0m"}
+	if changes := Entry(moving, true); !changes.ControlChars || moving.Message != `\x1b[2J\x1b]0;title\x07`+"\x1b[32mok\x1b[0m" {
+		t.Errorf("Expected other sequences escaped, got %q", moving.Message)
+	}
+	if changes := Entry(&types.LogEntry{Message: "\x1b[32mok\x1b[0m"}, true); changes.Any() {
+		t.Errorf("Expected no changes for a message with colors only, got %+v", changes)
+	}
+}
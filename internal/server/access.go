@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader carries the ID of an HTTP request, taken from the client or proxy when valid
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	// maxRequestIDLength bounds the length of request IDs accepted from clients
+	maxRequestIDLength = 128
+
+	// accessAppName and accessSDID identify ingested access log entries
+	accessAppName = "opentrail-http"
+	accessSDID    = "access@32473"
+	// accessFacility is the syslog facility of ingested access log entries (local0)
+	accessFacility = 16
+)
+
+// accessHostname is the hostname ingested access log entries are attributed to
+var accessHostname = sync.OnceValue(func() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "-"
+	}
+	return hostname
+})
+
+// accessContextKey is the request context key of the request's accessRecord
+type accessContextKey struct{}
+
+// accessRecord collects what the access log reports about a request beyond the request itself
+type accessRecord struct {
+	id   string
+	user string
+}
+
+// requestID returns the ID of a request, empty outside withAccessLog
+func requestID(r *http.Request) string {
+	if record, ok := r.Context().Value(accessContextKey{}).(*accessRecord); ok {
+		return record.id
+	}
+	return ""
+}
+
+// setAccessUser records the authenticated user of a request for the access log
+func setAccessUser(r *http.Request, user string) {
+	if record, ok := r.Context().Value(accessContextKey{}).(*accessRecord); ok {
+		record.user = user
+	}
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// validRequestID reports whether a client supplied request ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// accessResponseWriter records the status and size of a response
+type accessResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (w *accessResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades take over the connection, which is logged as switching protocols
+func (w *accessResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *accessResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withAccessLog assigns every request an ID, echoed in the X-Request-ID response header, and
+// writes an access log entry once the request is served when access logging is enabled
+func (s *HTTPServer) withAccessLog(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		record := &accessRecord{id: id}
+		r = r.WithContext(context.WithValue(r.Context(), accessContextKey{}, record))
+
+		if !s.config.AccessLog && !s.config.AccessLogIngest {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		started := time.Now()
+		recorder := &accessResponseWriter{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+		s.logAccess(r, record, recorder, time.Since(started))
+	})
+}
+
+// logAccess writes the access log entry of a served request to the process log and, with
+// -access-log-ingest, into the log store
+func (s *HTTPServer) logAccess(r *http.Request, record *accessRecord, recorder *accessResponseWriter, duration time.Duration) {
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	user := record.user
+	if user == "" {
+		user = "-"
+	}
+	client := s.proxies.clientIP(r)
+
+	if s.config.AccessLog {
+		log.Printf("access request_id=%s method=%s path=%q status=%d duration=%s bytes=%d user=%q client=%s",
+			record.id, r.Method, r.URL.Path, status, duration.Round(time.Microsecond), recorder.bytes, user, client)
+	}
+
+	if s.config.AccessLogIngest {
+		message := accessMessage(time.Now(), record.id, r.Method, r.URL.Path, status, duration, recorder.bytes, user, client)
+		if err := processLogFrom(s.logService, message, client); err != nil {
+			log.Printf("Failed to ingest access log entry for request %s: %v", record.id, err)
+		}
+	}
+}
+
+// accessMessage formats an access log entry as an RFC5424 message, with the request details as
+// structured data so they can be searched and aggregated like any other field
+func accessMessage(now time.Time, id, method, path string, status int, duration time.Duration, bytes int64, user, client string) string {
+	severity := 6 // informational
+	switch {
+	case status >= 500:
+		severity = 3 // error
+	case status >= 400:
+		severity = 4 // warning
+	}
+
+	params := []struct{ name, value string }{
+		{"request_id", id},
+		{"method", method},
+		{"path", path},
+		{"status", fmt.Sprint(status)},
+		{"duration_ms", fmt.Sprintf("%.3f", float64(duration.Microseconds())/1000)},
+		{"bytes", fmt.Sprint(bytes)},
+		{"user", user},
+		{"client", client},
+	}
+	var sd strings.Builder
+	sd.WriteString("[" + accessSDID)
+	for _, param := range params {
+		fmt.Fprintf(&sd, ` %s="%s"`, param.name, escapeSDValue(param.value))
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s - access %s %s %s %d %s",
+		accessFacility*8+severity, now.UTC().Format(time.RFC3339Nano), accessHostname(), accessAppName,
+		sd.String(), method, path, status, duration.Round(time.Microsecond))
+}
+
+// escapeSDValue escapes the characters RFC5424 reserves in structured data parameter values
+func escapeSDValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxAccountRequestSize bounds the body of a user or API token request
+	maxAccountRequestSize = 4096
+	// apiTokenUserPrefix starts the user of requests authenticated by an API token, followed by the
+	// token's name; usernames cannot contain the colon
+	apiTokenUserPrefix = "token:"
+)
+
+// userRequest is the body of user creation and update requests
+type userRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// apiTokenRequest is the body of an API token creation request
+type apiTokenRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// ExpiresInSeconds is how long the token is valid; zero keeps it valid until it is deleted
+	ExpiresInSeconds int64 `json:"expires_in_seconds,omitempty"`
+}
+
+// bearerToken returns the token of an Authorization: Bearer header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// authenticateUser checks credentials against the users managed through the admin API
+func (s *HTTPServer) authenticateUser(username, password string) (*types.User, bool) {
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		return nil, false
+	}
+	user, err := manager.Authenticate(username, password)
+	if err != nil {
+		if !errors.Is(err, interfaces.ErrUserNotFound) {
+			log.Printf("Error authenticating user %q: %v", username, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// authenticateToken checks a bearer token against the API tokens
+func (s *HTTPServer) authenticateToken(value string) (*types.APIToken, bool) {
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		return nil, false
+	}
+	token, err := manager.AuthenticateToken(value)
+	if err != nil {
+		if !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+			log.Printf("Error authenticating API token: %v", err)
+		}
+		return nil, false
+	}
+	return token, true
+}
+
+// sendAccountError answers a failed user or API token request
+func (s *HTTPServer) sendAccountError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, interfaces.ErrInvalidAccount):
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, interfaces.ErrUserExists):
+		s.sendErrorResponse(w, http.StatusConflict, err.Error())
+	case errors.Is(err, interfaces.ErrUserNotFound), errors.Is(err, interfaces.ErrAPITokenNotFound):
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("Error trying to %s: %v", action, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s", action))
+	}
+}
+
+// configuredUser reports whether a username belongs to an account of the server's configuration,
+// which cannot be managed through the admin API
+func (s *HTTPServer) configuredUser(username string) bool {
+	username = strings.TrimSpace(username)
+	return username != "" && (username == s.config.AuthUsername || username == s.config.ReaderUsername)
+}
+
+// handleUsers lists the users managed through the admin API (GET) or creates one (POST) from a body
+// like {"username": "alice", "password": "...", "role": "reader"}. The accounts of the server's
+// configuration are not listed and their names cannot be taken.
+func (s *HTTPServer) handleUsers(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Accounts are not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		users, err := manager.Users()
+		if err != nil {
+			s.sendAccountError(w, err, "list users")
+			return
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    users,
+		})
+		return
+	}
+
+	var req userRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAccountRequestSize)).Decode(&req); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if s.configuredUser(req.Username) {
+		s.sendErrorResponse(w, http.StatusConflict, fmt.Sprintf("User %q is set by the server's configuration", req.Username))
+		return
+	}
+
+	user, err := manager.CreateUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		s.sendAccountError(w, err, "create user")
+		return
+	}
+	log.Printf("User %q created with the %s role by %q", user.Username, user.Role, s.requestUser(r))
+
+	s.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// handleUser changes the password or role of a user (PUT /api/admin/users/{username} with a body
+// like {"password": "...", "role": "admin"}, leaving out what stays) or removes it (DELETE)
+func (s *HTTPServer) handleUser(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	username := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
+	if username == "" || strings.Contains(username, "/") {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Accounts are not supported")
+		return
+	}
+	if s.configuredUser(username) {
+		s.sendErrorResponse(w, http.StatusConflict, fmt.Sprintf("User %q is set by the server's configuration", username))
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := manager.DeleteUser(username); err != nil {
+			s.sendAccountError(w, err, "delete user")
+			return
+		}
+		log.Printf("User %q deleted by %q", username, s.requestUser(r))
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    map[string]string{"username": username},
+		})
+		return
+	}
+
+	var req userRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAccountRequestSize)).Decode(&req); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	user, err := manager.UpdateUser(username, req.Password, req.Role)
+	if err != nil {
+		s.sendAccountError(w, err, "update user")
+		return
+	}
+	log.Printf("User %q updated by %q (role %s, password changed: %t)", username, s.requestUser(r), user.Role, req.Password != "")
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// handleAPITokens lists the API tokens without their secrets (GET) or creates one (POST) from a body
+// like {"name": "ci", "role": "reader", "expires_in_seconds": 2592000}. The response to the creation
+// is the only one carrying the token.
+func (s *HTTPServer) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Accounts are not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		tokens, err := manager.APITokens()
+		if err != nil {
+			s.sendAccountError(w, err, "list API tokens")
+			return
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    tokens,
+		})
+		return
+	}
+
+	var req apiTokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAccountRequestSize)).Decode(&req); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if req.ExpiresInSeconds < 0 {
+		s.sendErrorResponse(w, http.StatusBadRequest, "expires_in_seconds must not be negative")
+		return
+	}
+
+	token := &types.APIToken{Name: req.Name, Role: req.Role, CreatedBy: s.requestUser(r)}
+	if err := manager.CreateAPIToken(token, time.Duration(req.ExpiresInSeconds)*time.Second); err != nil {
+		s.sendAccountError(w, err, "create API token")
+		return
+	}
+	log.Printf("API token %q created with the %s role by %q", token.Name, token.Role, token.CreatedBy)
+
+	s.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    token,
+	})
+}
+
+// handleAPIToken revokes an API token (DELETE /api/admin/tokens/{id})
+func (s *HTTPServer) handleAPIToken(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/tokens/"), 10, 64)
+	if err != nil || id <= 0 {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.AccountManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Accounts are not supported")
+		return
+	}
+
+	if err := manager.DeleteAPIToken(id); err != nil {
+		s.sendAccountError(w, err, "delete API token")
+		return
+	}
+	log.Printf("API token %d deleted by %q", id, s.requestUser(r))
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]int64{"id": id},
+	})
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// accountService keeps users and API tokens in memory, with passwords and tokens in the clear
+type accountService struct {
+	MockLogService
+	users     map[string]types.User
+	passwords map[string]string
+	tokens    map[string]types.APIToken
+}
+
+func (m *accountService) CreateUser(username, password, role string) (*types.User, error) {
+	if role != types.RoleAdmin && role != types.RoleReader {
+		return nil, fmt.Errorf("%w: bad role", interfaces.ErrInvalidAccount)
+	}
+	if _, ok := m.users[username]; ok {
+		return nil, interfaces.ErrUserExists
+	}
+	m.users[username], m.passwords[username] = types.User{Username: username, Role: role}, password
+	user := m.users[username]
+	return &user, nil
+}
+
+func (m *accountService) Users() ([]types.User, error) {
+	users := []types.User{}
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *accountService) UpdateUser(username, password, role string) (*types.User, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return nil, interfaces.ErrUserNotFound
+	}
+	if role != "" {
+		user.Role = role
+	}
+	if password != "" {
+		m.passwords[username] = password
+	}
+	m.users[username] = user
+	return &user, nil
+}
+
+func (m *accountService) DeleteUser(username string) error {
+	if _, ok := m.users[username]; !ok {
+		return interfaces.ErrUserNotFound
+	}
+	delete(m.users, username)
+	return nil
+}
+
+func (m *accountService) Authenticate(username, password string) (*types.User, error) {
+	user, ok := m.users[username]
+	if !ok || m.passwords[username] != password {
+		return nil, interfaces.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (m *accountService) CreateAPIToken(token *types.APIToken, ttl time.Duration) error {
+	token.ID = int64(len(m.tokens) + 1)
+	token.Token = fmt.Sprintf("ot_%d", token.ID)
+	m.tokens[token.Token] = *token
+	return nil
+}
+
+func (m *accountService) APITokens() ([]types.APIToken, error) {
+	tokens := []types.APIToken{}
+	for _, token := range m.tokens {
+		token.Token = ""
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+func (m *accountService) DeleteAPIToken(id int64) error {
+	for value, token := range m.tokens {
+		if token.ID == id {
+			delete(m.tokens, value)
+			return nil
+		}
+	}
+	return interfaces.ErrAPITokenNotFound
+}
+
+func (m *accountService) AuthenticateToken(value string) (*types.APIToken, error) {
+	token, ok := m.tokens[value]
+	if !ok {
+		return nil, interfaces.ErrAPITokenNotFound
+	}
+	return &token, nil
+}
+
+func TestHTTPServer_Users(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+	service := &accountService{users: make(map[string]types.User), passwords: make(map[string]string)}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	session := func(user, password string) Session {
+		t.Helper()
+		w := request(http.MethodGet, "/api/ui/session", "", user, password)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %s to sign in, got %d", user, w.Code)
+		}
+		var response struct {
+			Data Session `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Data
+	}
+
+	if w := request(http.MethodPost, "/api/admin/users", `{"username": "bob", "password": "hunter22", "role": "reader"}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected readers to be refused, got %d", w.Code)
+	}
+	w := request(http.MethodPost, "/api/admin/users", `{"username": "bob", "password": "hunter22", "role": "reader"}`, "admin", "password")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	for body, status := range map[string]int{
+		`{"username": "bob", "password": "hunter22", "role": "reader"}`:   http.StatusConflict,
+		`{"username": "reader", "password": "hunter22", "role": "admin"}`: http.StatusConflict,
+		`{"username": "carol", "password": "hunter22", "role": "owner"}`:  http.StatusBadRequest,
+		`{"username": "carol"`: http.StatusBadRequest,
+	} {
+		if w := request(http.MethodPost, "/api/admin/users", body, "admin", "password"); w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, w.Code)
+		}
+	}
+
+	// The new user signs in next to the configured accounts
+	if got := session("bob", "hunter22"); got.User != "bob" || got.Role != roleReader {
+		t.Errorf("Expected bob as a reader, got %+v", got)
+	}
+	if w := request(http.MethodGet, "/api/ui/session", "", "bob", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong password refused, got %d", w.Code)
+	}
+
+	if w := request(http.MethodPut, "/api/admin/users/bob", `{"role": "admin"}`, "admin", "password"); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected update response %d: %s", w.Code, w.Body.String())
+	}
+	if got := session("bob", "hunter22"); got.Role != roleAdmin {
+		t.Errorf("Expected bob promoted, got %+v", got)
+	}
+	if w := request(http.MethodPut, "/api/admin/users/admin", `{"password": "hunter22"}`, "admin", "password"); w.Code != http.StatusConflict {
+		t.Errorf("Expected the configured admin to be left alone, got %d", w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/admin/users", "", "bob", "hunter22")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"bob"`) || strings.Contains(w.Body.String(), "hunter22") {
+		t.Errorf("Expected bob listed without his password, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := request(http.MethodDelete, "/api/admin/users/bob", "", "admin", "password"); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected delete response %d", w.Code)
+	}
+	if w := request(http.MethodDelete, "/api/admin/users/bob", "", "admin", "password"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 once deleted, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/api/ui/session", "", "bob", "hunter22"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a deleted user refused, got %d", w.Code)
+	}
+}
+
+func TestHTTPServer_APITokens(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password"}
+	service := &accountService{tokens: make(map[string]types.APIToken)}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, bearer string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		} else {
+			r.SetBasicAuth("admin", "password")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	for body, status := range map[string]int{
+		`{"name": "ci", "role": "reader", "expires_in_seconds": -1}`: http.StatusBadRequest,
+		`{"name": "ci"`: http.StatusBadRequest,
+	} {
+		if w := request(http.MethodPost, "/api/admin/tokens", body, ""); w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, w.Code)
+		}
+	}
+	w := request(http.MethodPost, "/api/admin/tokens", `{"name": "ci", "role": "reader"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data types.APIToken `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Data.Token == "" || created.Data.CreatedBy != "admin" {
+		t.Errorf("Expected the token returned once with its creator, got %+v", created.Data)
+	}
+
+	// The token authenticates with its role and name
+	w = request(http.MethodGet, "/api/ui/session", "", created.Data.Token)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"user":"token:ci"`) || !strings.Contains(w.Body.String(), `"role":"reader"`) {
+		t.Errorf("Expected the session of the token, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodGet, "/api/admin/tokens", "", created.Data.Token); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a reader token refused admin endpoints, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/api/ui/session", "", "ot_unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown token refused, got %d", w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/admin/tokens", "", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Data.Token) {
+		t.Errorf("Expected the tokens listed without their secrets, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := request(http.MethodDelete, fmt.Sprintf("/api/admin/tokens/%d", created.Data.ID), "", ""); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected delete response %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/api/ui/session", "", created.Data.Token); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a deleted token refused, got %d", w.Code)
+	}
+	if w := request(http.MethodDelete, "/api/admin/tokens/nope", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a bad ID, got %d", w.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"opentrail/internal/shipper"
+)
+
+const (
+	// ackInterval is the shortest time between two ACKs sent to a shipper
+	ackInterval = 100 * time.Millisecond
+	// ackFlushTimeout bounds how long a closing shipper connection waits for its lines to be stored
+	ackFlushTimeout = 5 * time.Second
+)
+
+// ackTracker numbers the lines of a shipper connection that asked for acknowledgements and follows
+// which ones are stored, so they can be acknowledged in order
+type ackTracker struct {
+	mu       sync.Mutex
+	received int64
+	acked    int64
+	// stored holds the numbers of stored lines that follow one still being stored
+	stored map[int64]bool
+	failed bool
+	// changed is signalled when more lines are acknowledged or a line failed
+	changed chan struct{}
+	// stop ends the connection's ACK loop
+	stop chan struct{}
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{
+		stored:  make(map[int64]bool),
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+}
+
+// add numbers the next line received, starting with 1
+func (a *ackTracker) add() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.received++
+	return a.received
+}
+
+// complete records the outcome of storing a line
+func (a *ackTracker) complete(line int64, err error) {
+	a.mu.Lock()
+	if err != nil {
+		a.failed = true
+	} else {
+		a.stored[line] = true
+		for a.stored[a.acked+1] {
+			delete(a.stored, a.acked+1)
+			a.acked++
+		}
+	}
+	a.mu.Unlock()
+
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+// state returns the number of lines received and acknowledged, and whether a line failed to be
+// stored
+func (a *ackTracker) state() (received, acked int64, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.received, a.acked, a.failed
+}
+
+// ackLoop sends a shipper the number of its lines stored as it grows, and closes the connection
+// when a line could not be stored so that the shipper sends the unacknowledged lines again
+func (s *TCPServer) ackLoop(tracked *tcpConnection, acks *ackTracker) {
+	defer s.wg.Done()
+
+	var sent int64
+	for {
+		select {
+		case <-acks.changed:
+		case <-acks.stop:
+			return
+		}
+
+		_, acked, failed := acks.state()
+		if failed {
+			log.Printf("Closing shipper connection from %s: a line could not be stored", tracked.conn.RemoteAddr())
+			tracked.conn.Close()
+			return
+		}
+		if acked > sent {
+			if err := tracked.sendControl(shipper.AckNotice(acked)); err != nil {
+				log.Printf("Failed to send ACK to %s: %v", tracked.conn.RemoteAddr(), err)
+				return
+			}
+			sent = acked
+		}
+
+		select {
+		case <-time.After(ackInterval):
+		case <-acks.stop:
+			return
+		}
+	}
+}
+
+// finishAcks waits up to ackFlushTimeout for the lines of a closing shipper connection to be
+// stored and acknowledges them before the connection is closed
+func (s *TCPServer) finishAcks(tracked *tcpConnection) {
+	acks := tracked.acks
+	if acks == nil {
+		return
+	}
+	// The ACK loop would otherwise compete for the change notifications
+	close(acks.stop)
+
+	deadline := time.NewTimer(ackFlushTimeout)
+	defer deadline.Stop()
+	for {
+		received, acked, failed := acks.state()
+		if failed {
+			return
+		}
+		if acked == received {
+			if acked > 0 {
+				tracked.sendControl(shipper.AckNotice(acked))
+			}
+			return
+		}
+		select {
+		case <-acks.changed:
+		case <-deadline.C:
+			log.Printf("Closing shipper connection from %s with %d line(s) not yet stored", tracked.conn.RemoteAddr(), received-acked)
+			return
+		}
+	}
+}
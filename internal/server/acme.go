@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"opentrail/internal/types"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newACMEManager returns the manager obtaining the web interface's certificates through ACME and
+// renewing them before they expire, or nil if no ACME domains are configured. Certificates are
+// requested on the first TLS handshake for a domain, and only for the configured domains.
+func newACMEManager(config *types.Config) *autocert.Manager {
+	if len(config.ACMEDomains) == 0 {
+		return nil
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(config.ACMECacheDir),
+		HostPolicy: autocert.HostWhitelist(config.ACMEDomains...),
+		Email:      config.ACMEEmail,
+	}
+	if config.ACMEDirectory != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.ACMEDirectory}
+	}
+	return manager
+}
+
+// acmeTLSConfig returns the TLS configuration of the HTTPS listener, which also answers
+// tls-alpn-01 challenges
+func (s *HTTPServer) acmeTLSConfig() *tls.Config {
+	tlsConfig := s.acme.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig
+}
+
+// challengeHandler answers http-01 challenges and redirects every other request to HTTPS
+func (s *HTTPServer) challengeHandler() http.Handler {
+	return s.acme.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if s.config.HTTPPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(s.config.HTTPPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}))
+}
+
+// startChallengeServer serves http-01 challenges on the ACME HTTP port, if that challenge is used
+func (s *HTTPServer) startChallengeServer() error {
+	if s.acme == nil || s.config.ACMEChallenge != types.ACMEHTTP {
+		return nil
+	}
+
+	addr := listenAddress(s.config.HTTPBindAddress, s.config.ACMEHTTPPort)
+	listener, err := s.challengeListen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for ACME challenges on %s: %w", addr, err)
+	}
+	s.challengeServer = &http.Server{
+		Handler:           s.challengeHandler(),
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		log.Printf("ACME challenge server starting on %s", listener.Addr())
+		if err := s.challengeServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("ACME challenge server error: %v", err)
+		}
+	}()
+	return nil
+}
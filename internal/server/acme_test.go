@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func TestNewACMEManager(t *testing.T) {
+	if server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{}); server.acme != nil {
+		t.Fatal("Expected plain HTTP without ACME domains")
+	}
+
+	config := &types.Config{
+		HTTPPort:      443,
+		ACMEDomains:   []string{"logs.example.com"},
+		ACMEDirectory: "https://acme-staging-v02.api.letsencrypt.org/directory",
+		ACMECacheDir:  t.TempDir(),
+		ACMEChallenge: types.ACMETLSALPN,
+	}
+	manager := NewHTTPServer(config, &MockLogService{}).acme
+	if manager == nil {
+		t.Fatal("Expected an ACME manager")
+	}
+	if manager.Client == nil || manager.Client.DirectoryURL != config.ACMEDirectory {
+		t.Errorf("Expected the configured directory, got %+v", manager.Client)
+	}
+	if err := manager.HostPolicy(context.Background(), "logs.example.com"); err != nil {
+		t.Errorf("Expected the configured domain to be allowed, got %v", err)
+	}
+	if err := manager.HostPolicy(context.Background(), "other.example.com"); err == nil {
+		t.Error("Expected other domains to be rejected")
+	}
+}
+
+func TestHTTPServer_ACMEChallengeRedirect(t *testing.T) {
+	for _, tc := range []struct {
+		port     int
+		location string
+	}{
+		{443, "https://logs.example.com/api/logs?limit=10"},
+		{8443, "https://logs.example.com:8443/api/logs?limit=10"},
+	} {
+		config := &types.Config{HTTPPort: tc.port, ACMEDomains: []string{"logs.example.com"}, ACMECacheDir: t.TempDir()}
+		handler := NewHTTPServer(config, &MockLogService{}).challengeHandler()
+
+		req := httptest.NewRequest(http.MethodGet, "http://logs.example.com/api/logs?limit=10", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tc.location {
+			t.Errorf("Expected a redirect to %s, got %d %s", tc.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestHTTPServer_ACMEStart(t *testing.T) {
+	config := &types.Config{
+		HTTPPort:      8443,
+		ACMEDomains:   []string{"logs.example.com"},
+		ACMECacheDir:  filepath.Join(t.TempDir(), "acme"),
+		ACMEChallenge: types.ACMEHTTP,
+		ACMEHTTPPort:  8080,
+	}
+	server := NewHTTPServer(config, &MockLogService{})
+
+	var httpsAddr, challengeAddr net.Addr
+	server.SetListenFunc(func(network, addr string) (net.Listener, error) {
+		listener, err := net.Listen(network, "127.0.0.1:0")
+		if err == nil {
+			httpsAddr = listener.Addr()
+		}
+		return listener, err
+	})
+	server.SetChallengeListenFunc(func(network, addr string) (net.Listener, error) {
+		listener, err := net.Listen(network, "127.0.0.1:0")
+		if err == nil {
+			challengeAddr = listener.Addr()
+		}
+		return listener, err
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer server.Stop()
+
+	// The HTTPS listener only serves the configured domains
+	conn, err := tls.Dial("tcp", httpsAddr.String(), &tls.Config{ServerName: "other.example.com", InsecureSkipVerify: true})
+	if err == nil {
+		conn.Close()
+		t.Error("Expected the handshake for an unknown domain to fail")
+	}
+
+	// The challenge listener redirects to HTTPS
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, "http://"+challengeAddr.String()+"/", nil)
+	req.Host = "logs.example.com"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Challenge listener request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "https://logs.example.com:8443/" {
+		t.Errorf("Expected a redirect to HTTPS, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
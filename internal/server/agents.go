@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"opentrail/internal/agents"
+)
+
+// SetAgentRegistry makes agent registration and central agent configuration available
+func (s *HTTPServer) SetAgentRegistry(registry *agents.Registry) {
+	s.agents = registry
+}
+
+// handleAgentRegister serves POST /api/agents/register, where an agent announces itself when it starts
+func (s *HTTPServer) handleAgentRegister(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.agents == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Agent management is not available")
+		return
+	}
+
+	var registration agents.Registration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON in request body")
+		return
+	}
+	registered, err := s.agents.Register(registration)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    registered,
+	})
+}
+
+// handleAgentHeartbeat serves POST /api/agents/heartbeat, where a registered agent reports the
+// configuration it applies and how far it tailed its files
+func (s *HTTPServer) handleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.agents == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Agent management is not available")
+		return
+	}
+
+	var heartbeat agents.Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Invalid JSON in request body")
+		return
+	}
+	response, err := s.agents.Heartbeat(heartbeat)
+	if errors.Is(err, agents.ErrUnknownAgent) {
+		s.sendError(w, http.StatusNotFound, agents.UnknownAgentCode, err.Error())
+		return
+	}
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// handleAgentConfig serves GET /api/agents/config?id=..., the configuration centrally managed for an agent
+func (s *HTTPServer) handleAgentConfig(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.agents == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Agent management is not available")
+		return
+	}
+
+	config, err := s.agents.Config(r.URL.Query().Get("id"))
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    config,
+	})
+}
+
+// handleAgents lists the registered agents with their configuration state and lag
+func (s *HTTPServer) handleAgents(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.agents == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Agent management is not available")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.agents.Agents(),
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"opentrail/internal/interfaces"
+)
+
+// handleAlertHistory returns the recorded firing and resolution events of alert reports, oldest
+// first, with the sample entries that made each alert fire. Query parameters: report, since, tz, limit
+func (s *HTTPServer) handleAlertHistory(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	manager, ok := s.logService.(interfaces.ReportManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Scheduled reports are not supported")
+		return
+	}
+
+	since, limit, err := parseReportResultsQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	var reportID int64
+	if reportStr := r.URL.Query().Get("report"); reportStr != "" {
+		reportID, err = strconv.ParseInt(reportStr, 10, 64)
+		if err != nil || reportID <= 0 {
+			s.sendErrorResponse(w, http.StatusBadRequest, "Invalid query parameters: invalid report ID")
+			return
+		}
+	}
+
+	events, err := manager.AlertHistory(reportID, since, limit)
+	if err != nil {
+		log.Printf("Error reading alert history: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read alert history")
+		return
+	}
+
+	if rd := s.redactorFor(r); rd != nil {
+		for i := range events {
+			events[i].Samples = rd.entries(events[i].Samples)
+		}
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
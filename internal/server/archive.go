@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// ArchiveSearcher searches the archived dumps of entries past retention
+type ArchiveSearcher interface {
+	Search(ctx context.Context, query types.SearchQuery) (*types.ArchiveResult, error)
+}
+
+// SetArchive lets searches reaching back past retention read archived entries
+func (s *HTTPServer) SetArchive(archive ArchiveSearcher) {
+	s.archive = archive
+}
+
+// splitArchiveQuery decides whether a search reads the archive: when asked to with archive=true,
+// or with -archive-auto when its time range starts before the retention cutoff. The range is then
+// split at the cutoff: query keeps the part after it, searched in the database, and the part before
+// it is returned, to be searched in the archive. ok is false when an error response was sent.
+func (s *HTTPServer) splitArchiveQuery(w http.ResponseWriter, r *http.Request, query *types.SearchQuery) (archived *types.SearchQuery, ok bool) {
+	explicit := false
+	switch r.URL.Query().Get("archive") {
+	case "":
+		if s.archive == nil || !s.config.ArchiveAuto || query.StartTime == nil || query.Collapse {
+			return nil, true
+		}
+	case "true":
+		explicit = true
+	case "false":
+		return nil, true
+	default:
+		s.sendErrorResponse(w, http.StatusBadRequest, "invalid archive value, must be true or false")
+		return nil, false
+	}
+	if explicit {
+		switch {
+		case s.archive == nil:
+			s.sendErrorResponse(w, http.StatusNotImplemented, "No archive is configured")
+			return nil, false
+		case query.StartTime == nil:
+			s.sendErrorResponse(w, http.StatusBadRequest, "archive searches need a start_time")
+			return nil, false
+		case query.Collapse:
+			s.sendErrorResponse(w, http.StatusBadRequest, "archive searches cannot collapse repeated entries")
+			return nil, false
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.retentionDays())
+	if !query.StartTime.Before(cutoff) {
+		return nil, true
+	}
+	archivedQuery := *query
+	if end := cutoff.Add(-time.Nanosecond); query.EndTime == nil || query.EndTime.After(end) {
+		archivedQuery.EndTime = &end
+	}
+	query.StartTime = &cutoff
+	return &archivedQuery, true
+}
+
+// completeFromArchive fills the rest of a page of search results with archived entries, which are
+// all older than those in the database and so follow them. ok is false when an error response was
+// sent.
+func (s *HTTPServer) completeFromArchive(w http.ResponseWriter, r *http.Request, query, archived types.SearchQuery, logs []*types.LogEntry) ([]*types.LogEntry, bool) {
+	if len(logs) >= query.Limit {
+		return logs, true
+	}
+
+	// The archived results of a page start after all matches in the database
+	matched := query.Offset + len(logs)
+	if len(logs) == 0 && query.Offset > 0 {
+		count := query
+		count.Offset, count.Limit, count.Fields = 0, query.Offset, []string{"id"}
+		entries, err := s.logService.Search(count)
+		if s.sendBusy(w, err) {
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("Error searching logs: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search logs")
+			return nil, false
+		}
+		matched = len(entries)
+	}
+	archived.Offset = query.Offset + len(logs) - matched
+	archived.Limit = query.Limit - len(logs)
+
+	result, err := s.archive.Search(r.Context(), archived)
+	if errors.Is(err, interfaces.ErrArchiveRangeTooLarge) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error searching the archive: %v", err)
+		s.sendErrorResponse(w, http.StatusBadGateway, "Failed to search the archive")
+		return nil, false
+	}
+	w.Header().Set("X-Archive-Partitions", strconv.Itoa(result.Partitions))
+	w.Header().Set("Server-Timing", fmt.Sprintf("archive;dur=%.1f", float64(result.Elapsed.Microseconds())/1000))
+	return append(logs, result.Entries...), true
+}
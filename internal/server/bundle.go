@@ -0,0 +1,321 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxBundleEntries is the number of entries a bundle holds unless limit says fewer
+	maxBundleEntries = 1000
+	// maxBundleAlerts bounds the alert events in a bundle
+	maxBundleAlerts = 1000
+	// maxBundleEvents bounds the event markers in a bundle
+	maxBundleEvents = 1000
+	// defaultBundleTitle heads the report of a bundle unless title names the incident
+	defaultBundleTitle = "Incident bundle"
+)
+
+//go:embed bundle.html
+var bundlePage string
+
+// bundleSeverityNames are the severity keywords shown in bundle reports
+var bundleSeverityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// bundleReport is what the HTML report of a bundle shows
+type bundleReport struct {
+	Title       string
+	GeneratedAt time.Time
+	GeneratedBy string
+	StartTime   time.Time
+	EndTime     time.Time
+	Query       string
+	Entries     []*types.LogEntry
+	// Truncated tells whether more entries matched than the bundle holds
+	Truncated bool
+	Histogram *types.Histogram
+	Bars      []bundleBar
+	Alerts    []types.AlertEvent
+	Events    []types.Event
+}
+
+// bundleBar is one interval of the volume chart of a report, its height relative to the highest
+type bundleBar struct {
+	Height float64
+	Label  string
+}
+
+// bundleEntryAnnotations are the source address and pipeline annotations of a bundled entry
+type bundleEntryAnnotations struct {
+	ID          int64             `json:"id"`
+	SourceIP    string            `json:"source_ip,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// handleBundle exports an incident bundle for attaching to postmortems: a zip holding the entries
+// matching a search as NDJSON (logs.ndjson), the query (query.json), the search's histogram
+// (histogram.json), the alert events (alerts.json) and the event markers and entry annotations
+// (annotations.json) of its time range, and an HTML report of all of them (report.html). It accepts
+// the search parameters of /api/logs and the range, interval, group_by and tz parameters of
+// /api/logs/histogram; the range defaults to the last 24 hours, limit to 1000 entries, and title
+// names the incident in the report. Histograms, alerts and events are left out where the server
+// does not keep them.
+func (s *HTTPServer) handleBundle(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	now := time.Now()
+	histogramQuery, err := s.parseSearchHistogramQuery(r, now)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	if histogramQuery.GroupBy == "" {
+		histogramQuery.GroupBy = types.GroupBySeverity
+	}
+	query, err := s.parseSearchQuery(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	query.StartTime, query.EndTime = &histogramQuery.StartTime, &histogramQuery.EndTime
+	query.Fields = nil
+	query.Collapse = false
+	if r.URL.Query().Get("limit") == "" {
+		query.Limit = maxBundleEntries
+	}
+
+	redact := s.redactorFor(r)
+	if err := redact.checkQuery(query); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	report := bundleReport{
+		Title:       r.URL.Query().Get("title"),
+		GeneratedAt: now,
+		GeneratedBy: s.requestUser(r),
+		StartTime:   histogramQuery.StartTime,
+		EndTime:     histogramQuery.EndTime,
+		Query:       r.URL.RawQuery,
+	}
+	if report.Title == "" {
+		report.Title = defaultBundleTitle
+	}
+
+	entries, err := s.logService.Search(query)
+	if s.sendBusy(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error searching logs for bundle: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search logs")
+		return
+	}
+	report.Entries = redact.entries(entries)
+	report.Truncated = len(entries) == query.Limit
+
+	if provider, ok := s.logService.(interfaces.HistogramProvider); ok {
+		histogram, err := provider.Histogram(histogramQuery)
+		if s.sendBusy(w, err) {
+			return
+		}
+		if err != nil {
+			log.Printf("Error building histogram for bundle: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to build histogram")
+			return
+		}
+		redact.events(histogram.Events)
+		report.Histogram = histogram
+		report.Bars = bundleBars(histogram, histogramQuery.Location)
+	}
+
+	if manager, ok := s.logService.(interfaces.ReportManager); ok {
+		alerts, err := manager.AlertHistory(0, report.StartTime, maxBundleAlerts)
+		if err != nil {
+			log.Printf("Error reading alert history for bundle: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read alert history")
+			return
+		}
+		report.Alerts = []types.AlertEvent{}
+		for _, alert := range alerts {
+			if alert.Time.Before(report.EndTime) {
+				alert.Samples = redact.entries(alert.Samples)
+				report.Alerts = append(report.Alerts, alert)
+			}
+		}
+	}
+
+	if recorder, ok := s.logService.(interfaces.EventRecorder); ok {
+		events, err := recorder.Events(types.EventQuery{StartTime: report.StartTime, EndTime: report.EndTime, Limit: maxBundleEvents})
+		if err != nil {
+			log.Printf("Error listing events for bundle: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list events")
+			return
+		}
+		redact.events(events)
+		report.Events = events
+	}
+
+	bundle, err := writeBundle(report, query, histogramQuery.Location)
+	if err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to write bundle")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="opentrail-incident-%s.zip"`, now.UTC().Format("20060102T150405Z")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(bundle)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(bundle)
+}
+
+// bundleBars sums the groups of each histogram interval into the bars of the volume chart
+func bundleBars(histogram *types.Histogram, loc *time.Location) []bundleBar {
+	var times []time.Time
+	totals := make(map[time.Time]int64)
+	var highest int64
+	for _, bucket := range histogram.Buckets {
+		if _, seen := totals[bucket.Time]; !seen {
+			times = append(times, bucket.Time)
+		}
+		totals[bucket.Time] += bucket.Count
+		highest = max(highest, totals[bucket.Time])
+	}
+
+	bars := make([]bundleBar, len(times))
+	for i, start := range times {
+		bars[i].Label = fmt.Sprintf("%s: %d", bundleTime(start, loc), totals[start])
+		if highest > 0 {
+			bars[i].Height = float64(totals[start]) * 100 / float64(highest)
+		}
+	}
+	return bars
+}
+
+// bundleTime formats a time in a report, in the zone of the request
+func bundleTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// bundleFile is a file of a bundle and how its content is written
+type bundleFile struct {
+	name  string
+	write func(w *bytes.Buffer) error
+}
+
+// writeBundle writes the files of a bundle into a zip
+func writeBundle(report bundleReport, query types.SearchQuery, loc *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	add := func(file bundleFile) error {
+		var content bytes.Buffer
+		if err := file.write(&content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		zipped, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: report.GeneratedAt})
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.name, err)
+		}
+		_, err = zipped.Write(content.Bytes())
+		return err
+	}
+	writeJSON := func(value interface{}) func(w *bytes.Buffer) error {
+		return func(w *bytes.Buffer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(value)
+		}
+	}
+
+	files := []bundleFile{
+		{"logs.ndjson", func(w *bytes.Buffer) error {
+			encoder := json.NewEncoder(w)
+			for _, entry := range report.Entries {
+				if err := encoder.Encode(entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"query.json", writeJSON(map[string]interface{}{
+			"title":        report.Title,
+			"query":        report.Query,
+			"search":       query,
+			"start_time":   report.StartTime,
+			"end_time":     report.EndTime,
+			"entries":      len(report.Entries),
+			"truncated":    report.Truncated,
+			"generated_at": report.GeneratedAt,
+			"generated_by": report.GeneratedBy,
+		})},
+		{"report.html", func(w *bytes.Buffer) error { return renderBundleReport(w, report, loc) }},
+	}
+	if report.Histogram != nil {
+		files = append(files, bundleFile{"histogram.json", writeJSON(report.Histogram)})
+	}
+	if report.Alerts != nil {
+		files = append(files, bundleFile{"alerts.json", writeJSON(report.Alerts)})
+	}
+
+	annotations := []bundleEntryAnnotations{}
+	for _, entry := range report.Entries {
+		if sourceIP, notes := entryMetadata(entry); sourceIP != "" || len(notes) > 0 {
+			annotations = append(annotations, bundleEntryAnnotations{ID: entry.ID, SourceIP: sourceIP, Annotations: notes})
+		}
+	}
+	events := report.Events
+	if events == nil {
+		events = []types.Event{}
+	}
+	files = append(files, bundleFile{"annotations.json", writeJSON(map[string]interface{}{"events": events, "entries": annotations})})
+
+	for _, file := range files {
+		if err := add(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderBundleReport renders the HTML report of a bundle; html/template escapes every value taken
+// from entries
+func renderBundleReport(w *bytes.Buffer, report bundleReport, loc *time.Location) error {
+	page, err := template.New("bundle").Funcs(template.FuncMap{
+		"when": func(t time.Time) string { return bundleTime(t, loc) },
+		"severity": func(severity int) string {
+			if severity < 0 || severity >= len(bundleSeverityNames) {
+				return strconv.Itoa(severity)
+			}
+			return bundleSeverityNames[severity]
+		},
+	}).Parse(bundlePage)
+	if err != nil {
+		return err
+	}
+	return page.Execute(w, report)
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// bundleService finds two entries, counts them per severity and keeps an alert and an event marker
+type bundleService struct {
+	reportService
+	search    types.SearchQuery
+	histogram types.HistogramQuery
+	events    types.EventQuery
+}
+
+func (m *bundleService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.search = query
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []*types.LogEntry{
+		{ID: 2, Severity: 3, Timestamp: base.Add(time.Minute), Hostname: "web-1", AppName: "api", Message: "token s3cret rejected <b>",
+			StructuredData: map[string]interface{}{types.MetadataSDID: map[string]interface{}{types.SourceIPParam: "10.0.0.5", "pipeline": "edge"}}},
+		{ID: 1, Severity: 6, Timestamp: base, Hostname: "web-1", AppName: "api", Message: "request served"},
+	}, nil
+}
+
+func (m *bundleService) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	m.histogram = query
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &types.Histogram{Interval: query.Interval.String(), Total: 2, Buckets: []types.HistogramBucket{
+		{Time: base, Group: "3", Count: 1}, {Time: base, Group: "6", Count: 1}, {Time: base.Add(time.Hour), Group: "6", Count: 1},
+	}}, nil
+}
+
+func (m *bundleService) RecordEvent(event *types.Event) error {
+	return nil
+}
+
+func (m *bundleService) Events(query types.EventQuery) ([]types.Event, error) {
+	m.events = query
+	return []types.Event{{ID: 1, Type: types.EventDeploy, Service: "api", Version: "1.4.0", Description: "rotated s3cret"}}, nil
+}
+
+func (m *bundleService) DeleteEvent(id int64) error {
+	return nil
+}
+
+// readBundle returns the files of a bundle by name
+func readBundle(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Failed to open bundle: %v", err)
+	}
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name, err)
+		}
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestHTTPServer_Bundle(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `s3cret`,
+	}
+	service := &bundleService{}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(target, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("/api/logs/bundle?app_name=api&start_time=2024-05-01T00:00:00Z&end_time=2024-05-02T00:00:00Z&title=Checkout+outage", "admin", "password")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" || !strings.Contains(w.Header().Get("Content-Disposition"), "opentrail-incident-") {
+		t.Errorf("Expected a zip attachment, got %v", w.Header())
+	}
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if service.search.AppName != "api" || service.search.Limit != maxBundleEntries || !service.search.StartTime.Equal(start) ||
+		!service.search.EndTime.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("Expected the search over the range of the bundle, got %+v", service.search)
+	}
+	if service.histogram.GroupBy != types.GroupBySeverity || service.histogram.Search == nil || service.histogram.Search.AppName != "api" {
+		t.Errorf("Expected the search counted per severity, got %+v", service.histogram)
+	}
+	if !service.events.StartTime.Equal(start) || service.historyReport != 0 {
+		t.Errorf("Expected the events and alerts of the range, got %+v", service.events)
+	}
+
+	files := readBundle(t, w.Body.Bytes())
+	for _, name := range []string{"logs.ndjson", "query.json", "histogram.json", "alerts.json", "annotations.json", "report.html"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in the bundle, got %d files", name, len(files))
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(files["logs.ndjson"]), "\n")
+	var first types.LogEntry
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.ID != 2 {
+		t.Errorf("Expected one entry per line, got %q", files["logs.ndjson"])
+	}
+	var query map[string]interface{}
+	if err := json.Unmarshal([]byte(files["query.json"]), &query); err != nil || query["title"] != "Checkout outage" ||
+		!strings.Contains(query["query"].(string), "app_name=api") || query["generated_by"] != "admin" {
+		t.Errorf("Unexpected query.json %s", files["query.json"])
+	}
+	var annotations struct {
+		Events  []types.Event            `json:"events"`
+		Entries []bundleEntryAnnotations `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(files["annotations.json"]), &annotations); err != nil || len(annotations.Events) != 1 ||
+		len(annotations.Entries) != 1 || annotations.Entries[0].SourceIP != "10.0.0.5" || annotations.Entries[0].Annotations["pipeline"] != "edge" {
+		t.Errorf("Unexpected annotations.json %s", files["annotations.json"])
+	}
+
+	report := files["report.html"]
+	for _, want := range []string{"<title>Checkout outage</title>", "token s3cret rejected &lt;b&gt;", ">err<", "1.4.0", "errors", `style="height: 100%"`, `style="height: 50%"`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected %q in the report", want)
+		}
+	}
+
+	// Readers get the bundle masked like search results
+	w = request("/api/logs/bundle", "reader", "readonly")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	for name, content := range readBundle(t, w.Body.Bytes()) {
+		if strings.Contains(content, "s3cret") {
+			t.Errorf("Expected %s redacted for readers", name)
+		}
+	}
+
+	// Parts the service does not keep are left out
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleBundle(w, httptest.NewRequest(http.MethodGet, "/api/logs/bundle", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	files = readBundle(t, w.Body.Bytes())
+	if _, ok := files["histogram.json"]; ok || len(files) != 4 || !strings.Contains(files["report.html"], "No entries matched") {
+		t.Errorf("Expected only the entries, query, annotations and report, got %d files", len(files))
+	}
+
+	for target, status := range map[string]int{
+		"/api/logs/bundle?start_time=yesterday": http.StatusBadRequest,
+		"/api/logs/bundle?limit=5000":           http.StatusBadRequest,
+	} {
+		w = httptest.NewRecorder()
+		server.handleBundle(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, target, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	server.handleBundle(w, httptest.NewRequest(http.MethodPost, "/api/logs/bundle", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
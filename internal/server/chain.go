@@ -0,0 +1,49 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// handleVerifyChain recomputes the hash chains of stored entries so auditors can check that nothing
+// was altered after ingestion. Query parameters: partition (YYYY-MM-DD, all partitions when omitted)
+func (s *HTTPServer) handleVerifyChain(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	partition := r.URL.Query().Get("partition")
+	if partition != "" {
+		if _, err := time.Parse(types.ChainPartitionLayout, partition); err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, "Invalid partition, expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	verifier, ok := s.logService.(interfaces.ChainVerifier)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Hash chains are not supported")
+		return
+	}
+
+	report, err := verifier.VerifyChain(partition)
+	if err != nil {
+		log.Printf("Error verifying hash chain: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to verify hash chain")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
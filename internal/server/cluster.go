@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+
+	"opentrail/internal/types"
+)
+
+// ClusterStream merges the live streams of the nodes of a cluster
+type ClusterStream interface {
+	Subscribe() <-chan *types.LogEntry
+	Unsubscribe(subscription <-chan *types.LogEntry)
+	Status() types.ClusterStatus
+}
+
+// liveSource is a stream live tail clients subscribe to: the entries of this node, or those of
+// the whole cluster
+type liveSource interface {
+	Subscribe() <-chan *types.LogEntry
+	Unsubscribe(subscription <-chan *types.LogEntry)
+}
+
+// SetClusterStream makes live tail clients receive the entries of every node of a cluster
+func (s *HTTPServer) SetClusterStream(stream ClusterStream) {
+	s.cluster = stream
+}
+
+// liveSourceFor returns the stream a live tail request asks for with its scope parameter: cluster
+// (the default) or local, which peers following this node use so that entries they forwarded are
+// not sent back
+func (s *HTTPServer) liveSourceFor(r *http.Request) (liveSource, bool) {
+	switch r.URL.Query().Get("scope") {
+	case "", "cluster":
+		if s.cluster != nil {
+			return s.cluster, true
+		}
+		return s.logService, true
+	case "local":
+		return s.logService, true
+	}
+	return nil, false
+}
+
+// handleClusterStatus reports the live stream followed from every node of the cluster
+func (s *HTTPServer) handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.cluster == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "No cluster peers are configured")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.cluster.Status(),
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// defaultComparePatterns is the number of patterns returned when limit is omitted
+	defaultComparePatterns = 50
+	// maxComparePatterns bounds the number of patterns returned
+	maxComparePatterns = 500
+	// defaultBaselineOffset compares to the same time the day before
+	defaultBaselineOffset = 24 * time.Hour
+)
+
+// handleCompare runs a search over the current range (start_time to end_time, the last 24 hours by
+// default) and a baseline range and returns how the entry counts per severity and message pattern
+// changed. The baseline is the current range shifted back by baseline_offset (1d by default), or
+// baseline_start to baseline_end, e.g. the hour before a deploy. It accepts the search filters of
+// /api/logs; limit is the number of patterns returned.
+func (s *HTTPServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	comparer, ok := s.logService.(interfaces.SearchComparer)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Search comparison is not supported")
+		return
+	}
+
+	query, err := s.parseCompareQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	redact := s.redactorFor(r)
+	if err := redact.checkQuery(query.Search); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	result, err := comparer.Compare(query)
+	if s.sendBusy(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error comparing searches: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to compare searches")
+		return
+	}
+	redact.comparison(result)
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// parseCompareQuery parses the search filters and the two time ranges of a comparison
+func (s *HTTPServer) parseCompareQuery(r *http.Request, now time.Time) (types.CompareQuery, error) {
+	params := r.URL.Query()
+	query := types.CompareQuery{Limit: defaultComparePatterns}
+
+	// The search's own limit is not used, so the pattern limit gets its own bounds
+	searchParams := cloneWithout(params, "limit")
+	searchRequest := r.Clone(r.Context())
+	searchRequest.URL.RawQuery = searchParams.Encode()
+	search, err := s.parseSearchQuery(searchRequest)
+	if err != nil {
+		return query, err
+	}
+	search.StartTime, search.EndTime = nil, nil
+	search.Limit, search.Offset = 0, 0
+	search.Fields = nil
+	search.Collapse = false
+	query.Search = search
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+	query.CurrentStart, query.CurrentEnd, err = parseStatsRange(params, now, loc)
+	if err != nil {
+		return query, err
+	}
+
+	baselineStart, baselineEnd := params.Get("baseline_start"), params.Get("baseline_end")
+	switch {
+	case baselineStart != "" || baselineEnd != "":
+		if baselineStart == "" || baselineEnd == "" {
+			return query, fmt.Errorf("baseline_start and baseline_end must be given together")
+		}
+		if params.Get("baseline_offset") != "" {
+			return query, fmt.Errorf("baseline_offset cannot be combined with baseline_start and baseline_end")
+		}
+		if query.BaselineStart, err = parseTimeParam("baseline_start", baselineStart, now, loc); err != nil {
+			return query, err
+		}
+		if query.BaselineEnd, err = parseTimeParam("baseline_end", baselineEnd, now, loc); err != nil {
+			return query, err
+		}
+		if !query.BaselineStart.Before(query.BaselineEnd) {
+			return query, fmt.Errorf("baseline_start must be before baseline_end")
+		}
+	default:
+		offset := defaultBaselineOffset
+		if value := params.Get("baseline_offset"); value != "" {
+			offset, err = parseRelativeOffset("+" + value)
+			if err != nil || offset <= 0 {
+				return query, fmt.Errorf("invalid baseline_offset, expected a positive duration such as 1d or 7d")
+			}
+		}
+		query.BaselineStart = query.CurrentStart.Add(-offset)
+		query.BaselineEnd = query.CurrentEnd.Add(-offset)
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxComparePatterns {
+			return query, fmt.Errorf("invalid limit, must be between 1 and %d", maxComparePatterns)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// cloneWithout copies query parameters, leaving out the given ones
+func cloneWithout(params url.Values, names ...string) url.Values {
+	cloned := make(url.Values, len(params))
+	for key, values := range params {
+		cloned[key] = values
+	}
+	for _, name := range names {
+		delete(cloned, name)
+	}
+	return cloned
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConnectionAdmin lists and closes the open connections of an ingestion listener
+type ConnectionAdmin interface {
+	Connections() []ConnectionInfo
+	CloseConnection(id int64) bool
+}
+
+// SetConnectionAdmin makes the open ingestion connections available to the admin API
+func (s *HTTPServer) SetConnectionAdmin(admin ConnectionAdmin) {
+	s.connections = admin
+}
+
+// handleConnections lists the open ingestion connections with their statistics
+func (s *HTTPServer) handleConnections(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.connections == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Connection tracking is not available")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    s.connections.Connections(),
+	})
+}
+
+// handleCloseConnection serves DELETE /api/admin/connections/{id}, forcibly closing a connection
+// such as a misbehaving sender's
+func (s *HTTPServer) handleCloseConnection(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/connections/"), 10, 64)
+	if err != nil || id <= 0 {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.connections == nil {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Connection tracking is not available")
+		return
+	}
+	if !s.connections.CloseConnection(id) {
+		s.sendErrorResponse(w, http.StatusNotFound, "Connection not found")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]int64{"id": id},
+	})
+}
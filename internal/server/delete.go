@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/lifecycle"
+	"opentrail/internal/types"
+)
+
+// deleteTokenTTL is how long a dry run's confirmation token can be used to delete
+const deleteTokenTTL = 10 * time.Minute
+
+// deleteResponse is the body of DELETE /api/admin/logs
+type deleteResponse struct {
+	// Matched is the number of entries a dry run would delete
+	Matched *int64 `json:"matched,omitempty"`
+	// Deleted is the number of entries deleted
+	Deleted *int64 `json:"deleted,omitempty"`
+	// ConfirmToken, returned by a dry run, must be passed as confirm to delete the same entries
+	ConfirmToken string     `json:"confirm_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// handleDeleteLogs deletes the entries matching the search filter parameters. With dry_run=true it
+// only counts them and returns a confirmation token; deleting requires confirm=<token> from a dry
+// run with the same filters
+func (s *HTTPServer) handleDeleteLogs(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	deleter, ok := s.logService.(interfaces.LogDeleter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Deleting entries is not supported")
+		return
+	}
+
+	query, err := s.parseSearchQuery(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	if !hasPredicate(query) {
+		s.sendErrorResponse(w, http.StatusBadRequest, "At least one filter is required; use retention to delete everything")
+		return
+	}
+
+	dryRun := false
+	if dryRunStr := r.URL.Query().Get("dry_run"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, "Invalid dry_run value, must be true or false")
+			return
+		}
+	}
+
+	filters := deleteFilters(r)
+	if dryRun {
+		matched, err := deleter.DeleteMatching(query, true)
+		if err != nil {
+			log.Printf("Error counting entries to delete: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to count matching entries")
+			return
+		}
+		expiresAt := time.Now().Add(deleteTokenTTL).UTC().Truncate(time.Second)
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data: deleteResponse{
+				Matched:      &matched,
+				ConfirmToken: s.deleteToken(filters, expiresAt),
+				ExpiresAt:    &expiresAt,
+			},
+		})
+		return
+	}
+
+	confirm := r.URL.Query().Get("confirm")
+	if confirm == "" {
+		s.sendErrorResponse(w, http.StatusBadRequest, "A confirm token from a dry run is required")
+		return
+	}
+	if !s.validDeleteToken(confirm, filters, time.Now()) {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired confirm token for these filters; run a dry run again")
+		return
+	}
+
+	deleted, err := deleter.DeleteMatching(query, false)
+	username := s.requestUser(r)
+	if err != nil {
+		log.Printf("Error deleting entries matching %s (user %q, %d deleted): %v", filters, username, deleted, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete matching entries")
+		return
+	}
+	s.lifecycle.Emit(lifecycle.Event{
+		Type:    lifecycle.LogsDeleted,
+		Entries: deleted,
+		Details: map[string]string{"filters": filters, "user": username},
+	})
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    deleteResponse{Deleted: &deleted},
+	})
+}
+
+// hasPredicate reports whether a search query restricts the entries it matches
+func hasPredicate(query types.SearchQuery) bool {
+	return query.Text != "" || query.Facility != nil || query.Severity != nil || query.MinSeverity != nil ||
+		query.MaxSeverity != nil || query.Hostname != "" || query.AppName != "" || query.ProcID != "" ||
+		query.MsgID != "" || query.SourceIP != "" || query.StructuredDataQuery != "" || len(query.Filters) > 0 ||
+		query.StartTime != nil || query.EndTime != nil
+}
+
+// deleteFilters returns the request's filter parameters in a canonical form, leaving out the ones
+// that do not affect which entries are deleted
+func deleteFilters(r *http.Request) string {
+	params := r.URL.Query()
+	for _, name := range []string{"confirm", "dry_run", "limit", "offset", "fields", "collapse"} {
+		params.Del(name)
+	}
+	return params.Encode()
+}
+
+// deleteToken signs the filters and expiry of a dry run
+func (s *HTTPServer) deleteToken(filters string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, s.deleteSecret)
+	mac.Write([]byte(expiry + "\n" + filters))
+	return expiry + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validDeleteToken reports whether token was issued for the same filters and has not expired
+func (s *HTTPServer) validDeleteToken(token, filters string, now time.Time) bool {
+	expiry, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(s.deleteToken(filters, time.Unix(unix, 0))))
+}
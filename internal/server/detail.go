@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// handleLogEntry serves the routes below /api/logs/: an entry's detail at /api/logs/{id} and its
+// raw message at /api/logs/{id}/raw
+func (s *HTTPServer) handleLogEntry(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/raw") {
+		s.handleRawMessage(w, r)
+		return
+	}
+	s.handleEntryDetail(w, r)
+}
+
+// handleEntryDetail returns an entry with its raw message, source address, pipeline annotations
+// and hash chain link
+func (s *HTTPServer) handleEntryDetail(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/logs/"), 10, 64)
+	if err != nil || id < 1 {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	reader, ok := s.logService.(interfaces.EntryDetailReader)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Entry details are not supported")
+		return
+	}
+
+	detail, err := reader.EntryDetail(id)
+	if errors.Is(err, interfaces.ErrEntryNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, "Log entry not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading entry %d: %v", id, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read log entry")
+		return
+	}
+
+	response := *detail
+	// Raw messages cannot be redacted reliably, so readers only see them when nothing is redacted
+	redact := s.redactorFor(r)
+	response.Entry = redact.entry(detail.Entry)
+	if redact.restricts() {
+		response.Raw = ""
+	}
+	response.SourceIP, response.Annotations = entryMetadata(response.Entry)
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// entryMetadata splits the metadata element of an entry's structured data into the source address
+// and the remaining annotations
+func entryMetadata(entry *types.LogEntry) (string, map[string]string) {
+	var params map[string]string
+	switch element := entry.StructuredData[types.MetadataSDID].(type) {
+	case map[string]interface{}:
+		params = make(map[string]string, len(element))
+		for name, value := range element {
+			params[name] = fmt.Sprint(value)
+		}
+	case map[string]string:
+		params = make(map[string]string, len(element))
+		for name, value := range element {
+			params[name] = value
+		}
+	default:
+		return "", nil
+	}
+
+	sourceIP := params[types.SourceIPParam]
+	delete(params, types.SourceIPParam)
+	if len(params) == 0 {
+		params = nil
+	}
+	return sourceIP, params
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"opentrail/internal/interfaces"
+)
+
+// handleReencrypt reports the re-encryption job status (GET) or starts encrypting the configured
+// fields of every stored entry with the current keys (POST), after a key rotation
+func (s *HTTPServer) handleReencrypt(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	manager, ok := s.logService.(interfaces.ReencryptManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Re-encryption is not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    manager.ReencryptStatus(),
+		})
+		return
+	}
+
+	status, err := manager.StartReencrypt()
+	if errors.Is(err, interfaces.ErrReencryptRunning) {
+		s.sendErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, interfaces.ErrEncryptionDisabled) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting re-encryption: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to start re-encryption")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"opentrail/internal/interfaces"
+)
+
+// handleEntrySizes reports the size of the messages and structured data of entries per app (GET),
+// or forgets the per-app sizes (DELETE)
+func (s *HTTPServer) handleEntrySizes(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reporter, ok := s.logService.(interfaces.EntrySizeReporter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Entry sizes are not supported")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		reporter.ResetEntrySizes()
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    reporter.EntrySizes(),
+	})
+}
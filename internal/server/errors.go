@@ -0,0 +1,64 @@
+package server
+
+import "net/http"
+
+// Error codes of API error responses, which clients can handle without parsing the message
+const (
+	ErrCodeInvalidQuery       = "invalid_query"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeMethodNotAllowed   = "method_not_allowed"
+	ErrCodeConflict           = "conflict"
+	ErrCodePayloadTooLarge    = "payload_too_large"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeStorageUnavailable = "storage_unavailable"
+	ErrCodeNotImplemented     = "not_implemented"
+	ErrCodeUpstreamFailed     = "upstream_failed"
+	ErrCodeQueueFull          = "queue_full"
+	ErrCodeStarting           = "starting"
+	ErrCodeSetupRequired      = "setup_required"
+	ErrCodeUnavailable        = "unavailable"
+)
+
+// ErrorDocsURL documents the error codes and is linked from every error response
+const ErrorDocsURL = "https://github.com/mysty0/opentrail/blob/main/internal/config/README.md#error-codes"
+
+// APIError describes why a request failed
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	DocURL  string `json:"doc_url,omitempty"`
+}
+
+// newAPIError returns an error with a code and a link to the documentation of the codes
+func newAPIError(code, message string) *APIError {
+	return &APIError{Code: code, Message: message, DocURL: ErrorDocsURL}
+}
+
+// statusErrorCodes are the codes of errors sent without one of their own
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            ErrCodeInvalidQuery,
+	http.StatusUnauthorized:          ErrCodeUnauthorized,
+	http.StatusForbidden:             ErrCodeForbidden,
+	http.StatusNotFound:              ErrCodeNotFound,
+	http.StatusMethodNotAllowed:      ErrCodeMethodNotAllowed,
+	http.StatusConflict:              ErrCodeConflict,
+	http.StatusRequestEntityTooLarge: ErrCodePayloadTooLarge,
+	http.StatusTooManyRequests:       ErrCodeRateLimited,
+	http.StatusInternalServerError:   ErrCodeStorageUnavailable,
+	http.StatusNotImplemented:        ErrCodeNotImplemented,
+	http.StatusBadGateway:            ErrCodeUpstreamFailed,
+	http.StatusServiceUnavailable:    ErrCodeUnavailable,
+}
+
+// errorCode returns the code of an error sent with a status code
+func errorCode(statusCode int) string {
+	if code, ok := statusErrorCodes[statusCode]; ok {
+		return code
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return ErrCodeUnavailable
+	}
+	return ErrCodeInvalidQuery
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxEventRequestSize bounds the body of an event creation request
+	maxEventRequestSize = 16384
+	// defaultEventLimit is the number of events returned when limit is omitted
+	defaultEventLimit = 1000
+	// maxEventLimit bounds the number of events returned at once
+	maxEventLimit = 10000
+)
+
+// handleEvents lists deploy and other event markers (GET) or records one (POST, admin role) from a
+// body like {"service": "api", "version": "1.4.0", "time": "2024-05-01T12:00:00Z"}, as posted by
+// CI/CD systems. Type defaults to "deploy" and time to now. Query parameters for listing:
+// start_time, end_time (the last 24 hours by default), tz, service, type, limit
+func (s *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	recorder, ok := s.logService.(interfaces.EventRecorder)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Events are not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		query, err := parseEventQuery(r, time.Now())
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+			return
+		}
+		events, err := recorder.Events(query)
+		if err != nil {
+			log.Printf("Error listing events: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list events")
+			return
+		}
+		s.redactorFor(r).events(events)
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    events,
+		})
+		return
+	}
+
+	if requestRole(r) != roleAdmin {
+		s.sendErrorResponse(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	var event types.Event
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventRequestSize)).Decode(&event); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	err := recorder.RecordEvent(&event)
+	if errors.Is(err, interfaces.ErrInvalidEvent) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording %s event of %q: %v", event.Type, event.Service, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to record event")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    event,
+	})
+}
+
+// handleEvent serves DELETE /api/events/{id} (admin role), removing a marker posted by mistake
+func (s *HTTPServer) handleEvent(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/events/"), 10, 64)
+	if err != nil || id <= 0 {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	recorder, ok := s.logService.(interfaces.EventRecorder)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Events are not supported")
+		return
+	}
+	if requestRole(r) != roleAdmin {
+		s.sendErrorResponse(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	err = recorder.DeleteEvent(id)
+	if errors.Is(err, interfaces.ErrEventNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting event %d: %v", id, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete event")
+		return
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]int64{"id": id},
+	})
+}
+
+// parseEventQuery parses HTTP query parameters into an EventQuery
+func parseEventQuery(r *http.Request, now time.Time) (types.EventQuery, error) {
+	params := r.URL.Query()
+	query := types.EventQuery{
+		Service: params.Get("service"),
+		Type:    params.Get("type"),
+		Limit:   defaultEventLimit,
+	}
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+	query.StartTime, query.EndTime, err = parseStatsRange(params, now, loc)
+	if err != nil {
+		return query, err
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxEventLimit {
+			return query, fmt.Errorf("invalid limit, must be between 1 and %d", maxEventLimit)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"opentrail/internal/logformat"
+	"opentrail/internal/parquetlog"
+	"opentrail/internal/siem"
+	"opentrail/internal/types"
+)
+
+// SetOutputFormats makes the configured output format templates available to exports
+func (s *HTTPServer) SetOutputFormats(registry *logformat.Registry) {
+	s.formats = registry
+}
+
+// outputFormats returns the configured output formats, or the built-in ones if none are configured
+func (s *HTTPServer) outputFormats() *logformat.Registry {
+	if s.formats != nil {
+		return s.formats
+	}
+	builtin, _ := logformat.NewRegistry()
+	return builtin
+}
+
+// exportFormatParquet exports a Parquet file instead of lines
+const exportFormatParquet = "parquet"
+
+// handleExport returns the entries matching a search as SIEM events, one per line: CEF lines
+// (format=cef, the default), OCSF Base Event JSON objects (format=ocsf) or lines rendered through
+// an output format template (format=rfc3164, or the name of a configured format). format=parquet
+// returns a Parquet file instead, flattening the most common structured data keys into columns. It
+// accepts the search parameters of /api/logs; fields and collapse do not apply to exports and are
+// ignored.
+func (s *HTTPServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = siem.FormatCEF
+	}
+	encode := func(entry *types.LogEntry) ([]byte, error) { return siem.Encode(entry, format) }
+	contentType := siem.ContentType(format)
+	if !slices.Contains(siem.Formats, format) && format != exportFormatParquet {
+		template, ok := s.outputFormats().Format(format)
+		if !ok {
+			names := append(slices.Clone(siem.Formats), exportFormatParquet)
+			names = append(names, s.outputFormats().Names()...)
+			s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid format %q, expected one of %s", format, strings.Join(names, ", ")))
+			return
+		}
+		encode, contentType = template.Encode, template.ContentType()
+	}
+
+	query, err := s.parseSearchQuery(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	query.Fields = nil
+	query.Collapse = false
+
+	redact := s.redactorFor(r)
+	if err := redact.checkQuery(query); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	logs, err := s.logService.Search(query)
+	if s.sendBusy(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error searching logs for export: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search logs")
+		return
+	}
+
+	if format == exportFormatParquet {
+		s.writeParquetExport(w, redact.entries(logs))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	for _, entry := range redact.entries(logs) {
+		event, err := encode(entry)
+		if err != nil {
+			log.Printf("Error encoding entry %d for export: %v", entry.ID, err)
+			continue
+		}
+		if _, err := w.Write(append(event, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// writeParquetExport writes entries as a Parquet file attachment
+func (s *HTTPServer) writeParquetExport(w http.ResponseWriter, entries []*types.LogEntry) {
+	writer, err := parquetlog.NewWriter(w, parquetlog.CommonKeys(entries, parquetlog.DefaultStructuredColumns))
+	if err != nil {
+		log.Printf("Error creating parquet export: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to export logs")
+		return
+	}
+
+	w.Header().Set("Content-Type", parquetlog.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="opentrail-export.parquet"`)
+	w.WriteHeader(http.StatusOK)
+	for _, entry := range entries {
+		if err := writer.Write(entry); err != nil {
+			log.Printf("Error encoding entry %d for export: %v", entry.ID, err)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("Error finishing parquet export: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// defaultFacetLimit is the number of top values returned per field when limit is omitted
+	defaultFacetLimit = 10
+	// maxFacetLimit bounds the number of top values returned per field
+	maxFacetLimit = 100
+)
+
+// handleFacets returns the most common values and the approximate number of distinct values of the
+// hostname, app_name and msg_id fields, served from the storage facet rollups
+func (s *HTTPServer) handleFacets(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	provider, ok := s.logService.(interfaces.FacetProvider)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Facets are not supported")
+		return
+	}
+
+	query, err := parseFacetQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	result, err := provider.Facets(query)
+	if s.sendBusy(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error computing facets: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to compute facets")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// parseFacetQuery parses HTTP query parameters into a FacetQuery
+func parseFacetQuery(r *http.Request, now time.Time) (types.FacetQuery, error) {
+	params := r.URL.Query()
+	query := types.FacetQuery{Limit: defaultFacetLimit}
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+
+	query.StartTime, query.EndTime, err = parseStatsRange(params, now, loc)
+	if err != nil {
+		return query, err
+	}
+
+	if fields := params.Get("fields"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			switch field {
+			case types.FacetHostname, types.FacetAppName, types.FacetMsgID:
+				query.Fields = append(query.Fields, field)
+			default:
+				return query, fmt.Errorf("invalid field %q, expected hostname, app_name or msg_id", field)
+			}
+		}
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxFacetLimit {
+			return query, fmt.Errorf("invalid limit, must be between 1 and %d", maxFacetLimit)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// defaultCompletionLimit is the number of fields or values returned when limit is omitted
+	defaultCompletionLimit = 20
+	// maxCompletionLimit bounds the number of fields or values returned
+	maxCompletionLimit = 200
+)
+
+// handleFields lists known field names for query autocompletion
+// Query parameters: prefix, limit
+func (s *HTTPServer) handleFields(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	catalog, ok := s.logService.(interfaces.FieldCatalog)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Field catalog is not supported")
+		return
+	}
+
+	limit, err := parseCompletionLimit(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	fields, err := catalog.Fields(r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		log.Printf("Error listing fields: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list fields")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    fields,
+	})
+}
+
+// handleFieldValues lists recent values of a field, served at /api/fields/{name}/values
+// Query parameters: prefix, limit
+func (s *HTTPServer) handleFieldValues(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/fields/"), "/values")
+	if !ok || name == "" {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	catalog, ok := s.logService.(interfaces.FieldCatalog)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Field catalog is not supported")
+		return
+	}
+
+	limit, err := parseCompletionLimit(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	values, err := catalog.FieldValues(types.FieldValuesQuery{
+		Field:  name,
+		Prefix: r.URL.Query().Get("prefix"),
+		Limit:  limit,
+	})
+	if err != nil {
+		log.Printf("Error listing values of field %s: %v", name, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list field values")
+		return
+	}
+
+	// Readers see no values of redacted fields and masked values of the others
+	if redact := s.redactorFor(r); redact != nil {
+		if redact.field(name) {
+			values = []types.FieldValueInfo{}
+		}
+		for i := range values {
+			values[i].Value = redact.text(values[i].Value)
+		}
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    values,
+	})
+}
+
+// parseCompletionLimit parses the limit parameter of the autocompletion endpoints
+func parseCompletionLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultCompletionLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > maxCompletionLimit {
+		return 0, fmt.Errorf("invalid limit, must be between 1 and %d", maxCompletionLimit)
+	}
+	return limit, nil
+}
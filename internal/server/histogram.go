@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// defaultStatsRange is the time range covered when start_time is omitted
+	defaultStatsRange = 24 * time.Hour
+	// maxHistogramBuckets bounds the number of time buckets in a single response
+	maxHistogramBuckets = 10000
+	// targetHistogramBuckets is the bucket count aimed for when no interval is given
+	targetHistogramBuckets = 200
+)
+
+// histogramIntervals are the automatically chosen bucket sizes, smallest first
+var histogramIntervals = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// handleHistogram returns entry counts per time interval, served from the storage rollups
+func (s *HTTPServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	provider, ok := s.logService.(interfaces.HistogramProvider)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Histograms are not supported")
+		return
+	}
+
+	query, err := parseHistogramQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	s.sendHistogram(w, r, provider, query)
+}
+
+// handleSearchHistogram returns the number of entries matching a search per time interval. It
+// accepts the search filters of /api/logs along with the range, interval, group_by and tz parameters
+// of /api/stats/histogram. Entries are counted in the database, so charting a search over a wide
+// range does not fetch its rows.
+func (s *HTTPServer) handleSearchHistogram(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	provider, ok := s.logService.(interfaces.HistogramProvider)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Histograms are not supported")
+		return
+	}
+
+	query, err := s.parseSearchHistogramQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	if err := s.redactorFor(r).checkQuery(*query.Search); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	s.sendHistogram(w, r, provider, query)
+}
+
+// sendHistogram builds a histogram and sends it along with the events in its range
+func (s *HTTPServer) sendHistogram(w http.ResponseWriter, r *http.Request, provider interfaces.HistogramProvider, query types.HistogramQuery) {
+	histogram, err := provider.Histogram(query)
+	if s.sendBusy(w, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error building histogram: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to build histogram")
+		return
+	}
+	s.redactorFor(r).events(histogram.Events)
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    histogram,
+	})
+}
+
+// parseHistogramQuery parses HTTP query parameters into a HistogramQuery
+func parseHistogramQuery(r *http.Request, now time.Time) (types.HistogramQuery, error) {
+	params := r.URL.Query()
+	query := types.HistogramQuery{
+		GroupBy: params.Get("group_by"),
+	}
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+	query.Location = loc
+
+	query.StartTime, query.EndTime, err = parseStatsRange(params, now, loc)
+	if err != nil {
+		return query, err
+	}
+
+	span := query.EndTime.Sub(query.StartTime)
+	if intervalStr := params.Get("interval"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval < time.Minute || interval%time.Minute != 0 {
+			return query, fmt.Errorf("invalid interval, must be a whole number of minutes")
+		}
+		if span/interval > maxHistogramBuckets {
+			return query, fmt.Errorf("interval too small, at most %d buckets are allowed", maxHistogramBuckets)
+		}
+		query.Interval = interval
+	} else {
+		query.Interval = histogramIntervals[len(histogramIntervals)-1]
+		for _, interval := range histogramIntervals {
+			if span/interval <= targetHistogramBuckets {
+				query.Interval = interval
+				break
+			}
+		}
+	}
+
+	switch query.GroupBy {
+	case "", types.GroupBySeverity, types.GroupByAppName, types.GroupByHostname:
+	default:
+		return query, fmt.Errorf("invalid group_by %q, expected severity, app_name or hostname", query.GroupBy)
+	}
+
+	severity, minSeverity, err := parseSeverityParams(params)
+	if err != nil {
+		return query, err
+	}
+	query.Severity = severity
+	query.MinSeverity = minSeverity
+
+	query.AppName = params.Get("app_name")
+	query.Hostname = params.Get("hostname")
+
+	return query, nil
+}
+
+// parseSearchHistogramQuery parses the buckets of a histogram and the search whose entries it counts
+func (s *HTTPServer) parseSearchHistogramQuery(r *http.Request, now time.Time) (types.HistogramQuery, error) {
+	query, err := parseHistogramQuery(r, now)
+	if err != nil {
+		return query, err
+	}
+	// The rollup filters are part of the search
+	query.Severity, query.MinSeverity = nil, nil
+	query.AppName, query.Hostname = "", ""
+
+	// The search's own paging does not apply to counts
+	searchRequest := r.Clone(r.Context())
+	searchRequest.URL.RawQuery = cloneWithout(r.URL.Query(), "limit", "offset", "fields", "collapse").Encode()
+	search, err := s.parseSearchQuery(searchRequest)
+	if err != nil {
+		return query, err
+	}
+	search.StartTime, search.EndTime = nil, nil
+	search.Limit = 0
+	query.Search = &search
+	return query, nil
+}
+
+// parseStatsRange parses the start_time and end_time parameters shared by the statistics endpoints,
+// defaulting to the last 24 hours. Times without an offset are interpreted in loc.
+func parseStatsRange(params url.Values, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	endTime := now
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		parsed, err := parseTimeParam("end_time", endTimeStr, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		endTime = parsed
+	}
+
+	startTime := endTime.Add(-defaultStatsRange)
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		parsed, err := parseTimeParam("start_time", startTimeStr, now, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		startTime = parsed
+	}
+
+	if !startTime.Before(endTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_time must be before end_time")
+	}
+	return startTime, endTime, nil
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -13,11 +16,17 @@ import (
 	"sync"
 	"time"
 
+	"opentrail/internal/agents"
 	"opentrail/internal/interfaces"
+	"opentrail/internal/lifecycle"
+	"opentrail/internal/logformat"
+	"opentrail/internal/notify"
+	"opentrail/internal/querylang"
 	"opentrail/internal/types"
 
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // HTTPServer implements an HTTP server for the web UI and REST API
@@ -25,6 +34,51 @@ type HTTPServer struct {
 	config     *types.Config
 	logService interfaces.LogService
 	server     *http.Server
+	listen     func(network, addr string) (net.Listener, error)
+	proxies    *proxyTrust
+
+	// Obtains the certificates of the HTTPS listener, nil when serving plain HTTP
+	acme *autocert.Manager
+	// Listener and server answering http-01 challenges, nil when that challenge is not used
+	challengeListen func(network, addr string) (net.Listener, error)
+	challengeServer *http.Server
+
+	// Rate and request size limits per endpoint class
+	limits map[endpointClass]endpointLimits
+	// Token buckets per endpoint class, shared by all routes of the class; classes without a rate
+	// limit have none
+	limiters map[endpointClass]*rateLimiter
+
+	// Masks sensitive content for reader-role users, nil when nothing is redacted
+	redactor *redactor
+	// Decrypts encrypted fields for admins, nil when no fields are encrypted
+	decryptor *redactor
+
+	// Configured notification channels, nil when none are
+	notifications *notify.Registry
+
+	// Announces deletions to the lifecycle webhook, nil when only the server log records them
+	lifecycle *lifecycle.Notifier
+
+	// Output format templates for exports, nil when only the built-in ones are available
+	formats *logformat.Registry
+
+	// Open ingestion connections, nil when not tracked
+	connections ConnectionAdmin
+
+	// UDP listener whose stats the health check reports, nil when UDP ingestion is disabled
+	udpServer *UDPServer
+
+	// Registered agents and their managed configuration, nil when agents are not managed
+	agents *agents.Registry
+
+	// Live stream merged from the nodes of a cluster, nil without cluster peers
+	cluster ClusterStream
+	// Archived dumps searched past retention, nil without an archive
+	archive ArchiveSearcher
+
+	// Signs the confirmation tokens of bulk deletion dry runs; tokens do not survive a restart
+	deleteSecret []byte
 
 	// WebSocket upgrader
 	upgrader websocket.Upgrader
@@ -51,6 +105,7 @@ type HTTPServerStats struct {
 	RequestErrors        int64 `json:"request_errors"`
 	WebSocketConnections int64 `json:"websocket_connections"`
 	ActiveWebSockets     int64 `json:"active_websockets"`
+	RateLimited          int64 `json:"rate_limited"`
 	IsRunning            bool  `json:"is_running"`
 }
 
@@ -58,7 +113,9 @@ type HTTPServerStats struct {
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Error   *APIError   `json:"error,omitempty"`
+	// RequestID identifies the failed request in the server's logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // HealthResponse represents the health check response
@@ -72,24 +129,46 @@ type HealthResponse struct {
 // NewHTTPServer creates a new HTTP server instance
 func NewHTTPServer(config *types.Config, logService interfaces.LogService) *HTTPServer {
 	ctx, cancel := context.WithCancel(context.Background())
+	proxies := newProxyTrust(config.TrustedProxies)
 
 	// Configure WebSocket upgrader
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
-			// Allow all origins for now - in production, this should be more restrictive
-			return true
-		},
+		CheckOrigin:     originChecker(config.AllowedOrigins, proxies),
+	}
+
+	// Configure per-class request limits
+	limits := map[endpointClass]endpointLimits{
+		classSearch: {perMinute: config.SearchRateLimit, maxBody: int64(config.SearchMaxBodyBytes)},
+		classIngest: {perMinute: config.IngestRateLimit, maxBody: int64(config.IngestMaxBodyBytes)},
+		classAdmin:  {perMinute: config.AdminRateLimit, maxBody: int64(config.AdminMaxBodyBytes)},
+	}
+	limiters := make(map[endpointClass]*rateLimiter)
+	for class, classLimits := range limits {
+		if classLimits.perMinute > 0 {
+			limiters[class] = newRateLimiter(classLimits.perMinute)
+		}
 	}
 
+	deleteSecret := make([]byte, 32)
+	rand.Read(deleteSecret)
+
 	return &HTTPServer{
-		config:      config,
-		logService:  logService,
-		upgrader:    upgrader,
-		useEmbedded: false,
-		ctx:         ctx,
-		cancel:      cancel,
+		config:          config,
+		logService:      logService,
+		listen:          net.Listen,
+		proxies:         proxies,
+		acme:            newACMEManager(config),
+		challengeListen: net.Listen,
+		limits:          limits,
+		limiters:        limiters,
+		redactor:        newRedactor(config.RedactFields, config.RedactPattern),
+		deleteSecret:    deleteSecret,
+		upgrader:        upgrader,
+		useEmbedded:     false,
+		ctx:             ctx,
+		cancel:          cancel,
 		stats: HTTPServerStats{
 			IsRunning: false,
 		},
@@ -104,6 +183,32 @@ func NewHTTPServerWithStaticFiles(config *types.Config, logService interfaces.Lo
 	return server
 }
 
+// SetNotificationChannels makes the configured notification channels available to the admin API
+func (s *HTTPServer) SetNotificationChannels(registry *notify.Registry) {
+	s.notifications = registry
+}
+
+// SetLifecycleNotifier announces the entries admins delete to the lifecycle webhook
+func (s *HTTPServer) SetLifecycleNotifier(notifier *lifecycle.Notifier) {
+	s.lifecycle = notifier
+}
+
+// SetUDPServer reports the stats of the UDP listener in the health check
+func (s *HTTPServer) SetUDPServer(server *UDPServer) {
+	s.udpServer = server
+}
+
+// SetListenFunc overrides how the server obtains its listener (e.g. to reuse an inherited socket)
+func (s *HTTPServer) SetListenFunc(listen func(network, addr string) (net.Listener, error)) {
+	s.listen = listen
+}
+
+// SetChallengeListenFunc overrides how the server obtains the listener answering ACME http-01
+// challenges
+func (s *HTTPServer) SetChallengeListenFunc(listen func(network, addr string) (net.Listener, error)) {
+	s.challengeListen = listen
+}
+
 // Start starts the HTTP server
 func (s *HTTPServer) Start() error {
 	s.runningMux.Lock()
@@ -117,12 +222,32 @@ func (s *HTTPServer) Start() error {
 	mux := http.NewServeMux()
 	s.setupRoutes(mux)
 
+	// Serve everything below the base path when running behind a shared ingress
+	var handler http.Handler = mux
+	if s.config.HTTPBasePath != "" {
+		handler = withBasePath(s.config.HTTPBasePath, mux)
+	}
+
 	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.config.HTTPPort),
-		Handler:      mux,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              listenAddress(s.config.HTTPBindAddress, s.config.HTTPPort),
+		Handler:           s.withAccessLog(s.withH2CTrust(s.withTimeouts(handler))),
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		IdleTimeout:       s.httpIdleTimeout(),
+		Protocols:         s.httpProtocols(),
+		HTTP2:             s.http2Config(),
+	}
+	if s.acme != nil {
+		s.server.TLSConfig = s.acmeTLSConfig()
+	}
+
+	// Bind before returning so listen errors are reported to the caller
+	listener, err := s.listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+	if err := s.startChallengeServer(); err != nil {
+		listener.Close()
+		return err
 	}
 
 	s.isRunning = true
@@ -137,8 +262,15 @@ func (s *HTTPServer) Start() error {
 	go func() {
 		defer s.wg.Done()
 
-		log.Printf("HTTP server starting on port %d", s.config.HTTPPort)
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.acme != nil {
+			log.Printf("HTTPS server starting on %s for %s", listener.Addr(), strings.Join(s.config.ACMEDomains, ", "))
+			err = s.server.ServeTLS(listener, "", "")
+		} else {
+			log.Printf("HTTP server starting on %s", listener.Addr())
+			err = s.server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Printf("HTTP server error: %v", err)
 		}
 	}()
@@ -162,6 +294,9 @@ func (s *HTTPServer) Stop() error {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	if s.challengeServer != nil {
+		s.challengeServer.Shutdown(shutdownCtx)
+	}
 	if err := s.server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 		return err
@@ -192,8 +327,63 @@ func (s *HTTPServer) GetStats() HTTPServerStats {
 func (s *HTTPServer) setupRoutes(mux *http.ServeMux) {
 	// API routes
 	mux.HandleFunc("/api/health", s.handleHealth)
-	mux.HandleFunc("/api/logs", s.authMiddleware(s.handleLogs))
-	mux.HandleFunc("/api/logs/stream", s.authMiddleware(s.handleLogsStream))
+	mux.HandleFunc("/api/ready", s.handleReady)
+	mux.HandleFunc("/api/logs", s.limitMiddleware(classSearch, s.authMiddleware(s.handleLogs)))
+	mux.HandleFunc("/api/logs/stream", s.timeoutMiddleware(timeoutStream, s.limitMiddleware(classSearch, s.authMiddleware(s.handleLogsStream))))
+	mux.HandleFunc("/api/logs/compare", s.timeoutMiddleware(timeoutExport, s.limitMiddleware(classSearch, s.authMiddleware(s.handleCompare))))
+	mux.HandleFunc("/api/logs/export", s.timeoutMiddleware(timeoutExport, s.limitMiddleware(classSearch, s.authMiddleware(s.handleExport))))
+	mux.HandleFunc("/api/logs/scan", s.timeoutMiddleware(timeoutExport, s.limitMiddleware(classSearch, s.authMiddleware(s.handleScan))))
+	mux.HandleFunc("/api/logs/bundle", s.timeoutMiddleware(timeoutExport, s.limitMiddleware(classSearch, s.authMiddleware(s.handleBundle))))
+	mux.HandleFunc("/api/logs/histogram", s.limitMiddleware(classSearch, s.authMiddleware(s.handleSearchHistogram)))
+	mux.HandleFunc("/api/logs/recent-buffer", s.limitMiddleware(classSearch, s.authMiddleware(s.handleRecentBuffer)))
+	mux.HandleFunc("/api/logs/", s.limitMiddleware(classSearch, s.authMiddleware(s.handleLogEntry)))
+	mux.HandleFunc("/api/stats/histogram", s.limitMiddleware(classSearch, s.authMiddleware(s.handleHistogram)))
+	mux.HandleFunc("/api/stats/facets", s.limitMiddleware(classSearch, s.authMiddleware(s.handleFacets)))
+	mux.HandleFunc("/api/fields", s.limitMiddleware(classSearch, s.authMiddleware(s.handleFields)))
+	mux.HandleFunc("/api/fields/", s.limitMiddleware(classSearch, s.authMiddleware(s.handleFieldValues)))
+	mux.HandleFunc("/api/reports", s.limitMiddleware(classSearch, s.authMiddleware(s.handleReports)))
+	mux.HandleFunc("/api/reports/", s.limitMiddleware(classSearch, s.authMiddleware(s.handleReport)))
+	mux.HandleFunc("/api/events", s.limitMiddleware(classSearch, s.authMiddleware(s.handleEvents)))
+	mux.HandleFunc("/api/events/", s.limitMiddleware(classSearch, s.authMiddleware(s.handleEvent)))
+	mux.HandleFunc("/api/shares", s.limitMiddleware(classSearch, s.authMiddleware(s.handleShares)))
+	mux.HandleFunc("/api/shares/", s.limitMiddleware(classSearch, s.authMiddleware(s.handleShare)))
+	mux.HandleFunc("/api/alerts/history", s.limitMiddleware(classSearch, s.authMiddleware(s.handleAlertHistory)))
+	mux.HandleFunc("/api/ui/shortcuts", s.limitMiddleware(classSearch, s.authMiddleware(s.handleShortcuts)))
+	mux.HandleFunc("/api/ui/session", s.limitMiddleware(classSearch, s.authMiddleware(s.handleSession)))
+	mux.HandleFunc("/api/ui/preferences", s.limitMiddleware(classSearch, s.authMiddleware(s.handlePreferences)))
+	mux.HandleFunc("/api/ui/preferences/recent", s.limitMiddleware(classSearch, s.authMiddleware(s.handleRecentQuery)))
+
+	// Agent routes
+	mux.HandleFunc("/api/agents/register", s.limitMiddleware(classIngest, s.authMiddleware(s.handleAgentRegister)))
+	mux.HandleFunc("/api/agents/heartbeat", s.limitMiddleware(classIngest, s.authMiddleware(s.handleAgentHeartbeat)))
+	mux.HandleFunc("/api/agents/config", s.limitMiddleware(classIngest, s.authMiddleware(s.handleAgentConfig)))
+
+	// Admin routes
+	mux.HandleFunc("/api/admin/ingest/latency", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleIngestLatency))))
+	mux.HandleFunc("/api/admin/ingest/sizes", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleEntrySizes))))
+	mux.HandleFunc("/api/admin/cluster", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleClusterStatus))))
+	mux.HandleFunc("/api/admin/ingest/gaps", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleSequenceGaps))))
+	mux.HandleFunc("/api/admin/storage", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleStorageUsage))))
+	mux.HandleFunc("/api/admin/storage/checkpoint", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleCheckpoint))))
+	mux.HandleFunc("/api/admin/volume", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleVolume))))
+	mux.HandleFunc("/api/admin/logs", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleDeleteLogs))))
+	mux.HandleFunc("/api/admin/integrity", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleIntegrityCheck))))
+	mux.HandleFunc("/api/admin/fields/promoted", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handlePromotedFields))))
+	mux.HandleFunc("/api/admin/fields/promoted/", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleDemoteField))))
+	mux.HandleFunc("/api/admin/reprocess", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleReprocess))))
+	mux.HandleFunc("/api/admin/encryption/reencrypt", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleReencrypt))))
+	mux.HandleFunc("/api/admin/chain/verify", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleVerifyChain))))
+	mux.HandleFunc("/api/admin/notifications/channels", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleNotificationChannels))))
+	mux.HandleFunc("/api/admin/notifications/test", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleTestNotification))))
+	mux.HandleFunc("/api/admin/connections", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleConnections))))
+	mux.HandleFunc("/api/admin/connections/", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleCloseConnection))))
+	mux.HandleFunc("/api/admin/mode", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleMode))))
+	mux.HandleFunc("/api/admin/agents", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleAgents))))
+	mux.HandleFunc("/api/admin/users", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleUsers))))
+	mux.HandleFunc("/api/admin/users/", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleUser))))
+	mux.HandleFunc("/api/admin/tokens", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleAPITokens))))
+	mux.HandleFunc("/api/admin/tokens/", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleAPIToken))))
+	mux.HandleFunc("/api/admin/retention", s.limitMiddleware(classAdmin, s.authMiddleware(s.adminMiddleware(s.handleRetention))))
 
 	// Metrics endpoint for Prometheus
 	mux.Handle("/metrics", promhttp.Handler())
@@ -208,10 +398,27 @@ func (s *HTTPServer) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication if not enabled
 		if !s.config.AuthEnabled {
+			// A public demo is read-only to everyone, admin endpoints included
+			if s.config.Demo {
+				r = withRole(r, roleReader)
+			}
 			next(w, r)
 			return
 		}
 
+		// API tokens are sent as bearer tokens instead of credentials
+		if value, ok := bearerToken(r); ok {
+			token, ok := s.authenticateToken(value)
+			if !ok {
+				s.sendUnauthorized(w)
+				return
+			}
+			user := apiTokenUserPrefix + token.Name
+			setAccessUser(r, user)
+			next(w, withUser(withRole(r, token.Role), user))
+			return
+		}
+
 		// Get credentials from request
 		username, password, ok := r.BasicAuth()
 		if !ok {
@@ -228,13 +435,31 @@ func (s *HTTPServer) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		usernameMatch := s.constantTimeCompare(username, validUsername)
 		passwordMatch := s.constantTimeCompare(password, validPassword)
 
-		if !usernameMatch || !passwordMatch {
+		if usernameMatch && passwordMatch {
+			setAccessUser(r, username)
+			next(w, withUser(withRole(r, roleAdmin), username))
+			return
+		}
+
+		// Fall back to the reader-role account, if configured
+		readerMatch := s.constantTimeCompare(username, s.config.ReaderUsername)
+		readerPasswordMatch := s.constantTimeCompare(password, s.config.ReaderPassword)
+		if s.config.ReaderUsername != "" && readerMatch && readerPasswordMatch {
+			setAccessUser(r, username)
+			next(w, withUser(withRole(r, roleReader), username))
+			return
+		}
+
+		// Then to the users managed through the admin API
+		user, ok := s.authenticateUser(username, password)
+		if !ok {
 			s.sendUnauthorized(w)
 			return
 		}
 
 		// Authentication successful, proceed to handler
-		next(w, r)
+		setAccessUser(r, username)
+		next(w, withUser(withRole(r, user.Role), username))
 	}
 }
 
@@ -301,8 +526,13 @@ func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		Services: map[string]interface{}{
 			"log_service": serviceStats,
 			"http_server": s.GetStats(),
+			"mode":        s.currentMode(),
+			"recovery":    s.recoveryStatus(),
 		},
 	}
+	if s.udpServer != nil {
+		response.Services["udp_server"] = s.udpServer.GetStats()
+	}
 
 	s.sendJSONResponse(w, http.StatusOK, response)
 }
@@ -325,18 +555,130 @@ func (s *HTTPServer) handleLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	redact := s.redactorFor(r)
+	if err := redact.checkQuery(query); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	// Time ranges reaching back past retention may be completed from the archive
+	archived, ok := s.splitArchiveQuery(w, r, &query)
+	if !ok {
+		return
+	}
+
 	// Execute search
 	logs, err := s.logService.Search(query)
+	if s.sendBusy(w, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("Error searching logs: %v", err)
 		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search logs")
 		return
 	}
+	if archived != nil {
+		if logs, ok = s.completeFromArchive(w, r, query, *archived, logs); !ok {
+			return
+		}
+	}
+
+	presentInZone(r, logs)
 
-	// Return results
+	// Return results, masked for reader-role users and limited to the selected fields
+	logs = redact.entries(logs)
+	var data interface{} = logs
+	if len(query.Fields) > 0 {
+		data = selectResultFields(logs, query.Fields)
+	}
 	s.sendJSONResponse(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data:    logs,
+		Data:    data,
+	})
+}
+
+// presentInZone presents the timestamps of search results in the zone requested by tz, which
+// parseSearchQuery validated
+func presentInZone(r *http.Request, logs []*types.LogEntry) {
+	if r.URL.Query().Get("tz") == "" {
+		return
+	}
+	loc, _ := parseTimeZone(r.URL.Query())
+	for _, entry := range logs {
+		entry.Timestamp = entry.Timestamp.In(loc)
+		if entry.FirstTimestamp != nil {
+			first, last := entry.FirstTimestamp.In(loc), entry.LastTimestamp.In(loc)
+			entry.FirstTimestamp, entry.LastTimestamp = &first, &last
+		}
+	}
+}
+
+// handleIntegrityCheck runs a database integrity check on demand
+// Query parameter mode selects "quick" (default) or "full", or "last" for the report of the most
+// recent check, whether run on demand, at startup or periodically
+func (s *HTTPServer) handleIntegrityCheck(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	checker, ok := s.logService.(interfaces.IntegrityChecker)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Integrity checks are not supported")
+		return
+	}
+
+	quick, last := true, false
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "quick":
+	case "full":
+		quick = false
+	case "last":
+		last = true
+	default:
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid mode %q, expected quick, full or last", mode))
+		return
+	}
+
+	var report *interfaces.IntegrityReport
+	if last {
+		reporter, ok := s.logService.(interfaces.IntegrityReporter)
+		if !ok {
+			s.sendErrorResponse(w, http.StatusNotImplemented, "Integrity reports are not kept")
+			return
+		}
+		if report = reporter.LastIntegrityReport(); report == nil {
+			s.sendErrorResponse(w, http.StatusNotFound, "No integrity check has run yet")
+			return
+		}
+	} else {
+		var err error
+		if report, err = checker.CheckIntegrity(quick); err != nil {
+			log.Printf("Error running integrity check: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to run integrity check")
+			return
+		}
+	}
+
+	if !report.OK {
+		s.updateStats(func(stats *HTTPServerStats) {
+			stats.RequestErrors++
+		})
+		s.sendJSONResponse(w, http.StatusServiceUnavailable, APIResponse{
+			Success: false,
+			Data:    report,
+			Error:   newAPIError(ErrCodeStorageUnavailable, "Database integrity check reported problems"),
+		})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    report,
 	})
 }
 
@@ -360,23 +702,13 @@ func (s *HTTPServer) parseSearchQuery(r *http.Request) (types.SearchQuery, error
 		query.Facility = &facility
 	}
 
-	// Parse severity filter
-	if severityStr := r.URL.Query().Get("severity"); severityStr != "" {
-		severity, err := strconv.Atoi(severityStr)
-		if err != nil {
-			return query, fmt.Errorf("invalid severity value: %w", err)
-		}
-		query.Severity = &severity
-	}
-
-	// Parse min severity filter
-	if minSeverityStr := r.URL.Query().Get("min_severity"); minSeverityStr != "" {
-		minSeverity, err := strconv.Atoi(minSeverityStr)
-		if err != nil {
-			return query, fmt.Errorf("invalid min_severity value: %w", err)
-		}
-		query.MinSeverity = &minSeverity
+	// Parse severity filters, given as numbers or level names
+	severity, minSeverity, err := parseSeverityParams(r.URL.Query())
+	if err != nil {
+		return query, err
 	}
+	query.Severity = severity
+	query.MinSeverity = minSeverity
 
 	// Parse hostname filter
 	if hostname := r.URL.Query().Get("hostname"); hostname != "" {
@@ -393,9 +725,20 @@ func (s *HTTPServer) parseSearchQuery(r *http.Request) (types.SearchQuery, error
 		query.ProcID = procID
 	}
 
-	// Parse msg ID filter
-	if msgID := r.URL.Query().Get("msg_id"); msgID != "" {
-		query.MsgID = msgID
+	// Parse msg ID filter, which tracking_id is an alias for
+	msgID, err := parseTrackingID(r.URL.Query())
+	if err != nil {
+		return query, err
+	}
+	query.MsgID = msgID
+
+	// Parse source IP filter
+	if sourceIP := r.URL.Query().Get("source_ip"); sourceIP != "" {
+		normalized := normalizeSourceIP(sourceIP)
+		if normalized == "" {
+			return query, fmt.Errorf("invalid source_ip value: %s", sourceIP)
+		}
+		query.SourceIP = normalized
 	}
 
 	// Parse structured data query
@@ -403,20 +746,27 @@ func (s *HTTPServer) parseSearchQuery(r *http.Request) (types.SearchQuery, error
 		query.StructuredDataQuery = structuredDataQuery
 	}
 
+	// Parse time zone used for times without an offset; relative times are resolved against now
+	loc, err := parseTimeZone(r.URL.Query())
+	if err != nil {
+		return query, err
+	}
+	now := time.Now()
+
 	// Parse start time
 	if startTimeStr := r.URL.Query().Get("start_time"); startTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
+		startTime, err := parseTimeParam("start_time", startTimeStr, now, loc)
 		if err != nil {
-			return query, fmt.Errorf("invalid start_time format, expected RFC3339: %w", err)
+			return query, err
 		}
 		query.StartTime = &startTime
 	}
 
 	// Parse end time
 	if endTimeStr := r.URL.Query().Get("end_time"); endTimeStr != "" {
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
+		endTime, err := parseTimeParam("end_time", endTimeStr, now, loc)
 		if err != nil {
-			return query, fmt.Errorf("invalid end_time format, expected RFC3339: %w", err)
+			return query, err
 		}
 		query.EndTime = &endTime
 	}
@@ -439,6 +789,31 @@ func (s *HTTPServer) parseSearchQuery(r *http.Request) (types.SearchQuery, error
 		query.Offset = offset
 	}
 
+	// Parse result field selection
+	if fieldsStr := r.URL.Query().Get("fields"); fieldsStr != "" {
+		fields, err := parseResultFields(fieldsStr)
+		if err != nil {
+			return query, err
+		}
+		query.Fields = fields
+	}
+
+	// Parse collapsing of repeated messages
+	if collapseStr := r.URL.Query().Get("collapse"); collapseStr != "" {
+		collapse, err := strconv.ParseBool(collapseStr)
+		if err != nil {
+			return query, fmt.Errorf("invalid collapse value, must be true or false")
+		}
+		query.Collapse = collapse
+	}
+
+	// Parse query expression, which adds to the individual filters above
+	if expr := r.URL.Query().Get("q"); expr != "" {
+		if err := querylang.Compile(expr, &query); err != nil {
+			return query, fmt.Errorf("invalid q expression: %w", err)
+		}
+	}
+
 	return query, nil
 }
 
@@ -455,15 +830,40 @@ func (s *HTTPServer) sendJSONResponse(w http.ResponseWriter, statusCode int, dat
 	}
 }
 
-// sendErrorResponse sends an error response
+// sendErrorResponse sends an error response with the error code of the status code
 func (s *HTTPServer) sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	s.sendError(w, statusCode, errorCode(statusCode), message)
+}
+
+// sendBusy answers a request refused because the search slots are taken or the storage circuit
+// breaker is open, reporting whether it did
+func (s *HTTPServer) sendBusy(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, interfaces.ErrSearchBusy):
+		w.Header().Set("Retry-After", "1")
+		s.sendError(w, http.StatusServiceUnavailable, ErrCodeQueueFull, err.Error())
+	case errors.Is(err, interfaces.ErrStorageBusy):
+		w.Header().Set("Retry-After", "5")
+		s.sendError(w, http.StatusServiceUnavailable, ErrCodeStorageUnavailable, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+// sendError sends an error response with an error code more specific than the status code
+func (s *HTTPServer) sendError(w http.ResponseWriter, statusCode int, code, message string) {
 	s.updateStats(func(stats *HTTPServerStats) {
 		stats.RequestErrors++
 	})
 
 	response := APIResponse{
-		Success: false,
-		Error:   message,
+		Success:   false,
+		Error:     newAPIError(code, message),
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+	if statusCode >= http.StatusInternalServerError {
+		log.Printf("Request %s failed with status %d: %s", response.RequestID, statusCode, message)
 	}
 
 	s.sendJSONResponse(w, statusCode, response)
@@ -480,6 +880,11 @@ func (s *HTTPServer) handleLogsStream(w http.ResponseWriter, r *http.Request) {
 		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
+	source, ok := s.liveSourceFor(r)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusBadRequest, "invalid scope, must be cluster or local")
+		return
+	}
 
 	// Upgrade HTTP connection to WebSocket
 	conn, err := s.upgrader.Upgrade(w, r, nil)
@@ -498,11 +903,12 @@ func (s *HTTPServer) handleLogsStream(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Handle the WebSocket connection
-	s.handleWebSocketConnection(conn)
+	s.handleWebSocketConnection(conn, source, s.redactorFor(r))
 }
 
-// handleWebSocketConnection manages a single WebSocket connection
-func (s *HTTPServer) handleWebSocketConnection(conn *websocket.Conn) {
+// handleWebSocketConnection manages a single WebSocket connection streaming the entries of source,
+// masking entries with redact if set
+func (s *HTTPServer) handleWebSocketConnection(conn *websocket.Conn, source liveSource, redact *redactor) {
 	defer func() {
 		conn.Close()
 		s.updateStats(func(stats *HTTPServerStats) {
@@ -518,8 +924,8 @@ func (s *HTTPServer) handleWebSocketConnection(conn *websocket.Conn) {
 	})
 
 	// Subscribe to log updates
-	subscription := s.logService.Subscribe()
-	defer s.logService.Unsubscribe(subscription)
+	subscription := source.Subscribe()
+	defer source.Unsubscribe(subscription)
 
 	// Create context for this connection
 	ctx, cancel := context.WithCancel(s.ctx)
@@ -564,7 +970,7 @@ func (s *HTTPServer) handleWebSocketConnection(conn *websocket.Conn) {
 
 				// Send log entry to client
 				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-				if err := conn.WriteJSON(logEntry); err != nil {
+				if err := conn.WriteJSON(redact.entry(logEntry)); err != nil {
 					log.Printf("WebSocket write error: %v", err)
 					return
 				}
@@ -619,7 +1025,9 @@ func (s *HTTPServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Serve the index.html file
-	if s.useEmbedded {
+	if s.config.HTTPBasePath != "" {
+		s.serveIndexWithBasePath(w, r)
+	} else if s.useEmbedded {
 		s.serveEmbeddedFile(w, r, "index.html")
 	} else {
 		indexPath := s.getStaticFilePath("index.html")
@@ -681,6 +1089,31 @@ func (s *HTTPServer) serveEmbeddedFile(w http.ResponseWriter, r *http.Request, f
 	w.Write(data)
 }
 
+// serveIndexWithBasePath serves index.html with asset URLs rewritten below the base path and
+// the base path exposed to the UI so API and WebSocket requests are prefixed as well
+func (s *HTTPServer) serveIndexWithBasePath(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	if s.useEmbedded {
+		data, err = fs.ReadFile(s.staticFS, "index.html")
+	} else {
+		data, err = os.ReadFile(s.getStaticFilePath("index.html"))
+	}
+	if err != nil {
+		log.Printf("Error reading index.html: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	basePath := s.config.HTTPBasePath
+	basePathJSON, _ := json.Marshal(basePath)
+	page := strings.ReplaceAll(string(data), `"/static/`, `"`+basePath+`/static/`)
+	page = strings.Replace(page, "<head>", "<head>\n    <script>window.__OPENTRAIL_BASE_PATH__ = "+string(basePathJSON)+";</script>", 1)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
+
 // getStaticFilePath resolves the path to static files, handling different working directories
 func (s *HTTPServer) getStaticFilePath(filename string) string {
 	// Try different possible paths for static files
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultHTTPIdleTimeout is how long idle keep-alive HTTP connections are kept open
+	DefaultHTTPIdleTimeout = 120 * time.Second
+	// DefaultHTTP2MaxConcurrentStreams bounds the requests multiplexed on one HTTP/2 connection
+	DefaultHTTP2MaxConcurrentStreams = 250
+
+	// httpReadHeaderTimeout bounds reading request headers, so slow clients cannot hold connections
+	httpReadHeaderTimeout = 10 * time.Second
+	// http2PingInterval is how long an HTTP/2 connection may be silent before it is health checked
+	// with a ping, closing connections to clients that vanished behind a proxy
+	http2PingInterval = 30 * time.Second
+	// http2PingTimeout is how long a ping may go unanswered
+	http2PingTimeout = 15 * time.Second
+)
+
+// httpProtocols returns the protocols served on the HTTP listener. HTTP/2 over TLS is negotiated
+// automatically when the server is given TLS connections; cleartext HTTP/2 is served with -http-h2c.
+func (s *HTTPServer) httpProtocols() *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(s.config.HTTPH2C)
+	return protocols
+}
+
+// http2Config returns the HTTP/2 settings of the HTTP listener
+func (s *HTTPServer) http2Config() *http.HTTP2Config {
+	streams := s.config.HTTP2MaxConcurrentStreams
+	if streams <= 0 {
+		streams = DefaultHTTP2MaxConcurrentStreams
+	}
+	return &http.HTTP2Config{
+		MaxConcurrentStreams: streams,
+		SendPingTimeout:      http2PingInterval,
+		PingTimeout:          http2PingTimeout,
+	}
+}
+
+// httpIdleTimeout returns how long idle keep-alive connections are kept open
+func (s *HTTPServer) httpIdleTimeout() time.Duration {
+	if s.config.HTTPIdleTimeout > 0 {
+		return s.config.HTTPIdleTimeout
+	}
+	return DefaultHTTPIdleTimeout
+}
+
+// withH2CTrust rejects cleartext HTTP/2 requests that did not come from a trusted proxy, when
+// trusted proxies are configured. Clients reaching the listener directly still use HTTP/1.1.
+func (s *HTTPServer) withH2CTrust(handler http.Handler) http.Handler {
+	if !s.config.HTTPH2C || len(s.config.TrustedProxies) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && r.TLS == nil && !s.proxies.trusted(normalizeSourceIP(r.RemoteAddr)) {
+			s.sendErrorResponse(w, http.StatusForbidden, "Cleartext HTTP/2 is only accepted from trusted proxies")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
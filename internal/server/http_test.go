@@ -2,19 +2,30 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"opentrail/internal/agents"
+	"opentrail/internal/fieldcrypt"
 	"opentrail/internal/interfaces"
+	"opentrail/internal/logformat"
+	"opentrail/internal/notify"
 	"opentrail/internal/parser"
 	"opentrail/internal/service"
 	"opentrail/internal/storage"
@@ -237,8 +248,8 @@ func TestHTTPServer_LogsEndpoint_BasicQuery(t *testing.T) {
 		t.Errorf("Expected success=true, got %v", apiResp.Success)
 	}
 	
-	if apiResp.Error != "" {
-		t.Errorf("Expected no error, got %s", apiResp.Error)
+	if apiResp.Error != nil {
+		t.Errorf("Expected no error, got %+v", apiResp.Error)
 	}
 	
 	// Check that we got logs back
@@ -575,6 +586,21 @@ func TestHTTPServer_LogsEndpoint_InvalidParameters(t *testing.T) {
 			query:      "min_severity=invalid",
 			expectCode: http.StatusBadRequest,
 		},
+		{
+			name:       "Invalid level - unknown name",
+			query:      "level=loud",
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Conflicting severity and level",
+			query:      "severity=6&level=error",
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Conflicting msg_id and tracking_id",
+			query:      "msg_id=a&tracking_id=b",
+			expectCode: http.StatusBadRequest,
+		},
 	}
 	
 	for _, tc := range testCases {
@@ -598,8 +624,8 @@ func TestHTTPServer_LogsEndpoint_InvalidParameters(t *testing.T) {
 				t.Error("Expected success=false for invalid parameters")
 			}
 			
-			if apiResp.Error == "" {
-				t.Error("Expected error message for invalid parameters")
+			if apiResp.Error == nil || apiResp.Error.Code != ErrCodeInvalidQuery || apiResp.Error.Message == "" {
+				t.Errorf("Expected an invalid_query error with a message, got %+v", apiResp.Error)
 			}
 		})
 	}
@@ -1032,8 +1058,8 @@ func TestHTTPServer_AuthMiddleware_MissingAuthHeader(t *testing.T) {
 		t.Error("Expected success=false for unauthorized request")
 	}
 	
-	if apiResp.Error != "Authentication required" {
-		t.Errorf("Expected error message 'Authentication required', got %q", apiResp.Error)
+	if apiResp.Error == nil || apiResp.Error.Code != ErrCodeUnauthorized || apiResp.Error.Message != "Authentication required" {
+		t.Errorf("Expected an unauthorized error 'Authentication required', got %+v", apiResp.Error)
 	}
 }
 
@@ -1078,6 +1104,16 @@ func TestHTTPServer_ParseSearchQuery_RFC5424Fields(t *testing.T) {
 				Limit:       100,
 			},
 		},
+		{
+			name:  "Level names and tracking ID",
+			query: "level=ERROR&min_severity=warning&tracking_id=req-42",
+			expected: types.SearchQuery{
+				Severity:    func() *int { i := 3; return &i }(),
+				MinSeverity: func() *int { i := 4; return &i }(),
+				MsgID:       "req-42",
+				Limit:       100,
+			},
+		},
 		{
 			name:  "String fields",
 			query: "hostname=web01&app_name=nginx&proc_id=1234&msg_id=access",
@@ -1500,7 +1536,3094 @@ func TestHTTPServer_WebSocket_MethodNotAllowed(t *testing.T) {
 		t.Error("Expected success=false for method not allowed")
 	}
 	
-	if apiResp.Error != "Method not allowed" {
-		t.Errorf("Expected error message 'Method not allowed', got %q", apiResp.Error)
+	if apiResp.Error == nil || apiResp.Error.Code != ErrCodeMethodNotAllowed || apiResp.Error.Message != "Method not allowed" {
+		t.Errorf("Expected a method_not_allowed error 'Method not allowed', got %+v", apiResp.Error)
+	}
+}
+
+func TestHTTPServer_IntegrityCheckEndpoint(t *testing.T) {
+	server, cleanup := setupTestHTTPServer(t)
+	defer cleanup()
+
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	tests := []struct {
+		name           string
+		method         string
+		query          string
+		expectedStatus int
+		expectedMode   string
+	}{
+		{"no last check", http.MethodGet, "?mode=last", http.StatusNotFound, ""},
+		{"default quick check", http.MethodGet, "", http.StatusOK, "quick"},
+		{"explicit quick check", http.MethodGet, "?mode=quick", http.StatusOK, "quick"},
+		{"full check", http.MethodGet, "?mode=full", http.StatusOK, "full"},
+		{"last check", http.MethodGet, "?mode=last", http.StatusOK, "full"},
+		{"invalid mode", http.MethodGet, "?mode=deep", http.StatusBadRequest, ""},
+		{"method not allowed", http.MethodPost, "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/admin/integrity"+tt.query, nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedMode == "" {
+				return
+			}
+
+			var response struct {
+				Success bool                        `json:"success"`
+				Data    interfaces.IntegrityReport `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !response.Success || !response.Data.OK {
+				t.Errorf("Expected healthy integrity report, got %+v", response)
+			}
+			if response.Data.Mode != tt.expectedMode {
+				t.Errorf("Expected mode %s, got %s", tt.expectedMode, response.Data.Mode)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_IntegrityCheckEndpoint_Unsupported(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	server := NewHTTPServer(config, &MockLogService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/integrity", nil)
+	w := httptest.NewRecorder()
+	server.handleIntegrityCheck(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+// busySearchService rejects every search as if the concurrency limit were reached
+type busySearchService struct {
+	MockLogService
+}
+
+func (m *busySearchService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	return nil, interfaces.ErrSearchBusy
+}
+
+func TestHTTPServer_SearchBusy(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	server := NewHTTPServer(config, &busySearchService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+	w := httptest.NewRecorder()
+	server.handleLogs(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil {
+		t.Fatalf("Failed to decode API response: %v", err)
+	}
+	if apiResp.Error == nil || apiResp.Error.Code != ErrCodeQueueFull || apiResp.Error.DocURL != ErrorDocsURL {
+		t.Errorf("Expected a queue_full error linking the documentation, got %+v", apiResp.Error)
+	}
+}
+
+// histogramService records histogram queries and returns a fixed result
+type histogramService struct {
+	MockLogService
+	query types.HistogramQuery
+}
+
+func (m *histogramService) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	m.query = query
+	return &types.Histogram{Interval: query.Interval.String(), Total: 3}, nil
+}
+
+func TestHTTPServer_Histogram(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	// Services without rollups are reported as unsupported
+	server := NewHTTPServer(config, &MockLogService{})
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/histogram", nil)
+	w := httptest.NewRecorder()
+	server.handleHistogram(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &histogramService{}
+	server = NewHTTPServer(config, service)
+
+	req = httptest.NewRequest(http.MethodGet,
+		"/api/stats/histogram?start_time=2024-01-01T00:00:00Z&end_time=2024-01-08T00:00:00Z&group_by=app_name&min_severity=3", nil)
+	w = httptest.NewRecorder()
+	server.handleHistogram(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	// A week at no more than 200 buckets selects one hour
+	if service.query.Interval != time.Hour {
+		t.Errorf("Expected automatic interval of 1h, got %v", service.query.Interval)
+	}
+	if service.query.GroupBy != types.GroupByAppName {
+		t.Errorf("Expected group_by app_name, got %q", service.query.GroupBy)
+	}
+	if service.query.MinSeverity == nil || *service.query.MinSeverity != 3 {
+		t.Errorf("Expected min_severity 3, got %v", service.query.MinSeverity)
+	}
+
+	invalid := []string{
+		"interval=30s",
+		"interval=90s",
+		"interval=1m&start_time=2020-01-01T00:00:00Z",
+		"group_by=message",
+		"start_time=2024-01-02T00:00:00Z&end_time=2024-01-01T00:00:00Z",
+	}
+	for _, params := range invalid {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/histogram?"+params, nil)
+		w := httptest.NewRecorder()
+		server.handleHistogram(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", params, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestHTTPServer_SearchHistogram(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	service := &histogramService{}
+	server := NewHTTPServer(config, service)
+
+	req := httptest.NewRequest(http.MethodGet,
+		`/api/logs/histogram?start_time=2024-01-01T00:00:00Z&end_time=2024-01-02T00:00:00Z&group_by=severity&app_name=api&limit=5000&q=user_id%3D42+"timed+out"`, nil)
+	w := httptest.NewRecorder()
+	server.handleSearchHistogram(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	search := service.query.Search
+	if search == nil {
+		t.Fatal("Expected the histogram to be limited to a search")
+	}
+	if search.AppName != "api" || len(search.Filters) != 1 || search.Text == "" {
+		t.Errorf("Expected the search filters to be parsed, got %+v", search)
+	}
+	if search.Limit != 0 || search.StartTime != nil || search.EndTime != nil {
+		t.Errorf("Expected the search's paging and range to be left to the histogram, got %+v", search)
+	}
+	if service.query.AppName != "" {
+		t.Errorf("Expected the app filter to be part of the search only, got %q", service.query.AppName)
+	}
+	// A day at no more than 200 buckets selects 15 minutes
+	if service.query.Interval != 15*time.Minute || service.query.GroupBy != types.GroupBySeverity {
+		t.Errorf("Unexpected buckets: %v by %q", service.query.Interval, service.query.GroupBy)
+	}
+
+	for _, params := range []string{"q=host%3A", "group_by=message", "severity=high"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs/histogram?"+params, nil)
+		w := httptest.NewRecorder()
+		server.handleSearchHistogram(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", params, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+// facetService records facet queries and returns an empty result
+type facetService struct {
+	MockLogService
+	query types.FacetQuery
+}
+
+func (m *facetService) Facets(query types.FacetQuery) (*types.FacetResult, error) {
+	m.query = query
+	return &types.FacetResult{Facets: []types.Facet{}}, nil
+}
+
+func TestHTTPServer_Facets(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/facets", nil)
+	w := httptest.NewRecorder()
+	server.handleFacets(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &facetService{}
+	server = NewHTTPServer(config, service)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/facets?fields=hostname,msg_id&limit=25", nil)
+	w = httptest.NewRecorder()
+	server.handleFacets(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(service.query.Fields) != 2 || service.query.Fields[1] != types.FacetMsgID {
+		t.Errorf("Expected fields [hostname msg_id], got %v", service.query.Fields)
+	}
+	if service.query.Limit != 25 {
+		t.Errorf("Expected limit 25, got %d", service.query.Limit)
+	}
+	if got := service.query.EndTime.Sub(service.query.StartTime); got != 24*time.Hour {
+		t.Errorf("Expected default range of 24h, got %v", got)
+	}
+
+	for _, params := range []string{"fields=message", "limit=0", "limit=1000", "start_time=yesterday"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/facets?"+params, nil)
+		w := httptest.NewRecorder()
+		server.handleFacets(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", params, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestHTTPServer_Histogram_TimeZone(t *testing.T) {
+	service := &histogramService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/stats/histogram?tz=Europe/Berlin&start_time=2024-01-01&end_time=2024-01-08&interval=24h", nil)
+	w := httptest.NewRecorder()
+	server.handleHistogram(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if service.query.Location == nil || service.query.Location.String() != "Europe/Berlin" {
+		t.Fatalf("Expected Europe/Berlin location, got %v", service.query.Location)
+	}
+	if want := time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC); !service.query.StartTime.Equal(want) {
+		t.Errorf("Expected local midnight %v, got %v", want, service.query.StartTime)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stats/histogram?tz=Nowhere/City", nil)
+	w = httptest.NewRecorder()
+	server.handleHistogram(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown zone, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// fixedSearchService returns the same entries for every search
+type fixedSearchService struct {
+	MockLogService
+	entries []*types.LogEntry
+}
+
+func (m *fixedSearchService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	return m.entries, nil
+}
+
+func TestHTTPServer_Logs_TimeZone(t *testing.T) {
+	service := &fixedSearchService{entries: []*types.LogEntry{
+		{ID: 1, Timestamp: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Message: "tz"},
+	}}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?tz=America/New_York", nil)
+	w := httptest.NewRecorder()
+	server.handleLogs(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"2024-06-01T08:00:00-04:00"`) {
+		t.Errorf("Expected timestamp in New York time, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/logs?tz=Invalid/Zone", nil)
+	w = httptest.NewRecorder()
+	server.handleLogs(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown zone, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHTTPServer_ParseSearchQuery_RelativeTime(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+
+	before := time.Now()
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?start_time=-15m&end_time=now", nil)
+	query, err := server.parseSearchQuery(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	after := time.Now()
+
+	if query.StartTime == nil || query.EndTime == nil {
+		t.Fatal("Expected start and end times to be set")
+	}
+	if got := query.EndTime.Sub(*query.StartTime); got != 15*time.Minute {
+		t.Errorf("Expected a 15 minute range, got %v", got)
+	}
+	if query.EndTime.Before(before) || query.EndTime.After(after) {
+		t.Errorf("Expected end time to be now, got %v", query.EndTime)
+	}
+}
+
+func TestHTTPServer_ParseSearchQuery_Expression(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+
+	params := url.Values{}
+	params.Set("q", `app:nginx severity<=err "connection refused" -host:web03`)
+	params.Set("facility", "16")
+	req := httptest.NewRequest(http.MethodGet, "/api/logs?"+params.Encode(), nil)
+	query, err := server.parseSearchQuery(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if query.Text != `"connection refused"` {
+		t.Errorf("Expected phrase search, got %q", query.Text)
+	}
+	if query.MinSeverity == nil || *query.MinSeverity != 3 {
+		t.Errorf("Expected min severity 3, got %v", query.MinSeverity)
+	}
+	if query.Facility == nil || *query.Facility != 16 {
+		t.Errorf("Expected facility parameter to be kept, got %v", query.Facility)
+	}
+	if len(query.Filters) != 2 || !query.Filters[1].Negate {
+		t.Errorf("Expected app and negated host filters, got %+v", query.Filters)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/logs?q="+url.QueryEscape(`severity<=loud`), nil)
+	w := httptest.NewRecorder()
+	server.handleLogs(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid expression, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// catalogService serves a fixed field catalog
+type catalogService struct {
+	MockLogService
+	valuesQuery types.FieldValuesQuery
+}
+
+func (m *catalogService) Fields(prefix string, limit int) ([]types.FieldInfo, error) {
+	return []types.FieldInfo{{Name: "hostname", Builtin: true}, {Name: "request@32473.user_id"}}, nil
+}
+
+func (m *catalogService) FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	m.valuesQuery = query
+	return []types.FieldValueInfo{{Value: "42", Count: 3}}, nil
+}
+
+func TestHTTPServer_FieldCatalog(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleFields(w, httptest.NewRequest(http.MethodGet, "/api/fields", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &catalogService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/fields?prefix=req", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "request@32473.user_id") {
+		t.Errorf("Unexpected fields response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/fields/request@32473.user_id/values?prefix=4&limit=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	want := types.FieldValuesQuery{Field: "request@32473.user_id", Prefix: "4", Limit: 5}
+	if service.valuesQuery != want {
+		t.Errorf("Expected query %+v, got %+v", want, service.valuesQuery)
+	}
+
+	for path, status := range map[string]int{
+		"/api/fields/hostname":                http.StatusNotFound,
+		"/api/fields/hostname/values?limit=0": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", path, status, w.Code)
+		}
+	}
+}
+
+// promoterService records field promotions
+type promoterService struct {
+	MockLogService
+	promoted []string
+	demoted  []string
+}
+
+func (m *promoterService) PromoteField(field string) (*types.PromotedField, error) {
+	if !strings.Contains(field, ".") {
+		return nil, fmt.Errorf("%w: %q must have the form sdid.param", interfaces.ErrInvalidPromotion, field)
+	}
+	m.promoted = append(m.promoted, field)
+	return &types.PromotedField{Field: field, Column: "sd_col", Status: types.PromotionBuilding}, nil
+}
+
+func (m *promoterService) DemoteField(field string) error {
+	if field != "request@32473.user_id" {
+		return interfaces.ErrFieldNotPromoted
+	}
+	m.demoted = append(m.demoted, field)
+	return nil
+}
+
+func (m *promoterService) PromotedFields() ([]types.PromotedField, error) {
+	return []types.PromotedField{{Field: "request@32473.user_id", Column: "sd_col", Status: types.PromotionReady}}, nil
+}
+
+func TestHTTPServer_PromotedFields(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handlePromotedFields(w, httptest.NewRequest(http.MethodGet, "/api/admin/fields/promoted", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &promoterService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/fields/promoted", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ready"`) {
+		t.Errorf("Unexpected list response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/fields/promoted", strings.NewReader(`{"field":"request@32473.user_id"}`)))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if len(service.promoted) != 1 || service.promoted[0] != "request@32473.user_id" {
+		t.Errorf("Unexpected promotions: %v", service.promoted)
+	}
+
+	for _, body := range []string{`{"field":"user_id"}`, `not json`} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/fields/promoted", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, body, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/fields/promoted/request@32473.user_id", nil))
+	if w.Code != http.StatusOK || len(service.demoted) != 1 {
+		t.Errorf("Unexpected demote response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/fields/promoted/other.id", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/fields/promoted/request@32473.user_id", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// reprocessService records reprocessing jobs
+type reprocessService struct {
+	MockLogService
+	running bool
+	query   types.ReprocessQuery
+}
+
+func (m *reprocessService) StartReprocess(query types.ReprocessQuery) (*types.ReprocessStatus, error) {
+	if m.running {
+		return nil, interfaces.ErrReprocessRunning
+	}
+	m.running = true
+	m.query = query
+	return &types.ReprocessStatus{Running: true, Query: query}, nil
+}
+
+func (m *reprocessService) ReprocessStatus() types.ReprocessStatus {
+	return types.ReprocessStatus{Running: m.running, Processed: 10}
+}
+
+func TestHTTPServer_Reprocess(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleReprocess(w, httptest.NewRequest(http.MethodGet, "/api/admin/reprocess", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &reprocessService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/reprocess?start_time=2024-05-01T00:00:00Z&end_time=2024-05-02T00:00:00Z", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if service.query.StartTime == nil || !service.query.StartTime.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || service.query.EndTime == nil {
+		t.Errorf("Unexpected query %+v", service.query)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/reprocess", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/reprocess", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"processed":10`) {
+		t.Errorf("Unexpected status response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/reprocess?start_time=2024-05-02T00:00:00Z&end_time=2024-05-01T00:00:00Z", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// rawService serves the raw message of entry 7
+type rawService struct {
+	MockLogService
+}
+
+func (m *rawService) RawMessage(id int64) (string, error) {
+	switch id {
+	case 7:
+		return "<134>1 - web01 api - - - hello", nil
+	case 8:
+		return "", nil
+	}
+	return "", interfaces.ErrEntryNotFound
+}
+
+func TestHTTPServer_RawMessage(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleRawMessage(w, httptest.NewRequest(http.MethodGet, "/api/logs/7/raw", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	server = NewHTTPServer(config, &rawService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/7/raw", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `web01 api - - - hello`) {
+		t.Errorf("Unexpected raw response %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/logs/8/raw", "/api/logs/9/raw", "/api/logs/abc/raw", "/api/logs/7/other"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusNotFound, path, w.Code)
+		}
+	}
+}
+
+// detailService serves the detail of entry 7
+type detailService struct {
+	MockLogService
+}
+
+func (m *detailService) EntryDetail(id int64) (*types.EntryDetail, error) {
+	if id != 7 {
+		return nil, interfaces.ErrEntryNotFound
+	}
+	return &types.EntryDetail{
+		Entry: &types.LogEntry{
+			ID: 7, Hostname: "web01", Message: "card 1234-5678",
+			StructuredData: map[string]interface{}{
+				types.MetadataSDID: map[string]interface{}{types.SourceIPParam: "10.0.0.5", "reprocessed": "true"},
+			},
+		},
+		Raw:   "<134>1 - web01 api - - - card 1234-5678",
+		Chain: &types.ChainLink{Partition: "2024-05-01", Hash: "abc"},
+	}, nil
+}
+
+func TestHTTPServer_EntryDetail(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleEntryDetail(w, httptest.NewRequest(http.MethodGet, "/api/logs/7", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	server = NewHTTPServer(config, &detailService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/7", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data types.EntryDetail `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	detail := response.Data
+	if detail.Entry == nil || detail.Entry.Hostname != "web01" || detail.Raw == "" {
+		t.Errorf("Unexpected entry detail: %+v", detail)
+	}
+	if detail.SourceIP != "10.0.0.5" || detail.Annotations["reprocessed"] != "true" || len(detail.Annotations) != 1 {
+		t.Errorf("Expected the source IP and annotations to be split from the metadata, got %q and %v", detail.SourceIP, detail.Annotations)
 	}
-}
\ No newline at end of file
+	if detail.Chain == nil || detail.Chain.Hash != "abc" {
+		t.Errorf("Expected the chain link, got %+v", detail.Chain)
+	}
+
+	for path, status := range map[string]int{
+		"/api/logs/8":   http.StatusNotFound,
+		"/api/logs/abc": http.StatusNotFound,
+		"/api/logs/0":   http.StatusNotFound,
+	} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, path, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/logs/7", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	// Readers get the detail redacted and without the raw message
+	config = &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `\d{4}-\d{4}`,
+	}
+	server = NewHTTPServer(config, &detailService{})
+	mux = http.NewServeMux()
+	server.setupRoutes(mux)
+	req := httptest.NewRequest(http.MethodGet, "/api/logs/7", nil)
+	req.SetBasicAuth("reader", "readonly")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if body := w.Body.String(); w.Code != http.StatusOK || strings.Contains(body, "1234-5678") || strings.Contains(body, `"raw"`) {
+		t.Errorf("Expected a redacted detail without raw message for readers, got %d: %s", w.Code, body)
+	}
+}
+
+// chainService reports a broken chain for the requested partition
+type chainService struct {
+	MockLogService
+}
+
+func (m *chainService) VerifyChain(partition string) (*types.ChainReport, error) {
+	return &types.ChainReport{Partitions: []types.ChainPartition{{Partition: partition, Entries: 2, BrokenAt: 5}}}, nil
+}
+
+func TestHTTPServer_VerifyChain(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleVerifyChain(w, httptest.NewRequest(http.MethodGet, "/api/admin/chain/verify", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	server = NewHTTPServer(config, &chainService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/chain/verify?partition=2024-05-01", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"partition":"2024-05-01"`) || !strings.Contains(w.Body.String(), `"broken_at":5`) {
+		t.Errorf("Unexpected verification response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/chain/verify?partition=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid partition, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/chain/verify", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// redactService returns one entry with sensitive content
+type redactService struct {
+	MockLogService
+	query types.SearchQuery
+	entry *types.LogEntry
+}
+
+func (m *redactService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.query = query
+	return []*types.LogEntry{m.entry}, nil
+}
+
+func (m *redactService) RawMessage(id int64) (string, error) {
+	return "<134>1 - web01 api - - [auth token=\"s3cret\"] card 1234-5678", nil
+}
+
+func TestHTTPServer_Redaction(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+		RedactFields: []string{"auth.token"}, RedactPattern: `\d{4}-\d{4}`,
+	}
+	service := &redactService{entry: &types.LogEntry{
+		ID: 7, Message: "charged card 1234-5678",
+		StructuredData: map[string]interface{}{
+			"auth": map[string]interface{}{"token": "s3cret", "user": "alice"},
+			"payment": map[string]string{"ref": "ref 9999-0000"},
+		},
+	}}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	get := func(path, username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth(username, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/logs", "reader", "readonly")
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for reader search, got %d: %s", http.StatusOK, w.Code, body)
+	}
+	if strings.Contains(body, "s3cret") || strings.Contains(body, "1234-5678") || strings.Contains(body, "9999-0000") {
+		t.Errorf("Expected sensitive content to be masked for readers: %s", body)
+	}
+	if !strings.Contains(body, `"token":"[REDACTED]"`) || !strings.Contains(body, "charged card [REDACTED]") || !strings.Contains(body, `"user":"alice"`) {
+		t.Errorf("Unexpected redacted response: %s", body)
+	}
+	if service.entry.Message != "charged card 1234-5678" {
+		t.Errorf("Expected the original entry to be left intact, got %q", service.entry.Message)
+	}
+
+	w = get("/api/logs", "admin", "password")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "s3cret") || !strings.Contains(body, "1234-5678") {
+		t.Errorf("Expected admins to see full content, got %d: %s", w.Code, body)
+	}
+
+	// Readers cannot probe redacted values through filters, raw messages or admin endpoints
+	for path, status := range map[string]int{
+		"/api/logs?q=auth.token:s3cret": http.StatusForbidden,
+		"/api/logs?q=auth.user:alice":   http.StatusOK,
+		"/api/logs/7/raw":               http.StatusForbidden,
+		"/api/admin/reprocess":          http.StatusForbidden,
+	} {
+		if w := get(path, "reader", "readonly"); w.Code != status {
+			t.Errorf("Expected status %d for reader %s, got %d: %s", status, path, w.Code, w.Body.String())
+		}
+	}
+	if w := get("/api/logs/7/raw", "admin", "password"); w.Code != http.StatusOK {
+		t.Errorf("Expected admins to read raw messages, got %d", w.Code)
+	}
+	if w := get("/api/logs", "reader", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for wrong reader password, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+// reencryptService serves an entry and records re-encryption jobs
+type reencryptService struct {
+	redactService
+	running bool
+}
+
+func (m *reencryptService) StartReencrypt() (*types.ReencryptStatus, error) {
+	if m.running {
+		return nil, interfaces.ErrReencryptRunning
+	}
+	m.running = true
+	return &types.ReencryptStatus{Running: true}, nil
+}
+
+func (m *reencryptService) ReencryptStatus() types.ReencryptStatus {
+	return types.ReencryptStatus{Running: m.running, Updated: 4}
+}
+
+func TestHTTPServer_FieldEncryption(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+	keys, err := fieldcrypt.NewKeyring([]fieldcrypt.Key{{ID: "k1", Key: base64.StdEncoding.EncodeToString(make([]byte, 32))}})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	encryptor := fieldcrypt.New([]string{"auth.token"}, keys)
+	entry := &types.LogEntry{ID: 7, Message: "login", StructuredData: map[string]interface{}{
+		"auth": map[string]interface{}{"token": "s3cret", "user": "alice"},
+	}}
+	encryptor.Seal(entry)
+	sealed := entry.StructuredData["auth"].(map[string]interface{})["token"].(string)
+
+	service := &reencryptService{redactService: redactService{entry: entry}}
+	server := NewHTTPServer(config, service)
+	server.SetFieldEncryption(encryptor)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	request := func(method, path, username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth(username, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/api/logs", "admin", "password")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, `"token":"s3cret"`) {
+		t.Errorf("Expected admins to see decrypted values, got %d: %s", w.Code, body)
+	}
+	if entry.StructuredData["auth"].(map[string]interface{})["token"] != sealed {
+		t.Errorf("Expected the stored entry to stay encrypted")
+	}
+	w = request(http.MethodGet, "/api/logs", "reader", "readonly")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, `"token":"[REDACTED]"`) || strings.Contains(body, sealed) {
+		t.Errorf("Expected readers to see encrypted values masked, got %d: %s", w.Code, body)
+	}
+	if w := request(http.MethodGet, "/api/logs?q=auth.token:s3cret", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected readers not to filter on encrypted fields, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/api/logs/7/raw", "admin", "password"); w.Code != http.StatusOK {
+		t.Errorf("Expected admins to read raw messages, got %d", w.Code)
+	}
+
+	w = request(http.MethodPost, "/api/admin/encryption/reencrypt", "admin", "password")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPost, "/api/admin/encryption/reencrypt", "admin", "password"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if w := request(http.MethodGet, "/api/admin/encryption/reencrypt", "admin", "password"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"updated":4`) {
+		t.Errorf("Unexpected status response %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodPost, "/api/admin/encryption/reencrypt", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected readers not to start re-encryption, got %d", w.Code)
+	}
+}
+
+// fieldsService records the search query and returns one full entry
+type fieldsService struct {
+	MockLogService
+	query types.SearchQuery
+}
+
+func (m *fieldsService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.query = query
+	return []*types.LogEntry{{ID: 3, Severity: 3, Hostname: "web01", Message: "disk full"}}, nil
+}
+
+func TestHTTPServer_SearchResultFields(t *testing.T) {
+	service := &fieldsService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+
+	w := httptest.NewRecorder()
+	server.handleLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?fields=severity,%20message", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(service.query.Fields) != 2 || service.query.Fields[1] != "message" {
+		t.Errorf("Expected fields to reach the query, got %v", service.query.Fields)
+	}
+
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data) != 1 || len(response.Data[0]) != 3 || response.Data[0]["message"] != "disk full" || response.Data[0]["id"] != float64(3) {
+		t.Errorf("Expected only id, severity and message, got %v", response.Data)
+	}
+
+	for _, fields := range []string{"raw_message", ",", "severity,bogus"} {
+		w = httptest.NewRecorder()
+		server.handleLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?fields="+fields, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for fields=%s, got %d", http.StatusBadRequest, fields, w.Code)
+		}
+	}
+}
+
+func TestHTTPServer_CollapsedSearch(t *testing.T) {
+	service := &collapseService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+
+	w := httptest.NewRecorder()
+	server.handleLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?collapse=true&fields=message&tz=Europe/Berlin", nil))
+	if w.Code != http.StatusOK || !service.query.Collapse {
+		t.Fatalf("Expected collapsed search, got %d (collapse %v): %s", w.Code, service.query.Collapse, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"repeat_count":4`) || !strings.Contains(body, `"first_timestamp":"2024-05-01T12:00:00+02:00"`) {
+		t.Errorf("Expected repeat information in the response: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs?collapse=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid collapse, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// collapseService returns one collapsed result
+type collapseService struct {
+	MockLogService
+	query types.SearchQuery
+}
+
+func (m *collapseService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.query = query
+	first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	last := first.Add(time.Minute)
+	return []*types.LogEntry{{ID: 9, Message: "retrying", Timestamp: last, RepeatCount: 4, FirstTimestamp: &first, LastTimestamp: &last}}, nil
+}
+
+// reportService keeps one scheduled report with a stored result
+type reportService struct {
+	MockLogService
+	created       *types.Report
+	updated       *types.Report
+	historyReport int64
+}
+
+func (m *reportService) CreateReport(report *types.Report) error {
+	if report.IntervalSeconds < 60 {
+		return fmt.Errorf("%w: interval too short", interfaces.ErrInvalidReport)
+	}
+	report.ID = 1
+	m.created = report
+	return nil
+}
+
+func (m *reportService) Reports() ([]types.Report, error) {
+	return []types.Report{{ID: 1, Name: "errors", Query: "auth.token=s3cret", TopField: "auth.token", IntervalSeconds: 3600}}, nil
+}
+
+func (m *reportService) UpdateReport(report *types.Report) error {
+	if report.IntervalSeconds < 60 {
+		return fmt.Errorf("%w: interval too short", interfaces.ErrInvalidReport)
+	}
+	if report.ID != 1 {
+		return interfaces.ErrReportNotFound
+	}
+	m.updated = report
+	return nil
+}
+
+func (m *reportService) DeleteReport(id int64) error {
+	if id != 1 {
+		return interfaces.ErrReportNotFound
+	}
+	return nil
+}
+
+func (m *reportService) ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	if id != 1 {
+		return nil, interfaces.ErrReportNotFound
+	}
+	return []types.ReportResult{{ReportID: 1, Count: 12, TopValues: []types.FacetValue{{Value: "s3cret", Count: 12}}}}, nil
+}
+
+func (m *reportService) AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	m.historyReport = reportID
+	return []types.AlertEvent{{
+		ID: 1, ReportID: 1, ReportName: "errors", State: types.AlertFiring, Count: 12, Threshold: 10,
+		Samples: []*types.LogEntry{{ID: 4, Message: "token s3cret rejected"}},
+	}}, nil
+}
+
+func TestHTTPServer_Reports(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+		RedactFields: []string{"auth.token"}, RedactPattern: `s3cret`,
+	}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleReports(w, httptest.NewRequest(http.MethodGet, "/api/reports", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &reportService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w = request(http.MethodPost, "/api/reports", `{"name": "errors", "query": "severity<=err", "top_field": "app_name", "interval_seconds": 3600}`, "admin", "password")
+	if w.Code != http.StatusCreated || service.created == nil || service.created.TopField != "app_name" {
+		t.Errorf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodPost, "/api/reports", `{"name": "fast", "interval_seconds": 1}`, "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid report, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request(http.MethodPost, "/api/reports", `{"name": "errors", "interval_seconds": 3600}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/reports/1/results?since=now-30d", "", "admin", "password")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"count":12`) || !strings.Contains(w.Body.String(), `"value":"s3cret"`) {
+		t.Errorf("Unexpected results response %d: %s", w.Code, w.Body.String())
+	}
+
+	// Readers see neither redacted top values nor redacted text in report queries
+	w = request(http.MethodGet, "/api/reports/1/results", "", "reader", "readonly")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"count":12`) || strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Unexpected reader results response %d: %s", w.Code, w.Body.String())
+	}
+	w = request(http.MethodGet, "/api/reports", "", "reader", "readonly")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Unexpected reader list response %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = request(http.MethodGet, "/api/reports/2/results", "", "admin", "password"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown report, got %d", http.StatusNotFound, w.Code)
+	}
+	if w = request(http.MethodGet, "/api/reports/1/results?limit=0", "", "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid limit, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = request(http.MethodPut, "/api/reports/1", `{"name": "errors", "query": "severity<=err", "interval_seconds": 600, "alert_threshold": 5}`, "admin", "password")
+	if w.Code != http.StatusOK || service.updated == nil || service.updated.ID != 1 || service.updated.AlertThreshold != 5 {
+		t.Errorf("Unexpected update response %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodPut, "/api/reports/1", `{"name": "errors", "interval_seconds": 1}`, "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid update, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request(http.MethodPut, "/api/reports/2", `{"name": "errors", "interval_seconds": 600}`, "admin", "password"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown report update, got %d", http.StatusNotFound, w.Code)
+	}
+	if w = request(http.MethodPut, "/api/reports/1", `{"name": "errors", "interval_seconds": 600}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader update, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodDelete, "/api/reports/1", "", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader delete, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodDelete, "/api/reports/1", "", "admin", "password"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d for delete, got %d", http.StatusOK, w.Code)
+	}
+	if w = request(http.MethodGet, "/api/reports/1", "", "admin", "password"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// exportService returns one security event
+type exportService struct {
+	MockLogService
+	query types.SearchQuery
+}
+
+func (m *exportService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.query = query
+	return []*types.LogEntry{{ID: 3, Facility: 4, Severity: 3, Hostname: "web01", AppName: "sshd", MsgID: "AUTHFAIL", Message: "failed password"}}, nil
+}
+
+func TestHTTPServer_Export(t *testing.T) {
+	service := &exportService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?q=app:sshd&fields=message", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "CEF:0|OpenTrail|OpenTrail|") || !strings.HasSuffix(w.Body.String(), "\n") {
+		t.Errorf("Unexpected CEF export %d: %q", w.Code, w.Body.String())
+	}
+	if len(service.query.Filters) != 1 || service.query.Fields != nil {
+		t.Errorf("Expected search filters without field selection, got %+v", service.query)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?format=ocsf", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" || !strings.Contains(w.Body.String(), `"class_name":"Base Event"`) {
+		t.Errorf("Unexpected OCSF export %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?format=leef", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unsupported format, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?format=rfc3164", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "<0>Jan  1 00:00:00 web01 sshd: failed password\n" {
+		t.Errorf("Unexpected RFC3164 export %d: %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?format=parquet", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/vnd.apache.parquet" ||
+		!strings.HasPrefix(body, "PAR1") || !strings.HasSuffix(body, "PAR1") {
+		t.Errorf("Unexpected Parquet export %d with content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	custom, err := logformat.New("brief", "{{severity .Severity}} {{.Hostname}} {{.Message}}", "")
+	if err != nil {
+		t.Fatalf("Failed to create output format: %v", err)
+	}
+	formats, _ := logformat.NewRegistry(custom)
+	server.SetOutputFormats(formats)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/export?format=brief", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "err web01 failed password\n" {
+		t.Errorf("Unexpected templated export %d: %q", w.Code, w.Body.String())
+	}
+}
+
+type notifyService struct {
+	MockLogService
+}
+
+func (m *notifyService) GetRecent(limit int) ([]*types.LogEntry, error) {
+	return []*types.LogEntry{{ID: 9, Severity: 3, Hostname: "web01", AppName: "api", Message: "upstream timeout"}}, nil
+}
+
+// recordingChannel is a notification channel that records what it was sent
+type recordingChannel struct {
+	name string
+	err  error
+	sent []*notify.Notification
+}
+
+func (c *recordingChannel) Name() string {
+	return c.name
+}
+
+func (c *recordingChannel) Send(ctx context.Context, notification *notify.Notification) error {
+	c.sent = append(c.sent, notification)
+	return c.err
+}
+
+func TestHTTPServer_Notifications(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &notifyService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/notifications/channels", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":[]`) {
+		t.Errorf("Expected an empty channel list, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ops := &recordingChannel{name: "ops"}
+	broken := &recordingChannel{name: "broken", err: fmt.Errorf("channel broken rejected notification with status 403")}
+	registry, err := notify.NewRegistry(ops, broken)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	server.SetNotificationChannels(registry)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/notifications/channels", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":["broken","ops"]`) {
+		t.Errorf("Unexpected channel list %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/notifications/test?channel=ops", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a test notification, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(ops.sent) != 1 || ops.sent[0].Count != 1 || ops.sent[0].Entries[0].Message != "upstream timeout" {
+		t.Errorf("Expected the recent entries to be sent, got %+v", ops.sent)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/notifications/test?channel=broken", nil))
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "403") {
+		t.Errorf("Expected a failed delivery to be reported, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/notifications/test?channel=pager", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown channel, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/notifications/test?channel=ops", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHTTPServer_AlertHistory(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `s3cret`,
+	}
+	service := &reportService{}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(target, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("/api/alerts/history?report=1&since=now-7d", "admin", "password")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"state":"firing"`) || !strings.Contains(w.Body.String(), "token s3cret rejected") {
+		t.Errorf("Unexpected alert history %d: %s", w.Code, w.Body.String())
+	}
+	if service.historyReport != 1 {
+		t.Errorf("Expected the history of report 1, got %d", service.historyReport)
+	}
+
+	// Readers get the samples redacted
+	w = request("/api/alerts/history", "reader", "readonly")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"report_name":"errors"`) || strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Unexpected reader alert history %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = request("/api/alerts/history?report=x", "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid report, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request("/api/alerts/history?since=someday", "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid since, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleAlertHistory(w, httptest.NewRequest(http.MethodGet, "/api/alerts/history", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+// stubConnections is a connection admin with one open connection
+type stubConnections struct {
+	closed []int64
+}
+
+func (c *stubConnections) Connections() []ConnectionInfo {
+	return []ConnectionInfo{{ID: 7, RemoteAddr: "10.0.0.5:51234", MessagesReceived: 12, BytesReceived: 960, ParseErrors: 2}}
+}
+
+func (c *stubConnections) CloseConnection(id int64) bool {
+	if id != 7 {
+		return false
+	}
+	c.closed = append(c.closed, id)
+	return true
+}
+
+func TestHTTPServer_Connections(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/connections", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without connection tracking, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	connections := &stubConnections{}
+	server.SetConnectionAdmin(connections)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/connections", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"remote_addr":"10.0.0.5:51234"`) || !strings.Contains(w.Body.String(), `"parse_errors":2`) {
+		t.Errorf("Unexpected connections response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/connections/7", nil))
+	if w.Code != http.StatusOK || len(connections.closed) != 1 {
+		t.Errorf("Expected connection 7 to be closed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for target, code := range map[string]int{"/api/admin/connections/8": http.StatusNotFound, "/api/admin/connections/x": http.StatusNotFound} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, target, nil))
+		if w.Code != code {
+			t.Errorf("Expected status %d for %s, got %d", code, target, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/connections/7", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHTTPServer_H2C(t *testing.T) {
+	startServer := func(t *testing.T, trustedProxies []string) string {
+		server, cleanup := setupTestHTTPServer(t)
+		t.Cleanup(cleanup)
+		server.config.HTTPPort = 0
+		server.config.HTTPH2C = true
+		server.config.TrustedProxies = trustedProxies
+		server.proxies = newProxyTrust(trustedProxies)
+
+		var addr string
+		server.SetListenFunc(func(network, address string) (net.Listener, error) {
+			listener, err := net.Listen(network, "127.0.0.1:0")
+			if err == nil {
+				addr = listener.Addr().String()
+			}
+			return listener, err
+		})
+		if err := server.Start(); err != nil {
+			t.Fatalf("Failed to start HTTP server: %v", err)
+		}
+		t.Cleanup(func() { server.Stop() })
+		return "http://" + addr + "/api/health"
+	}
+
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	h2cClient := &http.Client{Transport: &http.Transport{Protocols: h2c}}
+
+	t.Run("accepted", func(t *testing.T) {
+		resp, err := h2cClient.Get(startServer(t, nil))
+		if err != nil {
+			t.Fatalf("h2c request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+			t.Errorf("Expected a 200 over HTTP/2, got %d over %s", resp.StatusCode, resp.Proto)
+		}
+	})
+
+	t.Run("untrusted peer", func(t *testing.T) {
+		url := startServer(t, []string{"10.0.0.0/8"})
+		resp, err := h2cClient.Get(url)
+		if err != nil {
+			t.Fatalf("h2c request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected h2c from an untrusted peer to be rejected, got %d", resp.StatusCode)
+		}
+
+		// HTTP/1.1 from the same peer is unaffected
+		resp, err = http.Get(url)
+		if err != nil {
+			t.Fatalf("HTTP/1.1 request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+			t.Errorf("Expected a 200 over HTTP/1.1, got %d over %s", resp.StatusCode, resp.Proto)
+		}
+	})
+}
+
+func TestHTTPServer_RouteTimeouts(t *testing.T) {
+	server, cleanup := setupTestHTTPServer(t)
+	defer cleanup()
+	server.config.HTTPAPITimeout = 100 * time.Millisecond
+	server.config.HTTPExportTimeout = time.Second
+	server.config.HTTPStreamTimeout = 0
+
+	// Each route writes its response after the API timeout has passed
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/slow", slow)
+	mux.HandleFunc("/api/export", server.timeoutMiddleware(timeoutExport, slow))
+	mux.HandleFunc("/api/stream", server.timeoutMiddleware(timeoutStream, slow))
+	ts := httptest.NewServer(server.withTimeouts(mux))
+	defer ts.Close()
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := get("/api/slow"); err == nil {
+		t.Errorf("Expected the API request to exceed its deadline, got %q", body)
+	}
+	for _, path := range []string{"/api/export", "/api/stream"} {
+		if body, err := get(path); err != nil || body != "done" {
+			t.Errorf("Expected %s to outlast the API timeout, got %q, %v", path, body, err)
+		}
+	}
+}
+
+func TestHTTPServer_AccessLog(t *testing.T) {
+	config := &types.Config{
+		AuthEnabled:     true,
+		AuthUsername:    "admin",
+		AuthPassword:    "password",
+		AccessLogIngest: true,
+	}
+	mockService := &MockLogService{}
+	server := NewHTTPServer(config, mockService)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	handler := server.withAccessLog(mux)
+
+	// A valid client request ID is kept, an invalid one replaced
+	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	req.Header.Set(RequestIDHeader, "lb-7f3a:1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if id := w.Header().Get(RequestIDHeader); id != "lb-7f3a:1" {
+		t.Errorf("Expected the client request ID to be kept, got %q", id)
+	}
+
+	// Error responses carry the request ID
+	req = httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+	req.Header.Set(RequestIDHeader, `"><script>`)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	generated := w.Header().Get(RequestIDHeader)
+	if len(generated) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", generated)
+	}
+	var response APIResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusUnauthorized || response.RequestID != generated {
+		t.Errorf("Expected a 401 carrying request ID %q, got %d with %q", generated, w.Code, response.RequestID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/logs?limit=5", nil)
+	req.SetBasicAuth("admin", "password")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Every request was ingested as a structured access log entry
+	mockService.mutex.RLock()
+	defer mockService.mutex.RUnlock()
+	if len(mockService.processedLogs) != 3 {
+		t.Fatalf("Expected 3 access log entries, got %d", len(mockService.processedLogs))
+	}
+	logParser := parser.NewRFC5424Parser(true)
+	expected := []struct {
+		id, path, status, user string
+		severity               int
+	}{
+		{"lb-7f3a:1", "/api/health", "200", "-", 6},
+		{generated, "/api/logs", "401", "-", 4},
+		{"", "/api/logs", "200", "admin", 6},
+	}
+	for i, raw := range mockService.processedLogs {
+		entry, err := logParser.Parse(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse access log entry %q: %v", raw, err)
+		}
+		access, _ := entry.StructuredData[accessSDID].(map[string]string)
+		want := expected[i]
+		if entry.AppName != accessAppName || entry.Severity != want.severity || access["path"] != want.path ||
+			access["status"] != want.status || access["user"] != want.user || (want.id != "" && access["request_id"] != want.id) {
+			t.Errorf("Unexpected access log entry %d: %+v %v", i, entry, access)
+		}
+	}
+}
+
+func TestHTTPServer_Session(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+	server := NewHTTPServer(config, &MockLogService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	for _, tc := range []struct{ username, password, role string }{
+		{"admin", "password", roleAdmin},
+		{"reader", "readonly", roleReader},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/ui/session", nil)
+		req.SetBasicAuth(tc.username, tc.password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", tc.username, w.Code)
+		}
+
+		var response struct {
+			Data Session `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Data.User != tc.username || response.Data.Role != tc.role {
+			t.Errorf("Expected %s with role %s, got %+v", tc.username, tc.role, response.Data)
+		}
+	}
+}
+
+func TestHTTPServer_DemoIsPublicReadOnly(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080, Demo: true}, &MockLogService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/ui/session", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"`+roleReader+`"`) {
+		t.Errorf("Expected visitors of a demo to get the reader role, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/admin/mode", strings.NewReader(`{"mode": "normal"}`))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected admin endpoints of a demo to be forbidden, got %d", w.Code)
+	}
+}
+
+func TestHTTPServer_Shortcuts(t *testing.T) {
+	server, cleanup := setupTestHTTPServer(t)
+	defer cleanup()
+
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/ui/shortcuts", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Success bool       `json:"success"`
+		Data    []Shortcut `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Every action the interface handles is mapped, and no key triggers two actions
+	actions := make(map[string]bool)
+	keys := make(map[string]string)
+	for _, shortcut := range response.Data {
+		actions[shortcut.Action] = true
+		if len(shortcut.Keys) == 0 || shortcut.Description == "" {
+			t.Errorf("Shortcut %s has no keys or description", shortcut.Action)
+		}
+		for _, key := range shortcut.Keys {
+			if other, taken := keys[key]; taken {
+				t.Errorf("Key %q is bound to both %s and %s", key, other, shortcut.Action)
+			}
+			keys[key] = shortcut.Action
+		}
+	}
+	for _, action := range []string{"focus-search", "next-entry", "previous-entry", "toggle-details", "toggle-live-tail", "show-help", "close"} {
+		if !actions[action] {
+			t.Errorf("Expected a shortcut for %s", action)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/ui/shortcuts", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", w.Code)
+	}
+}
+
+type deleteService struct {
+	MockLogService
+	queries []types.SearchQuery
+	deleted int64
+}
+
+func (m *deleteService) DeleteMatching(query types.SearchQuery, dryRun bool) (int64, error) {
+	if dryRun {
+		return 3, nil
+	}
+	m.queries = append(m.queries, query)
+	m.deleted += 3
+	return 3, nil
+}
+
+func TestHTTPServer_DeleteLogs(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+	service := &deleteService{}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	send := func(method, path, username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth(username, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodDelete, "/api/admin/logs?hostname=web01&text=password&dry_run=true", "admin", "password")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for dry run, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data deleteResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.Matched == nil || *response.Data.Matched != 3 || response.Data.ConfirmToken == "" {
+		t.Fatalf("Expected a match count and confirm token, got %+v", response.Data)
+	}
+	token := response.Data.ConfirmToken
+	if service.deleted != 0 {
+		t.Errorf("Expected dry run not to delete, deleted %d", service.deleted)
+	}
+
+	for name, tc := range map[string]struct {
+		method, path, username string
+		status                 int
+	}{
+		"missing token":   {http.MethodDelete, "/api/admin/logs?hostname=web01&text=password", "admin", http.StatusBadRequest},
+		"other filters":   {http.MethodDelete, "/api/admin/logs?hostname=web02&text=password&confirm=" + token, "admin", http.StatusBadRequest},
+		"forged token":    {http.MethodDelete, "/api/admin/logs?hostname=web01&text=password&confirm=9999999999.abc", "admin", http.StatusBadRequest},
+		"no predicate":    {http.MethodDelete, "/api/admin/logs?dry_run=true", "admin", http.StatusBadRequest},
+		"invalid dry run": {http.MethodDelete, "/api/admin/logs?hostname=web01&dry_run=maybe", "admin", http.StatusBadRequest},
+		"wrong method":    {http.MethodGet, "/api/admin/logs?hostname=web01", "admin", http.StatusMethodNotAllowed},
+		"reader":          {http.MethodDelete, "/api/admin/logs?hostname=web01&dry_run=true", "reader", http.StatusForbidden},
+	} {
+		password := "password"
+		if tc.username == "reader" {
+			password = "readonly"
+		}
+		if w := send(tc.method, tc.path, tc.username, password); w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tc.status, w.Code, w.Body.String())
+		}
+	}
+	if service.deleted != 0 {
+		t.Fatalf("Expected rejected requests not to delete, deleted %d", service.deleted)
+	}
+
+	// Parameter order and paging do not change which entries are deleted
+	w = send(http.MethodDelete, "/api/admin/logs?text=password&limit=10&hostname=web01&confirm="+token, "admin", "password")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for delete, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	response.Data = deleteResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.Deleted == nil || *response.Data.Deleted != 3 {
+		t.Errorf("Expected 3 deleted entries, got %+v", response.Data)
+	}
+	if len(service.queries) != 1 || service.queries[0].Hostname != "web01" || service.queries[0].Text != "password" {
+		t.Errorf("Expected the search filters to be passed on, got %+v", service.queries)
+	}
+
+	if server.validDeleteToken(token, "hostname=web01&text=password", time.Now().Add(deleteTokenTTL+time.Minute)) {
+		t.Error("Expected the token to expire")
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleDeleteLogs(w, httptest.NewRequest(http.MethodDelete, "/api/admin/logs?hostname=web01&dry_run=true", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+type usageService struct {
+	MockLogService
+}
+
+func (m *usageService) StorageUsage() (*types.StorageUsage, error) {
+	return &types.StorageUsage{
+		DatabaseBytes: 300 << 20,
+		Days: []types.DayUsage{
+			{Day: "2024-01-01", Rows: 10, Bytes: 50 << 20},
+			{Day: "2024-01-02", Rows: 10, Bytes: 50 << 20},
+			{Day: time.Now().UTC().Format(types.DayLayout), Rows: 1, Bytes: 50 << 20},
+		},
+	}, nil
+}
+
+func TestHTTPServer_StorageUsage(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080, StorageLimitMB: 1000, RetentionDays: 30}
+	server := NewHTTPServer(config, &usageService{})
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/storage", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data types.StorageUsage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	usage := response.Data
+	// Completed days average 50 MiB of entries, doubled by the database's overhead
+	if usage.LimitBytes != 1000<<20 || usage.GrowthBytesPerDay != 100<<20 || usage.RetainedBytes != 3000<<20 {
+		t.Errorf("Unexpected projection: %+v", usage)
+	}
+	if usage.DaysUntilLimit == nil || *usage.DaysUntilLimit != 7 {
+		t.Errorf("Expected the limit to be reached in 7 days, got %v", usage.DaysUntilLimit)
+	}
+
+	// Retention keeps the database below the limit
+	config.RetentionDays = 5
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/storage", nil))
+	response.Data = types.StorageUsage{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.DaysUntilLimit != nil {
+		t.Errorf("Expected no limit to be reached within retention, got %v", *response.Data.DaysUntilLimit)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/storage", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	server = NewHTTPServer(config, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleStorageUsage(w, httptest.NewRequest(http.MethodGet, "/api/admin/storage", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+type checkpointService struct {
+	MockLogService
+	walDisabled bool
+}
+
+func (m *checkpointService) Checkpoint(mode string) (*types.CheckpointResult, error) {
+	if m.walDisabled {
+		return nil, interfaces.ErrWALDisabled
+	}
+	return &types.CheckpointResult{Mode: mode, WALFrames: 12, CheckpointedFrames: 12}, nil
+}
+
+func TestHTTPServer_Checkpoint(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	service := &checkpointService{}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	for target, mode := range map[string]string{
+		"/api/admin/storage/checkpoint":              types.CheckpointTruncate,
+		"/api/admin/storage/checkpoint?mode=passive": types.CheckpointPassive,
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var response struct {
+			Data types.CheckpointResult `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Data.Mode != mode || response.Data.CheckpointedFrames != 12 {
+			t.Errorf("Expected a %s checkpoint, got %+v", mode, response.Data)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/storage/checkpoint?mode=full", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown mode, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/storage/checkpoint", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for GET, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	service.walDisabled = true
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/storage/checkpoint", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without a write-ahead log, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	server = NewHTTPServer(config, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleCheckpoint(w, httptest.NewRequest(http.MethodPost, "/api/admin/storage/checkpoint", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+type volumeService struct {
+	MockLogService
+	query types.VolumeQuery
+}
+
+func (m *volumeService) MeasureVolume(now time.Time) error {
+	return nil
+}
+
+func (m *volumeService) Volume(query types.VolumeQuery) (*types.VolumeReport, error) {
+	m.query = query
+	return &types.VolumeReport{
+		GroupBy:  query.GroupBy,
+		StartDay: query.StartDay.Format(types.DayLayout),
+		EndDay:   query.EndDay.Format(types.DayLayout),
+		Days: []types.VolumeRow{
+			{Day: "2024-01-02", Group: "api", IngestedEntries: 2, IngestedBytes: 150, AvgIngestedBytes: 75, StoredEntries: 2, StoredBytes: 120},
+			{Day: "2024-01-02", Group: "web, edge", IngestedEntries: 1, IngestedBytes: 400, AvgIngestedBytes: 400},
+		},
+	}, nil
+}
+
+func TestHTTPServer_Volume(t *testing.T) {
+	service := &volumeService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/volume", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if service.query.GroupBy != types.VolumeByAppName || !service.query.EndDay.Equal(today) ||
+		!service.query.StartDay.Equal(today.AddDate(0, 0, -29)) {
+		t.Errorf("Expected the last 30 days by app, got %+v", service.query)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/volume?group_by=hostname&start=2024-01-01&end=2024-01-31&format=csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Errorf("Expected CSV, got %s", contentType)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "opentrail-volume-hostname-2024-01-01-2024-01-31.csv") {
+		t.Errorf("Unexpected Content-Disposition %s", disposition)
+	}
+	expected := "day,hostname,ingested_entries,ingested_bytes,stored_entries,stored_bytes,avg_ingested_bytes\n" +
+		"2024-01-02,api,2,150,2,120,75\n" +
+		"2024-01-02,\"web, edge\",1,400,0,0,400\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, w.Body.String())
+	}
+
+	for _, query := range []string{"group_by=severity", "start=yesterday", "start=2024-02-01&end=2024-01-01",
+		"start=2022-01-01&end=2024-01-01", "format=xml"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/volume?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleVolume(w, httptest.NewRequest(http.MethodGet, "/api/admin/volume", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+type latencyService struct {
+	MockLogService
+	reset bool
+}
+
+func (m *latencyService) IngestLatency() types.IngestLatency {
+	return types.IngestLatency{
+		Buckets: []float64{1, 10},
+		Sources: []types.SourceLatency{{
+			Source:    "10.0.0.5",
+			Entries:   3,
+			SenderLag: types.LatencyHistogram{Counts: []int64{0, 1, 2}, P95Seconds: 42},
+			ServerLag: types.LatencyHistogram{Counts: []int64{3, 0, 0}, P95Seconds: 0.2},
+		}},
+	}
+}
+
+func (m *latencyService) ResetIngestLatency() {
+	m.reset = true
+}
+
+func TestHTTPServer_IngestLatency(t *testing.T) {
+	service := &latencyService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/latency", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data types.IngestLatency `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data.Sources) != 1 || response.Data.Sources[0].SenderLag.P95Seconds != 42 {
+		t.Errorf("Unexpected latency report: %+v", response.Data)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/ingest/latency", nil))
+	if w.Code != http.StatusOK || !service.reset {
+		t.Errorf("Expected DELETE to reset the latencies, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/ingest/latency", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleIngestLatency(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/latency", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+type sequenceService struct {
+	MockLogService
+	reset bool
+}
+
+func (m *sequenceService) SequenceGaps() types.SequenceReport {
+	return types.SequenceReport{Sources: []types.SourceSequence{{
+		Hostname:     "web01",
+		AppName:      "nginx",
+		Entries:      10,
+		LastSequence: 12,
+		Missing:      2,
+		Gaps:         []types.SequenceGap{{First: 5, Last: 6, Missing: 2}},
+	}}}
+}
+
+func (m *sequenceService) ResetSequenceGaps() {
+	m.reset = true
+}
+
+func TestHTTPServer_SequenceGaps(t *testing.T) {
+	service := &sequenceService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/gaps", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data types.SequenceReport `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data.Sources) != 1 || len(response.Data.Sources[0].Gaps) != 1 || response.Data.Sources[0].Gaps[0].First != 5 {
+		t.Errorf("Unexpected gaps report: %+v", response.Data)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/ingest/gaps", nil))
+	if w.Code != http.StatusOK || !service.reset {
+		t.Errorf("Expected DELETE to reset the sequence numbering, got status %d", w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleSequenceGaps(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/gaps", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+func TestHTTPServer_Agents(t *testing.T) {
+	registry, err := agents.NewRegistry(agents.Config{
+		Name:  "web",
+		Files: []agents.FileConfig{{Path: "/var/log/app.log", Parser: agents.ParserRaw}},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	server.SetAgentRegistry(registry)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	ctx := context.Background()
+	client := agents.NewClient(httpServer.URL)
+	registered, err := client.Register(ctx, agents.Registration{Hostname: "web-01", Version: "1.0.0"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	config, err := client.Config(ctx, registered.ID)
+	if err != nil || config.Hash != registered.ConfigHash || len(config.Config.Files) != 1 {
+		t.Fatalf("Unexpected configuration %+v: %v", config, err)
+	}
+	response, err := client.Heartbeat(ctx, agents.Heartbeat{
+		ID:         registered.ID,
+		ConfigHash: config.Hash,
+		Positions:  []agents.TailPosition{{Path: "/var/log/app.log", Offset: 100, Size: 250}},
+	})
+	if err != nil || response.ConfigChanged {
+		t.Errorf("Unexpected heartbeat response %+v: %v", response, err)
+	}
+	if _, err := client.Heartbeat(ctx, agents.Heartbeat{ID: "unknown"}); err != agents.ErrUnknownAgent {
+		t.Errorf("Expected ErrUnknownAgent, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/agents", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var listed struct {
+		Data []agents.Status `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(listed.Data) != 1 || listed.Data[0].LagBytes != 150 || !listed.Data[0].ConfigCurrent || listed.Data[0].Version != "1.0.0" {
+		t.Errorf("Unexpected agents: %+v", listed.Data)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/agents/register", strings.NewReader("{")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid JSON, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleAgents(w, httptest.NewRequest(http.MethodGet, "/api/admin/agents", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+// compareService records comparison queries and returns an empty result
+type compareService struct {
+	MockLogService
+	query types.CompareQuery
+}
+
+func (m *compareService) Compare(query types.CompareQuery) (*types.CompareResult, error) {
+	m.query = query
+	return &types.CompareResult{Patterns: []types.PatternDelta{{Pattern: "failed <*>", Current: 1, Delta: 1, New: true}}}, nil
+}
+
+func TestHTTPServer_Compare(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	req := httptest.NewRequest(http.MethodGet, "/api/logs/compare", nil)
+	w := httptest.NewRecorder()
+	server.handleCompare(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &compareService{}
+	server = NewHTTPServer(config, service)
+
+	// The baseline defaults to the day before
+	req = httptest.NewRequest(http.MethodGet,
+		"/api/logs/compare?start_time=2024-01-02T10:00:00Z&end_time=2024-01-02T11:00:00Z&app_name=api&limit=5", nil)
+	w = httptest.NewRecorder()
+	server.handleCompare(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if want := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC); !service.query.BaselineStart.Equal(want) {
+		t.Errorf("Expected baseline start %v, got %v", want, service.query.BaselineStart)
+	}
+	if service.query.Search.AppName != "api" || service.query.Limit != 5 {
+		t.Errorf("Expected app_name api and limit 5, got %+v", service.query)
+	}
+	if service.query.Search.StartTime != nil || service.query.Search.Limit != 0 {
+		t.Errorf("Expected search range and limit to be cleared, got %+v", service.query.Search)
+	}
+
+	req = httptest.NewRequest(http.MethodGet,
+		"/api/logs/compare?start_time=2024-01-02T10:00:00Z&end_time=2024-01-02T11:00:00Z&baseline_offset=7d", nil)
+	w = httptest.NewRecorder()
+	server.handleCompare(w, req)
+	if want := time.Date(2023, 12, 26, 11, 0, 0, 0, time.UTC); !service.query.BaselineEnd.Equal(want) {
+		t.Errorf("Expected baseline end %v, got %v", want, service.query.BaselineEnd)
+	}
+
+	req = httptest.NewRequest(http.MethodGet,
+		"/api/logs/compare?baseline_start=2024-01-02T08:00:00Z&baseline_end=2024-01-02T09:00:00Z", nil)
+	w = httptest.NewRecorder()
+	server.handleCompare(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if want := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC); !service.query.BaselineStart.Equal(want) {
+		t.Errorf("Expected baseline start %v, got %v", want, service.query.BaselineStart)
+	}
+
+	invalid := []string{
+		"baseline_start=2024-01-02T08:00:00Z",
+		"baseline_start=2024-01-02T09:00:00Z&baseline_end=2024-01-02T08:00:00Z",
+		"baseline_start=2024-01-02T08:00:00Z&baseline_end=2024-01-02T09:00:00Z&baseline_offset=1d",
+		"baseline_offset=-1d",
+		"limit=0",
+		"limit=501",
+	}
+	for _, params := range invalid {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs/compare?"+params, nil)
+		w := httptest.NewRecorder()
+		server.handleCompare(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", params, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+// eventService keeps recorded event markers and records event queries
+type eventService struct {
+	MockLogService
+	recorded []types.Event
+	query    types.EventQuery
+}
+
+func (m *eventService) RecordEvent(event *types.Event) error {
+	if event.Service == "" {
+		return fmt.Errorf("%w: service is required", interfaces.ErrInvalidEvent)
+	}
+	event.ID = int64(len(m.recorded) + 1)
+	m.recorded = append(m.recorded, *event)
+	return nil
+}
+
+func (m *eventService) Events(query types.EventQuery) ([]types.Event, error) {
+	m.query = query
+	return []types.Event{{ID: 1, Type: types.EventDeploy, Service: "api", Description: "rotated s3cret"}}, nil
+}
+
+func (m *eventService) DeleteEvent(id int64) error {
+	if id != 1 {
+		return interfaces.ErrEventNotFound
+	}
+	return nil
+}
+
+func TestHTTPServer_Events(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `s3cret`,
+	}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleEvents(w, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &eventService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w = request(http.MethodPost, "/api/events", `{"service": "api", "version": "1.4.0", "time": "2024-05-01T12:00:00Z"}`, "admin", "password")
+	if w.Code != http.StatusCreated || len(service.recorded) != 1 || service.recorded[0].Version != "1.4.0" {
+		t.Errorf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodPost, "/api/events", `{"version": "1.4.0"}`, "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid event, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request(http.MethodPost, "/api/events", `{"service": "api"}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = request(http.MethodGet, "/api/events?start_time=2024-05-01T00:00:00Z&end_time=2024-05-02T00:00:00Z&service=api", "", "admin", "password")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Unexpected list response %d: %s", w.Code, w.Body.String())
+	}
+	if service.query.Service != "api" || service.query.Limit != defaultEventLimit || !service.query.EndTime.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected event query %+v", service.query)
+	}
+	w = request(http.MethodGet, "/api/events", "", "reader", "readonly")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Unexpected reader list response %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/events?limit=0", "", "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid limit, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w = request(http.MethodDelete, "/api/events/1", "", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader delete, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodDelete, "/api/events/2", "", "admin", "password"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown event, got %d", http.StatusNotFound, w.Code)
+	}
+	if w = request(http.MethodDelete, "/api/events/1", "", "admin", "password"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d for delete, got %d", http.StatusOK, w.Code)
+	}
+	if w = request(http.MethodGet, "/api/events/1", "", "admin", "password"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// modeService is a log service with an operating mode
+type modeService struct {
+	MockLogService
+	mode types.ModeStatus
+}
+
+func (m *modeService) Mode() types.ModeStatus {
+	return m.mode
+}
+
+func (m *modeService) SetMode(mode, message string) (types.ModeStatus, error) {
+	if mode != types.ModeNormal && mode != types.ModeReadOnly && mode != types.ModeMaintenance {
+		return types.ModeStatus{}, fmt.Errorf("%w: %q", interfaces.ErrInvalidMode, mode)
+	}
+	m.mode = types.ModeStatus{Mode: mode, Message: message}
+	return m.mode, nil
+}
+
+func TestHTTPServer_Mode(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+
+	service := &modeService{mode: types.ModeStatus{Mode: types.ModeNormal}}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(http.MethodPut, "/api/admin/mode", `{"mode": "read_only"}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for reader, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := request(http.MethodPut, "/api/admin/mode", `{"mode": "paused"}`, "admin", "password"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid mode, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := request(http.MethodPut, "/api/admin/mode", `{"mode": "read_only", "message": "restoring backup"}`, "admin", "password")
+	if w.Code != http.StatusOK || service.mode.Mode != types.ModeReadOnly {
+		t.Fatalf("Unexpected mode response %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/logs", "", "admin", "password"); w.Code != http.StatusOK {
+		t.Errorf("Expected searches to be served in read-only mode, got %d", w.Code)
+	}
+	w = request(http.MethodPost, "/api/agents/heartbeat", `{}`, "admin", "password")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "restoring backup") || !strings.Contains(w.Body.String(), `"code":"read_only"`) {
+		t.Errorf("Expected ingestion to be rejected with the notice, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = request(http.MethodPut, "/api/admin/mode", `{"mode": "maintenance"}`, "admin", "password"); w.Code != http.StatusOK {
+		t.Fatalf("Unexpected mode response %d: %s", w.Code, w.Body.String())
+	}
+	w = request(http.MethodGet, "/api/logs", "", "admin", "password")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected searches to be rejected in maintenance mode, got %d", w.Code)
+	}
+	if w = request(http.MethodGet, "/api/admin/mode", "", "admin", "password"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance"`) {
+		t.Errorf("Expected the admin endpoints to be served, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/health", "", "", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance"`) {
+		t.Errorf("Expected health to report the mode, got %d: %s", w.Code, w.Body.String())
+	}
+	// The degraded mode entered while storage is down keeps ingestion and rejects searches
+	service.mode = types.ModeStatus{Mode: types.ModeDegraded, Message: "disk I/O error"}
+	w = request(http.MethodGet, "/api/logs", "", "admin", "password")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"code":"degraded"`) || !strings.Contains(w.Body.String(), "disk I/O error") {
+		t.Errorf("Expected searches to be rejected in degraded mode, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodPost, "/api/agents/heartbeat", `{}`, "admin", "password"); w.Code == http.StatusServiceUnavailable {
+		t.Errorf("Expected ingestion to be accepted in degraded mode, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// recoveringService is a log service whose storage reports startup progress
+type recoveringService struct {
+	MockLogService
+	recovery types.RecoveryStatus
+}
+
+func (m *recoveringService) RecoveryStatus() types.RecoveryStatus {
+	return m.recovery
+}
+
+func TestHTTPServer_Ready(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	service := &recoveringService{recovery: types.RecoveryStatus{Phase: types.RecoveryWAL, WALFrames: 500}}
+	server := NewHTTPServer(config, service)
+	service.Start()
+
+	w := httptest.NewRecorder()
+	server.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"wal_frames":500`) {
+		t.Errorf("Expected 503 with the recovery progress, got %d: %s", w.Code, w.Body.String())
+	}
+
+	service.recovery = types.RecoveryStatus{Ready: true}
+	w = httptest.NewRecorder()
+	server.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d once recovered, got %d", http.StatusOK, w.Code)
+	}
+
+	// Storage that reports no recovery is ready once the service runs
+	server = NewHTTPServer(config, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d while the service is stopped, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
+func TestStartupServer_Probes(t *testing.T) {
+	status := types.RecoveryStatus{Phase: types.RecoveryRollups, EntriesRecovered: 10000, EntriesTotal: 50000}
+	startup := NewStartupServer(&types.Config{}, func() types.RecoveryStatus { return status })
+
+	// Liveness must pass while recovering, or the process would be restarted mid-recovery
+	w := httptest.NewRecorder()
+	startup.handleHealth(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"recovering"`) || !strings.Contains(w.Body.String(), `"entries_recovered":10000`) {
+		t.Errorf("Unexpected health response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	startup.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"phase":"rollups"`) {
+		t.Errorf("Unexpected readiness response %d: %s", w.Code, w.Body.String())
+	}
+}
+
+type entrySizeService struct {
+	MockLogService
+	reset bool
+}
+
+func (m *entrySizeService) EntrySizes() types.EntrySizes {
+	return types.EntrySizes{
+		Buckets: []int64{1024, 65536},
+		Apps: []types.AppEntrySizes{{
+			AppName: "api",
+			Entries: 20,
+			Message: types.SizeHistogram{Counts: []int64{19, 1, 0}, MaxBytes: 51200, P99Bytes: 51200},
+		}},
+	}
+}
+
+func (m *entrySizeService) ResetEntrySizes() {
+	m.reset = true
+}
+
+func TestHTTPServer_EntrySizes(t *testing.T) {
+	service := &entrySizeService{}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/sizes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data types.EntrySizes `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data.Apps) != 1 || response.Data.Apps[0].Message.P99Bytes != 51200 {
+		t.Errorf("Unexpected entry sizes: %+v", response.Data)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/admin/ingest/sizes", nil))
+	if w.Code != http.StatusOK || !service.reset {
+		t.Errorf("Expected DELETE to reset the sizes, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/ingest/sizes", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+	w = httptest.NewRecorder()
+	server.handleEntrySizes(w, httptest.NewRequest(http.MethodGet, "/api/admin/ingest/sizes", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+// clusterStreamStub is a cluster stream with a fixed status
+type clusterStreamStub struct {
+	status types.ClusterStatus
+}
+
+func (c *clusterStreamStub) Subscribe() <-chan *types.LogEntry {
+	return make(chan *types.LogEntry)
+}
+
+func (c *clusterStreamStub) Unsubscribe(subscription <-chan *types.LogEntry) {}
+
+func (c *clusterStreamStub) Status() types.ClusterStatus {
+	return c.status
+}
+
+func TestHTTPServer_ClusterStream(t *testing.T) {
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080}, &MockLogService{})
+
+	w := httptest.NewRecorder()
+	server.handleClusterStatus(w, httptest.NewRequest(http.MethodGet, "/api/admin/cluster", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without cluster peers, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	stream := &clusterStreamStub{status: types.ClusterStatus{Node: "west", Nodes: []types.ClusterNode{
+		{Name: "west", Local: true, Connected: true}, {Name: "east", URL: "https://east.example.com:8080"},
+	}}}
+	server.SetClusterStream(stream)
+	w = httptest.NewRecorder()
+	server.handleClusterStatus(w, httptest.NewRequest(http.MethodGet, "/api/admin/cluster", nil))
+	var response struct {
+		Data types.ClusterStatus `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the cluster status, got %d: %s", w.Code, w.Body.String())
+	}
+	if response.Data.Node != "west" || len(response.Data.Nodes) != 2 || response.Data.Nodes[1].Connected {
+		t.Errorf("Unexpected cluster status: %+v", response.Data)
+	}
+
+	// The cluster stream is the default, peers follow the local stream
+	for query, expected := range map[string]liveSource{"": stream, "?scope=cluster": stream, "?scope=local": server.logService} {
+		source, ok := server.liveSourceFor(httptest.NewRequest(http.MethodGet, "/api/logs/stream"+query, nil))
+		if !ok || source != expected {
+			t.Errorf("Unexpected live source for %q", query)
+		}
+	}
+	w = httptest.NewRecorder()
+	server.handleLogsStream(w, httptest.NewRequest(http.MethodGet, "/api/logs/stream?scope=all", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid scope, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// archiveSearchService pages through a fixed set of local entries
+type archiveSearchService struct {
+	MockLogService
+	local   []*types.LogEntry
+	queries []types.SearchQuery
+}
+
+func (m *archiveSearchService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.queries = append(m.queries, query)
+	start := min(query.Offset, len(m.local))
+	return m.local[start:min(start+query.Limit, len(m.local))], nil
+}
+
+// archiveStub returns fixed archived entries
+type archiveStub struct {
+	entries []*types.LogEntry
+	queries []types.SearchQuery
+}
+
+func (a *archiveStub) Search(ctx context.Context, query types.SearchQuery) (*types.ArchiveResult, error) {
+	a.queries = append(a.queries, query)
+	if query.StartTime.Before(time.Now().AddDate(-1, 0, 0)) {
+		return nil, fmt.Errorf("%w: 400 partitions", interfaces.ErrArchiveRangeTooLarge)
+	}
+	start := min(query.Offset, len(a.entries))
+	return &types.ArchiveResult{Entries: a.entries[start:min(start+query.Limit, len(a.entries))], Partitions: 3}, nil
+}
+
+func TestHTTPServer_ArchiveSearch(t *testing.T) {
+	service := &archiveSearchService{local: []*types.LogEntry{{ID: 30}, {ID: 29}}}
+	archive := &archiveStub{entries: []*types.LogEntry{{ID: 5, Archived: true}, {ID: 4, Archived: true}, {ID: 3, Archived: true}}}
+	server := NewHTTPServer(&types.Config{HTTPPort: 8080, RetentionDays: 30}, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	search := func(query string) (*httptest.ResponseRecorder, []int64) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs?"+query, nil))
+		var response struct {
+			Data []*types.LogEntry `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var ids []int64
+		for _, entry := range response.Data {
+			ids = append(ids, entry.ID)
+		}
+		return w, ids
+	}
+	since := url.QueryEscape(time.Now().AddDate(0, 0, -60).Format(time.RFC3339))
+
+	// Without an archive, asking for it is not supported
+	if w, _ := search("archive=true&start_time=" + since); w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without an archive, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	server.SetArchive(archive)
+	// Without -archive-auto, the archive is only searched when asked to
+	if _, ids := search("start_time=" + since); len(ids) != 2 || len(archive.queries) != 0 {
+		t.Errorf("Expected only local entries, got %v", ids)
+	}
+
+	// The page is completed with archived entries, the range split at the retention cutoff
+	w, ids := search("archive=true&limit=4&start_time=" + since)
+	if w.Code != http.StatusOK || !slices.Equal(ids, []int64{30, 29, 5, 4}) {
+		t.Fatalf("Expected local then archived entries, got %d %v", w.Code, ids)
+	}
+	local, archived := service.queries[len(service.queries)-1], archive.queries[0]
+	if !local.StartTime.Equal(archived.EndTime.Add(time.Nanosecond)) || archived.Limit != 2 || archived.Offset != 0 {
+		t.Errorf("Expected the range to be split at the cutoff, got local %v, archive until %v", local.StartTime, archived.EndTime)
+	}
+	if w.Header().Get("X-Archive-Partitions") != "3" || !strings.HasPrefix(w.Header().Get("Server-Timing"), "archive;dur=") {
+		t.Errorf("Expected the archive search to be reported in headers, got %v", w.Header())
+	}
+
+	// Pages past the local entries skip as many archived entries as needed
+	server.config.ArchiveAuto = true
+	if _, ids := search("limit=2&offset=3&start_time=" + since); !slices.Equal(ids, []int64{4, 3}) {
+		t.Errorf("Expected archived entries 4 and 3, got %v", ids)
+	}
+	if archived := archive.queries[len(archive.queries)-1]; archived.Offset != 1 {
+		t.Errorf("Expected an archive offset of 1, got %d", archived.Offset)
+	}
+
+	// Searches within retention do not read the archive
+	recent := url.QueryEscape(time.Now().AddDate(0, 0, -1).Format(time.RFC3339))
+	before := len(archive.queries)
+	if _, ids := search("archive=true&start_time=" + recent); len(ids) != 2 || len(archive.queries) != before {
+		t.Errorf("Expected only local entries within retention, got %v", ids)
+	}
+
+	tooOld := url.QueryEscape(time.Now().AddDate(-2, 0, 0).Format(time.RFC3339))
+	for _, query := range []string{
+		"archive=true",
+		"archive=maybe&start_time=" + since,
+		"archive=true&collapse=true&start_time=" + since,
+		"archive=true&start_time=" + tooOld,
+	} {
+		if w, _ := search(query); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+// scanService scans two entries, failing instead when err is set
+type scanService struct {
+	MockLogService
+	query types.ScanQuery
+	err   error
+}
+
+func (m *scanService) Scan(ctx context.Context, query types.ScanQuery, match func(*types.LogEntry) error, progress func(types.ScanProgress) error) (types.ScanProgress, error) {
+	m.query = query
+	status := types.ScanProgress{Scanned: 5000}
+	if m.err != nil {
+		return status, m.err
+	}
+	for id, message := range []string{"login failed", "token s3cret rejected"} {
+		status.Matched++
+		if err := match(&types.LogEntry{ID: int64(id + 1), Message: message}); err != nil {
+			return status, err
+		}
+	}
+	if err := progress(status); err != nil {
+		return status, err
+	}
+	status.Outcome = types.ScanComplete
+	return status, nil
+}
+
+func TestHTTPServer_Scan(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `s3cret`,
+	}
+	service := &scanService{}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(target, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	lines := func(w *httptest.ResponseRecorder) []map[string]any {
+		var events []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+			var event map[string]any
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Fatalf("Invalid line %q: %v", line, err)
+			}
+			events = append(events, event)
+		}
+		return events
+	}
+
+	w := request("/api/logs/scan?regex=s3c.et&start_time=2024-05-01T00:00:00Z&limit=10", "admin", "password")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	if service.query.Regex != "s3c.et" || service.query.Limit != 10 || service.query.StartTime == nil || service.query.Mask != nil {
+		t.Errorf("Unexpected query %+v", service.query)
+	}
+	events := lines(w)
+	if len(events) != 3 || events[0]["type"] != "match" || events[2]["type"] != "done" ||
+		events[2]["outcome"] != types.ScanComplete || events[2]["scanned"] != float64(5000) {
+		t.Errorf("Unexpected events %v", events)
+	}
+
+	// Readers get matches redacted and cannot scan raw messages
+	w = request("/api/logs/scan?text=token", "reader", "readonly")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "s3cret") || service.query.Limit != defaultScanLimit {
+		t.Errorf("Unexpected reader scan %d: %s", w.Code, w.Body.String())
+	}
+	if w = request("/api/logs/scan?text=token&raw=true", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a raw scan by a reader, got %d", http.StatusForbidden, w.Code)
+	}
+
+	// A reader's pattern is matched against masked messages, so it cannot confirm a redacted value
+	request("/api/logs/scan?regex=s3c.et", "reader", "readonly")
+	if service.query.Mask == nil || regexp.MustCompile(service.query.Regex).MatchString(service.query.Mask("token s3cret rejected")) {
+		t.Error("Expected a reader's scan to match masked messages")
+	}
+
+	// Failures after the response started end it with an error line
+	service.err = errors.New("disk I/O error")
+	events = lines(request("/api/logs/scan?text=token", "admin", "password"))
+	if len(events) != 1 || events[0]["type"] != "error" || strings.Contains(events[0]["error"].(string), "disk") {
+		t.Errorf("Unexpected events of a failed scan %v", events)
+	}
+
+	for _, target := range []string{"/api/logs/scan", "/api/logs/scan?regex=(", "/api/logs/scan?text=x&limit=0", "/api/logs/scan?text=x&raw=yes"} {
+		if w := request(target, "admin", "password"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, target, w.Code)
+		}
+	}
+}
+
+type preferencesService struct {
+	MockLogService
+	preferences map[string]types.UserPreferences
+}
+
+func (m *preferencesService) UserPreferences(user string) (*types.UserPreferences, error) {
+	preferences := m.preferences[user]
+	return &preferences, nil
+}
+
+func (m *preferencesService) SaveUserPreferences(user string, preferences *types.UserPreferences) error {
+	if len(preferences.PinnedFilters) > types.MaxPinnedFilters {
+		return fmt.Errorf("%w: too many pinned filters", interfaces.ErrInvalidPreferences)
+	}
+	m.preferences[user] = *preferences
+	return nil
+}
+
+func (m *preferencesService) AddRecentQuery(user string, query json.RawMessage) (*types.UserPreferences, error) {
+	preferences := m.preferences[user]
+	preferences.RecentQueries = append([]json.RawMessage{query}, preferences.RecentQueries...)
+	m.preferences[user] = preferences
+	return &preferences, nil
+}
+
+func (m *preferencesService) DeleteUserPreferences(user string) error {
+	delete(m.preferences, user)
+	return nil
+}
+
+func TestHTTPServer_Preferences(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handlePreferences(w, httptest.NewRequest(http.MethodGet, "/api/ui/preferences", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &preferencesService{preferences: make(map[string]types.UserPreferences)}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// Readers keep preferences of their own
+	w = request(http.MethodPut, "/api/ui/preferences", `{"display": {"compactMode": true}, "pinned_filters": [{"name": "errors", "filters": {"minSeverity": 3}}]}`, "reader", "readonly")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected save response %d: %s", w.Code, w.Body.String())
+	}
+	if saved := service.preferences["reader"]; string(saved.Display) != `{"compactMode": true}` || len(saved.PinnedFilters) != 1 {
+		t.Errorf("Expected the reader's preferences saved, got %+v", saved)
+	}
+	w = request(http.MethodPost, "/api/ui/preferences/recent", `{"text": "timeout"}`, "reader", "readonly")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"recent_queries":[{"text":"timeout"}]`) {
+		t.Errorf("Unexpected recent query response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/api/ui/preferences", "", "admin", "password")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "compactMode") {
+		t.Errorf("Expected the admin's preferences to be apart, got %d: %s", w.Code, w.Body.String())
+	}
+	w = request(http.MethodGet, "/api/ui/preferences", "", "reader", "readonly")
+	if !strings.Contains(w.Body.String(), "compactMode") || !strings.Contains(w.Body.String(), "timeout") {
+		t.Errorf("Expected the reader's preferences, got %s", w.Body.String())
+	}
+
+	pinned := make([]string, types.MaxPinnedFilters+1)
+	for i := range pinned {
+		pinned[i] = fmt.Sprintf(`{"name": "f%d", "filters": {}}`, i)
+	}
+	if w = request(http.MethodPut, "/api/ui/preferences", `{"pinned_filters": [`+strings.Join(pinned, ",")+`]}`, "reader", "readonly"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid preferences, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request(http.MethodPut, "/api/ui/preferences", `{"display":`, "reader", "readonly"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a malformed body, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = request(http.MethodPost, "/api/ui/preferences", "{}", "reader", "readonly"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	if w = request(http.MethodDelete, "/api/ui/preferences", "", "reader", "readonly"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "compactMode") {
+		t.Errorf("Expected the preferences reset, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Visitors of the demo share its preferences, which they cannot change
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080, Demo: true}, service)
+	mux = http.NewServeMux()
+	server.setupRoutes(mux)
+	if w = request(http.MethodPut, "/api/ui/preferences", `{}`, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d in the demo, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodPost, "/api/ui/preferences/recent", `{}`, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d in the demo, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodGet, "/api/ui/preferences", "", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d reading the demo's preferences, got %d", http.StatusOK, w.Code)
+	}
+}
+
+// shareService keeps shares in memory, serves the detail of entry 7 and finds entry 8 searching
+type shareService struct {
+	detailService
+	shares   map[string]types.Share
+	searched types.SearchQuery
+}
+
+func (m *shareService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	m.searched = query
+	return []*types.LogEntry{{ID: 8, Hostname: "web02", Message: "card 8765-4321"}}, nil
+}
+
+func (m *shareService) CreateShare(share *types.Share, ttl time.Duration) error {
+	if ttl > types.MaxShareTTL {
+		return fmt.Errorf("%w: expiry too long", interfaces.ErrInvalidShare)
+	}
+	share.Token = fmt.Sprintf("token%d", len(m.shares)+1)
+	share.EntryCount = len(share.Entries)
+	m.shares[share.Token] = *share
+	return nil
+}
+
+func (m *shareService) Share(token string) (*types.Share, error) {
+	share, ok := m.shares[token]
+	if !ok {
+		return nil, interfaces.ErrShareNotFound
+	}
+	return &share, nil
+}
+
+func (m *shareService) Shares() ([]types.Share, error) {
+	var shares []types.Share
+	for _, share := range m.shares {
+		share.Entries = nil
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+func (m *shareService) DeleteShare(token string) error {
+	delete(m.shares, token)
+	return nil
+}
+
+func TestHTTPServer_Shares(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, HTTPBasePath: "/logs", AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly", RedactPattern: `\d{4}-\d{4}`,
+	}
+
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleShares(w, httptest.NewRequest(http.MethodGet, "/api/shares", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &shareService{shares: make(map[string]types.Share)}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	type shareResult struct {
+		Data struct {
+			types.Share
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+
+	// Entries selected by ID, frozen unredacted whoever shares them
+	w = request(http.MethodPost, "/api/shares", `{"ids": [7], "description": "card leak", "expires_in_seconds": 3600}`, "reader", "readonly")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	var created shareResult
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Data.Token != "token1" || created.Data.URL != "/logs/api/shares/token1" || created.Data.Entries != nil || created.Data.EntryCount != 1 {
+		t.Errorf("Unexpected share %+v", created.Data)
+	}
+	if saved := service.shares["token1"]; saved.CreatedBy != "reader" || saved.Description != "card leak" || saved.Query != "" ||
+		saved.Entries[0].Message != "card 1234-5678" {
+		t.Errorf("Expected the entry shared by the reader, got %+v", saved)
+	}
+
+	// Entries of a search, with whole entries kept whatever fields were selected
+	w = request(http.MethodPost, "/api/shares?app_name=api&fields=message", `{}`, "admin", "password")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected create response %d: %s", w.Code, w.Body.String())
+	}
+	if saved := service.shares["token2"]; saved.Query != "app_name=api&fields=message" || len(saved.Entries) != 1 ||
+		service.searched.AppName != "api" || service.searched.Fields != nil {
+		t.Errorf("Expected the search results shared, got %+v after %+v", saved, service.searched)
+	}
+
+	for body, status := range map[string]int{
+		`{"ids": [9]}`:                                 http.StatusBadRequest,
+		`{"ids": [7], "expires_in_seconds": -1}`:       http.StatusBadRequest,
+		`{"ids": [7], "expires_in_seconds": 99999999}`: http.StatusBadRequest,
+		`{"ids":`: http.StatusBadRequest,
+	} {
+		if w = request(http.MethodPost, "/api/shares", body, "admin", "password"); w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, w.Code)
+		}
+	}
+
+	// Shares are opened masked for readers, and listed to their creators
+	w = request(http.MethodGet, "/api/shares/token1", "", "reader", "readonly")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "1234-5678") || !strings.Contains(w.Body.String(), "card leak") {
+		t.Errorf("Expected the share redacted for the reader, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/shares/token1", "", "admin", "password"); !strings.Contains(w.Body.String(), "1234-5678") {
+		t.Errorf("Expected the share unredacted for the admin, got %s", w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/shares/missing", "", "admin", "password"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown share, got %d", http.StatusNotFound, w.Code)
+	}
+	if w = request(http.MethodGet, "/api/shares", "", "reader", "readonly"); !strings.Contains(w.Body.String(), "token1") || strings.Contains(w.Body.String(), "token2") {
+		t.Errorf("Expected only the reader's shares listed, got %s", w.Body.String())
+	}
+	if w = request(http.MethodGet, "/api/shares", "", "admin", "password"); !strings.Contains(w.Body.String(), "token1") || !strings.Contains(w.Body.String(), "token2") {
+		t.Errorf("Expected every share listed for the admin, got %s", w.Body.String())
+	}
+
+	// Only creators and admins delete shares
+	if w = request(http.MethodDelete, "/api/shares/token2", "", "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d deleting another's share, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = request(http.MethodDelete, "/api/shares/token1", "", "reader", "readonly"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d deleting an own share, got %d", http.StatusOK, w.Code)
+	}
+	if _, ok := service.shares["token1"]; ok {
+		t.Error("Expected the share deleted")
+	}
+	if w = request(http.MethodPut, "/api/shares/token2", "", "admin", "password"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	server = NewHTTPServer(&types.Config{HTTPPort: 8080, Demo: true}, service)
+	mux = http.NewServeMux()
+	server.setupRoutes(mux)
+	if w = request(http.MethodPost, "/api/shares", `{"ids": [7]}`, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d in the demo, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+// tailBufferService holds entries in a tail buffer
+type tailBufferService struct {
+	MockLogService
+	query types.SearchQuery
+	err   error
+}
+
+func (m *tailBufferService) RecentBuffer(query types.SearchQuery) (*types.RecentBuffer, error) {
+	m.query = query
+	if m.err != nil {
+		return nil, m.err
+	}
+	since := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	return &types.RecentBuffer{
+		Entries:       []*types.LogEntry{{ID: 9, Timestamp: since, AppName: "api", Message: "buffered"}},
+		Buffered:      25,
+		Since:         &since,
+		WindowSeconds: 600,
+	}, nil
+}
+
+func TestHTTPServer_RecentBuffer(t *testing.T) {
+	config := &types.Config{HTTPPort: 8080}
+	server := NewHTTPServer(config, &MockLogService{})
+	w := httptest.NewRecorder()
+	server.handleRecentBuffer(w, httptest.NewRequest(http.MethodGet, "/api/logs/recent-buffer", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+
+	service := &tailBufferService{}
+	server = NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w = request(http.MethodGet, "/api/logs/recent-buffer?app_name=api&q=timeout&limit=20&fields=message")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	if service.query.AppName != "api" || service.query.Text != `"timeout"` || service.query.Limit != 20 {
+		t.Errorf("Expected the search parameters passed on, got %+v", service.query)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"entries":[{"id":9,"message":"buffered"}]`) || !strings.Contains(body, `"buffered":25`) ||
+		!strings.Contains(body, `"since":"2024-01-02T10:00:00Z"`) || !strings.Contains(body, `"window_seconds":600`) {
+		t.Errorf("Unexpected response: %s", body)
+	}
+
+	if w = request(http.MethodPost, "/api/logs/recent-buffer"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	service.err = fmt.Errorf("%w: collapsing repeated messages is not supported", interfaces.ErrInvalidBufferQuery)
+	if w = request(http.MethodGet, "/api/logs/recent-buffer?collapse=true"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	service.err = interfaces.ErrTailBufferDisabled
+	if w = request(http.MethodGet, "/api/logs/recent-buffer"); w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"opentrail/internal/interfaces"
+)
+
+// handleIngestLatency reports how late entries are received and how long they take to be committed,
+// per source (GET), or forgets the per-source latencies (DELETE)
+func (s *HTTPServer) handleIngestLatency(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reporter, ok := s.logService.(interfaces.IngestLatencyReporter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Ingestion latency is not supported")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		reporter.ResetIngestLatency()
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    reporter.IngestLatency(),
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"opentrail/internal/types"
+)
+
+// parseSeverityParams reads the severity and min_severity filters. Both accept a severity number
+// or a level name such as "error"; level and min_level are aliases for them, so the level of an
+// application log line is filtered the same way as the severity of a syslog message.
+func parseSeverityParams(params url.Values) (*int, *int, error) {
+	severity, err := parseSeverityParam(params, "severity", "level")
+	if err != nil {
+		return nil, nil, err
+	}
+	minSeverity, err := parseSeverityParam(params, "min_severity", "min_level")
+	if err != nil {
+		return nil, nil, err
+	}
+	return severity, minSeverity, nil
+}
+
+// parseSeverityParam reads the severity given by name or its level alias, which must agree when
+// both are given
+func parseSeverityParam(params url.Values, name, alias string) (*int, error) {
+	var result *int
+	for _, param := range []string{name, alias} {
+		value := params.Get(param)
+		if value == "" {
+			continue
+		}
+		severity, err := strconv.Atoi(value)
+		if err != nil {
+			var ok bool
+			if severity, ok = types.SeverityForLevel(value); !ok {
+				return nil, fmt.Errorf("invalid %s value: %q", param, value)
+			}
+		}
+		if result != nil && *result != severity {
+			return nil, fmt.Errorf("%s and %s must not conflict", name, alias)
+		}
+		result = &severity
+	}
+	return result, nil
+}
+
+// parseTrackingID returns the msg_id filter, which tracking_id is an alias for since the tracking
+// IDs of application log lines are stored as the RFC5424 MSGID
+func parseTrackingID(params url.Values) (string, error) {
+	msgID, trackingID := params.Get("msg_id"), params.Get("tracking_id")
+	if msgID != "" && trackingID != "" && msgID != trackingID {
+		return "", fmt.Errorf("msg_id and tracking_id must not conflict")
+	}
+	if msgID != "" {
+		return msgID, nil
+	}
+	return trackingID, nil
+}
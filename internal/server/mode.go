@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxModeRequestSize bounds the body of a mode change
+	maxModeRequestSize = 4096
+	// modeRetryAfter is the Retry-After, in seconds, suggested to clients rejected by the mode
+	modeRetryAfter = "60"
+)
+
+// modeRequest is the body of PUT /api/admin/mode
+type modeRequest struct {
+	Mode    string `json:"mode"`
+	Message string `json:"message"`
+}
+
+// handleMode returns the operating mode (GET) or switches it (PUT {"mode": "read_only", "message":
+// "restoring backup"}). The read-only mode rejects ingestion and keeps search up; the maintenance
+// mode also rejects searches, leaving only the health and admin endpoints.
+func (s *HTTPServer) handleMode(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	controller, ok := s.logService.(interfaces.ModeController)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Operating modes are not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    controller.Mode(),
+		})
+		return
+	}
+
+	var req modeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxModeRequestSize)).Decode(&req); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	status, err := controller.SetMode(req.Mode, req.Message)
+	if errors.Is(err, interfaces.ErrInvalidMode) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error switching to %s mode: %v", req.Mode, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to switch mode")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// currentMode returns the operating mode, normal if the log service has none
+func (s *HTTPServer) currentMode() types.ModeStatus {
+	if controller, ok := s.logService.(interfaces.ModeController); ok {
+		return controller.Mode()
+	}
+	return types.ModeStatus{Mode: types.ModeNormal}
+}
+
+// rejectedByMode answers requests of an endpoint class the operating mode does not serve with 503,
+// reporting whether it did: ingestion in the read-only and maintenance modes and searches in the
+// maintenance and degraded modes, with the mode as error code. Admin endpoints are always served so the mode can
+// be switched back.
+func (s *HTTPServer) rejectedByMode(w http.ResponseWriter, class endpointClass) bool {
+	mode := s.currentMode()
+	switch {
+	case class == classIngest && !mode.Accepting():
+	case class == classSearch && !mode.Searching():
+	default:
+		return false
+	}
+	w.Header().Set("Retry-After", modeRetryAfter)
+	s.sendError(w, http.StatusServiceUnavailable, mode.Mode, mode.Notice())
+	return true
+}
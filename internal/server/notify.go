@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"opentrail/internal/notify"
+)
+
+const (
+	// testNotificationEntries is the number of recent entries a test notification carries
+	testNotificationEntries = 5
+	// testNotificationTimeout bounds the delivery of a test notification
+	testNotificationTimeout = 15 * time.Second
+)
+
+// handleNotificationChannels lists the names of the configured notification channels
+func (s *HTTPServer) handleNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	names := s.notifications.Names()
+	if names == nil {
+		names = []string{}
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    names,
+	})
+}
+
+// handleTestNotification sends a notification with the most recent entries through one channel so
+// its webhook and template can be checked. Query parameter: channel
+func (s *HTTPServer) handleTestNotification(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := r.URL.Query().Get("channel")
+	channel, ok := s.notifications.Channel(name)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotFound, "Unknown notification channel")
+		return
+	}
+
+	entries, err := s.logService.GetRecent(testNotificationEntries)
+	if err != nil {
+		log.Printf("Error reading recent logs for test notification: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read recent logs")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), testNotificationTimeout)
+	defer cancel()
+	notification := &notify.Notification{
+		Rule:    notify.Rule{Name: "Test notification", Description: "Sent from the OpenTrail admin API"},
+		Entries: entries,
+		Count:   len(entries),
+		Time:    time.Now(),
+	}
+	if err := channel.Send(ctx, notification); err != nil {
+		log.Printf("Error sending test notification: %v", err)
+		s.sendErrorResponse(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"channel": name, "entries": len(entries)},
+	})
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// maxPreferencesRequestSize bounds the body of a request saving preferences or a recent query
+const maxPreferencesRequestSize = 65536
+
+// handlePreferences serves the web interface preferences of the requesting user: GET returns them,
+// PUT replaces them with a body like {"display": {...}, "pinned_filters": [{"name": "errors",
+// "filters": {...}}], "recent_queries": [{...}]} and DELETE resets them
+func (s *HTTPServer) handlePreferences(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.PreferencesManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "User preferences are not supported")
+		return
+	}
+	user := s.requestUser(r)
+
+	if r.Method != http.MethodGet && s.config.Demo {
+		s.sendErrorResponse(w, http.StatusForbidden, "Preferences cannot be changed in the demo")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var preferences types.UserPreferences
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPreferencesRequestSize)).Decode(&preferences); err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+		err := manager.SaveUserPreferences(user, &preferences)
+		if errors.Is(err, interfaces.ErrInvalidPreferences) {
+			s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Error saving preferences of user %q: %v", user, err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to save preferences")
+			return
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true, Data: preferences})
+		return
+	case http.MethodDelete:
+		if err := manager.DeleteUserPreferences(user); err != nil {
+			log.Printf("Error deleting preferences of user %q: %v", user, err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to reset preferences")
+			return
+		}
+	}
+
+	preferences, err := manager.UserPreferences(user)
+	if err != nil {
+		log.Printf("Error reading preferences of user %q: %v", user, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read preferences")
+		return
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true, Data: preferences})
+}
+
+// handleRecentQuery serves POST /api/ui/preferences/recent, recording the filters in the body as the
+// requesting user's most recent query and returning the updated preferences. Recording queries one
+// at a time keeps those searched in other browsers meanwhile.
+func (s *HTTPServer) handleRecentQuery(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.PreferencesManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "User preferences are not supported")
+		return
+	}
+	if s.config.Demo {
+		s.sendErrorResponse(w, http.StatusForbidden, "Preferences cannot be changed in the demo")
+		return
+	}
+
+	var query json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPreferencesRequestSize)).Decode(&query); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	user := s.requestUser(r)
+	preferences, err := manager.AddRecentQuery(user, query)
+	if errors.Is(err, interfaces.ErrInvalidPreferences) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording a recent query of user %q: %v", user, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to record the query")
+		return
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true, Data: preferences})
+}
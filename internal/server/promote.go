@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"opentrail/internal/interfaces"
+)
+
+// maxPromoteRequestSize bounds the body of a field promotion request
+const maxPromoteRequestSize = 4096
+
+// promoteFieldRequest is the body of POST /api/admin/fields/promoted
+type promoteFieldRequest struct {
+	Field string `json:"field"`
+}
+
+// handlePromotedFields lists promoted fields (GET) or promotes a new one (POST {"field": "sdid.param"}).
+// Promotion returns 202 Accepted because the index over existing entries is built in the background.
+func (s *HTTPServer) handlePromotedFields(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	promoter, ok := s.logService.(interfaces.FieldPromoter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Field promotion is not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		fields, err := promoter.PromotedFields()
+		if err != nil {
+			log.Printf("Error listing promoted fields: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list promoted fields")
+			return
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    fields,
+		})
+		return
+	}
+
+	var req promoteFieldRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPromoteRequestSize)).Decode(&req); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	promoted, err := promoter.PromoteField(req.Field)
+	if errors.Is(err, interfaces.ErrInvalidPromotion) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error promoting field %s: %v", req.Field, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to promote field")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data:    promoted,
+	})
+}
+
+// handleDemoteField drops the column of a promoted field, served at DELETE /api/admin/fields/promoted/{field}
+func (s *HTTPServer) handleDemoteField(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	field := strings.TrimPrefix(r.URL.Path, "/api/admin/fields/promoted/")
+	if field == "" || strings.Contains(field, "/") {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	promoter, ok := s.logService.(interfaces.FieldPromoter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Field promotion is not supported")
+		return
+	}
+
+	err := promoter.DemoteField(field)
+	if errors.Is(err, interfaces.ErrFieldNotPromoted) {
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error demoting field %s: %v", field, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to demote field")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    promoteFieldRequest{Field: field},
+	})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// proxyTrust decides which forwarding headers to believe based on the configured trusted proxies
+type proxyTrust struct {
+	prefixes []netip.Prefix
+}
+
+// newProxyTrust parses trusted proxy CIDRs or bare IPs; invalid entries are rejected by config validation
+func newProxyTrust(cidrs []string) *proxyTrust {
+	trust := &proxyTrust{}
+	for _, cidr := range cidrs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			trust.prefixes = append(trust.prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(cidr); err == nil {
+			addr = addr.Unmap()
+			trust.prefixes = append(trust.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return trust
+}
+
+// trusted reports whether the given normalized IP belongs to a trusted proxy
+func (p *proxyTrust) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the normalized address of the client that sent the request. Forwarding headers are
+// only honoured when the request arrived from a trusted proxy; X-Forwarded-For is walked from the right,
+// skipping further trusted proxies, so a client cannot spoof its address by sending the header itself.
+func (p *proxyTrust) clientIP(r *http.Request) string {
+	remote := normalizeSourceIP(r.RemoteAddr)
+	if !p.trusted(remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := normalizeSourceIP(strings.TrimSpace(hops[i]))
+			if hop == "" {
+				break
+			}
+			if !p.trusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+
+	if realIP := normalizeSourceIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != "" {
+		return realIP
+	}
+
+	return remote
+}
+
+// requestHost returns the host the client addressed, honouring X-Forwarded-Host from trusted proxies
+func (p *proxyTrust) requestHost(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" && p.trusted(normalizeSourceIP(r.RemoteAddr)) {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return r.Host
+}
+
+// originChecker returns a WebSocket CheckOrigin function. Requests without an Origin header come from
+// non-browser clients and are allowed; browser requests must be same-origin or explicitly allowed.
+func originChecker(allowedOrigins []string, trust *proxyTrust) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+				return true
+			}
+		}
+
+		parsed, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(parsed.Host, trust.requestHost(r))
+	}
+}
+
+// withBasePath serves handler below a path prefix, e.g. "/logs", and redirects the bare prefix to "/logs/"
+func withBasePath(basePath string, handler http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(basePath+"/", http.StripPrefix(basePath, handler))
+	mux.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+	return mux
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"opentrail/internal/types"
+)
+
+func TestProxyTrust_ClientIP(t *testing.T) {
+	trust := newProxyTrust([]string{"10.0.0.0/8", "192.168.1.5"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted client spoofing header", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 192.168.1.5"}, "198.51.100.1"},
+		{"real ip header", "192.168.1.5:5000", map[string]string{"X-Real-IP": "2001:db8::1"}, "2001:db8::1"},
+		{"trusted proxy without headers", "10.1.2.3:5000", nil, "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := trust.clientIP(req); got != tt.expected {
+				t.Errorf("clientIP() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOriginChecker(t *testing.T) {
+	check := originChecker([]string{"https://dash.example.com"}, newProxyTrust([]string{"10.0.0.0/8"}))
+
+	tests := []struct {
+		name       string
+		host       string
+		origin     string
+		remoteAddr string
+		forwarded  string
+		expected   bool
+	}{
+		{"no origin", "logs.example.com", "", "203.0.113.7:5000", "", true},
+		{"same origin", "logs.example.com", "https://logs.example.com", "203.0.113.7:5000", "", true},
+		{"allowed origin", "logs.example.com", "https://dash.example.com", "203.0.113.7:5000", "", true},
+		{"foreign origin", "logs.example.com", "https://evil.example.com", "203.0.113.7:5000", "", false},
+		{"forwarded host from trusted proxy", "opentrail:8080", "https://logs.example.com", "10.0.0.2:5000", "logs.example.com", true},
+		{"forwarded host from untrusted client", "opentrail:8080", "https://evil.example.com", "203.0.113.7:5000", "evil.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/logs/stream", nil)
+			req.Host = tt.host
+			req.RemoteAddr = tt.remoteAddr
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Host", tt.forwarded)
+			}
+
+			if got := check(req); got != tt.expected {
+				t.Errorf("CheckOrigin() = %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_BasePath(t *testing.T) {
+	config := &types.Config{HTTPBasePath: "/logs"}
+	staticFS := fstest.MapFS{
+		"index.html": {Data: []byte(`<html><head><script src="/static/app.js"></script></head></html>`)},
+	}
+	server := NewHTTPServerWithStaticFiles(config, &MockLogService{}, staticFS)
+
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	handler := withBasePath(config.HTTPBasePath, mux)
+
+	// The bare prefix redirects to the UI
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/logs/" {
+		t.Errorf("Expected redirect to /logs/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// The index page references assets and the API below the prefix
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `src="/logs/static/app.js"`) {
+		t.Errorf("Expected rewritten asset path, got %s", body)
+	}
+	if !strings.Contains(string(body), `window.__OPENTRAIL_BASE_PATH__ = "/logs"`) {
+		t.Errorf("Expected base path to be exposed to the UI, got %s", body)
+	}
+
+	// API routes are served below the prefix and not at the root
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/api/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected health endpoint below base path, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 outside base path, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// proxyV1MaxLength is the maximum length of a PROXY protocol v1 header including CRLF
+	proxyV1MaxLength = 107
+	// proxyV2HeaderLength is the length of the fixed part of a PROXY protocol v2 header
+	proxyV2HeaderLength = 16
+)
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// readProxyHeader consumes a PROXY protocol v1 or v2 header from the start of a connection
+// and returns the original client address. A nil address means the proxy did not convey one
+// (v1 UNKNOWN, v2 LOCAL or an unsupported address family) and the connection address applies.
+func readProxyHeader(reader *bufio.Reader) (*net.TCPAddr, error) {
+	prefix, err := reader.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(prefix, proxyV2Signature) {
+		return readProxyV2Header(reader)
+	}
+	if len(prefix) >= 6 && string(prefix[:6]) == "PROXY " {
+		return readProxyV1Header(reader)
+	}
+	if err != nil && len(prefix) < 6 {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+	return nil, fmt.Errorf("missing PROXY protocol header")
+}
+
+// readProxyV1Header parses a human-readable header, e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 5000 514\r\n"
+func readProxyV1Header(reader *bufio.Reader) (*net.TCPAddr, error) {
+	var line []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLength {
+			return nil, fmt.Errorf("PROXY protocol v1 header too long")
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("PROXY protocol v1 header not terminated by CRLF")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 header")
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid PROXY protocol source address: %s", fields[2])
+	}
+	if (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("PROXY protocol source address %s does not match %s", fields[2], fields[1])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol source port: %s", fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2Header parses a binary header as described in the PROXY protocol specification, section 2.2
+func readProxyV2Header(reader *bufio.Reader) (*net.TCPAddr, error) {
+	header := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", header[12]>>4)
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol addresses: %w", err)
+	}
+
+	switch command {
+	case 0x0:
+		// LOCAL: health check or connection initiated by the proxy itself
+		return nil, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol command %d", command)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("PROXY protocol IPv4 address block too short")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("PROXY protocol IPv6 address block too short")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// UDP, UNIX sockets and unspecified families carry no usable TCP source
+		return nil, nil
+	}
+}
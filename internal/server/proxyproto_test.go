@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func proxyV2Header(command, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestReadProxyHeader_V1(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.10 198.51.100.1 51234 2253\r\n<134>1 - - - - - - hello\n"))
+
+	addr, err := readProxyHeader(reader)
+	if err != nil {
+		t.Fatalf("readProxyHeader failed: %v", err)
+	}
+	if addr.String() != "192.0.2.10:51234" {
+		t.Errorf("Expected client address 192.0.2.10:51234, got %s", addr)
+	}
+
+	// The payload following the header must be left intact
+	line, _ := reader.ReadString('\n')
+	if line != "<134>1 - - - - - - hello\n" {
+		t.Errorf("Expected log line after header, got %q", line)
+	}
+}
+
+func TestReadProxyHeader_V1Unknown(t *testing.T) {
+	addr, err := readProxyHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n")))
+	if err != nil {
+		t.Fatalf("readProxyHeader failed: %v", err)
+	}
+	if addr != nil {
+		t.Errorf("Expected no client address for UNKNOWN, got %s", addr)
+	}
+}
+
+func TestReadProxyHeader_V2(t *testing.T) {
+	addresses := make([]byte, 36)
+	copy(addresses[0:16], net.ParseIP("2001:db8::1"))
+	copy(addresses[16:32], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(addresses[32:34], 40000)
+	binary.BigEndian.PutUint16(addresses[34:36], 2253)
+
+	reader := bufio.NewReader(strings.NewReader(string(proxyV2Header(0x1, 0x21, addresses)) + "hello\n"))
+	addr, err := readProxyHeader(reader)
+	if err != nil {
+		t.Fatalf("readProxyHeader failed: %v", err)
+	}
+	if addr.String() != "[2001:db8::1]:40000" {
+		t.Errorf("Expected client address [2001:db8::1]:40000, got %s", addr)
+	}
+
+	// LOCAL connections keep the proxy's own address
+	addr, err = readProxyHeader(bufio.NewReader(strings.NewReader(string(proxyV2Header(0x0, 0x00, nil)))))
+	if err != nil || addr != nil {
+		t.Errorf("Expected LOCAL command to yield no address, got %v, %v", addr, err)
+	}
+}
+
+func TestReadProxyHeader_Invalid(t *testing.T) {
+	inputs := []string{
+		"<134>1 2024-01-01T00:00:00Z host app - - - no header\n",
+		"PROXY TCP4 192.0.2.10 198.51.100.1 51234\r\n",
+		"PROXY TCP4 2001:db8::1 198.51.100.1 51234 2253\r\n",
+		"PROXY TCP4 192.0.2.10 198.51.100.1 51234 2253\n",
+		"PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n",
+	}
+
+	for _, input := range inputs {
+		if _, err := readProxyHeader(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+// sourceRecordingService records the source IP passed along with each message
+type sourceRecordingService struct {
+	MockLogService
+	sources []string
+	mutex   sync.Mutex
+}
+
+func (m *sourceRecordingService) ProcessLogFrom(rawMessage, sourceIP string) error {
+	m.mutex.Lock()
+	m.sources = append(m.sources, sourceIP)
+	m.mutex.Unlock()
+	return m.ProcessLog(rawMessage)
+}
+
+func TestTCPServer_ProxyProtocol(t *testing.T) {
+	config := &types.Config{
+		TCPPort:          0, // Use random port
+		TCPBindAddress:   "127.0.0.1",
+		MaxConnections:   10,
+		TCPProxyProtocol: true,
+		TCPProxyTrusted:  []string{"127.0.0.1"},
+	}
+
+	mockService := &sourceRecordingService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 127.0.0.1 40000 2253\r\nproxied message\n"))
+	conn.Close()
+
+	// A connection without a header is rejected
+	conn, err = net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	conn.Write([]byte("direct message\n"))
+	conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	processed := mockService.GetProcessedLogs()
+	if len(processed) != 1 || processed[0] != "proxied message" {
+		t.Fatalf("Expected only the proxied message to be processed, got %v", processed)
+	}
+
+	mockService.mutex.Lock()
+	defer mockService.mutex.Unlock()
+	if len(mockService.sources) != 1 || mockService.sources[0] != "203.0.113.7" {
+		t.Errorf("Expected source IP from PROXY header, got %v", mockService.sources)
+	}
+}
+
+func TestTCPServer_ProxyProtocolUntrustedPeer(t *testing.T) {
+	config := &types.Config{
+		TCPPort:          0, // Use random port
+		TCPBindAddress:   "127.0.0.1",
+		MaxConnections:   10,
+		TCPProxyProtocol: true,
+		TCPProxyTrusted:  []string{"10.0.0.0/8"},
+	}
+
+	mockService := &sourceRecordingService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	// A peer outside the trusted CIDRs cannot pick its own source address
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 127.0.0.1 40000 2253\r\nforged message\n"))
+	conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if processed := mockService.GetProcessedLogs(); len(processed) != 0 {
+		t.Errorf("Expected the untrusted connection to be rejected, got %v", processed)
+	}
+	if stats := server.GetStats(); stats.ConnectionErrors != 1 {
+		t.Errorf("Expected 1 connection error, got %d", stats.ConnectionErrors)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// endpointClass groups HTTP endpoints that share rate and request size limits
+type endpointClass string
+
+const (
+	classSearch endpointClass = "search"
+	classIngest endpointClass = "ingest"
+	classAdmin  endpointClass = "admin"
+)
+
+// maxRateLimitClients bounds the number of tracked clients per class before idle buckets are swept
+const maxRateLimitClients = 10000
+
+// endpointLimits holds the limits applied to one endpoint class
+type endpointLimits struct {
+	// perMinute is the number of requests a single client may make per minute (0 disables)
+	perMinute int
+	// maxBody is the maximum request body size in bytes (0 disables)
+	maxBody int64
+}
+
+// tokenBucket allows bursts of up to capacity requests, refilled continuously over a minute
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter tracks a token bucket per client for a single endpoint class
+type rateLimiter struct {
+	perMinute int
+	buckets   map[string]*tokenBucket
+	mutex     sync.Mutex
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether client may make a request now, and if not, how long until it may retry
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	capacity := float64(l.perMinute)
+	rate := capacity / time.Minute.Seconds()
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.sweep(now)
+		}
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, since they carry no state
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= time.Minute {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// limitMiddleware enforces the rate and request size limits of an endpoint class and rejects its
+// requests while the operating mode does not serve it. The routes of a class draw from the same
+// rate limit.
+func (s *HTTPServer) limitMiddleware(class endpointClass, next http.HandlerFunc) http.HandlerFunc {
+	limits := s.limits[class]
+	limiter := s.limiters[class]
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.rejectedByMode(w, class) {
+			return
+		}
+
+		if limiter != nil {
+			if ok, wait := limiter.allow(s.proxies.clientIP(r), time.Now()); !ok {
+				s.updateStats(func(stats *HTTPServerStats) {
+					stats.RateLimited++
+				})
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				s.sendErrorResponse(w, http.StatusTooManyRequests, fmt.Sprintf("Rate limit exceeded for %s requests", class))
+				return
+			}
+		}
+
+		if limits.maxBody > 0 {
+			if r.ContentLength > limits.maxBody {
+				s.sendErrorResponse(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body exceeds %d bytes", limits.maxBody))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limits.maxBody)
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter(60) // one request per second, bursts of 60
+	now := time.Now()
+
+	for i := 0; i < 60; i++ {
+		if ok, _ := limiter.allow("192.0.2.1", now); !ok {
+			t.Fatalf("Request %d within burst was rejected", i+1)
+		}
+	}
+
+	ok, wait := limiter.allow("192.0.2.1", now)
+	if ok {
+		t.Fatal("Expected request beyond burst to be rejected")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("Expected retry within a second, got %v", wait)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := limiter.allow("192.0.2.2", now); !ok {
+		t.Error("Expected a different client to be allowed")
+	}
+
+	// Tokens refill over time
+	if ok, _ := limiter.allow("192.0.2.1", now.Add(time.Second)); !ok {
+		t.Error("Expected request to be allowed after refill")
+	}
+}
+
+func TestHTTPServer_LimitMiddleware(t *testing.T) {
+	config := &types.Config{AdminRateLimit: 2, AdminMaxBodyBytes: 16}
+	server := NewHTTPServer(config, &MockLogService{})
+
+	handler := server.limitMiddleware(classAdmin, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/admin/integrity", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to succeed, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/admin/integrity", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if server.GetStats().RateLimited != 1 {
+		t.Errorf("Expected 1 rate limited request, got %d", server.GetStats().RateLimited)
+	}
+
+	// Oversized bodies are rejected regardless of the rate limit
+	server = NewHTTPServer(&types.Config{AdminMaxBodyBytes: 16}, &MockLogService{})
+	handler = server.limitMiddleware(classAdmin, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/admin/integrity", strings.NewReader(strings.Repeat("x", 32))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", rec.Code)
+	}
+}
+
+func TestHTTPServer_LimitMiddlewareSharedPerClass(t *testing.T) {
+	server := NewHTTPServer(&types.Config{AdminRateLimit: 2, SearchRateLimit: 10}, &MockLogService{})
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	integrity := server.limitMiddleware(classAdmin, ok)
+	connections := server.limitMiddleware(classAdmin, ok)
+	logs := server.limitMiddleware(classSearch, ok)
+
+	codes := make([]int, 0, 3)
+	for _, handler := range []http.HandlerFunc{integrity, connections, integrity} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/admin/integrity", nil))
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected the admin routes to share a limit of 2, got %v", codes)
+	}
+
+	rec := httptest.NewRecorder()
+	connections(rec, httptest.NewRequest(http.MethodGet, "/api/admin/connections", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second admin route to be limited too, got %d", rec.Code)
+	}
+
+	// Other classes keep their own limit
+	rec = httptest.NewRecorder()
+	logs(rec, httptest.NewRequest(http.MethodGet, "/api/logs", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a search route to be unaffected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"opentrail/internal/interfaces"
+)
+
+// rawMessageResponse is the body of GET /api/logs/{id}/raw
+type rawMessageResponse struct {
+	ID  int64  `json:"id"`
+	Raw string `json:"raw"`
+}
+
+// handleRawMessage returns the message of an entry exactly as received, served at /api/logs/{id}/raw
+func (s *HTTPServer) handleRawMessage(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	idStr, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/logs/"), "/raw")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if !ok || err != nil || id < 1 {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	// Raw messages cannot be redacted reliably, so readers only see them when nothing is redacted
+	if s.redactorFor(r).restricts() {
+		s.sendErrorResponse(w, http.StatusForbidden, "Raw messages are not available to readers while redaction is enabled")
+		return
+	}
+
+	reader, ok := s.logService.(interfaces.RawMessageReader)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Raw messages are not supported")
+		return
+	}
+
+	raw, err := reader.RawMessage(id)
+	if errors.Is(err, interfaces.ErrEntryNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, "Log entry not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading raw message of entry %d: %v", id, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read raw message")
+		return
+	}
+	if raw == "" {
+		s.sendErrorResponse(w, http.StatusNotFound, "No raw message was retained for this entry")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    rawMessageResponse{ID: id, Raw: raw},
+	})
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// ReadinessResponse is the body of /api/ready
+type ReadinessResponse struct {
+	Ready    bool                 `json:"ready"`
+	Recovery types.RecoveryStatus `json:"recovery"`
+}
+
+// recoveryStatus returns the progress of the storage's startup work, ready if it reports none
+func (s *HTTPServer) recoveryStatus() types.RecoveryStatus {
+	if reporter, ok := s.logService.(interfaces.RecoveryReporter); ok {
+		return reporter.RecoveryStatus()
+	}
+	return types.RecoveryStatus{Ready: true}
+}
+
+// handleReady answers readiness probes: 200 once storage has finished its startup work and the log
+// service runs, 503 otherwise
+func (s *HTTPServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	response := ReadinessResponse{Recovery: s.recoveryStatus()}
+	response.Ready = response.Recovery.Ready && s.logService.GetStats().IsRunning
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	s.sendJSONResponse(w, status, response)
+}
+
+// StartupServer answers health and readiness probes on the HTTP address while storage is opened,
+// before the HTTP server can start. After a crash that can take minutes, and without it
+// orchestrators would take the silent port for a hung process and restart it mid-recovery.
+type StartupServer struct {
+	config *types.Config
+	status func() types.RecoveryStatus
+
+	server *http.Server
+	wg     sync.WaitGroup
+}
+
+// NewStartupServer creates a startup server reporting the progress status returns
+func NewStartupServer(config *types.Config, status func() types.RecoveryStatus) *StartupServer {
+	return &StartupServer{config: config, status: status}
+}
+
+// Start binds the HTTP address and serves probes until Stop
+func (s *StartupServer) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/ready", s.handleReady)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		writeStartupJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: newAPIError(ErrCodeStarting, "Server is starting")})
+	})
+
+	var handler http.Handler = mux
+	if s.config.HTTPBasePath != "" {
+		handler = withBasePath(s.config.HTTPBasePath, mux)
+	}
+
+	addr := listenAddress(s.config.HTTPBindAddress, s.config.HTTPPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.server = &http.Server{Handler: handler, ReadHeaderTimeout: httpReadHeaderTimeout}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Startup server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop closes the startup server, freeing the HTTP address for the HTTP server
+func (s *StartupServer) Stop() {
+	if s.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+	s.wg.Wait()
+}
+
+// handleHealth reports the process alive, so liveness probes leave it running while it recovers
+func (s *StartupServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeStartupJSON(w, http.StatusOK, HealthResponse{
+		Status:    "recovering",
+		Timestamp: time.Now(),
+		Version:   getVersion(),
+		Services: map[string]interface{}{
+			"recovery": s.status(),
+		},
+	})
+}
+
+// handleReady reports the process not ready yet
+func (s *StartupServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	writeStartupJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Recovery: s.status()})
+}
+
+func writeStartupJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"opentrail/internal/fieldcrypt"
+	"opentrail/internal/types"
+)
+
+// Roles of authenticated HTTP users
+const (
+	roleAdmin  = types.RoleAdmin
+	roleReader = types.RoleReader
+)
+
+// redactedValue replaces masked content in responses to reader-role users
+const redactedValue = "[REDACTED]"
+
+// roleContextKey carries the role of an authenticated request
+type roleContextKey struct{}
+
+// withRole returns the request annotated with the role of its user
+func withRole(r *http.Request, role string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), roleContextKey{}, role))
+}
+
+// requestRole returns the role of the request's user. Without authentication there is nobody to
+// restrict, so requests are treated as admin.
+func requestRole(r *http.Request) string {
+	if role, ok := r.Context().Value(roleContextKey{}).(string); ok {
+		return role
+	}
+	return roleAdmin
+}
+
+// adminMiddleware rejects reader-role users; it wraps the handler inside authMiddleware
+func (s *HTTPServer) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requestRole(r) != roleAdmin {
+			s.sendErrorResponse(w, http.StatusForbidden, "Admin role required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// redactor masks sensitive structured data values and message content in the entries served to
+// reader-role users. Entries are copied, so the ones shared with other subscribers stay intact.
+type redactor struct {
+	// fields holds the redacted "sdid.param" keys and params their bare parameter names
+	fields  map[string]bool
+	params  map[string]bool
+	pattern *regexp.Regexp
+
+	// decrypt holds the keys of the encrypted fields; a redactor with them serves admins and only
+	// decrypts
+	decrypt *fieldcrypt.Encryptor
+}
+
+// newRedactor builds the redactor for the configured fields and pattern, nil when nothing is redacted
+func newRedactor(fields []string, pattern string) *redactor {
+	if len(fields) == 0 && pattern == "" {
+		return nil
+	}
+
+	rd := &redactor{fields: make(map[string]bool), params: make(map[string]bool)}
+	for _, field := range fields {
+		rd.fields[field] = true
+		if _, param, ok := strings.Cut(field, "."); ok {
+			rd.params[param] = true
+		}
+	}
+	if pattern != "" {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			// The configuration is validated on load; fail closed rather than leak content
+			log.Printf("Invalid redaction pattern %q, masking whole messages: %v", pattern, err)
+			compiled = regexp.MustCompile(`(?s).+`)
+		}
+		rd.pattern = compiled
+	}
+	return rd
+}
+
+// SetFieldEncryption makes the encrypted structured data fields readable to admins; readers see them
+// masked like the redacted fields
+func (s *HTTPServer) SetFieldEncryption(encryptor *fieldcrypt.Encryptor) {
+	s.redactor = newRedactor(append(slices.Clone(s.config.RedactFields), encryptor.Fields()...), s.config.RedactPattern)
+	s.decryptor = &redactor{decrypt: encryptor}
+}
+
+// redactorFor returns the redactor to apply to the response of a request. Admins get the one
+// decrypting encrypted fields, nil when there are none.
+func (s *HTTPServer) redactorFor(r *http.Request) *redactor {
+	if requestRole(r) == roleReader {
+		return s.redactor
+	}
+	return s.decryptor
+}
+
+// restricts reports whether the redactor masks content, rather than only decrypting it for admins
+func (rd *redactor) restricts() bool {
+	return rd != nil && rd.decrypt == nil
+}
+
+// field reports whether a field name, "sdid.param" or a bare parameter name, is redacted
+func (rd *redactor) field(name string) bool {
+	if rd == nil {
+		return false
+	}
+	return rd.fields[name] || rd.params[name]
+}
+
+// text masks the matches of the redaction pattern
+func (rd *redactor) text(value string) string {
+	if rd == nil || rd.pattern == nil {
+		return value
+	}
+	return rd.pattern.ReplaceAllString(value, redactedValue)
+}
+
+// checkQuery rejects searches that could reveal redacted values by filtering on them
+func (rd *redactor) checkQuery(query types.SearchQuery) error {
+	if rd == nil {
+		return nil
+	}
+	for _, filter := range query.Filters {
+		if rd.field(filter.Field) {
+			return fmt.Errorf("filtering on redacted field %s is not allowed", filter.Field)
+		}
+	}
+	for param := range rd.params {
+		if strings.Contains(query.StructuredDataQuery, param) {
+			return fmt.Errorf("structured data queries on redacted field %s are not allowed", param)
+		}
+	}
+	return nil
+}
+
+// entry returns a copy of an entry with its sensitive content masked
+func (rd *redactor) entry(entry *types.LogEntry) *types.LogEntry {
+	if rd == nil || entry == nil {
+		return entry
+	}
+
+	redacted := *entry
+	redacted.Message = rd.text(entry.Message)
+	if redacted.Message != entry.Message {
+		// Match offsets refer to the original message and could reveal the masked text's length
+		redacted.Highlights = nil
+	}
+	redacted.Raw = ""
+	if entry.StructuredData != nil {
+		redacted.StructuredData = make(map[string]interface{}, len(entry.StructuredData))
+		for sdid, element := range entry.StructuredData {
+			redacted.StructuredData[sdid] = rd.element(sdid, element)
+		}
+	}
+	return &redacted
+}
+
+// entries returns copies of entries with their sensitive content masked
+func (rd *redactor) entries(entries []*types.LogEntry) []*types.LogEntry {
+	if rd == nil {
+		return entries
+	}
+	redacted := make([]*types.LogEntry, len(entries))
+	for i, entry := range entries {
+		redacted[i] = rd.entry(entry)
+	}
+	return redacted
+}
+
+// comparison masks the example messages and patterns of a search comparison; the result is not
+// shared with other requests and is masked in place
+func (rd *redactor) comparison(result *types.CompareResult) {
+	if rd == nil || result == nil {
+		return
+	}
+	for i := range result.Patterns {
+		result.Patterns[i].Example = rd.text(result.Patterns[i].Example)
+		result.Patterns[i].Pattern = rd.text(result.Patterns[i].Pattern)
+	}
+}
+
+// events masks the descriptions of event markers in place
+func (rd *redactor) events(events []types.Event) {
+	if rd == nil {
+		return
+	}
+	for i := range events {
+		events[i].Description = rd.text(events[i].Description)
+	}
+}
+
+// element masks, or decrypts, the parameters of one structured data element
+func (rd *redactor) element(sdid string, element interface{}) interface{} {
+	params := make(map[string]interface{})
+	switch typed := element.(type) {
+	case map[string]interface{}:
+		for name, value := range typed {
+			params[name] = value
+		}
+	case map[string]string:
+		for name, value := range typed {
+			params[name] = value
+		}
+	default:
+		return element
+	}
+
+	for name, value := range params {
+		s, isString := value.(string)
+		switch {
+		case rd.fields[sdid+"."+name]:
+			params[name] = redactedValue
+		case isString && rd.decrypt != nil:
+			params[name] = rd.decrypt.Decrypt(sdid+"."+name, s)
+		case isString:
+			params[name] = rd.text(s)
+		}
+	}
+	return params
+}
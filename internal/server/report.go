@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxReportRequestSize bounds the body of a report creation or update request
+	maxReportRequestSize = 16384
+	// defaultReportResultLimit is the number of results returned when limit is omitted
+	defaultReportResultLimit = 1000
+	// maxReportResultLimit bounds the number of results returned at once
+	maxReportResultLimit = 10000
+)
+
+// handleReports lists scheduled reports (GET) or creates one (POST, admin role) from a body like
+// {"name": "errors", "query": "severity<=err", "top_field": "app_name", "interval_seconds": 3600}
+func (s *HTTPServer) handleReports(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	manager, ok := s.logService.(interfaces.ReportManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Scheduled reports are not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		reports, err := manager.Reports()
+		if err != nil {
+			log.Printf("Error listing reports: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list reports")
+			return
+		}
+		if rd := s.redactorFor(r); rd != nil {
+			for i := range reports {
+				reports[i].Query = rd.text(reports[i].Query)
+			}
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    reports,
+		})
+		return
+	}
+
+	if requestRole(r) != roleAdmin {
+		s.sendErrorResponse(w, http.StatusForbidden, "Admin role required")
+		return
+	}
+
+	var report types.Report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportRequestSize)).Decode(&report); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	err := manager.CreateReport(&report)
+	if errors.Is(err, interfaces.ErrInvalidReport) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, interfaces.ErrReportExists) {
+		s.sendErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating report %q: %v", report.Name, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to create report")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// handleReport serves GET /api/reports/{id}/results, the stored run summaries of a report, and
+// PUT and DELETE /api/reports/{id} (admin role), which replace the report's definition with the
+// body, as in creation, or remove it. Query parameters for results: since, tz, limit
+func (s *HTTPServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	idStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/reports/"), "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 || (sub != "" && sub != "results") {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if (sub == "" && r.Method != http.MethodPut && r.Method != http.MethodDelete) || (sub == "results" && r.Method != http.MethodGet) {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	manager, ok := s.logService.(interfaces.ReportManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Scheduled reports are not supported")
+		return
+	}
+
+	if sub == "" {
+		if requestRole(r) != roleAdmin {
+			s.sendErrorResponse(w, http.StatusForbidden, "Admin role required")
+			return
+		}
+		if r.Method == http.MethodPut {
+			s.updateReport(w, r, manager, id)
+			return
+		}
+		err := manager.DeleteReport(id)
+		if errors.Is(err, interfaces.ErrReportNotFound) {
+			s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting report %d: %v", id, err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete report")
+			return
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    map[string]int64{"id": id},
+		})
+		return
+	}
+
+	since, limit, err := parseReportResultsQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	results, err := manager.ReportResults(id, since, limit)
+	if errors.Is(err, interfaces.ErrReportNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading results of report %d: %v", id, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read report results")
+		return
+	}
+
+	if rd := s.redactorFor(r); rd != nil {
+		results, err = s.redactReportResults(manager, rd, id, results)
+		if err != nil {
+			log.Printf("Error reading report %d: %v", id, err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read report results")
+			return
+		}
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    results,
+	})
+}
+
+// updateReport replaces the definition of a report with the body of the request
+func (s *HTTPServer) updateReport(w http.ResponseWriter, r *http.Request, manager interfaces.ReportManager, id int64) {
+	var report types.Report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportRequestSize)).Decode(&report); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	report.ID = id
+
+	err := manager.UpdateReport(&report)
+	if errors.Is(err, interfaces.ErrInvalidReport) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, interfaces.ErrReportExists) {
+		s.sendErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, interfaces.ErrReportNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating report %d: %v", id, err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update report")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// redactReportResults drops the top values of a report over a redacted field and masks the others
+func (s *HTTPServer) redactReportResults(manager interfaces.ReportManager, rd *redactor, id int64, results []types.ReportResult) ([]types.ReportResult, error) {
+	reports, err := manager.Reports()
+	if err != nil {
+		return nil, err
+	}
+	var topField string
+	for _, report := range reports {
+		if report.ID == id {
+			topField = report.TopField
+		}
+	}
+
+	for i := range results {
+		if rd.field(topField) {
+			results[i].TopValues = nil
+			continue
+		}
+		for j := range results[i].TopValues {
+			results[i].TopValues[j].Value = rd.text(results[i].TopValues[j].Value)
+		}
+	}
+	return results, nil
+}
+
+// parseReportResultsQuery parses the start and size of a report results request
+func parseReportResultsQuery(r *http.Request, now time.Time) (time.Time, int, error) {
+	params := r.URL.Query()
+	var since time.Time
+	limit := defaultReportResultLimit
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return since, limit, err
+	}
+
+	if sinceStr := params.Get("since"); sinceStr != "" {
+		if since, err = parseTimeParam("since", sinceStr, now, loc); err != nil {
+			return since, limit, err
+		}
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxReportResultLimit {
+			return since, limit, fmt.Errorf("invalid limit, must be between 1 and %d", maxReportResultLimit)
+		}
+	}
+
+	return since, limit, nil
+}
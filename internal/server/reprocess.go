@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// handleReprocess reports the reprocessing job status (GET) or starts re-parsing stored raw messages
+// with the current parser (POST). Query parameters for POST: start_time, end_time, tz
+func (s *HTTPServer) handleReprocess(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	manager, ok := s.logService.(interfaces.ReprocessManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Reprocessing is not supported")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    manager.ReprocessStatus(),
+		})
+		return
+	}
+
+	query, err := parseReprocessQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	status, err := manager.StartReprocess(query)
+	if errors.Is(err, interfaces.ErrReprocessRunning) {
+		s.sendErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting reprocessing: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to start reprocessing")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// parseReprocessQuery parses the optional time range of a reprocessing job
+func parseReprocessQuery(r *http.Request, now time.Time) (types.ReprocessQuery, error) {
+	params := r.URL.Query()
+	var query types.ReprocessQuery
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		startTime, err := parseTimeParam("start_time", startTimeStr, now, loc)
+		if err != nil {
+			return query, err
+		}
+		query.StartTime = &startTime
+	}
+
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		endTime, err := parseTimeParam("end_time", endTimeStr, now, loc)
+		if err != nil {
+			return query, err
+		}
+		query.EndTime = &endTime
+	}
+
+	if query.StartTime != nil && query.EndTime != nil && !query.StartTime.Before(*query.EndTime) {
+		return query, fmt.Errorf("start_time must be before end_time")
+	}
+	return query, nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// parseResultFields parses the comma-separated fields parameter of a search
+func parseResultFields(value string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(types.SearchResultFields, field) {
+			return nil, fmt.Errorf("invalid fields value %q, expected any of %s", field, strings.Join(types.SearchResultFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields cannot be empty")
+	}
+	return fields, nil
+}
+
+// selectResultFields converts entries into objects holding only the selected fields, the ID and the
+// repeat information of collapsed results, so fields that were not selected are left out of the
+// response rather than sent empty
+func selectResultFields(entries []*types.LogEntry, fields []string) []map[string]interface{} {
+	results := make([]map[string]interface{}, len(entries))
+	for i, entry := range entries {
+		result := map[string]interface{}{"id": entry.ID}
+		for _, field := range fields {
+			switch field {
+			case "uid":
+				result[field] = entry.UID
+			case "priority":
+				result[field] = entry.Priority
+			case "facility":
+				result[field] = entry.Facility
+			case "severity":
+				result[field] = entry.Severity
+			case "version":
+				result[field] = entry.Version
+			case "timestamp":
+				result[field] = entry.Timestamp
+			case "hostname":
+				result[field] = entry.Hostname
+			case "app_name":
+				result[field] = entry.AppName
+			case "proc_id":
+				result[field] = entry.ProcID
+			case "msg_id":
+				result[field] = entry.MsgID
+			case "structured_data":
+				result[field] = entry.StructuredData
+			case "message":
+				result[field] = entry.Message
+				if len(entry.Highlights) > 0 {
+					result["highlights"] = entry.Highlights
+				}
+			case "created_at":
+				result[field] = entry.CreatedAt
+			}
+		}
+		if entry.RepeatCount > 0 {
+			result["repeat_count"] = entry.RepeatCount
+			result["first_timestamp"] = entry.FirstTimestamp
+			result["last_timestamp"] = entry.LastTimestamp
+		}
+		results[i] = result
+	}
+	return results
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// maxRetentionRequestSize bounds the body of a retention change
+const maxRetentionRequestSize = 1024
+
+// retentionRequest is the body of PUT /api/admin/retention
+type retentionRequest struct {
+	Days int `json:"days"`
+}
+
+// retentionDays returns the retention period in effect, the configured one if the log service
+// cannot change it
+func (s *HTTPServer) retentionDays() int {
+	if manager, ok := s.logService.(interfaces.RetentionManager); ok {
+		return manager.Retention().Days
+	}
+	return s.config.RetentionDays
+}
+
+// handleRetention returns the retention period (GET), sets it (PUT {"days": 90}) or goes back to
+// the configured one (DELETE). A period set here is stored in the database and takes precedence
+// over -retention-days until it is reset.
+func (s *HTTPServer) handleRetention(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.RetentionManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Changing retention is not supported")
+		return
+	}
+
+	var status types.RetentionStatus
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		status = manager.Retention()
+	case http.MethodPut:
+		var req retentionRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRetentionRequestSize)).Decode(&req); err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+		if status, err = manager.UpdateRetention(req.Days); err == nil {
+			log.Printf("Retention set to %d days by %q", status.Days, s.requestUser(r))
+		}
+	case http.MethodDelete:
+		if status, err = manager.ResetRetention(); err == nil {
+			log.Printf("Retention reset to the configured %d days by %q", status.Days, s.requestUser(r))
+		}
+	}
+	if errors.Is(err, interfaces.ErrInvalidRetention) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error changing retention: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to change retention")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
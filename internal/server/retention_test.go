@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// retentionService keeps a retention period that can be changed
+type retentionService struct {
+	MockLogService
+	status types.RetentionStatus
+}
+
+func (m *retentionService) Retention() types.RetentionStatus {
+	return m.status
+}
+
+func (m *retentionService) UpdateRetention(days int) (types.RetentionStatus, error) {
+	if days < 1 || days > types.MaxRetentionDays {
+		return types.RetentionStatus{}, fmt.Errorf("%w: days out of range", interfaces.ErrInvalidRetention)
+	}
+	m.status.Days, m.status.Overridden = days, true
+	return m.status, nil
+}
+
+func (m *retentionService) ResetRetention() (types.RetentionStatus, error) {
+	m.status.Days, m.status.Overridden = m.status.ConfiguredDays, false
+	return m.status, nil
+}
+
+func TestHTTPServer_Retention(t *testing.T) {
+	config := &types.Config{
+		HTTPPort: 8080, RetentionDays: 30, AuthEnabled: true, AuthUsername: "admin", AuthPassword: "password",
+		ReaderUsername: "reader", ReaderPassword: "readonly",
+	}
+	service := &retentionService{status: types.RetentionStatus{Days: 30, ConfiguredDays: 30}}
+	server := NewHTTPServer(config, service)
+	mux := http.NewServeMux()
+	server.setupRoutes(mux)
+	request := func(method, body, user, password string) (*httptest.ResponseRecorder, types.RetentionStatus) {
+		t.Helper()
+		r := httptest.NewRequest(method, "/api/admin/retention", strings.NewReader(body))
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		var response struct {
+			Data types.RetentionStatus `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return w, response.Data
+	}
+
+	if w, _ := request(http.MethodPut, `{"days": 7}`, "reader", "readonly"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected readers to be refused, got %d", w.Code)
+	}
+	if w, status := request(http.MethodGet, "", "admin", "password"); w.Code != http.StatusOK || status.Days != 30 {
+		t.Errorf("Expected the configured 30 days, got %d: %+v", w.Code, status)
+	}
+
+	for body, code := range map[string]int{
+		`{"days": 0}`:    http.StatusBadRequest,
+		`{"days": "90"}`: http.StatusBadRequest,
+		`{"days": 90`:    http.StatusBadRequest,
+	} {
+		if w, _ := request(http.MethodPut, body, "admin", "password"); w.Code != code {
+			t.Errorf("Expected status %d for %s, got %d", code, body, w.Code)
+		}
+	}
+
+	w, status := request(http.MethodPut, `{"days": 90}`, "admin", "password")
+	if w.Code != http.StatusOK || status != (types.RetentionStatus{Days: 90, ConfiguredDays: 30, Overridden: true}) {
+		t.Fatalf("Unexpected update response %d: %+v", w.Code, status)
+	}
+	// The period in effect decides the archive cutoff and storage projection
+	if got := server.retentionDays(); got != 90 {
+		t.Errorf("Expected the server to use 90 days, got %d", got)
+	}
+
+	w, status = request(http.MethodDelete, "", "admin", "password")
+	if w.Code != http.StatusOK || status != (types.RetentionStatus{Days: 30, ConfiguredDays: 30}) {
+		t.Errorf("Unexpected reset response %d: %+v", w.Code, status)
+	}
+	if w, _ := request(http.MethodPost, "", "admin", "password"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
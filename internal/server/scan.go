@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// defaultScanLimit is the number of matches a scan returns unless limit is set
+	defaultScanLimit = 1000
+	// maxScanLimit bounds the limit of a scan
+	maxScanLimit = 100000
+	// scanProgressInterval is how often a scan reports its progress
+	scanProgressInterval = time.Second
+)
+
+// Line types of the response of a scan
+const (
+	scanEventMatch    = "match"
+	scanEventProgress = "progress"
+	scanEventDone     = "done"
+	scanEventError    = "error"
+)
+
+// scanEvent is a line of the response of a scan
+type scanEvent struct {
+	Type  string          `json:"type"`
+	Entry *types.LogEntry `json:"entry,omitempty"`
+	*types.ScanProgress
+	Error string `json:"error,omitempty"`
+}
+
+// handleScan streams the entries matching text or regex from a scan of every stored entry, for
+// broad searches the indexes cannot answer. The response is NDJSON: a match line per entry, a
+// progress line every second and a done line with the outcome, or an error line if the scan
+// failed. Closing the connection cancels the scan. Query parameters: text, regex, raw, start_time,
+// end_time, tz, limit
+func (s *HTTPServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	scanner, ok := s.logService.(interfaces.AdhocScanner)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Scans are not supported")
+		return
+	}
+
+	query, err := parseScanQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+	redact := s.redactorFor(r)
+	if redact.restricts() && query.Raw {
+		s.sendErrorResponse(w, http.StatusForbidden, "scanning raw messages is not allowed with redaction")
+		return
+	}
+	if redact.restricts() {
+		// Matching the masked messages keeps a pattern from confirming what the redaction hides
+		query.Mask = redact.text
+	}
+
+	// The scan stops a little before the write deadline to leave time for its done line
+	ctx := r.Context()
+	if timeout := s.routeTimeout(timeoutExport); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout-timeout/10)
+		defer cancel()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+
+	lastReport := time.Now()
+	match := func(entry *types.LogEntry) error {
+		return encoder.Encode(scanEvent{Type: scanEventMatch, Entry: redact.entry(entry)})
+	}
+	progress := func(status types.ScanProgress) error {
+		if time.Since(lastReport) < scanProgressInterval {
+			return nil
+		}
+		lastReport = time.Now()
+		if err := encoder.Encode(scanEvent{Type: scanEventProgress, ScanProgress: &status}); err != nil {
+			return err
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	status, err := scanner.Scan(ctx, query, match, progress)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status.Outcome = types.ScanTimeout
+	case errors.Is(err, context.Canceled):
+		// The client went away
+		return
+	case err != nil:
+		log.Printf("Error scanning logs: %v", err)
+		encoder.Encode(scanEvent{Type: scanEventError, ScanProgress: &status, Error: "Failed to scan logs"})
+		controller.Flush()
+		return
+	}
+	encoder.Encode(scanEvent{Type: scanEventDone, ScanProgress: &status})
+	controller.Flush()
+}
+
+// parseScanQuery parses the pattern, time range and limit of a scan
+func parseScanQuery(r *http.Request, now time.Time) (types.ScanQuery, error) {
+	params := r.URL.Query()
+	query := types.ScanQuery{
+		Text:  params.Get("text"),
+		Regex: params.Get("regex"),
+		Limit: defaultScanLimit,
+	}
+	if query.Text == "" && query.Regex == "" {
+		return query, fmt.Errorf("text or regex is required")
+	}
+	if query.Regex != "" {
+		if _, err := regexp.Compile(query.Regex); err != nil {
+			return query, fmt.Errorf("invalid regex: %v", err)
+		}
+	}
+	switch params.Get("raw") {
+	case "", "false":
+	case "true":
+		query.Raw = true
+	default:
+		return query, fmt.Errorf("invalid raw value, must be true or false")
+	}
+
+	loc, err := parseTimeZone(params)
+	if err != nil {
+		return query, err
+	}
+	if startTimeStr := params.Get("start_time"); startTimeStr != "" {
+		startTime, err := parseTimeParam("start_time", startTimeStr, now, loc)
+		if err != nil {
+			return query, err
+		}
+		query.StartTime = &startTime
+	}
+	if endTimeStr := params.Get("end_time"); endTimeStr != "" {
+		endTime, err := parseTimeParam("end_time", endTimeStr, now, loc)
+		if err != nil {
+			return query, err
+		}
+		query.EndTime = &endTime
+	}
+	if query.StartTime != nil && query.EndTime != nil && !query.StartTime.Before(*query.EndTime) {
+		return query, fmt.Errorf("start_time must be before end_time")
+	}
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxScanLimit {
+			return query, fmt.Errorf("invalid limit, must be between 1 and %d", maxScanLimit)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
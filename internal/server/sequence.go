@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"opentrail/internal/interfaces"
+)
+
+// handleSequenceGaps reports the gaps in the sequence numbers of the sources that number their
+// messages (GET), or forgets the sequence numbering of every source (DELETE)
+func (s *HTTPServer) handleSequenceGaps(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reporter, ok := s.logService.(interfaces.SequenceGapReporter)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Sequence gap detection is not supported")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		reporter.ResetSequenceGaps()
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{Success: true})
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    reporter.SequenceGaps(),
+	})
+}
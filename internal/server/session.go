@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// Session is the user of the web interface; the interface shows its admin section to the admin role
+type Session struct {
+	// User is the authenticated username, empty without authentication
+	User string `json:"user,omitempty"`
+	Role string `json:"role"`
+}
+
+// handleSession returns the user and role of the request
+func (s *HTTPServer) handleSession(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	session := Session{User: s.requestUser(r), Role: requestRole(r)}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    session,
+	})
+}
+
+// userContextKey carries the user of an authenticated request
+type userContextKey struct{}
+
+// withUser returns the request annotated with its authenticated user
+func withUser(r *http.Request, user string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+}
+
+// requestUser returns the authenticated user of a request, whose preferences and shares it works
+// with; requests authenticated by an API token have the token's name prefixed with "token:".
+// Without authentication every request has the empty user.
+func (s *HTTPServer) requestUser(r *http.Request) string {
+	user, _ := r.Context().Value(userContextKey{}).(string)
+	return user
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// maxSetupRequestSize bounds the body of a setup request
+	maxSetupRequestSize = 64 * 1024
+	// selfSignedValidity is how long certificates generated by the setup wizard are valid
+	selfSignedValidity = 5 * 365 * 24 * time.Hour
+	// Names of the generated certificate and key, stored next to the setup file
+	setupCertFile = "opentrail-tls.crt"
+	setupKeyFile  = "opentrail-tls.key"
+)
+
+//go:embed setup.html
+var setupPage []byte
+
+// SetupStore validates the settings chosen in the setup wizard and persists them
+type SetupStore interface {
+	Validate(settings types.SetupSettings) error
+	Save(settings types.SetupSettings) error
+}
+
+// SetupServer serves the first-run setup wizard on the HTTP address when no admin account is
+// configured, so the server is not run without authentication by accident. Completing the wizard
+// requires a one-time token the process logs, so that only whoever can read the logs can claim
+// the server.
+type SetupServer struct {
+	config *types.Config
+	store  SetupStore
+	token  string
+
+	mu       sync.Mutex
+	finished bool
+	done     chan types.SetupSettings
+
+	server *http.Server
+	wg     sync.WaitGroup
+}
+
+// setupDefaults is what the wizard's form starts with
+type setupDefaults struct {
+	RetentionDays        int      `json:"retention_days"`
+	TCPBindAddress       string   `json:"tcp_bind_address"`
+	TCPPort              int      `json:"tcp_port"`
+	WebSocketBindAddress string   `json:"websocket_bind_address"`
+	WebSocketPort        int      `json:"websocket_port"`
+	TLSHostnames         []string `json:"tls_hostnames"`
+}
+
+// setupRequest is the body of POST /api/setup
+type setupRequest struct {
+	Token string `json:"token"`
+	types.SetupSettings
+	// GenerateTLS creates a self-signed certificate for TCP ingestion, valid for TLSHostnames
+	GenerateTLS  bool     `json:"generate_tls"`
+	TLSHostnames []string `json:"tls_hostnames"`
+}
+
+// NewSetupServer creates a setup wizard storing its settings in store
+func NewSetupServer(config *types.Config, store SetupStore) *SetupServer {
+	var token [16]byte
+	rand.Read(token[:])
+	return &SetupServer{
+		config: config,
+		store:  store,
+		token:  hex.EncodeToString(token[:]),
+		done:   make(chan types.SetupSettings, 1),
+	}
+}
+
+// Token returns the one-time token that completing the setup requires
+func (s *SetupServer) Token() string {
+	return s.token
+}
+
+// Done delivers the settings once the setup is complete
+func (s *SetupServer) Done() <-chan types.SetupSettings {
+	return s.done
+}
+
+// Handler returns the routes of the setup wizard
+func (s *SetupServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/ready", s.handleReady)
+	mux.HandleFunc("/api/setup", s.handleSetup)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeStartupJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: newAPIError(ErrCodeSetupRequired, "Setup is required")})
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(setupPage)
+	})
+
+	if s.config.HTTPBasePath != "" {
+		return withBasePath(s.config.HTTPBasePath, mux)
+	}
+	return mux
+}
+
+// Start binds the HTTP address and serves the wizard until Stop
+func (s *SetupServer) Start() error {
+	addr := listenAddress(s.config.HTTPBindAddress, s.config.HTTPPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: httpReadHeaderTimeout}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Setup server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop closes the setup server, freeing the HTTP address for the HTTP server
+func (s *SetupServer) Stop() {
+	if s.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+	s.wg.Wait()
+}
+
+// handleHealth reports the process alive while it waits to be set up
+func (s *SetupServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeStartupJSON(w, http.StatusOK, HealthResponse{
+		Status:    "setup",
+		Timestamp: time.Now(),
+		Version:   getVersion(),
+	})
+}
+
+// handleReady reports the process not ready until it is set up
+func (s *SetupServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	writeStartupJSON(w, http.StatusServiceUnavailable, ReadinessResponse{})
+}
+
+// handleSetup returns the form defaults on GET and completes the setup on POST
+func (s *SetupServer) handleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		hostnames := []string{"localhost"}
+		if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+			hostnames = append([]string{hostname}, hostnames...)
+		}
+		writeStartupJSON(w, http.StatusOK, APIResponse{Success: true, Data: setupDefaults{
+			RetentionDays:        s.config.RetentionDays,
+			TCPBindAddress:       s.config.TCPBindAddress,
+			TCPPort:              s.config.TCPPort,
+			WebSocketBindAddress: s.config.WebSocketBindAddress,
+			WebSocketPort:        s.config.WebSocketPort,
+			TLSHostnames:         hostnames,
+		}})
+		return
+	case http.MethodPost:
+	default:
+		writeStartupJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Error: newAPIError(ErrCodeMethodNotAllowed, "Method not allowed")})
+		return
+	}
+
+	var req setupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSetupRequestSize)).Decode(&req); err != nil {
+		writeStartupJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: newAPIError(ErrCodeInvalidQuery, fmt.Sprintf("Invalid request body: %v", err))})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) != 1 {
+		writeStartupJSON(w, http.StatusUnauthorized, APIResponse{Success: false, Error: newAPIError(ErrCodeUnauthorized, "Invalid setup token; it is printed in the server's log")})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		writeStartupJSON(w, http.StatusConflict, APIResponse{Success: false, Error: newAPIError(ErrCodeConflict, "Setup is already complete")})
+		return
+	}
+
+	settings := req.SetupSettings
+	settings.TLSCert, settings.TLSKey = "", ""
+	if req.GenerateTLS {
+		dir := filepath.Dir(s.config.SetupFile)
+		settings.TLSCert = filepath.Join(dir, setupCertFile)
+		settings.TLSKey = filepath.Join(dir, setupKeyFile)
+	}
+	if err := s.store.Validate(settings); err != nil {
+		writeStartupJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: newAPIError(ErrCodeInvalidQuery, err.Error())})
+		return
+	}
+	if req.GenerateTLS {
+		if err := GenerateSelfSignedCert(settings.TLSCert, settings.TLSKey, req.TLSHostnames); err != nil {
+			log.Printf("Error generating TLS certificate: %v", err)
+			writeStartupJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: newAPIError(ErrCodeStorageUnavailable, "Failed to generate the TLS certificate")})
+			return
+		}
+	}
+	if err := s.store.Save(settings); err != nil {
+		log.Printf("Error saving setup: %v", err)
+		writeStartupJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: newAPIError(ErrCodeStorageUnavailable, "Failed to save the setup")})
+		return
+	}
+
+	s.finished = true
+	s.done <- settings
+	writeStartupJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]string{"username": settings.AdminUsername}})
+}
+
+// GenerateSelfSignedCert writes a self-signed ECDSA certificate valid for hosts, which may be host
+// names or IP addresses, and its private key as PEM files. The key is only readable by its owner.
+func GenerateSelfSignedCert(certPath, keyPath string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"OpenTrail"}, CommonName: "OpenTrail"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	if len(template.DNSNames) > 0 {
+		template.Subject.CommonName = template.DNSNames[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write TLS key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return fmt.Errorf("failed to write TLS certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+// recordingSetupStore accepts settings with a password and records what it saved
+type recordingSetupStore struct {
+	saved *types.SetupSettings
+}
+
+func (s *recordingSetupStore) Validate(settings types.SetupSettings) error {
+	if settings.AdminPassword == "" {
+		return errors.New("admin password cannot be empty")
+	}
+	return nil
+}
+
+func (s *recordingSetupStore) Save(settings types.SetupSettings) error {
+	s.saved = &settings
+	return nil
+}
+
+func TestSetupServer(t *testing.T) {
+	dir := t.TempDir()
+	config := &types.Config{HTTPPort: 8080, TCPPort: 2253, WebSocketPort: 8081, RetentionDays: 30, SetupFile: filepath.Join(dir, "setup.json")}
+	store := &recordingSetupStore{}
+	wizard := NewSetupServer(config, store)
+	handler := wizard.Handler()
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Set up OpenTrail") {
+		t.Errorf("Expected the setup page, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "/api/logs", ""); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), ErrCodeSetupRequired) {
+		t.Errorf("Expected other routes to require setup, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodGet, "/api/setup", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"tcp_port":2253`) {
+		t.Errorf("Expected the form defaults, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := request(http.MethodPost, "/api/setup", `{"token": "wrong", "admin_username": "admin", "admin_password": "secret123"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be rejected, got %d", w.Code)
+	}
+	body := `{"token": "` + wizard.Token() + `", "admin_username": "admin"}`
+	if w := request(http.MethodPost, "/api/setup", body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "password") {
+		t.Errorf("Expected invalid settings to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if store.saved != nil {
+		t.Fatal("Expected rejected settings not to be saved")
+	}
+
+	body = `{"token": "` + wizard.Token() + `", "admin_username": "admin", "admin_password": "secret123",
+		"retention_days": 14, "tcp_port": 6514, "generate_tls": true, "tls_hostnames": ["logs.example.com", "10.0.0.5"]}`
+	if w := request(http.MethodPost, "/api/setup", body); w.Code != http.StatusOK {
+		t.Fatalf("Expected setup to complete, got %d: %s", w.Code, w.Body.String())
+	}
+	settings := <-wizard.Done()
+	if store.saved == nil || settings.AdminUsername != "admin" || settings.RetentionDays != 14 || settings.TCPPort != 6514 {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+
+	// The generated certificate is valid for the given host names and addresses
+	certificate, err := tls.LoadX509KeyPair(settings.TLSCert, settings.TLSKey)
+	if err != nil {
+		t.Fatalf("Failed to load the generated certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse the generated certificate: %v", err)
+	}
+	if leaf.VerifyHostname("logs.example.com") != nil || leaf.VerifyHostname("10.0.0.5") != nil {
+		t.Errorf("Expected the certificate to cover the host names, got %v %v", leaf.DNSNames, leaf.IPAddresses)
+	}
+
+	w := request(http.MethodPost, "/api/setup", body)
+	var response APIResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusConflict || response.Error == nil || response.Error.Code != ErrCodeConflict {
+		t.Errorf("Expected a second setup to be rejected, got %d: %+v", w.Code, response.Error)
+	}
+}
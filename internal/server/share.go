@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// maxShareRequestSize bounds the body of a share creation request
+const maxShareRequestSize = 65536
+
+// shareRequest is the body of a share creation request
+type shareRequest struct {
+	// IDs select the shared entries; without them the entries are those the search given by the
+	// query parameters finds
+	IDs         []int64 `json:"ids,omitempty"`
+	Description string  `json:"description,omitempty"`
+	// ExpiresInSeconds is how long the share can be opened, types.DefaultShareTTL when zero
+	ExpiresInSeconds int64 `json:"expires_in_seconds,omitempty"`
+}
+
+// shareResponse is a share with the path it is opened at
+type shareResponse struct {
+	types.Share
+	URL string `json:"url"`
+}
+
+// handleShares lists the shares of the requesting user, all of them for admins (GET), or freezes
+// entries into a new share (POST): those of the search the query parameters describe, as for
+// /api/logs, or those whose IDs a body like {"ids": [1, 2], "description": "...",
+// "expires_in_seconds": 86400} lists
+func (s *HTTPServer) handleShares(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.ShareManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Shares are not supported")
+		return
+	}
+	user := s.requestUser(r)
+
+	if r.Method == http.MethodGet {
+		shares, err := manager.Shares()
+		if err != nil {
+			log.Printf("Error listing shares: %v", err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to list shares")
+			return
+		}
+		owned := []shareResponse{}
+		for _, share := range shares {
+			if requestRole(r) == roleAdmin || share.CreatedBy == user {
+				owned = append(owned, s.shareResponse(share))
+			}
+		}
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    owned,
+		})
+		return
+	}
+
+	if s.config.Demo {
+		s.sendErrorResponse(w, http.StatusForbidden, "Shares cannot be created in the demo")
+		return
+	}
+
+	var request shareRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShareRequestSize)).Decode(&request); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if request.ExpiresInSeconds < 0 {
+		s.sendErrorResponse(w, http.StatusBadRequest, "expires_in_seconds cannot be negative")
+		return
+	}
+
+	share := types.Share{Description: request.Description, CreatedBy: user}
+	if len(request.IDs) > 0 {
+		entries, ok := s.shareEntriesByID(w, request.IDs)
+		if !ok {
+			return
+		}
+		share.Entries = entries
+	} else {
+		entries, ok := s.shareSearchEntries(w, r)
+		if !ok {
+			return
+		}
+		share.Entries = entries
+		share.Query = r.URL.RawQuery
+	}
+
+	err := manager.CreateShare(&share, time.Duration(request.ExpiresInSeconds)*time.Second)
+	if errors.Is(err, interfaces.ErrInvalidShare) {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating share of %d entries: %v", len(share.Entries), err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to create share")
+		return
+	}
+
+	share.Entries = nil
+	s.sendJSONResponse(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    s.shareResponse(share),
+	})
+}
+
+// shareEntriesByID reads the entries with the given IDs, answering the request if it cannot
+func (s *HTTPServer) shareEntriesByID(w http.ResponseWriter, ids []int64) ([]*types.LogEntry, bool) {
+	if len(ids) > types.MaxShareEntries {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("At most %d entries can be shared", types.MaxShareEntries))
+		return nil, false
+	}
+	reader, ok := s.logService.(interfaces.EntryDetailReader)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Sharing entries by ID is not supported")
+		return nil, false
+	}
+
+	entries := make([]*types.LogEntry, 0, len(ids))
+	for _, id := range ids {
+		detail, err := reader.EntryDetail(id)
+		if errors.Is(err, interfaces.ErrEntryNotFound) {
+			s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Log entry %d not found", id))
+			return nil, false
+		}
+		if s.sendBusy(w, err) {
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("Error reading entry %d to share: %v", id, err)
+			s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read log entry")
+			return nil, false
+		}
+		entries = append(entries, detail.Entry)
+	}
+	return entries, true
+}
+
+// shareSearchEntries runs the search the query parameters of a share creation request describe,
+// answering the request if it cannot. Whole entries are shared, whatever fields were selected.
+func (s *HTTPServer) shareSearchEntries(w http.ResponseWriter, r *http.Request) ([]*types.LogEntry, bool) {
+	query, err := s.parseSearchQuery(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return nil, false
+	}
+	if err := s.redactorFor(r).checkQuery(query); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return nil, false
+	}
+	query.Fields = nil
+
+	entries, err := s.logService.Search(query)
+	if s.sendBusy(w, err) {
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error searching logs to share: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search logs")
+		return nil, false
+	}
+	return entries, true
+}
+
+// shareResponse adds the path a share is opened at
+func (s *HTTPServer) shareResponse(share types.Share) shareResponse {
+	return shareResponse{Share: share, URL: s.config.HTTPBasePath + "/api/shares/" + share.Token}
+}
+
+// handleShare opens a share at /api/shares/{token} (GET), its entries masked for the reader role
+// as in search results, or removes it before it expires (DELETE, its creator or an admin)
+func (s *HTTPServer) handleShare(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	token := strings.TrimPrefix(r.URL.Path, "/api/shares/")
+	if token == "" || strings.Contains(token, "/") {
+		s.sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	manager, ok := s.logService.(interfaces.ShareManager)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Shares are not supported")
+		return
+	}
+
+	share, err := manager.Share(token)
+	if errors.Is(err, interfaces.ErrShareNotFound) {
+		s.sendErrorResponse(w, http.StatusNotFound, "Share not found or expired")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading share: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to read share")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		share.Entries = s.redactorFor(r).entries(share.Entries)
+		s.sendJSONResponse(w, http.StatusOK, APIResponse{
+			Success: true,
+			Data:    s.shareResponse(*share),
+		})
+		return
+	}
+
+	if s.config.Demo {
+		s.sendErrorResponse(w, http.StatusForbidden, "Shares cannot be deleted in the demo")
+		return
+	}
+	if requestRole(r) != roleAdmin && share.CreatedBy != s.requestUser(r) {
+		s.sendErrorResponse(w, http.StatusForbidden, "Only the creator of a share or an admin can delete it")
+		return
+	}
+	err = manager.DeleteShare(token)
+	if err != nil && !errors.Is(err, interfaces.ErrShareNotFound) {
+		log.Printf("Error deleting share: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete share")
+		return
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    map[string]string{"token": token},
+	})
+}
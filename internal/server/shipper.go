@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/shipper"
+)
+
+const (
+	// drainReconnectAfter is the reconnect delay suggested to draining shippers
+	drainReconnectAfter = time.Second
+	// drainGrace is how long a shipper has to close its side of the connection after a DRAIN notice
+	drainGrace = 5 * time.Second
+	// stopDrainTimeout bounds how long Stop waits for draining shippers to close their connections
+	stopDrainTimeout = 2 * time.Second
+)
+
+// keepAliveInterval returns how often shippers are sent a keepalive, well within the idle timeout
+func (s *TCPServer) keepAliveInterval() time.Duration {
+	return s.idleTimeout() / 3
+}
+
+// handleControl handles a control protocol line, reporting whether the line was one. A sender opts
+// in with a HELLO as its first line; later control lines are only recognized from shippers.
+func (s *TCPServer) handleControl(tracked *tcpConnection, line string, first bool) bool {
+	if !shipper.IsControl(line) {
+		return false
+	}
+	if !tracked.shipper.Load() {
+		clientHello, ok := shipper.ParseClientHello(line)
+		if !first || !ok {
+			return false
+		}
+		tracked.shipper.Store(true)
+		hello := shipper.ServerHello{
+			IdleTimeout: s.idleTimeout(),
+			MaxLifetime: s.config.TCPMaxConnectionLifetime,
+			KeepAlive:   s.keepAliveInterval(),
+		}
+		if _, ok := s.logService.(interfaces.AckingLogProcessor); ok && clientHello.Ack {
+			hello.Ack = true
+			tracked.acks = newAckTracker()
+			s.wg.Add(1)
+			go s.ackLoop(tracked, tracked.acks)
+		}
+		if err := tracked.sendControl(hello.String()); err != nil {
+			log.Printf("Failed to answer shipper HELLO from %s: %v", tracked.conn.RemoteAddr(), err)
+		}
+		return true
+	}
+	// Keepalives only refresh the read deadline, which the caller already did
+	return true
+}
+
+// processLogAcked hands a line of a shipper that asked for acknowledgements to the log service,
+// acknowledging it once stored
+func (s *TCPServer) processLogAcked(tracked *tcpConnection, line, sourceIP, tenant string, parseFailed func()) error {
+	number := tracked.acks.add()
+	done := func(err error) {
+		tracked.acks.complete(number, err)
+	}
+	var err error
+	if processor, ok := s.logService.(interfaces.OrderedLogProcessor); ok {
+		err = processor.ProcessLogInStream(tracked.id, line, sourceIP, tenant, parseFailed, done)
+	} else {
+		err = s.logService.(interfaces.AckingLogProcessor).ProcessLogAcked(line, sourceIP, tenant, parseFailed, done)
+	}
+	if err != nil {
+		tracked.acks.complete(number, err)
+	}
+	return err
+}
+
+// sendControl writes a control line to a shipper
+func (c *tcpConnection) sendControl(line string) error {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(DefaultWriteTimeout))
+	if _, err := fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		return fmt.Errorf("failed to send control line: %w", err)
+	}
+	return nil
+}
+
+// drain sends a shipper a DRAIN notice, giving it drainGrace to close its side of the connection.
+// It reports whether a notice was sent, which happens once per connection.
+func (c *tcpConnection) drain() bool {
+	if !c.shipper.Load() || !c.drainDeadline.CompareAndSwap(0, time.Now().Add(drainGrace).UnixNano()) {
+		return false
+	}
+	c.conn.SetReadDeadline(time.Unix(0, c.drainDeadline.Load()))
+	if err := c.sendControl(shipper.DrainNotice(drainReconnectAfter)); err != nil {
+		log.Printf("Failed to send DRAIN to %s: %v", c.conn.RemoteAddr(), err)
+	}
+	return true
+}
+
+// drainAtLifetime drains a shipper whose read timed out at the end of its connection's lifetime,
+// reporting whether reading should continue until the shipper closes
+func (s *TCPServer) drainAtLifetime(tracked *tcpConnection) bool {
+	lifetime := s.config.TCPMaxConnectionLifetime
+	if lifetime <= 0 || time.Now().Before(tracked.connectedAt.Add(lifetime)) {
+		return false
+	}
+	return tracked.drain()
+}
+
+// drainShippers sends a DRAIN notice to every shipper, returning how many were notified
+func (s *TCPServer) drainShippers() int {
+	s.connectionsMux.RLock()
+	defer s.connectionsMux.RUnlock()
+
+	drained := 0
+	for _, tracked := range s.connections {
+		if tracked.drain() {
+			drained++
+		}
+	}
+	if drained > 0 {
+		log.Printf("Sent DRAIN to %d shipper connection(s)", drained)
+	}
+	return drained
+}
+
+// waitForShippers waits up to timeout for every shipper connection to be closed
+func (s *TCPServer) waitForShippers(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.shipperCount() == 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// shipperCount returns the number of open shipper connections
+func (s *TCPServer) shipperCount() int {
+	s.connectionsMux.RLock()
+	defer s.connectionsMux.RUnlock()
+
+	count := 0
+	for _, tracked := range s.connections {
+		if tracked.shipper.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+// keepAliveLoop periodically sends a keepalive to every shipper that is not draining
+func (s *TCPServer) keepAliveLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.keepAliveInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.connectionsMux.RLock()
+			for _, tracked := range s.connections {
+				if tracked.shipper.Load() && tracked.drainDeadline.Load() == 0 {
+					tracked.sendControl(shipper.KeepAlive)
+				}
+			}
+			s.connectionsMux.RUnlock()
+
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
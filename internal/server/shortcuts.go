@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+)
+
+// Shortcut is a keyboard shortcut of the web interface. Keys are KeyboardEvent.key values, any of
+// which triggers the action; the interface localizes the description by action.
+type Shortcut struct {
+	Action      string   `json:"action"`
+	Keys        []string `json:"keys"`
+	Description string   `json:"description"`
+}
+
+// shortcuts is the keyboard map of the web interface, shown in its help dialog
+var shortcuts = []Shortcut{
+	{Action: "focus-search", Keys: []string{"/"}, Description: "Focus message search"},
+	{Action: "next-entry", Keys: []string{"j", "ArrowDown"}, Description: "Select next entry"},
+	{Action: "previous-entry", Keys: []string{"k", "ArrowUp"}, Description: "Select previous entry"},
+	{Action: "toggle-details", Keys: []string{"Enter"}, Description: "Show or hide structured data of the selected entry"},
+	{Action: "toggle-live-tail", Keys: []string{"l"}, Description: "Pause or resume live tail"},
+	{Action: "show-help", Keys: []string{"?"}, Description: "Show keyboard shortcuts"},
+	{Action: "close", Keys: []string{"Escape"}, Description: "Close the dialog or leave the search field"},
+}
+
+// handleShortcuts returns the keyboard shortcut map of the web interface
+func (s *HTTPServer) handleShortcuts(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    shortcuts,
+	})
+}
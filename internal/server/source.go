@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+
+	"opentrail/internal/interfaces"
+)
+
+// normalizeSourceIP extracts the IP from a remote address ("ip:port" or a bare IP) in canonical form.
+// IPv4-mapped IPv6 addresses are reported as IPv4 and zones are dropped so that the same sender
+// always produces the same value regardless of which listener family accepted it.
+func normalizeSourceIP(address string) string {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+	return ip.Unmap().WithZone("").String()
+}
+
+// processLogFrom hands a message to the log service, recording the source IP if the service supports it
+func processLogFrom(logService interfaces.LogService, message, sourceIP string) error {
+	if processor, ok := logService.(interfaces.SourceLogProcessor); ok && sourceIP != "" {
+		return processor.ProcessLogFrom(message, sourceIP)
+	}
+	return logService.ProcessLog(message)
+}
+
+// processLogTracked hands a message to the log service like processLogFrom, calling parseFailed if
+// the service reports that the message could not be parsed
+func processLogTracked(logService interfaces.LogService, message, sourceIP string, parseFailed func()) error {
+	if processor, ok := logService.(interfaces.TrackedLogProcessor); ok {
+		return processor.ProcessLogTracked(message, sourceIP, parseFailed)
+	}
+	return processLogFrom(logService, message, sourceIP)
+}
+
+// processLogForTenant hands a message to the log service like processLogTracked, recording the
+// tenant the connection was routed to if there is one and the service supports it
+func processLogForTenant(logService interfaces.LogService, message, sourceIP, tenant string, parseFailed func()) error {
+	if processor, ok := logService.(interfaces.TenantLogProcessor); ok && tenant != "" {
+		return processor.ProcessLogForTenant(message, sourceIP, tenant, parseFailed)
+	}
+	return processLogTracked(logService, message, sourceIP, parseFailed)
+}
+
+// processLogInStream hands a message received on a connection to the log service like
+// processLogForTenant, keeping the entries of the connection in arrival order if the service
+// supports it
+func processLogInStream(logService interfaces.LogService, stream int64, message, sourceIP, tenant string, parseFailed func()) error {
+	if processor, ok := logService.(interfaces.OrderedLogProcessor); ok {
+		return processor.ProcessLogInStream(stream, message, sourceIP, tenant, parseFailed, nil)
+	}
+	return processLogForTenant(logService, message, sourceIP, tenant, parseFailed)
+}
@@ -0,0 +1,20 @@
+package server
+
+import "testing"
+
+func TestNormalizeSourceIP(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.10:51234":          "192.0.2.10",
+		"[::ffff:192.0.2.10]:51234": "192.0.2.10",
+		"[2001:DB8::1]:514":         "2001:db8::1",
+		"[fe80::1%eth0]:514":        "fe80::1",
+		"2001:db8:0:0::1":           "2001:db8::1",
+		"not-an-address":            "",
+	}
+
+	for input, expected := range tests {
+		if got := normalizeSourceIP(input); got != expected {
+			t.Errorf("normalizeSourceIP(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// recentBufferResponse is a search of the tail buffer, with its entries limited to the selected
+// fields
+type recentBufferResponse struct {
+	*types.RecentBuffer
+	Entries interface{} `json:"entries"`
+}
+
+// handleRecentBuffer searches the entries of the last minutes the service holds in memory for the
+// live tail. It takes the parameters of /api/logs and answers without touching storage, so it
+// neither waits for a search slot nor fails while storage is busy.
+func (s *HTTPServer) handleRecentBuffer(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reader, ok := s.logService.(interfaces.TailBufferReader)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "The tail buffer is not supported")
+		return
+	}
+
+	query, err := s.parseSearchQuery(r)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid query parameters: %v", err))
+		return
+	}
+
+	redact := s.redactorFor(r)
+	if err := redact.checkQuery(query); err != nil {
+		s.sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	result, err := reader.RecentBuffer(query)
+	switch {
+	case errors.Is(err, interfaces.ErrTailBufferDisabled):
+		s.sendErrorResponse(w, http.StatusNotImplemented, "The tail buffer is disabled")
+		return
+	case errors.Is(err, interfaces.ErrInvalidBufferQuery):
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		log.Printf("Error searching the tail buffer: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to search the tail buffer")
+		return
+	}
+
+	presentInZone(r, result.Entries)
+	result.Entries = redact.entries(result.Entries)
+	response := recentBufferResponse{RecentBuffer: result, Entries: result.Entries}
+	if len(query.Fields) > 0 {
+		response.Entries = selectResultFields(result.Entries, query.Fields)
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
@@ -3,9 +3,14 @@ package server
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,7 +20,8 @@ import (
 )
 
 const (
-	// DefaultReadTimeout is the default timeout for reading from connections
+	// DefaultReadTimeout is the default timeout for reading from connections, after which an idle
+	// connection is closed
 	DefaultReadTimeout = 30 * time.Second
 	// DefaultWriteTimeout is the default timeout for writing to connections
 	DefaultWriteTimeout = 10 * time.Second
@@ -28,11 +34,21 @@ type TCPServer struct {
 	config      *types.Config
 	logService  interfaces.LogService
 	listener    net.Listener
+	listen      func(network, addr string) (net.Listener, error)
+	// proxies are the peers allowed to send a PROXY protocol header
+	proxies *proxyTrust
+
+	// TLS ingestion, nil when connections are plain TCP
+	tlsConfig *tls.Config
+	tenants   *tenantRouter
+	// readFile reads the certificate, key, CA and tenant files of TLS ingestion
+	readFile func(name string) ([]byte, error)
 	
 	// Connection management
-	connections    map[net.Conn]bool
+	connections    map[net.Conn]*tcpConnection
 	connectionsMux sync.RWMutex
 	activeConns    int64
+	lastConnID     int64
 	
 	// Server lifecycle
 	ctx        context.Context
@@ -52,7 +68,42 @@ type TCPServerStats struct {
 	TotalConnections  int64 `json:"total_connections"`
 	MessagesReceived  int64 `json:"messages_received"`
 	ConnectionErrors  int64 `json:"connection_errors"`
-	IsRunning         bool  `json:"is_running"`
+	// Connections closed because they sent nothing for the idle timeout or reached the max lifetime
+	IdleClosed     int64 `json:"idle_closed"`
+	LifetimeClosed int64 `json:"lifetime_closed"`
+	IsRunning      bool  `json:"is_running"`
+}
+
+// ConnectionInfo describes one open ingestion connection
+type ConnectionInfo struct {
+	ID               int64     `json:"id"`
+	RemoteAddr       string    `json:"remote_addr"`
+	ConnectedAt      time.Time `json:"connected_at"`
+	MessagesReceived int64     `json:"messages_received"`
+	BytesReceived    int64     `json:"bytes_received"`
+	ParseErrors      int64     `json:"parse_errors"`
+	// Tenant is the tenant a TLS connection was routed to by its server name
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// tcpConnection holds the statistics and control protocol state of one open connection
+type tcpConnection struct {
+	id          int64
+	conn        net.Conn
+	remoteAddr  string // guarded by connectionsMux, updated once the PROXY header is read
+	tenant      string // guarded by connectionsMux, set once the TLS handshake is done
+	connectedAt time.Time
+	messages    atomic.Int64
+	bytes       atomic.Int64
+	parseErrors atomic.Int64
+
+	// shipper is set once the sender has opted in to the control protocol with a HELLO
+	shipper atomic.Bool
+	// drainDeadline is when a shipper sent a DRAIN notice must have closed, in Unix nanoseconds
+	drainDeadline atomic.Int64
+	writeMux      sync.Mutex
+	// acks is set once a shipper asked for its lines to be acknowledged; only the connection's reader uses it
+	acks *ackTracker
 }
 
 // NewTCPServer creates a new TCP server instance
@@ -62,7 +113,10 @@ func NewTCPServer(config *types.Config, logService interfaces.LogService) *TCPSe
 	return &TCPServer{
 		config:      config,
 		logService:  logService,
-		connections: make(map[net.Conn]bool),
+		listen:      net.Listen,
+		proxies:     newProxyTrust(config.TCPProxyTrusted),
+		readFile:    os.ReadFile,
+		connections: make(map[net.Conn]*tcpConnection),
 		ctx:         ctx,
 		cancel:      cancel,
 		stats: TCPServerStats{
@@ -71,6 +125,22 @@ func NewTCPServer(config *types.Config, logService interfaces.LogService) *TCPSe
 	}
 }
 
+// SetListenFunc overrides how the server obtains its listener (e.g. to reuse an inherited socket)
+func (s *TCPServer) SetListenFunc(listen func(network, addr string) (net.Listener, error)) {
+	s.listen = listen
+}
+
+// SetFileReader overrides how the files of TLS ingestion are read (e.g. to resolve Vault references
+// in their place)
+func (s *TCPServer) SetFileReader(readFile func(name string) ([]byte, error)) {
+	s.readFile = readFile
+}
+
+// listenAddress joins a bind address and port into a listen address (an empty host binds all interfaces)
+func listenAddress(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
 // Start starts the TCP server
 func (s *TCPServer) Start() error {
 	s.runningMux.Lock()
@@ -80,9 +150,15 @@ func (s *TCPServer) Start() error {
 		return fmt.Errorf("TCP server is already running")
 	}
 	
+	tlsConfig, tenants, err := newIngestTLSConfig(s.config, s.readFile)
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS ingestion: %w", err)
+	}
+	s.tlsConfig, s.tenants = tlsConfig, tenants
+
 	// Create listener
-	addr := fmt.Sprintf(":%d", s.config.TCPPort)
-	listener, err := net.Listen("tcp", addr)
+	addr := listenAddress(s.config.TCPBindAddress, s.config.TCPPort)
+	listener, err := s.listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
@@ -98,8 +174,16 @@ func (s *TCPServer) Start() error {
 	// Start accepting connections
 	s.wg.Add(1)
 	go s.acceptConnections()
+
+	// Keep control protocol connections alive
+	s.wg.Add(1)
+	go s.keepAliveLoop()
 	
-	log.Printf("TCP server started on port %d", s.config.TCPPort)
+	if s.tlsConfig != nil {
+		log.Printf("TCP server started on %s with TLS (%d tenant hostnames)", listener.Addr(), len(s.tenants.exact)+len(s.tenants.wildcard))
+	} else {
+		log.Printf("TCP server started on %s", listener.Addr())
+	}
 	return nil
 }
 
@@ -112,13 +196,18 @@ func (s *TCPServer) Stop() error {
 		return nil
 	}
 	
-	// Cancel context to signal shutdown
-	s.cancel()
-	
 	// Close the listener to stop accepting new connections
 	if s.listener != nil {
 		s.listener.Close()
 	}
+
+	// Let shippers finish sending before their connections are closed
+	if s.drainShippers() > 0 {
+		s.waitForShippers(stopDrainTimeout)
+	}
+
+	// Cancel context to signal shutdown
+	s.cancel()
 	
 	// Close all active connections
 	s.connectionsMux.Lock()
@@ -142,6 +231,27 @@ func (s *TCPServer) Stop() error {
 	return nil
 }
 
+// Drain stops accepting new connections and waits up to timeout for active
+// connections to be closed by their clients before the server is stopped
+func (s *TCPServer) Drain(timeout time.Duration) {
+	s.runningMux.RLock()
+	listener := s.listener
+	running := s.isRunning
+	s.runningMux.RUnlock()
+
+	if !running || listener == nil {
+		return
+	}
+
+	listener.Close()
+	s.drainShippers()
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&s.activeConns) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 // GetStats returns server statistics
 func (s *TCPServer) GetStats() TCPServerStats {
 	s.statsMutex.RLock()
@@ -153,6 +263,86 @@ func (s *TCPServer) GetStats() TCPServerStats {
 	return stats
 }
 
+// Connections returns the open connections with their statistics, oldest first
+func (s *TCPServer) Connections() []ConnectionInfo {
+	s.connectionsMux.RLock()
+	defer s.connectionsMux.RUnlock()
+
+	connections := make([]ConnectionInfo, 0, len(s.connections))
+	for _, tracked := range s.connections {
+		connections = append(connections, ConnectionInfo{
+			ID:               tracked.id,
+			RemoteAddr:       tracked.remoteAddr,
+			ConnectedAt:      tracked.connectedAt,
+			MessagesReceived: tracked.messages.Load(),
+			BytesReceived:    tracked.bytes.Load(),
+			ParseErrors:      tracked.parseErrors.Load(),
+			Tenant:           tracked.tenant,
+		})
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ID < connections[j].ID
+	})
+	return connections
+}
+
+// CloseConnection forcibly closes the open connection with the given ID, reporting whether it existed
+func (s *TCPServer) CloseConnection(id int64) bool {
+	s.connectionsMux.RLock()
+	defer s.connectionsMux.RUnlock()
+
+	for conn, tracked := range s.connections {
+		if tracked.id == id {
+			log.Printf("Closing connection %d from %s on request", id, tracked.remoteAddr)
+			// The connection's handler sees the read fail and removes it
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// idleTimeout returns how long a connection may send nothing before it is closed
+func (s *TCPServer) idleTimeout() time.Duration {
+	if s.config.TCPIdleTimeout > 0 {
+		return s.config.TCPIdleTimeout
+	}
+	return DefaultReadTimeout
+}
+
+// readDeadline returns when the next read on a connection times out: after the idle timeout, or at
+// the end of the connection's lifetime if that comes first
+func (s *TCPServer) readDeadline(tracked *tcpConnection, now time.Time) time.Time {
+	deadline := now.Add(s.idleTimeout())
+	if lifetime := s.config.TCPMaxConnectionLifetime; lifetime > 0 {
+		if end := tracked.connectedAt.Add(lifetime); end.Before(deadline) {
+			deadline = end
+		}
+	}
+	if drainDeadline := tracked.drainDeadline.Load(); drainDeadline > 0 {
+		if end := time.Unix(0, drainDeadline); end.Before(deadline) {
+			deadline = end
+		}
+	}
+	return deadline
+}
+
+// closeTimedOut records why a connection whose read timed out is closed
+func (s *TCPServer) closeTimedOut(tracked *tcpConnection, remoteAddr net.Addr) {
+	lifetime := s.config.TCPMaxConnectionLifetime
+	if lifetime > 0 && !time.Now().Before(tracked.connectedAt.Add(lifetime)) {
+		log.Printf("Closing connection from %s after reaching the max lifetime of %v", remoteAddr, lifetime)
+		s.updateStats(func(stats *TCPServerStats) {
+			stats.LifetimeClosed++
+		})
+		return
+	}
+	log.Printf("Closing connection from %s after %v idle", remoteAddr, s.idleTimeout())
+	s.updateStats(func(stats *TCPServerStats) {
+		stats.IdleClosed++
+	})
+}
+
 // acceptConnections runs in a goroutine to accept incoming connections
 func (s *TCPServer) acceptConnections() {
 	defer s.wg.Done()
@@ -178,6 +368,11 @@ func (s *TCPServer) acceptConnections() {
 					if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 						continue
 					}
+
+					// Listener closed while draining, stop accepting
+					if errors.Is(err, net.ErrClosed) {
+						return
+					}
 					
 					log.Printf("Error accepting connection: %v", err)
 					s.updateStats(func(stats *TCPServerStats) {
@@ -212,15 +407,74 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 	defer s.removeConnection(conn)
 	
 	// Add connection to tracking
-	s.addConnection(conn)
+	tracked := s.addConnection(conn)
+	defer s.finishAcks(tracked)
 	
 	// Set up connection timeouts
-	conn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
+	conn.SetReadDeadline(s.readDeadline(tracked, time.Now()))
 	
 	// Create a buffered reader for efficient line reading
 	reader := bufio.NewReaderSize(conn, ConnectionBufferSize)
 	
-	log.Printf("New connection from %s", conn.RemoteAddr())
+	// Behind a load balancer, the PROXY protocol header carries the real client address
+	remoteAddr := conn.RemoteAddr()
+	if s.config.TCPProxyProtocol {
+		// Only a trusted proxy may report the client address; anyone else could forge it
+		if peer := normalizeSourceIP(conn.RemoteAddr().String()); !s.proxies.trusted(peer) {
+			log.Printf("Rejecting connection from %s: not a trusted PROXY protocol peer", conn.RemoteAddr())
+			s.updateStats(func(stats *TCPServerStats) {
+				stats.ConnectionErrors++
+			})
+			return
+		}
+		clientAddr, err := readProxyHeader(reader)
+		if err != nil {
+			log.Printf("Rejecting connection from %s: %v", conn.RemoteAddr(), err)
+			s.updateStats(func(stats *TCPServerStats) {
+				stats.ConnectionErrors++
+			})
+			return
+		}
+		if clientAddr != nil {
+			remoteAddr = clientAddr
+			s.connectionsMux.Lock()
+			tracked.remoteAddr = remoteAddr.String()
+			s.connectionsMux.Unlock()
+		}
+	}
+
+	// The TLS handshake picks the tenant from the server name the sender connected to
+	tenant := ""
+	if s.tlsConfig != nil {
+		tlsConn := tls.Server(&bufferedConn{Conn: conn, reader: reader}, s.tlsConfig)
+		if err := tlsConn.HandshakeContext(s.ctx); err != nil {
+			log.Printf("Rejecting connection from %s: TLS handshake failed: %v", remoteAddr, err)
+			s.updateStats(func(stats *TCPServerStats) {
+				stats.ConnectionErrors++
+			})
+			return
+		}
+		if route := s.tenants.route(tlsConn.ConnectionState().ServerName); route != nil {
+			tenant = route.name
+		}
+		reader = bufio.NewReaderSize(tlsConn, ConnectionBufferSize)
+		s.connectionsMux.Lock()
+		tracked.conn = tlsConn
+		tracked.tenant = tenant
+		s.connectionsMux.Unlock()
+	}
+
+	if tenant != "" {
+		log.Printf("New connection from %s for tenant %s", remoteAddr, tenant)
+	} else {
+		log.Printf("New connection from %s", remoteAddr)
+	}
+	sourceIP := normalizeSourceIP(remoteAddr.String())
+	parseFailed := func() {
+		tracked.parseErrors.Add(1)
+	}
+	// partial holds the start of a line whose read timed out while a shipper drains
+	first, partial := true, ""
 	
 	for {
 		select {
@@ -230,11 +484,20 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 			// Read a line (newline-delimited message)
 			line, err := reader.ReadString('\n')
 			if err != nil {
-				if err.Error() != "EOF" {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					if s.drainAtLifetime(tracked) {
+						tracked.bytes.Add(int64(len(line)))
+						partial += line
+						continue
+					}
+					s.closeTimedOut(tracked, remoteAddr)
+				} else if err.Error() != "EOF" {
 					log.Printf("Error reading from connection %s: %v", conn.RemoteAddr(), err)
 				}
 				return
 			}
+			tracked.bytes.Add(int64(len(line)))
+			line, partial = partial+line, ""
 			
 			// Remove the trailing newline
 			if len(line) > 0 && line[len(line)-1] == '\n' {
@@ -247,13 +510,32 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 			}
 			
 			// Update read deadline
-			conn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
+			conn.SetReadDeadline(s.readDeadline(tracked, time.Now()))
+
+			// Control protocol lines are handled here rather than ingested
+			if s.handleControl(tracked, line, first) {
+				first = false
+				continue
+			}
+			first = false
 			
-			// Process the log message
-			if err := s.logService.ProcessLog(line); err != nil {
+			// Process the log message, acknowledging it once stored if the shipper asked
+			if tracked.acks != nil {
+				err = s.processLogAcked(tracked, line, sourceIP, tenant, parseFailed)
+			} else {
+				err = processLogInStream(s.logService, tracked.id, line, sourceIP, tenant, parseFailed)
+			}
+			if errors.Is(err, interfaces.ErrReadOnly) {
+				// A closed connection makes senders queue their messages and reconnect later,
+				// rather than having every line sent while ingestion is paused dropped
+				log.Printf("Closing connection from %s: %v", remoteAddr, err)
+				return
+			}
+			if err != nil {
 				log.Printf("Error processing log from %s: %v", conn.RemoteAddr(), err)
 				// Don't close connection on processing errors, just log and continue
 			} else {
+				tracked.messages.Add(1)
 				s.updateStats(func(stats *TCPServerStats) {
 					stats.MessagesReceived++
 				})
@@ -263,16 +545,24 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 }
 
 // addConnection adds a connection to the tracking map
-func (s *TCPServer) addConnection(conn net.Conn) {
+func (s *TCPServer) addConnection(conn net.Conn) *tcpConnection {
 	s.connectionsMux.Lock()
 	defer s.connectionsMux.Unlock()
 	
-	s.connections[conn] = true
+	s.lastConnID++
+	tracked := &tcpConnection{
+		id:          s.lastConnID,
+		conn:        conn,
+		remoteAddr:  conn.RemoteAddr().String(),
+		connectedAt: time.Now(),
+	}
+	s.connections[conn] = tracked
 	atomic.AddInt64(&s.activeConns, 1)
 	
 	s.updateStats(func(stats *TCPServerStats) {
 		stats.TotalConnections++
 	})
+	return tracked
 }
 
 // removeConnection removes a connection from the tracking map
@@ -3,6 +3,7 @@ package server
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"opentrail/internal/interfaces"
+	"opentrail/internal/shipper"
 	"opentrail/internal/types"
 )
 
@@ -623,4 +625,424 @@ func TestTCPServer_LongMessages(t *testing.T) {
 	if len(processedLogs) > 0 && processedLogs[0] != longMessage {
 		t.Error("Long message was not processed correctly")
 	}
-}
\ No newline at end of file
+}
+
+func TestTCPServer_BindAddress(t *testing.T) {
+	config := &types.Config{
+		TCPPort:        0, // Use random port
+		TCPBindAddress: "127.0.0.1",
+		MaxConnections: 10,
+	}
+
+	server := NewTCPServer(config, &MockLogService{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	addr, ok := server.listener.Addr().(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("Expected TCP listener address, got %T", server.listener.Addr())
+	}
+	if !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected listener bound to 127.0.0.1, got %s", addr.IP)
+	}
+}
+
+// trackedLogService reports messages starting with "bad" as unparseable
+type trackedLogService struct {
+	MockLogService
+}
+
+func (m *trackedLogService) ProcessLogTracked(rawMessage, sourceIP string, parseFailed func()) error {
+	if strings.HasPrefix(rawMessage, "bad") {
+		parseFailed()
+		return nil
+	}
+	return m.ProcessLog(rawMessage)
+}
+
+func TestTCPServer_ConnectionStats(t *testing.T) {
+	config := &types.Config{
+		TCPPort:        0, // Use random port
+		MaxConnections: 10,
+	}
+
+	server := NewTCPServer(config, &trackedLogService{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(conn, "first message\nsecond message\nbad message\n")
+	time.Sleep(100 * time.Millisecond)
+
+	connections := server.Connections()
+	if len(connections) != 1 {
+		t.Fatalf("Expected one connection, got %+v", connections)
+	}
+	info := connections[0]
+	if info.RemoteAddr != conn.LocalAddr().String() || info.MessagesReceived != 3 || info.BytesReceived != 41 ||
+		info.ParseErrors != 1 || info.ConnectedAt.IsZero() {
+		t.Errorf("Unexpected connection stats: %+v", info)
+	}
+
+	if server.CloseConnection(info.ID + 1) {
+		t.Error("Expected closing an unknown connection to fail")
+	}
+	if !server.CloseConnection(info.ID) {
+		t.Fatal("Expected the connection to be closed")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the closed connection to be unreadable")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if connections := server.Connections(); len(connections) != 0 {
+		t.Errorf("Expected no open connections, got %+v", connections)
+	}
+}
+
+func TestTCPServer_ConnectionTimeouts(t *testing.T) {
+	config := &types.Config{
+		TCPPort:                  0, // Use random port
+		MaxConnections:           10,
+		TCPIdleTimeout:           100 * time.Millisecond,
+		TCPMaxConnectionLifetime: 400 * time.Millisecond,
+	}
+
+	server := NewTCPServer(config, &MockLogService{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+	addr := server.listener.Addr().String()
+
+	// A silent sender is closed after the idle timeout
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer idle.Close()
+	idle.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the idle connection to be closed")
+	}
+
+	// A busy sender is closed at the end of its lifetime
+	busy, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer busy.Close()
+	started := time.Now()
+	for time.Since(started) < time.Second {
+		if _, err := fmt.Fprint(busy, "still here\n"); err != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if elapsed := time.Since(started); elapsed >= time.Second {
+		t.Errorf("Expected the busy connection to be closed after its lifetime, still open after %v", elapsed)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	stats := server.GetStats()
+	if stats.IdleClosed != 1 || stats.LifetimeClosed != 1 || stats.ActiveConnections != 0 {
+		t.Errorf("Expected one idle and one lifetime close, got %+v", stats)
+	}
+}
+
+func TestTCPServer_ShipperProtocol(t *testing.T) {
+	config := &types.Config{
+		TCPPort:                  0, // Use random port
+		MaxConnections:           10,
+		TCPIdleTimeout:           300 * time.Millisecond,
+		TCPMaxConnectionLifetime: 500 * time.Millisecond,
+	}
+
+	mockService := &MockLogService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// The server answers HELLO with its limits
+	fmt.Fprintf(conn, "%s\n", shipper.Hello)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read server HELLO: %v", err)
+	}
+	hello, err := shipper.ParseServerHello(line)
+	if err != nil {
+		t.Fatalf("Failed to parse server HELLO: %v", err)
+	}
+	if hello.IdleTimeout != 300*time.Millisecond || hello.MaxLifetime != 500*time.Millisecond || hello.KeepAlive != 100*time.Millisecond {
+		t.Errorf("Unexpected server HELLO %+v", hello)
+	}
+
+	// Keepalives are not ingested, and the server sends its own until the lifetime ends with a DRAIN
+	fmt.Fprintf(conn, "first\n%s\n", shipper.KeepAlive)
+	keepAlives := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected a DRAIN at the end of the lifetime, got %v", err)
+		}
+		if line == shipper.KeepAlive+"\n" {
+			keepAlives++
+			fmt.Fprintf(conn, "%s\n", shipper.KeepAlive)
+			continue
+		}
+		if _, ok := shipper.ParseDrain(line); !ok {
+			t.Fatalf("Expected a keepalive or DRAIN, got %q", line)
+		}
+		break
+	}
+	if keepAlives == 0 {
+		t.Error("Expected server keepalives before the DRAIN")
+	}
+
+	// Lines sent after the DRAIN are still ingested before the server closes the connection
+	fmt.Fprint(conn, "last\n")
+	conn.(*net.TCPConn).CloseWrite()
+	if _, err := reader.ReadString('\n'); err != io.EOF {
+		t.Errorf("Expected the server to close the drained connection, got %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mockService.mutex.RLock()
+	defer mockService.mutex.RUnlock()
+	if len(mockService.processedLogs) != 2 || mockService.processedLogs[0] != "first" || mockService.processedLogs[1] != "last" {
+		t.Errorf("Expected only the log lines to be ingested, got %q", mockService.processedLogs)
+	}
+}
+
+func TestTCPServer_StopDrainsShippers(t *testing.T) {
+	config := &types.Config{
+		TCPPort:        0, // Use random port
+		MaxConnections: 10,
+	}
+
+	mockService := &MockLogService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "%s\n", shipper.Hello)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("Failed to read server HELLO: %v", err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Stop() }()
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Expected a DRAIN on stop, got %v", err)
+	}
+	if _, ok := shipper.ParseDrain(line); !ok {
+		t.Fatalf("Expected a DRAIN on stop, got %q", line)
+	}
+	fmt.Fprint(conn, "flushed\n")
+	conn.(*net.TCPConn).CloseWrite()
+
+	if err := <-stopped; err != nil {
+		t.Errorf("Failed to stop server: %v", err)
+	}
+	mockService.mutex.RLock()
+	defer mockService.mutex.RUnlock()
+	if len(mockService.processedLogs) != 1 || mockService.processedLogs[0] != "flushed" {
+		t.Errorf("Expected the line sent after the DRAIN to be ingested, got %q", mockService.processedLogs)
+	}
+}
+
+// ackingLogService stores messages shortly after receiving them, failing those starting with "fail"
+type ackingLogService struct {
+	MockLogService
+}
+
+func (m *ackingLogService) ProcessLogAcked(rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error {
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasPrefix(rawMessage, "fail") {
+			done(fmt.Errorf("storage failed"))
+			return
+		}
+		m.ProcessLog(rawMessage)
+		done(nil)
+	}()
+	return nil
+}
+
+func TestTCPServer_ShipperAcks(t *testing.T) {
+	config := &types.Config{
+		TCPPort:        0, // Use random port
+		MaxConnections: 10,
+	}
+
+	server := NewTCPServer(config, &ackingLogService{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	connect := func(hello shipper.ClientHello) (net.Conn, *bufio.Reader, shipper.ServerHello) {
+		conn, err := net.Dial("tcp", server.listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "%s\n", hello)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read server HELLO: %v", err)
+		}
+		serverHello, err := shipper.ParseServerHello(line)
+		if err != nil {
+			t.Fatalf("Failed to parse server HELLO: %v", err)
+		}
+		return conn, reader, serverHello
+	}
+	// readAck returns the next acknowledged count, or -1 once the server closed the connection
+	readAck := func(reader *bufio.Reader) int64 {
+		for {
+			line, err := reader.ReadString('\n')
+			if err == io.EOF {
+				return -1
+			}
+			if err != nil {
+				t.Fatalf("Failed to read ACK: %v", err)
+			}
+			if lines, ok := shipper.ParseAck(line); ok {
+				return lines
+			}
+		}
+	}
+
+	if _, _, hello := connect(shipper.ClientHello{}); hello.Ack {
+		t.Error("Expected no acknowledgements without asking for them")
+	}
+
+	// Lines are acknowledged once stored, and a line that cannot be stored closes the connection
+	conn, reader, hello := connect(shipper.ClientHello{Ack: true})
+	if !hello.Ack {
+		t.Fatal("Expected the server to acknowledge lines")
+	}
+	fmt.Fprint(conn, "one\ntwo\nthree\n")
+	acked := int64(0)
+	for acked < 3 {
+		if acked = readAck(reader); acked < 0 {
+			t.Fatal("Expected the stored lines to be acknowledged before the connection closed")
+		}
+	}
+	if acked != 3 {
+		t.Errorf("Expected 3 lines acknowledged, got %d", acked)
+	}
+	fmt.Fprint(conn, "fail\nfour\n")
+	for acked >= 0 {
+		if acked = readAck(reader); acked > 3 {
+			t.Fatalf("Expected nothing after the failed line to be acknowledged, got %d", acked)
+		}
+	}
+
+	// A shipper closing its side gets every line acknowledged before the server closes
+	conn, reader, _ = connect(shipper.ClientHello{Ack: true})
+	fmt.Fprint(conn, "five\nsix\n")
+	conn.(*net.TCPConn).CloseWrite()
+	last := int64(0)
+	for acked := readAck(reader); acked >= 0; acked = readAck(reader) {
+		last = acked
+	}
+	if last != 2 {
+		t.Errorf("Expected both lines acknowledged before closing, got %d", last)
+	}
+}
+
+// orderedLogService records the stream each message was received on
+type orderedLogService struct {
+	MockLogService
+	streams map[string]int64
+}
+
+func (m *orderedLogService) ProcessLogInStream(stream int64, rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error {
+	m.mutex.Lock()
+	m.streams[rawMessage] = stream
+	m.mutex.Unlock()
+	err := m.ProcessLog(rawMessage)
+	if done != nil {
+		done(err)
+	}
+	return err
+}
+
+func TestTCPServer_ConnectionStreams(t *testing.T) {
+	config := &types.Config{
+		TCPPort:        0, // Use random port
+		MaxConnections: 10,
+	}
+
+	mockService := &orderedLogService{streams: make(map[string]int64)}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	for _, prefix := range []string{"a", "b"} {
+		conn, err := net.Dial("tcp", server.listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		fmt.Fprintf(conn, "%s1\n%s2\n", prefix, prefix)
+		conn.Close()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mockService.mutex.RLock()
+		received := len(mockService.streams)
+		mockService.mutex.RUnlock()
+		if received == 4 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The messages of a connection share a stream that no other connection uses
+	mockService.mutex.RLock()
+	defer mockService.mutex.RUnlock()
+	streams := mockService.streams
+	if len(streams) != 4 {
+		t.Fatalf("Expected 4 messages, got %v", streams)
+	}
+	if streams["a1"] == 0 || streams["a1"] != streams["a2"] || streams["b1"] != streams["b2"] || streams["a1"] == streams["b1"] {
+		t.Errorf("Expected one stream per connection, got %v", streams)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// timeoutClass groups HTTP endpoints that share read and write deadlines
+type timeoutClass string
+
+const (
+	// timeoutAPI covers ordinary API requests, static files and metrics
+	timeoutAPI timeoutClass = "api"
+	// timeoutExport covers downloads that stream large result sets
+	timeoutExport timeoutClass = "export"
+	// timeoutStream covers long-lived WebSocket streams
+	timeoutStream timeoutClass = "stream"
+)
+
+// routeTimeout returns the deadline applied to requests of a class, zero for none
+func (s *HTTPServer) routeTimeout(class timeoutClass) time.Duration {
+	switch class {
+	case timeoutExport:
+		return s.config.HTTPExportTimeout
+	case timeoutStream:
+		return s.config.HTTPStreamTimeout
+	default:
+		return s.config.HTTPAPITimeout
+	}
+}
+
+// timeoutMiddleware sets the read and write deadlines of a request to its class' timeout, replacing
+// the deadlines set for the API class by withTimeouts. They are per request on HTTP/2 connections.
+func (s *HTTPServer) timeoutMiddleware(class timeoutClass, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.setDeadlines(w, class)
+		next(w, r)
+	}
+}
+
+// withTimeouts applies the API class deadlines to every request; routes of other classes override them
+func (s *HTTPServer) withTimeouts(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.setDeadlines(w, timeoutAPI)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// setDeadlines sets the deadlines of the connection or stream serving a request
+func (s *HTTPServer) setDeadlines(w http.ResponseWriter, class timeoutClass) {
+	var deadline time.Time
+	if timeout := s.routeTimeout(class); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	controller := http.NewResponseController(w)
+	for _, set := range []func(time.Time) error{controller.SetReadDeadline, controller.SetWriteDeadline} {
+		if err := set(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("Failed to set %s request deadline: %v", class, err)
+		}
+	}
+}
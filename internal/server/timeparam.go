@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localTimeLayouts are accepted for time parameters without a UTC offset; they are interpreted
+// in the zone given by the tz parameter (UTC when omitted)
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseTimeZone returns the location named by the tz parameter, an IANA name such as
+// "Europe/Berlin". UTC is returned when the parameter is omitted.
+func parseTimeZone(params url.Values) (*time.Location, error) {
+	name := params.Get("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+	// Local would silently depend on the server's configuration
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid tz %q, expected an IANA time zone name", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz %q, expected an IANA time zone name", name)
+	}
+	return loc, nil
+}
+
+// relativeUnits are the units accepted in relative time expressions
+var relativeUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseTimeParam parses an RFC3339 timestamp, a date or date-time without an offset in loc,
+// or a time relative to now such as "now", "-15m", "now-7d" or "now-1h30m"
+func parseTimeParam(name, value string, now time.Time, loc *time.Location) (time.Time, error) {
+	if value == "now" || strings.HasPrefix(value, "now-") || strings.HasPrefix(value, "now+") ||
+		strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		offset, err := parseRelativeOffset(strings.TrimPrefix(value, "now"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return now.Add(offset), nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s format, expected RFC3339, a local date (YYYY-MM-DD[THH:MM[:SS]]) or a relative time (now-15m)", name)
+}
+
+// parseRelativeOffset parses a signed offset such as "-15m" or "+1d12h"; an empty string means zero
+func parseRelativeOffset(expr string) (time.Duration, error) {
+	if expr == "" {
+		return 0, nil
+	}
+
+	sign := time.Duration(1)
+	switch expr[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("relative time %q must start with + or -", expr)
+	}
+	rest := expr[1:]
+	if rest == "" {
+		return 0, fmt.Errorf("relative time %q has no duration", expr)
+	}
+
+	var total time.Duration
+	for rest != "" {
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) {
+			return 0, fmt.Errorf("relative time %q must be a number followed by s, m, h, d or w", expr)
+		}
+		count, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return 0, fmt.Errorf("relative time %q is out of range", expr)
+		}
+		unit, ok := relativeUnits[rest[digits:digits+1]]
+		if !ok {
+			return 0, fmt.Errorf("relative time %q has unknown unit %q", expr, rest[digits:digits+1])
+		}
+		total += time.Duration(count) * unit
+		rest = rest[digits+1:]
+	}
+
+	return sign * total, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-01T12:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"2024-03-01T12:00:00+02:00", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, tokyo)},
+		{"2024-03-01T09:30", time.Date(2024, 3, 1, 9, 30, 0, 0, tokyo)},
+		{"2024-03-01T09:30:15", time.Date(2024, 3, 1, 9, 30, 15, 0, tokyo)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeParam("start_time", tt.value, time.Now(), tokyo)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+
+	if _, err := parseTimeParam("start_time", "yesterday", time.Now(), tokyo); err == nil {
+		t.Error("Expected error for unparseable time")
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	loc, err := parseTimeZone(url.Values{})
+	if err != nil || loc != time.UTC {
+		t.Errorf("Expected UTC by default, got %v (%v)", loc, err)
+	}
+
+	loc, err = parseTimeZone(url.Values{"tz": {"America/New_York"}})
+	if err != nil || loc.String() != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %v (%v)", loc, err)
+	}
+
+	for _, name := range []string{"Mars/Olympus", "Local"} {
+		if _, err := parseTimeZone(url.Values{"tz": {name}}); err == nil {
+			t.Errorf("Expected error for tz %q", name)
+		}
+	}
+}
+
+func TestParseTimeParam_Relative(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"now", now},
+		{"-15m", now.Add(-15 * time.Minute)},
+		{"-24h", now.Add(-24 * time.Hour)},
+		{"now-7d", now.AddDate(0, 0, -7)},
+		{"now-1h30m", now.Add(-90 * time.Minute)},
+		{"now+2w", now.AddDate(0, 0, 14)},
+		{"+30s", now.Add(30 * time.Second)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeParam("start_time", tt.value, now, time.UTC)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+
+	for _, value := range []string{"now-", "-15", "-m", "now-15x", "now-1.5h", "nowish", "-15m-"} {
+		if _, err := parseTimeParam("start_time", value, now, time.UTC); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
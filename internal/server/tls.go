@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// TenantConfig describes a tenant whose senders reach the TLS ingestion listener under their own
+// host names, e.g. logs.tenant-a.example
+type TenantConfig struct {
+	// Name is recorded as the tenant metadata parameter of the tenant's entries
+	Name string `json:"name"`
+	// Hostnames are the TLS server names (SNI) routed to the tenant; "*.example.com" matches one
+	// label below example.com
+	Hostnames []string `json:"hostnames"`
+	// Cert and Key are the PEM certificate and key presented to the tenant's senders; empty uses
+	// the listener's default certificate
+	Cert string `json:"cert,omitempty"`
+	Key  string `json:"key,omitempty"`
+	// ClientCA is a PEM file of the CAs that must have signed the tenant's client certificates;
+	// empty uses the listener's client CAs
+	ClientCA string `json:"client_ca,omitempty"`
+}
+
+// tenantRoute is the tenant and TLS configuration selected by a server name
+type tenantRoute struct {
+	name      string
+	tlsConfig *tls.Config
+}
+
+// tenantRouter picks the tenant of a TLS ingestion connection from the server name it sent
+type tenantRouter struct {
+	exact    map[string]*tenantRoute
+	wildcard map[string]*tenantRoute // keyed by the domain below the "*."
+	// fallback serves connections without a known server name, nil rejects them
+	fallback *tls.Config
+}
+
+// LoadTenants reads a JSON file of tenant configurations with readFile
+func LoadTenants(path string, readFile func(string) ([]byte, error)) ([]TenantConfig, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	var tenants []TenantConfig
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants %s: %w", path, err)
+	}
+	return tenants, nil
+}
+
+// newIngestTLSConfig builds the TLS configuration of the TCP ingestion listener from the default
+// certificate and client CAs and the tenants, read with readFile, or returns nil if TLS ingestion is
+// not configured
+func newIngestTLSConfig(config *types.Config, readFile func(string) ([]byte, error)) (*tls.Config, *tenantRouter, error) {
+	if config.TCPTLSCert == "" && config.TCPTLSTenants == "" {
+		return nil, nil, nil
+	}
+
+	var defaults *tls.Config
+	if config.TCPTLSCert != "" {
+		cert, err := loadKeyPair(config.TCPTLSCert, config.TCPTLSKey, readFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		defaults = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	var clientCAs *x509.CertPool
+	if config.TCPTLSClientCA != "" {
+		pool, err := loadCertPool(config.TCPTLSClientCA, readFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientCAs = pool
+		if defaults != nil {
+			requireClientCerts(defaults, pool)
+		}
+	}
+
+	var tenants []TenantConfig
+	if config.TCPTLSTenants != "" {
+		loaded, err := LoadTenants(config.TCPTLSTenants, readFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tenants = loaded
+	}
+	router, err := newTenantRouter(tenants, defaults, clientCAs, readFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			if route := router.route(hello.ServerName); route != nil {
+				return route.tlsConfig, nil
+			}
+			if router.fallback == nil {
+				return nil, fmt.Errorf("no tenant serves TLS server name %q", hello.ServerName)
+			}
+			return router.fallback, nil
+		},
+	}, router, nil
+}
+
+// newTenantRouter builds the TLS configuration of every tenant, falling back to the default
+// certificate and client CAs for the ones a tenant leaves out
+func newTenantRouter(tenants []TenantConfig, defaults *tls.Config, clientCAs *x509.CertPool, readFile func(string) ([]byte, error)) (*tenantRouter, error) {
+	router := &tenantRouter{
+		exact:    make(map[string]*tenantRoute),
+		wildcard: make(map[string]*tenantRoute),
+		fallback: defaults,
+	}
+	names := make(map[string]bool)
+	for _, tenant := range tenants {
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("tenant name cannot be empty")
+		}
+		if names[tenant.Name] {
+			return nil, fmt.Errorf("duplicate tenant %q", tenant.Name)
+		}
+		names[tenant.Name] = true
+		if len(tenant.Hostnames) == 0 {
+			return nil, fmt.Errorf("tenant %q has no hostnames", tenant.Name)
+		}
+
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		switch {
+		case tenant.Cert != "" || tenant.Key != "":
+			cert, err := loadKeyPair(tenant.Cert, tenant.Key, readFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load TLS certificate of tenant %q: %w", tenant.Name, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		case defaults != nil:
+			tlsConfig.Certificates = defaults.Certificates
+		default:
+			return nil, fmt.Errorf("tenant %q has no certificate and no default certificate is configured", tenant.Name)
+		}
+		pool := clientCAs
+		if tenant.ClientCA != "" {
+			loaded, err := loadCertPool(tenant.ClientCA, readFile)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %q: %w", tenant.Name, err)
+			}
+			pool = loaded
+		}
+		if pool != nil {
+			requireClientCerts(tlsConfig, pool)
+		}
+
+		route := &tenantRoute{name: tenant.Name, tlsConfig: tlsConfig}
+		for _, hostname := range tenant.Hostnames {
+			hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+			routes, key := router.exact, hostname
+			if domain, ok := strings.CutPrefix(hostname, "*."); ok {
+				routes, key = router.wildcard, domain
+			}
+			if key == "" || strings.Contains(key, "*") {
+				return nil, fmt.Errorf("tenant %q has an invalid hostname %q", tenant.Name, hostname)
+			}
+			if other, ok := routes[key]; ok {
+				return nil, fmt.Errorf("hostname %q is routed to both tenant %q and %q", hostname, other.name, tenant.Name)
+			}
+			routes[key] = route
+		}
+	}
+	return router, nil
+}
+
+// route returns the tenant serving a TLS server name, preferring exact host names over wildcards
+func (r *tenantRouter) route(serverName string) *tenantRoute {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if serverName == "" {
+		return nil
+	}
+	if route, ok := r.exact[serverName]; ok {
+		return route
+	}
+	if _, domain, ok := strings.Cut(serverName, "."); ok {
+		return r.wildcard[domain]
+	}
+	return nil
+}
+
+// loadKeyPair reads a PEM certificate and private key with readFile
+func loadKeyPair(certFile, keyFile string, readFile func(string) ([]byte, error)) (tls.Certificate, error) {
+	certPEM, err := readFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := readFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// loadCertPool reads a PEM file of CA certificates with readFile
+func loadCertPool(path string, readFile func(string) ([]byte, error)) (*x509.CertPool, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CAs: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", path)
+	}
+	return pool, nil
+}
+
+// requireClientCerts makes a TLS configuration require client certificates signed by the pool
+func requireClientCerts(config *tls.Config, pool *x509.CertPool) {
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+}
+
+// bufferedConn reads through a buffered reader that may already hold the start of the stream, so
+// that TLS can follow a PROXY protocol header
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// testCA is a certificate authority issuing certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	path string
+}
+
+// newTestCA creates a CA and writes its certificate to dir
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	path := filepath.Join(dir, name+".pem")
+	writePEM(t, path, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, pool: pool, path: path}
+}
+
+// issue writes a certificate and key for the given host names to dir, returning their paths
+func (ca *testCA) issue(t *testing.T, dir, name string, hostnames ...string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     hostnames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath
+}
+
+// clientCert issues a certificate for use by a TLS client
+func clientCert(t *testing.T, dir string, ca *testCA, name string) tls.Certificate {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(ca.issue(t, dir, name, name))
+	if err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+	return cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// writeTenants writes a tenants file to dir
+func writeTenants(t *testing.T, dir string, tenants []TenantConfig) string {
+	t.Helper()
+	data, err := json.Marshal(tenants)
+	if err != nil {
+		t.Fatalf("Failed to encode tenants: %v", err)
+	}
+	path := filepath.Join(dir, "tenants.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write tenants: %v", err)
+	}
+	return path
+}
+
+// tenantRecordingService records the tenant passed along with each message
+type tenantRecordingService struct {
+	MockLogService
+	tenants map[string]string // message -> tenant
+	mutex   sync.Mutex
+}
+
+func (m *tenantRecordingService) ProcessLogForTenant(rawMessage, sourceIP, tenant string, parseFailed func()) error {
+	m.mutex.Lock()
+	if m.tenants == nil {
+		m.tenants = make(map[string]string)
+	}
+	m.tenants[rawMessage] = tenant
+	m.mutex.Unlock()
+	return m.ProcessLog(rawMessage)
+}
+
+// sendTLS connects to the server with a server name and an optional client certificate and sends
+// one line, returning the handshake error
+func sendTLS(t *testing.T, server *TCPServer, roots *x509.CertPool, serverName string, certs []tls.Certificate, line string) error {
+	t.Helper()
+	conn, err := tls.Dial("tcp", server.listener.Addr().String(), &tls.Config{
+		RootCAs:      roots,
+		ServerName:   serverName,
+		Certificates: certs,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		return err
+	}
+	// TLS 1.3 reports a rejected client certificate only on the first read
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func TestTCPServer_TLSTenantRouting(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	defaultCert, defaultKey := ca.issue(t, dir, "default", "logs.example", "*.tenant-b.example")
+	tenantCert, tenantKey := ca.issue(t, dir, "tenant-a", "logs.tenant-a.example")
+
+	config := &types.Config{
+		TCPPort:        0,
+		TCPBindAddress: "127.0.0.1",
+		MaxConnections: 10,
+		TCPTLSCert:     defaultCert,
+		TCPTLSKey:      defaultKey,
+		TCPTLSTenants: writeTenants(t, dir, []TenantConfig{
+			{Name: "tenant-a", Hostnames: []string{"logs.tenant-a.example"}, Cert: tenantCert, Key: tenantKey},
+			{Name: "tenant-b", Hostnames: []string{"*.tenant-b.example"}},
+		}),
+	}
+	mockService := &tenantRecordingService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	for serverName, line := range map[string]string{
+		"logs.tenant-a.example":   "for a",
+		"ingest.tenant-b.example": "for b",
+		"logs.example":            "for nobody",
+	} {
+		if err := sendTLS(t, server, ca.pool, serverName, nil, line); err != nil {
+			t.Fatalf("Failed to send to %s: %v", serverName, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if processed := mockService.GetProcessedLogs(); len(processed) != 3 {
+		t.Fatalf("Expected 3 processed messages, got %v", processed)
+	}
+	mockService.mutex.Lock()
+	defer mockService.mutex.Unlock()
+	if mockService.tenants["for a"] != "tenant-a" || mockService.tenants["for b"] != "tenant-b" {
+		t.Errorf("Expected messages routed to tenant-a and tenant-b, got %v", mockService.tenants)
+	}
+	if tenant, ok := mockService.tenants["for nobody"]; ok {
+		t.Errorf("Expected the default hostname to have no tenant, got %q", tenant)
+	}
+}
+
+func TestTCPServer_TLSTenantClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	clientsA := newTestCA(t, dir, "clients-a")
+	clientsB := newTestCA(t, dir, "clients-b")
+	serverCert, serverKey := ca.issue(t, dir, "server", "logs.tenant-a.example")
+	clientA := clientCert(t, dir, clientsA, "client-a")
+	clientB := clientCert(t, dir, clientsB, "client-b")
+
+	// Without a default certificate, only tenant host names are served
+	config := &types.Config{
+		TCPPort:        0,
+		TCPBindAddress: "127.0.0.1",
+		MaxConnections: 10,
+		TCPTLSTenants: writeTenants(t, dir, []TenantConfig{
+			{Name: "tenant-a", Hostnames: []string{"logs.tenant-a.example"}, Cert: serverCert, Key: serverKey, ClientCA: clientsA.path},
+		}),
+	}
+	mockService := &tenantRecordingService{}
+	server := NewTCPServer(config, mockService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	if err := sendTLS(t, server, ca.pool, "logs.tenant-a.example", []tls.Certificate{clientA}, "from a"); err != nil {
+		t.Fatalf("Expected tenant-a's client to be accepted: %v", err)
+	}
+	if err := sendTLS(t, server, ca.pool, "logs.tenant-a.example", []tls.Certificate{clientB}, "from b"); err == nil {
+		t.Error("Expected a client certificate of another tenant's CA to be rejected")
+	}
+	if err := sendTLS(t, server, ca.pool, "logs.tenant-a.example", nil, "anonymous"); err == nil {
+		t.Error("Expected a client without certificate to be rejected")
+	}
+	if err := sendTLS(t, server, ca.pool, "logs.other.example", []tls.Certificate{clientA}, "unknown"); err == nil {
+		t.Error("Expected an unknown server name to be rejected")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if processed := mockService.GetProcessedLogs(); len(processed) != 1 || processed[0] != "from a" {
+		t.Errorf("Expected only tenant-a's message to be processed, got %v", processed)
+	}
+}
+
+func TestNewTenantRouter(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	cert, _ := ca.issue(t, dir, "server", "example")
+	defaults := &tls.Config{Certificates: []tls.Certificate{clientCert(t, dir, ca, "default")}}
+
+	router, err := newTenantRouter([]TenantConfig{
+		{Name: "exact", Hostnames: []string{"Logs.Tenant.Example."}},
+		{Name: "wildcard", Hostnames: []string{"*.tenant.example"}},
+	}, defaults, nil, os.ReadFile)
+	if err != nil {
+		t.Fatalf("newTenantRouter failed: %v", err)
+	}
+	for serverName, want := range map[string]string{
+		"logs.tenant.example":     "exact",
+		"LOGS.TENANT.EXAMPLE.":    "exact",
+		"other.tenant.example":    "wildcard",
+		"a.b.tenant.example":      "",
+		"tenant.example":          "",
+		"":                        "",
+		"logs.tenant.example.com": "",
+	} {
+		got := ""
+		if route := router.route(serverName); route != nil {
+			got = route.name
+		}
+		if got != want {
+			t.Errorf("route(%q) = %q, want %q", serverName, got, want)
+		}
+	}
+
+	invalid := map[string][]TenantConfig{
+		"empty name":         {{Hostnames: []string{"a.example"}}},
+		"no hostnames":       {{Name: "a"}},
+		"duplicate tenant":   {{Name: "a", Hostnames: []string{"a.example"}}, {Name: "a", Hostnames: []string{"b.example"}}},
+		"duplicate hostname": {{Name: "a", Hostnames: []string{"x.example"}}, {Name: "b", Hostnames: []string{"X.example"}}},
+		"invalid wildcard":   {{Name: "a", Hostnames: []string{"logs.*.example"}}},
+		"missing key":        {{Name: "a", Hostnames: []string{"a.example"}, Cert: cert}},
+	}
+	for name, tenants := range invalid {
+		if _, err := newTenantRouter(tenants, defaults, nil, os.ReadFile); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := newTenantRouter([]TenantConfig{{Name: "a", Hostnames: []string{"a.example"}}}, nil, nil, os.ReadFile); err == nil {
+		t.Error("Expected an error for a tenant without certificate and no default certificate")
+	}
+}
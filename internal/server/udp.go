@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// DefaultUDPMaxMessageSize is the default size in bytes longer datagrams are truncated to
+const DefaultUDPMaxMessageSize = 8192
+
+// UDPServer implements a UDP syslog listener for log ingestion, taking one message per datagram
+// as RFC 5426 does
+type UDPServer struct {
+	config     *types.Config
+	logService interfaces.LogService
+	conn       net.PacketConn
+	listen     func(network, addr string) (net.PacketConn, error)
+
+	// dropping is set while messages are refused by the log service, so only the first drop is
+	// logged; only the reader uses it
+	dropping bool
+
+	// Server lifecycle
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	isRunning  bool
+	runningMux sync.RWMutex
+
+	// Statistics
+	stats      UDPServerStats
+	statsMutex sync.RWMutex
+}
+
+// UDPServerStats represents statistics about the UDP server
+type UDPServerStats struct {
+	DatagramsReceived int64 `json:"datagrams_received"`
+	MessagesReceived  int64 `json:"messages_received"`
+	BytesReceived     int64 `json:"bytes_received"`
+	// Datagrams longer than the max message size, ingested truncated
+	Truncated int64 `json:"truncated"`
+	// Messages the log service refused, e.g. while ingestion is paused or the memory limit is reached
+	Dropped     int64 `json:"dropped"`
+	ParseErrors int64 `json:"parse_errors"`
+	ReadErrors  int64 `json:"read_errors"`
+	IsRunning   bool  `json:"is_running"`
+}
+
+// NewUDPServer creates a new UDP server instance
+func NewUDPServer(config *types.Config, logService interfaces.LogService) *UDPServer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &UDPServer{
+		config:     config,
+		logService: logService,
+		listen:     net.ListenPacket,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetListenFunc overrides how the server obtains its socket (e.g. to reuse an inherited one)
+func (s *UDPServer) SetListenFunc(listen func(network, addr string) (net.PacketConn, error)) {
+	s.listen = listen
+}
+
+// maxMessageSize returns the size in bytes longer datagrams are truncated to
+func (s *UDPServer) maxMessageSize() int {
+	if s.config.UDPMaxMessageSize > 0 {
+		return s.config.UDPMaxMessageSize
+	}
+	return DefaultUDPMaxMessageSize
+}
+
+// Start starts the UDP server
+func (s *UDPServer) Start() error {
+	s.runningMux.Lock()
+	defer s.runningMux.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("UDP server is already running")
+	}
+
+	addr := listenAddress(s.config.UDPBindAddress, s.config.UDPPort)
+	conn, err := s.listen("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.conn = conn
+	s.isRunning = true
+	s.updateStats(func(stats *UDPServerStats) {
+		stats.IsRunning = true
+	})
+
+	s.wg.Add(1)
+	go s.readDatagrams()
+
+	log.Printf("UDP server started on %s", conn.LocalAddr())
+	return nil
+}
+
+// Stop stops the UDP server once the datagram being processed is handed to the log service
+func (s *UDPServer) Stop() error {
+	s.runningMux.Lock()
+	defer s.runningMux.Unlock()
+
+	if !s.isRunning {
+		return nil
+	}
+
+	// Closing the socket interrupts the read in progress
+	s.cancel()
+	s.conn.Close()
+	s.wg.Wait()
+
+	s.isRunning = false
+	s.updateStats(func(stats *UDPServerStats) {
+		stats.IsRunning = false
+	})
+
+	log.Printf("UDP server stopped")
+	return nil
+}
+
+// GetStats returns server statistics
+func (s *UDPServer) GetStats() UDPServerStats {
+	s.statsMutex.RLock()
+	defer s.statsMutex.RUnlock()
+	return s.stats
+}
+
+// readDatagrams runs in a goroutine to read datagrams until the server is stopped
+func (s *UDPServer) readDatagrams() {
+	defer s.wg.Done()
+
+	// One byte more than the max message size tells longer datagrams apart, which the socket
+	// silently cuts to the buffer
+	buffer := make([]byte, s.maxMessageSize()+1)
+	for {
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error reading UDP datagram: %v", err)
+			s.updateStats(func(stats *UDPServerStats) {
+				stats.ReadErrors++
+			})
+			continue
+		}
+		s.handleDatagram(buffer[:n], addr)
+	}
+}
+
+// handleDatagram hands the message of one datagram to the log service
+func (s *UDPServer) handleDatagram(data []byte, addr net.Addr) {
+	received, truncated := len(data), false
+	if limit := s.maxMessageSize(); len(data) > limit {
+		data = truncateDatagram(data, limit)
+		truncated = true
+	}
+	s.updateStats(func(stats *UDPServerStats) {
+		stats.DatagramsReceived++
+		stats.BytesReceived += int64(received)
+		if truncated {
+			stats.Truncated++
+		}
+	})
+
+	// Many senders terminate datagrams like lines of a stream
+	message := strings.TrimRight(string(data), "\r\n\x00")
+	if message == "" {
+		return
+	}
+
+	parseFailed := func() {
+		s.updateStats(func(stats *UDPServerStats) {
+			stats.ParseErrors++
+		})
+	}
+	if err := processLogTracked(s.logService, message, normalizeSourceIP(addr.String()), parseFailed); err != nil {
+		// UDP senders cannot be held back, so refused messages are lost
+		if !s.dropping {
+			log.Printf("Dropping UDP messages: %v", err)
+			s.dropping = true
+		}
+		s.updateStats(func(stats *UDPServerStats) {
+			stats.Dropped++
+		})
+		return
+	}
+	if s.dropping {
+		log.Printf("UDP messages are accepted again")
+		s.dropping = false
+	}
+	s.updateStats(func(stats *UDPServerStats) {
+		stats.MessagesReceived++
+	})
+}
+
+// truncateDatagram cuts data to at most limit bytes, without splitting a UTF-8 character
+func truncateDatagram(data []byte, limit int) []byte {
+	cut := limit
+	for cut > 0 && cut > limit-utf8.UTFMax && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	if !utf8.RuneStart(data[cut]) {
+		// Not UTF-8 anyway
+		cut = limit
+	}
+	return data[:cut]
+}
+
+// updateStats safely updates the server statistics
+func (s *UDPServer) updateStats(updateFunc func(*UDPServerStats)) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	updateFunc(&s.stats)
+}
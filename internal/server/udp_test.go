@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// startUDPServer starts a UDP server on a random local port and dials it
+func startUDPServer(t *testing.T, config *types.Config, logService interfaces.LogService) (*UDPServer, net.Conn) {
+	t.Helper()
+	config.UDPBindAddress = "127.0.0.1"
+
+	server := NewUDPServer(config, logService)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	conn, err := net.Dial("udp", server.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, conn
+}
+
+// waitForUDPStats waits until the server has received the given number of datagrams
+func waitForUDPStats(t *testing.T, server *UDPServer, datagrams int64) UDPServerStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := server.GetStats()
+		if stats.DatagramsReceived >= datagrams || time.Now().After(deadline) {
+			return stats
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUDPServer_StartStop(t *testing.T) {
+	server := NewUDPServer(&types.Config{UDPBindAddress: "127.0.0.1"}, &MockLogService{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	if err := server.Start(); err == nil {
+		t.Error("Expected starting a running server to fail")
+	}
+	if !server.GetStats().IsRunning {
+		t.Error("Expected the server to report running")
+	}
+
+	if err := server.Stop(); err != nil {
+		t.Fatalf("Failed to stop server: %v", err)
+	}
+	if server.GetStats().IsRunning {
+		t.Error("Expected the server to report stopped")
+	}
+	if err := server.Stop(); err != nil {
+		t.Errorf("Expected stopping a stopped server to succeed, got %v", err)
+	}
+}
+
+func TestUDPServer_Datagrams(t *testing.T) {
+	logService := &trackedLogService{}
+	server, conn := startUDPServer(t, &types.Config{}, logService)
+
+	for _, datagram := range []string{"first message\n", "second message\r\n", "", "bad message", "multi\nline"} {
+		fmt.Fprint(conn, datagram)
+	}
+	stats := waitForUDPStats(t, server, 5)
+
+	logs := logService.GetProcessedLogs()
+	if len(logs) != 3 || logs[0] != "first message" || logs[1] != "second message" || logs[2] != "multi\nline" {
+		t.Errorf("Unexpected messages: %q", logs)
+	}
+	if stats.DatagramsReceived != 5 || stats.MessagesReceived != 4 || stats.BytesReceived != 51 ||
+		stats.ParseErrors != 1 || stats.Truncated != 0 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestUDPServer_Truncation(t *testing.T) {
+	logService := &MockLogService{}
+	server, conn := startUDPServer(t, &types.Config{UDPMaxMessageSize: 480}, logService)
+
+	// The character straddling the limit is dropped whole
+	fmt.Fprint(conn, strings.Repeat("a", 479)+"é and more")
+	fmt.Fprint(conn, strings.Repeat("b", 480))
+	stats := waitForUDPStats(t, server, 2)
+
+	logs := logService.GetProcessedLogs()
+	if len(logs) != 2 || logs[0] != strings.Repeat("a", 479) || logs[1] != strings.Repeat("b", 480) {
+		t.Errorf("Unexpected messages: %q", logs)
+	}
+	if stats.Truncated != 1 || stats.MessagesReceived != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestUDPServer_Dropped(t *testing.T) {
+	logService := &MockLogService{}
+	server, conn := startUDPServer(t, &types.Config{}, logService)
+
+	logService.SetProcessError(interfaces.ErrReadOnly)
+	fmt.Fprint(conn, "while paused")
+	fmt.Fprint(conn, "still paused")
+	waitForUDPStats(t, server, 2)
+
+	logService.SetProcessError(nil)
+	fmt.Fprint(conn, "resumed")
+	stats := waitForUDPStats(t, server, 3)
+
+	if logs := logService.GetProcessedLogs(); len(logs) != 1 || logs[0] != "resumed" {
+		t.Errorf("Unexpected messages: %q", logs)
+	}
+	if stats.Dropped != 2 || stats.MessagesReceived != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestHTTPServer_HealthUDPStats(t *testing.T) {
+	logService := &MockLogService{}
+	udpServer, conn := startUDPServer(t, &types.Config{UDPMaxMessageSize: 480}, logService)
+	logService.SetProcessError(interfaces.ErrReadOnly)
+	fmt.Fprint(conn, strings.Repeat("a", 500))
+	waitForUDPStats(t, udpServer, 1)
+
+	httpServer := NewHTTPServer(&types.Config{}, logService)
+	mux := http.NewServeMux()
+	httpServer.setupRoutes(mux)
+	health := func() map[string]any {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+		var response struct {
+			Services map[string]any `json:"services"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Invalid health response %q: %v", w.Body.String(), err)
+		}
+		return response.Services
+	}
+
+	if _, ok := health()["udp_server"]; ok {
+		t.Error("Expected no UDP stats without a UDP server")
+	}
+	httpServer.SetUDPServer(udpServer)
+	stats, _ := health()["udp_server"].(map[string]any)
+	if stats["truncated"] != float64(1) || stats["dropped"] != float64(1) || stats["is_running"] != true {
+		t.Errorf("Unexpected UDP stats %v", stats)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// growthWindow is how many completed days the growth rate is averaged over
+const growthWindow = 7
+
+const (
+	// defaultVolumeDays is how many days a volume report covers when no start is given
+	defaultVolumeDays = 30
+	// maxVolumeDays bounds the days a volume report covers
+	maxVolumeDays = 366
+)
+
+// handleStorageUsage reports the database file sizes, the entries stored per day and how long the
+// disk space lasts at the current growth
+func (s *HTTPServer) handleStorageUsage(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reader, ok := s.logService.(interfaces.StorageUsageReader)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Storage usage is not supported")
+		return
+	}
+
+	usage, err := reader.StorageUsage()
+	if err != nil {
+		log.Printf("Error measuring storage usage: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to measure storage usage")
+		return
+	}
+	projectStorage(usage, int64(s.config.StorageLimitMB)<<20, s.retentionDays(), time.Now())
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    usage,
+	})
+}
+
+// handleCheckpoint checkpoints the write-ahead log on demand, in the mode of the mode parameter
+// (truncate by default, giving the space of the log back)
+func (s *HTTPServer) handleCheckpoint(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodPost {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	checkpointer, ok := s.logService.(interfaces.WALCheckpointer)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Checkpoints are not supported")
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = types.CheckpointTruncate
+	}
+	if !slices.Contains(types.CheckpointModes, mode) {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid mode %q, expected one of %s", mode, strings.Join(types.CheckpointModes, ", ")))
+		return
+	}
+
+	result, err := checkpointer.Checkpoint(mode)
+	if errors.Is(err, interfaces.ErrWALDisabled) {
+		s.sendErrorResponse(w, http.StatusBadRequest, "The database does not use a write-ahead log")
+		return
+	}
+	if err != nil {
+		log.Printf("Error checkpointing the write-ahead log: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to checkpoint the write-ahead log")
+		return
+	}
+
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// projectStorage fills in the capacity fields of usage. The growth rate is the average size of the
+// last completed days, scaled by the ratio of the database size to the entries' size so that
+// indexes count too. limitBytes 0 uses the space in use plus the free disk space.
+func projectStorage(usage *types.StorageUsage, limitBytes int64, retentionDays int, now time.Time) {
+	used := usage.DatabaseBytes + usage.WALBytes
+	usage.LimitBytes = limitBytes
+	usage.RetentionDays = retentionDays
+	if usage.LimitBytes == 0 && usage.DiskFreeBytes > 0 {
+		usage.LimitBytes = used + usage.DiskFreeBytes
+	}
+
+	var totalBytes, recentBytes, recentDays int64
+	today := now.UTC().Format(types.DayLayout)
+	for i := len(usage.Days) - 1; i >= 0; i-- {
+		day := usage.Days[i]
+		totalBytes += day.Bytes
+		// Today is still filling up
+		if day.Day < today && recentDays < growthWindow {
+			recentBytes += day.Bytes
+			recentDays++
+		}
+	}
+	if recentDays == 0 || totalBytes == 0 {
+		return
+	}
+	overhead := float64(usage.DatabaseBytes-usage.FreeBytes) / float64(totalBytes)
+	if overhead < 1 {
+		overhead = 1
+	}
+	usage.GrowthBytesPerDay = int64(float64(recentBytes) / float64(recentDays) * overhead)
+	usage.RetainedBytes = usage.GrowthBytesPerDay * int64(retentionDays)
+
+	if usage.GrowthBytesPerDay == 0 || usage.LimitBytes == 0 || usage.RetainedBytes <= usage.LimitBytes {
+		return
+	}
+	// Free pages inside the file are reused before it grows
+	days := float64(usage.LimitBytes-used+usage.FreeBytes) / float64(usage.GrowthBytesPerDay)
+	if days < 0 {
+		days = 0
+	}
+	usage.DaysUntilLimit = &days
+}
+
+// handleVolume attributes the volume ingested and stored per UTC day to apps, hosts or tenants
+// (group_by=app_name, the default, hostname or tenant) from start to end (YYYY-MM-DD, inclusive,
+// defaulting to the last 30 days), as JSON or, with format=csv, as a CSV file of the days
+func (s *HTTPServer) handleVolume(w http.ResponseWriter, r *http.Request) {
+	s.updateStats(func(stats *HTTPServerStats) {
+		stats.RequestsHandled++
+	})
+
+	if r.Method != http.MethodGet {
+		s.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	attributor, ok := s.logService.(interfaces.VolumeAttributor)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusNotImplemented, "Volume attribution is not supported")
+		return
+	}
+
+	query, err := parseVolumeQuery(r, time.Now())
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		s.sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid format %q, expected json or csv", format))
+		return
+	}
+
+	report, err := attributor.Volume(query)
+	if err != nil {
+		log.Printf("Error reporting volume: %v", err)
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Failed to report volume")
+		return
+	}
+
+	if format == "csv" {
+		writeVolumeCSV(w, report)
+		return
+	}
+	s.sendJSONResponse(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// parseVolumeQuery reads the grouping and the days of a volume report from the request
+func parseVolumeQuery(r *http.Request, now time.Time) (types.VolumeQuery, error) {
+	params := r.URL.Query()
+	query := types.VolumeQuery{GroupBy: params.Get("group_by")}
+	if query.GroupBy == "" {
+		query.GroupBy = types.VolumeByAppName
+	}
+	if !slices.Contains(types.VolumeGroupings, query.GroupBy) {
+		return query, fmt.Errorf("Invalid group_by %q, expected one of %s", query.GroupBy, strings.Join(types.VolumeGroupings, ", "))
+	}
+
+	query.EndDay = now.UTC().Truncate(24 * time.Hour)
+	if end := params.Get("end"); end != "" {
+		parsed, err := time.Parse(types.DayLayout, end)
+		if err != nil {
+			return query, fmt.Errorf("Invalid end %q, expected YYYY-MM-DD", end)
+		}
+		query.EndDay = parsed
+	}
+	query.StartDay = query.EndDay.AddDate(0, 0, 1-defaultVolumeDays)
+	if start := params.Get("start"); start != "" {
+		parsed, err := time.Parse(types.DayLayout, start)
+		if err != nil {
+			return query, fmt.Errorf("Invalid start %q, expected YYYY-MM-DD", start)
+		}
+		query.StartDay = parsed
+	}
+
+	if query.StartDay.After(query.EndDay) {
+		return query, fmt.Errorf("start must not be after end")
+	}
+	if query.EndDay.Sub(query.StartDay) >= maxVolumeDays*24*time.Hour {
+		return query, fmt.Errorf("A volume report covers at most %d days", maxVolumeDays)
+	}
+	return query, nil
+}
+
+// writeVolumeCSV writes the days of a volume report as a CSV attachment, one row per day and group
+func writeVolumeCSV(w http.ResponseWriter, report *types.VolumeReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="opentrail-volume-%s-%s-%s.csv"`,
+		report.GroupBy, report.StartDay, report.EndDay))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"day", report.GroupBy, "ingested_entries", "ingested_bytes", "stored_entries", "stored_bytes", "avg_ingested_bytes"})
+	for _, row := range report.Days {
+		writer.Write([]string{
+			row.Day,
+			row.Group,
+			strconv.FormatInt(row.IngestedEntries, 10),
+			strconv.FormatInt(row.IngestedBytes, 10),
+			strconv.FormatInt(row.StoredEntries, 10),
+			strconv.FormatInt(row.StoredBytes, 10),
+			strconv.FormatInt(row.AvgIngestedBytes, 10),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing volume report: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -30,6 +31,8 @@ type WebSocketServer struct {
 	logService interfaces.LogService
 	upgrader   websocket.Upgrader
 	server     *http.Server
+	listen     func(network, addr string) (net.Listener, error)
+	proxies    *proxyTrust
 
 	// Connection management
 	connections    map[*websocket.Conn]bool
@@ -60,20 +63,20 @@ type WebSocketServerStats struct {
 // NewWebSocketServer creates a new WebSocket server instance
 func NewWebSocketServer(config *types.Config, logService interfaces.LogService) *WebSocketServer {
 	ctx, cancel := context.WithCancel(context.Background())
+	proxies := newProxyTrust(config.TrustedProxies)
 
 	return &WebSocketServer{
 		config:      config,
 		logService:  logService,
+		listen:      net.Listen,
+		proxies:     proxies,
 		connections: make(map[*websocket.Conn]bool),
 		ctx:         ctx,
 		cancel:      cancel,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  WebSocketBufferSize,
 			WriteBufferSize: WebSocketBufferSize,
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow all origins for simplicity
-				return true
-			},
+			CheckOrigin:     originChecker(config.AllowedOrigins, proxies),
 		},
 		stats: WebSocketServerStats{
 			IsRunning: false,
@@ -81,6 +84,11 @@ func NewWebSocketServer(config *types.Config, logService interfaces.LogService)
 	}
 }
 
+// SetListenFunc overrides how the server obtains its listener (e.g. to reuse an inherited socket)
+func (s *WebSocketServer) SetListenFunc(listen func(network, addr string) (net.Listener, error)) {
+	s.listen = listen
+}
+
 // Start starts the WebSocket server
 func (s *WebSocketServer) Start() error {
 	s.runningMux.Lock()
@@ -95,10 +103,16 @@ func (s *WebSocketServer) Start() error {
 	mux.HandleFunc("/ws/logs", s.handleWebSocket)
 
 	s.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.config.WebSocketPort),
+		Addr:    listenAddress(s.config.WebSocketBindAddress, s.config.WebSocketPort),
 		Handler: mux,
 	}
 
+	// Bind before returning so listen errors are reported to the caller
+	listener, err := s.listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+
 	s.isRunning = true
 
 	// Update stats
@@ -110,8 +124,8 @@ func (s *WebSocketServer) Start() error {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		log.Printf("WebSocket server starting on port %d", s.config.WebSocketPort)
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("WebSocket server starting on %s", listener.Addr())
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Printf("WebSocket server error: %v", err)
 			s.updateStats(func(stats *WebSocketServerStats) {
 				stats.ConnectionErrors++
@@ -201,11 +215,11 @@ func (s *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Request
 
 	// Handle the connection
 	s.wg.Add(1)
-	go s.handleConnection(conn)
+	go s.handleConnection(conn, s.proxies.clientIP(r))
 }
 
 // handleConnection handles a single WebSocket connection
-func (s *WebSocketServer) handleConnection(conn *websocket.Conn) {
+func (s *WebSocketServer) handleConnection(conn *websocket.Conn, sourceIP string) {
 	defer s.wg.Done()
 	defer s.removeConnection(conn)
 
@@ -215,7 +229,7 @@ func (s *WebSocketServer) handleConnection(conn *websocket.Conn) {
 	// Set connection deadlines
 	conn.SetReadDeadline(time.Now().Add(DefaultWebSocketReadTimeout))
 
-	log.Printf("New WebSocket connection from %s", conn.RemoteAddr())
+	log.Printf("New WebSocket connection from %s (client %s)", conn.RemoteAddr(), sourceIP)
 
 	for {
 		select {
@@ -248,7 +262,7 @@ func (s *WebSocketServer) handleConnection(conn *websocket.Conn) {
 			conn.SetReadDeadline(time.Now().Add(DefaultWebSocketReadTimeout))
 
 			// Process the log message
-			if err := s.logService.ProcessLog(logMessage); err != nil {
+			if err := processLogFrom(s.logService, logMessage, sourceIP); err != nil {
 				log.Printf("Error processing WebSocket log from %s: %v", conn.RemoteAddr(), err)
 				// Don't close connection on processing errors, just log and continue
 			} else {
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// authCacheTTL is how long a successful authentication is remembered; it also bounds how often
+	// the last use of an API token is recorded
+	authCacheTTL = time.Minute
+	// apiTokenPrefix starts every API token, so leaked tokens are easy to recognize
+	apiTokenPrefix = "ot_"
+	// maxPasswordLength is the longest password bcrypt hashes
+	maxPasswordLength = 72
+)
+
+// dummyPasswordHash is checked when no user has the name, so failed logins take as long whether or
+// not the name is taken
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("opentrail"), bcrypt.DefaultCost)
+	return hash
+})
+
+// authCacheEntry is a remembered authentication, of a user or of an API token
+type authCacheEntry struct {
+	user    *types.User
+	token   *types.APIToken
+	expires time.Time
+}
+
+// accountStore returns the storage backend keeping users and API tokens
+func (s *LogService) accountStore() (interfaces.AccountStore, error) {
+	store, ok := s.storage.(interfaces.AccountStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support accounts")
+	}
+	return store, nil
+}
+
+// validateAccountName checks the name of a user or API token. Usernames are sent in Basic
+// authentication, which cannot carry a colon.
+func validateAccountName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: %s is required", interfaces.ErrInvalidAccount, kind)
+	}
+	if len(name) > types.MaxAccountName {
+		return fmt.Errorf("%w: %s must be at most %d bytes", interfaces.ErrInvalidAccount, kind, types.MaxAccountName)
+	}
+	if strings.ContainsRune(name, ':') || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: %s must not contain colons or control characters", interfaces.ErrInvalidAccount, kind)
+	}
+	return nil
+}
+
+// validateRole checks the role of a user or API token
+func validateRole(role string) error {
+	if role != types.RoleAdmin && role != types.RoleReader {
+		return fmt.Errorf("%w: role must be %s or %s", interfaces.ErrInvalidAccount, types.RoleAdmin, types.RoleReader)
+	}
+	return nil
+}
+
+// hashPassword checks the length of a password and hashes it
+func hashPassword(password string) (string, error) {
+	if len(password) < types.MinUserPassword || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: password must have between %d and %d characters", interfaces.ErrInvalidAccount,
+			types.MinUserPassword, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CreateUser validates and saves a new user with the password and role
+func (s *LogService) CreateUser(username, password, role string) (*types.User, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	username = strings.TrimSpace(username)
+	if err := validateAccountName("username", username); err != nil {
+		return nil, err
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &types.User{Username: username, Role: role}
+	if err := store.CreateUser(user, hash); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Users lists the users by name
+func (s *LogService) Users() ([]types.User, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Users()
+}
+
+// UpdateUser changes the password of a user unless it is empty, and its role unless it is empty.
+// Logins remembered with the old password or role are forgotten.
+func (s *LogService) UpdateUser(username, password, role string) (*types.User, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	if password == "" && role == "" {
+		return nil, fmt.Errorf("%w: a password or role is required", interfaces.ErrInvalidAccount)
+	}
+	if role != "" {
+		if err := validateRole(role); err != nil {
+			return nil, err
+		}
+	}
+	hash := ""
+	if password != "" {
+		if hash, err = hashPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := store.UpdateUser(username, role, hash)
+	s.clearAuthCache()
+	return user, err
+}
+
+// DeleteUser removes a user
+func (s *LogService) DeleteUser(username string) error {
+	store, err := s.accountStore()
+	if err != nil {
+		return err
+	}
+	err = store.DeleteUser(username)
+	s.clearAuthCache()
+	return err
+}
+
+// Authenticate returns the user with the password, ErrUserNotFound when there is none. Successful
+// logins are remembered for a minute, since the web interface sends the password with every request.
+func (s *LogService) Authenticate(username, password string) (*types.User, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte("password\x00" + username + "\x00" + password))
+	if entry, ok := s.cachedAuth(key); ok {
+		return entry.user, nil
+	}
+
+	user, hash, err := store.UserCredentials(username)
+	if errors.Is(err, interfaces.ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, interfaces.ErrUserNotFound
+	}
+
+	s.cacheAuth(key, authCacheEntry{user: user, expires: time.Now().Add(authCacheTTL)})
+	return user, nil
+}
+
+// hashAPIToken returns the hash an API token is stored by. Tokens are random, so a plain hash
+// cannot be reversed.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken validates and saves a new API token valid until ttl from now, or without expiry
+// when ttl is zero, setting its token, ID and times
+func (s *LogService) CreateAPIToken(token *types.APIToken, ttl time.Duration) error {
+	store, err := s.accountStore()
+	if err != nil {
+		return err
+	}
+	token.Name = strings.TrimSpace(token.Name)
+	if err := validateAccountName("name", token.Name); err != nil {
+		return err
+	}
+	if err := validateRole(token.Role); err != nil {
+		return err
+	}
+	if ttl != 0 && (ttl < time.Minute || ttl > types.MaxAPITokenTTL) {
+		return fmt.Errorf("%w: expiry must be between a minute and %s", interfaces.ErrInvalidAccount, types.MaxAPITokenTTL)
+	}
+
+	token.ExpiresAt, token.LastUsedAt = nil, nil
+	if ttl != 0 {
+		expires := time.Now().UTC().Add(ttl)
+		token.ExpiresAt = &expires
+	}
+	var secret [32]byte
+	rand.Read(secret[:])
+	value := apiTokenPrefix + hex.EncodeToString(secret[:])
+	if err := store.CreateAPIToken(token, hashAPIToken(value)); err != nil {
+		return err
+	}
+	token.Token = value
+	return nil
+}
+
+// APITokens lists the API tokens, newest first
+func (s *LogService) APITokens() ([]types.APIToken, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.APITokens()
+}
+
+// DeleteAPIToken removes an API token, which stops authenticating at once
+func (s *LogService) DeleteAPIToken(id int64) error {
+	store, err := s.accountStore()
+	if err != nil {
+		return err
+	}
+	err = store.DeleteAPIToken(id)
+	s.clearAuthCache()
+	return err
+}
+
+// AuthenticateToken returns the API token a bearer token belongs to, ErrAPITokenNotFound when it is
+// unknown or expired. Its last use is recorded when it is not remembered from the last minute.
+func (s *LogService) AuthenticateToken(value string) (*types.APIToken, error) {
+	store, err := s.accountStore()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte("token\x00" + value))
+	if entry, ok := s.cachedAuth(key); ok {
+		return entry.token, nil
+	}
+
+	now := time.Now()
+	token, err := store.APIToken(hashAPIToken(value), now)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.TouchAPIToken(token.ID, now); err != nil {
+		log.Printf("Error recording the use of API token %q: %v", token.Name, err)
+	}
+
+	expires := now.Add(authCacheTTL)
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(expires) {
+		expires = *token.ExpiresAt
+	}
+	s.cacheAuth(key, authCacheEntry{token: token, expires: expires})
+	return token, nil
+}
+
+// cachedAuth returns a remembered authentication that did not expire
+func (s *LogService) cachedAuth(key [32]byte) (authCacheEntry, bool) {
+	s.authCacheMutex.Lock()
+	defer s.authCacheMutex.Unlock()
+	entry, ok := s.authCache[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(s.authCache, key)
+		return authCacheEntry{}, false
+	}
+	return entry, ok
+}
+
+// cacheAuth remembers a successful authentication, dropping expired ones first
+func (s *LogService) cacheAuth(key [32]byte, entry authCacheEntry) {
+	s.authCacheMutex.Lock()
+	defer s.authCacheMutex.Unlock()
+	if s.authCache == nil {
+		s.authCache = make(map[[32]byte]authCacheEntry)
+	}
+	now := time.Now()
+	for cached, remembered := range s.authCache {
+		if now.After(remembered.expires) {
+			delete(s.authCache, cached)
+		}
+	}
+	s.authCache[key] = entry
+}
+
+// clearAuthCache forgets the remembered authentications, so changed and removed accounts take
+// effect at once
+func (s *LogService) clearAuthCache() {
+	s.authCacheMutex.Lock()
+	defer s.authCacheMutex.Unlock()
+	s.authCache = nil
+}
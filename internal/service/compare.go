@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// comparePageSize is the number of entries read from storage at a time by a comparison
+	comparePageSize = 500
+	// compareScanLimit bounds the entries a comparison examines per time range
+	compareScanLimit = 20000
+)
+
+// variablePart matches the parts of a message that differ between occurrences of the same event:
+// quoted values and words containing a digit, such as numbers, IDs, addresses and durations
+var variablePart = regexp.MustCompile(`"[^"]*"|'[^']*'|[\w.:/@-]*\d[\w.:/@-]*`)
+
+// messagePattern returns a message with its variable parts replaced by <*>
+func messagePattern(message string) string {
+	return variablePart.ReplaceAllString(message, "<*>")
+}
+
+// patternKey identifies a message pattern of one app
+type patternKey struct {
+	appName string
+	pattern string
+}
+
+// compareCounts are the counts of one time range
+type compareCounts struct {
+	total      int64
+	truncated  bool
+	severities map[int]int64
+	patterns   map[patternKey]int64
+}
+
+// Compare runs a search over a baseline and a current time range and returns how the number of
+// entries per severity and per message pattern changed between them. At most compareScanLimit
+// entries of each range are examined, the most recent ones.
+func (s *LogService) Compare(query types.CompareQuery) (*types.CompareResult, error) {
+	if !query.BaselineStart.Before(query.BaselineEnd) || !query.CurrentStart.Before(query.CurrentEnd) {
+		return nil, fmt.Errorf("comparison ranges must start before they end")
+	}
+
+	if err := s.acquireSearchSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseSearchSlot()
+
+	// Patterns keep the most recent current message, falling back to the baseline's
+	examples := make(map[patternKey]string)
+	severest := make(map[patternKey]int)
+	baseline, err := s.compareCounts(query.Search, query.BaselineStart, query.BaselineEnd, examples, severest)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.compareCounts(query.Search, query.CurrentStart, query.CurrentEnd, examples, severest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.CompareResult{
+		Baseline:   types.CompareRange{StartTime: query.BaselineStart, EndTime: query.BaselineEnd, Count: baseline.total, Truncated: baseline.truncated},
+		Current:    types.CompareRange{StartTime: query.CurrentStart, EndTime: query.CurrentEnd, Count: current.total, Truncated: current.truncated},
+		Severities: []types.SeverityDelta{},
+		Patterns:   []types.PatternDelta{},
+	}
+	for severity := 0; severity <= 7; severity++ {
+		before, after := baseline.severities[severity], current.severities[severity]
+		if before == 0 && after == 0 {
+			continue
+		}
+		result.Severities = append(result.Severities, types.SeverityDelta{
+			Severity: severity, Baseline: before, Current: after, Delta: after - before,
+		})
+	}
+
+	for key := range examples {
+		before, after := baseline.patterns[key], current.patterns[key]
+		result.Patterns = append(result.Patterns, types.PatternDelta{
+			Pattern:  key.pattern,
+			AppName:  key.appName,
+			Severity: severest[key],
+			Example:  examples[key],
+			Baseline: before,
+			Current:  after,
+			Delta:    after - before,
+			New:      before == 0,
+			Gone:     after == 0,
+		})
+	}
+	result.TotalPatterns = len(result.Patterns)
+	sort.Slice(result.Patterns, func(i, j int) bool {
+		a, b := result.Patterns[i], result.Patterns[j]
+		if a.New != b.New {
+			return a.New
+		}
+		if a.New && a.Severity != b.Severity {
+			return a.Severity < b.Severity
+		}
+		if da, db := abs(a.Delta), abs(b.Delta); da != db {
+			return da > db
+		}
+		if a.AppName != b.AppName {
+			return a.AppName < b.AppName
+		}
+		return a.Pattern < b.Pattern
+	})
+	if query.Limit > 0 && len(result.Patterns) > query.Limit {
+		result.Patterns = result.Patterns[:query.Limit]
+	}
+	return result, nil
+}
+
+// compareCounts counts the entries of one time range matching a search by severity and pattern,
+// recording an example message and the most severe severity of every pattern
+func (s *LogService) compareCounts(search types.SearchQuery, start, end time.Time, examples map[patternKey]string, severest map[patternKey]int) (*compareCounts, error) {
+	page := search
+	page.StartTime = &start
+	page.EndTime = &end
+	page.Collapse = false
+	page.Offset = 0
+	page.Limit = comparePageSize
+	page.Fields = []string{"timestamp", "severity", "app_name", "message"}
+
+	counts := &compareCounts{severities: make(map[int]int64), patterns: make(map[patternKey]int64)}
+	seen := make(map[patternKey]bool)
+	for {
+		entries, err := s.storage.Search(page)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			key := patternKey{appName: entry.AppName, pattern: messagePattern(entry.Message)}
+			counts.total++
+			counts.severities[entry.Severity]++
+			counts.patterns[key]++
+			// Entries come newest first, so the first one seen in a range is its most recent
+			if !seen[key] {
+				seen[key] = true
+				examples[key] = entry.Message
+			}
+			if severity, ok := severest[key]; !ok || entry.Severity < severity {
+				severest[key] = entry.Severity
+			}
+		}
+		if len(entries) < page.Limit {
+			return counts, nil
+		}
+		if page.Offset+len(entries) >= compareScanLimit {
+			counts.truncated = true
+			return counts, nil
+		}
+		page.Offset += len(entries)
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
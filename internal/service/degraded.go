@@ -0,0 +1,351 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// DefaultDegradeAfter is how long writes must keep failing before the degraded mode is entered
+	DefaultDegradeAfter = 10 * time.Second
+	// degradeMinFailures is the fewest consecutive failed writes that enter the degraded mode, so a
+	// single write failing slowly does not
+	degradeMinFailures = 5
+	// degradedProbeInterval is how often a write is attempted in the degraded mode to find out
+	// whether storage recovered
+	degradedProbeInterval = 5 * time.Second
+	// requeueInterval is how often a spooled message being replayed looks for room in the queue
+	requeueInterval = 10 * time.Millisecond
+	// replaySuffix names the spool file being replayed, while new messages go to a fresh spool
+	replaySuffix = ".replay"
+)
+
+// errSpoolFull is returned for messages that would take the degraded spool past its limit
+var errSpoolFull = errors.New("degraded spool is full")
+
+// storageHealth follows the outcome of writes to decide when storage is down. Writes failing for
+// degradeAfter without one succeeding enter the degraded mode, in which a single write is attempted
+// per probe interval until one succeeds again.
+type storageHealth struct {
+	mu sync.Mutex
+	// degradeAfter is how long writes must keep failing, 0 never degrades
+	degradeAfter time.Duration
+	// probeInterval is how often a write is attempted in the degraded mode
+	probeInterval time.Duration
+	failures      int
+	firstFailure  time.Time
+	degraded      bool
+	nextProbe     time.Time
+}
+
+// attempt reports whether a message should be written to storage: always outside the degraded
+// mode, and once per probe interval in it
+func (h *storageHealth) attempt(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.degraded {
+		return true
+	}
+	if now.Before(h.nextProbe) {
+		return false
+	}
+	h.nextProbe = now.Add(h.probeInterval)
+	return true
+}
+
+// failed records a failed write, reporting whether storage is now considered down
+func (h *storageHealth) failed(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failures == 0 {
+		h.firstFailure = now
+	}
+	h.failures++
+	if h.degraded || h.degradeAfter == 0 || h.failures < degradeMinFailures || now.Sub(h.firstFailure) < h.degradeAfter {
+		return false
+	}
+	h.degraded = true
+	h.nextProbe = now.Add(h.probeInterval)
+	return true
+}
+
+// succeeded records a successful write, reporting whether storage recovered from the degraded mode
+func (h *storageHealth) succeeded() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures = 0
+	recovered := h.degraded
+	h.degraded = false
+	return recovered
+}
+
+// spooledLog is a message kept in the degraded spool, one JSON object per line
+type spooledLog struct {
+	Message  string    `json:"message"`
+	SourceIP string    `json:"source_ip,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Received time.Time `json:"received"`
+}
+
+// degradedSpool keeps the messages received in the degraded mode in a file until storage recovers
+// and they are replayed. The file being replayed is renamed with replaySuffix, so messages spooled
+// meanwhile are kept for the next replay.
+type degradedSpool struct {
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// openDegradedSpool opens the spool file at path, keeping the messages of an earlier run. maxBytes
+// bounds the size of the file, 0 leaves it unbounded.
+func openDegradedSpool(path string, maxBytes int64) (*degradedSpool, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open degraded spool: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read degraded spool: %w", err)
+	}
+	return &degradedSpool{path: path, maxBytes: maxBytes, file: file, size: info.Size()}, nil
+}
+
+// append adds a message to the spool
+func (sp *degradedSpool) append(item queuedLog) error {
+	line, err := json.Marshal(spooledLog{Message: item.message, SourceIP: item.sourceIP, Tenant: item.tenant, Received: item.received})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.file == nil {
+		return fmt.Errorf("degraded spool is closed")
+	}
+	if sp.maxBytes > 0 && sp.size+int64(len(line)) > sp.maxBytes {
+		return errSpoolFull
+	}
+	n, err := sp.file.Write(line)
+	sp.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write degraded spool: %w", err)
+	}
+	return nil
+}
+
+// take returns the path of the messages to replay, empty if there are none: a replay file left by
+// an interrupted replay, or else the spooled messages, which are moved aside for a fresh spool
+func (sp *degradedSpool) take() (string, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	replay := sp.path + replaySuffix
+	if _, err := os.Stat(replay); err == nil {
+		return replay, nil
+	}
+	if sp.size == 0 || sp.file == nil {
+		return "", nil
+	}
+
+	sp.file.Close()
+	sp.file = nil
+	if err := os.Rename(sp.path, replay); err != nil {
+		return "", fmt.Errorf("failed to move degraded spool aside: %w", err)
+	}
+	file, err := os.OpenFile(sp.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open degraded spool: %w", err)
+	}
+	sp.file = file
+	sp.size = 0
+	return replay, nil
+}
+
+// close closes the spool file, keeping its messages for the next run
+func (sp *degradedSpool) close() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.file == nil {
+		return nil
+	}
+	err := sp.file.Close()
+	sp.file = nil
+	return err
+}
+
+// recordWrite feeds the outcome of a write to the storage health, switching to the degraded mode
+// when storage is down and back once a write succeeds. A duplicate was rejected by working storage.
+func (s *LogService) recordWrite(err error) {
+	if err == nil || errors.Is(err, interfaces.ErrDuplicateEntry) {
+		if s.health.succeeded() {
+			s.leaveDegraded()
+		}
+		return
+	}
+	if s.health.failed(time.Now()) {
+		s.enterDegraded(err)
+	}
+}
+
+// enterDegraded switches from the normal to the degraded mode after writes kept failing. A mode an
+// admin switched to is kept.
+func (s *LogService) enterDegraded(cause error) {
+	message := cause.Error()
+	if len(message) > maxModeMessageLength {
+		message = message[:maxModeMessageLength]
+	}
+
+	s.modeMutex.Lock()
+	defer s.modeMutex.Unlock()
+	log.Printf("Storage writes keep failing, entering degraded mode: %v", cause)
+	if s.mode.Accepting() && s.mode.Mode != types.ModeDegraded {
+		now := time.Now()
+		s.mode = types.ModeStatus{Mode: types.ModeDegraded, Message: message, Since: &now}
+	}
+}
+
+// leaveDegraded switches from the degraded back to the normal mode once a write succeeded, and
+// replays the messages spooled meanwhile
+func (s *LogService) leaveDegraded() {
+	s.modeMutex.Lock()
+	log.Printf("Storage writes succeed again, leaving degraded mode")
+	if s.mode.Mode == types.ModeDegraded {
+		now := time.Now()
+		s.mode = types.ModeStatus{Mode: types.ModeNormal, Since: &now}
+	}
+	s.modeMutex.Unlock()
+
+	s.startReplay()
+}
+
+// storeDegraded handles an entry received while storage is down: it is spooled if a spool is
+// configured and sent to subscribers, so outputs such as SIEM forwarding keep receiving entries.
+// Senders waiting for an acknowledgement are only told the entry is stored once it is spooled.
+func (s *LogService) storeDegraded(item queuedLog, entry *types.LogEntry) {
+	err := interfaces.ErrStorageUnavailable
+	if s.spool != nil {
+		if err = s.spool.append(item); err != nil {
+			log.Printf("Failed to spool entry in degraded mode: %v", err)
+			err = fmt.Errorf("%w: %v", interfaces.ErrStorageUnavailable, err)
+		}
+	}
+	s.updateStats(func(stats *interfaces.ServiceStats) {
+		if err == nil {
+			stats.SpooledLogs++
+		} else {
+			stats.UnstoredLogs++
+		}
+	})
+
+	s.announce(item, entry)
+	if item.done != nil {
+		item.done(err)
+	}
+}
+
+// startReplay replays the spooled messages in the background unless a replay is running already
+func (s *LogService) startReplay() {
+	if s.spool == nil || !s.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.replaying.Store(false)
+		if err := s.replaySpool(); err != nil {
+			log.Printf("Failed to replay degraded spool: %v", err)
+		}
+	}()
+}
+
+// replaySpool queues the spooled messages again, in the order they were received, and removes the
+// replay file once all of them are queued. An interrupted replay starts over on the next one.
+func (s *LogService) replaySpool() error {
+	path, err := s.spool.take()
+	if err != nil || path == "" {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer file.Close()
+
+	replayed := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var spooled spooledLog
+		if err := json.Unmarshal(scanner.Bytes(), &spooled); err != nil {
+			// A line cut off by a crash while it was written
+			continue
+		}
+		item := queuedLog{
+			message:  spooled.Message,
+			sourceIP: spooled.SourceIP,
+			tenant:   spooled.Tenant,
+			received: spooled.Received,
+			replayed: true,
+		}
+		if err := s.requeue(item); err != nil {
+			return err
+		}
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read replay file: %w", err)
+	}
+
+	s.updateStats(func(stats *interfaces.ServiceStats) {
+		stats.ReplayedLogs += int64(replayed)
+	})
+	log.Printf("Replayed %d entries spooled in degraded mode", replayed)
+	return os.Remove(path)
+}
+
+// requeue adds a spooled message to the processing queue, waiting for memory and room in the queue
+// rather than dropping it
+func (s *LogService) requeue(item queuedLog) error {
+	release, err := s.reserveMemory(&item)
+	for errors.Is(err, interfaces.ErrMemoryLimit) {
+		release, err = s.reserveMemory(&item)
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Holding the lock keeps Stop from closing the queue while sending
+		s.runningMux.RLock()
+		if !s.isRunning {
+			s.runningMux.RUnlock()
+			release()
+			return fmt.Errorf("service is not running")
+		}
+		select {
+		case s.logQueue <- item:
+			s.runningMux.RUnlock()
+			return nil
+		default:
+		}
+		s.runningMux.RUnlock()
+
+		select {
+		case <-time.After(requeueInterval):
+		case <-s.ctx.Done():
+			release()
+			return fmt.Errorf("service is shutting down")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// reencryptBatchSize is the number of entries re-encrypted per storage transaction
+const reencryptBatchSize = 500
+
+// StartReencrypt starts encrypting the configured fields of every stored entry with the current key
+// of its tenant and app in the background: after a key was added to rotate the previous one out, or
+// a field was added to those encrypted. Only one job runs at a time.
+func (s *LogService) StartReencrypt() (*types.ReencryptStatus, error) {
+	if s.encryptor == nil {
+		return nil, interfaces.ErrEncryptionDisabled
+	}
+	reencrypter, ok := s.storage.(interfaces.Reencrypter)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support re-encryption")
+	}
+
+	s.reencryptMutex.Lock()
+	defer s.reencryptMutex.Unlock()
+
+	if s.reencrypt.Running {
+		return nil, interfaces.ErrReencryptRunning
+	}
+
+	startedAt := time.Now()
+	s.reencrypt = types.ReencryptStatus{Running: true, StartedAt: &startedAt}
+
+	s.wg.Add(1)
+	go s.runReencrypt(reencrypter)
+
+	status := s.reencrypt
+	return &status, nil
+}
+
+// ReencryptStatus reports the progress of the most recent re-encryption job
+func (s *LogService) ReencryptStatus() types.ReencryptStatus {
+	s.reencryptMutex.RLock()
+	defer s.reencryptMutex.RUnlock()
+	return s.reencrypt
+}
+
+// runReencrypt re-encrypts batch after batch until every entry was seen or the service stops
+func (s *LogService) runReencrypt(reencrypter interfaces.Reencrypter) {
+	defer s.wg.Done()
+
+	var afterID int64
+	var jobErr error
+	for {
+		if s.ctx.Err() != nil {
+			jobErr = fmt.Errorf("re-encryption interrupted by shutdown")
+			break
+		}
+
+		batch, err := reencrypter.ReencryptBatch(afterID, reencryptBatchSize, s.encryptor.Seal)
+		if err != nil {
+			jobErr = err
+			break
+		}
+
+		if batch.Updated > 0 {
+			s.clearSearchCache()
+			s.clearTailBuffer()
+		}
+
+		s.reencryptMutex.Lock()
+		s.reencrypt.Processed += batch.Processed
+		s.reencrypt.Updated += batch.Updated
+		s.reencryptMutex.Unlock()
+
+		if batch.Done {
+			break
+		}
+		afterID = batch.LastID
+	}
+
+	if jobErr != nil {
+		log.Printf("Re-encryption stopped: %v", jobErr)
+	}
+
+	finishedAt := time.Now()
+	s.reencryptMutex.Lock()
+	s.reencrypt.Running = false
+	s.reencrypt.FinishedAt = &finishedAt
+	if jobErr != nil {
+		s.reencrypt.Error = jobErr.Error()
+	}
+	s.reencryptMutex.Unlock()
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// queuedLogOverhead approximates the memory of a message apart from its text: the queued
+	// message, the parsed entry with its maps and the storage write request
+	queuedLogOverhead = 512
+	// memoryLimitWait is how long a message waits for pending messages to release memory before it
+	// is rejected with ErrMemoryLimit
+	memoryLimitWait = 2 * time.Second
+)
+
+// memoryCost approximates the memory a message holds until its entry is written: its text, the
+// fields parsed from it and the overhead of the structures carrying them
+func memoryCost(item queuedLog) int64 {
+	return queuedLogOverhead + 2*int64(len(item.message)) + int64(len(item.sourceIP)+len(item.tenant))
+}
+
+// memoryAccount tracks the approximate memory held by messages from being queued until their
+// entries are written, and holds senders back while it exceeds the limit
+type memoryAccount struct {
+	mu    sync.Mutex
+	held  int64
+	limit int64
+	// wait is how long a message waits for memory before it is rejected
+	wait  time.Duration
+	gauge prometheus.Gauge
+	// freed is closed and replaced when memory is released while senders wait, waking them
+	freed   chan struct{}
+	waiters int
+
+	// Totals of the memory reserved so far, to estimate what an entry buffered for a subscriber holds
+	reserved int64
+	messages int64
+}
+
+func newMemoryAccount() *memoryAccount {
+	return &memoryAccount{
+		wait:  memoryLimitWait,
+		gauge: metrics.GetMemoryMetrics().Held.WithLabelValues(metrics.MemoryPending),
+		freed: make(chan struct{}),
+	}
+}
+
+// setLimit configures the memory pending messages may hold, 0 for no limit
+func (a *memoryAccount) setLimit(limit int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limit = max(limit, 0)
+	metrics.GetMemoryMetrics().Limit.Set(float64(a.limit))
+}
+
+// reserve accounts for the memory of a message, waiting up to the wait while the limit would
+// be exceeded. A message is always admitted when nothing else is pending, so one larger than the
+// limit cannot block ingestion forever.
+func (a *memoryAccount) reserve(ctx context.Context, size int64) error {
+	var timer *time.Timer
+	for {
+		a.mu.Lock()
+		if a.limit == 0 || a.held == 0 || a.held+size <= a.limit {
+			a.held += size
+			a.reserved += size
+			a.messages++
+			held := a.held
+			a.mu.Unlock()
+			a.gauge.Set(float64(held))
+			return nil
+		}
+		freed := a.freed
+		a.waiters++
+		a.mu.Unlock()
+
+		if timer == nil {
+			metrics.GetMemoryMetrics().Waits.Inc()
+			timer = time.NewTimer(a.wait)
+			defer timer.Stop()
+		}
+		var err error
+		select {
+		case <-freed:
+		case <-timer.C:
+			metrics.GetMemoryMetrics().Rejections.Inc()
+			err = interfaces.ErrMemoryLimit
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		a.mu.Lock()
+		a.waiters--
+		a.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// release returns the memory reserved for a message once its entry is written or dropped
+func (a *memoryAccount) release(size int64) {
+	a.mu.Lock()
+	a.held -= size
+	held := a.held
+	if a.waiters > 0 {
+		close(a.freed)
+		a.freed = make(chan struct{})
+	}
+	a.mu.Unlock()
+	a.gauge.Set(float64(held))
+}
+
+// pending returns the memory held by pending messages and the limit
+func (a *memoryAccount) pending() (int64, int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.held, a.limit
+}
+
+// averageCost returns the average memory reserved per message so far
+func (a *memoryAccount) averageCost() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.messages == 0 {
+		return queuedLogOverhead
+	}
+	return a.reserved / a.messages
+}
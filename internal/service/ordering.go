@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sync"
+
+	"opentrail/internal/types"
+)
+
+// streamOrder numbers the messages of each connection stream and releases their entries to
+// subscribers in that order. Entries are stored in queue order, but those with an idempotency key
+// are only announced once committed, which would let the entries after them overtake them.
+type streamOrder struct {
+	mu      sync.Mutex
+	streams map[int64]*streamState
+}
+
+// streamState follows the messages of one stream that are not released yet
+type streamState struct {
+	assigned uint64
+	released uint64
+	// completed holds the outcome of messages that follow one still in flight, a nil entry for
+	// messages with nothing to announce
+	completed map[uint64]*types.LogEntry
+}
+
+func newStreamOrder() *streamOrder {
+	return &streamOrder{streams: make(map[int64]*streamState)}
+}
+
+// next numbers the next message of a stream, starting with 1. Messages must be numbered in the
+// order they are queued.
+func (o *streamOrder) next(stream int64) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	state := o.streams[stream]
+	if state == nil {
+		state = &streamState{completed: make(map[uint64]*types.LogEntry)}
+		o.streams[stream] = state
+	}
+	state.assigned++
+	return state.assigned
+}
+
+// complete records that message seq of a stream is done, with the entry to announce or nil, and
+// passes the entries that are now in order to release, under the lock so that releases do not
+// interleave. A stream is forgotten once nothing of it is in flight.
+func (o *streamOrder) complete(stream int64, seq uint64, entry *types.LogEntry, release func(*types.LogEntry)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	state := o.streams[stream]
+	if state == nil || seq <= state.released {
+		return
+	}
+	state.completed[seq] = entry
+	for {
+		entry, ok := state.completed[state.released+1]
+		if !ok {
+			break
+		}
+		delete(state.completed, state.released+1)
+		state.released++
+		if entry != nil {
+			release(entry)
+		}
+	}
+	if state.released == state.assigned {
+		delete(o.streams, stream)
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// preferencesStore returns the storage backend keeping user preferences
+func (s *LogService) preferencesStore() (interfaces.PreferencesStore, error) {
+	store, ok := s.storage.(interfaces.PreferencesStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support user preferences")
+	}
+	return store, nil
+}
+
+// UserPreferences returns the web interface preferences of a user, empty if none were saved
+func (s *LogService) UserPreferences(user string) (*types.UserPreferences, error) {
+	store, err := s.preferencesStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.UserPreferences(user)
+}
+
+// SaveUserPreferences validates and replaces the web interface preferences of a user. Filters are
+// stored in a canonical form, so the same query is recognized however its keys were ordered.
+func (s *LogService) SaveUserPreferences(user string, preferences *types.UserPreferences) error {
+	store, err := s.preferencesStore()
+	if err != nil {
+		return err
+	}
+	if len(preferences.Display) > 0 {
+		if preferences.Display, err = canonicalObject(preferences.Display); err != nil {
+			return fmt.Errorf("%w: display: %v", interfaces.ErrInvalidPreferences, err)
+		}
+	}
+	if len(preferences.PinnedFilters) > types.MaxPinnedFilters {
+		return fmt.Errorf("%w: at most %d filters can be pinned", interfaces.ErrInvalidPreferences, types.MaxPinnedFilters)
+	}
+	if preferences.PinnedFilters == nil {
+		preferences.PinnedFilters = []types.PinnedFilter{}
+	}
+	for i := range preferences.PinnedFilters {
+		pinned := &preferences.PinnedFilters[i]
+		pinned.Name = strings.TrimSpace(pinned.Name)
+		if pinned.Name == "" || len(pinned.Name) > types.MaxPinnedFilterName {
+			return fmt.Errorf("%w: pinned filter %d needs a name of at most %d bytes", interfaces.ErrInvalidPreferences, i+1, types.MaxPinnedFilterName)
+		}
+		if pinned.Filters, err = canonicalObject(pinned.Filters); err != nil {
+			return fmt.Errorf("%w: pinned filter %q: %v", interfaces.ErrInvalidPreferences, pinned.Name, err)
+		}
+	}
+	queries := preferences.RecentQueries
+	preferences.RecentQueries = []json.RawMessage{}
+	for i, query := range queries {
+		canonical, err := canonicalObject(query)
+		if err != nil {
+			return fmt.Errorf("%w: recent query %d: %v", interfaces.ErrInvalidPreferences, i+1, err)
+		}
+		preferences.RecentQueries = appendRecentQuery(preferences.RecentQueries, canonical)
+	}
+
+	s.preferencesMutex.Lock()
+	defer s.preferencesMutex.Unlock()
+	return store.SaveUserPreferences(user, preferences)
+}
+
+// AddRecentQuery records the filters of a search as the most recent query of a user, moving it to
+// the front if it was already recorded and dropping the oldest beyond types.MaxRecentQueries
+func (s *LogService) AddRecentQuery(user string, query json.RawMessage) (*types.UserPreferences, error) {
+	store, err := s.preferencesStore()
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := canonicalObject(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: query: %v", interfaces.ErrInvalidPreferences, err)
+	}
+
+	s.preferencesMutex.Lock()
+	defer s.preferencesMutex.Unlock()
+	preferences, err := store.UserPreferences(user)
+	if err != nil {
+		return nil, err
+	}
+	queries := []json.RawMessage{canonical}
+	for _, recent := range preferences.RecentQueries {
+		queries = appendRecentQuery(queries, recent)
+	}
+	preferences.RecentQueries = queries
+	if preferences.PinnedFilters == nil {
+		preferences.PinnedFilters = []types.PinnedFilter{}
+	}
+	if err := store.SaveUserPreferences(user, preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// DeleteUserPreferences removes the web interface preferences of a user
+func (s *LogService) DeleteUserPreferences(user string) error {
+	store, err := s.preferencesStore()
+	if err != nil {
+		return err
+	}
+	s.preferencesMutex.Lock()
+	defer s.preferencesMutex.Unlock()
+	return store.DeleteUserPreferences(user)
+}
+
+// appendRecentQuery appends a query to the recent queries unless it is already among them or they
+// are full
+func appendRecentQuery(queries []json.RawMessage, query json.RawMessage) []json.RawMessage {
+	if len(queries) >= types.MaxRecentQueries {
+		return queries
+	}
+	for _, recent := range queries {
+		if bytes.Equal(recent, query) {
+			return queries
+		}
+	}
+	return append(queries, query)
+}
+
+// canonicalObject checks that a value is a JSON object and returns it with its keys sorted and
+// without insignificant whitespace
+func canonicalObject(value json.RawMessage) (json.RawMessage, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal(value, &object); err != nil || object == nil {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	return json.Marshal(object)
+}
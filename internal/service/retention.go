@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// retentionSetting is the setting the retention period set through the admin API is stored as
+const retentionSetting = "retention_days"
+
+// Retention returns the retention period in effect
+func (s *LogService) Retention() types.RetentionStatus {
+	s.retentionMutex.RLock()
+	defer s.retentionMutex.RUnlock()
+	return types.RetentionStatus{
+		Days:           s.retentionDays,
+		ConfiguredDays: s.configuredRetention,
+		Overridden:     s.retentionOverridden,
+	}
+}
+
+// UpdateRetention sets the retention period and stores it, so it outlasts restarts and takes
+// precedence over the configured one. A running service removes the entries past it at once.
+func (s *LogService) UpdateRetention(days int) (types.RetentionStatus, error) {
+	store, ok := s.storage.(interfaces.SettingStore)
+	if !ok {
+		return types.RetentionStatus{}, fmt.Errorf("storage backend does not support settings")
+	}
+	if days < 1 || days > types.MaxRetentionDays {
+		return types.RetentionStatus{}, fmt.Errorf("%w: days must be between 1 and %d, got %d",
+			interfaces.ErrInvalidRetention, types.MaxRetentionDays, days)
+	}
+	if err := store.SaveSetting(retentionSetting, strconv.Itoa(days)); err != nil {
+		return types.RetentionStatus{}, err
+	}
+
+	s.retentionMutex.Lock()
+	s.retentionDays, s.retentionOverridden = days, true
+	s.retentionMutex.Unlock()
+	s.wakeRetention()
+	return s.Retention(), nil
+}
+
+// ResetRetention removes the stored retention period, going back to the configured one
+func (s *LogService) ResetRetention() (types.RetentionStatus, error) {
+	store, ok := s.storage.(interfaces.SettingStore)
+	if !ok {
+		return types.RetentionStatus{}, fmt.Errorf("storage backend does not support settings")
+	}
+	if err := store.DeleteSetting(retentionSetting); err != nil {
+		return types.RetentionStatus{}, err
+	}
+
+	s.retentionMutex.Lock()
+	s.retentionDays, s.retentionOverridden = s.configuredRetention, false
+	s.retentionMutex.Unlock()
+	s.wakeRetention()
+	return s.Retention(), nil
+}
+
+// loadRetention applies the retention period stored through the admin API, if any. A period that
+// cannot be read leaves the configured one in effect.
+func (s *LogService) loadRetention() {
+	store, ok := s.storage.(interfaces.SettingStore)
+	if !ok {
+		return
+	}
+	value, err := store.Setting(retentionSetting)
+	if errors.Is(err, interfaces.ErrSettingNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading the stored retention period: %v", err)
+		return
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > types.MaxRetentionDays {
+		log.Printf("Ignoring the invalid stored retention period %q", value)
+		return
+	}
+
+	s.retentionMutex.Lock()
+	defer s.retentionMutex.Unlock()
+	if days != s.configuredRetention {
+		log.Printf("Keeping entries for %d days as set through the admin API, instead of the configured %d", days, s.configuredRetention)
+	}
+	s.retentionDays, s.retentionOverridden = days, true
+}
+
+// wakeRetention has the retention scheduler apply a changed period without waiting for its next run
+func (s *LogService) wakeRetention() {
+	select {
+	case s.retentionChanged <- struct{}{}:
+	default:
+	}
+}
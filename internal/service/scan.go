@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+const (
+	// scanPageSize is the number of entries an adhoc scan reads from storage at a time
+	scanPageSize = 1000
+	// scanBusyWait is how long a scan waits before trying again when no search slot was free
+	scanBusyWait = 100 * time.Millisecond
+)
+
+// Scan reads every stored entry from the newest ID down and reports those matching query. Each page
+// takes a search slot of its own, so a long scan shares storage with searches rather than holding a
+// slot throughout; when searches keep the slots busy, the scan waits instead of failing. The context
+// is checked between pages to stop the scan once its caller gives up.
+func (s *LogService) Scan(ctx context.Context, query types.ScanQuery, match func(*types.LogEntry) error, progress func(types.ScanProgress) error) (types.ScanProgress, error) {
+	var status types.ScanProgress
+	scanner, ok := s.storage.(interfaces.EntryScanner)
+	if !ok {
+		return status, fmt.Errorf("storage backend does not support scans")
+	}
+	matches, err := scanMatcher(query)
+	if err != nil {
+		return status, err
+	}
+
+	started := time.Now()
+	scanMetrics := metrics.GetScanMetrics()
+	finish := func(err error) (types.ScanProgress, error) {
+		status.ElapsedMS = time.Since(started).Milliseconds()
+		outcome := status.Outcome
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			outcome = types.ScanTimeout
+		case errors.Is(err, context.Canceled):
+			outcome = metrics.ScanCancelled
+		case err != nil:
+			outcome = metrics.ScanFailed
+		}
+		scanMetrics.Scans.WithLabelValues(outcome).Inc()
+		return status, err
+	}
+
+	var beforeID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return finish(err)
+		}
+		page, err := s.scanPage(scanner, beforeID, query.Raw)
+		if errors.Is(err, interfaces.ErrSearchBusy) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(scanBusyWait):
+			}
+			continue
+		}
+		if err != nil {
+			return finish(err)
+		}
+		scanMetrics.Rows.Add(float64(len(page)))
+
+		for _, entry := range page {
+			status.Scanned++
+			status.LastID = entry.ID
+			if (query.StartTime != nil && entry.Timestamp.Before(*query.StartTime)) ||
+				(query.EndTime != nil && entry.Timestamp.After(*query.EndTime)) || !matches(entry) {
+				continue
+			}
+			status.Matched++
+			if err := match(entry); err != nil {
+				return finish(err)
+			}
+			if query.Limit > 0 && status.Matched >= int64(query.Limit) {
+				status.Outcome = types.ScanLimit
+				return finish(nil)
+			}
+		}
+		if len(page) < scanPageSize {
+			status.Outcome = types.ScanComplete
+			return finish(nil)
+		}
+		beforeID = status.LastID
+
+		status.ElapsedMS = time.Since(started).Milliseconds()
+		if err := progress(status); err != nil {
+			return finish(err)
+		}
+	}
+}
+
+// scanPage reads a page of entries for a scan once a search slot is free
+func (s *LogService) scanPage(scanner interfaces.EntryScanner, beforeID int64, withRaw bool) ([]*types.LogEntry, error) {
+	if err := s.acquireSearchSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseSearchSlot()
+	return read(s, func() ([]*types.LogEntry, error) { return scanner.ScanPage(beforeID, scanPageSize, withRaw) })
+}
+
+// scanMatcher returns the function matching the messages of a scan
+func scanMatcher(query types.ScanQuery) (func(*types.LogEntry) bool, error) {
+	var pattern *regexp.Regexp
+	if query.Regex != "" {
+		var err error
+		if pattern, err = regexp.Compile(query.Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	text := strings.ToLower(query.Text)
+	return func(entry *types.LogEntry) bool {
+		message := entry.Message
+		if query.Raw {
+			message = entry.Raw
+		}
+		if query.Mask != nil {
+			message = query.Mask(message)
+		}
+		if text != "" && !strings.Contains(strings.ToLower(message), text) {
+			return false
+		}
+		return pattern == nil || pattern.MatchString(message)
+	}, nil
+}
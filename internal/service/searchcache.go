@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// DefaultSearchCacheSize is the default number of search results kept
+	DefaultSearchCacheSize = 256
+	// maxCachedResultEntries bounds the entries of a result worth caching; larger results are
+	// rare enough, and costly enough to keep, that they are always read from storage
+	maxCachedResultEntries = 5000
+)
+
+// timeRange is the span of entry timestamps a search covers; nil leaves a side open
+type timeRange struct {
+	start *time.Time
+	end   *time.Time
+}
+
+// covers reports whether a timestamp falls within the range
+func (r timeRange) covers(t time.Time) bool {
+	return (r.start == nil || !t.Before(*r.start)) && (r.end == nil || !t.After(*r.end))
+}
+
+// cachedSearch is the result of a search, kept until it expires or a write falls in its range
+type cachedSearch struct {
+	timeRange
+	results []*types.LogEntry
+	expires time.Time
+}
+
+// pendingSearch is a search running against storage. A write falling in its range while it runs
+// may be missing from its result, which is then not cached.
+type pendingSearch struct {
+	timeRange
+	stale bool
+}
+
+// searchCache keeps the results of recent searches for a short time, so dashboards firing the same
+// query every few seconds do not each hit storage. Queries are keyed with their time bounds
+// truncated to the TTL, so a relative range ("last 15 minutes") refired within the same bucket is
+// served the result of the first query; entries written since with timestamps in its range drop it.
+// It is safe for concurrent use.
+type searchCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*cachedSearch
+	pending map[*pendingSearch]struct{}
+}
+
+// newSearchCache creates a cache keeping up to maxEntries results for ttl
+func newSearchCache(ttl time.Duration, maxEntries int) *searchCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultSearchCacheSize
+	}
+	return &searchCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*cachedSearch),
+		pending:    make(map[*pendingSearch]struct{}),
+	}
+}
+
+// key normalizes a query into its cache key and the range of timestamps a cached result for it
+// covers, which spans the whole buckets of its bounds
+func (c *searchCache) key(query types.SearchQuery) (string, timeRange) {
+	var covered timeRange
+	if query.StartTime != nil {
+		start := query.StartTime.UTC().Truncate(c.ttl)
+		query.StartTime = &start
+		covered.start = &start
+	}
+	if query.EndTime != nil {
+		end := query.EndTime.UTC().Truncate(c.ttl)
+		query.EndTime = &end
+		last := end.Add(c.ttl)
+		covered.end = &last
+	}
+	// Filters are combined with AND, so their order does not change the result
+	if len(query.Filters) > 1 {
+		filters := append([]types.FieldFilter(nil), query.Filters...)
+		sort.Slice(filters, func(i, j int) bool {
+			if filters[i].Field != filters[j].Field {
+				return filters[i].Field < filters[j].Field
+			}
+			if filters[i].Value != filters[j].Value {
+				return filters[i].Value < filters[j].Value
+			}
+			return !filters[i].Negate && filters[j].Negate
+		})
+		query.Filters = filters
+	}
+
+	data, _ := json.Marshal(query)
+	return string(data), covered
+}
+
+// get returns copies of the entries of a cached result, so callers may change them
+func (c *searchCache) get(key string, now time.Time) ([]*types.LogEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(cached.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return copyEntries(cached.results), true
+}
+
+// begin records a search about to run against storage
+func (c *searchCache) begin(query types.SearchQuery) *pendingSearch {
+	pending := &pendingSearch{timeRange: timeRange{start: query.StartTime, end: query.EndTime}}
+	c.mu.Lock()
+	c.pending[pending] = struct{}{}
+	c.mu.Unlock()
+	return pending
+}
+
+// finish caches the result of a search unless it failed, is too large or may be missing a write
+func (c *searchCache) finish(pending *pendingSearch, key string, covered timeRange, results []*types.LogEntry, err error, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, pending)
+	if err != nil || pending.stale || len(results) > maxCachedResultEntries {
+		return
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = &cachedSearch{timeRange: covered, results: copyEntries(results), expires: now.Add(c.ttl)}
+}
+
+// evict drops the expired results, or the one expiring first if none has
+func (c *searchCache) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, cached := range c.entries {
+		if !now.Before(cached.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || cached.expires.Before(oldest) {
+			oldestKey, oldest = key, cached.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// invalidate drops the results whose range covers the timestamp of a written entry, returning how
+// many were dropped
+func (c *searchCache) invalidate(timestamp time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dropped := 0
+	for key, cached := range c.entries {
+		if cached.covers(timestamp) {
+			delete(c.entries, key)
+			dropped++
+		}
+	}
+	for pending := range c.pending {
+		if pending.covers(timestamp) {
+			pending.stale = true
+		}
+	}
+	return dropped
+}
+
+// clear drops every result, after entries were deleted or rewritten
+func (c *searchCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*cachedSearch)
+	for pending := range c.pending {
+		pending.stale = true
+	}
+}
+
+// copyEntries copies the entries of a result; structured data is shared, as it is only replaced,
+// never changed in place, once an entry is stored
+func copyEntries(entries []*types.LogEntry) []*types.LogEntry {
+	copied := make([]*types.LogEntry, len(entries))
+	for i, entry := range entries {
+		entry := *entry
+		copied[i] = &entry
+	}
+	return copied
+}
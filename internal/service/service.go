@@ -2,12 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"opentrail/internal/fieldcrypt"
 	"opentrail/internal/interfaces"
+	"opentrail/internal/lifecycle"
+	"opentrail/internal/metrics"
+	"opentrail/internal/querylang"
+	"opentrail/internal/resilience"
+	"opentrail/internal/sanitize"
 	"opentrail/internal/types"
 )
 
@@ -18,10 +31,56 @@ const (
 	DefaultBatchTimeout = 100 * time.Millisecond
 	// DefaultQueueSize is the default size of the processing queue
 	DefaultQueueSize = 10000
+	// DefaultParseWorkers is the default number of goroutines parsing the messages of a batch
+	DefaultParseWorkers = 1
 	// MaxSubscribers is the maximum number of concurrent subscribers
 	MaxSubscribers = 100
+	// DefaultMaxConcurrentSearches is the default number of storage searches allowed to run at once
+	DefaultMaxConcurrentSearches = 4
+	// DefaultSearchQueueTimeout is how long a search waits for a free slot before being rejected
+	DefaultSearchQueueTimeout = 5 * time.Second
+	// reprocessBatchSize is the number of entries re-parsed per storage transaction
+	reprocessBatchSize = 500
+	// collapsePageSize is the number of entries read from storage at a time by a collapsed search
+	collapsePageSize = 500
+	// collapseScanLimit bounds the number of entries a collapsed search examines
+	collapseScanLimit = 10000
+	// reportCheckInterval is how often the report scheduler looks for reports that are due
+	reportCheckInterval = time.Minute
+	// volumeMeasureInterval is how often the volume stored per app, host and tenant is measured
+	volumeMeasureInterval = time.Hour
+	// retentionInterval is how often entries older than the retention period are removed
+	retentionInterval = 24 * time.Hour
+	// maxReportTopLimit bounds the number of top values a report records per run
+	maxReportTopLimit = 100
+	// maxHistogramEvents bounds the event markers returned with a histogram
+	maxHistogramEvents = 1000
+	// maxModeMessageLength bounds the notice shown to clients rejected by the read-only or
+	// maintenance mode
+	maxModeMessageLength = 1024
 )
 
+// queuedLog is a raw message waiting to be processed along with its receive metadata
+type queuedLog struct {
+	message  string
+	sourceIP string
+	// tenant is the tenant the connection was routed to, empty if none
+	tenant   string
+	received time.Time
+	// parseFailed, if set, is called when the message cannot be parsed
+	parseFailed func()
+	// done, if set, is called once the message is stored or found unparseable, with the error if
+	// storing it failed
+	done func(error)
+	// stream identifies the connection the message arrived on and seq numbers it within the
+	// stream; zero when the message is not part of a stream
+	stream int64
+	seq    uint64
+	// replayed is set for messages spooled in the degraded mode, whose entries were sent to
+	// subscribers when they were received
+	replayed bool
+}
+
 // LogService implements the central log processing service
 type LogService struct {
 	parser  interfaces.LogParser
@@ -31,16 +90,93 @@ type LogService struct {
 	batchSize    int
 	batchTimeout time.Duration
 	queueSize    int
+	parseWorkers int
+
+	// Search admission control so heavy searches can't starve the batch writer
+	searchSlots        chan struct{}
+	searchQueueTimeout time.Duration
+
+	// Recent search results, nil when caching is disabled
+	searchCache *searchCache
+
+	// Entries of the last minutes announced to subscribers, nil when the buffer is disabled
+	tailBuffer *tailBuffer
+
+	// Periodic storage integrity checking (0 disables)
+	integrityInterval  time.Duration
+	lastIntegrity      *interfaces.IntegrityReport
+	lastIntegrityMutex sync.RWMutex
+
+	// Days entries are kept before the retention run removes them (0 keeps them forever), and the
+	// configured days, which a period stored through the admin API overrides
+	retentionDays       int
+	configuredRetention int
+	retentionOverridden bool
+	retentionMutex      sync.RWMutex
+	// Wakes the retention scheduler when the retention period changes
+	retentionChanged chan struct{}
+
+	// Announces retention runs to the lifecycle webhook, nil when only the server log records them
+	lifecycle *lifecycle.Notifier
+
+	// Whether the message as received is kept with each entry
+	retainRaw bool
+
+	// Whether the ANSI color sequences of messages are kept instead of escaped
+	keepColors bool
+
+	// Encrypts the configured structured data fields before storage, nil when none are
+	encryptor *fieldcrypt.Encryptor
+
+	// Deployment metadata recorded in every entry, nil when none is configured
+	stamp map[string]string
+
+	// Limits on the structured data of incoming entries
+	sdGuard *sanitize.Guard
+
+	// Operating mode, read-only or maintenance while ingestion is paused
+	mode      types.ModeStatus
+	modeMutex sync.RWMutex
+
+	// Recently authenticated passwords and API tokens, so their hashes are not checked on every request
+	authCache      map[[32]byte]authCacheEntry
+	authCacheMutex sync.Mutex
+
+	// Most recent reprocessing job
+	reprocess      types.ReprocessStatus
+	reprocessMutex sync.RWMutex
+
+	// Most recent re-encryption job
+	reencrypt      types.ReencryptStatus
+	reencryptMutex sync.RWMutex
+
+	// Serializes the updates of user preferences, which read them before writing
+	preferencesMutex sync.Mutex
 
 	// Processing queue and batch management
-	logQueue    chan string
-	batchBuffer []string
+	logQueue    chan queuedLog
+	batchBuffer []queuedLog
 	batchMutex  sync.Mutex
 	batchTimer  *time.Timer
 
 	// Real-time subscriptions
 	subscribers    map[chan *types.LogEntry]bool
 	subscribersMux sync.RWMutex
+	// Keeps the entries of each connection in order when they are announced
+	order *streamOrder
+
+	// Approximate memory held by messages until written, and the limit holding senders back
+	memory *memoryAccount
+
+	// Outcome of recent writes, deciding when storage is down and the degraded mode entered
+	health *storageHealth
+	// Messages received in the degraded mode, nil when they are not spooled
+	spool     *degradedSpool
+	replaying atomic.Bool
+
+	// Retry and circuit breaker policies around storage reads and writes
+	reads  *resilience.Policy
+	writes *resilience.Policy
 
 	// Service lifecycle
 	ctx        context.Context
@@ -59,16 +195,28 @@ func NewLogService(parser interfaces.LogParser, storage interfaces.LogStorage) *
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &LogService{
-		parser:       parser,
-		storage:      storage,
-		batchSize:    DefaultBatchSize,
-		batchTimeout: DefaultBatchTimeout,
-		queueSize:    DefaultQueueSize,
-		logQueue:     make(chan string, DefaultQueueSize),
-		batchBuffer:  make([]string, 0, DefaultBatchSize),
-		subscribers:  make(map[chan *types.LogEntry]bool),
-		ctx:          ctx,
-		cancel:       cancel,
+		parser:             parser,
+		storage:            storage,
+		batchSize:          DefaultBatchSize,
+		batchTimeout:       DefaultBatchTimeout,
+		queueSize:          DefaultQueueSize,
+		parseWorkers:       DefaultParseWorkers,
+		searchSlots:        make(chan struct{}, DefaultMaxConcurrentSearches),
+		searchQueueTimeout: DefaultSearchQueueTimeout,
+		retainRaw:          true,
+		mode:               types.ModeStatus{Mode: types.ModeNormal},
+		retentionChanged:   make(chan struct{}, 1),
+		sdGuard:            sanitize.NewGuard(sanitize.DefaultLimits),
+		logQueue:           make(chan queuedLog, DefaultQueueSize),
+		batchBuffer:        make([]queuedLog, 0, DefaultBatchSize),
+		subscribers:        make(map[chan *types.LogEntry]bool),
+		order:              newStreamOrder(),
+		memory:             newMemoryAccount(),
+		health:             &storageHealth{degradeAfter: DefaultDegradeAfter, probeInterval: degradedProbeInterval},
+		reads:              resilience.NewPolicy("read", resilience.DefaultConfig),
+		writes:             resilience.NewPolicy("write", resilience.DefaultConfig),
+		ctx:                ctx,
+		cancel:             cancel,
 		stats: interfaces.ServiceStats{
 			IsRunning: false,
 		},
@@ -96,6 +244,126 @@ func (s *LogService) SetQueueSize(size int) {
 	}
 }
 
+// SetParseWorkers configures how many goroutines parse the messages of a batch, 0 meaning one per
+// CPU. Entries are still stored in the order their messages were received. Call it before Start.
+func (s *LogService) SetParseWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	s.parseWorkers = workers
+}
+
+// SetMemoryLimit configures the approximate memory in bytes that messages not yet written to
+// storage may hold; past it, new messages wait for memory to be released and are rejected with
+// ErrMemoryLimit if that takes too long. 0 disables the limit.
+func (s *LogService) SetMemoryLimit(bytes int64) {
+	s.memory.setLimit(bytes)
+}
+
+// SetDegradeAfter configures how long writes must keep failing before the degraded mode is entered,
+// in which entries are sent to subscribers and spooled instead of stored. 0 disables the degraded
+// mode, so every write is attempted however long storage is down.
+func (s *LogService) SetDegradeAfter(after time.Duration) {
+	if after >= 0 {
+		s.health.degradeAfter = after
+	}
+}
+
+// SetStoragePolicy configures how storage reads and writes failing transiently are retried, and
+// when the circuit breaker failing them fast opens. Call it before Start.
+func (s *LogService) SetStoragePolicy(config resilience.Config) {
+	s.reads = resilience.NewPolicy("read", config)
+	s.writes = resilience.NewPolicy("write", config)
+}
+
+// SetDegradedSpool keeps the messages received in the degraded mode in the file at path, up to
+// maxBytes (0 for no limit), and stores them once storage recovers. Messages left by an earlier
+// run are stored after Start. Call it before Start.
+func (s *LogService) SetDegradedSpool(path string, maxBytes int64) error {
+	spool, err := openDegradedSpool(path, maxBytes)
+	if err != nil {
+		return err
+	}
+	s.spool = spool
+	return nil
+}
+
+// SetSearchConcurrency configures how many searches may run at once and how long excess searches
+// wait for a free slot before failing with ErrSearchBusy (0 rejects immediately)
+func (s *LogService) SetSearchConcurrency(maxConcurrent int, queueTimeout time.Duration) {
+	if maxConcurrent > 0 {
+		s.searchSlots = make(chan struct{}, maxConcurrent)
+	}
+	if queueTimeout >= 0 {
+		s.searchQueueTimeout = queueTimeout
+	}
+}
+
+// SetSearchCache keeps the results of identical searches for ttl, up to maxEntries results (0 uses
+// the default). A ttl of 0 disables caching. Call it before Start.
+func (s *LogService) SetSearchCache(ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		s.searchCache = nil
+		return
+	}
+	s.searchCache = newSearchCache(ttl, maxEntries)
+}
+
+// SetIntegrityCheckInterval configures how often storage integrity is verified in the background
+func (s *LogService) SetIntegrityCheckInterval(interval time.Duration) {
+	if interval >= 0 {
+		s.integrityInterval = interval
+	}
+}
+
+// SetRetention configures how many days entries are kept; 0 keeps them forever. A period stored
+// through the admin API takes precedence once the service starts.
+func (s *LogService) SetRetention(days int) {
+	if days < 0 {
+		return
+	}
+	s.retentionMutex.Lock()
+	defer s.retentionMutex.Unlock()
+	s.configuredRetention = days
+	if !s.retentionOverridden {
+		s.retentionDays = days
+	}
+}
+
+// SetLifecycleNotifier announces completed retention runs to the lifecycle webhook
+func (s *LogService) SetLifecycleNotifier(notifier *lifecycle.Notifier) {
+	s.lifecycle = notifier
+}
+
+// SetRawRetention configures whether the message as received is stored alongside the parsed fields
+func (s *LogService) SetRawRetention(enabled bool) {
+	s.retainRaw = enabled
+}
+
+// SetKeepANSIColors configures whether the ANSI color and style sequences of messages are stored for
+// the web UI to render, instead of being escaped like other control characters
+func (s *LogService) SetKeepANSIColors(enabled bool) {
+	s.keepColors = enabled
+}
+
+// SetFieldEncryption encrypts the configured structured data fields of every entry received from now
+// on; nil stores them as received
+func (s *LogService) SetFieldEncryption(encryptor *fieldcrypt.Encryptor) {
+	s.encryptor = encryptor
+}
+
+// SetStamp configures deployment metadata, such as environment=prod, recorded as metadata
+// parameters of every entry received from now on
+func (s *LogService) SetStamp(stamp map[string]string) {
+	s.stamp = maps.Clone(stamp)
+}
+
+// SetStructuredDataLimits configures the size, parameter and per-application key limits of the
+// structured data of incoming entries
+func (s *LogService) SetStructuredDataLimits(limits sanitize.Limits) {
+	s.sdGuard = sanitize.NewGuard(limits)
+}
+
 // Start starts the service background processes
 func (s *LogService) Start() error {
 	s.runningMux.Lock()
@@ -109,11 +377,37 @@ func (s *LogService) Start() error {
 	s.wg.Add(1)
 	go s.batchProcessor()
 
+	// Start the periodic integrity checker if enabled and supported by storage
+	if _, ok := s.storage.(interfaces.IntegrityChecker); ok && s.integrityInterval > 0 {
+		s.wg.Add(1)
+		go s.integrityChecker()
+	}
+
+	// Start the report scheduler if storage can keep reports
+	if _, ok := s.storage.(interfaces.ReportStore); ok {
+		s.wg.Add(1)
+		go s.reportScheduler()
+	}
+
+	// Start measuring the stored volume if storage attributes it
+	if _, ok := s.storage.(interfaces.VolumeAttributor); ok {
+		s.wg.Add(1)
+		go s.volumeMeter()
+	}
+
+	// Start removing expired entries, whenever a retention period is set
+	s.loadRetention()
+	s.wg.Add(1)
+	go s.retentionScheduler()
+
 	s.isRunning = true
 	s.updateStats(func(stats *interfaces.ServiceStats) {
 		stats.IsRunning = true
 	})
 
+	// Store the messages spooled while storage was down before the last stop
+	s.startReplay()
+
 	return nil
 }
 
@@ -138,6 +432,13 @@ func (s *LogService) Stop() error {
 	// Process any remaining logs in the batch buffer
 	s.processBatch()
 
+	// Messages spooled and not replayed yet are kept for the next start
+	if s.spool != nil {
+		if err := s.spool.close(); err != nil {
+			log.Printf("Failed to close degraded spool: %v", err)
+		}
+	}
+
 	// Close all subscriber channels
 	s.subscribersMux.Lock()
 	for ch := range s.subscribers {
@@ -157,6 +458,48 @@ func (s *LogService) Stop() error {
 
 // ProcessLog processes a single raw log message
 func (s *LogService) ProcessLog(rawMessage string) error {
+	return s.enqueue(queuedLog{message: rawMessage})
+}
+
+// ProcessLogFrom processes a single raw log message and records the sender's source IP
+func (s *LogService) ProcessLogFrom(rawMessage, sourceIP string) error {
+	return s.enqueue(queuedLog{message: rawMessage, sourceIP: sourceIP})
+}
+
+// ProcessLogTracked processes a single raw log message, calling parseFailed if it cannot be parsed
+func (s *LogService) ProcessLogTracked(rawMessage, sourceIP string, parseFailed func()) error {
+	return s.enqueue(queuedLog{message: rawMessage, sourceIP: sourceIP, parseFailed: parseFailed})
+}
+
+// ProcessLogForTenant processes a single raw log message received for a tenant, calling parseFailed
+// if it cannot be parsed
+func (s *LogService) ProcessLogForTenant(rawMessage, sourceIP, tenant string, parseFailed func()) error {
+	return s.enqueue(queuedLog{message: rawMessage, sourceIP: sourceIP, tenant: tenant, parseFailed: parseFailed})
+}
+
+// ProcessLogAcked processes a single raw log message received for a tenant and calls done once it
+// is stored, so the sender can be told it need not send it again
+func (s *LogService) ProcessLogAcked(rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error {
+	return s.enqueue(queuedLog{message: rawMessage, sourceIP: sourceIP, tenant: tenant, parseFailed: parseFailed, done: done})
+}
+
+// ProcessLogInStream processes a single raw log message as the next message of a connection, whose
+// entries are stored and announced in the order their messages were handed over
+func (s *LogService) ProcessLogInStream(stream int64, rawMessage, sourceIP, tenant string, parseFailed func(), done func(error)) error {
+	item := queuedLog{message: rawMessage, sourceIP: sourceIP, tenant: tenant, parseFailed: parseFailed, done: done, stream: stream}
+	if stream != 0 {
+		item.seq = s.order.next(stream)
+	}
+	err := s.enqueue(item)
+	if err != nil && stream != 0 {
+		// The entries after it must not wait for a message that was not queued
+		s.order.complete(stream, item.seq, nil, s.notifySubscribers)
+	}
+	return err
+}
+
+// enqueue adds a message to the processing queue, applying backpressure when it is full
+func (s *LogService) enqueue(item queuedLog) error {
 	s.runningMux.RLock()
 	if !s.isRunning {
 		s.runningMux.RUnlock()
@@ -164,13 +507,36 @@ func (s *LogService) ProcessLog(rawMessage string) error {
 	}
 	s.runningMux.RUnlock()
 
+	if mode := s.Mode(); !mode.Accepting() {
+		s.updateStats(func(stats *interfaces.ServiceStats) {
+			stats.RejectedLogs++
+		})
+		return fmt.Errorf("%w: %s", interfaces.ErrReadOnly, mode.Notice())
+	}
+
+	// Senders are held back while pending messages hold the memory limit
+	release, err := s.reserveMemory(&item)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrMemoryLimit) {
+			s.updateStats(func(stats *interfaces.ServiceStats) {
+				stats.FailedLogs++
+				stats.MemoryRejectedLogs++
+			})
+			return err
+		}
+		return fmt.Errorf("service is shutting down")
+	}
+
+	item.received = time.Now()
 	select {
-	case s.logQueue <- rawMessage:
+	case s.logQueue <- item:
 		return nil
 	case <-s.ctx.Done():
+		release()
 		return fmt.Errorf("service is shutting down")
 	default:
 		// Queue is full, implement backpressure
+		release()
 		s.updateStats(func(stats *interfaces.ServiceStats) {
 			stats.FailedLogs++
 		})
@@ -178,6 +544,63 @@ func (s *LogService) ProcessLog(rawMessage string) error {
 	}
 }
 
+// reserveMemory accounts for the memory of a message, released by its done callback once the entry
+// is written or the message dropped. The returned function releases it instead when the message is
+// not queued after all.
+func (s *LogService) reserveMemory(item *queuedLog) (func(), error) {
+	size := memoryCost(*item)
+	if err := s.memory.reserve(s.ctx, size); err != nil {
+		return nil, err
+	}
+	done := item.done
+	item.done = func(err error) {
+		s.memory.release(size)
+		if done != nil {
+			done(err)
+		}
+	}
+	return func() { s.memory.release(size) }, nil
+}
+
+// Mode returns the current operating mode
+func (s *LogService) Mode() types.ModeStatus {
+	s.modeMutex.RLock()
+	defer s.modeMutex.RUnlock()
+	return s.mode
+}
+
+// SetMode switches to the normal, read-only or maintenance mode. Messages already queued are still
+// stored; new ones are rejected with ErrReadOnly until the normal mode is restored.
+func (s *LogService) SetMode(mode, message string) (types.ModeStatus, error) {
+	switch mode {
+	case types.ModeNormal, types.ModeReadOnly, types.ModeMaintenance:
+	default:
+		return types.ModeStatus{}, fmt.Errorf("%w: %q, must be %s, %s or %s", interfaces.ErrInvalidMode,
+			mode, types.ModeNormal, types.ModeReadOnly, types.ModeMaintenance)
+	}
+	if len(message) > maxModeMessageLength {
+		return types.ModeStatus{}, fmt.Errorf("%w: message exceeds %d characters", interfaces.ErrInvalidMode, maxModeMessageLength)
+	}
+
+	// The normal mode rejects nothing, so there is nothing to explain
+	if mode == types.ModeNormal {
+		message = ""
+	}
+
+	s.modeMutex.Lock()
+	defer s.modeMutex.Unlock()
+
+	previous := s.mode.Mode
+	s.mode.Message = message
+	if mode != previous {
+		now := time.Now()
+		s.mode.Mode = mode
+		s.mode.Since = &now
+		log.Printf("Switched from %s to %s mode", previous, mode)
+	}
+	return s.mode, nil
+}
+
 // ProcessLogBatch processes multiple raw log messages in a batch
 func (s *LogService) ProcessLogBatch(rawMessages []string) error {
 	for _, msg := range rawMessages {
@@ -188,14 +611,641 @@ func (s *LogService) ProcessLogBatch(rawMessages []string) error {
 	return nil
 }
 
-// Search retrieves log entries based on the provided query
+// Search retrieves log entries based on the provided query, from the search cache if enabled
 func (s *LogService) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
-	return s.storage.Search(query)
+	cache := s.searchCache
+	if cache == nil {
+		return s.search(query)
+	}
+
+	key, covered := cache.key(query)
+	if results, ok := cache.get(key, time.Now()); ok {
+		metrics.GetSearchCacheMetrics().Requests.WithLabelValues(metrics.SearchCacheHit).Inc()
+		s.updateStats(func(stats *interfaces.ServiceStats) {
+			stats.CachedSearches++
+		})
+		return results, nil
+	}
+	metrics.GetSearchCacheMetrics().Requests.WithLabelValues(metrics.SearchCacheMiss).Inc()
+
+	pending := cache.begin(query)
+	results, err := s.search(query)
+	cache.finish(pending, key, covered, results, err, time.Now())
+	return results, err
+}
+
+// search runs a search against storage once a search slot is free
+func (s *LogService) search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	if err := s.acquireSearchSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseSearchSlot()
+
+	if query.Collapse {
+		return s.collapsedSearch(query)
+	}
+	return read(s, func() ([]*types.LogEntry, error) { return s.storage.Search(query) })
+}
+
+// read runs a storage read under the read policy, retrying it while it fails transiently
+func read[T any](s *LogService, op func() (T, error)) (T, error) {
+	var result T
+	err := s.reads.Do(func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+// collapsedSearch pages through the matching entries, newest first, folding runs of identical
+// messages from the same host and application into one result, like syslog's "last message
+// repeated N times". At most collapseScanLimit entries are examined.
+func (s *LogService) collapsedSearch(query types.SearchQuery) ([]*types.LogEntry, error) {
+	wanted := query.Offset + query.Limit
+
+	page := query
+	page.Collapse = false
+	page.Offset = 0
+	page.Limit = collapsePageSize
+	if len(page.Fields) > 0 {
+		// Runs are detected on these fields, whatever the caller selected
+		page.Fields = append([]string{"timestamp", "hostname", "app_name", "message"}, query.Fields...)
+	}
+
+	var results []*types.LogEntry
+	for scanned := 0; scanned < collapseScanLimit; {
+		entries, err := read(s, func() ([]*types.LogEntry, error) { return s.storage.Search(page) })
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if n := len(results); n > 0 && sameRepeatedMessage(results[n-1], entry) {
+				last := results[n-1]
+				last.RepeatCount++
+				first := entry.Timestamp
+				last.FirstTimestamp = &first
+				continue
+			}
+			// The previous run is complete once a different message starts
+			if query.Limit > 0 && len(results) == wanted {
+				return results[min(query.Offset, len(results)):], nil
+			}
+			first, last := entry.Timestamp, entry.Timestamp
+			entry.RepeatCount = 1
+			entry.FirstTimestamp = &first
+			entry.LastTimestamp = &last
+			results = append(results, entry)
+		}
+
+		scanned += len(entries)
+		if len(entries) < page.Limit {
+			break
+		}
+		page.Offset += len(entries)
+	}
+
+	return results[min(query.Offset, len(results)):], nil
+}
+
+// sameRepeatedMessage reports whether two entries are repeats of the same message from one source
+func sameRepeatedMessage(a, b *types.LogEntry) bool {
+	return a.Message == b.Message && a.Hostname == b.Hostname && a.AppName == b.AppName
+}
+
+// Histogram returns entry counts per interval if the storage backend maintains rollups, of all
+// entries or of those matching a search
+func (s *LogService) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	provider, ok := s.storage.(interfaces.HistogramProvider)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support histograms")
+	}
+
+	if err := s.acquireSearchSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseSearchSlot()
+
+	histogram, err := read(s, func() (*types.Histogram, error) { return provider.Histogram(query) })
+	if err != nil {
+		return nil, err
+	}
+
+	// Event markers are overlaid on the histogram; with an app filter, only the events of that
+	// service are included
+	if store, ok := s.storage.(interfaces.EventStore); ok {
+		service := query.AppName
+		if query.Search != nil {
+			service = query.Search.AppName
+		}
+		events, err := store.Events(types.EventQuery{
+			StartTime: query.StartTime,
+			EndTime:   query.EndTime,
+			Service:   service,
+			Limit:     maxHistogramEvents,
+		})
+		if err != nil {
+			return nil, err
+		}
+		histogram.Events = events
+	}
+	return histogram, nil
+}
+
+// Facets returns top values and cardinalities of facet fields if the storage backend maintains rollups
+func (s *LogService) Facets(query types.FacetQuery) (*types.FacetResult, error) {
+	provider, ok := s.storage.(interfaces.FacetProvider)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support facets")
+	}
+
+	if err := s.acquireSearchSlot(); err != nil {
+		return nil, err
+	}
+	defer s.releaseSearchSlot()
+
+	return read(s, func() (*types.FacetResult, error) { return provider.Facets(query) })
+}
+
+// Fields lists known field names if the storage backend maintains a field catalog
+func (s *LogService) Fields(prefix string, limit int) ([]types.FieldInfo, error) {
+	catalog, ok := s.storage.(interfaces.FieldCatalog)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support field catalogs")
+	}
+	return read(s, func() ([]types.FieldInfo, error) { return catalog.Fields(prefix, limit) })
+}
+
+// FieldValues lists recent values of a field if the storage backend maintains a field catalog
+func (s *LogService) FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	catalog, ok := s.storage.(interfaces.FieldCatalog)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support field catalogs")
+	}
+	return read(s, func() ([]types.FieldValueInfo, error) { return catalog.FieldValues(query) })
+}
+
+// PromoteField materializes a structured data field as an indexed column if the storage backend supports it
+func (s *LogService) PromoteField(field string) (*types.PromotedField, error) {
+	promoter, ok := s.storage.(interfaces.FieldPromoter)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support field promotion")
+	}
+	return promoter.PromoteField(field)
+}
+
+// DemoteField removes the indexed column of a promoted field if the storage backend supports it
+func (s *LogService) DemoteField(field string) error {
+	promoter, ok := s.storage.(interfaces.FieldPromoter)
+	if !ok {
+		return fmt.Errorf("storage backend does not support field promotion")
+	}
+	return promoter.DemoteField(field)
+}
+
+// PromotedFields lists the promoted fields if the storage backend supports field promotion
+func (s *LogService) PromotedFields() ([]types.PromotedField, error) {
+	promoter, ok := s.storage.(interfaces.FieldPromoter)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support field promotion")
+	}
+	return promoter.PromotedFields()
+}
+
+// RawMessage returns the message of an entry as received if the storage backend retains raw messages
+func (s *LogService) RawMessage(id int64) (string, error) {
+	reader, ok := s.storage.(interfaces.RawMessageReader)
+	if !ok {
+		return "", fmt.Errorf("storage backend does not retain raw messages")
+	}
+	return read(s, func() (string, error) { return reader.RawMessage(id) })
+}
+
+// IngestLatency returns the receive and commit latencies per source
+func (s *LogService) IngestLatency() types.IngestLatency {
+	return metrics.GetIngestLatency().Snapshot()
+}
+
+// ResetIngestLatency forgets the per-source latencies
+func (s *LogService) ResetIngestLatency() {
+	metrics.GetIngestLatency().Reset()
+}
+
+// EntrySizes returns the message and structured data sizes per app
+func (s *LogService) EntrySizes() types.EntrySizes {
+	return metrics.GetEntrySizes().Snapshot()
+}
+
+// ResetEntrySizes forgets the per-app sizes
+func (s *LogService) ResetEntrySizes() {
+	metrics.GetEntrySizes().Reset()
+}
+
+// SequenceGaps returns the sequence numbering and gaps of every source that numbers its messages
+func (s *LogService) SequenceGaps() types.SequenceReport {
+	return metrics.GetSequenceTracker().Snapshot()
+}
+
+// ResetSequenceGaps forgets the sequence numbering of every source
+func (s *LogService) ResetSequenceGaps() {
+	metrics.GetSequenceTracker().Reset()
+}
+
+// StorageUsage measures the disk usage of the storage backend if it supports it
+func (s *LogService) StorageUsage() (*types.StorageUsage, error) {
+	reader, ok := s.storage.(interfaces.StorageUsageReader)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support measuring disk usage")
+	}
+	return reader.StorageUsage()
+}
+
+// Checkpoint writes the frames of the write-ahead log to the database in a mode, if the storage
+// backend keeps one
+func (s *LogService) Checkpoint(mode string) (*types.CheckpointResult, error) {
+	checkpointer, ok := s.storage.(interfaces.WALCheckpointer)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support checkpoints")
+	}
+	return checkpointer.Checkpoint(mode)
+}
+
+// MeasureVolume records what each app, host and tenant occupies in storage, if the storage backend
+// attributes volume
+func (s *LogService) MeasureVolume(now time.Time) error {
+	attributor, ok := s.storage.(interfaces.VolumeAttributor)
+	if !ok {
+		return fmt.Errorf("storage backend does not support volume attribution")
+	}
+	return attributor.MeasureVolume(now)
+}
+
+// Volume reports the volume ingested and stored per day by app, host or tenant, if the storage
+// backend attributes volume
+func (s *LogService) Volume(query types.VolumeQuery) (*types.VolumeReport, error) {
+	attributor, ok := s.storage.(interfaces.VolumeAttributor)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support volume attribution")
+	}
+	return attributor.Volume(query)
+}
+
+// RecoveryStatus reports the progress of the storage backend's startup work; backends that do none
+// are ready at once
+func (s *LogService) RecoveryStatus() types.RecoveryStatus {
+	if reporter, ok := s.storage.(interfaces.RecoveryReporter); ok {
+		return reporter.RecoveryStatus()
+	}
+	return types.RecoveryStatus{Ready: true}
+}
+
+// DeleteMatching deletes the entries a search query matches if the storage backend supports deletion
+func (s *LogService) DeleteMatching(query types.SearchQuery, dryRun bool) (int64, error) {
+	deleter, ok := s.storage.(interfaces.LogDeleter)
+	if !ok {
+		return 0, fmt.Errorf("storage backend does not support deleting entries")
+	}
+	deleted, err := deleter.DeleteMatching(query, dryRun)
+	if deleted > 0 && !dryRun {
+		s.clearSearchCache()
+		if s.tailBuffer != nil {
+			s.tailBuffer.remove(query)
+		}
+	}
+	return deleted, err
+}
+
+// EntryDetail returns an entry with its raw message and hash chain link if the storage backend can read single entries
+func (s *LogService) EntryDetail(id int64) (*types.EntryDetail, error) {
+	reader, ok := s.storage.(interfaces.EntryDetailReader)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support reading single entries")
+	}
+	return read(s, func() (*types.EntryDetail, error) { return reader.EntryDetail(id) })
+}
+
+// VerifyChain recomputes the hash chain of one partition, or of all partitions when empty
+func (s *LogService) VerifyChain(partition string) (*types.ChainReport, error) {
+	verifier, ok := s.storage.(interfaces.ChainVerifier)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support hash chains")
+	}
+	return verifier.VerifyChain(partition)
+}
+
+// CreateReport validates and saves a scheduled report if the storage backend supports it
+func (s *LogService) CreateReport(report *types.Report) error {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support reports")
+	}
+	if err := validateReport(report); err != nil {
+		return err
+	}
+	report.Firing = false
+	report.LastRunAt = nil
+	return store.CreateReport(report)
+}
+
+// UpdateReport validates and replaces the definition of a scheduled report if the storage backend
+// supports reports. The next run continues after the last window.
+func (s *LogService) UpdateReport(report *types.Report) error {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support reports")
+	}
+	if err := validateReport(report); err != nil {
+		return err
+	}
+	return store.UpdateReport(report)
+}
+
+// validateReport checks the definition of a report, trimming its name
+func validateReport(report *types.Report) error {
+	report.Name = strings.TrimSpace(report.Name)
+	if report.Name == "" {
+		return fmt.Errorf("%w: name is required", interfaces.ErrInvalidReport)
+	}
+	if report.Interval() < types.MinReportInterval {
+		return fmt.Errorf("%w: interval must be at least %d seconds", interfaces.ErrInvalidReport, int64(types.MinReportInterval/time.Second))
+	}
+	if err := querylang.Compile(report.Query, &types.SearchQuery{}); err != nil {
+		return fmt.Errorf("%w: invalid query: %v", interfaces.ErrInvalidReport, err)
+	}
+	if report.TopField != "" && !validReportTopField(report.TopField) {
+		return fmt.Errorf("%w: top field must be one of %s or a sdid.param structured data field",
+			interfaces.ErrInvalidReport, strings.Join(types.ReportTopFields, ", "))
+	}
+	if report.TopLimit < 0 || report.TopLimit > maxReportTopLimit {
+		return fmt.Errorf("%w: top limit must be between 0 and %d", interfaces.ErrInvalidReport, maxReportTopLimit)
+	}
+	if report.AlertThreshold < 0 {
+		return fmt.Errorf("%w: alert threshold must not be negative", interfaces.ErrInvalidReport)
+	}
+	return nil
+}
+
+// validReportTopField reports whether top values can be recorded for a field
+func validReportTopField(field string) bool {
+	if slices.Contains(types.ReportTopFields, field) {
+		return true
+	}
+	sdID, param, ok := strings.Cut(field, ".")
+	return ok && sdID != "" && param != ""
+}
+
+// Reports lists the scheduled reports if the storage backend supports them
+func (s *LogService) Reports() ([]types.Report, error) {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support reports")
+	}
+	return store.Reports()
+}
+
+// DeleteReport removes a scheduled report and its results if the storage backend supports them
+func (s *LogService) DeleteReport(id int64) error {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support reports")
+	}
+	return store.DeleteReport(id)
+}
+
+// ReportResults returns the stored results of a scheduled report if the storage backend supports them
+func (s *LogService) ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support reports")
+	}
+	return store.ReportResults(id, since, limit)
+}
+
+// AlertHistory returns the recorded alert state changes if the storage backend supports reports
+func (s *LogService) AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support reports")
+	}
+	return store.AlertHistory(reportID, since, limit)
+}
+
+// RecordEvent validates and saves a deploy or other event marker if the storage backend supports it
+func (s *LogService) RecordEvent(event *types.Event) error {
+	store, ok := s.storage.(interfaces.EventStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support events")
+	}
+
+	event.Type = strings.TrimSpace(event.Type)
+	if event.Type == "" {
+		event.Type = types.EventDeploy
+	}
+	event.Service = strings.TrimSpace(event.Service)
+	if event.Service == "" {
+		return fmt.Errorf("%w: service is required", interfaces.ErrInvalidEvent)
+	}
+	if len(event.Type) > types.MaxEventFieldLength || len(event.Service) > types.MaxEventFieldLength ||
+		len(event.Version) > types.MaxEventFieldLength || len(event.Description) > types.MaxEventFieldLength {
+		return fmt.Errorf("%w: fields must be at most %d bytes", interfaces.ErrInvalidEvent, types.MaxEventFieldLength)
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	return store.CreateEvent(event)
+}
+
+// Events returns the event markers matching query if the storage backend supports them
+func (s *LogService) Events(query types.EventQuery) ([]types.Event, error) {
+	store, ok := s.storage.(interfaces.EventStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support events")
+	}
+	return store.Events(query)
+}
+
+// DeleteEvent removes an event marker if the storage backend supports them
+func (s *LogService) DeleteEvent(id int64) error {
+	store, ok := s.storage.(interfaces.EventStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support events")
+	}
+	return store.DeleteEvent(id)
+}
+
+// runDueReports runs every report whose interval has elapsed since its previous run. Each run covers
+// the time after the previous run's window, so consecutive results do not overlap, or one interval
+// before now for the first run.
+func (s *LogService) runDueReports(now time.Time) {
+	store, ok := s.storage.(interfaces.ReportStore)
+	if !ok {
+		return
+	}
+	reports, err := store.Reports()
+	if err != nil {
+		log.Printf("Error listing scheduled reports: %v", err)
+		return
+	}
+
+	now = now.UTC()
+	for _, report := range reports {
+		start := now.Add(-report.Interval())
+		if report.LastRunAt != nil {
+			if now.Sub(*report.LastRunAt) < report.Interval() {
+				continue
+			}
+			start = report.LastRunAt.UTC().Add(time.Nanosecond)
+		}
+
+		var query types.SearchQuery
+		if err := querylang.Compile(report.Query, &query); err != nil {
+			log.Printf("Skipping report %q with invalid query: %v", report.Name, err)
+			continue
+		}
+		end := now
+		query.StartTime, query.EndTime = &start, &end
+
+		// Report runs share the search slots so they can't starve the batch writer either
+		if err := s.acquireSearchSlot(); err != nil {
+			log.Printf("Postponing report %q: %v", report.Name, err)
+			continue
+		}
+		result, err := store.RunReport(report, query)
+		s.releaseSearchSlot()
+		if err != nil {
+			if !errors.Is(err, interfaces.ErrReportNotFound) {
+				log.Printf("Error running report %q: %v", report.Name, err)
+			}
+			continue
+		}
+		if result.Alert != nil {
+			log.Printf("Alert %q %s: %d entries matched, threshold %d", report.Name, result.Alert.State, result.Alert.Count, result.Alert.Threshold)
+		}
+	}
+}
+
+// StartReprocess starts re-parsing the stored raw messages selected by query with the current parser
+// in the background. Only one job runs at a time.
+func (s *LogService) StartReprocess(query types.ReprocessQuery) (*types.ReprocessStatus, error) {
+	reprocessor, ok := s.storage.(interfaces.Reprocessor)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support reprocessing")
+	}
+
+	s.reprocessMutex.Lock()
+	defer s.reprocessMutex.Unlock()
+
+	if s.reprocess.Running {
+		return nil, interfaces.ErrReprocessRunning
+	}
+
+	startedAt := time.Now()
+	s.reprocess = types.ReprocessStatus{Running: true, Query: query, StartedAt: &startedAt}
+
+	s.wg.Add(1)
+	go s.runReprocess(reprocessor, query)
+
+	status := s.reprocess
+	return &status, nil
+}
+
+// ReprocessStatus reports the progress of the most recent reprocessing job
+func (s *LogService) ReprocessStatus() types.ReprocessStatus {
+	s.reprocessMutex.RLock()
+	defer s.reprocessMutex.RUnlock()
+	return s.reprocess
+}
+
+// runReprocess re-parses batch after batch until every selected entry was seen or the service stops
+func (s *LogService) runReprocess(reprocessor interfaces.Reprocessor, query types.ReprocessQuery) {
+	defer s.wg.Done()
+
+	var afterID int64
+	var jobErr error
+	for {
+		if s.ctx.Err() != nil {
+			jobErr = fmt.Errorf("reprocessing interrupted by shutdown")
+			break
+		}
+
+		batch, err := reprocessor.ReprocessBatch(query, afterID, reprocessBatchSize, s.parser.Parse)
+		if err != nil {
+			jobErr = err
+			break
+		}
+
+		if batch.Updated > 0 {
+			s.clearSearchCache()
+			s.clearTailBuffer()
+		}
+
+		s.reprocessMutex.Lock()
+		s.reprocess.Processed += batch.Processed
+		s.reprocess.Updated += batch.Updated
+		s.reprocess.Failed += batch.Failed
+		s.reprocessMutex.Unlock()
+
+		if batch.Done {
+			break
+		}
+		afterID = batch.LastID
+	}
+
+	if jobErr != nil {
+		log.Printf("Reprocessing stopped: %v", jobErr)
+	}
+
+	finishedAt := time.Now()
+	s.reprocessMutex.Lock()
+	s.reprocess.Running = false
+	s.reprocess.FinishedAt = &finishedAt
+	if jobErr != nil {
+		s.reprocess.Error = jobErr.Error()
+	}
+	s.reprocessMutex.Unlock()
+}
+
+// acquireSearchSlot waits for a free search slot, giving up after the queue timeout
+func (s *LogService) acquireSearchSlot() error {
+	select {
+	case s.searchSlots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if s.searchQueueTimeout <= 0 {
+		s.recordRejectedSearch()
+		return interfaces.ErrSearchBusy
+	}
+
+	timer := time.NewTimer(s.searchQueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.searchSlots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		s.recordRejectedSearch()
+		return interfaces.ErrSearchBusy
+	}
+}
+
+// releaseSearchSlot frees a slot taken by acquireSearchSlot
+func (s *LogService) releaseSearchSlot() {
+	<-s.searchSlots
+}
+
+// recordRejectedSearch counts a search turned away by the concurrency limiter
+func (s *LogService) recordRejectedSearch() {
+	s.updateStats(func(stats *interfaces.ServiceStats) {
+		stats.RejectedSearches++
+	})
 }
 
 // GetRecent retrieves the most recent log entries
 func (s *LogService) GetRecent(limit int) ([]*types.LogEntry, error) {
-	return s.storage.GetRecent(limit)
+	return read(s, func() ([]*types.LogEntry, error) { return s.storage.GetRecent(limit) })
 }
 
 // Subscribe creates a subscription for real-time log updates
@@ -240,6 +1290,32 @@ func (s *LogService) Unsubscribe(subscription <-chan *types.LogEntry) {
 	}
 }
 
+// CheckIntegrity runs a storage integrity check if the storage backend supports it
+func (s *LogService) CheckIntegrity(quick bool) (*interfaces.IntegrityReport, error) {
+	checker, ok := s.storage.(interfaces.IntegrityChecker)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support integrity checks")
+	}
+
+	report, err := checker.CheckIntegrity(quick)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lastIntegrityMutex.Lock()
+	s.lastIntegrity = report
+	s.lastIntegrityMutex.Unlock()
+
+	return report, nil
+}
+
+// LastIntegrityReport returns the result of the most recent integrity check, or nil if none has run
+func (s *LogService) LastIntegrityReport() *interfaces.IntegrityReport {
+	s.lastIntegrityMutex.RLock()
+	defer s.lastIntegrityMutex.RUnlock()
+	return s.lastIntegrity
+}
+
 // GetStats returns service statistics
 func (s *LogService) GetStats() interfaces.ServiceStats {
 	s.statsMutex.RLock()
@@ -247,6 +1323,8 @@ func (s *LogService) GetStats() interfaces.ServiceStats {
 
 	stats := s.stats
 	stats.QueueSize = len(s.logQueue)
+	stats.PendingMemoryBytes, stats.MemoryLimitBytes = s.memory.pending()
+	stats.SubscriberMemoryBytes = s.subscriberMemory()
 
 	return stats
 }
@@ -260,14 +1338,14 @@ func (s *LogService) batchProcessor() {
 
 	for {
 		select {
-		case rawMessage, ok := <-s.logQueue:
+		case item, ok := <-s.logQueue:
 			if !ok {
 				// Channel closed, process remaining batch and exit
 				return
 			}
 
 			s.batchMutex.Lock()
-			s.batchBuffer = append(s.batchBuffer, rawMessage)
+			s.batchBuffer = append(s.batchBuffer, item)
 
 			// Process batch if it's full
 			if len(s.batchBuffer) >= s.batchSize {
@@ -293,20 +1371,152 @@ func (s *LogService) batchProcessor() {
 	}
 }
 
+// integrityChecker runs in a separate goroutine and periodically verifies storage integrity
+func (s *LogService) integrityChecker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.integrityInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := s.CheckIntegrity(true)
+			if err != nil {
+				log.Printf("ALERT: storage integrity check could not run: %v", err)
+				continue
+			}
+			if !report.OK {
+				log.Printf("ALERT: storage integrity check failed with %d problem(s): %v", len(report.Problems), report.Problems)
+			}
+
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// reportScheduler runs in a separate goroutine and periodically runs the scheduled reports that are due
+func (s *LogService) reportScheduler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(reportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.runDueReports(now)
+
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// volumeMeter runs in a separate goroutine and measures what each app, host and tenant occupies in
+// storage at start and then periodically, so the volume report follows it over time
+func (s *LogService) volumeMeter() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(volumeMeasureInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		if err := s.MeasureVolume(now); err != nil {
+			log.Printf("Error measuring stored volume: %v", err)
+		}
+
+		select {
+		case now = <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// retentionScheduler runs in a separate goroutine and removes the entries older than the retention
+// period at start, then daily and whenever the period changes. Nothing is removed while entries are
+// kept forever.
+func (s *LogService) retentionScheduler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.Retention().Days > 0 {
+			if err := s.RunRetention(); err != nil {
+				log.Printf("Error removing expired entries: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.retentionChanged:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// RunRetention removes the entries older than the retention period and announces the run as a
+// lifecycle event
+func (s *LogService) RunRetention() error {
+	days := s.Retention().Days
+	if days <= 0 {
+		return fmt.Errorf("no retention period is configured")
+	}
+
+	started := time.Now()
+	cutoff := started.AddDate(0, 0, -days)
+	var removed int64
+	var err error
+	cleaner, counts := s.storage.(interfaces.RetentionCleaner)
+	if counts {
+		removed, err = cleaner.CleanupExpired(days)
+	} else {
+		err = s.storage.Cleanup(days)
+	}
+	if err != nil {
+		return err
+	}
+	// Cached results may hold the removed entries; without a count, any of them may have been removed
+	if removed > 0 || !counts {
+		s.clearSearchCache()
+	}
+
+	s.lifecycle.Emit(lifecycle.Event{
+		Type:    lifecycle.RetentionCompleted,
+		Entries: removed,
+		Details: map[string]string{
+			"retention_days": strconv.Itoa(days),
+			"cutoff":         cutoff.UTC().Format(time.RFC3339),
+			"duration_ms":    strconv.FormatInt(time.Since(started).Milliseconds(), 10),
+		},
+	})
+	return nil
+}
+
 // processBatch processes the current batch of log messages
 func (s *LogService) processBatch() {
 	if len(s.batchBuffer) == 0 {
 		return
 	}
 
-	batch := make([]string, len(s.batchBuffer))
+	batch := make([]queuedLog, len(s.batchBuffer))
 	copy(batch, s.batchBuffer)
 	s.batchBuffer = s.batchBuffer[:0] // Clear the buffer
-	//fmt.Println("processing batch size: ", len(batch))
-	// Process each log in the batch
-	for _, rawMessage := range batch {
-		if err := s.processLogMessage(rawMessage); err != nil {
-			log.Printf("Error processing log message: %v", err)
+
+	// Messages are parsed concurrently but stored in the order they were received
+	parsed := s.parseBatch(batch)
+	for i, item := range batch {
+		if err := s.storeLogEntry(item, parsed[i].entry, parsed[i].err); err != nil {
+			// The breaker logged once when it opened, rather than once per message failed fast
+			if !errors.Is(err, interfaces.ErrStorageBusy) {
+				log.Printf("Error processing log message: %v", err)
+			}
 			s.updateStats(func(stats *interfaces.ServiceStats) {
 				stats.FailedLogs++
 			})
@@ -316,33 +1526,300 @@ func (s *LogService) processBatch() {
 			})
 		}
 	}
-	//fmt.Println("processing batch done")
+}
+
+// parsedLog is the entry parsed from a queued message, or the error parsing it
+type parsedLog struct {
+	entry *types.LogEntry
+	err   error
+}
+
+// parseBatch parses the messages of a batch on up to parseWorkers goroutines, returning the results
+// in the order of the batch
+func (s *LogService) parseBatch(batch []queuedLog) []parsedLog {
+	parsed := make([]parsedLog, len(batch))
+	workers := min(s.parseWorkers, len(batch))
+	if workers <= 1 {
+		for i, item := range batch {
+			parsed[i].entry, parsed[i].err = s.parseLogMessage(item)
+		}
+		return parsed
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(batch) {
+					return
+				}
+				parsed[i].entry, parsed[i].err = s.parseLogMessage(batch[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return parsed
 }
 
 // processLogMessage processes a single log message
-func (s *LogService) processLogMessage(rawMessage string) error {
-	// Parse the log message
-	logEntry, err := s.parser.Parse(rawMessage)
+func (s *LogService) processLogMessage(item queuedLog) error {
+	entry, err := s.parseLogMessage(item)
+	return s.storeLogEntry(item, entry, err)
+}
+
+// parseLogMessage parses and sanitizes a message. It does not depend on the messages before it, so
+// parse workers run it concurrently.
+func (s *LogService) parseLogMessage(item queuedLog) (*types.LogEntry, error) {
+	// Parse the log message; parsers may read it differently depending on the sender
+	var logEntry *types.LogEntry
+	var err error
+	if parser, ok := s.parser.(interfaces.SourceParser); ok {
+		logEntry, err = parser.ParseFrom(item.message, item.sourceIP, item.tenant)
+	} else {
+		logEntry, err = s.parser.Parse(item.message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Invalid UTF-8 and control characters would break JSON encoding and the web UI
+	if changes := sanitize.Entry(logEntry, s.keepColors); changes.Any() {
+		metrics.GetSanitizeMetrics().Observe(changes.InvalidUTF8, changes.ControlChars, changes.Normalized)
+		s.updateStats(func(stats *interfaces.ServiceStats) {
+			stats.SanitizedLogs++
+		})
+	}
+	return logEntry, nil
+}
+
+// storeLogEntry records and stores the entry parsed from a message, or reports that it could not be
+// parsed. Entries are stored in the order messages were received, as the sequence gap tracking and
+// the structured data limits depend on what came before.
+func (s *LogService) storeLogEntry(item queuedLog, logEntry *types.LogEntry, err error) error {
 	if err != nil {
+		if !item.replayed {
+			rejected := &types.LogEntry{Timestamp: item.received, Message: item.message}
+			metrics.GetFallbackTracker().Observe(metrics.FallbackSource{Tenant: item.tenant, SourceIP: item.sourceIP}, rejected, true)
+		}
+		s.announce(item, nil)
+		if item.parseFailed != nil {
+			item.parseFailed()
+		}
+		// Sending an unparseable message again would not help
+		if item.done != nil {
+			item.done(nil)
+		}
 		return fmt.Errorf("failed to parse log message: %w", err)
 	}
 
+	// Senders putting unbounded values into parameter names would flood the field catalog and indexes
+	if changes := s.sdGuard.Apply(logEntry); changes.Any() {
+		metrics.GetLimitMetrics().Observe(changes.Truncated, changes.Bucketed)
+		s.updateStats(func(stats *interfaces.ServiceStats) {
+			stats.LimitedLogs++
+		})
+	}
+
+	// The receive time lets storage report how late entries arrive and how long they wait, and the
+	// size what each sender's volume costs
+	logEntry.ReceivedAt = item.received
+	logEntry.ReceivedBytes = len(item.message)
+
+	// Sizes per app point out senders of oversized entries, and the share of unparsed messages per
+	// sender one that changed its format; replayed entries were counted when received
+	if !item.replayed {
+		metrics.GetEntrySizes().Record(logEntry, item.received)
+		metrics.GetFallbackTracker().Observe(metrics.FallbackSource{Tenant: item.tenant, SourceIP: item.sourceIP}, logEntry, logEntry.Fallback)
+	}
+
+	// Keep the message as received so it can be re-parsed after a format change
+	if s.retainRaw {
+		logEntry.Raw = item.message
+	}
+
+	// Record where the message actually came from, since senders often omit or misreport the
+	// hostname; an address the sender claims itself is never kept
+	if item.sourceIP != "" {
+		logEntry.SetMetadata(types.SourceIPParam, item.sourceIP)
+	} else {
+		logEntry.ClearMetadata(types.SourceIPParam)
+	}
+
+	// Only the receiver decides which tenant an entry belongs to
+	if item.tenant != "" {
+		logEntry.SetMetadata(types.TenantParam, item.tenant)
+	} else {
+		logEntry.ClearMetadata(types.TenantParam)
+	}
+
+	// Record which deployment received the entry, replacing what a sender claims under the same names
+	for name, value := range s.stamp {
+		logEntry.SetMetadata(name, value)
+	}
+
+	// Counts are extrapolated by the sample rate, so one that is not a valid rate is dropped
+	if rate := logEntry.Metadata(types.SampleRateParam); rate != "" && rate != strconv.FormatInt(logEntry.SampleRate(), 10) {
+		logEntry.ClearMetadata(types.SampleRateParam)
+	}
+
+	// Flag the entry following messages lost on the way, going by the sender's sequence numbers
+	logEntry.ClearMetadata(types.SequenceGapParam)
+	if sequence, ok := sequenceID(logEntry); ok {
+		tracker := metrics.GetSequenceTracker()
+		if skipped := tracker.Observe(item.tenant, logEntry.Hostname, logEntry.AppName, sequence, item.received); skipped > 0 {
+			logEntry.SetMetadata(types.SequenceGapParam, strconv.FormatInt(skipped, 10))
+		}
+	}
+
+	// Sensitive fields are encrypted before the entry goes anywhere, the spool included; the raw
+	// message would keep them readable
+	if s.encryptor != nil && s.encryptor.Seal(logEntry) {
+		logEntry.Raw = ""
+	}
+
+	// While storage is down, entries are sent to subscribers and spooled rather than failing one
+	// write after another; a write is attempted now and then to find out when storage recovered
+	if !s.health.attempt(time.Now()) {
+		s.storeDegraded(item, logEntry)
+		return nil
+	}
+
+	// An entry with an idempotency key may have been sent before, so it is announced only once
+	// stored. A duplicate counts as stored for the sender, which then stops sending it again.
+	if logEntry.Metadata(types.IdempotencyKeyParam) != "" {
+		stored := item.done
+		done := func(err error) {
+			if errors.Is(err, interfaces.ErrDuplicateEntry) {
+				s.updateStats(func(stats *interfaces.ServiceStats) {
+					stats.DuplicateLogs++
+				})
+				s.announce(item, nil)
+				err = nil
+			} else if err == nil {
+				s.announce(item, logEntry)
+			} else {
+				s.announce(item, nil)
+			}
+			if stored != nil {
+				stored(err)
+			}
+		}
+		if err := s.store(logEntry, done); err != nil && !errors.Is(err, interfaces.ErrDuplicateEntry) {
+			return fmt.Errorf("failed to store log entry: %w", err)
+		}
+		return nil
+	}
+
 	// Store the log entry
-	if err := s.storage.Store(logEntry); err != nil {
+	if err := s.store(logEntry, item.done); err != nil {
+		s.announce(item, nil)
 		return fmt.Errorf("failed to store log entry: %w", err)
 	}
 
 	// Notify subscribers
-	s.notifySubscribers(logEntry)
+	s.announce(item, logEntry)
 
 	return nil
 }
 
+// announce sends an entry to subscribers once the entries of the earlier messages of its stream
+// have been sent. entry is nil when the message has nothing to announce, which lets the ones after
+// it go.
+func (s *LogService) announce(item queuedLog, entry *types.LogEntry) {
+	if item.replayed {
+		return
+	}
+	if item.stream == 0 {
+		if entry != nil {
+			s.notifySubscribers(entry)
+		}
+		return
+	}
+	s.order.complete(item.stream, item.seq, entry, s.notifySubscribers)
+}
+
+// store saves an entry, calling done, if set, once it is written
+func (s *LogService) store(entry *types.LogEntry, done func(error)) error {
+	// Writes failing for a while switch to the degraded mode
+	written := done
+	done = func(err error) {
+		s.recordWrite(err)
+		if written != nil {
+			written(err)
+		}
+	}
+
+	// Cached results covering the entry are dropped once it is written, when searches see it
+	if s.searchCache != nil {
+		timestamp, stored := entry.Timestamp, done
+		done = func(err error) {
+			s.invalidateSearchCache(timestamp)
+			if stored != nil {
+				stored(err)
+			}
+		}
+	}
+
+	// Writes failing transiently are retried, and fail fast while the breaker is open
+	if notifier, ok := s.storage.(interfaces.CommitNotifier); ok {
+		committed := done
+		done = func(err error) {
+			s.writes.Record(err)
+			committed(err)
+		}
+		if err := s.writes.Submit(func() error { return notifier.StoreNotify(entry, done) }); err != nil {
+			committed(err)
+			return err
+		}
+		return nil
+	}
+	err := s.writes.Do(func() error { return s.storage.Store(entry) })
+	done(err)
+	return err
+}
+
+// invalidateSearchCache drops the cached search results covering the timestamp of a written entry
+func (s *LogService) invalidateSearchCache(timestamp time.Time) {
+	if dropped := s.searchCache.invalidate(timestamp); dropped > 0 {
+		metrics.GetSearchCacheMetrics().Invalidations.Add(float64(dropped))
+	}
+}
+
+// clearSearchCache drops every cached search result, after entries were deleted or rewritten
+func (s *LogService) clearSearchCache() {
+	if s.searchCache != nil {
+		s.searchCache.clear()
+	}
+}
+
+// sequenceID returns the RFC5424 sequence number a sender gave an entry, if valid
+func sequenceID(entry *types.LogEntry) (int64, bool) {
+	var value string
+	switch params := entry.StructuredData[types.MetaSDID].(type) {
+	case map[string]string:
+		value = params[types.SequenceIDParam]
+	case map[string]interface{}:
+		value, _ = params[types.SequenceIDParam].(string)
+	}
+	sequence, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || sequence < 1 || sequence > metrics.MaxSequenceID {
+		return 0, false
+	}
+	return sequence, true
+}
+
 // notifySubscribers sends the log entry to all active subscribers
 func (s *LogService) notifySubscribers(logEntry *types.LogEntry) {
+	s.bufferEntry(logEntry)
+
 	s.subscribersMux.RLock()
 	defer s.subscribersMux.RUnlock()
 
+	buffered := 0
 	for ch := range s.subscribers {
 		select {
 		case ch <- logEntry:
@@ -351,7 +1828,21 @@ func (s *LogService) notifySubscribers(logEntry *types.LogEntry) {
 			// Channel is full, skip this subscriber to prevent blocking
 			log.Printf("Subscriber channel is full, skipping notification")
 		}
+		buffered += len(ch)
+	}
+	metrics.GetMemoryMetrics().Held.WithLabelValues(metrics.MemorySubscribers).Set(float64(int64(buffered) * s.memory.averageCost()))
+}
+
+// subscriberMemory approximates the memory held by the entries buffered for subscribers, which
+// are written already and so no longer count as pending
+func (s *LogService) subscriberMemory() int64 {
+	s.subscribersMux.RLock()
+	buffered := 0
+	for ch := range s.subscribers {
+		buffered += len(ch)
 	}
+	s.subscribersMux.RUnlock()
+	return int64(buffered) * s.memory.averageCost()
 }
 
 // resetBatchTimer resets the batch processing timer
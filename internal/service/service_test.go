@@ -1,11 +1,25 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"opentrail/internal/fieldcrypt"
+	"opentrail/internal/interfaces"
+	"opentrail/internal/resilience"
+	"opentrail/internal/sanitize"
 	"opentrail/internal/types"
 )
 
@@ -308,7 +322,7 @@ func TestLogService_Backpressure(t *testing.T) {
 	
 	// Set very small queue size to test backpressure
 	service.SetQueueSize(2)
-	service.logQueue = make(chan string, 2)
+	service.logQueue = make(chan queuedLog, 2)
 	
 	err := service.Start()
 	if err != nil {
@@ -697,4 +711,2362 @@ func TestLogService_Configuration(t *testing.T) {
 	if service.queueSize != 5000 {
 		t.Error("Queue size should not change for invalid value")
 	}
-}
\ No newline at end of file
+}
+
+// MockIntegrityStorage extends MockStorage with integrity checking support
+type MockIntegrityStorage struct {
+	MockStorage
+	report *interfaces.IntegrityReport
+	calls  int
+	mutex  sync.Mutex
+}
+
+func (m *MockIntegrityStorage) CheckIntegrity(quick bool) (*interfaces.IntegrityReport, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls++
+	return m.report, nil
+}
+
+func (m *MockIntegrityStorage) Calls() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.calls
+}
+
+func TestLogService_CheckIntegrity(t *testing.T) {
+	// Storage without integrity support
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.CheckIntegrity(true); err == nil {
+		t.Error("Expected error when storage does not support integrity checks")
+	}
+
+	// Storage with integrity support
+	storage := &MockIntegrityStorage{
+		report: &interfaces.IntegrityReport{OK: false, Mode: "quick", Problems: []string{"page 3 is never used"}},
+	}
+	service = NewLogService(&MockParser{}, storage)
+
+	if service.LastIntegrityReport() != nil {
+		t.Error("Expected no integrity report before any check")
+	}
+
+	report, err := service.CheckIntegrity(true)
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if report.OK {
+		t.Error("Expected report to indicate problems")
+	}
+	if service.LastIntegrityReport() != report {
+		t.Error("Expected last integrity report to be recorded")
+	}
+}
+
+func TestLogService_PeriodicIntegrityCheck(t *testing.T) {
+	storage := &MockIntegrityStorage{
+		report: &interfaces.IntegrityReport{OK: true, Mode: "quick"},
+	}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetIntegrityCheckInterval(20 * time.Millisecond)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := service.Stop(); err != nil {
+		t.Fatalf("Failed to stop service: %v", err)
+	}
+
+	if storage.Calls() == 0 {
+		t.Error("Expected periodic integrity check to run")
+	}
+}
+
+func TestLogService_ProcessLogFrom(t *testing.T) {
+	parser := &MockParser{}
+	storage := &MockStorage{}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	before := time.Now()
+	if err := service.ProcessLogFrom("test message", "2001:db8::1"); err != nil {
+		t.Fatalf("Failed to process log: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	storedLogs := storage.GetStoredLogs()
+	if len(storedLogs) != 1 {
+		t.Fatalf("Expected 1 stored log, got %d", len(storedLogs))
+	}
+
+	metadata, ok := storedLogs[0].StructuredData[types.MetadataSDID].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected %s structured data element, got %v", types.MetadataSDID, storedLogs[0].StructuredData)
+	}
+	if metadata[types.SourceIPParam] != "2001:db8::1" {
+		t.Errorf("Expected source IP 2001:db8::1, got %v", metadata[types.SourceIPParam])
+	}
+	if receivedAt := storedLogs[0].ReceivedAt; receivedAt.Before(before) || receivedAt.After(time.Now()) {
+		t.Errorf("Expected the receive time to be recorded, got %v", receivedAt)
+	}
+	if size := storedLogs[0].ReceivedBytes; size != len("test message") {
+		t.Errorf("Expected the received size %d, got %d", len("test message"), size)
+	}
+}
+
+func TestLogService_SampleRate(t *testing.T) {
+	parser := &MockParser{parseFunc: func(rawMessage string) (*types.LogEntry, error) {
+		return &types.LogEntry{
+			Message:        rawMessage,
+			Timestamp:      time.Now(),
+			StructuredData: map[string]interface{}{types.MetadataSDID: map[string]string{types.SampleRateParam: rawMessage}},
+		}, nil
+	}}
+	storage := &MockStorage{}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	rates := map[string]int64{"10": 10, "0": 1, "-5": 1, "1.5": 1, "2000000": 1}
+	for rate := range rates {
+		if err := service.ProcessLog(rate); err != nil {
+			t.Fatalf("Failed to process log: %v", err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	storedLogs := storage.GetStoredLogs()
+	if len(storedLogs) != len(rates) {
+		t.Fatalf("Expected %d stored logs, got %d", len(rates), len(storedLogs))
+	}
+	for _, entry := range storedLogs {
+		if rate := entry.SampleRate(); rate != rates[entry.Message] {
+			t.Errorf("Expected sample rate %q to count as %d, got %d", entry.Message, rates[entry.Message], rate)
+		}
+		// Invalid rates are dropped rather than stored
+		if recorded := entry.Metadata(types.SampleRateParam); recorded != "" && rates[entry.Message] == 1 {
+			t.Errorf("Expected the invalid sample rate %q to be dropped", recorded)
+		}
+	}
+}
+
+func TestLogService_ProcessLogForTenant(t *testing.T) {
+	parser := &MockParser{parseFunc: func(rawMessage string) (*types.LogEntry, error) {
+		// Senders must not be able to pick a tenant or source address themselves
+		return &types.LogEntry{
+			Message:   rawMessage,
+			Timestamp: time.Now(),
+			StructuredData: map[string]interface{}{types.MetadataSDID: map[string]string{
+				types.TenantParam:   "spoofed",
+				types.SourceIPParam: "203.0.113.66",
+			}},
+		}, nil
+	}}
+	storage := &MockStorage{}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	if err := service.ProcessLogForTenant("routed", "192.0.2.1", "tenant-a", nil); err != nil {
+		t.Fatalf("Failed to process log: %v", err)
+	}
+	if err := service.ProcessLogTracked("plain", "", nil); err != nil {
+		t.Fatalf("Failed to process log: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	storedLogs := storage.GetStoredLogs()
+	if len(storedLogs) != 2 {
+		t.Fatalf("Expected 2 stored logs, got %d", len(storedLogs))
+	}
+	for _, entry := range storedLogs {
+		metadata, _ := entry.StructuredData[types.MetadataSDID].(map[string]interface{})
+		switch entry.Message {
+		case "routed":
+			if metadata[types.TenantParam] != "tenant-a" || metadata[types.SourceIPParam] != "192.0.2.1" {
+				t.Errorf("Expected tenant-a from 192.0.2.1, got %v", entry.StructuredData)
+			}
+		case "plain":
+			if _, ok := entry.StructuredData[types.MetadataSDID]; ok {
+				t.Errorf("Expected the sender-supplied tenant and source IP to be dropped, got %v", entry.StructuredData)
+			}
+		}
+	}
+}
+
+func TestLogService_ProcessLogAcked(t *testing.T) {
+	parser := &MockParser{parseFunc: func(rawMessage string) (*types.LogEntry, error) {
+		if rawMessage == "garbage" {
+			return nil, fmt.Errorf("invalid format")
+		}
+		return &types.LogEntry{Message: rawMessage, Timestamp: time.Now()}, nil
+	}}
+	storage := &MockStorage{storeFunc: func(entry *types.LogEntry) error {
+		if entry.Message == "unstorable" {
+			return fmt.Errorf("disk full")
+		}
+		return nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	results := make(map[string]chan error)
+	for _, message := range []string{"stored", "garbage", "unstorable"} {
+		done := make(chan error, 1)
+		results[message] = done
+		if err := service.ProcessLogAcked(message, "", "", nil, func(err error) { done <- err }); err != nil {
+			t.Fatalf("Failed to process log: %v", err)
+		}
+	}
+
+	for message, wantErr := range map[string]bool{"stored": false, "garbage": false, "unstorable": true} {
+		select {
+		case err := <-results[message]:
+			if (err != nil) != wantErr {
+				t.Errorf("Expected %q to be done with error %v, got %v", message, wantErr, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Expected %q to be done", message)
+		}
+	}
+}
+
+func TestLogService_SequenceGaps(t *testing.T) {
+	parser := &MockParser{parseFunc: func(rawMessage string) (*types.LogEntry, error) {
+		entry := &types.LogEntry{Hostname: "web01", AppName: "nginx", Message: rawMessage, Timestamp: time.Now()}
+		if rawMessage != "unnumbered" {
+			entry.StructuredData = map[string]interface{}{
+				types.MetaSDID: map[string]string{types.SequenceIDParam: rawMessage},
+				// Only the receiver flags gaps
+				types.MetadataSDID: map[string]string{types.SequenceGapParam: "99"},
+			}
+		}
+		return entry, nil
+	}}
+	storage := &MockStorage{}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+	service.ResetSequenceGaps()
+	defer service.ResetSequenceGaps()
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	for _, message := range []string{"1", "2", "5", "unnumbered", "6"} {
+		if err := service.ProcessLog(message); err != nil {
+			t.Fatalf("Failed to process log: %v", err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	storedLogs := storage.GetStoredLogs()
+	if len(storedLogs) != 5 {
+		t.Fatalf("Expected 5 stored logs, got %d", len(storedLogs))
+	}
+	for _, entry := range storedLogs {
+		metadata, _ := entry.StructuredData[types.MetadataSDID].(map[string]interface{})
+		want := ""
+		if entry.Message == "5" {
+			want = "2"
+		}
+		if gap, _ := metadata[types.SequenceGapParam].(string); gap != want {
+			t.Errorf("Expected entry %s to have sequence gap %q, got %v", entry.Message, want, entry.StructuredData)
+		}
+	}
+
+	report := service.SequenceGaps()
+	if len(report.Sources) != 1 || report.Sources[0].Missing != 2 || report.Sources[0].Entries != 4 {
+		t.Fatalf("Expected one source missing 2 entries, got %+v", report.Sources)
+	}
+	if gaps := report.Sources[0].Gaps; len(gaps) != 1 || gaps[0].First != 3 || gaps[0].Last != 4 {
+		t.Errorf("Expected a gap from 3 to 4, got %+v", gaps)
+	}
+}
+
+func TestLogService_ProcessLogTracked(t *testing.T) {
+	parser := &MockParser{parseFunc: func(rawMessage string) (*types.LogEntry, error) {
+		if rawMessage == "garbage" {
+			return nil, fmt.Errorf("invalid format")
+		}
+		return &types.LogEntry{Message: rawMessage, Timestamp: time.Now()}, nil
+	}}
+	storage := &MockStorage{}
+	service := NewLogService(parser, storage)
+	service.SetBatchSize(1)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	var failures atomic.Int64
+	for _, message := range []string{"valid", "garbage", "garbage"} {
+		if err := service.ProcessLogTracked(message, "", func() { failures.Add(1) }); err != nil {
+			t.Fatalf("Failed to process log: %v", err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if len(storage.GetStoredLogs()) != 1 || failures.Load() != 2 {
+		t.Errorf("Expected 1 stored log and 2 parse failures, got %d and %d", len(storage.GetStoredLogs()), failures.Load())
+	}
+}
+
+// BlockingSearchStorage blocks searches until released
+type BlockingSearchStorage struct {
+	MockStorage
+	release chan struct{}
+}
+
+func (m *BlockingSearchStorage) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
+	<-m.release
+	return nil, nil
+}
+
+func TestLogService_SearchConcurrencyLimit(t *testing.T) {
+	storage := &BlockingSearchStorage{release: make(chan struct{})}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetSearchConcurrency(1, 50*time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := service.Search(types.SearchQuery{})
+		done <- err
+	}()
+
+	// Wait for the first search to occupy the only slot
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := service.Search(types.SearchQuery{}); err != interfaces.ErrSearchBusy {
+		t.Errorf("Expected ErrSearchBusy, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Expected search to queue for the timeout, waited %v", waited)
+	}
+	if stats := service.GetStats(); stats.RejectedSearches != 1 {
+		t.Errorf("Expected 1 rejected search, got %d", stats.RejectedSearches)
+	}
+
+	close(storage.release)
+	if err := <-done; err != nil {
+		t.Errorf("First search failed: %v", err)
+	}
+
+	// The slot is released once the search completes
+	if _, err := service.Search(types.SearchQuery{}); err != nil {
+		t.Errorf("Expected search to be admitted after release, got %v", err)
+	}
+}
+
+// MockHistogramStorage is a MockStorage that also serves histograms, facets and the field catalog
+type MockHistogramStorage struct {
+	MockStorage
+	queries []types.HistogramQuery
+}
+
+func (m *MockHistogramStorage) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	m.queries = append(m.queries, query)
+	return &types.Histogram{Total: 42}, nil
+}
+
+func TestLogService_Histogram(t *testing.T) {
+	// Storage without rollups
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.Histogram(types.HistogramQuery{}); err == nil {
+		t.Error("Expected error when storage does not support histograms")
+	}
+
+	storage := &MockHistogramStorage{}
+	service = NewLogService(&MockParser{}, storage)
+	histogram, err := service.Histogram(types.HistogramQuery{GroupBy: types.GroupBySeverity})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if histogram.Total != 42 {
+		t.Errorf("Expected total 42, got %d", histogram.Total)
+	}
+	if len(storage.queries) != 1 || storage.queries[0].GroupBy != types.GroupBySeverity {
+		t.Errorf("Expected query to be passed to storage, got %+v", storage.queries)
+	}
+}
+
+func (m *MockHistogramStorage) Facets(query types.FacetQuery) (*types.FacetResult, error) {
+	return &types.FacetResult{Facets: []types.Facet{{Field: types.FacetAppName, Cardinality: 7}}}, nil
+}
+
+func TestLogService_Facets(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.Facets(types.FacetQuery{}); err == nil {
+		t.Error("Expected error when storage does not support facets")
+	}
+
+	service = NewLogService(&MockParser{}, &MockHistogramStorage{})
+	result, err := service.Facets(types.FacetQuery{Limit: 5})
+	if err != nil {
+		t.Fatalf("Facets failed: %v", err)
+	}
+	if len(result.Facets) != 1 || result.Facets[0].Cardinality != 7 {
+		t.Errorf("Unexpected facets: %+v", result.Facets)
+	}
+}
+
+func (m *MockHistogramStorage) Fields(prefix string, limit int) ([]types.FieldInfo, error) {
+	return []types.FieldInfo{{Name: prefix + "id"}}, nil
+}
+
+func (m *MockHistogramStorage) FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	return []types.FieldValueInfo{{Value: query.Prefix + "1"}}, nil
+}
+
+func TestLogService_FieldCatalog(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.Fields("", 10); err == nil {
+		t.Error("Expected error when storage does not support field catalogs")
+	}
+	if _, err := service.FieldValues(types.FieldValuesQuery{Field: "hostname"}); err == nil {
+		t.Error("Expected error when storage does not support field catalogs")
+	}
+
+	service = NewLogService(&MockParser{}, &MockHistogramStorage{})
+	fields, err := service.Fields("request.", 10)
+	if err != nil || len(fields) != 1 || fields[0].Name != "request.id" {
+		t.Errorf("Unexpected fields: %+v (%v)", fields, err)
+	}
+	values, err := service.FieldValues(types.FieldValuesQuery{Field: "request.id", Prefix: "4"})
+	if err != nil || len(values) != 1 || values[0].Value != "41" {
+		t.Errorf("Unexpected values: %+v (%v)", values, err)
+	}
+}
+
+// MockPromoterStorage records field promotions
+type MockPromoterStorage struct {
+	MockStorage
+	promoted []string
+}
+
+func (m *MockPromoterStorage) PromoteField(field string) (*types.PromotedField, error) {
+	m.promoted = append(m.promoted, field)
+	return &types.PromotedField{Field: field, Status: types.PromotionBuilding}, nil
+}
+
+func (m *MockPromoterStorage) DemoteField(field string) error {
+	return interfaces.ErrFieldNotPromoted
+}
+
+func (m *MockPromoterStorage) PromotedFields() ([]types.PromotedField, error) {
+	fields := []types.PromotedField{}
+	for _, field := range m.promoted {
+		fields = append(fields, types.PromotedField{Field: field, Status: types.PromotionReady})
+	}
+	return fields, nil
+}
+
+func TestLogService_FieldPromotion(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.PromoteField("request.id"); err == nil {
+		t.Error("Expected error when storage does not support field promotion")
+	}
+	if _, err := service.PromotedFields(); err == nil {
+		t.Error("Expected error when storage does not support field promotion")
+	}
+
+	storage := &MockPromoterStorage{}
+	service = NewLogService(&MockParser{}, storage)
+	promoted, err := service.PromoteField("request.id")
+	if err != nil || promoted.Status != types.PromotionBuilding {
+		t.Errorf("Unexpected promotion: %+v (%v)", promoted, err)
+	}
+	fields, err := service.PromotedFields()
+	if err != nil || len(fields) != 1 || fields[0].Field != "request.id" {
+		t.Errorf("Unexpected promoted fields: %+v (%v)", fields, err)
+	}
+	if err := service.DemoteField("other.id"); !errors.Is(err, interfaces.ErrFieldNotPromoted) {
+		t.Errorf("Expected ErrFieldNotPromoted, got %v", err)
+	}
+}
+
+// MockReprocessStorage serves reprocessing batches from a fixed number of pages
+type MockReprocessStorage struct {
+	MockStorage
+	pages   int64
+	release chan struct{}
+	parsed  []string
+}
+
+func (m *MockReprocessStorage) ReprocessBatch(query types.ReprocessQuery, afterID int64, limit int, parse func(string) (*types.LogEntry, error)) (*types.ReprocessBatch, error) {
+	if m.release != nil {
+		<-m.release
+	}
+	entry, err := parse(fmt.Sprintf("raw %d", afterID))
+	if err != nil {
+		return nil, err
+	}
+	m.parsed = append(m.parsed, entry.Message)
+	return &types.ReprocessBatch{LastID: afterID + 1, Processed: 2, Updated: 1, Done: afterID+1 >= m.pages}, nil
+}
+
+func TestLogService_Reprocess(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.StartReprocess(types.ReprocessQuery{}); err == nil {
+		t.Error("Expected error when storage does not support reprocessing")
+	}
+
+	storage := &MockReprocessStorage{pages: 3, release: make(chan struct{})}
+	service = NewLogService(&MockParser{}, storage)
+
+	status, err := service.StartReprocess(types.ReprocessQuery{})
+	if err != nil || !status.Running || status.StartedAt == nil {
+		t.Fatalf("Unexpected start status: %+v (%v)", status, err)
+	}
+	if _, err := service.StartReprocess(types.ReprocessQuery{}); !errors.Is(err, interfaces.ErrReprocessRunning) {
+		t.Errorf("Expected ErrReprocessRunning, got %v", err)
+	}
+	close(storage.release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for service.ReprocessStatus().Running && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	final := service.ReprocessStatus()
+	if final.Running || final.FinishedAt == nil || final.Error != "" {
+		t.Fatalf("Expected finished job, got %+v", final)
+	}
+	if final.Processed != 6 || final.Updated != 3 {
+		t.Errorf("Unexpected totals: %+v", final)
+	}
+	if len(storage.parsed) != 3 || storage.parsed[2] != "raw 2" {
+		t.Errorf("Expected batches to be parsed with the service parser, got %v", storage.parsed)
+	}
+}
+
+// MockReencryptStorage passes one entry per batch to seal, for a fixed number of batches
+type MockReencryptStorage struct {
+	MockStorage
+	pages  int64
+	sealed []*types.LogEntry
+}
+
+func (m *MockReencryptStorage) ReencryptBatch(afterID int64, limit int, seal func(*types.LogEntry) bool) (*types.ReencryptBatch, error) {
+	entry := &types.LogEntry{ID: afterID + 1, StructuredData: map[string]interface{}{
+		"auth": map[string]interface{}{"token": fmt.Sprintf("token %d", afterID)},
+	}}
+	batch := &types.ReencryptBatch{LastID: afterID + 1, Processed: 1, Done: afterID+1 >= m.pages}
+	if seal(entry) {
+		m.sealed = append(m.sealed, entry)
+		batch.Updated++
+	}
+	return batch, nil
+}
+
+func TestLogService_FieldEncryption(t *testing.T) {
+	keys, err := fieldcrypt.NewKeyring([]fieldcrypt.Key{{ID: "k1", Key: base64.StdEncoding.EncodeToString(make([]byte, 32))}})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	encryptor := fieldcrypt.New([]string{"auth.token"}, keys)
+
+	storage := &MockStorage{}
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		return &types.LogEntry{Timestamp: time.Now(), Message: "login", StructuredData: map[string]interface{}{
+			"auth": map[string]string{"token": raw},
+		}}, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetRawRetention(true)
+	service.SetFieldEncryption(encryptor)
+	service.batchBuffer = append(service.batchBuffer, queuedLog{message: "s3cret", tenant: "acme"})
+	service.processBatch()
+
+	stored := storage.GetStoredLogs()
+	if len(stored) != 1 {
+		t.Fatalf("Expected one stored entry, got %d", len(stored))
+	}
+	token := stored[0].StructuredData["auth"].(map[string]string)["token"]
+	if !fieldcrypt.Sealed(token) || encryptor.Decrypt("auth.token", token) != "s3cret" || stored[0].Raw != "" {
+		t.Errorf("Expected the token encrypted and the raw message dropped, got %q (raw %q)", token, stored[0].Raw)
+	}
+
+	if _, err := NewLogService(&MockParser{}, &MockReencryptStorage{}).StartReencrypt(); !errors.Is(err, interfaces.ErrEncryptionDisabled) {
+		t.Errorf("Expected ErrEncryptionDisabled, got %v", err)
+	}
+
+	reencryptStorage := &MockReencryptStorage{pages: 3}
+	service = NewLogService(&MockParser{}, reencryptStorage)
+	service.SetFieldEncryption(encryptor)
+	if status, err := service.StartReencrypt(); err != nil || !status.Running {
+		t.Fatalf("Unexpected start status: %+v (%v)", status, err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for service.ReencryptStatus().Running && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	final := service.ReencryptStatus()
+	if final.Running || final.FinishedAt == nil || final.Processed != 3 || final.Updated != 3 || len(reencryptStorage.sealed) != 3 {
+		t.Errorf("Expected three entries re-encrypted, got %+v", final)
+	}
+}
+
+func TestLogService_ParseWorkersKeepOrder(t *testing.T) {
+	storage := &MockStorage{}
+	var parsing, maxParsing atomic.Int32
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		if n := parsing.Add(1); n > maxParsing.Load() {
+			maxParsing.Store(n)
+		}
+		defer parsing.Add(-1)
+		time.Sleep(time.Millisecond)
+		if raw == "bad" {
+			return nil, errors.New("malformed")
+		}
+		return &types.LogEntry{Timestamp: time.Now(), Message: raw}, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetParseWorkers(4)
+
+	var failed atomic.Int32
+	for i := 0; i < 50; i++ {
+		message := fmt.Sprintf("message %d", i)
+		if i == 10 {
+			message = "bad"
+		}
+		service.batchBuffer = append(service.batchBuffer, queuedLog{message: message, parseFailed: func() { failed.Add(1) }})
+	}
+	service.processBatch()
+
+	stored := storage.GetStoredLogs()
+	if len(stored) != 49 || failed.Load() != 1 {
+		t.Fatalf("Expected 49 stored entries and 1 failure, got %d and %d", len(stored), failed.Load())
+	}
+	for i, entry := range stored {
+		expected := i
+		if i >= 10 {
+			expected++
+		}
+		if entry.Message != fmt.Sprintf("message %d", expected) {
+			t.Fatalf("Expected entries in the order received, got %q at %d", entry.Message, i)
+		}
+	}
+	if maxParsing.Load() < 2 {
+		t.Errorf("Expected messages to be parsed concurrently, at most %d were", maxParsing.Load())
+	}
+	if stats := service.GetStats(); stats.ProcessedLogs != 49 || stats.FailedLogs != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestLogService_RetainsRawMessage(t *testing.T) {
+	storage := &MockStorage{}
+	service := NewLogService(&MockParser{}, storage)
+	if err := service.processLogMessage(queuedLog{message: "<134>1 - - - - - - raw"}); err != nil {
+		t.Fatalf("processLogMessage failed: %v", err)
+	}
+	stored := storage.GetStoredLogs()
+	if len(stored) != 1 || stored[0].Raw != "<134>1 - - - - - - raw" {
+		t.Errorf("Expected raw message to be retained, got %+v", stored)
+	}
+
+	service.SetRawRetention(false)
+	if err := service.processLogMessage(queuedLog{message: "<134>1 - - - - - - dropped"}); err != nil {
+		t.Fatalf("processLogMessage failed: %v", err)
+	}
+	stored = storage.GetStoredLogs()
+	if len(stored) != 2 || stored[1].Raw != "" {
+		t.Errorf("Expected raw message to be dropped when retention is off, got %+v", stored)
+	}
+
+	if _, err := service.RawMessage(1); err == nil {
+		t.Error("Expected error when storage does not retain raw messages")
+	}
+}
+
+func TestLogService_Stamp(t *testing.T) {
+	storage := &MockStorage{}
+	service := NewLogService(&MockParser{}, storage)
+	stamp := map[string]string{"environment": "prod", "region": "eu-west-1"}
+	service.SetStamp(stamp)
+	stamp["environment"] = "changed"
+
+	if err := service.processLogMessage(queuedLog{message: "test", tenant: "acme"}); err != nil {
+		t.Fatalf("processLogMessage failed: %v", err)
+	}
+	stored := storage.GetStoredLogs()
+	if len(stored) != 1 {
+		t.Fatalf("Expected 1 stored entry, got %d", len(stored))
+	}
+	entry := stored[0]
+	if entry.Metadata("environment") != "prod" || entry.Metadata("region") != "eu-west-1" {
+		t.Errorf("Expected the deployment metadata, got %v", entry.StructuredData)
+	}
+	if entry.Metadata(types.TenantParam) != "acme" {
+		t.Errorf("Expected the tenant to be kept alongside the stamp, got %v", entry.StructuredData)
+	}
+}
+
+// MockChainStorage reports a fixed hash chain verification
+type MockChainStorage struct {
+	MockStorage
+	partition string
+}
+
+func (m *MockChainStorage) VerifyChain(partition string) (*types.ChainReport, error) {
+	m.partition = partition
+	return &types.ChainReport{Valid: true, Entries: 3}, nil
+}
+
+func TestLogService_VerifyChain(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.VerifyChain(""); err == nil {
+		t.Error("Expected error for storage without hash chains")
+	}
+
+	storage := &MockChainStorage{}
+	service = NewLogService(&MockParser{}, storage)
+	report, err := service.VerifyChain("2024-05-01")
+	if err != nil || !report.Valid || report.Entries != 3 || storage.partition != "2024-05-01" {
+		t.Errorf("Unexpected verification %+v (%v), partition %q", report, err, storage.partition)
+	}
+}
+
+func TestLogService_CollapsedSearch(t *testing.T) {
+	// Newest first: 3 x a, 1 x b, 701 x c spanning a storage page boundary, then 495 x a
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var entries []*types.LogEntry
+	for i := 0; i < 1200; i++ {
+		message := "a"
+		switch {
+		case i == 3:
+			message = "b"
+		case i >= 4 && i < 705:
+			message = "c"
+		}
+		entries = append(entries, &types.LogEntry{
+			ID: int64(1200 - i), Hostname: "web01", AppName: "api", Message: message,
+			Timestamp: base.Add(-time.Duration(i) * time.Second),
+		})
+	}
+
+	var pages []types.SearchQuery
+	storage := &MockStorage{searchFunc: func(query types.SearchQuery) ([]*types.LogEntry, error) {
+		pages = append(pages, query)
+		end := min(query.Offset+query.Limit, len(entries))
+		var page []*types.LogEntry
+		for _, entry := range entries[min(query.Offset, end):end] {
+			copied := *entry
+			page = append(page, &copied)
+		}
+		return page, nil
+	}}
+	service := NewLogService(&MockParser{}, storage)
+
+	results, err := service.Search(types.SearchQuery{Collapse: true, Limit: 2, Offset: 1, Fields: []string{"severity"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Message != "b" || results[1].Message != "c" {
+		t.Fatalf("Expected the b and c runs, got %+v", results)
+	}
+	c := results[1]
+	if c.RepeatCount != 701 || !c.LastTimestamp.Equal(base.Add(-4*time.Second)) || !c.FirstTimestamp.Equal(base.Add(-704*time.Second)) {
+		t.Errorf("Unexpected c run: count %d, %v to %v", c.RepeatCount, c.FirstTimestamp, c.LastTimestamp)
+	}
+	if len(pages) != 2 || pages[0].Collapse || pages[1].Offset != collapsePageSize {
+		t.Errorf("Expected two storage pages, got %+v", pages)
+	}
+	if fields := pages[0].Fields; len(fields) < 4 || fields[len(fields)-1] != "severity" {
+		t.Errorf("Expected the run fields to be selected, got %v", fields)
+	}
+
+	// Without a limit every run is returned, a different source starts a new one
+	entries[1].Hostname = "web02"
+	results, err = service.Search(types.SearchQuery{Collapse: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 6 || results[0].RepeatCount != 1 || results[5].RepeatCount != 495 {
+		t.Errorf("Unexpected runs: %d results", len(results))
+	}
+}
+
+// MockReportStorage keeps reports in memory and records report runs
+type MockReportStorage struct {
+	MockStorage
+	reports []types.Report
+	runs    []types.SearchQuery
+}
+
+func (m *MockReportStorage) CreateReport(report *types.Report) error {
+	report.ID = int64(len(m.reports) + 1)
+	m.reports = append(m.reports, *report)
+	return nil
+}
+
+func (m *MockReportStorage) Reports() ([]types.Report, error) {
+	return m.reports, nil
+}
+
+func (m *MockReportStorage) UpdateReport(report *types.Report) error {
+	for i := range m.reports {
+		if m.reports[i].ID == report.ID {
+			report.LastRunAt = m.reports[i].LastRunAt
+			m.reports[i] = *report
+			return nil
+		}
+	}
+	return interfaces.ErrReportNotFound
+}
+
+func (m *MockReportStorage) DeleteReport(id int64) error {
+	return interfaces.ErrReportNotFound
+}
+
+func (m *MockReportStorage) RunReport(report types.Report, query types.SearchQuery) (*types.ReportResult, error) {
+	m.runs = append(m.runs, query)
+	for i := range m.reports {
+		if m.reports[i].ID == report.ID {
+			m.reports[i].LastRunAt = query.EndTime
+		}
+	}
+	return &types.ReportResult{ReportID: report.ID}, nil
+}
+
+func (m *MockReportStorage) ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	return nil, nil
+}
+
+func (m *MockReportStorage) AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	return nil, nil
+}
+
+func TestLogService_Reports(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if err := service.CreateReport(&types.Report{Name: "errors", IntervalSeconds: 60}); err == nil {
+		t.Error("Expected error for storage without reports")
+	}
+
+	storage := &MockReportStorage{}
+	service = NewLogService(&MockParser{}, storage)
+	invalid := []types.Report{
+		{Name: " ", IntervalSeconds: 60},
+		{Name: "fast", IntervalSeconds: 10},
+		{Name: "bad query", Query: `"unterminated`, IntervalSeconds: 60},
+		{Name: "bad field", TopField: "message", IntervalSeconds: 60},
+		{Name: "bad limit", TopField: "app_name", TopLimit: 1000, IntervalSeconds: 60},
+		{Name: "bad threshold", AlertThreshold: -1, IntervalSeconds: 60},
+	}
+	for _, report := range invalid {
+		if err := service.CreateReport(&report); !errors.Is(err, interfaces.ErrInvalidReport) {
+			t.Errorf("Expected report %q to be invalid, got %v", report.Name, err)
+		}
+	}
+
+	if err := service.CreateReport(&types.Report{Name: " errors ", Query: "severity<=err app:api", TopField: "hostname", IntervalSeconds: 3600}); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	if storage.reports[0].Name != "errors" {
+		t.Errorf("Expected trimmed name, got %q", storage.reports[0].Name)
+	}
+
+	// The first run covers one interval, later ones start after the previous window
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service.runDueReports(now)
+	if len(storage.runs) != 1 {
+		t.Fatalf("Expected one run, got %d", len(storage.runs))
+	}
+	first := storage.runs[0]
+	if !first.StartTime.Equal(now.Add(-time.Hour)) || !first.EndTime.Equal(now) || len(first.Filters) != 1 || first.MinSeverity == nil {
+		t.Errorf("Unexpected first run query: %+v", first)
+	}
+
+	service.runDueReports(now.Add(30 * time.Minute))
+	if len(storage.runs) != 1 {
+		t.Errorf("Expected report not to run before its interval, got %d runs", len(storage.runs))
+	}
+
+	service.runDueReports(now.Add(time.Hour))
+	if len(storage.runs) != 2 {
+		t.Fatalf("Expected a second run, got %d", len(storage.runs))
+	}
+	if second := storage.runs[1]; !second.StartTime.Equal(now.Add(time.Nanosecond)) || !second.EndTime.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected second run window: %v - %v", second.StartTime, second.EndTime)
+	}
+
+	// An update is validated and the next run continues after the last window
+	if err := service.UpdateReport(&types.Report{ID: 1, Name: "errors", IntervalSeconds: 10}); !errors.Is(err, interfaces.ErrInvalidReport) {
+		t.Errorf("Expected an invalid update to be rejected, got %v", err)
+	}
+	if err := service.UpdateReport(&types.Report{ID: 1, Name: " errors ", Query: "severity<=err", IntervalSeconds: 1800}); err != nil {
+		t.Fatalf("UpdateReport failed: %v", err)
+	}
+	if storage.reports[0].Name != "errors" || storage.reports[0].IntervalSeconds != 1800 {
+		t.Errorf("Expected the new definition stored, got %+v", storage.reports[0])
+	}
+	service.runDueReports(now.Add(90 * time.Minute))
+	if len(storage.runs) != 3 || !storage.runs[2].StartTime.Equal(now.Add(time.Hour+time.Nanosecond)) {
+		t.Errorf("Expected a run after the last window at the new interval, got %+v", storage.runs)
+	}
+}
+
+func TestLogService_Compare(t *testing.T) {
+	baselineStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	currentStart := baselineStart.Add(24 * time.Hour)
+	entries := map[time.Time][]*types.LogEntry{
+		baselineStart: {
+			{Severity: 6, AppName: "api", Message: "request 17 served in 12ms"},
+			{Severity: 6, AppName: "api", Message: "request 16 served in 9ms"},
+			{Severity: 4, AppName: "db", Message: "slow query took 2.5s"},
+		},
+		currentStart: {
+			{Severity: 3, AppName: "api", Message: `connection to "10.0.0.7" refused`},
+			{Severity: 6, AppName: "api", Message: "request 42 served in 3ms"},
+			{Severity: 3, AppName: "api", Message: `connection to "10.0.0.8" refused`},
+		},
+	}
+	storage := &MockStorage{searchFunc: func(query types.SearchQuery) ([]*types.LogEntry, error) {
+		if query.Offset > 0 {
+			return nil, nil
+		}
+		return entries[*query.StartTime], nil
+	}}
+	service := NewLogService(&MockParser{}, storage)
+
+	result, err := service.Compare(types.CompareQuery{
+		BaselineStart: baselineStart,
+		BaselineEnd:   baselineStart.Add(time.Hour),
+		CurrentStart:  currentStart,
+		CurrentEnd:    currentStart.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if result.Baseline.Count != 3 || result.Current.Count != 3 {
+		t.Errorf("Expected 3 entries in each range, got %d and %d", result.Baseline.Count, result.Current.Count)
+	}
+	if result.TotalPatterns != 3 || len(result.Patterns) != 3 {
+		t.Fatalf("Expected 3 patterns, got %d: %+v", result.TotalPatterns, result.Patterns)
+	}
+
+	// The newly appearing error comes first
+	first := result.Patterns[0]
+	if first.Pattern != "connection to <*> refused" || !first.New || first.Current != 2 || first.Severity != 3 {
+		t.Errorf("Expected new connection pattern first, got %+v", first)
+	}
+	if first.Example != `connection to "10.0.0.7" refused` {
+		t.Errorf("Expected most recent example, got %q", first.Example)
+	}
+	for _, pattern := range result.Patterns[1:] {
+		switch pattern.Pattern {
+		case "request <*> served in <*>":
+			if pattern.Baseline != 2 || pattern.Current != 1 || pattern.Delta != -1 || pattern.New || pattern.Gone {
+				t.Errorf("Unexpected request pattern %+v", pattern)
+			}
+		case "slow query took <*>":
+			if !pattern.Gone || pattern.Delta != -1 {
+				t.Errorf("Expected slow query pattern to be gone, got %+v", pattern)
+			}
+		default:
+			t.Errorf("Unexpected pattern %q", pattern.Pattern)
+		}
+	}
+
+	severities := make(map[int]types.SeverityDelta)
+	for _, delta := range result.Severities {
+		severities[delta.Severity] = delta
+	}
+	if severities[3].Delta != 2 || severities[4].Delta != -1 || severities[6].Delta != -1 {
+		t.Errorf("Unexpected severity deltas %+v", result.Severities)
+	}
+
+	limited, err := service.Compare(types.CompareQuery{
+		BaselineStart: baselineStart,
+		BaselineEnd:   baselineStart.Add(time.Hour),
+		CurrentStart:  currentStart,
+		CurrentEnd:    currentStart.Add(time.Hour),
+		Limit:         1,
+	})
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if len(limited.Patterns) != 1 || limited.TotalPatterns != 3 {
+		t.Errorf("Expected 1 of 3 patterns, got %d of %d", len(limited.Patterns), limited.TotalPatterns)
+	}
+
+	if _, err := service.Compare(types.CompareQuery{BaselineStart: currentStart, BaselineEnd: baselineStart}); err == nil {
+		t.Error("Expected error for an inverted range")
+	}
+}
+
+// MockEventStorage is a MockHistogramStorage that also keeps event markers
+type MockEventStorage struct {
+	MockHistogramStorage
+	events  []types.Event
+	queries []types.EventQuery
+}
+
+func (m *MockEventStorage) CreateEvent(event *types.Event) error {
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *MockEventStorage) Events(query types.EventQuery) ([]types.Event, error) {
+	m.queries = append(m.queries, query)
+	return m.events, nil
+}
+
+func (m *MockEventStorage) DeleteEvent(id int64) error {
+	return interfaces.ErrEventNotFound
+}
+
+type MockPreferencesStorage struct {
+	MockStorage
+	preferences map[string]types.UserPreferences
+}
+
+func (m *MockPreferencesStorage) UserPreferences(user string) (*types.UserPreferences, error) {
+	preferences := m.preferences[user]
+	return &preferences, nil
+}
+
+func (m *MockPreferencesStorage) SaveUserPreferences(user string, preferences *types.UserPreferences) error {
+	if m.preferences == nil {
+		m.preferences = make(map[string]types.UserPreferences)
+	}
+	preferences.UpdatedAt = time.Now()
+	m.preferences[user] = *preferences
+	return nil
+}
+
+func (m *MockPreferencesStorage) DeleteUserPreferences(user string) error {
+	delete(m.preferences, user)
+	return nil
+}
+
+func TestLogService_UserPreferences(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.UserPreferences("alice"); err == nil {
+		t.Error("Expected error when storage does not support preferences")
+	}
+
+	storage := &MockPreferencesStorage{}
+	service = NewLogService(&MockParser{}, storage)
+
+	invalid := []types.UserPreferences{
+		{Display: json.RawMessage(`[1, 2]`)},
+		{PinnedFilters: []types.PinnedFilter{{Name: " ", Filters: json.RawMessage(`{}`)}}},
+		{PinnedFilters: []types.PinnedFilter{{Name: "errors", Filters: json.RawMessage(`"severity<=3"`)}}},
+		{PinnedFilters: make([]types.PinnedFilter, types.MaxPinnedFilters+1)},
+		{RecentQueries: []json.RawMessage{json.RawMessage(`null`)}},
+	}
+	for _, preferences := range invalid {
+		if err := service.SaveUserPreferences("alice", &preferences); !errors.Is(err, interfaces.ErrInvalidPreferences) {
+			t.Errorf("Expected ErrInvalidPreferences for %+v, got %v", preferences, err)
+		}
+	}
+
+	// Filters are stored with sorted keys, so the same query is only kept once
+	preferences := &types.UserPreferences{
+		Display:       json.RawMessage(`{"layout": "cards", "compactMode": true}`),
+		PinnedFilters: []types.PinnedFilter{{Name: " errors ", Filters: json.RawMessage(`{"minSeverity": 3}`)}},
+		RecentQueries: []json.RawMessage{json.RawMessage(`{"text": "a", "appName": "api"}`), json.RawMessage(`{"appName":"api","text":"a"}`)},
+	}
+	if err := service.SaveUserPreferences("alice", preferences); err != nil {
+		t.Fatalf("SaveUserPreferences failed: %v", err)
+	}
+	saved := storage.preferences["alice"]
+	if string(saved.Display) != `{"compactMode":true,"layout":"cards"}` || saved.PinnedFilters[0].Name != "errors" || len(saved.RecentQueries) != 1 {
+		t.Errorf("Expected canonical preferences, got %+v", saved)
+	}
+
+	// A recent query moves to the front, and the oldest are dropped
+	for i := 0; i < types.MaxRecentQueries+5; i++ {
+		if _, err := service.AddRecentQuery("alice", json.RawMessage(fmt.Sprintf(`{"text":"q%d"}`, i))); err != nil {
+			t.Fatalf("AddRecentQuery failed: %v", err)
+		}
+	}
+	updated, err := service.AddRecentQuery("alice", json.RawMessage(`{"text": "q10"}`))
+	if err != nil {
+		t.Fatalf("AddRecentQuery failed: %v", err)
+	}
+	if len(updated.RecentQueries) != types.MaxRecentQueries || string(updated.RecentQueries[0]) != `{"text":"q10"}` ||
+		string(updated.RecentQueries[1]) != fmt.Sprintf(`{"text":"q%d"}`, types.MaxRecentQueries+4) {
+		t.Errorf("Unexpected recent queries %s", updated.RecentQueries)
+	}
+	if len(updated.PinnedFilters) != 1 || string(updated.Display) != `{"compactMode":true,"layout":"cards"}` {
+		t.Errorf("Expected the other preferences kept, got %+v", updated)
+	}
+	if _, err := service.AddRecentQuery("alice", json.RawMessage(`"text"`)); !errors.Is(err, interfaces.ErrInvalidPreferences) {
+		t.Errorf("Expected ErrInvalidPreferences for a query that is not an object, got %v", err)
+	}
+
+	if err := service.DeleteUserPreferences("alice"); err != nil {
+		t.Fatalf("DeleteUserPreferences failed: %v", err)
+	}
+	if reset, _ := service.UserPreferences("alice"); reset.Display != nil {
+		t.Errorf("Expected the preferences removed, got %+v", reset)
+	}
+}
+
+type MockShareStorage struct {
+	MockStorage
+	shares         map[string]types.Share
+	expiredRemoved int
+}
+
+func (m *MockShareStorage) CreateShare(share *types.Share) error {
+	if m.shares == nil {
+		m.shares = make(map[string]types.Share)
+	}
+	share.EntryCount = len(share.Entries)
+	m.shares[share.Token] = *share
+	return nil
+}
+
+func (m *MockShareStorage) Share(token string, now time.Time) (*types.Share, error) {
+	share, ok := m.shares[token]
+	if !ok || !share.ExpiresAt.After(now) {
+		return nil, interfaces.ErrShareNotFound
+	}
+	return &share, nil
+}
+
+func (m *MockShareStorage) Shares(now time.Time) ([]types.Share, error) {
+	var shares []types.Share
+	for _, share := range m.shares {
+		if share.ExpiresAt.After(now) {
+			share.Entries = nil
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+func (m *MockShareStorage) DeleteShare(token string) error {
+	if _, ok := m.shares[token]; !ok {
+		return interfaces.ErrShareNotFound
+	}
+	delete(m.shares, token)
+	return nil
+}
+
+func (m *MockShareStorage) DeleteExpiredShares(now time.Time) (int64, error) {
+	m.expiredRemoved++
+	return 0, nil
+}
+
+func TestLogService_Shares(t *testing.T) {
+	entry := &types.LogEntry{ID: 1, Message: "upstream timed out"}
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if err := service.CreateShare(&types.Share{Entries: []*types.LogEntry{entry}}, 0); err == nil {
+		t.Error("Expected error when storage does not support shares")
+	}
+
+	storage := &MockShareStorage{}
+	service = NewLogService(&MockParser{}, storage)
+
+	invalid := []struct {
+		share types.Share
+		ttl   time.Duration
+	}{
+		{share: types.Share{}},
+		{share: types.Share{Entries: make([]*types.LogEntry, types.MaxShareEntries+1)}},
+		{share: types.Share{Entries: []*types.LogEntry{entry}, Description: strings.Repeat("d", types.MaxShareDescription+1)}},
+		{share: types.Share{Entries: []*types.LogEntry{entry}}, ttl: time.Second},
+		{share: types.Share{Entries: []*types.LogEntry{entry}}, ttl: types.MaxShareTTL + time.Hour},
+	}
+	for _, test := range invalid {
+		if err := service.CreateShare(&test.share, test.ttl); !errors.Is(err, interfaces.ErrInvalidShare) {
+			t.Errorf("Expected ErrInvalidShare for %d entries expiring in %s, got %v", len(test.share.Entries), test.ttl, err)
+		}
+	}
+
+	share := &types.Share{Entries: []*types.LogEntry{entry}, CreatedBy: "alice"}
+	if err := service.CreateShare(share, 0); err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+	if len(share.Token) != 32 || share.ExpiresAt.Sub(share.CreatedAt) != types.DefaultShareTTL || storage.expiredRemoved != 1 {
+		t.Errorf("Expected a token and the default expiry set and expired shares removed, got %+v", share)
+	}
+	other := &types.Share{Entries: []*types.LogEntry{entry}}
+	if err := service.CreateShare(other, time.Hour); err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+	if other.Token == share.Token || other.ExpiresAt.Sub(other.CreatedAt) != time.Hour {
+		t.Errorf("Expected a token of its own expiring in an hour, got %+v", other)
+	}
+
+	opened, err := service.Share(share.Token)
+	if err != nil || len(opened.Entries) != 1 || opened.CreatedBy != "alice" {
+		t.Errorf("Expected the share opened, got %+v (%v)", opened, err)
+	}
+	if listed, err := service.Shares(); err != nil || len(listed) != 2 {
+		t.Errorf("Expected two shares listed, got %+v (%v)", listed, err)
+	}
+	if err := service.DeleteShare(share.Token); err != nil {
+		t.Fatalf("DeleteShare failed: %v", err)
+	}
+	if _, err := service.Share(share.Token); !errors.Is(err, interfaces.ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound once deleted, got %v", err)
+	}
+}
+
+func TestLogService_Events(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if err := service.RecordEvent(&types.Event{Service: "api"}); err == nil {
+		t.Error("Expected error when storage does not support events")
+	}
+
+	storage := &MockEventStorage{}
+	service = NewLogService(&MockParser{}, storage)
+
+	invalid := []types.Event{
+		{},
+		{Service: "  "},
+		{Service: "api", Version: strings.Repeat("v", types.MaxEventFieldLength+1)},
+	}
+	for _, event := range invalid {
+		if err := service.RecordEvent(&event); !errors.Is(err, interfaces.ErrInvalidEvent) {
+			t.Errorf("Expected ErrInvalidEvent for %+v, got %v", event, err)
+		}
+	}
+
+	// Type and time default to a deploy now
+	event := &types.Event{Service: " api ", Version: "1.4.0"}
+	if err := service.RecordEvent(event); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+	if event.Type != types.EventDeploy || event.Service != "api" || event.Time.IsZero() {
+		t.Errorf("Expected defaults to be applied, got %+v", event)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	histogram, err := service.Histogram(types.HistogramQuery{StartTime: start, EndTime: start.Add(time.Hour), AppName: "api"})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if len(histogram.Events) != 1 || histogram.Events[0].Version != "1.4.0" {
+		t.Errorf("Expected the event with the histogram, got %+v", histogram.Events)
+	}
+	if len(storage.queries) != 1 || storage.queries[0].Service != "api" || !storage.queries[0].StartTime.Equal(start) {
+		t.Errorf("Expected events of the histogram's range and app, got %+v", storage.queries)
+	}
+}
+
+func TestLogService_SanitizesEntries(t *testing.T) {
+	storage := &MockStorage{}
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		return &types.LogEntry{Message: raw, Timestamp: time.Now()}, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetBatchTimeout(10 * time.Millisecond)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	service.ProcessLog("clean message")
+	service.ProcessLog("colored \x1b[31mtext\x1b[0m and \xff")
+
+	deadline := time.Now().Add(time.Second)
+	for len(storage.GetStoredLogs()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	logs := storage.GetStoredLogs()
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 stored logs, got %d", len(logs))
+	}
+	if want := `colored \x1b[31mtext\x1b[0m and ` + "\ufffd"; logs[1].Message != want {
+		t.Errorf("Expected sanitized message %q, got %q", want, logs[1].Message)
+	}
+	if stats := service.GetStats(); stats.SanitizedLogs != 1 {
+		t.Errorf("Expected 1 sanitized entry, got %d", stats.SanitizedLogs)
+	}
+}
+
+func TestLogService_LimitsStructuredData(t *testing.T) {
+	storage := &MockStorage{}
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		return &types.LogEntry{
+			AppName:        "api",
+			Message:        raw,
+			Timestamp:      time.Now(),
+			StructuredData: map[string]interface{}{"req": map[string]string{raw: "1"}},
+		}, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetBatchTimeout(10 * time.Millisecond)
+	service.SetStructuredDataLimits(sanitize.Limits{MaxKeysPerApp: 1})
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	service.ProcessLog("first")
+	service.ProcessLog("second")
+
+	deadline := time.Now().Add(time.Second)
+	for len(storage.GetStoredLogs()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	logs := storage.GetStoredLogs()
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 stored logs, got %d", len(logs))
+	}
+	if _, ok := logs[1].StructuredData["req"]; ok {
+		t.Errorf("Expected the new key to be bucketed, got %v", logs[1].StructuredData)
+	}
+	if stats := service.GetStats(); stats.LimitedLogs != 1 {
+		t.Errorf("Expected 1 limited entry, got %d", stats.LimitedLogs)
+	}
+}
+
+func TestLogService_DropsDuplicateEntries(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	storage := &MockStorage{}
+	storage.storeFunc = func(entry *types.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		key := entry.Metadata(types.IdempotencyKeyParam)
+		if seen[key] {
+			return interfaces.ErrDuplicateEntry
+		}
+		seen[key] = true
+		return nil
+	}
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		entry := &types.LogEntry{Message: raw, Timestamp: time.Now()}
+		entry.SetMetadata(types.IdempotencyKeyParam, raw)
+		return entry, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetBatchTimeout(10 * time.Millisecond)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	subscription := service.Subscribe()
+
+	for _, message := range []string{"a", "a", "b"} {
+		service.ProcessLog(message)
+	}
+
+	// Only entries actually stored are announced
+	for _, want := range []string{"a", "b"} {
+		select {
+		case entry := <-subscription:
+			if entry.Message != want {
+				t.Errorf("Expected entry %q, got %q", want, entry.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for entry %q", want)
+		}
+	}
+	select {
+	case entry := <-subscription:
+		t.Errorf("Expected the duplicate not to be announced, got %q", entry.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	stats := service.GetStats()
+	if stats.DuplicateLogs != 1 || stats.FailedLogs != 0 {
+		t.Errorf("Expected 1 duplicate and no failed entry, got %+v", stats)
+	}
+}
+
+// delayedCommitStorage stores entries at once but reports their commit later, as batched storage
+// does
+type delayedCommitStorage struct {
+	MockStorage
+}
+
+func (d *delayedCommitStorage) StoreNotify(entry *types.LogEntry, committed func(error)) error {
+	err := d.Store(entry)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		committed(err)
+	}()
+	return nil
+}
+
+func TestLogService_StreamOrder(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	storage := &delayedCommitStorage{}
+	storage.storeFunc = func(entry *types.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		key := entry.Metadata(types.IdempotencyKeyParam)
+		if key != "" && seen[key] {
+			return interfaces.ErrDuplicateEntry
+		}
+		seen[key] = true
+		return nil
+	}
+	// Messages starting with k carry their first word as idempotency key
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		if strings.HasPrefix(raw, "bad") {
+			return nil, errors.New("malformed")
+		}
+		entry := &types.LogEntry{Message: raw, Timestamp: time.Now()}
+		if key, _, _ := strings.Cut(raw, " "); strings.HasPrefix(key, "k") {
+			entry.SetMetadata(types.IdempotencyKeyParam, key)
+		}
+		return entry, nil
+	}}
+	service := NewLogService(parser, storage)
+	service.SetParseWorkers(4)
+	service.SetBatchTimeout(10 * time.Millisecond)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	subscription := service.Subscribe()
+
+	// Entries announced only once committed must not be overtaken by the entries after them, and
+	// messages that are dropped must not hold up the rest of their stream
+	streams := map[int64][]string{
+		1: {"k1 one", "a two", "bad three", "k1 four", "a five", "k6 six", "a seven"},
+		2: {"k2 one", "b two", "b three"},
+	}
+	for i := 0; i < 7; i++ {
+		for stream := int64(1); stream <= 2; stream++ {
+			if i < len(streams[stream]) {
+				if err := service.ProcessLogInStream(stream, streams[stream][i], "", "", nil, nil); err != nil {
+					t.Fatalf("ProcessLogInStream failed: %v", err)
+				}
+			}
+		}
+	}
+
+	expected := map[int64][]string{
+		1: {"k1 one", "a two", "a five", "k6 six", "a seven"},
+		2: {"k2 one", "b two", "b three"},
+	}
+	received := make(map[int64][]string)
+	deadline := time.After(2 * time.Second)
+	for n := 0; n < 8; n++ {
+		select {
+		case entry := <-subscription:
+			stream := int64(1)
+			if slices.Contains(streams[2], entry.Message) {
+				stream = 2
+			}
+			received[stream] = append(received[stream], entry.Message)
+		case <-deadline:
+			t.Fatalf("Timeout waiting for entries, got %v", received)
+		}
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected entries in the order of their streams %v, got %v", expected, received)
+	}
+
+	service.order.mu.Lock()
+	defer service.order.mu.Unlock()
+	if len(service.order.streams) != 0 {
+		t.Errorf("Expected finished streams to be forgotten, got %d", len(service.order.streams))
+	}
+}
+
+func TestLogService_Mode(t *testing.T) {
+	storage := &MockStorage{}
+	parser := &MockParser{parseFunc: func(raw string) (*types.LogEntry, error) {
+		return &types.LogEntry{Message: raw, Timestamp: time.Now()}, nil
+	}}
+	service := NewLogService(parser, storage)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+
+	if _, err := service.SetMode("paused", ""); !errors.Is(err, interfaces.ErrInvalidMode) {
+		t.Errorf("Expected ErrInvalidMode, got %v", err)
+	}
+
+	status, err := service.SetMode(types.ModeReadOnly, "restoring backup")
+	if err != nil || status.Mode != types.ModeReadOnly || status.Since == nil {
+		t.Fatalf("Unexpected mode %+v, error %v", status, err)
+	}
+	err = service.ProcessLog("rejected")
+	if !errors.Is(err, interfaces.ErrReadOnly) || !strings.Contains(err.Error(), "restoring backup") {
+		t.Errorf("Expected ErrReadOnly with the notice, got %v", err)
+	}
+	if stats := service.GetStats(); stats.RejectedLogs != 1 {
+		t.Errorf("Expected 1 rejected message, got %d", stats.RejectedLogs)
+	}
+
+	status, err = service.SetMode(types.ModeNormal, "ignored")
+	if err != nil || status.Message != "" {
+		t.Fatalf("Unexpected mode %+v, error %v", status, err)
+	}
+	if err := service.ProcessLog("accepted"); err != nil {
+		t.Errorf("Expected ingestion to resume, got %v", err)
+	}
+}
+
+func TestLogService_SearchCache(t *testing.T) {
+	var searches int
+	var duringSearch func()
+	storage := &MockStorage{}
+	storage.searchFunc = func(query types.SearchQuery) ([]*types.LogEntry, error) {
+		searches++
+		if duringSearch != nil {
+			duringSearch()
+		}
+		return []*types.LogEntry{{ID: int64(searches), Message: "result"}}, nil
+	}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetSearchCache(time.Hour, 0)
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	start, later := base.Add(-15*time.Minute), base.Add(-15*time.Minute+time.Second)
+	query := types.SearchQuery{StartTime: &start, Limit: 50, Filters: []types.FieldFilter{
+		{Field: "hostname", Value: "web-1"}, {Field: "app_name", Value: "api"},
+	}}
+
+	results, err := service.Search(query)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search failed: %v", err)
+	}
+	results[0].Message = "changed by the caller"
+
+	// Refired with the filters reordered and the relative start a second later
+	refired := query
+	refired.StartTime = &later
+	refired.Filters = []types.FieldFilter{query.Filters[1], query.Filters[0]}
+	results, _ = service.Search(refired)
+	if searches != 1 || results[0].Message != "result" {
+		t.Errorf("Expected an unchanged cached result, got %d searches and %q", searches, results[0].Message)
+	}
+	if stats := service.GetStats(); stats.CachedSearches != 1 {
+		t.Errorf("Expected 1 cached search, got %d", stats.CachedSearches)
+	}
+
+	other := query
+	other.Limit = 10
+	service.Search(other)
+	if searches != 2 {
+		t.Errorf("Expected a different limit to search storage, got %d searches", searches)
+	}
+
+	// An entry before the range leaves the results alone, one inside drops them
+	if err := service.store(&types.LogEntry{Timestamp: start.Add(-2 * time.Hour)}, nil); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+	service.Search(query)
+	if searches != 2 {
+		t.Errorf("Expected an entry outside the range to keep the cache, got %d searches", searches)
+	}
+	if err := service.store(&types.LogEntry{Timestamp: base}, nil); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+	service.Search(query)
+	if searches != 3 {
+		t.Errorf("Expected an entry in the range to drop the cache, got %d searches", searches)
+	}
+
+	// A result that may be missing an entry written while it was read is not cached
+	service.clearSearchCache()
+	duringSearch = func() {
+		duringSearch = nil
+		service.store(&types.LogEntry{Timestamp: base}, nil)
+	}
+	service.Search(query)
+	service.Search(query)
+	if searches != 5 {
+		t.Errorf("Expected a result raced by a write not to be cached, got %d searches", searches)
+	}
+
+	service.SetSearchCache(0, 0)
+	service.Search(query)
+	service.Search(query)
+	if searches != 7 {
+		t.Errorf("Expected every search to reach storage with caching disabled, got %d searches", searches)
+	}
+}
+
+func TestLogService_Retention(t *testing.T) {
+	cleaned := make(chan int, 1)
+	storage := &MockStorage{cleanupFunc: func(retentionDays int) error {
+		cleaned <- retentionDays
+		return nil
+	}}
+	service := NewLogService(&MockParser{}, storage)
+	if err := service.RunRetention(); err == nil {
+		t.Error("Expected an error without a retention period")
+	}
+
+	// The first run happens at start
+	service.SetRetention(30)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	select {
+	case days := <-cleaned:
+		if days != 30 {
+			t.Errorf("Expected a cleanup of entries older than 30 days, got %d", days)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected retention to run at start")
+	}
+
+	storage.cleanupFunc = func(int) error { return errors.New("disk I/O error") }
+	if err := service.RunRetention(); err == nil || !strings.Contains(err.Error(), "disk I/O error") {
+		t.Errorf("Expected the storage error, got %v", err)
+	}
+}
+
+func TestLogService_RetentionClearsSearchCache(t *testing.T) {
+	expired := true
+	storage := &MockStorage{cleanupFunc: func(int) error {
+		expired = false
+		return nil
+	}}
+	storage.searchFunc = func(query types.SearchQuery) ([]*types.LogEntry, error) {
+		if expired {
+			return []*types.LogEntry{{ID: 1, Message: "expired"}}, nil
+		}
+		return nil, nil
+	}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetSearchCache(time.Hour, 0)
+	service.SetRetention(30)
+
+	query := types.SearchQuery{Text: "expired", Limit: 50}
+	if results, err := service.Search(query); err != nil || len(results) != 1 {
+		t.Fatalf("Expected the expired entry before retention, got %v (%v)", results, err)
+	}
+	if err := service.RunRetention(); err != nil {
+		t.Fatalf("Retention failed: %v", err)
+	}
+	if results, err := service.Search(query); err != nil || len(results) != 0 {
+		t.Errorf("Expected retention to drop the cached result, got %v (%v)", results, err)
+	}
+}
+
+func TestLogService_MemoryLimit(t *testing.T) {
+	// Storage holds the entries until the gate opens, so their memory stays pending
+	gate := make(chan struct{})
+	storage := &MockStorage{storeFunc: func(*types.LogEntry) error {
+		<-gate
+		return nil
+	}}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetBatchSize(1)
+	cost := memoryCost(queuedLog{message: "message"})
+	service.SetMemoryLimit(2 * cost)
+	service.memory.wait = 50 * time.Millisecond
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	open := sync.OnceFunc(func() { close(gate) })
+	defer open()
+
+	for i := 0; i < 2; i++ {
+		if err := service.ProcessLog("message"); err != nil {
+			t.Fatalf("Expected messages within the limit to be accepted, got %v", err)
+		}
+	}
+	if stats := service.GetStats(); stats.PendingMemoryBytes != 2*cost || stats.MemoryLimitBytes != 2*cost {
+		t.Errorf("Expected %d bytes pending of %d, got %d of %d", 2*cost, 2*cost, stats.PendingMemoryBytes, stats.MemoryLimitBytes)
+	}
+
+	// A message past the limit waits for memory and is rejected when none is released in time
+	if err := service.ProcessLog("message"); !errors.Is(err, interfaces.ErrMemoryLimit) {
+		t.Fatalf("Expected the memory limit to reject the message, got %v", err)
+	}
+	if stats := service.GetStats(); stats.MemoryRejectedLogs != 1 {
+		t.Errorf("Expected 1 message rejected for memory, got %d", stats.MemoryRejectedLogs)
+	}
+
+	// and is accepted once stored entries release theirs
+	service.memory.wait = 5 * time.Second
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- service.ProcessLog("message")
+	}()
+	select {
+	case err := <-accepted:
+		t.Fatalf("Expected the message to wait for memory, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	open()
+	if err := <-accepted; err != nil {
+		t.Fatalf("Expected the message to be accepted once memory was released, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for service.GetStats().PendingMemoryBytes != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pending := service.GetStats().PendingMemoryBytes; pending != 0 {
+		t.Errorf("Expected all memory released once stored, got %d bytes pending", pending)
+	}
+}
+
+func TestLogService_DegradedMode(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	storage := &MockStorage{}
+	storage.storeFunc = func(entry *types.LogEntry) error {
+		if failing.Load() {
+			return errors.New("disk I/O error")
+		}
+		storage.mutex.Lock()
+		defer storage.mutex.Unlock()
+		storage.storedLogs = append(storage.storedLogs, *entry)
+		return nil
+	}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetBatchSize(1)
+	service.SetDegradeAfter(time.Nanosecond)
+	service.health.probeInterval = 50 * time.Millisecond
+	if err := service.SetDegradedSpool(filepath.Join(t.TempDir(), "degraded.spool"), 0); err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	subscription := service.Subscribe()
+
+	waitFor := func(what string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// Writes that keep failing switch to the degraded mode
+	for i := 0; i < degradeMinFailures; i++ {
+		service.ProcessLog(fmt.Sprintf("failed %d", i))
+	}
+	waitFor("the degraded mode", func() bool { return service.Mode().Mode == types.ModeDegraded })
+	if notice := service.Mode().Notice(); !strings.Contains(notice, "disk I/O error") {
+		t.Errorf("Expected the notice to name the storage error, got %q", notice)
+	}
+
+	// Entries are then spooled and still sent to subscribers, with acknowledgements once spooled
+	acked := make(chan error, 1)
+	if err := service.ProcessLogAcked("spooled", "", "", nil, func(err error) { acked <- err }); err != nil {
+		t.Fatalf("Expected ingestion to be accepted in degraded mode, got %v", err)
+	}
+	if err := <-acked; err != nil {
+		t.Errorf("Expected the spooled entry to be acknowledged, got %v", err)
+	}
+	if stats := service.GetStats(); stats.SpooledLogs != 1 {
+		t.Errorf("Expected 1 spooled entry, got %d", stats.SpooledLogs)
+	}
+
+	// Once storage recovers, the next probe leaves the degraded mode and stores the spooled entries
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	service.ProcessLog("probe")
+	waitFor("the spooled entry to be stored", func() bool { return service.GetStats().ReplayedLogs == 1 && len(storage.GetStoredLogs()) == 2 })
+	if mode := service.Mode().Mode; mode != types.ModeNormal {
+		t.Errorf("Expected the normal mode after recovery, got %s", mode)
+	}
+
+	// Subscribers got each entry once, not again when replayed
+	time.Sleep(20 * time.Millisecond)
+	var announced []string
+	for len(subscription) > 0 {
+		announced = append(announced, (<-subscription).Message)
+	}
+	if !slices.Equal(announced, []string{"spooled", "probe"}) {
+		t.Errorf("Expected the spooled and probe entries announced once, got %q", announced)
+	}
+}
+
+func TestLogService_StoragePolicy(t *testing.T) {
+	var calls atomic.Int32
+	storage := &MockStorage{
+		searchFunc: func(query types.SearchQuery) ([]*types.LogEntry, error) {
+			if calls.Add(1) <= 2 {
+				return nil, errors.New("database is locked")
+			}
+			return []*types.LogEntry{{Message: "found"}}, nil
+		},
+	}
+	service := NewLogService(&MockParser{}, storage)
+	service.SetStoragePolicy(resilience.Config{Attempts: 3, Backoff: time.Millisecond, Threshold: 2, OpenFor: time.Minute})
+
+	// Searches failing transiently are retried
+	results, err := service.Search(types.SearchQuery{Limit: 10})
+	if err != nil || len(results) != 1 || calls.Load() != 3 {
+		t.Fatalf("Expected the search to succeed on the third attempt, got %v after %d attempts", err, calls.Load())
+	}
+
+	// Searches failing transiently in a row open the breaker, which fails them fast
+	calls.Store(-100)
+	for i := 0; i < 2; i++ {
+		if _, err := service.Search(types.SearchQuery{Limit: 10}); err == nil || errors.Is(err, interfaces.ErrStorageBusy) {
+			t.Fatalf("Expected the storage error, got %v", err)
+		}
+	}
+	attempts := calls.Load()
+	if _, err := service.Search(types.SearchQuery{Limit: 10}); !errors.Is(err, interfaces.ErrStorageBusy) {
+		t.Errorf("Expected ErrStorageBusy while the breaker is open, got %v", err)
+	}
+	if calls.Load() != attempts {
+		t.Error("Expected storage not to be searched while the breaker is open")
+	}
+
+	// Writes have a breaker of their own
+	storage.storeFunc = func(entry *types.LogEntry) error { return nil }
+	if err := service.store(&types.LogEntry{Message: "stored"}, func(error) {}); err != nil {
+		t.Errorf("Expected writes to be unaffected by the read breaker, got %v", err)
+	}
+}
+
+// MockScanStorage serves scan pages from entries with IDs 1 to n
+type MockScanStorage struct {
+	MockStorage
+	n     int64
+	pages int
+}
+
+func (m *MockScanStorage) ScanPage(beforeID int64, limit int, withRaw bool) ([]*types.LogEntry, error) {
+	m.pages++
+	if beforeID == 0 {
+		beforeID = m.n + 1
+	}
+	var page []*types.LogEntry
+	for id := beforeID - 1; id > 0 && len(page) < limit; id-- {
+		entry := &types.LogEntry{ID: id, Timestamp: time.Unix(id, 0), Message: fmt.Sprintf("request %d served", id)}
+		if withRaw {
+			entry.Raw = fmt.Sprintf("<134>1 - web api - - - %s", entry.Message)
+		}
+		page = append(page, entry)
+	}
+	return page, nil
+}
+
+func TestLogService_Scan(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.Scan(context.Background(), types.ScanQuery{}, nil, nil); err == nil {
+		t.Error("Expected error when storage does not support scans")
+	}
+
+	storage := &MockScanStorage{n: 2500}
+	service = NewLogService(&MockParser{}, storage)
+	var matched []int64
+	var reports []types.ScanProgress
+	match := func(entry *types.LogEntry) error {
+		matched = append(matched, entry.ID)
+		return nil
+	}
+	progress := func(status types.ScanProgress) error {
+		reports = append(reports, status)
+		return nil
+	}
+
+	// Entries are matched from the newest down, across pages
+	status, err := service.Scan(context.Background(), types.ScanQuery{Regex: `request \d*00 `}, match, progress)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if status.Outcome != types.ScanComplete || status.Scanned != 2500 || status.Matched != 25 || len(matched) != 25 || matched[0] != 2500 {
+		t.Errorf("Unexpected scan: %+v, matched %v", status, matched)
+	}
+	if len(reports) != 2 || reports[0].Scanned != 1000 || reports[1].LastID != 501 {
+		t.Errorf("Expected progress after each full page, got %+v", reports)
+	}
+
+	// The limit, time range and raw messages
+	matched, reports = nil, nil
+	start, end := time.Unix(100, 0), time.Unix(2000, 0)
+	status, err = service.Scan(context.Background(), types.ScanQuery{Text: "WEB API", Raw: true, StartTime: &start, EndTime: &end, Limit: 3}, match, progress)
+	if err != nil || status.Outcome != types.ScanLimit || !slices.Equal(matched, []int64{2000, 1999, 1998}) {
+		t.Errorf("Expected the limit to stop the scan, got %+v, %v (%v)", status, matched, err)
+	}
+
+	// Cancelling the context stops the scan between pages
+	ctx, cancel := context.WithCancel(context.Background())
+	storage.pages = 0
+	_, err = service.Scan(ctx, types.ScanQuery{Text: "served"}, func(*types.LogEntry) error { return nil }, func(types.ScanProgress) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || storage.pages != 1 {
+		t.Errorf("Expected the scan to stop after one page, got %v after %d pages", err, storage.pages)
+	}
+
+	// A mask hides what it replaces from the pattern
+	matched = nil
+	mask := regexp.MustCompile(`request \d+`).ReplaceAllLiteralString
+	status, err = service.Scan(context.Background(), types.ScanQuery{Regex: `request 1\d{3} `, Mask: func(message string) string { return mask(message, "***") }}, match, progress)
+	if err != nil || status.Matched != 0 || len(matched) != 0 {
+		t.Errorf("Expected no match of masked text, got %+v, %v (%v)", status, matched, err)
+	}
+
+	if _, err := service.Scan(context.Background(), types.ScanQuery{Regex: "("}, match, progress); err == nil {
+		t.Error("Expected an invalid regex to be rejected")
+	}
+}
+
+func TestLogService_TailBuffer(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.RecentBuffer(types.SearchQuery{}); !errors.Is(err, interfaces.ErrTailBufferDisabled) {
+		t.Errorf("Expected ErrTailBufferDisabled without a buffer, got %v", err)
+	}
+
+	service.SetTailBuffer(time.Minute, 0)
+	service.notifySubscribers(&types.LogEntry{ID: 1, AppName: "api", Message: "announced"})
+	result, err := service.RecentBuffer(types.SearchQuery{AppName: "api"})
+	if err != nil || len(result.Entries) != 1 || result.Buffered != 1 || result.WindowSeconds != 60 || result.Since == nil {
+		t.Fatalf("Expected the announced entry buffered, got %+v (%v)", result, err)
+	}
+	result.Entries[0].Message = "changed by the caller"
+	if result, _ = service.RecentBuffer(types.SearchQuery{}); result.Entries[0].Message != "announced" {
+		t.Errorf("Expected buffered entries copied, got %q", result.Entries[0].Message)
+	}
+
+	if _, err := service.RecentBuffer(types.SearchQuery{Collapse: true}); !errors.Is(err, interfaces.ErrInvalidBufferQuery) {
+		t.Errorf("Expected collapsing to be rejected, got %v", err)
+	}
+	if _, err := service.RecentBuffer(types.SearchQuery{Text: `"unterminated`}); !errors.Is(err, interfaces.ErrInvalidBufferQuery) {
+		t.Errorf("Expected an invalid text search to be rejected, got %v", err)
+	}
+}
+
+func TestTailBuffer_WindowAndSize(t *testing.T) {
+	buffer := newTailBuffer(time.Minute, 100)
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 150; i++ {
+		buffer.add(&types.LogEntry{ID: int64(i)}, base.Add(time.Duration(i)*100*time.Millisecond))
+	}
+	now := base.Add(15 * time.Second)
+
+	// The oldest entries made room once 100 were held
+	result, _ := buffer.search(types.SearchQuery{}, now)
+	if result.Buffered != 100 || result.Entries[0].ID != 150 || result.Entries[99].ID != 51 {
+		t.Fatalf("Expected entries 150 to 51, got %d entries from %d", result.Buffered, result.Entries[0].ID)
+	}
+	if !result.Since.Equal(base.Add(5100 * time.Millisecond)) {
+		t.Errorf("Expected the oldest held entry received at 5.1s, got %v", result.Since)
+	}
+
+	result, _ = buffer.search(types.SearchQuery{Limit: 10, Offset: 5}, now)
+	if len(result.Entries) != 10 || result.Entries[0].ID != 145 || result.Entries[9].ID != 136 {
+		t.Errorf("Expected entries 145 to 136, got %d from %d", len(result.Entries), result.Entries[0].ID)
+	}
+
+	// Entries received more than a minute ago expire
+	result, _ = buffer.search(types.SearchQuery{}, base.Add(70*time.Second))
+	if result.Buffered != 51 || result.Entries[50].ID != 100 {
+		t.Errorf("Expected the entries received in the last minute, got %d", result.Buffered)
+	}
+
+	buffer.remove(types.SearchQuery{Filters: []types.FieldFilter{{Field: "hostname", Value: ""}}})
+	if result, _ = buffer.search(types.SearchQuery{}, base.Add(70*time.Second)); result.Buffered != 0 {
+		t.Errorf("Expected deleted entries removed, got %d", result.Buffered)
+	}
+	buffer.add(&types.LogEntry{ID: 200}, base.Add(70*time.Second))
+	buffer.clear()
+	if result, _ = buffer.search(types.SearchQuery{}, base.Add(70*time.Second)); result.Buffered != 0 || result.Since != nil {
+		t.Errorf("Expected a cleared buffer, got %+v", result)
+	}
+}
+
+func TestTailBuffer_Filters(t *testing.T) {
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	entries := []*types.LogEntry{
+		{ID: 1, Timestamp: base, Facility: 1, Severity: 3, Hostname: "web-1", AppName: "api", Message: "Connection refused by upstream",
+			StructuredData: map[string]interface{}{"request@32473": map[string]interface{}{"user_id": "42"}}},
+		{ID: 2, Timestamp: base.Add(time.Minute), Facility: 1, Severity: 6, Hostname: "web-2", AppName: "api", Message: "request served in 12ms"},
+		{ID: 3, Timestamp: base.Add(2 * time.Minute), Facility: 3, Severity: 4, Hostname: "db-1", AppName: "postgres", Message: "checkpoint starting: time"},
+	}
+	entries[2].SetMetadata(types.SourceIPParam, "10.0.0.5")
+
+	buffer := newTailBuffer(time.Hour, 0)
+	for _, entry := range entries {
+		buffer.add(entry, base)
+	}
+
+	severity, minSeverity, facility := 6, 4, 3
+	start := base.Add(30 * time.Second)
+	tests := []struct {
+		name  string
+		query types.SearchQuery
+		want  []int64
+	}{
+		{"all", types.SearchQuery{}, []int64{3, 2, 1}},
+		{"severity", types.SearchQuery{Severity: &severity}, []int64{2}},
+		{"min severity", types.SearchQuery{MinSeverity: &minSeverity}, []int64{3, 1}},
+		{"facility", types.SearchQuery{Facility: &facility}, []int64{3}},
+		{"app and host", types.SearchQuery{AppName: "api", Hostname: "web-1"}, []int64{1}},
+		{"source ip", types.SearchQuery{SourceIP: "10.0.0.5"}, []int64{3}},
+		{"time range", types.SearchQuery{StartTime: &start}, []int64{3, 2}},
+		{"word", types.SearchQuery{Text: "refused"}, []int64{1}},
+		{"whole words only", types.SearchQuery{Text: "refuse"}, nil},
+		{"prefix", types.SearchQuery{Text: "refuse*"}, []int64{1}},
+		{"phrase", types.SearchQuery{Text: `"connection refused"`}, []int64{1}},
+		{"phrase in order", types.SearchQuery{Text: `"refused connection"`}, nil},
+		{"or", types.SearchQuery{Text: "refused OR checkpoint"}, []int64{3, 1}},
+		{"not", types.SearchQuery{Text: `request NOT served`}, nil},
+		{"compiled expression", types.SearchQuery{Text: `(request OR upstream) AND ("connection")`}, []int64{1}},
+		{"parameter of an element", types.SearchQuery{Filters: []types.FieldFilter{{Field: "request@32473.user_id", Value: "42"}}}, []int64{1}},
+		{"parameter of any element", types.SearchQuery{Filters: []types.FieldFilter{{Field: "user_id", Value: "42"}}}, []int64{1}},
+		{"negated filter", types.SearchQuery{Filters: []types.FieldFilter{{Field: "app_name", Value: "api", Negate: true}}}, []int64{3}},
+		{"structured data query", types.SearchQuery{StructuredDataQuery: "USER_ID"}, []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := buffer.search(tt.query, base)
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			var ids []int64
+			for _, entry := range result.Entries {
+				ids = append(ids, entry.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Expected entries %v, got %v", tt.want, ids)
+			}
+		})
+	}
+}
+
+type MockAccountStorage struct {
+	MockStorage
+	users       map[string]types.User
+	hashes      map[string]string
+	tokens      map[string]types.APIToken
+	lookups     int
+	touches     int
+	lastTokenID int64
+}
+
+func (m *MockAccountStorage) CreateUser(user *types.User, passwordHash string) error {
+	if m.users == nil {
+		m.users, m.hashes = make(map[string]types.User), make(map[string]string)
+	}
+	if _, ok := m.users[user.Username]; ok {
+		return interfaces.ErrUserExists
+	}
+	m.users[user.Username], m.hashes[user.Username] = *user, passwordHash
+	return nil
+}
+
+func (m *MockAccountStorage) Users() ([]types.User, error) {
+	var users []types.User
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *MockAccountStorage) UserCredentials(username string) (*types.User, string, error) {
+	m.lookups++
+	user, ok := m.users[username]
+	if !ok {
+		return nil, "", interfaces.ErrUserNotFound
+	}
+	return &user, m.hashes[username], nil
+}
+
+func (m *MockAccountStorage) UpdateUser(username, role, passwordHash string) (*types.User, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return nil, interfaces.ErrUserNotFound
+	}
+	if role != "" {
+		user.Role = role
+	}
+	if passwordHash != "" {
+		m.hashes[username] = passwordHash
+	}
+	m.users[username] = user
+	return &user, nil
+}
+
+func (m *MockAccountStorage) DeleteUser(username string) error {
+	if _, ok := m.users[username]; !ok {
+		return interfaces.ErrUserNotFound
+	}
+	delete(m.users, username)
+	return nil
+}
+
+func (m *MockAccountStorage) CreateAPIToken(token *types.APIToken, tokenHash string) error {
+	if m.tokens == nil {
+		m.tokens = make(map[string]types.APIToken)
+	}
+	m.lastTokenID++
+	token.ID = m.lastTokenID
+	m.tokens[tokenHash] = *token
+	return nil
+}
+
+func (m *MockAccountStorage) APITokens() ([]types.APIToken, error) {
+	var tokens []types.APIToken
+	for _, token := range m.tokens {
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+func (m *MockAccountStorage) APIToken(tokenHash string, now time.Time) (*types.APIToken, error) {
+	m.lookups++
+	token, ok := m.tokens[tokenHash]
+	if !ok || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+		return nil, interfaces.ErrAPITokenNotFound
+	}
+	return &token, nil
+}
+
+func (m *MockAccountStorage) TouchAPIToken(id int64, now time.Time) error {
+	m.touches++
+	return nil
+}
+
+func (m *MockAccountStorage) DeleteAPIToken(id int64) error {
+	for hash, token := range m.tokens {
+		if token.ID == id {
+			delete(m.tokens, hash)
+			return nil
+		}
+	}
+	return interfaces.ErrAPITokenNotFound
+}
+
+func TestLogService_Users(t *testing.T) {
+	service := NewLogService(&MockParser{}, &MockStorage{})
+	if _, err := service.CreateUser("alice", "correct horse", types.RoleAdmin); err == nil {
+		t.Error("Expected error when storage does not support accounts")
+	}
+
+	storage := &MockAccountStorage{}
+	service = NewLogService(&MockParser{}, storage)
+
+	invalid := []struct{ username, password, role string }{
+		{"", "correct horse", types.RoleAdmin},
+		{"ali:ce", "correct horse", types.RoleAdmin},
+		{strings.Repeat("a", types.MaxAccountName+1), "correct horse", types.RoleAdmin},
+		{"alice", "short", types.RoleAdmin},
+		{"alice", strings.Repeat("p", 73), types.RoleAdmin},
+		{"alice", "correct horse", "owner"},
+	}
+	for _, test := range invalid {
+		if _, err := service.CreateUser(test.username, test.password, test.role); !errors.Is(err, interfaces.ErrInvalidAccount) {
+			t.Errorf("Expected ErrInvalidAccount for %q with role %q, got %v", test.username, test.role, err)
+		}
+	}
+
+	user, err := service.CreateUser(" alice ", "correct horse", types.RoleReader)
+	if err != nil || user.Username != "alice" {
+		t.Fatalf("Expected alice created, got %+v (%v)", user, err)
+	}
+	if storage.hashes["alice"] == "correct horse" {
+		t.Error("Expected the password stored hashed")
+	}
+
+	if _, err := service.Authenticate("alice", "wrong horse"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected a wrong password rejected, got %v", err)
+	}
+	if _, err := service.Authenticate("bob", "correct horse"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected an unknown user rejected, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if user, err := service.Authenticate("alice", "correct horse"); err != nil || user.Role != types.RoleReader {
+			t.Fatalf("Expected alice authenticated, got %+v (%v)", user, err)
+		}
+	}
+	if storage.lookups != 3 {
+		t.Errorf("Expected the repeated login remembered, got %d lookups", storage.lookups)
+	}
+
+	// A changed password stops the old one at once
+	if _, err := service.UpdateUser("alice", "", ""); !errors.Is(err, interfaces.ErrInvalidAccount) {
+		t.Errorf("Expected ErrInvalidAccount without changes, got %v", err)
+	}
+	if user, err := service.UpdateUser("alice", "battery staple", types.RoleAdmin); err != nil || user.Role != types.RoleAdmin {
+		t.Fatalf("Expected alice updated, got %+v (%v)", user, err)
+	}
+	if _, err := service.Authenticate("alice", "correct horse"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected the old password rejected, got %v", err)
+	}
+	if user, err := service.Authenticate("alice", "battery staple"); err != nil || user.Role != types.RoleAdmin {
+		t.Errorf("Expected the new password and role, got %+v (%v)", user, err)
+	}
+
+	if err := service.DeleteUser("alice"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := service.Authenticate("alice", "battery staple"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected a deleted user rejected, got %v", err)
+	}
+}
+
+func TestLogService_APITokens(t *testing.T) {
+	storage := &MockAccountStorage{}
+	service := NewLogService(&MockParser{}, storage)
+
+	invalid := []struct {
+		token types.APIToken
+		ttl   time.Duration
+	}{
+		{token: types.APIToken{Role: types.RoleReader}},
+		{token: types.APIToken{Name: "ci", Role: "owner"}},
+		{token: types.APIToken{Name: "ci", Role: types.RoleReader}, ttl: time.Second},
+		{token: types.APIToken{Name: "ci", Role: types.RoleReader}, ttl: types.MaxAPITokenTTL + time.Hour},
+	}
+	for _, test := range invalid {
+		if err := service.CreateAPIToken(&test.token, test.ttl); !errors.Is(err, interfaces.ErrInvalidAccount) {
+			t.Errorf("Expected ErrInvalidAccount for %+v expiring in %s, got %v", test.token, test.ttl, err)
+		}
+	}
+
+	token := &types.APIToken{Name: "ci", Role: types.RoleReader}
+	if err := service.CreateAPIToken(token, time.Hour); err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token.Token, "ot_") || token.ExpiresAt == nil {
+		t.Errorf("Expected a token and expiry set, got %+v", token)
+	}
+	for hash := range storage.tokens {
+		if hash == token.Token {
+			t.Error("Expected the token stored hashed")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if found, err := service.AuthenticateToken(token.Token); err != nil || found.Name != "ci" {
+			t.Fatalf("Expected the token authenticated, got %+v (%v)", found, err)
+		}
+	}
+	if storage.lookups != 1 || storage.touches != 1 {
+		t.Errorf("Expected the token looked up and its use recorded once, got %d and %d", storage.lookups, storage.touches)
+	}
+	if _, err := service.AuthenticateToken("ot_unknown"); !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+		t.Errorf("Expected an unknown token rejected, got %v", err)
+	}
+
+	if err := service.DeleteAPIToken(token.ID); err != nil {
+		t.Fatalf("DeleteAPIToken failed: %v", err)
+	}
+	if _, err := service.AuthenticateToken(token.Token); !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+		t.Errorf("Expected a deleted token rejected, got %v", err)
+	}
+}
+
+// MockSettingStorage keeps settings in memory
+type MockSettingStorage struct {
+	MockStorage
+	settings map[string]string
+}
+
+func (m *MockSettingStorage) Setting(key string) (string, error) {
+	value, ok := m.settings[key]
+	if !ok {
+		return "", interfaces.ErrSettingNotFound
+	}
+	return value, nil
+}
+
+func (m *MockSettingStorage) SaveSetting(key, value string) error {
+	m.settings[key] = value
+	return nil
+}
+
+func (m *MockSettingStorage) DeleteSetting(key string) error {
+	delete(m.settings, key)
+	return nil
+}
+
+func TestLogService_UpdateRetention(t *testing.T) {
+	cleaned := make(chan int, 1)
+	storage := &MockSettingStorage{settings: map[string]string{retentionSetting: "7"}}
+	storage.cleanupFunc = func(retentionDays int) error {
+		cleaned <- retentionDays
+		return nil
+	}
+	expectCleanup := func(days int) {
+		t.Helper()
+		select {
+		case got := <-cleaned:
+			if got != days {
+				t.Errorf("Expected a cleanup of entries older than %d days, got %d", days, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected retention to run with %d days", days)
+		}
+	}
+
+	service := NewLogService(&MockParser{}, storage)
+	service.SetRetention(30)
+	if got := service.Retention(); got != (types.RetentionStatus{Days: 30, ConfiguredDays: 30}) {
+		t.Errorf("Expected the configured period before start, got %+v", got)
+	}
+
+	// The stored period takes precedence once started
+	if err := service.Start(); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer service.Stop()
+	expectCleanup(7)
+	if got := service.Retention(); got != (types.RetentionStatus{Days: 7, ConfiguredDays: 30, Overridden: true}) {
+		t.Errorf("Expected the stored period, got %+v", got)
+	}
+
+	for _, days := range []int{0, -1, types.MaxRetentionDays + 1} {
+		if _, err := service.UpdateRetention(days); !errors.Is(err, interfaces.ErrInvalidRetention) {
+			t.Errorf("Expected %d days to be rejected, got %v", days, err)
+		}
+	}
+
+	// A change is stored and applied at once
+	status, err := service.UpdateRetention(14)
+	if err != nil {
+		t.Fatalf("UpdateRetention failed: %v", err)
+	}
+	if status != (types.RetentionStatus{Days: 14, ConfiguredDays: 30, Overridden: true}) || storage.settings[retentionSetting] != "14" {
+		t.Errorf("Expected 14 days stored, got %+v and %v", status, storage.settings)
+	}
+	expectCleanup(14)
+
+	status, err = service.ResetRetention()
+	if err != nil {
+		t.Fatalf("ResetRetention failed: %v", err)
+	}
+	if _, stored := storage.settings[retentionSetting]; stored || status != (types.RetentionStatus{Days: 30, ConfiguredDays: 30}) {
+		t.Errorf("Expected the configured period back, got %+v and %v", status, storage.settings)
+	}
+	expectCleanup(30)
+}
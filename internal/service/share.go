@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// shareStore returns the storage backend keeping shares
+func (s *LogService) shareStore() (interfaces.ShareStore, error) {
+	store, ok := s.storage.(interfaces.ShareStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support shares")
+	}
+	return store, nil
+}
+
+// CreateShare validates and saves a share of entries, opened until ttl from now or for
+// types.DefaultShareTTL when ttl is zero. Shares that expired meanwhile are removed first.
+func (s *LogService) CreateShare(share *types.Share, ttl time.Duration) error {
+	store, err := s.shareStore()
+	if err != nil {
+		return err
+	}
+	if len(share.Entries) == 0 {
+		return fmt.Errorf("%w: no entries to share", interfaces.ErrInvalidShare)
+	}
+	if len(share.Entries) > types.MaxShareEntries {
+		return fmt.Errorf("%w: at most %d entries can be shared", interfaces.ErrInvalidShare, types.MaxShareEntries)
+	}
+	if len(share.Description) > types.MaxShareDescription {
+		return fmt.Errorf("%w: description must be at most %d bytes", interfaces.ErrInvalidShare, types.MaxShareDescription)
+	}
+	if ttl == 0 {
+		ttl = types.DefaultShareTTL
+	}
+	if ttl < time.Minute || ttl > types.MaxShareTTL {
+		return fmt.Errorf("%w: expiry must be between a minute and %s", interfaces.ErrInvalidShare, types.MaxShareTTL)
+	}
+
+	now := time.Now().UTC()
+	if removed, err := store.DeleteExpiredShares(now); err != nil {
+		log.Printf("Error removing expired shares: %v", err)
+	} else if removed > 0 {
+		log.Printf("Removed %d expired shares", removed)
+	}
+
+	var token [16]byte
+	rand.Read(token[:])
+	share.Token = hex.EncodeToString(token[:])
+	share.CreatedAt = now
+	share.ExpiresAt = now.Add(ttl)
+	return store.CreateShare(share)
+}
+
+// Share returns a share with its entries unless it expired
+func (s *LogService) Share(token string) (*types.Share, error) {
+	store, err := s.shareStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Share(token, time.Now())
+}
+
+// Shares lists the shares that did not expire, without their entries
+func (s *LogService) Shares() ([]types.Share, error) {
+	store, err := s.shareStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Shares(time.Now())
+}
+
+// DeleteShare removes a share before it expires
+func (s *LogService) DeleteShare(token string) error {
+	store, err := s.shareStore()
+	if err != nil {
+		return err
+	}
+	return store.DeleteShare(token)
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+// DefaultTailBufferSize is the default number of entries the tail buffer holds
+const DefaultTailBufferSize = 10000
+
+// bufferedEntry is an entry of the tail buffer with when it was announced
+type bufferedEntry struct {
+	entry    *types.LogEntry
+	received time.Time
+}
+
+// tailBuffer holds the entries announced to live tail subscribers within the last window, up to
+// maxEntries, so searches of the last minutes are answered from memory instead of storage. It is a
+// ring that grows up to maxEntries and then overwrites its oldest entries. It is safe for
+// concurrent use.
+type tailBuffer struct {
+	window     time.Duration
+	maxEntries int
+
+	mu    sync.Mutex
+	ring  []bufferedEntry
+	head  int
+	count int
+}
+
+// newTailBuffer creates a buffer holding up to maxEntries entries for window
+func newTailBuffer(window time.Duration, maxEntries int) *tailBuffer {
+	if maxEntries <= 0 {
+		maxEntries = DefaultTailBufferSize
+	}
+	return &tailBuffer{window: window, maxEntries: maxEntries}
+}
+
+// at returns the i-th buffered entry, oldest first; the caller holds mu
+func (b *tailBuffer) at(i int) *bufferedEntry {
+	return &b.ring[(b.head+i)%len(b.ring)]
+}
+
+// expire drops the entries announced before the window; the caller holds mu
+func (b *tailBuffer) expire(now time.Time) {
+	cutoff := now.Add(-b.window)
+	for b.count > 0 && b.at(0).received.Before(cutoff) {
+		*b.at(0) = bufferedEntry{}
+		b.head = (b.head + 1) % len(b.ring)
+		b.count--
+	}
+}
+
+// add buffers an announced entry, returning how many entries are held
+func (b *tailBuffer) add(entry *types.LogEntry, now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire(now)
+	if b.count == len(b.ring) {
+		if len(b.ring) == b.maxEntries {
+			// Full: the oldest entry makes room
+			*b.at(0) = bufferedEntry{entry: entry, received: now}
+			b.head = (b.head + 1) % len(b.ring)
+			return b.count
+		}
+		grown := make([]bufferedEntry, min(max(2*len(b.ring), 64), b.maxEntries))
+		for i := 0; i < b.count; i++ {
+			grown[i] = *b.at(i)
+		}
+		b.ring, b.head = grown, 0
+	}
+	*b.at(b.count) = bufferedEntry{entry: entry, received: now}
+	b.count++
+	return b.count
+}
+
+// search returns copies of the buffered entries matching a query, the last announced first, with
+// the query's offset and limit applied
+func (b *tailBuffer) search(query types.SearchQuery, now time.Time) (*types.RecentBuffer, error) {
+	if query.Collapse {
+		return nil, fmt.Errorf("%w: collapsing repeated messages is not supported", interfaces.ErrInvalidBufferQuery)
+	}
+	matches, err := entryMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire(now)
+	result := &types.RecentBuffer{
+		Entries:       []*types.LogEntry{},
+		Buffered:      b.count,
+		WindowSeconds: int64(b.window / time.Second),
+	}
+	if b.count > 0 {
+		since := b.at(0).received
+		result.Since = &since
+	}
+	skipped := 0
+	for i := b.count - 1; i >= 0; i-- {
+		entry := b.at(i).entry
+		if !matches(entry) {
+			continue
+		}
+		if skipped < query.Offset {
+			skipped++
+			continue
+		}
+		copied := *entry
+		result.Entries = append(result.Entries, &copied)
+		if query.Limit > 0 && len(result.Entries) >= query.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// remove drops the buffered entries matching a query, after they were deleted from storage
+func (b *tailBuffer) remove(query types.SearchQuery) {
+	matches, err := entryMatcher(query)
+	if err != nil {
+		// Storage would not have deleted anything either
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := 0
+	for i := 0; i < b.count; i++ {
+		if buffered := *b.at(i); !matches(buffered.entry) {
+			*b.at(kept) = buffered
+			kept++
+		}
+	}
+	for i := kept; i < b.count; i++ {
+		*b.at(i) = bufferedEntry{}
+	}
+	b.count = kept
+}
+
+// clear drops every buffered entry, after entries were rewritten in storage
+func (b *tailBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ring, b.head, b.count = nil, 0, 0
+}
+
+// entryMatcher returns the function matching entries against the filters of a search query, as
+// storage applies them
+func entryMatcher(query types.SearchQuery) (func(*types.LogEntry) bool, error) {
+	var text textMatcher
+	if query.Text != "" {
+		var err error
+		if text, err = compileText(query.Text); err != nil {
+			return nil, fmt.Errorf("%w: %v", interfaces.ErrInvalidBufferQuery, err)
+		}
+	}
+	structuredDataQuery := strings.ToLower(query.StructuredDataQuery)
+
+	return func(entry *types.LogEntry) bool {
+		switch {
+		case query.Facility != nil && entry.Facility != *query.Facility,
+			query.Severity != nil && entry.Severity != *query.Severity,
+			query.MinSeverity != nil && entry.Severity > *query.MinSeverity,
+			query.MaxSeverity != nil && entry.Severity < *query.MaxSeverity,
+			query.Hostname != "" && entry.Hostname != query.Hostname,
+			query.AppName != "" && entry.AppName != query.AppName,
+			query.ProcID != "" && entry.ProcID != query.ProcID,
+			query.MsgID != "" && entry.MsgID != query.MsgID,
+			query.SourceIP != "" && entry.Metadata(types.SourceIPParam) != query.SourceIP,
+			query.StartTime != nil && entry.Timestamp.Before(*query.StartTime),
+			query.EndTime != nil && entry.Timestamp.After(*query.EndTime):
+			return false
+		}
+		for _, filter := range query.Filters {
+			if fieldMatches(entry, filter.Field, filter.Value) == filter.Negate {
+				return false
+			}
+		}
+		if structuredDataQuery != "" {
+			// Storage matches the JSON of the structured data with LIKE, which ignores ASCII case
+			data, _ := json.Marshal(entry.StructuredData)
+			if !strings.Contains(strings.ToLower(string(data)), structuredDataQuery) {
+				return false
+			}
+		}
+		return text == nil || text.matches(textWords(entry.Message))
+	}, nil
+}
+
+// fieldMatches reports whether a field of an entry equals value: a column, a parameter of a
+// structured data element (sdid.param) or a parameter of any element
+func fieldMatches(entry *types.LogEntry, field, value string) bool {
+	switch field {
+	case "hostname":
+		return entry.Hostname == value
+	case "app_name":
+		return entry.AppName == value
+	case "proc_id":
+		return entry.ProcID == value
+	case "msg_id":
+		return entry.MsgID == value
+	case types.SourceIPParam:
+		return entry.Metadata(types.SourceIPParam) == value
+	}
+	if sdID, param, ok := strings.Cut(field, "."); ok {
+		return structuredParam(entry.StructuredData[sdID], param, value)
+	}
+	for _, element := range entry.StructuredData {
+		if structuredParam(element, field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// structuredParam reports whether a structured data element has a parameter equal to value
+func structuredParam(element interface{}, param, value string) bool {
+	var actual interface{}
+	var found bool
+	switch params := element.(type) {
+	case map[string]interface{}:
+		actual, found = params[param]
+	case map[string]string:
+		actual, found = params[param]
+	}
+	return found && fmt.Sprint(actual) == value
+}
+
+// SetTailBuffer keeps the entries announced within the last window in memory, up to maxEntries (0
+// uses the default), for RecentBuffer. A window of 0 disables the buffer. Call it before Start.
+func (s *LogService) SetTailBuffer(window time.Duration, maxEntries int) {
+	if window <= 0 {
+		s.tailBuffer = nil
+		return
+	}
+	s.tailBuffer = newTailBuffer(window, maxEntries)
+}
+
+// RecentBuffer searches the entries announced within the tail buffer window, without touching
+// storage
+func (s *LogService) RecentBuffer(query types.SearchQuery) (*types.RecentBuffer, error) {
+	if s.tailBuffer == nil {
+		return nil, interfaces.ErrTailBufferDisabled
+	}
+	return s.tailBuffer.search(query, time.Now())
+}
+
+// bufferEntry adds an announced entry to the tail buffer, if enabled
+func (s *LogService) bufferEntry(entry *types.LogEntry) {
+	if s.tailBuffer == nil {
+		return
+	}
+	held := s.tailBuffer.add(entry, time.Now())
+	metrics.GetMemoryMetrics().Held.WithLabelValues(metrics.MemoryTailBuffer).Set(float64(int64(held) * s.memory.averageCost()))
+}
+
+// clearTailBuffer drops every buffered entry, after entries were rewritten in storage
+func (s *LogService) clearTailBuffer() {
+	if s.tailBuffer != nil {
+		s.tailBuffer.clear()
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// textMatcher evaluates the full-text syntax of searches against a message in memory, the way the
+// FTS5 index of the storage does: bare words and quoted phrases match whole words, case-insensitively,
+// a trailing * matches words by prefix, and terms combine with AND (also implied between terms), OR,
+// NOT and parentheses. NOT binds tighter than AND, which binds tighter than OR.
+type textMatcher interface {
+	matches(words []string) bool
+}
+
+// phraseMatch matches consecutive words, the last one only by prefix if prefix is set
+type phraseMatch struct {
+	words  []string
+	prefix bool
+}
+
+func (p phraseMatch) matches(words []string) bool {
+	if len(p.words) == 0 {
+		return false
+	}
+	for start := 0; start+len(p.words) <= len(words); start++ {
+		matched := true
+		for i, word := range p.words {
+			candidate := words[start+i]
+			if candidate != word && !(p.prefix && i == len(p.words)-1 && strings.HasPrefix(candidate, word)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// operatorMatch combines two matchers with AND, OR or NOT
+type operatorMatch struct {
+	operator    string
+	left, right textMatcher
+}
+
+func (o operatorMatch) matches(words []string) bool {
+	switch o.operator {
+	case "OR":
+		return o.left.matches(words) || o.right.matches(words)
+	case "NOT":
+		return o.left.matches(words) && !o.right.matches(words)
+	default:
+		return o.left.matches(words) && o.right.matches(words)
+	}
+}
+
+// textWords splits text into lowercase words as the unicode61 tokenizer does: runs of letters and
+// digits, everything else separating them
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+}
+
+// textToken is a parenthesis, operator, bare word or quoted phrase of a text search
+type textToken struct {
+	value  string
+	quoted bool
+}
+
+// tokenizeText splits a text search into tokens
+func tokenizeText(text string) ([]textToken, error) {
+	var tokens []textToken
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, textToken{value: string(r)})
+			i++
+		case r == '"':
+			// Quotes within a phrase are doubled
+			var phrase strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated quote in text search")
+				}
+				if runes[i] == '"' {
+					if i+1 < len(runes) && runes[i+1] == '"' {
+						phrase.WriteRune('"')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				phrase.WriteRune(runes[i])
+				i++
+			}
+			token := textToken{value: phrase.String(), quoted: true}
+			if i < len(runes) && runes[i] == '*' {
+				token.value += "*"
+				i++
+			}
+			tokens = append(tokens, token)
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, textToken{value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+// textParser builds a textMatcher from tokens by recursive descent
+type textParser struct {
+	tokens []textToken
+	pos    int
+}
+
+// compileText compiles a text search into a matcher
+func compileText(text string) (textMatcher, error) {
+	tokens, err := tokenizeText(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &textParser{tokens: tokens}
+	matcher, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q in text search", tokens[parser.pos].value)
+	}
+	return matcher, nil
+}
+
+// operator reports whether the next token is the unquoted operator op
+func (p *textParser) operator(op string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].value == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *textParser) or() (textMatcher, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.operator("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = operatorMatch{operator: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *textParser) and() (textMatcher, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) {
+		next := p.tokens[p.pos]
+		if !next.quoted && (next.value == ")" || next.value == "OR") {
+			break
+		}
+		p.operator("AND")
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = operatorMatch{operator: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *textParser) not() (textMatcher, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.operator("NOT") {
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		left = operatorMatch{operator: "NOT", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *textParser) primary() (textMatcher, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("incomplete text search")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	if !token.quoted {
+		switch token.value {
+		case "(":
+			matcher, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if !p.operator(")") {
+				return nil, fmt.Errorf("unbalanced parentheses in text search")
+			}
+			return matcher, nil
+		case ")", "AND", "OR", "NOT":
+			return nil, fmt.Errorf("unexpected %q in text search", token.value)
+		}
+	}
+	prefix := strings.HasSuffix(token.value, "*")
+	words := textWords(strings.TrimSuffix(token.value, "*"))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty term in text search")
+	}
+	return phraseMatch{words: words, prefix: prefix}, nil
+}
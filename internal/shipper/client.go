@@ -0,0 +1,439 @@
+package shipper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultQueueSize is the number of lines buffered while the server is unreachable
+	DefaultQueueSize = 10000
+
+	// dialTimeout bounds connecting and the HELLO exchange
+	dialTimeout = 5 * time.Second
+	// writeTimeout bounds a single line write
+	writeTimeout = 10 * time.Second
+	// retryDelay is how long to wait before reconnecting after a failure
+	retryDelay = 2 * time.Second
+	// drainTimeout bounds how long a draining server gets to ingest the lines already sent
+	drainTimeout = 30 * time.Second
+	// missedKeepAlives is how many server keepalives may be missed before the server is presumed dead
+	missedKeepAlives = 3
+)
+
+// ErrClosed is returned when sending on a closed client
+var ErrClosed = errors.New("shipper client is closed")
+
+// Client forwards log lines to a TCP ingestion listener using the control protocol, reconnecting
+// when the server drains or the connection fails. Lines are buffered while disconnected, and Send
+// blocks when the buffer is full.
+type Client struct {
+	addr  string
+	dial  func(network, address string) (net.Conn, error)
+	queue chan string
+
+	closeMux sync.RWMutex
+	closed   bool
+	done     chan struct{}
+	// abort ends forwarding when Close gives up waiting for the buffer to be flushed
+	abort     chan struct{}
+	abortOnce sync.Once
+
+	// pending is a line whose write failed, sent first after reconnecting
+	pending string
+	sent    atomic.Int64
+
+	// sequencer, if set, numbers the lines sent
+	sequencer *sequencer
+	// keyer, if set, gives the lines sent idempotency keys
+	keyer *keyer
+	// spool, if set, holds the lines on disk until the server acknowledges them, instead of queue
+	spool *spool
+}
+
+// sessionEnd describes why a connection ended
+type sessionEnd struct {
+	finished       bool
+	reconnectAfter time.Duration
+}
+
+// NewClient creates a client forwarding to addr (host:port), buffering up to queueSize lines, and
+// starts connecting in the background
+func NewClient(addr string, queueSize int) *Client {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	client := newClient(addr, queueSize)
+	go client.run()
+	return client
+}
+
+// NewSpoolingClient creates a client forwarding to addr (host:port) that keeps lines in a spool in
+// dir until the server acknowledges storing them, so no line is lost when the shipper restarts or
+// the server is down. Lines left unacknowledged by a previous client are sent first. maxBytes
+// bounds the spool's size, 0 leaves it unbounded; beyond it the oldest lines are dropped.
+func NewSpoolingClient(addr, dir string, maxBytes int64) (*Client, error) {
+	spool, err := openSpool(dir, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(addr, 0)
+	client.spool = spool
+	go client.run()
+	return client, nil
+}
+
+func newClient(addr string, queueSize int) *Client {
+	return &Client{
+		addr: addr,
+		dial: func(network, address string) (net.Conn, error) {
+			return net.DialTimeout(network, address, dialTimeout)
+		},
+		queue: make(chan string, queueSize),
+		done:  make(chan struct{}),
+		abort: make(chan struct{}),
+	}
+}
+
+// Send queues a line for forwarding, waiting while the buffer is full until ctx is done, or
+// appends it to the spool. Line breaks within the line are replaced by spaces, as the protocol is
+// newline-delimited.
+func (c *Client) Send(ctx context.Context, line string) error {
+	line = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(strings.TrimRight(line, "\r\n"))
+	if line == "" {
+		return nil
+	}
+	if c.keyer != nil {
+		line = c.keyer.key(line)
+	}
+
+	c.closeMux.RLock()
+	defer c.closeMux.RUnlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if c.spool != nil {
+		if c.sequencer == nil {
+			return c.spool.append(line)
+		}
+		c.sequencer.mu.Lock()
+		defer c.sequencer.mu.Unlock()
+		line, unnumber := c.sequencer.number(line)
+		if err := c.spool.append(line); err != nil {
+			unnumber()
+			return err
+		}
+		return nil
+	}
+	if c.sequencer != nil {
+		// Lines are queued in the order they are numbered
+		c.sequencer.mu.Lock()
+		defer c.sequencer.mu.Unlock()
+		var unnumber func()
+		line, unnumber = c.sequencer.number(line)
+		select {
+		case c.queue <- line:
+			return nil
+		case <-ctx.Done():
+			unnumber()
+			return ctx.Err()
+		}
+	}
+	select {
+	case c.queue <- line:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NumberLines makes the client add an RFC5424 meta sequenceId to every RFC5424 line it sends,
+// counting per hostname and app name, so the server can detect lines lost on the way. It must be
+// called before the first Send.
+func (c *Client) NumberLines() {
+	c.sequencer = newSequencer()
+}
+
+// KeyLines makes the client add an idempotency key to every RFC5424 line it sends, so the server
+// does not store a line twice when it is sent again because its acknowledgement was lost. A spooled
+// line keeps its key across restarts of the shipper. It must be called before the first Send.
+func (c *Client) KeyLines() {
+	c.keyer = newKeyer()
+}
+
+// Sent returns the number of lines written to the server
+func (c *Client) Sent() int64 {
+	return c.sent.Load()
+}
+
+// SpoolStats describes the client's spool, or returns false for a client without one
+func (c *Client) SpoolStats() (SpoolStats, bool) {
+	if c.spool == nil {
+		return SpoolStats{}, false
+	}
+	return c.spool.stats(), true
+}
+
+// Close stops accepting lines, forwards the buffered ones and closes the connection once the
+// server has read them, or for a spooling client acknowledged them. It gives up when ctx is done,
+// leaving unacknowledged lines in the spool for the next client.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeMux.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.closeMux.Unlock()
+	if c.spool != nil {
+		c.spool.notify()
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.abortOnce.Do(func() { close(c.abort) })
+		<-c.done
+		return fmt.Errorf("shipper client did not flush before closing: %w", ctx.Err())
+	}
+}
+
+// flushed reports whether the client is closed with no line left to send, or to be acknowledged
+func (c *Client) flushed() bool {
+	if c.spool != nil {
+		return c.isClosed() && c.spool.stats().Pending == 0
+	}
+	c.closeMux.RLock()
+	defer c.closeMux.RUnlock()
+	return c.closed && len(c.queue) == 0 && c.pending == ""
+}
+
+// isClosed reports whether Close was called
+func (c *Client) isClosed() bool {
+	c.closeMux.RLock()
+	defer c.closeMux.RUnlock()
+	return c.closed
+}
+
+// wait sleeps for delay, returning false if forwarding was aborted meanwhile
+func (c *Client) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.abort:
+		return false
+	}
+}
+
+// run connects and forwards lines until the client is closed and its buffer is flushed
+func (c *Client) run() {
+	defer close(c.done)
+	if c.spool != nil {
+		defer c.spool.close()
+	}
+	for {
+		if c.flushed() {
+			return
+		}
+		conn, reader, hello, err := c.connect()
+		if err != nil {
+			log.Printf("Failed to connect to %s, retrying in %v: %v", c.addr, retryDelay, err)
+			if !c.wait(retryDelay) {
+				return
+			}
+			continue
+		}
+
+		end := c.session(conn, reader, hello)
+		conn.Close()
+		if end.finished || !c.wait(end.reconnectAfter) {
+			return
+		}
+	}
+}
+
+// connect dials the server and exchanges HELLOs
+func (c *Client) connect() (net.Conn, *bufio.Reader, ServerHello, error) {
+	conn, err := c.dial("tcp", c.addr)
+	if err != nil {
+		return nil, nil, ServerHello{}, err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := fmt.Fprintf(conn, "%s\n", ClientHello{Ack: c.spool != nil}); err != nil {
+		conn.Close()
+		return nil, nil, ServerHello{}, fmt.Errorf("failed to send HELLO: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, ServerHello{}, fmt.Errorf("failed to read server HELLO: %w", err)
+	}
+	hello, err := ParseServerHello(line)
+	if err != nil {
+		conn.Close()
+		return nil, nil, ServerHello{}, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, reader, hello, nil
+}
+
+// session forwards lines over one connection until it drains, fails or the client is closed
+func (c *Client) session(conn net.Conn, reader *bufio.Reader, hello ServerHello) sessionEnd {
+	// A spooling client resends every line not acknowledged yet, tracking which spool line each line
+	// of this connection is
+	var spooled <-chan spooledLine
+	var sent *sentLines
+	queue := c.queue
+	if c.spool != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		acked, _, _ := c.spool.position()
+		spooled = c.feed(acked, stop)
+		sent = &sentLines{}
+		queue = nil
+	}
+
+	// Control lines from the server; the reader ends when the connection is closed
+	drains := make(chan time.Duration, 1)
+	eof := make(chan struct{})
+	var lastHeard atomic.Int64
+	lastHeard.Store(time.Now().UnixNano())
+	go func() {
+		defer close(eof)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lastHeard.Store(time.Now().UnixNano())
+			if reconnectAfter, ok := ParseDrain(line); ok {
+				select {
+				case drains <- reconnectAfter:
+				default:
+				}
+			}
+			if count, ok := ParseAck(line); ok && sent != nil && hello.Ack {
+				c.acknowledge(sent, count)
+			}
+		}
+	}()
+
+	interval := hello.KeepAlive
+	if interval <= 0 {
+		interval = hello.IdleTimeout / 3
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+
+	write := func(line string) bool {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+			log.Printf("Failed to write to %s, reconnecting: %v", c.addr, err)
+			return false
+		}
+		lastWrite = time.Now()
+		return true
+	}
+
+	if c.pending != "" {
+		if !write(c.pending) {
+			return sessionEnd{reconnectAfter: retryDelay}
+		}
+		c.pending = ""
+		c.sent.Add(1)
+	}
+
+	for {
+		select {
+		case line, ok := <-queue:
+			if !ok {
+				// Closed with the buffer flushed: let the server read everything before closing
+				c.finish(conn, eof)
+				return sessionEnd{finished: true}
+			}
+			if !write(line) {
+				c.pending = line
+				return sessionEnd{reconnectAfter: retryDelay}
+			}
+			c.sent.Add(1)
+
+		case line, ok := <-spooled:
+			if !ok {
+				// Closed with every line sent: the server acknowledges the rest before closing
+				c.finish(conn, eof)
+				return sessionEnd{finished: c.flushed(), reconnectAfter: retryDelay}
+			}
+			if !write(line.text) {
+				return sessionEnd{reconnectAfter: retryDelay}
+			}
+			c.sent.Add(1)
+			sent.add(line.number)
+			if !hello.Ack {
+				// The server does not acknowledge lines, so a line written is as good as it gets
+				c.acknowledge(sent, sent.sent)
+			}
+
+		case reconnectAfter := <-drains:
+			log.Printf("Server %s is draining, reconnecting in %v", c.addr, reconnectAfter)
+			c.finish(conn, eof)
+			return sessionEnd{reconnectAfter: reconnectAfter}
+
+		case <-eof:
+			log.Printf("Connection to %s closed, reconnecting", c.addr)
+			return sessionEnd{reconnectAfter: retryDelay}
+
+		case <-c.abort:
+			return sessionEnd{finished: true}
+
+		case <-ticker.C:
+			if hello.KeepAlive > 0 && time.Since(time.Unix(0, lastHeard.Load())) > missedKeepAlives*hello.KeepAlive {
+				log.Printf("No keepalive from %s, reconnecting", c.addr)
+				return sessionEnd{reconnectAfter: retryDelay}
+			}
+			if time.Since(lastWrite) >= interval && !write(KeepAlive) {
+				return sessionEnd{reconnectAfter: retryDelay}
+			}
+		}
+	}
+}
+
+// acknowledge trims the spool of the first count lines sent on a connection
+func (c *Client) acknowledge(sent *sentLines, count int64) {
+	through, ok := sent.through(count)
+	if !ok {
+		return
+	}
+	if err := c.spool.ack(through); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// finish closes the client's side of the connection and waits for the server to close its side,
+// which it does after ingesting every line sent
+func (c *Client) finish(conn net.Conn, eof <-chan struct{}) {
+	if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok {
+		halfCloser.CloseWrite()
+	} else {
+		conn.Close()
+	}
+	select {
+	case <-eof:
+	case <-time.After(drainTimeout):
+		log.Printf("Server %s did not close the connection after draining", c.addr)
+	}
+}
@@ -0,0 +1,143 @@
+package shipper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts shipper connections, recording every log line and draining the first
+// connection after drainAfter lines
+type fakeServer struct {
+	listener   net.Listener
+	drainAfter int
+
+	mutex       sync.Mutex
+	lines       []string
+	connections int
+}
+
+func newFakeServer(t *testing.T, drainAfter int) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fakeServer{listener: listener, drainAfter: drainAfter}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.connections++
+		first := s.connections == 1
+		s.mutex.Unlock()
+		go s.handle(conn, first)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn, first bool) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	received := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == Hello:
+			fmt.Fprintf(conn, "%s\n", ServerHello{IdleTimeout: time.Second, KeepAlive: 50 * time.Millisecond})
+		case IsControl(line):
+		default:
+			s.mutex.Lock()
+			s.lines = append(s.lines, line)
+			s.mutex.Unlock()
+			received++
+			if first && received == s.drainAfter {
+				fmt.Fprintf(conn, "%s\n", DrainNotice(10*time.Millisecond))
+			}
+		}
+	}
+}
+
+func TestClient_ReconnectsAfterDrain(t *testing.T) {
+	server := newFakeServer(t, 3)
+	client := NewClient(server.listener.Addr().String(), 100)
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		if err := client.Send(ctx, fmt.Sprintf("line %d\n", i)); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Close(closeCtx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := client.Send(ctx, "too late"); err != ErrClosed {
+		t.Errorf("Expected ErrClosed after closing, got %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.connections < 2 {
+		t.Errorf("Expected the client to reconnect after the drain, got %d connections", server.connections)
+	}
+	if len(server.lines) != 20 || client.Sent() != 20 {
+		t.Fatalf("Expected 20 lines to arrive, got %d (sent %d)", len(server.lines), client.Sent())
+	}
+	for i, line := range server.lines {
+		if line != fmt.Sprintf("line %d", i) {
+			t.Errorf("Expected line %d in order, got %q", i, line)
+		}
+	}
+}
+
+func TestClient_BuffersWhileUnreachable(t *testing.T) {
+	// Reserve an address, then only start listening on it after lines are queued
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := NewClient(addr, 10)
+	client.Send(context.Background(), "multi\nline")
+
+	server := &fakeServer{}
+	server.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Address %s was taken meanwhile: %v", addr, err)
+	}
+	defer server.listener.Close()
+	go server.serve()
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(closeCtx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.lines) != 1 || server.lines[0] != "multi line" {
+		t.Errorf("Expected the buffered line with its line break replaced, got %q", server.lines)
+	}
+}
@@ -0,0 +1,46 @@
+package shipper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// keyer gives RFC5424 lines an opentrail idempotency_key parameter unique to the line, so the server
+// does not store a line twice when it is sent again after a lost acknowledgement
+type keyer struct {
+	// prefix tells the keys of this keyer from those of other shippers and earlier runs
+	prefix string
+	next   atomic.Int64
+}
+
+func newKeyer() *keyer {
+	var random [8]byte
+	rand.Read(random[:])
+	return &keyer{prefix: hex.EncodeToString(random[:])}
+}
+
+// key adds the next key to an RFC5424 line. Lines that are not RFC5424 or already carry an
+// opentrail element are returned unchanged.
+func (k *keyer) key(line string) string {
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+	fields := strings.SplitN(line, " ", 7)
+	if len(fields) < 7 || !strings.HasPrefix(fields[0], "<") || !strings.HasSuffix(fields[0], ">1") {
+		return line
+	}
+	sd := fields[6]
+	switch {
+	case sd == "-" || strings.HasPrefix(sd, "- "):
+		sd = sd[1:]
+	case strings.HasPrefix(sd, "[opentrail ") || strings.Contains(sd, "][opentrail "):
+		return line
+	case !strings.HasPrefix(sd, "["):
+		return line
+	}
+
+	key := k.prefix + "-" + strconv.FormatInt(k.next.Add(1), 10)
+	fields[6] = `[opentrail idempotency_key="` + key + `"]` + sd
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,41 @@
+package shipper
+
+import (
+	"testing"
+)
+
+func TestKeyer_Key(t *testing.T) {
+	k := newKeyer()
+	k.prefix = "abc"
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			"<34>1 2023-10-15T10:30:00Z web01 app - - - user logged in",
+			`<34>1 2023-10-15T10:30:00Z web01 app - - [opentrail idempotency_key="abc-1"] user logged in`,
+		},
+		{
+			`<34>1 2023-10-15T10:30:01Z web01 app 12 login [user id="7"] again`,
+			`<34>1 2023-10-15T10:30:01Z web01 app 12 login [opentrail idempotency_key="abc-2"][user id="7"] again`,
+		},
+		{
+			`<34>1 2023-10-15T10:30:02Z web01 app - - [opentrail idempotency_key="mine"] keyed by the sender`,
+			`<34>1 2023-10-15T10:30:02Z web01 app - - [opentrail idempotency_key="mine"] keyed by the sender`,
+		},
+		{
+			"<34>Oct 15 10:30:03 web01 app: not RFC5424",
+			"<34>Oct 15 10:30:03 web01 app: not RFC5424",
+		},
+	}
+	for _, tt := range tests {
+		if got := k.key(tt.line); got != tt.want {
+			t.Errorf("key(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	// Keys of another shipper do not collide
+	if newKeyer().prefix == newKeyer().prefix {
+		t.Error("Expected every keyer to have its own prefix")
+	}
+}
@@ -0,0 +1,169 @@
+// Package shipper implements the optional control protocol of the TCP ingestion listener and a
+// client that forwards log lines with it.
+//
+// A shipper opts in by sending a HELLO line as the first line of a connection; senders that do not
+// are treated as plain newline-delimited syslog senders. Control lines start with "OPENTRAIL/1 ":
+//
+//	client: OPENTRAIL/1 HELLO
+//	server: OPENTRAIL/1 HELLO idle_timeout=30s max_lifetime=0s keepalive=10s
+//	either: OPENTRAIL/1 KEEPALIVE
+//	server: OPENTRAIL/1 DRAIN reconnect_after=1s
+//	server: OPENTRAIL/1 ACK lines=42
+//
+// The server answers HELLO with its connection limits and then sends a KEEPALIVE every keepalive
+// interval, so a shipper can tell a dead server from a quiet one. A shipper sends KEEPALIVE when it
+// has nothing else to send, so its connection is not closed as idle. Before the server shuts down,
+// is upgraded or closes a connection at the end of its lifetime, it sends DRAIN: the shipper stops
+// writing and closes its side of the connection, the server ingests every line already sent and
+// closes the connection, and the shipper reconnects after reconnect_after. No line is lost.
+//
+// A shipper that sends "HELLO ack=1" asks to be told which lines are stored. If the server supports
+// it, it answers with ack=1 in its HELLO and from then on sends ACK with the number of lines of the
+// connection stored so far, counted from its first line after the HELLO and always in order. When a
+// line cannot be stored, the server closes the connection so the shipper sends the unacknowledged
+// lines again; before closing a connection the shipper ended, it acknowledges the lines stored.
+package shipper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Control line prefix and commands
+const (
+	Prefix = "OPENTRAIL/1 "
+
+	Hello     = Prefix + "HELLO"
+	KeepAlive = Prefix + "KEEPALIVE"
+	Drain     = Prefix + "DRAIN"
+	Ack       = Prefix + "ACK"
+)
+
+// ClientHello is the HELLO a shipper opens a connection with
+type ClientHello struct {
+	// Ack asks the server to acknowledge the lines it stored
+	Ack bool
+}
+
+// String formats the hello as a control line, without the trailing newline
+func (h ClientHello) String() string {
+	if h.Ack {
+		return Hello + " ack=1"
+	}
+	return Hello
+}
+
+// ParseClientHello parses a shipper's HELLO line, reporting whether the line is one
+func ParseClientHello(line string) (ClientHello, bool) {
+	params, ok := command(line, Hello)
+	if !ok {
+		return ClientHello{}, false
+	}
+	return ClientHello{Ack: params["ack"] == "1"}, true
+}
+
+// ServerHello is the server's answer to a HELLO, describing the connection's limits
+type ServerHello struct {
+	// IdleTimeout is how long the connection may send nothing before it is closed
+	IdleTimeout time.Duration
+	// MaxLifetime is how long after being accepted the connection is drained, zero if unlimited
+	MaxLifetime time.Duration
+	// KeepAlive is the interval of the server's keepalives
+	KeepAlive time.Duration
+	// Ack is set when the server acknowledges the lines it stored
+	Ack bool
+}
+
+// String formats the hello as a control line, without the trailing newline
+func (h ServerHello) String() string {
+	line := fmt.Sprintf("%s idle_timeout=%s max_lifetime=%s keepalive=%s", Hello, h.IdleTimeout, h.MaxLifetime, h.KeepAlive)
+	if h.Ack {
+		line += " ack=1"
+	}
+	return line
+}
+
+// AckNotice formats an ACK control line acknowledging the first lines of a connection
+func AckNotice(lines int64) string {
+	return fmt.Sprintf("%s lines=%d", Ack, lines)
+}
+
+// ParseAck parses an ACK line, returning the number of lines acknowledged
+func ParseAck(line string) (int64, bool) {
+	params, ok := command(line, Ack)
+	if !ok {
+		return 0, false
+	}
+	lines, err := strconv.ParseInt(params["lines"], 10, 64)
+	if err != nil || lines < 0 {
+		return 0, false
+	}
+	return lines, true
+}
+
+// DrainNotice formats a DRAIN control line asking the shipper to reconnect after a delay
+func DrainNotice(reconnectAfter time.Duration) string {
+	return fmt.Sprintf("%s reconnect_after=%s", Drain, reconnectAfter)
+}
+
+// IsControl reports whether a line is a control line
+func IsControl(line string) bool {
+	return strings.HasPrefix(line, Prefix)
+}
+
+// ParseServerHello parses the server's HELLO line, ignoring parameters it does not know
+func ParseServerHello(line string) (ServerHello, error) {
+	var hello ServerHello
+	params, ok := command(line, Hello)
+	if !ok {
+		return hello, fmt.Errorf("expected a HELLO from the server, got %q", line)
+	}
+	fields := map[string]*time.Duration{
+		"idle_timeout": &hello.IdleTimeout,
+		"max_lifetime": &hello.MaxLifetime,
+		"keepalive":    &hello.KeepAlive,
+	}
+	hello.Ack = params["ack"] == "1"
+	for key, value := range params {
+		field, known := fields[key]
+		if !known {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return hello, fmt.Errorf("invalid %s in server HELLO: %w", key, err)
+		}
+		*field = duration
+	}
+	return hello, nil
+}
+
+// ParseDrain parses a DRAIN line, returning the delay before reconnecting
+func ParseDrain(line string) (time.Duration, bool) {
+	params, ok := command(line, Drain)
+	if !ok {
+		return 0, false
+	}
+	reconnectAfter, err := time.ParseDuration(params["reconnect_after"])
+	if err != nil || reconnectAfter < 0 {
+		reconnectAfter = 0
+	}
+	return reconnectAfter, true
+}
+
+// command splits a control line into its key=value parameters if it is the given command
+func command(line, name string) (map[string]string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if line != name && !strings.HasPrefix(line, name+" ") {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for _, field := range strings.Fields(strings.TrimPrefix(line, name)) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			params[key] = value
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,87 @@
+package shipper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerHello_RoundTrip(t *testing.T) {
+	hello := ServerHello{IdleTimeout: 30 * time.Second, MaxLifetime: time.Hour, KeepAlive: 10 * time.Second}
+	line := hello.String()
+	if line != "OPENTRAIL/1 HELLO idle_timeout=30s max_lifetime=1h0m0s keepalive=10s" {
+		t.Errorf("Unexpected hello line %q", line)
+	}
+	if !IsControl(line) {
+		t.Error("Expected the hello to be a control line")
+	}
+
+	parsed, err := ParseServerHello(line + "\r\n")
+	if err != nil {
+		t.Fatalf("Failed to parse hello: %v", err)
+	}
+	if parsed != hello {
+		t.Errorf("Expected %+v, got %+v", hello, parsed)
+	}
+
+	// Unknown parameters are ignored so servers can add limits
+	parsed, err = ParseServerHello(Hello + " keepalive=5s max_batch=100")
+	if err != nil || parsed.KeepAlive != 5*time.Second {
+		t.Errorf("Expected unknown parameters to be ignored, got %+v, %v", parsed, err)
+	}
+
+	for _, bad := range []string{"<34>1 2023-10-15T10:30:00Z host app - - - hello", KeepAlive, Hello + " idle_timeout=soon"} {
+		if _, err := ParseServerHello(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseDrain(t *testing.T) {
+	reconnectAfter, ok := ParseDrain(DrainNotice(1500*time.Millisecond) + "\n")
+	if !ok || reconnectAfter != 1500*time.Millisecond {
+		t.Errorf("Expected a 1.5s drain, got %v, %v", reconnectAfter, ok)
+	}
+
+	// A missing or invalid delay means reconnecting immediately
+	if reconnectAfter, ok := ParseDrain(Drain); !ok || reconnectAfter != 0 {
+		t.Errorf("Expected an immediate drain, got %v, %v", reconnectAfter, ok)
+	}
+	if reconnectAfter, ok := ParseDrain(Drain + " reconnect_after=-1s"); !ok || reconnectAfter != 0 {
+		t.Errorf("Expected a negative delay to be ignored, got %v, %v", reconnectAfter, ok)
+	}
+
+	if _, ok := ParseDrain(Drain + "ING"); ok {
+		t.Error("Expected a different command not to be a drain")
+	}
+	if _, ok := ParseDrain(KeepAlive); ok {
+		t.Error("Expected a keepalive not to be a drain")
+	}
+}
+
+func TestAck_RoundTrip(t *testing.T) {
+	hello := ServerHello{IdleTimeout: 30 * time.Second, KeepAlive: 10 * time.Second, Ack: true}
+	parsed, err := ParseServerHello(hello.String())
+	if err != nil {
+		t.Fatalf("Failed to parse hello: %v", err)
+	}
+	if parsed != hello {
+		t.Errorf("Expected %+v, got %+v", hello, parsed)
+	}
+
+	client, ok := ParseClientHello(ClientHello{Ack: true}.String() + "\n")
+	if !ok || !client.Ack {
+		t.Errorf("Expected a client hello asking for acks, got %+v", client)
+	}
+	if client, ok := ParseClientHello(Hello); !ok || client.Ack {
+		t.Errorf("Expected a plain client hello, got %+v", client)
+	}
+
+	if lines, ok := ParseAck(AckNotice(42) + "\r\n"); !ok || lines != 42 {
+		t.Errorf("Expected 42 lines acknowledged, got %d", lines)
+	}
+	for _, line := range []string{Ack, Ack + " lines=-1", Ack + " lines=x", KeepAlive} {
+		if _, ok := ParseAck(line); ok {
+			t.Errorf("Expected %q not to be accepted as an ACK", line)
+		}
+	}
+}
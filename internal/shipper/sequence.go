@@ -0,0 +1,50 @@
+package shipper
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSequenceID is the highest RFC5424 sequenceId, after which numbering starts again at 1
+const maxSequenceID = 2147483647
+
+// sequencer numbers RFC5424 lines per hostname and app name with a meta sequenceId parameter, so
+// the server can tell when lines are lost on the way
+type sequencer struct {
+	mu   sync.Mutex
+	next map[string]int64
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{next: make(map[string]int64)}
+}
+
+// number adds the next sequence number of the line's source to an RFC5424 line and returns the
+// numbered line with a function that gives the number back if the line is not sent after all.
+// Lines that are not RFC5424 or already carry a meta element are returned unchanged. The caller
+// must hold s.mu.
+func (s *sequencer) number(line string) (string, func()) {
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+	fields := strings.SplitN(line, " ", 7)
+	if len(fields) < 7 || !strings.HasPrefix(fields[0], "<") || !strings.HasSuffix(fields[0], ">1") {
+		return line, func() {}
+	}
+	sd := fields[6]
+	switch {
+	case sd == "-" || strings.HasPrefix(sd, "- "):
+		sd = sd[1:]
+	case strings.HasPrefix(sd, "[meta ") || strings.HasPrefix(sd, "[meta]") || strings.Contains(sd, "][meta "):
+		return line, func() {}
+	case !strings.HasPrefix(sd, "["):
+		return line, func() {}
+	}
+
+	key := fields[2] + " " + fields[3]
+	previous := s.next[key]
+	sequence := previous%maxSequenceID + 1
+	s.next[key] = sequence
+
+	fields[6] = `[meta sequenceId="` + strconv.FormatInt(sequence, 10) + `"]` + sd
+	return strings.Join(fields, " "), func() { s.next[key] = previous }
+}
@@ -0,0 +1,58 @@
+package shipper
+
+import (
+	"testing"
+)
+
+func TestSequencer_Number(t *testing.T) {
+	s := newSequencer()
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			"<34>1 2023-10-15T10:30:00Z web01 app - - - user logged in",
+			`<34>1 2023-10-15T10:30:00Z web01 app - - [meta sequenceId="1"] user logged in`,
+		},
+		{
+			"<34>1 2023-10-15T10:30:01Z web01 app - - -",
+			`<34>1 2023-10-15T10:30:01Z web01 app - - [meta sequenceId="2"]`,
+		},
+		{
+			`<34>1 2023-10-15T10:30:02Z web01 app 12 login [user id="7"] again`,
+			`<34>1 2023-10-15T10:30:02Z web01 app 12 login [meta sequenceId="3"][user id="7"] again`,
+		},
+		{
+			// Numbered per hostname and app name
+			"<34>1 2023-10-15T10:30:03Z web02 app - - - other host",
+			`<34>1 2023-10-15T10:30:03Z web02 app - - [meta sequenceId="1"] other host`,
+		},
+		{
+			`<34>1 2023-10-15T10:30:04Z web01 app - - [meta sequenceId="9"] numbered by the sender`,
+			`<34>1 2023-10-15T10:30:04Z web01 app - - [meta sequenceId="9"] numbered by the sender`,
+		},
+		{
+			"<34>Oct 15 10:30:05 web01 app: not RFC5424",
+			"<34>Oct 15 10:30:05 web01 app: not RFC5424",
+		},
+	}
+	for _, tt := range tests {
+		got, _ := s.number(tt.line)
+		if got != tt.want {
+			t.Errorf("number(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	// A line that is not sent gives its number back
+	_, unnumber := s.number("<34>1 2023-10-15T10:30:06Z web01 app - - - dropped")
+	unnumber()
+	if got, _ := s.number("<34>1 2023-10-15T10:30:07Z web01 app - - - next"); got != `<34>1 2023-10-15T10:30:07Z web01 app - - [meta sequenceId="4"] next` {
+		t.Errorf("Expected the number of an unsent line to be reused, got %q", got)
+	}
+
+	// The sequence wraps around like RFC5424 requires
+	s.next["web03 app"] = maxSequenceID
+	if got, _ := s.number("<34>1 2023-10-15T10:30:08Z web03 app - - - wrapped"); got != `<34>1 2023-10-15T10:30:08Z web03 app - - [meta sequenceId="1"] wrapped` {
+		t.Errorf("Expected the sequence to wrap around to 1, got %q", got)
+	}
+}
@@ -0,0 +1,474 @@
+package shipper
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// spoolSegmentSize is the size at which the spool starts a new segment file
+	spoolSegmentSize = 8 << 20
+	// spoolSegmentSuffix names segment files, which are named after the number of their first line
+	spoolSegmentSuffix = ".seg"
+	// spoolAckedFile holds the number of lines acknowledged, from which sending resumes
+	spoolAckedFile = "acked"
+)
+
+// SpoolStats describes the lines held in a client's spool
+type SpoolStats struct {
+	// Pending is the number of lines the server has not acknowledged yet
+	Pending int64
+	// Bytes is the size of the spool's segment files, including acknowledged lines not yet trimmed
+	Bytes    int64
+	Segments int
+	// Acked is the number of lines acknowledged since the spool was created
+	Acked int64
+	// Dropped is the number of lines dropped unacknowledged to keep the spool within its limit
+	// since it was opened
+	Dropped int64
+}
+
+// spool keeps the lines of a client in segment files on disk until the server acknowledges them,
+// so they survive restarts of the shipper and outages of the server. Lines are numbered from the
+// creation of the spool; only segments whose every line is acknowledged are deleted.
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	segments []*spoolSegment // oldest first, the last one is appended to
+	writer   *os.File
+	acked    int64 // number of the first line not acknowledged
+	end      int64 // number of the line appended next
+	size     int64
+	dropped  int64
+	// appended is closed and replaced whenever lines are appended or the spool should be looked at again
+	appended chan struct{}
+}
+
+// spoolSegment is one segment file of a spool
+type spoolSegment struct {
+	first int64
+	lines int64
+	size  int64
+}
+
+// openSpool opens the spool in dir, creating it if needed, recovering the lines not acknowledged
+// before the shipper stopped. maxBytes bounds the size of its segment files.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	s := &spool{dir: dir, maxBytes: maxBytes, appended: make(chan struct{})}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), spoolSegmentSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		first, err := strconv.ParseInt(name, 10, 64)
+		if err != nil || first < 0 {
+			continue
+		}
+		segment, err := s.recoverSegment(first)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, segment)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].first < s.segments[j].first })
+
+	var saved int64
+	if data, err := os.ReadFile(filepath.Join(dir, spoolAckedFile)); err == nil {
+		saved, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read spool position: %w", err)
+	}
+	if len(s.segments) > 0 {
+		last := s.segments[len(s.segments)-1]
+		s.end = last.first + last.lines
+		s.acked = min(max(saved, s.segments[0].first), s.end)
+	} else {
+		// Numbering carries on where the last, trimmed segment ended
+		s.acked, s.end = max(saved, 0), max(saved, 0)
+	}
+	s.trim()
+
+	if err := s.openWriter(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// segmentPath returns the path of the segment starting with the given line
+func (s *spool) segmentPath(first int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", first, spoolSegmentSuffix))
+}
+
+// recoverSegment counts the lines of a segment file, cutting off a line partially written when the
+// shipper stopped
+func (s *spool) recoverSegment(first int64) (*spoolSegment, error) {
+	path := s.segmentPath(first)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool segment: %w", err)
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, fmt.Errorf("failed to repair spool segment: %w", err)
+		}
+	}
+	segment := &spoolSegment{first: first, lines: int64(bytes.Count(data[:complete], []byte{'\n'})), size: int64(complete)}
+	s.size += segment.size
+	return segment, nil
+}
+
+// openWriter opens the last segment for appending, or starts one
+func (s *spool) openWriter() error {
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].size >= s.segmentLimit() {
+		s.segments = append(s.segments, &spoolSegment{first: s.end})
+	}
+	last := s.segments[len(s.segments)-1]
+	writer, err := os.OpenFile(s.segmentPath(last.first), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	s.writer = writer
+	return nil
+}
+
+// segmentLimit is the size of a full segment, small enough for the spool to be trimmed to its limit
+func (s *spool) segmentLimit() int64 {
+	if s.maxBytes > 0 && s.maxBytes/4 < spoolSegmentSize {
+		return max(s.maxBytes/4, 1)
+	}
+	return spoolSegmentSize
+}
+
+// append adds a line to the spool, dropping the oldest segment if the spool grows over its limit
+func (s *spool) append(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.segments[len(s.segments)-1].size >= s.segmentLimit() {
+		if err := s.writer.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+		s.writer.Close()
+		if err := s.openWriter(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.writer.Write([]byte(line + "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to write to spool: %w", err)
+	}
+	last := s.segments[len(s.segments)-1]
+	last.lines++
+	last.size += int64(n)
+	s.size += int64(n)
+	s.end++
+
+	for s.maxBytes > 0 && s.size > s.maxBytes && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if unacked := oldest.first + oldest.lines - max(s.acked, oldest.first); unacked > 0 {
+			s.dropped += unacked
+		}
+		s.acked = max(s.acked, oldest.first+oldest.lines)
+		s.removeOldest()
+	}
+	s.wake()
+	return nil
+}
+
+// wake tells readers waiting for lines to look at the spool again. The caller must hold s.mu.
+func (s *spool) wake() {
+	close(s.appended)
+	s.appended = make(chan struct{})
+}
+
+// ack records that the lines before the given number were acknowledged and deletes the segments
+// holding only acknowledged lines
+func (s *spool) ack(line int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line = min(line, s.end)
+	if line <= s.acked {
+		return nil
+	}
+	s.acked = line
+	s.trim()
+	// Written to a temporary file first, so a crash never leaves a torn position
+	path := filepath.Join(s.dir, spoolAckedFile)
+	if err := os.WriteFile(path+".tmp", []byte(strconv.FormatInt(line, 10)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to save spool position: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save spool position: %w", err)
+	}
+	return nil
+}
+
+// trim deletes the segments before the last one whose lines are all acknowledged. The caller must
+// hold s.mu.
+func (s *spool) trim() {
+	for len(s.segments) > 1 && s.segments[0].first+s.segments[0].lines <= s.acked {
+		s.removeOldest()
+	}
+}
+
+// removeOldest deletes the oldest segment. The caller must hold s.mu.
+func (s *spool) removeOldest() {
+	oldest := s.segments[0]
+	os.Remove(s.segmentPath(oldest.first))
+	s.size -= oldest.size
+	s.segments = s.segments[1:]
+}
+
+// notify wakes the readers waiting for lines, so they notice the client closing
+func (s *spool) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wake()
+}
+
+// position returns the number of the first line not acknowledged and of the line appended next,
+// with a channel closed once lines are appended
+func (s *spool) position() (acked, end int64, appended <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acked, s.end, s.appended
+}
+
+// stats describes the spool
+func (s *spool) stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpoolStats{
+		Pending:  s.end - s.acked,
+		Bytes:    s.size,
+		Segments: len(s.segments),
+		Acked:    s.acked,
+		Dropped:  s.dropped,
+	}
+}
+
+// close syncs and closes the segment being appended to
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Sync()
+	s.writer.Close()
+	s.writer = nil
+	return err
+}
+
+// spoolReader reads the lines of a spool in order from a given line
+type spoolReader struct {
+	spool  *spool
+	next   int64
+	file   *os.File
+	reader *bufio.Reader
+	// first is the number of the first line of the open segment, and end the number after its last
+	// line once another segment follows it, -1 until then
+	first, end int64
+}
+
+// newReader returns a reader starting at the given line
+func (s *spool) newReader(from int64) *spoolReader {
+	return &spoolReader{spool: s, next: from}
+}
+
+// read returns the next line and its number, or false when every line appended so far was read.
+// Lines dropped to keep the spool within its limit are skipped.
+func (r *spoolReader) read() (string, int64, bool, error) {
+	s := r.spool
+	s.mu.Lock()
+	if r.next < s.acked {
+		// Dropped or acknowledged meanwhile
+		r.next = s.acked
+		r.closeFile()
+	}
+	if r.next >= s.end {
+		s.mu.Unlock()
+		return "", 0, false, nil
+	}
+	if r.file != nil && r.end < 0 {
+		// The open segment was the last one; another may have been started since
+		if index := s.segmentIndex(r.first); index >= 0 && index+1 < len(s.segments) {
+			r.end = s.segments[index+1].first
+		}
+	}
+	if r.file == nil || (r.end >= 0 && r.next >= r.end) {
+		r.closeFile()
+		if err := r.open(); err != nil {
+			s.mu.Unlock()
+			return "", 0, false, err
+		}
+	}
+	s.mu.Unlock()
+
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		r.closeFile()
+		return "", 0, false, fmt.Errorf("failed to read spool: %w", err)
+	}
+	r.next++
+	return strings.TrimSuffix(line, "\n"), r.next - 1, true, nil
+}
+
+// segmentIndex returns the index of the segment holding a line, -1 if none. The caller must hold
+// s.mu.
+func (s *spool) segmentIndex(line int64) int {
+	return sort.Search(len(s.segments), func(i int) bool { return s.segments[i].first > line }) - 1
+}
+
+// open opens the segment holding the next line and skips to it. The caller must hold the spool's mu.
+func (r *spoolReader) open() error {
+	s := r.spool
+	index := s.segmentIndex(r.next)
+	if index < 0 {
+		return fmt.Errorf("spool line %d is not in any segment", r.next)
+	}
+	segment := s.segments[index]
+	file, err := os.Open(s.segmentPath(segment.first))
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	r.file = file
+	r.reader = bufio.NewReader(file)
+	r.first, r.end = segment.first, -1
+	if index+1 < len(s.segments) {
+		r.end = s.segments[index+1].first
+	}
+	for skip := r.next - segment.first; skip > 0; skip-- {
+		if _, err := r.reader.ReadString('\n'); err != nil {
+			r.closeFile()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to find line %d in spool: %w", r.next, err)
+		}
+	}
+	return nil
+}
+
+// closeFile closes the open segment, if any
+func (r *spoolReader) closeFile() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+		r.reader = nil
+	}
+}
+
+// spooledLine is a line read from the spool with its number
+type spooledLine struct {
+	text   string
+	number int64
+}
+
+// feed sends the lines of the spool from the given line on the returned channel until stop is
+// closed, closing the channel once the client is closed and every line was read
+func (c *Client) feed(from int64, stop <-chan struct{}) <-chan spooledLine {
+	lines := make(chan spooledLine)
+	go func() {
+		defer close(lines)
+		reader := c.spool.newReader(from)
+		defer reader.closeFile()
+		for {
+			// Checked before reading, so no line appended before the client closed is missed
+			closed := c.isClosed()
+			_, _, appended := c.spool.position()
+			text, number, ok, err := reader.read()
+			if err != nil {
+				log.Printf("Failed to read spooled line, retrying in %v: %v", retryDelay, err)
+				select {
+				case <-time.After(retryDelay):
+					continue
+				case <-stop:
+					return
+				}
+			}
+			if !ok {
+				if closed {
+					return
+				}
+				select {
+				case <-appended:
+					continue
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case lines <- spooledLine{text: text, number: number}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// sentLines maps the lines sent on a connection to their numbers in the spool, so that the lines
+// the server acknowledges by count can be acknowledged in the spool
+type sentLines struct {
+	mu   sync.Mutex
+	sent int64
+	// runs start where the spool numbers stop following each other, because lines were dropped
+	runs []sentRun
+}
+
+// sentRun is a run of consecutive spool lines, starting with the conn-th line of the connection
+type sentRun struct {
+	conn, number int64
+}
+
+// add records that the next line of the connection was the given spool line
+func (s *sentLines) add(number int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.runs) == 0 {
+		s.runs = append(s.runs, sentRun{conn: s.sent, number: number})
+	} else if last := s.runs[len(s.runs)-1]; last.number+(s.sent-last.conn) != number {
+		s.runs = append(s.runs, sentRun{conn: s.sent, number: number})
+	}
+	s.sent++
+}
+
+// through returns the number after the spool line sent as the count-th line of the connection,
+// forgetting the runs before it, or false if no line was sent
+func (s *sentLines) through(count int64) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count = min(count, s.sent)
+	if count <= 0 {
+		return 0, false
+	}
+	index := sort.Search(len(s.runs), func(i int) bool { return s.runs[i].conn > count-1 }) - 1
+	run := s.runs[index]
+	s.runs = s.runs[index:]
+	return run.number + (count - 1 - run.conn) + 1, true
+}
@@ -0,0 +1,277 @@
+package shipper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readAll reads every line of a spool from the given line
+func readAll(t *testing.T, s *spool, from int64) []string {
+	t.Helper()
+	reader := s.newReader(from)
+	defer reader.closeFile()
+	var lines []string
+	for {
+		line, _, ok, err := reader.read()
+		if err != nil {
+			t.Fatalf("Failed to read spool: %v", err)
+		}
+		if !ok {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestSpool_AckAndRecover(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, 1000)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := s.append(fmt.Sprintf("line %02d", i)); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	if stats := s.stats(); stats.Segments < 2 || stats.Pending != 100 {
+		t.Fatalf("Expected 100 pending lines over several segments, got %+v", stats)
+	}
+
+	if err := s.ack(60); err != nil {
+		t.Fatalf("Failed to ack: %v", err)
+	}
+	stats := s.stats()
+	if stats.Pending != 40 || stats.Bytes >= 800 {
+		t.Errorf("Expected acknowledged segments to be trimmed, got %+v", stats)
+	}
+	if lines := readAll(t, s, 60); len(lines) != 40 || lines[0] != "line 60" {
+		t.Fatalf("Expected lines 60 to 99, got %q", lines)
+	}
+
+	// A line torn by a crash is cut off when the spool is opened again
+	s.close()
+	last := s.segments[len(s.segments)-1]
+	file, err := os.OpenFile(s.segmentPath(last.first), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	file.WriteString("torn li")
+	file.Close()
+
+	s, err = openSpool(dir, 1000)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer s.close()
+	if stats := s.stats(); stats.Pending != 40 || stats.Acked != 60 {
+		t.Fatalf("Expected the unacknowledged lines to be recovered, got %+v", stats)
+	}
+	if err := s.append("after restart"); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	lines := readAll(t, s, 60)
+	if len(lines) != 41 || lines[39] != "line 99" || lines[40] != "after restart" {
+		t.Errorf("Expected the recovered lines followed by the new one, got %q", lines)
+	}
+}
+
+func TestSpool_Limit(t *testing.T) {
+	s, err := openSpool(t.TempDir(), 400)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	defer s.close()
+
+	reader := s.newReader(0)
+	defer reader.closeFile()
+	if line, _, _, err := func() (string, int64, bool, error) {
+		s.append("line 000")
+		return reader.read()
+	}(); err != nil || line != "line 000" {
+		t.Fatalf("Expected to read the first line, got %q: %v", line, err)
+	}
+
+	for i := 1; i < 200; i++ {
+		if err := s.append(fmt.Sprintf("line %03d", i)); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	stats := s.stats()
+	if stats.Bytes > 400 || stats.Dropped == 0 || stats.Pending+stats.Dropped != 200 {
+		t.Fatalf("Expected the oldest lines to be dropped to stay within the limit, got %+v", stats)
+	}
+
+	// A reader on a dropped segment skips to the oldest line kept
+	line, number, ok, err := reader.read()
+	if err != nil || !ok || number != stats.Acked || line != fmt.Sprintf("line %03d", stats.Acked) {
+		t.Errorf("Expected the reader to skip to line %d, got %d %q: %v", stats.Acked, number, line, err)
+	}
+}
+
+func TestSentLines_Through(t *testing.T) {
+	var sent sentLines
+	for _, number := range []int64{10, 11, 12, 20, 21, 30} {
+		sent.add(number)
+	}
+	for _, tt := range []struct{ count, want int64 }{{1, 11}, {3, 13}, {4, 21}, {5, 22}, {6, 31}, {9, 31}} {
+		if got, ok := sent.through(tt.count); !ok || got != tt.want {
+			t.Errorf("through(%d) = %d, want %d", tt.count, got, tt.want)
+		}
+	}
+	if _, ok := (&sentLines{}).through(1); ok {
+		t.Error("Expected nothing to acknowledge before a line was sent")
+	}
+}
+
+// ackServer acknowledges the lines it receives; the first connection only acknowledges the
+// first ackFirst lines and closes after receiving one more, as if the rest was lost
+type ackServer struct {
+	listener net.Listener
+	ackFirst int
+
+	mutex       sync.Mutex
+	stored      []string
+	connections int
+}
+
+func newAckServer(t *testing.T, ackFirst int) *ackServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &ackServer{listener: listener, ackFirst: ackFirst}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.connections++
+			first := server.connections == 1
+			server.mutex.Unlock()
+			go server.handle(conn, first)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *ackServer) handle(conn net.Conn, first bool) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	received := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// The shipper closed its side: acknowledge everything before closing
+			fmt.Fprintf(conn, "%s\n", AckNotice(int64(received)))
+			return
+		}
+		line = strings.TrimRight(line, "\n")
+		if hello, ok := ParseClientHello(line); ok {
+			fmt.Fprintf(conn, "%s\n", ServerHello{IdleTimeout: time.Second, KeepAlive: 50 * time.Millisecond, Ack: hello.Ack})
+			continue
+		}
+		if IsControl(line) {
+			continue
+		}
+		if first && received == s.ackFirst {
+			return
+		}
+		received++
+		s.mutex.Lock()
+		s.stored = append(s.stored, line)
+		s.mutex.Unlock()
+		if !first || received <= s.ackFirst {
+			fmt.Fprintf(conn, "%s\n", AckNotice(int64(received)))
+		}
+	}
+}
+
+func TestSpoolingClient_ResendsUnacknowledged(t *testing.T) {
+	server := newAckServer(t, 3)
+	dir := t.TempDir()
+	client, err := NewSpoolingClient(server.listener.Addr().String(), dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if err := client.Send(ctx, fmt.Sprintf("line %d", i)); err != nil {
+			t.Fatalf("Failed to send: %v", err)
+		}
+	}
+	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := client.Close(closeCtx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.stored) != 10 {
+		t.Fatalf("Expected each line to be stored once, got %q", server.stored)
+	}
+	for i, line := range server.stored {
+		if line != fmt.Sprintf("line %d", i) {
+			t.Errorf("Expected line %d in order, got %q", i, line)
+		}
+	}
+	if stats, _ := client.SpoolStats(); stats.Pending != 0 || stats.Acked != 10 {
+		t.Errorf("Expected every line to be acknowledged, got %+v", stats)
+	}
+}
+
+func TestSpoolingClient_SurvivesRestart(t *testing.T) {
+	// Nothing listens on the address, so the lines stay in the spool
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	dir := t.TempDir()
+	client, err := NewSpoolingClient(addr, dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		client.Send(context.Background(), fmt.Sprintf("line %d", i))
+	}
+	closeCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.Close(closeCtx); err == nil {
+		t.Fatal("Expected closing without a server to leave lines unacknowledged")
+	}
+
+	server := newAckServer(t, 100)
+	client, err = NewSpoolingClient(server.listener.Addr().String(), dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen client: %v", err)
+	}
+	if stats, _ := client.SpoolStats(); stats.Pending != 3 {
+		t.Fatalf("Expected the 3 lines to be recovered from the spool, got %+v", stats)
+	}
+	closeCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Close(closeCtx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.stored) != 3 || server.stored[0] != "line 0" {
+		t.Errorf("Expected the spooled lines to be sent after the restart, got %q", server.stored)
+	}
+}
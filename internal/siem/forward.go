@@ -0,0 +1,164 @@
+package siem
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// writeTimeout bounds a single event write so a stalled collector cannot block the stream
+	writeTimeout = 5 * time.Second
+	// reconnectDelay is how long events are dropped after a failed connection before retrying
+	reconnectDelay = 5 * time.Second
+)
+
+// Forwarder sends the security-relevant entries of a live entry stream to a SIEM collector over
+// TCP or UDP, one event per line. Entries arriving while the collector is unreachable are dropped
+// and counted rather than queued, so a dead collector cannot hold back ingestion.
+type Forwarder struct {
+	network     string
+	address     string
+	encode      Encoder
+	minSeverity int
+	facilities  []int
+
+	dial      func(network, address string) (net.Conn, error)
+	conn      net.Conn
+	retryAt   time.Time
+	forwarded atomic.Int64
+	dropped   atomic.Int64
+}
+
+// Encoder renders an entry as one event, without a trailing newline
+type Encoder func(entry *types.LogEntry) ([]byte, error)
+
+// NewForwarder creates a forwarder to target, a tcp://host:port or udp://host:port URL, selecting
+// entries at least as severe as minSeverity and, if any are given, from one of facilities
+func NewForwarder(target, format string, minSeverity int, facilities []int) (*Forwarder, error) {
+	if !slices.Contains(Formats, format) {
+		return nil, fmt.Errorf("unsupported SIEM format %q, expected cef or ocsf", format)
+	}
+	return NewForwarderWithEncoder(target, func(entry *types.LogEntry) ([]byte, error) {
+		return Encode(entry, format)
+	}, minSeverity, facilities)
+}
+
+// NewForwarderWithEncoder creates a forwarder like NewForwarder that renders events with encode,
+// e.g. through an output format template
+func NewForwarderWithEncoder(target string, encode Encoder, minSeverity int, facilities []int) (*Forwarder, error) {
+	network, address, err := ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	return &Forwarder{
+		network:     network,
+		address:     address,
+		encode:      encode,
+		minSeverity: minSeverity,
+		facilities:  facilities,
+		dial: func(network, address string) (net.Conn, error) {
+			return net.DialTimeout(network, address, writeTimeout)
+		},
+	}, nil
+}
+
+// ParseTarget splits a tcp://host:port or udp://host:port forwarding target into network and address
+func ParseTarget(target string) (string, string, error) {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid SIEM target %q: %w", target, err)
+	}
+	if parsed.Scheme != "tcp" && parsed.Scheme != "udp" {
+		return "", "", fmt.Errorf("invalid SIEM target %q, expected tcp://host:port or udp://host:port", target)
+	}
+	if _, port, err := net.SplitHostPort(parsed.Host); err != nil || port == "" || parsed.Path != "" {
+		return "", "", fmt.Errorf("invalid SIEM target %q, expected tcp://host:port or udp://host:port", target)
+	}
+	return parsed.Scheme, parsed.Host, nil
+}
+
+// Selects reports whether an entry is forwarded
+func (f *Forwarder) Selects(entry *types.LogEntry) bool {
+	if entry.Severity > f.minSeverity {
+		return false
+	}
+	return len(f.facilities) == 0 || slices.Contains(f.facilities, entry.Facility)
+}
+
+// Forwarded returns the number of events sent to the collector
+func (f *Forwarder) Forwarded() int64 {
+	return f.forwarded.Load()
+}
+
+// Dropped returns the number of selected entries that could not be sent
+func (f *Forwarder) Dropped() int64 {
+	return f.dropped.Load()
+}
+
+// Run forwards the selected entries received on entries until ctx is done or entries is closed
+func (f *Forwarder) Run(ctx context.Context, entries <-chan *types.LogEntry) {
+	defer f.close()
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			if f.Selects(entry) {
+				f.send(entry)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send writes one event, connecting first if needed
+func (f *Forwarder) send(entry *types.LogEntry) {
+	event, err := f.encode(entry)
+	if err != nil {
+		log.Printf("Failed to encode SIEM event for entry %d: %v", entry.ID, err)
+		f.dropped.Add(1)
+		return
+	}
+
+	if f.conn == nil {
+		if time.Now().Before(f.retryAt) {
+			f.dropped.Add(1)
+			return
+		}
+		conn, err := f.dial(f.network, f.address)
+		if err != nil {
+			log.Printf("Failed to connect to SIEM collector %s://%s, retrying in %v: %v", f.network, f.address, reconnectDelay, err)
+			f.retryAt = time.Now().Add(reconnectDelay)
+			f.dropped.Add(1)
+			return
+		}
+		f.conn = conn
+	}
+
+	f.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := f.conn.Write(append(event, '\n')); err != nil {
+		log.Printf("Failed to forward SIEM event to %s://%s: %v", f.network, f.address, err)
+		f.close()
+		f.retryAt = time.Now().Add(reconnectDelay)
+		f.dropped.Add(1)
+		return
+	}
+	f.forwarded.Add(1)
+}
+
+func (f *Forwarder) close() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
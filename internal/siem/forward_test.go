@@ -0,0 +1,121 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestParseTarget(t *testing.T) {
+	network, address, err := ParseTarget("udp://siem.example.com:514")
+	if err != nil || network != "udp" || address != "siem.example.com:514" {
+		t.Errorf("Unexpected target %s %s, %v", network, address, err)
+	}
+	for _, target := range []string{"siem.example.com:514", "http://siem:514", "tcp://siem", "tcp://siem:514/path"} {
+		if _, _, err := ParseTarget(target); err == nil {
+			t.Errorf("Expected target %q to be rejected", target)
+		}
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	forwarder, err := NewForwarder("tcp://"+listener.Addr().String(), FormatCEF, 4, []int{4, 10})
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+
+	entries := make(chan *types.LogEntry, 4)
+	entries <- &types.LogEntry{Facility: 4, Severity: 3, MsgID: "AUTHFAIL", Message: "failed password"}
+	entries <- &types.LogEntry{Facility: 4, Severity: 6, Message: "session opened"}
+	entries <- &types.LogEntry{Facility: 1, Severity: 2, Message: "disk failure"}
+	entries <- &types.LogEntry{Facility: 10, Severity: 4, MsgID: "SUDO", Message: "sudo denied"}
+	close(entries)
+
+	done := make(chan struct{})
+	go func() {
+		forwarder.Run(context.Background(), entries)
+		close(done)
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	var lines []string
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	<-done
+
+	if !strings.Contains(lines[0], "|AUTHFAIL|failed password|7|") || !strings.Contains(lines[1], "|SUDO|sudo denied|5|") {
+		t.Errorf("Unexpected forwarded events: %q", lines)
+	}
+	if forwarder.Forwarded() != 2 || forwarder.Dropped() != 0 {
+		t.Errorf("Expected 2 forwarded and 0 dropped, got %d and %d", forwarder.Forwarded(), forwarder.Dropped())
+	}
+}
+
+func TestForwarder_DropsWhileUnreachable(t *testing.T) {
+	forwarder, err := NewForwarder("tcp://127.0.0.1:1", FormatOCSF, 7, nil)
+	if err != nil {
+		t.Fatalf("NewForwarder failed: %v", err)
+	}
+	dials := 0
+	forwarder.dial = func(network, address string) (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	}
+
+	entries := make(chan *types.LogEntry, 3)
+	for i := 0; i < 3; i++ {
+		entries <- &types.LogEntry{Severity: 6, Message: "event"}
+	}
+	close(entries)
+	forwarder.Run(context.Background(), entries)
+
+	// Only the first entry attempts a connection; the others are dropped until the retry delay passes
+	if dials != 1 || forwarder.Dropped() != 3 || forwarder.Forwarded() != 0 {
+		t.Errorf("Expected 1 dial and 3 drops, got %d dials, %d dropped, %d forwarded", dials, forwarder.Dropped(), forwarder.Forwarded())
+	}
+}
+
+func TestForwarder_Encoder(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	forwarder, err := NewForwarderWithEncoder("udp://127.0.0.1:514", func(entry *types.LogEntry) ([]byte, error) {
+		return []byte(entry.Hostname + ": " + entry.Message), nil
+	}, 7, nil)
+	if err != nil {
+		t.Fatalf("NewForwarderWithEncoder failed: %v", err)
+	}
+	forwarder.dial = func(network, address string) (net.Conn, error) {
+		return client, nil
+	}
+
+	go forwarder.send(&types.LogEntry{Hostname: "web01", Message: "disk full"})
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(server).ReadString('\n')
+	if err != nil || line != "web01: disk full\n" {
+		t.Errorf("Expected the encoder's event, got %q, %v", line, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package siem encodes log entries as events in the formats enterprise SIEMs ingest:
+// ArcSight Common Event Format (CEF) lines or OCSF Base Event JSON objects.
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// Supported event formats
+const (
+	FormatCEF  = "cef"
+	FormatOCSF = "ocsf"
+)
+
+// Formats lists every supported event format
+var Formats = []string{FormatCEF, FormatOCSF}
+
+const (
+	vendor  = "OpenTrail"
+	product = "OpenTrail"
+
+	// ocsfVersion is the OCSF schema version events are produced for
+	ocsfVersion = "1.1.0"
+	// maxCEFName bounds the Name header field, which SIEMs show as the event title
+	maxCEFName = 512
+)
+
+// ProductVersion is reported as the device or product version of every event
+var ProductVersion = "dev"
+
+// Encode returns an entry as one event in the given format, without a trailing newline
+func Encode(entry *types.LogEntry, format string) ([]byte, error) {
+	switch format {
+	case FormatCEF:
+		return []byte(EncodeCEF(entry)), nil
+	case FormatOCSF:
+		return EncodeOCSF(entry)
+	default:
+		return nil, fmt.Errorf("unsupported SIEM format %q, expected cef or ocsf", format)
+	}
+}
+
+// ContentType returns the media type of a stream of events in the given format, one per line
+func ContentType(format string) string {
+	if format == FormatOCSF {
+		return "application/x-ndjson"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// cefSeverities maps syslog severities (emergency first) to the CEF 0-10 scale
+var cefSeverities = [8]int{10, 9, 8, 7, 5, 3, 2, 0}
+
+// EncodeCEF formats an entry as a CEF:0 line. The signature ID is the message ID, falling back to
+// the application name, and structured data is carried as JSON in a custom string extension.
+func EncodeCEF(entry *types.LogEntry) string {
+	signature := entry.MsgID
+	if signature == "" {
+		signature = entry.AppName
+	}
+	if signature == "" {
+		signature = "syslog"
+	}
+	name := entry.Message
+	if runes := []rune(name); len(runes) > maxCEFName {
+		name = string(runes[:maxCEFName])
+	}
+
+	header := []string{
+		"CEF:0", cefHeader(vendor), cefHeader(product), cefHeader(ProductVersion), cefHeader(signature),
+		cefHeader(name), strconv.Itoa(cefSeverity(entry.Severity)),
+	}
+
+	extension := []string{
+		"rt=" + strconv.FormatInt(entry.Timestamp.UnixMilli(), 10),
+		"deviceFacility=" + strconv.Itoa(entry.Facility),
+	}
+	add := func(key, value string) {
+		if value != "" && value != "-" {
+			extension = append(extension, key+"="+cefExtension(value))
+		}
+	}
+	if entry.ID > 0 {
+		add("externalId", strconv.FormatInt(entry.ID, 10))
+	}
+	add("dvchost", entry.Hostname)
+	add("deviceProcessName", entry.AppName)
+	if _, err := strconv.Atoi(entry.ProcID); err == nil {
+		add("dvcpid", entry.ProcID)
+	}
+	if addr, ok := sourceAddr(entry); ok {
+		if addr.Is4() {
+			add("src", addr.String())
+		} else {
+			add("c6a2", addr.String())
+			add("c6a2Label", "Source IPv6 Address")
+		}
+	}
+	if len(entry.StructuredData) > 0 {
+		if data, err := json.Marshal(entry.StructuredData); err == nil {
+			add("cs1", string(data))
+			add("cs1Label", "structuredData")
+		}
+	}
+	add("msg", entry.Message)
+
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
+
+// cefSeverity maps a syslog severity to the CEF scale, treating unknown values as informational
+func cefSeverity(severity int) int {
+	if severity < 0 || severity >= len(cefSeverities) {
+		return cefSeverities[6]
+	}
+	return cefSeverities[severity]
+}
+
+// cefHeader escapes a header field; pipes and backslashes are special and line breaks are not allowed
+func cefHeader(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+// cefExtension escapes an extension value; equal signs and backslashes are special and line breaks
+// are encoded
+func cefExtension(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(value)
+}
+
+// ocsfSeverity maps a syslog severity to an OCSF severity_id and its caption
+func ocsfSeverity(severity int) (int, string) {
+	switch severity {
+	case 0:
+		return 6, "Fatal"
+	case 1, 2:
+		return 5, "Critical"
+	case 3:
+		return 4, "High"
+	case 4:
+		return 3, "Medium"
+	case 5:
+		return 2, "Low"
+	case 6, 7:
+		return 1, "Informational"
+	default:
+		return 0, "Unknown"
+	}
+}
+
+// ocsfEvent is an OCSF Base Event; syslog header fields without an OCSF attribute go to unmapped
+type ocsfEvent struct {
+	ClassUID     int                    `json:"class_uid"`
+	ClassName    string                 `json:"class_name"`
+	CategoryUID  int                    `json:"category_uid"`
+	CategoryName string                 `json:"category_name"`
+	ActivityID   int                    `json:"activity_id"`
+	TypeUID      int                    `json:"type_uid"`
+	Time         int64                  `json:"time"`
+	SeverityID   int                    `json:"severity_id"`
+	Severity     string                 `json:"severity"`
+	Message      string                 `json:"message,omitempty"`
+	Metadata     ocsfMetadata           `json:"metadata"`
+	Device       *ocsfDevice            `json:"device,omitempty"`
+	RawData      string                 `json:"raw_data,omitempty"`
+	Unmapped     map[string]interface{} `json:"unmapped,omitempty"`
+}
+
+type ocsfMetadata struct {
+	Version string      `json:"version"`
+	UID     string      `json:"uid,omitempty"`
+	Product ocsfProduct `json:"product"`
+}
+
+type ocsfProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+type ocsfDevice struct {
+	Hostname string `json:"hostname,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	TypeID   int    `json:"type_id"`
+}
+
+// EncodeOCSF formats an entry as an OCSF Base Event JSON object
+func EncodeOCSF(entry *types.LogEntry) ([]byte, error) {
+	severityID, severity := ocsfSeverity(entry.Severity)
+	event := ocsfEvent{
+		ClassName:    "Base Event",
+		CategoryName: "Uncategorized",
+		Time:         entry.Timestamp.UnixMilli(),
+		SeverityID:   severityID,
+		Severity:     severity,
+		Message:      entry.Message,
+		Metadata: ocsfMetadata{
+			Version: ocsfVersion,
+			Product: ocsfProduct{Name: product, VendorName: vendor, Version: ProductVersion},
+		},
+		RawData: entry.Raw,
+		Unmapped: map[string]interface{}{
+			"facility": entry.Facility,
+		},
+	}
+	if entry.ID > 0 {
+		event.Metadata.UID = strconv.FormatInt(entry.ID, 10)
+	}
+
+	device := &ocsfDevice{Hostname: entry.Hostname}
+	if addr, ok := sourceAddr(entry); ok {
+		device.IP = addr.String()
+	}
+	if device.Hostname != "" || device.IP != "" {
+		event.Device = device
+	}
+
+	for key, value := range map[string]string{"app_name": entry.AppName, "proc_id": entry.ProcID, "msg_id": entry.MsgID} {
+		if value != "" && value != "-" {
+			event.Unmapped[key] = value
+		}
+	}
+	if len(entry.StructuredData) > 0 {
+		event.Unmapped["structured_data"] = entry.StructuredData
+	}
+
+	return json.Marshal(event)
+}
+
+// sourceAddr returns the sender address recorded by the receiver, if any
+func sourceAddr(entry *types.LogEntry) (netip.Addr, bool) {
+	var value string
+	switch params := entry.StructuredData[types.MetadataSDID].(type) {
+	case map[string]interface{}:
+		value, _ = params[types.SourceIPParam].(string)
+	case map[string]string:
+		value = params[types.SourceIPParam]
+	}
+	addr, err := netip.ParseAddr(value)
+	return addr, err == nil
+}
@@ -0,0 +1,88 @@
+package siem
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func testEntry() *types.LogEntry {
+	entry := &types.LogEntry{
+		ID: 42, Facility: 4, Severity: 3, Version: 1,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:  "web01", AppName: "sshd", ProcID: "812", MsgID: "AUTHFAIL",
+		Message: "failed password for root | from 10.0.0.9\nattempt=3",
+		StructuredData: map[string]interface{}{
+			"auth": map[string]interface{}{"user": "root"},
+		},
+	}
+	entry.SetMetadata(types.SourceIPParam, "10.0.0.9")
+	return entry
+}
+
+func TestEncodeCEF(t *testing.T) {
+	line := EncodeCEF(testEntry())
+
+	header := `CEF:0|OpenTrail|OpenTrail|dev|AUTHFAIL|failed password for root \| from 10.0.0.9 attempt=3|7|`
+	if !strings.HasPrefix(line, header) {
+		t.Fatalf("Unexpected CEF header:\n%s\nwant prefix\n%s", line, header)
+	}
+	for _, want := range []string{
+		"rt=1714564800000", "deviceFacility=4", "externalId=42", "dvchost=web01", "deviceProcessName=sshd",
+		"dvcpid=812", "src=10.0.0.9", `msg=failed password for root | from 10.0.0.9\nattempt\=3`, "cs1Label=structuredData",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected CEF extension %q in %s", want, line)
+		}
+	}
+	if strings.Contains(line, "\n") {
+		t.Errorf("CEF line must not contain line breaks: %q", line)
+	}
+
+	// Entries without a message ID are signed by their application, IPv6 senders use c6a2
+	entry := &types.LogEntry{Severity: 9, AppName: "kernel", ProcID: "-", Message: "boot"}
+	entry.SetMetadata(types.SourceIPParam, "2001:db8::1")
+	line = EncodeCEF(entry)
+	if !strings.HasPrefix(line, "CEF:0|OpenTrail|OpenTrail|dev|kernel|boot|2|") || !strings.Contains(line, "c6a2=2001:db8::1") || strings.Contains(line, "dvcpid") {
+		t.Errorf("Unexpected CEF line: %s", line)
+	}
+}
+
+func TestEncodeOCSF(t *testing.T) {
+	entry := testEntry()
+	entry.Raw = "<35>1 2024-05-01T12:00:00Z web01 sshd 812 AUTHFAIL - failed"
+	data, err := Encode(entry, FormatOCSF)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Invalid OCSF JSON: %v", err)
+	}
+	if event["class_name"] != "Base Event" || event["severity_id"] != float64(4) || event["severity"] != "High" || event["time"] != float64(1714564800000) {
+		t.Errorf("Unexpected OCSF event: %s", data)
+	}
+	metadata := event["metadata"].(map[string]interface{})
+	if metadata["uid"] != "42" || metadata["product"].(map[string]interface{})["vendor_name"] != "OpenTrail" {
+		t.Errorf("Unexpected OCSF metadata: %v", metadata)
+	}
+	device := event["device"].(map[string]interface{})
+	if device["hostname"] != "web01" || device["ip"] != "10.0.0.9" {
+		t.Errorf("Unexpected OCSF device: %v", device)
+	}
+	unmapped := event["unmapped"].(map[string]interface{})
+	if unmapped["app_name"] != "sshd" || unmapped["msg_id"] != "AUTHFAIL" || unmapped["structured_data"] == nil {
+		t.Errorf("Unexpected OCSF unmapped fields: %v", unmapped)
+	}
+	if event["raw_data"] != entry.Raw {
+		t.Errorf("Expected raw data to be carried, got %v", event["raw_data"])
+	}
+
+	if _, err := Encode(entry, "leef"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
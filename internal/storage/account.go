@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func createUser(db *sql.DB, user *types.User, passwordHash string) error {
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+	if _, err := db.Exec(`
+	INSERT INTO users (username, password_hash, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		user.Username, passwordHash, user.Role, user.CreatedAt, user.UpdatedAt); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return interfaces.ErrUserExists
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func listUsers(db *sql.DB) ([]types.User, error) {
+	rows, err := db.Query("SELECT username, role, created_at, updated_at FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to select users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+	for rows.Next() {
+		var user types.User
+		if err := rows.Scan(&user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		user.CreatedAt, user.UpdatedAt = user.CreatedAt.UTC(), user.UpdatedAt.UTC()
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	return users, nil
+}
+
+func loadUserCredentials(db *sql.DB, username string) (*types.User, string, error) {
+	user := &types.User{Username: username}
+	var passwordHash string
+	err := db.QueryRow("SELECT password_hash, role, created_at, updated_at FROM users WHERE username = ?", username).
+		Scan(&passwordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", interfaces.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to select user: %w", err)
+	}
+	user.CreatedAt, user.UpdatedAt = user.CreatedAt.UTC(), user.UpdatedAt.UTC()
+	return user, passwordHash, nil
+}
+
+func updateUser(db *sql.DB, username, role, passwordHash string) (*types.User, error) {
+	result, err := db.Exec(`
+	UPDATE users SET role = COALESCE(NULLIF(?, ''), role), password_hash = COALESCE(NULLIF(?, ''), password_hash),
+	updated_at = ? WHERE username = ?`, role, passwordHash, time.Now().UTC(), username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	} else if updated == 0 {
+		return nil, interfaces.ErrUserNotFound
+	}
+	user, _, err := loadUserCredentials(db, username)
+	return user, err
+}
+
+func deleteUser(db *sql.DB, username string) error {
+	result, err := db.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	} else if deleted == 0 {
+		return interfaces.ErrUserNotFound
+	}
+	return nil
+}
+
+func createAPIToken(db *sql.DB, token *types.APIToken, tokenHash string) error {
+	token.CreatedAt = time.Now().UTC()
+	var expiresAt interface{}
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.UTC()
+	}
+	result, err := db.Exec(`
+	INSERT INTO api_tokens (name, token_hash, role, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.Name, tokenHash, token.Role, token.CreatedBy, token.CreatedAt, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+	if token.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get API token ID: %w", err)
+	}
+	return nil
+}
+
+// scanAPIToken reads an API token selected with the columns of apiTokenColumns
+func scanAPIToken(scan func(dest ...interface{}) error) (*types.APIToken, error) {
+	token := &types.APIToken{}
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := scan(&token.ID, &token.Name, &token.Role, &token.CreatedBy, &token.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	if expiresAt.Valid {
+		expires := expiresAt.Time.UTC()
+		token.ExpiresAt = &expires
+	}
+	if lastUsedAt.Valid {
+		lastUsed := lastUsedAt.Time.UTC()
+		token.LastUsedAt = &lastUsed
+	}
+	return token, nil
+}
+
+// apiTokenColumns are the columns scanAPIToken reads
+const apiTokenColumns = "id, name, role, created_by, created_at, expires_at, last_used_at"
+
+func listAPITokens(db *sql.DB) ([]types.APIToken, error) {
+	rows, err := db.Query("SELECT " + apiTokenColumns + " FROM api_tokens ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to select API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []types.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func loadAPIToken(db *sql.DB, tokenHash string, now time.Time) (*types.APIToken, error) {
+	token, err := scanAPIToken(db.QueryRow(`
+	SELECT `+apiTokenColumns+` FROM api_tokens
+	WHERE token_hash = ? AND (expires_at IS NULL OR expires_at > ?)`, tokenHash, now.UTC()).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, interfaces.ErrAPITokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select API token: %w", err)
+	}
+	return token, nil
+}
+
+func touchAPIToken(db *sql.DB, id int64, now time.Time) error {
+	if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now.UTC(), id); err != nil {
+		return fmt.Errorf("failed to record API token use: %w", err)
+	}
+	return nil
+}
+
+func deleteAPIToken(db *sql.DB, id int64) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	} else if deleted == 0 {
+		return interfaces.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// CreateUser saves a new user with the hash of its password
+func (s *SQLiteStorage) CreateUser(user *types.User, passwordHash string) error {
+	return createUser(s.db, user, passwordHash)
+}
+
+// Users lists the users by name
+func (s *SQLiteStorage) Users() ([]types.User, error) {
+	return listUsers(s.db)
+}
+
+// UserCredentials returns a user with the hash of its password
+func (s *SQLiteStorage) UserCredentials(username string) (*types.User, string, error) {
+	return loadUserCredentials(s.db, username)
+}
+
+// UpdateUser changes the role and password hash of a user, keeping those passed empty
+func (s *SQLiteStorage) UpdateUser(username, role, passwordHash string) (*types.User, error) {
+	return updateUser(s.db, username, role, passwordHash)
+}
+
+// DeleteUser removes a user
+func (s *SQLiteStorage) DeleteUser(username string) error {
+	return deleteUser(s.db, username)
+}
+
+// CreateAPIToken saves a new API token by the hash of its token
+func (s *SQLiteStorage) CreateAPIToken(token *types.APIToken, tokenHash string) error {
+	return createAPIToken(s.db, token, tokenHash)
+}
+
+// APITokens lists the API tokens, newest first
+func (s *SQLiteStorage) APITokens() ([]types.APIToken, error) {
+	return listAPITokens(s.db)
+}
+
+// APIToken returns the API token with the hash unless it expired by now
+func (s *SQLiteStorage) APIToken(tokenHash string, now time.Time) (*types.APIToken, error) {
+	return loadAPIToken(s.db, tokenHash, now)
+}
+
+// TouchAPIToken records now as the last use of an API token
+func (s *SQLiteStorage) TouchAPIToken(id int64, now time.Time) error {
+	return touchAPIToken(s.db, id, now)
+}
+
+// DeleteAPIToken removes an API token
+func (s *SQLiteStorage) DeleteAPIToken(id int64) error {
+	return deleteAPIToken(s.db, id)
+}
+
+// CreateUser saves a new user with the hash of its password
+func (s *BatchedSQLiteStorage) CreateUser(user *types.User, passwordHash string) error {
+	return createUser(s.db, user, passwordHash)
+}
+
+// Users lists the users by name
+func (s *BatchedSQLiteStorage) Users() ([]types.User, error) {
+	return listUsers(s.db)
+}
+
+// UserCredentials returns a user with the hash of its password
+func (s *BatchedSQLiteStorage) UserCredentials(username string) (*types.User, string, error) {
+	return loadUserCredentials(s.db, username)
+}
+
+// UpdateUser changes the role and password hash of a user, keeping those passed empty
+func (s *BatchedSQLiteStorage) UpdateUser(username, role, passwordHash string) (*types.User, error) {
+	return updateUser(s.db, username, role, passwordHash)
+}
+
+// DeleteUser removes a user
+func (s *BatchedSQLiteStorage) DeleteUser(username string) error {
+	return deleteUser(s.db, username)
+}
+
+// CreateAPIToken saves a new API token by the hash of its token
+func (s *BatchedSQLiteStorage) CreateAPIToken(token *types.APIToken, tokenHash string) error {
+	return createAPIToken(s.db, token, tokenHash)
+}
+
+// APITokens lists the API tokens, newest first
+func (s *BatchedSQLiteStorage) APITokens() ([]types.APIToken, error) {
+	return listAPITokens(s.db)
+}
+
+// APIToken returns the API token with the hash unless it expired by now
+func (s *BatchedSQLiteStorage) APIToken(tokenHash string, now time.Time) (*types.APIToken, error) {
+	return loadAPIToken(s.db, tokenHash, now)
+}
+
+// TouchAPIToken records now as the last use of an API token
+func (s *BatchedSQLiteStorage) TouchAPIToken(id int64, now time.Time) error {
+	return touchAPIToken(s.db, id, now)
+}
+
+// DeleteAPIToken removes an API token
+func (s *BatchedSQLiteStorage) DeleteAPIToken(id int64) error {
+	return deleteAPIToken(s.db, id)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Users(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	for _, user := range []*types.User{{Username: "bob", Role: types.RoleReader}, {Username: "alice", Role: types.RoleAdmin}} {
+		if err := storage.CreateUser(user, "hash-"+user.Username); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+	}
+	if err := storage.CreateUser(&types.User{Username: "bob", Role: types.RoleAdmin}, "other"); !errors.Is(err, interfaces.ErrUserExists) {
+		t.Errorf("Expected a duplicate name to be rejected, got %v", err)
+	}
+
+	users, err := storage.Users()
+	if err != nil {
+		t.Fatalf("Users failed: %v", err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Role != types.RoleReader {
+		t.Errorf("Expected alice and bob by name, got %+v", users)
+	}
+
+	// Empty values keep what is stored
+	user, err := storage.UpdateUser("bob", types.RoleAdmin, "")
+	if err != nil || user.Role != types.RoleAdmin {
+		t.Fatalf("Expected bob promoted, got %+v (%v)", user, err)
+	}
+	if _, err := storage.UpdateUser("bob", "", "new-hash"); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	user, hash, err := storage.UserCredentials("bob")
+	if err != nil || hash != "new-hash" || user.Role != types.RoleAdmin {
+		t.Errorf("Expected the new hash and kept role, got %+v %q (%v)", user, hash, err)
+	}
+	if _, err := storage.UpdateUser("carol", types.RoleAdmin, ""); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := storage.DeleteUser("bob"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, _, err := storage.UserCredentials("bob"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if err := storage.DeleteUser("bob"); !errors.Is(err, interfaces.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteStorage_APITokens(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(time.Hour)
+	tokens := []*types.APIToken{
+		{Name: "ci", Role: types.RoleReader, CreatedBy: "alice"},
+		{Name: "shipper", Role: types.RoleAdmin, ExpiresAt: &expires},
+	}
+	for i, token := range tokens {
+		if err := storage.CreateAPIToken(token, []string{"hash-ci", "hash-shipper"}[i]); err != nil {
+			t.Fatalf("CreateAPIToken failed: %v", err)
+		}
+	}
+
+	listed, err := storage.APITokens()
+	if err != nil {
+		t.Fatalf("APITokens failed: %v", err)
+	}
+	if len(listed) != 2 || listed[0].Name != "shipper" || listed[1].CreatedBy != "alice" {
+		t.Errorf("Expected the tokens newest first, got %+v", listed)
+	}
+
+	token, err := storage.APIToken("hash-shipper", now)
+	if err != nil || token.ID != tokens[1].ID || token.ExpiresAt == nil || !token.ExpiresAt.Equal(expires) {
+		t.Fatalf("Expected the shipper token, got %+v (%v)", token, err)
+	}
+	if _, err := storage.APIToken("hash-shipper", expires); !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+		t.Errorf("Expected an expired token not to be found, got %v", err)
+	}
+	if _, err := storage.APIToken("unknown", now); !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+		t.Errorf("Expected ErrAPITokenNotFound, got %v", err)
+	}
+
+	if err := storage.TouchAPIToken(tokens[0].ID, now); err != nil {
+		t.Fatalf("TouchAPIToken failed: %v", err)
+	}
+	if token, err := storage.APIToken("hash-ci", now); err != nil || token.LastUsedAt == nil || !token.LastUsedAt.Equal(now) {
+		t.Errorf("Expected the last use recorded, got %+v (%v)", token, err)
+	}
+
+	if err := storage.DeleteAPIToken(tokens[0].ID); err != nil {
+		t.Fatalf("DeleteAPIToken failed: %v", err)
+	}
+	if err := storage.DeleteAPIToken(tokens[0].ID); !errors.Is(err, interfaces.ErrAPITokenNotFound) {
+		t.Errorf("Expected ErrAPITokenNotFound, got %v", err)
+	}
+}
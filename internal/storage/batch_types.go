@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -30,17 +31,38 @@ type BatchConfig struct {
 	// WriteTimeout is the maximum time to wait for a write operation to complete
 	// Default: 5s
 	WriteTimeout time.Duration `json:"write_timeout"`
+
+	// CheckpointMode is how the write-ahead log is checkpointed once it holds CheckpointPages
+	// pages, one of types.CheckpointModes
+	// Default: passive
+	CheckpointMode string `json:"checkpoint_mode"`
+
+	// CheckpointPages is the size in pages the write-ahead log is checkpointed at; 0 leaves it to
+	// TruncateInterval and manual checkpoints
+	// Default: 1000
+	CheckpointPages *int `json:"checkpoint_pages"`
+
+	// TruncateInterval is how often the write-ahead log is checkpointed and truncated regardless of
+	// its size; 0 disables
+	// Default: 0
+	TruncateInterval time.Duration `json:"truncate_interval"`
+
+	// Recovery, if set, follows the progress of the work done on opening the database
+	Recovery *RecoveryTracker `json:"-"`
 }
 
 // DefaultBatchConfig returns a BatchConfig with sensible default values
 func DefaultBatchConfig() BatchConfig {
 	walEnabled := true
+	checkpointPages := 1000
 	return BatchConfig{
-		BatchSize:    100,
-		BatchTimeout: 100 * time.Millisecond,
-		QueueSize:    10000,
-		WALEnabled:   &walEnabled,
-		WriteTimeout: 5 * time.Second,
+		BatchSize:       100,
+		BatchTimeout:    100 * time.Millisecond,
+		QueueSize:       10000,
+		WALEnabled:      &walEnabled,
+		WriteTimeout:    5 * time.Second,
+		CheckpointMode:  types.CheckpointPassive,
+		CheckpointPages: &checkpointPages,
 	}
 }
 
@@ -78,6 +100,18 @@ func (c *BatchConfig) Validate() error {
 		return fmt.Errorf("write_timeout must be <= 60s for responsiveness, got %v", c.WriteTimeout)
 	}
 
+	if c.CheckpointMode != "" && !slices.Contains(types.CheckpointModes, c.CheckpointMode) {
+		return fmt.Errorf("checkpoint_mode must be one of %v, got %q", types.CheckpointModes, c.CheckpointMode)
+	}
+
+	if c.CheckpointPages != nil && *c.CheckpointPages < 0 {
+		return fmt.Errorf("checkpoint_pages cannot be negative, got %d", *c.CheckpointPages)
+	}
+
+	if c.TruncateInterval < 0 {
+		return fmt.Errorf("truncate_interval cannot be negative, got %v", c.TruncateInterval)
+	}
+
 	return nil
 }
 
@@ -105,6 +139,19 @@ func (c *BatchConfig) ApplyDefaults() {
 	if c.WriteTimeout == 0 {
 		c.WriteTimeout = defaults.WriteTimeout
 	}
+
+	if c.CheckpointMode == "" {
+		c.CheckpointMode = defaults.CheckpointMode
+	}
+
+	// CheckpointPages: Apply default only if not set, as 0 disables
+	if c.CheckpointPages == nil {
+		c.CheckpointPages = defaults.CheckpointPages
+	}
+
+	if c.Recovery == nil {
+		c.Recovery = NewRecoveryTracker()
+	}
 }
 
 // writeRequest represents a single write operation to be processed asynchronously
@@ -119,6 +166,12 @@ type writeRequest struct {
 	// ctx allows for request-level cancellation
 	ctx context.Context
 
+	// cancel, if set, releases ctx once the result is sent
+	cancel context.CancelFunc
+
+	// committed, if set, is called with the error once the result is sent
+	committed func(error)
+
 	// resultSent ensures result is only sent once
 	resultSent sync.Once
 
@@ -156,6 +209,13 @@ func (wr *writeRequest) sendResult(id int64, err error) {
 		wr.completedMux.Lock()
 		wr.completed = true
 		wr.completedMux.Unlock()
+
+		if wr.cancel != nil {
+			wr.cancel()
+		}
+		if wr.committed != nil {
+			wr.committed(err)
+		}
 	})
 }
 
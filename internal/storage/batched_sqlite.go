@@ -4,23 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"opentrail/internal/entryid"
 	"opentrail/internal/interfaces"
 	"opentrail/internal/metrics"
+	"opentrail/internal/resilience"
 	"opentrail/internal/types"
 
 	_ "modernc.org/sqlite"
 )
 
+const (
+	// individualWriteAttempts bounds how often an entry whose batch failed is retried while the
+	// database is busy; the wait between attempts starts at individualWriteBackoff and doubles
+	individualWriteAttempts = 5
+	individualWriteBackoff  = 10 * time.Millisecond
+)
+
 // BatchedSQLiteStorage implements the LogStorage interface using SQLite with batched writes
 type BatchedSQLiteStorage struct {
 	// Database connection
 	db *sql.DB
 
+	// Path of the database file, whose write-ahead log the checkpoint policy measures
+	dbPath string
+
 	// Batching configuration
 	config BatchConfig
 
@@ -43,6 +57,21 @@ type BatchedSQLiteStorage struct {
 
 	// Metrics
 	metrics *metrics.StorageMetrics
+
+	// Structured data fields promoted to generated columns
+	promotions *fieldPromotions
+
+	// Whether raw messages are stored compressed
+	compressRaw atomic.Bool
+
+	// Hash chain linking new entries, nil when disabled
+	chain atomic.Pointer[hashChain]
+
+	// How long idempotency keys are remembered, zero when they are not
+	idempotencyWindow atomic.Int64
+
+	// Generator of the uid of new entries, nil when entries have none
+	entryIDs atomic.Pointer[entryid.Generator]
 }
 
 // NewBatchedSQLiteStorage creates a new batched SQLite storage instance
@@ -53,8 +82,13 @@ func NewBatchedSQLiteStorage(dbPath string, config BatchConfig) (interfaces.LogS
 		return nil, fmt.Errorf("invalid batch configuration: %w", err)
 	}
 
-	// Open database connection
-	db, err := sql.Open("sqlite", dbPath+"?_fk=1")
+	// Open database connection. The auto-checkpoint is set on every connection of the pool: SQLite
+	// checkpoints on the commits of a connection once the log reaches its threshold.
+	dsn := dbPath + "?_fk=1"
+	if *config.WALEnabled {
+		dsn += fmt.Sprintf("&_pragma=wal_autocheckpoint(%d)", autoCheckpointPages(config))
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -65,6 +99,7 @@ func NewBatchedSQLiteStorage(dbPath string, config BatchConfig) (interfaces.LogS
 	// Create storage instance
 	storage := &BatchedSQLiteStorage{
 		db:          db,
+		dbPath:      dbPath,
 		config:      config,
 		writeQueue:  make(chan *writeRequest, config.QueueSize),
 		batchBuffer: newBatchBuffer(config.BatchSize),
@@ -74,9 +109,9 @@ func NewBatchedSQLiteStorage(dbPath string, config BatchConfig) (interfaces.LogS
 		metrics:     metrics.GetStorageMetrics(),
 	}
 
-	// Configure WAL mode if enabled
+	// Configure WAL mode if enabled, applying what an unclean shutdown left in the log
 	if *config.WALEnabled {
-		if err := storage.configureWALMode(); err != nil {
+		if err := openWAL(dbPath, db, config.Recovery, storage.configureWALMode); err != nil {
 			storage.cleanup()
 			return nil, fmt.Errorf("failed to configure WAL mode: %w", err)
 		}
@@ -106,6 +141,7 @@ func NewBatchedSQLiteStorage(dbPath string, config BatchConfig) (interfaces.LogS
 		return nil, fmt.Errorf("failed to start batch processor: %w", err)
 	}
 
+	config.Recovery.finish()
 	return storage, nil
 }
 
@@ -121,11 +157,6 @@ func (s *BatchedSQLiteStorage) configureWALMode() error {
 		return fmt.Errorf("failed to set synchronous mode: %w", err)
 	}
 
-	// Configure WAL auto-checkpoint to run every 1000 pages
-	if _, err := s.db.Exec("PRAGMA wal_autocheckpoint = 1000"); err != nil {
-		return fmt.Errorf("failed to set WAL auto-checkpoint: %w", err)
-	}
-
 	// Set busy timeout to 5 seconds to handle concurrent access
 	if _, err := s.db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
 		return fmt.Errorf("failed to set busy timeout: %w", err)
@@ -182,99 +213,20 @@ func (s *BatchedSQLiteStorage) configureBasicMode() error {
 	return nil
 }
 
-// initializeDatabase creates the necessary tables and indexes
+// initializeDatabase migrates the schema and rebuilds the rollups of a database that lacks them
 func (s *BatchedSQLiteStorage) initializeDatabase() error {
-	// Remove old database file on startup (hard reset)
-	if _, err := s.db.Exec("DROP TABLE IF EXISTS logs"); err != nil {
-		return fmt.Errorf("failed to drop old logs table: %w", err)
-	}
-	if _, err := s.db.Exec("DROP TABLE IF EXISTS logs_fts"); err != nil {
-		return fmt.Errorf("failed to drop old FTS table: %w", err)
-	}
-
-	// Create main RFC5424 logs table
-	createLogsTable := `
-	CREATE TABLE logs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		
-		-- RFC5424 Header Fields
-		priority INTEGER NOT NULL,
-		facility INTEGER NOT NULL,
-		severity INTEGER NOT NULL,
-		version INTEGER NOT NULL DEFAULT 1,
-		timestamp DATETIME NOT NULL,
-		hostname TEXT,
-		app_name TEXT,
-		proc_id TEXT,
-		msg_id TEXT,
-		
-		-- Structured Data and Message
-		structured_data TEXT, -- JSON string
-		message TEXT NOT NULL,
-		
-		-- System Fields
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	if _, err := s.db.Exec(createLogsTable); err != nil {
-		return fmt.Errorf("failed to create logs table: %w", err)
-	}
-
-	// Create FTS5 virtual table for full-text search on message
-	createFTSTable := `
-	CREATE VIRTUAL TABLE logs_fts USING fts5(
-		message,
-		content='logs',
-		content_rowid='id'
-	);`
-
-	if _, err := s.db.Exec(createFTSTable); err != nil {
-		return fmt.Errorf("failed to create FTS table: %w", err)
-	}
-
-	// Create indexes for efficient RFC5424 field queries
-	indexes := []string{
-		"CREATE INDEX idx_logs_timestamp ON logs(timestamp);",
-		"CREATE INDEX idx_logs_facility ON logs(facility);",
-		"CREATE INDEX idx_logs_severity ON logs(severity);",
-		"CREATE INDEX idx_logs_hostname ON logs(hostname);",
-		"CREATE INDEX idx_logs_app_name ON logs(app_name);",
-		"CREATE INDEX idx_logs_proc_id ON logs(proc_id);",
-		"CREATE INDEX idx_logs_msg_id ON logs(msg_id);",
-		"CREATE INDEX idx_logs_priority ON logs(priority);",
-		"CREATE INDEX idx_logs_created_at ON logs(created_at);",
-		// Composite indexes for common query patterns
-		"CREATE INDEX idx_logs_facility_severity ON logs(facility, severity);",
-		"CREATE INDEX idx_logs_hostname_app_name ON logs(hostname, app_name);",
-		"CREATE INDEX idx_logs_timestamp_severity ON logs(timestamp, severity);",
-	}
-
-	for _, indexSQL := range indexes {
-		if _, err := s.db.Exec(indexSQL); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
-		}
+	if err := migrateSchema(s.db); err != nil {
+		return err
 	}
-
-	// Create triggers to keep FTS5 table in sync
-	triggers := []string{
-		`CREATE TRIGGER logs_ai AFTER INSERT ON logs BEGIN
-			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
-		END;`,
-		`CREATE TRIGGER logs_ad AFTER DELETE ON logs BEGIN
-			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
-		END;`,
-		`CREATE TRIGGER logs_au AFTER UPDATE ON logs BEGIN
-			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
-			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
-		END;`,
+	if err := backfillRollups(s.db, s.config.Recovery); err != nil {
+		return err
 	}
 
-	for _, triggerSQL := range triggers {
-		if _, err := s.db.Exec(triggerSQL); err != nil {
-			return fmt.Errorf("failed to create trigger: %w", err)
-		}
+	promotions, err := newFieldPromotions(s.db)
+	if err != nil {
+		return err
 	}
-
+	s.promotions = promotions
 	return nil
 }
 
@@ -282,8 +234,9 @@ func (s *BatchedSQLiteStorage) initializeDatabase() error {
 func (s *BatchedSQLiteStorage) prepareStatements() error {
 	// Prepare single insert statement
 	insertSQL := `
-	INSERT INTO logs (priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO logs (priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, raw_message, chain_partition, chain_prev, chain_hash, idempotency_key, uid)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING
 	`
 
 	var err error
@@ -318,6 +271,12 @@ func (s *BatchedSQLiteStorage) start() error {
 	// Start the batch processor goroutine
 	go s.batchProcessor()
 
+	// Apply the checkpoint policy to the write-ahead log
+	if *s.config.WALEnabled {
+		s.wg.Add(1)
+		go s.checkpointer()
+	}
+
 	return nil
 }
 
@@ -395,11 +354,9 @@ func (s *BatchedSQLiteStorage) processBatchRequests(requests []*writeRequest) {
 		// Continue with processing
 	}
 
-	// Try batch write first
-	if err := s.executeBatchWrite(requests); err != nil {
-		// Batch write failed, try individual writes with retry logic
-		s.retryIndividualWrites(requests, err)
-	}
+	// A failed batch write falls back to individual writes itself; retrying here as well would
+	// store the entries twice
+	s.executeBatchWrite(requests)
 }
 
 // executeBatchWrite performs a batch database write operation within a transaction
@@ -408,6 +365,11 @@ func (s *BatchedSQLiteStorage) executeBatchWrite(requests []*writeRequest) error
 
 	// Begin transaction for batch write
 	tx, err := s.db.Begin()
+	if err == nil {
+		if err = injectFault(faultBegin); err != nil {
+			tx.Rollback()
+		}
+	}
 	if err != nil {
 		// Transaction failed to begin, all requests need individual retry
 		s.retryIndividualWrites(requests, err)
@@ -418,12 +380,22 @@ func (s *BatchedSQLiteStorage) executeBatchWrite(requests []*writeRequest) error
 	stmt := tx.Stmt(s.insertStmt)
 	defer stmt.Close()
 
+	// Entries are linked to the chain head in the order they are inserted; the chain must be
+	// released before falling back to individual writes, which link entries themselves
+	chain := s.chain.Load().begin(tx)
+	defer chain.release()
+
 	// Track requests that need individual retry
 	var failedRequests []*writeRequest
 	var successfulWrites []struct {
 		request *writeRequest
 		result  sql.Result
 	}
+	// Entries whose idempotency key is already stored; they are written individually as well if
+	// the batch fails, since the keys they were checked against may have been forgotten by it
+	var duplicates []*writeRequest
+	window := time.Duration(s.idempotencyWindow.Load())
+	ids := s.entryIDs.Load()
 
 	// Execute all inserts within the transaction
 	for _, req := range requests {
@@ -443,21 +415,42 @@ func (s *BatchedSQLiteStorage) executeBatchWrite(requests []*writeRequest) error
 			continue
 		}
 
-		// Execute the insert
-		result, err := stmt.Exec(
-			req.entry.Priority,
-			req.entry.Facility,
-			req.entry.Severity,
-			req.entry.Version,
-			req.entry.Timestamp,
-			req.entry.Hostname,
-			req.entry.AppName,
-			req.entry.ProcID,
-			req.entry.MsgID,
-			structuredDataJSON,
-			req.entry.Message,
-		)
+		link, err := chain.link(req.entry, structuredDataJSON)
+		if err != nil {
+			failedRequests = append(failedRequests, req)
+			continue
+		}
 
+		// Execute the insert
+		if err := injectFault(faultExec); err != nil {
+			failedRequests = append(failedRequests, req)
+			continue
+		}
+		key := idempotencyKey(req.entry, window)
+		uid := entryUID(req.entry, ids)
+		result, err := insertIdempotent(tx, key, window, func() (sql.Result, error) {
+			return stmt.Exec(append([]interface{}{
+				req.entry.Priority,
+				req.entry.Facility,
+				req.entry.Severity,
+				req.entry.Version,
+				storedTimestamp(req.entry.Timestamp),
+				req.entry.Hostname,
+				req.entry.AppName,
+				req.entry.ProcID,
+				req.entry.MsgID,
+				structuredDataJSON,
+				req.entry.Message,
+				rawMessage(req.entry, s.compressRaw.Load()),
+			}, append(link.columns(), key, uid)...)...)
+		})
+
+		// An entry sent again is reported as a duplicate once the batch is committed
+		if errors.Is(err, interfaces.ErrDuplicateEntry) {
+			chain.unlink(link)
+			duplicates = append(duplicates, req)
+			continue
+		}
 		if err != nil {
 			// Individual insert failed within transaction, needs individual retry
 			failedRequests = append(failedRequests, req)
@@ -474,28 +467,66 @@ func (s *BatchedSQLiteStorage) executeBatchWrite(requests []*writeRequest) error
 	// If any requests failed, rollback and retry all individually
 	if len(failedRequests) > 0 {
 		tx.Rollback()
+		chain.release()
 		// Add successful writes to failed list for individual retry
 		for _, write := range successfulWrites {
 			failedRequests = append(failedRequests, write.request)
 		}
+		failedRequests = append(failedRequests, duplicates...)
 		// Retry all requests individually
 		s.retryIndividualWrites(failedRequests, fmt.Errorf("batch contained failed requests"))
 		return fmt.Errorf("batch contained %d failed requests", len(failedRequests))
 	}
 
+	// Update rollups in the same transaction so counts always match stored rows
+	counts := newRollupCounts()
+	for _, write := range successfulWrites {
+		counts.add(write.request.entry)
+		counts.ingest(write.request.entry)
+	}
+	if err := counts.apply(tx); err != nil {
+		tx.Rollback()
+		chain.release()
+		allRequests := make([]*writeRequest, len(successfulWrites))
+		for i, write := range successfulWrites {
+			allRequests[i] = write.request
+		}
+		allRequests = append(allRequests, duplicates...)
+		s.retryIndividualWrites(allRequests, err)
+		return err
+	}
+
 	// Commit transaction
-	if err := tx.Commit(); err != nil {
+	err = injectFault(faultCommit)
+	if err != nil {
+		tx.Rollback()
+	} else {
+		err = tx.Commit()
+	}
+	if err != nil {
+		chain.release()
 		// Transaction commit failed, retry all requests individually
 		allRequests := make([]*writeRequest, len(successfulWrites))
 		for i, write := range successfulWrites {
 			allRequests[i] = write.request
 		}
+		allRequests = append(allRequests, duplicates...)
 		s.retryIndividualWrites(allRequests, err)
 		return fmt.Errorf("transaction commit failed: %w", err)
 	}
 
+	chain.commit()
+
 	// Record successful transaction
 	s.metrics.RecordDatabaseTransaction(time.Since(txStart))
+	committed := make([]*types.LogEntry, len(successfulWrites))
+	for i, write := range successfulWrites {
+		committed[i] = write.request.entry
+	}
+	metrics.GetIngestLatency().RecordCommitted(committed, time.Now())
+	for _, req := range duplicates {
+		req.sendResult(0, interfaces.ErrDuplicateEntry)
+	}
 
 	// Assign IDs to successful writes
 	for _, write := range successfulWrites {
@@ -537,32 +568,66 @@ func (s *BatchedSQLiteStorage) executeIndividualWrite(req *writeRequest) error {
 		return fmt.Errorf("failed to convert structured data: %w", err)
 	}
 
-	// Execute the insert
-	result, err := s.insertStmt.Exec(
+	chain := s.chain.Load().begin(s.db)
+	defer chain.release()
+	link, err := chain.link(req.entry, structuredDataJSON)
+	if err != nil {
+		req.sendResult(0, err)
+		return err
+	}
+
+	// Execute the insert, retrying while the database is busy: by now the batch has failed and
+	// the entry has no other way into the database
+	args := append([]interface{}{
 		req.entry.Priority,
 		req.entry.Facility,
 		req.entry.Severity,
 		req.entry.Version,
-		req.entry.Timestamp,
+		storedTimestamp(req.entry.Timestamp),
 		req.entry.Hostname,
 		req.entry.AppName,
 		req.entry.ProcID,
 		req.entry.MsgID,
 		structuredDataJSON,
 		req.entry.Message,
-	)
+		rawMessage(req.entry, s.compressRaw.Load()),
+	}, link.columns()...)
+	window := time.Duration(s.idempotencyWindow.Load())
+	key := idempotencyKey(req.entry, window)
+	args = append(args, key, entryUID(req.entry, s.entryIDs.Load()))
+	var id int64
+	backoff := individualWriteBackoff
+	for attempt := 1; ; attempt++ {
+		if err = injectFault(faultExec); err == nil {
+			id, err = insertWithRollups(s.db, req.entry, key, window, func(tx *sql.Tx) (sql.Result, error) {
+				stmt := tx.Stmt(s.insertStmt)
+				defer stmt.Close()
+				return stmt.Exec(args...)
+			})
+		}
+		if err == nil || !resilience.IsTransient(err) || attempt == individualWriteAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		case <-req.ctx.Done():
+		}
+		err = fmt.Errorf("request cancelled while database was busy: %w", err)
+		break
+	}
 
+	if errors.Is(err, interfaces.ErrDuplicateEntry) {
+		req.sendResult(0, err)
+		return nil
+	}
 	if err != nil {
 		req.sendResult(0, fmt.Errorf("individual insert failed: %w", err))
 		return fmt.Errorf("individual insert failed: %w", err)
 	}
-
-	// Get the assigned ID
-	id, err := result.LastInsertId()
-	if err != nil {
-		req.sendResult(0, fmt.Errorf("failed to get insert ID: %w", err))
-		return fmt.Errorf("failed to get insert ID: %w", err)
-	}
+	chain.commit()
+	metrics.GetIngestLatency().RecordCommitted([]*types.LogEntry{req.entry}, time.Now())
 
 	// Send successful result
 	req.sendResult(id, nil)
@@ -591,6 +656,7 @@ func (s *BatchedSQLiteStorage) cleanup() {
 	if s.batchStmt != nil && s.batchStmt != s.insertStmt {
 		s.batchStmt.Close()
 	}
+	s.promotions.stop()
 	if s.db != nil {
 		s.db.Close()
 	}
@@ -601,6 +667,16 @@ func (s *BatchedSQLiteStorage) cleanup() {
 
 // Store saves a log entry to the database (non-blocking)
 func (s *BatchedSQLiteStorage) Store(entry *types.LogEntry) error {
+	return s.store(entry, nil)
+}
+
+// StoreNotify saves a log entry to the database like Store, calling committed once it is written
+func (s *BatchedSQLiteStorage) StoreNotify(entry *types.LogEntry, committed func(error)) error {
+	return s.store(entry, committed)
+}
+
+// store queues a write request for an entry, with an optional function called with its result
+func (s *BatchedSQLiteStorage) store(entry *types.LogEntry, committed func(error)) error {
 	start := time.Now()
 
 	// Check if storage is running
@@ -618,11 +694,12 @@ func (s *BatchedSQLiteStorage) Store(entry *types.LogEntry) error {
 	s.metrics.UpdateQueueUtilization(queueLen, s.config.QueueSize)
 	s.metrics.UpdateBatchQueueSize(queueLen)
 
-	// Create write request with context
+	// Create write request with context; Store returns before the write is processed, so the
+	// context lives until the result is sent rather than until Store returns
 	ctx, cancel := context.WithTimeout(s.ctx, s.config.WriteTimeout)
-	defer cancel()
-
 	req := newWriteRequest(entry, ctx)
+	req.cancel = cancel
+	req.committed = committed
 
 	// Try to send request to queue (non-blocking)
 	select {
@@ -652,6 +729,7 @@ func (s *BatchedSQLiteStorage) Store(entry *types.LogEntry) error {
 
 	default:
 		// Queue is full, apply backpressure
+		cancel()
 		err := fmt.Errorf("write queue is full, please try again later")
 		s.metrics.RecordQueueFullError()
 		s.metrics.RecordWriteRequest(time.Since(start), err)
@@ -666,93 +744,18 @@ func (s *BatchedSQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntr
 	defer func() {
 		s.metrics.RecordReadRequest(time.Since(start), err)
 	}()
-	var conditions []string
-	var args []interface{}
-
-	baseQuery := `SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at FROM logs`
-
-	// Handle full-text search
-	if query.Text != "" {
-		baseQuery = `
-		SELECT l.id, l.priority, l.facility, l.severity, l.version, l.timestamp, l.hostname, l.app_name, l.proc_id, l.msg_id, l.structured_data, l.message, l.created_at
-		FROM logs l 
-		JOIN logs_fts fts ON l.id = fts.rowid 
-		WHERE logs_fts MATCH ?`
-		args = append(args, query.Text)
-	}
-
-	// Add RFC5424 filters
-	if query.Facility != nil {
-		conditions = append(conditions, "facility = ?")
-		args = append(args, *query.Facility)
-	}
-
-	if query.Severity != nil {
-		conditions = append(conditions, "severity = ?")
-		args = append(args, *query.Severity)
-	}
-
-	if query.MinSeverity != nil {
-		conditions = append(conditions, "severity <= ?")
-		args = append(args, *query.MinSeverity)
-	}
-
-	if query.Hostname != "" {
-		conditions = append(conditions, "hostname = ?")
-		args = append(args, query.Hostname)
-	}
-
-	if query.AppName != "" {
-		conditions = append(conditions, "app_name = ?")
-		args = append(args, query.AppName)
-	}
-
-	if query.ProcID != "" {
-		conditions = append(conditions, "proc_id = ?")
-		args = append(args, query.ProcID)
-	}
-
-	if query.MsgID != "" {
-		conditions = append(conditions, "msg_id = ?")
-		args = append(args, query.MsgID)
-	}
 
-	if query.StartTime != nil {
-		conditions = append(conditions, "timestamp >= ?")
-		args = append(args, query.StartTime)
-	}
-
-	if query.EndTime != nil {
-		conditions = append(conditions, "timestamp <= ?")
-		args = append(args, query.EndTime)
-	}
-
-	// Handle structured data query (basic JSON search)
-	if query.StructuredDataQuery != "" {
-		conditions = append(conditions, "structured_data LIKE ?")
-		args = append(args, "%"+query.StructuredDataQuery+"%")
-	}
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		if query.Text != "" {
-			baseQuery += " AND " + strings.Join(conditions, " AND ")
-		} else {
-			baseQuery += " WHERE " + strings.Join(conditions, " AND ")
-		}
-	}
-
-	// Add ordering and limits
-	baseQuery += " ORDER BY timestamp DESC"
-
-	if query.Limit > 0 {
-		baseQuery += " LIMIT ?"
-		args = append(args, query.Limit)
+	columns, columnsErr := searchColumns(query.Fields)
+	if columnsErr != nil {
+		err = columnsErr
+		return nil, err
 	}
-
-	if query.Offset > 0 {
-		baseQuery += " OFFSET ?"
-		args = append(args, query.Offset)
+	// Text searches report where the terms matched the message
+	highlight := query.Text != "" && slices.Contains(columns, "message")
+	baseQuery, args, statementErr := searchStatement(s.db, query, s.promotions, columns, highlight)
+	if statementErr != nil {
+		err = statementErr
+		return nil, err
 	}
 
 	rows, queryErr := s.db.Query(baseQuery, args...)
@@ -764,24 +767,10 @@ func (s *BatchedSQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntr
 
 	var entries []*types.LogEntry
 	for rows.Next() {
-		entry := &types.LogEntry{}
-		var structuredDataJSON sql.NullString
-
-		err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
-			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
-			&structuredDataJSON, &entry.Message, &entry.CreatedAt)
+		entry, err := scanSearchRow(rows, columns, highlight)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan log entry: %w", err)
-		}
-
-		// Parse structured data JSON
-		if structuredDataJSON.Valid && structuredDataJSON.String != "" {
-			var structuredData map[string]interface{}
-			if err := json.Unmarshal([]byte(structuredDataJSON.String), &structuredData); err == nil {
-				entry.StructuredData = structuredData
-			}
+			return nil, err
 		}
-
 		entries = append(entries, entry)
 	}
 
@@ -795,9 +784,9 @@ func (s *BatchedSQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntr
 // GetRecent retrieves the most recent log entries up to the specified limit
 func (s *BatchedSQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 	query := `
-	SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at
+	SELECT id, uid, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at
 	FROM logs 
-	ORDER BY timestamp DESC 
+	ORDER BY ` + entryOrder + `
 	LIMIT ?
 	`
 
@@ -810,14 +799,15 @@ func (s *BatchedSQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 	var entries []*types.LogEntry
 	for rows.Next() {
 		entry := &types.LogEntry{}
-		var structuredDataJSON sql.NullString
+		var uid, structuredDataJSON sql.NullString
 
-		err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+		err := rows.Scan(&entry.ID, &uid, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
 			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
 			&structuredDataJSON, &entry.Message, &entry.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan log entry: %w", err)
 		}
+		entry.UID = uid.String
 
 		// Parse structured data JSON
 		if structuredDataJSON.Valid && structuredDataJSON.String != "" {
@@ -839,17 +829,28 @@ func (s *BatchedSQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 
 // Cleanup removes log entries older than the specified retention period
 func (s *BatchedSQLiteStorage) Cleanup(retentionDays int) error {
+	_, err := s.CleanupExpired(retentionDays)
+	return err
+}
+
+// CleanupExpired removes log entries older than the specified retention period and returns how
+// many were removed
+func (s *BatchedSQLiteStorage) CleanupExpired(retentionDays int) (int64, error) {
 	cutoffTime := time.Now().AddDate(0, 0, -retentionDays)
 
 	query := "DELETE FROM logs WHERE timestamp < ?"
-	result, err := s.db.Exec(query, cutoffTime)
+	result, err := s.db.Exec(query, storedTimestamp(cutoffTime))
 	if err != nil {
-		return fmt.Errorf("failed to cleanup old logs: %w", err)
+		return 0, fmt.Errorf("failed to cleanup old logs: %w", err)
+	}
+
+	if err := cleanupRollups(s.db, cutoffTime); err != nil {
+		return 0, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get cleanup result: %w", err)
+		return 0, fmt.Errorf("failed to get cleanup result: %w", err)
 	}
 
 	// Run VACUUM to reclaim space after cleanup, but only if WAL mode is enabled
@@ -864,11 +865,11 @@ func (s *BatchedSQLiteStorage) Cleanup(retentionDays int) error {
 		}
 
 		if _, err := s.db.Exec("VACUUM"); err != nil {
-			return fmt.Errorf("failed to vacuum database: %w", err)
+			return rowsAffected, fmt.Errorf("failed to vacuum database: %w", err)
 		}
 	}
 
-	return nil
+	return rowsAffected, nil
 }
 
 // checkpointWAL performs a WAL checkpoint to ensure data is written to main database
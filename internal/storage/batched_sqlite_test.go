@@ -532,6 +532,54 @@ func TestBatchedSQLiteStorage_Store_Success(t *testing.T) {
 	}
 }
 
+func TestBatchedSQLiteStorage_StoreNotify(t *testing.T) {
+	dbPath := "test_store_notify.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	config := DefaultBatchConfig()
+	config.BatchSize = 5
+	config.BatchTimeout = 50 * time.Millisecond
+
+	storage, err := NewBatchedSQLiteStorage(dbPath, config)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	notifier, ok := storage.(interfaces.CommitNotifier)
+	if !ok {
+		t.Fatal("Expected batched storage to notify commits")
+	}
+	committed := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		entry := &types.LogEntry{Priority: 14, Version: 1, Timestamp: time.Now(), Hostname: "host", Message: fmt.Sprintf("entry %d", i)}
+		if err := notifier.StoreNotify(entry, func(err error) { committed <- err }); err != nil {
+			t.Fatalf("StoreNotify failed: %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-committed:
+			if err != nil {
+				t.Errorf("Expected the entry to be committed, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the commit notification")
+		}
+	}
+
+	recent, err := storage.GetRecent(10)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if len(recent) != 3 {
+		t.Errorf("Expected the 3 notified entries to be stored, got %d", len(recent))
+	}
+}
+
 func TestBatchedSQLiteStorage_Store_QueueFull(t *testing.T) {
 	dbPath := "test_store_queue_full.db"
 	defer os.Remove(dbPath)
@@ -231,6 +231,27 @@ func BenchmarkReadPerformance_Batched(b *testing.B) {
 	b.StopTimer()
 }
 
+// BenchmarkSearch_Shapes benchmarks the frequent filter combinations on 200,000 entries, e.g.
+// go test -run XXX -bench Search_Shapes ./internal/storage
+func BenchmarkSearch_Shapes(b *testing.B) {
+	storage, err := NewSQLiteStorage(b.TempDir() + "/bench_search_shapes.db")
+	if err != nil {
+		b.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+	populateSearchShapes(b, storage.(*SQLiteStorage).db, 200000)
+
+	for name, query := range searchShapes() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := storage.Search(query); err != nil {
+					b.Fatalf("Search failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkBatchSizeOptimization benchmarks different batch sizes
 func BenchmarkBatchSizeOptimization(b *testing.B) {
 	batchSizes := []int{10, 25, 50, 100, 200, 500}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// hashChain links stored entries in one SHA-256 chain per partition, the UTC day of the entry's
+// timestamp, so retention only ever trims a chain from its start. Each hash covers the
+// previous hash and every stored field, so altering, removing or reordering an entry breaks the
+// chain from that point on.
+type hashChain struct {
+	mu    sync.Mutex
+	heads map[string]string
+}
+
+func newHashChain() *hashChain {
+	return &hashChain{heads: make(map[string]string)}
+}
+
+// setHashChain enables the chain of a storage, keeping the current one if already enabled
+func setHashChain(chain *atomic.Pointer[hashChain], enabled bool) {
+	if !enabled {
+		chain.Store(nil)
+		return
+	}
+	chain.CompareAndSwap(nil, newHashChain())
+}
+
+// chainLink holds the chain columns of one entry; the zero value stores NULLs
+type chainLink struct {
+	partition string
+	prev      string
+	hash      string
+}
+
+// columns returns the chain_partition, chain_prev and chain_hash values to insert
+func (l chainLink) columns() []interface{} {
+	if l.hash == "" {
+		return []interface{}{nil, nil, nil}
+	}
+	return []interface{}{l.partition, l.prev, l.hash}
+}
+
+// chainWrite links the entries of one write. The chain stays locked until the write is committed
+// or released, so the heads it advances are only kept for entries that were actually stored.
+type chainWrite struct {
+	chain *hashChain
+	db    queryRower
+	heads map[string]string
+	done  bool
+}
+
+// begin locks the chain for a write through db; it returns nil when the chain is disabled
+func (c *hashChain) begin(db queryRower) *chainWrite {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	return &chainWrite{chain: c, db: db, heads: make(map[string]string)}
+}
+
+// link computes the chain columns of an entry about to be inserted
+func (w *chainWrite) link(entry *types.LogEntry, structuredData string) (chainLink, error) {
+	if w == nil {
+		return chainLink{}, nil
+	}
+
+	partition := entry.Timestamp.UTC().Format(types.ChainPartitionLayout)
+	prev, ok := w.heads[partition]
+	if !ok {
+		prev, ok = w.chain.heads[partition]
+	}
+	if !ok {
+		err := w.db.QueryRow(`
+		SELECT chain_hash FROM logs
+		WHERE chain_partition = ? AND chain_hash IS NOT NULL
+		ORDER BY id DESC LIMIT 1`, partition).Scan(&prev)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return chainLink{}, fmt.Errorf("failed to read hash chain head: %w", err)
+		}
+	}
+
+	link := chainLink{
+		partition: partition,
+		prev:      prev,
+		hash:      chainHash(prev, partition, entry, structuredData),
+	}
+	w.heads[partition] = link.hash
+	return link, nil
+}
+
+// unlink takes back the link of an entry that was not inserted after all, the last one linked
+func (w *chainWrite) unlink(link chainLink) {
+	if w == nil || link.hash == "" {
+		return
+	}
+	w.heads[link.partition] = link.prev
+}
+
+// commit keeps the heads advanced by the write and unlocks the chain
+func (w *chainWrite) commit() {
+	if w == nil || w.done {
+		return
+	}
+	for partition, head := range w.heads {
+		w.chain.heads[partition] = head
+	}
+	w.release()
+}
+
+// release unlocks the chain, discarding the heads of a write that was not stored
+func (w *chainWrite) release() {
+	if w == nil || w.done {
+		return
+	}
+	w.done = true
+	w.chain.mu.Unlock()
+}
+
+// chainHash hashes an entry's stored fields together with the previous hash of its partition.
+// Every field is length-prefixed so no two different entries produce the same input.
+func chainHash(prev, partition string, entry *types.LogEntry, structuredData string) string {
+	fields := []string{
+		prev,
+		partition,
+		strconv.Itoa(entry.Priority),
+		strconv.Itoa(entry.Facility),
+		strconv.Itoa(entry.Severity),
+		strconv.Itoa(entry.Version),
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		entry.Hostname,
+		entry.AppName,
+		entry.ProcID,
+		entry.MsgID,
+		structuredData,
+		entry.Message,
+		entry.Raw,
+	}
+
+	hash := sha256.New()
+	var length [8]byte
+	for _, field := range fields {
+		binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+		hash.Write(length[:])
+		hash.Write([]byte(field))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// verifyChain walks the chained entries of one partition, or all of them, in insertion order and
+// recomputes every hash. A partition is invalid from the first entry whose recorded previous hash
+// does not match the entry before it, or whose content no longer matches its hash.
+func verifyChain(db *sql.DB, partition string) (*types.ChainReport, error) {
+	query := `
+	SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id,
+		structured_data, message, raw_message, chain_partition, chain_prev, chain_hash
+	FROM logs WHERE chain_hash IS NOT NULL`
+	var args []interface{}
+	if partition != "" {
+		query += " AND chain_partition = ?"
+		args = append(args, partition)
+	}
+	query += " ORDER BY chain_partition, id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select chained entries: %w", err)
+	}
+	defer rows.Close()
+
+	report := &types.ChainReport{Valid: true, Partitions: []types.ChainPartition{}}
+	var current *types.ChainPartition
+	var head string
+	for rows.Next() {
+		entry := &types.LogEntry{}
+		var structuredData, prev sql.NullString
+		var raw interface{}
+		var entryPartition, hash string
+		if err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
+			&structuredData, &entry.Message, &raw, &entryPartition, &prev, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan chained entry: %w", err)
+		}
+		if entry.Raw, err = decodeRawMessage(raw); err != nil {
+			return nil, fmt.Errorf("failed to decode raw message of entry %d: %w", entry.ID, err)
+		}
+
+		if current == nil || current.Partition != entryPartition {
+			report.Partitions = append(report.Partitions, types.ChainPartition{
+				Partition: entryPartition,
+				FirstID:   entry.ID,
+				Anchor:    prev.String,
+				Valid:     true,
+			})
+			current = &report.Partitions[len(report.Partitions)-1]
+			head = prev.String
+		}
+		current.Entries++
+		current.LastID = entry.ID
+		current.Head = hash
+		report.Entries++
+
+		if !current.Valid {
+			continue
+		}
+		switch {
+		case prev.String != head:
+			current.Valid = false
+			current.BrokenAt = entry.ID
+			current.Problem = "previous hash does not match the preceding entry, entries were removed or reordered"
+		case chainHash(head, entryPartition, entry, structuredData.String) != hash:
+			current.Valid = false
+			current.BrokenAt = entry.ID
+			current.Problem = "entry content does not match its hash"
+		}
+		if !current.Valid {
+			report.Valid = false
+		}
+		head = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chained entries: %w", err)
+	}
+
+	report.VerifiedAt = time.Now()
+	return report, nil
+}
+
+// SetHashChain selects whether new entries are linked in the hash chain
+func (s *SQLiteStorage) SetHashChain(enabled bool) {
+	setHashChain(&s.chain, enabled)
+}
+
+// VerifyChain recomputes the hash chain of one partition, or of all partitions when empty
+func (s *SQLiteStorage) VerifyChain(partition string) (*types.ChainReport, error) {
+	return verifyChain(s.db, partition)
+}
+
+// SetHashChain selects whether new entries are linked in the hash chain
+func (s *BatchedSQLiteStorage) SetHashChain(enabled bool) {
+	setHashChain(&s.chain, enabled)
+}
+
+// VerifyChain recomputes the hash chain of one partition, or of all partitions when empty
+func (s *BatchedSQLiteStorage) VerifyChain(partition string) (*types.ChainReport, error) {
+	return verifyChain(s.db, partition)
+}
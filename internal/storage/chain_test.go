@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_HashChain(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 23, 58, 0, 0, time.UTC)
+	// Entries stored before the chain is enabled are not part of it
+	if err := storage.Store(&types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: base, Message: "unchained"}); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	storage.SetHashChain(true)
+	var ids []int64
+	store := func(i int) {
+		entry := &types.LogEntry{
+			Version: 1, Priority: 134, Facility: 16, Severity: 6, Hostname: "web01", AppName: "api",
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Message: "request handled", Raw: "raw line",
+			StructuredData: map[string]interface{}{"request": map[string]interface{}{"id": i}},
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+		ids = append(ids, entry.ID)
+	}
+	for i := 0; i < 4; i++ {
+		store(i)
+	}
+
+	// Re-enabling drops the cached heads, so the chain must continue from the stored ones
+	storage.SetHashChain(false)
+	storage.SetHashChain(true)
+	store(4)
+
+	report, err := storage.VerifyChain("")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !report.Valid || report.Entries != 5 || len(report.Partitions) != 2 {
+		t.Fatalf("Expected two valid partitions of 5 entries, got %+v", report)
+	}
+	first := report.Partitions[0]
+	if first.Partition != "2024-05-01" || first.Entries != 2 || first.FirstID != ids[0] || first.LastID != ids[1] || first.Anchor != "" {
+		t.Errorf("Unexpected first partition: %+v", first)
+	}
+	if second := report.Partitions[1]; second.Partition != "2024-05-02" || second.Entries != 3 || second.LastID != ids[4] {
+		t.Errorf("Unexpected second partition: %+v", second)
+	}
+
+	single, err := storage.VerifyChain("2024-05-02")
+	if err != nil || len(single.Partitions) != 1 || single.Partitions[0].Head != report.Partitions[1].Head {
+		t.Errorf("Expected a single partition with the same head, got %+v, %v", single, err)
+	}
+
+	// Altering an entry breaks its partition from that entry on
+	if _, err := storage.db.Exec("UPDATE logs SET message = 'nothing happened' WHERE id = ?", ids[3]); err != nil {
+		t.Fatalf("Failed to alter entry: %v", err)
+	}
+	report, err = storage.VerifyChain("")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if report.Valid || !report.Partitions[0].Valid {
+		t.Errorf("Expected only the second partition to be invalid, got %+v", report)
+	}
+	if broken := report.Partitions[1]; broken.Valid || broken.BrokenAt != ids[3] || broken.Problem == "" {
+		t.Errorf("Expected alteration to be detected at %d, got %+v", ids[3], broken)
+	}
+
+	// Removing an entry breaks the link of the next one
+	if _, err := storage.db.Exec("DELETE FROM logs WHERE id = ?", ids[0]+1); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if _, err := storage.db.Exec("DELETE FROM logs WHERE id = ?", ids[0]); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	report, err = storage.VerifyChain("2024-05-01")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if len(report.Partitions) != 0 {
+		t.Errorf("Expected emptied partition to be absent, got %+v", report.Partitions)
+	}
+	if _, err := storage.db.Exec("DELETE FROM logs WHERE id = ?", ids[2]); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	report, err = storage.VerifyChain("2024-05-02")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if trimmed := report.Partitions[0]; trimmed.Valid || trimmed.Anchor == "" || trimmed.BrokenAt != ids[3] {
+		t.Errorf("Expected the trimmed chain to be anchored and still broken at %d, got %+v", ids[3], trimmed)
+	}
+}
+
+func TestSQLiteStorage_HashChainSkipsReprocessing(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+	storage.SetHashChain(true)
+
+	entry := &types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: "disk full", Raw: "ERROR disk full"}
+	if err := storage.Store(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	batch, err := storage.ReprocessBatch(types.ReprocessQuery{}, 0, 10, levelParser(map[string]int{"ERROR": 3}))
+	if err != nil || batch.Processed != 0 {
+		t.Errorf("Expected chained entries not to be reprocessed, got %+v, %v", batch, err)
+	}
+	report, err := storage.VerifyChain("")
+	if err != nil || !report.Valid || report.Entries != 1 {
+		t.Errorf("Expected the chain to stay valid, got %+v, %v", report, err)
+	}
+}
+
+func TestBatchedSQLiteStorage_HashChain(t *testing.T) {
+	created, err := NewBatchedSQLiteStorage(t.TempDir()+"/batched_chain.db", DefaultBatchConfig())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*BatchedSQLiteStorage)
+	defer storage.Close()
+	storage.SetHashChain(true)
+	storage.SetRawCompression(true)
+
+	now := time.Now().UTC()
+	var requests []*writeRequest
+	for i := 0; i < 3; i++ {
+		entry := &types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: now, Message: "batched",
+			Raw: "a raw message long enough to be worth compressing, compressing, compressing, compressing"}
+		requests = append(requests, newWriteRequest(entry, context.Background()))
+	}
+	if err := storage.executeBatchWrite(requests[:2]); err != nil {
+		t.Fatalf("Batch write failed: %v", err)
+	}
+	if err := storage.executeIndividualWrite(requests[2]); err != nil {
+		t.Fatalf("Individual write failed: %v", err)
+	}
+
+	report, err := storage.VerifyChain("")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !report.Valid || report.Entries != 3 || len(report.Partitions) != 1 {
+		t.Errorf("Expected one valid partition of 3 entries, got %+v", report)
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+	"opentrail/internal/types"
+)
+
+const (
+	// checkpointPollInterval is how often the checkpoint policy measures the write-ahead log
+	checkpointPollInterval = time.Second
+	// checkpointBusyBackoff is how long the policy waits after readers or writers kept a
+	// checkpoint from completing, as restart and truncate checkpoints hold up writers meanwhile
+	checkpointBusyBackoff = 10 * time.Second
+)
+
+// Triggers of a checkpoint, as counted in metrics
+const (
+	checkpointTriggerPages    = "pages"
+	checkpointTriggerSchedule = "schedule"
+	checkpointTriggerManual   = "manual"
+)
+
+// autoCheckpointPages returns the wal_autocheckpoint of the connections of a storage. SQLite's own
+// checkpoints are passive, so the policy runs the others itself.
+func autoCheckpointPages(config BatchConfig) int {
+	if config.CheckpointMode != types.CheckpointPassive {
+		return 0
+	}
+	return *config.CheckpointPages
+}
+
+// checkpoint runs a checkpoint of the write-ahead log of db in a mode, counted in metrics under
+// the trigger it was run by
+func checkpoint(db *sql.DB, mode, trigger string) (*types.CheckpointResult, error) {
+	if !slices.Contains(types.CheckpointModes, mode) {
+		return nil, fmt.Errorf("unknown checkpoint mode %q, expected one of %s", mode, strings.Join(types.CheckpointModes, ", "))
+	}
+
+	start := time.Now()
+	var busy int
+	result := &types.CheckpointResult{Mode: mode}
+	if err := db.QueryRow("PRAGMA wal_checkpoint("+strings.ToUpper(mode)+")").Scan(&busy, &result.WALFrames, &result.CheckpointedFrames); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint write-ahead log: %w", err)
+	}
+	elapsed := time.Since(start)
+	if result.WALFrames < 0 {
+		// Outside WAL mode SQLite reports -1 frames
+		return nil, interfaces.ErrWALDisabled
+	}
+	result.Busy = busy != 0
+	result.DurationMs = float64(elapsed.Microseconds()) / 1000
+
+	var seq int
+	var name, file string
+	if err := db.QueryRow("PRAGMA database_list").Scan(&seq, &name, &file); err == nil && file != "" {
+		if info, err := os.Stat(file + "-wal"); err == nil {
+			result.WALBytes = info.Size()
+		}
+	}
+
+	checkpointMetrics := metrics.GetCheckpointMetrics()
+	checkpointMetrics.Checkpoints.WithLabelValues(mode, trigger).Inc()
+	checkpointMetrics.Duration.Observe(elapsed.Seconds())
+	if result.Busy {
+		checkpointMetrics.Busy.WithLabelValues(mode).Inc()
+	}
+	return result, nil
+}
+
+// walFrames returns the number of frames in the current cycle of a write-ahead log. Once a
+// checkpoint has written the whole log, writers start over at its beginning with new salts in its
+// header, so the frames of the previous cycle that follow are not counted: the log's size only
+// tells how large it once grew.
+func walFrames(walPath string) int64 {
+	file, err := os.Open(walPath)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() <= walHeaderSize {
+		return 0
+	}
+	header, pageSize, ok := readWALHeader(file)
+	if !ok {
+		return 0
+	}
+	frames := (info.Size() - walHeaderSize) / (pageSize + walFrameHeaderSize)
+
+	// The frames of the current cycle carry the salts of the header and precede all others
+	salts := header[16:24]
+	frameSalts := make([]byte, len(salts))
+	return int64(sort.Search(int(frames), func(i int) bool {
+		offset := walHeaderSize + int64(i)*(pageSize+walFrameHeaderSize) + 8
+		if _, err := file.ReadAt(frameSalts, offset); err != nil {
+			return true
+		}
+		return !bytes.Equal(frameSalts, salts)
+	}))
+}
+
+// checkpointer measures the write-ahead log and applies the checkpoint policy until the storage is
+// closed: a checkpoint in the configured mode once the log holds the configured pages, unless SQLite
+// runs it on commit, and a truncating checkpoint every truncate interval
+func (s *BatchedSQLiteStorage) checkpointer() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(checkpointPollInterval)
+	defer ticker.Stop()
+
+	pages := int64(*s.config.CheckpointPages)
+	lastTruncate := time.Now()
+	var backoffUntil time.Time
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if s.config.TruncateInterval > 0 && now.Sub(lastTruncate) >= s.config.TruncateInterval {
+				lastTruncate = now
+				s.policyCheckpoint(types.CheckpointTruncate, checkpointTriggerSchedule)
+				continue
+			}
+
+			frames := walFrames(s.dbPath + "-wal")
+			metrics.GetCheckpointMetrics().WALFrames.Set(float64(frames))
+			if autoCheckpointPages(s.config) > 0 || pages == 0 || frames < pages || now.Before(backoffUntil) {
+				continue
+			}
+			if result := s.policyCheckpoint(s.config.CheckpointMode, checkpointTriggerPages); result != nil && result.Busy {
+				backoffUntil = now.Add(checkpointBusyBackoff)
+			}
+		}
+	}
+}
+
+// policyCheckpoint runs a checkpoint of the policy, logging failures and incomplete checkpoints
+func (s *BatchedSQLiteStorage) policyCheckpoint(mode, trigger string) *types.CheckpointResult {
+	result, err := checkpoint(s.db, mode, trigger)
+	if err != nil {
+		log.Printf("Failed to run %s checkpoint of the write-ahead log: %v", mode, err)
+		return nil
+	}
+	if result.Busy {
+		log.Printf("Readers or writers kept the %s checkpoint of the write-ahead log from completing, %d of %d frames written",
+			mode, result.CheckpointedFrames, result.WALFrames)
+	}
+	return result
+}
+
+// Checkpoint writes the frames of the write-ahead log to the database in a mode
+func (s *BatchedSQLiteStorage) Checkpoint(mode string) (*types.CheckpointResult, error) {
+	if !*s.config.WALEnabled {
+		return nil, interfaces.ErrWALDisabled
+	}
+	return checkpoint(s.db, mode, checkpointTriggerManual)
+}
+
+// Checkpoint writes the frames of the write-ahead log to the database in a mode
+func (s *SQLiteStorage) Checkpoint(mode string) (*types.CheckpointResult, error) {
+	return checkpoint(s.db, mode, checkpointTriggerManual)
+}
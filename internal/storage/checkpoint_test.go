@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestBatchedSQLiteStorage_Checkpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "logs.db")
+	config := DefaultBatchConfig()
+	config.BatchTimeout = time.Millisecond
+	// Only manual checkpoints, so the log keeps what is written
+	pages := 0
+	config.CheckpointPages = &pages
+	opened, err := NewBatchedSQLiteStorage(dbPath, config)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer opened.Close()
+	storage := opened.(*BatchedSQLiteStorage)
+
+	var autoCheckpoint int
+	if err := storage.db.QueryRow("PRAGMA wal_autocheckpoint").Scan(&autoCheckpoint); err != nil || autoCheckpoint != 0 {
+		t.Errorf("Expected SQLite's auto-checkpoint disabled, got %d (%v)", autoCheckpoint, err)
+	}
+
+	// Store returns before the entry is written
+	store := func(entry *types.LogEntry) {
+		committed := make(chan error, 1)
+		if err := storage.StoreNotify(entry, func(err error) { committed <- err }); err != nil {
+			t.Fatalf("StoreNotify failed: %v", err)
+		}
+		if err := <-committed; err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		store(&types.LogEntry{Priority: 14, Timestamp: base.Add(time.Duration(i) * time.Second), AppName: "api", Message: fmt.Sprintf("entry %d", i)})
+	}
+	frames := walFrames(dbPath + "-wal")
+	if frames == 0 {
+		t.Fatal("Expected frames in the write-ahead log")
+	}
+
+	if _, err := storage.Checkpoint("full"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+
+	result, err := storage.Checkpoint(types.CheckpointRestart)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if result.Busy || result.WALFrames != frames || result.CheckpointedFrames != frames || result.WALBytes == 0 {
+		t.Errorf("Expected all %d frames written and the log kept, got %+v", frames, result)
+	}
+
+	// Writers start over at the beginning of the log, which keeps its size
+	store(&types.LogEntry{Priority: 14, Timestamp: base, AppName: "api", Message: "after restart"})
+	if restarted := walFrames(dbPath + "-wal"); restarted == 0 || restarted >= frames {
+		t.Errorf("Expected the frames of the new cycle counted, got %d of %d", restarted, frames)
+	}
+
+	result, err = storage.Checkpoint(types.CheckpointTruncate)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if info, err := os.Stat(dbPath + "-wal"); err != nil || info.Size() != 0 || result.WALBytes != 0 {
+		t.Errorf("Expected the log truncated, got %+v", result)
+	}
+	if walFrames(dbPath+"-wal") != 0 {
+		t.Error("Expected no frames in a truncated log")
+	}
+}
+
+func TestBatchedSQLiteStorage_CheckpointPolicy(t *testing.T) {
+	config := DefaultBatchConfig()
+	if autoCheckpointPages(config) != 1000 {
+		t.Errorf("Expected passive checkpoints left to SQLite, got %d", autoCheckpointPages(config))
+	}
+	config.CheckpointMode = types.CheckpointTruncate
+	if autoCheckpointPages(config) != 0 {
+		t.Errorf("Expected SQLite's auto-checkpoint disabled for truncate, got %d", autoCheckpointPages(config))
+	}
+	config.CheckpointMode = "full"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an unknown checkpoint mode to be rejected")
+	}
+
+	disabled := false
+	config = BatchConfig{WALEnabled: &disabled}
+	store, err := NewBatchedSQLiteStorage(filepath.Join(t.TempDir(), "logs.db"), config)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.(*BatchedSQLiteStorage).Checkpoint(types.CheckpointTruncate); !errors.Is(err, interfaces.ErrWALDisabled) {
+		t.Errorf("Expected ErrWALDisabled without a write-ahead log, got %v", err)
+	}
+}
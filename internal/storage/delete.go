@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// deleteBatchSize bounds the entries deleted per transaction, so a large purge does not hold the
+// write lock, and block ingestion, for long
+const deleteBatchSize = 1000
+
+// matchCondition returns the WHERE clause and arguments selecting the entries a search query
+// matches, with the text search expressed as a subquery on the FTS table
+func matchCondition(query types.SearchQuery, promotions *fieldPromotions) (string, []interface{}) {
+	conditions, args := searchConditions(query, promotions)
+	if query.Text != "" {
+		conditions = append(conditions, "id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)")
+		args = append(args, query.Text)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// deleteMatching deletes the entries a search query matches and takes them out of the rollups,
+// facets and field catalog, returning how many were deleted. With dryRun it only counts them.
+func deleteMatching(db *sql.DB, query types.SearchQuery, promotions *fieldPromotions, dryRun bool) (int64, error) {
+	where, args := matchCondition(query, promotions)
+
+	if dryRun {
+		var count int64
+		if err := db.QueryRow("SELECT COUNT(*) FROM logs"+where, args...).Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to count matching entries: %w", err)
+		}
+		return count, nil
+	}
+
+	var deleted int64
+	for {
+		n, err := deleteBatch(db, where, args)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+		if n < deleteBatchSize {
+			break
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	// Deleted content lingers in FTS segments and free pages until they are rewritten, which a
+	// purge of sensitive data cannot leave to chance
+	if _, err := db.Exec("INSERT INTO logs_fts(logs_fts) VALUES('optimize')"); err != nil {
+		return deleted, fmt.Errorf("failed to optimize full-text index: %w", err)
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return deleted, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	// The WAL's frames still hold the deleted content until it is truncated; outside WAL mode this
+	// is a no-op
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return deleted, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteBatch deletes up to deleteBatchSize matching entries in one transaction
+func deleteBatch(db *sql.DB, where string, args []interface{}) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, timestamp, severity, COALESCE(app_name, ''), COALESCE(hostname, ''),
+		COALESCE(msg_id, ''), COALESCE(structured_data, '') FROM logs`+where+" LIMIT ?",
+		append(slices.Clip(args), deleteBatchSize)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select entries to delete: %w", err)
+	}
+	counts := newRollupCounts()
+	var ids []interface{}
+	for rows.Next() {
+		var entry types.LogEntry
+		var structuredData string
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Severity, &entry.AppName, &entry.Hostname,
+			&entry.MsgID, &structuredData); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan entry to delete: %w", err)
+		}
+		if structuredData != "" {
+			json.Unmarshal([]byte(structuredData), &entry.StructuredData)
+		}
+		counts.purge(&entry)
+		ids = append(ids, entry.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to select entries to delete: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	if _, err := tx.Exec("DELETE FROM logs WHERE id IN ("+placeholders+")", ids...); err != nil {
+		return 0, fmt.Errorf("failed to delete entries: %w", err)
+	}
+	if err := counts.apply(tx); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit deletion: %w", err)
+	}
+	return int64(len(ids)), nil
+}
+
+// DeleteMatching deletes the entries a search query matches, or only counts them with dryRun
+func (s *SQLiteStorage) DeleteMatching(query types.SearchQuery, dryRun bool) (int64, error) {
+	return deleteMatching(s.db, query, s.promotions, dryRun)
+}
+
+// DeleteMatching deletes the entries a search query matches, or only counts them with dryRun
+func (s *BatchedSQLiteStorage) DeleteMatching(query types.SearchQuery, dryRun bool) (int64, error) {
+	return deleteMatching(s.db, query, s.promotions, dryRun)
+}
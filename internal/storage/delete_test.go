@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_DeleteMatching(t *testing.T) {
+	path := t.TempDir() + "/delete.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+	defer storage.Close()
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	entries := []struct{ host, message, token string }{
+		{"web-1", "login ok", ""},
+		{"web-1", "password=hunter2 leaked", "abc123"},
+		{"web-2", "password=swordfish leaked", "def456"},
+		{"db-1", "checkpoint complete", ""},
+	}
+	for i, e := range entries {
+		entry := &types.LogEntry{
+			Severity:  6,
+			Version:   1,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Hostname:  e.host,
+			AppName:   "app",
+			Message:   e.message,
+		}
+		if e.token != "" {
+			entry.StructuredData = map[string]interface{}{"auth": map[string]string{"token": e.token}}
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	query := types.SearchQuery{Text: "leaked", Hostname: "web-1"}
+	count, err := storage.DeleteMatching(query, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected dry run to match 1 entry, got %d", count)
+	}
+	if total := countEntries(t, storage); total != 4 {
+		t.Errorf("Expected dry run to keep all 4 entries, got %d", total)
+	}
+
+	deleted, err := storage.DeleteMatching(types.SearchQuery{Text: "leaked"}, false)
+	if err != nil {
+		t.Fatalf("DeleteMatching failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted entries, got %d", deleted)
+	}
+	if total := countEntries(t, storage); total != 2 {
+		t.Errorf("Expected 2 remaining entries, got %d", total)
+	}
+
+	results, err := storage.Search(types.SearchQuery{Text: "password", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected deleted entries to be gone from the full-text index, got %d results", len(results))
+	}
+
+	// Values only the deleted entries carried drop out of the facets and field catalog
+	facets, err := storage.Facets(types.FacetQuery{
+		Fields:    []string{types.FacetHostname},
+		StartTime: base,
+		EndTime:   base.Add(time.Hour),
+		Limit:     10,
+	})
+	if err != nil {
+		t.Fatalf("Facets failed: %v", err)
+	}
+	for _, value := range facets.Facets[0].Values {
+		if value.Value == "web-2" {
+			t.Errorf("Expected web-2 to be removed from the facets, got %+v", facets.Facets[0].Values)
+		}
+		if value.Value == "web-1" && value.Count != 1 {
+			t.Errorf("Expected web-1 to count 1 entry, got %d", value.Count)
+		}
+	}
+	fields, err := storage.Fields("auth.", 10)
+	if err != nil {
+		t.Fatalf("Fields failed: %v", err)
+	}
+	if len(fields) != 0 {
+		t.Errorf("Expected the deleted structured data to leave the field catalog, got %+v", fields)
+	}
+
+	var rollupCount int64
+	if err := storage.db.QueryRow("SELECT COALESCE(SUM(count), 0) FROM log_rollups").Scan(&rollupCount); err != nil {
+		t.Fatalf("Failed to sum rollups: %v", err)
+	}
+	if rollupCount != 2 {
+		t.Errorf("Expected rollups to count 2 entries, got %d", rollupCount)
+	}
+
+	if deleted, err := storage.DeleteMatching(types.SearchQuery{Text: "leaked"}, false); err != nil || deleted != 0 {
+		t.Errorf("Expected nothing left to delete, got %d, %v", deleted, err)
+	}
+}
+
+func countEntries(t *testing.T, storage *SQLiteStorage) int64 {
+	var count int64
+	if err := storage.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count entries: %v", err)
+	}
+	return count
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+import "errors"
+
+// diskFree is not available on this platform
+func diskFree(path string) (int64, error) {
+	return 0, errors.New("free disk space is not available on this platform")
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// queryEntryDetail reads a single entry with its raw message and hash chain link
+func queryEntryDetail(db *sql.DB, id int64) (*types.EntryDetail, error) {
+	entry := &types.LogEntry{}
+	var uid, structuredDataJSON, partition, prev, hash sql.NullString
+	var raw interface{}
+	err := db.QueryRow(`
+		SELECT id, uid, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id,
+			structured_data, message, created_at, raw_message, chain_partition, chain_prev, chain_hash
+		FROM logs WHERE id = ?`, id).Scan(
+		&entry.ID, &uid, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+		&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
+		&structuredDataJSON, &entry.Message, &entry.CreatedAt, &raw, &partition, &prev, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", interfaces.ErrEntryNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log entry: %w", err)
+	}
+	entry.UID = uid.String
+
+	if structuredDataJSON.Valid && structuredDataJSON.String != "" {
+		var structuredData map[string]interface{}
+		if err := json.Unmarshal([]byte(structuredDataJSON.String), &structuredData); err == nil {
+			entry.StructuredData = structuredData
+		}
+	}
+
+	detail := &types.EntryDetail{Entry: entry}
+	if detail.Raw, err = decodeRawMessage(raw); err != nil {
+		return nil, err
+	}
+	if hash.Valid && hash.String != "" {
+		detail.Chain = &types.ChainLink{Partition: partition.String, Prev: prev.String, Hash: hash.String}
+	}
+	return detail, nil
+}
+
+// EntryDetail returns an entry with its raw message and hash chain link
+func (s *SQLiteStorage) EntryDetail(id int64) (*types.EntryDetail, error) {
+	return queryEntryDetail(s.db, id)
+}
+
+// EntryDetail returns an entry with its raw message and hash chain link
+func (s *BatchedSQLiteStorage) EntryDetail(id int64) (*types.EntryDetail, error) {
+	return queryEntryDetail(s.db, id)
+}
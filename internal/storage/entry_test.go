@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_EntryDetail(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	entry := &types.LogEntry{
+		Version: 1, Priority: 134, Facility: 16, Severity: 6, Timestamp: time.Now(), Hostname: "web01",
+		AppName: "api", Message: "detail test", Raw: "<134>1 - web01 api - - - detail test",
+	}
+	entry.SetMetadata(types.SourceIPParam, "10.0.0.5")
+	if err := storage.Store(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	storage.SetHashChain(true)
+	chained := &types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: "chained"}
+	if err := storage.Store(chained); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	detail, err := storage.EntryDetail(entry.ID)
+	if err != nil {
+		t.Fatalf("EntryDetail failed: %v", err)
+	}
+	if detail.Entry.ID != entry.ID || detail.Entry.Hostname != "web01" || detail.Entry.Message != "detail test" {
+		t.Errorf("Unexpected entry: %+v", detail.Entry)
+	}
+	if detail.Raw != entry.Raw {
+		t.Errorf("Expected raw message %q, got %q", entry.Raw, detail.Raw)
+	}
+	metadata, _ := detail.Entry.StructuredData[types.MetadataSDID].(map[string]interface{})
+	if metadata[types.SourceIPParam] != "10.0.0.5" {
+		t.Errorf("Expected the source IP in the structured data, got %v", detail.Entry.StructuredData)
+	}
+	if detail.Chain != nil {
+		t.Errorf("Expected no chain link for an entry stored without hash chain, got %+v", detail.Chain)
+	}
+
+	detail, err = storage.EntryDetail(chained.ID)
+	if err != nil {
+		t.Fatalf("EntryDetail failed: %v", err)
+	}
+	if detail.Chain == nil || detail.Chain.Hash == "" || detail.Chain.Partition == "" {
+		t.Errorf("Expected a chain link, got %+v", detail.Chain)
+	}
+
+	if _, err := storage.EntryDetail(chained.ID + 100); !errors.Is(err, interfaces.ErrEntryNotFound) {
+		t.Errorf("Expected ErrEntryNotFound, got %v", err)
+	}
+}
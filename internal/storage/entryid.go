@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"opentrail/internal/entryid"
+	"opentrail/internal/types"
+)
+
+// entryUID returns the uid column of an entry, first generating its uid from its timestamp when it
+// has none, or nil when it is left without one. Entries that are stored again, such as those of an
+// imported dump, keep their uid and are recognized as duplicates by the unique index on it.
+func entryUID(entry *types.LogEntry, ids *entryid.Generator) interface{} {
+	if entry.UID == "" && ids != nil {
+		entry.UID = ids.Next(entry.Timestamp)
+	}
+	if entry.UID == "" {
+		return nil
+	}
+	return entry.UID
+}
+
+// SetEntryIDs sets the generator of the uid of new entries that have none; nil leaves them without one
+func (s *SQLiteStorage) SetEntryIDs(generator *entryid.Generator) {
+	s.entryIDs.Store(generator)
+}
+
+// SetEntryIDs sets the generator of the uid of new entries that have none; nil leaves them without one
+func (s *BatchedSQLiteStorage) SetEntryIDs(generator *entryid.Generator) {
+	s.entryIDs.Store(generator)
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"opentrail/internal/entryid"
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_EntryIDs(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	// Entries stored without a scheme have no uid
+	plain := &types.LogEntry{Priority: 14, Version: 1, Timestamp: time.Now(), Hostname: "host", Message: "plain"}
+	if err := storage.Store(plain); err != nil || plain.UID != "" {
+		t.Fatalf("Expected an entry without uid, got %q (%v)", plain.UID, err)
+	}
+
+	ids, _ := entryid.New(entryid.SchemeULID)
+	storage.SetEntryIDs(ids)
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Stored out of order, the uids still sort by timestamp
+	var stored []*types.LogEntry
+	for _, offset := range []int{2, 0, 1} {
+		entry := &types.LogEntry{Priority: 14, Version: 1, Timestamp: base.Add(time.Duration(offset) * time.Minute),
+			Hostname: "host", Message: fmt.Sprintf("minute %d", offset)}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		stored = append(stored, entry)
+	}
+	if len(stored[0].UID) != 26 || !(stored[1].UID < stored[2].UID && stored[2].UID < stored[0].UID) {
+		t.Errorf("Expected ULIDs sorting by timestamp, got %s %s %s", stored[0].UID, stored[1].UID, stored[2].UID)
+	}
+
+	// The uid is read back by searches, the recent entries and the entry detail
+	results, err := storage.Search(types.SearchQuery{Text: "minute", Limit: 10})
+	if err != nil || len(results) != 3 || results[0].UID != stored[0].UID {
+		t.Errorf("Expected searches to return the uid %s first, got %v (%v)", stored[0].UID, results, err)
+	}
+	// The plain entry is the most recent
+	recent, err := storage.GetRecent(10)
+	if err != nil || len(recent) != 4 || recent[0].UID != "" || recent[1].UID != stored[0].UID {
+		t.Errorf("Expected recent entries to return the uid %s second, got %v (%v)", stored[0].UID, recent, err)
+	}
+	detail, err := storage.EntryDetail(stored[1].ID)
+	if err != nil || detail.Entry.UID != stored[1].UID {
+		t.Errorf("Expected the entry detail to return the uid %s, got %v (%v)", stored[1].UID, detail, err)
+	}
+
+	// An entry carrying a stored uid, such as one imported again, is a duplicate
+	again := &types.LogEntry{Priority: 14, Version: 1, Timestamp: base, Hostname: "host", Message: "imported again", UID: stored[1].UID}
+	if err := storage.Store(again); !errors.Is(err, interfaces.ErrDuplicateEntry) {
+		t.Errorf("Expected an entry with a stored uid to be a duplicate, got %v", err)
+	}
+	// ... also when it has an idempotency key of its own
+	storage.SetIdempotencyWindow(time.Hour)
+	keyed := keyedEntry("k1", "", "imported again")
+	keyed.UID = stored[1].UID
+	if err := storage.Store(keyed); !errors.Is(err, interfaces.ErrDuplicateEntry) {
+		t.Errorf("Expected a keyed entry with a stored uid to be a duplicate, got %v", err)
+	}
+	// A uid given to the entry by its source is kept
+	imported := &types.LogEntry{Priority: 14, Version: 1, Timestamp: base, Hostname: "host", Message: "imported", UID: "2dT9Dk4O4WWxeDYEUwDX8aGeH3m"}
+	if err := storage.Store(imported); err != nil || imported.UID != "2dT9Dk4O4WWxeDYEUwDX8aGeH3m" {
+		t.Errorf("Expected the uid of an imported entry to be kept, got %q (%v)", imported.UID, err)
+	}
+
+	var count int
+	if err := storage.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count entries: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 stored entries, got %d", count)
+	}
+}
+
+func TestBatchedSQLiteStorage_EntryIDs(t *testing.T) {
+	dbPath := "test_entry_ids.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	config := DefaultBatchConfig()
+	config.BatchSize = 10
+	config.BatchTimeout = 50 * time.Millisecond
+
+	storage, err := NewBatchedSQLiteStorage(dbPath, config)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+	ids, _ := entryid.New(entryid.SchemeKSUID)
+	storage.(interfaces.EntryIDAssigner).SetEntryIDs(ids)
+	notifier := storage.(interfaces.CommitNotifier)
+
+	// Entries of the same batch sharing a uid are recognized as duplicates
+	uids := []string{"", "2dT9Dk4O4WWxeDYEUwDX8aGeH3m", "", "2dT9Dk4O4WWxeDYEUwDX8aGeH3m"}
+	entries := make([]*types.LogEntry, len(uids))
+	results := make([]chan error, len(uids))
+	for i, uid := range uids {
+		entries[i] = &types.LogEntry{Priority: 14, Version: 1, Timestamp: time.Now(), Hostname: "host",
+			Message: fmt.Sprintf("entry %d", i), UID: uid}
+		results[i] = make(chan error, 1)
+		result := results[i]
+		if err := notifier.StoreNotify(entries[i], func(err error) { result <- err }); err != nil {
+			t.Fatalf("StoreNotify failed: %v", err)
+		}
+	}
+	for i, result := range results {
+		select {
+		case err := <-result:
+			duplicate := i == 3
+			if duplicate != errors.Is(err, interfaces.ErrDuplicateEntry) || (!duplicate && err != nil) {
+				t.Errorf("Entry %d with uid %q: unexpected result %v", i, uids[i], err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the commit notification")
+		}
+	}
+
+	recent, err := storage.GetRecent(10)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 entries to be stored, got %d", len(recent))
+	}
+	for _, entry := range recent {
+		if len(entry.UID) != 27 {
+			t.Errorf("Expected entry %d to have a KSUID, got %q", entry.ID, entry.UID)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func createEvent(db *sql.DB, event *types.Event) error {
+	event.CreatedAt = time.Now().UTC()
+	event.Time = event.Time.UTC()
+	result, err := db.Exec(`
+	INSERT INTO events (type, service, version, description, time, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`,
+		event.Type, event.Service, event.Version, event.Description, event.Time, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %w", err)
+	}
+	if event.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get event ID: %w", err)
+	}
+	return nil
+}
+
+func listEvents(db *sql.DB, query types.EventQuery) ([]types.Event, error) {
+	sqlQuery := `
+	SELECT id, type, service, version, description, time, created_at FROM events
+	WHERE time >= ? AND time < ?`
+	args := []interface{}{query.StartTime.UTC(), query.EndTime.UTC()}
+	if query.Service != "" {
+		sqlQuery += " AND service = ?"
+		args = append(args, query.Service)
+	}
+	if query.Type != "" {
+		sqlQuery += " AND type = ?"
+		args = append(args, query.Type)
+	}
+	sqlQuery += " ORDER BY time, id"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []types.Event{}
+	for rows.Next() {
+		var event types.Event
+		if err := rows.Scan(&event.ID, &event.Type, &event.Service, &event.Version, &event.Description,
+			&event.Time, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	return events, nil
+}
+
+func deleteEvent(db *sql.DB, id int64) error {
+	result, err := db.Exec("DELETE FROM events WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	if deleted == 0 {
+		return interfaces.ErrEventNotFound
+	}
+	return nil
+}
+
+// CreateEvent saves a new event marker
+func (s *SQLiteStorage) CreateEvent(event *types.Event) error {
+	return createEvent(s.db, event)
+}
+
+// Events returns the event markers matching query, oldest first
+func (s *SQLiteStorage) Events(query types.EventQuery) ([]types.Event, error) {
+	return listEvents(s.db, query)
+}
+
+// DeleteEvent removes an event marker
+func (s *SQLiteStorage) DeleteEvent(id int64) error {
+	return deleteEvent(s.db, id)
+}
+
+// CreateEvent saves a new event marker
+func (s *BatchedSQLiteStorage) CreateEvent(event *types.Event) error {
+	return createEvent(s.db, event)
+}
+
+// Events returns the event markers matching query, oldest first
+func (s *BatchedSQLiteStorage) Events(query types.EventQuery) ([]types.Event, error) {
+	return listEvents(s.db, query)
+}
+
+// DeleteEvent removes an event marker
+func (s *BatchedSQLiteStorage) DeleteEvent(id int64) error {
+	return deleteEvent(s.db, id)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Events(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []*types.Event{
+		{Type: types.EventDeploy, Service: "api", Version: "1.4.0", Time: base},
+		{Type: types.EventDeploy, Service: "web", Version: "2.0.1", Time: base.Add(30 * time.Minute)},
+		{Type: "rollback", Service: "api", Version: "1.3.9", Description: "errors after 1.4.0", Time: base.Add(time.Hour)},
+	}
+	for _, event := range events {
+		if err := storage.CreateEvent(event); err != nil {
+			t.Fatalf("CreateEvent failed: %v", err)
+		}
+		if event.ID == 0 || event.CreatedAt.IsZero() {
+			t.Errorf("Expected ID and creation time to be set, got %+v", event)
+		}
+	}
+
+	all, err := storage.Events(types.EventQuery{StartTime: base, EndTime: base.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(all) != 3 || all[0].Version != "1.4.0" || all[2].Description != "errors after 1.4.0" {
+		t.Errorf("Expected all events oldest first, got %+v", all)
+	}
+
+	// The end of the range is exclusive
+	api, err := storage.Events(types.EventQuery{StartTime: base, EndTime: base.Add(time.Hour), Service: "api"})
+	if err != nil || len(api) != 1 || api[0].ID != events[0].ID {
+		t.Errorf("Expected the api deploy only, got %+v, %v", api, err)
+	}
+	rollbacks, err := storage.Events(types.EventQuery{StartTime: base, EndTime: base.Add(2 * time.Hour), Type: "rollback"})
+	if err != nil || len(rollbacks) != 1 || rollbacks[0].Service != "api" {
+		t.Errorf("Expected the rollback only, got %+v, %v", rollbacks, err)
+	}
+	limited, err := storage.Events(types.EventQuery{StartTime: base, EndTime: base.Add(2 * time.Hour), Limit: 2})
+	if err != nil || len(limited) != 2 {
+		t.Errorf("Expected 2 events, got %+v, %v", limited, err)
+	}
+
+	// Events outlive retention
+	if err := storage.Cleanup(1); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if err := storage.DeleteEvent(events[1].ID); err != nil {
+		t.Fatalf("DeleteEvent failed: %v", err)
+	}
+	if err := storage.DeleteEvent(events[1].ID); !errors.Is(err, interfaces.ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+	remaining, err := storage.Events(types.EventQuery{StartTime: base, EndTime: base.Add(2 * time.Hour)})
+	if err != nil || len(remaining) != 2 {
+		t.Errorf("Expected 2 remaining events, got %+v, %v", remaining, err)
+	}
+}
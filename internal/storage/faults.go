@@ -0,0 +1,14 @@
+package storage
+
+// faultPoint is a step of a batched write at which a fault can be injected. Faults are only
+// injected in builds with the faultinject tag; otherwise injectFault always succeeds.
+type faultPoint int
+
+const (
+	// faultBegin follows beginning a batch transaction
+	faultBegin faultPoint = iota
+	// faultExec precedes inserting an entry, in a batch or individually
+	faultExec
+	// faultCommit precedes committing a batch transaction
+	faultCommit
+)
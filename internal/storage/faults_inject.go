@@ -0,0 +1,66 @@
+//go:build faultinject
+
+package storage
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// errInjectedFailure fails a transaction for good, like a disk or I/O error would
+	errInjectedFailure = errors.New("injected transaction failure")
+	// errInjectedBusy mimics the error SQLite returns when busy_timeout runs out
+	errInjectedBusy = errors.New("database is locked (5) (SQLITE_BUSY)")
+)
+
+// faults configures the faults injected into batched writes
+type faults struct {
+	// failRate is the fraction of batch transactions that fail to begin or commit
+	failRate float64
+	// commitDelay is slept before every batch commit
+	commitDelay time.Duration
+	// busyUntil makes every fault point report SQLITE_BUSY until then
+	busyUntil time.Time
+}
+
+var (
+	faultMu      sync.Mutex
+	activeFaults faults
+	faultRand    = rand.New(rand.NewSource(1))
+)
+
+// setFaults injects faults until the returned function restores the previous ones
+func setFaults(f faults) (restore func()) {
+	faultMu.Lock()
+	previous := activeFaults
+	activeFaults = f
+	faultMu.Unlock()
+
+	return func() {
+		faultMu.Lock()
+		activeFaults = previous
+		faultMu.Unlock()
+	}
+}
+
+// injectFault returns the fault injected at a point, if any
+func injectFault(point faultPoint) error {
+	faultMu.Lock()
+	f := activeFaults
+	fail := point != faultExec && f.failRate > 0 && faultRand.Float64() < f.failRate
+	faultMu.Unlock()
+
+	if point == faultCommit && f.commitDelay > 0 {
+		time.Sleep(f.commitDelay)
+	}
+	if time.Now().Before(f.busyUntil) {
+		return errInjectedBusy
+	}
+	if fail {
+		return errInjectedFailure
+	}
+	return nil
+}
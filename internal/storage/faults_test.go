@@ -0,0 +1,117 @@
+//go:build faultinject
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// newFaultStorage creates a batched storage in a temporary directory for fault injection tests
+func newFaultStorage(t *testing.T, config BatchConfig) *BatchedSQLiteStorage {
+	t.Helper()
+	config.ApplyDefaults()
+	storage, err := NewBatchedSQLiteStorage(t.TempDir()+"/faults.db", config)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage.(*BatchedSQLiteStorage)
+}
+
+// storeFaultEntries stores count entries with distinct messages, returning how many were accepted
+func storeFaultEntries(t *testing.T, storage *BatchedSQLiteStorage, count int) int {
+	t.Helper()
+	accepted := 0
+	for i := 0; i < count; i++ {
+		err := storage.Store(&types.LogEntry{
+			Priority:  14,
+			Facility:  1,
+			Severity:  6,
+			Version:   1,
+			Timestamp: time.Now(),
+			Hostname:  "faulty",
+			AppName:   "test",
+			Message:   fmt.Sprintf("entry %d", i),
+		})
+		if err == nil {
+			accepted++
+		} else if !strings.Contains(err.Error(), "write queue is full") {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+	return accepted
+}
+
+// waitForStored waits until want entries are stored, then checks that each is stored exactly once
+// and that the rollups count them
+func waitForStored(t *testing.T, storage *BatchedSQLiteStorage, want int) {
+	t.Helper()
+	var rows, distinct, rolledUp int
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if err := storage.db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT message) FROM logs").Scan(&rows, &distinct); err != nil {
+			t.Fatalf("Failed to count entries: %v", err)
+		}
+		if rows >= want || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give duplicates from a double retry time to show up
+	time.Sleep(200 * time.Millisecond)
+	if err := storage.db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT message) FROM logs").Scan(&rows, &distinct); err != nil {
+		t.Fatalf("Failed to count entries: %v", err)
+	}
+	if err := storage.db.QueryRow("SELECT COALESCE(SUM(count), 0) FROM log_rollups").Scan(&rolledUp); err != nil {
+		t.Fatalf("Failed to sum rollups: %v", err)
+	}
+
+	if rows != want || distinct != want {
+		t.Errorf("Expected %d entries stored once each, got %d rows with %d distinct messages", want, rows, distinct)
+	}
+	if rolledUp != want {
+		t.Errorf("Expected rollups to count %d entries, got %d", want, rolledUp)
+	}
+}
+
+func TestFaults_FailedTransactionsFallBackToIndividualWrites(t *testing.T) {
+	storage := newFaultStorage(t, BatchConfig{BatchSize: 10, BatchTimeout: 10 * time.Millisecond})
+	defer setFaults(faults{failRate: 0.5})()
+
+	accepted := storeFaultEntries(t, storage, 200)
+	if accepted != 200 {
+		t.Fatalf("Expected all entries to be accepted, got %d", accepted)
+	}
+	waitForStored(t, storage, accepted)
+}
+
+func TestFaults_BusyStormIsRetried(t *testing.T) {
+	storage := newFaultStorage(t, BatchConfig{BatchSize: 10, BatchTimeout: 10 * time.Millisecond})
+	// The storm is shorter than the individual write backoff, so no entry should give up
+	defer setFaults(faults{busyUntil: time.Now().Add(60 * time.Millisecond)})()
+
+	accepted := storeFaultEntries(t, storage, 50)
+	if accepted != 50 {
+		t.Fatalf("Expected all entries to be accepted, got %d", accepted)
+	}
+	waitForStored(t, storage, accepted)
+}
+
+func TestFaults_SlowCommitsApplyBackpressure(t *testing.T) {
+	storage := newFaultStorage(t, BatchConfig{BatchSize: 5, BatchTimeout: 10 * time.Millisecond, QueueSize: 10})
+	defer setFaults(faults{commitDelay: 50 * time.Millisecond})()
+
+	accepted := storeFaultEntries(t, storage, 200)
+	if accepted == 200 {
+		t.Fatal("Expected a full queue to reject entries while commits are slow")
+	}
+	if accepted == 0 {
+		t.Fatal("Expected the queue to accept some entries")
+	}
+	waitForStored(t, storage, accepted)
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"opentrail/internal/fieldcrypt"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxCatalogValueLength is the longest value recorded in the field catalog; longer values are
+	// rarely useful as completions and would bloat the table
+	maxCatalogValueLength = 256
+	// maxCatalogValues is the number of most recently seen values kept per field
+	maxCatalogValues = 1000
+)
+
+// catalogBuiltinFields are the columns recorded in the field catalog alongside structured data keys
+var catalogBuiltinFields = map[string]bool{
+	types.FacetHostname: true,
+	types.FacetAppName:  true,
+	types.FacetMsgID:    true,
+}
+
+const upsertField = `
+INSERT INTO log_fields (name, value, count, last_seen)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (name, value) DO UPDATE SET
+	count = count + excluded.count,
+	last_seen = MAX(last_seen, excluded.last_seen)`
+
+// fieldKey identifies a single field catalog row
+type fieldKey struct {
+	name  string
+	value string
+}
+
+// fieldStat accumulates the occurrences of one field value
+type fieldStat struct {
+	count    int64
+	lastSeen int64
+}
+
+// addField records one occurrence of a field value
+func (c *rollupCounts) addField(name, value string, seen int64) {
+	if !catalogued(value) {
+		return
+	}
+	key := fieldKey{name: name, value: value}
+	stat := c.fields[key]
+	stat.count++
+	if seen > stat.lastSeen {
+		stat.lastSeen = seen
+	}
+	c.fields[key] = stat
+}
+
+// addFields records the built-in fields and structured data parameters of an entry
+func (c *rollupCounts) addFields(entry *types.LogEntry) {
+	seen := entry.Timestamp.Unix()
+	forEachField(entry, func(name, value string) {
+		c.addField(name, value, seen)
+	})
+}
+
+// removeFields takes back the occurrences addFields recorded for an entry
+func (c *rollupCounts) removeFields(entry *types.LogEntry) {
+	forEachField(entry, func(name, value string) {
+		if !catalogued(value) {
+			return
+		}
+		key := fieldKey{name: name, value: value}
+		stat := c.fields[key]
+		stat.count--
+		c.fields[key] = stat
+	})
+}
+
+// catalogued reports whether a field value is recorded in the field catalog. Encrypted values are
+// unique to their entry and would be no use as completions.
+func catalogued(value string) bool {
+	return value != "" && value != "-" && len(value) <= maxCatalogValueLength && !fieldcrypt.Sealed(value)
+}
+
+// forEachField calls fn with the built-in fields and string structured data parameters of an entry
+func forEachField(entry *types.LogEntry, fn func(name, value string)) {
+	fn(types.FacetHostname, entry.Hostname)
+	fn(types.FacetAppName, entry.AppName)
+	fn(types.FacetMsgID, entry.MsgID)
+
+	for sdID, element := range entry.StructuredData {
+		switch params := element.(type) {
+		case map[string]string:
+			for param, value := range params {
+				fn(sdID+"."+param, value)
+			}
+		case map[string]interface{}:
+			for param, value := range params {
+				if s, ok := value.(string); ok {
+					fn(sdID+"."+param, s)
+				}
+			}
+		}
+	}
+}
+
+// pruneFields keeps only the most recently seen values of every field
+func pruneFields(db execer) error {
+	_, err := db.Exec(`
+	DELETE FROM log_fields WHERE (name, value) IN (
+		SELECT name, value FROM (
+			SELECT name, value, ROW_NUMBER() OVER (PARTITION BY name ORDER BY last_seen DESC, count DESC) AS rank
+			FROM log_fields
+		) WHERE rank > ?
+	)`, maxCatalogValues)
+	if err != nil {
+		return fmt.Errorf("failed to prune field catalog: %w", err)
+	}
+	return nil
+}
+
+// queryFields lists catalog fields whose name starts with prefix, built-in fields first
+func queryFields(db *sql.DB, prefix string, limit int) ([]types.FieldInfo, error) {
+	rows, err := db.Query(`
+	SELECT name, SUM(count), MAX(last_seen) FROM log_fields
+	WHERE substr(name, 1, length(?1)) = ?1
+	GROUP BY name
+	ORDER BY name IN ('hostname', 'app_name', 'msg_id') DESC, MAX(last_seen) DESC, name
+	LIMIT ?2`, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query field catalog: %w", err)
+	}
+	defer rows.Close()
+
+	fields := []types.FieldInfo{}
+	for rows.Next() {
+		var field types.FieldInfo
+		var lastSeen int64
+		if err := rows.Scan(&field.Name, &field.Count, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		field.Builtin = catalogBuiltinFields[field.Name]
+		field.LastSeen = time.Unix(lastSeen, 0).UTC()
+		fields = append(fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read field catalog: %w", err)
+	}
+	return fields, nil
+}
+
+// queryFieldValues lists the most recently seen values of a field that start with the query prefix.
+// A structured data parameter without an element ("user_id") matches that parameter in every element.
+func queryFieldValues(db *sql.DB, query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	rows, err := db.Query(`
+	SELECT value, SUM(count), MAX(last_seen) FROM log_fields
+	WHERE (name = ?1 OR (instr(?1, '.') = 0 AND instr(name, '.') > 0 AND substr(name, instr(name, '.') + 1) = ?1))
+		AND substr(value, 1, length(?2)) = ?2
+	GROUP BY value
+	ORDER BY MAX(last_seen) DESC, SUM(count) DESC, value
+	LIMIT ?3`, query.Field, query.Prefix, query.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query field values: %w", err)
+	}
+	defer rows.Close()
+
+	values := []types.FieldValueInfo{}
+	for rows.Next() {
+		var value types.FieldValueInfo
+		var lastSeen int64
+		if err := rows.Scan(&value.Value, &value.Count, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan field value: %w", err)
+		}
+		value.LastSeen = time.Unix(lastSeen, 0).UTC()
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read field values: %w", err)
+	}
+	return values, nil
+}
+
+// Fields lists known field names from the field catalog
+func (s *SQLiteStorage) Fields(prefix string, limit int) ([]types.FieldInfo, error) {
+	return queryFields(s.db, prefix, limit)
+}
+
+// FieldValues lists recent values of a field from the field catalog
+func (s *SQLiteStorage) FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	return queryFieldValues(s.db, query)
+}
+
+// Fields lists known field names from the field catalog
+func (s *BatchedSQLiteStorage) Fields(prefix string, limit int) ([]types.FieldInfo, error) {
+	return queryFields(s.db, prefix, limit)
+}
+
+// FieldValues lists recent values of a field from the field catalog
+func (s *BatchedSQLiteStorage) FieldValues(query types.FieldValuesQuery) ([]types.FieldValueInfo, error) {
+	return queryFieldValues(s.db, query)
+}
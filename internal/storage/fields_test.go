@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_FieldCatalog(t *testing.T) {
+	path := t.TempDir() + "/fields.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+
+	base := time.Now().UTC().Truncate(time.Second)
+	entries := []*types.LogEntry{
+		{Hostname: "web01", AppName: "api", StructuredData: map[string]interface{}{
+			"request@32473": map[string]string{"user_id": "42", "path": "/login"},
+		}},
+		{Hostname: "web02", AppName: "api", StructuredData: map[string]interface{}{
+			"session": map[string]interface{}{"user_id": "43"},
+		}},
+		{Hostname: "web01", AppName: "worker", StructuredData: map[string]interface{}{
+			"request@32473": map[string]string{"user_id": "42", "payload": strings.Repeat("x", maxCatalogValueLength+1)},
+		}},
+	}
+	for i, entry := range entries {
+		entry.Severity = 6
+		entry.Version = 1
+		entry.Timestamp = base.Add(time.Duration(i) * time.Second)
+		entry.Message = "catalog test"
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	check := func(t *testing.T, catalog interface {
+		Fields(string, int) ([]types.FieldInfo, error)
+		FieldValues(types.FieldValuesQuery) ([]types.FieldValueInfo, error)
+	}) {
+		fields, err := catalog.Fields("", 50)
+		if err != nil {
+			t.Fatalf("Fields failed: %v", err)
+		}
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Name
+		}
+		// Built-in fields come first; overlong values are not recorded
+		want := "app_name,hostname,request@32473.user_id,session.user_id,request@32473.path"
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("Expected fields %s, got %s", want, got)
+		}
+		if !fields[0].Builtin || fields[2].Builtin {
+			t.Errorf("Unexpected builtin flags: %+v", fields)
+		}
+
+		fields, err = catalog.Fields("request", 50)
+		if err != nil {
+			t.Fatalf("Fields failed: %v", err)
+		}
+		if len(fields) != 2 {
+			t.Errorf("Expected 2 fields with prefix request, got %+v", fields)
+		}
+
+		values, err := catalog.FieldValues(types.FieldValuesQuery{Field: "request@32473.user_id", Limit: 10})
+		if err != nil {
+			t.Fatalf("FieldValues failed: %v", err)
+		}
+		if len(values) != 1 || values[0].Value != "42" || values[0].Count != 2 {
+			t.Errorf("Unexpected values: %+v", values)
+		}
+
+		// A bare parameter name matches every element, most recent first
+		values, err = catalog.FieldValues(types.FieldValuesQuery{Field: "user_id", Prefix: "4", Limit: 10})
+		if err != nil {
+			t.Fatalf("FieldValues failed: %v", err)
+		}
+		if len(values) != 2 || values[0].Value != "42" || values[1].Value != "43" {
+			t.Errorf("Unexpected values: %+v", values)
+		}
+
+		values, err = catalog.FieldValues(types.FieldValuesQuery{Field: "hostname", Prefix: "web02", Limit: 10})
+		if err != nil {
+			t.Fatalf("FieldValues failed: %v", err)
+		}
+		if len(values) != 1 || values[0].Value != "web02" {
+			t.Errorf("Unexpected hostname values: %+v", values)
+		}
+	}
+	check(t, storage)
+
+	// The catalog is backfilled, including structured data, for databases created before it existed
+	if _, err := storage.db.Exec("DROP TABLE log_fields; DROP TABLE schema_version"); err != nil {
+		t.Fatalf("Failed to drop field catalog: %v", err)
+	}
+	storage.Close()
+
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+	check(t, reopened.(*SQLiteStorage))
+}
+
+func TestPruneFields(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	counts := newRollupCounts()
+	for i := 0; i < maxCatalogValues+5; i++ {
+		counts.addField("request.id", fmt.Sprintf("id-%d", i), int64(i))
+	}
+	if err := counts.apply(storage.db); err != nil {
+		t.Fatalf("Failed to apply counts: %v", err)
+	}
+	if err := pruneFields(storage.db); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+
+	var remaining int
+	if err := storage.db.QueryRow("SELECT COUNT(*) FROM log_fields WHERE name = 'request.id'").Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count values: %v", err)
+	}
+	if remaining != maxCatalogValues {
+		t.Errorf("Expected %d values after pruning, got %d", maxCatalogValues, remaining)
+	}
+
+	values, err := storage.FieldValues(types.FieldValuesQuery{Field: "request.id", Prefix: "id-0", Limit: 1})
+	if err != nil {
+		t.Fatalf("FieldValues failed: %v", err)
+	}
+	if len(values) != 0 {
+		t.Errorf("Expected the oldest value to be pruned, got %+v", values)
+	}
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"fmt"
+
+	"opentrail/internal/types"
+)
+
+// timestampUnixExpression converts a stored timestamp to Unix seconds. Timestamps are stored in UTC
+// (see storedTimestampLayout), so the seconds are read from the date and time before the fraction.
+const timestampUnixExpression = "CAST(strftime('%s', substr(timestamp, 1, 19)) AS INTEGER)"
+
+// sampleRateExpression is the number of messages an entry stands for, its recorded sample rate or 1
+const sampleRateExpression = "MAX(1, COALESCE(CASE WHEN json_valid(structured_data) THEN CAST(json_extract(structured_data, '$.opentrail.sample_rate') AS INTEGER) END, 1))"
+
+// searchHistogramQuery builds the SQL counting the entries matching the search of a histogram query
+// per step of the given number of seconds and group, so only the counts leave the database
+func searchHistogramQuery(query types.HistogramQuery, promotions *fieldPromotions, seconds int64, groupColumn string) (string, []interface{}) {
+	search := *query.Search
+	search.StartTime, search.EndTime = &query.StartTime, &query.EndTime
+	source, sourceArgs := reportSource(search, promotions)
+
+	args := append([]interface{}{seconds, seconds}, sourceArgs...)
+	return fmt.Sprintf(`
+	SELECT (%s / ?) * ? AS slot, %s AS grp, COUNT(*), SUM(%s)
+	%s
+	GROUP BY slot, grp
+	ORDER BY slot, grp`, timestampUnixExpression, groupColumn, sampleRateExpression, source), args
+}
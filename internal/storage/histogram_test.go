@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_SearchHistogram(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	ndt := time.FixedZone("NDT", -(2*3600 + 30*60))
+	entries := []struct {
+		timestamp time.Time
+		severity  int
+		message   string
+	}{
+		{base.Add(10*time.Second + 250*time.Millisecond), 3, "upstream timed out"},
+		{base.Add(20 * time.Second), 6, "request served"},
+		// 12:33 UTC, received with a -0230 offset as 10:03 on the wall clock
+		{base.Add(2*time.Hour + 33*time.Minute).In(ndt), 6, "upstream timed out"},
+		{base.Add(6 * time.Minute), 3, "upstream timed out"},
+		{base.Add(4 * time.Hour), 3, "upstream timed out"},
+	}
+	for _, e := range entries {
+		entry := &types.LogEntry{
+			Priority:  16*8 + e.severity,
+			Facility:  16,
+			Severity:  e.severity,
+			Version:   1,
+			Timestamp: e.timestamp,
+			Hostname:  "host",
+			AppName:   "api",
+			Message:   e.message,
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	histogram, err := storage.Histogram(types.HistogramQuery{
+		StartTime: base,
+		EndTime:   base.Add(3 * time.Hour),
+		Interval:  5 * time.Minute,
+		GroupBy:   types.GroupBySeverity,
+		Search:    &types.SearchQuery{Text: "timed"},
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	expected := []types.HistogramBucket{
+		{Time: base, Group: "3", Count: 1},
+		{Time: base.Add(5 * time.Minute), Group: "3", Count: 1},
+		{Time: base.Add(2*time.Hour + 30*time.Minute), Group: "6", Count: 1},
+	}
+	if histogram.Total != 3 || len(histogram.Buckets) != len(expected) {
+		t.Fatalf("Expected %d entries in %+v, got %d in %+v", 3, expected, histogram.Total, histogram.Buckets)
+	}
+	for i, bucket := range histogram.Buckets {
+		if !bucket.Time.Equal(expected[i].Time) || bucket.Group != expected[i].Group || bucket.Count != expected[i].Count {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, expected[i], bucket)
+		}
+	}
+
+	// Buckets of a zone that is not a whole number of hours off UTC start on its wall clock
+	zoned, err := storage.Histogram(types.HistogramQuery{
+		StartTime: base,
+		EndTime:   base.Add(3 * time.Hour),
+		Interval:  time.Hour,
+		Location:  ndt,
+		Search:    &types.SearchQuery{Text: "timed"},
+	})
+	if err != nil {
+		t.Fatalf("Zoned histogram failed: %v", err)
+	}
+	if len(zoned.Buckets) != 2 || !zoned.Buckets[0].Time.Equal(base.Add(-30*time.Minute)) || zoned.Buckets[0].Count != 2 ||
+		!zoned.Buckets[1].Time.Equal(base.Add(2*time.Hour+30*time.Minute)) || zoned.Buckets[1].Count != 1 {
+		t.Errorf("Unexpected zoned buckets: %+v", zoned.Buckets)
+	}
+}
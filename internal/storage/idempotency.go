@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// createdAtLayout is the form of the created_at column, which SQLite fills in UTC
+const createdAtLayout = "2006-01-02 15:04:05"
+
+// idempotencyKey returns the idempotency_key column of an entry: the key its sender gave it, scoped
+// to its tenant so that tenants cannot suppress each other's entries, or nil when it has none or
+// keys are not remembered
+func idempotencyKey(entry *types.LogEntry, window time.Duration) interface{} {
+	key := entry.Metadata(types.IdempotencyKeyParam)
+	if key == "" || window <= 0 {
+		return nil
+	}
+	// Sanitized parameter values cannot contain a line break
+	if tenant := entry.Metadata(types.TenantParam); tenant != "" {
+		return tenant + "\n" + key
+	}
+	return key
+}
+
+// insertIdempotent runs the insert of an entry, which skips entries whose idempotency key or uid is
+// already stored. A key stored more than window ago is forgotten and the entry inserted after all;
+// otherwise interfaces.ErrDuplicateEntry is returned.
+func insertIdempotent(db execer, key interface{}, window time.Duration, insert func() (sql.Result, error)) (sql.Result, error) {
+	result, err := insert()
+	if err != nil {
+		return result, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted > 0 {
+		return result, err
+	}
+	if key == nil {
+		// Only the uid can have been stored already
+		return nil, interfaces.ErrDuplicateEntry
+	}
+
+	cutoff := time.Now().UTC().Add(-window).Format(createdAtLayout)
+	forgotten, err := db.Exec("UPDATE logs SET idempotency_key = NULL WHERE idempotency_key = ? AND created_at < ?", key, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to forget idempotency key: %w", err)
+	}
+	n, err := forgotten.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to forget idempotency key: %w", err)
+	}
+	if n == 0 {
+		return nil, interfaces.ErrDuplicateEntry
+	}
+	if result, err = insert(); err != nil {
+		return result, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted > 0 {
+		return result, err
+	}
+	return nil, interfaces.ErrDuplicateEntry
+}
+
+// SetIdempotencyWindow sets how long idempotency keys are remembered; zero stores every entry
+func (s *SQLiteStorage) SetIdempotencyWindow(window time.Duration) {
+	s.idempotencyWindow.Store(int64(window))
+}
+
+// SetIdempotencyWindow sets how long idempotency keys are remembered; zero stores every entry
+func (s *BatchedSQLiteStorage) SetIdempotencyWindow(window time.Duration) {
+	s.idempotencyWindow.Store(int64(window))
+}
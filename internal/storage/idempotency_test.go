@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// keyedEntry returns an entry a sender gave an idempotency key, optionally on a tenant's connection
+func keyedEntry(key, tenant, message string) *types.LogEntry {
+	entry := &types.LogEntry{Priority: 14, Version: 1, Timestamp: time.Now(), Hostname: "host", Message: message}
+	entry.SetMetadata(types.IdempotencyKeyParam, key)
+	if tenant != "" {
+		entry.SetMetadata(types.TenantParam, tenant)
+	}
+	return entry
+}
+
+func TestSQLiteStorage_IdempotencyKeys(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+	storage.SetIdempotencyWindow(time.Hour)
+
+	if err := storage.Store(keyedEntry("k1", "", "first")); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := storage.Store(keyedEntry("k1", "", "sent again")); !errors.Is(err, interfaces.ErrDuplicateEntry) {
+		t.Errorf("Expected an entry sent again to be a duplicate, got %v", err)
+	}
+	// Keys are scoped to the tenant
+	if err := storage.Store(keyedEntry("k1", "tenant-a", "other tenant")); err != nil {
+		t.Errorf("Expected the key of another tenant to be stored, got %v", err)
+	}
+
+	// A key remembered for longer than the window is stored again
+	if _, err := storage.db.Exec("UPDATE logs SET created_at = datetime('now', '-2 hours') WHERE message = 'first'"); err != nil {
+		t.Fatalf("Failed to age entry: %v", err)
+	}
+	if err := storage.Store(keyedEntry("k1", "", "after the window")); err != nil {
+		t.Errorf("Expected a key older than the window to be stored again, got %v", err)
+	}
+
+	// Without a window every entry is stored
+	storage.SetIdempotencyWindow(0)
+	if err := storage.Store(keyedEntry("k1", "", "not remembered")); err != nil {
+		t.Errorf("Expected keys to be ignored without a window, got %v", err)
+	}
+
+	var count int
+	if err := storage.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count entries: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 stored entries, got %d", count)
+	}
+}
+
+func TestBatchedSQLiteStorage_IdempotencyKeys(t *testing.T) {
+	dbPath := "test_idempotency.db"
+	defer os.Remove(dbPath)
+	defer os.Remove(dbPath + "-wal")
+	defer os.Remove(dbPath + "-shm")
+
+	config := DefaultBatchConfig()
+	config.BatchSize = 10
+	config.BatchTimeout = 50 * time.Millisecond
+
+	storage, err := NewBatchedSQLiteStorage(dbPath, config)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+	storage.(interfaces.IdempotentStore).SetIdempotencyWindow(time.Hour)
+	storage.(interfaces.HashChainer).SetHashChain(true)
+	notifier := storage.(interfaces.CommitNotifier)
+
+	// Duplicates within the same batch are recognized as well
+	keys := []string{"a", "b", "a", "c", "b"}
+	results := make([]chan error, len(keys))
+	for i, key := range keys {
+		results[i] = make(chan error, 1)
+		result := results[i]
+		if err := notifier.StoreNotify(keyedEntry(key, "", fmt.Sprintf("entry %d", i)), func(err error) { result <- err }); err != nil {
+			t.Fatalf("StoreNotify failed: %v", err)
+		}
+	}
+	for i, result := range results {
+		select {
+		case err := <-result:
+			duplicate := i == 2 || i == 4
+			if duplicate != errors.Is(err, interfaces.ErrDuplicateEntry) || (!duplicate && err != nil) {
+				t.Errorf("Entry %d with key %q: unexpected result %v", i, keys[i], err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the commit notification")
+		}
+	}
+
+	recent, err := storage.GetRecent(10)
+	if err != nil {
+		t.Fatalf("GetRecent failed: %v", err)
+	}
+	if len(recent) != 3 {
+		t.Errorf("Expected 3 entries to be stored, got %d", len(recent))
+	}
+
+	// Duplicates leave no gap in the hash chain
+	report, err := storage.(interfaces.ChainVerifier).VerifyChain("")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !report.Valid || report.Entries != 3 {
+		t.Errorf("Expected a valid chain of 3 entries, got %+v", report)
+	}
+}
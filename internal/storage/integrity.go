@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
+)
+
+// runIntegrityCheck executes PRAGMA quick_check or integrity_check against db
+// and collects every reported problem. SQLite returns a single "ok" row when
+// the database is consistent, otherwise one row per problem found.
+func runIntegrityCheck(db *sql.DB, quick bool) (*interfaces.IntegrityReport, error) {
+	start := time.Now()
+
+	mode := "full"
+	pragma := "PRAGMA integrity_check"
+	if quick {
+		mode = "quick"
+		pragma = "PRAGMA quick_check"
+	}
+
+	rows, err := db.Query(pragma)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s integrity check: %w", mode, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to read integrity check result: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over integrity check results: %w", err)
+	}
+
+	report := &interfaces.IntegrityReport{
+		OK:         len(problems) == 0,
+		Mode:       mode,
+		Problems:   problems,
+		CheckedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	metrics.GetStorageMetrics().RecordIntegrityCheck(report.OK)
+
+	return report, nil
+}
+
+// CheckIntegrity verifies the consistency of the SQLite database file
+func (s *SQLiteStorage) CheckIntegrity(quick bool) (*interfaces.IntegrityReport, error) {
+	return runIntegrityCheck(s.db, quick)
+}
+
+// CheckIntegrity verifies the consistency of the SQLite database file
+func (s *BatchedSQLiteStorage) CheckIntegrity(quick bool) (*interfaces.IntegrityReport, error) {
+	return runIntegrityCheck(s.db, quick)
+}
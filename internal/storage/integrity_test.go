@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_CheckIntegrity(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	entry := &types.LogEntry{
+		Priority:  134,
+		Facility:  16,
+		Severity:  6,
+		Version:   1,
+		Timestamp: time.Now(),
+		Hostname:  "test-host",
+		AppName:   "test-app",
+		Message:   "Integrity test message",
+	}
+	if err := storage.Store(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	for _, quick := range []bool{true, false} {
+		report, err := storage.CheckIntegrity(quick)
+		if err != nil {
+			t.Fatalf("CheckIntegrity(%v) failed: %v", quick, err)
+		}
+		if !report.OK {
+			t.Errorf("Expected healthy database, got problems: %v", report.Problems)
+		}
+
+		expectedMode := "full"
+		if quick {
+			expectedMode = "quick"
+		}
+		if report.Mode != expectedMode {
+			t.Errorf("Expected mode %s, got %s", expectedMode, report.Mode)
+		}
+		if report.CheckedAt.IsZero() {
+			t.Error("Expected CheckedAt to be set")
+		}
+	}
+}
+
+func TestBatchedSQLiteStorage_CheckIntegrity(t *testing.T) {
+	tempDir := t.TempDir()
+	storage, err := NewBatchedSQLiteStorage(tempDir+"/integrity.db", DefaultBatchConfig())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	checker, ok := storage.(interfaces.IntegrityChecker)
+	if !ok {
+		t.Fatal("BatchedSQLiteStorage should implement IntegrityChecker")
+	}
+
+	report, err := checker.CheckIntegrity(true)
+	if err != nil {
+		t.Fatalf("CheckIntegrity failed: %v", err)
+	}
+	if !report.OK {
+		t.Errorf("Expected healthy database, got problems: %v", report.Problems)
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"opentrail/internal/types"
+)
+
+// Load inserts entries in a single transaction, setting their IDs; entries whose uid is already
+// stored are skipped and keep an ID of zero. Unlike Store, it neither links entries to the hash
+// chain, remembers idempotency keys nor counts entries in the rollups, so it suits databases built
+// to be searched, such as the staging database of an archive search.
+func (s *SQLiteStorage) Load(entries []*types.LogEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+	INSERT OR IGNORE INTO logs (priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, uid)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		var structuredDataJSON string
+		if entry.StructuredData != nil {
+			jsonBytes, err := json.Marshal(entry.StructuredData)
+			if err != nil {
+				return fmt.Errorf("failed to marshal structured data: %w", err)
+			}
+			structuredDataJSON = string(jsonBytes)
+		}
+		result, err := stmt.Exec(entry.Priority, entry.Facility, entry.Severity, entry.Version,
+			storedTimestamp(entry.Timestamp), entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID,
+			structuredDataJSON, entry.Message, entryUID(entry, nil))
+		if err != nil {
+			return fmt.Errorf("failed to load log entry: %w", err)
+		}
+		entry.ID = 0
+		if inserted, err := result.RowsAffected(); err == nil && inserted > 0 {
+			if entry.ID, err = result.LastInsertId(); err != nil {
+				return fmt.Errorf("failed to get inserted ID: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit loaded entries: %w", err)
+	}
+	return nil
+}
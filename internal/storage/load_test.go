@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Load(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*types.LogEntry{
+		{ID: 900, Priority: 14, Version: 1, Timestamp: base, Hostname: "web-1", AppName: "api", Message: "login failed",
+			StructuredData: map[string]interface{}{"auth": map[string]interface{}{"user": "alice"}}, UID: "01HQWY5CG0AAAAAAAAAAAAAAAA"},
+		{ID: 901, Priority: 11, Version: 1, Timestamp: base.Add(time.Minute), Hostname: "web-2", AppName: "api", Message: "request served"},
+		// A uid already loaded is skipped
+		{ID: 902, Priority: 14, Version: 1, Timestamp: base, Hostname: "web-1", AppName: "api", Message: "login failed", UID: "01HQWY5CG0AAAAAAAAAAAAAAAA"},
+	}
+	if err := storage.Load(entries); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if entries[0].ID == 0 || entries[1].ID == 0 || entries[2].ID != 0 {
+		t.Errorf("Expected the first two entries to get IDs, got %d %d %d", entries[0].ID, entries[1].ID, entries[2].ID)
+	}
+
+	// Loaded entries are found by text and structured data searches
+	results, err := storage.Search(types.SearchQuery{Text: "login", Limit: 10})
+	if err != nil || len(results) != 1 || results[0].ID != entries[0].ID || results[0].UID != entries[0].UID {
+		t.Errorf("Expected the loaded entry to be found by text, got %v (%v)", results, err)
+	}
+	results, err = storage.Search(types.SearchQuery{StructuredDataQuery: "alice", Limit: 10})
+	if err != nil || len(results) != 1 {
+		t.Errorf("Expected the loaded entry to be found by structured data, got %v (%v)", results, err)
+	}
+}
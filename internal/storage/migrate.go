@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the SQL migrations, named NNNN_name.up.sql with an optional NNNN_name.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// createSchemaVersionTable records the migrations applied to a database
+const createSchemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME NOT NULL
+);`
+
+// migration is one versioned step of the schema. SQL steps run in a transaction together with
+// recording their version; data migrations are implemented in Go instead.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+	// apply runs a data migration, which batches its own transactions and must be safe to repeat
+	// if interrupted
+	apply func(db *sql.DB) error
+}
+
+// dataMigrations are the steps implemented in Go. They have no down step, as the data they
+// rewrite stays readable by the earlier schema.
+var dataMigrations = []migration{
+	// Databases written before timestamps were stored in UTC keep them in the zone they arrived with
+	{version: 2, name: "utc_timestamps", apply: migrateTimestamps},
+}
+
+// legacyColumns were added to their tables before migrations were versioned, so databases created
+// by earlier versions may lack them; they are added before the baseline is applied
+var legacyColumns = []struct{ table, column, definition string }{
+	{"logs", "raw_message", "TEXT"},
+	{"logs", "chain_partition", "TEXT"},
+	{"logs", "chain_prev", "TEXT"},
+	{"logs", "chain_hash", "TEXT"},
+	{"logs", "idempotency_key", "TEXT"},
+	{"log_rollups", "sampled", "INTEGER NOT NULL DEFAULT 0"},
+	{"reports", "alert_threshold", "INTEGER NOT NULL DEFAULT 0"},
+	{"reports", "extrapolate", "INTEGER NOT NULL DEFAULT 0"},
+	{"reports", "firing", "INTEGER NOT NULL DEFAULT 0"},
+	{"report_results", "extrapolated", "INTEGER"},
+}
+
+// migrations are all the steps in version order
+var migrations = mustLoadMigrations()
+
+// LatestSchemaVersion is the schema version databases are migrated to when opened
+var LatestSchemaVersion = migrations[len(migrations)-1].version
+
+// SchemaMigration describes a migration step, as recorded in a database or as it would run
+type SchemaMigration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// AppliedAt is when the step was applied to the database, nil if it is pending
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Down is true for a step reverting the migration
+	Down bool `json:"down,omitempty"`
+	// SQL holds the statements the step runs, empty for data migrations implemented in Go
+	SQL string `json:"sql,omitempty"`
+}
+
+// mustLoadMigrations reads the embedded migration files and merges them with the data migrations.
+// Versions must run from 1 without gaps, so a malformed file name is a build defect.
+func mustLoadMigrations() []migration {
+	steps, err := loadMigrations()
+	if err != nil {
+		panic(err)
+	}
+	return steps
+}
+
+func loadMigrations() ([]migration, error) {
+	files, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	byVersion := make(map[int]*migration)
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".sql"), ".")
+		number, name, hasName := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !hasName || err != nil || version < 1 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.up.sql or NNNN_name.down.sql", file.Name())
+		}
+		content, err := migrationFiles.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file.Name(), err)
+		}
+
+		step := byVersion[version]
+		if step == nil {
+			step = &migration{version: version, name: name}
+			byVersion[version] = step
+		}
+		if step.name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, step.name, name)
+		}
+		if direction == "up" {
+			step.up = string(content)
+		} else {
+			step.down = string(content)
+		}
+	}
+	for i := range dataMigrations {
+		step := dataMigrations[i]
+		if byVersion[step.version] != nil {
+			return nil, fmt.Errorf("migration %d is defined both as a file and in Go", step.version)
+		}
+		byVersion[step.version] = &step
+	}
+
+	steps := make([]migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		step := byVersion[version]
+		if step == nil {
+			return nil, fmt.Errorf("migration %d is missing", version)
+		}
+		if step.apply == nil && strings.TrimSpace(step.up) == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up step", version, step.name)
+		}
+		steps = append(steps, *step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no migrations found")
+	}
+	return steps, nil
+}
+
+// migrateSchema brings a database opened by the storage up to LatestSchemaVersion
+func migrateSchema(db *sql.DB) error {
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, LatestSchemaVersion)
+	}
+	if current == LatestSchemaVersion {
+		return nil
+	}
+	for _, step := range migrations[current:] {
+		if err := applyMigration(db, step, false); err != nil {
+			return err
+		}
+	}
+	log.Printf("Migrated database schema from version %d to %d", current, LatestSchemaVersion)
+	return nil
+}
+
+// schemaVersion returns the highest migration applied to a database, 0 for a new database or one
+// created before migrations were versioned
+func schemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version')").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to inspect schema version: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// applyMigration runs the up or down step of a migration and records the outcome
+func applyMigration(db *sql.DB, step migration, down bool) error {
+	if _, err := db.Exec(createSchemaVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema version table: %w", err)
+	}
+
+	if step.apply != nil {
+		if !down {
+			if err := step.apply(db); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", step.version, step.name, err)
+			}
+		}
+		return recordMigration(db, step, down)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d (%s): %w", step.version, step.name, err)
+	}
+	statements, err := migrationStatements(tx, step, down)
+	if err == nil {
+		for _, statement := range statements {
+			if _, err = tx.Exec(statement); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = recordMigration(tx, step, down)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s) failed: %w", step.version, step.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d (%s): %w", step.version, step.name, err)
+	}
+	return nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// migrationStatements returns the SQL a step runs on a database. The baseline is preceded by the
+// columns a database created before migrations were versioned lacks.
+func migrationStatements(db queryer, step migration, down bool) ([]string, error) {
+	if down {
+		if step.down == "" {
+			return nil, fmt.Errorf("migration %d (%s) cannot be reverted", step.version, step.name)
+		}
+		return []string{step.down}, nil
+	}
+	var statements []string
+	if step.version == 1 {
+		for _, legacy := range legacyColumns {
+			columns, err := tableColumns(db, legacy.table)
+			if err != nil {
+				return nil, err
+			}
+			if len(columns) > 0 && !columns[legacy.column] {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", legacy.table, legacy.column, legacy.definition))
+			}
+		}
+	}
+	up, err := skipExistingColumns(db, step.up)
+	if err != nil {
+		return nil, err
+	}
+	return append(statements, up), nil
+}
+
+// addColumnStatement matches the statements of a step adding a column to a table
+var addColumnStatement = regexp.MustCompile(`(?i)ALTER TABLE (\w+) ADD COLUMN (\w+)[^;]*;`)
+
+// skipExistingColumns removes the statements of an up step adding a column the table already has,
+// as SQLite cannot add a column only if it is missing. A database without a recorded schema
+// version runs every step, and may have been written by a version that added the column already.
+func skipExistingColumns(db queryer, up string) (string, error) {
+	var kept strings.Builder
+	last := 0
+	for _, match := range addColumnStatement.FindAllStringSubmatchIndex(up, -1) {
+		columns, err := tableColumns(db, up[match[2]:match[3]])
+		if err != nil {
+			return "", err
+		}
+		if columns[strings.ToLower(up[match[4]:match[5]])] {
+			kept.WriteString(up[last:match[0]])
+			last = match[1]
+		}
+	}
+	kept.WriteString(up[last:])
+	return kept.String(), nil
+}
+
+// tableColumns returns the lower-cased column names of a table, none if it does not exist
+func tableColumns(db queryer, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	return columns, nil
+}
+
+// recordMigration adds or, for a reverted step, removes the version of a migration
+func recordMigration(db execer, step migration, down bool) error {
+	if down {
+		_, err := db.Exec("DELETE FROM schema_version WHERE version = ?", step.version)
+		return err
+	}
+	// A concurrent process may have applied the same step, whose statements are idempotent
+	_, err := db.Exec("INSERT OR IGNORE INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		step.version, step.name, time.Now().UTC())
+	return err
+}
+
+// openForMigration opens an existing database without migrating it
+func openForMigration(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure database: %w", err)
+	}
+	return db, nil
+}
+
+// SchemaStatus returns the schema version of the database at dbPath and every known migration,
+// with when it was applied
+func SchemaStatus(dbPath string) (int, []SchemaMigration, error) {
+	db, err := openForMigration(dbPath)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer db.Close()
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return 0, nil, err
+	}
+	applied := make(map[int]time.Time)
+	if current > 0 {
+		rows, err := db.Query("SELECT version, applied_at FROM schema_version")
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to read schema versions: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			var appliedAt time.Time
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return 0, nil, fmt.Errorf("failed to scan schema version: %w", err)
+			}
+			applied[version] = appliedAt
+		}
+		if err := rows.Err(); err != nil {
+			return 0, nil, fmt.Errorf("failed to read schema versions: %w", err)
+		}
+	}
+
+	steps := make([]SchemaMigration, 0, len(migrations))
+	for _, step := range migrations {
+		status := SchemaMigration{Version: step.version, Name: step.name}
+		if appliedAt, ok := applied[step.version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		steps = append(steps, status)
+	}
+	return current, steps, nil
+}
+
+// MigrateSchema migrates the database at dbPath up or down to the target version, returning the
+// steps in the order they ran. With dryRun nothing is changed and the steps that would run are
+// returned with their SQL. Down steps drop what their migration created, including stored data,
+// and are meant for development.
+func MigrateSchema(dbPath string, target int, dryRun bool) ([]SchemaMigration, error) {
+	if target < 0 || target > LatestSchemaVersion {
+		return nil, fmt.Errorf("schema version must be between 0 and %d, got %d", LatestSchemaVersion, target)
+	}
+	db, err := openForMigration(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestSchemaVersion {
+		return nil, fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, LatestSchemaVersion)
+	}
+
+	var plan []migration
+	down := target < current
+	if down {
+		plan = append(plan, migrations[target:current]...)
+		sort.Slice(plan, func(i, j int) bool { return plan[i].version > plan[j].version })
+	} else {
+		plan = migrations[current:target]
+	}
+
+	var steps []SchemaMigration
+	for _, step := range plan {
+		status := SchemaMigration{Version: step.version, Name: step.name, Down: down}
+		if dryRun {
+			if step.apply == nil {
+				statements, err := migrationStatements(db, step, down)
+				if err != nil {
+					return steps, err
+				}
+				status.SQL = strings.Join(statements, "\n")
+			}
+			steps = append(steps, status)
+			continue
+		}
+		if err := applyMigration(db, step, down); err != nil {
+			return steps, err
+		}
+		if !down {
+			appliedAt := time.Now().UTC()
+			status.AppliedAt = &appliedAt
+		}
+		steps = append(steps, status)
+	}
+	return steps, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestMigrations_Ordered(t *testing.T) {
+	steps, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	for i, step := range steps {
+		if step.version != i+1 {
+			t.Errorf("Expected migration %d at position %d, got %d", i+1, i, step.version)
+		}
+	}
+	if LatestSchemaVersion != len(steps) {
+		t.Errorf("Expected latest version %d, got %d", len(steps), LatestSchemaVersion)
+	}
+
+	// Searches by source address only use the index if the expressions are identical
+	if !strings.Contains(steps[0].up, sourceIPExpression) {
+		t.Error("Expected the baseline to index sourceIPExpression")
+	}
+	if steps[0].down == "" {
+		t.Error("Expected the baseline to have a down step")
+	}
+}
+
+func TestMigrateSchema_AdoptsUnversionedDatabase(t *testing.T) {
+	path := t.TempDir() + "/legacy.db"
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	// The tables as created by versions before raw messages, hash chains, idempotency keys, sample
+	// rates and UTC timestamps
+	legacy := `
+	CREATE TABLE logs (id INTEGER PRIMARY KEY AUTOINCREMENT, priority INTEGER NOT NULL, facility INTEGER NOT NULL,
+		severity INTEGER NOT NULL, version INTEGER NOT NULL DEFAULT 1, timestamp DATETIME NOT NULL, hostname TEXT,
+		app_name TEXT, proc_id TEXT, msg_id TEXT, structured_data TEXT, message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE VIRTUAL TABLE logs_fts USING fts5(message, content='logs', content_rowid='id');
+	CREATE TRIGGER logs_ai AFTER INSERT ON logs BEGIN
+		INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
+	END;
+	CREATE TABLE log_rollups (bucket INTEGER NOT NULL, severity INTEGER NOT NULL, app_name TEXT NOT NULL DEFAULT '',
+		hostname TEXT NOT NULL DEFAULT '', count INTEGER NOT NULL, PRIMARY KEY (bucket, severity, app_name, hostname)) WITHOUT ROWID;
+	CREATE TABLE reports (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE, query TEXT NOT NULL DEFAULT '',
+		top_field TEXT NOT NULL DEFAULT '', top_limit INTEGER NOT NULL DEFAULT 0, interval_seconds INTEGER NOT NULL,
+		created_at DATETIME NOT NULL, last_run_at DATETIME);
+	INSERT INTO logs (priority, facility, severity, timestamp, hostname, app_name, proc_id, msg_id, message)
+	VALUES (14, 1, 6, '2024-03-01 15:30:00 +0530 IST', 'web01', 'api', '', '', 'legacy entry');`
+	if _, err := db.Exec(legacy); err != nil {
+		t.Fatalf("Failed to create legacy tables: %v", err)
+	}
+	db.Close()
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer storage.Close()
+	sqliteStorage := storage.(*SQLiteStorage)
+
+	version, err := schemaVersion(sqliteStorage.db)
+	if err != nil || version != LatestSchemaVersion {
+		t.Fatalf("Expected schema version %d, got %d (%v)", LatestSchemaVersion, version, err)
+	}
+	for _, legacy := range legacyColumns {
+		columns, err := tableColumns(sqliteStorage.db, legacy.table)
+		if err != nil || !columns[legacy.column] {
+			t.Errorf("Expected column %s.%s to be added, got %v (%v)", legacy.table, legacy.column, columns, err)
+		}
+	}
+
+	// The entry is found through the full-text index and has its timestamp migrated to UTC
+	if err := storage.Store(&types.LogEntry{Priority: 14, Timestamp: time.Now(), Message: "new entry"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	results, err := storage.Search(types.SearchQuery{Text: "legacy", Limit: 1})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected the legacy entry to be found, got %v, %v", results, err)
+	}
+	if expected := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !results[0].Timestamp.Equal(expected) {
+		t.Errorf("Expected timestamp %v, got %v", expected, results[0].Timestamp)
+	}
+}
+
+func TestMigrateSchema_DryRunAndDown(t *testing.T) {
+	path := t.TempDir() + "/migrate.db"
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := storage.Store(&types.LogEntry{Priority: 14, Timestamp: time.Now(), Message: "entry"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	storage.Close()
+
+	current, steps, err := SchemaStatus(path)
+	if err != nil || current != LatestSchemaVersion || len(steps) != LatestSchemaVersion {
+		t.Fatalf("Unexpected status: version %d, steps %+v, error %v", current, steps, err)
+	}
+	for _, step := range steps {
+		if step.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied", step.Version)
+		}
+	}
+
+	// A dry run lists the down steps newest first without running them
+	planned, err := MigrateSchema(path, 0, true)
+	if err != nil || len(planned) != LatestSchemaVersion {
+		t.Fatalf("Unexpected dry run %+v, error %v", planned, err)
+	}
+	if first, last := planned[0], planned[len(planned)-1]; first.Version != LatestSchemaVersion || !first.Down || last.Version != 1 || !strings.Contains(last.SQL, "DROP TABLE IF EXISTS logs;") {
+		t.Errorf("Unexpected down steps %+v", planned)
+	}
+	if current, _, _ := SchemaStatus(path); current != LatestSchemaVersion {
+		t.Errorf("Expected the dry run to change nothing, got version %d", current)
+	}
+
+	if _, err := MigrateSchema(path, 0, false); err != nil {
+		t.Fatalf("Down migration failed: %v", err)
+	}
+	db, err := openForMigration(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	columns, err := tableColumns(db, "logs")
+	db.Close()
+	if err != nil || len(columns) != 0 {
+		t.Errorf("Expected the logs table to be dropped, got %v (%v)", columns, err)
+	}
+
+	applied, err := MigrateSchema(path, LatestSchemaVersion, false)
+	if err != nil || len(applied) != LatestSchemaVersion || applied[0].AppliedAt == nil {
+		t.Fatalf("Unexpected up migration %+v, error %v", applied, err)
+	}
+	if _, err := MigrateSchema(path, LatestSchemaVersion+1, true); err == nil {
+		t.Error("Expected an unknown target version to be rejected")
+	}
+	if _, err := MigrateSchema(t.TempDir()+"/missing.db", 0, true); err == nil {
+		t.Error("Expected a missing database to be rejected")
+	}
+}
+
+func TestMigrateSchema_RejectsNewerDatabase(t *testing.T) {
+	path := t.TempDir() + "/newer.db"
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	db := storage.(*SQLiteStorage).db
+	if _, err := db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'future', ?)", LatestSchemaVersion+1, time.Now()); err != nil {
+		t.Fatalf("Failed to record version: %v", err)
+	}
+	storage.Close()
+
+	if _, err := NewSQLiteStorage(path); err == nil || !strings.Contains(err.Error(), "newer than this build supports") {
+		t.Errorf("Expected a newer schema to be rejected, got %v", err)
+	}
+}
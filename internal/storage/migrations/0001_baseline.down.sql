@@ -0,0 +1,15 @@
+-- Removes every table, and with them all stored entries, reports and markers
+DROP TABLE IF EXISTS promoted_fields;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS report_results;
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS log_volume;
+DROP TABLE IF EXISTS log_fields;
+DROP TABLE IF EXISTS log_facets;
+DROP TABLE IF EXISTS log_rollups;
+DROP TRIGGER IF EXISTS logs_au;
+DROP TRIGGER IF EXISTS logs_ad;
+DROP TRIGGER IF EXISTS logs_ai;
+DROP TABLE IF EXISTS logs_fts;
+DROP TABLE IF EXISTS logs;
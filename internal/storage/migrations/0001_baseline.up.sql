@@ -0,0 +1,183 @@
+-- Schema of every table as of the introduction of versioned migrations. Statements are idempotent
+-- so that databases created by earlier versions, whose missing columns are added beforehand, can
+-- be adopted.
+
+-- RFC5424 log entries (existing data is preserved across restarts)
+CREATE TABLE IF NOT EXISTS logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+	-- RFC5424 Header Fields
+	priority INTEGER NOT NULL,
+	facility INTEGER NOT NULL,
+	severity INTEGER NOT NULL,
+	version INTEGER NOT NULL DEFAULT 1,
+	timestamp DATETIME NOT NULL,
+	hostname TEXT,
+	app_name TEXT,
+	proc_id TEXT,
+	msg_id TEXT,
+
+	-- Structured Data and Message
+	structured_data TEXT, -- JSON string
+	message TEXT NOT NULL,
+	raw_message TEXT, -- Message as received, for reprocessing
+
+	-- Hash chain, when enabled
+	chain_partition TEXT,
+	chain_prev TEXT,
+	chain_hash TEXT,
+
+	-- Key the sender gave the entry, while remembered
+	idempotency_key TEXT,
+
+	-- System Fields
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Full-text search on message
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(
+	message,
+	content='logs',
+	content_rowid='id'
+);
+
+-- Indexes for efficient RFC5424 field queries
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_facility ON logs(facility);
+CREATE INDEX IF NOT EXISTS idx_logs_severity ON logs(severity);
+CREATE INDEX IF NOT EXISTS idx_logs_hostname ON logs(hostname);
+CREATE INDEX IF NOT EXISTS idx_logs_app_name ON logs(app_name);
+CREATE INDEX IF NOT EXISTS idx_logs_proc_id ON logs(proc_id);
+CREATE INDEX IF NOT EXISTS idx_logs_msg_id ON logs(msg_id);
+CREATE INDEX IF NOT EXISTS idx_logs_priority ON logs(priority);
+CREATE INDEX IF NOT EXISTS idx_logs_created_at ON logs(created_at);
+-- Composite indexes for common query patterns
+CREATE INDEX IF NOT EXISTS idx_logs_facility_severity ON logs(facility, severity);
+CREATE INDEX IF NOT EXISTS idx_logs_hostname_app_name ON logs(hostname, app_name);
+CREATE INDEX IF NOT EXISTS idx_logs_timestamp_severity ON logs(timestamp, severity);
+-- Expression index for searching by the sender's source address; must match sourceIPExpression
+CREATE INDEX IF NOT EXISTS idx_logs_source_ip ON logs((CASE WHEN json_valid(structured_data) THEN json_extract(structured_data, '$.opentrail.source_ip') END));
+-- Partial index for walking and extending hash chains
+CREATE INDEX IF NOT EXISTS idx_logs_chain ON logs(chain_partition, id) WHERE chain_hash IS NOT NULL;
+-- Entries sent again under the same idempotency key are not inserted
+CREATE UNIQUE INDEX IF NOT EXISTS idx_logs_idempotency_key ON logs(idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- Triggers keeping the full-text index in sync
+CREATE TRIGGER IF NOT EXISTS logs_ai AFTER INSERT ON logs BEGIN
+	INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
+END;
+CREATE TRIGGER IF NOT EXISTS logs_ad AFTER DELETE ON logs BEGIN
+	INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
+END;
+CREATE TRIGGER IF NOT EXISTS logs_au AFTER UPDATE ON logs BEGIN
+	INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
+	INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
+END;
+
+-- Per-minute entry counts by severity, app and host so that long-range histograms can be answered
+-- without scanning raw rows. Sampled counts the messages that sampled entries stand for beyond
+-- themselves, so that counts can be extrapolated.
+CREATE TABLE IF NOT EXISTS log_rollups (
+	bucket INTEGER NOT NULL, -- Unix time of the start of the minute
+	severity INTEGER NOT NULL,
+	app_name TEXT NOT NULL DEFAULT '',
+	hostname TEXT NOT NULL DEFAULT '',
+	count INTEGER NOT NULL,
+	sampled INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (bucket, severity, app_name, hostname)
+) WITHOUT ROWID;
+
+-- Hourly entry counts per value of the facet fields, so that top values and cardinalities can be
+-- computed without DISTINCT scans over the logs table
+CREATE TABLE IF NOT EXISTS log_facets (
+	field TEXT NOT NULL,
+	bucket INTEGER NOT NULL, -- Unix time of the start of the hour
+	value TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (field, bucket, value)
+) WITHOUT ROWID;
+
+-- The field catalog: every structured data key ("sdid.param") and built-in field seen, with its
+-- recent values, used for query autocompletion
+CREATE TABLE IF NOT EXISTS log_fields (
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	last_seen INTEGER NOT NULL, -- Unix time of the newest entry carrying the value
+	PRIMARY KEY (name, value)
+) WITHOUT ROWID;
+
+-- Per-day entry counts and sizes by app, host and tenant. The ingested columns are added to as
+-- entries are stored and outlive them, the stored columns are replaced by each measurement of the
+-- logs table.
+CREATE TABLE IF NOT EXISTS log_volume (
+	day INTEGER NOT NULL, -- Unix time of the start of the UTC day
+	app_name TEXT NOT NULL DEFAULT '',
+	hostname TEXT NOT NULL DEFAULT '',
+	tenant TEXT NOT NULL DEFAULT '',
+	ingested_entries INTEGER NOT NULL DEFAULT 0,
+	ingested_bytes INTEGER NOT NULL DEFAULT 0,
+	stored_entries INTEGER NOT NULL DEFAULT 0,
+	stored_bytes INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (day, app_name, hostname, tenant)
+) WITHOUT ROWID;
+
+-- Reports, their results and the alert history, which retention cleanup does not touch
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	query TEXT NOT NULL DEFAULT '',
+	top_field TEXT NOT NULL DEFAULT '',
+	top_limit INTEGER NOT NULL DEFAULT 0,
+	interval_seconds INTEGER NOT NULL,
+	alert_threshold INTEGER NOT NULL DEFAULT 0,
+	extrapolate INTEGER NOT NULL DEFAULT 0,
+	firing INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	last_run_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS report_results (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	report_id INTEGER NOT NULL,
+	start_time DATETIME NOT NULL,
+	end_time DATETIME NOT NULL,
+	count INTEGER NOT NULL,
+	extrapolated INTEGER, -- NULL for runs before sample rates were counted
+	top_values TEXT, -- JSON array of value counts
+	run_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_report_results_report ON report_results(report_id, end_time);
+CREATE TABLE IF NOT EXISTS alert_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	report_id INTEGER NOT NULL,
+	report_name TEXT NOT NULL,
+	state TEXT NOT NULL,
+	time DATETIME NOT NULL,
+	count INTEGER NOT NULL,
+	threshold INTEGER NOT NULL,
+	samples TEXT -- JSON array of log entries
+);
+CREATE INDEX IF NOT EXISTS idx_alert_events_time ON alert_events(time);
+CREATE INDEX IF NOT EXISTS idx_alert_events_report ON alert_events(report_id, time);
+
+-- Event markers such as deploys, which retention cleanup does not touch
+CREATE TABLE IF NOT EXISTS events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	type TEXT NOT NULL,
+	service TEXT NOT NULL,
+	version TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	time DATETIME NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_events_time ON events(time);
+
+-- The structured data keys materialized as generated columns of logs
+CREATE TABLE IF NOT EXISTS promoted_fields (
+	field TEXT PRIMARY KEY,
+	column_name TEXT NOT NULL UNIQUE,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	ready_at INTEGER
+);
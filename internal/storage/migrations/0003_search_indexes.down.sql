@@ -0,0 +1,9 @@
+-- Restores the single-column indexes of the baseline
+CREATE INDEX IF NOT EXISTS idx_logs_severity ON logs(severity);
+CREATE INDEX IF NOT EXISTS idx_logs_hostname ON logs(hostname);
+CREATE INDEX IF NOT EXISTS idx_logs_app_name ON logs(app_name);
+
+DROP INDEX IF EXISTS idx_logs_severity_timestamp;
+DROP INDEX IF EXISTS idx_logs_hostname_timestamp;
+DROP INDEX IF EXISTS idx_logs_app_name_severity_timestamp;
+DROP INDEX IF EXISTS idx_logs_app_name_timestamp;
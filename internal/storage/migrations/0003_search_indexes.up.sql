@@ -0,0 +1,12 @@
+-- Composite indexes for the common search filters. Each ends with the timestamp (and implicitly the
+-- id), so entries matching the filter are read in entryOrder and a page stops after its last entry
+-- instead of sorting every match; counts by app, severity and time are answered from the index alone.
+CREATE INDEX IF NOT EXISTS idx_logs_app_name_timestamp ON logs(app_name, timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_app_name_severity_timestamp ON logs(app_name, severity, timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_hostname_timestamp ON logs(hostname, timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_severity_timestamp ON logs(severity, timestamp);
+
+-- Single-column indexes that are prefixes of the composite ones only slow down inserts
+DROP INDEX IF EXISTS idx_logs_app_name;
+DROP INDEX IF EXISTS idx_logs_hostname;
+DROP INDEX IF EXISTS idx_logs_severity;
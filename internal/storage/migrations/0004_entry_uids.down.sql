@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_uid;
+ALTER TABLE logs DROP COLUMN uid;
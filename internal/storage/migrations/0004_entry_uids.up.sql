@@ -0,0 +1,4 @@
+-- Globally unique, time-sortable identifiers of entries, set when the entry-ids option selects a
+-- scheme. Entries stored before, or while it is autoincrement, have none.
+ALTER TABLE logs ADD COLUMN uid TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_logs_uid ON logs(uid) WHERE uid IS NOT NULL;
@@ -0,0 +1,7 @@
+-- The web interface preferences of each user, a JSON document of display options, pinned filters
+-- and recent queries
+CREATE TABLE IF NOT EXISTS user_preferences (
+	username TEXT PRIMARY KEY,
+	preferences TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
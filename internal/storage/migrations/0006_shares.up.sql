@@ -0,0 +1,14 @@
+-- Frozen copies of log entries shared by token, kept apart from the logs table so retention does
+-- not remove them; they are removed once they expire
+CREATE TABLE IF NOT EXISTS shares (
+	token TEXT PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	query TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL,
+	entry_count INTEGER NOT NULL,
+	entries TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_shares_expires_at ON shares(expires_at);
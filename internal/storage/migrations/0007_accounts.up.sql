@@ -0,0 +1,20 @@
+-- Users and API tokens managed through the admin API, in addition to the accounts of the server's
+-- configuration; only hashes of their passwords and tokens are kept
+CREATE TABLE IF NOT EXISTS users (
+	username TEXT PRIMARY KEY,
+	password_hash TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	role TEXT NOT NULL,
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME,
+	last_used_at DATETIME
+);
@@ -0,0 +1,7 @@
+-- Settings changed at runtime through the admin API, which take precedence over the server's
+-- configuration
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
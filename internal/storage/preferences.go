@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func loadPreferences(db *sql.DB, user string) (*types.UserPreferences, error) {
+	var document string
+	var updatedAt time.Time
+	err := db.QueryRow("SELECT preferences, updated_at FROM user_preferences WHERE username = ?", user).
+		Scan(&document, &updatedAt)
+	preferences := &types.UserPreferences{}
+	if errors.Is(err, sql.ErrNoRows) {
+		return preferences, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select preferences: %w", err)
+	}
+	if err := json.Unmarshal([]byte(document), preferences); err != nil {
+		return nil, fmt.Errorf("failed to decode preferences: %w", err)
+	}
+	preferences.UpdatedAt = updatedAt.UTC()
+	return preferences, nil
+}
+
+func savePreferences(db *sql.DB, user string, preferences *types.UserPreferences) error {
+	preferences.UpdatedAt = time.Now().UTC()
+	document, err := json.Marshal(preferences)
+	if err != nil {
+		return fmt.Errorf("failed to encode preferences: %w", err)
+	}
+	if _, err := db.Exec(`
+	INSERT INTO user_preferences (username, preferences, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT(username) DO UPDATE SET preferences = excluded.preferences, updated_at = excluded.updated_at`,
+		user, string(document), preferences.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return nil
+}
+
+func deletePreferences(db *sql.DB, user string) error {
+	if _, err := db.Exec("DELETE FROM user_preferences WHERE username = ?", user); err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return nil
+}
+
+// UserPreferences returns the web interface preferences of a user, empty if none were saved
+func (s *SQLiteStorage) UserPreferences(user string) (*types.UserPreferences, error) {
+	return loadPreferences(s.db, user)
+}
+
+// SaveUserPreferences replaces the web interface preferences of a user
+func (s *SQLiteStorage) SaveUserPreferences(user string, preferences *types.UserPreferences) error {
+	return savePreferences(s.db, user, preferences)
+}
+
+// DeleteUserPreferences removes the web interface preferences of a user
+func (s *SQLiteStorage) DeleteUserPreferences(user string) error {
+	return deletePreferences(s.db, user)
+}
+
+// UserPreferences returns the web interface preferences of a user, empty if none were saved
+func (s *BatchedSQLiteStorage) UserPreferences(user string) (*types.UserPreferences, error) {
+	return loadPreferences(s.db, user)
+}
+
+// SaveUserPreferences replaces the web interface preferences of a user
+func (s *BatchedSQLiteStorage) SaveUserPreferences(user string, preferences *types.UserPreferences) error {
+	return savePreferences(s.db, user, preferences)
+}
+
+// DeleteUserPreferences removes the web interface preferences of a user
+func (s *BatchedSQLiteStorage) DeleteUserPreferences(user string) error {
+	return deletePreferences(s.db, user)
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_UserPreferences(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	empty, err := storage.UserPreferences("alice")
+	if err != nil {
+		t.Fatalf("UserPreferences failed: %v", err)
+	}
+	if empty.Display != nil || len(empty.PinnedFilters) != 0 || !empty.UpdatedAt.IsZero() {
+		t.Errorf("Expected no preferences before saving, got %+v", empty)
+	}
+
+	saved := &types.UserPreferences{
+		Display:       json.RawMessage(`{"compactMode":true}`),
+		PinnedFilters: []types.PinnedFilter{{Name: "errors", Filters: json.RawMessage(`{"minSeverity":3}`)}},
+		RecentQueries: []json.RawMessage{json.RawMessage(`{"text":"timeout"}`)},
+	}
+	if err := storage.SaveUserPreferences("alice", saved); err != nil {
+		t.Fatalf("SaveUserPreferences failed: %v", err)
+	}
+	if saved.UpdatedAt.IsZero() {
+		t.Error("Expected the update time to be set")
+	}
+
+	loaded, err := storage.UserPreferences("alice")
+	if err != nil {
+		t.Fatalf("UserPreferences failed: %v", err)
+	}
+	if string(loaded.Display) != `{"compactMode":true}` || len(loaded.PinnedFilters) != 1 || loaded.PinnedFilters[0].Name != "errors" ||
+		len(loaded.RecentQueries) != 1 || !loaded.UpdatedAt.Equal(saved.UpdatedAt) {
+		t.Errorf("Expected the saved preferences, got %+v", loaded)
+	}
+
+	// Saving again replaces them, and other users keep their own
+	if err := storage.SaveUserPreferences("alice", &types.UserPreferences{Display: json.RawMessage(`{"layout":"cards"}`)}); err != nil {
+		t.Fatalf("SaveUserPreferences failed: %v", err)
+	}
+	if loaded, _ = storage.UserPreferences("alice"); string(loaded.Display) != `{"layout":"cards"}` || len(loaded.PinnedFilters) != 0 {
+		t.Errorf("Expected the preferences replaced, got %+v", loaded)
+	}
+	if other, _ := storage.UserPreferences("bob"); other.Display != nil {
+		t.Errorf("Expected no preferences for another user, got %+v", other)
+	}
+
+	if err := storage.DeleteUserPreferences("alice"); err != nil {
+		t.Fatalf("DeleteUserPreferences failed: %v", err)
+	}
+	if loaded, _ = storage.UserPreferences("alice"); loaded.Display != nil || !loaded.UpdatedAt.IsZero() {
+		t.Errorf("Expected the preferences removed, got %+v", loaded)
+	}
+}
@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+const (
+	// maxPromotedFields bounds the number of generated columns added to the logs table
+	maxPromotedFields = 16
+	// maxSDNameLength is the RFC 5424 limit for SD-IDs and parameter names
+	maxSDNameLength = 32
+)
+
+// indexBuild is a running background index build for a promoted field
+type indexBuild struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// fieldPromotions manages the structured data keys promoted to virtual generated columns.
+// Adding a virtual column does not rewrite the table, so promotion itself is instant; the
+// index over existing rows is built in the background and searches use the column as soon
+// as it exists.
+type fieldPromotions struct {
+	db *sql.DB
+
+	mu      sync.RWMutex
+	columns map[string]string // field -> column name
+	builds  map[string]*indexBuild
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newFieldPromotions loads existing promotions and resumes index builds interrupted by a restart
+func newFieldPromotions(db *sql.DB) (*fieldPromotions, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &fieldPromotions{
+		db:      db,
+		columns: make(map[string]string),
+		builds:  make(map[string]*indexBuild),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	rows, err := db.Query("SELECT field, column_name, status FROM promoted_fields")
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to load promoted fields: %w", err)
+	}
+	var building []string
+	for rows.Next() {
+		var field, column, status string
+		if err := rows.Scan(&field, &column, &status); err != nil {
+			rows.Close()
+			cancel()
+			return nil, fmt.Errorf("failed to scan promoted field: %w", err)
+		}
+		p.columns[field] = column
+		if status == types.PromotionBuilding {
+			building = append(building, field)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to read promoted fields: %w", err)
+	}
+
+	p.mu.Lock()
+	for _, field := range building {
+		p.startBuild(field, p.columns[field])
+	}
+	p.mu.Unlock()
+
+	return p, nil
+}
+
+// validatePromotedField checks that field names a single structured data parameter ("sdid.param")
+func validatePromotedField(field string) error {
+	sdID, param, ok := strings.Cut(field, ".")
+	if !ok || sdID == "" || param == "" {
+		return fmt.Errorf("%w: %q must have the form sdid.param", interfaces.ErrInvalidPromotion, field)
+	}
+	if len(sdID) > maxSDNameLength || len(param) > maxSDNameLength {
+		return fmt.Errorf("%w: SD-IDs and parameter names are at most %d characters", interfaces.ErrInvalidPromotion, maxSDNameLength)
+	}
+	for _, r := range field {
+		if r == '"' || r == '\'' || r == '=' || r == ']' || r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return fmt.Errorf("%w: %q contains characters not allowed in SD names", interfaces.ErrInvalidPromotion, field)
+		}
+	}
+	return nil
+}
+
+// promotedColumnName derives a stable column name from a field. The hash keeps names unique
+// when sanitizing maps different fields to the same characters.
+func promotedColumnName(field string) string {
+	var name strings.Builder
+	for _, r := range strings.ToLower(field) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+		} else {
+			name.WriteByte('_')
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(field))
+	return fmt.Sprintf("sd_%s_%08x", name.String(), hash.Sum32())
+}
+
+// column returns the generated column of a promoted field
+func (p *fieldPromotions) column(field string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	column, ok := p.columns[field]
+	return column, ok
+}
+
+// promote adds a generated column for field and starts building its index. Promoting a field
+// that is already promoted returns its current state.
+func (p *fieldPromotions) promote(field string) (*types.PromotedField, error) {
+	if err := validatePromotedField(field); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.columns[field]; ok {
+		return p.get(field)
+	}
+	if len(p.columns) >= maxPromotedFields {
+		return nil, fmt.Errorf("%w: at most %d fields can be promoted", interfaces.ErrInvalidPromotion, maxPromotedFields)
+	}
+
+	sdID, param, _ := strings.Cut(field, ".")
+	column := promotedColumnName(field)
+	addColumn := fmt.Sprintf(
+		`ALTER TABLE logs ADD COLUMN "%s" TEXT GENERATED ALWAYS AS (CASE WHEN json_valid(structured_data) THEN json_extract(structured_data, '%s') END) VIRTUAL`,
+		column, jsonPath(sdID, param))
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(addColumn); err != nil {
+		return nil, fmt.Errorf("failed to add column for %s: %w", field, err)
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO promoted_fields (field, column_name, status, created_at) VALUES (?, ?, ?, ?)",
+		field, column, types.PromotionBuilding, time.Now().Unix()); err != nil {
+		return nil, fmt.Errorf("failed to record promoted field: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit field promotion: %w", err)
+	}
+
+	p.columns[field] = column
+	p.startBuild(field, column)
+	return p.get(field)
+}
+
+// startBuild creates the index of a promoted column in the background. The caller holds p.mu.
+func (p *fieldPromotions) startBuild(field, column string) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	build := &indexBuild{cancel: cancel, done: make(chan struct{})}
+	p.builds[field] = build
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(build.done)
+		defer cancel()
+
+		// The partial index skips entries without the field; equality filters imply IS NOT NULL
+		// so SQLite can still use it for them
+		_, err := p.db.ExecContext(ctx, fmt.Sprintf(
+			`CREATE INDEX IF NOT EXISTS "idx_logs_%s" ON logs("%s") WHERE "%s" IS NOT NULL`,
+			column, column, column))
+
+		p.mu.Lock()
+		if p.builds[field] == build {
+			delete(p.builds, field)
+		}
+		p.mu.Unlock()
+
+		// Interrupted builds are resumed on the next start, or discarded by a demotion
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			_, err = p.db.Exec("UPDATE promoted_fields SET status = ?, error = ? WHERE field = ?",
+				types.PromotionFailed, err.Error(), field)
+		} else {
+			_, err = p.db.Exec("UPDATE promoted_fields SET status = ?, error = '', ready_at = ? WHERE field = ?",
+				types.PromotionReady, time.Now().Unix(), field)
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to update promotion status of %s: %v\n", field, err)
+		}
+	}()
+}
+
+// demote stops any running index build and drops the index and column of a promoted field
+func (p *fieldPromotions) demote(field string) error {
+	p.mu.Lock()
+	column, ok := p.columns[field]
+	if !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("%w: %s", interfaces.ErrFieldNotPromoted, field)
+	}
+	// Searches fall back to JSON extraction before the column disappears
+	delete(p.columns, field)
+	build := p.builds[field]
+	delete(p.builds, field)
+	p.mu.Unlock()
+
+	if build != nil {
+		build.cancel()
+		<-build.done
+	}
+
+	err := p.dropColumn(field, column)
+	if err != nil {
+		p.mu.Lock()
+		p.columns[field] = column
+		p.mu.Unlock()
+	}
+	return err
+}
+
+// dropColumn removes the index, column and promotion record of a field in one transaction
+func (p *fieldPromotions) dropColumn(field, column string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS "idx_logs_%s"`, column)); err != nil {
+		return fmt.Errorf("failed to drop index for %s: %w", field, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE logs DROP COLUMN "%s"`, column)); err != nil {
+		return fmt.Errorf("failed to drop column for %s: %w", field, err)
+	}
+	if _, err := tx.Exec("DELETE FROM promoted_fields WHERE field = ?", field); err != nil {
+		return fmt.Errorf("failed to delete promoted field: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit field demotion: %w", err)
+	}
+	return nil
+}
+
+// get reads the promotion record of a single field
+func (p *fieldPromotions) get(field string) (*types.PromotedField, error) {
+	promoted, err := scanPromotedField(p.db.QueryRow(
+		"SELECT field, column_name, status, error, created_at, ready_at FROM promoted_fields WHERE field = ?", field))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read promoted field: %w", err)
+	}
+	return promoted, nil
+}
+
+// list returns all promotion records, oldest first
+func (p *fieldPromotions) list() ([]types.PromotedField, error) {
+	rows, err := p.db.Query(
+		"SELECT field, column_name, status, error, created_at, ready_at FROM promoted_fields ORDER BY created_at, field")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promoted fields: %w", err)
+	}
+	defer rows.Close()
+
+	fields := []types.PromotedField{}
+	for rows.Next() {
+		promoted, err := scanPromotedField(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promoted field: %w", err)
+		}
+		fields = append(fields, *promoted)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read promoted fields: %w", err)
+	}
+	return fields, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPromotedField scans a promoted_fields row selected by get or list
+func scanPromotedField(row rowScanner) (*types.PromotedField, error) {
+	var promoted types.PromotedField
+	var createdAt int64
+	var readyAt sql.NullInt64
+	if err := row.Scan(&promoted.Field, &promoted.Column, &promoted.Status, &promoted.Error, &createdAt, &readyAt); err != nil {
+		return nil, err
+	}
+	promoted.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if readyAt.Valid {
+		ready := time.Unix(readyAt.Int64, 0).UTC()
+		promoted.ReadyAt = &ready
+	}
+	return &promoted, nil
+}
+
+// stop interrupts running index builds; they resume when the database is opened again
+func (p *fieldPromotions) stop() {
+	if p == nil {
+		return
+	}
+	p.cancel()
+	p.wg.Wait()
+}
+
+// PromoteField adds an indexed generated column for a structured data field
+func (s *SQLiteStorage) PromoteField(field string) (*types.PromotedField, error) {
+	return s.promotions.promote(field)
+}
+
+// DemoteField drops the generated column and index of a promoted field
+func (s *SQLiteStorage) DemoteField(field string) error {
+	return s.promotions.demote(field)
+}
+
+// PromotedFields lists the promoted fields and their index status
+func (s *SQLiteStorage) PromotedFields() ([]types.PromotedField, error) {
+	return s.promotions.list()
+}
+
+// PromoteField adds an indexed generated column for a structured data field
+func (s *BatchedSQLiteStorage) PromoteField(field string) (*types.PromotedField, error) {
+	return s.promotions.promote(field)
+}
+
+// DemoteField drops the generated column and index of a promoted field
+func (s *BatchedSQLiteStorage) DemoteField(field string) error {
+	return s.promotions.demote(field)
+}
+
+// PromotedFields lists the promoted fields and their index status
+func (s *BatchedSQLiteStorage) PromotedFields() ([]types.PromotedField, error) {
+	return s.promotions.list()
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// waitForPromotion polls until the index of a promoted field has been built
+func waitForPromotion(t *testing.T, promoter interfaces.FieldPromoter, field string) types.PromotedField {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		fields, err := promoter.PromotedFields()
+		if err != nil {
+			t.Fatalf("PromotedFields failed: %v", err)
+		}
+		for _, promoted := range fields {
+			if promoted.Field == field && promoted.Status != types.PromotionBuilding {
+				return promoted
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Promotion of %s did not finish", field)
+	return types.PromotedField{}
+}
+
+func TestSQLiteStorage_PromoteField(t *testing.T) {
+	path := t.TempDir() + "/promote.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+
+	base := time.Now().UTC().Truncate(time.Second)
+	userIDs := []string{"42", "43", "42", ""}
+	for i, userID := range userIDs {
+		entry := &types.LogEntry{
+			Severity:  6,
+			Version:   1,
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Hostname:  "web01",
+			AppName:   "api",
+			Message:   "promotion test",
+		}
+		if userID != "" {
+			entry.StructuredData = map[string]interface{}{
+				"request@32473": map[string]string{"user_id": userID},
+			}
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	promoted, err := storage.PromoteField("request@32473.user_id")
+	if err != nil {
+		t.Fatalf("PromoteField failed: %v", err)
+	}
+	if promoted.Column == "" || !strings.HasPrefix(promoted.Column, "sd_request_32473_user_id_") {
+		t.Errorf("Unexpected column name %q", promoted.Column)
+	}
+
+	ready := waitForPromotion(t, storage, "request@32473.user_id")
+	if ready.Status != types.PromotionReady || ready.ReadyAt == nil {
+		t.Fatalf("Expected ready promotion, got %+v", ready)
+	}
+
+	// Promoting again returns the existing promotion
+	again, err := storage.PromoteField("request@32473.user_id")
+	if err != nil || again.Column != promoted.Column {
+		t.Errorf("Expected idempotent promotion, got %+v, %v", again, err)
+	}
+
+	// Entries stored after promotion are covered by the generated column
+	if err := storage.Store(&types.LogEntry{
+		Severity: 6, Version: 1, Timestamp: base.Add(time.Minute), Hostname: "web02", AppName: "api", Message: "late",
+		StructuredData: map[string]interface{}{"request@32473": map[string]string{"user_id": "42"}},
+	}); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	results, err := storage.Search(types.SearchQuery{
+		Filters: []types.FieldFilter{{Field: "request@32473.user_id", Value: "42"}},
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 entries for user 42, got %d", len(results))
+	}
+
+	results, err = storage.Search(types.SearchQuery{
+		Filters: []types.FieldFilter{{Field: "request@32473.user_id", Value: "42", Negate: true}},
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 entries without user 42, got %d", len(results))
+	}
+
+	condition, args := fieldFilterCondition(types.FieldFilter{Field: "request@32473.user_id", Value: "42"}, storage.promotions)
+	var plan strings.Builder
+	rows, err := storage.db.Query("EXPLAIN QUERY PLAN SELECT id FROM logs WHERE "+condition, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("Failed to scan plan: %v", err)
+		}
+		plan.WriteString(detail)
+	}
+	rows.Close()
+	if !strings.Contains(plan.String(), "idx_logs_"+promoted.Column) {
+		t.Errorf("Expected the promoted index to be used, plan: %s", plan.String())
+	}
+
+	// Promotions survive a restart
+	storage.Close()
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	storage = reopened.(*SQLiteStorage)
+	defer storage.Close()
+
+	if column, ok := storage.promotions.column("request@32473.user_id"); !ok || column != promoted.Column {
+		t.Errorf("Expected promotion to be loaded, got %q", column)
+	}
+
+	if err := storage.DemoteField("request@32473.user_id"); err != nil {
+		t.Fatalf("DemoteField failed: %v", err)
+	}
+	fields, err := storage.PromotedFields()
+	if err != nil || len(fields) != 0 {
+		t.Errorf("Expected no promoted fields, got %+v, %v", fields, err)
+	}
+	if err := storage.DemoteField("request@32473.user_id"); !errors.Is(err, interfaces.ErrFieldNotPromoted) {
+		t.Errorf("Expected ErrFieldNotPromoted, got %v", err)
+	}
+
+	// Filters fall back to JSON extraction
+	results, err = storage.Search(types.SearchQuery{
+		Filters: []types.FieldFilter{{Field: "request@32473.user_id", Value: "42"}},
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("Search after demotion failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 entries after demotion, got %d", len(results))
+	}
+}
+
+func TestSQLiteStorage_PromoteField_Invalid(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	for _, field := range []string{
+		"hostname",
+		"user_id",
+		".user_id",
+		"request.",
+		`request."user"`,
+		"request.user'id",
+		"request.user id",
+		"request." + strings.Repeat("x", maxSDNameLength+1),
+	} {
+		if _, err := storage.PromoteField(field); !errors.Is(err, interfaces.ErrInvalidPromotion) {
+			t.Errorf("Expected %q to be rejected, got %v", field, err)
+		}
+	}
+
+	for i := 0; i < maxPromotedFields; i++ {
+		if _, err := storage.PromoteField("limit.field" + string(rune('a'+i))); err != nil {
+			t.Fatalf("PromoteField failed: %v", err)
+		}
+	}
+	if _, err := storage.PromoteField("limit.overflow"); !errors.Is(err, interfaces.ErrInvalidPromotion) {
+		t.Errorf("Expected promotion limit to be enforced, got %v", err)
+	}
+}
+
+func TestPromotedColumnName(t *testing.T) {
+	a := promotedColumnName("request@32473.user-id")
+	b := promotedColumnName("request@32473.user_id")
+	if a == b {
+		t.Errorf("Expected distinct column names, got %s", a)
+	}
+	if a != promotedColumnName("request@32473.user-id") {
+		t.Error("Expected column names to be stable")
+	}
+}
+
+func TestBatchedSQLiteStorage_PromoteField(t *testing.T) {
+	created, err := NewBatchedSQLiteStorage(t.TempDir()+"/batched_promote.db", DefaultBatchConfig())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*BatchedSQLiteStorage)
+	defer storage.Close()
+
+	if _, err := storage.PromoteField("origin.software"); err != nil {
+		t.Fatalf("PromoteField failed: %v", err)
+	}
+	waitForPromotion(t, storage, "origin.software")
+
+	entry := &types.LogEntry{
+		Severity: 6, Version: 1, Timestamp: time.Now().UTC(), Hostname: "web01", AppName: "api", Message: "batched",
+		StructuredData: map[string]interface{}{"origin": map[string]string{"software": "rsyslogd"}},
+	}
+	storage.executeBatchWrite([]*writeRequest{newWriteRequest(entry, context.Background())})
+
+	results, err := storage.Search(types.SearchQuery{
+		Filters: []types.FieldFilter{{Field: "origin.software", Value: "rsyslogd"}},
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 entry, got %d", len(results))
+	}
+}
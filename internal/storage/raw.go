@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// rawMessage returns the raw_message value to store for an entry: NULL when none was retained,
+// DEFLATE-compressed bytes (a BLOB) when compression is enabled and saves space, text otherwise
+func rawMessage(entry *types.LogEntry, compress bool) interface{} {
+	if entry.Raw == "" {
+		return nil
+	}
+	if !compress {
+		return entry.Raw
+	}
+
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return entry.Raw
+	}
+	if _, err := writer.Write([]byte(entry.Raw)); err != nil {
+		return entry.Raw
+	}
+	if err := writer.Close(); err != nil || buf.Len() >= len(entry.Raw) {
+		// Short lines rarely compress, keep them readable
+		return entry.Raw
+	}
+	return buf.Bytes()
+}
+
+// decodeRawMessage converts a scanned raw_message value back into the message as received.
+// Text values are stored as is and BLOBs are compressed, so both modes can coexist in one database.
+func decodeRawMessage(value interface{}) (string, error) {
+	switch raw := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return raw, nil
+	case []byte:
+		decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress raw message: %w", err)
+		}
+		return string(decoded), nil
+	default:
+		return "", fmt.Errorf("unexpected raw message type %T", value)
+	}
+}
+
+// queryRawMessage reads the raw message of a single entry
+func queryRawMessage(db *sql.DB, id int64) (string, error) {
+	var raw interface{}
+	err := db.QueryRow("SELECT raw_message FROM logs WHERE id = ?", id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: %d", interfaces.ErrEntryNotFound, id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read raw message: %w", err)
+	}
+	return decodeRawMessage(raw)
+}
+
+// SetRawCompression selects whether raw messages are stored compressed
+func (s *SQLiteStorage) SetRawCompression(enabled bool) {
+	s.compressRaw.Store(enabled)
+}
+
+// RawMessage returns the message of an entry as received, empty if none was retained
+func (s *SQLiteStorage) RawMessage(id int64) (string, error) {
+	return queryRawMessage(s.db, id)
+}
+
+// SetRawCompression selects whether raw messages are stored compressed
+func (s *BatchedSQLiteStorage) SetRawCompression(enabled bool) {
+	s.compressRaw.Store(enabled)
+}
+
+// RawMessage returns the message of an entry as received, empty if none was retained
+func (s *BatchedSQLiteStorage) RawMessage(id int64) (string, error) {
+	return queryRawMessage(s.db, id)
+}
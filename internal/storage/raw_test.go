@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_RawMessage(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	long := "<134>1 2024-05-01T10:00:00Z web01 api - - - " + strings.Repeat("request served ", 20)
+	short := "<134>1 - - - - - - hi"
+
+	store := func(raw string) int64 {
+		entry := &types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: "raw test", Raw: raw}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+		return entry.ID
+	}
+
+	plainID := store(long)
+	storage.SetRawCompression(true)
+	compressedID := store(long)
+	shortID := store(short)
+	noneID := store("")
+
+	var compressedType, shortType string
+	storage.db.QueryRow("SELECT typeof(raw_message) FROM logs WHERE id = ?", compressedID).Scan(&compressedType)
+	storage.db.QueryRow("SELECT typeof(raw_message) FROM logs WHERE id = ?", shortID).Scan(&shortType)
+	if compressedType != "blob" || shortType != "text" {
+		t.Errorf("Expected long messages compressed and short ones kept as text, got %s and %s", compressedType, shortType)
+	}
+
+	for id, want := range map[int64]string{plainID: long, compressedID: long, shortID: short, noneID: ""} {
+		raw, err := storage.RawMessage(id)
+		if err != nil {
+			t.Fatalf("RawMessage(%d) failed: %v", id, err)
+		}
+		if raw != want {
+			t.Errorf("RawMessage(%d) = %q, want %q", id, raw, want)
+		}
+	}
+
+	if _, err := storage.RawMessage(noneID + 100); !errors.Is(err, interfaces.ErrEntryNotFound) {
+		t.Errorf("Expected ErrEntryNotFound, got %v", err)
+	}
+
+	// Compressed messages can be reprocessed like plain ones
+	batch, err := storage.ReprocessBatch(types.ReprocessQuery{}, plainID, 10, func(raw string) (*types.LogEntry, error) {
+		if raw != long && raw != short {
+			t.Errorf("Unexpected raw message %q", raw)
+		}
+		return &types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: "raw test"}, nil
+	})
+	if err != nil || batch.Processed != 2 {
+		t.Errorf("Unexpected reprocess batch %+v, %v", batch, err)
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// recoveryLogInterval is how often the progress of a startup phase is logged
+	recoveryLogInterval = 5 * time.Second
+	// recoveryProgressRows is the number of entries between progress updates while rollups are rebuilt
+	recoveryProgressRows = 10000
+
+	// walHeaderSize and walFrameHeaderSize are the sizes of the write-ahead log header and of the
+	// header preceding every page in it
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// RecoveryTracker follows the work storage does on startup: applying the write-ahead log an
+// unclean shutdown left behind and rebuilding rollups. Pass one in BatchConfig to report progress
+// while storage is still being opened, e.g. on a readiness endpoint. It is safe for concurrent use.
+type RecoveryTracker struct {
+	mu           sync.Mutex
+	status       types.RecoveryStatus
+	phaseStarted time.Time
+	// stopLogging ends the periodic progress log of the phase in progress
+	stopLogging chan struct{}
+}
+
+// NewRecoveryTracker creates a tracker, starting the clock
+func NewRecoveryTracker() *RecoveryTracker {
+	return &RecoveryTracker{status: types.RecoveryStatus{StartedAt: time.Now()}}
+}
+
+// Status returns the progress so far
+func (t *RecoveryTracker) Status() types.RecoveryStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.statusLocked(time.Now())
+}
+
+func (t *RecoveryTracker) statusLocked(now time.Time) types.RecoveryStatus {
+	status := t.status
+	end := now
+	if status.FinishedAt != nil {
+		end = *status.FinishedAt
+	}
+	status.ElapsedSeconds = end.Sub(status.StartedAt).Seconds()
+
+	// Only rebuilding rollups advances in steps; a checkpoint applies the whole log at once
+	if status.Phase == types.RecoveryRollups && status.EntriesRecovered > 0 && status.EntriesTotal > status.EntriesRecovered {
+		rate := float64(status.EntriesRecovered) / now.Sub(t.phaseStarted).Seconds()
+		remaining := float64(status.EntriesTotal-status.EntriesRecovered) / rate
+		status.EstimatedRemainingSeconds = &remaining
+	}
+	return status
+}
+
+// beginPhase starts a phase, logging its progress until endPhase
+func (t *RecoveryTracker) beginPhase(phase string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.status.Phase = phase
+	t.phaseStarted = time.Now()
+	stop := make(chan struct{})
+	t.stopLogging = stop
+	t.mu.Unlock()
+
+	t.logProgress("started")
+	go func() {
+		ticker := time.NewTicker(recoveryLogInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.logProgress("in progress")
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// endPhase finishes the phase in progress
+func (t *RecoveryTracker) endPhase() {
+	if t == nil {
+		return
+	}
+	t.logProgress("finished")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopLogging != nil {
+		close(t.stopLogging)
+		t.stopLogging = nil
+	}
+	t.status.Phase = ""
+}
+
+// finish marks storage ready
+func (t *RecoveryTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.status.Ready = true
+	t.status.FinishedAt = &now
+}
+
+// update changes the progress of the phase in progress
+func (t *RecoveryTracker) update(fn func(status *types.RecoveryStatus)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(&t.status)
+}
+
+// logProgress logs the progress of the phase in progress as key=value pairs
+func (t *RecoveryTracker) logProgress(event string) {
+	t.mu.Lock()
+	status := t.statusLocked(time.Now())
+	t.mu.Unlock()
+
+	remaining := "unknown"
+	if status.EstimatedRemainingSeconds != nil {
+		remaining = (time.Duration(*status.EstimatedRemainingSeconds) * time.Second).String()
+	}
+	log.Printf("Startup recovery %s: phase=%s wal_frames=%d wal_frames_applied=%d entries_recovered=%d entries_total=%d elapsed=%s estimated_remaining=%s",
+		event, status.Phase, status.WALFrames, status.WALFramesApplied, status.EntriesRecovered, status.EntriesTotal,
+		(time.Duration(status.ElapsedSeconds) * time.Second).String(), remaining)
+}
+
+// pendingWALFrames returns the number of frames in the write-ahead log of a database, which a clean
+// shutdown leaves empty or removes. It is an upper bound, as frames of an earlier cycle of the log
+// may follow the valid ones.
+func pendingWALFrames(dbPath string) int64 {
+	file, err := os.Open(dbPath + "-wal")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() <= walHeaderSize {
+		return 0
+	}
+	_, pageSize, ok := readWALHeader(file)
+	if !ok {
+		return 0
+	}
+	return (info.Size() - walHeaderSize) / (pageSize + walFrameHeaderSize)
+}
+
+// readWALHeader reads the header of a write-ahead log file and the page size it records
+func readWALHeader(file io.Reader) ([]byte, int64, bool) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, 0, false
+	}
+	if magic := binary.BigEndian.Uint32(header[0:4]); magic != 0x377f0682 && magic != 0x377f0683 {
+		return nil, 0, false
+	}
+	pageSize := int64(binary.BigEndian.Uint32(header[8:12]))
+	if pageSize == 1 {
+		// A page size of 65536 does not fit the 16 bits it was once stored in
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil, 0, false
+	}
+	return header, pageSize, true
+}
+
+// applyWAL writes the frames of the write-ahead log back to the database. The checkpoint is
+// passive: after a crash nothing else has the database open, and when a running server does, e.g.
+// while importing, its writers and readers are not held up.
+func applyWAL(db *sql.DB, recovery *RecoveryTracker) error {
+	var busy, frames, checkpointed int64
+	if err := db.QueryRow("PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return fmt.Errorf("failed to apply write-ahead log: %w", err)
+	}
+	recovery.update(func(status *types.RecoveryStatus) {
+		// The log holds fewer valid frames than its size suggests after it was reused
+		status.WALFrames = frames
+		status.WALFramesApplied = checkpointed
+	})
+	if checkpointed < frames {
+		log.Printf("Write-ahead log is in use by another connection, %d of %d frames applied", checkpointed, frames)
+	}
+	return nil
+}
+
+// openWAL enables the write-ahead log through configure, then applies the frames an unclean
+// shutdown left in it
+func openWAL(dbPath string, db *sql.DB, recovery *RecoveryTracker, configure func() error) error {
+	frames := pendingWALFrames(dbPath)
+	if frames == 0 {
+		return configure()
+	}
+
+	recovery.update(func(status *types.RecoveryStatus) {
+		status.WALFrames = frames
+	})
+	recovery.beginPhase(types.RecoveryWAL)
+	defer recovery.endPhase()
+
+	// Opening the database reads the whole log to rebuild its index
+	if err := configure(); err != nil {
+		return err
+	}
+	return applyWAL(db, recovery)
+}
+
+// RecoveryStatus reports the progress of the work done on opening the database
+func (s *SQLiteStorage) RecoveryStatus() types.RecoveryStatus {
+	return s.recovery.Status()
+}
+
+// RecoveryStatus reports the progress of the work done on opening the database
+func (s *BatchedSQLiteStorage) RecoveryStatus() types.RecoveryStatus {
+	return s.config.Recovery.Status()
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestRecovery_AppliesWALAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "logs.db")
+	storage, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		entry := &types.LogEntry{Priority: 14, Timestamp: base.Add(time.Duration(i) * time.Second), AppName: "api", Message: fmt.Sprintf("entry %d", i)}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+
+	// Copying the files of the open database leaves the copy as a crash would
+	crashedPath := filepath.Join(dir, "crashed.db")
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(dbPath + suffix)
+		if err != nil {
+			t.Fatalf("Failed to read database file: %v", err)
+		}
+		if err := os.WriteFile(crashedPath+suffix, data, 0o644); err != nil {
+			t.Fatalf("Failed to copy database file: %v", err)
+		}
+	}
+	storage.Close()
+
+	frames := pendingWALFrames(crashedPath)
+	if frames == 0 {
+		t.Fatal("Expected frames in the copied write-ahead log")
+	}
+
+	recovered, err := NewSQLiteStorage(crashedPath)
+	if err != nil {
+		t.Fatalf("Failed to open crashed database: %v", err)
+	}
+	defer recovered.Close()
+
+	status := recovered.(*SQLiteStorage).RecoveryStatus()
+	if !status.Ready || status.Phase != "" || status.WALFrames == 0 || status.WALFrames > frames || status.WALFramesApplied != status.WALFrames {
+		t.Errorf("Unexpected recovery status %+v", status)
+	}
+	entries, err := recovered.GetRecent(100)
+	if err != nil || len(entries) != 20 {
+		t.Errorf("Expected 20 recovered entries, got %d (%v)", len(entries), err)
+	}
+}
+
+func TestRecovery_ReportsRollupRebuild(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		entry := &types.LogEntry{Priority: 14, Timestamp: base.Add(time.Duration(i) * time.Minute), AppName: "api", Message: "entry"}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+	if _, err := storage.db.Exec("DELETE FROM log_rollups"); err != nil {
+		t.Fatalf("Failed to clear rollups: %v", err)
+	}
+
+	tracker := NewRecoveryTracker()
+	if err := backfillRollups(storage.db, tracker); err != nil {
+		t.Fatalf("backfillRollups failed: %v", err)
+	}
+	status := tracker.Status()
+	if status.EntriesRecovered != 5 || status.EntriesTotal != 5 || status.Phase != "" {
+		t.Errorf("Unexpected recovery status %+v", status)
+	}
+	if status.Ready {
+		t.Error("Expected the tracker to be ready only once storage is opened")
+	}
+}
+
+func TestRecoveryTracker_EstimatesRemaining(t *testing.T) {
+	tracker := NewRecoveryTracker()
+	tracker.beginPhase(types.RecoveryRollups)
+	defer tracker.endPhase()
+
+	tracker.mu.Lock()
+	tracker.phaseStarted = time.Now().Add(-10 * time.Second)
+	tracker.mu.Unlock()
+	tracker.update(func(status *types.RecoveryStatus) {
+		status.EntriesTotal = 400
+		status.EntriesRecovered = 100
+	})
+
+	status := tracker.Status()
+	if status.EstimatedRemainingSeconds == nil {
+		t.Fatal("Expected an estimate of the remaining time")
+	}
+	if remaining := *status.EstimatedRemainingSeconds; remaining < 29 || remaining > 31 {
+		t.Errorf("Expected about 30 seconds remaining, got %v", remaining)
+	}
+}
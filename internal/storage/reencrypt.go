@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"opentrail/internal/types"
+)
+
+// reencryptBatch passes the entries with structured data among up to limit entries with IDs above
+// afterID to seal, and rewrites the structured data of those it changed. Their raw messages are
+// dropped, as they hold the values in plaintext, and the field catalog loses the values that are
+// encrypted now. Hash-chained entries are never rewritten, as that would break their chain.
+func reencryptBatch(db *sql.DB, afterID int64, limit int, seal func(*types.LogEntry) bool) (*types.ReencryptBatch, error) {
+	rows, err := db.Query(`
+	SELECT id, timestamp, app_name, structured_data FROM logs
+	WHERE id > ? AND structured_data IS NOT NULL AND structured_data != '' AND chain_hash IS NULL
+	ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select entries to re-encrypt: %w", err)
+	}
+	var stored []storedEntry
+	for rows.Next() {
+		entry := &types.LogEntry{}
+		var structuredData string
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.AppName, &structuredData); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan entry to re-encrypt: %w", err)
+		}
+		stored = append(stored, storedEntry{entry: entry, structuredData: structuredData})
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries to re-encrypt: %w", err)
+	}
+
+	batch := &types.ReencryptBatch{LastID: afterID, Done: len(stored) < limit}
+	if len(stored) == 0 {
+		return batch, nil
+	}
+	batch.LastID = stored[len(stored)-1].entry.ID
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts := newRollupCounts()
+	for _, row := range stored {
+		batch.Processed++
+		entry := row.entry
+		if err := json.Unmarshal([]byte(row.structuredData), &entry.StructuredData); err != nil {
+			continue
+		}
+		if !seal(entry) {
+			continue
+		}
+		structuredDataJSON, err := json.Marshal(entry.StructuredData)
+		if err != nil {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE logs SET structured_data = ?, raw_message = NULL WHERE id = ?", string(structuredDataJSON), entry.ID); err != nil {
+			return nil, fmt.Errorf("failed to update entry %d: %w", entry.ID, err)
+		}
+
+		// Only the structured data changed, so only its catalog values are counted again
+		old := &types.LogEntry{Timestamp: entry.Timestamp}
+		json.Unmarshal([]byte(row.structuredData), &old.StructuredData)
+		counts.removeFields(old)
+		counts.addFields(&types.LogEntry{Timestamp: entry.Timestamp, StructuredData: entry.StructuredData})
+		counts.removed = true
+		batch.Updated++
+	}
+
+	if err := counts.apply(tx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit re-encrypted entries: %w", err)
+	}
+	return batch, nil
+}
+
+// ReencryptBatch rewrites the encrypted fields of a batch of stored entries
+func (s *SQLiteStorage) ReencryptBatch(afterID int64, limit int, seal func(*types.LogEntry) bool) (*types.ReencryptBatch, error) {
+	return reencryptBatch(s.db, afterID, limit, seal)
+}
+
+// ReencryptBatch rewrites the encrypted fields of a batch of stored entries
+func (s *BatchedSQLiteStorage) ReencryptBatch(afterID int64, limit int, seal func(*types.LogEntry) bool) (*types.ReencryptBatch, error) {
+	return reencryptBatch(s.db, afterID, limit, seal)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_ReencryptBatch(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		entry := &types.LogEntry{Version: 1, Priority: 134, Facility: 16, Severity: 6, Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Hostname: "host", AppName: "app", Message: fmt.Sprintf("login %d", i), Raw: fmt.Sprintf("<134>1 raw %d", i),
+			StructuredData: map[string]interface{}{"auth": map[string]string{"token": fmt.Sprintf("s3cret%d", i), "user": "alice"}}}
+		if i == 2 {
+			entry.StructuredData = nil
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	// A stand-in for the encryptor sealing the token
+	seal := func(entry *types.LogEntry) bool {
+		params := entry.StructuredData["auth"].(map[string]interface{})
+		if params["token"] == "enc:v1:test:sealed" {
+			return false
+		}
+		params["token"] = "enc:v1:test:sealed"
+		return true
+	}
+
+	batch, err := storage.ReencryptBatch(0, 10, seal)
+	if err != nil {
+		t.Fatalf("ReencryptBatch failed: %v", err)
+	}
+	if batch.Processed != 2 || batch.Updated != 2 || !batch.Done {
+		t.Errorf("Expected the two entries with structured data updated, got %+v", batch)
+	}
+
+	detail, err := storage.EntryDetail(1)
+	if err != nil {
+		t.Fatalf("EntryDetail failed: %v", err)
+	}
+	auth := detail.Entry.StructuredData["auth"].(map[string]interface{})
+	if auth["token"] != "enc:v1:test:sealed" || auth["user"] != "alice" || detail.Raw != "" {
+		t.Errorf("Expected the token sealed and the raw message dropped, got %+v (raw %q)", auth, detail.Raw)
+	}
+	if raw, err := storage.RawMessage(3); err != nil || raw != "<134>1 raw 2" {
+		t.Errorf("Expected entries without structured data to keep their raw message, got %q (%v)", raw, err)
+	}
+
+	values, err := storage.FieldValues(types.FieldValuesQuery{Field: "auth.token", Limit: 10})
+	if err != nil {
+		t.Fatalf("FieldValues failed: %v", err)
+	}
+	if len(values) != 0 {
+		t.Errorf("Expected no token values left in the field catalog, got %+v", values)
+	}
+	if values, _ := storage.FieldValues(types.FieldValuesQuery{Field: "auth.user", Limit: 10}); len(values) != 1 || values[0].Count != 2 {
+		t.Errorf("Expected the other values to keep their counts, got %+v", values)
+	}
+
+	if batch, err := storage.ReencryptBatch(0, 10, seal); err != nil || batch.Updated != 0 {
+		t.Errorf("Expected nothing left to re-encrypt, got %+v (%v)", batch, err)
+	}
+}
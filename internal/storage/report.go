@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+// reportTopExpression returns the SQL expression grouping a report's top values, with its argument
+func reportTopExpression(field string) (string, []interface{}, error) {
+	if slices.Contains(types.ReportTopFields, field) {
+		return field, nil, nil
+	}
+	sdID, param, ok := strings.Cut(field, ".")
+	if !ok || sdID == "" || param == "" {
+		return "", nil, fmt.Errorf("unsupported report top field: %s", field)
+	}
+	return "CASE WHEN json_valid(structured_data) THEN json_extract(structured_data, ?) END", []interface{}{jsonPath(sdID, param)}, nil
+}
+
+// reportSource returns the FROM and WHERE clauses selecting the entries matched by a compiled query
+func reportSource(query types.SearchQuery, promotions *fieldPromotions) (string, []interface{}) {
+	source := " FROM logs"
+	var args []interface{}
+	conditions, conditionArgs := searchConditions(query, promotions)
+	if query.Text != "" {
+		source = " FROM logs JOIN logs_fts ON logs.id = logs_fts.rowid"
+		conditions = append([]string{"logs_fts MATCH ?"}, conditions...)
+		args = append(args, query.Text)
+	}
+	args = append(args, conditionArgs...)
+	if len(conditions) > 0 {
+		source += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return source, args
+}
+
+func createReport(db *sql.DB, report *types.Report) error {
+	report.CreatedAt = time.Now().UTC()
+	result, err := db.Exec(`
+	INSERT INTO reports (name, query, top_field, top_limit, interval_seconds, alert_threshold, extrapolate, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.Name, report.Query, report.TopField, report.TopLimit, report.IntervalSeconds, report.AlertThreshold, report.Extrapolate,
+		report.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return interfaces.ErrReportExists
+		}
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if report.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get report ID: %w", err)
+	}
+	return nil
+}
+
+func listReports(db *sql.DB) ([]types.Report, error) {
+	rows, err := db.Query(`
+	SELECT id, name, query, top_field, top_limit, interval_seconds, alert_threshold, extrapolate, firing, created_at, last_run_at
+	FROM reports ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []types.Report{}
+	for rows.Next() {
+		var report types.Report
+		var lastRun sql.NullTime
+		if err := rows.Scan(&report.ID, &report.Name, &report.Query, &report.TopField, &report.TopLimit,
+			&report.IntervalSeconds, &report.AlertThreshold, &report.Extrapolate, &report.Firing, &report.CreatedAt, &lastRun); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		if lastRun.Valid {
+			report.LastRunAt = &lastRun.Time
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read reports: %w", err)
+	}
+	return reports, nil
+}
+
+// updateReport replaces the definition of a report, keeping its runs so the next one continues
+// after the last window. An alert that is switched off stops firing.
+func updateReport(db *sql.DB, report *types.Report) error {
+	result, err := db.Exec(`
+	UPDATE reports SET name = ?, query = ?, top_field = ?, top_limit = ?, interval_seconds = ?, alert_threshold = ?,
+	extrapolate = ?, firing = CASE WHEN ? = 0 THEN 0 ELSE firing END WHERE id = ?`,
+		report.Name, report.Query, report.TopField, report.TopLimit, report.IntervalSeconds, report.AlertThreshold,
+		report.Extrapolate, report.AlertThreshold, report.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return interfaces.ErrReportExists
+		}
+		return fmt.Errorf("failed to update report: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update report: %w", err)
+	} else if updated == 0 {
+		return interfaces.ErrReportNotFound
+	}
+
+	var lastRun sql.NullTime
+	if err := db.QueryRow("SELECT firing, created_at, last_run_at FROM reports WHERE id = ?", report.ID).
+		Scan(&report.Firing, &report.CreatedAt, &lastRun); err != nil {
+		return fmt.Errorf("failed to select report: %w", err)
+	}
+	report.LastRunAt = nil
+	if lastRun.Valid {
+		report.LastRunAt = &lastRun.Time
+	}
+	return nil
+}
+
+func deleteReport(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM reports WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete report: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete report: %w", err)
+	} else if deleted == 0 {
+		return interfaces.ErrReportNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM report_results WHERE report_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete report results: %w", err)
+	}
+	return tx.Commit()
+}
+
+// runReport counts the entries matching a report's compiled query, groups them by the top field
+// and stores the result; the query's time range is the run's window. For an alert report, a run
+// that changes the alert state also records an alert event.
+func runReport(db *sql.DB, promotions *fieldPromotions, report types.Report, query types.SearchQuery) (*types.ReportResult, error) {
+	if query.StartTime == nil || query.EndTime == nil {
+		return nil, fmt.Errorf("report runs need a time window")
+	}
+	result := &types.ReportResult{
+		ReportID:  report.ID,
+		StartTime: *query.StartTime,
+		EndTime:   *query.EndTime,
+		RunAt:     time.Now().UTC(),
+	}
+
+	source, args := reportSource(query, promotions)
+	if err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM("+sampleRateExpression+"), 0)"+source, args...).Scan(&result.Count, &result.Extrapolated); err != nil {
+		return nil, fmt.Errorf("failed to count report entries: %w", err)
+	}
+
+	if report.TopField != "" && result.Count > 0 {
+		expression, expressionArgs, err := reportTopExpression(report.TopField)
+		if err != nil {
+			return nil, err
+		}
+		limit := report.TopLimit
+		if limit <= 0 {
+			limit = types.DefaultReportTopLimit
+		}
+		topArgs := append(append(expressionArgs, args...), limit)
+		rows, err := db.Query(`SELECT CAST(value AS TEXT), COUNT(*) AS value_count FROM (SELECT `+expression+` AS value`+source+`)
+		WHERE value IS NOT NULL AND value != '' GROUP BY value ORDER BY value_count DESC, value LIMIT ?`, topArgs...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select report top values: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var value types.FacetValue
+			if err := rows.Scan(&value.Value, &value.Count); err != nil {
+				return nil, fmt.Errorf("failed to scan report top value: %w", err)
+			}
+			result.TopValues = append(result.TopValues, value)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read report top values: %w", err)
+		}
+	}
+
+	var topValues interface{}
+	if len(result.TopValues) > 0 {
+		encoded, err := json.Marshal(result.TopValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode report top values: %w", err)
+		}
+		topValues = string(encoded)
+	}
+
+	count := result.Count
+	if report.Extrapolate {
+		count = result.Extrapolated
+	}
+	firing := report.AlertThreshold > 0 && count >= report.AlertThreshold
+	var samples interface{}
+	if firing != report.Firing {
+		result.Alert = &types.AlertEvent{
+			ReportID:   report.ID,
+			ReportName: report.Name,
+			State:      types.AlertResolved,
+			Time:       result.EndTime,
+			Count:      count,
+			Threshold:  report.AlertThreshold,
+		}
+		if firing {
+			result.Alert.State = types.AlertFiring
+			entries, err := alertSamples(db, source, args)
+			if err != nil {
+				return nil, err
+			}
+			result.Alert.Samples = entries
+			encoded, err := json.Marshal(entries)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode alert samples: %w", err)
+			}
+			samples = string(encoded)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := tx.Exec("UPDATE reports SET last_run_at = ?, firing = ? WHERE id = ?", result.EndTime, firing, report.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record report run: %w", err)
+	}
+	if n, err := updated.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to record report run: %w", err)
+	} else if n == 0 {
+		return nil, interfaces.ErrReportNotFound
+	}
+	if _, err := tx.Exec(`
+	INSERT INTO report_results (report_id, start_time, end_time, count, extrapolated, top_values, run_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		report.ID, result.StartTime, result.EndTime, result.Count, result.Extrapolated, topValues, result.RunAt); err != nil {
+		return nil, fmt.Errorf("failed to store report result: %w", err)
+	}
+	if event := result.Alert; event != nil {
+		inserted, err := tx.Exec(`
+		INSERT INTO alert_events (report_id, report_name, state, time, count, threshold, samples)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			event.ReportID, event.ReportName, event.State, event.Time, event.Count, event.Threshold, samples)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store alert event: %w", err)
+		}
+		if event.ID, err = inserted.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get alert event ID: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit report result: %w", err)
+	}
+	return result, nil
+}
+
+// alertSamples selects the newest entries of a run window for a firing alert event
+func alertSamples(db *sql.DB, source string, args []interface{}) ([]*types.LogEntry, error) {
+	columns := types.SearchResultFields
+	rows, err := db.Query("SELECT "+selectList(columns, "logs")+source+" ORDER BY logs.timestamp DESC, logs.id DESC LIMIT ?",
+		append(args, types.MaxAlertSamples)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select alert samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []*types.LogEntry
+	for rows.Next() {
+		entry, err := scanSearchRow(rows, columns, false)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alert samples: %w", err)
+	}
+	return samples, nil
+}
+
+// alertHistory returns the alert events after since, oldest first, of one report or of all when
+// reportID is zero
+func alertHistory(db *sql.DB, reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	query := `
+	SELECT id, report_id, report_name, state, time, count, threshold, samples FROM alert_events
+	WHERE time >= ?`
+	args := []interface{}{since.UTC()}
+	if reportID > 0 {
+		query += " AND report_id = ?"
+		args = append(args, reportID)
+	}
+	query += " ORDER BY time, id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select alert history: %w", err)
+	}
+	defer rows.Close()
+
+	events := []types.AlertEvent{}
+	for rows.Next() {
+		var event types.AlertEvent
+		var samples sql.NullString
+		if err := rows.Scan(&event.ID, &event.ReportID, &event.ReportName, &event.State, &event.Time,
+			&event.Count, &event.Threshold, &samples); err != nil {
+			return nil, fmt.Errorf("failed to scan alert event: %w", err)
+		}
+		if samples.Valid {
+			if err := json.Unmarshal([]byte(samples.String), &event.Samples); err != nil {
+				return nil, fmt.Errorf("failed to decode alert samples: %w", err)
+			}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alert history: %w", err)
+	}
+	return events, nil
+}
+
+func reportResults(db *sql.DB, id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM reports WHERE id = ?)", id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up report: %w", err)
+	}
+	if !exists {
+		return nil, interfaces.ErrReportNotFound
+	}
+
+	query := `
+	SELECT start_time, end_time, count, COALESCE(extrapolated, count), top_values, run_at FROM report_results
+	WHERE report_id = ? AND end_time >= ? ORDER BY end_time`
+	args := []interface{}{id, since.UTC()}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select report results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []types.ReportResult{}
+	for rows.Next() {
+		result := types.ReportResult{ReportID: id}
+		var topValues sql.NullString
+		if err := rows.Scan(&result.StartTime, &result.EndTime, &result.Count, &result.Extrapolated, &topValues, &result.RunAt); err != nil {
+			return nil, fmt.Errorf("failed to scan report result: %w", err)
+		}
+		if topValues.Valid {
+			if err := json.Unmarshal([]byte(topValues.String), &result.TopValues); err != nil {
+				return nil, fmt.Errorf("failed to decode report top values: %w", err)
+			}
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read report results: %w", err)
+	}
+	return results, nil
+}
+
+// CreateReport saves a new scheduled report
+func (s *SQLiteStorage) CreateReport(report *types.Report) error {
+	return createReport(s.db, report)
+}
+
+// Reports lists the saved scheduled reports
+func (s *SQLiteStorage) Reports() ([]types.Report, error) {
+	return listReports(s.db)
+}
+
+// UpdateReport replaces the definition of a scheduled report, keeping its results
+func (s *SQLiteStorage) UpdateReport(report *types.Report) error {
+	return updateReport(s.db, report)
+}
+
+// DeleteReport removes a scheduled report and its results
+func (s *SQLiteStorage) DeleteReport(id int64) error {
+	return deleteReport(s.db, id)
+}
+
+// RunReport summarizes the entries matching a report's query over the query's time range and stores the result
+func (s *SQLiteStorage) RunReport(report types.Report, query types.SearchQuery) (*types.ReportResult, error) {
+	return runReport(s.db, s.promotions, report, query)
+}
+
+// ReportResults returns the stored results of a report's runs
+func (s *SQLiteStorage) ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	return reportResults(s.db, id, since, limit)
+}
+
+// AlertHistory returns the recorded alert state changes
+func (s *SQLiteStorage) AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	return alertHistory(s.db, reportID, since, limit)
+}
+
+// CreateReport saves a new scheduled report
+func (s *BatchedSQLiteStorage) CreateReport(report *types.Report) error {
+	return createReport(s.db, report)
+}
+
+// Reports lists the saved scheduled reports
+func (s *BatchedSQLiteStorage) Reports() ([]types.Report, error) {
+	return listReports(s.db)
+}
+
+// UpdateReport replaces the definition of a scheduled report, keeping its results
+func (s *BatchedSQLiteStorage) UpdateReport(report *types.Report) error {
+	return updateReport(s.db, report)
+}
+
+// DeleteReport removes a scheduled report and its results
+func (s *BatchedSQLiteStorage) DeleteReport(id int64) error {
+	return deleteReport(s.db, id)
+}
+
+// RunReport summarizes the entries matching a report's query over the query's time range and stores the result
+func (s *BatchedSQLiteStorage) RunReport(report types.Report, query types.SearchQuery) (*types.ReportResult, error) {
+	return runReport(s.db, s.promotions, report, query)
+}
+
+// ReportResults returns the stored results of a report's runs
+func (s *BatchedSQLiteStorage) ReportResults(id int64, since time.Time, limit int) ([]types.ReportResult, error) {
+	return reportResults(s.db, id, since, limit)
+}
+
+// AlertHistory returns the recorded alert state changes
+func (s *BatchedSQLiteStorage) AlertHistory(reportID int64, since time.Time, limit int) ([]types.AlertEvent, error) {
+	return alertHistory(s.db, reportID, since, limit)
+}
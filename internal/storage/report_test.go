@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Reports(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, app := range []string{"api", "api", "worker", "api", "web"} {
+		severity := 3
+		if app == "web" {
+			severity = 6
+		}
+		entry := &types.LogEntry{
+			Version: 1, Priority: 8 + severity, Facility: 1, Severity: severity, Hostname: "web01", AppName: app,
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Message: "request failed",
+			StructuredData: map[string]interface{}{"request": map[string]interface{}{"path": "/" + app}},
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	report := &types.Report{Name: "errors", Query: "severity<=err failed", TopField: "app_name", TopLimit: 1, IntervalSeconds: 3600}
+	if err := storage.CreateReport(report); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	if err := storage.CreateReport(&types.Report{Name: "errors", IntervalSeconds: 60}); !errors.Is(err, interfaces.ErrReportExists) {
+		t.Errorf("Expected duplicate name to be rejected, got %v", err)
+	}
+
+	start, end := base, base.Add(time.Hour)
+	result, err := storage.RunReport(*report, types.SearchQuery{
+		Text: "failed", MinSeverity: intPtr(3), StartTime: &start, EndTime: &end,
+	})
+	if err != nil {
+		t.Fatalf("RunReport failed: %v", err)
+	}
+	if result.Count != 4 || len(result.TopValues) != 1 || result.TopValues[0] != (types.FacetValue{Value: "api", Count: 3}) {
+		t.Errorf("Unexpected report result: %+v", result)
+	}
+
+	// Structured data parameters can be the top field too
+	pathReport := types.Report{ID: report.ID, TopField: "request.path"}
+	result, err = storage.RunReport(pathReport, types.SearchQuery{StartTime: &start, EndTime: &end})
+	if err != nil || result.Count != 5 || len(result.TopValues) != 3 || result.TopValues[0].Value != "/api" {
+		t.Errorf("Unexpected structured data report result: %+v, %v", result, err)
+	}
+
+	// Results outlive the entries they summarize
+	if err := storage.Cleanup(1); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	results, err := storage.ReportResults(report.ID, time.Time{}, 0)
+	if err != nil || len(results) != 2 || results[0].Count != 4 || results[0].TopValues[0].Value != "api" {
+		t.Fatalf("Expected both results to be kept, got %+v, %v", results, err)
+	}
+	if results, err := storage.ReportResults(report.ID, end.Add(time.Minute), 0); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results after since, got %+v, %v", results, err)
+	}
+
+	reports, err := storage.Reports()
+	if err != nil || len(reports) != 1 || reports[0].LastRunAt == nil || !reports[0].LastRunAt.Equal(end) {
+		t.Errorf("Expected the run to be recorded, got %+v, %v", reports, err)
+	}
+
+	// An update keeps the results and the last run
+	updated := &types.Report{ID: report.ID, Name: "server errors", Query: "severity<=err", IntervalSeconds: 600}
+	if err := storage.UpdateReport(updated); err != nil {
+		t.Fatalf("UpdateReport failed: %v", err)
+	}
+	if updated.LastRunAt == nil || !updated.LastRunAt.Equal(end) || updated.CreatedAt.IsZero() {
+		t.Errorf("Expected the state to be filled in, got %+v", updated)
+	}
+	if reports, err := storage.Reports(); err != nil || len(reports) != 1 || reports[0].Name != "server errors" || reports[0].TopField != "" {
+		t.Errorf("Expected the new definition, got %+v, %v", reports, err)
+	}
+	if results, err := storage.ReportResults(report.ID, time.Time{}, 0); err != nil || len(results) != 2 {
+		t.Errorf("Expected the results to be kept, got %+v, %v", results, err)
+	}
+	if err := storage.CreateReport(&types.Report{Name: "other", IntervalSeconds: 60}); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	if err := storage.UpdateReport(&types.Report{ID: report.ID, Name: "other", IntervalSeconds: 60}); !errors.Is(err, interfaces.ErrReportExists) {
+		t.Errorf("Expected a taken name to be rejected, got %v", err)
+	}
+	if err := storage.UpdateReport(&types.Report{ID: report.ID + 10, Name: "missing", IntervalSeconds: 60}); !errors.Is(err, interfaces.ErrReportNotFound) {
+		t.Errorf("Expected ErrReportNotFound, got %v", err)
+	}
+
+	if err := storage.DeleteReport(report.ID); err != nil {
+		t.Fatalf("DeleteReport failed: %v", err)
+	}
+	if _, err := storage.ReportResults(report.ID, time.Time{}, 0); !errors.Is(err, interfaces.ErrReportNotFound) {
+		t.Errorf("Expected deleted report to be missing, got %v", err)
+	}
+	if err := storage.DeleteReport(report.ID); !errors.Is(err, interfaces.ErrReportNotFound) {
+		t.Errorf("Expected deleting twice to fail, got %v", err)
+	}
+}
+
+func TestSQLiteStorage_AlertHistory(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		entry := &types.LogEntry{
+			Version: 1, Priority: 11, Facility: 1, Severity: 3, Hostname: "web01", AppName: "api",
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Message: "upstream timeout",
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	report := &types.Report{Name: "timeouts", Query: "timeout", AlertThreshold: 3, IntervalSeconds: 600}
+	if err := storage.CreateReport(report); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	run := func(start, end time.Time) *types.ReportResult {
+		t.Helper()
+		reports, err := storage.Reports()
+		if err != nil || len(reports) != 1 {
+			t.Fatalf("Reports failed: %+v, %v", reports, err)
+		}
+		result, err := storage.RunReport(reports[0], types.SearchQuery{Text: "timeout", StartTime: &start, EndTime: &end})
+		if err != nil {
+			t.Fatalf("RunReport failed: %v", err)
+		}
+		return result
+	}
+
+	// Eight matches fire the alert with the newest entries as samples, and it stays firing
+	if result := run(base, base.Add(10*time.Minute)); result.Alert == nil || result.Alert.State != types.AlertFiring {
+		t.Fatalf("Expected the alert to fire, got %+v", result.Alert)
+	}
+	if result := run(base.Add(4*time.Minute), base.Add(20*time.Minute)); result.Alert != nil {
+		t.Errorf("Expected no state change while still firing, got %+v", result.Alert)
+	}
+	if result := run(base.Add(20*time.Minute), base.Add(30*time.Minute)); result.Alert == nil || result.Alert.State != types.AlertResolved {
+		t.Fatalf("Expected the alert to resolve, got %+v", result.Alert)
+	}
+
+	// The history is kept after the report and the matching entries are gone
+	if err := storage.DeleteReport(report.ID); err != nil {
+		t.Fatalf("DeleteReport failed: %v", err)
+	}
+	if err := storage.Cleanup(1); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	events, err := storage.AlertHistory(report.ID, time.Time{}, 0)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("Expected two alert events, got %+v, %v", events, err)
+	}
+	fired, resolved := events[0], events[1]
+	if fired.State != types.AlertFiring || fired.ReportName != "timeouts" || fired.Count != 8 || fired.Threshold != 3 ||
+		!fired.Time.Equal(base.Add(10*time.Minute)) {
+		t.Errorf("Unexpected firing event: %+v", fired)
+	}
+	if len(fired.Samples) != types.MaxAlertSamples || !fired.Samples[0].Timestamp.Equal(base.Add(7*time.Minute)) ||
+		fired.Samples[0].Message != "upstream timeout" {
+		t.Errorf("Expected the newest entries as samples, got %+v", fired.Samples)
+	}
+	if resolved.State != types.AlertResolved || resolved.Count != 0 || len(resolved.Samples) != 0 {
+		t.Errorf("Unexpected resolution event: %+v", resolved)
+	}
+
+	if events, err := storage.AlertHistory(0, base.Add(15*time.Minute), 0); err != nil || len(events) != 1 || events[0].State != types.AlertResolved {
+		t.Errorf("Expected only the resolution after since, got %+v, %v", events, err)
+	}
+	if events, err := storage.AlertHistory(report.ID+1, time.Time{}, 0); err != nil || len(events) != 0 {
+		t.Errorf("Expected no events for another report, got %+v, %v", events, err)
+	}
+}
+
+func TestSQLiteStorage_UpdateAlert(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		entry := &types.LogEntry{
+			Version: 1, Priority: 11, Facility: 1, Severity: 3, Hostname: "web01", AppName: "api",
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Message: "upstream timeout",
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	report := &types.Report{Name: "timeouts", Query: "timeout", AlertThreshold: 3, IntervalSeconds: 600}
+	if err := storage.CreateReport(report); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	end := base.Add(10 * time.Minute)
+	result, err := storage.RunReport(*report, types.SearchQuery{Text: "timeout", StartTime: &base, EndTime: &end})
+	if err != nil || result.Alert == nil || result.Alert.State != types.AlertFiring {
+		t.Fatalf("Expected the alert to fire, got %+v, %v", result, err)
+	}
+
+	// Changing the threshold keeps the alert firing, switching it off stops it
+	changed := *report
+	changed.AlertThreshold = 2
+	if err := storage.UpdateReport(&changed); err != nil || !changed.Firing || changed.LastRunAt == nil {
+		t.Errorf("Expected the alert to keep firing, got %+v, %v", changed, err)
+	}
+	off := changed
+	off.AlertThreshold = 0
+	if err := storage.UpdateReport(&off); err != nil || off.Firing {
+		t.Errorf("Expected the alert to stop firing, got %+v, %v", off, err)
+	}
+	if reports, err := storage.Reports(); err != nil || len(reports) != 1 || reports[0].Firing || reports[0].AlertThreshold != 0 {
+		t.Errorf("Expected the stored report switched off, got %+v, %v", reports, err)
+	}
+}
+
+func TestSQLiteStorage_ReportExtrapolation(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		entry := &types.LogEntry{
+			Version: 1, Priority: 11, Facility: 1, Severity: 3, Hostname: "web01", AppName: "api",
+			Timestamp: base.Add(time.Duration(i) * time.Minute), Message: "upstream timeout",
+		}
+		entry.SetMetadata(types.SampleRateParam, "50")
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	end := base.Add(10 * time.Minute)
+	for _, tc := range []struct {
+		name        string
+		extrapolate bool
+		firing      bool
+	}{
+		{"entries", false, false},
+		{"messages", true, true},
+	} {
+		report := &types.Report{Name: tc.name, AlertThreshold: 100, Extrapolate: tc.extrapolate, IntervalSeconds: 600}
+		if err := storage.CreateReport(report); err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		result, err := storage.RunReport(*report, types.SearchQuery{StartTime: &base, EndTime: &end})
+		if err != nil {
+			t.Fatalf("RunReport failed: %v", err)
+		}
+		if result.Count != 2 || result.Extrapolated != 100 {
+			t.Errorf("Expected 2 entries standing for 100 messages, got %d and %d", result.Count, result.Extrapolated)
+		}
+		if firing := result.Alert != nil && result.Alert.State == types.AlertFiring; firing != tc.firing {
+			t.Errorf("Report counting %s: expected firing %v, got %+v", tc.name, tc.firing, result.Alert)
+		}
+		if tc.firing && result.Alert.Count != 100 {
+			t.Errorf("Expected the alert to record the extrapolated count, got %d", result.Alert.Count)
+		}
+
+		results, err := storage.ReportResults(report.ID, time.Time{}, 0)
+		if err != nil || len(results) != 1 || results[0].Extrapolated != 100 {
+			t.Errorf("Expected the extrapolated count to be stored, got %+v, %v", results, err)
+		}
+	}
+
+	reports, err := storage.Reports()
+	if err != nil || len(reports) != 2 || reports[0].Extrapolate || !reports[1].Extrapolate {
+		t.Errorf("Expected the extrapolation setting to be stored, got %+v, %v", reports, err)
+	}
+}
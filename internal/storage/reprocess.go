@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// storedEntry is a row selected for reprocessing
+type storedEntry struct {
+	entry          *types.LogEntry
+	structuredData string
+}
+
+// reprocessBatch re-parses the raw messages of up to limit entries with IDs above afterID and rewrites
+// the parsed fields of those that changed, keeping the rollups in step. Receiver metadata is carried
+// over, and the stored timestamp is kept when the parser fell back to the current time because the raw
+// message has none. Hash-chained entries are never rewritten, as that would break their chain.
+func reprocessBatch(db *sql.DB, query types.ReprocessQuery, afterID int64, limit int, parse func(string) (*types.LogEntry, error)) (*types.ReprocessBatch, error) {
+	started := time.Now()
+
+	sqlQuery := `
+	SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at, raw_message
+	FROM logs WHERE id > ? AND raw_message IS NOT NULL AND chain_hash IS NULL`
+	args := []interface{}{afterID}
+	if query.StartTime != nil {
+		sqlQuery += " AND timestamp >= ?"
+		args = append(args, storedTimestamp(*query.StartTime))
+	}
+	if query.EndTime != nil {
+		sqlQuery += " AND timestamp <= ?"
+		args = append(args, storedTimestamp(*query.EndTime))
+	}
+	sqlQuery += " ORDER BY id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select entries to reprocess: %w", err)
+	}
+	var stored []storedEntry
+	for rows.Next() {
+		entry := &types.LogEntry{}
+		var structuredDataJSON sql.NullString
+		var raw interface{}
+		if err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
+			&structuredDataJSON, &entry.Message, &entry.CreatedAt, &raw); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan entry to reprocess: %w", err)
+		}
+		if entry.Raw, err = decodeRawMessage(raw); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to decode raw message of entry %d: %w", entry.ID, err)
+		}
+		if structuredDataJSON.String != "" {
+			json.Unmarshal([]byte(structuredDataJSON.String), &entry.StructuredData)
+		}
+		stored = append(stored, storedEntry{entry: entry, structuredData: structuredDataJSON.String})
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries to reprocess: %w", err)
+	}
+
+	batch := &types.ReprocessBatch{LastID: afterID, Done: len(stored) < limit}
+	if len(stored) == 0 {
+		return batch, nil
+	}
+	batch.LastID = stored[len(stored)-1].entry.ID
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts := newRollupCounts()
+	for _, row := range stored {
+		batch.Processed++
+		old := row.entry
+
+		parsed, err := parse(old.Raw)
+		if err != nil || parsed == nil {
+			batch.Failed++
+			continue
+		}
+
+		if !parsed.Timestamp.Before(started) {
+			parsed.Timestamp = old.Timestamp
+		}
+		if metadata, ok := old.StructuredData[types.MetadataSDID].(map[string]interface{}); ok {
+			for name, value := range metadata {
+				if s, ok := value.(string); ok {
+					parsed.SetMetadata(name, s)
+				}
+			}
+		}
+
+		var structuredDataJSON string
+		if parsed.StructuredData != nil {
+			jsonBytes, err := json.Marshal(parsed.StructuredData)
+			if err != nil {
+				batch.Failed++
+				continue
+			}
+			structuredDataJSON = string(jsonBytes)
+		}
+
+		if parsed.Priority == old.Priority && parsed.Version == old.Version && parsed.Timestamp.Equal(old.Timestamp) &&
+			parsed.Hostname == old.Hostname && parsed.AppName == old.AppName && parsed.ProcID == old.ProcID &&
+			parsed.MsgID == old.MsgID && parsed.Message == old.Message && structuredDataJSON == row.structuredData {
+			continue
+		}
+
+		if _, err := tx.Exec(`
+		UPDATE logs SET priority = ?, facility = ?, severity = ?, version = ?, timestamp = ?, hostname = ?,
+			app_name = ?, proc_id = ?, msg_id = ?, structured_data = ?, message = ?
+		WHERE id = ?`,
+			parsed.Priority, parsed.Facility, parsed.Severity, parsed.Version, storedTimestamp(parsed.Timestamp),
+			parsed.Hostname, parsed.AppName, parsed.ProcID, parsed.MsgID, structuredDataJSON, parsed.Message,
+			old.ID); err != nil {
+			return nil, fmt.Errorf("failed to update entry %d: %w", old.ID, err)
+		}
+
+		counts.remove(old)
+		counts.add(parsed)
+		batch.Updated++
+	}
+
+	if err := counts.apply(tx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reprocessed entries: %w", err)
+	}
+	return batch, nil
+}
+
+// ReprocessBatch re-parses a batch of stored raw messages and updates the entries' fields
+func (s *SQLiteStorage) ReprocessBatch(query types.ReprocessQuery, afterID int64, limit int, parse func(string) (*types.LogEntry, error)) (*types.ReprocessBatch, error) {
+	return reprocessBatch(s.db, query, afterID, limit, parse)
+}
+
+// ReprocessBatch re-parses a batch of stored raw messages and updates the entries' fields
+func (s *BatchedSQLiteStorage) ReprocessBatch(query types.ReprocessQuery, afterID int64, limit int, parse func(string) (*types.LogEntry, error)) (*types.ReprocessBatch, error) {
+	return reprocessBatch(s.db, query, afterID, limit, parse)
+}
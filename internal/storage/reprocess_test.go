@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// levelParser parses "LEVEL message" lines, with or without a leading timestamp
+func levelParser(severities map[string]int) func(string) (*types.LogEntry, error) {
+	return func(raw string) (*types.LogEntry, error) {
+		entry := &types.LogEntry{Version: 1, Timestamp: time.Now(), AppName: "app", Hostname: "host"}
+		if ts, rest, ok := strings.Cut(raw, " "); ok {
+			if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+				entry.Timestamp = parsed
+				raw = rest
+			}
+		}
+		level, message, _ := strings.Cut(raw, " ")
+		severity, ok := severities[level]
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", level)
+		}
+		entry.SetPriority(16*8 + severity)
+		entry.Message = message
+		return entry, nil
+	}
+}
+
+func TestSQLiteStorage_ReprocessBatch(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	// The original format did not know ERROR and stored everything as info
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	oldParse := levelParser(map[string]int{"INFO": 6, "ERROR": 6, "WARN": 6})
+	raws := []string{
+		base.Format(time.RFC3339) + " INFO started",
+		base.Add(time.Minute).Format(time.RFC3339) + " ERROR disk full",
+		"WARN no timestamp",
+		"BOGUS unparseable",
+	}
+	for i, raw := range raws {
+		entry, err := oldParse(raw)
+		if err != nil {
+			entry = &types.LogEntry{Version: 1, Priority: 134, Facility: 16, Severity: 6, AppName: "app", Hostname: "host", Message: raw}
+		}
+		if i >= 2 {
+			entry.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		}
+		entry.Raw = raw
+		entry.SetMetadata(types.SourceIPParam, "10.0.0.1")
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+	// Entries without a raw message are not reprocessed
+	if err := storage.Store(&types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: base, Message: "no raw"}); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	newParse := levelParser(map[string]int{"INFO": 6, "ERROR": 3, "WARN": 4})
+	first, err := storage.ReprocessBatch(types.ReprocessQuery{}, 0, 2, newParse)
+	if err != nil {
+		t.Fatalf("ReprocessBatch failed: %v", err)
+	}
+	if first.Processed != 2 || first.Updated != 1 || first.Failed != 0 || first.Done {
+		t.Errorf("Unexpected first batch: %+v", first)
+	}
+	second, err := storage.ReprocessBatch(types.ReprocessQuery{}, first.LastID, 2, newParse)
+	if err != nil {
+		t.Fatalf("ReprocessBatch failed: %v", err)
+	}
+	if second.Processed != 2 || second.Updated != 1 || second.Failed != 1 {
+		t.Errorf("Unexpected second batch: %+v", second)
+	}
+	last, err := storage.ReprocessBatch(types.ReprocessQuery{}, second.LastID, 2, newParse)
+	if err != nil || !last.Done || last.Processed != 0 {
+		t.Errorf("Expected final empty batch, got %+v, %v", last, err)
+	}
+
+	entries, err := storage.Search(types.SearchQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	byMessage := make(map[string]*types.LogEntry)
+	for _, entry := range entries {
+		byMessage[entry.Message] = entry
+	}
+
+	if entry := byMessage["disk full"]; entry == nil || entry.Severity != 3 {
+		t.Errorf("Expected ERROR entry to be re-parsed as severity 3, got %+v", entry)
+	}
+	warn := byMessage["no timestamp"]
+	if warn == nil || warn.Severity != 4 {
+		t.Fatalf("Expected WARN entry to be re-parsed as severity 4, got %+v", warn)
+	}
+	if !warn.Timestamp.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("Expected stored timestamp to be kept, got %v", warn.Timestamp)
+	}
+	metadata, _ := warn.StructuredData[types.MetadataSDID].(map[string]interface{})
+	if metadata[types.SourceIPParam] != "10.0.0.1" {
+		t.Errorf("Expected receiver metadata to be kept, got %v", warn.StructuredData)
+	}
+
+	// Rollups follow the new severities
+	histogram, err := storage.Histogram(types.HistogramQuery{
+		StartTime: base, EndTime: base.Add(time.Hour), Interval: time.Hour, GroupBy: types.GroupBySeverity,
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	counts := make(map[string]int64)
+	for _, bucket := range histogram.Buckets {
+		counts[bucket.Group] += bucket.Count
+	}
+	if counts["6"] != 3 || counts["3"] != 1 || counts["4"] != 1 {
+		t.Errorf("Unexpected severity counts after reprocessing: %v", counts)
+	}
+	var empty int
+	if err := storage.db.QueryRow("SELECT COUNT(*) FROM log_rollups WHERE count <= 0").Scan(&empty); err != nil || empty != 0 {
+		t.Errorf("Expected emptied rollup rows to be deleted, found %d (%v)", empty, err)
+	}
+
+	// A time range restricts the entries examined
+	start := base.Add(90 * time.Second)
+	ranged, err := storage.ReprocessBatch(types.ReprocessQuery{StartTime: &start}, 0, 10, newParse)
+	if err != nil || ranged.Processed != 2 || ranged.Updated != 0 {
+		t.Errorf("Unexpected ranged batch: %+v, %v", ranged, err)
+	}
+}
+
+func TestAddColumnIfMissing(t *testing.T) {
+	path := t.TempDir() + "/old.db"
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, priority INTEGER NOT NULL, facility INTEGER NOT NULL,
+		severity INTEGER NOT NULL, version INTEGER NOT NULL DEFAULT 1, timestamp DATETIME NOT NULL,
+		hostname TEXT, app_name TEXT, proc_id TEXT, msg_id TEXT, structured_data TEXT, message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+	db.Close()
+
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+	defer storage.Close()
+
+	if err := storage.Store(&types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: "migrated", Raw: "raw"}); err != nil {
+		t.Fatalf("Failed to store entry after migration: %v", err)
+	}
+	var raw string
+	if err := storage.db.QueryRow("SELECT raw_message FROM logs").Scan(&raw); err != nil || raw != "raw" {
+		t.Errorf("Expected raw message to be stored, got %q (%v)", raw, err)
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// searchColumns returns the columns selected for the requested result fields, all of them when none
+// are requested. The id is always selected so entries stay addressable. Result field names are the
+// logs column names.
+func searchColumns(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return types.SearchResultFields, nil
+	}
+
+	known := make(map[string]bool, len(types.SearchResultFields))
+	for _, field := range types.SearchResultFields {
+		known[field] = true
+	}
+	columns := []string{"id"}
+	selected := map[string]bool{"id": true}
+	for _, field := range fields {
+		if !known[field] {
+			return nil, fmt.Errorf("unknown result field %q", field)
+		}
+		if !selected[field] {
+			selected[field] = true
+			columns = append(columns, field)
+		}
+	}
+	return columns, nil
+}
+
+// FTS5 highlight() marks the matches of a text search with these control characters, which do
+// not occur in log messages in practice
+const (
+	highlightOpen  = '\x02'
+	highlightClose = '\x03'
+)
+
+// highlightExpression selects the message of a text search result with its matches marked
+const highlightExpression = "highlight(logs_fts, 0, char(2), char(3))"
+
+// highlightRanges converts the marked message returned by highlightExpression into match ranges,
+// in characters of message. It returns nil if the markers cannot be trusted.
+func highlightRanges(marked, message string) []types.TextRange {
+	if strings.ContainsRune(message, highlightOpen) || strings.ContainsRune(message, highlightClose) {
+		return nil
+	}
+
+	var ranges []types.TextRange
+	var stripped strings.Builder
+	position, start := 0, -1
+	for _, r := range marked {
+		switch r {
+		case highlightOpen:
+			start = position
+		case highlightClose:
+			if start >= 0 && position > start {
+				ranges = append(ranges, types.TextRange{Start: start, End: position})
+			}
+			start = -1
+		default:
+			stripped.WriteRune(r)
+			position++
+		}
+	}
+	if stripped.String() != message {
+		return nil
+	}
+	return ranges
+}
+
+// selectList formats columns as a SELECT list, qualified with a table alias if set
+func selectList(columns []string, alias string) string {
+	if alias == "" {
+		return strings.Join(columns, ", ")
+	}
+	qualified := make([]string, len(columns))
+	for i, column := range columns {
+		qualified[i] = alias + "." + column
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// scanSearchRow scans a row selected with columns into a new entry, leaving other fields empty.
+// With highlight the row ends with highlightExpression.
+func scanSearchRow(rows *sql.Rows, columns []string, highlight bool) (*types.LogEntry, error) {
+	entry := &types.LogEntry{}
+	var uid, structuredDataJSON, marked sql.NullString
+
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dest[i] = &entry.ID
+		case "uid":
+			dest[i] = &uid
+		case "priority":
+			dest[i] = &entry.Priority
+		case "facility":
+			dest[i] = &entry.Facility
+		case "severity":
+			dest[i] = &entry.Severity
+		case "version":
+			dest[i] = &entry.Version
+		case "timestamp":
+			dest[i] = &entry.Timestamp
+		case "hostname":
+			dest[i] = &entry.Hostname
+		case "app_name":
+			dest[i] = &entry.AppName
+		case "proc_id":
+			dest[i] = &entry.ProcID
+		case "msg_id":
+			dest[i] = &entry.MsgID
+		case "structured_data":
+			dest[i] = &structuredDataJSON
+		case "message":
+			dest[i] = &entry.Message
+		case "created_at":
+			dest[i] = &entry.CreatedAt
+		default:
+			return nil, fmt.Errorf("unknown result field %q", column)
+		}
+	}
+
+	if highlight {
+		dest = append(dest, &marked)
+	}
+
+	if err := rows.Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to scan log entry: %w", err)
+	}
+	entry.UID = uid.String
+
+	// Parse structured data JSON
+	if structuredDataJSON.Valid && structuredDataJSON.String != "" {
+		var structuredData map[string]interface{}
+		if err := json.Unmarshal([]byte(structuredDataJSON.String), &structuredData); err == nil {
+			entry.StructuredData = structuredData
+		}
+	}
+	if highlight {
+		entry.Highlights = highlightRanges(marked.String, entry.Message)
+	}
+	return entry, nil
+}
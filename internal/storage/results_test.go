@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_SearchResultFields(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	entry := &types.LogEntry{
+		Version: 1, Priority: 131, Facility: 16, Severity: 3, Timestamp: time.Now().UTC(), Hostname: "web01",
+		AppName: "api", Message: "disk full", StructuredData: map[string]interface{}{"disk": map[string]interface{}{"mount": "/"}},
+	}
+	if err := storage.Store(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	for _, query := range []types.SearchQuery{
+		{Fields: []string{"severity", "message", "severity"}, Limit: 10},
+		{Text: "disk", Fields: []string{"severity", "message"}, Limit: 10},
+	} {
+		results, err := storage.Search(query)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("Expected 1 result, got %d", len(results))
+		}
+		got := results[0]
+		if got.ID != entry.ID || got.Severity != 3 || got.Message != "disk full" {
+			t.Errorf("Expected selected fields to be filled, got %+v", got)
+		}
+		if got.Hostname != "" || got.StructuredData != nil || !got.Timestamp.IsZero() {
+			t.Errorf("Expected unselected fields to be empty, got %+v", got)
+		}
+	}
+
+	if _, err := storage.Search(types.SearchQuery{Fields: []string{"raw_message"}}); err == nil {
+		t.Error("Expected error for unknown result field")
+	}
+}
+
+func TestSQLiteStorage_SearchHighlights(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	for _, message := range []string{"Connection to db-01 failed: connection refused", "ünïcode connection lost", "nothing here"} {
+		if err := storage.Store(&types.LogEntry{Version: 1, Priority: 134, Severity: 6, Timestamp: time.Now(), Message: message}); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	results, err := storage.Search(types.SearchQuery{Text: "connection", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, entry := range results {
+		runes := []rune(entry.Message)
+		if len(entry.Highlights) == 0 {
+			t.Errorf("Expected highlights for %q", entry.Message)
+		}
+		for _, highlight := range entry.Highlights {
+			if match := string(runes[highlight.Start:highlight.End]); !strings.EqualFold(match, "connection") {
+				t.Errorf("Unexpected highlight %q in %q", match, entry.Message)
+			}
+		}
+	}
+
+	// Plain searches and searches without the message carry no highlights
+	results, err = storage.Search(types.SearchQuery{Text: "connection", Fields: []string{"severity"}, Limit: 10})
+	if err != nil || len(results) != 2 || results[0].Highlights != nil {
+		t.Errorf("Expected no highlights without the message field, got %+v, %v", results, err)
+	}
+	results, err = storage.Search(types.SearchQuery{Limit: 10})
+	if err != nil || len(results) != 3 || results[0].Highlights != nil {
+		t.Errorf("Expected no highlights without text search, got %+v, %v", results, err)
+	}
+}
+
+func TestHighlightRanges(t *testing.T) {
+	ranges := highlightRanges("a \x02bc\x03 d\x02é\x03", "a bc dé")
+	if len(ranges) != 2 || ranges[0] != (types.TextRange{Start: 2, End: 4}) || ranges[1] != (types.TextRange{Start: 6, End: 7}) {
+		t.Errorf("Unexpected ranges: %v", ranges)
+	}
+	if ranges := highlightRanges("\x02a\x03", "b"); ranges != nil {
+		t.Errorf("Expected mismatching markup to be ignored, got %v", ranges)
+	}
+	if ranges := highlightRanges("\x02a\x02\x03", "a\x02"); ranges != nil {
+		t.Errorf("Expected messages containing markers to be ignored, got %v", ranges)
+	}
+}
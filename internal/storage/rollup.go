@@ -0,0 +1,565 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const (
+	// rollupResolution is the granularity of the pre-aggregated counts
+	rollupResolution = time.Minute
+	// facetResolution is the granularity of the per-value counts used for facets
+	facetResolution = time.Hour
+	// zoneAlignment divides the UTC offset of every time zone in use
+	zoneAlignment = 15 * time.Minute
+
+	day = 24 * time.Hour
+)
+
+const upsertRollup = `
+INSERT INTO log_rollups (bucket, severity, app_name, hostname, count, sampled)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (bucket, severity, app_name, hostname) DO UPDATE SET
+	count = count + excluded.count, sampled = sampled + excluded.sampled`
+
+const upsertFacet = `
+INSERT INTO log_facets (field, bucket, value, count)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (field, bucket, value) DO UPDATE SET count = count + excluded.count`
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// rollupKey identifies a single rollup row
+type rollupKey struct {
+	bucket   int64
+	severity int
+	appName  string
+	hostname string
+}
+
+// rollupCount is the number of entries of a rollup row and the messages they stand for beyond
+// themselves
+type rollupCount struct {
+	entries int64
+	sampled int64
+}
+
+// facetKey identifies a single facet row
+type facetKey struct {
+	field  string
+	bucket int64
+	value  string
+}
+
+// rollupCounts accumulates entry counts per rollup, facet and field catalog row
+type rollupCounts struct {
+	series map[rollupKey]rollupCount
+	facets map[facetKey]int64
+	fields map[fieldKey]fieldStat
+	volume map[volumeKey]volumeCount
+
+	// removed is set once counts were taken back, so apply drops rows that reached zero
+	removed bool
+}
+
+func newRollupCounts() *rollupCounts {
+	return &rollupCounts{
+		series: make(map[rollupKey]rollupCount),
+		facets: make(map[facetKey]int64),
+		fields: make(map[fieldKey]fieldStat),
+		volume: make(map[volumeKey]volumeCount),
+	}
+}
+
+// add counts an entry towards its rollup, facet and field catalog rows
+func (c *rollupCounts) add(entry *types.LogEntry) {
+	c.count(entry, 1)
+	c.addFields(entry)
+}
+
+// remove takes an entry back out of its rollup and facet rows. The field catalog only tracks
+// recently seen values, so it is left alone.
+func (c *rollupCounts) remove(entry *types.LogEntry) {
+	c.count(entry, -1)
+	c.removed = true
+}
+
+// purge takes a deleted entry out of its rollup, facet and field catalog rows, so values that only
+// deleted entries carried are no longer offered for autocompletion
+func (c *rollupCounts) purge(entry *types.LogEntry) {
+	c.remove(entry)
+	c.removeFields(entry)
+}
+
+// count adds delta to the rollup and facet rows of an entry
+func (c *rollupCounts) count(entry *types.LogEntry, delta int64) {
+	key := rollupKey{
+		bucket:   entry.Timestamp.Truncate(rollupResolution).Unix(),
+		severity: entry.Severity,
+		appName:  entry.AppName,
+		hostname: entry.Hostname,
+	}
+	series := c.series[key]
+	series.entries += delta
+	series.sampled += delta * (entry.SampleRate() - 1)
+	c.series[key] = series
+
+	hour := entry.Timestamp.Truncate(facetResolution).Unix()
+	for _, facet := range [...]facetKey{
+		{field: types.FacetHostname, value: entry.Hostname},
+		{field: types.FacetAppName, value: entry.AppName},
+		{field: types.FacetMsgID, value: entry.MsgID},
+	} {
+		// Empty and nil ("-") values carry no information for a facet
+		if facet.value != "" && facet.value != "-" {
+			facet.bucket = hour
+			c.facets[facet] += delta
+		}
+	}
+}
+
+// apply adds the accumulated counts to the rollup tables
+func (c *rollupCounts) apply(db execer) error {
+	for key, count := range c.series {
+		if _, err := db.Exec(upsertRollup, key.bucket, key.severity, key.appName, key.hostname, count.entries, count.sampled); err != nil {
+			return fmt.Errorf("failed to update rollups: %w", err)
+		}
+	}
+	for key, count := range c.facets {
+		if _, err := db.Exec(upsertFacet, key.field, key.bucket, key.value, count); err != nil {
+			return fmt.Errorf("failed to update facets: %w", err)
+		}
+	}
+	for key, stat := range c.fields {
+		if _, err := db.Exec(upsertField, key.name, key.value, stat.count, stat.lastSeen); err != nil {
+			return fmt.Errorf("failed to update field catalog: %w", err)
+		}
+	}
+	for key, count := range c.volume {
+		if _, err := db.Exec(upsertIngestedVolume, key.day, key.appName, key.hostname, key.tenant, count.entries, count.bytes); err != nil {
+			return fmt.Errorf("failed to update volume: %w", err)
+		}
+	}
+	if c.removed {
+		return c.deleteEmpty(db)
+	}
+	return nil
+}
+
+// insertWithRollups inserts a single entry with insert and adds it to the rollups in one
+// transaction, so a failed rollup update stores nothing and the rollups always match the stored
+// rows. It returns the ID of the inserted row.
+func insertWithRollups(db *sql.DB, entry *types.LogEntry, key interface{}, window time.Duration, insert func(tx *sql.Tx) (sql.Result, error)) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := insertIdempotent(tx, key, window, func() (sql.Result, error) {
+		return insert(tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted ID: %w", err)
+	}
+
+	counts := newRollupCounts()
+	counts.add(entry)
+	counts.ingest(entry)
+	if err := counts.apply(tx); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// deleteEmpty removes the rollup, facet and field catalog rows whose count was lowered to zero
+func (c *rollupCounts) deleteEmpty(db execer) error {
+	for key, count := range c.series {
+		if count.entries >= 0 {
+			continue
+		}
+		if _, err := db.Exec("DELETE FROM log_rollups WHERE bucket = ? AND severity = ? AND app_name = ? AND hostname = ? AND count <= 0",
+			key.bucket, key.severity, key.appName, key.hostname); err != nil {
+			return fmt.Errorf("failed to delete empty rollups: %w", err)
+		}
+	}
+	for key, count := range c.facets {
+		if count >= 0 {
+			continue
+		}
+		if _, err := db.Exec("DELETE FROM log_facets WHERE field = ? AND bucket = ? AND value = ? AND count <= 0",
+			key.field, key.bucket, key.value); err != nil {
+			return fmt.Errorf("failed to delete empty facets: %w", err)
+		}
+	}
+	for key, stat := range c.fields {
+		if stat.count >= 0 {
+			continue
+		}
+		if _, err := db.Exec("DELETE FROM log_fields WHERE name = ? AND value = ? AND count <= 0", key.name, key.value); err != nil {
+			return fmt.Errorf("failed to delete empty field catalog rows: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillRollups rebuilds the rollup, facet and field catalog tables that are empty from existing
+// rows. The volume table is not backfilled, since what was ingested is not known once entries are
+// stored.
+func backfillRollups(db *sql.DB, recovery *RecoveryTracker) error {
+	var hasRollups, hasFacets, hasFields, hasLogs bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM log_rollups)").Scan(&hasRollups); err != nil {
+		return fmt.Errorf("failed to inspect rollup table: %w", err)
+	}
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM log_facets)").Scan(&hasFacets); err != nil {
+		return fmt.Errorf("failed to inspect facet table: %w", err)
+	}
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM log_fields)").Scan(&hasFields); err != nil {
+		return fmt.Errorf("failed to inspect field catalog table: %w", err)
+	}
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM logs)").Scan(&hasLogs); err != nil {
+		return fmt.Errorf("failed to inspect logs table: %w", err)
+	}
+	if (hasRollups && hasFacets && hasFields) || !hasLogs {
+		return nil
+	}
+
+	// Rebuilding reads every entry, which takes minutes on large databases
+	var entries int64
+	if err := db.QueryRow("SELECT COALESCE(MAX(id) - MIN(id) + 1, 0) FROM logs").Scan(&entries); err != nil {
+		return fmt.Errorf("failed to inspect logs table: %w", err)
+	}
+	recovery.update(func(status *types.RecoveryStatus) {
+		status.EntriesTotal = entries
+	})
+	recovery.beginPhase(types.RecoveryRollups)
+	defer recovery.endPhase()
+
+	// Timestamps are stored in more than one text layout, so buckets are computed in Go
+	rows, err := db.Query(`SELECT timestamp, severity, COALESCE(app_name, ''), COALESCE(hostname, ''), COALESCE(msg_id, ''),
+		COALESCE(structured_data, '') FROM logs`)
+	if err != nil {
+		return fmt.Errorf("failed to read logs for rollup backfill: %w", err)
+	}
+	counts := newRollupCounts()
+	var scanned int64
+	for rows.Next() {
+		var entry types.LogEntry
+		var structuredData string
+		if err := rows.Scan(&entry.Timestamp, &entry.Severity, &entry.AppName, &entry.Hostname, &entry.MsgID, &structuredData); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan log for rollup backfill: %w", err)
+		}
+		if (!hasRollups || !hasFields) && structuredData != "" {
+			// Rows with malformed structured data simply contribute no catalog fields or sample rate
+			json.Unmarshal([]byte(structuredData), &entry.StructuredData)
+		}
+		counts.add(&entry)
+		scanned++
+		if scanned%recoveryProgressRows == 0 {
+			recovery.update(func(status *types.RecoveryStatus) {
+				status.EntriesRecovered = scanned
+			})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read logs for rollup backfill: %w", err)
+	}
+	recovery.update(func(status *types.RecoveryStatus) {
+		status.EntriesRecovered = scanned
+	})
+
+	// Only backfill the tables that were just created
+	if hasRollups {
+		counts.series = nil
+	}
+	if hasFacets {
+		counts.facets = nil
+	}
+	if hasFields {
+		counts.fields = nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rollup backfill: %w", err)
+	}
+	if err := counts.apply(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !hasFields {
+		if err := pruneFields(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// cleanupRollups removes rollup, facet and field catalog rows older than the cutoff
+func cleanupRollups(db execer, cutoff time.Time) error {
+	if _, err := db.Exec("DELETE FROM log_rollups WHERE bucket < ?", cutoff.Truncate(rollupResolution).Unix()); err != nil {
+		return fmt.Errorf("failed to cleanup rollups: %w", err)
+	}
+	if _, err := db.Exec("DELETE FROM log_facets WHERE bucket < ?", cutoff.Truncate(facetResolution).Unix()); err != nil {
+		return fmt.Errorf("failed to cleanup facets: %w", err)
+	}
+	if _, err := db.Exec("DELETE FROM log_fields WHERE last_seen < ?", cutoff.Unix()); err != nil {
+		return fmt.Errorf("failed to cleanup field catalog: %w", err)
+	}
+	return pruneFields(db)
+}
+
+// queryHistogram answers a histogram query from the rollup table, or by counting the matching
+// entries of the logs table if it is limited to a search
+func queryHistogram(db *sql.DB, promotions *fieldPromotions, query types.HistogramQuery) (*types.Histogram, error) {
+	interval := query.Interval.Truncate(rollupResolution)
+	if interval < rollupResolution {
+		interval = rollupResolution
+	}
+	loc := query.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	// In UTC, buckets are fixed multiples of the interval since the epoch and are computed in SQL.
+	// Other zones are grouped at a step that divides every zone offset and re-bucketed in Go.
+	step := interval
+	if loc != time.UTC {
+		step = rollupResolution
+		if interval%zoneAlignment == 0 {
+			step = zoneAlignment
+		}
+	}
+	seconds := int64(step / time.Second)
+
+	var groupColumn string
+	switch query.GroupBy {
+	case "":
+		groupColumn = "''"
+	case types.GroupBySeverity:
+		groupColumn = "CAST(severity AS TEXT)"
+	case types.GroupByAppName:
+		groupColumn = "app_name"
+	case types.GroupByHostname:
+		groupColumn = "hostname"
+	default:
+		return nil, fmt.Errorf("unsupported group_by: %s", query.GroupBy)
+	}
+
+	var sqlQuery string
+	var args []interface{}
+	if query.Search != nil {
+		sqlQuery, args = searchHistogramQuery(query, promotions, seconds, groupColumn)
+	} else {
+		sqlQuery, args = rollupHistogramQuery(query, seconds, groupColumn)
+	}
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query histogram: %w", err)
+	}
+	defer rows.Close()
+
+	histogram := &types.Histogram{
+		StartTime: query.StartTime.In(loc),
+		EndTime:   query.EndTime.In(loc),
+		Interval:  interval.String(),
+		TimeZone:  loc.String(),
+		GroupBy:   query.GroupBy,
+		Buckets:   []types.HistogramBucket{},
+	}
+	type bucketKey struct {
+		time  int64
+		group string
+	}
+	index := make(map[bucketKey]int)
+	for rows.Next() {
+		var slot, count, extrapolated int64
+		var group string
+		if err := rows.Scan(&slot, &group, &count, &extrapolated); err != nil {
+			return nil, fmt.Errorf("failed to scan histogram bucket: %w", err)
+		}
+		histogram.Total += count
+		histogram.ExtrapolatedTotal += extrapolated
+
+		bucketTime := alignBucket(time.Unix(slot, 0), interval, loc)
+		key := bucketKey{time: bucketTime.Unix(), group: group}
+		if i, ok := index[key]; ok {
+			histogram.Buckets[i].Count += count
+			histogram.Buckets[i].Extrapolated += extrapolated
+			continue
+		}
+		index[key] = len(histogram.Buckets)
+		histogram.Buckets = append(histogram.Buckets, types.HistogramBucket{
+			Time:         bucketTime,
+			Group:        group,
+			Count:        count,
+			Extrapolated: extrapolated,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read histogram: %w", err)
+	}
+
+	sort.SliceStable(histogram.Buckets, func(i, j int) bool {
+		a, b := histogram.Buckets[i], histogram.Buckets[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.Group < b.Group
+	})
+
+	return histogram, nil
+}
+
+// rollupHistogramQuery builds the SQL summing the rollups of a histogram query per step of the given
+// number of seconds and group
+func rollupHistogramQuery(query types.HistogramQuery, seconds int64, groupColumn string) (string, []interface{}) {
+	conditions := []string{"bucket >= ?", "bucket <= ?"}
+	args := []interface{}{seconds, seconds, query.StartTime.Truncate(rollupResolution).Unix(), query.EndTime.Unix()}
+
+	if query.Severity != nil {
+		conditions = append(conditions, "severity = ?")
+		args = append(args, *query.Severity)
+	}
+	if query.MinSeverity != nil {
+		conditions = append(conditions, "severity <= ?")
+		args = append(args, *query.MinSeverity)
+	}
+	if query.AppName != "" {
+		conditions = append(conditions, "app_name = ?")
+		args = append(args, query.AppName)
+	}
+	if query.Hostname != "" {
+		conditions = append(conditions, "hostname = ?")
+		args = append(args, query.Hostname)
+	}
+
+	return fmt.Sprintf(`
+	SELECT (bucket / ?) * ? AS slot, %s AS grp, SUM(count), SUM(count + sampled)
+	FROM log_rollups
+	WHERE %s
+	GROUP BY slot, grp
+	ORDER BY slot, grp`, groupColumn, strings.Join(conditions, " AND ")), args
+}
+
+// alignBucket returns the start of the interval containing t on the wall clock of loc. Whole-day
+// intervals start at local midnight, so they stay aligned across daylight saving changes.
+func alignBucket(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	local := t.In(loc)
+
+	if interval%day == 0 {
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		if days := int64(interval / day); days > 1 {
+			// Count days on the local calendar so multi-day buckets are stable across queries
+			epochDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+			midnight = midnight.AddDate(0, 0, -int(((epochDay%days)+days)%days))
+		}
+		return midnight
+	}
+
+	_, offset := local.Zone()
+	seconds := int64(interval / time.Second)
+	wall := t.Unix() + int64(offset)
+	aligned := wall - ((wall%seconds)+seconds)%seconds
+	return time.Unix(aligned-int64(offset), 0).In(loc)
+}
+
+// queryFacets answers a facet query from the facet table
+func queryFacets(db *sql.DB, query types.FacetQuery) (*types.FacetResult, error) {
+	fields := query.Fields
+	if len(fields) == 0 {
+		fields = types.FacetFields
+	}
+
+	start := query.StartTime.Truncate(facetResolution)
+	result := &types.FacetResult{
+		StartTime: start,
+		EndTime:   query.EndTime,
+		Facets:    make([]types.Facet, 0, len(fields)),
+	}
+
+	for _, field := range fields {
+		switch field {
+		case types.FacetHostname, types.FacetAppName, types.FacetMsgID:
+		default:
+			return nil, fmt.Errorf("unsupported facet field: %s", field)
+		}
+
+		facet := types.Facet{Field: field, Values: []types.FacetValue{}}
+		args := []interface{}{field, start.Unix(), query.EndTime.Unix()}
+
+		if err := db.QueryRow(`
+		SELECT COUNT(DISTINCT value) FROM log_facets
+		WHERE field = ? AND bucket >= ? AND bucket <= ?`, args...).Scan(&facet.Cardinality); err != nil {
+			return nil, fmt.Errorf("failed to count facet values: %w", err)
+		}
+
+		rows, err := db.Query(`
+		SELECT value, SUM(count) AS total FROM log_facets
+		WHERE field = ? AND bucket >= ? AND bucket <= ?
+		GROUP BY value
+		ORDER BY total DESC, value
+		LIMIT ?`, append(args, query.Limit)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query facet values: %w", err)
+		}
+		for rows.Next() {
+			var value types.FacetValue
+			if err := rows.Scan(&value.Value, &value.Count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan facet value: %w", err)
+			}
+			facet.Values = append(facet.Values, value)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read facet values: %w", err)
+		}
+
+		result.Facets = append(result.Facets, facet)
+	}
+
+	return result, nil
+}
+
+// Histogram returns entry counts per time interval from the rollup table, or from the matching
+// entries for a search
+func (s *SQLiteStorage) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	return queryHistogram(s.db, s.promotions, query)
+}
+
+// Histogram returns entry counts per time interval from the rollup table, or from the matching
+// entries for a search
+func (s *BatchedSQLiteStorage) Histogram(query types.HistogramQuery) (*types.Histogram, error) {
+	return queryHistogram(s.db, s.promotions, query)
+}
+
+// Facets returns the top values and cardinalities of the facet fields from the facet table
+func (s *SQLiteStorage) Facets(query types.FacetQuery) (*types.FacetResult, error) {
+	return queryFacets(s.db, query)
+}
+
+// Facets returns the top values and cardinalities of the facet fields from the facet table
+func (s *BatchedSQLiteStorage) Facets(query types.FacetQuery) (*types.FacetResult, error) {
+	return queryFacets(s.db, query)
+}
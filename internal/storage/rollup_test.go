@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Histogram(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	entries := []struct {
+		offset   time.Duration
+		severity int
+		app      string
+	}{
+		{10 * time.Second, 3, "api"},
+		{20 * time.Second, 6, "api"},
+		{50 * time.Second, 6, "worker"},
+		{90 * time.Second, 6, "api"},
+		{6 * time.Minute, 3, "worker"},
+	}
+	for _, e := range entries {
+		entry := &types.LogEntry{
+			Priority:  16*8 + e.severity,
+			Facility:  16,
+			Severity:  e.severity,
+			Version:   1,
+			Timestamp: base.Add(e.offset),
+			Hostname:  "host",
+			AppName:   e.app,
+			Message:   "rollup test",
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	histogram, err := storage.Histogram(types.HistogramQuery{
+		StartTime: base,
+		EndTime:   base.Add(time.Hour),
+		Interval:  5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if histogram.Total != 5 {
+		t.Errorf("Expected total 5, got %d", histogram.Total)
+	}
+	if len(histogram.Buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d: %+v", len(histogram.Buckets), histogram.Buckets)
+	}
+	if !histogram.Buckets[0].Time.Equal(base) || histogram.Buckets[0].Count != 4 {
+		t.Errorf("Unexpected first bucket: %+v", histogram.Buckets[0])
+	}
+	if !histogram.Buckets[1].Time.Equal(base.Add(5*time.Minute)) || histogram.Buckets[1].Count != 1 {
+		t.Errorf("Unexpected second bucket: %+v", histogram.Buckets[1])
+	}
+
+	severity := 6
+	grouped, err := storage.Histogram(types.HistogramQuery{
+		StartTime: base,
+		EndTime:   base.Add(time.Hour),
+		Interval:  time.Minute,
+		GroupBy:   types.GroupByAppName,
+		Severity:  &severity,
+	})
+	if err != nil {
+		t.Fatalf("Grouped histogram failed: %v", err)
+	}
+	// Minute 0: api=1, worker=1; minute 1: api=1
+	expected := []types.HistogramBucket{
+		{Time: base, Group: "api", Count: 1},
+		{Time: base, Group: "worker", Count: 1},
+		{Time: base.Add(time.Minute), Group: "api", Count: 1},
+	}
+	if len(grouped.Buckets) != len(expected) {
+		t.Fatalf("Expected %d buckets, got %+v", len(expected), grouped.Buckets)
+	}
+	for i, bucket := range grouped.Buckets {
+		if !bucket.Time.Equal(expected[i].Time) || bucket.Group != expected[i].Group || bucket.Count != expected[i].Count {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, expected[i], bucket)
+		}
+	}
+
+	if _, err := storage.Histogram(types.HistogramQuery{StartTime: base, EndTime: base.Add(time.Hour), GroupBy: "message"}); err == nil {
+		t.Error("Expected error for unsupported group_by")
+	}
+}
+
+func TestSQLiteStorage_RollupBackfill(t *testing.T) {
+	path := t.TempDir() + "/backfill.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	for i := 0; i < 3; i++ {
+		entry := &types.LogEntry{Severity: 6, Version: 1, Timestamp: now, AppName: "app", Message: "backfill"}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	// Simulate a database created before rollups existed
+	if _, err := storage.db.Exec("DROP TABLE log_rollups; DROP TABLE schema_version"); err != nil {
+		t.Fatalf("Failed to drop rollups: %v", err)
+	}
+	storage.Close()
+
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+
+	histogram, err := reopened.(interfaces.HistogramProvider).Histogram(types.HistogramQuery{
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now.Add(time.Hour),
+		Interval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if histogram.Total != 3 {
+		t.Errorf("Expected backfilled total 3, got %d", histogram.Total)
+	}
+}
+
+func TestSQLiteStorage_Histogram_SampleRates(t *testing.T) {
+	path := t.TempDir() + "/sampled.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for _, rate := range []string{"", "10", "100"} {
+		entry := &types.LogEntry{Severity: 6, Version: 1, Timestamp: base, AppName: "api", Message: "sampled"}
+		if rate != "" {
+			entry.SetMetadata(types.SampleRateParam, rate)
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	check := func(storage interfaces.HistogramProvider, search *types.SearchQuery) {
+		t.Helper()
+		histogram, err := storage.Histogram(types.HistogramQuery{
+			StartTime: base,
+			EndTime:   base.Add(time.Hour),
+			Interval:  time.Hour,
+			Search:    search,
+		})
+		if err != nil {
+			t.Fatalf("Histogram failed: %v", err)
+		}
+		if histogram.Total != 3 || histogram.ExtrapolatedTotal != 111 {
+			t.Errorf("Expected 3 entries standing for 111 messages, got %d and %d", histogram.Total, histogram.ExtrapolatedTotal)
+		}
+		if len(histogram.Buckets) != 1 || histogram.Buckets[0].Count != 3 || histogram.Buckets[0].Extrapolated != 111 {
+			t.Errorf("Unexpected buckets: %+v", histogram.Buckets)
+		}
+	}
+	check(storage, nil)
+	check(storage, &types.SearchQuery{})
+
+	// Rebuilt rollups read the sample rates from the stored entries
+	if _, err := storage.db.Exec("DROP TABLE log_rollups; DROP TABLE schema_version"); err != nil {
+		t.Fatalf("Failed to drop rollups: %v", err)
+	}
+	storage.Close()
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+	check(reopened.(interfaces.HistogramProvider), nil)
+}
+
+func TestBatchedSQLiteStorage_Histogram(t *testing.T) {
+	storage, err := NewBatchedSQLiteStorage(t.TempDir()+"/rollup.db", DefaultBatchConfig())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storage.Close()
+	batched := storage.(*BatchedSQLiteStorage)
+
+	now := time.Now().UTC()
+	requests := make([]*writeRequest, 10)
+	for i := range requests {
+		entry := &types.LogEntry{Severity: i % 2, Version: 1, Timestamp: now, Hostname: "host", Message: "batched"}
+		requests[i] = newWriteRequest(entry, context.Background())
+	}
+	if err := batched.executeBatchWrite(requests); err != nil {
+		t.Fatalf("Batch write failed: %v", err)
+	}
+
+	histogram, err := batched.Histogram(types.HistogramQuery{
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now.Add(time.Hour),
+		Interval:  time.Hour,
+		GroupBy:   types.GroupBySeverity,
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if histogram.Total != 10 {
+		t.Errorf("Expected total 10, got %d", histogram.Total)
+	}
+	for _, bucket := range histogram.Buckets {
+		if bucket.Count != 5 {
+			t.Errorf("Expected 5 entries for severity %s, got %d", bucket.Group, bucket.Count)
+		}
+	}
+}
+
+func TestSQLiteStorage_Facets(t *testing.T) {
+	path := t.TempDir() + "/facets.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	entries := []struct{ host, app, msgID string }{
+		{"web-1", "nginx", "ACCESS"},
+		{"web-1", "nginx", "ACCESS"},
+		{"web-2", "nginx", "ERROR"},
+		{"db-1", "postgres", "-"},
+	}
+	for i, e := range entries {
+		entry := &types.LogEntry{
+			Severity:  6,
+			Version:   1,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Hostname:  e.host,
+			AppName:   e.app,
+			MsgID:     e.msgID,
+			Message:   "facet test",
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	check := func(t *testing.T, provider interfaces.FacetProvider) {
+		result, err := provider.Facets(types.FacetQuery{
+			StartTime: base,
+			EndTime:   base.Add(time.Hour),
+			Limit:     2,
+		})
+		if err != nil {
+			t.Fatalf("Facets failed: %v", err)
+		}
+		if len(result.Facets) != len(types.FacetFields) {
+			t.Fatalf("Expected %d facets, got %d", len(types.FacetFields), len(result.Facets))
+		}
+
+		hosts := result.Facets[0]
+		if hosts.Field != types.FacetHostname || hosts.Cardinality != 3 {
+			t.Errorf("Unexpected hostname facet: %+v", hosts)
+		}
+		if len(hosts.Values) != 2 || hosts.Values[0] != (types.FacetValue{Value: "web-1", Count: 2}) {
+			t.Errorf("Expected web-1 to be the top host limited to 2 values, got %+v", hosts.Values)
+		}
+
+		msgIDs := result.Facets[2]
+		if msgIDs.Cardinality != 2 {
+			t.Errorf("Expected nil msg_id to be ignored, got cardinality %d", msgIDs.Cardinality)
+		}
+	}
+	check(t, storage)
+
+	if _, err := storage.Facets(types.FacetQuery{Fields: []string{"message"}, Limit: 1}); err == nil {
+		t.Error("Expected error for unsupported facet field")
+	}
+
+	// Facets are backfilled for databases created before they existed
+	if _, err := storage.db.Exec("DROP TABLE log_facets; DROP TABLE schema_version"); err != nil {
+		t.Fatalf("Failed to drop facets: %v", err)
+	}
+	storage.Close()
+
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+	check(t, reopened.(interfaces.FacetProvider))
+
+	// The histogram rollups must not have been counted twice
+	histogram, err := reopened.(interfaces.HistogramProvider).Histogram(types.HistogramQuery{
+		StartTime: base,
+		EndTime:   base.Add(time.Hour),
+		Interval:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if histogram.Total != int64(len(entries)) {
+		t.Errorf("Expected histogram total %d, got %d", len(entries), histogram.Total)
+	}
+}
+
+func TestSQLiteStorage_Histogram_TimeZone(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// 23:30 UTC on Jan 1 is already Jan 2 in Berlin
+	for _, ts := range []time.Time{
+		time.Date(2024, 1, 1, 22, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC),
+	} {
+		if err := storage.Store(&types.LogEntry{Severity: 6, Version: 1, Timestamp: ts, Message: "tz"}); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	query := types.HistogramQuery{
+		StartTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		Interval:  24 * time.Hour,
+	}
+
+	utc, err := storage.Histogram(query)
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if len(utc.Buckets) != 1 || utc.Buckets[0].Count != 2 {
+		t.Errorf("Expected one UTC day with 2 entries, got %+v", utc.Buckets)
+	}
+
+	query.Location = berlin
+	local, err := storage.Histogram(query)
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	if local.TimeZone != "Europe/Berlin" {
+		t.Errorf("Expected time zone Europe/Berlin, got %s", local.TimeZone)
+	}
+	expected := []time.Time{
+		time.Date(2024, 1, 1, 0, 0, 0, 0, berlin),
+		time.Date(2024, 1, 2, 0, 0, 0, 0, berlin),
+	}
+	if len(local.Buckets) != len(expected) {
+		t.Fatalf("Expected %d local days, got %+v", len(expected), local.Buckets)
+	}
+	for i, bucket := range local.Buckets {
+		if !bucket.Time.Equal(expected[i]) || bucket.Count != 1 {
+			t.Errorf("Bucket %d: expected 1 entry at %v, got %+v", i, expected[i], bucket)
+		}
+	}
+}
+
+func TestAlignBucket(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// Hourly buckets in a +05:30 zone start at half past the UTC hour
+	got := alignBucket(time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC), time.Hour, kolkata)
+	if want := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got = alignBucket(time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC), 15*time.Minute, time.UTC)
+	if want := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRollups_FailedUpdateStoresNothing(t *testing.T) {
+	entry := func() *types.LogEntry {
+		return &types.LogEntry{Severity: 6, Version: 1, Timestamp: time.Now().UTC(), Hostname: "host", Message: "rollup failure"}
+	}
+	countRows := func(t *testing.T, db interface {
+		QueryRow(query string, args ...interface{}) *sql.Row
+	}) int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+			t.Fatalf("Failed to count entries: %v", err)
+		}
+		return count
+	}
+
+	t.Run("Store", func(t *testing.T) {
+		storage := setupTestStorage(t)
+		defer cleanupTestStorage(storage)
+
+		if _, err := storage.db.Exec("DROP TABLE log_rollups"); err != nil {
+			t.Fatalf("Failed to drop rollups: %v", err)
+		}
+		if err := storage.Store(entry()); err == nil {
+			t.Fatal("Expected the store to fail without rollups")
+		}
+		if count := countRows(t, storage.db); count != 0 {
+			t.Errorf("Expected no stored entry after the rollup update failed, got %d", count)
+		}
+	})
+
+	t.Run("IndividualWrite", func(t *testing.T) {
+		storage, err := NewBatchedSQLiteStorage(t.TempDir()+"/rollup.db", DefaultBatchConfig())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		defer storage.Close()
+		batched := storage.(*BatchedSQLiteStorage)
+
+		if _, err := batched.db.Exec("DROP TABLE log_rollups"); err != nil {
+			t.Fatalf("Failed to drop rollups: %v", err)
+		}
+		if err := batched.executeIndividualWrite(newWriteRequest(entry(), context.Background())); err == nil {
+			t.Fatal("Expected the write to fail without rollups")
+		}
+		if count := countRows(t, batched.db); count != 0 {
+			t.Errorf("Expected no stored entry after the rollup update failed, got %d", count)
+		}
+	})
+}
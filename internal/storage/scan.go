@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"opentrail/internal/types"
+)
+
+// scanPage reads a page of entries by descending ID for an adhoc scan. Only the primary key is used,
+// so every page costs the same however selective the scan is.
+func scanPage(db *sql.DB, beforeID int64, limit int, withRaw bool) ([]*types.LogEntry, error) {
+	sqlQuery := `
+	SELECT id, uid, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at`
+	if withRaw {
+		sqlQuery += ", raw_message"
+	}
+	sqlQuery += " FROM logs"
+	args := []interface{}{}
+	if beforeID > 0 {
+		sqlQuery += " WHERE id < ?"
+		args = append(args, beforeID)
+	}
+	sqlQuery += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*types.LogEntry
+	for rows.Next() {
+		entry := &types.LogEntry{}
+		var uid, structuredDataJSON sql.NullString
+		var raw interface{}
+		dest := []interface{}{&entry.ID, &uid, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
+			&structuredDataJSON, &entry.Message, &entry.CreatedAt}
+		if withRaw {
+			dest = append(dest, &raw)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan log entry: %w", err)
+		}
+		entry.UID = uid.String
+		if withRaw {
+			if entry.Raw, err = decodeRawMessage(raw); err != nil {
+				return nil, fmt.Errorf("failed to decode raw message of entry %d: %w", entry.ID, err)
+			}
+		}
+		if structuredDataJSON.Valid && structuredDataJSON.String != "" {
+			var structuredData map[string]interface{}
+			if err := json.Unmarshal([]byte(structuredDataJSON.String), &structuredData); err == nil {
+				entry.StructuredData = structuredData
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return entries, nil
+}
+
+// ScanPage reads a page of entries by descending ID for an adhoc scan
+func (s *SQLiteStorage) ScanPage(beforeID int64, limit int, withRaw bool) ([]*types.LogEntry, error) {
+	return scanPage(s.db, beforeID, limit, withRaw)
+}
+
+// ScanPage reads a page of entries by descending ID for an adhoc scan
+func (s *BatchedSQLiteStorage) ScanPage(beforeID int64, limit int, withRaw bool) ([]*types.LogEntry, error) {
+	return scanPage(s.db, beforeID, limit, withRaw)
+}
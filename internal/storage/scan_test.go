@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_ScanPage(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		entry := &types.LogEntry{Version: 1, Priority: 134, Facility: 16, Severity: 6, Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Hostname: "host", AppName: "app", Message: fmt.Sprintf("message %d", i), Raw: fmt.Sprintf("<134>1 raw %d", i)}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	first, err := storage.ScanPage(0, 3, false)
+	if err != nil {
+		t.Fatalf("ScanPage failed: %v", err)
+	}
+	if len(first) != 3 || first[0].Message != "message 4" || first[2].Message != "message 2" || first[0].Raw != "" {
+		t.Fatalf("Expected the three newest entries without raw messages, got %+v", first)
+	}
+
+	rest, err := storage.ScanPage(first[2].ID, 3, true)
+	if err != nil {
+		t.Fatalf("ScanPage failed: %v", err)
+	}
+	if len(rest) != 2 || rest[0].Message != "message 1" || rest[1].Raw != "<134>1 raw 0" {
+		t.Fatalf("Expected the two oldest entries with raw messages, got %+v", rest)
+	}
+
+	if last, err := storage.ScanPage(rest[1].ID, 3, false); err != nil || len(last) != 0 {
+		t.Errorf("Expected no entries past the oldest, got %d (%v)", len(last), err)
+	}
+}
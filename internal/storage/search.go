@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"opentrail/internal/types"
+)
+
+// timeFirstTextLimit is the most entries a text search's time range may hold for the range to be
+// read first, newest entry first, checking each against the full-text index until the page is
+// full. Larger ranges read every full-text match first and sort them, which costs the same however
+// wide the range is, while each check against the index costs several microseconds.
+const timeFirstTextLimit = 10000
+
+// searchStatement builds the SQL and arguments of a search selecting columns, with the highlight
+// expression last if highlight is set. Filters are answered by the composite indexes of the
+// search_indexes migration, whose columns end with the timestamp so that entries come out of the
+// index already in entryOrder and the search stops reading after the page instead of sorting
+// every match. Text searches within a short time range read the range first, see
+// timeFirstTextLimit.
+func searchStatement(db queryRower, query types.SearchQuery, promotions *fieldPromotions, columns []string, highlight bool) (string, []interface{}, error) {
+	var args []interface{}
+	statement := "SELECT " + selectList(columns, "") + " FROM logs"
+
+	if query.Text != "" {
+		timeFirst, err := narrowTimeRange(db, query)
+		if err != nil {
+			return "", nil, err
+		}
+		selected := selectList(columns, "l")
+		if highlight {
+			selected += ", " + highlightExpression
+		}
+		// SQLite keeps the left table of a CROSS JOIN in the outer loop, so logs is read by timestamp
+		join := "logs l JOIN logs_fts fts ON l.id = fts.rowid"
+		if timeFirst {
+			join = "logs l CROSS JOIN logs_fts fts ON fts.rowid = l.id"
+		}
+		statement = `
+		SELECT ` + selected + `
+		FROM ` + join + `
+		WHERE logs_fts MATCH ?`
+		args = append(args, query.Text)
+	}
+
+	// Add RFC5424 and field filters
+	conditions, conditionArgs := searchConditions(query, promotions)
+	args = append(args, conditionArgs...)
+
+	if len(conditions) > 0 {
+		if query.Text != "" {
+			statement += " AND " + strings.Join(conditions, " AND ")
+		} else {
+			statement += " WHERE " + strings.Join(conditions, " AND ")
+		}
+	}
+
+	statement += " ORDER BY " + entryOrder
+
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	if query.Offset > 0 {
+		statement += " OFFSET ?"
+		args = append(args, query.Offset)
+	}
+
+	return statement, args, nil
+}
+
+// narrowTimeRange reports whether a search has a time range holding at most timeFirstTextLimit
+// entries. Counting stops after the limit, and only reads the timestamp index.
+func narrowTimeRange(db queryRower, query types.SearchQuery) (bool, error) {
+	if query.StartTime == nil && query.EndTime == nil {
+		return false, nil
+	}
+	var conditions []string
+	var args []interface{}
+	if query.StartTime != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, storedTimestamp(*query.StartTime))
+	}
+	if query.EndTime != nil {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, storedTimestamp(*query.EndTime))
+	}
+	args = append(args, timeFirstTextLimit+1)
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM logs WHERE "+strings.Join(conditions, " AND ")+" LIMIT ?)", args...).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to count entries in time range: %w", err)
+	}
+	return count <= timeFirstTextLimit, nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// searchShapesStart is the timestamp of the first entry written by populateSearchShapes
+var searchShapesStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// populateSearchShapes writes count entries five seconds apart from 50 hosts and 20 apps. Every
+// 50th entry is an error, every 10th otherwise a warning, and every 100th is "connection refused".
+func populateSearchShapes(tb testing.TB, db *sql.DB, count int) {
+	tb.Helper()
+	_, err := db.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+	INSERT INTO logs (priority, facility, severity, timestamp, hostname, app_name, proc_id, msg_id, message)
+	SELECT 128 + s, 16, s, strftime('%Y-%m-%dT%H:%M:%S.000000000Z', ?, '+' || (i * 5) || ' seconds'),
+		'host' || (i % 50), 'app' || (i % 20), '', '',
+		'request ' || i || CASE WHEN i % 100 = 0 THEN ' connection refused' ELSE ' ok' END
+	FROM (SELECT i, CASE WHEN i % 50 = 0 THEN 3 WHEN i % 10 = 0 THEN 4 ELSE 6 END AS s FROM n)`,
+		count, searchShapesStart.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		tb.Fatalf("Failed to populate entries: %v", err)
+	}
+}
+
+// searchShapes are the frequent filter combinations of the search page and the API
+func searchShapes() map[string]types.SearchQuery {
+	at := func(hours int) *time.Time {
+		t := searchShapesStart.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	errorSeverity, warningSeverity := 3, 4
+	return map[string]types.SearchQuery{
+		"app_severity_time": {AppName: "app10", MinSeverity: &warningSeverity, StartTime: at(24), EndTime: at(48), Limit: 100},
+		"app_severity":      {AppName: "app10", Severity: &errorSeverity, Limit: 100},
+		"hostname_time":     {Hostname: "host7", StartTime: at(24), Limit: 100},
+		"severity":          {Severity: &errorSeverity, Limit: 100},
+		"text_narrow_time":  {Text: "request", StartTime: at(24), EndTime: at(30), Limit: 100},
+		"text_wide_time":    {Text: "refused", StartTime: at(24), Limit: 100},
+	}
+}
+
+func TestSearchStatement_Plans(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+	populateSearchShapes(t, storage.db, 50000)
+
+	// The index each shape should use; none of them may sort its matches
+	expected := map[string]string{
+		"app_severity_time": "idx_logs_app_name_timestamp",
+		"app_severity":      "idx_logs_app_name_severity_timestamp",
+		"hostname_time":     "idx_logs_hostname_timestamp",
+		"severity":          "idx_logs_severity_timestamp",
+		"text_narrow_time":  "idx_logs_timestamp",
+		"text_wide_time":    "VIRTUAL TABLE",
+	}
+	for name, query := range searchShapes() {
+		statement, args, err := searchStatement(storage.db, query, storage.promotions, types.SearchResultFields, query.Text != "")
+		if err != nil {
+			t.Fatalf("%s: searchStatement failed: %v", name, err)
+		}
+		rows, err := storage.db.Query("EXPLAIN QUERY PLAN "+statement, args...)
+		if err != nil {
+			t.Fatalf("%s: EXPLAIN failed: %v", name, err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+				t.Fatalf("%s: failed to read plan: %v", name, err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+
+		// The first step of the plan is the outer loop
+		if len(plan) == 0 || !strings.Contains(plan[0], expected[name]) {
+			t.Errorf("%s: expected the search to start with %s, got %q", name, expected[name], plan)
+		}
+		if name != "text_wide_time" && strings.Contains(strings.Join(plan, "\n"), "TEMP B-TREE") {
+			t.Errorf("%s: expected no sort, got %q", name, plan)
+		}
+	}
+}
+
+func TestSearch_TextPlansAgree(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+	populateSearchShapes(t, storage.db, 20000)
+
+	// The same range read time first and full-text first returns the same page
+	start := searchShapesStart.Add(2 * time.Hour)
+	narrowEnd := start.Add(time.Hour)
+	narrow := types.SearchQuery{Text: "refused", StartTime: &start, EndTime: &narrowEnd, Limit: 5}
+	wide := types.SearchQuery{Text: "refused", StartTime: &start, Limit: 1000}
+
+	timeFirst, err := narrowTimeRange(storage.db, narrow)
+	if err != nil || !timeFirst {
+		t.Fatalf("Expected an hour to be read time first, got %v (%v)", timeFirst, err)
+	}
+	if timeFirst, _ := narrowTimeRange(storage.db, wide); timeFirst {
+		t.Fatal("Expected a day to be read full-text first")
+	}
+
+	page, err := storage.Search(narrow)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	all, err := storage.Search(wide)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var inRange []*types.LogEntry
+	for _, entry := range all {
+		if !entry.Timestamp.After(narrowEnd) {
+			inRange = append(inRange, entry)
+		}
+	}
+	if len(page) != 5 || len(inRange) < 5 {
+		t.Fatalf("Expected a full page, got %d entries of %d", len(page), len(inRange))
+	}
+	for i, entry := range page {
+		if entry.ID != inRange[i].ID || len(entry.Highlights) == 0 {
+			t.Errorf("Entry %d: expected %d with highlights, got %d (%v)", i, inRange[i].ID, entry.ID, entry.Highlights)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"opentrail/internal/interfaces"
+)
+
+func loadSetting(db *sql.DB, key string) (string, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", interfaces.ErrSettingNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to select setting: %w", err)
+	}
+	return value, nil
+}
+
+func saveSetting(db *sql.DB, key, value string) error {
+	if _, err := db.Exec(`
+	INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save setting: %w", err)
+	}
+	return nil
+}
+
+func deleteSetting(db *sql.DB, key string) error {
+	if _, err := db.Exec("DELETE FROM settings WHERE key = ?", key); err != nil {
+		return fmt.Errorf("failed to delete setting: %w", err)
+	}
+	return nil
+}
+
+// Setting returns the value stored for a setting
+func (s *SQLiteStorage) Setting(key string) (string, error) {
+	return loadSetting(s.db, key)
+}
+
+// SaveSetting stores the value of a setting, replacing the previous one
+func (s *SQLiteStorage) SaveSetting(key, value string) error {
+	return saveSetting(s.db, key, value)
+}
+
+// DeleteSetting removes the value of a setting, if any
+func (s *SQLiteStorage) DeleteSetting(key string) error {
+	return deleteSetting(s.db, key)
+}
+
+// Setting returns the value stored for a setting
+func (s *BatchedSQLiteStorage) Setting(key string) (string, error) {
+	return loadSetting(s.db, key)
+}
+
+// SaveSetting stores the value of a setting, replacing the previous one
+func (s *BatchedSQLiteStorage) SaveSetting(key, value string) error {
+	return saveSetting(s.db, key, value)
+}
+
+// DeleteSetting removes the value of a setting, if any
+func (s *BatchedSQLiteStorage) DeleteSetting(key string) error {
+	return deleteSetting(s.db, key)
+}
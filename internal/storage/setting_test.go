@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"opentrail/internal/interfaces"
+)
+
+func TestSQLiteStorage_Settings(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	if _, err := storage.Setting("retention_days"); !errors.Is(err, interfaces.ErrSettingNotFound) {
+		t.Errorf("Expected ErrSettingNotFound, got %v", err)
+	}
+	for _, value := range []string{"30", "90"} {
+		if err := storage.SaveSetting("retention_days", value); err != nil {
+			t.Fatalf("SaveSetting failed: %v", err)
+		}
+	}
+	if value, err := storage.Setting("retention_days"); err != nil || value != "90" {
+		t.Errorf("Expected the last value saved, got %q (%v)", value, err)
+	}
+
+	if err := storage.DeleteSetting("retention_days"); err != nil {
+		t.Fatalf("DeleteSetting failed: %v", err)
+	}
+	if _, err := storage.Setting("retention_days"); !errors.Is(err, interfaces.ErrSettingNotFound) {
+		t.Errorf("Expected ErrSettingNotFound once deleted, got %v", err)
+	}
+	if err := storage.DeleteSetting("retention_days"); err != nil {
+		t.Errorf("Expected deleting a missing setting to succeed, got %v", err)
+	}
+}
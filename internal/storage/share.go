@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func createShare(db *sql.DB, share *types.Share) error {
+	entries, err := json.Marshal(share.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode shared entries: %w", err)
+	}
+	share.EntryCount = len(share.Entries)
+	if _, err := db.Exec(`
+	INSERT INTO shares (token, description, query, created_by, created_at, expires_at, entry_count, entries)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		share.Token, share.Description, share.Query, share.CreatedBy, share.CreatedAt.UTC(), share.ExpiresAt.UTC(),
+		share.EntryCount, string(entries)); err != nil {
+		return fmt.Errorf("failed to create share: %w", err)
+	}
+	return nil
+}
+
+func loadShare(db *sql.DB, token string, now time.Time) (*types.Share, error) {
+	share := &types.Share{Token: token}
+	var entries string
+	err := db.QueryRow(`
+	SELECT description, query, created_by, created_at, expires_at, entry_count, entries FROM shares
+	WHERE token = ? AND expires_at > ?`, token, now.UTC()).
+		Scan(&share.Description, &share.Query, &share.CreatedBy, &share.CreatedAt, &share.ExpiresAt, &share.EntryCount, &entries)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, interfaces.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select share: %w", err)
+	}
+	if err := json.Unmarshal([]byte(entries), &share.Entries); err != nil {
+		return nil, fmt.Errorf("failed to decode shared entries: %w", err)
+	}
+	share.CreatedAt, share.ExpiresAt = share.CreatedAt.UTC(), share.ExpiresAt.UTC()
+	return share, nil
+}
+
+func listShares(db *sql.DB, now time.Time) ([]types.Share, error) {
+	rows, err := db.Query(`
+	SELECT token, description, query, created_by, created_at, expires_at, entry_count FROM shares
+	WHERE expires_at > ? ORDER BY created_at DESC, token`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to select shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []types.Share{}
+	for rows.Next() {
+		var share types.Share
+		if err := rows.Scan(&share.Token, &share.Description, &share.Query, &share.CreatedBy, &share.CreatedAt,
+			&share.ExpiresAt, &share.EntryCount); err != nil {
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+		share.CreatedAt, share.ExpiresAt = share.CreatedAt.UTC(), share.ExpiresAt.UTC()
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shares: %w", err)
+	}
+	return shares, nil
+}
+
+func deleteShare(db *sql.DB, token string) error {
+	result, err := db.Exec("DELETE FROM shares WHERE token = ?", token)
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+	if deleted == 0 {
+		return interfaces.ErrShareNotFound
+	}
+	return nil
+}
+
+func deleteExpiredShares(db *sql.DB, now time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM shares WHERE expires_at <= ?", now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired shares: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// CreateShare saves a share with its entries
+func (s *SQLiteStorage) CreateShare(share *types.Share) error {
+	return createShare(s.db, share)
+}
+
+// Share returns a share with its entries unless it expired by now
+func (s *SQLiteStorage) Share(token string, now time.Time) (*types.Share, error) {
+	return loadShare(s.db, token, now)
+}
+
+// Shares lists the shares not expired by now without their entries, newest first
+func (s *SQLiteStorage) Shares(now time.Time) ([]types.Share, error) {
+	return listShares(s.db, now)
+}
+
+// DeleteShare removes a share
+func (s *SQLiteStorage) DeleteShare(token string) error {
+	return deleteShare(s.db, token)
+}
+
+// DeleteExpiredShares removes the shares expired by now
+func (s *SQLiteStorage) DeleteExpiredShares(now time.Time) (int64, error) {
+	return deleteExpiredShares(s.db, now)
+}
+
+// CreateShare saves a share with its entries
+func (s *BatchedSQLiteStorage) CreateShare(share *types.Share) error {
+	return createShare(s.db, share)
+}
+
+// Share returns a share with its entries unless it expired by now
+func (s *BatchedSQLiteStorage) Share(token string, now time.Time) (*types.Share, error) {
+	return loadShare(s.db, token, now)
+}
+
+// Shares lists the shares not expired by now without their entries, newest first
+func (s *BatchedSQLiteStorage) Shares(now time.Time) ([]types.Share, error) {
+	return listShares(s.db, now)
+}
+
+// DeleteShare removes a share
+func (s *BatchedSQLiteStorage) DeleteShare(token string) error {
+	return deleteShare(s.db, token)
+}
+
+// DeleteExpiredShares removes the shares expired by now
+func (s *BatchedSQLiteStorage) DeleteExpiredShares(now time.Time) (int64, error) {
+	return deleteExpiredShares(s.db, now)
+}
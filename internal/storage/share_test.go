@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opentrail/internal/interfaces"
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Shares(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := &types.LogEntry{
+		ID: 42, Priority: 11, Facility: 1, Severity: 3, Version: 1, Timestamp: now.Add(-time.Hour),
+		Hostname: "web-1", AppName: "api", Message: "upstream timed out",
+		StructuredData: map[string]interface{}{"req@32473": map[string]interface{}{"path": "/orders"}},
+	}
+	shares := []*types.Share{
+		{Token: "older", Description: "outage", Query: "app_name=api", CreatedBy: "alice", CreatedAt: now.Add(-time.Minute),
+			ExpiresAt: now.Add(time.Hour), Entries: []*types.LogEntry{entry}},
+		{Token: "newer", CreatedBy: "bob", CreatedAt: now, ExpiresAt: now.Add(2 * time.Hour), Entries: []*types.LogEntry{entry, entry}},
+		{Token: "expired", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now, Entries: []*types.LogEntry{entry}},
+	}
+	for _, share := range shares {
+		if err := storage.CreateShare(share); err != nil {
+			t.Fatalf("CreateShare failed: %v", err)
+		}
+	}
+	if shares[1].EntryCount != 2 {
+		t.Errorf("Expected the entry count set, got %d", shares[1].EntryCount)
+	}
+
+	share, err := storage.Share("older", now)
+	if err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	if share.Description != "outage" || share.Query != "app_name=api" || share.CreatedBy != "alice" || share.EntryCount != 1 ||
+		!share.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected share %+v", share)
+	}
+	if len(share.Entries) != 1 || share.Entries[0].ID != 42 || share.Entries[0].Message != "upstream timed out" ||
+		!share.Entries[0].Timestamp.Equal(entry.Timestamp) {
+		t.Fatalf("Expected the entry frozen, got %+v", share.Entries)
+	}
+	if element, ok := share.Entries[0].StructuredData["req@32473"].(map[string]interface{}); !ok || element["path"] != "/orders" {
+		t.Errorf("Expected the structured data frozen, got %v", share.Entries[0].StructuredData)
+	}
+
+	if _, err := storage.Share("expired", now); !errors.Is(err, interfaces.ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound for an expired share, got %v", err)
+	}
+	if _, err := storage.Share("missing", now); !errors.Is(err, interfaces.ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound for an unknown token, got %v", err)
+	}
+
+	listed, err := storage.Shares(now)
+	if err != nil {
+		t.Fatalf("Shares failed: %v", err)
+	}
+	if len(listed) != 2 || listed[0].Token != "newer" || listed[1].Token != "older" || listed[0].Entries != nil || listed[0].EntryCount != 2 {
+		t.Errorf("Expected the open shares newest first without entries, got %+v", listed)
+	}
+
+	removed, err := storage.DeleteExpiredShares(now)
+	if err != nil || removed != 1 {
+		t.Errorf("Expected the expired share removed, got %d (%v)", removed, err)
+	}
+	if err := storage.DeleteShare("older"); err != nil {
+		t.Fatalf("DeleteShare failed: %v", err)
+	}
+	if err := storage.DeleteShare("older"); !errors.Is(err, interfaces.ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound deleting twice, got %v", err)
+	}
+	if listed, _ := storage.Shares(now); len(listed) != 1 {
+		t.Errorf("Expected one share left, got %+v", listed)
+	}
+}
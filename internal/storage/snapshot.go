@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// Snapshot reads a database as it was when the snapshot was opened. It holds a read transaction:
+// in WAL mode a running server keeps writing meanwhile, but entries it stores or deletes afterwards
+// neither show up in nor vanish from a long read. Close it promptly, as checkpoints cannot move past
+// an open snapshot and the write-ahead log keeps growing.
+type Snapshot struct {
+	db *sql.DB
+	tx *sql.Tx
+
+	// TakenAt is when the snapshot was opened
+	TakenAt time.Time
+	// MaxID is the highest entry ID in the snapshot
+	MaxID int64
+	// TotalEntries is the number of entries in the snapshot
+	TotalEntries int64
+}
+
+// OpenSnapshot opens a read-only snapshot of the database at dbPath. Unlike NewSQLiteStorage it
+// neither creates nor migrates the database, so it can be used alongside a running server.
+func OpenSnapshot(dbPath string) (*Snapshot, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// The transaction pins a single connection
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{"PRAGMA busy_timeout = 5000", "PRAGMA query_only = ON"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure database: %w", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to begin snapshot: %w", err)
+	}
+
+	// The first read fixes the snapshot
+	snapshot := &Snapshot{db: db, tx: tx, TakenAt: time.Now()}
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0), COUNT(*) FROM logs").Scan(&snapshot.MaxID, &snapshot.TotalEntries); err != nil {
+		tx.Rollback()
+		db.Close()
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Count returns the number of entries with timestamps in the given bounds, either of which may be nil
+func (s *Snapshot) Count(startTime, endTime *time.Time) (int64, error) {
+	where, args := snapshotBounds(startTime, endTime)
+	var count int64
+	if err := s.tx.QueryRow("SELECT COUNT(*) FROM logs"+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count entries: %w", err)
+	}
+	return count, nil
+}
+
+// Entries calls fn for every entry with a timestamp in the given bounds, either of which may be nil,
+// in timestamp order. Entries with the same timestamp are passed in the order they were stored.
+// Iteration stops at the first error fn returns.
+func (s *Snapshot) Entries(startTime, endTime *time.Time, fn func(entry *types.LogEntry) error) error {
+	where, args := snapshotBounds(startTime, endTime)
+	rows, err := s.tx.Query(`
+	SELECT id, uid, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at
+	FROM logs`+where+" ORDER BY timestamp, id", args...)
+	if err != nil {
+		return fmt.Errorf("failed to read entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &types.LogEntry{}
+		var uid, structuredDataJSON sql.NullString
+		if err := rows.Scan(&entry.ID, &uid, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
+			&structuredDataJSON, &entry.Message, &entry.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan log entry: %w", err)
+		}
+		entry.UID = uid.String
+		if structuredDataJSON.String != "" {
+			json.Unmarshal([]byte(structuredDataJSON.String), &entry.StructuredData)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return nil
+}
+
+// StructuredKeys returns up to limit structured data keys ("sdid.param") from the field catalog,
+// those seen most often first. The catalog only keeps recent values, so the counts are approximate.
+func (s *Snapshot) StructuredKeys(limit int) ([]string, error) {
+	rows, err := s.tx.Query(`
+	SELECT name FROM log_fields
+	WHERE name NOT IN ('hostname', 'app_name', 'msg_id')
+	GROUP BY name
+	ORDER BY SUM(count) DESC, name
+	LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query field catalog: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan field: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read field catalog: %w", err)
+	}
+	return keys, nil
+}
+
+// Close ends the snapshot and closes the database
+func (s *Snapshot) Close() error {
+	s.tx.Rollback()
+	return s.db.Close()
+}
+
+// snapshotBounds builds the WHERE clause limiting entries to a time range
+func snapshotBounds(startTime, endTime *time.Time) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if startTime != nil {
+		where = " WHERE timestamp >= ?"
+		args = append(args, storedTimestamp(*startTime))
+	}
+	if endTime != nil {
+		if where == "" {
+			where = " WHERE timestamp <= ?"
+		} else {
+			where += " AND timestamp <= ?"
+		}
+		args = append(args, storedTimestamp(*endTime))
+	}
+	return where, args
+}
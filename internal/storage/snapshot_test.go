@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSnapshot_IsolatedFromLaterWrites(t *testing.T) {
+	path := t.TempDir() + "/snapshot.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+	defer storage.Close()
+
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	store := func(offset time.Duration, message string) {
+		t.Helper()
+		entry := &types.LogEntry{Severity: 6, Version: 1, Timestamp: base.Add(offset), Hostname: "web-1", AppName: "app", Message: message}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+	store(2*time.Hour, "third")
+	store(0, "first")
+	store(time.Hour, "second")
+
+	snapshot, err := OpenSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+	if snapshot.TotalEntries != 3 || snapshot.MaxID != 3 {
+		t.Errorf("Expected 3 entries up to ID 3, got %d up to %d", snapshot.TotalEntries, snapshot.MaxID)
+	}
+
+	// Written while the snapshot is open
+	store(30*time.Minute, "late")
+	if _, err := storage.db.Exec("DELETE FROM logs WHERE message = 'second'"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+
+	var messages []string
+	err = snapshot.Entries(nil, nil, func(entry *types.LogEntry) error {
+		messages = append(messages, entry.Message)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(messages) != 3 || messages[0] != "first" || messages[1] != "second" || messages[2] != "third" {
+		t.Errorf("Expected the snapshot in timestamp order, got %v", messages)
+	}
+
+	start, end := base.Add(time.Hour), base.Add(2*time.Hour)
+	count, err := snapshot.Count(&start, &end)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 entries within the bounds, got %d", count)
+	}
+}
+
+func TestSnapshot_StructuredKeys(t *testing.T) {
+	path := t.TempDir() + "/keys.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer created.Close()
+
+	for i, params := range []map[string]string{{"id": "1", "user": "a"}, {"id": "2"}, {"id": "3", "user": "b"}, {"trace": "t"}} {
+		entry := &types.LogEntry{Severity: 6, Version: 1, Timestamp: time.Now().Add(time.Duration(i) * time.Second),
+			Hostname: "web-1", AppName: "app", Message: "request", StructuredData: map[string]interface{}{"req": params}}
+		if err := created.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	snapshot, err := OpenSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	keys, err := snapshot.StructuredKeys(2)
+	if err != nil {
+		t.Fatalf("StructuredKeys failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "req.id" || keys[1] != "req.user" {
+		t.Errorf("Expected the most common keys req.id and req.user, got %v", keys)
+	}
+}
+
+func TestOpenSnapshot_MissingDatabase(t *testing.T) {
+	path := t.TempDir() + "/missing.db"
+	if _, err := OpenSnapshot(path); err == nil {
+		t.Fatal("Expected an error for a missing database")
+	}
+}
@@ -3,19 +3,171 @@ package storage
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"opentrail/internal/entryid"
 	"opentrail/internal/interfaces"
+	"opentrail/internal/metrics"
 	"opentrail/internal/types"
 
 	_ "modernc.org/sqlite"
 )
 
+// sourceIPExpression extracts the receiver-recorded sender address from structured data
+// (entries without structured data store an empty string, which is not valid JSON).
+// Search conditions, and the index created by the baseline migration, must use the exact same
+// expression for SQLite to use the index.
+const sourceIPExpression = "(CASE WHEN json_valid(structured_data) THEN json_extract(structured_data, '$.opentrail.source_ip') END)"
+
+// sourceIPCondition filters entries by the normalized sender address
+const sourceIPCondition = sourceIPExpression + " = ?"
+
+// entryOrder sorts entries newest first. The id breaks ties between entries sharing a timestamp, so
+// that pages of a search neither skip nor repeat entries; the timestamp index already holds it.
+const entryOrder = "timestamp DESC, id DESC"
+
+// filterColumns maps the field names of column filters to their SQL expressions
+var filterColumns = map[string]string{
+	"hostname":          "hostname",
+	"app_name":          "app_name",
+	"proc_id":           "proc_id",
+	"msg_id":            "msg_id",
+	types.SourceIPParam: sourceIPExpression,
+}
+
+// fieldFilterCondition returns the SQL condition and arguments for a field filter. Fields that are not
+// columns match structured data parameters, through their generated column if the field is promoted;
+// the CASE guards json_each against rows without valid JSON.
+func fieldFilterCondition(filter types.FieldFilter, promotions *fieldPromotions) (string, []interface{}) {
+	column, ok := filterColumns[filter.Field]
+	if promoted, isPromoted := promotions.column(filter.Field); !ok && isPromoted {
+		column, ok = `"`+promoted+`"`, true
+	}
+	if ok {
+		if filter.Negate {
+			return column + " IS NOT ?", []interface{}{filter.Value}
+		}
+		return column + " = ?", []interface{}{filter.Value}
+	}
+
+	var match string
+	var args []interface{}
+	if sdID, param, ok := strings.Cut(filter.Field, "."); ok {
+		match = "json_extract(structured_data, ?) = ?"
+		args = []interface{}{jsonPath(sdID, param), filter.Value}
+	} else {
+		match = "EXISTS (SELECT 1 FROM json_each(structured_data) WHERE json_extract(json_each.value, ?) = ?)"
+		args = []interface{}{jsonPath(filter.Field), filter.Value}
+	}
+
+	condition := "COALESCE(CASE WHEN json_valid(structured_data) THEN " + match + " END, 0)"
+	if filter.Negate {
+		condition = "NOT " + condition
+	}
+	return condition, args
+}
+
+// searchConditions returns the WHERE conditions and arguments of a search query's filters; the
+// text search is handled by the caller, as it needs the FTS join
+func searchConditions(query types.SearchQuery, promotions *fieldPromotions) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if query.Facility != nil {
+		conditions = append(conditions, "facility = ?")
+		args = append(args, *query.Facility)
+	}
+
+	if query.Severity != nil {
+		conditions = append(conditions, "severity = ?")
+		args = append(args, *query.Severity)
+	}
+
+	if query.MinSeverity != nil {
+		conditions = append(conditions, "severity <= ?")
+		args = append(args, *query.MinSeverity)
+	}
+
+	if query.MaxSeverity != nil {
+		conditions = append(conditions, "severity >= ?")
+		args = append(args, *query.MaxSeverity)
+	}
+
+	if query.Hostname != "" {
+		conditions = append(conditions, "hostname = ?")
+		args = append(args, query.Hostname)
+	}
+
+	if query.AppName != "" {
+		conditions = append(conditions, "app_name = ?")
+		args = append(args, query.AppName)
+	}
+
+	if query.ProcID != "" {
+		conditions = append(conditions, "proc_id = ?")
+		args = append(args, query.ProcID)
+	}
+
+	if query.MsgID != "" {
+		conditions = append(conditions, "msg_id = ?")
+		args = append(args, query.MsgID)
+	}
+
+	if query.SourceIP != "" {
+		conditions = append(conditions, sourceIPCondition)
+		args = append(args, query.SourceIP)
+	}
+
+	if query.StartTime != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, storedTimestamp(*query.StartTime))
+	}
+
+	if query.EndTime != nil {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, storedTimestamp(*query.EndTime))
+	}
+
+	// Handle structured data query (basic JSON search)
+	if query.StructuredDataQuery != "" {
+		conditions = append(conditions, "structured_data LIKE ?")
+		args = append(args, "%"+query.StructuredDataQuery+"%")
+	}
+
+	for _, filter := range query.Filters {
+		condition, filterArgs := fieldFilterCondition(filter, promotions)
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+
+	return conditions, args
+}
+
+// jsonPath builds a JSON path of quoted object keys, e.g. $."request@32473"."user_id"
+func jsonPath(keys ...string) string {
+	path := "$"
+	for _, key := range keys {
+		path += `."` + strings.ReplaceAll(key, `"`, "") + `"`
+	}
+	return path
+}
+
 // SQLiteStorage implements the LogStorage interface using SQLite with FTS5
 type SQLiteStorage struct {
-	db *sql.DB
+	db          *sql.DB
+	promotions  *fieldPromotions
+	compressRaw atomic.Bool
+	chain       atomic.Pointer[hashChain]
+	recovery    *RecoveryTracker
+	// How long idempotency keys are remembered, zero when they are not
+	idempotencyWindow atomic.Int64
+	// Generator of the uid of new entries, nil when entries have none
+	entryIDs atomic.Pointer[entryid.Generator]
 }
 
 // NewSQLiteStorage creates a new SQLite storage instance
@@ -25,8 +177,8 @@ func NewSQLiteStorage(dbPath string) (interfaces.LogStorage, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	storage := &SQLiteStorage{db: db}
-	if err := storage.configureWALMode(); err != nil {
+	storage := &SQLiteStorage{db: db, recovery: NewRecoveryTracker()}
+	if err := openWAL(dbPath, db, storage.recovery, storage.configureWALMode); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to configure WAL mode: %w", err)
 	}
@@ -36,6 +188,7 @@ func NewSQLiteStorage(dbPath string) (interfaces.LogStorage, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	storage.recovery.finish()
 	return storage, nil
 }
 
@@ -80,99 +233,20 @@ func (s *SQLiteStorage) configureWALMode() error {
 	return nil
 }
 
-// initializeDatabase creates the necessary tables and indexes
+// initializeDatabase migrates the schema and rebuilds the rollups of a database that lacks them
 func (s *SQLiteStorage) initializeDatabase() error {
-	// Remove old database file on startup (hard reset)
-	if _, err := s.db.Exec("DROP TABLE IF EXISTS logs"); err != nil {
-		return fmt.Errorf("failed to drop old logs table: %w", err)
-	}
-	if _, err := s.db.Exec("DROP TABLE IF EXISTS logs_fts"); err != nil {
-		return fmt.Errorf("failed to drop old FTS table: %w", err)
-	}
-
-	// Create main RFC5424 logs table
-	createLogsTable := `
-	CREATE TABLE logs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		
-		-- RFC5424 Header Fields
-		priority INTEGER NOT NULL,
-		facility INTEGER NOT NULL,
-		severity INTEGER NOT NULL,
-		version INTEGER NOT NULL DEFAULT 1,
-		timestamp DATETIME NOT NULL,
-		hostname TEXT,
-		app_name TEXT,
-		proc_id TEXT,
-		msg_id TEXT,
-		
-		-- Structured Data and Message
-		structured_data TEXT, -- JSON string
-		message TEXT NOT NULL,
-		
-		-- System Fields
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	if _, err := s.db.Exec(createLogsTable); err != nil {
-		return fmt.Errorf("failed to create logs table: %w", err)
-	}
-
-	// Create FTS5 virtual table for full-text search on message
-	createFTSTable := `
-	CREATE VIRTUAL TABLE logs_fts USING fts5(
-		message,
-		content='logs',
-		content_rowid='id'
-	);`
-
-	if _, err := s.db.Exec(createFTSTable); err != nil {
-		return fmt.Errorf("failed to create FTS table: %w", err)
-	}
-
-	// Create indexes for efficient RFC5424 field queries
-	indexes := []string{
-		"CREATE INDEX idx_logs_timestamp ON logs(timestamp);",
-		"CREATE INDEX idx_logs_facility ON logs(facility);",
-		"CREATE INDEX idx_logs_severity ON logs(severity);",
-		"CREATE INDEX idx_logs_hostname ON logs(hostname);",
-		"CREATE INDEX idx_logs_app_name ON logs(app_name);",
-		"CREATE INDEX idx_logs_proc_id ON logs(proc_id);",
-		"CREATE INDEX idx_logs_msg_id ON logs(msg_id);",
-		"CREATE INDEX idx_logs_priority ON logs(priority);",
-		"CREATE INDEX idx_logs_created_at ON logs(created_at);",
-		// Composite indexes for common query patterns
-		"CREATE INDEX idx_logs_facility_severity ON logs(facility, severity);",
-		"CREATE INDEX idx_logs_hostname_app_name ON logs(hostname, app_name);",
-		"CREATE INDEX idx_logs_timestamp_severity ON logs(timestamp, severity);",
-	}
-
-	for _, indexSQL := range indexes {
-		if _, err := s.db.Exec(indexSQL); err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
-		}
+	if err := migrateSchema(s.db); err != nil {
+		return err
 	}
-
-	// Create triggers to keep FTS5 table in sync
-	triggers := []string{
-		`CREATE TRIGGER logs_ai AFTER INSERT ON logs BEGIN
-			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
-		END;`,
-		`CREATE TRIGGER logs_ad AFTER DELETE ON logs BEGIN
-			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
-		END;`,
-		`CREATE TRIGGER logs_au AFTER UPDATE ON logs BEGIN
-			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES('delete', old.id, old.message);
-			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
-		END;`,
+	if err := backfillRollups(s.db, s.recovery); err != nil {
+		return err
 	}
 
-	for _, triggerSQL := range triggers {
-		if _, err := s.db.Exec(triggerSQL); err != nil {
-			return fmt.Errorf("failed to create trigger: %w", err)
-		}
+	promotions, err := newFieldPromotions(s.db)
+	if err != nil {
+		return err
 	}
-
+	s.promotions = promotions
 	return nil
 }
 
@@ -189,14 +263,34 @@ func (s *SQLiteStorage) Store(entry *types.LogEntry) error {
 	}
 
 	query := `
-	INSERT INTO logs (priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO logs (priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, raw_message, chain_partition, chain_prev, chain_hash, idempotency_key, uid)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING
 	`
 
-	result, err := s.db.Exec(query,
+	// Entries are linked to the chain head in the order they are inserted
+	chain := s.chain.Load().begin(s.db)
+	defer chain.release()
+	link, err := chain.link(entry, structuredDataJSON)
+	if err != nil {
+		return err
+	}
+	args := append([]interface{}{
 		entry.Priority, entry.Facility, entry.Severity, entry.Version,
-		entry.Timestamp, entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID,
-		structuredDataJSON, entry.Message)
+		storedTimestamp(entry.Timestamp), entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID,
+		structuredDataJSON, entry.Message, rawMessage(entry, s.compressRaw.Load()),
+	}, link.columns()...)
+	window := time.Duration(s.idempotencyWindow.Load())
+	key := idempotencyKey(entry, window)
+	args = append(args, key, entryUID(entry, s.entryIDs.Load()))
+	insert := func(tx *sql.Tx) (sql.Result, error) {
+		return tx.Exec(query, args...)
+	}
+
+	id, err := insertWithRollups(s.db, entry, key, window, insert)
+	if errors.Is(err, interfaces.ErrDuplicateEntry) {
+		return err
+	}
 	if err != nil {
 		// Check if this is a WAL-related error and attempt recovery
 		if s.isWALError(err) {
@@ -204,10 +298,10 @@ func (s *SQLiteStorage) Store(entry *types.LogEntry) error {
 				return fmt.Errorf("failed to store log entry and WAL recovery failed: %w (original: %v)", recoveryErr, err)
 			}
 			// Retry the operation after recovery
-			result, err = s.db.Exec(query,
-				entry.Priority, entry.Facility, entry.Severity, entry.Version,
-				entry.Timestamp, entry.Hostname, entry.AppName, entry.ProcID, entry.MsgID,
-				structuredDataJSON, entry.Message)
+			id, err = insertWithRollups(s.db, entry, key, window, insert)
+			if errors.Is(err, interfaces.ErrDuplicateEntry) {
+				return err
+			}
 			if err != nil {
 				return fmt.Errorf("failed to store log entry after WAL recovery: %w", err)
 			}
@@ -216,104 +310,23 @@ func (s *SQLiteStorage) Store(entry *types.LogEntry) error {
 		}
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get inserted ID: %w", err)
-	}
-
 	entry.ID = id
+	chain.commit()
+	metrics.GetIngestLatency().RecordCommitted([]*types.LogEntry{entry}, time.Now())
 	return nil
 }
 
 // Search retrieves log entries based on the provided query
 func (s *SQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntry, error) {
-	var conditions []string
-	var args []interface{}
-
-	baseQuery := `SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at FROM logs`
-
-	// Handle full-text search
-	if query.Text != "" {
-		baseQuery = `
-		SELECT l.id, l.priority, l.facility, l.severity, l.version, l.timestamp, l.hostname, l.app_name, l.proc_id, l.msg_id, l.structured_data, l.message, l.created_at
-		FROM logs l 
-		JOIN logs_fts fts ON l.id = fts.rowid 
-		WHERE logs_fts MATCH ?`
-		args = append(args, query.Text)
-	}
-
-	// Add RFC5424 filters
-	if query.Facility != nil {
-		conditions = append(conditions, "facility = ?")
-		args = append(args, *query.Facility)
-	}
-
-	if query.Severity != nil {
-		conditions = append(conditions, "severity = ?")
-		args = append(args, *query.Severity)
-	}
-
-	if query.MinSeverity != nil {
-		conditions = append(conditions, "severity <= ?")
-		args = append(args, *query.MinSeverity)
-	}
-
-	if query.Hostname != "" {
-		conditions = append(conditions, "hostname = ?")
-		args = append(args, query.Hostname)
-	}
-
-	if query.AppName != "" {
-		conditions = append(conditions, "app_name = ?")
-		args = append(args, query.AppName)
-	}
-
-	if query.ProcID != "" {
-		conditions = append(conditions, "proc_id = ?")
-		args = append(args, query.ProcID)
-	}
-
-	if query.MsgID != "" {
-		conditions = append(conditions, "msg_id = ?")
-		args = append(args, query.MsgID)
-	}
-
-	if query.StartTime != nil {
-		conditions = append(conditions, "timestamp >= ?")
-		args = append(args, query.StartTime)
-	}
-
-	if query.EndTime != nil {
-		conditions = append(conditions, "timestamp <= ?")
-		args = append(args, query.EndTime)
-	}
-
-	// Handle structured data query (basic JSON search)
-	if query.StructuredDataQuery != "" {
-		conditions = append(conditions, "structured_data LIKE ?")
-		args = append(args, "%"+query.StructuredDataQuery+"%")
-	}
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		if query.Text != "" {
-			baseQuery += " AND " + strings.Join(conditions, " AND ")
-		} else {
-			baseQuery += " WHERE " + strings.Join(conditions, " AND ")
-		}
-	}
-
-	// Add ordering and limits
-	baseQuery += " ORDER BY timestamp DESC"
-
-	if query.Limit > 0 {
-		baseQuery += " LIMIT ?"
-		args = append(args, query.Limit)
+	columns, err := searchColumns(query.Fields)
+	if err != nil {
+		return nil, err
 	}
-
-	if query.Offset > 0 {
-		baseQuery += " OFFSET ?"
-		args = append(args, query.Offset)
+	// Text searches report where the terms matched the message
+	highlight := query.Text != "" && slices.Contains(columns, "message")
+	baseQuery, args, err := searchStatement(s.db, query, s.promotions, columns, highlight)
+	if err != nil {
+		return nil, err
 	}
 
 	rows, err := s.db.Query(baseQuery, args...)
@@ -324,24 +337,10 @@ func (s *SQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntry, erro
 
 	var entries []*types.LogEntry
 	for rows.Next() {
-		entry := &types.LogEntry{}
-		var structuredDataJSON sql.NullString
-
-		err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
-			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
-			&structuredDataJSON, &entry.Message, &entry.CreatedAt)
+		entry, err := scanSearchRow(rows, columns, highlight)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan log entry: %w", err)
+			return nil, err
 		}
-
-		// Parse structured data JSON
-		if structuredDataJSON.Valid && structuredDataJSON.String != "" {
-			var structuredData map[string]interface{}
-			if err := json.Unmarshal([]byte(structuredDataJSON.String), &structuredData); err == nil {
-				entry.StructuredData = structuredData
-			}
-		}
-
 		entries = append(entries, entry)
 	}
 
@@ -355,9 +354,9 @@ func (s *SQLiteStorage) Search(query types.SearchQuery) ([]*types.LogEntry, erro
 // GetRecent retrieves the most recent log entries up to the specified limit
 func (s *SQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 	query := `
-	SELECT id, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at
+	SELECT id, uid, priority, facility, severity, version, timestamp, hostname, app_name, proc_id, msg_id, structured_data, message, created_at
 	FROM logs 
-	ORDER BY timestamp DESC 
+	ORDER BY ` + entryOrder + `
 	LIMIT ?
 	`
 
@@ -370,14 +369,15 @@ func (s *SQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 	var entries []*types.LogEntry
 	for rows.Next() {
 		entry := &types.LogEntry{}
-		var structuredDataJSON sql.NullString
+		var uid, structuredDataJSON sql.NullString
 
-		err := rows.Scan(&entry.ID, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
+		err := rows.Scan(&entry.ID, &uid, &entry.Priority, &entry.Facility, &entry.Severity, &entry.Version,
 			&entry.Timestamp, &entry.Hostname, &entry.AppName, &entry.ProcID, &entry.MsgID,
 			&structuredDataJSON, &entry.Message, &entry.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan log entry: %w", err)
 		}
+		entry.UID = uid.String
 
 		// Parse structured data JSON
 		if structuredDataJSON.Valid && structuredDataJSON.String != "" {
@@ -399,27 +399,38 @@ func (s *SQLiteStorage) GetRecent(limit int) ([]*types.LogEntry, error) {
 
 // Cleanup removes log entries older than the specified retention period
 func (s *SQLiteStorage) Cleanup(retentionDays int) error {
+	_, err := s.CleanupExpired(retentionDays)
+	return err
+}
+
+// CleanupExpired removes log entries older than the specified retention period and returns how
+// many were removed
+func (s *SQLiteStorage) CleanupExpired(retentionDays int) (int64, error) {
 	cutoffTime := time.Now().AddDate(0, 0, -retentionDays)
 
 	query := "DELETE FROM logs WHERE timestamp < ?"
-	result, err := s.db.Exec(query, cutoffTime)
+	result, err := s.db.Exec(query, storedTimestamp(cutoffTime))
 	if err != nil {
-		return fmt.Errorf("failed to cleanup old logs: %w", err)
+		return 0, fmt.Errorf("failed to cleanup old logs: %w", err)
+	}
+
+	if err := cleanupRollups(s.db, cutoffTime); err != nil {
+		return 0, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get cleanup result: %w", err)
+		return 0, fmt.Errorf("failed to get cleanup result: %w", err)
 	}
 
 	// Run VACUUM to reclaim space after cleanup
 	if rowsAffected > 0 {
 		if _, err := s.db.Exec("VACUUM"); err != nil {
-			return fmt.Errorf("failed to vacuum database: %w", err)
+			return rowsAffected, fmt.Errorf("failed to vacuum database: %w", err)
 		}
 	}
 
-	return nil
+	return rowsAffected, nil
 }
 
 // isWALError checks if an error is related to WAL corruption or issues
@@ -480,6 +491,9 @@ func (s *SQLiteStorage) recoverFromWALCorruption() error {
 
 // Close closes the database connection with proper WAL cleanup
 func (s *SQLiteStorage) Close() error {
+	// Stop background index builds before the connection goes away
+	s.promotions.stop()
+
 	// Perform final checkpoint before closing
 	if err := s.checkpointWAL(); err != nil {
 		// Log the error but don't fail the close operation
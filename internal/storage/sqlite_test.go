@@ -3,9 +3,12 @@ package storage
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"opentrail/internal/metrics"
 	"opentrail/internal/types"
 )
 
@@ -572,6 +575,59 @@ func TestSQLiteStorage_Search_LimitAndOffset(t *testing.T) {
 	}
 }
 
+func TestSQLiteStorage_Search_PagesOfIdenticalTimestamps(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	timestamp := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		entry := &types.LogEntry{
+			Priority:  134,
+			Facility:  16,
+			Severity:  6,
+			Version:   1,
+			Timestamp: timestamp,
+			Hostname:  "test-host",
+			AppName:   "test-app",
+			Message:   fmt.Sprintf("Message %d", i),
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	// Entries sharing a timestamp come newest insert first, and pages neither skip nor repeat them
+	var ids []int64
+	for offset := 0; offset < 7; offset += 3 {
+		page, err := storage.Search(types.SearchQuery{Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatalf("Failed to search logs: %v", err)
+		}
+		for _, entry := range page {
+			ids = append(ids, entry.ID)
+		}
+	}
+	if len(ids) != 7 {
+		t.Fatalf("Expected 7 entries over all pages, got %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] >= ids[i-1] {
+			t.Errorf("Expected ids in descending order, got %v", ids)
+			break
+		}
+	}
+
+	recent, err := storage.GetRecent(7)
+	if err != nil {
+		t.Fatalf("Failed to get recent logs: %v", err)
+	}
+	for i, entry := range recent {
+		if entry.ID != ids[i] {
+			t.Errorf("Expected GetRecent in the order of Search %v, got entry %d at %d", ids, entry.ID, i)
+		}
+	}
+}
+
 func TestSQLiteStorage_Search_RFC5424Filters(t *testing.T) {
 	storage := setupTestStorage(t)
 	defer cleanupTestStorage(storage)
@@ -843,6 +899,130 @@ func TestSQLiteStorage_Cleanup(t *testing.T) {
 
 // Helper functions for testing
 
+func TestSQLiteStorage_Search_SourceIP(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	for _, sourceIP := range []string{"192.0.2.10", "2001:db8::1", "192.0.2.10"} {
+		entry := &types.LogEntry{
+			Priority:  134,
+			Facility:  16,
+			Severity:  6,
+			Version:   1,
+			Timestamp: time.Now(),
+			Hostname:  "localhost",
+			Message:   "message from " + sourceIP,
+			CreatedAt: time.Now(),
+		}
+		entry.SetMetadata(types.SourceIPParam, sourceIP)
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	results, err := storage.Search(types.SearchQuery{SourceIP: "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("Failed to search logs: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results for source 192.0.2.10, got %d", len(results))
+	}
+
+	results, err = storage.Search(types.SearchQuery{SourceIP: "2001:db8::1", Text: "message"})
+	if err != nil {
+		t.Fatalf("Failed to search logs: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result for source 2001:db8::1, got %d", len(results))
+	}
+}
+
+func TestSQLiteStorage_Search_FieldFilters(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	entries := []struct {
+		hostname string
+		severity int
+		sd       map[string]interface{}
+	}{
+		{"web01", 3, map[string]interface{}{"request@32473": map[string]interface{}{"user_id": "42"}}},
+		{"web02", 6, map[string]interface{}{"session": map[string]interface{}{"user_id": "42"}}},
+		{"web03", 7, map[string]interface{}{"request@32473": map[string]interface{}{"user_id": "7"}}},
+		{"web04", 4, nil},
+	}
+	for _, e := range entries {
+		entry := &types.LogEntry{
+			Priority:       128 + e.severity,
+			Facility:       16,
+			Severity:       e.severity,
+			Version:        1,
+			Timestamp:      time.Now(),
+			Hostname:       e.hostname,
+			StructuredData: e.sd,
+			Message:        "filter test",
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query types.SearchQuery
+		want  []string
+	}{
+		{"any element", types.SearchQuery{Filters: []types.FieldFilter{{Field: "user_id", Value: "42"}}}, []string{"web01", "web02"}},
+		{"specific element", types.SearchQuery{Filters: []types.FieldFilter{{Field: "request@32473.user_id", Value: "42"}}}, []string{"web01"}},
+		{"negated parameter", types.SearchQuery{Filters: []types.FieldFilter{{Field: "user_id", Value: "42", Negate: true}}}, []string{"web03", "web04"}},
+		{"negated column", types.SearchQuery{Filters: []types.FieldFilter{{Field: "hostname", Value: "web03", Negate: true}}}, []string{"web01", "web02", "web04"}},
+		{"max severity", types.SearchQuery{MaxSeverity: &entries[3].severity, Text: "filter"}, []string{"web02", "web03", "web04"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := storage.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to search logs: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Hostname)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSQLiteStorage_RecordsIngestLatency(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	latency := metrics.GetIngestLatency()
+	latency.Reset()
+	defer latency.Reset()
+
+	received := time.Now()
+	entry := &types.LogEntry{
+		Version: 1, Priority: 134, Severity: 6, Hostname: "latency-host", Message: "late",
+		Timestamp: received.Add(-time.Minute), ReceivedAt: received,
+	}
+	if err := storage.Store(entry); err != nil {
+		t.Fatalf("Failed to store entry: %v", err)
+	}
+
+	snapshot := latency.Snapshot()
+	if len(snapshot.Sources) != 1 || snapshot.Sources[0].Source != "latency-host" {
+		t.Fatalf("Expected the committed entry to be recorded, got %+v", snapshot.Sources)
+	}
+	if lag := snapshot.Sources[0].SenderLag.MaxSeconds; lag < 60 || lag > 61 {
+		t.Errorf("Expected a sender lag of about a minute, got %v", lag)
+	}
+}
+
 func setupTestStorage(t *testing.T) *SQLiteStorage {
 	tmpFile, err := os.CreateTemp("", "test_*.db")
 	if err != nil {
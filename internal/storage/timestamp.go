@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// storedTimestampLayout is the form entry timestamps are stored in: UTC with all nine fractional
+// digits, so text comparison and ORDER BY follow time order whatever offset an entry arrived with.
+// The driver reads it back as a time.Time.
+const storedTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// timestampMigrationBatch is the number of entries rewritten per transaction when migrating
+const timestampMigrationBatch = 1000
+
+// storedTimestamp formats t as stored in, and compared against, the timestamp column of logs
+func storedTimestamp(t time.Time) string {
+	return t.UTC().Format(storedTimestampLayout)
+}
+
+// migrateTimestamps rewrites timestamps stored before they were kept in storedTimestampLayout. Those
+// were written as Go's time.String in the zone they arrived with, which sorts wrongly across offsets.
+// Values the driver cannot read as a time are left alone.
+func migrateTimestamps(db *sql.DB) error {
+	lastID := int64(0)
+	for {
+		rows, err := db.Query(`SELECT id, timestamp FROM logs
+		WHERE id > ? AND (length(timestamp) <> 30 OR substr(timestamp, 11, 1) <> 'T' OR substr(timestamp, 30, 1) <> 'Z')
+		ORDER BY id LIMIT ?`, lastID, timestampMigrationBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read timestamps to migrate: %w", err)
+		}
+		updates := make(map[int64]string)
+		count := 0
+		for rows.Next() {
+			var id int64
+			var value interface{}
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan timestamp to migrate: %w", err)
+			}
+			count++
+			lastID = id
+			if timestamp, ok := value.(time.Time); ok {
+				updates[id] = storedTimestamp(timestamp)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read timestamps to migrate: %w", err)
+		}
+		if count == 0 {
+			return nil
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin timestamp migration: %w", err)
+		}
+		for id, timestamp := range updates {
+			if _, err := tx.Exec("UPDATE logs SET timestamp = ? WHERE id = ?", timestamp, id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to migrate timestamp of entry %d: %w", id, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit timestamp migration: %w", err)
+		}
+	}
+}
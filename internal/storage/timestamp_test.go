@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_TimestampPrecisionAndOrder(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	ist := time.FixedZone("IST", 5*3600+30*60)
+	pdt := time.FixedZone("PDT", -7*3600)
+	// Stored out of order and in different zones: "16:..." in IST is earlier than "03:..." in PDT
+	timestamps := []time.Time{
+		base.Add(3 * time.Nanosecond).In(pdt),
+		base.Add(1 * time.Nanosecond).In(ist),
+		base.Add(2 * time.Nanosecond),
+		base.Add(-time.Hour).In(pdt),
+	}
+	for i, timestamp := range timestamps {
+		entry := &types.LogEntry{Priority: 14, Facility: 1, Severity: 6, Version: 1, Timestamp: timestamp, Hostname: "host", AppName: "trace", Message: "span"}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry %d: %v", i, err)
+		}
+	}
+
+	start := base
+	results, err := storage.Search(types.SearchQuery{StartTime: &start, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	expected := []time.Time{base.Add(3 * time.Nanosecond), base.Add(2 * time.Nanosecond), base.Add(1 * time.Nanosecond)}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d entries from %v, got %d", len(expected), start, len(results))
+	}
+	for i, result := range results {
+		if !result.Timestamp.Equal(expected[i]) {
+			t.Errorf("Entry %d: expected %v, got %v", i, expected[i], result.Timestamp)
+		}
+	}
+}
+
+func TestMigrateTimestamps(t *testing.T) {
+	storage := setupTestStorage(t)
+	defer cleanupTestStorage(storage)
+
+	// Timestamps bound as a time.Time were written by the driver as time.String
+	legacy := time.Date(2024, 3, 1, 15, 30, 0, 123456789, time.FixedZone("IST", 5*3600+30*60))
+	if _, err := storage.db.Exec("INSERT INTO logs (priority, facility, severity, timestamp, hostname, app_name, proc_id, msg_id, message) VALUES (14, 1, 6, ?, 'host', 'app', '', '', 'legacy')", legacy); err != nil {
+		t.Fatalf("Failed to insert legacy entry: %v", err)
+	}
+	if err := migrateTimestamps(storage.db); err != nil {
+		t.Fatalf("migrateTimestamps failed: %v", err)
+	}
+
+	var stored string
+	if err := storage.db.QueryRow("SELECT CAST(timestamp AS TEXT) FROM logs WHERE message = 'legacy'").Scan(&stored); err != nil {
+		t.Fatalf("Failed to read migrated timestamp: %v", err)
+	}
+	if stored != "2024-03-01T10:00:00.123456789Z" {
+		t.Errorf("Expected the timestamp in UTC with nanoseconds, got %q", stored)
+	}
+
+	results, err := storage.Search(types.SearchQuery{Text: "legacy", Limit: 1})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected the migrated entry to be found, got %v, %v", results, err)
+	}
+	if !results[0].Timestamp.Equal(legacy) {
+		t.Errorf("Expected %v after migration, got %v", legacy, results[0].Timestamp)
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+// entryBytesExpression is the stored size of an entry's fields, messages and raw message
+const entryBytesExpression = `LENGTH(CAST(message AS BLOB)) + COALESCE(LENGTH(CAST(raw_message AS BLOB)), 0) +
+	COALESCE(LENGTH(CAST(structured_data AS BLOB)), 0) + COALESCE(LENGTH(CAST(hostname AS BLOB)), 0) +
+	COALESCE(LENGTH(CAST(app_name AS BLOB)), 0) + COALESCE(LENGTH(CAST(proc_id AS BLOB)), 0) +
+	COALESCE(LENGTH(CAST(msg_id AS BLOB)), 0)`
+
+// queryStorageUsage measures the database files and the entries stored per day. The capacity
+// projection is left to the caller, which knows the configured limit and retention
+func queryStorageUsage(db *sql.DB) (*types.StorageUsage, error) {
+	usage := &types.StorageUsage{Days: []types.DayUsage{}}
+
+	var pageSize, pageCount, freePages int64
+	for pragma, value := range map[string]*int64{"page_size": &pageSize, "page_count": &pageCount, "freelist_count": &freePages} {
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(value); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma, err)
+		}
+	}
+	usage.DatabaseBytes = pageCount * pageSize
+	usage.FreeBytes = freePages * pageSize
+
+	// In-memory databases have no file
+	var seq int
+	var name, file string
+	if err := db.QueryRow("PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return nil, fmt.Errorf("failed to locate database file: %w", err)
+	}
+	if file != "" {
+		if info, err := os.Stat(file); err == nil {
+			usage.DatabaseBytes = info.Size()
+		}
+		if info, err := os.Stat(file + "-wal"); err == nil {
+			usage.WALBytes = info.Size()
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to stat WAL: %w", err)
+		}
+		if free, err := diskFree(filepath.Dir(file)); err == nil {
+			usage.DiskFreeBytes = free
+		}
+	}
+
+	if err := db.QueryRow("SELECT COALESCE(SUM(LENGTH(block)), 0) FROM logs_fts_data").Scan(&usage.FTSBytes); err != nil {
+		return nil, fmt.Errorf("failed to measure full-text index: %w", err)
+	}
+
+	// The rollups already count the entries per minute, so only the sizes scan the logs table
+	rows, err := db.Query(`SELECT bucket - bucket % 86400 AS day, SUM(count) FROM log_rollups
+		GROUP BY day HAVING SUM(count) > 0 ORDER BY day`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count entries per day: %w", err)
+	}
+	for rows.Next() {
+		var day, count int64
+		if err := rows.Scan(&day, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan day: %w", err)
+		}
+		usage.Days = append(usage.Days, types.DayUsage{
+			Day:  time.Unix(day, 0).UTC().Format(types.DayLayout),
+			Rows: count,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count entries per day: %w", err)
+	}
+
+	for i := range usage.Days {
+		start, _ := time.Parse(types.DayLayout, usage.Days[i].Day)
+		if err := db.QueryRow("SELECT COALESCE(SUM("+entryBytesExpression+"), 0) FROM logs WHERE timestamp >= ? AND timestamp < ?",
+			storedTimestamp(start), storedTimestamp(start.Add(day))).Scan(&usage.Days[i].Bytes); err != nil {
+			return nil, fmt.Errorf("failed to measure entries of %s: %w", usage.Days[i].Day, err)
+		}
+	}
+	return usage, nil
+}
+
+// StorageUsage measures the database files and the entries stored per day
+func (s *SQLiteStorage) StorageUsage() (*types.StorageUsage, error) {
+	return queryStorageUsage(s.db)
+}
+
+// StorageUsage measures the database files and the entries stored per day
+func (s *BatchedSQLiteStorage) StorageUsage() (*types.StorageUsage, error) {
+	return queryStorageUsage(s.db)
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_StorageUsage(t *testing.T) {
+	path := t.TempDir() + "/usage.db"
+	created, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+	defer storage.Close()
+
+	base := time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)
+	for i, message := range []string{"first day", "second day", "second day again"} {
+		entry := &types.LogEntry{
+			Severity:  6,
+			Version:   1,
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			Hostname:  "web",
+			Message:   message,
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+
+	usage, err := storage.StorageUsage()
+	if err != nil {
+		t.Fatalf("StorageUsage failed: %v", err)
+	}
+	if usage.DatabaseBytes == 0 || usage.FTSBytes == 0 {
+		t.Errorf("Expected database and full-text index sizes, got %+v", usage)
+	}
+	want := []types.DayUsage{
+		{Day: "2024-01-02", Rows: 1, Bytes: int64(len("first day") + len("web"))},
+		{Day: "2024-01-03", Rows: 2, Bytes: int64(len("second day") + len("second day again") + 2*len("web"))},
+	}
+	if len(usage.Days) != len(want) {
+		t.Fatalf("Expected %d days, got %+v", len(want), usage.Days)
+	}
+	for i, day := range want {
+		if usage.Days[i] != day {
+			t.Errorf("Expected %+v, got %+v", day, usage.Days[i])
+		}
+	}
+}
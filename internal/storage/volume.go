@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+const upsertIngestedVolume = `
+INSERT INTO log_volume (day, app_name, hostname, tenant, ingested_entries, ingested_bytes)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (day, app_name, hostname, tenant) DO UPDATE SET
+	ingested_entries = ingested_entries + excluded.ingested_entries,
+	ingested_bytes = ingested_bytes + excluded.ingested_bytes`
+
+// tenantExpression extracts the tenant the receiver routed an entry to from structured data
+const tenantExpression = "(CASE WHEN json_valid(structured_data) THEN json_extract(structured_data, '$.opentrail.tenant') END)"
+
+// volumeColumns maps the volume groupings to their log_volume columns
+var volumeColumns = map[string]string{
+	types.VolumeByAppName:  "app_name",
+	types.VolumeByHostname: "hostname",
+	types.VolumeByTenant:   "tenant",
+}
+
+// volumeKey identifies a single log_volume row
+type volumeKey struct {
+	day      int64
+	appName  string
+	hostname string
+	tenant   string
+}
+
+// volumeCount is the ingested volume accumulated for a log_volume row
+type volumeCount struct {
+	entries int64
+	bytes   int64
+}
+
+// ingest counts a newly stored entry towards the volume ingested on the day it was received. Its
+// size is that of the message as received, or of what was kept of it if that is unknown.
+func (c *rollupCounts) ingest(entry *types.LogEntry) {
+	received := entry.ReceivedAt
+	if received.IsZero() {
+		received = time.Now()
+	}
+	size := entry.ReceivedBytes
+	if size == 0 {
+		size = len(entry.Raw)
+	}
+	if size == 0 {
+		size = len(entry.Message)
+	}
+
+	key := volumeKey{
+		day:      received.UTC().Truncate(day).Unix(),
+		appName:  entry.AppName,
+		hostname: entry.Hostname,
+		tenant:   entry.Metadata(types.TenantParam),
+	}
+	count := c.volume[key]
+	count.entries++
+	count.bytes += int64(size)
+	c.volume[key] = count
+}
+
+// measureVolume replaces the stored volume of the day of now with what each app, host and tenant
+// currently occupies in the logs table
+func measureVolume(db *sql.DB, now time.Time) error {
+	today := now.UTC().Truncate(day).Unix()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin volume measurement: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Groups whose entries were all removed since the last measurement occupy nothing
+	if _, err := tx.Exec("UPDATE log_volume SET stored_entries = 0, stored_bytes = 0 WHERE day = ?", today); err != nil {
+		return fmt.Errorf("failed to reset stored volume: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO log_volume (day, app_name, hostname, tenant, stored_entries, stored_bytes)
+		SELECT ?, COALESCE(app_name, ''), COALESCE(hostname, ''), COALESCE(`+tenantExpression+`, ''), COUNT(*), SUM(`+entryBytesExpression+`)
+		FROM logs WHERE true GROUP BY 2, 3, 4
+		ON CONFLICT (day, app_name, hostname, tenant) DO UPDATE SET
+			stored_entries = excluded.stored_entries, stored_bytes = excluded.stored_bytes`, today); err != nil {
+		return fmt.Errorf("failed to measure stored volume: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM log_volume WHERE day = ? AND ingested_entries = 0 AND stored_entries = 0", today); err != nil {
+		return fmt.Errorf("failed to delete empty volume rows: %w", err)
+	}
+	return tx.Commit()
+}
+
+// queryVolume reports the volume per day and in total of each value of the query's field
+func queryVolume(db *sql.DB, query types.VolumeQuery) (*types.VolumeReport, error) {
+	column, ok := volumeColumns[query.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported group_by: %s", query.GroupBy)
+	}
+	start := query.StartDay.UTC().Truncate(day)
+	end := query.EndDay.UTC().Truncate(day)
+
+	rows, err := db.Query(`SELECT day, `+column+`, SUM(ingested_entries), SUM(ingested_bytes), SUM(stored_entries), SUM(stored_bytes)
+		FROM log_volume WHERE day >= ? AND day <= ? GROUP BY day, `+column+` ORDER BY day, `+column,
+		start.Unix(), end.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query volume: %w", err)
+	}
+	defer rows.Close()
+
+	report := &types.VolumeReport{
+		GroupBy:  query.GroupBy,
+		StartDay: start.Format(types.DayLayout),
+		EndDay:   end.Format(types.DayLayout),
+		Days:     []types.VolumeRow{},
+		Totals:   []types.VolumeRow{},
+	}
+	totals := make(map[string]int)
+	for rows.Next() {
+		var dayStart int64
+		var row types.VolumeRow
+		if err := rows.Scan(&dayStart, &row.Group, &row.IngestedEntries, &row.IngestedBytes, &row.StoredEntries, &row.StoredBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan volume: %w", err)
+		}
+		row.Day = time.Unix(dayStart, 0).UTC().Format(types.DayLayout)
+		row.AvgIngestedBytes = averageSize(row.IngestedBytes, row.IngestedEntries)
+		report.Days = append(report.Days, row)
+
+		i, ok := totals[row.Group]
+		if !ok {
+			i = len(report.Totals)
+			totals[row.Group] = i
+			report.Totals = append(report.Totals, types.VolumeRow{Group: row.Group})
+		}
+		total := &report.Totals[i]
+		total.IngestedEntries += row.IngestedEntries
+		total.IngestedBytes += row.IngestedBytes
+		// Days are in order, so the last measured one wins
+		if row.StoredEntries > 0 {
+			total.StoredEntries, total.StoredBytes = row.StoredEntries, row.StoredBytes
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read volume: %w", err)
+	}
+	for i := range report.Totals {
+		total := &report.Totals[i]
+		total.AvgIngestedBytes = averageSize(total.IngestedBytes, total.IngestedEntries)
+	}
+
+	sort.SliceStable(report.Totals, func(i, j int) bool {
+		a, b := report.Totals[i], report.Totals[j]
+		if a.IngestedBytes != b.IngestedBytes {
+			return a.IngestedBytes > b.IngestedBytes
+		}
+		return a.StoredBytes > b.StoredBytes
+	})
+	return report, nil
+}
+
+// averageSize returns the average size of entries, 0 when there are none
+func averageSize(bytes, entries int64) int64 {
+	if entries == 0 {
+		return 0
+	}
+	return bytes / entries
+}
+
+// MeasureVolume records what each app, host and tenant occupies in storage on the day of now
+func (s *SQLiteStorage) MeasureVolume(now time.Time) error {
+	return measureVolume(s.db, now)
+}
+
+// MeasureVolume records what each app, host and tenant occupies in storage on the day of now
+func (s *BatchedSQLiteStorage) MeasureVolume(now time.Time) error {
+	return measureVolume(s.db, now)
+}
+
+// Volume reports the volume ingested and stored per day by app, host or tenant
+func (s *SQLiteStorage) Volume(query types.VolumeQuery) (*types.VolumeReport, error) {
+	return queryVolume(s.db, query)
+}
+
+// Volume reports the volume ingested and stored per day by app, host or tenant
+func (s *BatchedSQLiteStorage) Volume(query types.VolumeQuery) (*types.VolumeReport, error) {
+	return queryVolume(s.db, query)
+}
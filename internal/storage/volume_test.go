@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"opentrail/internal/types"
+)
+
+func TestSQLiteStorage_Volume(t *testing.T) {
+	created, err := NewSQLiteStorage(t.TempDir() + "/volume.db")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	storage := created.(*SQLiteStorage)
+	defer storage.Close()
+
+	first := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+	for _, tc := range []struct {
+		received time.Time
+		app      string
+		tenant   string
+		size     int
+	}{
+		{first, "api", "acme", 100},
+		{first, "api", "", 50},
+		{first, "nginx", "acme", 400},
+		{second, "api", "acme", 100},
+	} {
+		entry := &types.LogEntry{
+			Severity:      6,
+			Version:       1,
+			Timestamp:     tc.received,
+			Hostname:      "web",
+			AppName:       tc.app,
+			Message:       "message",
+			ReceivedAt:    tc.received,
+			ReceivedBytes: tc.size,
+		}
+		if tc.tenant != "" {
+			entry.SetMetadata(types.TenantParam, tc.tenant)
+		}
+		if err := storage.Store(entry); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+	}
+	if err := storage.MeasureVolume(second); err != nil {
+		t.Fatalf("MeasureVolume failed: %v", err)
+	}
+
+	report, err := storage.Volume(types.VolumeQuery{GroupBy: types.VolumeByAppName, StartDay: first, EndDay: second})
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	if report.StartDay != "2024-01-02" || report.EndDay != "2024-01-03" {
+		t.Errorf("Unexpected days %s to %s", report.StartDay, report.EndDay)
+	}
+	// Each entry stores its message, hostname and app, and the tenant in its structured data
+	entryBytes := int64(len("message") + len("web"))
+	tenantBytes := int64(len(`{"opentrail":{"tenant":"acme"}}`))
+	days := []types.VolumeRow{
+		{Day: "2024-01-02", Group: "api", IngestedEntries: 2, IngestedBytes: 150, AvgIngestedBytes: 75},
+		{Day: "2024-01-02", Group: "nginx", IngestedEntries: 1, IngestedBytes: 400, AvgIngestedBytes: 400},
+		{Day: "2024-01-03", Group: "api", IngestedEntries: 1, IngestedBytes: 100, AvgIngestedBytes: 100, StoredEntries: 3, StoredBytes: 3*(entryBytes+3) + 2*tenantBytes},
+		{Day: "2024-01-03", Group: "nginx", StoredEntries: 1, StoredBytes: entryBytes + 5 + tenantBytes},
+	}
+	if len(report.Days) != len(days) {
+		t.Fatalf("Expected %d days, got %+v", len(days), report.Days)
+	}
+	for i, row := range days {
+		if report.Days[i] != row {
+			t.Errorf("Expected %+v, got %+v", row, report.Days[i])
+		}
+	}
+	if len(report.Totals) != 2 || report.Totals[0].Group != "nginx" || report.Totals[1].IngestedBytes != 250 ||
+		report.Totals[1].AvgIngestedBytes != 83 || report.Totals[1].StoredEntries != 3 {
+		t.Errorf("Expected nginx to be the noisiest, got %+v", report.Totals)
+	}
+
+	report, err = storage.Volume(types.VolumeQuery{GroupBy: types.VolumeByTenant, StartDay: first, EndDay: first})
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	if len(report.Totals) != 2 || report.Totals[0].Group != "acme" || report.Totals[0].IngestedBytes != 500 ||
+		report.Totals[1].Group != "" || report.Totals[1].IngestedBytes != 50 {
+		t.Errorf("Unexpected tenant totals %+v", report.Totals)
+	}
+
+	// Deleted entries no longer count as stored at the next measurement, but remain ingested
+	if _, err := storage.db.Exec("DELETE FROM logs WHERE app_name = 'nginx'"); err != nil {
+		t.Fatalf("Failed to delete entries: %v", err)
+	}
+	if err := storage.MeasureVolume(second); err != nil {
+		t.Fatalf("MeasureVolume failed: %v", err)
+	}
+	report, err = storage.Volume(types.VolumeQuery{GroupBy: types.VolumeByAppName, StartDay: second, EndDay: second})
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	if len(report.Days) != 1 || report.Days[0].Group != "api" {
+		t.Errorf("Expected the emptied group to be dropped, got %+v", report.Days)
+	}
+
+	if _, err := storage.Volume(types.VolumeQuery{GroupBy: "severity"}); err == nil {
+		t.Error("Expected an unsupported grouping to be rejected")
+	}
+}
@@ -0,0 +1,44 @@
+package types
+
+import "time"
+
+// Roles of the users and API tokens of the web interface and API
+const (
+	RoleAdmin  = "admin"
+	RoleReader = "reader"
+)
+
+const (
+	// MinUserPassword is the shortest password accepted for a user, as in the setup wizard
+	MinUserPassword = 8
+	// MaxAccountName bounds the length of usernames and API token names
+	MaxAccountName = 64
+	// MaxAPITokenTTL bounds how long an API token stays valid
+	MaxAPITokenTTL = 365 * 24 * time.Hour
+)
+
+// User is an account of the web interface and API managed through the admin API, alongside the
+// admin and reader accounts of the server's configuration. Its password is only kept hashed.
+type User struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// APIToken lets scripts and ingestion clients call the API with an Authorization: Bearer header
+// instead of a password. Only a hash of the token is kept, so the token itself is shown once, when
+// it is created.
+type APIToken struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// Token is only set in the response creating the token
+	Token string `json:"token,omitempty"`
+	// CreatedBy is the user who created the token, empty without authentication
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is nil for tokens that do not expire
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// ArchiveResult is a page of entries found in the archived dumps of entries past retention
+type ArchiveResult struct {
+	Entries []*LogEntry
+	// Partitions is the number of dump files downloaded, Loaded the entries they held in the time
+	// range and Bytes their size as downloaded
+	Partitions int
+	Loaded     int64
+	Bytes      int64
+	// Elapsed is how long downloading and searching the partitions took
+	Elapsed time.Duration
+}
@@ -0,0 +1,30 @@
+package types
+
+import "time"
+
+// ChainPartitionLayout formats the partition of a hash-chained entry: the UTC day of its timestamp
+const ChainPartitionLayout = "2006-01-02"
+
+// ChainPartition is the verification result of one hash chain partition
+type ChainPartition struct {
+	Partition string `json:"partition"`
+	Entries   int64  `json:"entries"`
+	FirstID   int64  `json:"first_id"`
+	LastID    int64  `json:"last_id"`
+	// Anchor is the previous hash recorded by the first remaining entry, set when the start
+	// of the chain was removed, for example by retention
+	Anchor string `json:"anchor,omitempty"`
+	// Head is the hash of the last entry; recording it elsewhere lets later removals be detected too
+	Head     string `json:"head"`
+	Valid    bool   `json:"valid"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// ChainReport is the result of verifying the hash chains of stored entries
+type ChainReport struct {
+	Valid      bool             `json:"valid"`
+	Entries    int64            `json:"entries"`
+	Partitions []ChainPartition `json:"partitions"`
+	VerifiedAt time.Time        `json:"verified_at"`
+}
@@ -0,0 +1,28 @@
+package types
+
+// Modes of a checkpoint of the write-ahead log
+const (
+	// CheckpointPassive writes what it can without waiting for readers or writers
+	CheckpointPassive = "passive"
+	// CheckpointRestart waits for readers so that writers start over at the beginning of the log
+	CheckpointRestart = "restart"
+	// CheckpointTruncate also truncates the log file to zero bytes, giving its space back
+	CheckpointTruncate = "truncate"
+)
+
+// CheckpointModes lists the checkpoint modes
+var CheckpointModes = []string{CheckpointPassive, CheckpointRestart, CheckpointTruncate}
+
+// CheckpointResult reports what a checkpoint of the write-ahead log did
+type CheckpointResult struct {
+	Mode string `json:"mode"`
+	// Busy is set when readers or writers kept the checkpoint from completing
+	Busy bool `json:"busy"`
+	// WALFrames is the number of frames the log held, and CheckpointedFrames how many of them are
+	// now written to the database
+	WALFrames          int64 `json:"wal_frames"`
+	CheckpointedFrames int64 `json:"checkpointed_frames"`
+	// WALBytes is the size of the log file afterwards; only truncating shrinks it
+	WALBytes   int64   `json:"wal_bytes"`
+	DurationMs float64 `json:"duration_ms"`
+}
@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+// ClusterStatus reports how the live stream of this node is merged with those of its peers
+type ClusterStatus struct {
+	// Node is the name this node tags its own entries with
+	Node string `json:"node"`
+	// MergeWindowSeconds is how long entries are held to be put in timestamp order
+	MergeWindowSeconds float64 `json:"merge_window_seconds"`
+	Subscribers        int     `json:"subscribers"`
+	// Nodes lists this node first, then the peers in the order they are configured
+	Nodes []ClusterNode `json:"nodes"`
+}
+
+// ClusterNode is the state of the live stream followed from one node
+type ClusterNode struct {
+	Name string `json:"name"`
+	// URL is the address of a peer's HTTP API without its credentials, empty for this node
+	URL       string `json:"url,omitempty"`
+	Local     bool   `json:"local"`
+	Connected bool   `json:"connected"`
+	// ConnectedAt is when the stream of a peer was last connected
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	// CursorID and CursorTimestamp are the row ID on the node and the timestamp of the last entry
+	// received from it; after a reconnect, the entries stored after the cursor are fetched first
+	CursorID        int64      `json:"cursor_id"`
+	CursorTimestamp *time.Time `json:"cursor_timestamp,omitempty"`
+	// Received counts the entries received from the node, Backfilled those of them fetched after
+	// a reconnect
+	Received   int64  `json:"received"`
+	Backfilled int64  `json:"backfilled"`
+	Reconnects int64  `json:"reconnects"`
+	LastError  string `json:"last_error,omitempty"`
+}
@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// CompareQuery runs one search over a baseline and a current time range to find what changed
+// between them, e.g. since yesterday or since the last deploy
+type CompareQuery struct {
+	// Search holds the filters applied to both ranges; its time range and pagination are ignored
+	Search SearchQuery `json:"search"`
+
+	BaselineStart time.Time `json:"baseline_start"`
+	BaselineEnd   time.Time `json:"baseline_end"`
+	CurrentStart  time.Time `json:"current_start"`
+	CurrentEnd    time.Time `json:"current_end"`
+
+	// Limit is the number of patterns returned, those that changed the most
+	Limit int `json:"limit"`
+}
+
+// CompareRange is one side of a comparison
+type CompareRange struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Count     int64     `json:"count"`
+	// Truncated is set when the range held more entries than are examined; counts then cover the
+	// most recent ones
+	Truncated bool `json:"truncated"`
+}
+
+// SeverityDelta is the change in the number of entries of one severity
+type SeverityDelta struct {
+	Severity int   `json:"severity"`
+	Baseline int64 `json:"baseline"`
+	Current  int64 `json:"current"`
+	Delta    int64 `json:"delta"`
+}
+
+// PatternDelta is the change in the number of entries of one message pattern from one app
+type PatternDelta struct {
+	// Pattern is the message with its variable parts, such as numbers, IDs and quoted values,
+	// replaced by <*>
+	Pattern string `json:"pattern"`
+	AppName string `json:"app_name"`
+	// Severity is the most severe severity among the pattern's entries
+	Severity int `json:"severity"`
+	// Example is the most recent message of the pattern
+	Example  string `json:"example"`
+	Baseline int64  `json:"baseline"`
+	Current  int64  `json:"current"`
+	Delta    int64  `json:"delta"`
+	// New is set for patterns absent from the baseline, Gone for patterns absent from the current range
+	New  bool `json:"new,omitempty"`
+	Gone bool `json:"gone,omitempty"`
+}
+
+// CompareResult is the result of a CompareQuery. Patterns are listed new ones first, then by
+// the size of their change.
+type CompareResult struct {
+	Baseline   CompareRange    `json:"baseline"`
+	Current    CompareRange    `json:"current"`
+	Severities []SeverityDelta `json:"severities"`
+	Patterns   []PatternDelta  `json:"patterns"`
+	// TotalPatterns is the number of distinct patterns seen, of which Limit are returned
+	TotalPatterns int `json:"total_patterns"`
+}
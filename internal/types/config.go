@@ -1,5 +1,22 @@
 package types
 
+import "time"
+
+// Raw message storage modes
+const (
+	RawMessagesPlain      = "plain"
+	RawMessagesCompressed = "compressed"
+	RawMessagesOff        = "off"
+)
+
+// ACME challenge types proving control of the web interface's domains
+const (
+	// ACMETLSALPN answers the challenge on the HTTPS listener itself, which must be reachable on port 443
+	ACMETLSALPN = "tls-alpn-01"
+	// ACMEHTTP answers the challenge on a separate plain HTTP listener, which must be reachable on port 80
+	ACMEHTTP = "http-01"
+)
+
 // Config holds all configuration options for the application
 type Config struct {
 	TCPPort        int    `json:"tcp_port"`
@@ -12,4 +29,278 @@ type Config struct {
 	AuthUsername   string `json:"auth_username"`
 	AuthPassword   string `json:"auth_password"`
 	AuthEnabled    bool   `json:"auth_enabled"`
-}
\ No newline at end of file
+
+	// SetupFile holds the settings chosen in the first-run setup wizard (empty disables the wizard)
+	SetupFile string `json:"setup_file,omitempty"`
+	// NoAuth runs without authentication instead of starting the setup wizard
+	NoAuth bool `json:"no_auth"`
+	// Demo seeds an empty database with sample logs and serves it read-only. Without authentication,
+	// every visitor gets the reader role.
+	Demo bool `json:"demo"`
+
+	// Stamp holds deployment metadata, such as environment=prod, recorded in the metadata element of
+	// every entry so that entries from several deployments can be told apart
+	Stamp map[string]string `json:"stamp,omitempty"`
+	// StampCloud reads the region, zone, instance and account from the instance metadata service
+	// of this cloud provider and stamps them too (empty disables)
+	StampCloud string `json:"stamp_cloud,omitempty"`
+
+	// TimestampRules is a JSON file of timestamp layouts and time zones per sender, for timestamps
+	// that are not RFC3339 (empty accepts RFC3339 only)
+	TimestampRules string `json:"timestamp_rules,omitempty"`
+
+	// TransformRules is a JSON file of rewrites per sender, such as stripping ANSI color codes or
+	// container runtime prefixes, applied to messages before they are parsed (empty disables)
+	TransformRules string `json:"transform_rules,omitempty"`
+
+	// Reader-role account: sees redacted content and cannot use admin endpoints
+	ReaderUsername string `json:"reader_username"`
+	ReaderPassword string `json:"reader_password"`
+
+	// RedactFields are structured data keys ("sdid.param") masked for reader-role users
+	RedactFields []string `json:"redact_fields,omitempty"`
+	// RedactPattern is a regular expression whose matches are masked for reader-role users
+	RedactPattern string `json:"redact_pattern,omitempty"`
+
+	// EncryptFields are structured data keys ("sdid.param") encrypted before storage with the keys
+	// of EncryptionKeys, a JSON file of keys per tenant and app
+	EncryptFields  []string `json:"encrypt_fields,omitempty"`
+	EncryptionKeys string   `json:"encryption_keys,omitempty"`
+
+	// Bind addresses for each listener (empty binds all interfaces)
+	TCPBindAddress       string `json:"tcp_bind_address"`
+	HTTPBindAddress      string `json:"http_bind_address"`
+	WebSocketBindAddress string `json:"websocket_bind_address"`
+
+	// HTTPH2C accepts cleartext HTTP/2 (h2c with prior knowledge) on the HTTP listener, only from
+	// trusted proxies when any are configured
+	HTTPH2C bool `json:"http_h2c"`
+	// HTTP2MaxConcurrentStreams bounds the concurrent requests multiplexed on one HTTP/2 connection (0 uses the default)
+	HTTP2MaxConcurrentStreams int `json:"http2_max_concurrent_streams"`
+	// HTTPIdleTimeout closes keep-alive HTTP connections with no request in flight after this long (0 uses the default)
+	HTTPIdleTimeout time.Duration `json:"http_idle_timeout"`
+
+	// Read and write deadlines of HTTP requests per route class, measured from when the request
+	// headers were read (0 disables)
+	HTTPAPITimeout    time.Duration `json:"http_api_timeout"`
+	HTTPExportTimeout time.Duration `json:"http_export_timeout"`
+	HTTPStreamTimeout time.Duration `json:"http_stream_timeout"`
+
+	// AccessLog writes an access log line per HTTP request to the process log
+	AccessLog bool `json:"access_log"`
+	// AccessLogIngest stores an access log entry per HTTP request in the log store itself
+	AccessLogIngest bool `json:"access_log_ingest"`
+
+	// ACMEDomains serves the web UI over HTTPS with certificates for these domains, obtained and
+	// renewed through ACME (empty serves plain HTTP)
+	ACMEDomains []string `json:"acme_domains,omitempty"`
+	// ACMEEmail is the contact address of the ACME account, notified about expiring certificates
+	ACMEEmail string `json:"acme_email,omitempty"`
+	// ACMEDirectory is the directory URL of the ACME certificate authority (empty uses Let's Encrypt)
+	ACMEDirectory string `json:"acme_directory,omitempty"`
+	// ACMECacheDir stores the ACME account key and the certificates across restarts
+	ACMECacheDir string `json:"acme_cache_dir,omitempty"`
+	// ACMEChallenge is the challenge type, ACMETLSALPN or ACMEHTTP
+	ACMEChallenge string `json:"acme_challenge,omitempty"`
+	// ACMEHTTPPort is the port of the plain HTTP listener answering http-01 challenges and
+	// redirecting everything else to HTTPS
+	ACMEHTTPPort int `json:"acme_http_port,omitempty"`
+
+	// HTTPBasePath serves the web UI and API below a path prefix, e.g. "/logs" (empty serves at the root)
+	HTTPBasePath string `json:"http_base_path"`
+
+	// TrustedProxies lists the CIDRs of reverse proxies whose X-Forwarded-For/X-Real-IP headers are honoured
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// AllowedOrigins lists additional browser origins allowed to open WebSocket connections ("*" allows any)
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	// Per-client HTTP request limits per minute for each endpoint class (0 disables)
+	SearchRateLimit int `json:"search_rate_limit"`
+	IngestRateLimit int `json:"ingest_rate_limit"`
+	AdminRateLimit  int `json:"admin_rate_limit"`
+
+	// Maximum HTTP request body sizes in bytes for each endpoint class (0 disables)
+	SearchMaxBodyBytes int `json:"search_max_body_bytes"`
+	IngestMaxBodyBytes int `json:"ingest_max_body_bytes"`
+	AdminMaxBodyBytes  int `json:"admin_max_body_bytes"`
+
+	// ParseWorkers is how many goroutines parse incoming messages (0 uses one per CPU)
+	ParseWorkers int `json:"parse_workers"`
+	// MemoryLimitMB is the approximate memory in MiB messages not yet stored may hold before
+	// senders are held back (0 disables)
+	MemoryLimitMB int `json:"memory_limit_mb"`
+
+	// DegradeAfter is how long storage writes must keep failing before the degraded mode is entered
+	// (0 disables it)
+	DegradeAfter time.Duration `json:"degrade_after"`
+	// DegradedSpool is the file keeping the messages received in the degraded mode until storage
+	// recovers, empty to only forward them; DegradedSpoolLimitMB bounds its size (0 for no limit)
+	DegradedSpool        string `json:"degraded_spool"`
+	DegradedSpoolLimitMB int    `json:"degraded_spool_limit_mb"`
+
+	// StorageRetryAttempts is how often a storage operation failing transiently is run, with
+	// StorageRetryBackoff before the first retry, doubled before each further one
+	StorageRetryAttempts int           `json:"storage_retry_attempts"`
+	StorageRetryBackoff  time.Duration `json:"storage_retry_backoff"`
+	// StorageBreakerThreshold is how many storage operations in a row must fail transiently for
+	// the circuit breaker to open, for StorageBreakerOpen (0 disables it)
+	StorageBreakerThreshold int           `json:"storage_breaker_threshold"`
+	StorageBreakerOpen      time.Duration `json:"storage_breaker_open"`
+
+	// MaxConcurrentSearches limits how many storage searches run at once
+	MaxConcurrentSearches int `json:"max_concurrent_searches"`
+	// SearchQueueTimeout is how long an excess search waits for a free slot before being rejected (0 rejects immediately)
+	SearchQueueTimeout time.Duration `json:"search_queue_timeout"`
+	// SearchCacheTTL is how long the results of identical searches are reused (0 disables the cache)
+	SearchCacheTTL time.Duration `json:"search_cache_ttl"`
+	// SearchCacheSize is the maximum number of search results cached
+	SearchCacheSize int `json:"search_cache_size"`
+	// TailBufferWindow is how long entries are held in memory for searching the live tail (0 disables the buffer)
+	TailBufferWindow time.Duration `json:"tail_buffer_window"`
+	// TailBufferSize is the maximum number of entries held for searching the live tail
+	TailBufferSize int `json:"tail_buffer_size"`
+
+	// TCPProxyProtocol requires a PROXY protocol v1/v2 header on every TCP ingestion connection
+	TCPProxyProtocol bool `json:"tcp_proxy_protocol"`
+	// TCPProxyTrusted lists the CIDRs of the proxies allowed to send that header; connections from other
+	// peers are rejected
+	TCPProxyTrusted []string `json:"tcp_proxy_trusted"`
+
+	// TCPIdleTimeout closes TCP ingestion connections that send nothing for this long (0 uses the default)
+	TCPIdleTimeout time.Duration `json:"tcp_idle_timeout"`
+	// TCPMaxConnectionLifetime closes TCP ingestion connections this long after they were accepted (0 disables)
+	TCPMaxConnectionLifetime time.Duration `json:"tcp_max_connection_lifetime"`
+
+	// TCPTLSCert and TCPTLSKey are the PEM certificate and key files of TLS ingestion; when they or
+	// tenants are set, the TCP listener only accepts TLS connections
+	TCPTLSCert string `json:"tcp_tls_cert,omitempty"`
+	TCPTLSKey  string `json:"tcp_tls_key,omitempty"`
+	// TCPTLSClientCA is a PEM file of the CAs that must have signed the certificate of every TLS
+	// ingestion client (empty accepts clients without certificates)
+	TCPTLSClientCA string `json:"tcp_tls_client_ca,omitempty"`
+	// TCPTLSTenants is a JSON file of tenants, each selected by the TLS server names (SNI) its
+	// senders connect with (empty configures none)
+	TCPTLSTenants string `json:"tcp_tls_tenants,omitempty"`
+
+	// UDPPort is the UDP port syslog datagrams are received on (0 disables the UDP listener)
+	UDPPort int `json:"udp_port"`
+	// UDPBindAddress is the address the UDP listener binds to (empty binds all interfaces)
+	UDPBindAddress string `json:"udp_bind_address"`
+	// UDPMaxMessageSize is the size in bytes longer datagrams are truncated to
+	UDPMaxMessageSize int `json:"udp_max_message_size"`
+
+	// StorageLimitMB is the disk space in MiB the database may use, against which
+	// /api/admin/storage projects the days left (0 uses the free space of its file system)
+	StorageLimitMB int `json:"storage_limit_mb"`
+
+	// IntegrityCheckInterval is how often the database is quick-checked in the background (0 disables)
+	IntegrityCheckInterval time.Duration `json:"integrity_check_interval"`
+
+	// WALCheckpointMode is how the write-ahead log is checkpointed once it holds WALCheckpointPages
+	// pages (0 disables), one of CheckpointModes; WALTruncateInterval is how often it is also
+	// checkpointed and truncated regardless of its size (0 disables)
+	WALCheckpointMode   string        `json:"wal_checkpoint_mode"`
+	WALCheckpointPages  int           `json:"wal_checkpoint_pages"`
+	WALTruncateInterval time.Duration `json:"wal_truncate_interval"`
+
+	// RawMessages selects how the message as received is kept: "plain", "compressed" or "off"
+	RawMessages string `json:"raw_messages"`
+
+	// KeepANSIColors stores the ANSI color and style sequences of messages for the web UI to render,
+	// instead of escaping them like other control characters
+	KeepANSIColors bool `json:"keep_ansi_colors"`
+
+	// SDMaxBytes, SDMaxParams and SDMaxKeysPerApp limit the total size and number of structured data
+	// parameters of an entry and the distinct keys ("sdid.param") of an application (0 disables)
+	SDMaxBytes      int `json:"sd_max_bytes"`
+	SDMaxParams     int `json:"sd_max_params"`
+	SDMaxKeysPerApp int `json:"sd_max_keys_per_app"`
+
+	// HashChain links each stored entry to the previous one of its day with a SHA-256 hash
+	HashChain bool `json:"hash_chain"`
+
+	// IdempotencyWindow is how long the idempotency keys of stored entries are remembered, so that
+	// entries sent again under the same key are not stored twice (0 disables)
+	IdempotencyWindow time.Duration `json:"idempotency_window"`
+
+	// EntryIDs is the scheme of the globally unique, time-sortable uid given to new entries besides
+	// their row ID: autoincrement (none), ulid or ksuid
+	EntryIDs string `json:"entry_ids"`
+
+	// SIEMForward is a tcp://host:port or udp://host:port collector that security-relevant entries
+	// are forwarded to as they arrive (empty disables forwarding)
+	SIEMForward string `json:"siem_forward,omitempty"`
+	// SIEMFormat is the format of forwarded events: "cef", "ocsf" or the name of an output format
+	SIEMFormat string `json:"siem_format"`
+	// SIEMMinSeverity forwards entries at least this severe (syslog severity, 0 is emergency)
+	SIEMMinSeverity int `json:"siem_min_severity"`
+	// SIEMFacilities restricts forwarding to these syslog facilities (empty forwards every facility)
+	SIEMFacilities []int `json:"siem_facilities,omitempty"`
+
+	// OutputFormats is a JSON file of Go-template output formats for exports and forwarding (empty
+	// provides only the built-in ones)
+	OutputFormats string `json:"output_formats,omitempty"`
+
+	// NotificationChannels is a JSON file of Slack, Discord and Teams webhook channels (empty configures none)
+	NotificationChannels string `json:"notification_channels,omitempty"`
+
+	// FallbackAlertPercent is the share of a source's messages the parser could not parse at which
+	// the built-in fallback alert fires (0 disables it)
+	FallbackAlertPercent int `json:"fallback_alert_percent"`
+	// FallbackAlertMinEntries is the number of messages a source must send in a window for the
+	// fallback alert to judge it
+	FallbackAlertMinEntries int `json:"fallback_alert_min_entries"`
+	// FallbackAlertWindow is the period the messages of each source are counted over
+	FallbackAlertWindow time.Duration `json:"fallback_alert_window"`
+	// FallbackAlertChannels names the notification channels the fallback alert is sent to (empty
+	// only logs it)
+	FallbackAlertChannels []string `json:"fallback_alert_channels,omitempty"`
+
+	// LifecycleWebhook is an http(s) URL that retention runs, deletions and backups are announced
+	// to (empty only logs them)
+	LifecycleWebhook string `json:"lifecycle_webhook,omitempty"`
+
+	// AgentConfig is a JSON file of the configurations centrally managed for shipper agents (empty
+	// serves agents an empty configuration)
+	AgentConfig string `json:"agent_config,omitempty"`
+
+	// NodeName identifies this node in the metadata of the entries it receives and in the cluster
+	// live stream (empty uses the host name)
+	NodeName string `json:"node_name,omitempty"`
+	// ClusterPeers lists the other nodes of a cluster whose live streams are followed, as name=url
+	// or url, the URL being that of their HTTP API with any credentials (empty follows none)
+	ClusterPeers []string `json:"cluster_peers,omitempty"`
+	// ClusterMergeWindow is how long entries of the cluster live stream are held to be put in
+	// timestamp order (0 passes them on as they arrive)
+	ClusterMergeWindow time.Duration `json:"cluster_merge_window"`
+
+	// Archive lists s3://bucket/prefix URLs of NDJSON dumps searched for entries past retention
+	// (empty searches none)
+	Archive []string `json:"archive,omitempty"`
+	// ArchiveEndpoint is the URL of an S3-compatible store holding the dumps (empty uses AWS)
+	ArchiveEndpoint string `json:"archive_endpoint,omitempty"`
+	// ArchiveRegion is the region of the buckets (empty uses AWS_REGION or us-east-1)
+	ArchiveRegion string `json:"archive_region,omitempty"`
+	// ArchiveAuto searches the archive whenever a search starts before the retention cutoff,
+	// rather than only when asked to
+	ArchiveAuto bool `json:"archive_auto"`
+	// ArchiveMaxPartitions is the most dump files a search downloads
+	ArchiveMaxPartitions int `json:"archive_max_partitions"`
+	// ArchiveAccessKey and ArchiveSecretKey sign archive requests instead of the AWS environment
+	// variables
+	ArchiveAccessKey string `json:"archive_access_key,omitempty"`
+	ArchiveSecretKey string `json:"archive_secret_key,omitempty"`
+
+	// VaultAddress is the URL of the Vault server that secrets referred to as vault:path#key are
+	// read from, logging in with VaultAuth: a VaultToken, or the AppRole VaultRoleID and
+	// VaultSecretID
+	VaultAddress  string `json:"vault_address,omitempty"`
+	VaultAuth     string `json:"vault_auth"`
+	VaultToken    string `json:"vault_token,omitempty"`
+	VaultRoleID   string `json:"vault_role_id,omitempty"`
+	VaultSecretID string `json:"vault_secret_id,omitempty"`
+
+	// ReusePort binds listeners with SO_REUSEPORT so a second instance can share the ports
+	ReusePort bool `json:"reuse_port"`
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// ReencryptBatch is the outcome of re-encrypting one batch of stored entries
+type ReencryptBatch struct {
+	// LastID is the highest entry ID examined; the next batch starts after it
+	LastID    int64 `json:"last_id"`
+	Processed int64 `json:"processed"`
+	Updated   int64 `json:"updated"`
+	Done      bool  `json:"done"`
+}
+
+// ReencryptStatus reports the progress of the most recent re-encryption job
+type ReencryptStatus struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Processed counts entries examined and Updated those whose encrypted fields were rewritten
+	Processed int64 `json:"processed"`
+	Updated   int64 `json:"updated"`
+
+	Error string `json:"error,omitempty"`
+}
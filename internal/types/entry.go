@@ -0,0 +1,23 @@
+package types
+
+// EntryDetail is a single entry with what was recorded about it besides its fields
+type EntryDetail struct {
+	Entry *LogEntry `json:"entry"`
+	// Raw is the message as received, empty if none was retained
+	Raw string `json:"raw,omitempty"`
+	// SourceIP is the normalized address of the sender, if the receiver recorded it
+	SourceIP string `json:"source_ip,omitempty"`
+	// Annotations are the other parameters the receiver and the ingestion pipeline recorded in the
+	// metadata structured data element
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Chain is the entry's link in its hash chain, nil if it was stored without one
+	Chain *ChainLink `json:"chain,omitempty"`
+}
+
+// ChainLink is the position of an entry in a hash chain
+type ChainLink struct {
+	Partition string `json:"partition"`
+	// Prev is the hash of the previous entry of the partition, empty for its first entry
+	Prev string `json:"prev,omitempty"`
+	Hash string `json:"hash"`
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// EntrySizes reports how large the messages and structured data of entries are, per app, so that
+// an app sending oversized entries stands out
+type EntrySizes struct {
+	// Buckets are the upper bounds in bytes of the histogram buckets; a final bucket without bound
+	// counts the rest
+	Buckets []int64 `json:"buckets"`
+	// Apps lists the sizes of each app, largest 95th percentile message first
+	Apps []AppEntrySizes `json:"apps"`
+}
+
+// AppEntrySizes is the size of the entries of one app, "-" for entries without an app name
+type AppEntrySizes struct {
+	AppName  string    `json:"app_name"`
+	Entries  int64     `json:"entries"`
+	LastSeen time.Time `json:"last_seen"`
+	// Message is the size of the messages as received
+	Message SizeHistogram `json:"message"`
+	// StructuredData is the size of the structured data the sender attached, as written in
+	// RFC5424, leaving out the metadata the server records
+	StructuredData SizeHistogram `json:"structured_data"`
+}
+
+// SizeHistogram counts sizes into the buckets of an EntrySizes
+type SizeHistogram struct {
+	Counts   []int64 `json:"counts"`
+	SumBytes int64   `json:"sum_bytes"`
+	MaxBytes int64   `json:"max_bytes"`
+	AvgBytes int64   `json:"avg_bytes"`
+	// P50Bytes, P95Bytes and P99Bytes are the upper bounds of the buckets holding the percentiles,
+	// MaxBytes for the unbounded bucket
+	P50Bytes int64 `json:"p50_bytes"`
+	P95Bytes int64 `json:"p95_bytes"`
+	P99Bytes int64 `json:"p99_bytes"`
+}
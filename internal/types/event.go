@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// EventDeploy is the type of events that do not name one
+const EventDeploy = "deploy"
+
+// MaxEventFieldLength bounds the length of an event's service, version and description
+const MaxEventFieldLength = 1024
+
+// Event marks a point in time that explains changes in log volume, such as a deploy posted by a
+// CI/CD system. Events are shown alongside histograms and are not removed by retention.
+type Event struct {
+	ID int64 `json:"id"`
+	// Type is "deploy" unless given, e.g. "rollback" or "config"
+	Type        string `json:"type"`
+	Service     string `json:"service"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Time is when the event happened, the time it was posted unless given
+	Time      time.Time `json:"time"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventQuery selects the events in a time range, oldest first
+type EventQuery struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Optional filters; empty matches every service or type
+	Service string `json:"service,omitempty"`
+	Type    string `json:"type,omitempty"`
+	// Limit bounds the number of events returned (zero means no limit)
+	Limit int `json:"limit,omitempty"`
+}
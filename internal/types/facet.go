@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+// Fields that facet counts are maintained for
+const (
+	FacetHostname = "hostname"
+	FacetAppName  = "app_name"
+	FacetMsgID    = "msg_id"
+)
+
+// FacetFields lists every field that supports facet queries
+var FacetFields = []string{FacetHostname, FacetAppName, FacetMsgID}
+
+// FacetQuery requests the most common values of one or more fields over a time range
+type FacetQuery struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	// Fields to return facets for (empty means all FacetFields)
+	Fields []string `json:"fields,omitempty"`
+
+	// Limit is the number of top values returned per field
+	Limit int `json:"limit"`
+}
+
+// FacetValue is a single field value and the number of entries that carry it
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facet holds the top values and the number of distinct values of one field
+type Facet struct {
+	Field       string       `json:"field"`
+	Cardinality int64        `json:"cardinality"`
+	Values      []FacetValue `json:"values"`
+}
+
+// FacetResult is the result of a FacetQuery. Counts come from hourly rollups, so the
+// time range is widened to whole hours and figures are approximate at the edges.
+type FacetResult struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Facets    []Facet   `json:"facets"`
+}
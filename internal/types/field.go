@@ -0,0 +1,51 @@
+package types
+
+import "time"
+
+// FieldInfo describes a field known to the field catalog. Structured data keys are named
+// "sdid.param"; built-in fields use their column name (hostname, app_name, msg_id).
+type FieldInfo struct {
+	Name     string    `json:"name"`
+	Builtin  bool      `json:"builtin"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// FieldValuesQuery requests recent values of a single field
+type FieldValuesQuery struct {
+	// Field is a catalog field name, or a bare parameter name matching it in every SD element
+	Field string `json:"field"`
+
+	// Prefix restricts values to those starting with it (case-sensitive)
+	Prefix string `json:"prefix,omitempty"`
+
+	Limit int `json:"limit"`
+}
+
+// FieldValueInfo is a value seen for a field, most recent first
+type FieldValueInfo struct {
+	Value    string    `json:"value"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Field promotion states
+const (
+	PromotionBuilding = "building"
+	PromotionReady    = "ready"
+	PromotionFailed   = "failed"
+)
+
+// PromotedField is a structured data key ("sdid.param") materialized as an indexed column so
+// filters on it are index lookups instead of JSON scans
+type PromotedField struct {
+	Field  string `json:"field"`
+	Column string `json:"column"`
+
+	// Status is building while the index is backfilled, then ready or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+}
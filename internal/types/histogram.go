@@ -0,0 +1,59 @@
+package types
+
+import "time"
+
+// Histogram dimensions that can be used to split counts
+const (
+	GroupBySeverity = "severity"
+	GroupByAppName  = "app_name"
+	GroupByHostname = "hostname"
+)
+
+// HistogramQuery describes a time-bucketed count of log entries
+type HistogramQuery struct {
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Interval  time.Duration `json:"interval"`
+
+	// Location aligns buckets to the zone's wall clock, e.g. days start at local midnight (nil means UTC)
+	Location *time.Location `json:"-"`
+
+	// GroupBy splits each bucket by severity, app_name or hostname (empty counts all entries together)
+	GroupBy string `json:"group_by,omitempty"`
+
+	// Optional filters on the pre-aggregated dimensions
+	Severity    *int   `json:"severity,omitempty"`
+	MinSeverity *int   `json:"min_severity,omitempty"`
+	AppName     string `json:"app_name,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`
+
+	// Search, if set, counts the entries matching a search instead, such as a full-text or structured
+	// data query the rollups cannot answer. Its time range, limit and offset are ignored, as are the
+	// filters above; buckets are counted from the stored entries.
+	Search *SearchQuery `json:"search,omitempty"`
+}
+
+// HistogramBucket is the number of entries in one interval, optionally for a single group
+type HistogramBucket struct {
+	Time  time.Time `json:"time"`
+	Group string    `json:"group,omitempty"`
+	Count int64     `json:"count"`
+	// Extrapolated is the number of messages the entries stand for by their sample rates, which
+	// equals Count when none were sampled
+	Extrapolated int64 `json:"extrapolated"`
+}
+
+// Histogram is the result of a HistogramQuery
+type Histogram struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Interval  string    `json:"interval"`
+	TimeZone  string    `json:"time_zone"`
+	GroupBy   string    `json:"group_by,omitempty"`
+	Total     int64     `json:"total"`
+	// ExtrapolatedTotal is the number of messages the entries stand for by their sample rates
+	ExtrapolatedTotal int64             `json:"extrapolated_total"`
+	Buckets           []HistogramBucket `json:"buckets"`
+	// Events are the deploys and other markers in the histogram's range, oldest first
+	Events []Event `json:"events,omitempty"`
+}
@@ -0,0 +1,39 @@
+package types
+
+import "time"
+
+// IngestLatency reports how late entries were received and how long they took to be committed,
+// per source
+type IngestLatency struct {
+	// Buckets are the upper bounds in seconds of the histogram buckets; a final bucket without
+	// bound counts the rest
+	Buckets []float64       `json:"buckets"`
+	Sources []SourceLatency `json:"sources"`
+}
+
+// SourceLatency is the ingestion latency of entries from one source: the sender's IP address, or
+// its hostname when the receiver did not record one
+type SourceLatency struct {
+	Source   string    `json:"source"`
+	Entries  int64     `json:"entries"`
+	LastSeen time.Time `json:"last_seen"`
+	// SenderLag is the time from the entries' timestamps until they were received; it grows when
+	// the sender's clock lags or the sender buffers entries before sending them
+	SenderLag LatencyHistogram `json:"sender_lag"`
+	// ServerLag is the time from receiving the entries until they were committed to storage
+	ServerLag LatencyHistogram `json:"server_lag"`
+	// FutureTimestamps counts entries timestamped after they were received, a sign of the sender's
+	// clock running ahead; they count as zero sender lag
+	FutureTimestamps int64 `json:"future_timestamps"`
+}
+
+// LatencyHistogram counts latencies into the buckets of an IngestLatency
+type LatencyHistogram struct {
+	Counts     []int64 `json:"counts"`
+	SumSeconds float64 `json:"sum_seconds"`
+	MaxSeconds float64 `json:"max_seconds"`
+	// P50Seconds and P95Seconds are the upper bounds of the buckets holding the percentiles,
+	// MaxSeconds for the unbounded bucket
+	P50Seconds float64 `json:"p50_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+}
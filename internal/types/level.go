@@ -0,0 +1,36 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TrackingIDField is the RFC5424 field that the tracking IDs of application log lines are stored
+// in and filtered by, so they are indexed like the message IDs of syslog messages
+const TrackingIDField = "msg_id"
+
+// LevelSeverities maps the level names of application logs and the syslog severity keywords to
+// syslog severities, so that a line logged at ERROR is stored and filtered as severity 3
+var LevelSeverities = map[string]int{
+	"emerg": 0, "emergency": 0, "panic": 0,
+	"alert": 1,
+	"crit":  2, "critical": 2, "fatal": 2,
+	"err": 3, "error": 3,
+	"warn": 4, "warning": 4,
+	"notice": 5,
+	"info":   6, "informational": 6,
+	"debug": 7, "trace": 7,
+}
+
+// SeverityForLevel returns the severity of a level name, ignoring case, or of a severity number
+// from 0 to 7
+func SeverityForLevel(level string) (int, bool) {
+	if severity, ok := LevelSeverities[strings.ToLower(level)]; ok {
+		return severity, true
+	}
+	severity, err := strconv.Atoi(level)
+	if err != nil || severity < 0 || severity > 7 {
+		return 0, false
+	}
+	return severity, true
+}
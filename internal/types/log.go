@@ -1,10 +1,16 @@
 package types
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // LogEntry represents a single RFC5424 log entry in the system
 type LogEntry struct {
 	ID            int64                  `json:"id"`
+	// UID is the globally unique, time-sortable identifier of the entry when entry IDs are
+	// generated (see the entry-ids option), empty otherwise
+	UID           string                 `json:"uid,omitempty"`
 	
 	// RFC5424 Header Fields
 	Priority      int                    `json:"priority"`      // PRI field (facility * 8 + severity)
@@ -23,8 +29,60 @@ type LogEntry struct {
 	
 	// System Fields
 	CreatedAt     time.Time              `json:"created_at"`    // When stored in DB
+	Raw           string                 `json:"-"`             // Message as received, kept for reprocessing
+	ReceivedAt    time.Time              `json:"-"`             // When the receiver accepted the message, zero if unknown
+	ReceivedBytes int                    `json:"-"`             // Size of the message as received, zero if unknown
+	Fallback      bool                   `json:"-"`             // Set when the message could not be parsed and is kept whole
+	
+	// Set on the entries of a cluster live stream: the node that received the entry
+	Node           string                `json:"node,omitempty"`
+	
+	// Set on search results read from the archive rather than the database; their ID is the one
+	// they had when they were dumped
+	Archived       bool                  `json:"archived,omitempty"`
+	
+	// Set on results of a text search: where the search terms matched the message
+	Highlights     []TextRange           `json:"highlights,omitempty"`
+	
+	// Set on collapsed search results: how many consecutive identical entries this one stands for
+	// and the time span they cover; Timestamp is that of the most recent one
+	RepeatCount    int                   `json:"repeat_count,omitempty"`
+	FirstTimestamp *time.Time            `json:"first_timestamp,omitempty"`
+	LastTimestamp  *time.Time            `json:"last_timestamp,omitempty"`
+}
+
+// TextRange is a span of a message, counted in characters (Unicode code points), end exclusive
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
+const (
+	// MetadataSDID is the structured data element holding metadata recorded by the receiver
+	MetadataSDID = "opentrail"
+	// SourceIPParam is the metadata parameter holding the normalized address of the sender
+	SourceIPParam = "source_ip"
+	// TenantParam is the metadata parameter holding the tenant a TLS ingestion connection was routed to
+	TenantParam = "tenant"
+	// SequenceGapParam is the metadata parameter holding how many sequence numbers the sender
+	// skipped just before the entry
+	SequenceGapParam = "sequence_gap"
+	// IdempotencyKeyParam is the metadata parameter through which a sender names an entry, so that
+	// sending it again is recognized and not stored twice
+	IdempotencyKeyParam = "idempotency_key"
+	// SampleRateParam is the metadata parameter through which a sender that forwards only some of
+	// its messages records how many messages the entry stands for, such as 10 for one in ten
+	SampleRateParam = "sample_rate"
+	// NodeParam is the metadata parameter holding the name of the node that received the entry
+	NodeParam = "node"
+	// MaxSampleRate is the highest sample rate an entry may record
+	MaxSampleRate = 1000000
+	// MetaSDID and SequenceIDParam are the RFC5424 structured data element and parameter through
+	// which senders number their messages
+	MetaSDID        = "meta"
+	SequenceIDParam = "sequenceId"
+)
+
 // GetFacility extracts facility from priority field
 func (l *LogEntry) GetFacility() int {
 	return l.Priority >> 3
@@ -42,6 +100,75 @@ func (l *LogEntry) SetPriority(priority int) {
 	l.Severity = l.GetSeverity()
 }
 
+// SetMetadata records a receiver-side metadata parameter in the entry's structured data
+func (l *LogEntry) SetMetadata(name, value string) {
+	if l.StructuredData == nil {
+		l.StructuredData = make(map[string]interface{})
+	}
+
+	params, ok := l.StructuredData[MetadataSDID].(map[string]interface{})
+	if !ok {
+		params = make(map[string]interface{})
+		// Keep any sender-supplied parameters under the same SD-ID
+		if existing, ok := l.StructuredData[MetadataSDID].(map[string]string); ok {
+			for key, value := range existing {
+				params[key] = value
+			}
+		}
+		l.StructuredData[MetadataSDID] = params
+	}
+	params[name] = value
+}
+
+// Metadata returns a parameter of the entry's metadata element, empty if not set
+func (l *LogEntry) Metadata(name string) string {
+	switch params := l.StructuredData[MetadataSDID].(type) {
+	case map[string]interface{}:
+		value, _ := params[name].(string)
+		return value
+	case map[string]string:
+		return params[name]
+	}
+	return ""
+}
+
+// SampleRate returns how many messages the entry stands for: its recorded sample rate, or 1 if it
+// records none or an invalid one
+func (l *LogEntry) SampleRate() int64 {
+	rate, err := strconv.ParseInt(l.Metadata(SampleRateParam), 10, 64)
+	if err != nil || rate < 1 || rate > MaxSampleRate {
+		return 1
+	}
+	return rate
+}
+
+// ClearMetadata removes a metadata parameter, such as one a sender supplied that only the receiver
+// may set, and the metadata element if nothing is left in it
+func (l *LogEntry) ClearMetadata(name string) {
+	switch params := l.StructuredData[MetadataSDID].(type) {
+	case map[string]interface{}:
+		delete(params, name)
+		if len(params) == 0 {
+			delete(l.StructuredData, MetadataSDID)
+		}
+	case map[string]string:
+		delete(params, name)
+		if len(params) == 0 {
+			delete(l.StructuredData, MetadataSDID)
+		}
+	}
+}
+
+// FieldFilter matches a column or a structured data parameter against a value.
+// Field is one of hostname, app_name, proc_id, msg_id or source_ip; any other
+// name matches a structured data parameter, either in any element ("user_id")
+// or in a specific one ("request@32473.user_id").
+type FieldFilter struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Negate bool   `json:"negate,omitempty"`
+}
+
 // SearchQuery represents parameters for searching RFC5424 logs
 type SearchQuery struct {
 	// Text search
@@ -51,14 +178,19 @@ type SearchQuery struct {
 	Facility      *int       `json:"facility,omitempty"`
 	Severity      *int       `json:"severity,omitempty"`
 	MinSeverity   *int       `json:"min_severity,omitempty"`
+	MaxSeverity   *int       `json:"max_severity,omitempty"`
 	Hostname      string     `json:"hostname,omitempty"`
 	AppName       string     `json:"app_name,omitempty"`
 	ProcID        string     `json:"proc_id,omitempty"`
 	MsgID         string     `json:"msg_id,omitempty"`
+	SourceIP      string     `json:"source_ip,omitempty"`
 	
 	// Structured data queries (JSON path)
 	StructuredDataQuery string `json:"structured_data_query,omitempty"`
 	
+	// Additional equality filters, e.g. compiled from a query expression
+	Filters       []FieldFilter `json:"filters,omitempty"`
+	
 	// Time range
 	StartTime     *time.Time `json:"start_time,omitempty"`
 	EndTime       *time.Time `json:"end_time,omitempty"`
@@ -66,4 +198,17 @@ type SearchQuery struct {
 	// Pagination
 	Limit         int        `json:"limit,omitempty"`
 	Offset        int        `json:"offset,omitempty"`
+	
+	// Result fields to return, all when empty; the entry ID is always included
+	Fields        []string   `json:"fields,omitempty"`
+	
+	// Collapse folds consecutive identical messages from the same source into one result;
+	// Limit and Offset then count collapsed results
+	Collapse      bool       `json:"collapse,omitempty"`
+}
+
+// SearchResultFields are the entry fields a search result can be restricted to
+var SearchResultFields = []string{
+	"id", "uid", "priority", "facility", "severity", "version", "timestamp", "hostname", "app_name", "proc_id",
+	"msg_id", "structured_data", "message", "created_at",
 }
\ No newline at end of file
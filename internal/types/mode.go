@@ -0,0 +1,50 @@
+package types
+
+import "time"
+
+// Operating modes of the server
+const (
+	// ModeNormal accepts ingestion and serves searches
+	ModeNormal = "normal"
+	// ModeReadOnly rejects ingestion and keeps serving searches
+	ModeReadOnly = "read_only"
+	// ModeMaintenance rejects ingestion and searches; only health and admin endpoints are served
+	ModeMaintenance = "maintenance"
+	// ModeDegraded is entered by the server itself while storage is down: ingestion is accepted and
+	// entries are forwarded and spooled, while searches are rejected
+	ModeDegraded = "degraded"
+)
+
+// ModeStatus is the operating mode the server is in
+type ModeStatus struct {
+	Mode string `json:"mode"`
+	// Message is the notice shown to clients whose requests are rejected
+	Message string `json:"message,omitempty"`
+	// Since is when the mode was entered, unset while in the normal mode since startup
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// Accepting reports whether ingestion is accepted
+func (m ModeStatus) Accepting() bool {
+	return m.Mode == ModeNormal || m.Mode == ModeDegraded || m.Mode == ""
+}
+
+// Searching reports whether searches are served
+func (m ModeStatus) Searching() bool {
+	return m.Mode != ModeMaintenance && m.Mode != ModeDegraded
+}
+
+// Notice describes why a request was rejected in the mode
+func (m ModeStatus) Notice() string {
+	notice := "server is in read-only mode"
+	switch m.Mode {
+	case ModeMaintenance:
+		notice = "server is in maintenance mode"
+	case ModeDegraded:
+		notice = "storage is unavailable"
+	}
+	if m.Message != "" {
+		notice += ": " + m.Message
+	}
+	return notice
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// MaxRecentQueries bounds the recent queries kept for a user; older ones are dropped
+	MaxRecentQueries = 20
+	// MaxPinnedFilters bounds the filters a user can pin
+	MaxPinnedFilters = 50
+	// MaxPinnedFilterName bounds the length of the name of a pinned filter
+	MaxPinnedFilterName = 256
+)
+
+// UserPreferences are the settings of the web interface kept for a user on the server, so that they
+// follow the user across browsers. Display options and filters are stored as the interface sends
+// them, as JSON objects.
+type UserPreferences struct {
+	// Display holds the display options of the interface, empty until first saved
+	Display json.RawMessage `json:"display,omitempty"`
+	// PinnedFilters are the filters the user keeps at hand, in the order they were arranged
+	PinnedFilters []PinnedFilter `json:"pinned_filters"`
+	// RecentQueries are the filters last searched, newest first
+	RecentQueries []json.RawMessage `json:"recent_queries"`
+	// UpdatedAt is when the preferences were last saved, zero if they never were
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PinnedFilter is a set of filters saved under a name
+type PinnedFilter struct {
+	Name    string          `json:"name"`
+	Filters json.RawMessage `json:"filters"`
+}
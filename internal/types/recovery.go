@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// Phases of the work storage does on startup before it can serve
+const (
+	// RecoveryWAL applies the write-ahead log an unclean shutdown left behind to the database
+	RecoveryWAL = "wal"
+	// RecoveryRollups rebuilds the histogram rollups, facets and field catalog from stored entries
+	RecoveryRollups = "rollups"
+)
+
+// RecoveryStatus reports the progress of the work storage does on startup, which after a crash or
+// an upgrade can take minutes
+type RecoveryStatus struct {
+	// Ready is set once storage has finished starting
+	Ready bool `json:"ready"`
+	// Phase is the phase in progress, empty when none is
+	Phase      string     `json:"phase,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// WALFrames is the number of frames found in the write-ahead log on startup and
+	// WALFramesApplied the number written back to the database
+	WALFrames        int64 `json:"wal_frames"`
+	WALFramesApplied int64 `json:"wal_frames_applied"`
+
+	// EntriesRecovered counts the entries whose rollups were rebuilt, out of about EntriesTotal
+	EntriesRecovered int64 `json:"entries_recovered"`
+	EntriesTotal     int64 `json:"entries_total"`
+
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	// EstimatedRemainingSeconds extrapolates the rate of the phase in progress, omitted while it
+	// cannot be estimated
+	EstimatedRemainingSeconds *float64 `json:"estimated_remaining_seconds,omitempty"`
+}
@@ -0,0 +1,82 @@
+package types
+
+import "time"
+
+// MinReportInterval is the shortest interval a scheduled report can run at
+const MinReportInterval = time.Minute
+
+// DefaultReportTopLimit is the number of top values kept per run when a report does not set one
+const DefaultReportTopLimit = 10
+
+// Report is a saved search that runs periodically and keeps a summary of each run, so its trend
+// remains available after the matching entries have been removed by retention
+type Report struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Query is a q expression selecting the counted entries; empty counts every entry
+	Query string `json:"query,omitempty"`
+	// TopField is a field whose most common values are recorded with each run, a built-in
+	// field or a "sdid.param" structured data parameter
+	TopField string `json:"top_field,omitempty"`
+	TopLimit int    `json:"top_limit,omitempty"`
+	// IntervalSeconds is how often the report runs; each run covers the time since the previous one
+	IntervalSeconds int64 `json:"interval_seconds"`
+	// AlertThreshold makes the report an alert, firing while a run counts at least this many
+	// entries and resolving on the first run counting fewer; zero disables alerting
+	AlertThreshold int64 `json:"alert_threshold,omitempty"`
+	// Extrapolate compares the alert threshold with the number of messages the entries stand for
+	// by their sample rates instead of the number of entries
+	Extrapolate bool `json:"extrapolate,omitempty"`
+	// Firing is the alert state after the latest run
+	Firing    bool       `json:"firing,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// Interval returns how often the report runs
+func (r Report) Interval() time.Duration {
+	return time.Duration(r.IntervalSeconds) * time.Second
+}
+
+// ReportResult is the stored summary of one report run over the entries timestamped in its window
+type ReportResult struct {
+	ReportID  int64     `json:"report_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Count     int64     `json:"count"`
+	// Extrapolated is the number of messages the entries stand for by their sample rates
+	Extrapolated int64        `json:"extrapolated"`
+	TopValues    []FacetValue `json:"top_values,omitempty"`
+	RunAt        time.Time    `json:"run_at"`
+	// Alert is the alert state change caused by the run, if any; it is not stored with the result
+	Alert *AlertEvent `json:"-"`
+}
+
+// ReportTopFields lists the built-in fields a report can record top values of
+var ReportTopFields = []string{"hostname", "app_name", "proc_id", "msg_id", "facility", "severity"}
+
+// Alert states recorded in the alert history
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// MaxAlertSamples is the number of matching entries kept with a firing event
+const MaxAlertSamples = 5
+
+// AlertEvent records a report alert starting to fire or resolving. Events are kept when their
+// report is deleted, so they carry the report's name.
+type AlertEvent struct {
+	ID         int64  `json:"id"`
+	ReportID   int64  `json:"report_id"`
+	ReportName string `json:"report_name"`
+	State      string `json:"state"`
+	// Time is the end of the run window in which the state changed
+	Time time.Time `json:"time"`
+	// Count is the number of entries in the window, or of messages they stand for if the report
+	// extrapolates
+	Count     int64 `json:"count"`
+	Threshold int64 `json:"threshold"`
+	// Samples are the newest entries of the window that made the alert fire
+	Samples []*LogEntry `json:"samples,omitempty"`
+}
@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+// ReprocessQuery selects the stored entries to re-parse from their raw messages
+type ReprocessQuery struct {
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+// ReprocessBatch is the outcome of re-parsing one batch of stored entries
+type ReprocessBatch struct {
+	// LastID is the highest entry ID examined; the next batch starts after it
+	LastID    int64 `json:"last_id"`
+	Processed int64 `json:"processed"`
+	Updated   int64 `json:"updated"`
+	Failed    int64 `json:"failed"`
+	Done      bool  `json:"done"`
+}
+
+// ReprocessStatus reports the progress of the most recent reprocessing job
+type ReprocessStatus struct {
+	Running    bool           `json:"running"`
+	Query      ReprocessQuery `json:"query"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+
+	// Processed counts entries re-parsed, Updated those whose fields changed and Failed those
+	// the current parser rejected (they keep their previous fields)
+	Processed int64 `json:"processed"`
+	Updated   int64 `json:"updated"`
+	Failed    int64 `json:"failed"`
+
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,15 @@
+package types
+
+// MaxRetentionDays bounds the retention period set through the admin API
+const MaxRetentionDays = 3650
+
+// RetentionStatus is the retention period in effect and where it comes from
+type RetentionStatus struct {
+	// Days is how many days of entries are kept, 0 keeping them forever
+	Days int `json:"days"`
+	// ConfiguredDays is the period of the server's configuration
+	ConfiguredDays int `json:"configured_days"`
+	// Overridden is set when Days was set through the admin API, replacing ConfiguredDays until it
+	// is reset
+	Overridden bool `json:"overridden"`
+}
@@ -0,0 +1,44 @@
+package types
+
+import "time"
+
+// ScanQuery selects the entries of an adhoc scan, which reads every stored entry and matches them
+// one by one instead of using the indexes of searches
+type ScanQuery struct {
+	// Text matches messages containing it, ignoring case
+	Text string
+	// Regex matches messages with a match of the regular expression
+	Regex string
+	// Raw matches the messages as received instead of the parsed messages
+	Raw bool
+	// Mask, when set, is applied to each message before matching, so a scan cannot match content
+	// its caller is not shown
+	Mask func(string) string
+
+	StartTime *time.Time
+	EndTime   *time.Time
+	// Limit is the most matches returned, zero for all
+	Limit int
+}
+
+// Scan outcomes
+const (
+	// ScanComplete means every stored entry was scanned
+	ScanComplete = "complete"
+	// ScanLimit means the scan stopped at its limit of matches
+	ScanLimit = "limit"
+	// ScanTimeout means the scan stopped at its deadline
+	ScanTimeout = "timeout"
+)
+
+// ScanProgress reports how far an adhoc scan got
+type ScanProgress struct {
+	// Scanned counts the entries read and Matched those that matched
+	Scanned int64 `json:"scanned"`
+	Matched int64 `json:"matched"`
+	// LastID is the ID of the last entry read; entries are read from the newest ID down
+	LastID    int64 `json:"last_id,omitempty"`
+	ElapsedMS int64 `json:"elapsed_ms"`
+	// Outcome is why the scan ended, empty while it runs
+	Outcome string `json:"outcome,omitempty"`
+}
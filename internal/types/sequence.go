@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// SequenceReport lists the sources that number their messages and the gaps found in their
+// sequence numbers
+type SequenceReport struct {
+	Sources []SourceSequence `json:"sources"`
+}
+
+// SourceSequence is the sequence numbering of one source, identified by its tenant, hostname and
+// app name
+type SourceSequence struct {
+	Tenant       string    `json:"tenant,omitempty"`
+	Hostname     string    `json:"hostname"`
+	AppName      string    `json:"app_name"`
+	Entries      int64     `json:"entries"`
+	LastSequence int64     `json:"last_sequence"`
+	LastSeen     time.Time `json:"last_seen"`
+	// Missing is the number of sequence numbers skipped and not received since
+	Missing int64 `json:"missing"`
+	// Late counts entries received after a higher sequence number, including duplicates
+	Late int64 `json:"late"`
+	// Resets counts the times the sequence started over, usually because the sender restarted
+	Resets int64 `json:"resets"`
+	// Gaps are the most recent gaps that are still missing entries, oldest first
+	Gaps []SequenceGap `json:"gaps"`
+}
+
+// SequenceGap is a range of sequence numbers skipped by a source
+type SequenceGap struct {
+	// First and Last are the first and last skipped sequence numbers
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+	// Missing is the number of skipped entries that have not arrived late
+	Missing    int64     `json:"missing"`
+	DetectedAt time.Time `json:"detected_at"`
+}
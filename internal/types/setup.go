@@ -0,0 +1,18 @@
+package types
+
+// SetupSettings are the choices made in the first-run setup wizard, stored in the setup file and
+// applied on every start unless a flag or environment variable sets the same option
+type SetupSettings struct {
+	AdminUsername string `json:"admin_username"`
+	AdminPassword string `json:"admin_password"`
+	RetentionDays int    `json:"retention_days"`
+
+	TCPBindAddress       string `json:"tcp_bind_address,omitempty"`
+	TCPPort              int    `json:"tcp_port"`
+	WebSocketBindAddress string `json:"websocket_bind_address,omitempty"`
+	WebSocketPort        int    `json:"websocket_port"`
+
+	// TLSCert and TLSKey are the self-signed certificate generated for TCP ingestion, if requested
+	TLSCert string `json:"tls_cert,omitempty"`
+	TLSKey  string `json:"tls_key,omitempty"`
+}
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+const (
+	// DefaultShareTTL is how long a share can be opened when its creator does not say
+	DefaultShareTTL = 7 * 24 * time.Hour
+	// MaxShareTTL bounds how long a share can be opened
+	MaxShareTTL = 90 * 24 * time.Hour
+	// MaxShareEntries bounds the number of entries frozen in a share
+	MaxShareEntries = 1000
+	// MaxShareDescription bounds the length of a share's description
+	MaxShareDescription = 1024
+)
+
+// Share is a frozen copy of log entries, opened through an unguessable token until it expires, so
+// exact evidence can be linked from tickets even after retention removed the originals
+type Share struct {
+	Token       string `json:"token"`
+	Description string `json:"description,omitempty"`
+	// Query is the query string of the search the entries were selected by, empty when they were
+	// selected by ID
+	Query string `json:"query,omitempty"`
+	// CreatedBy is the user who created the share, empty without authentication
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	EntryCount int       `json:"entry_count"`
+	// Entries are left out when shares are listed
+	Entries []*LogEntry `json:"entries,omitempty"`
+}
@@ -0,0 +1,16 @@
+package types
+
+import "time"
+
+// RecentBuffer is the result of a search of the entries the service holds in memory for the live
+// tail
+type RecentBuffer struct {
+	// Entries are the matching entries, the last received first
+	Entries []*LogEntry `json:"entries"`
+	// Buffered is the number of entries held, matching or not
+	Buffered int `json:"buffered"`
+	// Since is when the oldest entry held was received, nil when none is
+	Since *time.Time `json:"since,omitempty"`
+	// WindowSeconds is how long entries are held after they are received
+	WindowSeconds int64 `json:"window_seconds"`
+}
@@ -0,0 +1,91 @@
+package types
+
+import "time"
+
+// StorageUsage breaks down the disk space the database uses and projects when it runs out
+type StorageUsage struct {
+	// DatabaseBytes is the size of the main database file
+	DatabaseBytes int64 `json:"database_bytes"`
+	// WALBytes is the size of the write-ahead log, zero outside WAL mode
+	WALBytes int64 `json:"wal_bytes"`
+	// FreeBytes is the space inside the database file that deleted rows left for reuse
+	FreeBytes int64 `json:"free_bytes"`
+	// FTSBytes is the approximate size of the full-text index
+	FTSBytes int64 `json:"fts_bytes"`
+	// DiskFreeBytes is the space left on the file system holding the database, zero if unknown
+	DiskFreeBytes int64 `json:"disk_free_bytes"`
+	// Days lists the entries stored per UTC day, oldest first
+	Days []DayUsage `json:"days"`
+
+	// LimitBytes is the space the database may grow to: the configured storage limit, or else
+	// the space it uses plus the free disk space
+	LimitBytes int64 `json:"limit_bytes"`
+	// RetentionDays is how many days of entries are kept
+	RetentionDays int `json:"retention_days"`
+	// GrowthBytesPerDay is the disk space recent days took on average, including index overhead
+	GrowthBytesPerDay int64 `json:"growth_bytes_per_day"`
+	// RetainedBytes is the size the database settles at when retention removes a day per day
+	RetainedBytes int64 `json:"retained_bytes"`
+	// DaysUntilLimit is when the database reaches LimitBytes at the current growth; nil if it does
+	// not grow or retention keeps it below the limit
+	DaysUntilLimit *float64 `json:"days_until_limit,omitempty"`
+}
+
+// DayUsage is the number and size of the entries stored for one UTC day
+type DayUsage struct {
+	Day  string `json:"day"` // YYYY-MM-DD
+	Rows int64  `json:"rows"`
+	// Bytes is the size of the entries' fields, messages and raw messages, without indexes
+	Bytes int64 `json:"bytes"`
+}
+
+// DayLayout formats the Day of a DayUsage
+const DayLayout = "2006-01-02"
+
+// Fields volume can be attributed to
+const (
+	VolumeByAppName  = "app_name"
+	VolumeByHostname = "hostname"
+	VolumeByTenant   = "tenant"
+)
+
+// VolumeGroupings lists the fields volume can be attributed to
+var VolumeGroupings = []string{VolumeByAppName, VolumeByHostname, VolumeByTenant}
+
+// VolumeQuery selects the UTC days and the field of a volume attribution report
+type VolumeQuery struct {
+	GroupBy string
+	// StartDay and EndDay are the first and last day reported, inclusive
+	StartDay time.Time
+	EndDay   time.Time
+}
+
+// VolumeReport attributes the entries ingested and stored to the values of a field, so that the
+// noisiest apps, hosts or tenants can be charged back
+type VolumeReport struct {
+	GroupBy  string `json:"group_by"`
+	StartDay string `json:"start_day"`
+	EndDay   string `json:"end_day"`
+	// Days lists the volume of each group per day, oldest first
+	Days []VolumeRow `json:"days"`
+	// Totals lists the volume of each group over the whole report, most bytes ingested first; its
+	// stored volume is that of the latest day the group occupied storage
+	Totals []VolumeRow `json:"totals"`
+}
+
+// VolumeRow is the volume of one group on one UTC day, or over a whole report
+type VolumeRow struct {
+	Day   string `json:"day,omitempty"` // YYYY-MM-DD, empty in totals
+	Group string `json:"group"`
+	// IngestedEntries and IngestedBytes count the entries received that day and their size as
+	// received; they remain after retention removes the entries
+	IngestedEntries int64 `json:"ingested_entries"`
+	IngestedBytes   int64 `json:"ingested_bytes"`
+	// AvgIngestedBytes is the average size of those entries, pointing out groups that send
+	// oversized ones
+	AvgIngestedBytes int64 `json:"avg_ingested_bytes"`
+	// StoredEntries and StoredBytes are what the group's entries occupied in storage when last
+	// measured that day, sized like the Bytes of a DayUsage
+	StoredEntries int64 `json:"stored_entries"`
+	StoredBytes   int64 `json:"stored_bytes"`
+}
@@ -0,0 +1,178 @@
+// Package upgrade implements zero-downtime binary upgrades by handing the
+// process's listening sockets over to a freshly started copy of the binary.
+//
+// The running process starts the new binary with every listener passed as an
+// inherited file descriptor. The child re-creates its listeners from those
+// descriptors instead of binding again, signals readiness over a pipe once it
+// has started, and the parent then stops accepting and drains gracefully.
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvListenFDs lists the names of inherited listeners, in file descriptor order starting at 3
+	EnvListenFDs = "OPENTRAIL_UPGRADE_FDS"
+	// EnvReadyFD is the file descriptor the child writes to once it is ready to serve
+	EnvReadyFD = "OPENTRAIL_UPGRADE_READY_FD"
+)
+
+// Upgrader tracks the listeners of the current process so they can be passed to a new process
+type Upgrader struct {
+	reusePort bool
+
+	// Listeners inherited from a parent process, keyed by name
+	inherited map[string]*os.File
+
+	// Listeners and packet connections created by this process, keyed by name, in creation order
+	listeners map[string]io.Closer
+	names     []string
+
+	// Readiness pipe to the parent process (nil when not started by an upgrade)
+	readyFile *os.File
+
+	mutex sync.Mutex
+}
+
+// New creates an Upgrader, picking up any listeners inherited from a parent process.
+// When reusePort is true, freshly bound listeners set SO_REUSEPORT where supported
+// so that an independently started instance can bind the same ports.
+func New(reusePort bool) *Upgrader {
+	u := &Upgrader{
+		reusePort: reusePort,
+		inherited: make(map[string]*os.File),
+		listeners: make(map[string]io.Closer),
+	}
+
+	if value := os.Getenv(EnvListenFDs); value != "" {
+		for i, name := range strings.Split(value, ",") {
+			u.inherited[name] = os.NewFile(uintptr(3+i), name)
+		}
+	}
+
+	if value := os.Getenv(EnvReadyFD); value != "" {
+		var fd uintptr
+		if _, err := fmt.Sscanf(value, "%d", &fd); err == nil {
+			u.readyFile = os.NewFile(fd, "upgrade-ready")
+		}
+	}
+
+	// Do not leak the handoff variables into processes we start ourselves
+	os.Unsetenv(EnvListenFDs)
+	os.Unsetenv(EnvReadyFD)
+
+	return u
+}
+
+// HasParent reports whether this process was started by an upgrade
+func (u *Upgrader) HasParent() bool {
+	return u.readyFile != nil
+}
+
+// ListenFunc returns a listen function for the named listener, suitable for
+// the servers' SetListenFunc. The name identifies the listener across upgrades.
+func (u *Upgrader) ListenFunc(name string) func(network, addr string) (net.Listener, error) {
+	return func(network, addr string) (net.Listener, error) {
+		return u.Listen(name, network, addr)
+	}
+}
+
+// Listen returns the inherited listener for name if one exists, otherwise binds addr
+func (u *Upgrader) Listen(name, network, addr string) (net.Listener, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var listener net.Listener
+	if file, ok := u.inherited[name]; ok {
+		delete(u.inherited, name)
+
+		inherited, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited listener %s: %w", name, err)
+		}
+		listener = inherited
+	} else {
+		bound, err := listen(network, addr, u.reusePort)
+		if err != nil {
+			return nil, err
+		}
+		listener = bound
+	}
+
+	u.track(name, listener)
+	return listener, nil
+}
+
+// PacketListenFunc returns a listen function for the named packet connection, suitable for the UDP
+// server's SetListenFunc. The name identifies the socket across upgrades.
+func (u *Upgrader) PacketListenFunc(name string) func(network, addr string) (net.PacketConn, error) {
+	return func(network, addr string) (net.PacketConn, error) {
+		return u.ListenPacket(name, network, addr)
+	}
+}
+
+// ListenPacket returns the inherited packet connection for name if one exists, otherwise binds addr
+func (u *Upgrader) ListenPacket(name, network, addr string) (net.PacketConn, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var conn net.PacketConn
+	if file, ok := u.inherited[name]; ok {
+		delete(u.inherited, name)
+
+		inherited, err := net.FilePacketConn(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited packet connection %s: %w", name, err)
+		}
+		conn = inherited
+	} else {
+		bound, err := listenPacket(network, addr, u.reusePort)
+		if err != nil {
+			return nil, err
+		}
+		conn = bound
+	}
+
+	u.track(name, conn)
+	return conn, nil
+}
+
+// track records a socket to hand over on upgrade; the caller holds mutex
+func (u *Upgrader) track(name string, socket io.Closer) {
+	if _, exists := u.listeners[name]; !exists {
+		u.names = append(u.names, name)
+	}
+	u.listeners[name] = socket
+}
+
+// Ready tells the parent process that this process has started and can take over
+func (u *Upgrader) Ready() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	// Close inherited listeners that this process did not claim
+	for name, file := range u.inherited {
+		file.Close()
+		delete(u.inherited, name)
+	}
+
+	if u.readyFile == nil {
+		return nil
+	}
+
+	_, err := u.readyFile.Write([]byte("ready\n"))
+	u.readyFile.Close()
+	u.readyFile = nil
+	if err != nil {
+		return fmt.Errorf("failed to notify parent process: %w", err)
+	}
+	return nil
+}
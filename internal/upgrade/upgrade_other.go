@@ -0,0 +1,37 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package upgrade
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Signal is nil on platforms without listener handoff
+var Signal os.Signal
+
+// Supported reports whether listener handoff is available on this platform
+const Supported = false
+
+// listen binds a new listener; SO_REUSEPORT is not available on this platform
+func listen(network, addr string, reusePort bool) (net.Listener, error) {
+	if reusePort {
+		return nil, fmt.Errorf("reuse-port is not supported on this platform")
+	}
+	return net.Listen(network, addr)
+}
+
+// listenPacket binds a new packet connection; SO_REUSEPORT is not available on this platform
+func listenPacket(network, addr string, reusePort bool) (net.PacketConn, error) {
+	if reusePort {
+		return nil, fmt.Errorf("reuse-port is not supported on this platform")
+	}
+	return net.ListenPacket(network, addr)
+}
+
+// Upgrade is not supported on this platform
+func (u *Upgrader) Upgrade(timeout time.Duration) error {
+	return fmt.Errorf("binary upgrades are not supported on this platform")
+}
@@ -0,0 +1,74 @@
+package upgrade
+
+import (
+	"net"
+	"testing"
+)
+
+func TestUpgrader_ListenWithoutParent(t *testing.T) {
+	u := New(false)
+	if u.HasParent() {
+		t.Fatal("Expected no parent process")
+	}
+
+	listener, err := u.ListenFunc("tcp")("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to listener: %v", err)
+	}
+	conn.Close()
+
+	if len(u.names) != 1 || u.names[0] != "tcp" {
+		t.Errorf("Expected listener to be tracked as tcp, got %v", u.names)
+	}
+
+	if err := u.Ready(); err != nil {
+		t.Errorf("Ready without parent should succeed, got %v", err)
+	}
+}
+
+func TestUpgrader_ReusePort(t *testing.T) {
+	if !Supported {
+		t.Skip("upgrades are not supported on this platform")
+	}
+
+	u := New(true)
+	first, err := u.Listen("a", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer first.Close()
+
+	second, err := u.Listen("b", "tcp", first.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected second bind to succeed with SO_REUSEPORT: %v", err)
+	}
+	second.Close()
+}
+
+func TestUpgrader_ListenPacket(t *testing.T) {
+	u := New(false)
+	conn, err := u.PacketListenFunc("udp")("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer conn.Close()
+
+	listener, err := u.Listen("tcp", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	if len(u.names) != 2 || u.names[0] != "udp" || u.names[1] != "tcp" {
+		t.Errorf("Expected both sockets to be tracked, got %v", u.names)
+	}
+	if _, ok := u.listeners["udp"].(net.PacketConn); !ok {
+		t.Errorf("Expected the udp socket to be a packet connection, got %T", u.listeners["udp"])
+	}
+}
@@ -0,0 +1,140 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package upgrade
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Signal is the signal that asks a running process to upgrade itself
+var Signal os.Signal = syscall.SIGUSR2
+
+// Supported reports whether listener handoff is available on this platform
+const Supported = true
+
+// filer is implemented by listeners and packet connections that can expose their socket as a file
+type filer interface {
+	File() (*os.File, error)
+}
+
+// listenConfig returns the configuration binding new sockets, optionally with SO_REUSEPORT set
+func listenConfig(reusePort bool) net.ListenConfig {
+	config := net.ListenConfig{}
+	if reusePort {
+		config.Control = func(network, address string, conn syscall.RawConn) error {
+			var sockErr error
+			err := conn.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+	return config
+}
+
+// listen binds a new listener, optionally with SO_REUSEPORT set
+func listen(network, addr string, reusePort bool) (net.Listener, error) {
+	config := listenConfig(reusePort)
+	return config.Listen(context.Background(), network, addr)
+}
+
+// listenPacket binds a new packet connection, optionally with SO_REUSEPORT set
+func listenPacket(network, addr string, reusePort bool) (net.PacketConn, error) {
+	config := listenConfig(reusePort)
+	return config.ListenPacket(context.Background(), network, addr)
+}
+
+// Upgrade starts a new copy of the running binary with all listeners handed over
+// and waits until it reports ready. On success the caller should stop accepting
+// new work and shut down gracefully; on failure the current process keeps serving.
+func (u *Upgrader) Upgrade(timeout time.Duration) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	u.mutex.Lock()
+	var names []string
+	var files []*os.File
+	for _, name := range u.names {
+		listener, ok := u.listeners[name].(filer)
+		if !ok {
+			continue
+		}
+		file, err := listener.File()
+		if err != nil {
+			u.mutex.Unlock()
+			closeFiles(files)
+			return fmt.Errorf("failed to duplicate listener %s: %w", name, err)
+		}
+		names = append(names, name)
+		files = append(files, file)
+	}
+	u.mutex.Unlock()
+	defer closeFiles(files)
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyWriter)
+	cmd.Env = append(os.Environ(),
+		EnvListenFDs+"="+strings.Join(names, ","),
+		fmt.Sprintf("%s=%d", EnvReadyFD, 3+len(files)),
+	)
+
+	if err := cmd.Start(); err != nil {
+		readyWriter.Close()
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+	readyWriter.Close()
+
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(readyReader).ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != "ready" {
+			ready <- fmt.Errorf("new process exited before becoming ready")
+			return
+		}
+		ready <- nil
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			cmd.Wait()
+			return err
+		}
+		// Reap the child in the background if we outlive it
+		go cmd.Wait()
+		return nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("new process did not become ready within %v", timeout)
+	}
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
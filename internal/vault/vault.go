@@ -0,0 +1,334 @@
+// Package vault reads secrets from HashiCorp Vault, so that credentials, keys and certificates can
+// be referred to in the configuration instead of being kept on disk or in the environment.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authentication methods
+const (
+	AuthToken   = "token"
+	AuthAppRole = "approle"
+)
+
+const (
+	// RefPrefix starts a configuration value referring to a Vault secret, as in
+	// vault:secret/data/opentrail#admin_password
+	RefPrefix = "vault:"
+	// secretCacheTTL is how long secrets are reused before being read again, so rotated secrets
+	// are picked up without asking Vault for every use
+	secretCacheTTL = 5 * time.Minute
+	// retryInterval is how long renewal waits after a failed attempt to renew or log in
+	retryInterval = 30 * time.Second
+)
+
+// Options select the Vault server and how to authenticate to it
+type Options struct {
+	// Address is the URL of the Vault server
+	Address string
+	// Auth is AuthToken or AuthAppRole
+	Auth string
+	// Token authenticates with AuthToken
+	Token string
+	// RoleID and SecretID authenticate with AuthAppRole
+	RoleID   string
+	SecretID string
+}
+
+// Client reads secrets from Vault and keeps its token valid
+type Client struct {
+	address *url.URL
+	opts    Options
+	client  *http.Client
+
+	mu        sync.Mutex
+	token     string
+	ttl       time.Duration
+	renewable bool
+	secrets   map[string]cachedSecret
+}
+
+// cachedSecret is the data of a secret path as read at a time
+type cachedSecret struct {
+	data   map[string]interface{}
+	readAt time.Time
+}
+
+// New creates a client; Login must succeed before secrets are read
+func New(opts Options) (*Client, error) {
+	address, err := url.Parse(opts.Address)
+	if err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return nil, fmt.Errorf("vault address %q must be an http or https URL", opts.Address)
+	}
+	switch opts.Auth {
+	case AuthToken, AuthAppRole:
+	default:
+		return nil, fmt.Errorf("unknown vault auth method %q", opts.Auth)
+	}
+	return &Client{
+		address: address,
+		opts:    opts,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		secrets: make(map[string]cachedSecret),
+	}, nil
+}
+
+// IsRef reports whether a configuration value refers to a Vault secret
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, RefPrefix)
+}
+
+// ParseRef splits a reference into the API path of the secret, such as secret/data/opentrail for a
+// KV version 2 engine, and the key of the value within it
+func ParseRef(value string) (string, string, error) {
+	ref, ok := strings.CutPrefix(value, RefPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("%q is not a vault reference", value)
+	}
+	path, key, ok := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || key == "" {
+		return "", "", fmt.Errorf("vault reference %q must have the form vault:path#key", value)
+	}
+	return path, key, nil
+}
+
+// authResponse is the auth part of a login or renewal response
+type authResponse struct {
+	Auth *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// Login authenticates with the configured method. A token is looked up to learn when it expires.
+func (c *Client) Login(ctx context.Context) error {
+	if c.opts.Auth == AuthToken {
+		var lookup struct {
+			Data struct {
+				TTL       int64 `json:"ttl"`
+				Renewable bool  `json:"renewable"`
+			} `json:"data"`
+		}
+		if err := c.do(ctx, http.MethodGet, "auth/token/lookup-self", c.opts.Token, nil, &lookup); err != nil {
+			return fmt.Errorf("failed to look up vault token: %w", err)
+		}
+		c.mu.Lock()
+		c.token = c.opts.Token
+		c.ttl = time.Duration(lookup.Data.TTL) * time.Second
+		c.renewable = lookup.Data.Renewable
+		c.mu.Unlock()
+		return nil
+	}
+
+	var response authResponse
+	body := map[string]string{"role_id": c.opts.RoleID, "secret_id": c.opts.SecretID}
+	if err := c.do(ctx, http.MethodPost, "auth/approle/login", "", body, &response); err != nil {
+		return fmt.Errorf("failed to log in to vault with approle: %w", err)
+	}
+	return c.setAuth(response)
+}
+
+// renew extends the lease of the token
+func (c *Client) renew(ctx context.Context) error {
+	var response authResponse
+	if err := c.do(ctx, http.MethodPost, "auth/token/renew-self", c.currentToken(), map[string]string{}, &response); err != nil {
+		return fmt.Errorf("failed to renew vault token: %w", err)
+	}
+	return c.setAuth(response)
+}
+
+// setAuth takes over the token and lease of a login or renewal
+func (c *Client) setAuth(response authResponse) error {
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return fmt.Errorf("vault returned no token")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = response.Auth.ClientToken
+	c.ttl = time.Duration(response.Auth.LeaseDuration) * time.Second
+	c.renewable = response.Auth.Renewable
+	return nil
+}
+
+// currentToken returns the token requests are sent with
+func (c *Client) currentToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Run renews the token at two thirds of its lease until ctx is done. With AppRole, a token that
+// can no longer be renewed is replaced by logging in again; a token given directly is used until it
+// expires.
+func (c *Client) Run(ctx context.Context) {
+	for {
+		c.mu.Lock()
+		ttl, renewable := c.ttl, c.renewable
+		c.mu.Unlock()
+		if ttl <= 0 {
+			// The token does not expire
+			return
+		}
+		if !renewable && c.opts.Auth == AuthToken {
+			log.Printf("Vault token cannot be renewed and expires in %s", ttl)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ttl * 2 / 3):
+		}
+
+		err := fmt.Errorf("vault token cannot be renewed")
+		if renewable {
+			err = c.renew(ctx)
+		}
+		if err != nil && c.opts.Auth == AuthAppRole {
+			err = c.Login(ctx)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to keep the vault token valid, retrying in %s: %v", retryInterval, err)
+			c.mu.Lock()
+			// Retry well before what is left of the lease runs out
+			c.ttl = retryInterval * 3 / 2
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Read returns the value a reference refers to. Secrets of a KV version 2 engine are unwrapped, and
+// string values are returned as they are while others are returned as JSON.
+func (c *Client) Read(ctx context.Context, ref string) (string, error) {
+	path, key, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.secret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %q", path, key)
+	}
+	if s, ok := value.(string); ok {
+		if s == "" {
+			return "", fmt.Errorf("vault secret %s has an empty %q", path, key)
+		}
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// Resolve returns the secret a configuration value refers to, or the value itself if it is not a
+// reference
+func (c *Client) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	return c.Read(ctx, value)
+}
+
+// ReadFile reads the file named by a configuration option, or the secret referred to in its place
+func (c *Client) ReadFile(name string) ([]byte, error) {
+	if !IsRef(name) {
+		return os.ReadFile(name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.client.Timeout)
+	defer cancel()
+	value, err := c.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// secret returns the data of a secret path, read again once the cached copy is too old
+func (c *Client) secret(ctx context.Context, path string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	cached, ok := c.secrets[path]
+	c.mu.Unlock()
+	if ok && time.Since(cached.readAt) < secretCacheTTL {
+		return cached.data, nil
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, c.currentToken(), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to read vault secret %s: %w", path, err)
+	}
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+
+	c.mu.Lock()
+	c.secrets[path] = cachedSecret{data: data, readAt: time.Now()}
+	c.mu.Unlock()
+	return data, nil
+}
+
+// do sends a request to the Vault API and decodes its JSON response
+func (c *Client) do(ctx context.Context, method, path, token string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	endpoint := c.address.JoinPath("v1", path)
+	request, err := http.NewRequestWithContext(ctx, method, endpoint.String(), reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(response.Body, 64<<10)).Decode(&failure)
+		if len(failure.Errors) > 0 {
+			return fmt.Errorf("%s: %s", response.Status, strings.Join(failure.Errors, "; "))
+		}
+		return fmt.Errorf("%s", response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
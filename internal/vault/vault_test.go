@@ -0,0 +1,161 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeVault serves the parts of the Vault API the client uses
+type fakeVault struct {
+	reads atomic.Int32
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Vault-Token")
+	respond := func(body interface{}) {
+		json.NewEncoder(w).Encode(body)
+	}
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		var login map[string]string
+		json.NewDecoder(r.Body).Decode(&login)
+		if login["role_id"] != "role" || login["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			respond(map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		respond(map[string]interface{}{"auth": map[string]interface{}{"client_token": "approle-token", "lease_duration": 3600, "renewable": true}})
+	case "/v1/auth/token/lookup-self":
+		if token != "static-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		respond(map[string]interface{}{"data": map[string]interface{}{"ttl": 0, "renewable": false}})
+	case "/v1/auth/token/renew-self":
+		respond(map[string]interface{}{"auth": map[string]interface{}{"client_token": token, "lease_duration": 7200, "renewable": true}})
+	case "/v1/secret/data/opentrail":
+		if token == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.reads.Add(1)
+		respond(map[string]interface{}{"data": map[string]interface{}{
+			"data":     map[string]interface{}{"password": "hunter22", "keys": []string{"a"}},
+			"metadata": map[string]interface{}{"version": 3},
+		}})
+	case "/v1/kv/opentrail":
+		respond(map[string]interface{}{"data": map[string]interface{}{"cert": "-----BEGIN CERTIFICATE-----"}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	path, key, err := ParseRef("vault:secret/data/opentrail#password")
+	if err != nil || path != "secret/data/opentrail" || key != "password" {
+		t.Errorf("Unexpected reference %q %q (%v)", path, key, err)
+	}
+	for _, value := range []string{"secret/data/opentrail#password", "vault:secret/data/opentrail", "vault:#password", "vault:secret#"} {
+		if _, _, err := ParseRef(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestClient_AppRole(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+
+	if _, err := New(Options{Address: "vault.example", Auth: AuthToken}); err == nil {
+		t.Error("Expected an error for an address without scheme")
+	}
+	if _, err := New(Options{Address: server.URL, Auth: "ldap"}); err == nil {
+		t.Error("Expected an error for an unknown auth method")
+	}
+
+	wrong, _ := New(Options{Address: server.URL, Auth: AuthAppRole, RoleID: "role", SecretID: "wrong"})
+	if err := wrong.Login(ctx); err == nil || !strings.Contains(err.Error(), "invalid role or secret ID") {
+		t.Errorf("Expected the vault error to be reported, got %v", err)
+	}
+
+	client, _ := New(Options{Address: server.URL, Auth: AuthAppRole, RoleID: "role", SecretID: "secret"})
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if client.currentToken() != "approle-token" || client.ttl != time.Hour || !client.renewable {
+		t.Errorf("Unexpected token state %q %s %v", client.token, client.ttl, client.renewable)
+	}
+	if err := client.renew(ctx); err != nil || client.ttl != 2*time.Hour {
+		t.Errorf("Expected the lease renewed, got %s (%v)", client.ttl, err)
+	}
+
+	password, err := client.Read(ctx, "vault:secret/data/opentrail#password")
+	if err != nil || password != "hunter22" {
+		t.Errorf("Expected the KV version 2 value, got %q (%v)", password, err)
+	}
+	if keys, err := client.Read(ctx, "vault:secret/data/opentrail#keys"); err != nil || keys != `["a"]` {
+		t.Errorf("Expected a non-string value as JSON, got %q (%v)", keys, err)
+	}
+	if fake.reads.Load() != 1 {
+		t.Errorf("Expected the secret to be read once and cached, got %d reads", fake.reads.Load())
+	}
+	if _, err := client.Read(ctx, "vault:secret/data/opentrail#missing"); err == nil {
+		t.Error("Expected an error for a missing key")
+	}
+	if _, err := client.Read(ctx, "vault:secret/data/other#password"); err == nil {
+		t.Error("Expected an error for a missing secret")
+	}
+}
+
+func TestClient_TokenAndFiles(t *testing.T) {
+	server := httptest.NewServer(&fakeVault{})
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := New(Options{Address: server.URL, Auth: AuthToken, Token: "static-token"})
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	// A token that does not expire needs no renewal
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected renewal to stop for a token without expiry")
+	}
+
+	if value, err := client.Resolve(ctx, "plain"); err != nil || value != "plain" {
+		t.Errorf("Expected a plain value as it is, got %q (%v)", value, err)
+	}
+	if value, err := client.Resolve(ctx, "vault:kv/opentrail#cert"); err != nil || value != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Expected the KV version 1 value, got %q (%v)", value, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	os.WriteFile(path, []byte("on disk"), 0600)
+	if data, err := client.ReadFile(path); err != nil || string(data) != "on disk" {
+		t.Errorf("Expected the file read from disk, got %q (%v)", data, err)
+	}
+	if data, err := client.ReadFile("vault:kv/opentrail#cert"); err != nil || string(data) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Expected the file read from vault, got %q (%v)", data, err)
+	}
+
+	bad, _ := New(Options{Address: server.URL, Auth: AuthToken, Token: "revoked"})
+	if err := bad.Login(ctx); err == nil {
+		t.Error("Expected an error for an invalid token")
+	}
+}
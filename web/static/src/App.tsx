@@ -2,12 +2,29 @@ import React, { useState, useEffect, useCallback, useMemo, useRef } from 'react'
 import { ConnectionStatus } from './components/ConnectionStatus';
 import { FilterPanel } from './components/FilterPanel';
 import { DisplayPanel } from './components/DisplayPanel';
-import { LogContainer } from './components/LogContainer';
+import { AlertTimeline } from './components/AlertTimeline';
+import { AgentList } from './components/AgentList';
+import { AdminPanel } from './components/AdminPanel';
+import { ComparePanel } from './components/ComparePanel';
+import { HistogramPanel } from './components/HistogramPanel';
+import { LogContainer, type LogContainerHandle } from './components/LogContainer';
+import { ShortcutHelp } from './components/ShortcutHelp';
+import { EntryDetailDrawer } from './components/EntryDetailDrawer';
 import { useWebSocket } from './hooks/useWebSocket';
 import { useLocalStorage } from './hooks/useLocalStorage';
+import { useKeyboardShortcuts } from './hooks/useKeyboardShortcuts';
+import { useMediaQuery } from './hooks/useMediaQuery';
 import { ApiService } from './services/api';
-import { DEFAULT_DISPLAY_OPTIONS, STORAGE_KEYS } from './utils/constants';
-import type { LogEntry, LogFilters, DisplayOptions } from './types';
+import { LANGUAGES, useI18n, type Language } from './i18n';
+import {
+  CARD_RESULT_FIELDS,
+  DEFAULT_DISPLAY_OPTIONS,
+  DEFAULT_SHORTCUTS,
+  NARROW_SCREEN_QUERY,
+  STORAGE_KEYS
+} from './utils/constants';
+import { filterParams } from './utils/filters';
+import type { LogEntry, LogFilters, DisplayOptions, Session, Shortcut, UserPreferences } from './types';
 
 const MAX_RENDERED_LOGS = 500;
 const LOAD_BATCH_SIZE = 50;
@@ -20,6 +37,24 @@ const App: React.FC = () => {
   const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [hasMoreLogs, setHasMoreLogs] = useState(true);
   const [error, setError] = useState<string | null>(null);
+  const [shortcuts, setShortcuts] = useState<Shortcut[]>(DEFAULT_SHORTCUTS);
+  const [showShortcutHelp, setShowShortcutHelp] = useState(false);
+  const [detailId, setDetailId] = useState<string | null>(null);
+  const [focusSearchSignal, setFocusSearchSignal] = useState(0);
+  const [session, setSession] = useState<Session | null>(null);
+  // Matches of the applied filters in the server's tail buffer, null when it was not searched
+  const [bufferMatches, setBufferMatches] = useState<{ count: number; minutes: number } | null>(null);
+  // Preferences kept by the server, null until loaded or where the server does not keep them
+  const [preferences, setPreferences] = useState<UserPreferences | null>(null);
+  const preferencesRef = useRef<UserPreferences | null>(null);
+  preferencesRef.current = preferences;
+  // Screen reader announcement of live tail changes made from the keyboard
+  const [announcement, setAnnouncement] = useState('');
+  const logContainerRef = useRef<LogContainerHandle>(null);
+  const { t, language, setLanguage } = useI18n();
+  // Read through a ref so switching languages does not reload the logs
+  const tRef = useRef(t);
+  tRef.current = t;
   
   // Track the oldest log timestamp for pagination
   const oldestLogTimestamp = useRef<string | null>(null);
@@ -32,6 +67,15 @@ const App: React.FC = () => {
     DEFAULT_DISPLAY_OPTIONS
   );
 
+  // Cards replace lines on narrow screens unless a layout was chosen explicitly
+  const isNarrowScreen = useMediaQuery(NARROW_SCREEN_QUERY);
+  const cardLayout = displayOptions.layout === 'cards' ||
+    (displayOptions.layout !== 'lines' && isNarrowScreen);
+  // Cards show fewer fields, so only those are fetched
+  const resultFields = cardLayout ? CARD_RESULT_FIELDS : undefined;
+  const resultFieldsRef = useRef(resultFields);
+  resultFieldsRef.current = resultFields;
+
   // API service
   const apiService = useMemo(() => ApiService.getInstance(), []);
 
@@ -89,44 +133,53 @@ const App: React.FC = () => {
 
       // Hostname filter
       if (filters.hostname && 
-          !log.hostname.toLowerCase().includes(filters.hostname.toLowerCase())) {
+          !(log.hostname || '').toLowerCase().includes(filters.hostname.toLowerCase())) {
         return false;
       }
 
       // App name filter
       if (filters.appName && 
-          !log.app_name.toLowerCase().includes(filters.appName.toLowerCase())) {
+          !(log.app_name || '').toLowerCase().includes(filters.appName.toLowerCase())) {
         return false;
       }
 
       // Process ID filter
       if (filters.procId && 
-          !log.proc_id.toLowerCase().includes(filters.procId.toLowerCase())) {
+          !(log.proc_id || '').toLowerCase().includes(filters.procId.toLowerCase())) {
         return false;
       }
 
       // Message ID filter
       if (filters.msgId && 
-          !log.msg_id.toLowerCase().includes(filters.msgId.toLowerCase())) {
+          !(log.msg_id || '').toLowerCase().includes(filters.msgId.toLowerCase())) {
         return false;
       }
 
       // Text filter (search in message)
       if (filters.text && 
-          !log.message.toLowerCase().includes(filters.text.toLowerCase())) {
+          !(log.message || '').toLowerCase().includes(filters.text.toLowerCase())) {
         return false;
       }
 
+      // Structured data parameters (exact match)
+      for (const [field, value] of Object.entries(filters.structuredData ?? {})) {
+        const dot = field.indexOf('.');
+        const param = log.structured_data?.[field.slice(0, dot)]?.[field.slice(dot + 1)];
+        if (param === undefined || String(param) !== value) {
+          return false;
+        }
+      }
+
       return true;
     });
   }, [displayedLogs, filters]);
 
-  // Load initial logs
+  // Load initial logs, again when the layout changes which fields are fetched
   useEffect(() => {
     const loadInitialLogs = async () => {
       try {
         setError(null);
-        const logs = await apiService.fetchLogs(LOAD_BATCH_SIZE, 0);
+        const logs = await apiService.fetchLogs(LOAD_BATCH_SIZE, 0, resultFieldsRef.current);
         
         if (logs.length > 0) {
           // Logs should already be sorted by the API (newest first)
@@ -139,14 +192,14 @@ const App: React.FC = () => {
           setHasMoreLogs(logs.length === LOAD_BATCH_SIZE);
         }
       } catch (error) {
-        const errorMessage = error instanceof Error ? error.message : 'Failed to load initial logs';
+        const errorMessage = error instanceof Error ? error.message : tRef.current('app.loadInitialFailed');
         console.error('Failed to load initial logs:', errorMessage);
         setError(errorMessage);
       }
     };
 
     loadInitialLogs();
-  }, [apiService]);
+  }, [apiService, cardLayout]);
 
   // Load more logs (older logs when scrolling up)
   const handleLoadMore = useCallback(async () => {
@@ -155,7 +208,11 @@ const App: React.FC = () => {
     setIsLoadingMore(true);
     try {
       // Fetch older logs using timestamp-based pagination
-      const moreLogs = await apiService.fetchLogsBefore(oldestLogTimestamp.current, LOAD_BATCH_SIZE);
+      const moreLogs = await apiService.fetchLogsBefore(
+        oldestLogTimestamp.current,
+        LOAD_BATCH_SIZE,
+        resultFieldsRef.current
+      );
       
       if (moreLogs.length > 0) {
         setDisplayedLogs(prev => {
@@ -184,7 +241,7 @@ const App: React.FC = () => {
         setHasMoreLogs(false);
       }
     } catch (error) {
-      const errorMessage = error instanceof Error ? error.message : 'Failed to load more logs';
+      const errorMessage = error instanceof Error ? error.message : tRef.current('app.loadMoreFailed');
       console.error('Failed to load more logs:', errorMessage);
       setError(errorMessage);
       // Don't disable hasMoreLogs on error, allow retry
@@ -193,26 +250,172 @@ const App: React.FC = () => {
     }
   }, [apiService, isLoadingMore, hasMoreLogs]);
 
+  // updatePreferences changes the preferences kept by the server, once they were loaded
+  const updatePreferences = useCallback((change: (preferences: UserPreferences) => UserPreferences) => {
+    if (!preferencesRef.current) return;
+    const updated = change(preferencesRef.current);
+    preferencesRef.current = updated;
+    setPreferences(updated);
+    apiService.savePreferences(updated)
+      .catch(error => console.warn('Failed to save preferences:', error));
+  }, [apiService]);
+
   // Filter handlers
   const handleApplyFilters = useCallback(() => {
-    // Filters are applied automatically via useMemo
-    console.log('Filters applied:', filters);
-  }, [filters]);
+    // Filters are applied automatically via useMemo; applied filters join the recent queries
+    const params = filterParams(filters);
+    if (preferencesRef.current && Object.keys(params).length > 0) {
+      apiService.addRecentQuery(filters)
+        .then(setPreferences)
+        .catch(error => console.warn('Failed to record the query:', error));
+    }
+    if (Object.keys(params).length === 0) {
+      setBufferMatches(null);
+      return;
+    }
+
+    // The matches of the last minutes held by the server join the logs shown, so the filters
+    // cover more than the logs loaded so far without a search of storage
+    params.limit = String(MAX_RENDERED_LOGS);
+    if (resultFieldsRef.current) params.fields = resultFieldsRef.current.join(',');
+    apiService.fetchRecentBuffer(params)
+      .then(result => {
+        setBufferMatches({ count: result.entries.length, minutes: Math.round(result.window_seconds / 60) });
+        setDisplayedLogs(prev => {
+          const shown = new Set(prev.map(log => log.id));
+          const added = result.entries.filter(log => !shown.has(log.id));
+          if (added.length === 0) return prev;
+          const merged = [...prev, ...added]
+            .sort((a, b) => new Date(a.timestamp).getTime() - new Date(b.timestamp).getTime())
+            .slice(-MAX_RENDERED_LOGS);
+          oldestLogTimestamp.current = merged[0].timestamp;
+          newestLogTimestamp.current = merged[merged.length - 1].timestamp;
+          return merged;
+        });
+      })
+      .catch(error => {
+        // Servers without the buffer only filter the logs loaded
+        setBufferMatches(null);
+        console.warn('Failed to search the tail buffer:', error);
+      });
+  }, [apiService, filters]);
+
+  const handlePinFilters = useCallback((name: string) => {
+    updatePreferences(prev => ({
+      ...prev,
+      pinned_filters: [...prev.pinned_filters.filter(pinned => pinned.name !== name), { name, filters }]
+    }));
+  }, [filters, updatePreferences]);
+
+  const handleUnpinFilter = useCallback((name: string) => {
+    updatePreferences(prev => ({
+      ...prev,
+      pinned_filters: prev.pinned_filters.filter(pinned => pinned.name !== name)
+    }));
+  }, [updatePreferences]);
 
   const handleClearFilters = useCallback(() => {
     setFilters({});
+    setBufferMatches(null);
+  }, []);
+
+  // Pivoting from the detail drawer narrows the current filters to a field's value
+  const handlePivot = useCallback((pivot: LogFilters) => {
+    setFilters(prev => ({
+      ...prev,
+      ...pivot,
+      structuredData: pivot.structuredData
+        ? { ...prev.structuredData, ...pivot.structuredData }
+        : prev.structuredData
+    }));
   }, []);
 
+  // The server's shortcut map replaces the built-in one; the built-in map stays if it cannot be loaded
+  useEffect(() => {
+    apiService.fetchShortcuts()
+      .then(setShortcuts)
+      .catch(error => console.warn('Failed to load keyboard shortcuts:', error));
+  }, [apiService]);
+
+  // The admin section is only shown once the server confirms the admin role
+  useEffect(() => {
+    apiService.fetchSession()
+      .then(setSession)
+      .catch(error => console.warn('Failed to load the session:', error));
+  }, [apiService]);
+
+  // Display options saved on the server follow the user across browsers and replace the local ones
+  useEffect(() => {
+    apiService.fetchPreferences()
+      .then(loaded => {
+        setPreferences(loaded);
+        if (loaded.display) {
+          setDisplayOptions({ ...DEFAULT_DISPLAY_OPTIONS, ...loaded.display });
+        }
+      })
+      .catch(error => console.warn('Failed to load preferences:', error));
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [apiService]);
+
+  // While a dialog is open it is the only thing the keyboard operates
+  const dialogOpen = showShortcutHelp || detailId !== null;
+  const closeShortcut = () => {
+    if (showShortcutHelp) {
+      setShowShortcutHelp(false);
+    } else if (detailId !== null) {
+      setDetailId(null);
+    } else if (document.activeElement instanceof HTMLElement) {
+      document.activeElement.blur();
+    }
+  };
+  useKeyboardShortcuts(shortcuts, dialogOpen ? { close: closeShortcut } : {
+    'focus-search': () => setFocusSearchSignal(signal => signal + 1),
+    'next-entry': () => logContainerRef.current?.moveSelection(1),
+    'previous-entry': () => logContainerRef.current?.moveSelection(-1),
+    'toggle-details': () => logContainerRef.current?.toggleSelectedDetails(),
+    'toggle-live-tail': () => {
+      setAutoScroll(!autoScroll);
+      setAnnouncement(t(autoScroll ? 'logs.liveTailOff' : 'logs.liveTailOn'));
+    },
+    'show-help': () => setShowShortcutHelp(true),
+    close: closeShortcut
+  });
+
   // Display option handlers
+  const handleDisplayOptionsChange = useCallback((options: DisplayOptions) => {
+    setDisplayOptions(options);
+    updatePreferences(prev => ({ ...prev, display: options }));
+  }, [setDisplayOptions, updatePreferences]);
+
   const handleResetDisplayOptions = useCallback(() => {
-    setDisplayOptions(DEFAULT_DISPLAY_OPTIONS);
-  }, [setDisplayOptions]);
+    handleDisplayOptionsChange(DEFAULT_DISPLAY_OPTIONS);
+  }, [handleDisplayOptionsChange]);
 
   return (
     <div className="container">
       <header className="header">
         <h1 className="title">OpenTrail</h1>
-        <ConnectionStatus connectionStatus={connectionStatus} />
+        <div className="header-controls">
+          <select
+            className="language-select"
+            value={language}
+            onChange={(e) => setLanguage(e.target.value as Language)}
+            aria-label={t('language.label')}
+          >
+            {Object.entries(LANGUAGES).map(([code, name]) => (
+              <option key={code} value={code}>{name}</option>
+            ))}
+          </select>
+          <button
+            className="shortcut-help-button"
+            onClick={() => setShowShortcutHelp(true)}
+            title={t('shortcuts.hint')}
+            aria-label={t('shortcuts.title')}
+          >
+            ?
+          </button>
+          <ConnectionStatus connectionStatus={connectionStatus} />
+        </div>
       </header>
       
       <main className="main">
@@ -221,37 +424,79 @@ const App: React.FC = () => {
           onFiltersChange={setFilters}
           onApplyFilters={handleApplyFilters}
           onClearFilters={handleClearFilters}
+          focusSearchSignal={focusSearchSignal}
+          pinnedFilters={preferences?.pinned_filters}
+          recentQueries={preferences?.recent_queries}
+          onPinFilters={preferences ? handlePinFilters : undefined}
+          onUnpinFilter={preferences ? handleUnpinFilter : undefined}
         />
         
         <DisplayPanel
           displayOptions={displayOptions}
-          onDisplayOptionsChange={setDisplayOptions}
+          onDisplayOptionsChange={handleDisplayOptionsChange}
           onResetDisplayOptions={handleResetDisplayOptions}
         />
+
+        <AlertTimeline />
+
+        {session?.role === 'admin' && (
+          <>
+            <AdminPanel />
+            <AgentList />
+          </>
+        )}
+
+        <ComparePanel />
+
+        <HistogramPanel filters={filters} />
         
         {error && (
-          <div className="error-banner">
+          <div className="error-banner" role="alert">
             <span className="error-message">{error}</span>
             <button 
               className="error-dismiss" 
               onClick={() => setError(null)}
-              aria-label="Dismiss error"
+              aria-label={t('app.dismissError')}
             >
               ×
             </button>
           </div>
         )}
         
+        {bufferMatches && (
+          <div className="buffer-matches" role="status">
+            {t('logs.bufferMatches', bufferMatches)}
+          </div>
+        )}
+
         <LogContainer
+          ref={logContainerRef}
           logs={filteredLogs}
           displayOptions={displayOptions}
+          cardLayout={cardLayout}
           autoScroll={autoScroll}
           onAutoScrollChange={setAutoScroll}
           onLoadMore={handleLoadMore}
           isLoadingMore={isLoadingMore}
           hasMoreLogs={hasMoreLogs}
+          onOpenDetail={setDetailId}
         />
+
+        <div className="sr-only" role="status" aria-live="polite">{announcement}</div>
       </main>
+
+      {detailId !== null && (
+        <EntryDetailDrawer
+          entryId={detailId}
+          ansiColors={displayOptions.ansiColors ?? true}
+          onClose={() => setDetailId(null)}
+          onPivot={handlePivot}
+        />
+      )}
+
+      {showShortcutHelp && (
+        <ShortcutHelp shortcuts={shortcuts} onClose={() => setShowShortcutHelp(false)} />
+      )}
     </div>
   );
 };
@@ -0,0 +1,784 @@
+import React, { useState, useEffect, useCallback } from 'react';
+import { ChevronDown, ChevronRight } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n, type TranslationKey } from '../i18n';
+import { splitBytes } from '../utils/formatters';
+import type {
+  ApiToken, EntrySizes, HealthStatus, IngestConnection, OperatingMode, Report, RetentionStatus, Role, StorageUsage,
+  User
+} from '../types';
+
+const REFRESH_MS = 15000;
+
+type Tab = 'server' | 'storage' | 'connections' | 'sizes' | 'notifications' | 'users' | 'tokens' | 'alerts';
+const TABS: Tab[] = ['server', 'storage', 'connections', 'sizes', 'notifications', 'users', 'tokens', 'alerts'];
+const MODES: OperatingMode[] = ['normal', 'read_only', 'maintenance'];
+const ROLES: Role[] = ['reader', 'admin'];
+// The longest retention the server accepts, in days
+const MAX_RETENTION_DAYS = 3650;
+// Expiry choices of new API tokens in days, 0 for none
+const TOKEN_DAYS = [0, 30, 90, 365];
+// The alert rule form, with its numbers as typed
+const EMPTY_ALERT = { name: '', query: '', threshold: '10', minutes: '5', extrapolate: false };
+
+// The admin section: server statistics and operating mode, storage and retention, ingestion
+// connections, entry sizes per app, notification channels, users, API tokens and alert rules. It
+// is only rendered for the admin role.
+export const AdminPanel: React.FC = () => {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [tab, setTab] = useState<Tab>('server');
+  const [health, setHealth] = useState<HealthStatus | null>(null);
+  const [usage, setUsage] = useState<StorageUsage | null>(null);
+  const [connections, setConnections] = useState<IngestConnection[]>([]);
+  const [sizes, setSizes] = useState<EntrySizes | null>(null);
+  const [channels, setChannels] = useState<string[]>([]);
+  const [retention, setRetention] = useState<RetentionStatus | null>(null);
+  const [retentionDays, setRetentionDays] = useState('');
+  const [users, setUsers] = useState<User[]>([]);
+  const [newUser, setNewUser] = useState({ username: '', password: '', role: 'reader' as Role });
+  // Changes typed into the rows of the user table, by username
+  const [userEdits, setUserEdits] = useState<Record<string, { password: string; role: Role }>>({});
+  const [tokens, setTokens] = useState<ApiToken[]>([]);
+  const [newToken, setNewToken] = useState({ name: '', role: 'reader' as Role, days: 90 });
+  // The token just created, whose secret is shown until the tab is left
+  const [createdToken, setCreatedToken] = useState<ApiToken | null>(null);
+  // Alert rules are the scheduled reports with an alert threshold
+  const [alerts, setAlerts] = useState<Report[]>([]);
+  // The alert rule being edited, null while the form adds one
+  const [editing, setEditing] = useState<Report | null>(null);
+  const [alertForm, setAlertForm] = useState(EMPTY_ALERT);
+  const [mode, setMode] = useState<OperatingMode>('normal');
+  const [modeMessage, setModeMessage] = useState('');
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+  const [notice, setNotice] = useState<string | null>(null);
+  const { t, formatNumber, formatDateTime } = useI18n();
+
+  const errorMessage = useCallback(
+    (err: unknown) => (err instanceof Error ? err.message : t('admin.loadFailed')),
+    [t]
+  );
+
+  const load = useCallback(async (initial: boolean) => {
+    const api = ApiService.getInstance();
+    try {
+      switch (tab) {
+        case 'server': {
+          const status = await api.fetchHealth();
+          setHealth(status);
+          // The form keeps what is being edited while the statistics refresh
+          if (initial) {
+            const current = status.services.mode.mode;
+            setMode(MODES.includes(current) ? current : 'normal');
+            setModeMessage(status.services.mode.message || '');
+          }
+          break;
+        }
+        case 'storage': {
+          const [storage, status] = await Promise.all([api.fetchStorageUsage(), api.fetchRetention()]);
+          setUsage(storage);
+          setRetention(status);
+          if (initial) setRetentionDays(String(status.days));
+          break;
+        }
+        case 'connections':
+          setConnections(await api.fetchConnections());
+          break;
+        case 'sizes':
+          setSizes(await api.fetchEntrySizes());
+          break;
+        case 'notifications':
+          setChannels(await api.fetchNotificationChannels());
+          break;
+        case 'users':
+          setUsers(await api.fetchUsers());
+          break;
+        case 'tokens':
+          setTokens(await api.fetchApiTokens());
+          break;
+        case 'alerts':
+          setAlerts((await api.fetchReports()).filter(report => (report.alert_threshold ?? 0) > 0));
+          break;
+      }
+      setError(null);
+    } catch (err) {
+      setError(errorMessage(err));
+    }
+  }, [tab, errorMessage]);
+
+  // Refreshed while shown, like the agent list
+  useEffect(() => {
+    if (!isExpanded) return;
+    setNotice(null);
+    setCreatedToken(null);
+    load(true);
+    const timer = setInterval(() => load(false), REFRESH_MS);
+    return () => clearInterval(timer);
+  }, [isExpanded, load]);
+
+  // run performs an action, reporting its outcome in the notice or error line
+  const run = async (action: () => Promise<unknown>, success: string) => {
+    setBusy(true);
+    setNotice(null);
+    try {
+      await action();
+      setError(null);
+      setNotice(success);
+      await load(false);
+    } catch (err) {
+      setError(errorMessage(err));
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const formatBytes = (bytes: number) => {
+    const [value, unit] = splitBytes(bytes);
+    return `${formatNumber(value)} ${unit}`;
+  };
+
+  const stat = (label: TranslationKey, value: React.ReactNode) => (
+    <div className="admin-stat" key={label}>
+      <dt>{t(label)}</dt>
+      <dd>{value}</dd>
+    </div>
+  );
+
+  const renderServer = () => {
+    if (!health) return null;
+    const service = health.services.log_service;
+    const http = health.services.http_server;
+    const status = health.services.mode;
+    return (
+      <>
+        {status.mode === 'degraded' && (
+          <div className="alert-timeline-empty" role="alert">{t('admin.degraded', { message: status.message || '' })}</div>
+        )}
+        <dl className="admin-stats">
+          {stat('admin.version', health.version)}
+          {stat('admin.processed', formatNumber(service.processed_logs))}
+          {stat('admin.failed', formatNumber(service.failed_logs))}
+          {stat('admin.duplicates', formatNumber(service.duplicate_logs))}
+          {status.mode === 'degraded' || service.spooled_logs || service.unstored_logs ? (
+            <>
+              {stat('admin.spooled', formatNumber(service.spooled_logs))}
+              {stat('admin.unstored', formatNumber(service.unstored_logs))}
+            </>
+          ) : null}
+          {stat('admin.queue', formatNumber(service.queue_size))}
+          {stat('admin.subscribers', formatNumber(service.active_subscribers))}
+          {stat('admin.rejectedSearches', formatNumber(service.rejected_searches))}
+          {stat('admin.requests', formatNumber(http.requests_handled))}
+          {stat('admin.requestErrors', formatNumber(http.request_errors))}
+          {stat('admin.rateLimited', formatNumber(http.rate_limited))}
+        </dl>
+        <div className="compare-controls">
+          <label>
+            {t('admin.mode')}
+            <select value={mode} onChange={e => setMode(e.target.value as OperatingMode)}>
+              {MODES.map(value => (
+                <option key={value} value={value}>{t(`admin.mode.${value}`)}</option>
+              ))}
+            </select>
+          </label>
+          {mode !== 'normal' && (
+            <label>
+              {t('admin.modeMessage')}
+              <input type="text" value={modeMessage} onChange={e => setModeMessage(e.target.value)} />
+            </label>
+          )}
+          <button
+            className="display-toggle"
+            disabled={busy}
+            onClick={() => run(
+              () => ApiService.getInstance().setMode(mode, mode === 'normal' ? '' : modeMessage),
+              t('admin.modeChanged', { mode: t(`admin.mode.${mode}`) })
+            )}
+          >
+            {t('admin.apply')}
+          </button>
+        </div>
+      </>
+    );
+  };
+
+  const renderStorage = () => {
+    if (!usage) return null;
+    const days = Number(retentionDays);
+    const validDays = Number.isInteger(days) && days >= 1 && days <= MAX_RETENTION_DAYS;
+    return (
+      <>
+        <dl className="admin-stats">
+          {stat('admin.database', formatBytes(usage.database_bytes + usage.wal_bytes))}
+          {stat('admin.limit', usage.limit_bytes ? formatBytes(usage.limit_bytes) : t('admin.none'))}
+          {stat('admin.diskFree', usage.disk_free_bytes ? formatBytes(usage.disk_free_bytes) : t('admin.unknown'))}
+          {stat('admin.retention', t('admin.days', { count: usage.retention_days }))}
+          {stat('admin.growth', formatBytes(usage.growth_bytes_per_day))}
+          {stat('admin.retained', formatBytes(usage.retained_bytes))}
+          {stat('admin.untilLimit', usage.days_until_limit === undefined
+            ? t('admin.never')
+            : t('admin.days', { count: Math.floor(usage.days_until_limit) }))}
+        </dl>
+        {retention?.overridden && (
+          <div className="alert-timeline-empty">
+            {t('admin.retentionConfigured', { days: t('admin.days', { count: retention.configured_days }) })}
+          </div>
+        )}
+        <div className="compare-controls">
+          <label>
+            {t('admin.retentionDays')}
+            <input
+              type="number"
+              min={1}
+              max={MAX_RETENTION_DAYS}
+              value={retentionDays}
+              onChange={e => setRetentionDays(e.target.value)}
+            />
+          </label>
+          <button
+            className="display-toggle"
+            disabled={busy || !validDays || days === retention?.days}
+            onClick={() => run(
+              () => ApiService.getInstance().updateRetention(days),
+              t('admin.retentionChanged', { days: t('admin.days', { count: days }) })
+            )}
+          >
+            {t('admin.apply')}
+          </button>
+          {retention?.overridden && (
+            <button
+              className="display-toggle"
+              disabled={busy}
+              onClick={() => run(async () => {
+                const status = await ApiService.getInstance().resetRetention();
+                setRetentionDays(String(status.days));
+              }, t('admin.retentionReset', { days: t('admin.days', { count: retention.configured_days }) }))}
+            >
+              {t('admin.resetRetention')}
+            </button>
+          )}
+        </div>
+      </>
+    );
+  };
+
+  const renderConnections = () => {
+    if (connections.length === 0) {
+      return <div className="alert-timeline-empty">{t('admin.noConnections')}</div>;
+    }
+    return (
+      <table className="agent-table">
+        <thead>
+          <tr>
+            <th scope="col">{t('admin.remote')}</th>
+            <th scope="col">{t('admin.connectedAt')}</th>
+            <th scope="col">{t('admin.messages')}</th>
+            <th scope="col">{t('admin.bytes')}</th>
+            <th scope="col">{t('admin.parseErrors')}</th>
+            <th scope="col"><span className="sr-only">{t('admin.close')}</span></th>
+          </tr>
+        </thead>
+        <tbody>
+          {connections.map(connection => (
+            <tr key={connection.id}>
+              <td>
+                {connection.remote_addr}
+                {connection.tenant && <span className="agent-version"> {connection.tenant}</span>}
+              </td>
+              <td>{formatDateTime(connection.connected_at)}</td>
+              <td>{formatNumber(connection.messages_received)}</td>
+              <td>{formatBytes(connection.bytes_received)}</td>
+              <td className={connection.parse_errors > 0 ? 'agent-outdated' : ''}>
+                {formatNumber(connection.parse_errors)}
+              </td>
+              <td>
+                <button
+                  className="display-toggle"
+                  disabled={busy}
+                  onClick={() => run(
+                    () => ApiService.getInstance().closeConnection(connection.id),
+                    t('admin.connectionClosed', { remote: connection.remote_addr })
+                  )}
+                >
+                  {t('admin.close')}
+                </button>
+              </td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    );
+  };
+
+  // Apps come largest 95th percentile message first, so oversized senders top the table
+  const renderSizes = () => {
+    if (!sizes) return null;
+    return (
+      <>
+        {sizes.apps.length === 0 ? (
+          <div className="alert-timeline-empty">{t('admin.noSizes')}</div>
+        ) : (
+          <table className="agent-table">
+            <thead>
+              <tr>
+                <th scope="col">{t('admin.app')}</th>
+                <th scope="col">{t('admin.entries')}</th>
+                <th scope="col">{t('admin.messageAvg')}</th>
+                <th scope="col">{t('admin.messageP95')}</th>
+                <th scope="col">{t('admin.messageMax')}</th>
+                <th scope="col">{t('admin.structuredDataAvg')}</th>
+                <th scope="col">{t('admin.structuredDataP95')}</th>
+              </tr>
+            </thead>
+            <tbody>
+              {sizes.apps.map(app => (
+                <tr key={app.app_name}>
+                  <td>{app.app_name}</td>
+                  <td>{formatNumber(app.entries)}</td>
+                  <td>{formatBytes(app.message.avg_bytes)}</td>
+                  <td>{formatBytes(app.message.p95_bytes)}</td>
+                  <td>{formatBytes(app.message.max_bytes)}</td>
+                  <td>{formatBytes(app.structured_data.avg_bytes)}</td>
+                  <td>{formatBytes(app.structured_data.p95_bytes)}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+        <div className="compare-controls">
+          <button
+            className="display-toggle"
+            disabled={busy}
+            onClick={() => run(() => ApiService.getInstance().resetEntrySizes(), t('admin.sizesReset'))}
+          >
+            {t('admin.resetSizes')}
+          </button>
+        </div>
+      </>
+    );
+  };
+
+  const renderNotifications = () => {
+    if (channels.length === 0) {
+      return <div className="alert-timeline-empty">{t('admin.noChannels')}</div>;
+    }
+    return (
+      <table className="agent-table">
+        <tbody>
+          {channels.map(channel => (
+            <tr key={channel}>
+              <td>{channel}</td>
+              <td>
+                <button
+                  className="display-toggle"
+                  disabled={busy}
+                  onClick={() => run(
+                    () => ApiService.getInstance().sendTestNotification(channel),
+                    t('admin.testSent', { channel })
+                  )}
+                >
+                  {t('admin.sendTest')}
+                </button>
+              </td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    );
+  };
+
+  const roleSelect = (value: Role, onChange: (role: Role) => void) => (
+    <select value={value} onChange={e => onChange(e.target.value as Role)}>
+      {ROLES.map(role => (
+        <option key={role} value={role}>{t(`admin.role.${role}`)}</option>
+      ))}
+    </select>
+  );
+
+  const renderUsers = () => {
+    const edit = (user: User) => userEdits[user.username] ?? { password: '', role: user.role };
+    const setEdit = (user: User, change: Partial<{ password: string; role: Role }>) =>
+      setUserEdits(edits => ({ ...edits, [user.username]: { ...edit(user), ...change } }));
+    const forget = (username: string) => setUserEdits(edits => {
+      const { [username]: _, ...rest } = edits;
+      return rest;
+    });
+    return (
+      <>
+        {users.length === 0 ? (
+          <div className="alert-timeline-empty">{t('admin.noUsers')}</div>
+        ) : (
+          <table className="agent-table">
+            <thead>
+              <tr>
+                <th scope="col">{t('admin.username')}</th>
+                <th scope="col">{t('admin.role')}</th>
+                <th scope="col">{t('admin.newPassword')}</th>
+                <th scope="col">{t('admin.created')}</th>
+                <th scope="col"><span className="sr-only">{t('admin.save')}</span></th>
+              </tr>
+            </thead>
+            <tbody>
+              {users.map(user => (
+                <tr key={user.username}>
+                  <td>{user.username}</td>
+                  <td>{roleSelect(edit(user).role, role => setEdit(user, { role }))}</td>
+                  <td>
+                    <input
+                      type="password"
+                      autoComplete="new-password"
+                      aria-label={t('admin.newPassword')}
+                      value={edit(user).password}
+                      onChange={e => setEdit(user, { password: e.target.value })}
+                    />
+                  </td>
+                  <td>{formatDateTime(user.created_at)}</td>
+                  <td>
+                    <button
+                      className="display-toggle"
+                      disabled={busy || (edit(user).password === '' && edit(user).role === user.role)}
+                      onClick={() => run(async () => {
+                        await ApiService.getInstance().updateUser(user.username, edit(user).password, edit(user).role);
+                        forget(user.username);
+                      }, t('admin.userUpdated', { username: user.username }))}
+                    >
+                      {t('admin.save')}
+                    </button>
+                    <button
+                      className="display-toggle"
+                      disabled={busy}
+                      onClick={() => run(async () => {
+                        await ApiService.getInstance().deleteUser(user.username);
+                        forget(user.username);
+                      }, t('admin.userDeleted', { username: user.username }))}
+                    >
+                      {t('admin.delete')}
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+        <div className="compare-controls">
+          <label>
+            {t('admin.username')}
+            <input
+              type="text"
+              autoComplete="off"
+              value={newUser.username}
+              onChange={e => setNewUser({ ...newUser, username: e.target.value })}
+            />
+          </label>
+          <label>
+            {t('admin.password')}
+            <input
+              type="password"
+              autoComplete="new-password"
+              value={newUser.password}
+              onChange={e => setNewUser({ ...newUser, password: e.target.value })}
+            />
+          </label>
+          <label>
+            {t('admin.role')}
+            {roleSelect(newUser.role, role => setNewUser({ ...newUser, role }))}
+          </label>
+          <button
+            className="display-toggle"
+            disabled={busy || !newUser.username.trim() || !newUser.password}
+            onClick={() => run(async () => {
+              await ApiService.getInstance().createUser(newUser.username.trim(), newUser.password, newUser.role);
+              setNewUser({ username: '', password: '', role: newUser.role });
+            }, t('admin.userCreated', { username: newUser.username.trim() }))}
+          >
+            {t('admin.add')}
+          </button>
+        </div>
+      </>
+    );
+  };
+
+  const renderTokens = () => (
+    <>
+      {createdToken && (
+        <div className="alert-timeline-empty" role="status">
+          {t('admin.tokenCreated', { name: createdToken.name })} <code>{createdToken.token}</code>
+        </div>
+      )}
+      {tokens.length === 0 ? (
+        <div className="alert-timeline-empty">{t('admin.noTokens')}</div>
+      ) : (
+        <table className="agent-table">
+          <thead>
+            <tr>
+              <th scope="col">{t('admin.tokenName')}</th>
+              <th scope="col">{t('admin.role')}</th>
+              <th scope="col">{t('admin.createdBy')}</th>
+              <th scope="col">{t('admin.created')}</th>
+              <th scope="col">{t('admin.expires')}</th>
+              <th scope="col">{t('admin.lastUsed')}</th>
+              <th scope="col"><span className="sr-only">{t('admin.revoke')}</span></th>
+            </tr>
+          </thead>
+          <tbody>
+            {tokens.map(token => (
+              <tr key={token.id}>
+                <td>{token.name}</td>
+                <td>{t(`admin.role.${token.role}`)}</td>
+                <td>{token.created_by || '-'}</td>
+                <td>{formatDateTime(token.created_at)}</td>
+                <td>{token.expires_at ? formatDateTime(token.expires_at) : t('admin.never')}</td>
+                <td>{token.last_used_at ? formatDateTime(token.last_used_at) : t('admin.never')}</td>
+                <td>
+                  <button
+                    className="display-toggle"
+                    disabled={busy}
+                    onClick={() => run(
+                      () => ApiService.getInstance().deleteApiToken(token.id),
+                      t('admin.tokenDeleted', { name: token.name })
+                    )}
+                  >
+                    {t('admin.revoke')}
+                  </button>
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      )}
+      <div className="compare-controls">
+        <label>
+          {t('admin.tokenName')}
+          <input
+            type="text"
+            autoComplete="off"
+            value={newToken.name}
+            onChange={e => setNewToken({ ...newToken, name: e.target.value })}
+          />
+        </label>
+        <label>
+          {t('admin.role')}
+          {roleSelect(newToken.role, role => setNewToken({ ...newToken, role }))}
+        </label>
+        <label>
+          {t('admin.expiresIn')}
+          <select value={newToken.days} onChange={e => setNewToken({ ...newToken, days: Number(e.target.value) })}>
+            {TOKEN_DAYS.map(days => (
+              <option key={days} value={days}>{days ? t('admin.days', { count: days }) : t('admin.never')}</option>
+            ))}
+          </select>
+        </label>
+        <button
+          className="display-toggle"
+          disabled={busy || !newToken.name.trim()}
+          onClick={() => {
+            setCreatedToken(null);
+            run(async () => {
+              setCreatedToken(await ApiService.getInstance().createApiToken(
+                newToken.name.trim(), newToken.role, newToken.days * 86400
+              ));
+              setNewToken({ ...newToken, name: '' });
+            }, '');
+          }}
+        >
+          {t('admin.add')}
+        </button>
+      </div>
+    </>
+  );
+
+  const editAlert = (report: Report | null) => {
+    setEditing(report);
+    setAlertForm(report ? {
+      name: report.name,
+      query: report.query ?? '',
+      threshold: String(report.alert_threshold ?? ''),
+      minutes: String(Math.round(report.interval_seconds / 60)),
+      extrapolate: !!report.extrapolate
+    } : EMPTY_ALERT);
+  };
+
+  const renderAlerts = () => {
+    const threshold = Number(alertForm.threshold);
+    const minutes = Number(alertForm.minutes);
+    const valid = alertForm.name.trim() !== '' && Number.isInteger(threshold) && threshold >= 1 &&
+      Number.isInteger(minutes) && minutes >= 1;
+    const save = () => {
+      const report = {
+        name: alertForm.name.trim(),
+        query: alertForm.query.trim(),
+        top_field: editing?.top_field,
+        top_limit: editing?.top_limit,
+        interval_seconds: minutes * 60,
+        alert_threshold: threshold,
+        extrapolate: alertForm.extrapolate
+      };
+      const api = ApiService.getInstance();
+      run(async () => {
+        await (editing ? api.updateReport(editing.id, report) : api.createReport(report));
+        editAlert(null);
+      }, t(editing ? 'admin.alertUpdated' : 'admin.alertCreated', { name: report.name }));
+    };
+    return (
+      <>
+        {alerts.length === 0 ? (
+          <div className="alert-timeline-empty">{t('admin.noAlerts')}</div>
+        ) : (
+          <table className="agent-table">
+            <thead>
+              <tr>
+                <th scope="col">{t('admin.alertName')}</th>
+                <th scope="col">{t('admin.alertQuery')}</th>
+                <th scope="col">{t('admin.alertThreshold')}</th>
+                <th scope="col">{t('admin.alertInterval')}</th>
+                <th scope="col">{t('admin.alertState')}</th>
+                <th scope="col">{t('admin.lastRun')}</th>
+                <th scope="col"><span className="sr-only">{t('admin.edit')}</span></th>
+              </tr>
+            </thead>
+            <tbody>
+              {alerts.map(report => (
+                <tr key={report.id}>
+                  <td>{report.name}</td>
+                  <td><code>{report.query || '*'}</code></td>
+                  <td>{formatNumber(report.alert_threshold ?? 0)}</td>
+                  <td>{t('admin.minutes', { count: Math.round(report.interval_seconds / 60) })}</td>
+                  <td className={report.firing ? 'agent-outdated' : ''}>
+                    {report.firing ? t('admin.alertFiring') : t('admin.alertOk')}
+                  </td>
+                  <td>{report.last_run_at ? formatDateTime(report.last_run_at) : t('admin.never')}</td>
+                  <td>
+                    <button className="display-toggle" disabled={busy} onClick={() => editAlert(report)}>
+                      {t('admin.edit')}
+                    </button>
+                    <button
+                      className="display-toggle"
+                      disabled={busy}
+                      onClick={() => run(async () => {
+                        await ApiService.getInstance().deleteReport(report.id);
+                        if (editing?.id === report.id) editAlert(null);
+                      }, t('admin.alertDeleted', { name: report.name }))}
+                    >
+                      {t('admin.delete')}
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+        <div className="compare-controls">
+          <label>
+            {t('admin.alertName')}
+            <input
+              type="text"
+              value={alertForm.name}
+              onChange={e => setAlertForm({ ...alertForm, name: e.target.value })}
+            />
+          </label>
+          <label>
+            {t('admin.alertQuery')}
+            <input
+              type="text"
+              placeholder="severity<=err app:api"
+              value={alertForm.query}
+              onChange={e => setAlertForm({ ...alertForm, query: e.target.value })}
+            />
+          </label>
+          <label>
+            {t('admin.alertThreshold')}
+            <input
+              type="number"
+              min={1}
+              value={alertForm.threshold}
+              onChange={e => setAlertForm({ ...alertForm, threshold: e.target.value })}
+            />
+          </label>
+          <label>
+            {t('admin.alertMinutes')}
+            <input
+              type="number"
+              min={1}
+              value={alertForm.minutes}
+              onChange={e => setAlertForm({ ...alertForm, minutes: e.target.value })}
+            />
+          </label>
+          <label>
+            <input
+              type="checkbox"
+              checked={alertForm.extrapolate}
+              onChange={e => setAlertForm({ ...alertForm, extrapolate: e.target.checked })}
+            />
+            {t('admin.alertExtrapolate')}
+          </label>
+          <button className="display-toggle" disabled={busy || !valid} onClick={save}>
+            {editing ? t('admin.save') : t('admin.add')}
+          </button>
+          {editing && (
+            <button className="display-toggle" disabled={busy} onClick={() => editAlert(null)}>
+              {t('admin.cancel')}
+            </button>
+          )}
+        </div>
+      </>
+    );
+  };
+
+  return (
+    <div className="alert-panel">
+      <div className="display-header">
+        <h3>{t('admin.title')}</h3>
+        <button
+          className="display-toggle"
+          onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="admin-content"
+        >
+          {isExpanded ? (
+            <>
+              <ChevronDown size={16} />
+              {t('admin.hide')}
+            </>
+          ) : (
+            <>
+              <ChevronRight size={16} />
+              {t('admin.show')}
+            </>
+          )}
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="display-content" id="admin-content">
+          <div className="admin-tabs" role="tablist">
+            {TABS.map(value => (
+              <button
+                key={value}
+                role="tab"
+                aria-selected={tab === value}
+                className={`admin-tab${tab === value ? ' active' : ''}`}
+                onClick={() => setTab(value)}
+              >
+                {t(`admin.tab.${value}`)}
+              </button>
+            ))}
+          </div>
+
+          {error && <div className="alert-timeline-empty" role="alert">{error}</div>}
+          {notice && <div className="alert-timeline-empty" role="status">{notice}</div>}
+
+          <div role="tabpanel">
+            {tab === 'server' && renderServer()}
+            {tab === 'storage' && renderStorage()}
+            {tab === 'connections' && renderConnections()}
+            {tab === 'sizes' && renderSizes()}
+            {tab === 'notifications' && renderNotifications()}
+            {tab === 'users' && renderUsers()}
+            {tab === 'tokens' && renderTokens()}
+            {tab === 'alerts' && renderAlerts()}
+          </div>
+        </div>
+      )}
+    </div>
+  );
+};
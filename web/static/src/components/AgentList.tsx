@@ -0,0 +1,109 @@
+import React, { useState, useEffect } from 'react';
+import { ChevronDown, ChevronRight } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n } from '../i18n';
+import { splitBytes } from '../utils/formatters';
+import type { AgentStatus } from '../types';
+
+const REFRESH_MS = 15000;
+
+export const AgentList: React.FC = () => {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [agents, setAgents] = useState<AgentStatus[]>([]);
+  const [error, setError] = useState<string | null>(null);
+  const { t, formatNumber, formatDateTime } = useI18n();
+
+  // Refreshed while shown, so lag and missed heartbeats show up without reloading
+  useEffect(() => {
+    if (!isExpanded) return;
+    const load = () => ApiService.getInstance()
+      .fetchAgents()
+      .then(list => {
+        setAgents(list);
+        setError(null);
+      })
+      .catch(err => setError(err instanceof Error ? err.message : t('agents.loadFailed')));
+    load();
+    const timer = setInterval(load, REFRESH_MS);
+    return () => clearInterval(timer);
+  }, [isExpanded, t]);
+
+  const formatLag = (bytes: number) => {
+    const [value, unit] = splitBytes(bytes);
+    return `${formatNumber(value)} ${unit}`;
+  };
+
+  return (
+    <div className="alert-panel">
+      <div className="display-header">
+        <h3>{t('agents.title')}</h3>
+        <button
+          className="display-toggle"
+          onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="agent-content"
+        >
+          {isExpanded ? (
+            <>
+              <ChevronDown size={16} />
+              {t('agents.hide')}
+            </>
+          ) : (
+            <>
+              <ChevronRight size={16} />
+              {t('agents.show')}
+            </>
+          )}
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="display-content" id="agent-content">
+          {error && <div className="alert-timeline-empty">{error}</div>}
+          {!error && agents.length === 0 && (
+            <div className="alert-timeline-empty">{t('agents.empty')}</div>
+          )}
+
+          {agents.length > 0 && (
+            <table className="agent-table">
+              <thead>
+                <tr>
+                  <th scope="col">{t('agents.hostname')}</th>
+                  <th scope="col">{t('agents.status')}</th>
+                  <th scope="col">{t('agents.config')}</th>
+                  <th scope="col">{t('agents.lag')}</th>
+                  <th scope="col">{t('agents.lastSeen')}</th>
+                </tr>
+              </thead>
+              <tbody>
+                {agents.map(agent => (
+                  <tr key={agent.id}>
+                    <td title={agent.id}>
+                      {agent.hostname}
+                      {agent.version && <span className="agent-version"> {agent.version}</span>}
+                    </td>
+                    <td className={agent.connected ? 'agent-connected' : 'agent-disconnected'}>
+                      {t(agent.connected ? 'agents.connected' : 'agents.disconnected')}
+                    </td>
+                    <td className={agent.config_current ? '' : 'agent-outdated'}>
+                      {agent.config || t('agents.noConfig')}
+                      {!agent.config_current && ` (${t('agents.outdated')})`}
+                    </td>
+                    <td
+                      title={agent.positions
+                        .map(position => `${position.path}: ${formatLag(Math.max(0, position.size - position.offset))}`)
+                        .join('\n')}
+                    >
+                      {formatLag(agent.lag_bytes)}
+                    </td>
+                    <td>{formatDateTime(agent.last_seen)}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -0,0 +1,160 @@
+import React, { useState, useEffect, useMemo } from 'react';
+import { ChevronDown, ChevronRight } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n } from '../i18n';
+import type { AlertEvent } from '../types';
+
+const TIMELINE_DAYS = 7;
+const TIMELINE_MS = TIMELINE_DAYS * 24 * 60 * 60 * 1000;
+
+interface FiringPeriod {
+  start: number;
+  end: number;
+  open: boolean;
+}
+
+interface AlertTrack {
+  name: string;
+  periods: FiringPeriod[];
+  events: AlertEvent[];
+}
+
+// buildTracks pairs each firing event with the resolution that follows it, per report.
+// An alert already firing when the window starts begins at the window edge.
+const buildTracks = (events: AlertEvent[], from: number, to: number): AlertTrack[] => {
+  const tracks = new Map<number, AlertTrack>();
+  for (const event of events) {
+    let track = tracks.get(event.report_id);
+    if (!track) {
+      track = { name: event.report_name, periods: [], events: [] };
+      tracks.set(event.report_id, track);
+    }
+    track.events.push(event);
+
+    const time = new Date(event.time).getTime();
+    const last = track.periods[track.periods.length - 1];
+    if (event.state === 'firing') {
+      track.periods.push({ start: time, end: to, open: true });
+    } else if (last && last.open) {
+      last.end = time;
+      last.open = false;
+    } else {
+      track.periods.push({ start: from, end: time, open: false });
+    }
+  }
+  return [...tracks.values()].sort((a, b) => a.name.localeCompare(b.name));
+};
+
+export const AlertTimeline: React.FC = () => {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [events, setEvents] = useState<AlertEvent[]>([]);
+  const [loadedAt, setLoadedAt] = useState(Date.now());
+  const [error, setError] = useState<string | null>(null);
+  const [selected, setSelected] = useState<number | null>(null);
+  const { t, formatDateTime } = useI18n();
+
+  useEffect(() => {
+    if (!isExpanded) return;
+    ApiService.getInstance()
+      .fetchAlertHistory(`now-${TIMELINE_DAYS}d`)
+      .then(history => {
+        setEvents(history);
+        setLoadedAt(Date.now());
+        setError(null);
+      })
+      .catch(err => setError(err instanceof Error ? err.message : t('alerts.loadFailed')));
+  }, [isExpanded, t]);
+
+  const from = loadedAt - TIMELINE_MS;
+  const tracks = useMemo(() => buildTracks(events, from, loadedAt), [events, from, loadedAt]);
+  const position = (time: number) => `${Math.max(0, ((time - from) / TIMELINE_MS) * 100)}%`;
+  const selectedEvent = events.find(event => event.id === selected);
+
+  return (
+    <div className="alert-panel">
+      <div className="display-header">
+        <h3>{t('alerts.title')}</h3>
+        <button
+          className="display-toggle"
+          onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="alert-content"
+        >
+          {isExpanded ? (
+            <>
+              <ChevronDown size={16} />
+              {t('alerts.hide')}
+            </>
+          ) : (
+            <>
+              <ChevronRight size={16} />
+              {t('alerts.show')}
+            </>
+          )}
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="display-content" id="alert-content">
+          {error && <div className="alert-timeline-empty">{error}</div>}
+          {!error && tracks.length === 0 && (
+            <div className="alert-timeline-empty">{t('alerts.empty', { days: TIMELINE_DAYS })}</div>
+          )}
+
+          {tracks.map(track => (
+            <div key={track.name} className="alert-track">
+              <span className="alert-track-name">{track.name}</span>
+              <div className="alert-track-bar">
+                {track.periods.map(period => (
+                  <div
+                    key={period.start}
+                    className={`alert-period ${period.open ? 'alert-period-open' : ''}`}
+                    style={{
+                      left: position(period.start),
+                      width: `calc(${position(period.end)} - ${position(period.start)})`
+                    }}
+                    title={`${formatDateTime(period.start)} – ${
+                      period.open ? t('alerts.stillFiring') : formatDateTime(period.end)
+                    }`}
+                  />
+                ))}
+                {track.events.map(event => (
+                  <button
+                    key={event.id}
+                    className={`alert-marker alert-marker-${event.state}`}
+                    style={{ left: position(new Date(event.time).getTime()) }}
+                    onClick={() => setSelected(event.id === selected ? null : event.id)}
+                    aria-label={t('alerts.marker', {
+                      name: track.name,
+                      state: t(`alerts.state.${event.state}`),
+                      time: formatDateTime(event.time)
+                    })}
+                  />
+                ))}
+              </div>
+            </div>
+          ))}
+
+          {selectedEvent && (
+            <div className="alert-event-detail">
+              <div>
+                <strong>{selectedEvent.report_name}</strong>{' '}
+                {t('alerts.detail', {
+                  state: t(`alerts.state.${selectedEvent.state}`),
+                  time: formatDateTime(selectedEvent.time),
+                  count: selectedEvent.count,
+                  threshold: selectedEvent.threshold
+                })}
+              </div>
+              {selectedEvent.samples?.map(sample => (
+                <pre key={sample.id} className="alert-sample">
+                  {formatDateTime(sample.timestamp)} {sample.hostname} {sample.app_name}: {sample.message}
+                </pre>
+              ))}
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -0,0 +1,165 @@
+import React, { useState } from 'react';
+import { ChevronDown, ChevronRight } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n, type TranslationKey } from '../i18n';
+import type { CompareResult } from '../types';
+
+type Baseline = 'yesterday' | 'lastWeek' | 'deploy';
+
+const WINDOWS = ['15m', '1h', '6h', '24h'];
+const OFFSETS: Record<Exclude<Baseline, 'deploy'>, string> = { yesterday: '1d', lastWeek: '7d' };
+
+// compareParams builds the query of a comparison. Against a deploy, the time since the deploy is
+// compared to a range of the same length before it.
+const compareParams = (range: string, baseline: Baseline, deployTime: string): Record<string, string> => {
+  if (baseline !== 'deploy') {
+    return { start_time: `-${range}`, baseline_offset: OFFSETS[baseline] };
+  }
+  const deploy = new Date(deployTime);
+  const now = new Date();
+  const before = new Date(deploy.getTime() - (now.getTime() - deploy.getTime()));
+  return {
+    start_time: deploy.toISOString(),
+    end_time: now.toISOString(),
+    baseline_start: before.toISOString(),
+    baseline_end: deploy.toISOString()
+  };
+};
+
+export const ComparePanel: React.FC = () => {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [range, setRange] = useState('1h');
+  const [baseline, setBaseline] = useState<Baseline>('yesterday');
+  const [deployTime, setDeployTime] = useState('');
+  const [result, setResult] = useState<CompareResult | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [loading, setLoading] = useState(false);
+  const { t, formatNumber } = useI18n();
+
+  const runComparison = () => {
+    setLoading(true);
+    ApiService.getInstance()
+      .fetchComparison(compareParams(range, baseline, deployTime))
+      .then(comparison => {
+        setResult(comparison);
+        setError(null);
+      })
+      .catch(err => setError(err instanceof Error ? err.message : t('compare.loadFailed')))
+      .finally(() => setLoading(false));
+  };
+
+  const formatDelta = (delta: number) => (delta > 0 ? `+${formatNumber(delta)}` : formatNumber(delta));
+
+  return (
+    <div className="alert-panel">
+      <div className="display-header">
+        <h3>{t('compare.title')}</h3>
+        <button
+          className="display-toggle"
+          onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="compare-content"
+        >
+          {isExpanded ? (
+            <>
+              <ChevronDown size={16} />
+              {t('compare.hide')}
+            </>
+          ) : (
+            <>
+              <ChevronRight size={16} />
+              {t('compare.show')}
+            </>
+          )}
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="display-content" id="compare-content">
+          <div className="compare-controls">
+            {baseline !== 'deploy' && (
+              <label>
+                {t('compare.window')}
+                <select value={range} onChange={e => setRange(e.target.value)}>
+                  {WINDOWS.map(value => (
+                    <option key={value} value={value}>{value}</option>
+                  ))}
+                </select>
+              </label>
+            )}
+            <label>
+              {t('compare.baseline')}
+              <select value={baseline} onChange={e => setBaseline(e.target.value as Baseline)}>
+                <option value="yesterday">{t('compare.yesterday')}</option>
+                <option value="lastWeek">{t('compare.lastWeek')}</option>
+                <option value="deploy">{t('compare.deploy')}</option>
+              </select>
+            </label>
+            {baseline === 'deploy' && (
+              <label>
+                {t('compare.deployTime')}
+                <input
+                  type="datetime-local"
+                  value={deployTime}
+                  onChange={e => setDeployTime(e.target.value)}
+                />
+              </label>
+            )}
+            <button
+              className="display-toggle"
+              onClick={runComparison}
+              disabled={loading || (baseline === 'deploy' && !deployTime)}
+            >
+              {t('compare.run')}
+            </button>
+          </div>
+
+          {error && <div className="alert-timeline-empty">{error}</div>}
+          {!error && result && result.patterns.length === 0 && (
+            <div className="alert-timeline-empty">{t('compare.empty')}</div>
+          )}
+
+          {!error && result && result.patterns.length > 0 && (
+            <>
+              <div className="compare-summary">
+                {t('compare.totals', { baseline: result.baseline.count, current: result.current.count })}
+                {(result.baseline.truncated || result.current.truncated) && ` (${t('compare.truncated')})`}
+              </div>
+              <table className="agent-table">
+                <thead>
+                  <tr>
+                    <th scope="col">{t('compare.pattern')}</th>
+                    <th scope="col">{t('compare.app')}</th>
+                    <th scope="col">{t('compare.severity')}</th>
+                    <th scope="col">{t('compare.baselineCount')}</th>
+                    <th scope="col">{t('compare.currentCount')}</th>
+                    <th scope="col">{t('compare.delta')}</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {result.patterns.map(pattern => (
+                    <tr
+                      key={`${pattern.app_name}\u0000${pattern.pattern}`}
+                      className={pattern.new && pattern.severity <= 3 ? 'compare-new-error' : ''}
+                    >
+                      <td title={pattern.example}>
+                        {pattern.pattern}
+                        {pattern.new && <span className="compare-badge"> {t('compare.new')}</span>}
+                        {pattern.gone && <span className="agent-version"> {t('compare.gone')}</span>}
+                      </td>
+                      <td>{pattern.app_name}</td>
+                      <td>{t(`severity.${pattern.severity}` as TranslationKey)}</td>
+                      <td>{formatNumber(pattern.baseline)}</td>
+                      <td>{formatNumber(pattern.current)}</td>
+                      <td className={pattern.delta > 0 ? 'compare-increase' : ''}>{formatDelta(pattern.delta)}</td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
+            </>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -1,4 +1,5 @@
 import React from 'react';
+import { useI18n } from '../i18n';
 import type { ConnectionStatus as ConnectionStatusType } from '../types';
 
 interface ConnectionStatusProps {
@@ -6,10 +7,15 @@ interface ConnectionStatusProps {
 }
 
 export const ConnectionStatus: React.FC<ConnectionStatusProps> = ({ connectionStatus }) => {
+  const { t } = useI18n();
+  const text = connectionStatus.retryIn !== undefined
+    ? t('connection.reconnecting', { seconds: connectionStatus.retryIn })
+    : t(`connection.${connectionStatus.status}`);
+
   return (
     <div className="connection-status">
       <span className={`status-indicator ${connectionStatus.status}`}></span>
-      <span className="status-text">{connectionStatus.text}</span>
+      <span className="status-text">{text}</span>
     </div>
   );
 };
\ No newline at end of file
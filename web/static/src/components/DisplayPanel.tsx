@@ -1,6 +1,9 @@
 import React, { useState } from 'react';
 import { ChevronDown, ChevronRight } from 'lucide-react';
-import type { DisplayOptions } from '../types';
+import { useI18n } from '../i18n';
+import type { DisplayOptions, LogLayout } from '../types';
+
+const LAYOUTS: LogLayout[] = ['auto', 'lines', 'cards'];
 
 interface DisplayPanelProps {
   displayOptions: DisplayOptions;
@@ -14,6 +17,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
   onResetDisplayOptions
 }) => {
   const [isExpanded, setIsExpanded] = useState(false);
+  const { t } = useI18n();
 
   const handleCheckboxChange = (field: keyof DisplayOptions, checked: boolean) => {
     onDisplayOptionsChange({
@@ -25,29 +29,31 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
   return (
     <div className="display-panel">
       <div className="display-header">
-        <h3>Display Options</h3>
+        <h3>{t('display.title')}</h3>
         <button 
           className="display-toggle" 
           onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="display-content"
         >
           {isExpanded ? (
             <>
               <ChevronDown size={16} />
-              Hide Options
+              {t('display.hide')}
             </>
           ) : (
             <>
               <ChevronRight size={16} />
-              Show Options
+              {t('display.show')}
             </>
           )}
         </button>
       </div>
       
       {isExpanded && (
-        <div className="display-content">
+        <div className="display-content" id="display-content">
           <div className="display-section">
-            <h4>Show/Hide Fields</h4>
+            <h4>{t('display.fields')}</h4>
             <div className="checkbox-grid">
               <label className="checkbox-item">
                 <input 
@@ -55,7 +61,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showTimestamp}
                   onChange={(e) => handleCheckboxChange('showTimestamp', e.target.checked)}
                 />
-                <span>Timestamp</span>
+                <span>{t('field.timestamp')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -64,7 +70,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showPriority}
                   onChange={(e) => handleCheckboxChange('showPriority', e.target.checked)}
                 />
-                <span>Priority</span>
+                <span>{t('field.priority')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -73,7 +79,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showFacility}
                   onChange={(e) => handleCheckboxChange('showFacility', e.target.checked)}
                 />
-                <span>Facility</span>
+                <span>{t('field.facility')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -82,7 +88,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showSeverity}
                   onChange={(e) => handleCheckboxChange('showSeverity', e.target.checked)}
                 />
-                <span>Severity</span>
+                <span>{t('field.severity')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -91,7 +97,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showHostname}
                   onChange={(e) => handleCheckboxChange('showHostname', e.target.checked)}
                 />
-                <span>Hostname</span>
+                <span>{t('field.hostname')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -100,7 +106,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showAppName}
                   onChange={(e) => handleCheckboxChange('showAppName', e.target.checked)}
                 />
-                <span>App Name</span>
+                <span>{t('field.appName')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -109,7 +115,7 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showProcId}
                   onChange={(e) => handleCheckboxChange('showProcId', e.target.checked)}
                 />
-                <span>Process ID</span>
+                <span>{t('field.procId')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -118,21 +124,38 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.showMsgId}
                   onChange={(e) => handleCheckboxChange('showMsgId', e.target.checked)}
                 />
-                <span>Message ID</span>
+                <span>{t('field.msgId')}</span>
               </label>
             </div>
           </div>
           
           <div className="display-section">
-            <h4>Layout Options</h4>
+            <h4>{t('display.layout')}</h4>
             <div className="layout-options">
+              <label className="checkbox-item" htmlFor="layoutSelect">
+                <span>{t('display.layoutMode')}</span>
+                <select
+                  id="layoutSelect"
+                  className="layout-select"
+                  value={displayOptions.layout ?? 'auto'}
+                  onChange={(e) => onDisplayOptionsChange({
+                    ...displayOptions,
+                    layout: e.target.value as LogLayout
+                  })}
+                >
+                  {LAYOUTS.map(layout => (
+                    <option key={layout} value={layout}>{t(`display.layout.${layout}`)}</option>
+                  ))}
+                </select>
+              </label>
+
               <label className="checkbox-item">
                 <input 
                   type="checkbox" 
                   checked={displayOptions.showSeparators}
                   onChange={(e) => handleCheckboxChange('showSeparators', e.target.checked)}
                 />
-                <span>Field Separators</span>
+                <span>{t('display.separators')}</span>
               </label>
               
               <label className="checkbox-item">
@@ -141,14 +164,23 @@ export const DisplayPanel: React.FC<DisplayPanelProps> = ({
                   checked={displayOptions.compactMode}
                   onChange={(e) => handleCheckboxChange('compactMode', e.target.checked)}
                 />
-                <span>Compact Mode</span>
+                <span>{t('display.compact')}</span>
+              </label>
+
+              <label className="checkbox-item">
+                <input 
+                  type="checkbox" 
+                  checked={displayOptions.ansiColors ?? true}
+                  onChange={(e) => handleCheckboxChange('ansiColors', e.target.checked)}
+                />
+                <span>{t('display.ansiColors')}</span>
               </label>
             </div>
           </div>
           
           <div className="display-actions">
             <button onClick={onResetDisplayOptions} className="btn-secondary">
-              Reset to Default
+              {t('display.reset')}
             </button>
           </div>
         </div>
@@ -0,0 +1,240 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Copy, Filter, X } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n, type TranslationKey } from '../i18n';
+import { getFacilityName } from '../utils/formatters';
+import { BASE_PATH } from '../utils/constants';
+import { MessageText } from './MessageText';
+import type { EntryDetail, LogFilters } from '../types';
+
+interface EntryDetailDrawerProps {
+  entryId: string;
+  // Renders ANSI colors in the message
+  ansiColors: boolean;
+  onClose: () => void;
+  onPivot: (filters: LogFilters) => void;
+}
+
+interface DetailField {
+  name: string;
+  value: string;
+  // The filter showing entries with the same value, if the field can be filtered on
+  pivot?: LogFilters;
+}
+
+// Tokens of pretty-printed JSON: strings (keys when followed by a colon), literals and numbers
+const JSON_TOKEN = /("(?:\\.|[^"\\])*")(\s*:)?|\b(true|false|null)\b|-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?/g;
+
+const highlightJson = (json: string): React.ReactNode[] => {
+  const nodes: React.ReactNode[] = [];
+  let last = 0;
+  for (const match of json.matchAll(JSON_TOKEN)) {
+    const index = match.index ?? 0;
+    nodes.push(json.slice(last, index));
+    const [token, string, colon, literal] = match;
+    if (string !== undefined) {
+      nodes.push(<span key={index} className={colon ? 'json-key' : 'json-string'}>{string}</span>);
+      if (colon) nodes.push(colon);
+    } else {
+      nodes.push(<span key={index} className={literal ? 'json-literal' : 'json-number'}>{token}</span>);
+    }
+    last = index + token.length;
+  }
+  nodes.push(json.slice(last));
+  return nodes;
+};
+
+const copyText = async (text: string) => {
+  if (navigator.clipboard) {
+    await navigator.clipboard.writeText(text);
+    return;
+  }
+  // Clipboard API is unavailable outside secure contexts, e.g. plain HTTP on another host
+  const textarea = document.createElement('textarea');
+  textarea.value = text;
+  document.body.appendChild(textarea);
+  textarea.select();
+  document.execCommand('copy');
+  document.body.removeChild(textarea);
+};
+
+// The entry's API request; curl prompts for the password so none ends up in the clipboard
+const curlCommand = (id: string) =>
+  `curl -s -u "$OPENTRAIL_USER" '${window.location.origin}${BASE_PATH}/api/logs/${encodeURIComponent(id)}'`;
+
+export const EntryDetailDrawer: React.FC<EntryDetailDrawerProps> = ({ entryId, ansiColors, onClose, onPivot }) => {
+  const [detail, setDetail] = useState<EntryDetail | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [copied, setCopied] = useState<string | null>(null);
+  const closeRef = useRef<HTMLButtonElement>(null);
+  const { t, formatDateTime } = useI18n();
+
+  useEffect(() => {
+    setDetail(null);
+    setError(null);
+    ApiService.getInstance()
+      .fetchEntryDetail(entryId)
+      .then(setDetail)
+      .catch((err: Error) => setError(err.message));
+  }, [entryId]);
+
+  useEffect(() => {
+    const previous = document.activeElement;
+    closeRef.current?.focus();
+    return () => {
+      if (previous instanceof HTMLElement) previous.focus();
+    };
+  }, []);
+
+  const copy = (key: string, text: string) => {
+    copyText(text)
+      .then(() => setCopied(key))
+      .catch(() => setCopied(null));
+  };
+
+  const fields: DetailField[] = [];
+  if (detail) {
+    const { entry } = detail;
+    fields.push(
+      { name: 'timestamp', value: entry.timestamp },
+      { name: 'priority', value: String(entry.priority) },
+      { name: 'facility', value: `${getFacilityName(entry.facility)} (${entry.facility})`, pivot: { facility: entry.facility } },
+      { name: 'severity', value: `${t(`severity.${entry.severity}` as TranslationKey)} (${entry.severity})`, pivot: { severity: entry.severity } },
+      { name: 'hostname', value: entry.hostname || '-', pivot: entry.hostname ? { hostname: entry.hostname } : undefined },
+      { name: 'app_name', value: entry.app_name || '-', pivot: entry.app_name ? { appName: entry.app_name } : undefined },
+      { name: 'proc_id', value: entry.proc_id || '-', pivot: entry.proc_id ? { procId: entry.proc_id } : undefined },
+      { name: 'msg_id', value: entry.msg_id || '-', pivot: entry.msg_id ? { msgId: entry.msg_id } : undefined },
+      { name: 'created_at', value: entry.created_at }
+    );
+    if (entry.uid) {
+      fields.unshift({ name: 'uid', value: entry.uid });
+    }
+    for (const [sdid, params] of Object.entries(entry.structured_data ?? {})) {
+      if (!params || typeof params !== 'object') continue;
+      for (const [param, value] of Object.entries(params)) {
+        const name = `${sdid}.${param}`;
+        fields.push({ name, value: String(value), pivot: { structuredData: { [name]: String(value) } } });
+      }
+    }
+  }
+
+  return (
+    <div className="modal-overlay drawer-overlay" onClick={onClose}>
+      <aside
+        className="detail-drawer"
+        role="dialog"
+        aria-modal="true"
+        aria-labelledby="entry-detail-title"
+        onClick={(e) => e.stopPropagation()}
+      >
+        <div className="modal-header">
+          <h3 id="entry-detail-title">{t('detail.title', { id: entryId })}</h3>
+          <div className="detail-actions">
+            <button className="btn-secondary" onClick={() => copy('curl', curlCommand(entryId))}>
+              <Copy size={12} /> {copied === 'curl' ? t('detail.copied') : t('detail.copyCurl')}
+            </button>
+            <button ref={closeRef} className="btn-secondary" onClick={onClose} aria-label={t('detail.close')}>
+              <X size={12} />
+            </button>
+          </div>
+        </div>
+
+        {error && <div className="alert-timeline-empty" role="alert">{t('detail.loadFailed', { error })}</div>}
+        {!detail && !error && <div className="alert-timeline-empty" role="status">{t('entry.loading')}</div>}
+
+        {detail && (
+          <div className="detail-content">
+            <section>
+              <h4>{t('detail.message')}</h4>
+              <pre className="detail-message"><MessageText text={detail.entry.message} colors={ansiColors} /></pre>
+            </section>
+
+            <section>
+              <h4>{t('detail.fields')}</h4>
+              <table className="detail-fields">
+                <tbody>
+                  {fields.map(field => (
+                    <tr key={field.name}>
+                      <th scope="row">{field.name}</th>
+                      <td className="detail-value">
+                        {field.name === 'timestamp' || field.name === 'created_at'
+                          ? <time dateTime={field.value}>{formatDateTime(field.value)}</time>
+                          : field.value}
+                      </td>
+                      <td className="detail-field-actions">
+                        <button
+                          className="icon-button"
+                          onClick={() => copy(field.name, field.value)}
+                          aria-label={t('detail.copyField', { field: field.name })}
+                          title={copied === field.name ? t('detail.copied') : t('detail.copyField', { field: field.name })}
+                        >
+                          <Copy size={12} />
+                        </button>
+                        {field.pivot && (
+                          <button
+                            className="icon-button"
+                            onClick={() => {
+                              onPivot(field.pivot!);
+                              onClose();
+                            }}
+                            aria-label={t('detail.pivot', { field: field.name })}
+                            title={t('detail.pivot', { field: field.name })}
+                          >
+                            <Filter size={12} />
+                          </button>
+                        )}
+                      </td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
+            </section>
+
+            {detail.entry.structured_data && Object.keys(detail.entry.structured_data).length > 0 && (
+              <section>
+                <h4>{t('detail.structuredData')}</h4>
+                <pre className="detail-json">
+                  {highlightJson(JSON.stringify(detail.entry.structured_data, null, 2))}
+                </pre>
+              </section>
+            )}
+
+            <section>
+              <h4>{t('detail.metadata')}</h4>
+              <dl className="detail-metadata">
+                <dt>{t('detail.sourceIp')}</dt>
+                <dd>{detail.source_ip || '-'}</dd>
+                {Object.entries(detail.annotations ?? {}).map(([name, value]) => (
+                  <React.Fragment key={name}>
+                    <dt>{name}</dt>
+                    <dd>{value}</dd>
+                  </React.Fragment>
+                ))}
+                {detail.chain && (
+                  <>
+                    <dt>{t('detail.chain')}</dt>
+                    <dd className="detail-value">{detail.chain.partition} {detail.chain.hash}</dd>
+                  </>
+                )}
+              </dl>
+            </section>
+
+            <section>
+              <h4>{t('detail.raw')}</h4>
+              {detail.raw ? (
+                <>
+                  <pre className="detail-message">{detail.raw}</pre>
+                  <button className="btn-secondary" onClick={() => copy('raw', detail.raw!)}>
+                    <Copy size={12} /> {copied === 'raw' ? t('detail.copied') : t('detail.copyRaw')}
+                  </button>
+                </>
+              ) : (
+                <div className="alert-timeline-empty">{t('detail.noRaw')}</div>
+              )}
+            </section>
+          </div>
+        )}
+      </aside>
+    </div>
+  );
+};
@@ -1,21 +1,65 @@
-import React, { useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import { ChevronDown, ChevronRight } from 'lucide-react';
-import type { LogFilters } from '../types';
+import { FACILITIES } from '../utils/constants';
+import { useI18n } from '../i18n';
+import type { LogFilters, PinnedFilter } from '../types';
+
+const SEVERITY_LEVELS = [0, 1, 2, 3, 4, 5, 6, 7] as const;
+
+// describeFilters lists the set filters of a query, to tell recent queries apart
+const describeFilters = (filters: LogFilters): string =>
+  Object.entries(filters)
+    .flatMap(([field, value]) => {
+      if (value === null || value === undefined || value === '') return [];
+      if (typeof value === 'object') return Object.entries(value).map(([key, param]) => `${key}=${param}`);
+      return [`${field}=${value}`];
+    })
+    .join(' ');
 
 interface FilterPanelProps {
   filters: LogFilters;
   onFiltersChange: (filters: LogFilters) => void;
   onApplyFilters: () => void;
   onClearFilters: () => void;
+  // Incremented to expand the panel and focus the message search
+  focusSearchSignal?: number;
+  // Saved with the user's preferences on the server, where it keeps them
+  pinnedFilters?: PinnedFilter[];
+  recentQueries?: LogFilters[];
+  onPinFilters?: (name: string) => void;
+  onUnpinFilter?: (name: string) => void;
 }
 
 export const FilterPanel: React.FC<FilterPanelProps> = ({
   filters,
   onFiltersChange,
   onApplyFilters,
-  onClearFilters
+  onClearFilters,
+  focusSearchSignal = 0,
+  pinnedFilters = [],
+  recentQueries = [],
+  onPinFilters,
+  onUnpinFilter
 }) => {
   const [isExpanded, setIsExpanded] = useState(false);
+  const [pinName, setPinName] = useState('');
+  const textFilterRef = useRef<HTMLInputElement>(null);
+  const focusPending = useRef(false);
+  const { t } = useI18n();
+
+  useEffect(() => {
+    if (focusSearchSignal === 0) return;
+    focusPending.current = true;
+    setIsExpanded(true);
+  }, [focusSearchSignal]);
+
+  // The search field only exists once the expanded panel has rendered
+  useEffect(() => {
+    if (focusPending.current && isExpanded) {
+      focusPending.current = false;
+      textFilterRef.current?.focus();
+    }
+  }, [focusSearchSignal, isExpanded]);
 
   const handleInputChange = (field: keyof LogFilters, value: string | number | null) => {
     onFiltersChange({
@@ -24,130 +68,200 @@ export const FilterPanel: React.FC<FilterPanelProps> = ({
     });
   };
 
+  const removeStructuredDataFilter = (field: string) => {
+    const rest = { ...filters.structuredData };
+    delete rest[field];
+    onFiltersChange({
+      ...filters,
+      structuredData: Object.keys(rest).length > 0 ? rest : undefined
+    });
+  };
+
   return (
     <div className="filter-panel">
       <div className="filter-header">
-        <h3>RFC5424 Filters</h3>
+        <h3>{t('filters.title')}</h3>
         <button 
           className="filter-toggle" 
           onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="filter-content"
         >
           {isExpanded ? (
             <>
               <ChevronDown size={16} />
-              Hide Filters
+              {t('filters.hide')}
             </>
           ) : (
             <>
               <ChevronRight size={16} />
-              Show Filters
+              {t('filters.show')}
             </>
           )}
         </button>
       </div>
       
+      {filters.structuredData && Object.keys(filters.structuredData).length > 0 && (
+        <div className="filter-chips">
+          {Object.entries(filters.structuredData).map(([field, value]) => (
+            <span key={field} className="filter-chip">
+              {field}={value}
+              <button
+                onClick={() => removeStructuredDataFilter(field)}
+                aria-label={t('filters.removeField', { field })}
+              >
+                ×
+              </button>
+            </span>
+          ))}
+        </div>
+      )}
+
+      {pinnedFilters.length > 0 && (
+        <div className="filter-chips pinned-filters" aria-label={t('filters.pinned')}>
+          {pinnedFilters.map(pinned => (
+            <span key={pinned.name} className="filter-chip pinned-filter">
+              <button
+                className="pinned-filter-apply"
+                onClick={() => onFiltersChange(pinned.filters)}
+                title={describeFilters(pinned.filters)}
+              >
+                {pinned.name}
+              </button>
+              {onUnpinFilter && (
+                <button
+                  onClick={() => onUnpinFilter(pinned.name)}
+                  aria-label={t('filters.unpin', { name: pinned.name })}
+                >
+                  ×
+                </button>
+              )}
+            </span>
+          ))}
+        </div>
+      )}
+
       {isExpanded && (
-        <div className="filter-content">
+        <div className="filter-content" id="filter-content">
+          {(recentQueries.length > 0 || onPinFilters) && (
+            <div className="filter-row">
+              {recentQueries.length > 0 && (
+                <div className="filter-group">
+                  <label htmlFor="recentQueries">{t('filters.recent')}</label>
+                  <select
+                    id="recentQueries"
+                    value=""
+                    onChange={(e) => e.target.value !== '' && onFiltersChange(recentQueries[parseInt(e.target.value)])}
+                  >
+                    <option value="">{t('filters.recentPlaceholder')}</option>
+                    {recentQueries.map((query, index) => (
+                      <option key={index} value={index}>{describeFilters(query)}</option>
+                    ))}
+                  </select>
+                </div>
+              )}
+
+              {onPinFilters && (
+                <div className="filter-group">
+                  <label htmlFor="pinName">{t('filters.pinName')}</label>
+                  <div className="pin-filters">
+                    <input
+                      type="text"
+                      id="pinName"
+                      maxLength={256}
+                      placeholder={t('filters.pinNamePlaceholder')}
+                      value={pinName}
+                      onChange={(e) => setPinName(e.target.value)}
+                    />
+                    <button
+                      className="btn-secondary"
+                      disabled={pinName.trim() === ''}
+                      onClick={() => {
+                        onPinFilters(pinName.trim());
+                        setPinName('');
+                      }}
+                    >
+                      {t('filters.pin')}
+                    </button>
+                  </div>
+                </div>
+              )}
+            </div>
+          )}
+
           <div className="filter-row">
             <div className="filter-group">
-              <label htmlFor="facilityFilter">Facility:</label>
+              <label htmlFor="facilityFilter">{t('filters.facility')}</label>
               <select 
                 id="facilityFilter"
                 value={filters.facility ?? ''}
                 onChange={(e) => handleInputChange('facility', e.target.value ? parseInt(e.target.value) : null)}
               >
-                <option value="">All</option>
-                <option value="0">Kernel (0)</option>
-                <option value="1">User (1)</option>
-                <option value="2">Mail (2)</option>
-                <option value="3">Daemon (3)</option>
-                <option value="4">Auth (4)</option>
-                <option value="5">Syslog (5)</option>
-                <option value="6">LPR (6)</option>
-                <option value="7">News (7)</option>
-                <option value="8">UUCP (8)</option>
-                <option value="9">Cron (9)</option>
-                <option value="10">Authpriv (10)</option>
-                <option value="11">FTP (11)</option>
-                <option value="16">Local0 (16)</option>
-                <option value="17">Local1 (17)</option>
-                <option value="18">Local2 (18)</option>
-                <option value="19">Local3 (19)</option>
-                <option value="20">Local4 (20)</option>
-                <option value="21">Local5 (21)</option>
-                <option value="22">Local6 (22)</option>
-                <option value="23">Local7 (23)</option>
+                <option value="">{t('filters.all')}</option>
+                {Object.entries(FACILITIES).map(([code, name]) => (
+                  <option key={code} value={code}>{name} ({code})</option>
+                ))}
               </select>
             </div>
             
             <div className="filter-group">
-              <label htmlFor="severityFilter">Severity:</label>
+              <label htmlFor="severityFilter">{t('filters.severity')}</label>
               <select 
                 id="severityFilter"
                 value={filters.severity ?? ''}
                 onChange={(e) => handleInputChange('severity', e.target.value ? parseInt(e.target.value) : null)}
               >
-                <option value="">All</option>
-                <option value="0">Emergency (0)</option>
-                <option value="1">Alert (1)</option>
-                <option value="2">Critical (2)</option>
-                <option value="3">Error (3)</option>
-                <option value="4">Warning (4)</option>
-                <option value="5">Notice (5)</option>
-                <option value="6">Info (6)</option>
-                <option value="7">Debug (7)</option>
+                <option value="">{t('filters.all')}</option>
+                {SEVERITY_LEVELS.map(level => (
+                  <option key={level} value={level}>{t(`severity.${level}`)} ({level})</option>
+                ))}
               </select>
             </div>
             
             <div className="filter-group">
-              <label htmlFor="minSeverityFilter">Min Severity:</label>
+              <label htmlFor="minSeverityFilter">{t('filters.minSeverity')}</label>
               <select 
                 id="minSeverityFilter"
                 value={filters.minSeverity ?? ''}
                 onChange={(e) => handleInputChange('minSeverity', e.target.value ? parseInt(e.target.value) : null)}
               >
-                <option value="">None</option>
-                <option value="0">Emergency+</option>
-                <option value="1">Alert+</option>
-                <option value="2">Critical+</option>
-                <option value="3">Error+</option>
-                <option value="4">Warning+</option>
-                <option value="5">Notice+</option>
-                <option value="6">Info+</option>
-                <option value="7">Debug+</option>
+                <option value="">{t('filters.none')}</option>
+                {SEVERITY_LEVELS.map(level => (
+                  <option key={level} value={level}>{t(`severity.${level}`)}+</option>
+                ))}
               </select>
             </div>
           </div>
           
           <div className="filter-row">
             <div className="filter-group">
-              <label htmlFor="hostnameFilter">Hostname:</label>
+              <label htmlFor="hostnameFilter">{t('filters.hostname')}</label>
               <input 
                 type="text" 
                 id="hostnameFilter"
-                placeholder="Filter by hostname"
+                placeholder={t('filters.hostnamePlaceholder')}
                 value={filters.hostname || ''}
                 onChange={(e) => handleInputChange('hostname', e.target.value)}
               />
             </div>
             
             <div className="filter-group">
-              <label htmlFor="appNameFilter">App Name:</label>
+              <label htmlFor="appNameFilter">{t('filters.appName')}</label>
               <input 
                 type="text" 
                 id="appNameFilter"
-                placeholder="Filter by application"
+                placeholder={t('filters.appNamePlaceholder')}
                 value={filters.appName || ''}
                 onChange={(e) => handleInputChange('appName', e.target.value)}
               />
             </div>
             
             <div className="filter-group">
-              <label htmlFor="procIdFilter">Process ID:</label>
+              <label htmlFor="procIdFilter">{t('filters.procId')}</label>
               <input 
                 type="text" 
                 id="procIdFilter"
-                placeholder="Filter by process ID"
+                placeholder={t('filters.procIdPlaceholder')}
                 value={filters.procId || ''}
                 onChange={(e) => handleInputChange('procId', e.target.value)}
               />
@@ -156,22 +270,23 @@ export const FilterPanel: React.FC<FilterPanelProps> = ({
           
           <div className="filter-row">
             <div className="filter-group">
-              <label htmlFor="msgIdFilter">Message ID:</label>
+              <label htmlFor="msgIdFilter">{t('filters.msgId')}</label>
               <input 
                 type="text" 
                 id="msgIdFilter"
-                placeholder="Filter by message ID"
+                placeholder={t('filters.msgIdPlaceholder')}
                 value={filters.msgId || ''}
                 onChange={(e) => handleInputChange('msgId', e.target.value)}
               />
             </div>
             
             <div className="filter-group">
-              <label htmlFor="textFilter">Message Text:</label>
+              <label htmlFor="textFilter">{t('filters.text')}</label>
               <input 
                 type="text" 
                 id="textFilter"
-                placeholder="Search in message content"
+                ref={textFilterRef}
+                placeholder={t('filters.textPlaceholder')}
                 value={filters.text || ''}
                 onChange={(e) => handleInputChange('text', e.target.value)}
               />
@@ -179,10 +294,10 @@ export const FilterPanel: React.FC<FilterPanelProps> = ({
             
             <div className="filter-group filter-actions">
               <button onClick={onApplyFilters} className="btn-primary">
-                Apply Filters
+                {t('filters.apply')}
               </button>
               <button onClick={onClearFilters} className="btn-secondary">
-                Clear All
+                {t('filters.clear')}
               </button>
             </div>
           </div>
@@ -0,0 +1,173 @@
+import React, { useState, useEffect, useMemo } from 'react';
+import { ChevronDown, ChevronRight } from 'lucide-react';
+import { ApiService } from '../services/api';
+import { useI18n, type TranslationKey } from '../i18n';
+import { BASE_PATH, SEVERITIES } from '../utils/constants';
+import { filterParams } from '../utils/filters';
+import type { Histogram, LogFilters } from '../types';
+
+const RANGES = ['1h', '6h', '24h', '7d', '30d'];
+// Typing in the filters refetches once the user pauses
+const FETCH_DELAY_MS = 300;
+
+interface Column {
+  time: number;
+  total: number;
+  // Counts per severity, most severe first
+  segments: { severity: number; count: number }[];
+}
+
+// histogramParams builds the query of the chart from the range and the filters of the log view
+const histogramParams = (filters: LogFilters, range: string): Record<string, string> => ({
+  start_time: `-${range}`,
+  group_by: 'severity',
+  tz: Intl.DateTimeFormat().resolvedOptions().timeZone,
+  ...filterParams(filters)
+});
+
+// parseInterval converts a Go duration such as "15m0s" or "24h0m0s" to milliseconds
+const parseInterval = (interval: string): number => {
+  const units: Record<string, number> = { h: 3600000, m: 60000, s: 1000 };
+  let ms = 0;
+  for (const [, value, unit] of interval.matchAll(/(\d+)([hms])/g)) {
+    ms += Number(value) * units[unit];
+  }
+  return ms;
+};
+
+const buildColumns = (histogram: Histogram): Column[] => {
+  const columns = new Map<number, Column>();
+  for (const bucket of histogram.buckets) {
+    const time = new Date(bucket.time).getTime();
+    let column = columns.get(time);
+    if (!column) {
+      column = { time, total: 0, segments: [] };
+      columns.set(time, column);
+    }
+    column.total += bucket.count;
+    column.segments.push({ severity: Number(bucket.group), count: bucket.count });
+  }
+  for (const column of columns.values()) {
+    column.segments.sort((a, b) => a.severity - b.severity);
+  }
+  return [...columns.values()];
+};
+
+export const HistogramPanel: React.FC<{ filters: LogFilters }> = ({ filters }) => {
+  const [isExpanded, setIsExpanded] = useState(false);
+  const [range, setRange] = useState('24h');
+  const [histogram, setHistogram] = useState<Histogram | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const { t, formatNumber, formatDateTime } = useI18n();
+
+  useEffect(() => {
+    if (!isExpanded) return;
+    const timer = setTimeout(() => {
+      ApiService.getInstance()
+        .fetchHistogram(histogramParams(filters, range))
+        .then(result => {
+          setHistogram(result);
+          setError(null);
+        })
+        .catch(err => setError(err instanceof Error ? err.message : t('histogram.loadFailed')));
+    }, FETCH_DELAY_MS);
+    return () => clearTimeout(timer);
+  }, [isExpanded, filters, range, t]);
+
+  const columns = useMemo(() => (histogram ? buildColumns(histogram) : []), [histogram]);
+  const maxTotal = Math.max(1, ...columns.map(column => column.total));
+  const from = histogram ? new Date(histogram.start_time).getTime() : 0;
+  const span = histogram ? new Date(histogram.end_time).getTime() - from : 1;
+  const interval = histogram ? parseInterval(histogram.interval) : 0;
+
+  return (
+    <div className="alert-panel">
+      <div className="display-header">
+        <h3>{t('histogram.title')}</h3>
+        <button
+          className="display-toggle"
+          onClick={() => setIsExpanded(!isExpanded)}
+          aria-expanded={isExpanded}
+          aria-controls="histogram-content"
+        >
+          {isExpanded ? (
+            <>
+              <ChevronDown size={16} />
+              {t('histogram.hide')}
+            </>
+          ) : (
+            <>
+              <ChevronRight size={16} />
+              {t('histogram.show')}
+            </>
+          )}
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="display-content" id="histogram-content">
+          <div className="compare-controls">
+            <label>
+              {t('histogram.range')}
+              <select value={range} onChange={e => setRange(e.target.value)}>
+                {RANGES.map(value => (
+                  <option key={value} value={value}>{value}</option>
+                ))}
+              </select>
+            </label>
+            {histogram && (
+              <span className="compare-summary">
+                {t('histogram.total', { count: histogram.total, interval: histogram.interval })}
+              </span>
+            )}
+            {histogram && histogram.extrapolated_total > histogram.total && (
+              <span className="compare-summary">
+                {t('histogram.extrapolated', { count: histogram.extrapolated_total })}
+              </span>
+            )}
+            {/* The bundle covers the range and filters of the chart */}
+            <a
+              className="btn-secondary bundle-link"
+              href={`${BASE_PATH}/api/logs/bundle?${new URLSearchParams(histogramParams(filters, range))}`}
+              download
+            >
+              {t('histogram.bundle')}
+            </a>
+          </div>
+
+          {error && <div className="alert-timeline-empty">{error}</div>}
+          {!error && histogram && histogram.total === 0 && (
+            <div className="alert-timeline-empty">{t('histogram.empty')}</div>
+          )}
+
+          {!error && histogram && histogram.total > 0 && (
+            <div className="histogram-chart" role="img" aria-label={t('histogram.title')}>
+              {columns.map(column => (
+                <div
+                  key={column.time}
+                  className="histogram-column"
+                  style={{
+                    left: `${Math.max(0, ((column.time - from) / span) * 100)}%`,
+                    width: `${(interval / span) * 100}%`,
+                    height: `${(column.total / maxTotal) * 100}%`
+                  }}
+                  title={`${formatDateTime(column.time)}: ${column.segments
+                    .map(s => `${t(`severity.${s.severity}` as TranslationKey)} ${formatNumber(s.count)}`)
+                    .join(', ')}`}
+                >
+                  {column.segments.map(segment => (
+                    <div
+                      key={segment.severity}
+                      className={`histogram-segment ${SEVERITIES[segment.severity as keyof typeof SEVERITIES]?.class ?? ''}`}
+                      style={{ flexGrow: segment.count }}
+                    />
+                  ))}
+                </div>
+              ))}
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
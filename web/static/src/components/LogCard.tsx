@@ -0,0 +1,148 @@
+import React, { useRef, useState } from 'react';
+import { ChevronDown, ChevronRight, Info } from 'lucide-react';
+import { getSeverityInfo } from '../utils/formatters';
+import { stripAnsi } from '../utils/ansi';
+import { useI18n, type TranslationKey } from '../i18n';
+import { MessageText } from './MessageText';
+import type { LogEntry as LogEntryType } from '../types';
+
+// A horizontal swipe longer than this toggles the structured data
+const SWIPE_THRESHOLD = 60;
+// How far the card follows the finger while swiping
+const SWIPE_MAX_OFFSET = 80;
+
+interface LogCardProps {
+  logEntry: LogEntryType;
+  // Renders ANSI colors in the message
+  ansiColors: boolean;
+  showStructuredData: boolean;
+  onToggleStructuredData: () => void;
+  isSelected: boolean;
+  onSelect: () => void;
+  onOpenDetail: () => void;
+  position: number;
+  setSize: number;
+}
+
+export const LogCard: React.FC<LogCardProps> = ({
+  logEntry,
+  ansiColors,
+  showStructuredData,
+  onToggleStructuredData,
+  isSelected,
+  onSelect,
+  onOpenDetail,
+  position,
+  setSize
+}) => {
+  const [showFullMessage, setShowFullMessage] = useState(false);
+  const [swipeOffset, setSwipeOffset] = useState(0);
+  const touchStart = useRef<{ x: number; y: number } | null>(null);
+  const { t, formatDateTime } = useI18n();
+
+  const severity = getSeverityInfo(logEntry.severity || 0);
+  const severityLabel = severity.class === 'unknown'
+    ? severity.name
+    : t(`severity.${logEntry.severity || 0}` as TranslationKey);
+  const timestamp = formatDateTime(logEntry.timestamp);
+  const hostname = logEntry.hostname || '-';
+  const appName = logEntry.app_name || '-';
+  const message = logEntry.message || '';
+  const hasStructuredData = logEntry.structured_data &&
+    Object.keys(logEntry.structured_data).length > 0;
+
+  const handleTouchStart = (e: React.TouchEvent) => {
+    const touch = e.touches[0];
+    touchStart.current = { x: touch.clientX, y: touch.clientY };
+  };
+
+  const handleTouchMove = (e: React.TouchEvent) => {
+    if (!touchStart.current || !hasStructuredData) return;
+    const touch = e.touches[0];
+    const dx = touch.clientX - touchStart.current.x;
+    const dy = touch.clientY - touchStart.current.y;
+    // Mostly vertical movement is scrolling the list
+    if (Math.abs(dy) > Math.abs(dx)) return;
+    setSwipeOffset(Math.max(-SWIPE_MAX_OFFSET, Math.min(SWIPE_MAX_OFFSET, dx)));
+  };
+
+  const handleTouchEnd = () => {
+    if (Math.abs(swipeOffset) >= SWIPE_THRESHOLD && hasStructuredData) {
+      onToggleStructuredData();
+    }
+    touchStart.current = null;
+    setSwipeOffset(0);
+  };
+
+  return (
+    <div
+      className={`log-card severity-${severity.class}${isSelected ? ' selected' : ''}`}
+      data-entry-id={logEntry.id}
+      role="article"
+      tabIndex={isSelected ? 0 : -1}
+      aria-posinset={position}
+      aria-setsize={setSize}
+      aria-label={t('entry.summary', {
+        severity: severityLabel,
+        hostname,
+        app: appName,
+        time: timestamp,
+        message: stripAnsi(message)
+      })}
+      onFocus={(e) => {
+        if (e.target === e.currentTarget) onSelect();
+      }}
+      onTouchStart={handleTouchStart}
+      onTouchMove={handleTouchMove}
+      onTouchEnd={handleTouchEnd}
+      onTouchCancel={() => {
+        touchStart.current = null;
+        setSwipeOffset(0);
+      }}
+      style={swipeOffset ? { transform: `translateX(${swipeOffset}px)` } : undefined}
+    >
+      <div className="log-card-header" aria-hidden="true">
+        <span className={`severity ${severity.class}`}>{severity.name}</span>
+        <span className="log-card-source">{hostname} · {appName}</span>
+        <span className="timestamp">{timestamp}</span>
+      </div>
+
+      <div
+        className={`log-card-message${showFullMessage ? '' : ' clamped'}`}
+        onClick={() => setShowFullMessage(!showFullMessage)}
+        aria-hidden="true"
+      >
+        <MessageText text={message} highlights={logEntry.highlights} colors={ansiColors} />
+      </div>
+
+      {hasStructuredData && (
+        <div className="log-entry-structured-data">
+          <button
+            className="structured-data-toggle"
+            onClick={onToggleStructuredData}
+            aria-expanded={showStructuredData}
+            title={t('entry.swipeHint')}
+          >
+            {showStructuredData ? <ChevronDown size={12} /> : <ChevronRight size={12} />}
+            {showStructuredData ? t('entry.hideStructuredData') : t('entry.showStructuredData')}
+          </button>
+
+          {showStructuredData && (
+            <div className="structured-data-content">
+              <pre>{JSON.stringify(logEntry.structured_data, null, 2)}</pre>
+            </div>
+          )}
+        </div>
+      )}
+
+      {logEntry.id && (
+        <div className="log-entry-structured-data">
+          <button className="structured-data-toggle" onClick={onOpenDetail}>
+            <Info size={12} />
+            {t('entry.openDetail')}
+          </button>
+        </div>
+      )}
+    </div>
+  );
+};
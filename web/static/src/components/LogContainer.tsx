@@ -1,33 +1,48 @@
-import React, { useEffect, useRef, useCallback, useState } from 'react';
+import React, { forwardRef, useEffect, useImperativeHandle, useRef, useCallback, useState } from 'react';
 import { ArrowDown } from 'lucide-react';
 import { LogEntry } from './LogEntry';
-import { getCurrentTime } from '../utils/formatters';
+import { LogCard } from './LogCard';
+import { useI18n } from '../i18n';
 import type { LogEntry as LogEntryType, DisplayOptions } from '../types';
 
 interface LogContainerProps {
   logs: LogEntryType[];
   displayOptions: DisplayOptions;
+  cardLayout: boolean;
   autoScroll: boolean;
   onAutoScrollChange: (autoScroll: boolean) => void;
   onLoadMore: () => void;
   isLoadingMore: boolean;
   hasMoreLogs: boolean;
+  onOpenDetail: (id: string) => void;
 }
 
-export const LogContainer: React.FC<LogContainerProps> = ({
+// Keyboard navigation of the entries, driven by the shortcuts in App
+export interface LogContainerHandle {
+  moveSelection: (delta: number) => void;
+  toggleSelectedDetails: () => void;
+}
+
+export const LogContainer = forwardRef<LogContainerHandle, LogContainerProps>(({
   logs,
   displayOptions,
+  cardLayout,
   autoScroll,
   onAutoScrollChange,
   onLoadMore,
   isLoadingMore,
-  hasMoreLogs
-}) => {
+  hasMoreLogs,
+  onOpenDetail
+}, ref) => {
   const containerRef = useRef<HTMLDivElement>(null);
+  // Selection is tracked by entry ID so it stays on the same entry as logs arrive or are trimmed
+  const [selectedId, setSelectedId] = useState<string | null>(null);
+  const [expandedIds, setExpandedIds] = useState<Set<string>>(() => new Set());
   const [isUserScrolling, setIsUserScrolling] = useState(false);
   const scrollTimeoutRef = useRef<number | null>(null);
   const lastScrollTop = useRef(0);
   const previousScrollHeight = useRef(0);
+  const { t, formatDateTime } = useI18n();
 
 
 
@@ -98,6 +113,44 @@ export const LogContainer: React.FC<LogContainerProps> = ({
     };
   }, []);
 
+  const toggleStructuredData = useCallback((id: string) => {
+    setExpandedIds(prev => {
+      const next = new Set(prev);
+      if (next.has(id)) {
+        next.delete(id);
+      } else {
+        next.add(id);
+      }
+      return next;
+    });
+  }, []);
+
+  useImperativeHandle(ref, () => ({
+    moveSelection: (delta: number) => {
+      if (logs.length === 0) return;
+      const current = logs.findIndex(log => log.id === selectedId);
+      const index = current === -1
+        ? (delta > 0 ? 0 : logs.length - 1)
+        : Math.min(Math.max(current + delta, 0), logs.length - 1);
+      const id = logs[index].id;
+      setSelectedId(id);
+
+      // Stop following new entries so the selection does not scroll away
+      onAutoScrollChange(false);
+      const row = containerRef.current?.querySelector<HTMLElement>(`[data-entry-id="${CSS.escape(id)}"]`);
+      row?.focus({ preventScroll: true });
+      row?.scrollIntoView({ block: 'nearest' });
+    },
+    toggleSelectedDetails: () => {
+      if (selectedId !== null) toggleStructuredData(selectedId);
+    }
+  }), [logs, selectedId, onAutoScrollChange, toggleStructuredData]);
+
+  // Without a selection the newest entry is the one reached with Tab
+  const tabbableId = logs.some(log => log.id === selectedId)
+    ? selectedId
+    : logs[logs.length - 1]?.id;
+
   const handleScrollToBottom = useCallback(() => {
     if (containerRef.current) {
       containerRef.current.scrollTop = containerRef.current.scrollHeight;
@@ -113,26 +166,29 @@ export const LogContainer: React.FC<LogContainerProps> = ({
           <span className="control-dot yellow"></span>
           <span className="control-dot green"></span>
         </div>
-        <div className="terminal-title">RFC5424 Log Stream</div>
+        <div className="terminal-title">{t('logs.title')}</div>
       </div>
       
       <div className="terminal-content">
         <div 
-          className={`log-container${displayOptions.compactMode ? ' compact-mode' : ''}`}
+          className={`log-container${displayOptions.compactMode ? ' compact-mode' : ''}${cardLayout ? ' card-layout' : ''}`}
           ref={containerRef}
           onScroll={handleScroll}
+          role="feed"
+          aria-label={t('logs.feed')}
+          aria-busy={isLoadingMore}
         >
           {isLoadingMore && (
-            <div className="loading-more">
+            <div className="loading-more" role="status">
               <div className="log-entry">
                 <div className="log-entry-header">
-                  <span className="timestamp">{getCurrentTime()}</span>
+                  <span className="timestamp">{formatDateTime(Date.now())}</span>
                   <span className="field-separator">|</span>
                   <span className="severity info">INFO</span>
                   <span className="field-separator">|</span>
                   <span className="app-name">system</span>
                 </div>
-                <div className="log-entry-message">Loading more logs...</div>
+                <div className="log-entry-message">{t('logs.loadingMore')}</div>
               </div>
             </div>
           )}
@@ -141,7 +197,7 @@ export const LogContainer: React.FC<LogContainerProps> = ({
             <div className="welcome-message">
               <div className="log-entry">
                 <div className="log-entry-header">
-                  <span className="timestamp">{getCurrentTime()}</span>
+                  <span className="timestamp">{formatDateTime(Date.now())}</span>
                   <span className="field-separator">|</span>
                   <span className="priority">P:134</span>
                   <span className="field-separator">|</span>
@@ -158,17 +214,37 @@ export const LogContainer: React.FC<LogContainerProps> = ({
                   <span className="msg-id">startup</span>
                 </div>
                 <div className="log-entry-message">
-                  OpenTrail RFC5424 log viewer initialized. Waiting for log entries...
+                  {t('logs.welcome')}
                 </div>
               </div>
             </div>
           ) : (
-            logs.map((log) => (
+            logs.map((log, index) => cardLayout ? (
+              <LogCard
+                key={log.id}
+                logEntry={log}
+                ansiColors={displayOptions.ansiColors ?? true}
+                showStructuredData={expandedIds.has(log.id)}
+                onToggleStructuredData={() => toggleStructuredData(log.id)}
+                isSelected={log.id === tabbableId}
+                onSelect={() => setSelectedId(log.id)}
+                onOpenDetail={() => onOpenDetail(log.id)}
+                position={index + 1}
+                setSize={hasMoreLogs ? -1 : logs.length}
+              />
+            ) : (
               <LogEntry
                 key={log.id}
                 logEntry={log}
                 displayOptions={displayOptions}
                 isNew={false}
+                showStructuredData={expandedIds.has(log.id)}
+                onToggleStructuredData={() => toggleStructuredData(log.id)}
+                isSelected={log.id === tabbableId}
+                onSelect={() => setSelectedId(log.id)}
+                onOpenDetail={() => onOpenDetail(log.id)}
+                position={index + 1}
+                setSize={hasMoreLogs ? -1 : logs.length}
               />
             ))
           )}
@@ -178,11 +254,13 @@ export const LogContainer: React.FC<LogContainerProps> = ({
           <div className="scroll-indicator visible">
             <button className="scroll-to-bottom" onClick={handleScrollToBottom}>
               <ArrowDown size={16} />
-              Scroll to bottom to resume auto-scroll
+              {t('logs.scrollToBottom')}
             </button>
           </div>
         )}
       </div>
     </div>
   );
-};
\ No newline at end of file
+});
+
+LogContainer.displayName = 'LogContainer';
\ No newline at end of file
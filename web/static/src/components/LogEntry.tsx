@@ -1,25 +1,63 @@
 import React, { useState } from 'react';
-import { ChevronDown, ChevronRight } from 'lucide-react';
-import { formatTimestamp, getFacilityName, getSeverityInfo } from '../utils/formatters';
+import { ChevronDown, ChevronRight, Info } from 'lucide-react';
+import { getFacilityName, getSeverityInfo } from '../utils/formatters';
+import { stripAnsi } from '../utils/ansi';
+import { useI18n, type TranslationKey } from '../i18n';
+import { ApiService } from '../services/api';
+import { MessageText } from './MessageText';
 import type { LogEntry as LogEntryType, DisplayOptions } from '../types';
 
 interface LogEntryProps {
   logEntry: LogEntryType;
   displayOptions: DisplayOptions;
   isNew?: boolean;
+  showStructuredData: boolean;
+  onToggleStructuredData: () => void;
+  // Only the selected entry is in the tab order; the others are reached with the arrow shortcuts
+  isSelected: boolean;
+  onSelect: () => void;
+  onOpenDetail: () => void;
+  position: number;
+  setSize: number;
 }
 
 export const LogEntry: React.FC<LogEntryProps> = ({ 
   logEntry, 
   displayOptions, 
-  isNew = false 
+  isNew = false,
+  showStructuredData,
+  onToggleStructuredData,
+  isSelected,
+  onSelect,
+  onOpenDetail,
+  position,
+  setSize
 }) => {
-  const [showStructuredData, setShowStructuredData] = useState(false);
+  const [showRaw, setShowRaw] = useState(false);
+  const [rawMessage, setRawMessage] = useState<string | null>(null);
+  const [rawError, setRawError] = useState<string | null>(null);
+  const { t, formatDateTime } = useI18n();
 
-  const timestamp = formatTimestamp(logEntry.timestamp);
+  // The raw message is fetched on first expansion to keep log listings small
+  const toggleRaw = () => {
+    const next = !showRaw;
+    setShowRaw(next);
+    if (next && rawMessage === null) {
+      setRawError(null);
+      ApiService.getInstance()
+        .fetchRawMessage(String(logEntry.id))
+        .then(setRawMessage)
+        .catch((error: Error) => setRawError(error.message));
+    }
+  };
+
+  const timestamp = formatDateTime(logEntry.timestamp);
   const priority = logEntry.priority || 0;
   const facility = getFacilityName(logEntry.facility || 0);
   const severity = getSeverityInfo(logEntry.severity || 0);
+  const severityLabel = severity.class === 'unknown'
+    ? severity.name
+    : t(`severity.${logEntry.severity || 0}` as TranslationKey);
   const hostname = logEntry.hostname || '-';
   const appName = logEntry.app_name || '-';
   const procId = logEntry.proc_id || '-';
@@ -29,8 +67,29 @@ export const LogEntry: React.FC<LogEntryProps> = ({
   const hasStructuredData = logEntry.structured_data && 
     Object.keys(logEntry.structured_data).length > 0;
   return (
-    <div className={`log-entry${isNew ? ' new' : ''}`}>
-      <div className="log-entry-line">
+    <div
+      className={`log-entry${isNew ? ' new' : ''}${isSelected ? ' selected' : ''}`}
+      data-entry-id={logEntry.id}
+      role="article"
+      tabIndex={isSelected ? 0 : -1}
+      aria-posinset={position}
+      aria-setsize={setSize}
+      aria-label={t('entry.summary', {
+        severity: severityLabel,
+        hostname,
+        app: appName,
+        time: timestamp,
+        message: stripAnsi(message)
+      })}
+      onFocus={(e) => {
+        if (e.target === e.currentTarget) onSelect();
+      }}
+    >
+      <div className="log-entry-line" aria-hidden="true">
+        {logEntry.archived && (
+          <span className="archived" title={t('entry.archived')}>{t('entry.archivedBadge')}</span>
+        )}
+
         {displayOptions.showTimestamp && (
           <>
             <span className="timestamp">{timestamp}</span>
@@ -61,6 +120,9 @@ export const LogEntry: React.FC<LogEntryProps> = ({
         
         {displayOptions.showHostname && (
           <>
+            {logEntry.node && (
+              <span className="node" title={t('entry.node', { node: logEntry.node })}>{logEntry.node}</span>
+            )}
             <span className="hostname">{hostname}</span>
             {displayOptions.showSeparators && <span className="field-separator">|</span>}
           </>
@@ -87,24 +149,31 @@ export const LogEntry: React.FC<LogEntryProps> = ({
           </>
         )}
         
-        <span className="log-entry-message">{message}</span>
+        <span className="log-entry-message">
+          <MessageText
+            text={message}
+            highlights={logEntry.highlights}
+            colors={displayOptions.ansiColors ?? true}
+          />
+        </span>
       </div>
       
       {hasStructuredData && (
         <div className="log-entry-structured-data">
           <button 
             className="structured-data-toggle"
-            onClick={() => setShowStructuredData(!showStructuredData)}
+            onClick={onToggleStructuredData}
+            aria-expanded={showStructuredData}
           >
             {showStructuredData ? (
               <>
                 <ChevronDown size={12} />
-                Hide Structured Data
+                {t('entry.hideStructuredData')}
               </>
             ) : (
               <>
                 <ChevronRight size={12} />
-                Show Structured Data
+                {t('entry.showStructuredData')}
               </>
       )}
           </button>
@@ -116,6 +185,43 @@ export const LogEntry: React.FC<LogEntryProps> = ({
           )}
         </div>
       )}
+
+      {logEntry.id && (
+        <div className="log-entry-structured-data">
+          <button
+            className="structured-data-toggle"
+            onClick={toggleRaw}
+            aria-expanded={showRaw}
+          >
+            {showRaw ? (
+              <>
+                <ChevronDown size={12} />
+                {t('entry.hideRaw')}
+              </>
+            ) : (
+              <>
+                <ChevronRight size={12} />
+                {t('entry.showRaw')}
+              </>
+            )}
+          </button>
+
+          {showRaw && (
+            <div className="structured-data-content">
+              <pre>{rawError ? t('entry.rawUnavailable', { error: rawError }) : rawMessage ?? t('entry.loading')}</pre>
+            </div>
+          )}
+        </div>
+      )}
+
+      {logEntry.id && (
+        <div className="log-entry-structured-data">
+          <button className="structured-data-toggle" onClick={onOpenDetail}>
+            <Info size={12} />
+            {t('entry.openDetail')}
+          </button>
+        </div>
+      )}
     </div>
   );
 };
\ No newline at end of file
@@ -0,0 +1,49 @@
+import React from 'react';
+import { colorOf, splitMessage, type AnsiStyle } from '../utils/ansi';
+import type { TextRange } from '../types';
+
+interface MessageTextProps {
+  text: string;
+  highlights?: TextRange[];
+  // Renders ANSI colors and styles; without it escape sequences are only removed
+  colors: boolean;
+}
+
+// styleProps turns an ANSI style into class names for the 16 theme colors and inline colors for the
+// rest. Everything comes from parsed numbers, so no text of the message reaches the markup.
+const styleProps = (style: AnsiStyle): { className?: string; style?: React.CSSProperties } => {
+  const classes: string[] = [];
+  const inline: React.CSSProperties = {};
+  for (const [kind, value] of [['fg', style.fg], ['bg', style.bg]] as const) {
+    if (value === undefined) continue;
+    const color = colorOf(value);
+    if (typeof color === 'number') {
+      classes.push(`ansi-${kind}-${color}`);
+    } else if (kind === 'fg') {
+      inline.color = color;
+    } else {
+      inline.backgroundColor = color;
+    }
+  }
+  if (style.bold) classes.push('ansi-bold');
+  if (style.dim) classes.push('ansi-dim');
+  if (style.italic) classes.push('ansi-italic');
+  if (style.underline) classes.push('ansi-underline');
+  return {
+    className: classes.length ? classes.join(' ') : undefined,
+    style: Object.keys(inline).length ? inline : undefined
+  };
+};
+
+// MessageText renders a message with its search matches marked and its ANSI colors applied
+export const MessageText: React.FC<MessageTextProps> = ({ text, highlights, colors }) => (
+  <>
+    {splitMessage(text, highlights, colors).map((segment, index) => {
+      const content = segment.match ? <mark>{segment.text}</mark> : segment.text;
+      const props = styleProps(segment.style);
+      return props.className || props.style
+        ? <span key={index} {...props}>{content}</span>
+        : <React.Fragment key={index}>{content}</React.Fragment>;
+    })}
+  </>
+);
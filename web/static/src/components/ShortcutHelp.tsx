@@ -0,0 +1,76 @@
+import React, { useEffect, useRef } from 'react';
+import { useI18n, type TranslationKey } from '../i18n';
+import type { Shortcut } from '../types';
+
+interface ShortcutHelpProps {
+  shortcuts: Shortcut[];
+  onClose: () => void;
+}
+
+const KEY_LABELS: Record<string, string> = {
+  ArrowDown: '↓',
+  ArrowUp: '↑',
+  Escape: 'Esc'
+};
+
+export const ShortcutHelp: React.FC<ShortcutHelpProps> = ({ shortcuts, onClose }) => {
+  const closeRef = useRef<HTMLButtonElement>(null);
+  const { t } = useI18n();
+
+  // Focus moves into the dialog while it is open and returns to where it was when it closes
+  useEffect(() => {
+    const previous = document.activeElement;
+    closeRef.current?.focus();
+    return () => {
+      if (previous instanceof HTMLElement) previous.focus();
+    };
+  }, []);
+
+  // Actions the catalogs do not know yet are described by the server
+  const describe = (shortcut: Shortcut) => {
+    const key = `shortcuts.action.${shortcut.action}`;
+    const text = t(key as TranslationKey);
+    return text === key ? shortcut.description : text;
+  };
+
+  return (
+    <div className="modal-overlay" onClick={onClose}>
+      <div
+        className="modal"
+        role="dialog"
+        aria-modal="true"
+        aria-labelledby="shortcut-help-title"
+        onClick={(e) => e.stopPropagation()}
+        onKeyDown={(e) => {
+          // The close button is the only focusable element, so Tab keeps focus on it
+          if (e.key === 'Tab') {
+            e.preventDefault();
+            closeRef.current?.focus();
+          }
+        }}
+      >
+        <div className="modal-header">
+          <h3 id="shortcut-help-title">{t('shortcuts.title')}</h3>
+          <button ref={closeRef} className="btn-secondary" onClick={onClose}>
+            {t('shortcuts.close')}
+          </button>
+        </div>
+
+        <table className="shortcut-table">
+          <tbody>
+            {shortcuts.map(shortcut => (
+              <tr key={shortcut.action}>
+                <td className="shortcut-keys">
+                  {shortcut.keys.map(key => (
+                    <kbd key={key} aria-label={key}>{KEY_LABELS[key] ?? key}</kbd>
+                  ))}
+                </td>
+                <td>{describe(shortcut)}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  );
+};
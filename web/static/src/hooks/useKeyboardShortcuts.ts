@@ -0,0 +1,49 @@
+import { useEffect, useRef } from 'react';
+import type { Shortcut } from '../types';
+
+type ShortcutHandlers = Partial<Record<string, () => void>>;
+
+const EDITABLE_TAGS = ['INPUT', 'TEXTAREA', 'SELECT'];
+
+const isEditable = (target: EventTarget | null): boolean =>
+  target instanceof HTMLElement && (target.isContentEditable || EDITABLE_TAGS.includes(target.tagName));
+
+export const useKeyboardShortcuts = (shortcuts: Shortcut[], handlers: ShortcutHandlers) => {
+  // Handlers are read through a ref so the listener is not re-registered on every render
+  const handlersRef = useRef(handlers);
+  handlersRef.current = handlers;
+
+  useEffect(() => {
+    const actions = new Map<string, string>();
+    for (const shortcut of shortcuts) {
+      for (const key of shortcut.keys) {
+        actions.set(key, shortcut.action);
+      }
+    }
+
+    const handleKeyDown = (event: KeyboardEvent) => {
+      if (event.defaultPrevented || event.ctrlKey || event.metaKey || event.altKey) return;
+
+      const action = actions.get(event.key);
+      if (!action) return;
+
+      // While typing, only Escape is a shortcut, so the field can be left without the mouse
+      if (isEditable(event.target) && event.key !== 'Escape') return;
+
+      // Enter keeps activating buttons and links
+      if (event.key === 'Enter' && event.target instanceof HTMLElement &&
+          ['BUTTON', 'A'].includes(event.target.tagName)) {
+        return;
+      }
+
+      const handler = handlersRef.current[action];
+      if (!handler) return;
+
+      event.preventDefault();
+      handler();
+    };
+
+    window.addEventListener('keydown', handleKeyDown);
+    return () => window.removeEventListener('keydown', handleKeyDown);
+  }, [shortcuts]);
+};
@@ -1,5 +1,6 @@
 import { useState, useEffect, useRef, useCallback } from 'react';
 import type { LogEntry, ConnectionStatus } from '../types';
+import { BASE_PATH } from '../utils/constants';
 
 interface UseWebSocketOptions {
   onMessage: (logEntry: LogEntry) => void;
@@ -21,8 +22,8 @@ export const useWebSocket = ({
   const reconnectAttemptsRef = useRef(0);
   const reconnectTimeoutRef = useRef<number | null>(null);
 
-  const updateConnectionStatus = useCallback((status: ConnectionStatus['status'], text: string) => {
-    setConnectionStatus({ status, text });
+  const updateConnectionStatus = useCallback((status: ConnectionStatus['status'], text: string, retryIn?: number) => {
+    setConnectionStatus({ status, text, retryIn });
   }, []);
 
   const connect = useCallback(() => {
@@ -33,7 +34,7 @@ export const useWebSocket = ({
     updateConnectionStatus('connecting', 'Connecting...');
 
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/api/logs/stream`;
+    const wsUrl = `${protocol}//${window.location.host}${BASE_PATH}/api/logs/stream`;
 
     try {
       wsRef.current = new WebSocket(wsUrl);
@@ -78,7 +79,7 @@ export const useWebSocket = ({
     reconnectAttemptsRef.current++;
     const delay = reconnectDelay * Math.pow(2, reconnectAttemptsRef.current - 1);
 
-    updateConnectionStatus('connecting', `Reconnecting in ${Math.ceil(delay / 1000)}s...`);
+    updateConnectionStatus('connecting', `Reconnecting in ${Math.ceil(delay / 1000)}s...`, Math.ceil(delay / 1000));
 
     reconnectTimeoutRef.current = setTimeout(() => {
       if (!wsRef.current || wsRef.current.readyState !== WebSocket.OPEN) {
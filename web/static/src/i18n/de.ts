@@ -0,0 +1,301 @@
+import type { Catalog } from './en';
+
+export const de: Catalog = {
+  'app.dismissError': 'Fehler ausblenden',
+  'app.loadInitialFailed': 'Logs konnten nicht geladen werden',
+  'app.loadMoreFailed': 'Weitere Logs konnten nicht geladen werden',
+  'language.label': 'Sprache',
+
+  'connection.connecting': 'Verbinde...',
+  'connection.connected': 'Verbunden',
+  'connection.disconnected': 'Getrennt',
+  'connection.error': 'Verbindungsfehler',
+  'connection.reconnecting': 'Neuer Versuch in {seconds} s...',
+
+  'filters.title': 'RFC5424-Filter',
+  'filters.show': 'Filter einblenden',
+  'filters.hide': 'Filter ausblenden',
+  'filters.facility': 'Facility:',
+  'filters.severity': 'Schweregrad:',
+  'filters.minSeverity': 'Mindestschweregrad:',
+  'filters.all': 'Alle',
+  'filters.none': 'Keiner',
+  'filters.hostname': 'Hostname:',
+  'filters.hostnamePlaceholder': 'Nach Hostname filtern',
+  'filters.appName': 'Anwendung:',
+  'filters.appNamePlaceholder': 'Nach Anwendung filtern',
+  'filters.procId': 'Prozess-ID:',
+  'filters.procIdPlaceholder': 'Nach Prozess-ID filtern',
+  'filters.msgId': 'Nachrichten-ID:',
+  'filters.msgIdPlaceholder': 'Nach Nachrichten-ID filtern',
+  'filters.text': 'Nachrichtentext:',
+  'filters.textPlaceholder': 'In Nachrichten suchen',
+  'filters.apply': 'Filter anwenden',
+  'filters.clear': 'Alle zurücksetzen',
+  'filters.removeField': 'Filter auf {field} entfernen',
+  'filters.pinned': 'Angeheftete Filter',
+  'filters.unpin': '{name} lösen',
+  'filters.recent': 'Letzte Suchen:',
+  'filters.recentPlaceholder': 'Letzte Suche wiederholen',
+  'filters.pinName': 'Filter anheften als:',
+  'filters.pinNamePlaceholder': 'Name der angehefteten Filter',
+  'filters.pin': 'Anheften',
+
+  'severity.0': 'Notfall',
+  'severity.1': 'Alarm',
+  'severity.2': 'Kritisch',
+  'severity.3': 'Fehler',
+  'severity.4': 'Warnung',
+  'severity.5': 'Hinweis',
+  'severity.6': 'Info',
+  'severity.7': 'Debug',
+
+  'display.title': 'Anzeigeoptionen',
+  'display.show': 'Optionen einblenden',
+  'display.hide': 'Optionen ausblenden',
+  'display.fields': 'Felder ein-/ausblenden',
+  'display.layout': 'Layout',
+  'display.separators': 'Feldtrenner',
+  'display.compact': 'Kompakte Ansicht',
+  'display.ansiColors': 'Nachrichtenfarben (ANSI)',
+  'display.layoutMode': 'Log-Layout',
+  'display.layout.auto': 'Automatisch (Karten auf kleinen Bildschirmen)',
+  'display.layout.lines': 'Zeilen',
+  'display.layout.cards': 'Karten',
+  'display.reset': 'Standard wiederherstellen',
+
+  'field.timestamp': 'Zeitstempel',
+  'field.priority': 'Priorität',
+  'field.facility': 'Facility',
+  'field.severity': 'Schweregrad',
+  'field.hostname': 'Hostname',
+  'field.appName': 'Anwendung',
+  'field.procId': 'Prozess-ID',
+  'field.msgId': 'Nachrichten-ID',
+
+  'alerts.title': 'Alarmverlauf',
+  'alerts.show': 'Zeitleiste einblenden',
+  'alerts.hide': 'Zeitleiste ausblenden',
+  'alerts.loadFailed': 'Alarmverlauf konnte nicht geladen werden',
+  'alerts.empty': 'In den letzten {days} Tagen wurde kein Alarm ausgelöst',
+  'alerts.stillFiring': 'noch aktiv',
+  'alerts.state.firing': 'ausgelöst',
+  'alerts.state.resolved': 'behoben',
+  'alerts.marker': '{name} {state} um {time}',
+  'alerts.detail.one': '{state} um {time}: {count} Eintrag gefunden, Schwellwert {threshold}',
+  'alerts.detail.other': '{state} um {time}: {count} Einträge gefunden, Schwellwert {threshold}',
+
+  'agents.title': 'Agenten',
+  'agents.show': 'Agenten einblenden',
+  'agents.hide': 'Agenten ausblenden',
+  'agents.loadFailed': 'Agenten konnten nicht geladen werden',
+  'agents.empty': 'Es hat sich noch kein Agent registriert',
+  'agents.hostname': 'Host',
+  'agents.status': 'Status',
+  'agents.config': 'Konfiguration',
+  'agents.lag': 'Rückstand',
+  'agents.lastSeen': 'Zuletzt gesehen',
+  'agents.connected': 'verbunden',
+  'agents.disconnected': 'getrennt',
+  'agents.noConfig': 'keine',
+  'agents.outdated': 'veraltet',
+
+  'admin.title': 'Administration',
+  'admin.show': 'Administration anzeigen',
+  'admin.hide': 'Administration ausblenden',
+  'admin.loadFailed': 'Laden fehlgeschlagen',
+  'admin.tab.server': 'Server',
+  'admin.tab.storage': 'Speicher',
+  'admin.tab.connections': 'Verbindungen',
+  'admin.tab.sizes': 'Eintragsgrößen',
+  'admin.tab.notifications': 'Benachrichtigungen',
+  'admin.tab.users': 'Benutzer',
+  'admin.tab.tokens': 'API-Tokens',
+  'admin.tab.alerts': 'Alarme',
+  'admin.version': 'Version',
+  'admin.processed': 'Verarbeitete Einträge',
+  'admin.failed': 'Fehlgeschlagene Einträge',
+  'admin.duplicates': 'Verworfene Duplikate',
+  'admin.spooled': 'Im eingeschränkten Modus zwischengespeichert',
+  'admin.unstored': 'Weitergeleitet, nicht gespeichert',
+  'admin.queue': 'Warteschlange',
+  'admin.subscribers': 'Live-Zuschauer',
+  'admin.rejectedSearches': 'Abgelehnte Suchen',
+  'admin.requests': 'HTTP-Anfragen',
+  'admin.requestErrors': 'HTTP-Fehler',
+  'admin.rateLimited': 'Ratenbegrenzt',
+  'admin.mode': 'Modus',
+  'admin.mode.normal': 'Normal',
+  'admin.mode.read_only': 'Nur lesen',
+  'admin.mode.maintenance': 'Wartung',
+  'admin.mode.degraded': 'Eingeschränkt',
+  'admin.degraded': 'Speicher nicht verfügbar ({message}): Einträge werden weitergeleitet und zwischengespeichert, Suchen sind pausiert',
+  'admin.modeMessage': 'Hinweis',
+  'admin.apply': 'Übernehmen',
+  'admin.modeChanged': 'Modus {mode} aktiviert',
+  'admin.database': 'Datenbank',
+  'admin.limit': 'Limit',
+  'admin.diskFree': 'Freier Speicherplatz',
+  'admin.retention': 'Aufbewahrung',
+  'admin.retentionDays': 'Aufbewahrung in Tagen',
+  'admin.retentionConfigured': 'Zur Laufzeit gesetzt; die Konfiguration bewahrt {days} auf',
+  'admin.retentionChanged': 'Aufbewahrung auf {days} gesetzt',
+  'admin.retentionReset': 'Aufbewahrung auf die konfigurierten {days} zurückgesetzt',
+  'admin.resetRetention': 'Konfiguration verwenden',
+  'admin.growth': 'Wachstum pro Tag',
+  'admin.retained': 'Größe bei Aufbewahrung',
+  'admin.untilLimit': 'Zeit bis zum Limit',
+  'admin.days.one': '{count} Tag',
+  'admin.days.other': '{count} Tage',
+  'admin.never': 'nie',
+  'admin.none': 'keins',
+  'admin.unknown': 'unbekannt',
+  'admin.noConnections': 'Kein Sender verbunden',
+  'admin.remote': 'Sender',
+  'admin.connectedAt': 'Verbunden',
+  'admin.messages': 'Nachrichten',
+  'admin.bytes': 'Empfangen',
+  'admin.parseErrors': 'Parserfehler',
+  'admin.close': 'Schließen',
+  'admin.connectionClosed': 'Verbindung von {remote} geschlossen',
+  'admin.noChannels': 'Kein Benachrichtigungskanal konfiguriert',
+  'admin.sendTest': 'Test senden',
+  'admin.testSent': 'Testbenachrichtigung an {channel} gesendet',
+  'admin.noSizes': 'Seit dem letzten Zurücksetzen keine Einträge empfangen',
+  'admin.app': 'App',
+  'admin.entries': 'Einträge',
+  'admin.messageAvg': 'Nachricht Ø',
+  'admin.messageP95': 'Nachricht p95',
+  'admin.messageMax': 'Größte Nachricht',
+  'admin.structuredDataAvg': 'Strukturierte Daten Ø',
+  'admin.structuredDataP95': 'Strukturierte Daten p95',
+  'admin.resetSizes': 'Zurücksetzen',
+  'admin.sizesReset': 'Eintragsgrößen zurückgesetzt',
+  'admin.username': 'Benutzername',
+  'admin.password': 'Passwort',
+  'admin.newPassword': 'Neues Passwort',
+  'admin.role': 'Rolle',
+  'admin.role.admin': 'Admin',
+  'admin.role.reader': 'Leser',
+  'admin.created': 'Erstellt',
+  'admin.add': 'Hinzufügen',
+  'admin.save': 'Speichern',
+  'admin.delete': 'Löschen',
+  'admin.noUsers': 'Keine Benutzer außer den Konten der Konfiguration',
+  'admin.userCreated': '{username} hinzugefügt',
+  'admin.userUpdated': '{username} geändert',
+  'admin.userDeleted': '{username} gelöscht',
+  'admin.tokenName': 'Name',
+  'admin.expiresIn': 'Läuft ab in',
+  'admin.expires': 'Läuft ab',
+  'admin.lastUsed': 'Zuletzt verwendet',
+  'admin.createdBy': 'Erstellt von',
+  'admin.noTokens': 'Keine API-Tokens vorhanden',
+  'admin.tokenCreated': 'Token von {name} jetzt kopieren, es wird nicht erneut angezeigt:',
+  'admin.tokenDeleted': '{name} widerrufen',
+  'admin.revoke': 'Widerrufen',
+  'admin.alertName': 'Name',
+  'admin.alertQuery': 'Abfrage',
+  'admin.alertThreshold': 'Schwellenwert',
+  'admin.alertInterval': 'Geprüft alle',
+  'admin.alertMinutes': 'Geprüft alle (Minuten)',
+  'admin.alertExtrapolate': 'Gesampelte Nachrichten zählen',
+  'admin.alertState': 'Zustand',
+  'admin.alertFiring': 'ausgelöst',
+  'admin.alertOk': 'ok',
+  'admin.lastRun': 'Letzte Ausführung',
+  'admin.minutes.one': '{count} Minute',
+  'admin.minutes.other': '{count} Minuten',
+  'admin.noAlerts': 'Es gibt keine Alarmregel',
+  'admin.edit': 'Bearbeiten',
+  'admin.cancel': 'Abbrechen',
+  'admin.alertCreated': 'Alarm {name} hinzugefügt',
+  'admin.alertUpdated': 'Alarm {name} aktualisiert',
+  'admin.alertDeleted': 'Alarm {name} gelöscht',
+
+  'compare.title': 'Vergleich',
+  'compare.show': 'Vergleich einblenden',
+  'compare.hide': 'Vergleich ausblenden',
+  'compare.loadFailed': 'Vergleich fehlgeschlagen',
+  'compare.window': 'Letzte',
+  'compare.baseline': 'Verglichen mit',
+  'compare.yesterday': 'Gestern',
+  'compare.lastWeek': 'Letzte Woche',
+  'compare.deploy': 'Vor dem Deployment',
+  'compare.deployTime': 'Deployment-Zeitpunkt',
+  'compare.run': 'Vergleichen',
+  'compare.empty': 'Keine Einträge in beiden Zeiträumen',
+  'compare.totals': '{baseline} → {current} Einträge',
+  'compare.truncated': 'Nur die neuesten Einträge wurden gezählt',
+  'compare.pattern': 'Muster',
+  'compare.app': 'App',
+  'compare.severity': 'Schweregrad',
+  'compare.baselineCount': 'Vorher',
+  'compare.currentCount': 'Jetzt',
+  'compare.delta': 'Änderung',
+  'compare.new': 'neu',
+  'compare.gone': 'verschwunden',
+
+  'histogram.title': 'Volumen',
+  'histogram.show': 'Volumen einblenden',
+  'histogram.hide': 'Volumen ausblenden',
+  'histogram.loadFailed': 'Volumen konnte nicht geladen werden',
+  'histogram.range': 'Letzte',
+  'histogram.total.one': '{count} Eintrag, {interval} pro Balken',
+  'histogram.total.other': '{count} Einträge, {interval} pro Balken',
+  'histogram.extrapolated': '~{count} Meldungen nach Stichprobenrate',
+  'histogram.empty': 'Keine passenden Einträge in diesem Zeitraum',
+  'histogram.bundle': 'Vorfallpaket exportieren',
+
+  'entry.showStructuredData': 'Strukturierte Daten einblenden',
+  'entry.hideStructuredData': 'Strukturierte Daten ausblenden',
+  'entry.showRaw': 'Rohnachricht einblenden',
+  'entry.hideRaw': 'Rohnachricht ausblenden',
+  'entry.rawUnavailable': 'Rohnachricht nicht verfügbar: {error}',
+  'entry.loading': 'Wird geladen...',
+  'entry.swipeHint': 'Karte seitlich wischen, um strukturierte Daten ein- oder auszublenden',
+  'entry.openDetail': 'Details',
+
+  'detail.title': 'Eintrag {id}',
+  'detail.close': 'Details schließen',
+  'detail.loadFailed': 'Eintrag konnte nicht geladen werden: {error}',
+  'detail.message': 'Nachricht',
+  'detail.fields': 'Felder',
+  'detail.structuredData': 'Strukturierte Daten',
+  'detail.metadata': 'Metadaten',
+  'detail.sourceIp': 'Quell-IP',
+  'detail.chain': 'Hash-Kette',
+  'detail.raw': 'Rohnachricht',
+  'detail.noRaw': 'Für diesen Eintrag wurde keine Rohnachricht aufbewahrt',
+  'detail.copyCurl': 'Als curl kopieren',
+  'detail.copyRaw': 'Rohnachricht kopieren',
+  'detail.copyField': '{field} kopieren',
+  'detail.copied': 'Kopiert',
+  'detail.pivot': 'Nach diesem Wert von {field} filtern',
+
+  'logs.title': 'RFC5424-Logstream',
+  'logs.loadingMore': 'Weitere Logs werden geladen...',
+  'logs.welcome': 'OpenTrail RFC5424-Logviewer gestartet. Warte auf Logeinträge...',
+  'logs.scrollToBottom': 'Nach unten scrollen, um automatisches Scrollen fortzusetzen',
+  'logs.feed': 'Logeinträge',
+  'logs.liveTailOn': 'Live-Ansicht fortgesetzt',
+  'logs.liveTailOff': 'Live-Ansicht pausiert',
+  'logs.bufferMatches.one': '{count} Treffer unter den Einträgen der letzten {minutes} Min. im Speicher des Servers',
+  'logs.bufferMatches.other': '{count} Treffer unter den Einträgen der letzten {minutes} Min. im Speicher des Servers',
+
+  'entry.summary': '{severity} von {hostname} {app} um {time}: {message}',
+  'entry.node': 'Empfangen von Clusterknoten {node}',
+  'entry.archived': 'Aus dem Archiv gelesen, älter als die Aufbewahrungsdauer',
+  'entry.archivedBadge': 'Archiv',
+
+  'shortcuts.title': 'Tastenkürzel',
+  'shortcuts.close': 'Schließen',
+  'shortcuts.hint': '? drücken, um die Tastenkürzel anzuzeigen',
+  'shortcuts.action.focus-search': 'Nachrichtensuche fokussieren',
+  'shortcuts.action.next-entry': 'Nächsten Eintrag auswählen',
+  'shortcuts.action.previous-entry': 'Vorherigen Eintrag auswählen',
+  'shortcuts.action.toggle-details': 'Strukturierte Daten des ausgewählten Eintrags ein- oder ausblenden',
+  'shortcuts.action.toggle-live-tail': 'Live-Ansicht pausieren oder fortsetzen',
+  'shortcuts.action.show-help': 'Tastenkürzel anzeigen',
+  'shortcuts.action.close': 'Dialog schließen oder Suchfeld verlassen'
+};
@@ -0,0 +1,306 @@
+// English is the reference catalog: every key exists here and other languages fall back to it.
+// Placeholders are written {name}; keys ending in .one/.other are plural forms selected by {count}.
+export const en = {
+  'app.dismissError': 'Dismiss error',
+  'app.loadInitialFailed': 'Failed to load initial logs',
+  'app.loadMoreFailed': 'Failed to load more logs',
+  'language.label': 'Language',
+
+  'connection.connecting': 'Connecting...',
+  'connection.connected': 'Connected',
+  'connection.disconnected': 'Disconnected',
+  'connection.error': 'Connection error',
+  'connection.reconnecting': 'Reconnecting in {seconds}s...',
+
+  'filters.title': 'RFC5424 Filters',
+  'filters.show': 'Show Filters',
+  'filters.hide': 'Hide Filters',
+  'filters.facility': 'Facility:',
+  'filters.severity': 'Severity:',
+  'filters.minSeverity': 'Min Severity:',
+  'filters.all': 'All',
+  'filters.none': 'None',
+  'filters.hostname': 'Hostname:',
+  'filters.hostnamePlaceholder': 'Filter by hostname',
+  'filters.appName': 'App Name:',
+  'filters.appNamePlaceholder': 'Filter by application',
+  'filters.procId': 'Process ID:',
+  'filters.procIdPlaceholder': 'Filter by process ID',
+  'filters.msgId': 'Message ID:',
+  'filters.msgIdPlaceholder': 'Filter by message ID',
+  'filters.text': 'Message Text:',
+  'filters.textPlaceholder': 'Search in message content',
+  'filters.apply': 'Apply Filters',
+  'filters.clear': 'Clear All',
+  'filters.removeField': 'Remove filter on {field}',
+  'filters.pinned': 'Pinned filters',
+  'filters.unpin': 'Unpin {name}',
+  'filters.recent': 'Recent Searches:',
+  'filters.recentPlaceholder': 'Repeat a recent search',
+  'filters.pinName': 'Pin Filters As:',
+  'filters.pinNamePlaceholder': 'Name of the pinned filters',
+  'filters.pin': 'Pin',
+
+  'severity.0': 'Emergency',
+  'severity.1': 'Alert',
+  'severity.2': 'Critical',
+  'severity.3': 'Error',
+  'severity.4': 'Warning',
+  'severity.5': 'Notice',
+  'severity.6': 'Info',
+  'severity.7': 'Debug',
+
+  'display.title': 'Display Options',
+  'display.show': 'Show Options',
+  'display.hide': 'Hide Options',
+  'display.fields': 'Show/Hide Fields',
+  'display.layout': 'Layout Options',
+  'display.separators': 'Field Separators',
+  'display.compact': 'Compact Mode',
+  'display.ansiColors': 'Message colors (ANSI)',
+  'display.layoutMode': 'Log layout',
+  'display.layout.auto': 'Automatic (cards on small screens)',
+  'display.layout.lines': 'Lines',
+  'display.layout.cards': 'Cards',
+  'display.reset': 'Reset to Default',
+
+  'field.timestamp': 'Timestamp',
+  'field.priority': 'Priority',
+  'field.facility': 'Facility',
+  'field.severity': 'Severity',
+  'field.hostname': 'Hostname',
+  'field.appName': 'App Name',
+  'field.procId': 'Process ID',
+  'field.msgId': 'Message ID',
+
+  'alerts.title': 'Alert History',
+  'alerts.show': 'Show Timeline',
+  'alerts.hide': 'Hide Timeline',
+  'alerts.loadFailed': 'Failed to load alert history',
+  'alerts.empty': 'No alerts fired in the last {days} days',
+  'alerts.stillFiring': 'still firing',
+  'alerts.state.firing': 'firing',
+  'alerts.state.resolved': 'resolved',
+  'alerts.marker': '{name} {state} at {time}',
+  'alerts.detail.one': '{state} at {time}: {count} entry matched, threshold {threshold}',
+  'alerts.detail.other': '{state} at {time}: {count} entries matched, threshold {threshold}',
+
+  'agents.title': 'Agents',
+  'agents.show': 'Show Agents',
+  'agents.hide': 'Hide Agents',
+  'agents.loadFailed': 'Failed to load agents',
+  'agents.empty': 'No agent has registered',
+  'agents.hostname': 'Host',
+  'agents.status': 'Status',
+  'agents.config': 'Configuration',
+  'agents.lag': 'Lag',
+  'agents.lastSeen': 'Last seen',
+  'agents.connected': 'connected',
+  'agents.disconnected': 'disconnected',
+  'agents.noConfig': 'none',
+  'agents.outdated': 'outdated',
+
+  'admin.title': 'Administration',
+  'admin.show': 'Show Administration',
+  'admin.hide': 'Hide Administration',
+  'admin.loadFailed': 'Failed to load',
+  'admin.tab.server': 'Server',
+  'admin.tab.storage': 'Storage',
+  'admin.tab.connections': 'Connections',
+  'admin.tab.sizes': 'Entry sizes',
+  'admin.tab.notifications': 'Notifications',
+  'admin.tab.users': 'Users',
+  'admin.tab.tokens': 'API tokens',
+  'admin.tab.alerts': 'Alerts',
+  'admin.version': 'Version',
+  'admin.processed': 'Processed entries',
+  'admin.failed': 'Failed entries',
+  'admin.duplicates': 'Duplicates dropped',
+  'admin.spooled': 'Spooled while degraded',
+  'admin.unstored': 'Forwarded, not stored',
+  'admin.queue': 'Queue',
+  'admin.subscribers': 'Live viewers',
+  'admin.rejectedSearches': 'Rejected searches',
+  'admin.requests': 'HTTP requests',
+  'admin.requestErrors': 'HTTP errors',
+  'admin.rateLimited': 'Rate limited',
+  'admin.mode': 'Mode',
+  'admin.mode.normal': 'Normal',
+  'admin.mode.read_only': 'Read-only',
+  'admin.mode.maintenance': 'Maintenance',
+  'admin.mode.degraded': 'Degraded',
+  'admin.degraded': 'Storage is unavailable ({message}): entries are forwarded and spooled, searches are paused',
+  'admin.modeMessage': 'Notice',
+  'admin.apply': 'Apply',
+  'admin.modeChanged': 'Switched to {mode} mode',
+  'admin.database': 'Database',
+  'admin.limit': 'Limit',
+  'admin.diskFree': 'Free disk space',
+  'admin.retention': 'Retention',
+  'admin.retentionDays': 'Retention in days',
+  'admin.retentionConfigured': 'Set at runtime; the configuration keeps {days}',
+  'admin.retentionChanged': 'Retention set to {days}',
+  'admin.retentionReset': 'Retention reset to the configured {days}',
+  'admin.resetRetention': 'Use configured',
+  'admin.growth': 'Growth per day',
+  'admin.retained': 'Size at retention',
+  'admin.untilLimit': 'Limit reached in',
+  'admin.days.one': '{count} day',
+  'admin.days.other': '{count} days',
+  'admin.never': 'never',
+  'admin.none': 'none',
+  'admin.unknown': 'unknown',
+  'admin.noConnections': 'No sender is connected',
+  'admin.remote': 'Sender',
+  'admin.connectedAt': 'Connected',
+  'admin.messages': 'Messages',
+  'admin.bytes': 'Received',
+  'admin.parseErrors': 'Parse errors',
+  'admin.close': 'Close',
+  'admin.connectionClosed': 'Closed the connection of {remote}',
+  'admin.noChannels': 'No notification channel is configured',
+  'admin.sendTest': 'Send test',
+  'admin.testSent': 'Sent a test notification to {channel}',
+  'admin.noSizes': 'No entries received since the last reset',
+  'admin.app': 'App',
+  'admin.entries': 'Entries',
+  'admin.messageAvg': 'Message avg',
+  'admin.messageP95': 'Message p95',
+  'admin.messageMax': 'Largest message',
+  'admin.structuredDataAvg': 'Structured data avg',
+  'admin.structuredDataP95': 'Structured data p95',
+  'admin.resetSizes': 'Reset',
+  'admin.sizesReset': 'Entry sizes reset',
+  'admin.username': 'Username',
+  'admin.password': 'Password',
+  'admin.newPassword': 'New password',
+  'admin.role': 'Role',
+  'admin.role.admin': 'Admin',
+  'admin.role.reader': 'Reader',
+  'admin.created': 'Created',
+  'admin.add': 'Add',
+  'admin.save': 'Save',
+  'admin.delete': 'Delete',
+  'admin.noUsers': 'No users besides the accounts of the configuration',
+  'admin.userCreated': 'Added {username}',
+  'admin.userUpdated': 'Updated {username}',
+  'admin.userDeleted': 'Deleted {username}',
+  'admin.tokenName': 'Name',
+  'admin.expiresIn': 'Expires in',
+  'admin.expires': 'Expires',
+  'admin.lastUsed': 'Last used',
+  'admin.createdBy': 'Created by',
+  'admin.noTokens': 'No API token exists',
+  'admin.tokenCreated': 'Copy the token of {name} now, it is not shown again:',
+  'admin.tokenDeleted': 'Revoked {name}',
+  'admin.revoke': 'Revoke',
+  'admin.alertName': 'Name',
+  'admin.alertQuery': 'Query',
+  'admin.alertThreshold': 'Threshold',
+  'admin.alertInterval': 'Checked every',
+  'admin.alertMinutes': 'Checked every (minutes)',
+  'admin.alertExtrapolate': 'Count sampled messages',
+  'admin.alertState': 'State',
+  'admin.alertFiring': 'firing',
+  'admin.alertOk': 'ok',
+  'admin.lastRun': 'Last run',
+  'admin.minutes.one': '{count} minute',
+  'admin.minutes.other': '{count} minutes',
+  'admin.noAlerts': 'No alert rule exists',
+  'admin.edit': 'Edit',
+  'admin.cancel': 'Cancel',
+  'admin.alertCreated': 'Added alert {name}',
+  'admin.alertUpdated': 'Updated alert {name}',
+  'admin.alertDeleted': 'Deleted alert {name}',
+
+  'compare.title': 'Compare',
+  'compare.show': 'Show Comparison',
+  'compare.hide': 'Hide Comparison',
+  'compare.loadFailed': 'Failed to compare',
+  'compare.window': 'Last',
+  'compare.baseline': 'Compared to',
+  'compare.yesterday': 'Yesterday',
+  'compare.lastWeek': 'Last week',
+  'compare.deploy': 'Before deploy',
+  'compare.deployTime': 'Deploy time',
+  'compare.run': 'Compare',
+  'compare.empty': 'No entries in either range',
+  'compare.totals': '{baseline} → {current} entries',
+  'compare.truncated': 'Only the most recent entries were counted',
+  'compare.pattern': 'Pattern',
+  'compare.app': 'App',
+  'compare.severity': 'Severity',
+  'compare.baselineCount': 'Before',
+  'compare.currentCount': 'Now',
+  'compare.delta': 'Change',
+  'compare.new': 'new',
+  'compare.gone': 'gone',
+
+  'histogram.title': 'Volume',
+  'histogram.show': 'Show Volume',
+  'histogram.hide': 'Hide Volume',
+  'histogram.loadFailed': 'Failed to load volume',
+  'histogram.range': 'Last',
+  'histogram.total.one': '{count} entry, {interval} per bar',
+  'histogram.total.other': '{count} entries, {interval} per bar',
+  'histogram.extrapolated': '~{count} messages by sample rate',
+  'histogram.empty': 'No matching entries in this range',
+  'histogram.bundle': 'Export incident bundle',
+
+  'entry.showStructuredData': 'Show Structured Data',
+  'entry.hideStructuredData': 'Hide Structured Data',
+  'entry.showRaw': 'Show Raw Message',
+  'entry.hideRaw': 'Hide Raw Message',
+  'entry.rawUnavailable': 'Raw message unavailable: {error}',
+  'entry.loading': 'Loading...',
+  'entry.swipeHint': 'Swipe the card sideways to show or hide structured data',
+  'entry.openDetail': 'Details',
+
+  'detail.title': 'Entry {id}',
+  'detail.close': 'Close details',
+  'detail.loadFailed': 'Failed to load entry: {error}',
+  'detail.message': 'Message',
+  'detail.fields': 'Fields',
+  'detail.structuredData': 'Structured Data',
+  'detail.metadata': 'Metadata',
+  'detail.sourceIp': 'Source IP',
+  'detail.chain': 'Hash chain',
+  'detail.raw': 'Raw Message',
+  'detail.noRaw': 'No raw message was retained for this entry',
+  'detail.copyCurl': 'Copy as curl',
+  'detail.copyRaw': 'Copy raw message',
+  'detail.copyField': 'Copy {field}',
+  'detail.copied': 'Copied',
+  'detail.pivot': 'Show entries with this {field}',
+
+  'logs.title': 'RFC5424 Log Stream',
+  'logs.loadingMore': 'Loading more logs...',
+  'logs.welcome': 'OpenTrail RFC5424 log viewer initialized. Waiting for log entries...',
+  'logs.scrollToBottom': 'Scroll to bottom to resume auto-scroll',
+  'logs.feed': 'Log entries',
+  'logs.liveTailOn': 'Live tail resumed',
+  'logs.liveTailOff': 'Live tail paused',
+  'logs.bufferMatches.one': '{count} match among the entries of the last {minutes} min held by the server',
+  'logs.bufferMatches.other': '{count} matches among the entries of the last {minutes} min held by the server',
+
+  'entry.summary': '{severity} from {hostname} {app} at {time}: {message}',
+  'entry.node': 'Received by cluster node {node}',
+  'entry.archived': 'Read from the archive, past retention',
+  'entry.archivedBadge': 'archive',
+
+  'shortcuts.title': 'Keyboard Shortcuts',
+  'shortcuts.close': 'Close',
+  'shortcuts.hint': 'Press ? to show keyboard shortcuts',
+  'shortcuts.action.focus-search': 'Focus message search',
+  'shortcuts.action.next-entry': 'Select next entry',
+  'shortcuts.action.previous-entry': 'Select previous entry',
+  'shortcuts.action.toggle-details': 'Show or hide structured data of the selected entry',
+  'shortcuts.action.toggle-live-tail': 'Pause or resume live tail',
+  'shortcuts.action.show-help': 'Show keyboard shortcuts',
+  'shortcuts.action.close': 'Close the dialog or leave the search field'
+} as const;
+
+export type MessageKey = keyof typeof en;
+
+// A catalog translates any subset of the English keys
+export type Catalog = Partial<Record<MessageKey, string>>;
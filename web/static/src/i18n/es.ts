@@ -0,0 +1,301 @@
+import type { Catalog } from './en';
+
+export const es: Catalog = {
+  'app.dismissError': 'Descartar error',
+  'app.loadInitialFailed': 'No se pudieron cargar los registros',
+  'app.loadMoreFailed': 'No se pudieron cargar más registros',
+  'language.label': 'Idioma',
+
+  'connection.connecting': 'Conectando...',
+  'connection.connected': 'Conectado',
+  'connection.disconnected': 'Desconectado',
+  'connection.error': 'Error de conexión',
+  'connection.reconnecting': 'Reconectando en {seconds} s...',
+
+  'filters.title': 'Filtros RFC5424',
+  'filters.show': 'Mostrar filtros',
+  'filters.hide': 'Ocultar filtros',
+  'filters.facility': 'Facility:',
+  'filters.severity': 'Severidad:',
+  'filters.minSeverity': 'Severidad mínima:',
+  'filters.all': 'Todas',
+  'filters.none': 'Ninguna',
+  'filters.hostname': 'Host:',
+  'filters.hostnamePlaceholder': 'Filtrar por host',
+  'filters.appName': 'Aplicación:',
+  'filters.appNamePlaceholder': 'Filtrar por aplicación',
+  'filters.procId': 'ID de proceso:',
+  'filters.procIdPlaceholder': 'Filtrar por ID de proceso',
+  'filters.msgId': 'ID de mensaje:',
+  'filters.msgIdPlaceholder': 'Filtrar por ID de mensaje',
+  'filters.text': 'Texto del mensaje:',
+  'filters.textPlaceholder': 'Buscar en el contenido del mensaje',
+  'filters.apply': 'Aplicar filtros',
+  'filters.clear': 'Limpiar todo',
+  'filters.removeField': 'Quitar el filtro de {field}',
+  'filters.pinned': 'Filtros fijados',
+  'filters.unpin': 'Desfijar {name}',
+  'filters.recent': 'Búsquedas recientes:',
+  'filters.recentPlaceholder': 'Repetir una búsqueda reciente',
+  'filters.pinName': 'Fijar filtros como:',
+  'filters.pinNamePlaceholder': 'Nombre de los filtros fijados',
+  'filters.pin': 'Fijar',
+
+  'severity.0': 'Emergencia',
+  'severity.1': 'Alerta',
+  'severity.2': 'Crítico',
+  'severity.3': 'Error',
+  'severity.4': 'Advertencia',
+  'severity.5': 'Aviso',
+  'severity.6': 'Información',
+  'severity.7': 'Depuración',
+
+  'display.title': 'Opciones de visualización',
+  'display.show': 'Mostrar opciones',
+  'display.hide': 'Ocultar opciones',
+  'display.fields': 'Mostrar/ocultar campos',
+  'display.layout': 'Diseño',
+  'display.separators': 'Separadores de campos',
+  'display.compact': 'Modo compacto',
+  'display.ansiColors': 'Colores de mensajes (ANSI)',
+  'display.layoutMode': 'Diseño de registros',
+  'display.layout.auto': 'Automático (tarjetas en pantallas pequeñas)',
+  'display.layout.lines': 'Líneas',
+  'display.layout.cards': 'Tarjetas',
+  'display.reset': 'Restablecer valores predeterminados',
+
+  'field.timestamp': 'Marca de tiempo',
+  'field.priority': 'Prioridad',
+  'field.facility': 'Facility',
+  'field.severity': 'Severidad',
+  'field.hostname': 'Host',
+  'field.appName': 'Aplicación',
+  'field.procId': 'ID de proceso',
+  'field.msgId': 'ID de mensaje',
+
+  'alerts.title': 'Historial de alertas',
+  'alerts.show': 'Mostrar cronología',
+  'alerts.hide': 'Ocultar cronología',
+  'alerts.loadFailed': 'No se pudo cargar el historial de alertas',
+  'alerts.empty': 'No se disparó ninguna alerta en los últimos {days} días',
+  'alerts.stillFiring': 'sigue activa',
+  'alerts.state.firing': 'disparada',
+  'alerts.state.resolved': 'resuelta',
+  'alerts.marker': '{name} {state} a las {time}',
+  'alerts.detail.one': '{state} a las {time}: {count} entrada coincidente, umbral {threshold}',
+  'alerts.detail.other': '{state} a las {time}: {count} entradas coincidentes, umbral {threshold}',
+
+  'agents.title': 'Agentes',
+  'agents.show': 'Mostrar agentes',
+  'agents.hide': 'Ocultar agentes',
+  'agents.loadFailed': 'No se pudieron cargar los agentes',
+  'agents.empty': 'Ningún agente se ha registrado',
+  'agents.hostname': 'Host',
+  'agents.status': 'Estado',
+  'agents.config': 'Configuración',
+  'agents.lag': 'Retraso',
+  'agents.lastSeen': 'Visto por última vez',
+  'agents.connected': 'conectado',
+  'agents.disconnected': 'desconectado',
+  'agents.noConfig': 'ninguna',
+  'agents.outdated': 'desactualizada',
+
+  'admin.title': 'Administración',
+  'admin.show': 'Mostrar administración',
+  'admin.hide': 'Ocultar administración',
+  'admin.loadFailed': 'Error al cargar',
+  'admin.tab.server': 'Servidor',
+  'admin.tab.storage': 'Almacenamiento',
+  'admin.tab.connections': 'Conexiones',
+  'admin.tab.sizes': 'Tamaño de entradas',
+  'admin.tab.notifications': 'Notificaciones',
+  'admin.tab.users': 'Usuarios',
+  'admin.tab.tokens': 'Tokens de API',
+  'admin.tab.alerts': 'Alertas',
+  'admin.version': 'Versión',
+  'admin.processed': 'Entradas procesadas',
+  'admin.failed': 'Entradas fallidas',
+  'admin.duplicates': 'Duplicados descartados',
+  'admin.spooled': 'En cola durante el modo degradado',
+  'admin.unstored': 'Reenviadas, no almacenadas',
+  'admin.queue': 'Cola',
+  'admin.subscribers': 'Espectadores en vivo',
+  'admin.rejectedSearches': 'Búsquedas rechazadas',
+  'admin.requests': 'Solicitudes HTTP',
+  'admin.requestErrors': 'Errores HTTP',
+  'admin.rateLimited': 'Limitadas por tasa',
+  'admin.mode': 'Modo',
+  'admin.mode.normal': 'Normal',
+  'admin.mode.read_only': 'Solo lectura',
+  'admin.mode.maintenance': 'Mantenimiento',
+  'admin.mode.degraded': 'Degradado',
+  'admin.degraded': 'Almacenamiento no disponible ({message}): las entradas se reenvían y se ponen en cola, las búsquedas están en pausa',
+  'admin.modeMessage': 'Aviso',
+  'admin.apply': 'Aplicar',
+  'admin.modeChanged': 'Modo {mode} activado',
+  'admin.database': 'Base de datos',
+  'admin.limit': 'Límite',
+  'admin.diskFree': 'Espacio libre en disco',
+  'admin.retention': 'Retención',
+  'admin.retentionDays': 'Retención en días',
+  'admin.retentionConfigured': 'Establecida en ejecución; la configuración conserva {days}',
+  'admin.retentionChanged': 'Retención establecida en {days}',
+  'admin.retentionReset': 'Retención restablecida a los {days} configurados',
+  'admin.resetRetention': 'Usar la configuración',
+  'admin.growth': 'Crecimiento por día',
+  'admin.retained': 'Tamaño con retención',
+  'admin.untilLimit': 'Límite alcanzado en',
+  'admin.days.one': '{count} día',
+  'admin.days.other': '{count} días',
+  'admin.never': 'nunca',
+  'admin.none': 'ninguno',
+  'admin.unknown': 'desconocido',
+  'admin.noConnections': 'Ningún emisor conectado',
+  'admin.remote': 'Emisor',
+  'admin.connectedAt': 'Conectado',
+  'admin.messages': 'Mensajes',
+  'admin.bytes': 'Recibido',
+  'admin.parseErrors': 'Errores de análisis',
+  'admin.close': 'Cerrar',
+  'admin.connectionClosed': 'Conexión de {remote} cerrada',
+  'admin.noChannels': 'No hay canales de notificación configurados',
+  'admin.sendTest': 'Enviar prueba',
+  'admin.testSent': 'Notificación de prueba enviada a {channel}',
+  'admin.noSizes': 'No se recibieron entradas desde el último reinicio',
+  'admin.app': 'Aplicación',
+  'admin.entries': 'Entradas',
+  'admin.messageAvg': 'Mensaje (media)',
+  'admin.messageP95': 'Mensaje p95',
+  'admin.messageMax': 'Mensaje más grande',
+  'admin.structuredDataAvg': 'Datos estructurados (media)',
+  'admin.structuredDataP95': 'Datos estructurados p95',
+  'admin.resetSizes': 'Reiniciar',
+  'admin.sizesReset': 'Tamaños de entradas reiniciados',
+  'admin.username': 'Usuario',
+  'admin.password': 'Contraseña',
+  'admin.newPassword': 'Nueva contraseña',
+  'admin.role': 'Rol',
+  'admin.role.admin': 'Administrador',
+  'admin.role.reader': 'Lector',
+  'admin.created': 'Creado',
+  'admin.add': 'Añadir',
+  'admin.save': 'Guardar',
+  'admin.delete': 'Eliminar',
+  'admin.noUsers': 'No hay usuarios aparte de las cuentas de la configuración',
+  'admin.userCreated': '{username} añadido',
+  'admin.userUpdated': '{username} actualizado',
+  'admin.userDeleted': '{username} eliminado',
+  'admin.tokenName': 'Nombre',
+  'admin.expiresIn': 'Caduca en',
+  'admin.expires': 'Caduca',
+  'admin.lastUsed': 'Último uso',
+  'admin.createdBy': 'Creado por',
+  'admin.noTokens': 'No hay tokens de API',
+  'admin.tokenCreated': 'Copia ahora el token de {name}, no se volverá a mostrar:',
+  'admin.tokenDeleted': '{name} revocado',
+  'admin.revoke': 'Revocar',
+  'admin.alertName': 'Nombre',
+  'admin.alertQuery': 'Consulta',
+  'admin.alertThreshold': 'Umbral',
+  'admin.alertInterval': 'Comprobada cada',
+  'admin.alertMinutes': 'Comprobada cada (minutos)',
+  'admin.alertExtrapolate': 'Contar mensajes muestreados',
+  'admin.alertState': 'Estado',
+  'admin.alertFiring': 'activa',
+  'admin.alertOk': 'ok',
+  'admin.lastRun': 'Última ejecución',
+  'admin.minutes.one': '{count} minuto',
+  'admin.minutes.other': '{count} minutos',
+  'admin.noAlerts': 'No existe ninguna regla de alerta',
+  'admin.edit': 'Editar',
+  'admin.cancel': 'Cancelar',
+  'admin.alertCreated': 'Alerta {name} añadida',
+  'admin.alertUpdated': 'Alerta {name} actualizada',
+  'admin.alertDeleted': 'Alerta {name} eliminada',
+
+  'compare.title': 'Comparar',
+  'compare.show': 'Mostrar comparación',
+  'compare.hide': 'Ocultar comparación',
+  'compare.loadFailed': 'No se pudo comparar',
+  'compare.window': 'Últimos',
+  'compare.baseline': 'Comparado con',
+  'compare.yesterday': 'Ayer',
+  'compare.lastWeek': 'La semana pasada',
+  'compare.deploy': 'Antes del despliegue',
+  'compare.deployTime': 'Hora del despliegue',
+  'compare.run': 'Comparar',
+  'compare.empty': 'No hay entradas en ningún rango',
+  'compare.totals': '{baseline} → {current} entradas',
+  'compare.truncated': 'Solo se contaron las entradas más recientes',
+  'compare.pattern': 'Patrón',
+  'compare.app': 'App',
+  'compare.severity': 'Severidad',
+  'compare.baselineCount': 'Antes',
+  'compare.currentCount': 'Ahora',
+  'compare.delta': 'Cambio',
+  'compare.new': 'nuevo',
+  'compare.gone': 'desaparecido',
+
+  'histogram.title': 'Volumen',
+  'histogram.show': 'Mostrar volumen',
+  'histogram.hide': 'Ocultar volumen',
+  'histogram.loadFailed': 'No se pudo cargar el volumen',
+  'histogram.range': 'Últimas',
+  'histogram.total.one': '{count} entrada, {interval} por barra',
+  'histogram.total.other': '{count} entradas, {interval} por barra',
+  'histogram.extrapolated': '~{count} mensajes según la tasa de muestreo',
+  'histogram.empty': 'No hay entradas coincidentes en este intervalo',
+  'histogram.bundle': 'Exportar paquete del incidente',
+
+  'entry.showStructuredData': 'Mostrar datos estructurados',
+  'entry.hideStructuredData': 'Ocultar datos estructurados',
+  'entry.showRaw': 'Mostrar mensaje original',
+  'entry.hideRaw': 'Ocultar mensaje original',
+  'entry.rawUnavailable': 'Mensaje original no disponible: {error}',
+  'entry.loading': 'Cargando...',
+  'entry.swipeHint': 'Desliza la tarjeta hacia un lado para mostrar u ocultar los datos estructurados',
+  'entry.openDetail': 'Detalles',
+
+  'detail.title': 'Entrada {id}',
+  'detail.close': 'Cerrar detalles',
+  'detail.loadFailed': 'No se pudo cargar la entrada: {error}',
+  'detail.message': 'Mensaje',
+  'detail.fields': 'Campos',
+  'detail.structuredData': 'Datos estructurados',
+  'detail.metadata': 'Metadatos',
+  'detail.sourceIp': 'IP de origen',
+  'detail.chain': 'Cadena de hashes',
+  'detail.raw': 'Mensaje original',
+  'detail.noRaw': 'No se conservó el mensaje original de esta entrada',
+  'detail.copyCurl': 'Copiar como curl',
+  'detail.copyRaw': 'Copiar mensaje original',
+  'detail.copyField': 'Copiar {field}',
+  'detail.copied': 'Copiado',
+  'detail.pivot': 'Filtrar por este valor de {field}',
+
+  'logs.title': 'Flujo de registros RFC5424',
+  'logs.loadingMore': 'Cargando más registros...',
+  'logs.welcome': 'Visor de registros RFC5424 de OpenTrail iniciado. Esperando entradas...',
+  'logs.scrollToBottom': 'Desplázate hasta abajo para reanudar el desplazamiento automático',
+  'logs.feed': 'Entradas de registro',
+  'logs.liveTailOn': 'Seguimiento en vivo reanudado',
+  'logs.liveTailOff': 'Seguimiento en vivo en pausa',
+  'logs.bufferMatches.one': '{count} coincidencia entre las entradas de los últimos {minutes} min que guarda el servidor',
+  'logs.bufferMatches.other': '{count} coincidencias entre las entradas de los últimos {minutes} min que guarda el servidor',
+
+  'entry.summary': '{severity} de {hostname} {app} a las {time}: {message}',
+  'entry.node': 'Recibido por el nodo del clúster {node}',
+  'entry.archived': 'Leído del archivo, fuera del periodo de retención',
+  'entry.archivedBadge': 'archivo',
+
+  'shortcuts.title': 'Atajos de teclado',
+  'shortcuts.close': 'Cerrar',
+  'shortcuts.hint': 'Pulsa ? para ver los atajos de teclado',
+  'shortcuts.action.focus-search': 'Ir a la búsqueda de mensajes',
+  'shortcuts.action.next-entry': 'Seleccionar la entrada siguiente',
+  'shortcuts.action.previous-entry': 'Seleccionar la entrada anterior',
+  'shortcuts.action.toggle-details': 'Mostrar u ocultar los datos estructurados de la entrada seleccionada',
+  'shortcuts.action.toggle-live-tail': 'Pausar o reanudar el seguimiento en vivo',
+  'shortcuts.action.show-help': 'Mostrar los atajos de teclado',
+  'shortcuts.action.close': 'Cerrar el diálogo o salir del campo de búsqueda'
+};
@@ -0,0 +1,121 @@
+import React, { createContext, useCallback, useContext, useEffect, useMemo, useState } from 'react';
+import { STORAGE_KEYS } from '../utils/constants';
+import { en, type Catalog, type MessageKey } from './en';
+import { de } from './de';
+import { es } from './es';
+
+// Supported languages with their names in that language, for the language picker
+export const LANGUAGES = {
+  en: 'English',
+  de: 'Deutsch',
+  es: 'Español'
+} as const;
+
+export type Language = keyof typeof LANGUAGES;
+
+const CATALOGS: Record<Language, Catalog> = { en, de, es };
+
+// Plural keys are looked up by their base, e.g. t('alerts.detail', { count })
+type PluralKey = MessageKey extends infer K ? (K extends `${infer Base}.other` ? Base : never) : never;
+
+export type TranslationKey = MessageKey | PluralKey;
+
+type Params = Record<string, string | number>;
+
+interface I18nContextValue {
+  language: Language;
+  setLanguage: (language: Language) => void;
+  t: (key: TranslationKey, params?: Params) => string;
+  formatNumber: (value: number) => string;
+  formatDateTime: (timestamp: string | number | Date) => string;
+}
+
+const isLanguage = (value: string): value is Language => value in LANGUAGES;
+
+// negotiateLanguage picks the first supported language of the browser's preferences, matching
+// regional variants such as de-AT by their primary subtag
+export const negotiateLanguage = (preferred: readonly string[]): Language => {
+  for (const tag of preferred) {
+    const primary = tag.toLowerCase().split('-')[0];
+    if (isLanguage(primary)) return primary;
+  }
+  return 'en';
+};
+
+const initialLanguage = (): Language => {
+  try {
+    const stored = window.localStorage.getItem(STORAGE_KEYS.LANGUAGE);
+    if (stored && isLanguage(stored)) return stored;
+  } catch {
+    // Storage may be unavailable, e.g. in private browsing
+  }
+  return negotiateLanguage(navigator.languages ?? [navigator.language]);
+};
+
+const createTranslator = (language: Language, formatNumber: (value: number) => string) => {
+  const catalog = CATALOGS[language];
+  const plurals = new Intl.PluralRules(language);
+  const lookup = (key: string): string | undefined =>
+    catalog[key as MessageKey] ?? en[key as MessageKey];
+
+  return (key: TranslationKey, params: Params = {}): string => {
+    let message = lookup(key);
+    if (message === undefined && typeof params.count === 'number') {
+      message = lookup(`${key}.${plurals.select(params.count)}`) ?? lookup(`${key}.other`);
+    }
+    if (message === undefined) return key;
+    return message.replace(/\{(\w+)\}/g, (placeholder, name: string) => {
+      const value = params[name];
+      if (value === undefined) return placeholder;
+      return typeof value === 'number' ? formatNumber(value) : value;
+    });
+  };
+};
+
+const I18nContext = createContext<I18nContextValue | null>(null);
+
+export const I18nProvider: React.FC<{ children: React.ReactNode }> = ({ children }) => {
+  const [language, setLanguageState] = useState<Language>(initialLanguage);
+
+  const setLanguage = useCallback((next: Language) => {
+    setLanguageState(next);
+    try {
+      window.localStorage.setItem(STORAGE_KEYS.LANGUAGE, next);
+    } catch {
+      // The choice then only lasts for this page view
+    }
+  }, []);
+
+  useEffect(() => {
+    document.documentElement.lang = language;
+  }, [language]);
+
+  const value = useMemo<I18nContextValue>(() => {
+    const numberFormat = new Intl.NumberFormat(language);
+    const dateTimeFormat = new Intl.DateTimeFormat(language, {
+      year: 'numeric',
+      month: '2-digit',
+      day: '2-digit',
+      hour: '2-digit',
+      minute: '2-digit',
+      second: '2-digit',
+      hour12: false
+    });
+    const formatNumber = (value: number) => numberFormat.format(value);
+    const formatDateTime = (timestamp: string | number | Date) => {
+      const date = new Date(timestamp);
+      return isNaN(date.getTime()) ? String(timestamp) : dateTimeFormat.format(date);
+    };
+    return { language, setLanguage, t: createTranslator(language, formatNumber), formatNumber, formatDateTime };
+  }, [language, setLanguage]);
+
+  return <I18nContext.Provider value={value}>{children}</I18nContext.Provider>;
+};
+
+export const useI18n = (): I18nContextValue => {
+  const context = useContext(I18nContext);
+  if (!context) {
+    throw new Error('useI18n must be used within an I18nProvider');
+  }
+  return context;
+};
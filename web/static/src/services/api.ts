@@ -1,4 +1,9 @@
-import type { LogEntry, ApiResponse } from '../types';
+import type {
+  LogEntry, ApiResponse, AlertEvent, AgentStatus, ApiToken, CompareResult, EntryDetail, HealthStatus, Histogram,
+  EntrySizes, IngestConnection, LogFilters, ModeStatus, OperatingMode, RecentBuffer, Report, RetentionStatus, Role,
+  Session, Shortcut, StorageUsage, User, UserPreferences
+} from '../types';
+import { BASE_PATH } from '../utils/constants';
 
 export class ApiService {
   private static instance: ApiService;
@@ -10,17 +15,18 @@ export class ApiService {
     return ApiService.instance;
   }
 
-  async fetchLogs(limit = 50, offset = 0): Promise<LogEntry[]> {
+  async fetchLogs(limit = 50, offset = 0, fields?: string[]): Promise<LogEntry[]> {
     try {
       const params = new URLSearchParams({
         limit: limit.toString(),
         offset: offset.toString()
       });
+      if (fields) params.set('fields', fields.join(','));
 
       const controller = new AbortController();
       const timeoutId = setTimeout(() => controller.abort(), 10000); // 10 second timeout
 
-      const response = await fetch(`/api/logs?${params}`, {
+      const response = await fetch(`${BASE_PATH}/api/logs?${params}`, {
         signal: controller.signal,
         headers: {
           'Accept': 'application/json',
@@ -38,7 +44,7 @@ export class ApiService {
       const data: ApiResponse<LogEntry[]> = await response.json();
       
       if (!data.success) {
-        throw new Error(data.error || 'API returned unsuccessful response');
+        throw new Error(data.error?.message || 'API returned unsuccessful response');
       }
 
       return data.data || [];
@@ -55,17 +61,318 @@ export class ApiService {
     }
   }
 
-  async fetchLogsBefore(beforeTimestamp: string, limit = 50): Promise<LogEntry[]> {
+  async fetchRawMessage(id: string): Promise<string> {
+    const response = await fetch(`${BASE_PATH}/api/logs/${encodeURIComponent(id)}/raw`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<{ id: number; raw: string }> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || !data.data) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data.raw;
+  }
+
+  async fetchEntryDetail(id: string): Promise<EntryDetail> {
+    const response = await fetch(`${BASE_PATH}/api/logs/${encodeURIComponent(id)}`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<EntryDetail> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || !data.data) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data;
+  }
+
+  async fetchAlertHistory(since = 'now-7d'): Promise<AlertEvent[]> {
+    const params = new URLSearchParams({ since });
+    const response = await fetch(`${BASE_PATH}/api/alerts/history?${params}`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<AlertEvent[]> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data || [];
+  }
+
+  async fetchAgents(): Promise<AgentStatus[]> {
+    const response = await fetch(`${BASE_PATH}/api/admin/agents`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<AgentStatus[]> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data || [];
+  }
+
+  async fetchComparison(params: Record<string, string>): Promise<CompareResult> {
+    const response = await fetch(`${BASE_PATH}/api/logs/compare?${new URLSearchParams(params)}`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<CompareResult> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || !data.data) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data;
+  }
+
+  async fetchHistogram(params: Record<string, string>): Promise<Histogram> {
+    const response = await fetch(`${BASE_PATH}/api/logs/histogram?${new URLSearchParams(params)}`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<Histogram> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || !data.data) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data;
+  }
+
+  async fetchShortcuts(): Promise<Shortcut[]> {
+    const response = await fetch(`${BASE_PATH}/api/ui/shortcuts`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+
+    const data: ApiResponse<Shortcut[]> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || !data.data) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data;
+  }
+
+  async fetchRecentBuffer(params: Record<string, string>): Promise<RecentBuffer> {
+    return this.request<RecentBuffer>(`/api/logs/recent-buffer?${new URLSearchParams(params)}`);
+  }
+
+  async fetchSession(): Promise<Session> {
+    return this.request<Session>('/api/ui/session');
+  }
+
+  async fetchPreferences(): Promise<UserPreferences> {
+    return this.request<UserPreferences>('/api/ui/preferences');
+  }
+
+  async savePreferences(preferences: UserPreferences): Promise<UserPreferences> {
+    return this.request<UserPreferences>('/api/ui/preferences', {
+      method: 'PUT',
+      body: JSON.stringify(preferences)
+    });
+  }
+
+  async addRecentQuery(filters: LogFilters): Promise<UserPreferences> {
+    return this.request<UserPreferences>('/api/ui/preferences/recent', {
+      method: 'POST',
+      body: JSON.stringify(filters)
+    });
+  }
+
+  async fetchHealth(): Promise<HealthStatus> {
+    const response = await fetch(`${BASE_PATH}/api/health`, {
+      headers: {
+        'Accept': 'application/json'
+      }
+    });
+    if (!response.ok) {
+      throw new Error(`HTTP ${response.status}`);
+    }
+    return response.json();
+  }
+
+  async fetchStorageUsage(): Promise<StorageUsage> {
+    return this.request<StorageUsage>('/api/admin/storage');
+  }
+
+  async fetchRetention(): Promise<RetentionStatus> {
+    return this.request<RetentionStatus>('/api/admin/retention');
+  }
+
+  async updateRetention(days: number): Promise<RetentionStatus> {
+    return this.request<RetentionStatus>('/api/admin/retention', {
+      method: 'PUT',
+      body: JSON.stringify({ days })
+    });
+  }
+
+  async resetRetention(): Promise<RetentionStatus> {
+    return this.request<RetentionStatus>('/api/admin/retention', { method: 'DELETE' });
+  }
+
+  async setMode(mode: OperatingMode, message: string): Promise<ModeStatus> {
+    return this.request<ModeStatus>('/api/admin/mode', {
+      method: 'PUT',
+      body: JSON.stringify({ mode, message })
+    });
+  }
+
+  async fetchConnections(): Promise<IngestConnection[]> {
+    return this.request<IngestConnection[]>('/api/admin/connections');
+  }
+
+  async closeConnection(id: number): Promise<void> {
+    await this.request<{ id: number }>(`/api/admin/connections/${id}`, { method: 'DELETE' });
+  }
+
+  async fetchEntrySizes(): Promise<EntrySizes> {
+    return this.request<EntrySizes>('/api/admin/ingest/sizes');
+  }
+
+  async resetEntrySizes(): Promise<void> {
+    await this.request<unknown>('/api/admin/ingest/sizes', { method: 'DELETE' });
+  }
+
+  async fetchNotificationChannels(): Promise<string[]> {
+    return this.request<string[]>('/api/admin/notifications/channels');
+  }
+
+  async sendTestNotification(channel: string): Promise<void> {
+    await this.request<unknown>(
+      `/api/admin/notifications/test?${new URLSearchParams({ channel })}`,
+      { method: 'POST' }
+    );
+  }
+
+  async fetchUsers(): Promise<User[]> {
+    return this.request<User[]>('/api/admin/users');
+  }
+
+  async createUser(username: string, password: string, role: Role): Promise<User> {
+    return this.request<User>('/api/admin/users', {
+      method: 'POST',
+      body: JSON.stringify({ username, password, role })
+    });
+  }
+
+  // updateUser changes the password unless it is empty, and the role
+  async updateUser(username: string, password: string, role: Role): Promise<User> {
+    return this.request<User>(`/api/admin/users/${encodeURIComponent(username)}`, {
+      method: 'PUT',
+      body: JSON.stringify({ password: password || undefined, role })
+    });
+  }
+
+  async deleteUser(username: string): Promise<void> {
+    await this.request<unknown>(`/api/admin/users/${encodeURIComponent(username)}`, { method: 'DELETE' });
+  }
+
+  async fetchApiTokens(): Promise<ApiToken[]> {
+    return this.request<ApiToken[]>('/api/admin/tokens');
+  }
+
+  // createApiToken returns the token with its secret, which is never shown again
+  async createApiToken(name: string, role: Role, expiresInSeconds: number): Promise<ApiToken> {
+    return this.request<ApiToken>('/api/admin/tokens', {
+      method: 'POST',
+      body: JSON.stringify({ name, role, expires_in_seconds: expiresInSeconds || undefined })
+    });
+  }
+
+  async deleteApiToken(id: number): Promise<void> {
+    await this.request<{ id: number }>(`/api/admin/tokens/${id}`, { method: 'DELETE' });
+  }
+
+  async fetchReports(): Promise<Report[]> {
+    return this.request<Report[]>('/api/reports');
+  }
+
+  async createReport(report: Omit<Report, 'id' | 'created_at'>): Promise<Report> {
+    return this.request<Report>('/api/reports', {
+      method: 'POST',
+      body: JSON.stringify(report)
+    });
+  }
+
+  async updateReport(id: number, report: Omit<Report, 'id' | 'created_at'>): Promise<Report> {
+    return this.request<Report>(`/api/reports/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(report)
+    });
+  }
+
+  async deleteReport(id: number): Promise<void> {
+    await this.request<{ id: number }>(`/api/reports/${id}`, { method: 'DELETE' });
+  }
+
+  // request calls an endpoint answering with ApiResponse and returns its data
+  private async request<T>(path: string, init: RequestInit = {}): Promise<T> {
+    const response = await fetch(`${BASE_PATH}${path}`, {
+      ...init,
+      headers: {
+        'Accept': 'application/json',
+        ...(init.body ? { 'Content-Type': 'application/json' } : {})
+      }
+    });
+
+    const data: ApiResponse<T> = await response.json().catch(() => ({
+      success: false
+    }));
+
+    if (!response.ok || !data.success || data.data === undefined) {
+      throw new Error(data.error?.message || `HTTP ${response.status}`);
+    }
+
+    return data.data;
+  }
+
+  async fetchLogsBefore(beforeTimestamp: string, limit = 50, fields?: string[]): Promise<LogEntry[]> {
     try {
       const params = new URLSearchParams({
         limit: limit.toString(),
         end_time: beforeTimestamp
       });
+      if (fields) params.set('fields', fields.join(','));
 
       const controller = new AbortController();
       const timeoutId = setTimeout(() => controller.abort(), 10000); // 10 second timeout
 
-      const response = await fetch(`/api/logs?${params}`, {
+      const response = await fetch(`${BASE_PATH}/api/logs?${params}`, {
         signal: controller.signal,
         headers: {
           'Accept': 'application/json',
@@ -83,7 +390,7 @@ export class ApiService {
       const data: ApiResponse<LogEntry[]> = await response.json();
       
       if (!data.success) {
-        throw new Error(data.error || 'API returned unsuccessful response');
+        throw new Error(data.error?.message || 'API returned unsuccessful response');
       }
 
       return data.data || [];
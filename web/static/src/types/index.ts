@@ -1,5 +1,7 @@
 export interface LogEntry {
   id: string;
+  // Globally unique, time-sortable identifier, set when the server generates entry IDs
+  uid?: string;
   timestamp: string;
   priority: number;
   facility: number;
@@ -10,6 +12,17 @@ export interface LogEntry {
   msg_id: string;
   message: string;
   structured_data?: Record<string, any>;
+  // Node of the cluster that received the entry, set on the live stream with cluster peers
+  node?: string;
+  // Read from an archived dump past retention rather than the database
+  archived?: boolean;
+  // Matches of a text search in message, in characters
+  highlights?: TextRange[];
+}
+
+export interface TextRange {
+  start: number;
+  end: number;
 }
 
 export interface LogFilters {
@@ -21,6 +34,21 @@ export interface LogFilters {
   procId?: string;
   msgId?: string;
   text?: string;
+  // Structured data parameters ("sdid.param") that must equal the given values
+  structuredData?: Record<string, string>;
+}
+
+// An entry with what was recorded about it, from /api/logs/{id}
+export interface EntryDetail {
+  entry: LogEntry & { version: number; created_at: string };
+  raw?: string;
+  source_ip?: string;
+  annotations?: Record<string, string>;
+  chain?: {
+    partition: string;
+    prev?: string;
+    hash: string;
+  };
 }
 
 export interface DisplayOptions {
@@ -34,11 +62,20 @@ export interface DisplayOptions {
   showMsgId: boolean;
   showSeparators: boolean;
   compactMode: boolean;
+  // Missing in options stored before layouts existed, which means 'auto'
+  layout?: LogLayout;
+  // Renders ANSI colors kept in messages; missing in options stored before, which means true
+  ansiColors?: boolean;
 }
 
+// 'auto' shows cards on narrow screens and lines elsewhere
+export type LogLayout = 'auto' | 'lines' | 'cards';
+
 export interface ConnectionStatus {
   status: 'connected' | 'connecting' | 'disconnected' | 'error';
   text: string;
+  // Seconds until the next reconnection attempt, while waiting to reconnect
+  retryIn?: number;
 }
 
 export interface SeverityInfo {
@@ -46,8 +83,276 @@ export interface SeverityInfo {
   class: string;
 }
 
+export interface AlertEvent {
+  id: number;
+  report_id: number;
+  report_name: string;
+  state: 'firing' | 'resolved';
+  time: string;
+  count: number;
+  threshold: number;
+  // Newest matching entries of the window that made the alert fire
+  samples?: LogEntry[];
+}
+
+// How far an agent has shipped one of the files it tails
+export interface TailPosition {
+  path: string;
+  offset: number;
+  size: number;
+}
+
+export interface AgentStatus {
+  id: string;
+  hostname: string;
+  version?: string;
+  registered_at: string;
+  last_seen: string;
+  connected: boolean;
+  // Name of the configuration assigned to the agent, absent if none applies
+  config?: string;
+  config_hash?: string;
+  config_current: boolean;
+  positions: TailPosition[];
+  // Bytes of the tailed files not shipped yet
+  lag_bytes: number;
+}
+
+export interface SeverityDelta {
+  severity: number;
+  baseline: number;
+  current: number;
+  delta: number;
+}
+
+// Change in the entries of one message pattern, whose variable parts are replaced by <*>
+export interface PatternDelta {
+  pattern: string;
+  app_name: string;
+  severity: number;
+  example: string;
+  baseline: number;
+  current: number;
+  delta: number;
+  new?: boolean;
+  gone?: boolean;
+}
+
+export interface CompareRange {
+  start_time: string;
+  end_time: string;
+  count: number;
+  truncated: boolean;
+}
+
+export interface CompareResult {
+  baseline: CompareRange;
+  current: CompareRange;
+  severities: SeverityDelta[];
+  patterns: PatternDelta[];
+  total_patterns: number;
+}
+
+// Number of entries in one interval, per group (a severity when grouped by severity)
+export interface HistogramBucket {
+  time: string;
+  group?: string;
+  count: number;
+  // Messages the entries stand for by their sample rates
+  extrapolated: number;
+}
+
+export interface Histogram {
+  start_time: string;
+  end_time: string;
+  interval: string;
+  time_zone: string;
+  group_by?: string;
+  total: number;
+  extrapolated_total: number;
+  buckets: HistogramBucket[];
+}
+
+// Entries of the last minutes the server holds in memory, from /api/logs/recent-buffer
+export interface RecentBuffer {
+  // Matching entries, the last received first
+  entries: LogEntry[];
+  // Entries held, matching or not
+  buffered: number;
+  // When the oldest entry held was received
+  since?: string;
+  window_seconds: number;
+}
+
+// A keyboard shortcut of the interface; any of the keys (KeyboardEvent.key values) triggers the action
+export interface Shortcut {
+  action: string;
+  keys: string[];
+  description: string;
+}
+
+export type Role = 'admin' | 'reader';
+
+// The user of the interface; the admin section is shown to the admin role
+export interface Session {
+  user?: string;
+  role: Role;
+}
+
+// A user managed in the admin section, next to the accounts of the server's configuration
+export interface User {
+  username: string;
+  role: Role;
+  created_at: string;
+  updated_at: string;
+}
+
+// An API token; token is only set in the response creating it
+export interface ApiToken {
+  id: number;
+  name: string;
+  role: Role;
+  token?: string;
+  created_by?: string;
+  created_at: string;
+  expires_at?: string;
+  last_used_at?: string;
+}
+
+// A scheduled report, an alert rule when alert_threshold is set
+export interface Report {
+  id: number;
+  name: string;
+  query?: string;
+  top_field?: string;
+  top_limit?: number;
+  interval_seconds: number;
+  alert_threshold?: number;
+  extrapolate?: boolean;
+  firing?: boolean;
+  created_at: string;
+  last_run_at?: string;
+}
+
+// Filters saved under a name in the user's preferences
+export interface PinnedFilter {
+  name: string;
+  filters: LogFilters;
+}
+
+// The web interface preferences the server keeps per user, from /api/ui/preferences
+export interface UserPreferences {
+  display?: Partial<DisplayOptions>;
+  pinned_filters: PinnedFilter[];
+  // Newest first
+  recent_queries: LogFilters[];
+  updated_at?: string;
+}
+
+export interface ServiceStats {
+  processed_logs: number;
+  failed_logs: number;
+  queue_size: number;
+  active_subscribers: number;
+  rejected_searches: number;
+  rejected_logs: number;
+  duplicate_logs: number;
+  spooled_logs: number;
+  unstored_logs: number;
+}
+
+export interface HttpServerStats {
+  requests_handled: number;
+  request_errors: number;
+  active_websockets: number;
+  rate_limited: number;
+}
+
+// degraded is entered by the server while storage is down and cannot be switched to
+export type OperatingMode = 'normal' | 'read_only' | 'maintenance' | 'degraded';
+
+export interface ModeStatus {
+  mode: OperatingMode;
+  message?: string;
+  since?: string;
+}
+
+// The /api/health response, which is not wrapped in ApiResponse
+export interface HealthStatus {
+  status: string;
+  version: string;
+  services: {
+    log_service: ServiceStats;
+    http_server: HttpServerStats;
+    mode: ModeStatus;
+  };
+}
+
+export interface StorageUsage {
+  database_bytes: number;
+  wal_bytes: number;
+  disk_free_bytes: number;
+  limit_bytes: number;
+  retention_days: number;
+  growth_bytes_per_day: number;
+  retained_bytes: number;
+  days_until_limit?: number;
+}
+
+// The retention period in effect, which admins can set at runtime over the configured one
+export interface RetentionStatus {
+  days: number;
+  configured_days: number;
+  overridden: boolean;
+}
+
+// An open ingestion connection
+export interface IngestConnection {
+  id: number;
+  remote_addr: string;
+  connected_at: string;
+  messages_received: number;
+  bytes_received: number;
+  parse_errors: number;
+  tenant?: string;
+}
+
+// Sizes counted into the buckets of EntrySizes, with percentiles estimated from them
+export interface SizeHistogram {
+  counts: number[];
+  sum_bytes: number;
+  max_bytes: number;
+  avg_bytes: number;
+  p50_bytes: number;
+  p95_bytes: number;
+  p99_bytes: number;
+}
+
+// Message and structured data sizes of the entries of one app, '-' for entries without one
+export interface AppEntrySizes {
+  app_name: string;
+  entries: number;
+  last_seen: string;
+  message: SizeHistogram;
+  structured_data: SizeHistogram;
+}
+
+export interface EntrySizes {
+  buckets: number[];
+  apps: AppEntrySizes[];
+}
+
+// Why a request failed; code is one of the documented error codes
+export interface ApiError {
+  code: string;
+  message: string;
+  doc_url?: string;
+}
+
 export interface ApiResponse<T> {
   success: boolean;
   data?: T;
-  error?: string;
+  error?: ApiError;
+  // Identifies a failed request in the server's logs
+  request_id?: string;
 }
\ No newline at end of file
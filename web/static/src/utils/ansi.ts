@@ -0,0 +1,138 @@
+import type { TextRange } from '../types';
+
+// ANSI escape sequences: CSI sequences such as colors and cursor movements, OSC sequences such as
+// window titles and hyperlinks, and two-character escapes. Only colors and styles are rendered;
+// every other sequence is dropped, whatever the server kept.
+const ANSI_SEQUENCE = /\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])/g;
+
+// SGR_SEQUENCE is a Select Graphic Rendition sequence, which sets colors and text styles
+const SGR_SEQUENCE = /^\x1b\[([0-9;:]*)m$/;
+
+// A color is an index into the 256-color palette or an RGB hex color
+export type AnsiColor = number | string;
+
+export interface AnsiStyle {
+  fg?: AnsiColor;
+  bg?: AnsiColor;
+  bold?: boolean;
+  dim?: boolean;
+  italic?: boolean;
+  underline?: boolean;
+}
+
+export interface MessageSegment {
+  text: string;
+  style: AnsiStyle;
+  match: boolean;
+}
+
+// stripAnsi removes the escape sequences of a message, for labels and copying
+export const stripAnsi = (text: string): string => text.replace(ANSI_SEQUENCE, '');
+
+const CUBE_LEVELS = [0, 95, 135, 175, 215, 255];
+
+const hex = (r: number, g: number, b: number): string =>
+  '#' + [r, g, b].map(value => Math.min(255, Math.max(0, value)).toString(16).padStart(2, '0')).join('');
+
+// colorOf returns the palette index of colors 0-15, which CSS styles, and the hex color of the rest
+export const colorOf = (color: AnsiColor): string | number => {
+  if (typeof color === 'string' || color < 16) return color;
+  if (color >= 232) {
+    const gray = 8 + (color - 232) * 10;
+    return hex(gray, gray, gray);
+  }
+  const cube = color - 16;
+  return hex(CUBE_LEVELS[Math.floor(cube / 36)], CUBE_LEVELS[Math.floor(cube / 6) % 6], CUBE_LEVELS[cube % 6]);
+};
+
+// extendedColor reads the palette index (5;n) or RGB color (2;r;g;b) following a 38 or 48 code
+const extendedColor = (values: number[]): AnsiColor | undefined => {
+  if (values[0] === 5 && values.length >= 2 && values[1] <= 255) return values[1];
+  if (values[0] === 2 && values.length >= 4) return hex(values[1], values[2], values[3]);
+  return undefined;
+};
+
+// applySgr returns the style after the parameters of an SGR sequence
+const applySgr = (style: AnsiStyle, params: string): AnsiStyle => {
+  const next = { ...style };
+  const groups = params.split(';');
+  for (let i = 0; i < groups.length; i++) {
+    // Colons separate the sub-parameters of 38 and 48 in one group, as in 38:2::255:0:0
+    const parts = groups[i].split(':').map(part => (part === '' ? 0 : Number(part)));
+    const code = parts[0];
+    if (code === 38 || code === 48) {
+      let color: AnsiColor | undefined;
+      if (parts.length > 1) {
+        // The colon form may name a color space before the RGB values
+        color = parts[1] === 2 ? extendedColor([2, ...parts.slice(-3)]) : extendedColor(parts.slice(1));
+      } else {
+        const mode = Number(groups[i + 1]);
+        const count = mode === 5 ? 2 : mode === 2 ? 4 : 1;
+        color = extendedColor(groups.slice(i + 1, i + 1 + count).map(Number));
+        i += count;
+      }
+      if (color !== undefined) {
+        if (code === 38) next.fg = color;
+        else next.bg = color;
+      }
+      continue;
+    }
+    if (code === 0) {
+      for (const key of Object.keys(next) as (keyof AnsiStyle)[]) delete next[key];
+    } else if (code === 1) next.bold = true;
+    else if (code === 2) next.dim = true;
+    else if (code === 3) next.italic = true;
+    else if (code === 4) next.underline = true;
+    else if (code === 22) next.bold = next.dim = false;
+    else if (code === 23) next.italic = false;
+    else if (code === 24) next.underline = false;
+    else if (code >= 30 && code <= 37) next.fg = code - 30;
+    else if (code === 39) delete next.fg;
+    else if (code >= 40 && code <= 47) next.bg = code - 40;
+    else if (code === 49) delete next.bg;
+    else if (code >= 90 && code <= 97) next.fg = code - 90 + 8;
+    else if (code >= 100 && code <= 107) next.bg = code - 100 + 8;
+  }
+  return next;
+};
+
+// splitMessage splits a message into segments of one style that are either all inside or all
+// outside the highlighted ranges. Ranges count characters (code points) of the message as stored,
+// escape sequences included. Without colors the sequences are only dropped.
+export const splitMessage = (
+  text: string,
+  ranges: TextRange[] = [],
+  colors = true
+): MessageSegment[] => {
+  const sorted = [...ranges].sort((a, b) => a.start - b.start);
+  const segments: MessageSegment[] = [];
+  let style: AnsiStyle = {};
+  let position = 0;
+  let range = 0;
+
+  const pushText = (chunk: string) => {
+    for (const char of chunk) {
+      while (range < sorted.length && sorted[range].end <= position) range++;
+      const match = range < sorted.length && sorted[range].start <= position;
+      const last = segments[segments.length - 1];
+      if (last && last.match === match && last.style === style) {
+        last.text += char;
+      } else {
+        segments.push({ text: char, style, match });
+      }
+      position++;
+    }
+  };
+
+  let offset = 0;
+  for (const sequence of text.matchAll(ANSI_SEQUENCE)) {
+    const index = sequence.index ?? 0;
+    pushText(text.slice(offset, index));
+    const sgr = colors ? SGR_SEQUENCE.exec(sequence[0]) : null;
+    if (sgr) style = applySgr(style, sgr[1]);
+    position += Array.from(sequence[0]).length;
+    offset = index + sequence[0].length;
+  }
+  pushText(text.slice(offset));
+  return segments;
+};
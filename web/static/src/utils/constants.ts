@@ -1,3 +1,8 @@
+import type { Shortcut } from '../types';
+
+// Path prefix the UI is served under (e.g. "/logs"), injected by the server when running behind a shared ingress
+export const BASE_PATH: string = (window as { __OPENTRAIL_BASE_PATH__?: string }).__OPENTRAIL_BASE_PATH__ ?? '';
+
 export const FACILITIES = {
   0: 'Kernel',
   1: 'User',
@@ -42,9 +47,29 @@ export const DEFAULT_DISPLAY_OPTIONS = {
   showProcId: true,
   showMsgId: true,
   showSeparators: true,
-  compactMode: false
+  compactMode: false,
+  layout: 'auto' as const,
+  ansiColors: true
 };
 
+// Screens at most this wide use the card layout when the layout is 'auto'
+export const NARROW_SCREEN_QUERY = '(max-width: 768px)';
+
+// Fields the card layout shows, requested through the fields selection of /api/logs to keep payloads small
+export const CARD_RESULT_FIELDS = ['id', 'severity', 'timestamp', 'hostname', 'app_name', 'message', 'structured_data'];
+
+// Keyboard map used until the server's map (/api/ui/shortcuts) has loaded, and if it cannot be loaded
+export const DEFAULT_SHORTCUTS: Shortcut[] = [
+  { action: 'focus-search', keys: ['/'], description: 'Focus message search' },
+  { action: 'next-entry', keys: ['j', 'ArrowDown'], description: 'Select next entry' },
+  { action: 'previous-entry', keys: ['k', 'ArrowUp'], description: 'Select previous entry' },
+  { action: 'toggle-details', keys: ['Enter'], description: 'Show or hide structured data of the selected entry' },
+  { action: 'toggle-live-tail', keys: ['l'], description: 'Pause or resume live tail' },
+  { action: 'show-help', keys: ['?'], description: 'Show keyboard shortcuts' },
+  { action: 'close', keys: ['Escape'], description: 'Close the dialog or leave the search field' }
+];
+
 export const STORAGE_KEYS = {
-  DISPLAY_OPTIONS: 'opentrail-display-options'
+  DISPLAY_OPTIONS: 'opentrail-display-options',
+  LANGUAGE: 'opentrail-language'
 } as const;
\ No newline at end of file
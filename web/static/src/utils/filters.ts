@@ -0,0 +1,29 @@
+import type { LogFilters } from '../types';
+
+// filterParams turns the filters of the log view into search parameters. Text and structured data
+// go into a q expression so they are quoted rather than read as query syntax.
+export const filterParams = (filters: LogFilters): Record<string, string> => {
+  const params: Record<string, string> = {};
+  const numeric: [keyof LogFilters, string][] = [
+    ['facility', 'facility'],
+    ['severity', 'severity'],
+    ['minSeverity', 'min_severity']
+  ];
+  for (const [key, param] of numeric) {
+    const value = filters[key];
+    if (value !== null && value !== undefined) params[param] = String(value);
+  }
+  if (filters.hostname) params.hostname = filters.hostname;
+  if (filters.appName) params.app_name = filters.appName;
+  if (filters.procId) params.proc_id = filters.procId;
+  if (filters.msgId) params.msg_id = filters.msgId;
+
+  const quote = (value: string) => `"${value.replace(/"/g, '')}"`;
+  const terms: string[] = [];
+  if (filters.text) terms.push(quote(filters.text));
+  for (const [field, value] of Object.entries(filters.structuredData ?? {})) {
+    terms.push(`${field}=${quote(value)}`);
+  }
+  if (terms.length > 0) params.q = terms.join(' ');
+  return params;
+};
@@ -1,23 +1,6 @@
 import { FACILITIES, SEVERITIES } from './constants';
 import type { SeverityInfo } from '../types';
 
-export const formatTimestamp = (timestamp: string): string => {
-  try {
-    const date = new Date(timestamp);
-    return date.toLocaleString('en-US', {
-      year: 'numeric',
-      month: '2-digit',
-      day: '2-digit',
-      hour: '2-digit',
-      minute: '2-digit',
-      second: '2-digit',
-      hour12: false
-    });
-  } catch (error) {
-    return timestamp;
-  }
-};
-
 export const getFacilityName = (facility: number): string => {
   return FACILITIES[facility as keyof typeof FACILITIES] || `F${facility}`;
 };
@@ -35,14 +18,15 @@ export const escapeHtml = (text: string): string => {
   return div.innerHTML;
 };
 
-export const getCurrentTime = (): string => {
-  return new Date().toLocaleString('en-US', {
-    year: 'numeric',
-    month: '2-digit',
-    day: '2-digit',
-    hour: '2-digit',
-    minute: '2-digit',
-    second: '2-digit',
-    hour12: false
-  });
-};
\ No newline at end of file
+const BYTE_UNITS = ['B', 'KiB', 'MiB', 'GiB', 'TiB'];
+
+// splitBytes picks the largest binary unit in which a byte count is at least 1
+export const splitBytes = (bytes: number): [number, string] => {
+  let value = bytes;
+  let unit = 0;
+  while (value >= 1024 && unit < BYTE_UNITS.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return [Math.round(value * 10) / 10, BYTE_UNITS[unit]];
+};